@@ -18,6 +18,7 @@ import (
 	"github.com/hashicorp/memberlist"
 
 	ucli "github.com/actiontech/dtle/internal/client"
+	"github.com/actiontech/dtle/internal/client/driver"
 	uconf "github.com/actiontech/dtle/internal/config"
 	ulog "github.com/actiontech/dtle/internal/logger"
 	umodel "github.com/actiontech/dtle/internal/models"
@@ -51,6 +52,9 @@ func NewAgent(config *Config, logOutput io.Writer, log *ulog.Logger) (*Agent, er
 		logOutput:  logOutput,
 		shutdownCh: make(chan struct{}),
 	}
+	if err := a.setupPlugins(); err != nil {
+		return nil, err
+	}
 	if err := a.setupServer(); err != nil {
 		return nil, err
 	}
@@ -64,6 +68,19 @@ func NewAgent(config *Config, logOutput io.Writer, log *ulog.Logger) (*Agent, er
 	return a, nil
 }
 
+// setupPlugins loads the driver plugins, for the clients to run their
+// tasks and the servers to validate them
+func (a *Agent) setupPlugins() error {
+	dir := a.config.PluginDir
+	if dir == "" {
+		if a.config.DataDir == "" {
+			return nil
+		}
+		dir = filepath.Join(a.config.DataDir, "plugins")
+	}
+	return driver.LoadPlugins(dir, a.logger)
+}
+
 // convertServerConfig takes an agent config and log output and returns a Udup Config.
 func convertServerConfig(agentConfig *Config, logOutput io.Writer) (*uconf.ServerConfig, error) {
 	conf := agentConfig.UdupConfig
@@ -145,6 +162,29 @@ func convertServerConfig(agentConfig *Config, logOutput io.Writer) (*uconf.Serve
 		conf.HeartbeatGrace = dur
 	}
 
+	conf.CleanupDeadServers = agentConfig.Server.CleanupDeadServers
+	if threshold := agentConfig.Server.DeadServerThreshold; threshold != "" {
+		dur, err := time.ParseDuration(threshold)
+		if err != nil {
+			return nil, err
+		}
+		conf.DeadServerThreshold = dur
+	}
+	if interval := agentConfig.Server.BinlogRetentionInterval; interval != "" {
+		dur, err := time.ParseDuration(interval)
+		if err != nil {
+			return nil, err
+		}
+		conf.BinlogRetentionInterval = dur
+	}
+
+	for _, webhook := range agentConfig.Server.Webhooks {
+		if err := webhook.Validate(); err != nil {
+			return nil, err
+		}
+	}
+	conf.Webhooks = agentConfig.Server.Webhooks
+
 	if *agentConfig.Consul.AutoAdvertise && agentConfig.Consul.ServerServiceName == "" {
 		return nil, fmt.Errorf("server_service_name must be set when auto_advertise is enabled")
 	}
@@ -206,6 +246,9 @@ func (a *Agent) clientConfig() (*uconf.ClientConfig, error) {
 	conf.PublishAllocationMetrics = a.config.Metric.PublishAllocationMetrics
 
 	conf.NoHostUUID = a.config.Client.NoHostUUID
+	if a.config.Client.DrainTimeout != nil {
+		conf.DrainTimeout = *a.config.Client.DrainTimeout
+	}
 
 	return conf, nil
 }
@@ -58,7 +58,7 @@ func (s *HTTPServer) AgentSelfRequest(resp http.ResponseWriter, req *http.Reques
 	}
 
 	self := agentSelf{
-		Config: s.agent.config,
+		Config: redactedConfig(s.agent.config),
 		Member: udupMember(member),
 		Stats:  s.agent.Stats(),
 	}
@@ -10,8 +10,8 @@ import (
 	"bytes"
 	"io"
 	"reflect"
-	"sync"
 	"testing"
+
 	ucli "github.com/actiontech/dtle/internal/client"
 	uconf "github.com/actiontech/dtle/internal/config"
 	ulog "github.com/actiontech/dtle/internal/logger"
@@ -83,14 +83,13 @@ func Test_convertServerConfig(t *testing.T) {
 
 func TestAgent_serverConfig(t *testing.T) {
 	type fields struct {
-		config       *Config
-		logger       *ulog.Logger
-		logOutput    io.Writer
-		client       *ucli.Client
-		server       *usrv.Server
-		shutdown     bool
-		shutdownCh   chan struct{}
-		shutdownLock sync.Mutex
+		config     *Config
+		logger     *ulog.Logger
+		logOutput  io.Writer
+		client     *ucli.Client
+		server     *usrv.Server
+		shutdown   bool
+		shutdownCh chan struct{}
 	}
 	tests := []struct {
 		name    string
@@ -103,14 +102,13 @@ func TestAgent_serverConfig(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			a := &Agent{
-				config:       tt.fields.config,
-				logger:       tt.fields.logger,
-				logOutput:    tt.fields.logOutput,
-				client:       tt.fields.client,
-				server:       tt.fields.server,
-				shutdown:     tt.fields.shutdown,
-				shutdownCh:   tt.fields.shutdownCh,
-				shutdownLock: tt.fields.shutdownLock,
+				config:     tt.fields.config,
+				logger:     tt.fields.logger,
+				logOutput:  tt.fields.logOutput,
+				client:     tt.fields.client,
+				server:     tt.fields.server,
+				shutdown:   tt.fields.shutdown,
+				shutdownCh: tt.fields.shutdownCh,
 			}
 			got, err := a.serverConfig()
 			if (err != nil) != tt.wantErr {
@@ -126,14 +124,13 @@ func TestAgent_serverConfig(t *testing.T) {
 
 func TestAgent_clientConfig(t *testing.T) {
 	type fields struct {
-		config       *Config
-		logger       *ulog.Logger
-		logOutput    io.Writer
-		client       *ucli.Client
-		server       *usrv.Server
-		shutdown     bool
-		shutdownCh   chan struct{}
-		shutdownLock sync.Mutex
+		config     *Config
+		logger     *ulog.Logger
+		logOutput  io.Writer
+		client     *ucli.Client
+		server     *usrv.Server
+		shutdown   bool
+		shutdownCh chan struct{}
 	}
 	tests := []struct {
 		name    string
@@ -146,14 +143,13 @@ func TestAgent_clientConfig(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			a := &Agent{
-				config:       tt.fields.config,
-				logger:       tt.fields.logger,
-				logOutput:    tt.fields.logOutput,
-				client:       tt.fields.client,
-				server:       tt.fields.server,
-				shutdown:     tt.fields.shutdown,
-				shutdownCh:   tt.fields.shutdownCh,
-				shutdownLock: tt.fields.shutdownLock,
+				config:     tt.fields.config,
+				logger:     tt.fields.logger,
+				logOutput:  tt.fields.logOutput,
+				client:     tt.fields.client,
+				server:     tt.fields.server,
+				shutdown:   tt.fields.shutdown,
+				shutdownCh: tt.fields.shutdownCh,
 			}
 			got, err := a.clientConfig()
 			if (err != nil) != tt.wantErr {
@@ -169,14 +165,13 @@ func TestAgent_clientConfig(t *testing.T) {
 
 func TestAgent_setupServer(t *testing.T) {
 	type fields struct {
-		config       *Config
-		logger       *ulog.Logger
-		logOutput    io.Writer
-		client       *ucli.Client
-		server       *usrv.Server
-		shutdown     bool
-		shutdownCh   chan struct{}
-		shutdownLock sync.Mutex
+		config     *Config
+		logger     *ulog.Logger
+		logOutput  io.Writer
+		client     *ucli.Client
+		server     *usrv.Server
+		shutdown   bool
+		shutdownCh chan struct{}
 	}
 	tests := []struct {
 		name    string
@@ -188,14 +183,13 @@ func TestAgent_setupServer(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			a := &Agent{
-				config:       tt.fields.config,
-				logger:       tt.fields.logger,
-				logOutput:    tt.fields.logOutput,
-				client:       tt.fields.client,
-				server:       tt.fields.server,
-				shutdown:     tt.fields.shutdown,
-				shutdownCh:   tt.fields.shutdownCh,
-				shutdownLock: tt.fields.shutdownLock,
+				config:     tt.fields.config,
+				logger:     tt.fields.logger,
+				logOutput:  tt.fields.logOutput,
+				client:     tt.fields.client,
+				server:     tt.fields.server,
+				shutdown:   tt.fields.shutdown,
+				shutdownCh: tt.fields.shutdownCh,
 			}
 			if err := a.setupServer(); (err != nil) != tt.wantErr {
 				t.Errorf("Agent.setupServer() error = %v, wantErr %v", err, tt.wantErr)
@@ -206,14 +200,13 @@ func TestAgent_setupServer(t *testing.T) {
 
 func TestAgent_setupClient(t *testing.T) {
 	type fields struct {
-		config       *Config
-		logger       *ulog.Logger
-		logOutput    io.Writer
-		client       *ucli.Client
-		server       *usrv.Server
-		shutdown     bool
-		shutdownCh   chan struct{}
-		shutdownLock sync.Mutex
+		config     *Config
+		logger     *ulog.Logger
+		logOutput  io.Writer
+		client     *ucli.Client
+		server     *usrv.Server
+		shutdown   bool
+		shutdownCh chan struct{}
 	}
 	tests := []struct {
 		name    string
@@ -225,14 +218,13 @@ func TestAgent_setupClient(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			a := &Agent{
-				config:       tt.fields.config,
-				logger:       tt.fields.logger,
-				logOutput:    tt.fields.logOutput,
-				client:       tt.fields.client,
-				server:       tt.fields.server,
-				shutdown:     tt.fields.shutdown,
-				shutdownCh:   tt.fields.shutdownCh,
-				shutdownLock: tt.fields.shutdownLock,
+				config:     tt.fields.config,
+				logger:     tt.fields.logger,
+				logOutput:  tt.fields.logOutput,
+				client:     tt.fields.client,
+				server:     tt.fields.server,
+				shutdown:   tt.fields.shutdown,
+				shutdownCh: tt.fields.shutdownCh,
 			}
 			if err := a.setupClient(); (err != nil) != tt.wantErr {
 				t.Errorf("Agent.setupClient() error = %v, wantErr %v", err, tt.wantErr)
@@ -243,14 +235,13 @@ func TestAgent_setupClient(t *testing.T) {
 
 func TestAgent_Leave(t *testing.T) {
 	type fields struct {
-		config       *Config
-		logger       *ulog.Logger
-		logOutput    io.Writer
-		client       *ucli.Client
-		server       *usrv.Server
-		shutdown     bool
-		shutdownCh   chan struct{}
-		shutdownLock sync.Mutex
+		config     *Config
+		logger     *ulog.Logger
+		logOutput  io.Writer
+		client     *ucli.Client
+		server     *usrv.Server
+		shutdown   bool
+		shutdownCh chan struct{}
 	}
 	tests := []struct {
 		name    string
@@ -262,14 +253,13 @@ func TestAgent_Leave(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			a := &Agent{
-				config:       tt.fields.config,
-				logger:       tt.fields.logger,
-				logOutput:    tt.fields.logOutput,
-				client:       tt.fields.client,
-				server:       tt.fields.server,
-				shutdown:     tt.fields.shutdown,
-				shutdownCh:   tt.fields.shutdownCh,
-				shutdownLock: tt.fields.shutdownLock,
+				config:     tt.fields.config,
+				logger:     tt.fields.logger,
+				logOutput:  tt.fields.logOutput,
+				client:     tt.fields.client,
+				server:     tt.fields.server,
+				shutdown:   tt.fields.shutdown,
+				shutdownCh: tt.fields.shutdownCh,
 			}
 			if err := a.Leave(); (err != nil) != tt.wantErr {
 				t.Errorf("Agent.Leave() error = %v, wantErr %v", err, tt.wantErr)
@@ -280,14 +270,13 @@ func TestAgent_Leave(t *testing.T) {
 
 func TestAgent_Shutdown(t *testing.T) {
 	type fields struct {
-		config       *Config
-		logger       *ulog.Logger
-		logOutput    io.Writer
-		client       *ucli.Client
-		server       *usrv.Server
-		shutdown     bool
-		shutdownCh   chan struct{}
-		shutdownLock sync.Mutex
+		config     *Config
+		logger     *ulog.Logger
+		logOutput  io.Writer
+		client     *ucli.Client
+		server     *usrv.Server
+		shutdown   bool
+		shutdownCh chan struct{}
 	}
 	tests := []struct {
 		name    string
@@ -299,14 +288,13 @@ func TestAgent_Shutdown(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			a := &Agent{
-				config:       tt.fields.config,
-				logger:       tt.fields.logger,
-				logOutput:    tt.fields.logOutput,
-				client:       tt.fields.client,
-				server:       tt.fields.server,
-				shutdown:     tt.fields.shutdown,
-				shutdownCh:   tt.fields.shutdownCh,
-				shutdownLock: tt.fields.shutdownLock,
+				config:     tt.fields.config,
+				logger:     tt.fields.logger,
+				logOutput:  tt.fields.logOutput,
+				client:     tt.fields.client,
+				server:     tt.fields.server,
+				shutdown:   tt.fields.shutdown,
+				shutdownCh: tt.fields.shutdownCh,
 			}
 			if err := a.Shutdown(); (err != nil) != tt.wantErr {
 				t.Errorf("Agent.Shutdown() error = %v, wantErr %v", err, tt.wantErr)
@@ -317,14 +305,13 @@ func TestAgent_Shutdown(t *testing.T) {
 
 func TestAgent_RPC(t *testing.T) {
 	type fields struct {
-		config       *Config
-		logger       *ulog.Logger
-		logOutput    io.Writer
-		client       *ucli.Client
-		server       *usrv.Server
-		shutdown     bool
-		shutdownCh   chan struct{}
-		shutdownLock sync.Mutex
+		config     *Config
+		logger     *ulog.Logger
+		logOutput  io.Writer
+		client     *ucli.Client
+		server     *usrv.Server
+		shutdown   bool
+		shutdownCh chan struct{}
 	}
 	type args struct {
 		method string
@@ -342,14 +329,13 @@ func TestAgent_RPC(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			a := &Agent{
-				config:       tt.fields.config,
-				logger:       tt.fields.logger,
-				logOutput:    tt.fields.logOutput,
-				client:       tt.fields.client,
-				server:       tt.fields.server,
-				shutdown:     tt.fields.shutdown,
-				shutdownCh:   tt.fields.shutdownCh,
-				shutdownLock: tt.fields.shutdownLock,
+				config:     tt.fields.config,
+				logger:     tt.fields.logger,
+				logOutput:  tt.fields.logOutput,
+				client:     tt.fields.client,
+				server:     tt.fields.server,
+				shutdown:   tt.fields.shutdown,
+				shutdownCh: tt.fields.shutdownCh,
 			}
 			if err := a.RPC(tt.args.method, tt.args.args, tt.args.reply); (err != nil) != tt.wantErr {
 				t.Errorf("Agent.RPC() error = %v, wantErr %v", err, tt.wantErr)
@@ -360,14 +346,13 @@ func TestAgent_RPC(t *testing.T) {
 
 func TestAgent_Client(t *testing.T) {
 	type fields struct {
-		config       *Config
-		logger       *ulog.Logger
-		logOutput    io.Writer
-		client       *ucli.Client
-		server       *usrv.Server
-		shutdown     bool
-		shutdownCh   chan struct{}
-		shutdownLock sync.Mutex
+		config     *Config
+		logger     *ulog.Logger
+		logOutput  io.Writer
+		client     *ucli.Client
+		server     *usrv.Server
+		shutdown   bool
+		shutdownCh chan struct{}
 	}
 	tests := []struct {
 		name   string
@@ -379,14 +364,13 @@ func TestAgent_Client(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			a := &Agent{
-				config:       tt.fields.config,
-				logger:       tt.fields.logger,
-				logOutput:    tt.fields.logOutput,
-				client:       tt.fields.client,
-				server:       tt.fields.server,
-				shutdown:     tt.fields.shutdown,
-				shutdownCh:   tt.fields.shutdownCh,
-				shutdownLock: tt.fields.shutdownLock,
+				config:     tt.fields.config,
+				logger:     tt.fields.logger,
+				logOutput:  tt.fields.logOutput,
+				client:     tt.fields.client,
+				server:     tt.fields.server,
+				shutdown:   tt.fields.shutdown,
+				shutdownCh: tt.fields.shutdownCh,
 			}
 			if got := a.Client(); !reflect.DeepEqual(got, tt.want) {
 				t.Errorf("Agent.Client() = %v, want %v", got, tt.want)
@@ -397,14 +381,13 @@ func TestAgent_Client(t *testing.T) {
 
 func TestAgent_Server(t *testing.T) {
 	type fields struct {
-		config       *Config
-		logger       *ulog.Logger
-		logOutput    io.Writer
-		client       *ucli.Client
-		server       *usrv.Server
-		shutdown     bool
-		shutdownCh   chan struct{}
-		shutdownLock sync.Mutex
+		config     *Config
+		logger     *ulog.Logger
+		logOutput  io.Writer
+		client     *ucli.Client
+		server     *usrv.Server
+		shutdown   bool
+		shutdownCh chan struct{}
 	}
 	tests := []struct {
 		name   string
@@ -416,14 +399,13 @@ func TestAgent_Server(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			a := &Agent{
-				config:       tt.fields.config,
-				logger:       tt.fields.logger,
-				logOutput:    tt.fields.logOutput,
-				client:       tt.fields.client,
-				server:       tt.fields.server,
-				shutdown:     tt.fields.shutdown,
-				shutdownCh:   tt.fields.shutdownCh,
-				shutdownLock: tt.fields.shutdownLock,
+				config:     tt.fields.config,
+				logger:     tt.fields.logger,
+				logOutput:  tt.fields.logOutput,
+				client:     tt.fields.client,
+				server:     tt.fields.server,
+				shutdown:   tt.fields.shutdown,
+				shutdownCh: tt.fields.shutdownCh,
 			}
 			if got := a.Server(); !reflect.DeepEqual(got, tt.want) {
 				t.Errorf("Agent.Server() = %v, want %v", got, tt.want)
@@ -434,14 +416,13 @@ func TestAgent_Server(t *testing.T) {
 
 func TestAgent_Stats(t *testing.T) {
 	type fields struct {
-		config       *Config
-		logger       *ulog.Logger
-		logOutput    io.Writer
-		client       *ucli.Client
-		server       *usrv.Server
-		shutdown     bool
-		shutdownCh   chan struct{}
-		shutdownLock sync.Mutex
+		config     *Config
+		logger     *ulog.Logger
+		logOutput  io.Writer
+		client     *ucli.Client
+		server     *usrv.Server
+		shutdown   bool
+		shutdownCh chan struct{}
 	}
 	tests := []struct {
 		name   string
@@ -453,14 +434,13 @@ func TestAgent_Stats(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			a := &Agent{
-				config:       tt.fields.config,
-				logger:       tt.fields.logger,
-				logOutput:    tt.fields.logOutput,
-				client:       tt.fields.client,
-				server:       tt.fields.server,
-				shutdown:     tt.fields.shutdown,
-				shutdownCh:   tt.fields.shutdownCh,
-				shutdownLock: tt.fields.shutdownLock,
+				config:     tt.fields.config,
+				logger:     tt.fields.logger,
+				logOutput:  tt.fields.logOutput,
+				client:     tt.fields.client,
+				server:     tt.fields.server,
+				shutdown:   tt.fields.shutdown,
+				shutdownCh: tt.fields.shutdownCh,
 			}
 			if got := a.Stats(); !reflect.DeepEqual(got, tt.want) {
 				t.Errorf("Agent.Stats() = %v, want %v", got, tt.want)
@@ -32,6 +32,9 @@ func (s *HTTPServer) AllocsRequest(resp http.ResponseWriter, req *http.Request)
 	if s.parse(resp, req, &args.Region, &args.QueryOptions) {
 		return nil, nil
 	}
+	if status := req.URL.Query().Get("status"); status != "" {
+		args.ClientStatuses = strings.Split(status, ",")
+	}
 
 	var out umodel.AllocListResponse
 	s.logger.Debugf("HTTPServer.AllocsRequest: call rpc")
@@ -43,7 +46,7 @@ func (s *HTTPServer) AllocsRequest(resp http.ResponseWriter, req *http.Request)
 	if out.Allocations == nil {
 		out.Allocations = make([]*umodel.AllocListStub, 0)
 	}
-	return out.Allocations, nil
+	return selectFields(req, out.Allocations)
 }
 
 func (s *HTTPServer) AllocSpecificRequest(resp http.ResponseWriter, req *http.Request) (interface{}, error) {
@@ -23,6 +23,7 @@ import (
 	"github.com/actiontech/dtle/internal/g"
 
 	"github.com/armon/go-metrics"
+	"github.com/armon/go-metrics/datadog"
 	"github.com/armon/go-metrics/prometheus"
 	"github.com/mitchellh/cli"
 
@@ -46,6 +47,8 @@ type Command struct {
 	args           []string
 	agent          *Agent
 	httpServer     *HTTPServer
+	grpcServer     *GRPCServer
+	service        serviceManager
 	logger         *ulog.Logger
 	logOutput      io.Writer
 	retryJoinErrCh chan struct{}
@@ -127,6 +130,14 @@ func (c *Command) readConfig() *Config {
 		}
 	}
 
+	// Merge the environment variables over the config file options
+	envConfig, err := LoadEnvConfig(os.LookupEnv)
+	if err != nil {
+		c.Ui.Error(fmt.Sprintf("Error loading configuration from the environment: %s", err))
+		return nil
+	}
+	config = config.Merge(envConfig)
+
 	// Ensure the sub-structs at least exist
 	if config.Client == nil {
 		config.Client = &ClientConfig{}
@@ -248,10 +259,22 @@ func (c *Command) setupAgent(config *Config, logOutput io.Writer) error {
 	}
 	c.httpServer = http
 
+	// Setup the gRPC server if enabled
+	if config.Ports.GRPC != 0 {
+		grpcServer, err := NewGRPCServer(agent, config)
+		if err != nil {
+			http.Shutdown()
+			agent.Shutdown()
+			c.logger.Errorf("Error starting grpc server: %s", err)
+			return err
+		}
+		c.grpcServer = grpcServer
+	}
+
 	return nil
 }
 
-func (c *Command) Run(args []string) int {
+func (c *Command) Run(args []string) (code int) {
 	// Parse our configs
 	c.args = args
 	config := c.readConfig()
@@ -266,6 +289,19 @@ func (c *Command) Run(args []string) int {
 		return 1
 	}
 
+	// Report the lifecycle of the agent to systemd or the Windows service
+	// control manager
+	c.service, err = newServiceManager(c.logger, func() bool {
+		return c.agent != nil && c.agent.checkHealth(false).Healthy
+	})
+	if err != nil {
+		c.Ui.Error("Error setup service: " + err.Error())
+		return 1
+	}
+	defer func() {
+		c.service.Stopped(code)
+	}()
+
 	// Log config files
 	if len(config.Files) > 0 {
 		c.logger.Printf("Loaded configuration from %s", strings.Join(config.Files, ", "))
@@ -297,7 +333,11 @@ func (c *Command) Run(args []string) int {
 		if c.httpServer != nil {
 			c.httpServer.Shutdown()
 		}
+		if c.grpcServer != nil {
+			c.grpcServer.Shutdown()
+		}
 	}()
+	defer c.service.Stopping()
 
 	// Join startup nodes if specified
 	if err := c.startupJoin(config); err != nil {
@@ -325,11 +365,10 @@ func (c *Command) Run(args []string) int {
 	padding := 18
 	c.logger.Printf("Dtle server configuration:\n")
 	for _, k := range infoKeys {
-		c.logger.Printf(fmt.Sprintf(
-			" %s%s: %s",
+		c.logger.Printf(" %s%s: %s",
 			strings.Repeat(" ", padding-len(k)),
 			strings.Title(k),
-			info[k]))
+			info[k])
 	}
 	// Output the header that the server has started
 	c.logger.Printf("Dtle server started! Log data will stream in below:\n")
@@ -338,6 +377,8 @@ func (c *Command) Run(args []string) int {
 	c.retryJoinErrCh = make(chan struct{})
 	go c.retryJoin(config)
 
+	c.service.Ready()
+
 	// Wait for exit
 	return c.handleSignals(config)
 }
@@ -355,6 +396,8 @@ WAIT:
 		sig = s
 	case <-c.ShutdownCh:
 		sig = os.Interrupt
+	case <-c.service.StopCh():
+		sig = syscall.SIGTERM
 	case <-c.retryJoinErrCh:
 		return 1
 	}
@@ -367,9 +410,11 @@ WAIT:
 
 	// Check if this is a SIGHUP
 	if sig == syscall.SIGHUP {
+		c.service.Reloading()
 		if conf := c.handleReload(config); conf != nil {
 			*config = *conf
 		}
+		c.service.Ready()
 		goto WAIT
 	}
 
@@ -459,6 +504,37 @@ func (c *Command) setupMetric(config *Config) error {
 	}
 	fanout = append(fanout, sink)
 
+	// Configure the sinks the metrics are pushed to
+	if telConfig.StatsdAddr != "" {
+		sink, err := metrics.NewStatsdSink(telConfig.StatsdAddr)
+		if err != nil {
+			return err
+		}
+		fanout = append(fanout, sink)
+	}
+	if telConfig.DogStatsdAddr != "" {
+		sink, err := datadog.NewDogStatsdSink(telConfig.DogStatsdAddr, metricsConf.HostName)
+		if err != nil {
+			return err
+		}
+		sink.SetTags(telConfig.DogStatsdTags)
+		fanout = append(fanout, sink)
+	}
+	if telConfig.InfluxDBAddr != "" {
+		sink, err := newInfluxDBSink(telConfig.InfluxDBAddr, telConfig.InfluxDBToken, c.logger)
+		if err != nil {
+			return err
+		}
+		fanout = append(fanout, sink)
+	}
+	if telConfig.OTLPEndpoint != "" {
+		sink, err := newOTLPSink(telConfig.OTLPEndpoint, telConfig.OTLPHeaders, metricsConf.HostName, c.logger)
+		if err != nil {
+			return err
+		}
+		fanout = append(fanout, sink)
+	}
+
 	// Initialize the global sink
 	fanout = append(fanout, inm)
 	metrics.NewGlobal(metricsConf, fanout)
@@ -526,6 +602,12 @@ Usage: dtle server [options]
   files used, but a subset of the options may also be passed directly
   as CLI arguments, listed below.
 
+  Any option of the config files may also be set by an environment
+  variable named after its key, upper-cased and prefixed with DTLE_ and
+  the keys of its blocks, such as DTLE_BIND_ADDR or DTLE_CONSUL_ADDRESS.
+  The environment variables override the config files, and the CLI
+  arguments override both.
+
 General Options (agents and managers):
 
   -bind=<addr>
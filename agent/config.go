@@ -117,6 +117,10 @@ type Config struct {
 	// set arbritrary headers on API responses
 	HTTPAPIResponseHeaders map[string]string `mapstructure:"http_api_response_headers"`
 
+	// HTTPAPI lets browsers and reverse proxies in front of the HTTP API
+	// call it
+	HTTPAPI *HTTPAPIConfig `mapstructure:"http_api"`
+
 	// EnableUi enables the statically-compiled assets for the Udup web UI and
 	// serves them at the default /ui/ endpoint automatically.
 	EnableUi bool `mapstructure:"ui"`
@@ -125,6 +129,10 @@ type Config struct {
 	// If provided, the UI endpoints will be enabled.
 	UiDir string `mapstructure:"ui_dir"`
 
+	// PluginDir is the directory of the driver plugins, data_dir/plugins
+	// by default
+	PluginDir string `mapstructure:"plugin_dir"`
+
 	// Schema name for dtle meta info (e.g. gtid_executed).
 	// Do not use special characters (which need to be quoted) in schema name.
 	DtleSchemaName string `mapstructure:"dtle_schema_name"`
@@ -144,6 +152,11 @@ type ClientConfig struct {
 	// NoHostUUID disables using the host's UUID and will force generation of a
 	// random UUID.
 	NoHostUUID bool `mapstructure:"no_host_uuid"`
+
+	// DrainTimeout is how long each task may take on shutdown to write the
+	// events it received to its target before it is stopped, nil if unset
+	// since 0 stops the tasks at once
+	DrainTimeout *time.Duration `mapstructure:"drain_timeout"`
 }
 
 // ServerConfig is configuration specific to the server mode
@@ -187,6 +200,54 @@ type ServerConfig struct {
 	// the default is 30s.
 	RetryInterval string        `mapstructure:"retry_interval"`
 	retryInterval time.Duration `mapstructure:"-"`
+
+	// CleanupDeadServers enables the leader to remove failed servers from
+	// the Raft configuration once they have been failed for longer than
+	// DeadServerThreshold.
+	CleanupDeadServers bool `mapstructure:"cleanup_dead_servers"`
+
+	// DeadServerThreshold is how long a server must be failed before it is
+	// removed by CleanupDeadServers. The default is 10m.
+	DeadServerThreshold string `mapstructure:"dead_server_threshold"`
+
+	// Webhooks are the endpoints the leader notifies of job events
+	Webhooks []*uconf.WebhookConfig `mapstructure:"webhook"`
+
+	// BinlogRetentionInterval is how often the leader checks that the
+	// sources of the jobs still have the binlogs after their checkpoints,
+	// raising a warning event otherwise. The default is 5m, 0 disables the
+	// checks.
+	BinlogRetentionInterval string `mapstructure:"binlog_retention_interval"`
+}
+
+// HTTPAPIConfig configures the HTTP API for the dashboards calling it from
+// browsers and the reverse proxies it sits behind.
+type HTTPAPIConfig struct {
+	// CORSAllowedOrigins are the origins of the pages allowed to call the
+	// API, "*" for any. Cross-origin requests are not allowed when empty.
+	CORSAllowedOrigins []string `mapstructure:"cors_allowed_origins"`
+
+	// CORSAllowedHeaders are the request headers allowed besides
+	// Content-Type, such as Authorization.
+	CORSAllowedHeaders []string `mapstructure:"cors_allowed_headers"`
+
+	// CORSMaxAge is how long browsers cache a preflight response, in
+	// seconds. The default is 600.
+	CORSMaxAge int `mapstructure:"cors_max_age"`
+
+	// TrustedProxies are the addresses or CIDR blocks of the proxies whose
+	// X-Forwarded-For, X-Forwarded-Proto, X-Forwarded-Host and
+	// X-Forwarded-Prefix headers are trusted.
+	TrustedProxies []string `mapstructure:"trusted_proxies"`
+
+	// URLPrefix is the path the API and the UI are also served under, such
+	// as /dtle, for proxies passing the path as is.
+	URLPrefix string `mapstructure:"url_prefix"`
+
+	// OperatorAuth is the "user:password" the operators authenticate with
+	// to capture the profiles and the diagnostic bundle of the agent. The
+	// diagnostics are disabled when empty.
+	OperatorAuth string `mapstructure:"operator_auth"`
 }
 
 type Network struct {
@@ -202,6 +263,29 @@ type Metric struct {
 	collectionInterval       time.Duration `mapstructure:"-"`
 	PublishAllocationMetrics bool          `mapstructure:"publish_allocation_metrics"`
 	PublishNodeMetrics       bool          `mapstructure:"publish_node_metrics"`
+
+	// StatsdAddr is the "host:port" of a StatsD server the metrics are
+	// sent to, besides Prometheus
+	StatsdAddr string `mapstructure:"statsd_address"`
+	// DogStatsdAddr is the "host:port" of a DogStatsD agent the metrics are
+	// sent to, with their labels as tags
+	DogStatsdAddr string `mapstructure:"datadog_address"`
+	// DogStatsdTags are "key:value" tags added to the metrics sent to
+	// DogStatsD
+	DogStatsdTags []string `mapstructure:"datadog_tags"`
+	// InfluxDBAddr is where the metrics are written in the InfluxDB line
+	// protocol: "udp://host:port", or the URL of a write endpoint such as
+	// "http://host:8086/write?db=dtle"
+	InfluxDBAddr string `mapstructure:"influxdb_address"`
+	// InfluxDBToken authorizes the writes to an InfluxDB 2 endpoint
+	InfluxDBToken string `mapstructure:"influxdb_token"`
+	// OTLPEndpoint is the URL the metrics are exported to with OTLP over
+	// HTTP, such as "http://host:4318/v1/metrics" of an OpenTelemetry
+	// collector
+	OTLPEndpoint string `mapstructure:"otlp_endpoint"`
+	// OTLPHeaders are the headers of the exports, such as those
+	// authenticating them
+	OTLPHeaders map[string]string `mapstructure:"otlp_headers"`
 }
 
 // Ports encapsulates the various ports we bind to for network services. If any
@@ -211,6 +295,9 @@ type Ports struct {
 	RPC  int `mapstructure:"rpc"`
 	Serf int `mapstructure:"serf"`
 	Nats int `mapstructure:"nats"`
+
+	// GRPC is the port of the gRPC job API. It is disabled when zero.
+	GRPC int `mapstructure:"grpc"`
 }
 
 // Addresses encapsulates all of the addresses we bind to for various
@@ -220,6 +307,7 @@ type Addresses struct {
 	RPC  string `mapstructure:"rpc"`
 	Serf string `mapstructure:"serf"`
 	Nats string `mapstructure:"nats"`
+	GRPC string `mapstructure:"grpc"`
 }
 
 // AdvertiseAddrs is used to control the addresses we advertise out for
@@ -289,10 +377,33 @@ func DefaultConfig() *Config {
 		Network: &Network{
 			MaxPayload: DefaultMaxPayload,
 		},
+		HTTPAPI: &HTTPAPIConfig{
+			CORSMaxAge: 600,
+		},
 		DtleSchemaName: "dtle",
 	}
 }
 
+// DevConfig returns the config of an agent running a manager and an agent
+// on the loopback address, with its data in dataDir, as the tests running
+// jobs in their process do. The ports are those given.
+func DevConfig(dataDir string, ports *Ports) (*Config, error) {
+	config := DefaultConfig()
+	config.LogFile = ""
+	config.PidFile = ""
+	config.BindAddr = "127.0.0.1"
+	config.DataDir = dataDir
+	config.Ports = ports
+	config.Client.Enabled = true
+	config.Server.Enabled = true
+	config.Server.BootstrapExpect = 1
+	config.Server.retryInterval = 15 * time.Second
+	if err := config.normalizeAddrs(); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
 // Listener can be used to get a new listener using a custom bind address.
 // If the bind provided address is empty, the BindAddr is used instead.
 func (c *Config) Listener(proto, addr string, port int) (net.Listener, error) {
@@ -340,6 +451,9 @@ func (c *Config) Merge(b *Config) *Config {
 	if b.UiDir != "" {
 		result.UiDir = b.UiDir
 	}
+	if b.PluginDir != "" {
+		result.PluginDir = b.PluginDir
+	}
 	if b.LogLevel != "" {
 		result.LogLevel = b.LogLevel
 	}
@@ -387,6 +501,14 @@ func (c *Config) Merge(b *Config) *Config {
 		result.Network = result.Network.Merge(b.Network)
 	}
 
+	// Apply the HTTP API config
+	if result.HTTPAPI == nil && b.HTTPAPI != nil {
+		httpAPI := *b.HTTPAPI
+		result.HTTPAPI = &httpAPI
+	} else if b.HTTPAPI != nil {
+		result.HTTPAPI = result.HTTPAPI.Merge(b.HTTPAPI)
+	}
+
 	// Apply the client config
 	if result.Client == nil && b.Client != nil {
 		client := *b.Client
@@ -459,11 +581,13 @@ func (c *Config) normalizeAddrs() error {
 	c.Addresses.RPC = normalizeBind(c.Addresses.RPC, c.BindAddr)
 	c.Addresses.Serf = normalizeBind(c.Addresses.Serf, c.BindAddr)
 	c.Addresses.Nats = normalizeBind(c.Addresses.Nats, c.BindAddr)
+	c.Addresses.GRPC = normalizeBind(c.Addresses.GRPC, c.BindAddr)
 	c.normalizedAddrs = &Addresses{
 		HTTP: net.JoinHostPort(c.Addresses.HTTP, strconv.Itoa(c.Ports.HTTP)),
 		RPC:  net.JoinHostPort(c.Addresses.RPC, strconv.Itoa(c.Ports.RPC)),
 		Serf: net.JoinHostPort(c.Addresses.Serf, strconv.Itoa(c.Ports.Serf)),
 		Nats: net.JoinHostPort(c.Addresses.Nats, strconv.Itoa(c.Ports.Nats)),
+		GRPC: net.JoinHostPort(c.Addresses.GRPC, strconv.Itoa(c.Ports.GRPC)),
 	}
 
 	addr, err := normalizeAdvertise(c.AdvertiseAddrs.HTTP, c.Addresses.HTTP, c.Ports.HTTP)
@@ -566,9 +690,23 @@ func (a *ServerConfig) Merge(b *ServerConfig) *ServerConfig {
 		result.RetryInterval = b.RetryInterval
 		result.retryInterval = b.retryInterval
 	}
+	if b.CleanupDeadServers {
+		result.CleanupDeadServers = true
+	}
+	if b.DeadServerThreshold != "" {
+		result.DeadServerThreshold = b.DeadServerThreshold
+	}
+	if b.BinlogRetentionInterval != "" {
+		result.BinlogRetentionInterval = b.BinlogRetentionInterval
+	}
 	// Add the schedulers
 	result.EnabledSchedulers = append(result.EnabledSchedulers, b.EnabledSchedulers...)
 
+	// Add the webhooks
+	result.Webhooks = make([]*uconf.WebhookConfig, 0, len(a.Webhooks)+len(b.Webhooks))
+	result.Webhooks = append(result.Webhooks, a.Webhooks...)
+	result.Webhooks = append(result.Webhooks, b.Webhooks...)
+
 	// Copy the start join addresses
 	result.StartJoin = make([]string, 0, len(a.StartJoin)+len(b.StartJoin))
 	result.StartJoin = append(result.StartJoin, a.StartJoin...)
@@ -596,6 +734,9 @@ func (a *ClientConfig) Merge(b *ClientConfig) *ClientConfig {
 	if b.NoHostUUID {
 		result.NoHostUUID = b.NoHostUUID
 	}
+	if b.DrainTimeout != nil {
+		result.DrainTimeout = b.DrainTimeout
+	}
 
 	// Add the servers
 	result.Servers = append(result.Servers, b.Servers...)
@@ -612,6 +753,31 @@ func (a *Network) Merge(b *Network) *Network {
 	return &result
 }
 
+// Merge is used to merge two HTTP API configs together
+func (a *HTTPAPIConfig) Merge(b *HTTPAPIConfig) *HTTPAPIConfig {
+	result := *a
+
+	if len(b.CORSAllowedOrigins) != 0 {
+		result.CORSAllowedOrigins = b.CORSAllowedOrigins
+	}
+	if len(b.CORSAllowedHeaders) != 0 {
+		result.CORSAllowedHeaders = b.CORSAllowedHeaders
+	}
+	if b.CORSMaxAge != 0 {
+		result.CORSMaxAge = b.CORSMaxAge
+	}
+	if len(b.TrustedProxies) != 0 {
+		result.TrustedProxies = b.TrustedProxies
+	}
+	if b.URLPrefix != "" {
+		result.URLPrefix = b.URLPrefix
+	}
+	if b.OperatorAuth != "" {
+		result.OperatorAuth = b.OperatorAuth
+	}
+	return &result
+}
+
 // Merge is used to merge two metric configs together
 func (a *Metric) Merge(b *Metric) *Metric {
 	result := *a
@@ -631,6 +797,34 @@ func (a *Metric) Merge(b *Metric) *Metric {
 	if b.PublishAllocationMetrics {
 		result.PublishAllocationMetrics = true
 	}
+	if b.StatsdAddr != "" {
+		result.StatsdAddr = b.StatsdAddr
+	}
+	if b.DogStatsdAddr != "" {
+		result.DogStatsdAddr = b.DogStatsdAddr
+	}
+	if b.DogStatsdTags != nil {
+		result.DogStatsdTags = append(result.DogStatsdTags, b.DogStatsdTags...)
+	}
+	if b.InfluxDBAddr != "" {
+		result.InfluxDBAddr = b.InfluxDBAddr
+	}
+	if b.InfluxDBToken != "" {
+		result.InfluxDBToken = b.InfluxDBToken
+	}
+	if b.OTLPEndpoint != "" {
+		result.OTLPEndpoint = b.OTLPEndpoint
+	}
+	if len(b.OTLPHeaders) > 0 {
+		headers := make(map[string]string)
+		for k, v := range a.OTLPHeaders {
+			headers[k] = v
+		}
+		for k, v := range b.OTLPHeaders {
+			headers[k] = v
+		}
+		result.OTLPHeaders = headers
+	}
 	return &result
 }
 
@@ -650,6 +844,9 @@ func (a *Ports) Merge(b *Ports) *Ports {
 	if b.Nats != 0 {
 		result.Nats = b.Nats
 	}
+	if b.GRPC != 0 {
+		result.GRPC = b.GRPC
+	}
 	return &result
 }
 
@@ -669,6 +866,9 @@ func (a *Addresses) Merge(b *Addresses) *Addresses {
 	if b.Nats != "" {
 		result.Nats = b.Nats
 	}
+	if b.GRPC != "" {
+		result.GRPC = b.GRPC
+	}
 	return &result
 }
 
@@ -0,0 +1,151 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package agent
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/go-multierror"
+)
+
+const (
+	// EnvConfigPrefix is the prefix of the environment variables setting
+	// the options of the config file
+	EnvConfigPrefix = "DTLE_"
+)
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// LoadEnvConfig returns the config set by the environment variables. An
+// option of the config file is set by the variable named after its key,
+// upper-cased and prefixed with DTLE_ and the keys of its blocks, such as
+// DTLE_BIND_ADDR for bind_addr and DTLE_CONSUL_ADDRESS for the address of
+// the consul block. Lists are comma-separated. The variables of the
+// options the file can not set, such as the webhooks of the metric block,
+// are not read.
+func LoadEnvConfig(lookup func(key string) (string, bool)) (*Config, error) {
+	config := &Config{}
+	var mErr multierror.Error
+	loadEnvStruct(reflect.ValueOf(config).Elem(), EnvConfigPrefix, lookup, &mErr)
+	if err := mErr.ErrorOrNil(); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+// loadEnvStruct sets the fields of a struct from the environment variables
+// named after their mapstructure keys. The blocks are allocated only if one
+// of their variables is set, for the config to merge over the file config.
+func loadEnvStruct(v reflect.Value, prefix string, lookup func(string) (string, bool), mErr *multierror.Error) bool {
+	set := false
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := strings.Split(field.Tag.Get("mapstructure"), ",")[0]
+		if field.PkgPath != "" || tag == "" || tag == "-" {
+			continue
+		}
+		name := prefix + strings.ToUpper(tag)
+		fv := v.Field(i)
+
+		if field.Type.Kind() == reflect.Ptr && field.Type.Elem().Kind() == reflect.Struct {
+			block := reflect.New(field.Type.Elem())
+			if loadEnvStruct(block.Elem(), name+"_", lookup, mErr) {
+				fv.Set(block)
+				set = true
+			}
+			continue
+		}
+
+		if !envSettable(field.Type) {
+			continue
+		}
+		value, ok := lookup(name)
+		if !ok {
+			continue
+		}
+		if err := setEnvValue(fv, value); err != nil {
+			mErr.Errors = append(mErr.Errors, fmt.Errorf("%s: %v", name, err))
+			continue
+		}
+		set = true
+	}
+	return set
+}
+
+// envSettable returns whether a field of a type can be set by an
+// environment variable
+func envSettable(t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.String, reflect.Bool,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return true
+	case reflect.Ptr:
+		return envSettable(t.Elem())
+	case reflect.Slice:
+		return t.Elem().Kind() == reflect.String
+	}
+	return false
+}
+
+// setEnvValue sets a field from the value of an environment variable
+func setEnvValue(v reflect.Value, value string) error {
+	value = strings.TrimSpace(value)
+	if v.Type() == durationType {
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return err
+		}
+		v.SetInt(int64(d))
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(value)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return err
+		}
+		v.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(value, 10, 64)
+		if err != nil {
+			return err
+		}
+		v.SetUint(n)
+	case reflect.Ptr:
+		elem := reflect.New(v.Type().Elem())
+		if err := setEnvValue(elem.Elem(), value); err != nil {
+			return err
+		}
+		v.Set(elem)
+	case reflect.Slice:
+		var items []string
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+		v.Set(reflect.ValueOf(items).Convert(v.Type()))
+	default:
+		return fmt.Errorf("can not be set by an environment variable")
+	}
+	return nil
+}
@@ -84,6 +84,7 @@ func parseConfig(result *Config, list *ast.ObjectList) error {
 		"data_dir",
 		"ui",
 		"ui_dir",
+		"plugin_dir",
 		"log_level",
 		"log_to_stdout",
 		"log_file",
@@ -103,6 +104,7 @@ func parseConfig(result *Config, list *ast.ObjectList) error {
 		"leave_on_terminate",
 		"consul",
 		"http_api_response_headers",
+		"http_api",
 		"dtle_schema_name",
 	}
 	if err := checkHCLKeys(list, valid); err != nil {
@@ -123,6 +125,7 @@ func parseConfig(result *Config, list *ast.ObjectList) error {
 	delete(m, "network")
 	delete(m, "consul")
 	delete(m, "http_api_response_headers")
+	delete(m, "http_api")
 
 	// Decode the rest
 	if err := mapstructure.WeakDecode(m, result); err != nil {
@@ -184,6 +187,13 @@ func parseConfig(result *Config, list *ast.ObjectList) error {
 		}
 	}
 
+	// Parse the http api config
+	if o := list.Filter("http_api"); len(o.Items) > 0 {
+		if err := parseHTTPAPI(&result.HTTPAPI, o); err != nil {
+			return multierror.Prefix(err, "http_api ->")
+		}
+	}
+
 	// Parse out http_api_response_headers fields. These are in HCL as a list so
 	// we need to iterate over them and merge them.
 	if headersO := list.Filter("http_api_response_headers"); len(headersO.Items) > 0 {
@@ -216,6 +226,7 @@ func parsePorts(result **Ports, list *ast.ObjectList) error {
 		"rpc",
 		"serf",
 		"nats",
+		"grpc",
 	}
 	if err := checkHCLKeys(listVal, valid); err != nil {
 		return err
@@ -249,6 +260,7 @@ func parseAddresses(result **Addresses, list *ast.ObjectList) error {
 		"rpc",
 		"serf",
 		"nats",
+		"grpc",
 	}
 	if err := checkHCLKeys(listVal, valid); err != nil {
 		return err
@@ -323,6 +335,7 @@ func parseClient(result **ClientConfig, list *ast.ObjectList) error {
 		"managers",
 		"stats",
 		"no_host_uuid",
+		"drain_timeout",
 	}
 	if err := checkHCLKeys(listVal, valid); err != nil {
 		return err
@@ -382,18 +395,45 @@ func parseServer(result **ServerConfig, list *ast.ObjectList) error {
 		"join",
 		"retry_max",
 		"retry_interval",
+		"cleanup_dead_servers",
+		"dead_server_threshold",
+		"webhook",
+		"binlog_retention_interval",
 	}
 	if err := checkHCLKeys(listVal, valid); err != nil {
 		return err
 	}
 
+	// Check the keys of the webhooks
+	for _, item := range listVal.Filter("webhook").Items {
+		valid := []string{
+			"url",
+			"events",
+			"format",
+			"secret",
+			"max_retries",
+			"timeout",
+		}
+		if err := checkHCLKeys(item.Val, valid); err != nil {
+			return multierror.Prefix(err, "webhook ->")
+		}
+	}
+
 	var m map[string]interface{}
 	if err := hcl.DecodeObject(&m, listVal); err != nil {
 		return err
 	}
 
 	var config ServerConfig
-	if err := mapstructure.WeakDecode(m, &config); err != nil {
+	dec, err := mapstructure.NewDecoder(&mapstructure.DecoderConfig{
+		DecodeHook:       mapstructure.StringToTimeDurationHookFunc(),
+		WeaklyTypedInput: true,
+		Result:           &config,
+	})
+	if err != nil {
+		return err
+	}
+	if err := dec.Decode(m); err != nil {
 		return err
 	}
 
@@ -418,6 +458,13 @@ func parseMetric(result **Metric, list *ast.ObjectList) error {
 		"collection_interval",
 		"publish_allocation_metrics",
 		"publish_node_metrics",
+		"statsd_address",
+		"datadog_address",
+		"datadog_tags",
+		"influxdb_address",
+		"influxdb_token",
+		"otlp_endpoint",
+		"otlp_headers",
 	}
 	if err := checkHCLKeys(listVal, valid); err != nil {
 		return err
@@ -473,6 +520,41 @@ func parseNetwork(result **Network, list *ast.ObjectList) error {
 	return nil
 }
 
+func parseHTTPAPI(result **HTTPAPIConfig, list *ast.ObjectList) error {
+	list = list.Elem()
+	if len(list.Items) > 1 {
+		return fmt.Errorf("only one 'http_api' block allowed")
+	}
+
+	// Get our http api object
+	listVal := list.Items[0].Val
+
+	// Check for invalid keys
+	valid := []string{
+		"cors_allowed_origins",
+		"cors_allowed_headers",
+		"cors_max_age",
+		"trusted_proxies",
+		"url_prefix",
+		"operator_auth",
+	}
+	if err := checkHCLKeys(listVal, valid); err != nil {
+		return err
+	}
+
+	var m map[string]interface{}
+	if err := hcl.DecodeObject(&m, listVal); err != nil {
+		return err
+	}
+
+	var httpAPI HTTPAPIConfig
+	if err := mapstructure.WeakDecode(m, &httpAPI); err != nil {
+		return err
+	}
+	*result = &httpAPI
+	return nil
+}
+
 func parseConsulConfig(result **config.ConsulConfig, list *ast.ObjectList) error {
 	list = list.Elem()
 	if len(list.Items) > 1 {
@@ -9,7 +9,9 @@ package agent
 import (
 	"io"
 	"reflect"
+	"strings"
 	"testing"
+	"time"
 	"github.com/actiontech/dtle/internal/config"
 
 	"github.com/hashicorp/hcl/hcl/ast"
@@ -276,3 +278,31 @@ func Test_checkHCLKeys(t *testing.T) {
 		})
 	}
 }
+
+func TestParseConfig_drainTimeout(t *testing.T) {
+	tests := []struct {
+		name   string
+		config string
+		want   time.Duration
+	}{
+		{"unset", `agent { enabled = true }`, config.DefaultDrainTimeout},
+		{"zero", `agent { drain_timeout = "0s" }`, 0},
+		{"set", `agent { drain_timeout = "10s" }`, 10 * time.Second},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			parsed, err := ParseConfig(strings.NewReader(tt.config))
+			if err != nil {
+				t.Fatalf("ParseConfig() error = %v", err)
+			}
+			a := &Agent{config: DefaultConfig().Merge(parsed)}
+			conf, err := a.clientConfig()
+			if err != nil {
+				t.Fatalf("clientConfig() error = %v", err)
+			}
+			if conf.DrainTimeout != tt.want {
+				t.Errorf("DrainTimeout = %v, want %v", conf.DrainTimeout, tt.want)
+			}
+		})
+	}
+}
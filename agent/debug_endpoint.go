@@ -0,0 +1,339 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package agent
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"runtime"
+	"runtime/pprof"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/actiontech/dtle/internal/models"
+)
+
+const (
+	// defaultProfileSeconds is how long the CPU profile is captured for when
+	// the request has no ?seconds
+	defaultProfileSeconds = 10
+	// maxProfileSeconds bounds the ?seconds of a request
+	maxProfileSeconds = 300
+	// redacted replaces the secrets of the config in the diagnostics
+	redacted = "<redacted>"
+)
+
+// secretKeys are the keys of the task configs holding credentials, such as
+// the Password of their connection configs
+var secretKeys = map[string]bool{"Password": true, "Secret": true, "Token": true}
+
+// debugAllocation is an allocation of the agent in the diagnostic bundle,
+// with the latest stats of its tasks, such as the depths of their queues
+type debugAllocation struct {
+	Alloc *models.Allocation
+	Stats *models.AllocStatistics `json:",omitempty"`
+}
+
+// debugVersions are the versions of the agent in the diagnostic bundle
+type debugVersions struct {
+	Version      string
+	GoVersion    string
+	OS           string
+	Arch         string
+	NumCPU       int
+	NumGoroutine int
+}
+
+// AgentDebugRequest captures the profiles and the diagnostic bundle of the
+// agent serving the request, for the operators authenticated with the
+// operator_auth of the http_api block:
+//
+//	GET /v1/agent/debug/profile/<name>?seconds=N
+//	GET /v1/agent/debug/bundle?seconds=N
+func (s *HTTPServer) AgentDebugRequest(resp http.ResponseWriter, req *http.Request) (interface{}, error) {
+	if err := s.checkOperatorAuth(resp, req); err != nil {
+		return nil, err
+	}
+	if req.Method != "GET" {
+		return nil, CodedError(405, ErrInvalidMethod)
+	}
+	seconds, err := profileSeconds(req)
+	if err != nil {
+		return nil, err
+	}
+
+	path := strings.TrimPrefix(req.URL.Path, "/v1/agent/debug/")
+	switch {
+	case strings.HasPrefix(path, "profile/"):
+		name := strings.TrimPrefix(path, "profile/")
+		var buf bytes.Buffer
+		if err := writeProfile(&buf, name, seconds); err != nil {
+			return nil, err
+		}
+		resp.Header().Set("Content-Type", "application/octet-stream")
+		resp.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.pprof"`, name))
+		resp.Write(buf.Bytes())
+		return nil, nil
+	case path == "bundle":
+		var buf bytes.Buffer
+		if err := s.writeDebugBundle(&buf, seconds); err != nil {
+			return nil, err
+		}
+		resp.Header().Set("Content-Type", "application/gzip")
+		resp.Header().Set("Content-Disposition", `attachment; filename="dtle-debug.tar.gz"`)
+		resp.Write(buf.Bytes())
+		return nil, nil
+	default:
+		return nil, CodedError(404, resourceNotFoundErr)
+	}
+}
+
+// checkOperatorAuth checks the request carries the operator_auth of the
+// agent as its basic auth
+func (s *HTTPServer) checkOperatorAuth(resp http.ResponseWriter, req *http.Request) error {
+	var auth string
+	if s.agent.config.HTTPAPI != nil {
+		auth = s.agent.config.HTTPAPI.OperatorAuth
+	}
+	if auth == "" {
+		return CodedError(403, "Diagnostics are disabled, set the operator_auth of the http_api block to enable them")
+	}
+
+	user, password, _ := req.BasicAuth()
+	if subtle.ConstantTimeCompare([]byte(user+":"+password), []byte(auth)) != 1 {
+		s.logger.Warnf("http: Unauthorized diagnostics request from %v", s.frontend.clientAddr(req))
+		resp.Header().Set("WWW-Authenticate", `Basic realm="dtle"`)
+		return CodedError(401, "Invalid operator credentials")
+	}
+	return nil
+}
+
+// profileSeconds returns the ?seconds of a request, how long the CPU profile
+// is captured for
+func profileSeconds(req *http.Request) (int, error) {
+	v := req.URL.Query().Get("seconds")
+	if v == "" {
+		return defaultProfileSeconds, nil
+	}
+	seconds, err := strconv.Atoi(v)
+	if err != nil || seconds < 0 || seconds > maxProfileSeconds {
+		return 0, CodedError(400, fmt.Sprintf("Invalid seconds %q, must be within 0 and %d", v, maxProfileSeconds))
+	}
+	return seconds, nil
+}
+
+// writeProfile writes the runtime profile of the given name, such as heap
+// or goroutine, in the pprof format. The "cpu" profile is captured for the
+// given seconds.
+func writeProfile(w io.Writer, name string, seconds int) error {
+	if name == "cpu" {
+		if seconds == 0 {
+			return CodedError(400, "The cpu profile needs seconds greater than 0")
+		}
+		if err := pprof.StartCPUProfile(w); err != nil {
+			return CodedError(409, fmt.Sprintf("Failed to start the cpu profile: %v", err))
+		}
+		time.Sleep(time.Duration(seconds) * time.Second)
+		pprof.StopCPUProfile()
+		return nil
+	}
+
+	profile := pprof.Lookup(name)
+	if profile == nil {
+		return CodedError(404, fmt.Sprintf("Unknown profile %q", name))
+	}
+	if name == "heap" {
+		// the heap profile is that of the last GC
+		runtime.GC()
+	}
+	return profile.WriteTo(w, 0)
+}
+
+// writeDebugBundle writes the diagnostic bundle of the agent as a tar.gz:
+// its versions, config, stats and allocations, the memory stats, and the
+// heap, goroutine and, for seconds greater than 0, cpu profiles.
+func (s *HTTPServer) writeDebugBundle(w io.Writer, seconds int) error {
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+	now := time.Now()
+	add := func(name string, data []byte) error {
+		hdr := &tar.Header{Name: name, Mode: 0644, Size: int64(len(data)), ModTime: now}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		_, err := tw.Write(data)
+		return err
+	}
+	addJSON := func(name string, v interface{}) error {
+		data, err := json.MarshalIndent(v, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to encode %s: %v", name, err)
+		}
+		return add(name, data)
+	}
+	addProfile := func(name string) error {
+		var buf bytes.Buffer
+		if err := writeProfile(&buf, name, seconds); err != nil {
+			return err
+		}
+		return add(name+".pprof", buf.Bytes())
+	}
+
+	versions := debugVersions{
+		Version:      s.agent.config.Version,
+		GoVersion:    runtime.Version(),
+		OS:           runtime.GOOS,
+		Arch:         runtime.GOARCH,
+		NumCPU:       runtime.NumCPU(),
+		NumGoroutine: runtime.NumGoroutine(),
+	}
+	var memStats runtime.MemStats
+	runtime.ReadMemStats(&memStats)
+	if err := addJSON("versions.json", versions); err != nil {
+		return err
+	}
+	if err := addJSON("config.json", redactedConfig(s.agent.config)); err != nil {
+		return err
+	}
+	if err := addJSON("stats.json", s.agent.Stats()); err != nil {
+		return err
+	}
+	if err := addJSON("memstats.json", memStats); err != nil {
+		return err
+	}
+	if err := addJSON("allocations.json", s.debugAllocations()); err != nil {
+		return err
+	}
+
+	var goroutines bytes.Buffer
+	if err := pprof.Lookup("goroutine").WriteTo(&goroutines, 2); err != nil {
+		return err
+	}
+	if err := add("goroutine.txt", goroutines.Bytes()); err != nil {
+		return err
+	}
+	if err := addProfile("goroutine"); err != nil {
+		return err
+	}
+	if err := addProfile("heap"); err != nil {
+		return err
+	}
+	if seconds > 0 {
+		if err := addProfile("cpu"); err != nil {
+			return err
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return gz.Close()
+}
+
+// debugAllocations returns the allocations of the client of the agent, if
+// any, with the latest stats of their tasks and without their credentials
+func (s *HTTPServer) debugAllocations() []*debugAllocation {
+	allocs := make([]*debugAllocation, 0)
+	if s.agent.client == nil {
+		return allocs
+	}
+	for _, alloc := range s.agent.client.Allocs() {
+		a := &debugAllocation{Alloc: redactedAllocation(alloc)}
+		if reporter, err := s.agent.client.GetAllocStats(alloc.ID); err == nil {
+			if stats, err := reporter.LatestAllocStats(""); err == nil {
+				a.Stats = stats
+			}
+		}
+		allocs = append(allocs, a)
+	}
+	return allocs
+}
+
+// redactedConfig returns a copy of the config without the operator_auth
+func redactedConfig(config *Config) *Config {
+	if config.HTTPAPI == nil || config.HTTPAPI.OperatorAuth == "" {
+		return config
+	}
+	c := *config
+	httpAPI := *config.HTTPAPI
+	httpAPI.OperatorAuth = redacted
+	c.HTTPAPI = &httpAPI
+	return &c
+}
+
+// redactedAllocation returns a copy of the allocation without the
+// credentials of the configs of its tasks
+func redactedAllocation(alloc *models.Allocation) *models.Allocation {
+	a := alloc.Copy()
+	if a.Job == nil {
+		return a
+	}
+	for _, task := range a.Job.Tasks {
+		if task.ConfigLock != nil {
+			task.ConfigLock.RLock()
+		}
+		config, _ := redactSecrets("", task.Config).(map[string]interface{})
+		if task.ConfigLock != nil {
+			task.ConfigLock.RUnlock()
+		}
+		task.Config = config
+	}
+	return a
+}
+
+// redactSecrets returns a copy of the value of the key of a task config,
+// with the values of secretKeys, the headers of the webhooks and the
+// passwords of the URLs redacted
+func redactSecrets(key string, v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for k, e := range v {
+			if key == "Headers" {
+				// the headers may carry credentials
+				m[k] = redacted
+				continue
+			}
+			m[k] = redactSecrets(k, e)
+		}
+		return m
+	case []interface{}:
+		l := make([]interface{}, len(v))
+		for i, e := range v {
+			l[i] = redactSecrets(key, e)
+		}
+		return l
+	case []map[string]interface{}:
+		l := make([]map[string]interface{}, len(v))
+		for i, e := range v {
+			l[i] = redactSecrets(key, e).(map[string]interface{})
+		}
+		return l
+	case string:
+		if v == "" {
+			return v
+		}
+		if secretKeys[key] {
+			return redacted
+		}
+		if u, err := url.Parse(v); err == nil && u.User != nil {
+			if _, ok := u.User.Password(); ok {
+				u.User = url.UserPassword(u.User.Username(), redacted)
+				return u.String()
+			}
+		}
+	}
+	return v
+}
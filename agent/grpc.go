@@ -0,0 +1,324 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package agent
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"time"
+
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/actiontech/dtle/api"
+	"github.com/actiontech/dtle/api/pb"
+	log "github.com/actiontech/dtle/internal/logger"
+	"github.com/actiontech/dtle/internal/models"
+)
+
+const (
+	// grpcWatchWait bounds each blocking query of a Watch stream, so that
+	// changes of the job itself are noticed even if its allocations do not
+	// change and closed streams are detected.
+	grpcWatchWait = 5 * time.Second
+)
+
+// GRPCServer is used to wrap an Agent and expose the job API over gRPC. It
+// mirrors the job endpoints of the HTTP API.
+type GRPCServer struct {
+	agent    *Agent
+	server   *grpc.Server
+	listener net.Listener
+	logger   *log.Logger
+}
+
+// NewGRPCServer starts a new gRPC server over the agent
+func NewGRPCServer(agent *Agent, config *Config) (*GRPCServer, error) {
+	// Start the listener
+	lnAddr, err := net.ResolveTCPAddr("tcp", config.normalizedAddrs.GRPC)
+	if err != nil {
+		return nil, err
+	}
+	ln, err := config.Listener("tcp", lnAddr.IP.String(), lnAddr.Port)
+	if err != nil {
+		return nil, fmt.Errorf("failed to start gRPC listener: %v", err)
+	}
+
+	srv := &GRPCServer{
+		agent:    agent,
+		server:   grpc.NewServer(),
+		listener: ln,
+		logger:   agent.logger,
+	}
+	pb.RegisterJobServiceServer(srv.server, srv)
+
+	// Start the server
+	go srv.server.Serve(ln)
+	return srv, nil
+}
+
+// Shutdown is used to shutdown the gRPC server
+func (s *GRPCServer) Shutdown() {
+	if s != nil {
+		s.logger.Debugf("grpc: Shutting down grpc server")
+		s.server.Stop()
+	}
+}
+
+// Submit registers a new job or updates an existing one
+func (s *GRPCServer) Submit(ctx context.Context, in *pb.SubmitJobRequest) (*pb.WriteResponse, error) {
+	if in.Job == nil {
+		return nil, status.Error(codes.InvalidArgument, "Job must be specified")
+	}
+	if in.Job.Name == "" {
+		return nil, status.Error(codes.InvalidArgument, "Job Name hasn't been provided")
+	}
+	args, err := pbJobToApiJob(in.Job)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	if args.Region == nil {
+		args.Region = &s.agent.config.Region
+	}
+
+	sJob := ApiJobToStructJob(args, 0)
+	regReq := models.JobRegisterRequest{
+		Job:            sJob,
+		EnforceIndex:   in.EnforceIndex,
+		JobModifyIndex: in.JobModifyIndex,
+		WriteRequest: models.WriteRequest{
+			Region: *args.Region,
+		},
+	}
+	var out models.JobResponse
+	if err := s.agent.RPC("Job.Register", &regReq, &out); err != nil {
+		return nil, err
+	}
+	return &pb.WriteResponse{Index: out.Index}, nil
+}
+
+// Status returns a job along with its allocations
+func (s *GRPCServer) Status(ctx context.Context, in *pb.JobRequest) (*pb.JobStatus, error) {
+	out, err := s.jobStatus(in.Id, 0)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// List returns the jobs, optionally of a single namespace
+func (s *GRPCServer) List(ctx context.Context, in *pb.ListJobsRequest) (*pb.ListJobsResponse, error) {
+	args := models.JobListRequest{
+		QueryOptions: models.QueryOptions{
+			Region:    s.agent.config.Region,
+			Namespace: in.Namespace,
+			Prefix:    in.Prefix,
+		},
+	}
+	var out models.JobListResponse
+	if err := s.agent.RPC("Job.List", &args, &out); err != nil {
+		return nil, err
+	}
+
+	resp := &pb.ListJobsResponse{Index: out.Index}
+	for _, stub := range out.Jobs {
+		resp.Jobs = append(resp.Jobs, &pb.Job{
+			Id:                stub.ID,
+			Name:              stub.Name,
+			Namespace:         stub.Namespace,
+			Type:              stub.Type,
+			Status:            stub.Status,
+			StatusDescription: stub.StatusDescription,
+			CreateIndex:       stub.CreateIndex,
+			ModifyIndex:       stub.ModifyIndex,
+			JobModifyIndex:    stub.JobModifyIndex,
+		})
+	}
+	return resp, nil
+}
+
+// Pause pauses a running job
+func (s *GRPCServer) Pause(ctx context.Context, in *pb.JobRequest) (*pb.WriteResponse, error) {
+	return s.updateStatus(in.Id, models.JobStatusPause)
+}
+
+// Resume resumes a paused job
+func (s *GRPCServer) Resume(ctx context.Context, in *pb.JobRequest) (*pb.WriteResponse, error) {
+	return s.updateStatus(in.Id, models.JobStatusRunning)
+}
+
+// Watch streams the status of a job each time it changes, starting with its
+// current status
+func (s *GRPCServer) Watch(in *pb.WatchJobRequest, stream pb.JobService_WatchServer) error {
+	index := in.WaitIndex
+	for {
+		select {
+		case <-stream.Context().Done():
+			return nil
+		default:
+		}
+
+		out, err := s.jobStatus(in.Id, index)
+		if err != nil {
+			return err
+		}
+		if out.Index > index {
+			if err := stream.Send(out); err != nil {
+				return err
+			}
+			index = out.Index
+		}
+	}
+}
+
+func (s *GRPCServer) updateStatus(jobID, jobStatus string) (*pb.WriteResponse, error) {
+	args := models.JobUpdateStatusRequest{
+		JobID:  jobID,
+		Status: jobStatus,
+		WriteRequest: models.WriteRequest{
+			Region: s.agent.config.Region,
+		},
+	}
+	var out models.JobResponse
+	if err := s.agent.RPC("Job.UpdateStatus", &args, &out); err != nil {
+		return nil, err
+	}
+	return &pb.WriteResponse{Index: out.Index}, nil
+}
+
+// jobStatus queries a job and its allocations. A non-zero index turns the
+// allocations query into a blocking query waiting for changes past it. The
+// index of the result is the highest of the job and the allocations.
+func (s *GRPCServer) jobStatus(jobID string, index uint64) (*pb.JobStatus, error) {
+	allocArgs := models.JobSpecificRequest{
+		JobID: jobID,
+		QueryOptions: models.QueryOptions{
+			Region:        s.agent.config.Region,
+			MinQueryIndex: index,
+			MaxQueryTime:  grpcWatchWait,
+		},
+	}
+	var allocOut models.JobAllocationsResponse
+	if err := s.agent.RPC("Job.Allocations", &allocArgs, &allocOut); err != nil {
+		return nil, err
+	}
+
+	jobArgs := models.JobSpecificRequest{
+		JobID: jobID,
+		QueryOptions: models.QueryOptions{
+			Region: s.agent.config.Region,
+		},
+	}
+	var jobOut models.SingleJobResponse
+	if err := s.agent.RPC("Job.GetJob", &jobArgs, &jobOut); err != nil {
+		return nil, err
+	}
+	if jobOut.Job == nil {
+		return nil, status.Errorf(codes.NotFound, "job %q not found", jobID)
+	}
+
+	job, err := structJobToPbJob(jobOut.Job)
+	if err != nil {
+		return nil, err
+	}
+	out := &pb.JobStatus{
+		Job:   job,
+		Index: allocOut.Index,
+	}
+	if jobOut.Job.ModifyIndex > out.Index {
+		out.Index = jobOut.Job.ModifyIndex
+	}
+	for _, alloc := range allocOut.Allocations {
+		out.Allocations = append(out.Allocations, &pb.Allocation{
+			Id:                alloc.ID,
+			Task:              alloc.Task,
+			NodeId:            alloc.NodeID,
+			DesiredStatus:     alloc.DesiredStatus,
+			ClientStatus:      alloc.ClientStatus,
+			ClientDescription: alloc.ClientDescription,
+			CreateIndex:       alloc.CreateIndex,
+			ModifyIndex:       alloc.ModifyIndex,
+		})
+	}
+	return out, nil
+}
+
+// pbJobToApiJob converts a job of the gRPC API to a job of the HTTP API.
+// Empty fields are left unset so that they get their default values.
+func pbJobToApiJob(job *pb.Job) (*api.Job, error) {
+	j := &api.Job{
+		Failover:    job.Failover,
+		Datacenters: job.Datacenters,
+	}
+	for _, f := range []struct {
+		dst **string
+		val string
+	}{
+		{&j.ID, job.Id},
+		{&j.Name, job.Name},
+		{&j.Namespace, job.Namespace},
+		{&j.Region, job.Region},
+		{&j.Type, job.Type},
+	} {
+		if f.val != "" {
+			val := f.val
+			*f.dst = &val
+		}
+	}
+
+	for _, task := range job.Tasks {
+		t := &api.Task{
+			Type:     task.Type,
+			Driver:   task.Driver,
+			NodeID:   task.NodeId,
+			NodeName: task.NodeName,
+			Config:   make(map[string]interface{}),
+		}
+		if task.ConfigJson != "" {
+			if err := json.Unmarshal([]byte(task.ConfigJson), &t.Config); err != nil {
+				return nil, fmt.Errorf("invalid config of task %q: %v", task.Type, err)
+			}
+		}
+		j.Tasks = append(j.Tasks, t)
+	}
+	return j, nil
+}
+
+// structJobToPbJob converts a job to a job of the gRPC API
+func structJobToPbJob(job *models.Job) (*pb.Job, error) {
+	j := &pb.Job{
+		Id:                job.ID,
+		Name:              job.Name,
+		Namespace:         job.Namespace,
+		Region:            job.Region,
+		Type:              job.Type,
+		Datacenters:       job.Datacenters,
+		Failover:          job.Failover,
+		Status:            job.Status,
+		StatusDescription: job.StatusDescription,
+		CreateIndex:       job.CreateIndex,
+		ModifyIndex:       job.ModifyIndex,
+		JobModifyIndex:    job.JobModifyIndex,
+	}
+	for _, task := range job.Tasks {
+		config, err := json.Marshal(task.Config)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode config of task %q: %v", task.Type, err)
+		}
+		j.Tasks = append(j.Tasks, &pb.Task{
+			Type:       task.Type,
+			Driver:     task.Driver,
+			NodeId:     task.NodeID,
+			NodeName:   task.NodeName,
+			ConfigJson: string(config),
+		})
+	}
+	return j, nil
+}
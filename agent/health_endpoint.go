@@ -0,0 +1,118 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package agent
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+const (
+	// healthMinFreeDiskPercent is the free space of the data dir below
+	// which an agent is unhealthy
+	healthMinFreeDiskPercent = 5
+)
+
+// componentHealth is the health of a component an agent depends on
+type componentHealth struct {
+	Healthy bool
+	Message string `json:",omitempty"`
+}
+
+// agentHealth is the health of an agent and of its components
+type agentHealth struct {
+	Healthy    bool
+	Components map[string]*componentHealth
+}
+
+func (h *agentHealth) set(component string, message string, err error) {
+	if err != nil {
+		h.Healthy = false
+		h.Components[component] = &componentHealth{Message: err.Error()}
+		return
+	}
+	h.Components[component] = &componentHealth{Healthy: true, Message: message}
+}
+
+// HealthRequest is the liveness probe of the agent: it fails when the
+// components of the agent itself, its NATS server and its data dir, fail
+func (s *HTTPServer) HealthRequest(resp http.ResponseWriter, req *http.Request) (interface{}, error) {
+	if req.Method != "GET" && req.Method != "HEAD" {
+		return nil, CodedError(405, ErrInvalidMethod)
+	}
+	return nil, writeHealth(resp, s.agent.checkHealth(false))
+}
+
+// ReadyRequest is the readiness probe of the agent: it also fails when the
+// cluster of a server has no leader or its Consul store is unreachable
+func (s *HTTPServer) ReadyRequest(resp http.ResponseWriter, req *http.Request) (interface{}, error) {
+	if req.Method != "GET" && req.Method != "HEAD" {
+		return nil, CodedError(405, ErrInvalidMethod)
+	}
+	return nil, writeHealth(resp, s.agent.checkHealth(true))
+}
+
+// writeHealth writes the health of an agent, with the 503 status code if
+// it is unhealthy
+func writeHealth(resp http.ResponseWriter, health *agentHealth) error {
+	buf, err := json.Marshal(health)
+	if err != nil {
+		return err
+	}
+	resp.Header().Set("Content-Type", "application/json")
+	if !health.Healthy {
+		resp.WriteHeader(http.StatusServiceUnavailable)
+	}
+	_, err = resp.Write(buf)
+	return err
+}
+
+// checkHealth checks the components of the agent, and its dependencies
+// when ready is set
+func (a *Agent) checkHealth(ready bool) *agentHealth {
+	health := &agentHealth{
+		Healthy:    true,
+		Components: make(map[string]*componentHealth),
+	}
+	if client := a.Client(); client != nil {
+		health.set("nats", "", client.CheckNats())
+	}
+	if a.config.DataDir != "" {
+		message, err := checkDisk(a.config.DataDir)
+		health.set("disk", message, err)
+	}
+	if !ready {
+		return health
+	}
+
+	if server := a.Server(); server != nil {
+		message, err := server.CheckConsensus()
+		health.set("raft", message, err)
+		if a.config.Consul != nil && a.config.Consul.Addr != "" {
+			health.set("consul", "", server.CheckConsul())
+		}
+	}
+	return health
+}
+
+// checkDisk returns an error if the file system of a dir is nearly full
+func checkDisk(dir string) (string, error) {
+	free, total, err := diskUsage(dir)
+	if err != nil {
+		return "", err
+	}
+	if total == 0 {
+		return "", nil
+	}
+	percent := free * 100 / total
+	message := fmt.Sprintf("%d%% free in %s", percent, dir)
+	if percent < healthMinFreeDiskPercent {
+		return "", fmt.Errorf("only %s", message)
+	}
+	return message, nil
+}
@@ -0,0 +1,12 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package agent
+
+// diskUsage is not supported on windows, where the disk is not checked
+func diskUsage(path string) (free uint64, total uint64, err error) {
+	return 0, 0, nil
+}
@@ -13,6 +13,7 @@ import (
 	"io"
 	"net"
 	"net/http"
+	"reflect"
 	"strconv"
 	"time"
 
@@ -52,6 +53,7 @@ type HTTPServer struct {
 	logger   *log.Logger
 	uiDir    string
 	addr     string
+	frontend *httpFrontend
 }
 
 // NewHTTPServer starts new HTTP server over the agent
@@ -79,9 +81,14 @@ func NewHTTPServer(agent *Agent, config *Config, logOutput io.Writer) (*HTTPServ
 		addr:     ln.Addr().String(),
 	}
 	srv.registerHandlers()
+	srv.frontend, err = newHTTPFrontend(config.HTTPAPI, mux)
+	if err != nil {
+		ln.Close()
+		return nil, err
+	}
 
 	// Start the server
-	go http.Serve(ln, gziphandler.GzipHandler(mux))
+	go http.Serve(ln, gziphandler.GzipHandler(srv.frontend))
 	return srv, nil
 }
 
@@ -152,8 +159,12 @@ func (s *HTTPServer) registerHandlers() {
 	s.mux.HandleFunc("/v1/job/renewal", s.wrap(s.JobsRenewalRequest))
 	s.mux.HandleFunc("/v1/job/info", s.wrap(s.JobsInfoRequest))
 	s.mux.HandleFunc("/v1/validate/job", s.wrap(s.ValidateJobRequest))
+	s.mux.HandleFunc("/v1/estimate/tables", s.wrap(s.EstimateTablesRequest))
 	s.mux.HandleFunc("/v1/job/", s.wrap(s.JobSpecificRequest))
 
+	s.mux.HandleFunc("/v1/namespaces", s.wrap(s.NamespacesRequest))
+	s.mux.HandleFunc("/v1/namespace/", s.wrap(s.NamespaceSpecificRequest))
+
 	s.mux.HandleFunc("/v1/nodes", s.wrap(s.NodesRequest))
 	s.mux.HandleFunc("/v1/node/", s.wrap(s.NodeSpecificRequest))
 
@@ -164,6 +175,7 @@ func (s *HTTPServer) registerHandlers() {
 	s.mux.HandleFunc("/v1/evaluation/", s.wrap(s.EvalSpecificRequest))
 
 	s.mux.HandleFunc("/v1/agent/allocation/", s.wrap(s.ClientAllocRequest))
+	s.mux.HandleFunc("/v1/agent/debug/", s.wrap(s.AgentDebugRequest))
 
 	s.mux.HandleFunc("/v1/self", s.wrap(s.AgentSelfRequest))
 	s.mux.HandleFunc("/v1/join", s.wrap(s.AgentJoinRequest))
@@ -178,6 +190,11 @@ func (s *HTTPServer) registerHandlers() {
 
 	s.mux.HandleFunc("/v1/operator/", s.wrap(s.OperatorRequest))
 
+	s.mux.HandleFunc("/v1/openapi.json", s.wrap(s.OpenAPIRequest))
+
+	s.mux.HandleFunc("/health", s.wrap(s.HealthRequest))
+	s.mux.HandleFunc("/ready", s.wrap(s.ReadyRequest))
+
 	if s.agent.config.LogLevel == "DEBUG" {
 		s.mux.HandleFunc("/debug/pprof/", pprof.Index)
 		s.mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
@@ -233,7 +250,7 @@ func (s *HTTPServer) wrap(handler func(resp http.ResponseWriter, req *http.Reque
 		// Check for an error
 	HAS_ERR:
 		if err != nil {
-			s.logger.Errorf("http: Request %v, error: %v", reqURL, err)
+			s.logger.Errorf("http: Request %v from %v, error: %v", reqURL, s.frontend.clientAddr(req), err)
 			code := 500
 			if http, ok := err.(HTTPCodedError); ok {
 				code = http.Code()
@@ -304,6 +321,9 @@ func setMeta(resp http.ResponseWriter, m *umodel.QueryMeta) {
 	setIndex(resp, m.Index)
 	setLastContact(resp, m.LastContact)
 	setKnownLeader(resp, m.KnownLeader)
+	if m.NextToken != "" {
+		resp.Header().Set("X-Udup-NextToken", m.NextToken)
+	}
 }
 
 // setHeaders is used to set canonical response header fields
@@ -338,6 +358,49 @@ func parseWait(resp http.ResponseWriter, req *http.Request, b *umodel.QueryOptio
 	return false
 }
 
+// parsePagination is used to parse the ?per_page and ?next_token query params
+// Returns true on error
+func parsePagination(resp http.ResponseWriter, req *http.Request, b *umodel.QueryOptions) bool {
+	query := req.URL.Query()
+	if perPage := query.Get("per_page"); perPage != "" {
+		n, err := strconv.Atoi(perPage)
+		if err != nil || n < 0 {
+			resp.WriteHeader(400)
+			resp.Write([]byte("Invalid per_page"))
+			return true
+		}
+		b.PerPage = n
+	}
+	b.NextToken = query.Get("next_token")
+	return false
+}
+
+// selectFields is used to apply the ?fields query param to a list of
+// structs, keeping only the comma separated fields of each item
+func selectFields(req *http.Request, list interface{}) (interface{}, error) {
+	fields := req.URL.Query().Get("fields")
+	if fields == "" {
+		return list, nil
+	}
+	names := strings.Split(fields, ",")
+	v := reflect.ValueOf(list)
+	out := make([]map[string]interface{}, 0, v.Len())
+	for i := 0; i < v.Len(); i++ {
+		item := reflect.Indirect(v.Index(i))
+		selected := make(map[string]interface{}, len(names))
+		for _, name := range names {
+			name = strings.TrimSpace(name)
+			field := item.FieldByName(name)
+			if !field.IsValid() || !field.CanInterface() {
+				return nil, CodedError(400, fmt.Sprintf("Invalid field %q", name))
+			}
+			selected[name] = field.Interface()
+		}
+		out = append(out, selected)
+	}
+	return out, nil
+}
+
 // parseConsistency is used to parse the ?stale query params.
 func parseConsistency(req *http.Request, b *umodel.QueryOptions) {
 	query := req.URL.Query()
@@ -354,6 +417,14 @@ func parsePrefix(req *http.Request, b *umodel.QueryOptions) {
 	}
 }
 
+// parseNamespace is used to parse the ?namespace query param
+func parseNamespace(req *http.Request, b *umodel.QueryOptions) {
+	query := req.URL.Query()
+	if namespace := query.Get("namespace"); namespace != "" {
+		b.Namespace = namespace
+	}
+}
+
 // parseRegion is used to parse the ?region query param
 func (s *HTTPServer) parseRegion(req *http.Request, r *string) {
 	if other := req.URL.Query().Get("region"); other != "" {
@@ -368,5 +439,9 @@ func (s *HTTPServer) parse(resp http.ResponseWriter, req *http.Request, r *strin
 	s.parseRegion(req, r)
 	parseConsistency(req, b)
 	parsePrefix(req, b)
+	parseNamespace(req, b)
+	if parsePagination(resp, req, b) {
+		return true
+	}
 	return parseWait(resp, req, b)
 }
@@ -0,0 +1,164 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package agent
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+const (
+	// corsAllowedMethods are the methods of the HTTP API
+	corsAllowedMethods = "GET, PUT, POST, DELETE, OPTIONS"
+
+	// corsExposedHeaders are the response headers the pages calling the
+	// API may read
+	corsExposedHeaders = "X-Udup-Index, X-Udup-KnownLeader, X-Udup-LastContact, X-Udup-NextToken"
+)
+
+// httpFrontend answers the CORS requests of the browsers and strips the URL
+// prefix of the requests before passing them to the mux
+type httpFrontend struct {
+	config         *HTTPAPIConfig
+	trustedProxies []*net.IPNet
+	handler        http.Handler
+}
+
+func newHTTPFrontend(config *HTTPAPIConfig, handler http.Handler) (*httpFrontend, error) {
+	if config == nil {
+		config = &HTTPAPIConfig{}
+	}
+	f := &httpFrontend{
+		config:  config,
+		handler: handler,
+	}
+	for _, proxy := range config.TrustedProxies {
+		if !strings.Contains(proxy, "/") {
+			if ip := net.ParseIP(proxy); ip != nil && ip.To4() != nil {
+				proxy += "/32"
+			} else {
+				proxy += "/128"
+			}
+		}
+		_, ipNet, err := net.ParseCIDR(proxy)
+		if err != nil {
+			return nil, fmt.Errorf("invalid trusted proxy %q: %v", proxy, err)
+		}
+		f.trustedProxies = append(f.trustedProxies, ipNet)
+	}
+	if prefix := strings.TrimSuffix(config.URLPrefix, "/"); prefix != "" && !strings.HasPrefix(prefix, "/") {
+		return nil, fmt.Errorf("invalid url_prefix %q: it must start with /", config.URLPrefix)
+	}
+	return f, nil
+}
+
+func (f *httpFrontend) ServeHTTP(resp http.ResponseWriter, req *http.Request) {
+	if prefix := strings.TrimSuffix(f.config.URLPrefix, "/"); prefix != "" {
+		if req.URL.Path == prefix {
+			req.URL.Path = "/"
+			req.URL.RawPath = ""
+		} else if strings.HasPrefix(req.URL.Path, prefix+"/") {
+			req.URL.Path = strings.TrimPrefix(req.URL.Path, prefix)
+			req.URL.RawPath = ""
+		}
+	}
+
+	if origin := req.Header.Get("Origin"); origin != "" && f.corsAllowed(origin) {
+		h := resp.Header()
+		h.Set("Access-Control-Allow-Origin", origin)
+		h.Add("Vary", "Origin")
+		h.Set("Access-Control-Allow-Credentials", "true")
+		h.Set("Access-Control-Expose-Headers", corsExposedHeaders)
+
+		// Answer the preflight request itself
+		if req.Method == "OPTIONS" && req.Header.Get("Access-Control-Request-Method") != "" {
+			h.Set("Access-Control-Allow-Methods", corsAllowedMethods)
+			h.Set("Access-Control-Allow-Headers",
+				strings.Join(append([]string{"Content-Type"}, f.config.CORSAllowedHeaders...), ", "))
+			if f.config.CORSMaxAge > 0 {
+				h.Set("Access-Control-Max-Age", strconv.Itoa(f.config.CORSMaxAge))
+			}
+			resp.WriteHeader(http.StatusNoContent)
+			return
+		}
+	}
+
+	f.handler.ServeHTTP(resp, req)
+}
+
+// corsAllowed returns whether a page of an origin may call the API
+func (f *httpFrontend) corsAllowed(origin string) bool {
+	for _, allowed := range f.config.CORSAllowedOrigins {
+		if allowed == "*" || strings.EqualFold(allowed, origin) {
+			return true
+		}
+	}
+	return false
+}
+
+// fromTrustedProxy returns whether a request was sent by a trusted proxy,
+// whose X-Forwarded-* headers are used
+func (f *httpFrontend) fromTrustedProxy(req *http.Request) bool {
+	if f == nil || len(f.trustedProxies) == 0 {
+		return false
+	}
+	host, _, err := net.SplitHostPort(req.RemoteAddr)
+	if err != nil {
+		host = req.RemoteAddr
+	}
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return false
+	}
+	for _, ipNet := range f.trustedProxies {
+		if ipNet.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// clientAddr returns the address of the client of a request, the first
+// address of the X-Forwarded-For header of a trusted proxy
+func (f *httpFrontend) clientAddr(req *http.Request) string {
+	if f.fromTrustedProxy(req) {
+		if forwarded := req.Header.Get("X-Forwarded-For"); forwarded != "" {
+			return strings.TrimSpace(strings.Split(forwarded, ",")[0])
+		}
+	}
+	return req.RemoteAddr
+}
+
+// externalURL returns the URL the clients reach the API at: the scheme,
+// host and prefix forwarded by a trusted proxy, those of the request
+// otherwise
+func (f *httpFrontend) externalURL(req *http.Request) string {
+	scheme := "http"
+	if req.TLS != nil {
+		scheme = "https"
+	}
+	host := req.Host
+	var prefix string
+	if f != nil {
+		prefix = strings.TrimSuffix(f.config.URLPrefix, "/")
+	}
+	if f.fromTrustedProxy(req) {
+		if proto := req.Header.Get("X-Forwarded-Proto"); proto != "" {
+			scheme = strings.TrimSpace(strings.Split(proto, ",")[0])
+		}
+		if forwardedHost := req.Header.Get("X-Forwarded-Host"); forwardedHost != "" {
+			host = strings.TrimSpace(strings.Split(forwardedHost, ",")[0])
+		}
+		if forwardedPrefix := req.Header.Get("X-Forwarded-Prefix"); forwardedPrefix != "" {
+			prefix = strings.TrimSuffix(forwardedPrefix, "/")
+		}
+	}
+	return scheme + "://" + host + prefix
+}
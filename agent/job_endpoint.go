@@ -7,19 +7,30 @@
 package agent
 
 import (
+	"bytes"
+	"encoding/json"
 	"fmt"
 	"net/http"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/mitchellh/mapstructure"
+	"github.com/ugorji/go/codec"
 
 	"github.com/actiontech/dtle/api"
 	"github.com/actiontech/dtle/internal/client/driver/mysql/sql"
 	"github.com/actiontech/dtle/internal/config"
+	"github.com/actiontech/dtle/internal/jobspec"
 	"github.com/actiontech/dtle/internal/models"
 )
 
+const (
+	// eventStreamHeartbeat is how often an idle event stream is written to,
+	// so that closed clients are noticed
+	eventStreamHeartbeat = 30 * time.Second
+)
+
 func (s *HTTPServer) JobsRequest(resp http.ResponseWriter, req *http.Request) (interface{}, error) {
 	switch req.Method {
 	case "GET":
@@ -78,6 +89,9 @@ func (s *HTTPServer) jobInfoRequest(resp http.ResponseWriter, req *http.Request)
 			if "" == driverConfig.ConnectionConfig.Charset {
 				driverConfig.ConnectionConfig.Charset = "utf8"
 			}
+			if err := driverConfig.ConnectionConfig.Prepare(); err != nil {
+				return nil, err
+			}
 			uri := driverConfig.ConnectionConfig.GetDBUri()
 			db, err := sql.CreateDB(uri)
 			defer db.Close()
@@ -127,6 +141,23 @@ func (s *HTTPServer) jobListRequest(resp http.ResponseWriter, req *http.Request)
 	if s.parse(resp, req, &args.Region, &args.QueryOptions) {
 		return nil, nil
 	}
+	query := req.URL.Query()
+	args.NamePrefix = query.Get("name_prefix")
+	if status := query.Get("status"); status != "" {
+		args.Statuses = strings.Split(status, ",")
+	}
+	for _, labels := range query["label"] {
+		for _, label := range strings.Split(labels, ",") {
+			kv := strings.SplitN(label, "=", 2)
+			if len(kv) != 2 || kv[0] == "" {
+				return nil, CodedError(400, fmt.Sprintf("Invalid label %q, expected key=value", label))
+			}
+			if args.Labels == nil {
+				args.Labels = make(map[string]string)
+			}
+			args.Labels[kv[0]] = kv[1]
+		}
+	}
 
 	var out models.JobListResponse
 	if err := s.agent.RPC("Job.List", &args, &out); err != nil {
@@ -137,7 +168,7 @@ func (s *HTTPServer) jobListRequest(resp http.ResponseWriter, req *http.Request)
 	if out.Jobs == nil {
 		out.Jobs = make([]*models.JobListStub, 0)
 	}
-	return out.Jobs, nil
+	return selectFields(req, out.Jobs)
 }
 
 func (s *HTTPServer) JobSpecificRequest(resp http.ResponseWriter, req *http.Request) (interface{}, error) {
@@ -155,6 +186,24 @@ func (s *HTTPServer) JobSpecificRequest(resp http.ResponseWriter, req *http.Requ
 	case strings.HasSuffix(path, "/evaluations"):
 		jobName := strings.TrimSuffix(path, "/evaluations")
 		return s.jobEvaluations(resp, req, jobName)
+	case strings.HasSuffix(path, "/plan"):
+		jobName := strings.TrimSuffix(path, "/plan")
+		return s.jobPlan(resp, req, jobName)
+	case strings.HasSuffix(path, "/events"):
+		jobName := strings.TrimSuffix(path, "/events")
+		return s.jobEvents(resp, req, jobName)
+	case strings.HasSuffix(path, "/cutover"):
+		jobName := strings.TrimSuffix(path, "/cutover")
+		return s.jobCutover(resp, req, jobName)
+	case strings.HasSuffix(path, "/schema-history"):
+		jobName := strings.TrimSuffix(path, "/schema-history")
+		return s.jobSchemaHistory(resp, req, jobName)
+	case strings.HasSuffix(path, "/ddl"):
+		jobName := strings.TrimSuffix(path, "/ddl")
+		return s.jobDDLHistory(resp, req, jobName)
+	case strings.HasSuffix(path, "/skip-ddl"):
+		jobName := strings.TrimSuffix(path, "/skip-ddl")
+		return s.jobSkipDDL(resp, req, jobName)
 	default:
 		return s.jobCRUD(resp, req, path)
 	}
@@ -217,6 +266,103 @@ func (s *HTTPServer) jobEvaluations(resp http.ResponseWriter, req *http.Request,
 	return out.Evaluations, nil
 }
 
+func (s *HTTPServer) jobEvents(resp http.ResponseWriter, req *http.Request,
+	jobName string) (interface{}, error) {
+	if req.Method != "GET" {
+		return nil, CodedError(405, ErrInvalidMethod)
+	}
+	args := models.JobSpecificRequest{
+		JobID: jobName,
+	}
+	if args.Region == "" {
+		args.Region = s.agent.config.Region
+	}
+	if s.parse(resp, req, &args.Region, &args.QueryOptions) {
+		return nil, nil
+	}
+	if _, ok := req.URL.Query()["stream"]; ok {
+		return s.jobEventStream(resp, req, &args)
+	}
+
+	var out models.JobEventsResponse
+	if err := s.agent.RPC("Job.Events", &args, &out); err != nil {
+		return nil, err
+	}
+
+	setMeta(resp, &out.QueryMeta)
+	if out.Events == nil {
+		out.Events = make([]*models.JobEvent, 0)
+	}
+	return out.Events, nil
+}
+
+// jobEventStream streams the events of a job as server-sent events, each
+// one as soon as it is recorded. Events up to the ?index param or the
+// Last-Event-ID header are skipped, so clients can resume a stream.
+func (s *HTTPServer) jobEventStream(resp http.ResponseWriter, req *http.Request,
+	args *models.JobSpecificRequest) (interface{}, error) {
+	flusher, ok := resp.(http.Flusher)
+	if !ok {
+		return nil, CodedError(500, "Streaming not supported")
+	}
+	if id := req.Header.Get("Last-Event-ID"); id != "" {
+		index, err := strconv.ParseUint(id, 10, 64)
+		if err != nil {
+			return nil, CodedError(400, "Invalid Last-Event-ID")
+		}
+		args.MinQueryIndex = index
+	}
+	if args.MaxQueryTime == 0 {
+		args.MaxQueryTime = eventStreamHeartbeat
+	}
+
+	resp.Header().Set("Content-Type", "text/event-stream")
+	resp.Header().Set("Cache-Control", "no-cache")
+	resp.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	closeCh := req.Context().Done()
+	index := args.MinQueryIndex
+	for {
+		args.MinQueryIndex = index
+		var out models.JobEventsResponse
+		if err := s.agent.RPC("Job.Events", args, &out); err != nil {
+			// The response has been started, so the error can only be
+			// reported in the stream.
+			fmt.Fprintf(resp, "event: error\ndata: %s\n\n", strings.Replace(err.Error(), "\n", " ", -1))
+			flusher.Flush()
+			return nil, nil
+		}
+
+		sent := false
+		for _, event := range out.Events {
+			if event.CreateIndex <= index {
+				continue
+			}
+			var buf bytes.Buffer
+			if err := codec.NewEncoder(&buf, jsonHandle).Encode(event); err != nil {
+				return nil, nil
+			}
+			fmt.Fprintf(resp, "id: %d\nevent: %s\ndata: %s\n\n", event.CreateIndex, event.Type, buf.String())
+			sent = true
+		}
+		if !sent {
+			// Keep the connection alive and notice closed clients
+			fmt.Fprint(resp, ": heartbeat\n\n")
+		}
+		flusher.Flush()
+
+		if out.Index > index {
+			index = out.Index
+		}
+		select {
+		case <-closeCh:
+			return nil, nil
+		default:
+		}
+	}
+}
+
 func (s *HTTPServer) jobCRUD(resp http.ResponseWriter, req *http.Request,
 	jobName string) (interface{}, error) {
 	switch req.Method {
@@ -260,9 +406,8 @@ func (s *HTTPServer) jobQuery(resp http.ResponseWriter, req *http.Request,
 
 func (s *HTTPServer) jobUpdate(resp http.ResponseWriter, req *http.Request,
 	jobName string) (interface{}, error) {
-	var args *api.Job
-	var trafficLimit int
-	if err := decodeBody(req, &args); err != nil {
+	args, err := decodeJob(req)
+	if err != nil {
 		return nil, CodedError(400, err.Error())
 	}
 
@@ -277,23 +422,9 @@ func (s *HTTPServer) jobUpdate(resp http.ResponseWriter, req *http.Request,
 	}
 	s.parseRegion(req, args.Region)
 
-	for _, order := range args.Orders {
-		argsOrder := models.OrderSpecificRequest{
-			OrderID: order,
-		}
-		if s.parse(resp, req, &argsOrder.Region, &argsOrder.QueryOptions) {
-			return nil, nil
-		}
-		var outOrder models.SingleOrderResponse
-		if err := s.agent.RPC("Order.GetOrder", &argsOrder, &outOrder); err != nil {
-			return nil, err
-		}
-
-		setMeta(resp, &outOrder.QueryMeta)
-		if outOrder.Order == nil {
-			return nil, CodedError(404, "order not found")
-		}
-		trafficLimit += outOrder.Order.TrafficAgainstLimits
+	trafficLimit, err := s.ordersTrafficLimit(*args.Region, args.Orders)
+	if err != nil {
+		return nil, err
 	}
 
 	sJob := ApiJobToStructJob(args, trafficLimit)
@@ -315,6 +446,101 @@ func (s *HTTPServer) jobUpdate(resp http.ResponseWriter, req *http.Request,
 	return out, nil
 }
 
+// decodeJob decodes the job of a request body. The job is written in HCL if
+// the request has the application/hcl content type, in JSON otherwise, as
+// is or in the api.RegisterJobRequest of the API client.
+func decodeJob(req *http.Request) (*api.Job, error) {
+	if strings.HasPrefix(req.Header.Get("Content-Type"), "application/hcl") {
+		return jobspec.Parse(req.Body)
+	}
+	var body json.RawMessage
+	if err := decodeBody(req, &body); err != nil {
+		return nil, err
+	}
+	var register api.RegisterJobRequest
+	if err := json.Unmarshal(body, &register); err == nil && register.Job != nil {
+		job := register.Job
+		if register.EnforceIndex {
+			job.EnforceIndex = true
+			job.JobModifyIndex = &register.JobModifyIndex
+		}
+		return job, nil
+	}
+	var job *api.Job
+	if err := json.Unmarshal(body, &job); err != nil {
+		return nil, err
+	}
+	if job == nil {
+		return nil, fmt.Errorf("Job must be specified")
+	}
+	return job, nil
+}
+
+func (s *HTTPServer) jobPlan(resp http.ResponseWriter, req *http.Request,
+	jobName string) (interface{}, error) {
+	if req.Method != "PUT" && req.Method != "POST" {
+		return nil, CodedError(405, ErrInvalidMethod)
+	}
+
+	var args api.JobPlanRequest
+	if err := decodeBody(req, &args); err != nil {
+		return nil, CodedError(400, err.Error())
+	}
+	if args.Job == nil {
+		return nil, CodedError(400, "Job must be specified")
+	}
+	if args.Job.ID == nil {
+		args.Job.ID = &jobName
+	} else if *args.Job.ID != jobName {
+		return nil, CodedError(400, "Job ID does not match")
+	}
+	if args.Job.Region == nil {
+		args.Job.Region = &s.agent.config.Region
+	}
+	s.parseRegion(req, args.Job.Region)
+
+	trafficLimit, err := s.ordersTrafficLimit(*args.Job.Region, args.Job.Orders)
+	if err != nil {
+		return nil, err
+	}
+
+	planReq := models.JobPlanRequest{
+		Job:  ApiJobToStructJob(args.Job, trafficLimit),
+		Diff: args.Diff,
+		WriteRequest: models.WriteRequest{
+			Region: *args.Job.Region,
+		},
+	}
+	var out models.JobPlanResponse
+	if err := s.agent.RPC("Job.Plan", &planReq, &out); err != nil {
+		return nil, err
+	}
+	setIndex(resp, out.Index)
+	return out, nil
+}
+
+// ordersTrafficLimit sums the traffic limits of the orders of a job
+func (s *HTTPServer) ordersTrafficLimit(region string, orders []string) (int, error) {
+	var trafficLimit int
+	for _, order := range orders {
+		argsOrder := models.OrderSpecificRequest{
+			OrderID: order,
+			QueryOptions: models.QueryOptions{
+				Region: region,
+			},
+		}
+		var outOrder models.SingleOrderResponse
+		if err := s.agent.RPC("Order.GetOrder", &argsOrder, &outOrder); err != nil {
+			return 0, err
+		}
+		if outOrder.Order == nil {
+			return 0, CodedError(404, "order not found")
+		}
+		trafficLimit += outOrder.Order.TrafficAgainstLimits
+	}
+	return trafficLimit, nil
+}
+
 func (s *HTTPServer) jobRenewalRequest(resp http.ResponseWriter, req *http.Request) (interface{}, error) {
 	var args *api.RenewalJobRequest
 	if err := decodeBody(req, &args); err != nil {
@@ -388,6 +614,130 @@ func (s *HTTPServer) jobResumeRequest(resp http.ResponseWriter, req *http.Reques
 	return out, nil
 }
 
+func (s *HTTPServer) jobCutover(resp http.ResponseWriter, req *http.Request,
+	jobName string) (interface{}, error) {
+	if req.Method != "PUT" && req.Method != "POST" {
+		return nil, CodedError(405, ErrInvalidMethod)
+	}
+
+	var args api.JobCutoverRequest
+	if req.ContentLength != 0 {
+		if err := decodeBody(req, &args); err != nil {
+			return nil, CodedError(400, err.Error())
+		}
+	}
+	if args.MaxLag < 0 {
+		return nil, CodedError(400, "MaxLag must not be negative")
+	}
+
+	cutoverReq := models.JobCutoverRequest{
+		JobID:    jobName,
+		MaxLag:   args.MaxLag,
+		ReadOnly: args.ReadOnly,
+		Timeout:  args.Timeout,
+	}
+	s.parseRegion(req, &cutoverReq.Region)
+
+	var out models.JobCutoverResponse
+	if err := s.agent.RPC("Job.Cutover", &cutoverReq, &out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// jobDDLHistory lists the last DDLs a job replicated to its target
+func (s *HTTPServer) jobDDLHistory(resp http.ResponseWriter, req *http.Request,
+	jobName string) (interface{}, error) {
+	if req.Method != "GET" {
+		return nil, CodedError(405, ErrInvalidMethod)
+	}
+	args := models.JobSpecificRequest{
+		JobID: jobName,
+	}
+	if s.parse(resp, req, &args.Region, &args.QueryOptions) {
+		return nil, nil
+	}
+
+	var out models.JobDDLHistoryResponse
+	if err := s.agent.RPC("Job.DDLHistory", &args, &out); err != nil {
+		return nil, err
+	}
+	setMeta(resp, &out.QueryMeta)
+	if out.DDLs == nil {
+		out.DDLs = make([]*models.DDLRecord, 0)
+	}
+	return out.DDLs, nil
+}
+
+// jobSchemaHistory lists the versions of the tables a job replicated
+func (s *HTTPServer) jobSchemaHistory(resp http.ResponseWriter, req *http.Request,
+	jobName string) (interface{}, error) {
+	if req.Method != "GET" {
+		return nil, CodedError(405, ErrInvalidMethod)
+	}
+	args := models.JobSpecificRequest{
+		JobID: jobName,
+	}
+	if s.parse(resp, req, &args.Region, &args.QueryOptions) {
+		return nil, nil
+	}
+
+	var out models.JobSchemaHistoryResponse
+	if err := s.agent.RPC("Job.SchemaHistory", &args, &out); err != nil {
+		return nil, err
+	}
+	setMeta(resp, &out.QueryMeta)
+	if out.Versions == nil {
+		out.Versions = make([]*models.SchemaVersion, 0)
+	}
+	return out.Versions, nil
+}
+
+// jobSkipDDL declares a DDL applied manually on the target of a job, or
+// lists those the job has yet to skip
+func (s *HTTPServer) jobSkipDDL(resp http.ResponseWriter, req *http.Request,
+	jobName string) (interface{}, error) {
+	switch req.Method {
+	case "GET":
+		args := models.JobSpecificRequest{
+			JobID: jobName,
+		}
+		if s.parse(resp, req, &args.Region, &args.QueryOptions) {
+			return nil, nil
+		}
+		var out models.JobSkippedDDLsResponse
+		if err := s.agent.RPC("Job.SkippedDDLs", &args, &out); err != nil {
+			return nil, err
+		}
+		setMeta(resp, &out.QueryMeta)
+		if out.DDLs == nil {
+			out.DDLs = make([]*models.SkippedDDL, 0)
+		}
+		return out.DDLs, nil
+	case "PUT", "POST":
+		var args api.JobSkipDDLRequest
+		if err := decodeBody(req, &args); err != nil {
+			return nil, CodedError(400, err.Error())
+		}
+		if strings.TrimSpace(args.Query) == "" {
+			return nil, CodedError(400, "missing the DDL to skip")
+		}
+		skipReq := models.JobSkipDDLRequest{
+			JobID: jobName,
+			Query: args.Query,
+		}
+		s.parseRegion(req, &skipReq.Region)
+
+		var out models.JobSkipDDLResponse
+		if err := s.agent.RPC("Job.SkipDDL", &skipReq, &out); err != nil {
+			return nil, err
+		}
+		return out, nil
+	default:
+		return nil, CodedError(405, ErrInvalidMethod)
+	}
+}
+
 func (s *HTTPServer) jobPauseRequest(resp http.ResponseWriter, req *http.Request, name string) (interface{}, error) {
 	args := models.JobUpdateStatusRequest{
 		JobID:  name,
@@ -432,14 +782,45 @@ func (s *HTTPServer) ValidateJobRequest(resp http.ResponseWriter, req *http.Requ
 	return out, nil
 }
 
+// EstimateTablesRequest estimates the tables a source task would replicate
+func (s *HTTPServer) EstimateTablesRequest(resp http.ResponseWriter, req *http.Request) (interface{}, error) {
+	if !(req.Method == "POST" || req.Method == "PUT") {
+		return nil, CodedError(405, ErrInvalidMethod)
+	}
+
+	var task api.Task
+	if err := decodeBody(req, &task); err != nil {
+		return nil, CodedError(400, err.Error())
+	}
+	if task.Config == nil {
+		return nil, CodedError(400, "Missing task config")
+	}
+	args := models.TableEstimateRequest{
+		Task: models.NewTask(),
+	}
+	ApiTaskToStructsTask(&task, args.Task)
+	s.parseRegion(req, &args.Region)
+
+	var out models.TableEstimateResponse
+	if err := s.agent.RPC("Job.EstimateTables", &args, &out); err != nil {
+		return nil, err
+	}
+	if out.Tables == nil {
+		out.Tables = make([]*models.TableEstimate, 0)
+	}
+	return out, nil
+}
+
 func ApiJobToStructJob(job *api.Job, trafficLimit int) *models.Job {
 	job.Canonicalize()
 
 	j := &models.Job{
 		Region:            *job.Region,
 		ID:                *job.ID,
+		Namespace:         *job.Namespace,
 		Orders:            job.Orders,
 		Name:              *job.Name,
+		Labels:            job.Labels,
 		Failover:          job.Failover,
 		Type:              *job.Type,
 		Datacenters:       job.Datacenters,
@@ -450,6 +831,34 @@ func ApiJobToStructJob(job *api.Job, trafficLimit int) *models.Job {
 		JobModifyIndex:    *job.JobModifyIndex,
 	}
 
+	if job.Restart != nil {
+		j.Restart = &models.RestartPolicy{
+			Attempts: job.Restart.Attempts,
+			Interval: job.Restart.Interval,
+			Delay:    job.Restart.Delay,
+			MaxDelay: job.Restart.MaxDelay,
+			Mode:     job.Restart.Mode,
+		}
+		j.Restart.Canonicalize()
+	}
+	if job.Reschedule != nil {
+		j.Reschedule = &models.ReschedulePolicy{
+			Attempts:   job.Reschedule.Attempts,
+			Interval:   job.Reschedule.Interval,
+			Delay:      job.Reschedule.Delay,
+			MaxDelay:   job.Reschedule.MaxDelay,
+			OnFailure:  job.Reschedule.OnFailure,
+			OnLostNode: job.Reschedule.OnLostNode || job.Failover,
+		}
+		j.Reschedule.Canonicalize()
+	}
+	if job.Alert != nil {
+		j.Alert = &models.AlertPolicy{
+			MaxSecondsBehind:   job.Alert.MaxSecondsBehind,
+			MaxErrorsPerMinute: job.Alert.MaxErrorsPerMinute,
+		}
+	}
+
 	j.Tasks = make([]*models.Task, len(job.Tasks))
 	cfg := ""
 	for _, task := range job.Tasks {
@@ -464,6 +873,18 @@ func ApiJobToStructJob(job *api.Job, trafficLimit int) *models.Job {
 			task.Driver = models.TaskDriverMySQL
 		}
 	}
+	for _, task := range job.Tasks {
+		if task.Type != models.TaskTypeDest || task.Driver != models.TaskDriverMySQL {
+			continue
+		}
+		// The consistency check of the source compares the rows on the target
+		for _, src := range job.Tasks {
+			if check := configBlock(src.Config, "ConsistencyCheck"); check != nil && src.Type == models.TaskTypeSrc {
+				check["TargetConnectionConfig"] = task.Config["ConnectionConfig"]
+				src.Config["ConsistencyCheck"] = check
+			}
+		}
+	}
 	for i, task := range job.Tasks {
 		if task.Type == models.TaskTypeDest {
 			task.Leader = true
@@ -477,6 +898,26 @@ func ApiJobToStructJob(job *api.Job, trafficLimit int) *models.Job {
 	return j
 }
 
+// configBlock returns the block of a task config, which a job specification
+// in HCL gives as a list of one block
+func configBlock(config map[string]interface{}, key string) map[string]interface{} {
+	switch v := config[key].(type) {
+	case map[string]interface{}:
+		return v
+	case []map[string]interface{}:
+		if len(v) == 1 {
+			return v[0]
+		}
+	case []interface{}:
+		if len(v) == 1 {
+			if m, ok := v[0].(map[string]interface{}); ok {
+				return m
+			}
+		}
+	}
+	return nil
+}
+
 func ApiTaskToStructsTask(apiTask *api.Task, structsTask *models.Task) {
 	structsTask.Type = apiTask.Type
 	structsTask.NodeID = apiTask.NodeID
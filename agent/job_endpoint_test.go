@@ -7,12 +7,14 @@
 package agent
 
 import (
-	"log"
 	"net"
 	"net/http"
 	"reflect"
 	"testing"
+	"time"
+
 	"github.com/actiontech/dtle/api"
+	log "github.com/actiontech/dtle/internal/logger"
 	"github.com/actiontech/dtle/internal/models"
 )
 
@@ -488,7 +490,8 @@ func TestHTTPServer_jobPauseRequest(t *testing.T) {
 
 func TestApiJobToStructJob(t *testing.T) {
 	type args struct {
-		job *api.Job
+		job          *api.Job
+		trafficLimit int
 	}
 	tests := []struct {
 		name string
@@ -499,13 +502,59 @@ func TestApiJobToStructJob(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			if got := ApiJobToStructJob(tt.args.job); !reflect.DeepEqual(got, tt.want) {
+			if got := ApiJobToStructJob(tt.args.job, tt.args.trafficLimit); !reflect.DeepEqual(got, tt.want) {
 				t.Errorf("ApiJobToStructJob() = %v, want %v", got, tt.want)
 			}
 		})
 	}
 }
 
+func TestApiJobToStructJob_partialPolicies(t *testing.T) {
+	job := ApiJobToStructJob(&api.Job{
+		Restart:    &api.RestartPolicy{Attempts: 3, Delay: time.Minute},
+		Reschedule: &api.ReschedulePolicy{OnFailure: true},
+	}, 0)
+	if err := job.Restart.Validate(); err != nil {
+		t.Errorf("restart policy: %v", err)
+	}
+	wantRestart := &models.RestartPolicy{
+		Attempts: 3,
+		Interval: time.Minute,
+		Delay:    time.Minute,
+		MaxDelay: time.Minute,
+		Mode:     models.RestartPolicyModeDelay,
+	}
+	if !reflect.DeepEqual(job.Restart, wantRestart) {
+		t.Errorf("restart policy %+v, want %+v", job.Restart, wantRestart)
+	}
+	wantReschedule := models.DefaultReschedulePolicy()
+	wantReschedule.OnFailure = true
+	if !reflect.DeepEqual(job.Reschedule, wantReschedule) {
+		t.Errorf("reschedule policy %+v, want %+v", job.Reschedule, wantReschedule)
+	}
+	alloc := &models.Allocation{}
+	if !alloc.RescheduleEligible(job.Reschedule, time.Now()) {
+		t.Errorf("a task of a partial reschedule policy is not rescheduled")
+	}
+
+	// the policies of a job registered without the API are filled too
+	job = &models.Job{
+		Failover:   true,
+		Restart:    &models.RestartPolicy{Mode: models.RestartPolicyModeFail},
+		Reschedule: &models.ReschedulePolicy{Attempts: 1},
+	}
+	job.Canonicalize()
+	if err := job.Restart.Validate(); err != nil {
+		t.Errorf("restart policy: %v", err)
+	}
+	if job.Restart.Attempts != 5 || job.Restart.Mode != models.RestartPolicyModeFail {
+		t.Errorf("restart policy %+v, want 5 attempts failing", job.Restart)
+	}
+	if job.Reschedule.Attempts != 1 || job.Reschedule.Interval != time.Hour || !job.Reschedule.OnLostNode {
+		t.Errorf("reschedule policy %+v, want 1 attempt per hour on a lost node", job.Reschedule)
+	}
+}
+
 func TestApiTaskToStructsTask(t *testing.T) {
 	type args struct {
 		apiTask     *api.Task
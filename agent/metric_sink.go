@@ -0,0 +1,372 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package agent
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"net"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/armon/go-metrics"
+
+	ulog "github.com/actiontech/dtle/internal/logger"
+)
+
+// pushSinkInterval is how often the metrics aggregated by a pushSink are
+// written, as the intervals of the in-memory sink
+const pushSinkInterval = 10 * time.Second
+
+// pushSink aggregates the metrics and periodically writes them to a backend
+// the metrics are pushed to: the last value of the gauges, and the counters
+// and samples of the interval
+type pushSink struct {
+	name   string
+	write  func(points []*metricPoint, now time.Time) error
+	logger *ulog.Logger
+
+	mutex sync.Mutex
+	// points are by the name and labels of the metric
+	gauges   map[string]*metricPoint
+	counters map[string]*metricPoint
+	samples  map[string]*metricPoint
+	start    time.Time
+}
+
+type metricKind int
+
+const (
+	metricGauge metricKind = iota
+	metricCounter
+	metricSample
+)
+
+// metricPoint is a metric over an interval
+type metricPoint struct {
+	kind   metricKind
+	name   string
+	labels []metrics.Label
+	start  time.Time
+	// value is the last value of a gauge, the sum of a counter or a sample
+	value float64
+	count int64
+	min   float64
+	max   float64
+}
+
+func newPushSink(name string, logger *ulog.Logger, write func(points []*metricPoint, now time.Time) error) *pushSink {
+	s := &pushSink{
+		name:     name,
+		write:    write,
+		logger:   logger,
+		gauges:   make(map[string]*metricPoint),
+		counters: make(map[string]*metricPoint),
+		samples:  make(map[string]*metricPoint),
+		start:    time.Now(),
+	}
+	go func() {
+		for now := range time.Tick(pushSinkInterval) {
+			if err := s.flush(now); err != nil {
+				s.logger.Warnf("agent: writing the metrics to %s: %v", s.name, err)
+			}
+		}
+	}()
+	return s
+}
+
+func (s *pushSink) SetGauge(key []string, val float32) {
+	s.SetGaugeWithLabels(key, val, nil)
+}
+
+func (s *pushSink) SetGaugeWithLabels(key []string, val float32, labels []metrics.Label) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	p := s.point(s.gauges, metricGauge, key, labels)
+	p.value = float64(val)
+}
+
+func (s *pushSink) EmitKey(key []string, val float32) {
+	s.AddSample(key, val)
+}
+
+func (s *pushSink) IncrCounter(key []string, val float32) {
+	s.IncrCounterWithLabels(key, val, nil)
+}
+
+func (s *pushSink) IncrCounterWithLabels(key []string, val float32, labels []metrics.Label) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	p := s.point(s.counters, metricCounter, key, labels)
+	p.value += float64(val)
+	p.count++
+}
+
+func (s *pushSink) AddSample(key []string, val float32) {
+	s.AddSampleWithLabels(key, val, nil)
+}
+
+func (s *pushSink) AddSampleWithLabels(key []string, val float32, labels []metrics.Label) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	p := s.point(s.samples, metricSample, key, labels)
+	v := float64(val)
+	if p.count == 0 || v < p.min {
+		p.min = v
+	}
+	if p.count == 0 || v > p.max {
+		p.max = v
+	}
+	p.value += v
+	p.count++
+}
+
+func (s *pushSink) point(points map[string]*metricPoint, kind metricKind, key []string,
+	labels []metrics.Label) *metricPoint {
+	name := strings.Join(key, ".")
+	id := name
+	for _, l := range labels {
+		id += "\x00" + l.Name + "=" + l.Value
+	}
+	p, ok := points[id]
+	if !ok {
+		p = &metricPoint{
+			kind:   kind,
+			name:   name,
+			labels: append([]metrics.Label{}, labels...),
+			start:  s.start,
+		}
+		points[id] = p
+	}
+	return p
+}
+
+// flush writes the gauges and the counters and samples of the interval
+// ending now, which are reset
+func (s *pushSink) flush(now time.Time) error {
+	s.mutex.Lock()
+	points := make([]*metricPoint, 0, len(s.gauges)+len(s.counters)+len(s.samples))
+	for _, p := range s.gauges {
+		gauge := *p
+		points = append(points, &gauge)
+	}
+	for _, p := range s.counters {
+		points = append(points, p)
+	}
+	for _, p := range s.samples {
+		points = append(points, p)
+	}
+	s.counters = make(map[string]*metricPoint)
+	s.samples = make(map[string]*metricPoint)
+	s.start = now
+	s.mutex.Unlock()
+
+	if len(points) == 0 {
+		return nil
+	}
+	sort.Slice(points, func(i, j int) bool {
+		return points[i].name < points[j].name
+	})
+	return s.write(points, now)
+}
+
+// newInfluxDBSink writes the metrics in the InfluxDB line protocol to addr:
+// "udp://host:port", or the URL of a write endpoint the lines are posted to
+func newInfluxDBSink(addr, token string, logger *ulog.Logger) (*pushSink, error) {
+	u, err := url.Parse(addr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid influxdb_address %q: %v", addr, err)
+	}
+	var send func(lines []byte) error
+	switch u.Scheme {
+	case "udp":
+		conn, err := net.Dial("udp", u.Host)
+		if err != nil {
+			return nil, err
+		}
+		send = func(lines []byte) error {
+			// a datagram per line, for them to stay below the MTU
+			for _, line := range bytes.SplitAfter(lines, []byte("\n")) {
+				if len(line) == 0 {
+					continue
+				}
+				if _, err := conn.Write(line); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+	case "http", "https":
+		headers := map[string]string{"Content-Type": "text/plain; charset=utf-8"}
+		if token != "" {
+			headers["Authorization"] = "Token " + token
+		}
+		send = func(lines []byte) error {
+			return postMetrics(addr, headers, lines)
+		}
+	default:
+		return nil, fmt.Errorf("invalid influxdb_address %q: the scheme is not udp, http or https", addr)
+	}
+	return newPushSink("InfluxDB", logger, func(points []*metricPoint, now time.Time) error {
+		return send(influxDBLines(points, now))
+	}), nil
+}
+
+// influxDBLines formats the points in the InfluxDB line protocol, the labels
+// being the tags
+func influxDBLines(points []*metricPoint, now time.Time) []byte {
+	var b bytes.Buffer
+	escaper := strings.NewReplacer(",", `\,`, " ", `\ `, "=", `\=`)
+	for _, p := range points {
+		b.WriteString(escaper.Replace(strings.Replace(p.name, ".", "_", -1)))
+		for _, l := range p.labels {
+			if l.Value == "" {
+				continue
+			}
+			fmt.Fprintf(&b, ",%s=%s", escaper.Replace(l.Name), escaper.Replace(l.Value))
+		}
+		switch p.kind {
+		case metricGauge:
+			fmt.Fprintf(&b, " value=%s", influxDBFloat(p.value))
+		case metricCounter:
+			fmt.Fprintf(&b, " count=%di,sum=%s", p.count, influxDBFloat(p.value))
+		case metricSample:
+			fmt.Fprintf(&b, " count=%di,sum=%s,min=%s,max=%s,mean=%s", p.count, influxDBFloat(p.value),
+				influxDBFloat(p.min), influxDBFloat(p.max), influxDBFloat(p.value/float64(p.count)))
+		}
+		fmt.Fprintf(&b, " %d\n", now.UnixNano())
+	}
+	return b.Bytes()
+}
+
+func influxDBFloat(v float64) string {
+	if math.IsNaN(v) || math.IsInf(v, 0) {
+		return "0"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+// newOTLPSink exports the metrics to endpoint with OTLP over HTTP, in its
+// JSON encoding
+func newOTLPSink(endpoint string, headers map[string]string, hostName string,
+	logger *ulog.Logger) (*pushSink, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return nil, fmt.Errorf("invalid otlp_endpoint %q: not an http or https URL", endpoint)
+	}
+	h := map[string]string{"Content-Type": "application/json"}
+	for k, v := range headers {
+		h[k] = v
+	}
+	return newPushSink("OTLP", logger, func(points []*metricPoint, now time.Time) error {
+		body, err := json.Marshal(otlpRequest(points, now, hostName))
+		if err != nil {
+			return err
+		}
+		return postMetrics(endpoint, h, body)
+	}), nil
+}
+
+// otlpRequest is the ExportMetricsServiceRequest of the points: the gauges
+// as gauges, the counters as delta sums and the samples as summaries
+func otlpRequest(points []*metricPoint, now time.Time, hostName string) map[string]interface{} {
+	nanos := func(t time.Time) string {
+		return strconv.FormatInt(t.UnixNano(), 10)
+	}
+	var otlpMetrics []interface{}
+	for _, p := range points {
+		attributes := make([]interface{}, 0, len(p.labels))
+		for _, l := range p.labels {
+			attributes = append(attributes, otlpAttribute(l.Name, l.Value))
+		}
+		point := map[string]interface{}{
+			"attributes":        attributes,
+			"startTimeUnixNano": nanos(p.start),
+			"timeUnixNano":      nanos(now),
+		}
+		metric := map[string]interface{}{"name": p.name}
+		switch p.kind {
+		case metricGauge:
+			point["asDouble"] = p.value
+			metric["gauge"] = map[string]interface{}{"dataPoints": []interface{}{point}}
+		case metricCounter:
+			point["asDouble"] = p.value
+			metric["sum"] = map[string]interface{}{
+				"dataPoints": []interface{}{point},
+				// AGGREGATION_TEMPORALITY_DELTA
+				"aggregationTemporality": 1,
+				"isMonotonic":            p.value >= 0,
+			}
+		case metricSample:
+			point["count"] = strconv.FormatInt(p.count, 10)
+			point["sum"] = p.value
+			point["quantileValues"] = []interface{}{
+				map[string]interface{}{"quantile": 0.0, "value": p.min},
+				map[string]interface{}{"quantile": 1.0, "value": p.max},
+			}
+			metric["summary"] = map[string]interface{}{"dataPoints": []interface{}{point}}
+		}
+		otlpMetrics = append(otlpMetrics, metric)
+	}
+
+	resource := []interface{}{otlpAttribute("service.name", "dtle")}
+	if hostName != "" {
+		resource = append(resource, otlpAttribute("host.name", hostName))
+	}
+	return map[string]interface{}{
+		"resourceMetrics": []interface{}{
+			map[string]interface{}{
+				"resource": map[string]interface{}{"attributes": resource},
+				"scopeMetrics": []interface{}{
+					map[string]interface{}{
+						"scope":   map[string]interface{}{"name": "github.com/actiontech/dtle"},
+						"metrics": otlpMetrics,
+					},
+				},
+			},
+		},
+	}
+}
+
+func otlpAttribute(key, value string) map[string]interface{} {
+	return map[string]interface{}{
+		"key":   key,
+		"value": map[string]interface{}{"stringValue": value},
+	}
+}
+
+// postMetrics posts a body of metrics to a URL
+func postMetrics(url string, headers map[string]string, body []byte) error {
+	req, err := http.NewRequest("POST", url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	client := &http.Client{Timeout: pushSinkInterval}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		msg, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("%s: %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+	io.Copy(ioutil.Discard, resp.Body)
+	return nil
+}
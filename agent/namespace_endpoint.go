@@ -0,0 +1,122 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package agent
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/actiontech/dtle/internal/models"
+)
+
+func (s *HTTPServer) NamespacesRequest(resp http.ResponseWriter, req *http.Request) (interface{}, error) {
+	switch req.Method {
+	case "GET":
+		return s.namespaceListRequest(resp, req)
+	case "PUT", "POST":
+		return s.namespaceUpdate(resp, req, "")
+	default:
+		return nil, CodedError(405, ErrInvalidMethod)
+	}
+}
+
+func (s *HTTPServer) NamespaceSpecificRequest(resp http.ResponseWriter, req *http.Request) (interface{}, error) {
+	name := strings.TrimPrefix(req.URL.Path, "/v1/namespace/")
+	if name == "" {
+		return nil, CodedError(400, "Missing namespace name")
+	}
+	switch req.Method {
+	case "GET":
+		return s.namespaceQuery(resp, req, name)
+	case "PUT", "POST":
+		return s.namespaceUpdate(resp, req, name)
+	case "DELETE":
+		return s.namespaceDelete(resp, req, name)
+	default:
+		return nil, CodedError(405, ErrInvalidMethod)
+	}
+}
+
+func (s *HTTPServer) namespaceListRequest(resp http.ResponseWriter, req *http.Request) (interface{}, error) {
+	args := models.NamespaceListRequest{}
+	if s.parse(resp, req, &args.Region, &args.QueryOptions) {
+		return nil, nil
+	}
+
+	var out models.NamespaceListResponse
+	if err := s.agent.RPC("Namespace.List", &args, &out); err != nil {
+		return nil, err
+	}
+
+	setMeta(resp, &out.QueryMeta)
+	if out.Namespaces == nil {
+		out.Namespaces = make([]*models.Namespace, 0)
+	}
+	return out.Namespaces, nil
+}
+
+func (s *HTTPServer) namespaceQuery(resp http.ResponseWriter, req *http.Request,
+	name string) (interface{}, error) {
+	args := models.NamespaceSpecificRequest{
+		Name: name,
+	}
+	if s.parse(resp, req, &args.Region, &args.QueryOptions) {
+		return nil, nil
+	}
+
+	var out models.SingleNamespaceResponse
+	if err := s.agent.RPC("Namespace.GetNamespace", &args, &out); err != nil {
+		return nil, err
+	}
+
+	setMeta(resp, &out.QueryMeta)
+	if out.Namespace == nil {
+		return nil, CodedError(404, "namespace not found")
+	}
+	return out.Namespace, nil
+}
+
+func (s *HTTPServer) namespaceUpdate(resp http.ResponseWriter, req *http.Request,
+	name string) (interface{}, error) {
+	var ns models.Namespace
+	if err := decodeBody(req, &ns); err != nil {
+		return nil, CodedError(400, err.Error())
+	}
+	if name != "" && ns.Name != name {
+		if ns.Name != "" {
+			return nil, CodedError(400, "Namespace name does not match request path")
+		}
+		ns.Name = name
+	}
+
+	args := models.NamespaceUpsertRequest{
+		Namespaces: []*models.Namespace{&ns},
+	}
+	s.parseRegion(req, &args.Region)
+
+	var out models.GenericResponse
+	if err := s.agent.RPC("Namespace.Upsert", &args, &out); err != nil {
+		return nil, err
+	}
+	setIndex(resp, out.Index)
+	return out, nil
+}
+
+func (s *HTTPServer) namespaceDelete(resp http.ResponseWriter, req *http.Request,
+	name string) (interface{}, error) {
+	args := models.NamespaceDeleteRequest{
+		Namespaces: []string{name},
+	}
+	s.parseRegion(req, &args.Region)
+
+	var out models.GenericResponse
+	if err := s.agent.RPC("Namespace.Delete", &args, &out); err != nil {
+		return nil, err
+	}
+	setIndex(resp, out.Index)
+	return out, nil
+}
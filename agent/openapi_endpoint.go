@@ -0,0 +1,38 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package agent
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/actiontech/dtle/api"
+)
+
+// OpenAPIRequest serves the OpenAPI document of the HTTP API, its server
+// being the URL the client reached the agent at
+func (s *HTTPServer) OpenAPIRequest(resp http.ResponseWriter, req *http.Request) (interface{}, error) {
+	if req.Method != "GET" {
+		return nil, CodedError(405, ErrInvalidMethod)
+	}
+	var doc map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(api.OpenAPISpec), &doc); err != nil {
+		return nil, err
+	}
+	servers, err := json.Marshal([]map[string]string{{"url": s.frontend.externalURL(req)}})
+	if err != nil {
+		return nil, err
+	}
+	doc["servers"] = servers
+	spec, err := json.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	resp.Header().Set("Content-Type", "application/json")
+	resp.Write(spec)
+	return nil, nil
+}
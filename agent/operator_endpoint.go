@@ -8,6 +8,7 @@ package agent
 
 import (
 	"fmt"
+	"io/ioutil"
 	"net/http"
 	"strings"
 
@@ -17,12 +18,14 @@ import (
 )
 
 func (s *HTTPServer) OperatorRequest(resp http.ResponseWriter, req *http.Request) (interface{}, error) {
-	path := strings.TrimPrefix(req.URL.Path, "/v1/operator/raft/")
+	path := strings.TrimPrefix(req.URL.Path, "/v1/operator/")
 	switch {
-	case strings.HasPrefix(path, "configuration"):
+	case strings.HasPrefix(path, "raft/configuration"):
 		return s.OperatorRaftConfiguration(resp, req)
-	case strings.HasPrefix(path, "peer"):
+	case strings.HasPrefix(path, "raft/peer"):
 		return s.OperatorRaftPeer(resp, req)
+	case strings.HasPrefix(path, "snapshot"):
+		return s.OperatorSnapshot(resp, req)
 	default:
 		return nil, CodedError(404, ErrInvalidMethod)
 	}
@@ -121,3 +124,48 @@ func (s *HTTPServer) OperatorRaftPeer(resp http.ResponseWriter, req *http.Reques
 
 	return nil, nil
 }
+
+// OperatorSnapshot is used to save the server state with a GET, returning the
+// snapshot archive as the response body, or to restore it with a PUT taking
+// the archive as the request body.
+func (s *HTTPServer) OperatorSnapshot(resp http.ResponseWriter, req *http.Request) (interface{}, error) {
+	switch req.Method {
+	case "GET":
+		var args models.GenericRequest
+		if done := s.parse(resp, req, &args.Region, &args.QueryOptions); done {
+			return nil, nil
+		}
+
+		var reply models.SnapshotSaveResponse
+		if err := s.agent.RPC("Operator.SnapshotSave", &args, &reply); err != nil {
+			return nil, err
+		}
+
+		setIndex(resp, reply.Index)
+		resp.Header().Set("Content-Type", "application/octet-stream")
+		resp.Write(reply.Data)
+		return nil, nil
+
+	case "PUT", "POST":
+		var args models.SnapshotRestoreRequest
+		s.parseRegion(req, &args.Region)
+
+		data, err := ioutil.ReadAll(req.Body)
+		if err != nil {
+			return nil, CodedError(400, err.Error())
+		}
+		if len(data) == 0 {
+			return nil, CodedError(400, "Missing snapshot")
+		}
+		args.Data = data
+
+		var reply struct{}
+		if err := s.agent.RPC("Operator.SnapshotRestore", &args, &reply); err != nil {
+			return nil, err
+		}
+		return nil, nil
+
+	default:
+		return nil, CodedError(405, ErrInvalidMethod)
+	}
+}
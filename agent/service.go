@@ -0,0 +1,133 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package agent
+
+import (
+	"net"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	ulog "github.com/actiontech/dtle/internal/logger"
+)
+
+// serviceManager reports the lifecycle of the agent to the service manager
+// running it, systemd or the Windows service control manager
+type serviceManager interface {
+	// Ready reports that the agent has started
+	Ready()
+
+	// Reloading reports that the agent reloads its config, until Ready is
+	// reported again
+	Reloading()
+
+	// Stopping reports that the agent is shutting down
+	Stopping()
+
+	// Stopped reports the exit code of the agent once it has shut down
+	Stopped(code int)
+
+	// StopCh is closed when the service manager stops the agent
+	StopCh() <-chan struct{}
+}
+
+// systemdService notifies systemd of the state of the agent through the
+// socket of $NOTIFY_SOCKET, and pings its watchdog while the agent is
+// healthy if the unit has a WatchdogSec. It does nothing when the agent is
+// not run by systemd.
+type systemdService struct {
+	logger  *ulog.Logger
+	healthy func() bool
+
+	socket       string
+	watchdog     time.Duration
+	watchdogOnce sync.Once
+
+	stopOnce sync.Once
+	stopCh   chan struct{}
+}
+
+func newSystemdService(logger *ulog.Logger, healthy func() bool) *systemdService {
+	s := &systemdService{
+		logger:  logger,
+		healthy: healthy,
+		socket:  os.Getenv("NOTIFY_SOCKET"),
+		stopCh:  make(chan struct{}),
+	}
+
+	// The watchdog is meant for the main process only
+	if usec, err := strconv.ParseInt(os.Getenv("WATCHDOG_USEC"), 10, 64); err == nil && usec > 0 {
+		if pid := os.Getenv("WATCHDOG_PID"); pid == "" || pid == strconv.Itoa(os.Getpid()) {
+			s.watchdog = time.Duration(usec) * time.Microsecond
+		}
+	}
+	return s
+}
+
+func (s *systemdService) Ready() {
+	s.notify("READY=1")
+	if s.watchdog > 0 {
+		s.watchdogOnce.Do(func() { go s.runWatchdog() })
+	}
+}
+
+func (s *systemdService) Reloading() {
+	s.notify("RELOADING=1")
+}
+
+func (s *systemdService) Stopping() {
+	s.stopOnce.Do(func() { close(s.stopCh) })
+	s.notify("STOPPING=1")
+}
+
+func (s *systemdService) Stopped(code int) {}
+
+// StopCh is never closed: systemd stops the agent with SIGTERM
+func (s *systemdService) StopCh() <-chan struct{} {
+	return nil
+}
+
+// runWatchdog pings the watchdog of systemd twice per interval while the
+// agent is healthy, for systemd to restart an agent that is not
+func (s *systemdService) runWatchdog() {
+	ticker := time.NewTicker(s.watchdog / 2)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if s.healthy() {
+				s.notify("WATCHDOG=1")
+			} else {
+				s.logger.Warnf("Agent unhealthy, not pinging the systemd watchdog")
+			}
+		case <-s.stopCh:
+			return
+		}
+	}
+}
+
+// notify sends a state to systemd
+func (s *systemdService) notify(state string) {
+	if s.socket == "" {
+		return
+	}
+	addr := &net.UnixAddr{Name: s.socket, Net: "unixgram"}
+	// An abstract socket is given with a leading @
+	if addr.Name[0] == '@' {
+		addr.Name = "\x00" + addr.Name[1:]
+	}
+	conn, err := net.DialUnix("unixgram", nil, addr)
+	if err != nil {
+		s.logger.Warnf("Failed to notify systemd of %s: %v", state, err)
+		return
+	}
+	defer conn.Close()
+	if _, err := conn.Write([]byte(state)); err != nil {
+		s.logger.Warnf("Failed to notify systemd of %s: %v", state, err)
+	}
+}
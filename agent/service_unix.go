@@ -0,0 +1,19 @@
+//go:build !windows
+// +build !windows
+
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package agent
+
+import (
+	ulog "github.com/actiontech/dtle/internal/logger"
+)
+
+// newServiceManager returns the manager of the service of the agent
+func newServiceManager(logger *ulog.Logger, healthy func() bool) (serviceManager, error) {
+	return newSystemdService(logger, healthy), nil
+}
@@ -0,0 +1,123 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package agent
+
+import (
+	"sync"
+	"time"
+
+	"golang.org/x/sys/windows/svc"
+
+	ulog "github.com/actiontech/dtle/internal/logger"
+)
+
+const (
+	// windowsServiceName is the name the agent is run as a service under
+	windowsServiceName = "dtle"
+
+	// windowsServiceExitTimeout is how long the exit code of the agent
+	// waits to be reported to the service control manager
+	windowsServiceExitTimeout = 5 * time.Second
+)
+
+// newServiceManager returns the manager of the service of the agent: the
+// service control manager when the agent is run as a Windows service
+func newServiceManager(logger *ulog.Logger, healthy func() bool) (serviceManager, error) {
+	interactive, err := svc.IsAnInteractiveSession()
+	if err != nil {
+		return nil, err
+	}
+	if interactive {
+		return newSystemdService(logger, healthy), nil
+	}
+
+	s := &windowsService{
+		logger:   logger,
+		statusCh: make(chan svc.State, 4),
+		exitCh:   make(chan int, 1),
+		stopCh:   make(chan struct{}),
+		doneCh:   make(chan struct{}),
+	}
+	go func() {
+		defer close(s.doneCh)
+		if err := svc.Run(windowsServiceName, s); err != nil {
+			logger.Errorf("Failed to run as a Windows service: %v", err)
+		}
+	}()
+	return s, nil
+}
+
+// windowsService reports the state of the agent to the service control
+// manager, and stops the agent when the service is stopped
+type windowsService struct {
+	logger *ulog.Logger
+
+	statusCh chan svc.State
+	exitCh   chan int
+
+	stopOnce sync.Once
+	stopCh   chan struct{}
+	doneCh   chan struct{}
+}
+
+func (s *windowsService) Ready() {
+	s.setStatus(svc.Running)
+}
+
+// Reloading is not reported: the service keeps running
+func (s *windowsService) Reloading() {}
+
+func (s *windowsService) Stopping() {
+	s.setStatus(svc.StopPending)
+}
+
+// setStatus reports a state, unless the service failed to run
+func (s *windowsService) setStatus(state svc.State) {
+	select {
+	case s.statusCh <- state:
+	case <-s.doneCh:
+	}
+}
+
+func (s *windowsService) Stopped(code int) {
+	s.exitCh <- code
+	select {
+	case <-s.doneCh:
+	case <-time.After(windowsServiceExitTimeout):
+	}
+}
+
+func (s *windowsService) StopCh() <-chan struct{} {
+	return s.stopCh
+}
+
+// Execute handles the requests of the service control manager until the
+// agent exits
+func (s *windowsService) Execute(args []string, r <-chan svc.ChangeRequest, changes chan<- svc.Status) (bool, uint32) {
+	status := svc.Status{State: svc.StartPending}
+	changes <- status
+	for {
+		select {
+		case state := <-s.statusCh:
+			status = svc.Status{State: state}
+			if state == svc.Running {
+				status.Accepts = svc.AcceptStop | svc.AcceptShutdown
+			}
+			changes <- status
+		case req := <-r:
+			switch req.Cmd {
+			case svc.Interrogate:
+				changes <- req.CurrentStatus
+			case svc.Stop, svc.Shutdown:
+				s.logger.Printf("Windows service stop requested")
+				s.stopOnce.Do(func() { close(s.stopCh) })
+			}
+		case code := <-s.exitCh:
+			return false, uint32(code)
+		}
+	}
+}
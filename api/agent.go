@@ -8,7 +8,9 @@ package api
 
 import (
 	"fmt"
+	"io"
 	"net/url"
+	"strconv"
 )
 
 // Agent encapsulates an API client which talks to Udup's
@@ -151,6 +153,40 @@ func (a *Agent) Servers() ([]string, error) {
 	return resp, nil
 }
 
+// Metrics is used to read the metrics of the agent, in the Prometheus text
+// exposition format. The caller must close the returned reader.
+func (a *Agent) Metrics() (io.ReadCloser, error) {
+	return a.client.rawQuery("/metrics", nil)
+}
+
+// Profile is used to capture a runtime profile of the agent, such as heap,
+// goroutine or cpu, in the pprof format. The cpu profile is captured for the
+// given seconds. The caller must close the returned reader.
+func (a *Agent) Profile(name string, seconds int) (io.ReadCloser, error) {
+	return a.debugQuery("/v1/agent/debug/profile/"+name, seconds)
+}
+
+// DebugBundle is used to capture the diagnostic bundle of the agent, a
+// tar.gz of its versions, config, allocations and profiles, with a cpu
+// profile captured for the given seconds if greater than 0. The caller must
+// close the returned reader.
+func (a *Agent) DebugBundle(seconds int) (io.ReadCloser, error) {
+	return a.debugQuery("/v1/agent/debug/bundle", seconds)
+}
+
+func (a *Agent) debugQuery(endpoint string, seconds int) (io.ReadCloser, error) {
+	r, err := a.client.newRequest("GET", endpoint)
+	if err != nil {
+		return nil, err
+	}
+	r.params.Set("seconds", strconv.Itoa(seconds))
+	_, resp, err := requireOK(a.client.doRequest(r))
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
 // SetServers is used to update the list of servers on a client node.
 func (a *Agent) SetServers(addrs []string) error {
 	// Accumulate the addresses
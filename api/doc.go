@@ -0,0 +1,19 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+// Package api is the Go client of the dtle HTTP API. It wraps the endpoints
+// with typed structs, so tools do not need to build the requests and decode
+// the responses themselves:
+//
+//	client, err := api.NewClient(api.DefaultConfig())
+//	if err != nil {
+//		return err
+//	}
+//	jobs, _, err := client.Jobs().List(nil)
+//
+// The progress and the metrics of the tasks of a job are read with
+// Jobs.Stats, the metrics of an agent with Agent.Metrics.
+package api
@@ -7,10 +7,15 @@
 package api
 
 import (
+	"bufio"
+	"encoding/json"
 	"fmt"
+	"io"
 	"net/url"
 	"sort"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/actiontech/dtle/internal"
 	"github.com/actiontech/dtle/internal/models"
@@ -42,6 +47,68 @@ func (j *Jobs) Validate(job *Job, q *WriteOptions) (*JobValidateResponse, *Write
 	return &resp, wm, err
 }
 
+// EstimateTables is used to estimate the tables a source task would
+// replicate, before submitting its job
+func (j *Jobs) EstimateTables(task *Task, q *WriteOptions) (*TableEstimateResponse, *WriteMeta, error) {
+	var resp TableEstimateResponse
+	wm, err := j.client.write("/v1/estimate/tables", task, &resp, q)
+	return &resp, wm, err
+}
+
+// Cutover waits for a job to catch up with its source, optionally sets the
+// source read-only, and returns the GTID set of the source the job has
+// applied. It blocks until the job has caught up or the timeout expired.
+func (j *Jobs) Cutover(jobID string, req *JobCutoverRequest, q *WriteOptions) (*JobCutoverResponse, *WriteMeta, error) {
+	var resp JobCutoverResponse
+	wm, err := j.client.write("/v1/job/"+jobID+"/cutover", req, &resp, q)
+	if err != nil {
+		return nil, nil, err
+	}
+	return &resp, wm, nil
+}
+
+// SkipDDL declares a DDL applied manually on the target of a job, which the
+// job skips once it replicates it. It returns the checksum of the DDL.
+func (j *Jobs) SkipDDL(jobID string, query string, q *WriteOptions) (*JobSkipDDLResponse, *WriteMeta, error) {
+	var resp JobSkipDDLResponse
+	wm, err := j.client.write("/v1/job/"+jobID+"/skip-ddl", &JobSkipDDLRequest{Query: query}, &resp, q)
+	if err != nil {
+		return nil, nil, err
+	}
+	return &resp, wm, nil
+}
+
+// SkippedDDLs lists the DDLs declared for a job that it has yet to skip
+func (j *Jobs) SkippedDDLs(jobID string, q *QueryOptions) ([]*SkippedDDL, *QueryMeta, error) {
+	var resp []*SkippedDDL
+	qm, err := j.client.query("/v1/job/"+jobID+"/skip-ddl", &resp, q)
+	if err != nil {
+		return nil, nil, err
+	}
+	return resp, qm, nil
+}
+
+// DDLHistory lists the last DDLs a job replicated to its target
+func (j *Jobs) DDLHistory(jobID string, q *QueryOptions) ([]*DDLRecord, *QueryMeta, error) {
+	var resp []*DDLRecord
+	qm, err := j.client.query("/v1/job/"+jobID+"/ddl", &resp, q)
+	if err != nil {
+		return nil, nil, err
+	}
+	return resp, qm, nil
+}
+
+// SchemaHistory lists the versions of the tables a job replicated, the
+// oldest first
+func (j *Jobs) SchemaHistory(jobID string, q *QueryOptions) ([]*SchemaVersion, *QueryMeta, error) {
+	var resp []*SchemaVersion
+	qm, err := j.client.query("/v1/job/"+jobID+"/schema-history", &resp, q)
+	if err != nil {
+		return nil, nil, err
+	}
+	return resp, qm, nil
+}
+
 // Register is used to register a new job. It returns the ID
 // of the evaluation, along with any errors encountered.
 func (j *Jobs) Register(job *Job, q *WriteOptions) (string, *WriteMeta, error) {
@@ -132,6 +199,147 @@ func (j *Jobs) Evaluations(jobID string, q *QueryOptions) ([]*Evaluation, *Query
 	return resp, qm, nil
 }
 
+// Events is used to query the timeline of the given job ID, oldest
+// event first.
+func (j *Jobs) Events(jobID string, q *QueryOptions) ([]*JobEvent, *QueryMeta, error) {
+	var resp []*JobEvent
+	qm, err := j.client.query("/v1/job/"+jobID+"/events", &resp, q)
+	if err != nil {
+		return nil, nil, err
+	}
+	return resp, qm, nil
+}
+
+// EventStream is used to stream the events of the given job ID as they are
+// recorded, starting after the given index. The stream ends when cancelCh is
+// closed or an error is sent on the error channel.
+func (j *Jobs) EventStream(jobID string, index uint64, cancelCh <-chan struct{},
+	q *QueryOptions) (<-chan *JobEvent, <-chan error) {
+	eventCh := make(chan *JobEvent)
+	errCh := make(chan error, 1)
+
+	qo := QueryOptions{}
+	if q != nil {
+		qo = *q
+	}
+	qo.WaitIndex = index
+	qo.Params = map[string]string{"stream": ""}
+	if q != nil {
+		for k, v := range q.Params {
+			qo.Params[k] = v
+		}
+	}
+
+	body, err := j.client.rawQuery("/v1/job/"+jobID+"/events", &qo)
+	if err != nil {
+		errCh <- err
+		return eventCh, errCh
+	}
+
+	// Close the body to unblock the reader once cancelled
+	doneCh := make(chan struct{})
+	go func() {
+		select {
+		case <-cancelCh:
+		case <-doneCh:
+		}
+		body.Close()
+	}()
+
+	go func() {
+		defer close(doneCh)
+		scanner := bufio.NewScanner(body)
+		var eventType, data string
+		for scanner.Scan() {
+			line := scanner.Text()
+			switch {
+			case strings.HasPrefix(line, "event: "):
+				eventType = strings.TrimPrefix(line, "event: ")
+				continue
+			case strings.HasPrefix(line, "data: "):
+				data = strings.TrimPrefix(line, "data: ")
+				continue
+			case line != "":
+				// Comments and ids are not needed
+				continue
+			}
+
+			if data == "" {
+				continue
+			}
+			if eventType == "error" {
+				errCh <- fmt.Errorf("event stream failed: %s", data)
+				return
+			}
+			var event JobEvent
+			if err := json.Unmarshal([]byte(data), &event); err != nil {
+				errCh <- err
+				return
+			}
+			eventType, data = "", ""
+
+			select {
+			case eventCh <- &event:
+			case <-cancelCh:
+				return
+			}
+		}
+
+		select {
+		case <-cancelCh:
+		default:
+			if err := scanner.Err(); err != nil {
+				errCh <- err
+			} else {
+				errCh <- io.EOF
+			}
+		}
+	}()
+	return eventCh, errCh
+}
+
+// Pause is used to pause a running job.
+func (j *Jobs) Pause(jobID string, q *WriteOptions) (*WriteMeta, error) {
+	wm, err := j.client.write("/v1/job/"+jobID+"/pause", nil, nil, q)
+	if err != nil {
+		return nil, err
+	}
+	return wm, nil
+}
+
+// Resume is used to resume a paused job.
+func (j *Jobs) Resume(jobID string, q *WriteOptions) (*WriteMeta, error) {
+	wm, err := j.client.write("/v1/job/"+jobID+"/resume", nil, nil, q)
+	if err != nil {
+		return nil, err
+	}
+	return wm, nil
+}
+
+// Stats is used to query the progress and the metrics of the running
+// allocations of the given job ID, keyed by allocation ID. The stats are
+// read from the agents running the allocations.
+func (j *Jobs) Stats(jobID string, q *QueryOptions) (map[string]*AllocStatistics, error) {
+	allocs, _, err := j.Allocations(jobID, false, q)
+	if err != nil {
+		return nil, err
+	}
+
+	stats := make(map[string]*AllocStatistics)
+	for _, stub := range allocs {
+		if stub.ClientStatus != models.AllocClientStatusRunning {
+			continue
+		}
+		alloc := &Allocation{ID: stub.ID, NodeID: stub.NodeID}
+		s, err := j.client.Allocations().Stats(alloc, q)
+		if err != nil {
+			return nil, fmt.Errorf("failed to query stats of allocation %q: %v", stub.ID, err)
+		}
+		stats[stub.ID] = s
+	}
+	return stats, nil
+}
+
 // Deregister is used to remove an existing job.
 func (j *Jobs) Deregister(jobID string, q *WriteOptions) (string, *WriteMeta, error) {
 	var resp deregisterJobResponse
@@ -183,14 +391,21 @@ func (j *Jobs) Summary(jobID string, q *QueryOptions) (*Job, *QueryMeta, error)
 type Job struct {
 	Region            *string
 	ID                *string
+	Namespace         *string
 	Orders            []string
 	Name              *string
+	Labels            map[string]string
 	Failover          bool
+	Restart           *RestartPolicy
+	Reschedule        *ReschedulePolicy
+	Alert             *AlertPolicy
 	Type              *string
 	Datacenters       []string
 	Tasks             []*Task
 	Status            *string
 	StatusDescription *string
+	Health            *string
+	HealthDescription *string
 	EnforceIndex      bool
 	CreateIndex       *uint64
 	ModifyIndex       *uint64
@@ -204,6 +419,9 @@ func (j *Job) Canonicalize() {
 	if j.Name == nil {
 		j.Name = internal.StringToPtr(*j.ID)
 	}
+	if j.Namespace == nil || *j.Namespace == "" {
+		j.Namespace = internal.StringToPtr(models.DefaultNamespace)
+	}
 	if j.Region == nil {
 		j.Region = internal.StringToPtr("global")
 	}
@@ -230,14 +448,42 @@ func (j *Job) Canonicalize() {
 	}
 }
 
+// RestartPolicy defines how a failed task is restarted on its node.
+type RestartPolicy struct {
+	Attempts int
+	Interval time.Duration
+	Delay    time.Duration
+	MaxDelay time.Duration
+	Mode     string
+}
+
+// ReschedulePolicy defines when a task is moved to another node.
+type ReschedulePolicy struct {
+	Attempts   int
+	Interval   time.Duration
+	Delay      time.Duration
+	MaxDelay   time.Duration
+	OnFailure  bool
+	OnLostNode bool
+}
+
+// AlertPolicy defines the thresholds past which a job is degraded.
+type AlertPolicy struct {
+	MaxSecondsBehind   int64
+	MaxErrorsPerMinute int
+}
+
 // JobListStub is used to return a subset of information about
 // jobs during list operations.
 type JobListStub struct {
 	ID                string
+	Namespace         string
 	Name              string
+	Labels            map[string]string
 	Type              string
 	Status            string
 	StatusDescription string
+	Health            string
 	JobSummary        *Job
 	CreateIndex       uint64
 	ModifyIndex       uint64
@@ -259,6 +505,20 @@ func (j JobIDSort) Swap(a, b int) {
 	j[a], j[b] = j[b], j[a]
 }
 
+// JobEvent is an entry in the timeline of a job.
+type JobEvent struct {
+	ID          string
+	JobID       string
+	Type        string
+	Task        string
+	AllocID     string
+	NodeID      string
+	Message     string
+	Details     map[string]string
+	Time        int64
+	CreateIndex uint64
+}
+
 // AddDatacenter is used to add a datacenter to a job.
 func (j *Job) AddDatacenter(dc string) *Job {
 	j.Datacenters = append(j.Datacenters, dc)
@@ -295,6 +555,100 @@ type JobValidateResponse struct {
 	Error string
 }
 
+// TableEstimateResponse is the response from an estimate request
+type TableEstimateResponse struct {
+	Tables          []*TableEstimate
+	TotalRows       int64
+	TotalDataLength int64
+}
+
+// TableEstimate is the estimated size of a table of a source
+type TableEstimate struct {
+	TableSchema   string
+	TableName     string
+	Engine        string
+	EstimatedRows int64
+	DataLength    int64
+	IndexLength   int64
+	HasPrimaryKey bool
+	HasUniqueKey  bool
+}
+
+// JobCutoverRequest is used to cut a job over from its source to its target
+type JobCutoverRequest struct {
+	// MaxLag is the transactions the job may be behind before the source is
+	// set read-only
+	MaxLag int64
+	// ReadOnly sets the source super_read_only once the job has caught up
+	ReadOnly bool
+	// Timeout bounds each wait, in seconds
+	Timeout int
+}
+
+// JobCutoverResponse is the response from a cutover request
+type JobCutoverResponse struct {
+	CutoverGtid    string
+	TargetGtid     string
+	SourceReadOnly bool
+	LagWait        float64
+	SyncWait       float64
+}
+
+// JobSkipDDLRequest declares a DDL applied manually on the target of a job
+type JobSkipDDLRequest struct {
+	// Query is the DDL as executed on the source
+	Query string
+}
+
+// JobSkipDDLResponse is the response from a skip DDL request
+type JobSkipDDLResponse struct {
+	Checksum string
+}
+
+// SkippedDDL is a DDL declared for a job that it has yet to skip
+type SkippedDDL struct {
+	Checksum   string
+	Query      string
+	DeclaredAt string
+}
+
+// DDLRecord is a DDL a job replicated to its target, with whether it was
+// applied, skipped or failed
+type DDLRecord struct {
+	Gtid        string
+	TableSchema string
+	TableName   string
+	Query       string
+	Status      string
+	ExecMillis  int64
+	Error       string
+	Time        string
+}
+
+// SchemaVersion is a version of a table created or altered by a DDL a job
+// replicated
+type SchemaVersion struct {
+	Gtid        string
+	TableSchema string
+	TableName   string
+	Query       string
+	Columns     *SchemaColumns
+	Time        int64
+}
+
+// SchemaColumns is the columns of a version of a table
+type SchemaColumns struct {
+	Columns []*SchemaColumn
+}
+
+// SchemaColumn is a column of a version of a table
+type SchemaColumn struct {
+	Name       string
+	ColumnType string
+	Key        string
+	Nullable   bool
+}
+
 // JobUpdateRequest is used to update a job
 type JobRegisterRequest struct {
 	Job *Job
@@ -0,0 +1,92 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package api
+
+import (
+	"sort"
+)
+
+// Namespaces is used to query the namespace endpoints.
+type Namespaces struct {
+	client *Client
+}
+
+// Namespaces returns a handle on the namespace endpoints.
+func (c *Client) Namespaces() *Namespaces {
+	return &Namespaces{client: c}
+}
+
+// List is used to list all of the namespaces.
+func (n *Namespaces) List(q *QueryOptions) ([]*Namespace, *QueryMeta, error) {
+	var resp []*Namespace
+	qm, err := n.client.query("/v1/namespaces", &resp, q)
+	if err != nil {
+		return nil, qm, err
+	}
+	sort.Sort(NamespaceNameSort(resp))
+	return resp, qm, nil
+}
+
+// Info is used to query a single namespace by its name.
+func (n *Namespaces) Info(name string, q *QueryOptions) (*Namespace, *QueryMeta, error) {
+	var resp Namespace
+	qm, err := n.client.query("/v1/namespace/"+name, &resp, q)
+	if err != nil {
+		return nil, nil, err
+	}
+	return &resp, qm, nil
+}
+
+// Register is used to create or update a namespace.
+func (n *Namespaces) Register(namespace *Namespace, q *WriteOptions) (*WriteMeta, error) {
+	wm, err := n.client.write("/v1/namespace/"+namespace.Name, namespace, nil, q)
+	if err != nil {
+		return nil, err
+	}
+	return wm, nil
+}
+
+// Delete is used to delete a namespace. It fails while the namespace has
+// jobs.
+func (n *Namespaces) Delete(name string, q *WriteOptions) (*WriteMeta, error) {
+	wm, err := n.client.delete("/v1/namespace/"+name, nil, q)
+	if err != nil {
+		return nil, err
+	}
+	return wm, nil
+}
+
+// Namespace is used to share a cluster between several teams.
+type Namespace struct {
+	Name        string
+	Description string
+	Quota       *NamespaceQuota
+	CreateIndex uint64
+	ModifyIndex uint64
+}
+
+// NamespaceQuota limits the jobs of a namespace. A zero value means no
+// limit.
+type NamespaceQuota struct {
+	MaxJobs      int
+	MaxBandwidth int64
+}
+
+// NamespaceNameSort is used to sort namespaces by their name.
+type NamespaceNameSort []*Namespace
+
+func (n NamespaceNameSort) Len() int {
+	return len(n)
+}
+
+func (n NamespaceNameSort) Less(i, j int) bool {
+	return n[i].Name < n[j].Name
+}
+
+func (n NamespaceNameSort) Swap(i, j int) {
+	n[i], n[j] = n[j], n[i]
+}
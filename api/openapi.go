@@ -0,0 +1,14 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package api
+
+// The HTTP API is described by the OpenAPI document openapi.json, from
+// which clients in other languages can be generated. OpenAPISpec holds the
+// document, so that the agents serve it at /v1/openapi.json, and is
+// regenerated after openapi.json is edited.
+//
+//go:generate go run openapi_gen.go
@@ -0,0 +1,50 @@
+//go:build ignore
+// +build ignore
+
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+// openapi_gen writes openapi_spec.go, holding the OpenAPI document
+// openapi.json in the OpenAPISpec constant.
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+)
+
+func main() {
+	spec, err := ioutil.ReadFile("openapi.json")
+	if err != nil {
+		fail(err)
+	}
+	var doc map[string]interface{}
+	if err := json.Unmarshal(spec, &doc); err != nil {
+		fail(fmt.Errorf("openapi.json: %v", err))
+	}
+	if bytes.ContainsRune(spec, '`') {
+		fail(fmt.Errorf("openapi.json: backquotes can not be held in a raw string"))
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString("// Code generated by openapi_gen.go from openapi.json. DO NOT EDIT.\n\n")
+	buf.WriteString("package api\n\n")
+	buf.WriteString("// OpenAPISpec is the OpenAPI document of the HTTP API\n")
+	buf.WriteString("const OpenAPISpec = `")
+	buf.Write(spec)
+	buf.WriteString("`\n")
+	if err := ioutil.WriteFile("openapi_spec.go", buf.Bytes(), 0644); err != nil {
+		fail(err)
+	}
+}
+
+func fail(err error) {
+	fmt.Fprintln(os.Stderr, err)
+	os.Exit(1)
+}
@@ -0,0 +1,148 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package api
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/actiontech/dtle/internal/models"
+)
+
+// openAPISchemaTypes are the structs the schemas of the OpenAPI document
+// describe
+var openAPISchemaTypes = map[string]interface{}{
+	"Job":                Job{},
+	"Task":               Task{},
+	"RestartPolicy":      RestartPolicy{},
+	"ReschedulePolicy":   ReschedulePolicy{},
+	"AlertPolicy":        AlertPolicy{},
+	"JobListStub":        JobListStub{},
+	"JobEvent":           JobEvent{},
+	"JobCutoverRequest":  JobCutoverRequest{},
+	"JobCutoverResponse": JobCutoverResponse{},
+	"AllocationListStub": AllocationListStub{},
+	"TaskState":          TaskState{},
+	"TaskEvent":          TaskEvent{},
+	"Evaluation":         Evaluation{},
+	"NodeListStub":       NodeListStub{},
+	"Namespace":          Namespace{},
+	"NamespaceQuota":     NamespaceQuota{},
+	"JobResponse":        models.JobResponse{},
+}
+
+type openAPISchema struct {
+	Ref                  string `json:"$ref"`
+	Type                 string
+	Properties           map[string]*openAPISchema
+	Items                *openAPISchema
+	AdditionalProperties interface{}
+}
+
+func TestOpenAPISpec_Generated(t *testing.T) {
+	spec, err := ioutil.ReadFile("openapi.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(spec) != OpenAPISpec {
+		t.Fatal("OpenAPISpec differs from openapi.json, run go generate")
+	}
+}
+
+func TestOpenAPISpec_Schemas(t *testing.T) {
+	var doc struct {
+		Components struct {
+			Schemas map[string]*openAPISchema
+		}
+	}
+	if err := json.Unmarshal([]byte(OpenAPISpec), &doc); err != nil {
+		t.Fatal(err)
+	}
+
+	for name, v := range openAPISchemaTypes {
+		schema, ok := doc.Components.Schemas[name]
+		if !ok {
+			t.Errorf("schema %s is missing", name)
+			continue
+		}
+		typ := reflect.TypeOf(v)
+		fields := make(map[string]reflect.Type)
+		structFields(typ, fields)
+
+		var names []string
+		for field := range fields {
+			names = append(names, field)
+		}
+		for prop := range schema.Properties {
+			names = append(names, prop)
+		}
+		sort.Strings(names)
+		for _, field := range names {
+			prop, ok := schema.Properties[field]
+			fieldType, isField := fields[field]
+			switch {
+			case !ok:
+				t.Errorf("schema %s lacks the %s field of %s", name, field, typ)
+			case !isField:
+				t.Errorf("schema %s has the %s property %s does not have", name, field, typ)
+			default:
+				if want := openAPIType(fieldType); prop.Ref == "" && prop.Type != want {
+					t.Errorf("schema %s: property %s is of type %q, %s.%s is of type %q",
+						name, field, prop.Type, typ, field, want)
+				}
+			}
+		}
+	}
+	for name := range doc.Components.Schemas {
+		if _, ok := openAPISchemaTypes[name]; !ok {
+			t.Errorf("schema %s describes no struct", name)
+		}
+	}
+}
+
+// structFields collects the exported fields of a struct, those of its
+// embedded structs included
+func structFields(typ reflect.Type, fields map[string]reflect.Type) {
+	for i := 0; i < typ.NumField(); i++ {
+		f := typ.Field(i)
+		switch {
+		case f.Anonymous && f.Type.Kind() == reflect.Struct:
+			structFields(f.Type, fields)
+		case f.PkgPath == "":
+			fields[f.Name] = f.Type
+		}
+	}
+}
+
+// openAPIType returns the OpenAPI type of a field
+func openAPIType(typ reflect.Type) string {
+	if typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	if typ == reflect.TypeOf(time.Time{}) {
+		return "string"
+	}
+	switch typ.Kind() {
+	case reflect.String:
+		return "string"
+	case reflect.Bool:
+		return "boolean"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return "integer"
+	case reflect.Float32, reflect.Float64:
+		return "number"
+	case reflect.Slice, reflect.Array:
+		return "array"
+	default:
+		return "object"
+	}
+}
@@ -6,6 +6,10 @@
 
 package api
 
+import (
+	"io"
+)
+
 // Operator can be used to perform low-level operator tasks for Nomad.
 type Operator struct {
 	c *Client
@@ -111,3 +115,39 @@ func (op *Operator) RaftRemovePeerByID(id string, q *WriteOptions) error {
 	resp.Body.Close()
 	return nil
 }
+
+// SnapshotSave is used to take a snapshot of the server state, including all
+// jobs and their checkpoints. The snapshot archive is returned as a stream
+// which the caller must close.
+func (op *Operator) SnapshotSave(q *QueryOptions) (io.ReadCloser, error) {
+	r, err := op.c.newRequest("GET", "/v1/operator/snapshot")
+	if err != nil {
+		return nil, err
+	}
+	r.setQueryOptions(q)
+	_, resp, err := requireOK(op.c.doRequest(r))
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+// SnapshotRestore is used to replace the server state with a snapshot archive
+// taken by SnapshotSave. This is meant for disaster recovery into a fresh
+// server cluster; the current state of the cluster is lost.
+func (op *Operator) SnapshotRestore(in io.Reader, q *WriteOptions) error {
+	r, err := op.c.newRequest("PUT", "/v1/operator/snapshot")
+	if err != nil {
+		return err
+	}
+	r.setWriteOptions(q)
+	r.body = in
+
+	_, resp, err := requireOK(op.c.doRequest(r))
+	if err != nil {
+		return err
+	}
+
+	resp.Body.Close()
+	return nil
+}
@@ -0,0 +1,11 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+// Package pb holds the Go bindings of the dtle gRPC API defined in
+// dtle.proto, and of the driver plugin protocol defined in driver.proto.
+// The *.pb.go files are generated by protoc with `make proto`.
+package pb
+
+//go:generate protoc --go_out=plugins=grpc:. dtle.proto driver.proto
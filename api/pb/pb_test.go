@@ -0,0 +1,57 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package pb
+
+import (
+	"testing"
+
+	proto "github.com/golang/protobuf/proto"
+)
+
+func TestMessages_RoundTrip(t *testing.T) {
+	msgs := []proto.Message{
+		&SubmitJobRequest{
+			Job: &Job{
+				Id:          "job1",
+				Name:        "job1",
+				Type:        "synchronous",
+				Datacenters: []string{"dc1", "dc2"},
+				Failover:    true,
+				Tasks: []*Task{
+					{Type: "Src", Driver: "MySQL", NodeId: "n1", ConfigJson: `{"Gtid":""}`},
+					{Type: "Dest", Driver: "MySQL", NodeName: "node2"},
+				},
+				JobModifyIndex: 7,
+			},
+			EnforceIndex:   true,
+			JobModifyIndex: 7,
+		},
+		&JobStatus{
+			Job: &Job{Id: "job1", Status: "running"},
+			Allocations: []*Allocation{
+				{Id: "a1", Task: "Src", ClientStatus: "running", CreateIndex: 3, ModifyIndex: 4},
+			},
+			Index: 4,
+		},
+		&StartRequest{Id: "a1", Type: "Src", ConfigJson: "{}", Subject: "job1_full", MaxPayload: 1 << 20},
+		&StatsResponse{Gtid: "uuid:1-10", ExecMasterRowCount: 100, ExecMasterTxCount: -1},
+		&WaitResponse{},
+	}
+	for _, m := range msgs {
+		data, err := proto.Marshal(m)
+		if err != nil {
+			t.Fatalf("%T: marshal: %v", m, err)
+		}
+		got := proto.Clone(m)
+		got.Reset()
+		if err := proto.Unmarshal(data, got); err != nil {
+			t.Fatalf("%T: unmarshal: %v", m, err)
+		}
+		if !proto.Equal(m, got) {
+			t.Errorf("%T: got %v, want %v", m, got, m)
+		}
+	}
+}
@@ -43,6 +43,14 @@ type QueryOptions struct {
 	// If set, used as prefix for resource list searches
 	Prefix string
 
+	// If set, only the resources of the namespace are listed
+	Namespace string
+
+	// PerPage is the most resources a list returns. The next page is
+	// listed with the NextToken of the QueryMeta of the previous one.
+	PerPage   int
+	NextToken string
+
 	// Token is used to provide a per-request ACL token
 	// which overrides the agent's default token.
 	Token string
@@ -73,6 +81,10 @@ type QueryMeta struct {
 
 	// How long did the request take
 	RequestTime time.Duration
+
+	// NextToken is the token of the next page of a paginated list, empty
+	// on the last page
+	NextToken string
 }
 
 // WriteMeta is used to return meta data about a write
@@ -219,6 +231,15 @@ func (r *request) setQueryOptions(q *QueryOptions) {
 	if q.Prefix != "" {
 		r.params.Set("prefix", q.Prefix)
 	}
+	if q.Namespace != "" {
+		r.params.Set("namespace", q.Namespace)
+	}
+	if q.PerPage != 0 {
+		r.params.Set("per_page", strconv.Itoa(q.PerPage))
+	}
+	if q.NextToken != "" {
+		r.params.Set("next_token", q.NextToken)
+	}
 	if q.Token != "" {
 		r.params.Set("X-Udup-Token", q.Token)
 	}
@@ -293,7 +314,8 @@ func (c *Client) newRequest(method, path string) (*request, error) {
 			Scheme: base.Scheme,
 			User:   base.User,
 			Host:   base.Host,
-			Path:   u.Path,
+			// Keep the path of an agent behind a proxy, such as /dtle
+			Path: strings.TrimSuffix(base.Path, "/") + u.Path,
 		},
 		params: make(map[string][]string),
 	}
@@ -486,6 +508,8 @@ func parseQueryMeta(resp *http.Response, q *QueryMeta) error {
 	default:
 		q.KnownLeader = false
 	}
+
+	q.NextToken = header.Get("X-Udup-NextToken")
 	return nil
 }
 
@@ -76,15 +76,195 @@ type ThroughputStat struct {
 	Time uint64
 }
 
-type Stats struct {
-	TableStats     *TableStats
-	DelayCount     *DelayCount
-	ThroughputStat *ThroughputStat
+// HeartbeatStat describes the last heartbeat of the extractor a task sent or
+// received.
+type HeartbeatStat struct {
+	Time int64
+	Age  int64
+	Lag  int64
+	Gtid string
 }
 
+// LatencyStat is the end-to-end latency of the transactions applied by a
+// task, and the scale of its batching.
+type LatencyStat struct {
+	TargetMs int64
+	P99Ms    int64
+	Scale    float64
+}
+
+// TableApplyStat counts the rows applied to a table in the incremental copy.
+type TableApplyStat struct {
+	TableSchema      string
+	TableName        string
+	InsertCount      int64
+	UpdateCount      int64
+	DeleteCount      int64
+	Bytes            int64
+	TotalApplyMillis int64
+	AvgApplyMicros   int64
+}
+
+// CurrentCoordinates is the replication position of a task.
+type CurrentCoordinates struct {
+	File     string
+	Position int64
+	GtidSet  string
+
+	RelayMasterLogFile string
+	ReadMasterLogPos   int64
+	RetrievedGtidSet   string
+	ExecutedGtidSet    string
+}
+
+// MsgStat holds the counters of the messages exchanged by a task.
+type MsgStat struct {
+	InMsgs     uint64
+	OutMsgs    uint64
+	InBytes    uint64
+	OutBytes   uint64
+	Reconnects uint64
+}
+
+// BufferStat holds the queue sizes of a task.
+type BufferStat struct {
+	ExtractorTxQueueSize    int
+	ApplierTxQueueSize      int
+	ApplierGroupTxQueueSize int
+	SendByTimeout           int
+	SendBySizeFull          int
+	GroupSize               int
+	// Queues are the depths and capacities of the queues of the task, in
+	// the order the entries go through them
+	Queues []QueueStat
+}
+
+// QueueStat is the depth of a queue of a task
+type QueueStat struct {
+	Name     string
+	Depth    int
+	Capacity int
+}
+
+// SchemaMapping is a change made to the definition of a table the applier
+// created, as of the CreateTable* parameters. ColumnName is empty for the
+// table options and the partitioning, Target empty for a stripped clause.
+type SchemaMapping struct {
+	TableSchema string
+	TableName   string
+	ColumnName  string
+	Source      string
+	Target      string
+}
+
+// DDLRewrite is a DDL of the source the applier adapted to the version of
+// the target, by the rules named. In dry run, Query was applied as is.
+type DDLRewrite struct {
+	Rules     []string
+	Query     string
+	Rewritten string
+	DryRun    bool
+}
+
+// SlowApply is a statement the applier took longer than the
+// SlowApplyThreshold to apply, with the transaction it is from.
+type SlowApply struct {
+	Time        int64
+	Gtid        string
+	TableSchema string
+	TableName   string
+	Query       string
+	ApplyMillis int64
+}
+
+// IndexAdvisory suggests an index on a table of the target whose updates
+// and deletes are applied by full scans.
+type IndexAdvisory struct {
+	TableSchema   string
+	TableName     string
+	ScanCount     int64
+	AvgScanMillis int64
+	Message       string
+}
+
+// TopicMapping is the topic of a table whose name is not valid as is in a
+// topic name
+type TopicMapping struct {
+	TableSchema string
+	TableName   string
+	Topic       string
+}
+
+// TableConsistency scores the consistency of a table by the ranges of its
+// rows sampled on the source and the target
+type TableConsistency struct {
+	TableSchema    string
+	TableName      string
+	Samples        int64
+	Mismatches     int64
+	Score          float64
+	LastMismatch   string
+	Error          string
+	LastSampleTime int64
+}
+
+// RowCountReconciliation compares the rows of each table read by the
+// snapshot of a job to the rows loaded on the target or emitted to it.
+type RowCountReconciliation struct {
+	Gtid       string
+	Tables     []*TableRowCount
+	Mismatches int
+	Message    string
+	Time       int64
+}
+
+// TableRowCount is the rows of a table read by a snapshot and those loaded.
+type TableRowCount struct {
+	TableSchema string
+	TableName   string
+	SourceRows  int64
+	TargetRows  int64
+	Error       string
+}
+
+// NetworkStat counts the bytes a task exchanged with MySQL, and in total
+// along with the messages with the other task of the job, as in MsgStat
+type NetworkStat struct {
+	BytesIn       int64
+	BytesOut      int64
+	MySQLBytesIn  int64
+	MySQLBytesOut int64
+}
+
+// TaskStatistics is the progress and the metrics of a task.
 type TaskStatistics struct {
-	Stats     *Stats
-	Timestamp int64
+	CurrentCoordinates *CurrentCoordinates
+	TableStats         *TableStats
+	DelayCount         *DelayCount
+	Heartbeat          *HeartbeatStat
+	Latency            *LatencyStat
+	TableApplyStats    []*TableApplyStat
+	SchemaMappings     []*SchemaMapping
+	DDLRewrites        []*DDLRewrite
+	SlowApplies        []*SlowApply
+	IndexAdvisories    []*IndexAdvisory
+	Reconciliation     *RowCountReconciliation
+	Consistency        []*TableConsistency
+	TopicMappings      []*TopicMapping
+	ProgressPct        string
+	ExecMasterRowCount int64
+	ExecMasterTxCount  int64
+	ReadMasterRowCount int64
+	ReadMasterTxCount  int64
+	ETA                string
+	Backlog            string
+	ThroughputStat     *ThroughputStat
+	MsgStat            MsgStat
+	BufferStat         BufferStat
+	NetworkStat        *NetworkStat
+	Stage              string
+	ApplyMode          string
+	Timestamp          int64
 }
 
 type AllocStatistics struct {
@@ -104,6 +284,8 @@ type TaskEvent struct {
 	ExitCode         int
 	Signal           int
 	Message          string
+	ErrorClass       string
+	CrashReport      string
 	KillReason       string
 	KillTimeout      time.Duration
 	KillError        string
@@ -0,0 +1,34 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package command
+
+import (
+	"strings"
+
+	"github.com/mitchellh/cli"
+)
+
+type BinlogCommand struct {
+	Meta
+}
+
+func (c *BinlogCommand) Help() string {
+	helpText := `
+Usage: dtle binlog <subcommand> [options]
+
+  Reads the binlog of the source of a job specification.
+`
+	return strings.TrimSpace(helpText)
+}
+
+func (c *BinlogCommand) Synopsis() string {
+	return "Read the binlog of the source of a job"
+}
+
+func (c *BinlogCommand) Run(args []string) int {
+	return cli.RunResultHelp
+}
@@ -0,0 +1,173 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package command
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/mitchellh/mapstructure"
+
+	"github.com/actiontech/dtle/internal/client/driver/mysql"
+	"github.com/actiontech/dtle/internal/config"
+	"github.com/actiontech/dtle/internal/g"
+	ulog "github.com/actiontech/dtle/internal/logger"
+	"github.com/actiontech/dtle/internal/models"
+)
+
+// binlogExportTimeLayout is the layout of the times of "dtle binlog export"
+const binlogExportTimeLayout = "2006-01-02 15:04:05"
+
+// BinlogExportCommand writes the SQL of the transactions of the binlog of
+// the source of a job, or that reverting them.
+type BinlogExportCommand struct {
+	Meta
+}
+
+func (c *BinlogExportCommand) Help() string {
+	helpText := `
+Usage: dtle binlog export [options] -job <path>
+
+  Reads the binlog of the MySQL source of the job specification at <path>,
+  or read from stdin if <path> is "-", and writes the SQL of the
+  transactions passing the filters of the job: the databases and tables it
+  replicates, their 'where' and its SqlFilter. The transactions exported are
+  those after -start-gtid up to -stop-gtid, committed between
+  -start-datetime and -stop-datetime.
+
+  With -flashback, the statements reverting the transactions are written
+  instead, the last transaction first, for a mistaken change to be undone.
+  The DDLs are not reverted. The rows are decoded with the columns the
+  tables have now, and the source needs binlog_row_image=FULL.
+
+  Nothing is written to the source, the SQL is to be reviewed and applied
+  by hand.
+
+Export Options:
+
+  -job
+    The path of the job specification. Required.
+
+  -start-gtid
+    The GTID set executed before the first transaction exported. Defaults
+    to the GTID set purged from the source, the oldest binlog kept being
+    read.
+
+  -stop-gtid
+    The GTID set executed once the last transaction is exported. Defaults
+    to the GTID set executed by the source when the export starts.
+
+  -start-datetime, -stop-datetime
+    The transactions committed before -start-datetime or after
+    -stop-datetime are not exported, given as "2006-01-02 15:04:05" in the
+    local time zone.
+
+  -flashback
+    Write the statements reverting the transactions.
+
+  -output
+    The file the SQL is written to. Defaults to stdout.
+
+  -dtle-schema
+    The schema of the tables of dtle, whose changes are not exported.
+    Defaults to "dtle".
+
+  -log-level
+    The level of the logs. Defaults to "WARN".
+`
+	return strings.TrimSpace(helpText)
+}
+
+func (c *BinlogExportCommand) Synopsis() string {
+	return "Export the SQL of the binlog of the source of a job"
+}
+
+func (c *BinlogExportCommand) Run(args []string) int {
+	var jobPath, startDatetime, stopDatetime, output, dtleSchema, logLevel string
+	opts := &mysql.BinlogExportOptions{}
+
+	flags := c.Meta.FlagSet("binlog export", FlagSetNone)
+	flags.Usage = func() { c.Ui.Output(c.Help()) }
+	flags.StringVar(&jobPath, "job", "", "")
+	flags.StringVar(&opts.StartGtid, "start-gtid", "", "")
+	flags.StringVar(&opts.StopGtid, "stop-gtid", "", "")
+	flags.StringVar(&startDatetime, "start-datetime", "", "")
+	flags.StringVar(&stopDatetime, "stop-datetime", "", "")
+	flags.BoolVar(&opts.Flashback, "flashback", false, "")
+	flags.StringVar(&output, "output", "", "")
+	flags.StringVar(&dtleSchema, "dtle-schema", "dtle", "")
+	flags.StringVar(&logLevel, "log-level", "WARN", "")
+
+	if err := flags.Parse(args); err != nil {
+		return 1
+	}
+	if jobPath == "" || len(flags.Args()) != 0 {
+		c.Ui.Error(c.Help())
+		return 1
+	}
+
+	var err error
+	if startDatetime != "" {
+		if opts.StartTime, err = time.ParseInLocation(binlogExportTimeLayout, startDatetime, time.Local); err != nil {
+			c.Ui.Error(fmt.Sprintf("Invalid -start-datetime: %s", err))
+			return 1
+		}
+	}
+	if stopDatetime != "" {
+		if opts.StopTime, err = time.ParseInLocation(binlogExportTimeLayout, stopDatetime, time.Local); err != nil {
+			c.Ui.Error(fmt.Sprintf("Invalid -stop-datetime: %s", err))
+			return 1
+		}
+	}
+
+	job, err := ReadJobFile(jobPath)
+	if err != nil {
+		c.Ui.Error(fmt.Sprintf("Error reading job file: %s", err))
+		return 1
+	}
+	var driverConfig *config.MySQLDriverConfig
+	for _, task := range job.Tasks {
+		if task.Type != models.TaskTypeSrc {
+			continue
+		}
+		if task.Driver != "" && task.Driver != models.TaskDriverMySQL {
+			c.Ui.Error(fmt.Sprintf("The binlog of a %s source can not be exported", task.Driver))
+			return 1
+		}
+		driverConfig = &config.MySQLDriverConfig{}
+		if err := mapstructure.WeakDecode(task.Config, driverConfig); err != nil {
+			c.Ui.Error(fmt.Sprintf("Error decoding the source config: %s", err))
+			return 1
+		}
+	}
+	if driverConfig == nil {
+		c.Ui.Error("The job has no source")
+		return 1
+	}
+
+	var w io.Writer = os.Stdout
+	if output != "" {
+		f, err := os.Create(output)
+		if err != nil {
+			c.Ui.Error(fmt.Sprintf("Error creating the output file: %s", err))
+			return 1
+		}
+		defer f.Close()
+		w = f
+	}
+
+	g.DtleSchemaName = dtleSchema
+	logger := ulog.New(os.Stderr, ulog.ParseLevel(logLevel))
+	if err := mysql.ExportBinlog(driverConfig, opts, w, logger); err != nil {
+		c.Ui.Error(fmt.Sprintf("Error exporting the binlog: %s", err))
+		return 1
+	}
+	return 0
+}
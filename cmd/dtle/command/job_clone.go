@@ -0,0 +1,246 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package command
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+
+	gomysql "github.com/siddontang/go-mysql/mysql"
+
+	"github.com/actiontech/dtle/api"
+	"github.com/actiontech/dtle/internal/models"
+)
+
+// JobCloneCommand creates a job from the spec of another one, with another
+// target or starting position.
+type JobCloneCommand struct {
+	Meta
+}
+
+func (c *JobCloneCommand) Help() string {
+	helpText := `
+Usage: dtle job clone [options] <job>
+
+  Creates a job from the spec of an existing job, such as a staging copy of
+  a production job, replicating the same source with the same tables and
+  options. The options below override the target and the starting position
+  of the new job, the rest of the spec being that of the job.
+
+  Without -start-gtid the new job starts as the spec of the job does: with
+  a full copy of the source if the spec has no GTID set.
+
+General Options:
+
+  ` + generalOptionsUsage() + `
+
+Clone Options:
+
+  -name
+    The name of the new job. Defaults to "<job name>-clone".
+
+  -start-gtid
+    The GTID set of the MySQL source the new job replicates from, skipping
+    the full copy: the transactions of the set are taken to be applied on
+    the target of the new job already.
+
+  -dest
+    The host:port of the MySQL target of the new job.
+
+  -dest-user, -dest-password
+    The user and password of the MySQL target of the new job.
+
+  -dest-topic, -dest-brokers
+    The topic and the comma separated brokers of the Kafka target of the
+    new job.
+
+  -dest-node
+    The node the target task of the new job runs on.
+`
+	return strings.TrimSpace(helpText)
+}
+
+func (c *JobCloneCommand) Synopsis() string {
+	return "Create a job from the spec of another one"
+}
+
+// cloneOptions are the overrides of the spec of a cloned job
+type cloneOptions struct {
+	name         string
+	startGtid    string
+	dest         string
+	destUser     string
+	destPassword string
+	destTopic    string
+	destBrokers  string
+	destNode     string
+}
+
+func (c *JobCloneCommand) Run(args []string) int {
+	var opts cloneOptions
+
+	flags := c.Meta.FlagSet("job clone", FlagSetClient)
+	flags.Usage = func() { c.Ui.Output(c.Help()) }
+	flags.StringVar(&opts.name, "name", "", "")
+	flags.StringVar(&opts.startGtid, "start-gtid", "", "")
+	flags.StringVar(&opts.dest, "dest", "", "")
+	flags.StringVar(&opts.destUser, "dest-user", "", "")
+	flags.StringVar(&opts.destPassword, "dest-password", "", "")
+	flags.StringVar(&opts.destTopic, "dest-topic", "", "")
+	flags.StringVar(&opts.destBrokers, "dest-brokers", "", "")
+	flags.StringVar(&opts.destNode, "dest-node", "", "")
+
+	if err := flags.Parse(args); err != nil {
+		return 1
+	}
+
+	// Check that we got exactly one job
+	args = flags.Args()
+	if len(args) != 1 {
+		c.Ui.Error(c.Help())
+		return 1
+	}
+	jobID := args[0]
+
+	// Get the HTTP client
+	client, err := c.Meta.Client()
+	if err != nil {
+		c.Ui.Error(fmt.Sprintf("Error initializing client: %s", err))
+		return 1
+	}
+
+	job, _, err := client.Jobs().Info(jobID, nil)
+	if err != nil {
+		c.Ui.Error(fmt.Sprintf("Error querying job: %s", err))
+		return 1
+	}
+	if opts.name == "" && job.Name != nil {
+		opts.name = *job.Name + "-clone"
+	}
+	clone, err := cloneJob(job, &opts)
+	if err != nil {
+		c.Ui.Error(fmt.Sprintf("Error cloning job: %s", err))
+		return 1
+	}
+
+	evalID, _, err := client.Jobs().Register(clone, nil)
+	if err != nil {
+		c.Ui.Error(fmt.Sprintf("Error submitting the new job: %s", err))
+		return 1
+	}
+	c.Ui.Output(fmt.Sprintf("Job %q submitted as a clone of %q", *clone.Name, jobID))
+	c.Ui.Output("Job ID: " + *clone.ID)
+	c.Ui.Output("Evaluation ID: " + evalID)
+	return 0
+}
+
+// cloneJob returns a new job of the spec of a job, with the overrides of
+// opts. The status and the indexes of the job are left out.
+func cloneJob(job *api.Job, opts *cloneOptions) (*api.Job, error) {
+	var src, dest *api.Task
+	var tasks []*api.Task
+	for _, t := range job.Tasks {
+		clone := &api.Task{
+			Type:     t.Type,
+			NodeID:   t.NodeID,
+			NodeName: t.NodeName,
+			Driver:   t.Driver,
+			Config:   copyTaskConfig(t.Config),
+		}
+		switch t.Type {
+		case models.TaskTypeSrc:
+			src = clone
+		case models.TaskTypeDest:
+			dest = clone
+		}
+		tasks = append(tasks, clone)
+	}
+	if src == nil || dest == nil {
+		return nil, fmt.Errorf("the job has no source or target")
+	}
+
+	if opts.startGtid != "" {
+		if src.Driver != "" && src.Driver != models.TaskDriverMySQL {
+			return nil, fmt.Errorf("-start-gtid needs a MySQL source, not %s", src.Driver)
+		}
+		if _, err := gomysql.ParseMysqlGTIDSet(opts.startGtid); err != nil {
+			return nil, fmt.Errorf("invalid -start-gtid %q: %v", opts.startGtid, err)
+		}
+		src.Config["Gtid"] = opts.startGtid
+		delete(src.Config, "GtidStart")
+		delete(src.Config, "AutoGtid")
+		// the versions of the tables kept are those of the old Gtid
+		delete(src.Config, "SchemaHistory")
+		delete(dest.Config, "SchemaHistory")
+	}
+
+	if opts.dest != "" || opts.destUser != "" || opts.destPassword != "" {
+		if dest.Driver != "" && dest.Driver != models.TaskDriverMySQL {
+			return nil, fmt.Errorf("-dest, -dest-user and -dest-password need a MySQL target, not %s", dest.Driver)
+		}
+		conn := map[string]interface{}{}
+		if m, ok := dest.Config["ConnectionConfig"].(map[string]interface{}); ok {
+			conn = copyTaskConfig(m)
+		}
+		if opts.dest != "" {
+			host, port, err := net.SplitHostPort(opts.dest)
+			if err != nil {
+				return nil, fmt.Errorf("invalid -dest %q: %v", opts.dest, err)
+			}
+			n, err := strconv.Atoi(port)
+			if err != nil {
+				return nil, fmt.Errorf("invalid port in -dest %q", opts.dest)
+			}
+			conn["Host"] = host
+			conn["Port"] = n
+		}
+		if opts.destUser != "" {
+			conn["User"] = opts.destUser
+		}
+		if opts.destPassword != "" {
+			conn["Password"] = opts.destPassword
+		}
+		dest.Config["ConnectionConfig"] = conn
+	}
+
+	if opts.destTopic != "" || opts.destBrokers != "" {
+		if dest.Driver != models.TaskDriverKafka {
+			return nil, fmt.Errorf("-dest-topic and -dest-brokers need a Kafka target")
+		}
+		if opts.destTopic != "" {
+			dest.Config["Topic"] = opts.destTopic
+		}
+		if opts.destBrokers != "" {
+			dest.Config["Brokers"] = strings.Split(opts.destBrokers, ",")
+		}
+	}
+
+	if opts.destNode != "" {
+		dest.NodeName = opts.destNode
+		dest.NodeID = ""
+	}
+
+	id := models.GenerateUUID()
+	name := opts.name
+	return &api.Job{
+		ID:          &id,
+		Name:        &name,
+		Labels:      job.Labels,
+		Namespace:   job.Namespace,
+		Region:      job.Region,
+		Orders:      job.Orders,
+		Failover:    job.Failover,
+		Restart:     job.Restart,
+		Reschedule:  job.Reschedule,
+		Alert:       job.Alert,
+		Type:        job.Type,
+		Datacenters: job.Datacenters,
+		Tasks:       tasks,
+	}, nil
+}
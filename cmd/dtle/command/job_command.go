@@ -0,0 +1,34 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package command
+
+import (
+	"strings"
+
+	"github.com/mitchellh/cli"
+)
+
+type JobCommand struct {
+	Meta
+}
+
+func (c *JobCommand) Help() string {
+	helpText := `
+Usage: dtle job <subcommand> [options]
+
+  Helps writing and managing job specifications.
+`
+	return strings.TrimSpace(helpText)
+}
+
+func (c *JobCommand) Synopsis() string {
+	return "Write and manage job specifications"
+}
+
+func (c *JobCommand) Run(args []string) int {
+	return cli.RunResultHelp
+}
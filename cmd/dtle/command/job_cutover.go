@@ -0,0 +1,124 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package command
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/actiontech/dtle/api"
+)
+
+// JobCutoverCommand waits for a job to catch up with its source before the
+// applications are switched over to its target.
+type JobCutoverCommand struct {
+	Meta
+}
+
+func (c *JobCutoverCommand) Help() string {
+	helpText := `
+Usage: dtle job cutover [options] <job>
+
+  Cuts a running MySQL job over from its source to its target. The command
+  waits until the job is at most -max-lag transactions behind its source,
+  sets the source read-only if -read-only is given, then waits until the
+  job has applied every transaction executed on the source and prints
+  their GTID set, the cutover GTID.
+
+  Without -read-only the source is still written to, and the cutover GTID
+  is only that of the source when the job caught up. If a wait times out
+  after the source was set read-only, the source is left read-only.
+
+General Options:
+
+  ` + generalOptionsUsage() + `
+
+Cutover Options:
+
+  -max-lag
+    The transactions the job may be behind before the source is set
+    read-only. Defaults to 100.
+
+  -read-only
+    Set the source super_read_only, or read_only before MySQL 5.7.8, once
+    the job has caught up.
+
+  -timeout
+    The longest each wait lasts. Defaults to 10m.
+`
+	return strings.TrimSpace(helpText)
+}
+
+func (c *JobCutoverCommand) Synopsis() string {
+	return "Wait for a job to catch up and cut it over"
+}
+
+func (c *JobCutoverCommand) Run(args []string) int {
+	var maxLag int64
+	var readOnly bool
+	var timeout time.Duration
+
+	flags := c.Meta.FlagSet("job cutover", FlagSetClient)
+	flags.Usage = func() { c.Ui.Output(c.Help()) }
+	flags.Int64Var(&maxLag, "max-lag", 100, "")
+	flags.BoolVar(&readOnly, "read-only", false, "")
+	flags.DurationVar(&timeout, "timeout", 10*time.Minute, "")
+
+	if err := flags.Parse(args); err != nil {
+		return 1
+	}
+
+	// Check that we got exactly one job
+	args = flags.Args()
+	if len(args) != 1 {
+		c.Ui.Error(c.Help())
+		return 1
+	}
+	jobID := args[0]
+	if maxLag < 0 {
+		c.Ui.Error("Error: -max-lag must not be negative")
+		return 1
+	}
+	if timeout < time.Second {
+		c.Ui.Error("Error: -timeout must be at least 1s")
+		return 1
+	}
+
+	// Get the HTTP client
+	client, err := c.Meta.Client()
+	if err != nil {
+		c.Ui.Error(fmt.Sprintf("Error initializing client: %s", err))
+		return 1
+	}
+
+	req := &api.JobCutoverRequest{
+		MaxLag:   maxLag,
+		ReadOnly: readOnly,
+		Timeout:  int(timeout / time.Second),
+	}
+	c.Ui.Output(fmt.Sprintf("Waiting for job %q to catch up with its source...", jobID))
+	resp, _, err := client.Jobs().Cutover(jobID, req, nil)
+	if err != nil {
+		c.Ui.Error(fmt.Sprintf("Error cutting over job: %s", err))
+		return 1
+	}
+
+	out := []string{
+		fmt.Sprintf("Cutover GTID|%s", resp.CutoverGtid),
+		fmt.Sprintf("Source Read-Only|%v", resp.SourceReadOnly),
+		fmt.Sprintf("Lag Wait|%v", secondsDuration(resp.LagWait)),
+		fmt.Sprintf("Sync Wait|%v", secondsDuration(resp.SyncWait)),
+	}
+	c.Ui.Output(formatKV(out))
+	return 0
+}
+
+// secondsDuration rounds a duration in seconds to the millisecond
+func secondsDuration(seconds float64) time.Duration {
+	return (time.Duration(seconds*float64(time.Second)) / time.Millisecond) * time.Millisecond
+}
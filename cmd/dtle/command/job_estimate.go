@@ -0,0 +1,128 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package command
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/actiontech/dtle/api"
+	"github.com/actiontech/dtle/internal/models"
+)
+
+// JobEstimateCommand shows the estimated size of the tables the source of
+// a job spec replicates.
+type JobEstimateCommand struct {
+	Meta
+}
+
+func (c *JobEstimateCommand) Help() string {
+	helpText := `
+Usage: dtle job estimate [options] <path>
+
+  Connects to the source of the job specification at <path>, or read from
+  stdin if <path> is "-", and lists the tables it replicates with their
+  estimated rows, size and engine. Tables without a primary key or a
+  non-nullable unique key are flagged: their full copy is slower.
+
+  The rows and sizes are the statistics of the source, not counts, and are
+  approximations for InnoDB tables.
+
+General Options:
+
+  ` + generalOptionsUsage()
+	return strings.TrimSpace(helpText)
+}
+
+func (c *JobEstimateCommand) Synopsis() string {
+	return "Estimate the tables the source of a job spec replicates"
+}
+
+func (c *JobEstimateCommand) Run(args []string) int {
+	flags := c.Meta.FlagSet("job estimate", FlagSetClient)
+	flags.Usage = func() { c.Ui.Output(c.Help()) }
+
+	if err := flags.Parse(args); err != nil {
+		return 1
+	}
+
+	// Check that we got exactly one job file
+	args = flags.Args()
+	if len(args) != 1 {
+		c.Ui.Error(c.Help())
+		return 1
+	}
+
+	job, err := ReadJobFile(args[0])
+	if err != nil {
+		c.Ui.Error(fmt.Sprintf("Error reading job file: %s", err))
+		return 1
+	}
+
+	var src *api.Task
+	for _, t := range job.Tasks {
+		if t.Type == models.TaskTypeSrc {
+			src = t
+		}
+	}
+	if src == nil {
+		c.Ui.Error("Error: the job has no Src task")
+		return 1
+	}
+
+	// Get the HTTP client
+	client, err := c.Meta.Client()
+	if err != nil {
+		c.Ui.Error(fmt.Sprintf("Error initializing client: %s", err))
+		return 1
+	}
+
+	// Force the region to be that of the job.
+	if r := job.Region; r != nil {
+		client.SetRegion(*r)
+	}
+
+	resp, _, err := client.Jobs().EstimateTables(src, nil)
+	if err != nil {
+		c.Ui.Error(fmt.Sprintf("Error estimating the tables: %s", err))
+		return 1
+	}
+
+	c.Ui.Output(formatTableEstimates(resp))
+	return 0
+}
+
+func formatTableEstimates(resp *api.TableEstimateResponse) string {
+	out := []string{"Table|Engine|Rows|Data|Index|Key"}
+	for _, t := range resp.Tables {
+		key := "none"
+		if t.HasPrimaryKey {
+			key = "primary"
+		} else if t.HasUniqueKey {
+			key = "unique"
+		}
+		out = append(out, fmt.Sprintf("%s.%s|%s|%d|%s|%s|%s",
+			t.TableSchema, t.TableName, t.Engine, t.EstimatedRows,
+			formatBytes(t.DataLength), formatBytes(t.IndexLength), key))
+	}
+	return fmt.Sprintf("%s\n\nTotal: %d tables, %d rows, %s",
+		formatList(out), len(resp.Tables), resp.TotalRows, formatBytes(resp.TotalDataLength))
+}
+
+// formatBytes renders a size in bytes in the largest binary unit it has
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
@@ -0,0 +1,285 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package command
+
+import (
+	gosql "database/sql"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/mitchellh/mapstructure"
+
+	"github.com/actiontech/dtle/api"
+	"github.com/actiontech/dtle/internal/client/driver/mysql/sql"
+	"github.com/actiontech/dtle/internal/config"
+	"github.com/actiontech/dtle/internal/models"
+)
+
+// jobBundleVersion is the version of the bundles written by job export
+const jobBundleVersion = 1
+
+// jobBundle is a job along with the state needed to continue it on another
+// cluster
+type jobBundle struct {
+	Version    int
+	ExportTime time.Time
+
+	// Job is the job as registered on the exporting cluster
+	Job *api.Job
+
+	// Checkpoint is the GTID set the job had replicated up to. It is empty
+	// if the job had not finished its full copy.
+	Checkpoint string
+
+	// Schemas are the definitions of the replicated tables at export time
+	Schemas []*jobBundleTable `json:",omitempty"`
+}
+
+type jobBundleTable struct {
+	TableSchema string
+	TableName   string
+	CreateTable string
+}
+
+// JobExportCommand writes a job and its replication state to a bundle that
+// can be imported on another cluster.
+type JobExportCommand struct {
+	Meta
+}
+
+func (c *JobExportCommand) Help() string {
+	helpText := `
+Usage: dtle job export [options] <job>
+
+  Exports a job to a bundle holding its spec, the GTID set it has
+  replicated up to and the definitions of its tables. The bundle can be
+  turned into a job spec continuing the replication from the same point
+  with "dtle job import -format=bundle", for example to move a job to
+  another cluster or for disaster recovery drills.
+
+  The job should be paused first, so that its checkpoint does not move
+  after the export.
+
+General Options:
+
+  ` + generalOptionsUsage() + `
+
+Export Options:
+
+  -out
+    The path the bundle is written to, or "-" for stdout. Defaults to
+    "<job>.bundle.json".
+
+  -schema
+    Connect to the source of the job to save the definitions of its
+    tables. Defaults to true.
+`
+	return strings.TrimSpace(helpText)
+}
+
+func (c *JobExportCommand) Synopsis() string {
+	return "Export a job and its checkpoint to a bundle"
+}
+
+func (c *JobExportCommand) Run(args []string) int {
+	var out string
+	var schema bool
+
+	flags := c.Meta.FlagSet("job export", FlagSetClient)
+	flags.Usage = func() { c.Ui.Output(c.Help()) }
+	flags.StringVar(&out, "out", "", "")
+	flags.BoolVar(&schema, "schema", true, "")
+
+	if err := flags.Parse(args); err != nil {
+		return 1
+	}
+
+	// Check that we got exactly one job
+	args = flags.Args()
+	if len(args) != 1 {
+		c.Ui.Error(c.Help())
+		return 1
+	}
+	jobID := args[0]
+	if out == "" {
+		out = jobID + ".bundle.json"
+	}
+
+	// Get the HTTP client
+	client, err := c.Meta.Client()
+	if err != nil {
+		c.Ui.Error(fmt.Sprintf("Error initializing client: %s", err))
+		return 1
+	}
+
+	job, _, err := client.Jobs().Info(jobID, nil)
+	if err != nil {
+		c.Ui.Error(fmt.Sprintf("Error querying job: %s", err))
+		return 1
+	}
+	if job.Status != nil && *job.Status == models.JobStatusRunning {
+		c.Ui.Warn("Warning: the job is running, its checkpoint moves on after the export")
+	}
+
+	bundle := &jobBundle{
+		Version:    jobBundleVersion,
+		ExportTime: time.Now().UTC(),
+		Job:        job,
+	}
+	src := jobSrcTask(job)
+	if src == nil {
+		c.Ui.Error("The job has no source task")
+		return 1
+	}
+	if gtid, ok := src.Config["Gtid"].(string); ok {
+		bundle.Checkpoint = gtid
+	}
+	if bundle.Checkpoint == "" {
+		c.Ui.Warn("Warning: the job has not finished its full copy, it starts over when imported")
+	}
+
+	if schema {
+		if src.Driver != models.TaskDriverMySQL {
+			c.Ui.Error(fmt.Sprintf("Can not save the tables of a %s source, use -schema=false", src.Driver))
+			return 1
+		}
+		bundle.Schemas, err = snapshotSchemas(src)
+		if err != nil {
+			c.Ui.Error(fmt.Sprintf("Error saving the tables of the source, use -schema=false to skip them: %s", err))
+			return 1
+		}
+	}
+
+	encoded, err := json.MarshalIndent(bundle, "", "    ")
+	if err != nil {
+		c.Ui.Error(fmt.Sprintf("Error encoding the bundle: %s", err))
+		return 1
+	}
+	if out == "-" {
+		c.Ui.Output(string(encoded))
+		return 0
+	}
+	if err := ioutil.WriteFile(out, append(encoded, '\n'), 0600); err != nil {
+		c.Ui.Error(fmt.Sprintf("Failed to write %q: %v", out, err))
+		return 1
+	}
+	c.Ui.Output(fmt.Sprintf("Job %q exported to %s", jobID, out))
+	return 0
+}
+
+func jobSrcTask(job *api.Job) *api.Task {
+	for _, task := range job.Tasks {
+		if task.Type == models.TaskTypeSrc {
+			return task
+		}
+	}
+	return nil
+}
+
+// sourceConfig decodes the MySQL config of a source task
+func sourceConfig(src *api.Task) (*config.MySQLDriverConfig, jobInitConn, error) {
+	var cfg config.MySQLDriverConfig
+	if err := mapstructure.WeakDecode(src.Config, &cfg); err != nil {
+		return nil, jobInitConn{}, err
+	}
+	if cfg.ConnectionConfig == nil {
+		return nil, jobInitConn{}, fmt.Errorf("the source has no connection config")
+	}
+	conn := jobInitConn{
+		Host:     cfg.ConnectionConfig.Host,
+		Port:     cfg.ConnectionConfig.Port,
+		User:     cfg.ConnectionConfig.User,
+		Password: cfg.ConnectionConfig.Password,
+	}
+	return &cfg, conn, nil
+}
+
+// snapshotSchemas returns the definitions of the tables a source task
+// replicates
+func snapshotSchemas(src *api.Task) ([]*jobBundleTable, error) {
+	cfg, conn, err := sourceConfig(src)
+	if err != nil {
+		return nil, err
+	}
+	db, err := openMySQL(conn)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	tables, err := discoverTables(db)
+	if err != nil {
+		return nil, err
+	}
+	var snapshot []*jobBundleTable
+	for _, schema := range sortedSchemas(tables) {
+		names := tables[schema]
+		sort.Strings(names)
+		for _, table := range names {
+			if !replicatesTable(cfg, schema, table) {
+				continue
+			}
+			create, err := showCreateTable(db, schema, table)
+			if err != nil {
+				return nil, err
+			}
+			snapshot = append(snapshot, &jobBundleTable{
+				TableSchema: schema,
+				TableName:   table,
+				CreateTable: create,
+			})
+		}
+	}
+	return snapshot, nil
+}
+
+// replicatesTable tells whether the filters of a source replicate a table
+func replicatesTable(cfg *config.MySQLDriverConfig, schema, table string) bool {
+	if len(cfg.ReplicateDoDb) > 0 && !matchesDataSources(cfg.ReplicateDoDb, schema, table) {
+		return false
+	}
+	return !matchesDataSources(cfg.ReplicateIgnoreDb, schema, table)
+}
+
+func matchesDataSources(sources []*config.DataSource, schema, table string) bool {
+	for _, ds := range sources {
+		if ds.TableSchema != schema {
+			continue
+		}
+		if len(ds.Tables) == 0 {
+			return true
+		}
+		for _, t := range ds.Tables {
+			if t.TableName == table {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func showCreateTable(db *gosql.DB, schema, table string) (string, error) {
+	var name, create string
+	query := fmt.Sprintf("SHOW CREATE TABLE %s.%s", sql.EscapeName(schema), sql.EscapeName(table))
+	if err := db.QueryRow(query).Scan(&name, &create); err != nil {
+		return "", err
+	}
+	return create, nil
+}
+
+var autoIncrementRegexp = regexp.MustCompile(` AUTO_INCREMENT=\d+`)
+
+// sameTableDefinition compares two CREATE TABLE statements, ignoring the
+// next AUTO_INCREMENT value
+func sameTableDefinition(a, b string) bool {
+	return autoIncrementRegexp.ReplaceAllString(a, "") == autoIncrementRegexp.ReplaceAllString(b, "")
+}
@@ -0,0 +1,557 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package command
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/actiontech/dtle/api"
+	"github.com/actiontech/dtle/internal/client/driver/kafka3"
+	uconf "github.com/actiontech/dtle/internal/config"
+	"github.com/actiontech/dtle/internal/models"
+)
+
+const (
+	// jobImportDebezium is the format of Debezium MySQL connector configs
+	jobImportDebezium = "debezium"
+
+	// jobImportBundle is the format of the bundles written by job export
+	jobImportBundle = "bundle"
+
+	debeziumMySQLConnector = "io.debezium.connector.mysql.MySqlConnector"
+)
+
+// JobImportCommand converts the configs of other replication tools into
+// job specs.
+type JobImportCommand struct {
+	Meta
+}
+
+func (c *JobImportCommand) Help() string {
+	helpText := `
+Usage: dtle job import [options] <path>
+
+  Converts the configuration of another replication tool at <path>, or read
+  from stdin if <path> is "-", into a job specification. The job spec is
+  written to stdout unless -out is given. Settings that have no equivalent
+  in dtle are reported and left out.
+
+  The "debezium" format is the JSON config of a Debezium MySQL connector,
+  either as posted to Kafka Connect ({"name": ..., "config": {...}}) or the
+  bare config. It is converted into a job streaming the same tables of the
+  same MySQL server to Kafka, with the same topic prefix, snapshot mode and
+  decimal handling.
+
+  The "bundle" format is a bundle written by "dtle job export". It is
+  converted into the exported job, continuing the replication from the
+  checkpoint of the bundle. The node IDs of the tasks are left out since
+  they belong to the exporting cluster.
+
+Import Options:
+
+  -format
+    The format of the config, "debezium" or "bundle". Defaults to
+    "debezium".
+
+  -name
+    The name of the job. Defaults to the name of the connector.
+
+  -kafka-brokers
+    The comma separated Kafka brokers the job writes to. Defaults to the
+    brokers of the schema history topic of the connector.
+
+  -out
+    The path the job spec is written to.
+
+  -verify-schema
+    Connect to the source of a bundle and check that its tables still have
+    the definitions saved in the bundle. Changed or missing tables are an
+    error.
+`
+	return strings.TrimSpace(helpText)
+}
+
+func (c *JobImportCommand) Synopsis() string {
+	return "Convert the config of another replication tool into a job spec"
+}
+
+func (c *JobImportCommand) Run(args []string) int {
+	var format, name, brokers, out string
+	var verifySchema bool
+
+	flags := c.Meta.FlagSet("job import", FlagSetNone)
+	flags.Usage = func() { c.Ui.Output(c.Help()) }
+	flags.StringVar(&format, "format", jobImportDebezium, "")
+	flags.StringVar(&name, "name", "", "")
+	flags.StringVar(&brokers, "kafka-brokers", "", "")
+	flags.StringVar(&out, "out", "", "")
+	flags.BoolVar(&verifySchema, "verify-schema", false, "")
+
+	if err := flags.Parse(args); err != nil {
+		return 1
+	}
+
+	// Check that we got exactly one config
+	args = flags.Args()
+	if len(args) != 1 {
+		c.Ui.Error(c.Help())
+		return 1
+	}
+	if format != jobImportDebezium && format != jobImportBundle {
+		c.Ui.Error(fmt.Sprintf("Unsupported format %q: must be %q or %q", format, jobImportDebezium, jobImportBundle))
+		return 1
+	}
+
+	var buf []byte
+	var err error
+	if args[0] == "-" {
+		buf, err = ioutil.ReadAll(os.Stdin)
+	} else {
+		buf, err = ioutil.ReadFile(args[0])
+	}
+	if err != nil {
+		c.Ui.Error(fmt.Sprintf("Error reading %q: %v", args[0], err))
+		return 1
+	}
+
+	var spec interface{}
+	var warnings []string
+	switch format {
+	case jobImportBundle:
+		var bundle jobBundle
+		if err := json.Unmarshal(buf, &bundle); err != nil {
+			c.Ui.Error(fmt.Sprintf("Error parsing the bundle: %s", err))
+			return 1
+		}
+		if name != "" {
+			bundle.Job.Name = &name
+		}
+		job, bundleWarnings, err := bundleToJob(&bundle)
+		if err != nil {
+			c.Ui.Error(fmt.Sprintf("Error converting the bundle: %s", err))
+			return 1
+		}
+		if verifySchema {
+			if err := verifyBundleSchemas(&bundle); err != nil {
+				c.Ui.Error(fmt.Sprintf("Error verifying the tables of the source: %s", err))
+				return 1
+			}
+		}
+		spec, warnings = job, bundleWarnings
+	default:
+		connector, err := parseDebeziumConnector(buf)
+		if err != nil {
+			c.Ui.Error(fmt.Sprintf("Error parsing the connector config: %s", err))
+			return 1
+		}
+		if name != "" {
+			connector.Name = name
+		}
+		spec, warnings, err = debeziumToJobSpec(connector, brokers)
+		if err != nil {
+			c.Ui.Error(fmt.Sprintf("Error converting the connector config: %s", err))
+			return 1
+		}
+	}
+	for _, w := range warnings {
+		c.Ui.Warn(fmt.Sprintf("Warning: %s", w))
+	}
+
+	encoded, err := json.MarshalIndent(spec, "", "    ")
+	if err != nil {
+		c.Ui.Error(fmt.Sprintf("Error encoding the job: %s", err))
+		return 1
+	}
+	if out == "" || out == "-" {
+		c.Ui.Output(string(encoded))
+		return 0
+	}
+	if err := ioutil.WriteFile(out, append(encoded, '\n'), 0600); err != nil {
+		c.Ui.Error(fmt.Sprintf("Failed to write %q: %v", out, err))
+		return 1
+	}
+	c.Ui.Output(fmt.Sprintf("Job file written to %s", out))
+	return 0
+}
+
+// bundleToJob returns the job spec of a bundle, continuing from its
+// checkpoint. The state of the exporting cluster, such as the indexes, the
+// status and the node IDs, is left out.
+func bundleToJob(bundle *jobBundle) (*api.Job, []string, error) {
+	if bundle.Version != jobBundleVersion {
+		return nil, nil, fmt.Errorf("unsupported bundle version %d", bundle.Version)
+	}
+	if bundle.Job == nil {
+		return nil, nil, fmt.Errorf("the bundle has no job")
+	}
+
+	job := &api.Job{
+		ID:          bundle.Job.ID,
+		Name:        bundle.Job.Name,
+		Labels:      bundle.Job.Labels,
+		Namespace:   bundle.Job.Namespace,
+		Orders:      bundle.Job.Orders,
+		Failover:    bundle.Job.Failover,
+		Restart:     bundle.Job.Restart,
+		Reschedule:  bundle.Job.Reschedule,
+		Alert:       bundle.Job.Alert,
+		Type:        bundle.Job.Type,
+		Datacenters: bundle.Job.Datacenters,
+	}
+	var warnings []string
+	for _, t := range bundle.Job.Tasks {
+		task := &api.Task{
+			Type:     t.Type,
+			NodeName: t.NodeName,
+			Driver:   t.Driver,
+			Config:   t.Config,
+		}
+		if t.NodeID != "" {
+			warnings = append(warnings, fmt.Sprintf("the node ID of task %q is left out, set its node_name to place it on a given node", t.Type))
+		}
+		if t.Type == models.TaskTypeSrc {
+			if task.Config == nil {
+				task.Config = make(map[string]interface{})
+			}
+			task.Config["Gtid"] = bundle.Checkpoint
+		}
+		job.Tasks = append(job.Tasks, task)
+	}
+	if jobSrcTask(job) == nil {
+		return nil, nil, fmt.Errorf("the job of the bundle has no source task")
+	}
+	if bundle.Checkpoint == "" {
+		warnings = append(warnings, "the bundle has no checkpoint, the job starts over with a full copy")
+	}
+	return job, warnings, nil
+}
+
+// verifyBundleSchemas checks that the tables of the source still have the
+// definitions saved in the bundle
+func verifyBundleSchemas(bundle *jobBundle) error {
+	if len(bundle.Schemas) == 0 {
+		return fmt.Errorf("the bundle has no table definitions")
+	}
+	_, conn, err := sourceConfig(jobSrcTask(bundle.Job))
+	if err != nil {
+		return err
+	}
+	db, err := openMySQL(conn)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	var errs []string
+	for _, table := range bundle.Schemas {
+		create, err := showCreateTable(db, table.TableSchema, table.TableName)
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("%s.%s: %v", table.TableSchema, table.TableName, err))
+			continue
+		}
+		if !sameTableDefinition(create, table.CreateTable) {
+			errs = append(errs, fmt.Sprintf("%s.%s: definition changed since the export", table.TableSchema, table.TableName))
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("%s", strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// debeziumConnector is a Debezium connector as registered in Kafka Connect
+type debeziumConnector struct {
+	Name   string
+	Config map[string]string
+}
+
+// parseDebeziumConnector parses a connector registration or a bare
+// connector config. Values that are not strings are kept in their JSON
+// form.
+func parseDebeziumConnector(buf []byte) (*debeziumConnector, error) {
+	var raw map[string]interface{}
+	if err := json.Unmarshal(buf, &raw); err != nil {
+		return nil, err
+	}
+
+	connector := &debeziumConnector{
+		Config: make(map[string]string),
+	}
+	config := raw
+	if nested, ok := raw["config"].(map[string]interface{}); ok {
+		config = nested
+		if name, ok := raw["name"].(string); ok {
+			connector.Name = name
+		}
+	}
+	for k, v := range config {
+		switch v := v.(type) {
+		case string:
+			connector.Config[k] = v
+		case float64:
+			connector.Config[k] = strconv.FormatFloat(v, 'f', -1, 64)
+		default:
+			encoded, err := json.Marshal(v)
+			if err != nil {
+				return nil, err
+			}
+			connector.Config[k] = string(encoded)
+		}
+	}
+	if connector.Name == "" {
+		connector.Name = connector.Config["name"]
+	}
+	return connector, nil
+}
+
+// debeziumIgnoredKeys are the connector settings that do not matter to a
+// dtle job, so they are left out without a warning
+var debeziumIgnoredKeys = map[string]struct{}{
+	"name":                                     {},
+	"connector.class":                          {},
+	"tasks.max":                                {},
+	"database.server.id":                       {},
+	"key.converter":                            {},
+	"value.converter":                          {},
+	"include.schema.changes":                   {},
+	"database.history.kafka.topic":             {},
+	"schema.history.internal.kafka.topic":      {},
+	"database.history.kafka.bootstrap.servers": {},
+	"schema.history.internal.kafka.bootstrap.servers": {},
+}
+
+// debeziumToJobSpec converts a Debezium MySQL connector into a job
+// streaming to Kafka. It returns the settings that could not be converted
+// as warnings.
+func debeziumToJobSpec(connector *debeziumConnector, brokers string) (*jobInitSpec, []string, error) {
+	config := connector.Config
+	used := make(map[string]struct{})
+	get := func(keys ...string) string {
+		for _, k := range keys {
+			used[k] = struct{}{}
+		}
+		for _, k := range keys {
+			if v, ok := config[k]; ok {
+				return v
+			}
+		}
+		return ""
+	}
+
+	if class := get("connector.class"); class != "" && class != debeziumMySQLConnector {
+		return nil, nil, fmt.Errorf("unsupported connector class %q: only %s is supported", class, debeziumMySQLConnector)
+	}
+	if connector.Name == "" {
+		return nil, nil, fmt.Errorf("missing connector name")
+	}
+
+	var warnings []string
+	src := jobInitConn{
+		Host:     get("database.hostname"),
+		Port:     3306,
+		User:     get("database.user"),
+		Password: get("database.password"),
+	}
+	if port := get("database.port"); port != "" {
+		var err error
+		if src.Port, err = strconv.Atoi(port); err != nil {
+			return nil, nil, fmt.Errorf("invalid database.port %q", port)
+		}
+	}
+	if err := src.validate("source"); err != nil {
+		return nil, nil, err
+	}
+
+	topic := get("topic.prefix", "database.server.name")
+	if topic == "" {
+		topic = connector.Name
+	}
+	if brokers == "" {
+		brokers = get("schema.history.internal.kafka.bootstrap.servers", "database.history.kafka.bootstrap.servers")
+		if brokers != "" {
+			warnings = append(warnings, "the Kafka brokers are the ones of the schema history topic, use -kafka-brokers if the connector wrote elsewhere")
+		}
+	}
+	var brokerList []string
+	for _, broker := range strings.Split(brokers, ",") {
+		if broker = strings.TrimSpace(broker); broker != "" {
+			brokerList = append(brokerList, broker)
+		}
+	}
+	if len(brokerList) == 0 {
+		return nil, nil, fmt.Errorf("missing Kafka brokers, use -kafka-brokers")
+	}
+
+	doDb, err := debeziumTableFilter(
+		get("database.include.list", "database.whitelist"),
+		get("table.include.list", "table.whitelist"))
+	if err != nil {
+		return nil, nil, err
+	}
+	ignoreDb, err := debeziumTableFilter(
+		get("database.exclude.list", "database.blacklist"),
+		get("table.exclude.list", "table.blacklist"))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	srcConfig := map[string]interface{}{
+		"ConnectionConfig": src,
+	}
+	if len(doDb) > 0 {
+		srcConfig["ReplicateDoDb"] = doDb
+	}
+	if len(ignoreDb) > 0 {
+		srcConfig["ReplicateIgnoreDb"] = ignoreDb
+	}
+
+	switch mode := get("snapshot.mode"); mode {
+	case "", "initial":
+		// A job without a GTID copies the tables before streaming
+	case "when_needed":
+		warnings = append(warnings, `snapshot.mode "when_needed" is converted to "initial": the job copies the tables once when it starts`)
+	case "never", "schema_only", "no_data":
+		srcConfig["AutoGtid"] = true
+	default:
+		return nil, nil, fmt.Errorf("unsupported snapshot.mode %q: must be initial, when_needed, never, schema_only or no_data", mode)
+	}
+	switch mode := get("snapshot.locking.mode"); mode {
+	case "", "minimal", "none":
+		// dtle reads the tables in a consistent snapshot without any lock
+	case "extended":
+		srcConfig["SnapshotMode"] = uconf.SnapshotModeLockTables
+	default:
+		return nil, nil, fmt.Errorf("unsupported snapshot.locking.mode %q: must be minimal, extended or none", mode)
+	}
+
+	destConfig := map[string]interface{}{
+		"Brokers": brokerList,
+		"Topic":   topic,
+	}
+	switch mode := get("decimal.handling.mode"); mode {
+	case "":
+	case kafka3.DecimalHandlingPrecise, kafka3.DecimalHandlingString, kafka3.DecimalHandlingDouble:
+		destConfig["DecimalHandlingMode"] = mode
+	default:
+		return nil, nil, fmt.Errorf("unsupported decimal.handling.mode %q", mode)
+	}
+	switch mode := get("binary.handling.mode"); mode {
+	case "", "bytes":
+		// Kafka Connect bytes are written in base64 by the JSON converter
+	case kafka3.BinaryHandlingBase64, kafka3.BinaryHandlingHex:
+		destConfig["BinaryHandlingMode"] = mode
+	default:
+		return nil, nil, fmt.Errorf("unsupported binary.handling.mode %q", mode)
+	}
+	switch enable := get("value.converter.schemas.enable"); enable {
+	case "", "true":
+	case "false":
+		destConfig["OmitSchema"] = true
+	default:
+		return nil, nil, fmt.Errorf("invalid value.converter.schemas.enable %q", enable)
+	}
+
+	var ignored []string
+	for k := range config {
+		if _, ok := used[k]; ok {
+			continue
+		}
+		if _, ok := debeziumIgnoredKeys[k]; ok {
+			continue
+		}
+		ignored = append(ignored, k)
+	}
+	sort.Strings(ignored)
+	for _, k := range ignored {
+		warnings = append(warnings, fmt.Sprintf("%s has no equivalent and is left out", k))
+	}
+
+	spec := &jobInitSpec{
+		Name: connector.Name,
+		Type: models.JobTypeSync,
+		Tasks: []*jobInitTask{
+			{
+				Type:   models.TaskTypeSrc,
+				Driver: models.TaskDriverMySQL,
+				Config: srcConfig,
+			},
+			{
+				Type:   models.TaskTypeDest,
+				Driver: models.TaskDriverKafka,
+				Config: destConfig,
+			},
+		},
+	}
+	return spec, warnings, nil
+}
+
+// debeziumTableFilter converts the database and table lists of a connector
+// into a ReplicateDoDb or ReplicateIgnoreDb. Debezium lists are regular
+// expressions, only plain names and whole schemas such as "inventory.*"
+// can be converted.
+func debeziumTableFilter(databases, tables string) ([]map[string]interface{}, error) {
+	var names []string
+	withTables := make(map[string]struct{})
+	for _, table := range splitDebeziumList(tables) {
+		table = strings.Replace(table, `\.`, ".", -1)
+		parts := strings.SplitN(table, ".", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("invalid table %q: must be schema.table", table)
+		}
+		schema, err := debeziumName(parts[0])
+		if err != nil {
+			return nil, err
+		}
+		withTables[schema] = struct{}{}
+		if parts[1] == ".*" || parts[1] == "*" {
+			names = append(names, schema)
+			continue
+		}
+		name, err := debeziumName(parts[1])
+		if err != nil {
+			return nil, err
+		}
+		names = append(names, schema+"."+name)
+	}
+	// The tables of a listed database are further filtered by the table
+	// list, so a database is only whole if none of its tables is listed
+	for _, db := range splitDebeziumList(databases) {
+		name, err := debeziumName(db)
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := withTables[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	return parseTableList(strings.Join(names, ","))
+}
+
+func splitDebeziumList(list string) []string {
+	var items []string
+	for _, item := range strings.Split(list, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// debeziumName returns the name matched by a Debezium regular expression,
+// which must not match anything else
+func debeziumName(expr string) (string, error) {
+	if strings.ContainsAny(expr, `.*+?[](){}|^$\`) {
+		return "", fmt.Errorf("regular expression %q can not be converted, list the names instead", expr)
+	}
+	return expr, nil
+}
@@ -0,0 +1,535 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package command
+
+import (
+	gosql "database/sql"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	_ "github.com/go-sql-driver/mysql"
+
+	umconf "github.com/actiontech/dtle/internal/config/mysql"
+	"github.com/actiontech/dtle/internal/models"
+)
+
+const (
+	// DefaultJobInitName is the file the job spec is written to when no
+	// path is given
+	DefaultJobInitName = "job.json"
+
+	// jobPatternMySQL replicates a MySQL instance into another one
+	jobPatternMySQL = "mysql-mysql"
+
+	// jobPatternKafka streams the changes of a MySQL instance to Kafka
+	jobPatternKafka = "mysql-kafka"
+)
+
+// JobInitCommand generates a job spec for the common replication patterns,
+// asking for the values that are not given as flags.
+type JobInitCommand struct {
+	Meta
+}
+
+func (c *JobInitCommand) Help() string {
+	helpText := `
+Usage: dtle job init [options] [<path>]
+
+  Generates a job specification for a common replication pattern and writes
+  it to <path>, "job.json" by default, or to stdout if <path> is "-". The
+  values that are not given as flags are asked for interactively. The spec
+  can be submitted with "curl -X POST --data @job.json <addr>/v1/jobs".
+
+Init Options:
+
+  -pattern
+    The replication pattern, either "mysql-mysql" to migrate a MySQL
+    instance into another one or "mysql-kafka" to stream the changes of a
+    MySQL instance to Kafka. Defaults to "mysql-mysql".
+
+  -name
+    The name of the job.
+
+  -namespace
+    The namespace of the job. Defaults to the default namespace.
+
+  -src-host, -src-port, -src-user, -src-password
+    The connection to the source MySQL instance. The port defaults to 3306.
+
+  -dest-host, -dest-port, -dest-user, -dest-password
+    The connection to the destination MySQL instance of the mysql-mysql
+    pattern. The port defaults to 3306.
+
+  -kafka-brokers
+    The comma separated Kafka brokers of the mysql-kafka pattern.
+
+  -kafka-topic
+    The prefix of the Kafka topics of the mysql-kafka pattern. Defaults to
+    the job name.
+
+  -tables
+    The comma separated schemas or schema.table names to replicate. The
+    whole instance is replicated when empty.
+
+  -discover
+    Connect to the source to list its tables. The listed schemas are
+    proposed when -tables is not given, and the given tables are checked
+    against the source.
+
+  -parallel-workers
+    The number of parallel workers of the tasks. Defaults to 4.
+
+  -non-interactive
+    Do not ask for missing values. Missing required values are an error.
+`
+	return strings.TrimSpace(helpText)
+}
+
+func (c *JobInitCommand) Synopsis() string {
+	return "Generate a job spec for a common replication pattern"
+}
+
+// jobInitConn is a MySQL connection of a generated job
+type jobInitConn struct {
+	Host     string
+	Port     int
+	User     string
+	Password string
+}
+
+// jobInitOptions are the values a job spec is generated from
+type jobInitOptions struct {
+	Pattern         string
+	Name            string
+	Namespace       string
+	Src             jobInitConn
+	Dest            jobInitConn
+	KafkaBrokers    string
+	KafkaTopic      string
+	Tables          string
+	ParallelWorkers int
+}
+
+// jobInitSpec is the generated job spec. It only holds the fields the
+// wizard sets, so the written spec stays short.
+type jobInitSpec struct {
+	Name      string
+	Namespace string `json:",omitempty"`
+	Type      string
+	Tasks     []*jobInitTask
+}
+
+type jobInitTask struct {
+	Type   string
+	Driver string
+	Config map[string]interface{}
+}
+
+func (c *JobInitCommand) Run(args []string) int {
+	var opts jobInitOptions
+	var discover, nonInteractive bool
+
+	flags := c.Meta.FlagSet("job init", FlagSetNone)
+	flags.Usage = func() { c.Ui.Output(c.Help()) }
+	flags.StringVar(&opts.Pattern, "pattern", "", "")
+	flags.StringVar(&opts.Name, "name", "", "")
+	flags.StringVar(&opts.Namespace, "namespace", "", "")
+	flags.StringVar(&opts.Src.Host, "src-host", "", "")
+	flags.IntVar(&opts.Src.Port, "src-port", 0, "")
+	flags.StringVar(&opts.Src.User, "src-user", "", "")
+	flags.StringVar(&opts.Src.Password, "src-password", "", "")
+	flags.StringVar(&opts.Dest.Host, "dest-host", "", "")
+	flags.IntVar(&opts.Dest.Port, "dest-port", 0, "")
+	flags.StringVar(&opts.Dest.User, "dest-user", "", "")
+	flags.StringVar(&opts.Dest.Password, "dest-password", "", "")
+	flags.StringVar(&opts.KafkaBrokers, "kafka-brokers", "", "")
+	flags.StringVar(&opts.KafkaTopic, "kafka-topic", "", "")
+	flags.StringVar(&opts.Tables, "tables", "", "")
+	flags.IntVar(&opts.ParallelWorkers, "parallel-workers", 4, "")
+	flags.BoolVar(&discover, "discover", false, "")
+	flags.BoolVar(&nonInteractive, "non-interactive", false, "")
+
+	if err := flags.Parse(args); err != nil {
+		return 1
+	}
+
+	// Check that we got at most one path
+	args = flags.Args()
+	if len(args) > 1 {
+		c.Ui.Error(c.Help())
+		return 1
+	}
+	path := DefaultJobInitName
+	if len(args) == 1 {
+		path = args[0]
+	}
+	if path != "-" {
+		if _, err := os.Stat(path); err == nil {
+			c.Ui.Error(fmt.Sprintf("Job file %q already exists", path))
+			return 1
+		} else if !os.IsNotExist(err) {
+			c.Ui.Error(fmt.Sprintf("Failed to stat %q: %v", path, err))
+			return 1
+		}
+	}
+
+	if !nonInteractive {
+		if err := c.askOptions(&opts); err != nil {
+			c.Ui.Error(fmt.Sprintf("Error reading input: %s", err))
+			return 1
+		}
+		if !discover && opts.Src.Host != "" {
+			answer, err := c.ask("Connect to the source to list its tables? (y/n)", "y")
+			if err != nil {
+				c.Ui.Error(fmt.Sprintf("Error reading input: %s", err))
+				return 1
+			}
+			discover = strings.HasPrefix(strings.ToLower(answer), "y")
+		}
+	}
+	setJobInitDefaults(&opts)
+
+	if discover {
+		tables, err := discoverSourceTables(opts.Src)
+		if err != nil {
+			c.Ui.Error(fmt.Sprintf("Error listing the tables of the source: %s", err))
+			return 1
+		}
+		schemas := sortedSchemas(tables)
+		c.Ui.Output("Schemas of the source:")
+		for _, schema := range schemas {
+			c.Ui.Output(fmt.Sprintf("  %s (%d tables)", schema, len(tables[schema])))
+		}
+
+		if opts.Tables == "" {
+			opts.Tables = strings.Join(schemas, ",")
+			if !nonInteractive {
+				opts.Tables, err = c.ask("Tables to replicate, as schema or schema.table separated by commas", opts.Tables)
+				if err != nil {
+					c.Ui.Error(fmt.Sprintf("Error reading input: %s", err))
+					return 1
+				}
+			}
+		}
+		if err := checkTables(opts.Tables, tables); err != nil {
+			c.Ui.Error(err.Error())
+			return 1
+		}
+	}
+
+	spec, err := buildJobInitSpec(&opts)
+	if err != nil {
+		c.Ui.Error(fmt.Sprintf("Invalid job: %s", err))
+		return 1
+	}
+	out, err := json.MarshalIndent(spec, "", "    ")
+	if err != nil {
+		c.Ui.Error(fmt.Sprintf("Error encoding the job: %s", err))
+		return 1
+	}
+
+	if path == "-" {
+		c.Ui.Output(string(out))
+		return 0
+	}
+	if err := ioutil.WriteFile(path, append(out, '\n'), 0600); err != nil {
+		c.Ui.Error(fmt.Sprintf("Failed to write %q: %v", path, err))
+		return 1
+	}
+	c.Ui.Output(fmt.Sprintf("Job file written to %s", path))
+	return 0
+}
+
+// askOptions asks for the options that were not given as flags
+func (c *JobInitCommand) askOptions(opts *jobInitOptions) error {
+	var err error
+	askString := func(dst *string, query, def string) {
+		if err == nil && *dst == "" {
+			*dst, err = c.ask(query, def)
+		}
+	}
+	askSecret := func(dst *string, query string) {
+		if err == nil && *dst == "" {
+			*dst, err = c.Ui.AskSecret(query + ":")
+		}
+	}
+	askPort := func(dst *int, query string) {
+		if err != nil || *dst != 0 {
+			return
+		}
+		var port string
+		port, err = c.ask(query, "3306")
+		if err == nil {
+			*dst, err = strconv.Atoi(port)
+		}
+	}
+
+	askString(&opts.Pattern, "Pattern (mysql-mysql or mysql-kafka)", jobPatternMySQL)
+	askString(&opts.Name, "Job name", "")
+	askString(&opts.Src.Host, "Source MySQL host", "")
+	askPort(&opts.Src.Port, "Source MySQL port")
+	askString(&opts.Src.User, "Source MySQL user", "")
+	askSecret(&opts.Src.Password, "Source MySQL password")
+	switch opts.Pattern {
+	case jobPatternKafka:
+		askString(&opts.KafkaBrokers, "Kafka brokers, separated by commas", "")
+		askString(&opts.KafkaTopic, "Kafka topic prefix", opts.Name)
+	default:
+		askString(&opts.Dest.Host, "Destination MySQL host", "")
+		askPort(&opts.Dest.Port, "Destination MySQL port")
+		askString(&opts.Dest.User, "Destination MySQL user", "")
+		askSecret(&opts.Dest.Password, "Destination MySQL password")
+	}
+	return err
+}
+
+// ask asks a question, returning def for an empty answer
+func (c *JobInitCommand) ask(query, def string) (string, error) {
+	if def != "" {
+		query = fmt.Sprintf("%s [%s]", query, def)
+	}
+	answer, err := c.Ui.Ask(query + ":")
+	if err != nil {
+		return "", err
+	}
+	answer = strings.TrimSpace(answer)
+	if answer == "" {
+		return def, nil
+	}
+	return answer, nil
+}
+
+func setJobInitDefaults(opts *jobInitOptions) {
+	if opts.Pattern == "" {
+		opts.Pattern = jobPatternMySQL
+	}
+	if opts.Src.Port == 0 {
+		opts.Src.Port = 3306
+	}
+	if opts.Dest.Port == 0 {
+		opts.Dest.Port = 3306
+	}
+	if opts.KafkaTopic == "" {
+		opts.KafkaTopic = opts.Name
+	}
+}
+
+// buildJobInitSpec validates the options and returns the job spec
+func buildJobInitSpec(opts *jobInitOptions) (*jobInitSpec, error) {
+	if opts.Name == "" {
+		return nil, fmt.Errorf("missing job name")
+	}
+	if opts.ParallelWorkers < 1 {
+		return nil, fmt.Errorf("parallel workers must be at least 1")
+	}
+	if err := opts.Src.validate("source"); err != nil {
+		return nil, err
+	}
+	doDb, err := parseTableList(opts.Tables)
+	if err != nil {
+		return nil, err
+	}
+
+	srcConfig := map[string]interface{}{
+		"ParallelWorkers":  opts.ParallelWorkers,
+		"ConnectionConfig": opts.Src,
+	}
+	if len(doDb) > 0 {
+		srcConfig["ReplicateDoDb"] = doDb
+	}
+	spec := &jobInitSpec{
+		Name:      opts.Name,
+		Namespace: opts.Namespace,
+		Type:      models.JobTypeSync,
+		Tasks: []*jobInitTask{{
+			Type:   models.TaskTypeSrc,
+			Driver: models.TaskDriverMySQL,
+			Config: srcConfig,
+		}},
+	}
+
+	switch opts.Pattern {
+	case jobPatternMySQL:
+		if err := opts.Dest.validate("destination"); err != nil {
+			return nil, err
+		}
+		spec.Tasks = append(spec.Tasks, &jobInitTask{
+			Type:   models.TaskTypeDest,
+			Driver: models.TaskDriverMySQL,
+			Config: map[string]interface{}{
+				"ParallelWorkers":  opts.ParallelWorkers,
+				"ConnectionConfig": opts.Dest,
+			},
+		})
+	case jobPatternKafka:
+		var brokers []string
+		for _, broker := range strings.Split(opts.KafkaBrokers, ",") {
+			if broker = strings.TrimSpace(broker); broker != "" {
+				brokers = append(brokers, broker)
+			}
+		}
+		if len(brokers) == 0 {
+			return nil, fmt.Errorf("missing Kafka brokers")
+		}
+		spec.Tasks = append(spec.Tasks, &jobInitTask{
+			Type:   models.TaskTypeDest,
+			Driver: models.TaskDriverKafka,
+			Config: map[string]interface{}{
+				"Brokers": brokers,
+				"Topic":   opts.KafkaTopic,
+			},
+		})
+	default:
+		return nil, fmt.Errorf("unknown pattern %q: must be %s or %s", opts.Pattern, jobPatternMySQL, jobPatternKafka)
+	}
+	return spec, nil
+}
+
+func (c *jobInitConn) validate(role string) error {
+	if c.Host == "" {
+		return fmt.Errorf("missing %s host", role)
+	}
+	if c.Port < 1 || c.Port > 65535 {
+		return fmt.Errorf("invalid %s port %d", role, c.Port)
+	}
+	if c.User == "" {
+		return fmt.Errorf("missing %s user", role)
+	}
+	return nil
+}
+
+// parseTableList turns a comma separated list of schemas and schema.table
+// names into the ReplicateDoDb of a task config
+func parseTableList(list string) ([]map[string]interface{}, error) {
+	var schemas []string
+	tables := make(map[string][]string)
+	for _, name := range strings.Split(list, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		parts := strings.SplitN(name, ".", 2)
+		if parts[0] == "" || (len(parts) == 2 && parts[1] == "") {
+			return nil, fmt.Errorf("invalid table %q: must be schema or schema.table", name)
+		}
+		current, ok := tables[parts[0]]
+		if !ok {
+			schemas = append(schemas, parts[0])
+		}
+		switch {
+		case len(parts) == 1:
+			// The whole schema is replicated
+			tables[parts[0]] = []string{}
+		case ok && len(current) == 0:
+			// The whole schema is already replicated
+		default:
+			tables[parts[0]] = append(current, parts[1])
+		}
+	}
+
+	var doDb []map[string]interface{}
+	for _, schema := range schemas {
+		ds := map[string]interface{}{
+			"TableSchema": schema,
+		}
+		if names := tables[schema]; len(names) > 0 {
+			var ts []map[string]interface{}
+			for _, name := range names {
+				ts = append(ts, map[string]interface{}{"TableName": name})
+			}
+			ds["Tables"] = ts
+		}
+		doDb = append(doDb, ds)
+	}
+	return doDb, nil
+}
+
+// openMySQL connects to a MySQL instance
+func openMySQL(conn jobInitConn) (*gosql.DB, error) {
+	cfg := umconf.ConnectionConfig{
+		Host:     conn.Host,
+		Port:     conn.Port,
+		User:     conn.User,
+		Password: conn.Password,
+		Charset:  "utf8",
+	}
+	return gosql.Open("mysql", cfg.GetDBUriByDbName("information_schema"))
+}
+
+func discoverSourceTables(conn jobInitConn) (map[string][]string, error) {
+	db, err := openMySQL(conn)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+	return discoverTables(db)
+}
+
+// discoverTables lists the tables of the source by schema, leaving out the
+// system schemas
+func discoverTables(db *gosql.DB) (map[string][]string, error) {
+	rows, err := db.Query(`SELECT table_schema, table_name FROM information_schema.tables
+		WHERE table_type = 'BASE TABLE'
+		AND table_schema NOT IN ('mysql', 'information_schema', 'performance_schema', 'sys')`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tables := make(map[string][]string)
+	for rows.Next() {
+		var schema, table string
+		if err := rows.Scan(&schema, &table); err != nil {
+			return nil, err
+		}
+		tables[schema] = append(tables[schema], table)
+	}
+	return tables, rows.Err()
+}
+
+func sortedSchemas(tables map[string][]string) []string {
+	schemas := make([]string, 0, len(tables))
+	for schema := range tables {
+		schemas = append(schemas, schema)
+	}
+	sort.Strings(schemas)
+	return schemas
+}
+
+// checkTables verifies the listed schemas and tables exist on the source
+func checkTables(list string, tables map[string][]string) error {
+	for _, name := range strings.Split(list, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		parts := strings.SplitN(name, ".", 2)
+		names, ok := tables[parts[0]]
+		if !ok {
+			return fmt.Errorf("schema %q does not exist on the source", parts[0])
+		}
+		if len(parts) == 1 {
+			continue
+		}
+		found := false
+		for _, table := range names {
+			if table == parts[1] {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fmt.Errorf("table %q does not exist on the source", name)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,300 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package command
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/actiontech/dtle/api"
+	"github.com/actiontech/dtle/internal/models"
+)
+
+const (
+	// planChanges is the exit code of a plan that changes the job
+	planChanges = 1
+
+	// planError is the exit code of a failed plan
+	planError = 255
+)
+
+// JobPlanCommand shows what submitting a job spec would change, without
+// changing anything.
+type JobPlanCommand struct {
+	Meta
+}
+
+func (c *JobPlanCommand) Help() string {
+	helpText := `
+Usage: dtle job plan [options] <path>
+
+  Diffs the job specification at <path>, or read from stdin if <path> is
+  "-", against the registered job and shows which changes are applied in
+  place and which restart tasks. Nothing is changed by a plan.
+
+  The job is matched by the ID of the spec, or by its name and namespace
+  if the spec has no ID. A scheduler dry-run shows how the tasks would be
+  placed.
+
+  The exit code is 0 if the job is unchanged, 1 if submitting the spec
+  changes the job and 255 on error.
+
+General Options:
+
+  ` + generalOptionsUsage() + `
+
+Plan Options:
+
+  -verbose
+    Show the fields of the tasks that are created or destroyed.
+`
+	return strings.TrimSpace(helpText)
+}
+
+func (c *JobPlanCommand) Synopsis() string {
+	return "Show the changes of an updated job spec"
+}
+
+func (c *JobPlanCommand) Run(args []string) int {
+	var verbose bool
+
+	flags := c.Meta.FlagSet("job plan", FlagSetClient)
+	flags.Usage = func() { c.Ui.Output(c.Help()) }
+	flags.BoolVar(&verbose, "verbose", false, "")
+
+	if err := flags.Parse(args); err != nil {
+		return planError
+	}
+
+	// Check that we got exactly one job file
+	args = flags.Args()
+	if len(args) != 1 {
+		c.Ui.Error(c.Help())
+		return planError
+	}
+
+	job, err := ReadJobFile(args[0])
+	if err != nil {
+		c.Ui.Error(fmt.Sprintf("Error reading job file: %s", err))
+		return planError
+	}
+
+	// Get the HTTP client
+	client, err := c.Meta.Client()
+	if err != nil {
+		c.Ui.Error(fmt.Sprintf("Error initializing client: %s", err))
+		return planError
+	}
+
+	// Force the region to be that of the job.
+	if r := job.Region; r != nil {
+		client.SetRegion(*r)
+	}
+
+	if job.ID == nil {
+		id, err := lookupJobID(client, job)
+		if err != nil {
+			c.Ui.Error(fmt.Sprintf("Error looking up the job: %s", err))
+			return planError
+		}
+		job.ID = &id
+	}
+
+	resp, _, err := client.Jobs().Plan(job, true, nil)
+	if err != nil {
+		c.Ui.Error(fmt.Sprintf("Error during plan: %s", err))
+		return planError
+	}
+
+	c.Ui.Output(c.Colorize().Color(formatJobDiff(resp.Diff, verbose)))
+	c.Ui.Output(c.Colorize().Color(formatDryRun(resp)))
+	if resp.JobModifyIndex != 0 {
+		c.Ui.Output(fmt.Sprintf("Job Modify Index: %d", resp.JobModifyIndex))
+	}
+
+	if resp.Diff != nil && resp.Diff.Type != models.DiffTypeNone {
+		return planChanges
+	}
+	return 0
+}
+
+// lookupJobID returns the ID of the registered job with the name and
+// namespace of the spec, or a new ID if there is no such job
+func lookupJobID(client *api.Client, job *api.Job) (string, error) {
+	if job.Name == nil || *job.Name == "" {
+		return "", fmt.Errorf("the job spec has neither an ID nor a name")
+	}
+	namespace := models.DefaultNamespace
+	if job.Namespace != nil && *job.Namespace != "" {
+		namespace = *job.Namespace
+	}
+
+	jobs, _, err := client.Jobs().List(&api.QueryOptions{Namespace: namespace})
+	if err != nil {
+		return "", err
+	}
+	for _, stub := range jobs {
+		if stub.Name == *job.Name && stub.Namespace == namespace {
+			return stub.ID, nil
+		}
+	}
+	return models.GenerateUUID(), nil
+}
+
+// formatJobDiff renders the diff of a plan, annotating each change with
+// whether it is applied in place or restarts the task
+func formatJobDiff(diff *api.JobDiff, verbose bool) string {
+	if diff == nil {
+		return "[bold]Job is unchanged[reset]\n"
+	}
+
+	var out []string
+	out = append(out, fmt.Sprintf("%s[bold]Job: %q[reset]", diffMarker(diff.Type), diff.ID))
+	for _, f := range diff.Fields {
+		out = append(out, formatFieldDiff(f, "  "))
+	}
+	for _, t := range diff.Tasks {
+		line := fmt.Sprintf("%s[bold]Task: %q[reset]", diffMarker(t.Type), t.Name)
+		if len(t.Annotations) > 0 {
+			line += fmt.Sprintf(" (%s)", colorAnnotations(t.Annotations))
+		}
+		out = append(out, "", line)
+		if !verbose && (t.Type == models.DiffTypeAdded || t.Type == models.DiffTypeDeleted) {
+			continue
+		}
+		for _, f := range t.Fields {
+			out = append(out, formatFieldDiff(f, "  "))
+		}
+	}
+	if diff.Type == models.DiffTypeNone {
+		out = append(out, "", "[bold]Job is unchanged[reset]")
+	}
+	return strings.Join(out, "\n") + "\n"
+}
+
+func formatFieldDiff(f *api.FieldDiff, prefix string) string {
+	var change string
+	switch f.Type {
+	case models.DiffTypeAdded:
+		change = fmt.Sprintf("%q", f.New)
+	case models.DiffTypeDeleted:
+		change = fmt.Sprintf("%q", f.Old)
+	default:
+		change = fmt.Sprintf("%q => %q", f.Old, f.New)
+	}
+
+	annotations := f.Annotations
+	if category := configFieldCategory(f.Name); category != "" {
+		annotations = append([]string{category}, annotations...)
+	}
+	line := fmt.Sprintf("%s%s%s: %s", prefix, diffMarker(f.Type), f.Name, change)
+	if len(annotations) > 0 {
+		line += fmt.Sprintf(" (%s)", colorAnnotations(annotations))
+	}
+	return line
+}
+
+// configFieldCategory names the kind of task config a diff field belongs
+// to, so that changed filters, throttles and destinations stand out
+func configFieldCategory(name string) string {
+	if !strings.HasPrefix(name, "Config.") {
+		return ""
+	}
+	name = strings.TrimPrefix(name, "Config.")
+	if i := strings.IndexAny(name, ".["); i >= 0 {
+		name = name[:i]
+	}
+	switch name {
+	case "ReplicateDoDb", "ReplicateIgnoreDb", "SqlFilter":
+		return "filter"
+	case "ParallelWorkers", "ReplChanBufferSize", "MsgBytesLimit", "TrafficAgainstLimits",
+		"ChunkSize", "GroupCount", "GroupMaxSize", "GroupTimeout",
+		"MaxLagMillisecondsThrottleThreshold":
+		return "throttle"
+	case "ConnectionConfig", "Brokers", "Topic", "NatsAddr":
+		return "destination"
+	}
+	return ""
+}
+
+func diffMarker(diffType string) string {
+	switch diffType {
+	case models.DiffTypeAdded:
+		return "[green]+[reset] "
+	case models.DiffTypeDeleted:
+		return "[red]-[reset] "
+	case models.DiffTypeEdited:
+		return "[light_yellow]~[reset] "
+	default:
+		return "  "
+	}
+}
+
+func colorAnnotations(annotations []string) string {
+	colored := make([]string, len(annotations))
+	for i, a := range annotations {
+		switch a {
+		case models.AnnotationForcesCreate:
+			colored[i] = fmt.Sprintf("[green]%s[reset]", a)
+		case models.AnnotationForcesDestroy, models.AnnotationForcesRestart:
+			colored[i] = fmt.Sprintf("[red]%s[reset]", a)
+		case models.AnnotationInPlace:
+			colored[i] = fmt.Sprintf("[cyan]%s[reset]", a)
+		default:
+			colored[i] = a
+		}
+	}
+	return strings.Join(colored, ", ")
+}
+
+// formatDryRun renders the scheduler dry-run of a plan
+func formatDryRun(resp *api.JobPlanResponse) string {
+	out := []string{"[bold]Scheduler dry-run:[reset]"}
+	if len(resp.FailedTGAllocs) == 0 {
+		out = append(out, "- [green]All tasks successfully allocated.[reset]")
+	} else {
+		var tasks []string
+		for task := range resp.FailedTGAllocs {
+			tasks = append(tasks, task)
+		}
+		sort.Strings(tasks)
+		for _, task := range tasks {
+			out = append(out, fmt.Sprintf("- [red]WARNING: Failed to place task %q.[reset]", task))
+		}
+	}
+
+	if resp.Annotations != nil {
+		var tasks []string
+		for task := range resp.Annotations.DesiredTGUpdates {
+			tasks = append(tasks, task)
+		}
+		sort.Strings(tasks)
+		for _, task := range tasks {
+			u := resp.Annotations.DesiredTGUpdates[task]
+			var parts []string
+			for _, p := range []struct {
+				count uint64
+				what  string
+			}{
+				{u.Place, "create"},
+				{u.Stop, "destroy"},
+				{u.InPlaceUpdate, "in-place update"},
+				{u.DestructiveUpdate, "restart"},
+				{u.Migrate, "migrate"},
+			} {
+				if p.count > 0 {
+					parts = append(parts, fmt.Sprintf("%d %s", p.count, p.what))
+				}
+			}
+			if len(parts) > 0 {
+				out = append(out, fmt.Sprintf("- Task %q: %s", task, strings.Join(parts, ", ")))
+			}
+		}
+	}
+	return strings.Join(out, "\n") + "\n"
+}
@@ -0,0 +1,135 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package command
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/mitchellh/mapstructure"
+
+	"github.com/actiontech/dtle/internal/client/driver/kafka3"
+	ulog "github.com/actiontech/dtle/internal/logger"
+	"github.com/actiontech/dtle/internal/models"
+)
+
+// JobReplayCommand sends the messages a target recorded to its RecordFile
+// through its serialization again, without the source
+type JobReplayCommand struct {
+	Meta
+}
+
+func (c *JobReplayCommand) Help() string {
+	helpText := `
+Usage: dtle job replay [options] -job <path> -fixture <file>
+
+  Replays the messages of the source recorded by the target of the job
+  specification at <path>, or read from stdin if <path> is "-", to the
+  RecordFile <file>, and writes the records the target made of them, one
+  JSON object per line as with DryRunFile. The records have the times the
+  messages were received, so that a replay writes the same records, for an
+  issue of their serialization to be reproduced without the source.
+
+  The target is a Kafka, Webhook, JetStream or Embedded one, whose options
+  such as Topic, MessageFormat and Converter make the records. Nothing is
+  sent to its brokers or endpoint.
+
+Replay Options:
+
+  -job
+    The path of the job specification. Required.
+
+  -fixture
+    The RecordFile of the target. Required.
+
+  -output
+    The file the records are appended to. Defaults to stdout.
+
+  -log-level
+    The level of the logs. Defaults to "WARN".
+`
+	return strings.TrimSpace(helpText)
+}
+
+func (c *JobReplayCommand) Synopsis() string {
+	return "Replay the messages recorded by the target of a job"
+}
+
+func (c *JobReplayCommand) Run(args []string) int {
+	var jobPath, fixture, output, logLevel string
+
+	flags := c.Meta.FlagSet("job replay", FlagSetNone)
+	flags.Usage = func() { c.Ui.Output(c.Help()) }
+	flags.StringVar(&jobPath, "job", "", "")
+	flags.StringVar(&fixture, "fixture", "", "")
+	flags.StringVar(&output, "output", "", "")
+	flags.StringVar(&logLevel, "log-level", "WARN", "")
+
+	if err := flags.Parse(args); err != nil {
+		return 1
+	}
+	if jobPath == "" || fixture == "" || len(flags.Args()) != 0 {
+		c.Ui.Error(c.Help())
+		return 1
+	}
+
+	job, err := ReadJobFile(jobPath)
+	if err != nil {
+		c.Ui.Error(fmt.Sprintf("Error reading job file: %s", err))
+		return 1
+	}
+	var cfg *kafka3.KafkaConfig
+	for _, task := range job.Tasks {
+		if task.Type != models.TaskTypeDest {
+			continue
+		}
+		switch task.Driver {
+		case models.TaskDriverKafka, models.TaskDriverWebhook, models.TaskDriverJetStream, models.TaskDriverEmbedded:
+		default:
+			c.Ui.Error(fmt.Sprintf("The messages of a %s target can not be replayed", task.Driver))
+			return 1
+		}
+		cfg = &kafka3.KafkaConfig{}
+		if err := mapstructure.WeakDecode(task.Config, cfg); err != nil {
+			c.Ui.Error(fmt.Sprintf("Error decoding the target config: %s", err))
+			return 1
+		}
+	}
+	if cfg == nil {
+		c.Ui.Error("The job has no target")
+		return 1
+	}
+	if cfg.Topic == "" && job.Name != nil {
+		cfg.Topic = *job.Name
+	}
+	// the records are written rather than sent
+	cfg.Webhook, cfg.JetStream, cfg.Embedded, cfg.TopicCreation = nil, nil, nil, nil
+	cfg.RecordFile = ""
+	cfg.DryRunFile = output
+	if output == "" {
+		cfg.DryRunFile = os.Stdout.Name()
+	}
+
+	f, err := os.Open(fixture)
+	if err != nil {
+		c.Ui.Error(fmt.Sprintf("Error opening the fixture: %s", err))
+		return 1
+	}
+	defer f.Close()
+
+	logger := ulog.New(os.Stderr, ulog.ParseLevel(logLevel))
+	n, err := kafka3.Replay(f, cfg, logger)
+	if err != nil {
+		c.Ui.Error(fmt.Sprintf("Error replaying the fixture after %d messages: %s", n, err))
+		return 1
+	}
+	if output != "" {
+		c.Ui.Output(fmt.Sprintf("Replayed %d messages to %s", n, output))
+	}
+	return 0
+}
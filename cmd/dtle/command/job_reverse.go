@@ -0,0 +1,221 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package command
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/mitchellh/mapstructure"
+
+	"github.com/actiontech/dtle/api"
+	"github.com/actiontech/dtle/internal/config"
+	"github.com/actiontech/dtle/internal/models"
+)
+
+// JobReverseCommand creates the job replicating the target of a cut over
+// job back to its source, to roll the cutover back.
+type JobReverseCommand struct {
+	Meta
+}
+
+func (c *JobReverseCommand) Help() string {
+	helpText := `
+Usage: dtle job reverse [options] <job>
+
+  Creates the reverse of a MySQL job cut over with "dtle job cutover": a job
+  replicating the tables of the job from its target, the new primary, back
+  to its source, the old primary. The reverse job starts from the GTID set
+  executed on the target at the cutover, so that the writes made to the
+  target since are replayed on the source without a full copy, and the
+  source can be switched back to during the bake period.
+
+  The job is paused first if it is running, as it would replicate the
+  writes of the reverse job back to the target. The source must accept the
+  writes of the reverse job: a source left super_read_only by the cutover
+  has to be set writable for the user of the job.
+
+General Options:
+
+  ` + generalOptionsUsage() + `
+
+Reverse Options:
+
+  -name
+    The name of the reverse job. Defaults to "<job name>-reverse".
+`
+	return strings.TrimSpace(helpText)
+}
+
+func (c *JobReverseCommand) Synopsis() string {
+	return "Create the job replicating a cut over job back"
+}
+
+func (c *JobReverseCommand) Run(args []string) int {
+	var name string
+
+	flags := c.Meta.FlagSet("job reverse", FlagSetClient)
+	flags.Usage = func() { c.Ui.Output(c.Help()) }
+	flags.StringVar(&name, "name", "", "")
+
+	if err := flags.Parse(args); err != nil {
+		return 1
+	}
+
+	// Check that we got exactly one job
+	args = flags.Args()
+	if len(args) != 1 {
+		c.Ui.Error(c.Help())
+		return 1
+	}
+	jobID := args[0]
+
+	// Get the HTTP client
+	client, err := c.Meta.Client()
+	if err != nil {
+		c.Ui.Error(fmt.Sprintf("Error initializing client: %s", err))
+		return 1
+	}
+
+	job, _, err := client.Jobs().Info(jobID, nil)
+	if err != nil {
+		c.Ui.Error(fmt.Sprintf("Error querying job: %s", err))
+		return 1
+	}
+	events, _, err := client.Jobs().Events(jobID, nil)
+	if err != nil {
+		c.Ui.Error(fmt.Sprintf("Error querying job events: %s", err))
+		return 1
+	}
+	var cutover *api.JobEvent
+	for _, e := range events {
+		if e.Type == models.JobEventCutover {
+			cutover = e
+		}
+	}
+	if cutover == nil || cutover.Details[models.JobEventDetailTargetGtid] == "" {
+		c.Ui.Error(fmt.Sprintf("Error: job %q has not been cut over, run \"dtle job cutover\" first", jobID))
+		return 1
+	}
+
+	if name == "" && job.Name != nil {
+		name = *job.Name + "-reverse"
+	}
+	reverse, warnings, err := reverseJob(job, cutover.Details[models.JobEventDetailTargetGtid], name)
+	if err != nil {
+		c.Ui.Error(fmt.Sprintf("Error reversing job: %s", err))
+		return 1
+	}
+	for _, w := range warnings {
+		c.Ui.Warn(fmt.Sprintf("Warning: %s", w))
+	}
+
+	if job.Status != nil && *job.Status == models.JobStatusRunning {
+		if _, err := client.Jobs().Pause(jobID, nil); err != nil {
+			c.Ui.Error(fmt.Sprintf("Error pausing job: %s", err))
+			return 1
+		}
+		c.Ui.Output(fmt.Sprintf("Job %q paused", jobID))
+	}
+
+	evalID, _, err := client.Jobs().Register(reverse, nil)
+	if err != nil {
+		c.Ui.Error(fmt.Sprintf("Error submitting the reverse job: %s", err))
+		return 1
+	}
+	c.Ui.Output(fmt.Sprintf("Reverse job %q submitted, replicating from %s", *reverse.Name,
+		cutover.Details[models.JobEventDetailTargetGtid]))
+	c.Ui.Output("Job ID: " + *reverse.ID)
+	c.Ui.Output("Evaluation ID: " + evalID)
+	return 0
+}
+
+// reverseJob returns the job replicating the target of a MySQL job back to
+// its source from a GTID set of the target. The tasks keep their config and
+// swap their connections and nodes.
+func reverseJob(job *api.Job, targetGtid string, name string) (*api.Job, []string, error) {
+	var src, dest *api.Task
+	for _, t := range job.Tasks {
+		if t.Driver != "" && t.Driver != models.TaskDriverMySQL {
+			return nil, nil, fmt.Errorf("a job with a %s task can not be reversed", t.Driver)
+		}
+		switch t.Type {
+		case models.TaskTypeSrc:
+			src = t
+		case models.TaskTypeDest:
+			dest = t
+		}
+	}
+	if src == nil || dest == nil {
+		return nil, nil, fmt.Errorf("the job has no source or target")
+	}
+	if src.Config["ConnectionConfig"] == nil || dest.Config["ConnectionConfig"] == nil {
+		return nil, nil, fmt.Errorf("the job has no source or target connection config")
+	}
+
+	var warnings []string
+	var srcConfig config.MySQLDriverConfig
+	if err := mapstructure.WeakDecode(src.Config, &srcConfig); err != nil {
+		return nil, nil, err
+	}
+	for _, db := range srcConfig.ReplicateDoDb {
+		for _, t := range db.Tables {
+			if t.Where != "" {
+				warnings = append(warnings, fmt.Sprintf("table %s.%s is filtered with %q, the reverse job replicates all its rows",
+					db.TableSchema, t.TableName, t.Where))
+			}
+		}
+	}
+
+	reverseSrc := &api.Task{
+		Type:     models.TaskTypeSrc,
+		NodeName: dest.NodeName,
+		Driver:   models.TaskDriverMySQL,
+		Config:   copyTaskConfig(src.Config),
+	}
+	reverseSrc.Config["ConnectionConfig"] = dest.Config["ConnectionConfig"]
+	reverseSrc.Config["Gtid"] = targetGtid
+	delete(reverseSrc.Config, "SchemaHistory")
+	reverseDest := &api.Task{
+		Type:     models.TaskTypeDest,
+		NodeName: src.NodeName,
+		Driver:   models.TaskDriverMySQL,
+		Config:   copyTaskConfig(dest.Config),
+	}
+	reverseDest.Config["ConnectionConfig"] = src.Config["ConnectionConfig"]
+	delete(reverseDest.Config, "SchemaHistory")
+	if dest.NodeName == "" && dest.NodeID != "" || src.NodeName == "" && src.NodeID != "" {
+		warnings = append(warnings, "the node IDs of the tasks are left out, set their node_name to place them on given nodes")
+	}
+
+	id := models.GenerateUUID()
+	reverse := &api.Job{
+		ID:          &id,
+		Name:        &name,
+		Labels:      job.Labels,
+		Namespace:   job.Namespace,
+		Region:      job.Region,
+		Orders:      job.Orders,
+		Failover:    job.Failover,
+		Restart:     job.Restart,
+		Reschedule:  job.Reschedule,
+		Alert:       job.Alert,
+		Type:        job.Type,
+		Datacenters: job.Datacenters,
+		Tasks:       []*api.Task{reverseSrc, reverseDest},
+	}
+	return reverse, warnings, nil
+}
+
+// copyTaskConfig returns a shallow copy of the config of a task
+func copyTaskConfig(cfg map[string]interface{}) map[string]interface{} {
+	c := make(map[string]interface{}, len(cfg))
+	for k, v := range cfg {
+		c[k] = v
+	}
+	return c
+}
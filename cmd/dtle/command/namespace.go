@@ -0,0 +1,37 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package command
+
+import (
+	"strings"
+
+	"github.com/mitchellh/cli"
+)
+
+type NamespaceCommand struct {
+	Meta
+}
+
+func (c *NamespaceCommand) Help() string {
+	helpText := `
+Usage: dtle namespace <subcommand> [options]
+
+  Manages the namespaces of the cluster. Namespaces let several teams share
+  a cluster: job names are unique within a namespace and a quota can limit
+  the jobs of a namespace. Jobs select their namespace with the "namespace"
+  key of the job specification.
+`
+	return strings.TrimSpace(helpText)
+}
+
+func (c *NamespaceCommand) Synopsis() string {
+	return "Manage the namespaces of the cluster"
+}
+
+func (c *NamespaceCommand) Run(args []string) int {
+	return cli.RunResultHelp
+}
@@ -0,0 +1,97 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package command
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/actiontech/dtle/api"
+)
+
+type NamespaceApplyCommand struct {
+	Meta
+}
+
+func (c *NamespaceApplyCommand) Help() string {
+	helpText := `
+Usage: dtle namespace apply [options] <namespace>
+
+  Creates or updates a namespace.
+
+General Options:
+
+  ` + generalOptionsUsage() + `
+
+Apply Options:
+
+  -description
+    An optional human readable description of the namespace.
+
+  -max-jobs
+    The number of jobs of the namespace that may be pending or running at
+    the same time. Defaults to 0, which is unlimited.
+
+  -max-bandwidth
+    The aggregate replication bandwidth of the jobs of the namespace, in
+    bytes per second. It is split evenly between the running source tasks
+    of the namespace. Defaults to 0, which is unlimited.
+`
+	return strings.TrimSpace(helpText)
+}
+
+func (c *NamespaceApplyCommand) Synopsis() string {
+	return "Create or update a namespace"
+}
+
+func (c *NamespaceApplyCommand) Run(args []string) int {
+	var description string
+	var maxJobs int
+	var maxBandwidth int64
+
+	flags := c.Meta.FlagSet("namespace apply", FlagSetClient)
+	flags.Usage = func() { c.Ui.Output(c.Help()) }
+	flags.StringVar(&description, "description", "", "")
+	flags.IntVar(&maxJobs, "max-jobs", 0, "")
+	flags.Int64Var(&maxBandwidth, "max-bandwidth", 0, "")
+
+	if err := flags.Parse(args); err != nil {
+		return 1
+	}
+
+	// Check that we got exactly one namespace
+	args = flags.Args()
+	if len(args) != 1 {
+		c.Ui.Error(c.Help())
+		return 1
+	}
+
+	// Get the HTTP client
+	client, err := c.Meta.Client()
+	if err != nil {
+		c.Ui.Error(fmt.Sprintf("Error initializing client: %s", err))
+		return 1
+	}
+
+	ns := &api.Namespace{
+		Name:        args[0],
+		Description: description,
+	}
+	if maxJobs != 0 || maxBandwidth != 0 {
+		ns.Quota = &api.NamespaceQuota{
+			MaxJobs:      maxJobs,
+			MaxBandwidth: maxBandwidth,
+		}
+	}
+	if _, err := client.Namespaces().Register(ns, nil); err != nil {
+		c.Ui.Error(fmt.Sprintf("Error applying namespace: %s", err))
+		return 1
+	}
+
+	c.Ui.Output(fmt.Sprintf("Successfully applied namespace %q", ns.Name))
+	return 0
+}
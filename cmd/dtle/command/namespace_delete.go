@@ -0,0 +1,64 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package command
+
+import (
+	"fmt"
+	"strings"
+)
+
+type NamespaceDeleteCommand struct {
+	Meta
+}
+
+func (c *NamespaceDeleteCommand) Help() string {
+	helpText := `
+Usage: dtle namespace delete [options] <namespace>
+
+  Deletes a namespace. A namespace can only be deleted once all of its jobs
+  have been stopped, and the default namespace can never be deleted.
+
+General Options:
+
+  ` + generalOptionsUsage()
+	return strings.TrimSpace(helpText)
+}
+
+func (c *NamespaceDeleteCommand) Synopsis() string {
+	return "Delete a namespace"
+}
+
+func (c *NamespaceDeleteCommand) Run(args []string) int {
+	flags := c.Meta.FlagSet("namespace delete", FlagSetClient)
+	flags.Usage = func() { c.Ui.Output(c.Help()) }
+
+	if err := flags.Parse(args); err != nil {
+		return 1
+	}
+
+	// Check that we got exactly one namespace
+	args = flags.Args()
+	if len(args) != 1 {
+		c.Ui.Error(c.Help())
+		return 1
+	}
+
+	// Get the HTTP client
+	client, err := c.Meta.Client()
+	if err != nil {
+		c.Ui.Error(fmt.Sprintf("Error initializing client: %s", err))
+		return 1
+	}
+
+	if _, err := client.Namespaces().Delete(args[0], nil); err != nil {
+		c.Ui.Error(fmt.Sprintf("Error deleting namespace: %s", err))
+		return 1
+	}
+
+	c.Ui.Output(fmt.Sprintf("Successfully deleted namespace %q", args[0]))
+	return 0
+}
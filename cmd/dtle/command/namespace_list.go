@@ -0,0 +1,78 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package command
+
+import (
+	"fmt"
+	"strings"
+)
+
+type NamespaceListCommand struct {
+	Meta
+}
+
+func (c *NamespaceListCommand) Help() string {
+	helpText := `
+Usage: dtle namespace list [options]
+
+  Lists the namespaces of the cluster along with their quota.
+
+General Options:
+
+  ` + generalOptionsUsage()
+	return strings.TrimSpace(helpText)
+}
+
+func (c *NamespaceListCommand) Synopsis() string {
+	return "List the namespaces"
+}
+
+func (c *NamespaceListCommand) Run(args []string) int {
+	flags := c.Meta.FlagSet("namespace list", FlagSetClient)
+	flags.Usage = func() { c.Ui.Output(c.Help()) }
+
+	if err := flags.Parse(args); err != nil {
+		return 1
+	}
+	if len(flags.Args()) != 0 {
+		c.Ui.Error(c.Help())
+		return 1
+	}
+
+	// Get the HTTP client
+	client, err := c.Meta.Client()
+	if err != nil {
+		c.Ui.Error(fmt.Sprintf("Error initializing client: %s", err))
+		return 1
+	}
+
+	namespaces, _, err := client.Namespaces().List(nil)
+	if err != nil {
+		c.Ui.Error(fmt.Sprintf("Error querying namespaces: %s", err))
+		return 1
+	}
+	if len(namespaces) == 0 {
+		c.Ui.Output("No namespaces found")
+		return 0
+	}
+
+	out := []string{"Name|Description|Max Jobs|Max Bandwidth"}
+	for _, ns := range namespaces {
+		maxJobs, maxBandwidth := "unlimited", "unlimited"
+		if q := ns.Quota; q != nil {
+			if q.MaxJobs > 0 {
+				maxJobs = fmt.Sprintf("%d", q.MaxJobs)
+			}
+			if q.MaxBandwidth > 0 {
+				maxBandwidth = fmt.Sprintf("%d B/s", q.MaxBandwidth)
+			}
+		}
+		out = append(out, fmt.Sprintf("%s|%s|%s|%s", ns.Name, ns.Description, maxJobs, maxBandwidth))
+	}
+	c.Ui.Output(formatList(out))
+	return 0
+}
@@ -0,0 +1,35 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package command
+
+import (
+	"strings"
+
+	"github.com/mitchellh/cli"
+)
+
+type OperatorCommand struct {
+	Meta
+}
+
+func (c *OperatorCommand) Help() string {
+	helpText := `
+Usage: dtle operator <subcommand> [options]
+
+  Provides cluster-level tools for Dtle operators, such as saving and
+  restoring the server state.
+`
+	return strings.TrimSpace(helpText)
+}
+
+func (c *OperatorCommand) Synopsis() string {
+	return "Provides cluster-level tools for Dtle operators"
+}
+
+func (c *OperatorCommand) Run(args []string) int {
+	return cli.RunResultHelp
+}
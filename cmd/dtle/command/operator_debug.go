@@ -0,0 +1,116 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package command
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+type OperatorDebugCommand struct {
+	Meta
+}
+
+func (c *OperatorDebugCommand) Help() string {
+	helpText := `
+Usage: dtle operator debug [options] <file>
+
+  Captures the diagnostic bundle of the agent given by -address to the given
+  file, a tar.gz of its versions, config, allocations with the depths of
+  their queues, memory stats and heap, goroutine and cpu profiles. With
+  -profile, only the given profile is captured, in the pprof format.
+
+  The agent must set the operator_auth of its http_api block, which is
+  given as the UDUP_HTTP_AUTH environment variable, in the "user:password"
+  form.
+
+General Options:
+
+  ` + generalOptionsUsage() + `
+
+Debug Options:
+
+  -profile=<name>
+    Capture only the given profile: heap, allocs, goroutine, block, mutex,
+    threadcreate or cpu.
+
+  -seconds=<n>
+    How long the cpu profile is captured for. 0 leaves the cpu profile out
+    of the bundle. Defaults to 10.
+`
+	return strings.TrimSpace(helpText)
+}
+
+func (c *OperatorDebugCommand) Synopsis() string {
+	return "Captures the profiles and diagnostics of an agent"
+}
+
+func (c *OperatorDebugCommand) Run(args []string) int {
+	var profile string
+	var seconds int
+
+	flags := c.Meta.FlagSet("operator debug", FlagSetClient)
+	flags.Usage = func() { c.Ui.Output(c.Help()) }
+	flags.StringVar(&profile, "profile", "", "")
+	flags.IntVar(&seconds, "seconds", 10, "")
+
+	if err := flags.Parse(args); err != nil {
+		return 1
+	}
+
+	// Check that we got exactly one file
+	args = flags.Args()
+	if len(args) != 1 {
+		c.Ui.Error(c.Help())
+		return 1
+	}
+	path := args[0]
+
+	// Get the HTTP client
+	client, err := c.Meta.Client()
+	if err != nil {
+		c.Ui.Error(fmt.Sprintf("Error initializing client: %s", err))
+		return 1
+	}
+
+	if profile == "" || profile == "cpu" {
+		c.Ui.Output(fmt.Sprintf("Capturing the cpu profile for %ds", seconds))
+	}
+	var capture io.ReadCloser
+	if profile != "" {
+		capture, err = client.Agent().Profile(profile, seconds)
+	} else {
+		capture, err = client.Agent().DebugBundle(seconds)
+	}
+	if err != nil {
+		c.Ui.Error(fmt.Sprintf("Error capturing diagnostics: %s", err))
+		return 1
+	}
+	defer capture.Close()
+
+	f, err := os.Create(path)
+	if err != nil {
+		c.Ui.Error(fmt.Sprintf("Error creating file: %s", err))
+		return 1
+	}
+	if _, err := io.Copy(f, capture); err != nil {
+		f.Close()
+		os.Remove(path)
+		c.Ui.Error(fmt.Sprintf("Error writing file: %s", err))
+		return 1
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(path)
+		c.Ui.Error(fmt.Sprintf("Error writing file: %s", err))
+		return 1
+	}
+
+	c.Ui.Output(fmt.Sprintf("Saved diagnostics to %q", path))
+	return 0
+}
@@ -0,0 +1,45 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package command
+
+import (
+	"strings"
+
+	"github.com/mitchellh/cli"
+)
+
+type OperatorRaftCommand struct {
+	Meta
+}
+
+func (c *OperatorRaftCommand) Help() string {
+	helpText := `
+Usage: dtle operator raft <subcommand> [options]
+
+  Inspects and maintains the Raft configuration of the Dtle servers.
+
+  List the Raft peers:
+
+      $ dtle operator raft list-peers
+
+  Remove a dead server from the Raft configuration:
+
+      $ dtle operator raft remove-peer -address="IP:port"
+
+  Failed servers can also be removed automatically by the leader with the
+  "cleanup_dead_servers" option of the manager block.
+`
+	return strings.TrimSpace(helpText)
+}
+
+func (c *OperatorRaftCommand) Synopsis() string {
+	return "Inspects and maintains the Raft configuration"
+}
+
+func (c *OperatorRaftCommand) Run(args []string) int {
+	return cli.RunResultHelp
+}
@@ -0,0 +1,81 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package command
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/actiontech/dtle/api"
+)
+
+type OperatorRaftListCommand struct {
+	Meta
+}
+
+func (c *OperatorRaftListCommand) Help() string {
+	helpText := `
+Usage: dtle operator raft list-peers [options]
+
+  Displays the current Raft peer configuration.
+
+General Options:
+
+  ` + generalOptionsUsage() + `
+
+List Peers Options:
+
+  -stale
+    Allow any server to answer the query, even if it is not the leader.
+    This is useful to inspect the peers when the cluster has no leader.
+`
+	return strings.TrimSpace(helpText)
+}
+
+func (c *OperatorRaftListCommand) Synopsis() string {
+	return "Display the current Raft peer configuration"
+}
+
+func (c *OperatorRaftListCommand) Run(args []string) int {
+	var stale bool
+
+	flags := c.Meta.FlagSet("operator raft list-peers", FlagSetClient)
+	flags.Usage = func() { c.Ui.Output(c.Help()) }
+	flags.BoolVar(&stale, "stale", false, "")
+
+	if err := flags.Parse(args); err != nil {
+		return 1
+	}
+
+	// Get the HTTP client
+	client, err := c.Meta.Client()
+	if err != nil {
+		c.Ui.Error(fmt.Sprintf("Error initializing client: %s", err))
+		return 1
+	}
+
+	// Fetch the current configuration.
+	reply, err := client.Operator().RaftGetConfiguration(&api.QueryOptions{AllowStale: stale})
+	if err != nil {
+		c.Ui.Error(fmt.Sprintf("Error getting peers: %v", err))
+		return 1
+	}
+
+	// Format it as a nice table.
+	result := []string{"Node|ID|Address|State|Voter"}
+	for _, s := range reply.Servers {
+		state := "follower"
+		if s.Leader {
+			state = "leader"
+		}
+		result = append(result, fmt.Sprintf("%s|%s|%s|%s|%v",
+			s.Node, s.ID, s.Address, state, s.Voter))
+	}
+	c.Ui.Output(formatList(result))
+
+	return 0
+}
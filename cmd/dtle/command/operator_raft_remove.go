@@ -20,7 +20,7 @@ type OperatorRaftRemoveCommand struct {
 
 func (c *OperatorRaftRemoveCommand) Help() string {
 	helpText := `
-Usage: dtle remove-peer [options]
+Usage: dtle operator raft remove-peer [options]
 
 Remove the Dtle server with given -peer-address from the Raft configuration.
 
@@ -0,0 +1,44 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package command
+
+import (
+	"strings"
+
+	"github.com/mitchellh/cli"
+)
+
+type OperatorSnapshotCommand struct {
+	Meta
+}
+
+func (c *OperatorSnapshotCommand) Help() string {
+	helpText := `
+Usage: dtle operator snapshot <subcommand> [options]
+
+  Saves and restores snapshots of the Dtle server state. A snapshot holds
+  all job definitions and their checkpoints, and can be restored onto a
+  fresh server cluster after a catastrophic loss.
+
+  Save a snapshot of the current state:
+
+      $ dtle operator snapshot save backup.snap
+
+  Restore a snapshot:
+
+      $ dtle operator snapshot restore backup.snap
+`
+	return strings.TrimSpace(helpText)
+}
+
+func (c *OperatorSnapshotCommand) Synopsis() string {
+	return "Saves and restores snapshots of the server state"
+}
+
+func (c *OperatorSnapshotCommand) Run(args []string) int {
+	return cli.RunResultHelp
+}
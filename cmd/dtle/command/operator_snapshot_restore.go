@@ -0,0 +1,77 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package command
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+type OperatorSnapshotRestoreCommand struct {
+	Meta
+}
+
+func (c *OperatorSnapshotRestoreCommand) Help() string {
+	helpText := `
+Usage: dtle operator snapshot restore [options] <file>
+
+  Restores a snapshot taken with "dtle operator snapshot save" onto the
+  Dtle servers. The current server state, including all jobs, is replaced
+  by the contents of the snapshot.
+
+  This is meant for disaster recovery into a fresh server cluster and
+  should not be used in normal operations.
+
+General Options:
+
+  ` + generalOptionsUsage()
+	return strings.TrimSpace(helpText)
+}
+
+func (c *OperatorSnapshotRestoreCommand) Synopsis() string {
+	return "Restores a snapshot of the server state"
+}
+
+func (c *OperatorSnapshotRestoreCommand) Run(args []string) int {
+	flags := c.Meta.FlagSet("operator snapshot restore", FlagSetClient)
+	flags.Usage = func() { c.Ui.Output(c.Help()) }
+
+	if err := flags.Parse(args); err != nil {
+		return 1
+	}
+
+	// Check that we got exactly one file
+	args = flags.Args()
+	if len(args) != 1 {
+		c.Ui.Error(c.Help())
+		return 1
+	}
+	path := args[0]
+
+	f, err := os.Open(path)
+	if err != nil {
+		c.Ui.Error(fmt.Sprintf("Error opening snapshot file: %s", err))
+		return 1
+	}
+	defer f.Close()
+
+	// Get the HTTP client
+	client, err := c.Meta.Client()
+	if err != nil {
+		c.Ui.Error(fmt.Sprintf("Error initializing client: %s", err))
+		return 1
+	}
+
+	if err := client.Operator().SnapshotRestore(f, nil); err != nil {
+		c.Ui.Error(fmt.Sprintf("Error restoring snapshot: %s", err))
+		return 1
+	}
+
+	c.Ui.Output("Restored snapshot")
+	return 0
+}
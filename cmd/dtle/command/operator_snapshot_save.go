@@ -0,0 +1,106 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package command
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/actiontech/dtle/api"
+)
+
+type OperatorSnapshotSaveCommand struct {
+	Meta
+}
+
+func (c *OperatorSnapshotSaveCommand) Help() string {
+	helpText := `
+Usage: dtle operator snapshot save [options] <file>
+
+  Saves a snapshot of the Dtle server state to the given file. The snapshot
+  is taken on the leader unless -stale is given.
+
+General Options:
+
+  ` + generalOptionsUsage() + `
+
+Snapshot Save Options:
+
+  -stale
+    Allow any server to take the snapshot, even if it is not the leader.
+    The snapshot may then miss the latest changes.
+`
+	return strings.TrimSpace(helpText)
+}
+
+func (c *OperatorSnapshotSaveCommand) Synopsis() string {
+	return "Saves a snapshot of the server state"
+}
+
+func (c *OperatorSnapshotSaveCommand) Run(args []string) int {
+	var stale bool
+
+	flags := c.Meta.FlagSet("operator snapshot save", FlagSetClient)
+	flags.Usage = func() { c.Ui.Output(c.Help()) }
+	flags.BoolVar(&stale, "stale", false, "")
+
+	if err := flags.Parse(args); err != nil {
+		return 1
+	}
+
+	// Check that we got exactly one file
+	args = flags.Args()
+	if len(args) != 1 {
+		c.Ui.Error(c.Help())
+		return 1
+	}
+	path := args[0]
+
+	// Get the HTTP client
+	client, err := c.Meta.Client()
+	if err != nil {
+		c.Ui.Error(fmt.Sprintf("Error initializing client: %s", err))
+		return 1
+	}
+
+	snap, err := client.Operator().SnapshotSave(&api.QueryOptions{AllowStale: stale})
+	if err != nil {
+		c.Ui.Error(fmt.Sprintf("Error saving snapshot: %s", err))
+		return 1
+	}
+	defer snap.Close()
+
+	// Write to a temporary file first so a failed save never leaves a
+	// truncated snapshot behind.
+	tmp := path + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		c.Ui.Error(fmt.Sprintf("Error creating snapshot file: %s", err))
+		return 1
+	}
+	if _, err := io.Copy(f, snap); err != nil {
+		f.Close()
+		os.Remove(tmp)
+		c.Ui.Error(fmt.Sprintf("Error writing snapshot file: %s", err))
+		return 1
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(tmp)
+		c.Ui.Error(fmt.Sprintf("Error writing snapshot file: %s", err))
+		return 1
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		c.Ui.Error(fmt.Sprintf("Error writing snapshot file: %s", err))
+		return 1
+	}
+
+	c.Ui.Output(fmt.Sprintf("Saved snapshot to %q", path))
+	return 0
+}
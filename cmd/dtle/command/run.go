@@ -0,0 +1,339 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package command
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	gnatsd "github.com/nats-io/gnatsd/server"
+	stand "github.com/nats-io/nats-streaming-server/server"
+	"github.com/satori/go.uuid"
+
+	"github.com/actiontech/dtle/agent"
+	"github.com/actiontech/dtle/internal/client/driver"
+	"github.com/actiontech/dtle/internal/config"
+	ulog "github.com/actiontech/dtle/internal/logger"
+	"github.com/actiontech/dtle/internal/models"
+)
+
+const (
+	// runCheckpointInterval is how often the GTID set applied by a job run
+	// with "dtle run" is saved to its checkpoint file
+	runCheckpointInterval = 5 * time.Second
+)
+
+// RunCommand runs a single job in the foreground, without the server and
+// the scheduler, for the job to be managed as a container.
+type RunCommand struct {
+	Meta
+}
+
+func (c *RunCommand) Help() string {
+	helpText := `
+Usage: dtle run [options] -job <path>
+
+  Runs the job specification at <path>, or read from stdin if <path> is
+  "-", in the foreground. The source and target tasks of the job are run in
+  this process, connected by an embedded NATS streaming server, with
+  neither a server nor a scheduler: the job is meant to be run as a
+  Kubernetes Job or Deployment, the operator or the orchestrator taking
+  care of its placement and of its restarts.
+
+  The job is stopped on SIGINT or SIGTERM. The exit code is 0 if the job
+  completed or was stopped and 1 if it failed.
+
+Run Options:
+
+  -job
+    The path of the job specification to run. Required.
+
+  -once
+    Exit on the first failure of the job instead of restarting its tasks
+    per the restart policy of the job.
+
+  -nats-addr
+    The address the embedded NATS streaming server listens on. Defaults to
+    "127.0.0.1:8193".
+
+  -checkpoint-file
+    The file the GTID set applied by a MySQL job is saved to, and resumed
+    from when the job is run again. By default the job starts from the
+    Gtid of its spec on each run.
+
+  -log-level
+    The level of the logs of the tasks. Defaults to "INFO".
+`
+	return strings.TrimSpace(helpText)
+}
+
+func (c *RunCommand) Synopsis() string {
+	return "Run a single job in the foreground"
+}
+
+func (c *RunCommand) Run(args []string) int {
+	var jobPath, natsAddr, checkpointFile, logLevel string
+	var once bool
+
+	flags := c.Meta.FlagSet("run", FlagSetNone)
+	flags.Usage = func() { c.Ui.Output(c.Help()) }
+	flags.StringVar(&jobPath, "job", "", "")
+	flags.BoolVar(&once, "once", false, "")
+	flags.StringVar(&natsAddr, "nats-addr", "127.0.0.1:8193", "")
+	flags.StringVar(&checkpointFile, "checkpoint-file", "", "")
+	flags.StringVar(&logLevel, "log-level", "INFO", "")
+
+	if err := flags.Parse(args); err != nil {
+		return 1
+	}
+	if jobPath == "" || len(flags.Args()) != 0 {
+		c.Ui.Error(c.Help())
+		return 1
+	}
+
+	apiJob, err := ReadJobFile(jobPath)
+	if err != nil {
+		c.Ui.Error(fmt.Sprintf("Error reading job file: %s", err))
+		return 1
+	}
+	if apiJob.ID == nil || *apiJob.ID == "" {
+		id := models.GenerateUUID()
+		apiJob.ID = &id
+	} else if _, err := uuid.FromString(*apiJob.ID); err != nil {
+		// The tasks record the transactions they apply under the UUID of
+		// the job: derive a UUID from the ID of the spec that is the same
+		// on each run
+		id := uuid.NewV5(uuid.NamespaceOID, *apiJob.ID).String()
+		apiJob.ID = &id
+	}
+	for _, task := range apiJob.Tasks {
+		if task.Config == nil {
+			task.Config = make(map[string]interface{})
+		}
+	}
+	job := agent.ApiJobToStructJob(apiJob, 0)
+	if err := job.Validate(); err != nil {
+		c.Ui.Error(fmt.Sprintf("Error validating job: %s", err))
+		return 1
+	}
+	if checkpointFile != "" {
+		if err := loadRunCheckpoint(checkpointFile, job); err != nil {
+			c.Ui.Error(fmt.Sprintf("Error reading checkpoint file: %s", err))
+			return 1
+		}
+	}
+
+	clientConfig := config.DefaultClientConfig()
+	clientConfig.NatsAddr = natsAddr
+	clientConfig.LogLevel = logLevel
+	hostname, _ := os.Hostname()
+	clientConfig.Node = &models.Node{
+		ID:         models.GenerateUUID(),
+		Name:       hostname,
+		Attributes: make(map[string]string),
+	}
+	logger := ulog.New(os.Stderr, ulog.ParseLevel(logLevel))
+
+	nats, err := runNatsServer(natsAddr, clientConfig.MaxPayload)
+	if err != nil {
+		c.Ui.Error(fmt.Sprintf("Error starting the NATS streaming server: %s", err))
+		return 1
+	}
+	defer nats.Shutdown()
+	for _, task := range job.Tasks {
+		task.Config["NatsAddr"] = natsAddr
+	}
+
+	signalCh := make(chan os.Signal, 1)
+	signal.Notify(signalCh, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(signalCh)
+
+	restart := job.Restart
+	if restart == nil {
+		restart = models.DefaultRestartPolicy()
+	}
+	var attempts int
+	var intervalStart time.Time
+	for {
+		c.Ui.Output(fmt.Sprintf("Running job %q (%s)", job.Name, job.ID))
+		result, stopped := c.runJob(job, clientConfig, logger, checkpointFile, signalCh)
+		if stopped {
+			c.Ui.Output(fmt.Sprintf("Job %q stopped", job.Name))
+			return 0
+		}
+		if result.Successful() {
+			c.Ui.Output(fmt.Sprintf("Job %q completed", job.Name))
+			return 0
+		}
+		c.Ui.Error(fmt.Sprintf("Job %q failed: %s", job.Name, result))
+		if once {
+			return 1
+		}
+
+		now := time.Now()
+		if intervalStart.IsZero() || now.Sub(intervalStart) > restart.Interval {
+			intervalStart = now
+			attempts = 0
+		}
+		attempts++
+		delay := restart.Backoff(attempts)
+		if attempts > restart.Attempts {
+			if restart.Mode == models.RestartPolicyModeFail {
+				c.Ui.Error(fmt.Sprintf("Job %q failed %d times in %s, giving up", job.Name, attempts, restart.Interval))
+				return 1
+			}
+			delay = intervalStart.Add(restart.Interval).Sub(now)
+			intervalStart = time.Time{}
+		}
+
+		c.Ui.Output(fmt.Sprintf("Restarting job %q in %s", job.Name, delay))
+		select {
+		case <-time.After(delay):
+		case <-signalCh:
+			c.Ui.Output(fmt.Sprintf("Job %q stopped", job.Name))
+			return 0
+		}
+	}
+}
+
+// runJob starts the tasks of a job and waits until one of them exits, or a
+// signal is received, in which case stopped is true. The other tasks are
+// shut down.
+func (c *RunCommand) runJob(job *models.Job, clientConfig *config.ClientConfig, logger *ulog.Logger,
+	checkpointFile string, signalCh <-chan os.Signal) (result *models.WaitResult, stopped bool) {
+	handles := make(map[string]driver.DriverHandle, len(job.Tasks))
+	defer func() {
+		for taskType, handle := range handles {
+			if checkpointFile != "" && taskType == models.TaskTypeDest {
+				c.saveCheckpoint(checkpointFile, handle)
+			}
+			if err := handle.Shutdown(); err != nil {
+				logger.Warnf("run: Failed to shut down task %q: %v", taskType, err)
+			}
+		}
+	}()
+
+	// Start the target first, for it to subscribe before the source
+	// publishes
+	tasks := make([]*models.Task, 0, len(job.Tasks))
+	for _, task := range job.Tasks {
+		if task.Type == models.TaskTypeDest {
+			tasks = append([]*models.Task{task}, tasks...)
+		} else {
+			tasks = append(tasks, task)
+		}
+	}
+	for _, task := range tasks {
+		drv, err := driver.NewDriver(task.Driver,
+			driver.NewDriverContext(task.Type, job.ID, clientConfig, clientConfig.Node, logger))
+		if err != nil {
+			return models.NewWaitResult(1, err), false
+		}
+		handle, err := drv.Start(driver.NewExecContext(job.ID, job.Type, clientConfig.MaxPayload), task)
+		if err != nil {
+			return models.NewWaitResult(1, fmt.Errorf("failed to start task %q: %v", task.Type, err)), false
+		}
+		handles[task.Type] = handle
+	}
+
+	type taskResult struct {
+		taskType string
+		result   *models.WaitResult
+	}
+	resultCh := make(chan taskResult, len(handles))
+	for taskType, handle := range handles {
+		go func(taskType string, handle driver.DriverHandle) {
+			resultCh <- taskResult{taskType, <-handle.WaitCh()}
+		}(taskType, handle)
+	}
+
+	checkpoint := time.NewTicker(runCheckpointInterval)
+	defer checkpoint.Stop()
+	for {
+		select {
+		case r := <-resultCh:
+			delete(handles, r.taskType)
+			if r.result == nil {
+				r.result = models.NewWaitResult(0, nil)
+			}
+			if !r.result.Successful() {
+				return models.NewWaitResult(r.result.ExitCode,
+					fmt.Errorf("task %q: %v", r.taskType, r.result.Err)), false
+			}
+			if len(handles) == 0 {
+				return r.result, false
+			}
+		case <-checkpoint.C:
+			if handle, ok := handles[models.TaskTypeDest]; ok && checkpointFile != "" {
+				c.saveCheckpoint(checkpointFile, handle)
+			}
+		case <-signalCh:
+			return nil, true
+		}
+	}
+}
+
+// saveCheckpoint writes the GTID set applied by the target of a job to its
+// checkpoint file
+func (c *RunCommand) saveCheckpoint(path string, handle driver.DriverHandle) {
+	var id config.DriverCtx
+	if err := json.Unmarshal([]byte(handle.ID()), &id); err != nil || id.DriverConfig == nil ||
+		id.DriverConfig.Gtid == "" {
+		return
+	}
+	tmp := path + ".tmp"
+	if err := ioutil.WriteFile(tmp, []byte(id.DriverConfig.Gtid+"\n"), 0640); err != nil {
+		c.Ui.Warn(fmt.Sprintf("Warning: failed to save the checkpoint: %s", err))
+		return
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		c.Ui.Warn(fmt.Sprintf("Warning: failed to save the checkpoint: %s", err))
+	}
+}
+
+// loadRunCheckpoint sets the GTID set of a checkpoint file as the one the
+// tasks of a job start from. A missing file is not an error.
+func loadRunCheckpoint(path string, job *models.Job) error {
+	buf, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	gtid := strings.TrimSpace(string(buf))
+	if gtid == "" {
+		return nil
+	}
+	for _, task := range job.Tasks {
+		task.Config["Gtid"] = gtid
+	}
+	return nil
+}
+
+// runNatsServer starts the NATS streaming server connecting the tasks of a
+// job run with "dtle run"
+func runNatsServer(addr string, maxPayload int) (*stand.StanServer, error) {
+	natsAddr, err := net.ResolveTCPAddr("tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse NATS address %q: %v", addr, err)
+	}
+	nOpts := gnatsd.Options{
+		Host:       natsAddr.IP.String(),
+		Port:       natsAddr.Port,
+		MaxPayload: maxPayload,
+	}
+	sOpts := stand.GetDefaultOptions()
+	sOpts.ID = config.DefaultClusterID
+	return stand.RunServerWithOpts(sOpts, &nOpts)
+}
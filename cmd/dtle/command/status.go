@@ -11,7 +11,11 @@ import (
 	"sort"
 	"strings"
 
+	"github.com/mitchellh/mapstructure"
+
 	"github.com/actiontech/dtle/api"
+	"github.com/actiontech/dtle/internal/config"
+	"github.com/actiontech/dtle/internal/models"
 )
 
 const (
@@ -24,8 +28,10 @@ type StatusCommand struct {
 	Meta
 	length    int
 	evals     bool
+	events    bool
 	allAllocs bool
 	verbose   bool
+	namespace string
 }
 
 func (c *StatusCommand) Help() string {
@@ -44,6 +50,28 @@ Status Options:
   -evals
     Display the evaluations associated with the job.
 
+  -events
+    Display the timeline of the job, such as placements, snapshot and
+    streaming transitions, pauses and errors.
+
+  -namespace=<name>
+    Only list the jobs of the given namespace.
+
+  -name-prefix=<prefix>
+    Only list the jobs whose name starts with the prefix.
+
+  -status=<status,...>
+    Only list the jobs in one of the comma separated statuses.
+
+  -label=<key=value,...>
+    Only list the jobs having all the comma separated labels.
+
+  -per-page=<n>
+    List at most n jobs, and print the token listing the next ones.
+
+  -next-token=<token>
+    List the jobs from the token printed by a previous -per-page list.
+
   -all-allocs
     Display all allocations matching the job ID, including those from an older
     instance of the job.
@@ -60,12 +88,21 @@ func (c *StatusCommand) Synopsis() string {
 
 func (c *StatusCommand) Run(args []string) int {
 	var short bool
+	var namePrefix, status, labels, nextToken string
+	var perPage int
 
 	flags := c.Meta.FlagSet("status", FlagSetClient)
 	flags.Usage = func() { c.Ui.Output(c.Help()) }
 	flags.BoolVar(&short, "short", false, "")
 	flags.BoolVar(&c.evals, "evals", false, "")
+	flags.BoolVar(&c.events, "events", false, "")
 	flags.BoolVar(&c.allAllocs, "all-allocs", false, "")
+	flags.StringVar(&c.namespace, "namespace", "", "")
+	flags.StringVar(&namePrefix, "name-prefix", "", "")
+	flags.StringVar(&status, "status", "", "")
+	flags.StringVar(&labels, "label", "", "")
+	flags.IntVar(&perPage, "per-page", 0, "")
+	flags.StringVar(&nextToken, "next-token", "", "")
 	flags.BoolVar(&c.verbose, "verbose", false, "")
 
 	if err := flags.Parse(args); err != nil {
@@ -91,7 +128,26 @@ func (c *StatusCommand) Run(args []string) int {
 
 	// Invoke list mode if no job ID.
 	if len(args) == 0 {
-		jobs, _, err := client.Jobs().List(nil)
+		if perPage < 0 {
+			c.Ui.Error("Error: -per-page must not be negative")
+			return 1
+		}
+		q := &api.QueryOptions{
+			Namespace: c.namespace,
+			PerPage:   perPage,
+			NextToken: nextToken,
+			Params:    make(map[string]string),
+		}
+		if namePrefix != "" {
+			q.Params["name_prefix"] = namePrefix
+		}
+		if status != "" {
+			q.Params["status"] = status
+		}
+		if labels != "" {
+			q.Params["label"] = labels
+		}
+		jobs, qm, err := client.Jobs().List(q)
 		if err != nil {
 			c.Ui.Error(fmt.Sprintf("Error querying jobs: %s", err))
 			return 1
@@ -103,6 +159,9 @@ func (c *StatusCommand) Run(args []string) int {
 		} else {
 			c.Ui.Output(createStatusListOutput(jobs))
 		}
+		if qm.NextToken != "" {
+			c.Ui.Output(fmt.Sprintf("\nNext page: -next-token=%s", qm.NextToken))
+		}
 		return 0
 	}
 
@@ -132,10 +191,20 @@ func (c *StatusCommand) Run(args []string) int {
 	basic := []string{
 		fmt.Sprintf("ID|%s", *job.ID),
 		fmt.Sprintf("Name|%s", *job.Name),
+		fmt.Sprintf("Namespace|%s", *job.Namespace),
 		fmt.Sprintf("Type|%s", *job.Type),
 		fmt.Sprintf("Datacenters|%s", strings.Join(job.Datacenters, ",")),
 		fmt.Sprintf("Status|%s", *job.Status),
 	}
+	if mode := applyMode(job); mode != "" {
+		basic = append(basic, fmt.Sprintf("Apply Mode|%s", mode))
+	}
+	if job.Health != nil && *job.Health != "" {
+		basic = append(basic, fmt.Sprintf("Health|%s", *job.Health))
+		if job.HealthDescription != nil && *job.HealthDescription != "" {
+			basic = append(basic, fmt.Sprintf("Health Description|%s", *job.HealthDescription))
+		}
+	}
 
 	c.Ui.Output(formatKV(basic))
 
@@ -152,6 +221,29 @@ func (c *StatusCommand) Run(args []string) int {
 	return 0
 }
 
+// applyMode returns the mode the MySQL target of a job applies the
+// transactions in, as set in its spec
+func applyMode(job *api.Job) string {
+	for _, t := range job.Tasks {
+		if t.Type != models.TaskTypeDest || t.Driver != "" && t.Driver != models.TaskDriverMySQL {
+			continue
+		}
+		var cfg config.MySQLDriverConfig
+		if err := mapstructure.WeakDecode(t.Config, &cfg); err != nil {
+			return ""
+		}
+		if cfg.StrictOrder {
+			return models.ApplyModeStrictOrder
+		}
+		workers := cfg.ParallelWorkers
+		if workers <= 0 {
+			workers = 1
+		}
+		return fmt.Sprintf("%s, %d workers", models.ApplyModeParallel, workers)
+	}
+	return ""
+}
+
 // outputJobInfo prints information about the passed non-periodic job. If a
 // request fails, an error is returned.
 func (c *StatusCommand) outputJobInfo(client *api.Client, job *api.Job) error {
@@ -214,6 +306,12 @@ func (c *StatusCommand) outputJobInfo(client *api.Client, job *api.Job) error {
 		c.outputFailedPlacements(latestFailedPlacement)
 	}
 
+	if c.verbose || c.events {
+		if err := c.outputJobEvents(client, job); err != nil {
+			return err
+		}
+	}
+
 	// Format the allocs
 	c.Ui.Output(c.Colorize().Color("\n[bold]Allocations[reset]"))
 	if len(jobAllocs) > 0 {
@@ -237,6 +335,33 @@ func (c *StatusCommand) outputJobInfo(client *api.Client, job *api.Job) error {
 	return nil
 }
 
+// outputJobEvents displays the timeline of the given job
+func (c *StatusCommand) outputJobEvents(client *api.Client, job *api.Job) error {
+	jobEvents, _, err := client.Jobs().Events(*job.ID, nil)
+	if err != nil {
+		return fmt.Errorf("Error querying job events: %s", err)
+	}
+
+	c.Ui.Output(c.Colorize().Color("\n[bold]Events[reset]"))
+	if len(jobEvents) == 0 {
+		c.Ui.Output("No events recorded")
+		return nil
+	}
+
+	events := make([]string, len(jobEvents)+1)
+	events[0] = "Time|Type|Task|Node ID|Message"
+	for i, event := range jobEvents {
+		events[i+1] = fmt.Sprintf("%s|%s|%s|%s|%s",
+			formatUnixNanoTime(event.Time),
+			event.Type,
+			event.Task,
+			limit(event.NodeID, c.length),
+			event.Message)
+	}
+	c.Ui.Output(formatList(events))
+	return nil
+}
+
 // outputJobSummary displays the given jobs summary and children job summary
 // where appropriate
 func (c *StatusCommand) outputJobSummary(client *api.Client, job *api.Job) error {
@@ -304,12 +429,14 @@ func sortedTaskFromMetrics(groups map[string]*api.AllocationMetric) []string {
 // list general information about a list of jobs
 func createStatusListOutput(jobs []*api.JobListStub) string {
 	out := make([]string, len(jobs)+1)
-	out[0] = "ID|Type|Status"
+	out[0] = "ID|Namespace|Type|Status|Health"
 	for i, job := range jobs {
-		out[i+1] = fmt.Sprintf("%s|%s|%s",
+		out[i+1] = fmt.Sprintf("%s|%s|%s|%s|%s",
 			job.ID,
+			job.Namespace,
 			job.Type,
-			job.Status)
+			job.Status,
+			job.Health)
 	}
 	return formatList(out)
 }
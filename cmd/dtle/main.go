@@ -110,6 +110,131 @@ func realMain() int {
 				Meta: meta,
 			}, nil
 		},*/
+		"operator": func() (cli.Command, error) {
+			return &command.OperatorCommand{
+				Meta: meta,
+			}, nil
+		},
+		"operator debug": func() (cli.Command, error) {
+			return &command.OperatorDebugCommand{
+				Meta: meta,
+			}, nil
+		},
+		"operator raft": func() (cli.Command, error) {
+			return &command.OperatorRaftCommand{
+				Meta: meta,
+			}, nil
+		},
+		"operator raft list-peers": func() (cli.Command, error) {
+			return &command.OperatorRaftListCommand{
+				Meta: meta,
+			}, nil
+		},
+		"operator raft remove-peer": func() (cli.Command, error) {
+			return &command.OperatorRaftRemoveCommand{
+				Meta: meta,
+			}, nil
+		},
+		"operator snapshot": func() (cli.Command, error) {
+			return &command.OperatorSnapshotCommand{
+				Meta: meta,
+			}, nil
+		},
+		"operator snapshot save": func() (cli.Command, error) {
+			return &command.OperatorSnapshotSaveCommand{
+				Meta: meta,
+			}, nil
+		},
+		"operator snapshot restore": func() (cli.Command, error) {
+			return &command.OperatorSnapshotRestoreCommand{
+				Meta: meta,
+			}, nil
+		},
+		"namespace": func() (cli.Command, error) {
+			return &command.NamespaceCommand{
+				Meta: meta,
+			}, nil
+		},
+		"namespace list": func() (cli.Command, error) {
+			return &command.NamespaceListCommand{
+				Meta: meta,
+			}, nil
+		},
+		"namespace apply": func() (cli.Command, error) {
+			return &command.NamespaceApplyCommand{
+				Meta: meta,
+			}, nil
+		},
+		"namespace delete": func() (cli.Command, error) {
+			return &command.NamespaceDeleteCommand{
+				Meta: meta,
+			}, nil
+		},
+		"job": func() (cli.Command, error) {
+			return &command.JobCommand{
+				Meta: meta,
+			}, nil
+		},
+		"job init": func() (cli.Command, error) {
+			return &command.JobInitCommand{
+				Meta: meta,
+			}, nil
+		},
+		"job export": func() (cli.Command, error) {
+			return &command.JobExportCommand{
+				Meta: meta,
+			}, nil
+		},
+		"job import": func() (cli.Command, error) {
+			return &command.JobImportCommand{
+				Meta: meta,
+			}, nil
+		},
+		"job estimate": func() (cli.Command, error) {
+			return &command.JobEstimateCommand{
+				Meta: meta,
+			}, nil
+		},
+		"job cutover": func() (cli.Command, error) {
+			return &command.JobCutoverCommand{
+				Meta: meta,
+			}, nil
+		},
+		"job reverse": func() (cli.Command, error) {
+			return &command.JobReverseCommand{
+				Meta: meta,
+			}, nil
+		},
+		"job clone": func() (cli.Command, error) {
+			return &command.JobCloneCommand{
+				Meta: meta,
+			}, nil
+		},
+		"job plan": func() (cli.Command, error) {
+			return &command.JobPlanCommand{
+				Meta: meta,
+			}, nil
+		},
+		"job replay": func() (cli.Command, error) {
+			return &command.JobReplayCommand{
+				Meta: meta,
+			}, nil
+		},
+		"binlog": func() (cli.Command, error) {
+			return &command.BinlogCommand{
+				Meta: meta,
+			}, nil
+		},
+		"binlog export": func() (cli.Command, error) {
+			return &command.BinlogExportCommand{
+				Meta: meta,
+			}, nil
+		},
+		"run": func() (cli.Command, error) {
+			return &command.RunCommand{
+				Meta: meta,
+			}, nil
+		},
 		"job-status": func() (cli.Command, error) {
 			return &command.StatusCommand{
 				Meta: meta,
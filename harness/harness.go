@@ -0,0 +1,251 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+// Package harness runs the jobs of the tests in their process: a manager
+// and an agent on the loopback address, the sources of the jobs replaced by
+// scripts of rows and events, and their Kafka targets by the in-memory
+// store of the Embedded driver, whose tables the tests check.
+package harness
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/actiontech/dtle/agent"
+	"github.com/actiontech/dtle/api"
+	"github.com/actiontech/dtle/internal/client/driver/kafka3"
+	"github.com/actiontech/dtle/internal/client/driver/script"
+	"github.com/actiontech/dtle/internal/jobspec"
+	ulog "github.com/actiontech/dtle/internal/logger"
+	"github.com/actiontech/dtle/internal/models"
+)
+
+// The script of the source of a job: its tables, their rows copied first,
+// and the events sent then
+type (
+	Script = script.ScriptConfig
+	Table  = script.ScriptTable
+	Column = script.ScriptColumn
+	Event  = script.ScriptEvent
+)
+
+// The operations of the events
+const (
+	OpInsert = script.OpInsert
+	OpUpdate = script.OpUpdate
+	OpDelete = script.OpDelete
+)
+
+// pollInterval is how often the tables and jobs are checked while waiting
+const pollInterval = 100 * time.Millisecond
+
+// Harness is a manager and an agent running in process
+type Harness struct {
+	dataDir string
+	agent   *agent.Agent
+	http    *agent.HTTPServer
+	client  *api.Client
+}
+
+// New starts a manager and an agent on free ports of the loopback address,
+// logging to logOutput, ioutil.Discard if nil. Close stops them.
+func New(logOutput io.Writer) (*Harness, error) {
+	if logOutput == nil {
+		logOutput = ioutil.Discard
+	}
+	dataDir, err := ioutil.TempDir("", "dtle-harness")
+	if err != nil {
+		return nil, err
+	}
+	h := &Harness{dataDir: dataDir}
+	if err := h.start(logOutput); err != nil {
+		h.Close()
+		return nil, err
+	}
+	return h, nil
+}
+
+func (h *Harness) start(logOutput io.Writer) error {
+	ports, err := freePorts(4)
+	if err != nil {
+		return err
+	}
+	config, err := agent.DevConfig(h.dataDir, &agent.Ports{
+		HTTP: ports[0],
+		RPC:  ports[1],
+		Serf: ports[2],
+		Nats: ports[3],
+	})
+	if err != nil {
+		return err
+	}
+	logger := ulog.New(logOutput, ulog.ParseLevel(config.LogLevel))
+	if h.agent, err = agent.NewAgent(config, logOutput, logger); err != nil {
+		return err
+	}
+	if h.http, err = agent.NewHTTPServer(h.agent, config, logOutput); err != nil {
+		return err
+	}
+	h.client, err = api.NewClient(&api.Config{Address: fmt.Sprintf("http://127.0.0.1:%d", ports[0])})
+	if err != nil {
+		return err
+	}
+	// The jobs are scheduled once the manager is the leader and the agent
+	// is ready
+	return h.wait(time.Minute, func() (bool, error) {
+		nodes, _, err := h.client.Nodes().List(nil)
+		return err == nil && len(nodes) > 0 && nodes[0].Status == models.NodeStatusReady, nil
+	})
+}
+
+// freePorts returns n ports free on the loopback address
+func freePorts(n int) ([]int, error) {
+	var ports []int
+	for i := 0; i < n; i++ {
+		l, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			return nil, err
+		}
+		defer l.Close()
+		ports = append(ports, l.Addr().(*net.TCPAddr).Port)
+	}
+	return ports, nil
+}
+
+// Client returns the API client of the harness
+func (h *Harness) Client() *api.Client {
+	return h.client
+}
+
+// Close stops the manager and the agent, and removes their data
+func (h *Harness) Close() error {
+	if h.http != nil {
+		h.http.Shutdown()
+	}
+	var err error
+	if h.agent != nil {
+		err = h.agent.Shutdown()
+	}
+	os.RemoveAll(h.dataDir)
+	return err
+}
+
+// ParseJob parses a job specification in HCL
+func ParseJob(spec string) (*api.Job, error) {
+	return jobspec.Parse(strings.NewReader(spec))
+}
+
+// Mock replaces the source of a job by a script, and its target by the
+// in-memory store of the Embedded driver. The options of a Kafka, Webhook or
+// JetStream target, such as Topic and TopicNaming, are kept, as the records
+// are those they would send.
+func Mock(job *api.Job, s *Script) error {
+	if err := s.Validate(); err != nil {
+		return err
+	}
+	for _, task := range job.Tasks {
+		switch task.Type {
+		case models.TaskTypeSrc:
+			task.Driver = models.TaskDriverScript
+			task.Config = map[string]interface{}{
+				"Tables": s.Tables,
+				"Events": s.Events,
+			}
+		case models.TaskTypeDest:
+			switch task.Driver {
+			case models.TaskDriverKafka, models.TaskDriverWebhook, models.TaskDriverJetStream,
+				models.TaskDriverEmbedded:
+			default:
+				return fmt.Errorf("the %s target of job %s can not be mocked: only those of Kafka records can",
+					task.Driver, *job.Name)
+			}
+			task.Driver = models.TaskDriverEmbedded
+			if task.Config == nil {
+				task.Config = make(map[string]interface{})
+			}
+			for _, key := range []string{"Brokers", "Webhook", "JetStream", "DryRunFile", "TopicCreation"} {
+				delete(task.Config, key)
+			}
+			task.Config["Embedded"] = map[string]interface{}{}
+		}
+	}
+	return nil
+}
+
+// Run registers a job, whose source and target are mocked
+func (h *Harness) Run(job *api.Job) error {
+	_, _, err := h.client.Jobs().Register(job, nil)
+	return err
+}
+
+// Rows returns the rows of the table of a topic, by their key
+func Rows(topic string) (map[string]json.RawMessage, error) {
+	return kafka3.EmbeddedTable("", topic)
+}
+
+// WaitForRows waits until the table of a topic has n rows, and returns
+// them. It fails once timeout elapses, or if a job is dead.
+func (h *Harness) WaitForRows(topic string, n int, timeout time.Duration) (map[string]json.RawMessage, error) {
+	rows, err := h.WaitForTable(topic, timeout, func(rows map[string]json.RawMessage) bool {
+		return len(rows) == n
+	})
+	if err != nil {
+		return rows, fmt.Errorf("waiting for %d rows of %s, got %d: %v", n, topic, len(rows), err)
+	}
+	return rows, nil
+}
+
+// WaitForTable waits until done returns true for the rows of the table of a
+// topic, and returns them. It fails once timeout elapses, or if a job is
+// dead.
+func (h *Harness) WaitForTable(topic string, timeout time.Duration,
+	done func(rows map[string]json.RawMessage) bool) (map[string]json.RawMessage, error) {
+	var rows map[string]json.RawMessage
+	err := h.wait(timeout, func() (bool, error) {
+		var err error
+		if rows, err = Rows(topic); err != nil {
+			return false, err
+		}
+		if done(rows) {
+			return true, nil
+		}
+		jobs, _, err := h.client.Jobs().List(nil)
+		if err != nil {
+			return false, nil
+		}
+		for _, job := range jobs {
+			if job.Status == models.JobStatusDead {
+				return false, fmt.Errorf("job %s is dead: %s", job.Name, job.StatusDescription)
+			}
+		}
+		return false, nil
+	})
+	return rows, err
+}
+
+// wait calls done until it returns true or an error, or timeout elapses
+func (h *Harness) wait(timeout time.Duration, done func() (bool, error)) error {
+	deadline := time.Now().Add(timeout)
+	for {
+		ok, err := done()
+		if err != nil {
+			return err
+		}
+		if ok {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("timeout after %v", timeout)
+		}
+		time.Sleep(pollInterval)
+	}
+}
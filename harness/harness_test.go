@@ -0,0 +1,98 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package harness
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+const testJob = `
+job "harness" {
+  task "Src" {
+    driver = "MySQL"
+    config {
+      connection_config {
+        host = "127.0.0.1"
+      }
+    }
+  }
+
+  task "Dest" {
+    driver = "Kafka"
+    config {
+      brokers = ["127.0.0.1:9092"]
+      topic   = "harness"
+    }
+  }
+}
+`
+
+func TestHarness(t *testing.T) {
+	h, err := New(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer h.Close()
+
+	job, err := ParseJob(testJob)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = Mock(job, &Script{
+		Tables: []*Table{{
+			Schema: "shop",
+			Table:  "orders",
+			Columns: []*Column{
+				{Name: "id", Type: "bigint", Key: true},
+				{Name: "status", Type: "varchar(16)"},
+			},
+			Rows: []map[string]interface{}{
+				{"id": 1, "status": "new"},
+				{"id": 2, "status": "new"},
+			},
+		}},
+		Events: []*Event{
+			{Schema: "shop", Table: "orders", Op: OpInsert, After: map[string]interface{}{"id": 3, "status": "new"}},
+			{Schema: "shop", Table: "orders", Op: OpUpdate,
+				Before: map[string]interface{}{"id": 1, "status": "new"},
+				After:  map[string]interface{}{"id": 1, "status": "paid"}},
+			{Schema: "shop", Table: "orders", Op: OpDelete, Before: map[string]interface{}{"id": 2, "status": "new"}},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := h.Run(job); err != nil {
+		t.Fatal(err)
+	}
+
+	rows, err := h.WaitForRows("harness.shop.orders", 2, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the events update the first row, insert a third and delete the second
+	want := map[string]string{
+		`{"id":1}`: `{"id":1,"status":"paid"}`,
+		`{"id":3}`: `{"id":3,"status":"new"}`,
+	}
+	rows, err = h.WaitForTable("harness.shop.orders", time.Minute, func(rows map[string]json.RawMessage) bool {
+		if len(rows) != len(want) {
+			return false
+		}
+		for k, v := range want {
+			if string(rows[k]) != v {
+				return false
+			}
+		}
+		return true
+	})
+	if err != nil {
+		t.Fatalf("%v: %s", err, rows)
+	}
+}
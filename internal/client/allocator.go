@@ -507,6 +507,29 @@ func (r *Allocator) StatsReporter() AllocStatsReporter {
 	return r
 }
 
+// Checkpoint stops the tasks of the allocation and saves the positions they
+// stopped at, for them to resume from there when the agent restarts
+func (r *Allocator) Checkpoint() error {
+	var mErr multierror.Error
+	var lock sync.Mutex
+	var wg sync.WaitGroup
+	// The tasks drain at once, so that the shutdown is bounded by the drain
+	// timeout rather than by the number of tasks.
+	for _, tr := range r.getWorkers() {
+		wg.Add(1)
+		go func(tr *Worker) {
+			defer wg.Done()
+			if err := tr.Checkpoint(); err != nil {
+				lock.Lock()
+				mErr.Errors = append(mErr.Errors, err)
+				lock.Unlock()
+			}
+		}(tr)
+	}
+	wg.Wait()
+	return mErr.ErrorOrNil()
+}
+
 // getWorkers is a helper that returns a copy of the task runners list using
 // the taskLock.
 func (r *Allocator) getWorkers() []*Worker {
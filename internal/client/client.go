@@ -71,9 +71,16 @@ const (
 	// are synced with the server.
 	allocSyncIntv = 200 * time.Millisecond
 
+	// checkpointSyncTimeout is how long the client waits for the positions
+	// of its tasks to be synced with the servers when it shuts down
+	checkpointSyncTimeout = 10 * time.Second
+
 	// allocSyncRetryIntv is the interval on which we retry updating
 	// the status of the allocation
 	allocSyncRetryIntv = 5 * time.Second
+
+	// natsCheckTimeout bounds the connection made to check the NATS server
+	natsCheckTimeout = time.Second
 )
 
 // ClientStatsReporter exposes all the APIs related to resource usage of a Udup
@@ -132,6 +139,10 @@ type Client struct {
 
 	workUpdates chan *models.TaskUpdate
 
+	// allocSyncDoneCh is closed once the last updates are synced with the
+	// servers on shutdown
+	allocSyncDoneCh chan struct{}
+
 	stand *stand.StanServer
 
 	shutdown     bool
@@ -186,6 +197,7 @@ func NewClient(cfg *config.ClientConfig, logger *ulog.Logger) (*Client, error) {
 		blockedAllocations:  make(map[string]*models.Allocation),
 		allocUpdates:        make(chan *models.Allocation, 64),
 		workUpdates:         make(chan *models.TaskUpdate, 64),
+		allocSyncDoneCh:     make(chan struct{}),
 		shutdownCh:          make(chan struct{}),
 		migratingAllocs:     make(map[string]*migrateAllocCtrl),
 		servers:             newServerList(),
@@ -236,6 +248,9 @@ func NewClient(cfg *config.ClientConfig, logger *ulog.Logger) (*Client, error) {
 	// Begin syncing allocations to the server
 	go c.allocSync()
 
+	// Apply the namespace quotas to the tasks
+	go c.watchQuotas()
+
 	// Start the client!
 	go c.run()
 
@@ -300,13 +315,38 @@ func (c *Client) Shutdown() error {
 		return nil
 	}
 
+	// Stop the tasks and sync the positions they stopped at, for them to
+	// resume from there rather than from the last periodic snapshot
+	c.checkpointAllocs()
+
 	c.stand.Shutdown()
 	c.shutdown = true
 	close(c.shutdownCh)
+	select {
+	case <-c.allocSyncDoneCh:
+	case <-time.After(checkpointSyncTimeout):
+		c.logger.Warnf("agent: Timed out syncing the positions of the tasks with the servers")
+	}
 	c.connPool.Shutdown()
 	return c.saveState()
 }
 
+// checkpointAllocs stops the tasks of the allocations and saves the
+// positions they stopped at
+func (c *Client) checkpointAllocs() {
+	var wg sync.WaitGroup
+	for id, ar := range c.getAllocRunners() {
+		wg.Add(1)
+		go func(id string, ar *Allocator) {
+			defer wg.Done()
+			if err := ar.Checkpoint(); err != nil {
+				c.logger.Errorf("agent: Failed to checkpoint alloc %s: %v", id, err)
+			}
+		}(id, ar)
+	}
+	wg.Wait()
+}
+
 // RPC is used to forward an RPC call to a server server, or fail if no servers.
 func (c *Client) RPC(method string, args interface{}, reply interface{}) error {
 	// Invoke the RPCHandler if it exists
@@ -358,6 +398,25 @@ func (c *Client) Stats() map[string]map[string]string {
 	return stats
 }
 
+// CheckNats returns an error if the NATS streaming server the tasks send
+// their messages through has failed or does not accept connections
+func (c *Client) CheckNats() error {
+	if c.stand == nil {
+		return fmt.Errorf("nats streaming server not started")
+	}
+	if err := c.stand.LastError(); err != nil {
+		return err
+	}
+	if state := c.stand.State(); state == stand.Failed || state == stand.Shutdown {
+		return fmt.Errorf("nats streaming server is %v", state)
+	}
+	conn, err := net.DialTimeout("tcp", c.config.NatsAddr, natsCheckTimeout)
+	if err != nil {
+		return err
+	}
+	return conn.Close()
+}
+
 // Node returns the locally registered node
 func (c *Client) Node() *models.Node {
 	c.configLock.RLock()
@@ -391,6 +450,17 @@ func (c *Client) GetClientAlloc(allocID string) (*models.Allocation, error) {
 	return alloc, nil
 }
 
+// Allocs returns the allocations of the client, ordered by ID
+func (c *Client) Allocs() []*models.Allocation {
+	all := c.allAllocs()
+	allocs := make([]*models.Allocation, 0, len(all))
+	for _, alloc := range all {
+		allocs = append(allocs, alloc)
+	}
+	sort.Slice(allocs, func(i, j int) bool { return allocs[i].ID < allocs[j].ID })
+	return allocs
+}
+
 // GetServers returns the list of server servers this client is aware of.
 func (c *Client) GetServers() []string {
 	endpoints := c.servers.all()
@@ -553,14 +623,16 @@ func (c *Client) setupNatsServer() error {
 func (c *Client) setupDrivers() error {
 	var avail []string
 	driverCtx := driver.NewDriverContext("", "", c.config, c.config.Node, c.logger)
-	for name := range driver.BuiltinDrivers {
-		_, err := driver.NewDriver(name, driverCtx)
-		if err != nil {
-			return err
-		}
+	for _, drivers := range []map[string]driver.Factory{driver.BuiltinDrivers, driver.PluginDrivers} {
+		for name := range drivers {
+			_, err := driver.NewDriver(name, driverCtx)
+			if err != nil {
+				return err
+			}
 
-		avail = append(avail, name)
-		c.config.Node.Attributes[fmt.Sprintf("driver.%s", name)] = "1"
+			avail = append(avail, name)
+			c.config.Node.Attributes[fmt.Sprintf("driver.%s", name)] = "1"
+		}
 	}
 
 	c.logger.Debugf("agent: Available drivers %v", avail)
@@ -831,6 +903,8 @@ func (c *Client) allocSync() {
 		select {
 		case <-c.shutdownCh:
 			syncTicker.Stop()
+			c.syncLastJobUpdates(jUpdates)
+			close(c.allocSyncDoneCh)
 			return
 		case alloc := <-c.allocUpdates:
 			// Batch the allocation updates until the timer triggers.
@@ -902,6 +976,36 @@ func (c *Client) allocSync() {
 	}
 }
 
+// syncLastJobUpdates syncs the pending job updates, with the positions the
+// tasks stopped at, with the servers on shutdown
+func (c *Client) syncLastJobUpdates(jUpdates map[string]*models.TaskUpdate) {
+	for {
+		select {
+		case update := <-c.workUpdates:
+			jUpdates[update.JobID] = update
+			continue
+		default:
+		}
+		break
+	}
+	if len(jUpdates) == 0 {
+		return
+	}
+
+	sync := make([]*models.TaskUpdate, 0, len(jUpdates))
+	for _, ju := range jUpdates {
+		sync = append(sync, ju)
+	}
+	args := models.JobUpdateRequest{
+		JobUpdates:   sync,
+		WriteRequest: models.WriteRequest{Region: c.Region()},
+	}
+	var resp models.GenericResponse
+	if err := c.RPC("Node.UpdateJob", &args, &resp); err != nil {
+		c.logger.Errorf("agent: Failed to sync the positions of the tasks: %v", err)
+	}
+}
+
 type jobUpdates struct {
 	pulled map[string]string
 }
@@ -0,0 +1,74 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package bulkload
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"unicode/utf8"
+
+	"github.com/actiontech/dtle/internal/config"
+)
+
+const defaultNull = `\N`
+
+// csvReader reads the rows of a CSV file, quoted as of RFC 4180
+type csvReader struct {
+	file    io.Closer
+	r       *csv.Reader
+	columns []string
+	null    string
+}
+
+func newCSVReader(rc io.ReadCloser, t *config.BulkLoadTable) (*csvReader, error) {
+	r := &csvReader{
+		file:    rc,
+		r:       csv.NewReader(rc),
+		columns: t.Columns,
+		null:    t.Null,
+	}
+	if t.Delimiter != "" {
+		r.r.Comma, _ = utf8.DecodeRuneInString(t.Delimiter)
+	}
+	if r.null == "" {
+		r.null = defaultNull
+	}
+	if len(r.columns) == 0 {
+		header, err := r.r.Read()
+		if err == io.EOF {
+			return nil, fmt.Errorf("no header line naming the columns")
+		} else if err != nil {
+			return nil, err
+		}
+		r.columns = header
+	}
+	r.r.FieldsPerRecord = len(r.columns)
+	return r, nil
+}
+
+func (r *csvReader) Columns() []string {
+	return r.columns
+}
+
+func (r *csvReader) Next() ([][]byte, error) {
+	record, err := r.r.Read()
+	if err != nil {
+		return nil, err
+	}
+	values := make([][]byte, len(record))
+	for i, v := range record {
+		if v != r.null {
+			values[i] = []byte(v)
+		}
+	}
+	return values, nil
+}
+
+func (r *csvReader) Close() error {
+	return r.file.Close()
+}
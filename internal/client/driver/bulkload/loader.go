@@ -0,0 +1,322 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+// Package bulkload reads the rows of the files a snapshot is loaded from,
+// CSV or Parquet, local or on S3.
+package bulkload
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3"
+	gomysql "github.com/siddontang/go-mysql/mysql"
+
+	"github.com/actiontech/dtle/internal/config"
+)
+
+const s3Scheme = "s3://"
+
+// Reader reads the rows of a file, as the text MySQL reads values from
+type Reader interface {
+	// Columns are the names of the values of the rows
+	Columns() []string
+	// Next returns the values of the next row, nil for NULL, or io.EOF
+	// after the last row
+	Next() ([][]byte, error)
+	Close() error
+}
+
+// Validate checks the config of a bulk load
+func Validate(cfg *config.BulkLoadConfig) error {
+	if cfg.Gtid == "" {
+		return fmt.Errorf("BulkLoad: the Gtid of the export is required")
+	}
+	if _, err := gomysql.ParseMysqlGTIDSet(cfg.Gtid); err != nil {
+		return fmt.Errorf("BulkLoad: invalid Gtid %q: %v", cfg.Gtid, err)
+	}
+	if len(cfg.Tables) == 0 {
+		return fmt.Errorf("BulkLoad: no table to load")
+	}
+	seen := make(map[string]bool)
+	for _, t := range cfg.Tables {
+		if t.Schema == "" || t.Table == "" {
+			return fmt.Errorf("BulkLoad: Schema and Table are required")
+		}
+		name := fmt.Sprintf("%s.%s", t.Schema, t.Table)
+		if seen[name] {
+			return fmt.Errorf("BulkLoad: table %s is loaded more than once", name)
+		}
+		seen[name] = true
+		if len(t.Files) == 0 {
+			return fmt.Errorf("BulkLoad: no file to load %s from", name)
+		}
+		switch t.Format {
+		case "", config.BulkLoadFormatCSV:
+		case config.BulkLoadFormatParquet:
+			if len(t.Columns) > 0 || t.Delimiter != "" || t.Null != "" {
+				return fmt.Errorf("BulkLoad: Columns, Delimiter and Null of %s are for CSV files", name)
+			}
+		default:
+			return fmt.Errorf("BulkLoad: unknown Format %q of %s: must be %q or %q", t.Format, name,
+				config.BulkLoadFormatCSV, config.BulkLoadFormatParquet)
+		}
+		if t.Delimiter != "" && utf8.RuneCountInString(t.Delimiter) != 1 {
+			return fmt.Errorf("BulkLoad: the Delimiter of %s must be one character", name)
+		}
+	}
+	return nil
+}
+
+// Loader lists and opens the files of the tables
+type Loader struct {
+	cfg *config.BulkLoadConfig
+
+	// s3Lock guards s3Client, created when first needed
+	s3Lock   sync.Mutex
+	s3Client *s3.S3
+}
+
+func NewLoader(cfg *config.BulkLoadConfig) *Loader {
+	return &Loader{cfg: cfg}
+}
+
+// Files returns the files of a table, with the glob patterns and the S3
+// directories expanded
+func (l *Loader) Files(t *config.BulkLoadTable) ([]string, error) {
+	var files []string
+	for _, name := range t.Files {
+		if !strings.HasPrefix(name, s3Scheme) {
+			matches, err := filepath.Glob(name)
+			if err != nil {
+				return nil, err
+			}
+			if len(matches) == 0 {
+				return nil, fmt.Errorf("no file matches %s", name)
+			}
+			sort.Strings(matches)
+			files = append(files, matches...)
+			continue
+		}
+
+		bucket, key := splitS3URL(name)
+		if !strings.HasSuffix(key, "/") {
+			files = append(files, name)
+			continue
+		}
+		client, err := l.s3()
+		if err != nil {
+			return nil, err
+		}
+		var keys []string
+		err = client.ListObjectsV2Pages(&s3.ListObjectsV2Input{
+			Bucket: aws.String(bucket),
+			Prefix: aws.String(key),
+		}, func(page *s3.ListObjectsV2Output, lastPage bool) bool {
+			for _, object := range page.Contents {
+				if !strings.HasSuffix(*object.Key, "/") {
+					keys = append(keys, *object.Key)
+				}
+			}
+			return true
+		})
+		if err != nil {
+			return nil, fmt.Errorf("list %s: %v", name, err)
+		}
+		if len(keys) == 0 {
+			return nil, fmt.Errorf("no file under %s", name)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			files = append(files, s3Scheme+bucket+"/"+k)
+		}
+	}
+	return files, nil
+}
+
+// Open opens a file of a table, as returned by Files
+func (l *Loader) Open(t *config.BulkLoadTable, name string) (Reader, error) {
+	format, err := fileFormat(t, name)
+	if err != nil {
+		return nil, err
+	}
+
+	if format == config.BulkLoadFormatParquet {
+		var r readerAtCloser
+		var size int64
+		if strings.HasPrefix(name, s3Scheme) {
+			client, err := l.s3()
+			if err != nil {
+				return nil, err
+			}
+			bucket, key := splitS3URL(name)
+			object, err := client.HeadObject(&s3.HeadObjectInput{Bucket: aws.String(bucket), Key: aws.String(key)})
+			if err != nil {
+				return nil, fmt.Errorf("open %s: %v", name, err)
+			}
+			r = &s3ReaderAt{client: client, bucket: bucket, key: key}
+			size = aws.Int64Value(object.ContentLength)
+		} else {
+			f, err := os.Open(name)
+			if err != nil {
+				return nil, err
+			}
+			info, err := f.Stat()
+			if err != nil {
+				f.Close()
+				return nil, err
+			}
+			r = f
+			size = info.Size()
+		}
+		pr, err := newParquetReader(r, size)
+		if err != nil {
+			r.Close()
+			return nil, fmt.Errorf("read %s: %v", name, err)
+		}
+		return pr, nil
+	}
+
+	var rc io.ReadCloser
+	if strings.HasPrefix(name, s3Scheme) {
+		client, err := l.s3()
+		if err != nil {
+			return nil, err
+		}
+		bucket, key := splitS3URL(name)
+		object, err := client.GetObject(&s3.GetObjectInput{Bucket: aws.String(bucket), Key: aws.String(key)})
+		if err != nil {
+			return nil, fmt.Errorf("open %s: %v", name, err)
+		}
+		rc = object.Body
+	} else {
+		if rc, err = os.Open(name); err != nil {
+			return nil, err
+		}
+	}
+	if strings.HasSuffix(name, ".gz") {
+		zr, err := gzip.NewReader(rc)
+		if err != nil {
+			rc.Close()
+			return nil, fmt.Errorf("read %s: %v", name, err)
+		}
+		rc = &gzipReadCloser{Reader: zr, file: rc}
+	}
+	cr, err := newCSVReader(rc, t)
+	if err != nil {
+		rc.Close()
+		return nil, fmt.Errorf("read %s: %v", name, err)
+	}
+	return cr, nil
+}
+
+// fileFormat returns the format of a file of a table, that of the table or
+// else of its extension
+func fileFormat(t *config.BulkLoadTable, name string) (string, error) {
+	if t.Format != "" {
+		return t.Format, nil
+	}
+	switch path.Ext(strings.TrimSuffix(name, ".gz")) {
+	case ".csv":
+		return config.BulkLoadFormatCSV, nil
+	case ".parquet":
+		if strings.HasSuffix(name, ".gz") {
+			return "", fmt.Errorf("gzipped Parquet file %s: Parquet files are compressed within", name)
+		}
+		return config.BulkLoadFormatParquet, nil
+	default:
+		return "", fmt.Errorf("unknown format of %s: set the Format of %s.%s", name, t.Schema, t.Table)
+	}
+}
+
+func (l *Loader) s3() (*s3.S3, error) {
+	l.s3Lock.Lock()
+	defer l.s3Lock.Unlock()
+	if l.s3Client != nil {
+		return l.s3Client, nil
+	}
+	awsConfig := &aws.Config{}
+	if l.cfg.S3 != nil {
+		if l.cfg.S3.Region != "" {
+			awsConfig.Region = aws.String(l.cfg.S3.Region)
+		}
+		if l.cfg.S3.Endpoint != "" {
+			awsConfig.Endpoint = aws.String(l.cfg.S3.Endpoint)
+			awsConfig.S3ForcePathStyle = aws.Bool(true)
+		}
+	}
+	sess, err := session.NewSessionWithOptions(session.Options{
+		Config:            *awsConfig,
+		SharedConfigState: session.SharedConfigEnable,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("S3 session: %v", err)
+	}
+	l.s3Client = s3.New(sess)
+	return l.s3Client, nil
+}
+
+// splitS3URL returns the bucket and key of an s3://bucket/key URL
+func splitS3URL(name string) (bucket, key string) {
+	parts := strings.SplitN(strings.TrimPrefix(name, s3Scheme), "/", 2)
+	if len(parts) == 1 {
+		return parts[0], ""
+	}
+	return parts[0], parts[1]
+}
+
+type readerAtCloser interface {
+	io.ReaderAt
+	io.Closer
+}
+
+// s3ReaderAt reads an S3 object by ranges, for a Parquet file to be read
+// from its footer on
+type s3ReaderAt struct {
+	client *s3.S3
+	bucket string
+	key    string
+}
+
+func (r *s3ReaderAt) ReadAt(p []byte, off int64) (int, error) {
+	if len(p) == 0 {
+		return 0, nil
+	}
+	object, err := r.client.GetObject(&s3.GetObjectInput{
+		Bucket: aws.String(r.bucket),
+		Key:    aws.String(r.key),
+		Range:  aws.String(fmt.Sprintf("bytes=%d-%d", off, off+int64(len(p))-1)),
+	})
+	if err != nil {
+		return 0, err
+	}
+	defer object.Body.Close()
+	return io.ReadFull(object.Body, p)
+}
+
+func (r *s3ReaderAt) Close() error {
+	return nil
+}
+
+type gzipReadCloser struct {
+	*gzip.Reader
+	file io.Closer
+}
+
+func (r *gzipReadCloser) Close() error {
+	r.Reader.Close()
+	return r.file.Close()
+}
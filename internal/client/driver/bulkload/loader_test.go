@@ -0,0 +1,161 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package bulkload
+
+import (
+	"compress/gzip"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/actiontech/dtle/internal/config"
+)
+
+const testGtid = "3e11fa47-71ca-11e1-9e33-c80aa9429562:1-42"
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     *config.BulkLoadConfig
+		wantErr bool
+	}{
+		{"csv and parquet", &config.BulkLoadConfig{Gtid: testGtid, Tables: []*config.BulkLoadTable{
+			{Schema: "shop", Table: "orders", Files: []string{"/export/orders-*.csv.gz"}, Delimiter: "\t"},
+			{Schema: "shop", Table: "items", Files: []string{"s3://exports/items/"}, Format: "parquet"},
+		}}, false},
+		{"no gtid", &config.BulkLoadConfig{Tables: []*config.BulkLoadTable{
+			{Schema: "shop", Table: "orders", Files: []string{"orders.csv"}},
+		}}, true},
+		{"invalid gtid", &config.BulkLoadConfig{Gtid: "42", Tables: []*config.BulkLoadTable{
+			{Schema: "shop", Table: "orders", Files: []string{"orders.csv"}},
+		}}, true},
+		{"no file", &config.BulkLoadConfig{Gtid: testGtid, Tables: []*config.BulkLoadTable{
+			{Schema: "shop", Table: "orders"},
+		}}, true},
+		{"twice the same table", &config.BulkLoadConfig{Gtid: testGtid, Tables: []*config.BulkLoadTable{
+			{Schema: "shop", Table: "orders", Files: []string{"a.csv"}},
+			{Schema: "shop", Table: "orders", Files: []string{"b.csv"}},
+		}}, true},
+		{"csv options of parquet", &config.BulkLoadConfig{Gtid: testGtid, Tables: []*config.BulkLoadTable{
+			{Schema: "shop", Table: "orders", Files: []string{"orders"}, Format: "parquet", Null: "NULL"},
+		}}, true},
+		{"long delimiter", &config.BulkLoadConfig{Gtid: testGtid, Tables: []*config.BulkLoadTable{
+			{Schema: "shop", Table: "orders", Files: []string{"orders.csv"}, Delimiter: "||"},
+		}}, true},
+	}
+	for _, tt := range tests {
+		if err := Validate(tt.cfg); (err != nil) != tt.wantErr {
+			t.Errorf("%s: Validate() error = %v, wantErr %v", tt.name, err, tt.wantErr)
+		}
+	}
+}
+
+func readAll(t *testing.T, r Reader) [][]string {
+	defer r.Close()
+	var rows [][]string
+	for {
+		row, err := r.Next()
+		if err == io.EOF {
+			return rows
+		} else if err != nil {
+			t.Fatal(err)
+		}
+		var values []string
+		for _, v := range row {
+			if v == nil {
+				values = append(values, "NULL")
+			} else {
+				values = append(values, string(v))
+			}
+		}
+		rows = append(rows, values)
+	}
+}
+
+func TestCSV(t *testing.T) {
+	dir, err := ioutil.TempDir("", "bulkload")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	if err := ioutil.WriteFile(filepath.Join(dir, "orders-1.csv"),
+		[]byte("id,note\n1,\"a, \"\"quoted\"\" note\"\n2,\\N\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.Create(filepath.Join(dir, "orders-2.csv.gz"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	zw := gzip.NewWriter(f)
+	zw.Write([]byte("id,note\n3,\n"))
+	zw.Close()
+	f.Close()
+
+	table := &config.BulkLoadTable{Schema: "shop", Table: "orders", Files: []string{filepath.Join(dir, "orders-*")}}
+	l := NewLoader(&config.BulkLoadConfig{Gtid: testGtid, Tables: []*config.BulkLoadTable{table}})
+	files, err := l.Files(table)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{filepath.Join(dir, "orders-1.csv"), filepath.Join(dir, "orders-2.csv.gz")}; !reflect.DeepEqual(files, want) {
+		t.Fatalf("Files() = %v, want %v", files, want)
+	}
+
+	var rows [][]string
+	for _, file := range files {
+		r, err := l.Open(table, file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := []string{"id", "note"}; !reflect.DeepEqual(r.Columns(), want) {
+			t.Errorf("Columns() = %v, want %v", r.Columns(), want)
+		}
+		rows = append(rows, readAll(t, r)...)
+	}
+	if want := [][]string{{"1", `a, "quoted" note`}, {"2", "NULL"}, {"3", ""}}; !reflect.DeepEqual(rows, want) {
+		t.Errorf("rows = %q, want %q", rows, want)
+	}
+
+	// Without header, with another delimiter and NULL
+	if err := ioutil.WriteFile(filepath.Join(dir, "items.tsv"), []byte("1\tNULL\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	table = &config.BulkLoadTable{Schema: "shop", Table: "items", Files: []string{filepath.Join(dir, "items.tsv")},
+		Columns: []string{"id", "sku"}, Delimiter: "\t", Null: "NULL"}
+	if _, err := l.Open(table, table.Files[0]); err == nil {
+		t.Errorf("Open() of a file of unknown format, got no error")
+	}
+	table.Format = config.BulkLoadFormatCSV
+	r, err := l.Open(table, table.Files[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rows, want := readAll(t, r), [][]string{{"1", "NULL"}}; !reflect.DeepEqual(rows, want) {
+		t.Errorf("rows = %q, want %q", rows, want)
+	}
+
+	table.Files = []string{filepath.Join(dir, "none-*.csv")}
+	if _, err := l.Files(table); err == nil {
+		t.Errorf("Files() matching no file, got no error")
+	}
+}
+
+func TestSplitS3URL(t *testing.T) {
+	for url, want := range map[string][2]string{
+		"s3://exports/shop/orders.csv": {"exports", "shop/orders.csv"},
+		"s3://exports/shop/":           {"exports", "shop/"},
+		"s3://exports":                 {"exports", ""},
+	} {
+		if bucket, key := splitS3URL(url); bucket != want[0] || key != want[1] {
+			t.Errorf("splitS3URL(%v) = %v, %v, want %v", url, bucket, key, want)
+		}
+	}
+}
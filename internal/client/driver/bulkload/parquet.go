@@ -0,0 +1,660 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package bulkload
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"math/big"
+	"strconv"
+	"time"
+
+	"github.com/golang/snappy"
+)
+
+const parquetMagic = "PAR1"
+
+// The physical types
+const (
+	parquetBoolean = iota
+	parquetInt32
+	parquetInt64
+	parquetInt96
+	parquetFloat
+	parquetDouble
+	parquetByteArray
+	parquetFixedLenByteArray
+)
+
+const (
+	repetitionOptional = 1
+	repetitionRepeated = 2
+)
+
+// The converted types of the files without logical types
+const (
+	convertedDecimal         = 5
+	convertedDate            = 6
+	convertedTimeMillis      = 7
+	convertedTimeMicros      = 8
+	convertedTimestampMillis = 9
+	convertedTimestampMicros = 10
+	convertedUint8           = 11
+	convertedUint64          = 14
+)
+
+// The page types
+const (
+	pageData       = 0
+	pageDictionary = 2
+	pageDataV2     = 3
+)
+
+// The encodings
+const (
+	encodingPlain           = 0
+	encodingPlainDictionary = 2
+	encodingRLE             = 3
+	encodingRLEDictionary   = 8
+)
+
+// The compression codecs
+const (
+	codecUncompressed = 0
+	codecSnappy       = 1
+	codecGzip         = 2
+)
+
+var errTruncated = fmt.Errorf("truncated values")
+
+// julianEpoch is the Julian day of 1970-01-01, that of the INT96 timestamps
+const julianEpoch = 2440588
+
+// The kinds of the values, as of the logical or converted type of a column
+const (
+	kindPlain = iota
+	kindUnsigned
+	kindDecimal
+	kindDate
+	kindTime
+	kindTimestamp
+	kindUUID
+)
+
+// parquetColumn is a column of a flat Parquet file
+type parquetColumn struct {
+	name       string
+	typ        int64
+	typeLength int
+	optional   bool
+	kind       int
+	// scale is that of a decimal
+	scale int
+	// unit is the nanoseconds of the unit of a time or timestamp
+	unit int64
+}
+
+func newParquetColumn(e thriftStruct) (*parquetColumn, error) {
+	c := &parquetColumn{
+		name:       e.string(4),
+		typ:        e.int(1),
+		typeLength: int(e.int(2)),
+		optional:   e.int(3) == repetitionOptional,
+	}
+	if e.int(3) == repetitionRepeated {
+		return nil, fmt.Errorf("repeated column %s is not supported", c.name)
+	}
+
+	if logical := e.strct(10); logical != nil {
+		switch {
+		case logical.has(5):
+			c.kind = kindDecimal
+			c.scale = int(logical.strct(5).int(1))
+		case logical.has(6):
+			c.kind = kindDate
+		case logical.has(7):
+			c.kind = kindTime
+			c.unit = timeUnit(logical.strct(7).strct(2))
+		case logical.has(8):
+			c.kind = kindTimestamp
+			c.unit = timeUnit(logical.strct(8).strct(2))
+		case logical.has(10):
+			if signed, ok := logical.strct(10).bool(2); ok && !signed {
+				c.kind = kindUnsigned
+			}
+		case logical.has(14):
+			c.kind = kindUUID
+		}
+	} else if e.has(6) {
+		switch converted := e.int(6); {
+		case converted == convertedDecimal:
+			c.kind = kindDecimal
+			c.scale = int(e.int(7))
+		case converted == convertedDate:
+			c.kind = kindDate
+		case converted == convertedTimeMillis:
+			c.kind, c.unit = kindTime, int64(time.Millisecond)
+		case converted == convertedTimeMicros:
+			c.kind, c.unit = kindTime, int64(time.Microsecond)
+		case converted == convertedTimestampMillis:
+			c.kind, c.unit = kindTimestamp, int64(time.Millisecond)
+		case converted == convertedTimestampMicros:
+			c.kind, c.unit = kindTimestamp, int64(time.Microsecond)
+		case converted >= convertedUint8 && converted <= convertedUint64:
+			c.kind = kindUnsigned
+		}
+	}
+	if c.typ == parquetInt96 {
+		c.kind = kindTimestamp
+	}
+	return c, nil
+}
+
+// timeUnit returns the nanoseconds of a TimeUnit
+func timeUnit(u thriftStruct) int64 {
+	switch {
+	case u.has(1):
+		return int64(time.Millisecond)
+	case u.has(2):
+		return int64(time.Microsecond)
+	default:
+		return int64(time.Nanosecond)
+	}
+}
+
+// parquetReader reads the rows of a Parquet file of flat columns, a row
+// group at a time
+type parquetReader struct {
+	r         readerAtCloser
+	size      int64
+	columns   []*parquetColumn
+	rowGroups []thriftStruct
+
+	// nextGroup is the next row group to read, and values the values of the
+	// columns of the row group read, by column, row being the next
+	nextGroup int
+	values    [][][]byte
+	row       int
+}
+
+func newParquetReader(r readerAtCloser, size int64) (*parquetReader, error) {
+	if size < int64(2*len(parquetMagic)+4) {
+		return nil, fmt.Errorf("not a Parquet file")
+	}
+	footer := make([]byte, 4+len(parquetMagic))
+	if _, err := r.ReadAt(footer, size-int64(len(footer))); err != nil {
+		return nil, err
+	}
+	if string(footer[4:]) != parquetMagic {
+		return nil, fmt.Errorf("not a Parquet file")
+	}
+	metaSize := int64(binary.LittleEndian.Uint32(footer))
+	if metaSize > size-int64(len(footer)+len(parquetMagic)) {
+		return nil, fmt.Errorf("invalid metadata size %d", metaSize)
+	}
+	meta := make([]byte, metaSize)
+	if _, err := r.ReadAt(meta, size-int64(len(footer))-metaSize); err != nil {
+		return nil, err
+	}
+	fileMeta, err := readThriftStruct(bytes.NewReader(meta))
+	if err != nil {
+		return nil, fmt.Errorf("read metadata: %v", err)
+	}
+
+	p := &parquetReader{r: r, size: size}
+	schema := fileMeta.list(2)
+	if len(schema) == 0 {
+		return nil, fmt.Errorf("no schema")
+	}
+	for _, e := range schema[1:] {
+		e, _ := e.(thriftStruct)
+		if e.int(5) > 0 {
+			return nil, fmt.Errorf("nested column %s is not supported", e.string(4))
+		}
+		c, err := newParquetColumn(e)
+		if err != nil {
+			return nil, err
+		}
+		p.columns = append(p.columns, c)
+	}
+	for _, rg := range fileMeta.list(4) {
+		rg, _ := rg.(thriftStruct)
+		if len(rg.list(1)) != len(p.columns) {
+			return nil, fmt.Errorf("row group of %d columns, not %d", len(rg.list(1)), len(p.columns))
+		}
+		p.rowGroups = append(p.rowGroups, rg)
+	}
+	return p, nil
+}
+
+func (p *parquetReader) Columns() []string {
+	names := make([]string, len(p.columns))
+	for i, c := range p.columns {
+		names[i] = c.name
+	}
+	return names
+}
+
+func (p *parquetReader) Next() ([][]byte, error) {
+	for len(p.values) == 0 || p.row == len(p.values[0]) {
+		if p.nextGroup == len(p.rowGroups) {
+			return nil, io.EOF
+		}
+		if err := p.readRowGroup(p.rowGroups[p.nextGroup]); err != nil {
+			return nil, fmt.Errorf("row group %d: %v", p.nextGroup, err)
+		}
+		p.nextGroup++
+	}
+	row := make([][]byte, len(p.columns))
+	for i := range p.columns {
+		row[i] = p.values[i][p.row]
+	}
+	p.row++
+	return row, nil
+}
+
+func (p *parquetReader) Close() error {
+	return p.r.Close()
+}
+
+func (p *parquetReader) readRowGroup(rg thriftStruct) error {
+	rows := rg.int(3)
+	p.values = make([][][]byte, len(p.columns))
+	p.row = 0
+	for i, chunk := range rg.list(1) {
+		chunk, _ := chunk.(thriftStruct)
+		meta := chunk.strct(3)
+		if meta == nil {
+			return fmt.Errorf("column %s: no metadata", p.columns[i].name)
+		}
+		offset := meta.int(9)
+		if dictOffset := meta.int(11); dictOffset > 0 && dictOffset < offset {
+			offset = dictOffset
+		}
+		length := meta.int(7)
+		if offset < 0 || length < 0 || offset+length > p.size {
+			return fmt.Errorf("column %s: chunk out of the file", p.columns[i].name)
+		}
+		data := make([]byte, length)
+		if _, err := p.r.ReadAt(data, offset); err != nil {
+			return err
+		}
+		values, err := p.columns[i].readChunk(data, meta.int(4), rows)
+		if err != nil {
+			return fmt.Errorf("column %s: %v", p.columns[i].name, err)
+		}
+		p.values[i] = values
+	}
+	return nil
+}
+
+// readChunk returns the n values of a column chunk
+func (c *parquetColumn) readChunk(data []byte, codec int64, n int64) ([][]byte, error) {
+	r := bytes.NewReader(data)
+	values := make([][]byte, 0, n)
+	var dict [][]byte
+	for int64(len(values)) < n {
+		header, err := readThriftStruct(r)
+		if err != nil {
+			return nil, fmt.Errorf("read page header: %v", err)
+		}
+		pos := len(data) - r.Len()
+		size := int(header.int(3))
+		if size < 0 || pos+size > len(data) {
+			return nil, fmt.Errorf("truncated page")
+		}
+		page := data[pos : pos+size]
+		r.Seek(int64(size), io.SeekCurrent)
+		uncompressedSize := int(header.int(2))
+
+		switch header.int(1) {
+		case pageDictionary:
+			body, err := decompress(codec, page, uncompressedSize)
+			if err != nil {
+				return nil, err
+			}
+			if dict, err = c.decodePlain(body, int(header.strct(7).int(1))); err != nil {
+				return nil, err
+			}
+		case pageData:
+			h := header.strct(5)
+			count := int(h.int(1))
+			body, err := decompress(codec, page, uncompressedSize)
+			if err != nil {
+				return nil, err
+			}
+			var defs []int
+			if c.optional {
+				if len(body) < 4 {
+					return nil, fmt.Errorf("truncated definition levels")
+				}
+				length := int(binary.LittleEndian.Uint32(body))
+				if 4+length > len(body) {
+					return nil, fmt.Errorf("truncated definition levels")
+				}
+				if defs, err = decodeRLE(body[4:4+length], 1, count); err != nil {
+					return nil, err
+				}
+				body = body[4+length:]
+			}
+			if values, err = c.appendValues(values, body, h.int(2), count, defs, dict); err != nil {
+				return nil, err
+			}
+		case pageDataV2:
+			h := header.strct(8)
+			count := int(h.int(1))
+			repLength, defLength := int(h.int(6)), int(h.int(5))
+			if repLength < 0 || defLength < 0 || repLength+defLength > len(page) {
+				return nil, fmt.Errorf("truncated levels")
+			}
+			body := page[repLength+defLength:]
+			if compressed, ok := h.bool(7); !ok || compressed {
+				if body, err = decompress(codec, body, uncompressedSize-repLength-defLength); err != nil {
+					return nil, err
+				}
+			}
+			var defs []int
+			if c.optional {
+				if defs, err = decodeRLE(page[repLength:repLength+defLength], 1, count); err != nil {
+					return nil, err
+				}
+			}
+			if values, err = c.appendValues(values, body, h.int(4), count, defs, dict); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return values[:n], nil
+}
+
+// appendValues appends the count values of a data page, with their
+// definition levels, 0 for NULL, if the column is optional
+func (c *parquetColumn) appendValues(values [][]byte, body []byte, encoding int64, count int, defs []int, dict [][]byte) ([][]byte, error) {
+	nonNull := count
+	if defs != nil {
+		nonNull = 0
+		for _, d := range defs {
+			nonNull += d
+		}
+	}
+
+	var decoded [][]byte
+	var err error
+	switch encoding {
+	case encodingPlain:
+		decoded, err = c.decodePlain(body, nonNull)
+	case encodingPlainDictionary, encodingRLEDictionary:
+		if dict == nil {
+			return nil, fmt.Errorf("no dictionary page")
+		}
+		if len(body) == 0 {
+			if nonNull > 0 {
+				return nil, fmt.Errorf("truncated page")
+			}
+			break
+		}
+		var indexes []int
+		if indexes, err = decodeRLE(body[1:], int(body[0]), nonNull); err != nil {
+			return nil, err
+		}
+		decoded = make([][]byte, nonNull)
+		for i, index := range indexes {
+			if index >= len(dict) {
+				return nil, fmt.Errorf("dictionary index %d out of %d values", index, len(dict))
+			}
+			decoded[i] = dict[index]
+		}
+	case encodingRLE:
+		if c.typ != parquetBoolean || len(body) < 4 {
+			return nil, fmt.Errorf("invalid RLE page")
+		}
+		var bits []int
+		if bits, err = decodeRLE(body[4:], 1, nonNull); err != nil {
+			return nil, err
+		}
+		decoded = make([][]byte, nonNull)
+		for i, b := range bits {
+			decoded[i] = []byte(strconv.Itoa(b))
+		}
+	default:
+		return nil, fmt.Errorf("unsupported encoding %d", encoding)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if defs == nil {
+		return append(values, decoded...), nil
+	}
+	next := 0
+	for _, d := range defs {
+		if d == 0 {
+			values = append(values, nil)
+		} else {
+			values = append(values, decoded[next])
+			next++
+		}
+	}
+	return values, nil
+}
+
+// decodePlain decodes n values of the PLAIN encoding, as text
+func (c *parquetColumn) decodePlain(body []byte, n int) ([][]byte, error) {
+	values := make([][]byte, 0, n)
+	switch c.typ {
+	case parquetBoolean:
+		if len(body)*8 < n {
+			return nil, errTruncated
+		}
+		for i := 0; i < n; i++ {
+			values = append(values, []byte(strconv.Itoa(int(body[i/8]>>uint(i%8)&1))))
+		}
+	case parquetInt32:
+		if len(body) < 4*n {
+			return nil, errTruncated
+		}
+		for i := 0; i < n; i++ {
+			v := int32(binary.LittleEndian.Uint32(body[4*i:]))
+			if c.kind == kindUnsigned {
+				values = append(values, []byte(strconv.FormatUint(uint64(uint32(v)), 10)))
+			} else {
+				values = append(values, c.intText(int64(v)))
+			}
+		}
+	case parquetInt64:
+		if len(body) < 8*n {
+			return nil, errTruncated
+		}
+		for i := 0; i < n; i++ {
+			v := int64(binary.LittleEndian.Uint64(body[8*i:]))
+			if c.kind == kindUnsigned {
+				values = append(values, []byte(strconv.FormatUint(uint64(v), 10)))
+			} else {
+				values = append(values, c.intText(v))
+			}
+		}
+	case parquetInt96:
+		if len(body) < 12*n {
+			return nil, errTruncated
+		}
+		for i := 0; i < n; i++ {
+			nanos := int64(binary.LittleEndian.Uint64(body[12*i:]))
+			day := int64(binary.LittleEndian.Uint32(body[12*i+8:]))
+			t := time.Unix((day-julianEpoch)*86400, nanos)
+			values = append(values, []byte(t.UTC().Format(timestampLayout)))
+		}
+	case parquetFloat:
+		if len(body) < 4*n {
+			return nil, errTruncated
+		}
+		for i := 0; i < n; i++ {
+			v := math.Float32frombits(binary.LittleEndian.Uint32(body[4*i:]))
+			values = append(values, []byte(strconv.FormatFloat(float64(v), 'g', -1, 32)))
+		}
+	case parquetDouble:
+		if len(body) < 8*n {
+			return nil, errTruncated
+		}
+		for i := 0; i < n; i++ {
+			v := math.Float64frombits(binary.LittleEndian.Uint64(body[8*i:]))
+			values = append(values, []byte(strconv.FormatFloat(v, 'g', -1, 64)))
+		}
+	case parquetByteArray:
+		for i := 0; i < n; i++ {
+			if len(body) < 4 {
+				return nil, errTruncated
+			}
+			length := int(binary.LittleEndian.Uint32(body))
+			if 4+length > len(body) {
+				return nil, errTruncated
+			}
+			values = append(values, c.bytesText(body[4:4+length]))
+			body = body[4+length:]
+		}
+	case parquetFixedLenByteArray:
+		if c.typeLength <= 0 || len(body) < c.typeLength*n {
+			return nil, errTruncated
+		}
+		for i := 0; i < n; i++ {
+			values = append(values, c.bytesText(body[c.typeLength*i:c.typeLength*(i+1)]))
+		}
+	default:
+		return nil, fmt.Errorf("unknown type %d", c.typ)
+	}
+	return values, nil
+}
+
+const (
+	dateLayout      = "2006-01-02"
+	timeLayout      = "15:04:05.999999"
+	timestampLayout = "2006-01-02 15:04:05.999999"
+)
+
+// intText returns an integer as MySQL reads it, as of the kind of the column
+func (c *parquetColumn) intText(v int64) []byte {
+	switch c.kind {
+	case kindDecimal:
+		return []byte(decimalText(big.NewInt(v), c.scale))
+	case kindDate:
+		return []byte(time.Unix(v*86400, 0).UTC().Format(dateLayout))
+	case kindTime:
+		return []byte(time.Unix(0, v*c.unit).UTC().Format(timeLayout))
+	case kindTimestamp:
+		perSecond := int64(time.Second) / c.unit
+		t := time.Unix(v/perSecond, v%perSecond*c.unit)
+		return []byte(t.UTC().Format(timestampLayout))
+	default:
+		return []byte(strconv.FormatInt(v, 10))
+	}
+}
+
+// bytesText returns a copy of a byte array, or its text as of the kind of
+// the column
+func (c *parquetColumn) bytesText(b []byte) []byte {
+	switch c.kind {
+	case kindDecimal:
+		// Big-endian two's complement
+		v := new(big.Int).SetBytes(b)
+		if len(b) > 0 && b[0]&0x80 != 0 {
+			v.Sub(v, new(big.Int).Lsh(big.NewInt(1), uint(8*len(b))))
+		}
+		return []byte(decimalText(v, c.scale))
+	case kindUUID:
+		if len(b) == 16 {
+			h := hex.EncodeToString(b)
+			return []byte(h[:8] + "-" + h[8:12] + "-" + h[12:16] + "-" + h[16:20] + "-" + h[20:])
+		}
+	}
+	return append([]byte(nil), b...)
+}
+
+// decimalText returns an unscaled decimal as text
+func decimalText(unscaled *big.Int, scale int) string {
+	s := new(big.Int).Abs(unscaled).String()
+	if scale > 0 {
+		for len(s) <= scale {
+			s = "0" + s
+		}
+		s = s[:len(s)-scale] + "." + s[len(s)-scale:]
+	}
+	if unscaled.Sign() < 0 {
+		s = "-" + s
+	}
+	return s
+}
+
+func decompress(codec int64, data []byte, size int) ([]byte, error) {
+	switch codec {
+	case codecUncompressed:
+		return data, nil
+	case codecSnappy:
+		return snappy.Decode(make([]byte, 0, size), data)
+	case codecGzip:
+		r, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, err
+		}
+		defer r.Close()
+		return ioutil.ReadAll(r)
+	default:
+		return nil, fmt.Errorf("unsupported compression codec %d: only snappy and gzip are", codec)
+	}
+}
+
+// decodeRLE decodes n values of the RLE and bit-packing hybrid encoding of
+// the levels and dictionary indexes
+func decodeRLE(data []byte, bitWidth int, n int) ([]int, error) {
+	if bitWidth > 32 {
+		return nil, fmt.Errorf("invalid bit width %d", bitWidth)
+	}
+	values := make([]int, 0, n)
+	byteWidth := (bitWidth + 7) / 8
+	for len(values) < n {
+		header, k := binary.Uvarint(data)
+		if k <= 0 {
+			return nil, errTruncated
+		}
+		data = data[k:]
+		if header&1 == 0 {
+			// A run of a value
+			if len(data) < byteWidth {
+				return nil, errTruncated
+			}
+			v := 0
+			for i := 0; i < byteWidth; i++ {
+				v |= int(data[i]) << uint(8*i)
+			}
+			data = data[byteWidth:]
+			for i := uint64(0); i < header>>1 && len(values) < n; i++ {
+				values = append(values, v)
+			}
+			continue
+		}
+		// Groups of 8 values of bitWidth bits
+		groups := int(header >> 1)
+		size := groups * bitWidth
+		if size > len(data) {
+			return nil, errTruncated
+		}
+		for i := 0; i < 8*groups && len(values) < n; i++ {
+			v := 0
+			for b := 0; b < bitWidth; b++ {
+				bit := i*bitWidth + b
+				v |= int(data[bit/8]>>uint(bit%8)&1) << uint(b)
+			}
+			values = append(values, v)
+		}
+		data = data[size:]
+	}
+	return values, nil
+}
@@ -0,0 +1,274 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package bulkload
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"io"
+	"reflect"
+	"testing"
+
+	"github.com/golang/snappy"
+)
+
+// field is a field of a thrift struct written by writeStruct: an int64 of
+// an integer type, a []byte, a []field for a struct or a list
+type field struct {
+	id  int16
+	typ byte
+	v   interface{}
+}
+
+type list struct {
+	typ   byte
+	elems []interface{}
+}
+
+func zigzag(v int64) uint64 {
+	return uint64(v<<1) ^ uint64(v>>63)
+}
+
+func writeUvarint(buf *bytes.Buffer, v uint64) {
+	var b [binary.MaxVarintLen64]byte
+	buf.Write(b[:binary.PutUvarint(b[:], v)])
+}
+
+func writeValue(buf *bytes.Buffer, typ byte, v interface{}) {
+	switch typ {
+	case thriftI16, thriftI32, thriftI64:
+		writeUvarint(buf, zigzag(v.(int64)))
+	case thriftBinary:
+		b := []byte(v.(string))
+		writeUvarint(buf, uint64(len(b)))
+		buf.Write(b)
+	case thriftList:
+		l := v.(list)
+		buf.WriteByte(byte(len(l.elems))<<4 | l.typ)
+		for _, e := range l.elems {
+			writeValue(buf, l.typ, e)
+		}
+	case thriftStructType:
+		writeStruct(buf, v.([]field)...)
+	}
+}
+
+func writeStruct(buf *bytes.Buffer, fields ...field) {
+	var last int16
+	for _, f := range fields {
+		buf.WriteByte(byte(f.id-last)<<4 | f.typ)
+		last = f.id
+		writeValue(buf, f.typ, f.v)
+	}
+	buf.WriteByte(thriftStop)
+}
+
+func i32(id int16, v int64) field {
+	return field{id, thriftI32, v}
+}
+
+func i64(id int16, v int64) field {
+	return field{id, thriftI64, v}
+}
+
+func strct(id int16, fields ...field) field {
+	return field{id, thriftStructType, fields}
+}
+
+// column is a column of the test file and its chunk
+type column struct {
+	schema []field
+	codec  int64
+	pages  []byte
+	dict   int
+}
+
+func page(header []field, uncompressed int, body []byte) []byte {
+	var buf bytes.Buffer
+	header = append([]field{i32(1, header[0].v.(int64)), i32(2, int64(uncompressed)), i32(3, int64(len(body)))}, header[1:]...)
+	writeStruct(&buf, header...)
+	buf.Write(body)
+	return buf.Bytes()
+}
+
+func plainInt32(values ...int32) []byte {
+	var buf bytes.Buffer
+	for _, v := range values {
+		binary.Write(&buf, binary.LittleEndian, v)
+	}
+	return buf.Bytes()
+}
+
+// parquetFile writes a file of a row group of the columns
+func parquetFile(rows int64, columns []column) []byte {
+	var buf bytes.Buffer
+	buf.WriteString(parquetMagic)
+	schema := []interface{}{[]field{{4, thriftBinary, "schema"}, i32(5, int64(len(columns)))}}
+	var chunks []interface{}
+	for _, c := range columns {
+		schema = append(schema, c.schema)
+		offset := int64(buf.Len())
+		meta := []field{i32(1, c.schema[0].v.(int64)), i32(4, c.codec), i64(5, rows), i64(7, int64(len(c.pages))),
+			i64(9, offset+int64(c.dict))}
+		if c.dict > 0 {
+			meta = append(meta, i64(11, offset))
+		}
+		chunks = append(chunks, []field{i64(2, offset), strct(3, meta...)})
+		buf.Write(c.pages)
+	}
+
+	var meta bytes.Buffer
+	writeStruct(&meta, i32(1, 1), field{2, thriftList, list{thriftStructType, schema}}, i64(3, rows),
+		field{4, thriftList, list{thriftStructType, []interface{}{
+			[]field{{1, thriftList, list{thriftStructType, chunks}}, i64(3, rows)},
+		}}})
+	buf.Write(meta.Bytes())
+	binary.Write(&buf, binary.LittleEndian, uint32(meta.Len()))
+	buf.WriteString(parquetMagic)
+	return buf.Bytes()
+}
+
+type bytesReaderAt struct {
+	*bytes.Reader
+}
+
+func (bytesReaderAt) Close() error {
+	return nil
+}
+
+func TestParquetReader(t *testing.T) {
+	var columns []column
+
+	// id: required INT64, plain
+	ids := new(bytes.Buffer)
+	binary.Write(ids, binary.LittleEndian, []int64{1, 2, 3})
+	columns = append(columns, column{
+		schema: []field{i32(1, parquetInt64), i32(3, 0), {4, thriftBinary, "id"}},
+		pages:  page([]field{i32(1, pageData), strct(5, i32(1, 3), i32(2, encodingPlain))}, ids.Len(), ids.Bytes()),
+	})
+
+	// name: optional UTF8 string, dictionary encoded with snappy, the
+	// second NULL
+	dict := []byte("\x03\x00\x00\x00ann\x03\x00\x00\x00bob")
+	dictPage := page([]field{i32(1, pageDictionary), strct(7, i32(1, 2), i32(2, encodingPlain))},
+		len(dict), snappy.Encode(nil, dict))
+	// Definition levels 1, 0, 1 then indexes 1, 0 of 1 bit, bit-packed
+	names := []byte{2, 0, 0, 0, 0x03, 0x05, 1, 0x03, 0x01}
+	columns = append(columns, column{
+		schema: []field{i32(1, parquetByteArray), i32(3, repetitionOptional), {4, thriftBinary, "name"}, i32(6, 0),
+			strct(10, strct(1))},
+		codec: codecSnappy,
+		pages: append(dictPage, page([]field{i32(1, pageData), strct(5, i32(1, 3), i32(2, encodingRLEDictionary))},
+			len(names), snappy.Encode(nil, names))...),
+		dict: len(dictPage),
+	})
+
+	// price: DECIMAL(9,2) of INT32, in a data page v2 with gzip
+	prices := plainInt32(1234, -5, 0)
+	gzipped := new(bytes.Buffer)
+	zw := gzip.NewWriter(gzipped)
+	zw.Write(prices)
+	zw.Close()
+	columns = append(columns, column{
+		schema: []field{i32(1, parquetInt32), i32(3, 0), {4, thriftBinary, "price"},
+			strct(10, strct(5, i32(1, 2), i32(2, 9)))},
+		codec: codecGzip,
+		pages: page([]field{i32(1, pageDataV2), strct(8, i32(1, 3), i32(2, 0), i32(3, 3), i32(4, encodingPlain),
+			i32(5, 0), i32(6, 0))}, len(prices), gzipped.Bytes()),
+	})
+
+	// day: DATE of the converted type only
+	days := plainInt32(17897, 0, -1)
+	columns = append(columns, column{
+		schema: []field{i32(1, parquetInt32), i32(3, 0), {4, thriftBinary, "day"}, i32(6, convertedDate)},
+		pages:  page([]field{i32(1, pageData), strct(5, i32(1, 3), i32(2, encodingPlain))}, len(days), days),
+	})
+
+	// at: TIMESTAMP in microseconds
+	ats := new(bytes.Buffer)
+	binary.Write(ats, binary.LittleEndian, []int64{1546300800000001, 0, -1})
+	columns = append(columns, column{
+		schema: []field{i32(1, parquetInt64), i32(3, 0), {4, thriftBinary, "at"},
+			strct(10, strct(8, field{1, thriftTrue, nil}, strct(2, strct(2))))},
+		pages: page([]field{i32(1, pageData), strct(5, i32(1, 3), i32(2, encodingPlain))}, ats.Len(), ats.Bytes()),
+	})
+
+	// ok: optional BOOLEAN, the last NULL
+	oks := []byte{2, 0, 0, 0, 0x03, 0x03, 0x01}
+	columns = append(columns, column{
+		schema: []field{i32(1, parquetBoolean), i32(3, repetitionOptional), {4, thriftBinary, "ok"}},
+		pages:  page([]field{i32(1, pageData), strct(5, i32(1, 3), i32(2, encodingPlain))}, len(oks), oks),
+	})
+
+	data := parquetFile(3, columns)
+	r, err := newParquetReader(bytesReaderAt{bytes.NewReader(data)}, int64(len(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"id", "name", "price", "day", "at", "ok"}; !reflect.DeepEqual(r.Columns(), want) {
+		t.Errorf("Columns() = %v, want %v", r.Columns(), want)
+	}
+	want := [][]string{
+		{"1", "bob", "12.34", "2019-01-01", "2019-01-01 00:00:00.000001", "1"},
+		{"2", "", "-0.05", "1970-01-01", "1970-01-01 00:00:00", "0"},
+		{"3", "ann", "0.00", "1969-12-31", "1969-12-31 23:59:59.999999", ""},
+	}
+	for i, w := range want {
+		row, err := r.Next()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for _, v := range row {
+			got = append(got, string(v))
+		}
+		if !reflect.DeepEqual(got, w) {
+			t.Errorf("row %d = %q, want %q", i, got, w)
+		}
+	}
+	if row, err := r.Next(); err != io.EOF {
+		t.Errorf("Next() after the last row = %q, %v, want io.EOF", row, err)
+	}
+
+	if _, err := newParquetReader(bytesReaderAt{bytes.NewReader([]byte("PAR1,a,b\n"))}, 9); err == nil {
+		t.Errorf("newParquetReader() of a CSV file, got no error")
+	}
+}
+
+func TestDecodeRLE(t *testing.T) {
+	// A run of 3 times 5, then a group of 8 values of 3 bits
+	data := []byte{0x06, 0x05, 0x03, 0x88, 0xc6, 0xfa}
+	got, err := decodeRLE(data, 3, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []int{5, 5, 5, 0, 1, 2, 3, 4, 5, 6}; !reflect.DeepEqual(got, want) {
+		t.Errorf("decodeRLE() = %v, want %v", got, want)
+	}
+	if _, err := decodeRLE(data[:4], 3, 10); err == nil {
+		t.Errorf("decodeRLE() of truncated values, got no error")
+	}
+}
+
+func TestDecimalText(t *testing.T) {
+	c := &parquetColumn{kind: kindDecimal, scale: 3}
+	tests := []struct {
+		b    []byte
+		want string
+	}{
+		{[]byte{0x01}, "0.001"},
+		{[]byte{0xff}, "-0.001"},
+		{[]byte{0x30, 0x39}, "12.345"},
+		{[]byte{0xcf, 0xc7}, "-12.345"},
+	}
+	for _, tt := range tests {
+		if got := string(c.bytesText(tt.b)); got != tt.want {
+			t.Errorf("bytesText(%x) = %v, want %v", tt.b, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,231 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package bulkload
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+)
+
+// The types of the thrift compact protocol
+const (
+	thriftStop       = 0
+	thriftTrue       = 1
+	thriftFalse      = 2
+	thriftByte       = 3
+	thriftI16        = 4
+	thriftI32        = 5
+	thriftI64        = 6
+	thriftDouble     = 7
+	thriftBinary     = 8
+	thriftList       = 9
+	thriftSet        = 10
+	thriftMap        = 11
+	thriftStructType = 12
+
+	thriftMaxDepth = 64
+	thriftMaxSize  = 1 << 26
+)
+
+// thriftStruct is a struct of the thrift compact protocol, the Parquet
+// metadata is written in, by field id. The integers are int64, the lists
+// []interface{} and the maps are left out.
+type thriftStruct map[int16]interface{}
+
+func (s thriftStruct) int(id int16) int64 {
+	v, _ := s[id].(int64)
+	return v
+}
+
+func (s thriftStruct) has(id int16) bool {
+	_, ok := s[id]
+	return ok
+}
+
+func (s thriftStruct) bool(id int16) (bool, bool) {
+	v, ok := s[id].(bool)
+	return v, ok
+}
+
+func (s thriftStruct) string(id int16) string {
+	v, _ := s[id].([]byte)
+	return string(v)
+}
+
+func (s thriftStruct) strct(id int16) thriftStruct {
+	v, _ := s[id].(thriftStruct)
+	return v
+}
+
+func (s thriftStruct) list(id int16) []interface{} {
+	v, _ := s[id].([]interface{})
+	return v
+}
+
+// thriftReader decodes the thrift compact protocol
+type thriftReader struct {
+	r     io.ByteReader
+	depth int
+}
+
+func readThriftStruct(r io.ByteReader) (thriftStruct, error) {
+	tr := &thriftReader{r: r}
+	return tr.readStruct()
+}
+
+func (t *thriftReader) readStruct() (thriftStruct, error) {
+	t.depth++
+	defer func() { t.depth-- }()
+	if t.depth > thriftMaxDepth {
+		return nil, fmt.Errorf("thrift: structs nested too deep")
+	}
+
+	s := make(thriftStruct)
+	var id int16
+	for {
+		header, err := t.r.ReadByte()
+		if err != nil {
+			return nil, err
+		}
+		typ := header & 0x0f
+		if typ == thriftStop {
+			return s, nil
+		}
+		if delta := int16(header >> 4); delta != 0 {
+			id += delta
+		} else {
+			v, err := t.readVarint()
+			if err != nil {
+				return nil, err
+			}
+			id = int16(v)
+		}
+		var v interface{}
+		switch typ {
+		case thriftTrue:
+			v = true
+		case thriftFalse:
+			v = false
+		default:
+			if v, err = t.readValue(typ); err != nil {
+				return nil, err
+			}
+		}
+		if v != nil {
+			s[id] = v
+		}
+	}
+}
+
+func (t *thriftReader) readValue(typ byte) (interface{}, error) {
+	switch typ {
+	case thriftTrue, thriftFalse:
+		// A bool of a list
+		b, err := t.r.ReadByte()
+		return b == thriftTrue, err
+	case thriftByte:
+		b, err := t.r.ReadByte()
+		return int64(int8(b)), err
+	case thriftI16, thriftI32, thriftI64:
+		return t.readVarint()
+	case thriftDouble:
+		var b [8]byte
+		for i := range b {
+			c, err := t.r.ReadByte()
+			if err != nil {
+				return nil, err
+			}
+			b[i] = c
+		}
+		return math.Float64frombits(binary.LittleEndian.Uint64(b[:])), nil
+	case thriftBinary:
+		n, err := t.readSize()
+		if err != nil {
+			return nil, err
+		}
+		b := make([]byte, n)
+		for i := range b {
+			if b[i], err = t.r.ReadByte(); err != nil {
+				return nil, err
+			}
+		}
+		return b, nil
+	case thriftList, thriftSet:
+		header, err := t.r.ReadByte()
+		if err != nil {
+			return nil, err
+		}
+		n := int64(header >> 4)
+		if n == 15 {
+			if n, err = t.readSize(); err != nil {
+				return nil, err
+			}
+		}
+		list := make([]interface{}, 0, minInt64(n, 1024))
+		for i := int64(0); i < n; i++ {
+			v, err := t.readValue(header & 0x0f)
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, v)
+		}
+		return list, nil
+	case thriftMap:
+		n, err := t.readSize()
+		if err != nil || n == 0 {
+			return nil, err
+		}
+		types, err := t.r.ReadByte()
+		if err != nil {
+			return nil, err
+		}
+		for i := int64(0); i < 2*n; i++ {
+			typ := types >> 4
+			if i%2 == 1 {
+				typ = types & 0x0f
+			}
+			if _, err := t.readValue(typ); err != nil {
+				return nil, err
+			}
+		}
+		return nil, nil
+	case thriftStructType:
+		return t.readStruct()
+	default:
+		return nil, fmt.Errorf("thrift: unknown type %d", typ)
+	}
+}
+
+// readVarint reads a zigzag varint
+func (t *thriftReader) readVarint() (int64, error) {
+	u, err := binary.ReadUvarint(t.r)
+	if err != nil {
+		return 0, err
+	}
+	return int64(u>>1) ^ -int64(u&1), nil
+}
+
+// readSize reads the unsigned varint size of a binary or container
+func (t *thriftReader) readSize() (int64, error) {
+	u, err := binary.ReadUvarint(t.r)
+	if err != nil {
+		return 0, err
+	}
+	if u > thriftMaxSize {
+		return 0, fmt.Errorf("thrift: size %d too large", u)
+	}
+	return int64(u), nil
+}
+
+func minInt64(a, b int64) int64 {
+	if a < b {
+		return a
+	}
+	return b
+}
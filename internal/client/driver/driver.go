@@ -9,6 +9,7 @@ package driver
 import (
 	"errors"
 	"fmt"
+	"time"
 
 	uconf "github.com/actiontech/dtle/internal/config"
 	log "github.com/actiontech/dtle/internal/logger"
@@ -19,11 +20,20 @@ var (
 	// BuiltinDrivers contains the built in registered drivers
 	// which are available for allocation handling
 	BuiltinDrivers = map[string]Factory{
-		models.TaskDriverMySQL: NewMySQLDriver,
-		models.TaskDriverKafka: NewKafkaDriver,
-		//"models.TaskDriverOracle:     NewOracleDriver,
+		models.TaskDriverMySQL:     NewMySQLDriver,
+		models.TaskDriverKafka:     NewKafkaDriver,
+		models.TaskDriverSQLServer: NewSQLServerDriver,
+		models.TaskDriverPolling:   NewPollingDriver,
+		models.TaskDriverWebhook:   NewWebhookDriver,
+		models.TaskDriverJetStream: NewJetStreamDriver,
+		models.TaskDriverEmbedded:  NewEmbeddedDriver,
+		models.TaskDriverScript:    NewScriptDriver,
 	}
 
+	// PluginDrivers contains the drivers served by the plugins loaded by
+	// LoadPlugins
+	PluginDrivers = map[string]Factory{}
+
 	// DriverStatsNotImplemented is the error to be returned if a driver doesn't
 	// implement stats.
 	DriverStatsNotImplemented = errors.New("stats not implemented for driver")
@@ -34,6 +44,9 @@ var (
 func NewDriver(name string, ctx *DriverContext) (Driver, error) {
 	// Lookup the factory function
 	factory, ok := BuiltinDrivers[name]
+	if !ok {
+		factory, ok = PluginDrivers[name]
+	}
 	if !ok {
 		return nil, fmt.Errorf("unknown driver '%s'", name)
 	}
@@ -104,6 +117,66 @@ type DriverHandle interface {
 	Stats() (*models.TaskStatistics, error)
 }
 
+// Drainer is implemented by the handles of tasks that can write the events
+// they received to their target before they stop. It is used on the
+// shutdown of the agent, before the task is stopped.
+type Drainer interface {
+	// Drain stops receiving events, and waits up to timeout for those
+	// received to be written to the target along with their positions
+	Drain(timeout time.Duration) error
+}
+
+// BandwidthLimiter is implemented by the handles of tasks that can limit
+// the rate they send data at. It is used to apply the bandwidth quota of the
+// namespace of the job.
+type BandwidthLimiter interface {
+	// SetBandwidthLimit limits the bytes sent per second, 0 is unlimited
+	SetBandwidthLimit(bytesPerSecond int64)
+}
+
+// TableEstimator is implemented by the drivers that can estimate the size
+// of the tables a source task replicates, to size a job before submitting
+// it
+type TableEstimator interface {
+	EstimateTables(task *models.Task) ([]*models.TableEstimate, error)
+}
+
+// BinlogRetentionChecker is implemented by the drivers that can tell
+// whether the source of a task still has the binlogs after its checkpoint.
+// The warning is empty if it does.
+type BinlogRetentionChecker interface {
+	CheckBinlogRetention(task *models.Task) (warning string, err error)
+}
+
+// Cutoverer is implemented by the drivers that can compare the source of a
+// job to its target, to cut the applications of the job over
+type Cutoverer interface {
+	// GtidExecuted returns the GTID set executed on the database of a task
+	GtidExecuted(task *models.Task) (string, error)
+	// SetSourceReadOnly stops the writes to the source
+	SetSourceReadOnly(src *models.Task) error
+	// TransactionsBehind returns the transactions of a GTID set of the
+	// source the target has yet to apply
+	TransactionsBehind(jobID string, src, dest *models.Task, gtidSet string) (int64, error)
+}
+
+// DDLSkipper is implemented by the drivers whose target tasks can skip the
+// DDLs applied manually on their target
+type DDLSkipper interface {
+	// SkipDDL records a DDL applied manually on the target of a job and
+	// returns its checksum
+	SkipDDL(jobID string, dest *models.Task, query string) (string, error)
+	// SkippedDDLs returns the DDLs recorded the job has yet to skip
+	SkippedDDLs(jobID string, dest *models.Task) ([]*models.SkippedDDL, error)
+}
+
+// DDLRecorder is implemented by the drivers whose target tasks record the
+// DDLs they replicate
+type DDLRecorder interface {
+	// DDLHistory returns the last DDLs a job replicated to its target
+	DDLHistory(jobID string, dest *models.Task) ([]*models.DDLRecord, error)
+}
+
 type ExecContext struct {
 	Subject    string
 	Tp         string
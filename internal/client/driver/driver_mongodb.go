@@ -0,0 +1,19 @@
+//go:build mongodb
+// +build mongodb
+
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package driver
+
+import (
+	"github.com/actiontech/dtle/internal/models"
+)
+
+func init() {
+	// MongoDB can only be connected to with the driver of the mongodb tag
+	BuiltinDrivers[models.TaskDriverMongoDB] = NewMongoDBDriver
+}
@@ -0,0 +1,19 @@
+//go:build oracle
+// +build oracle
+
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package driver
+
+import (
+	"github.com/actiontech/dtle/internal/models"
+)
+
+func init() {
+	// Oracle can only be connected to with the driver of the oracle tag
+	BuiltinDrivers[models.TaskDriverOracle] = NewOracleDriver
+}
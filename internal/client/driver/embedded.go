@@ -0,0 +1,63 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package driver
+
+import (
+	"fmt"
+
+	"github.com/mitchellh/mapstructure"
+
+	"github.com/actiontech/dtle/internal/client/driver/kafka3"
+	"github.com/actiontech/dtle/internal/models"
+)
+
+// EmbeddedDriver applies the records a Kafka target would send to a local
+// store, a bolt file or in memory, for the jobs to be tried and tested
+// without a target database
+type EmbeddedDriver struct {
+	DriverContext
+}
+
+func (ed *EmbeddedDriver) Start(ctx *ExecContext, task *models.Task) (DriverHandle, error) {
+	var driverConfig kafka3.KafkaConfig
+	if err := mapstructure.WeakDecode(task.Config, &driverConfig); err != nil {
+		return nil, err
+	}
+
+	switch task.Type {
+	case models.TaskTypeSrc:
+		return nil, fmt.Errorf("Embedded can only be used on 'Dest'")
+	case models.TaskTypeDest:
+		if err := driverConfig.ValidateEmbedded(); err != nil {
+			return nil, err
+		}
+		if driverConfig.Topic == "" {
+			driverConfig.Topic = ctx.Subject
+		}
+		runner := kafka3.NewKafkaRunner(ctx.Subject, ctx.Tp, ctx.MaxPayload, &driverConfig, ed.logger)
+		go runner.Run()
+		return runner, nil
+	default:
+		return nil, fmt.Errorf("unknown processor type : %+v", task.Type)
+	}
+}
+
+func (ed *EmbeddedDriver) Validate(task *models.Task) (*models.TaskValidateResponse, error) {
+	var driverConfig kafka3.KafkaConfig
+	reply := &models.TaskValidateResponse{}
+	if err := mapstructure.WeakDecode(task.Config, &driverConfig); err != nil {
+		return reply, err
+	}
+	if task.Type != models.TaskTypeDest {
+		return reply, fmt.Errorf("Embedded can only be used on 'Dest'")
+	}
+	return reply, driverConfig.ValidateEmbedded()
+}
+
+func NewEmbeddedDriver(ctx *DriverContext) Driver {
+	return &EmbeddedDriver{DriverContext: *ctx}
+}
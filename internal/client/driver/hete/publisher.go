@@ -0,0 +1,291 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+// Package hete holds what the extractors of the sources other than MySQL
+// share: sending their snapshot and transactions to the target task as the
+// MySQL extractor does, and the GTIDs they are resumed from.
+package hete
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	gonats "github.com/nats-io/go-nats"
+	"github.com/satori/go.uuid"
+
+	"github.com/actiontech/dtle/internal/client/driver/mysql"
+	"github.com/actiontech/dtle/internal/client/driver/mysql/binlog"
+	usql "github.com/actiontech/dtle/internal/client/driver/mysql/sql"
+	"github.com/actiontech/dtle/internal/config"
+	log "github.com/actiontech/dtle/internal/logger"
+	"github.com/actiontech/dtle/internal/models"
+)
+
+// MaxEntriesPerMessage is the largest number of transactions sent at a time
+const MaxEntriesPerMessage = 100
+
+// dumpStatResult ends the snapshot, as that of the MySQL extractor
+type dumpStatResult struct {
+	Gtid       string
+	TotalCount int64
+}
+
+// Publisher sends the snapshot and the transactions of an extractor to the
+// target task of its job, and counts them for the statistics of the task
+type Publisher struct {
+	logger *log.Entry
+	// name prefixes the logs, as "oracle.extractor"
+	name       string
+	subject    string
+	maxPayload int
+	natsConn   *gonats.Conn
+	shutdownCh <-chan struct{}
+
+	// schemas are those created by the snapshot
+	schemas map[string]bool
+
+	rowsCopied   int64
+	rowsEstimate int64
+	txCount      int64
+}
+
+// NewPublisher connects to the NATS server of the target task. The
+// messages timing out are sent again until shutdownCh is closed.
+func NewPublisher(name, subject string, maxPayload int, natsAddr string, shutdownCh <-chan struct{}, logger *log.Entry) (*Publisher, error) {
+	p := &Publisher{
+		logger:     logger,
+		name:       name,
+		subject:    subject,
+		maxPayload: maxPayload,
+		shutdownCh: shutdownCh,
+		schemas:    make(map[string]bool),
+	}
+	addr := fmt.Sprintf("nats://%s", natsAddr)
+	var err error
+	if p.natsConn, err = gonats.Connect(addr); err != nil {
+		logger.Errorf("%s: Can't connect nats server %v: %v", name, addr, err)
+		return nil, err
+	}
+	logger.Debugf("%s: Connect nats server %v", name, addr)
+	return p, nil
+}
+
+// publish sends a message to the target task, waiting for its reply
+func (p *Publisher) publish(subject string, msg []byte) (err error) {
+	if len(msg) > p.maxPayload {
+		return gonats.ErrMaxPayload
+	}
+	for {
+		_, err = p.natsConn.Request(subject, msg, mysql.DefaultConnectWait)
+		if err != gonats.ErrTimeout {
+			return err
+		}
+		p.logger.Debugf("%s: publish timeout, got %v", p.name, err)
+		select {
+		case <-p.shutdownCh:
+			return err
+		default:
+		}
+	}
+}
+
+func (p *Publisher) publishDumpEntry(entry *mysql.DumpEntry) error {
+	msg, err := mysql.Encode(entry)
+	if err != nil {
+		return err
+	}
+	return p.publish(fmt.Sprintf("%s_full", p.subject), msg)
+}
+
+// AddRowsEstimate adds the estimated rows of a table to those the snapshot
+// copies
+func (p *Publisher) AddRowsEstimate(rows int64) {
+	atomic.AddInt64(&p.rowsEstimate, rows)
+}
+
+// PublishCreateTable sends the creation of a table by the snapshot, after
+// that of its schema if its first table
+func (p *Publisher) PublishCreateTable(schema, createTableSQL string) error {
+	entry := &mysql.DumpEntry{
+		TbSQL:      []string{fmt.Sprintf("USE %s", usql.EscapeName(schema)), createTableSQL},
+		TotalCount: 1,
+		RowsCount:  1,
+	}
+	if !p.schemas[schema] {
+		p.schemas[schema] = true
+		entry.DbSQL = fmt.Sprintf("CREATE DATABASE IF NOT EXISTS %s", usql.EscapeName(schema))
+	}
+	return p.publishDumpEntry(entry)
+}
+
+// PublishDumpComplete ends the snapshot, whose changes are those of the
+// GTID set, "" if none
+func (p *Publisher) PublishDumpComplete(gtid string) error {
+	msg, err := mysql.Encode(&dumpStatResult{Gtid: gtid, TotalCount: atomic.LoadInt64(&p.rowsCopied)})
+	if err != nil {
+		return err
+	}
+	return p.publish(fmt.Sprintf("%s_full_complete", p.subject), msg)
+}
+
+// TableDumper sends the rows of a table copied by the snapshot, chunkSize
+// at a time
+type TableDumper struct {
+	p         *Publisher
+	chunkSize int64
+	newEntry  func() *mysql.DumpEntry
+	entry     *mysql.DumpEntry
+}
+
+// NewTableDumper returns the dumper of the rows of a table, of the columns
+// of names
+func (p *Publisher) NewTableDumper(schema, table string, names []string, def *config.Table, chunkSize int64) *TableDumper {
+	d := &TableDumper{
+		p:         p,
+		chunkSize: chunkSize,
+		newEntry: func() *mysql.DumpEntry {
+			return &mysql.DumpEntry{
+				TableSchema: schema,
+				TableName:   table,
+				ColumnNames: names,
+				Table:       def,
+			}
+		},
+	}
+	d.entry = d.newEntry()
+	return d
+}
+
+// Add adds a row of the values of its columns as the target reads them, nil
+// for NULL, sending the rows once chunkSize
+func (d *TableDumper) Add(row [][]byte) error {
+	values := make([]*interface{}, len(row))
+	for i, b := range row {
+		var v interface{}
+		if b != nil {
+			v = b
+		}
+		values[i] = &v
+	}
+	d.entry.ValuesX = append(d.entry.ValuesX, values)
+	d.entry.RowsCount++
+	if d.entry.RowsCount >= d.chunkSize {
+		return d.Flush()
+	}
+	return nil
+}
+
+// Flush sends the rows added not sent yet
+func (d *TableDumper) Flush() error {
+	if d.entry.RowsCount == 0 {
+		return nil
+	}
+	d.entry.TotalCount = atomic.LoadInt64(&d.p.rowsEstimate)
+	if err := d.p.publishDumpEntry(d.entry); err != nil {
+		return err
+	}
+	atomic.AddInt64(&d.p.rowsCopied, d.entry.RowsCount)
+	d.entry = d.newEntry()
+	return nil
+}
+
+// PublishEntries sends transactions, MaxEntriesPerMessage at a time, split
+// in halves while too large for a message. sent, if not nil, is given the
+// last transaction of each message sent.
+func (p *Publisher) PublishEntries(entries []*binlog.BinlogEntry, sent func(last *binlog.BinlogEntry)) error {
+	for len(entries) > 0 {
+		n := len(entries)
+		if n > MaxEntriesPerMessage {
+			n = MaxEntriesPerMessage
+		}
+		if err := p.publishEntries(entries[:n], sent); err != nil {
+			return err
+		}
+		entries = entries[n:]
+	}
+	return nil
+}
+
+func (p *Publisher) publishEntries(entries []*binlog.BinlogEntry, sent func(last *binlog.BinlogEntry)) error {
+	msg, err := mysql.Encode(&binlog.BinlogEntries{Entries: entries})
+	if err != nil {
+		return err
+	}
+	err = p.publish(fmt.Sprintf("%s_incr_hete", p.subject), msg)
+	if err == gonats.ErrMaxPayload && len(entries) > 1 {
+		half := len(entries) / 2
+		if err := p.publishEntries(entries[:half], sent); err != nil {
+			return err
+		}
+		return p.publishEntries(entries[half:], sent)
+	}
+	if err != nil {
+		return err
+	}
+	if sent != nil {
+		sent(entries[len(entries)-1])
+	}
+	atomic.AddInt64(&p.txCount, int64(len(entries)))
+	return nil
+}
+
+// Statistics returns the statistics of the task of what was sent, none if p
+// is nil, the extractor not connected yet. The coordinates are those to
+// resume from.
+func (p *Publisher) Statistics(stage string, coordinates *models.CurrentCoordinates) *models.TaskStatistics {
+	stats := &models.TaskStatistics{
+		ProgressPct:        strconv.FormatFloat(0, 'f', 1, 64),
+		ETA:                "N/A",
+		Stage:              stage,
+		Timestamp:          time.Now().UTC().UnixNano(),
+		CurrentCoordinates: coordinates,
+	}
+	if p == nil {
+		return stats
+	}
+	rowsCopied := atomic.LoadInt64(&p.rowsCopied)
+	rowsEstimate := atomic.LoadInt64(&p.rowsEstimate)
+	if rowsEstimate > 0 {
+		stats.ProgressPct = strconv.FormatFloat(100.0*float64(rowsCopied)/float64(rowsEstimate), 'f', 1, 64)
+	}
+	txCount := atomic.LoadInt64(&p.txCount)
+	stats.ExecMasterRowCount = rowsCopied
+	stats.ExecMasterTxCount = txCount
+	stats.ReadMasterRowCount = rowsEstimate
+	stats.ReadMasterTxCount = txCount
+	stats.MsgStat = p.natsConn.Statistics
+	return stats
+}
+
+// Close closes the connection to the NATS server
+func (p *Publisher) Close() {
+	p.natsConn.Close()
+}
+
+// Gtid returns the GTID set of the transactions of a source up to a GNO, ""
+// if none
+func Gtid(sid uuid.UUID, gno int64) string {
+	if gno <= 0 {
+		return ""
+	}
+	return fmt.Sprintf("%s:1-%d", sid, gno)
+}
+
+// ParseGtid returns the GNO of the last transaction of a GTID set returned
+// by Gtid, 0 if empty
+func ParseGtid(sid uuid.UUID, gtid string) (int64, error) {
+	if gtid == "" {
+		return 0, nil
+	}
+	prefix := sid.String() + ":1-"
+	if !strings.HasPrefix(gtid, prefix) {
+		return 0, fmt.Errorf("Gtid %q is not of this source, %s", gtid, sid)
+	}
+	return strconv.ParseInt(strings.TrimPrefix(gtid, prefix), 10, 64)
+}
@@ -0,0 +1,132 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package hete
+
+import (
+	"io/ioutil"
+	"reflect"
+	"testing"
+	"time"
+
+	gnatsd "github.com/nats-io/gnatsd/server"
+	gonats "github.com/nats-io/go-nats"
+	"github.com/satori/go.uuid"
+
+	"github.com/actiontech/dtle/internal/client/driver/mysql"
+	"github.com/actiontech/dtle/internal/client/driver/mysql/base"
+	"github.com/actiontech/dtle/internal/client/driver/mysql/binlog"
+	log "github.com/actiontech/dtle/internal/logger"
+)
+
+func TestGtid(t *testing.T) {
+	sid := uuid.NewV5(uuid.NamespaceOID, "hete:a")
+	if got := Gtid(sid, 0); got != "" {
+		t.Errorf("Gtid(0) = %q, want none", got)
+	}
+	gtid := Gtid(sid, 42)
+	if gno, err := ParseGtid(sid, gtid); err != nil || gno != 42 {
+		t.Errorf("ParseGtid(%q) = %v, %v", gtid, gno, err)
+	}
+	if gno, err := ParseGtid(sid, ""); err != nil || gno != 0 {
+		t.Errorf("ParseGtid(\"\") = %v, %v", gno, err)
+	}
+	if _, err := ParseGtid(uuid.NewV5(uuid.NamespaceOID, "hete:b"), gtid); err == nil {
+		t.Errorf("ParseGtid() of the GTID of another source, got no error")
+	}
+}
+
+// newTestPublisher returns a publisher to a NATS server, and the numbers of
+// transactions of the messages it receives
+func newTestPublisher(t *testing.T, maxPayload int) (*Publisher, chan int) {
+	s := gnatsd.New(&gnatsd.Options{Host: "127.0.0.1", Port: gnatsd.RANDOM_PORT, NoLog: true, NoSigs: true})
+	go s.Start()
+	if !s.ReadyForConnections(10 * time.Second) {
+		t.Fatal("nats server not ready")
+	}
+	t.Cleanup(s.Shutdown)
+
+	logger := log.NewEntry(log.New(ioutil.Discard, log.InfoLevel))
+	p, err := NewPublisher("hete.test", "job", maxPayload, s.Addr().String(), make(chan struct{}), logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(p.Close)
+
+	received := make(chan int, 10)
+	sub, err := p.natsConn.Subscribe("job_incr_hete", func(m *gonats.Msg) {
+		var entries binlog.BinlogEntries
+		if err := mysql.Decode(m.Data, &entries); err != nil {
+			t.Errorf("decoding the transactions: %v", err)
+		}
+		received <- len(entries.Entries)
+		p.natsConn.Publish(m.Reply, nil)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { sub.Unsubscribe() })
+	return p, received
+}
+
+func testEntries(gnos ...int64) []*binlog.BinlogEntry {
+	var entries []*binlog.BinlogEntry
+	for _, gno := range gnos {
+		entry := binlog.NewBinlogEntryAt(base.BinlogCoordinateTx{GNO: gno})
+		entry.Events = append(entry.Events, binlog.DataEvent{Query: "INSERT INTO t VALUES (1, 'some text to make it large')"})
+		entries = append(entries, entry)
+	}
+	return entries
+}
+
+func TestPublisher_PublishEntries(t *testing.T) {
+	// Two transactions fit in a message, not four
+	msg, err := mysql.Encode(&binlog.BinlogEntries{Entries: testEntries(1, 2)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, received := newTestPublisher(t, len(msg))
+
+	var sent []int64
+	err = p.PublishEntries(testEntries(1, 2, 3, 4), func(last *binlog.BinlogEntry) {
+		sent = append(sent, last.Coordinates.GNO)
+	})
+	if err != nil {
+		t.Fatalf("PublishEntries() error = %v", err)
+	}
+	if want := []int64{2, 4}; !reflect.DeepEqual(sent, want) {
+		t.Errorf("sent up to %v, want %v", sent, want)
+	}
+	for i := 0; i < 2; i++ {
+		if n := <-received; n != 2 {
+			t.Errorf("message of %d transactions, want 2", n)
+		}
+	}
+	if stats := p.Statistics("stage", nil); stats.ExecMasterTxCount != 4 {
+		t.Errorf("ExecMasterTxCount = %d, want 4", stats.ExecMasterTxCount)
+	}
+
+	// A transaction too large on its own is an error
+	p.maxPayload = 10
+	if err := p.PublishEntries(testEntries(5), nil); err != gonats.ErrMaxPayload {
+		t.Errorf("PublishEntries() of a large transaction error = %v, want %v", err, gonats.ErrMaxPayload)
+	}
+}
+
+func TestPublisher_Statistics(t *testing.T) {
+	var p *Publisher
+	stats := p.Statistics("stage", nil)
+	if stats.Stage != "stage" || stats.ExecMasterRowCount != 0 || stats.ProgressPct != "0.0" {
+		t.Errorf("statistics before connecting %+v", stats)
+	}
+
+	p = &Publisher{rowsCopied: 1, rowsEstimate: 4, txCount: 2, natsConn: &gonats.Conn{}}
+	stats = p.Statistics("stage", nil)
+	if stats.ExecMasterRowCount != 1 || stats.ReadMasterRowCount != 4 || stats.ProgressPct != "25.0" ||
+		stats.ExecMasterTxCount != 2 {
+		t.Errorf("statistics %+v", stats)
+	}
+}
@@ -25,7 +25,12 @@ func (kd *KafkaDriver) Start(ctx *ExecContext, task *models.Task) (DriverHandle,
 
 	switch task.Type {
 	case models.TaskTypeSrc:
-		return nil, fmt.Errorf("afka can only be used on 'Dest'")
+		source, err := kafka3.NewKafkaSource(ctx.Subject, ctx.Tp, ctx.MaxPayload, &driverConfig, kd.logger)
+		if err != nil {
+			return nil, err
+		}
+		go source.Run()
+		return source, nil
 	case models.TaskTypeDest:
 		runner := kafka3.NewKafkaRunner(ctx.Subject, ctx.Tp, ctx.MaxPayload, &driverConfig, kd.logger)
 		go runner.Run()
@@ -37,7 +42,23 @@ func (kd *KafkaDriver) Start(ctx *ExecContext, task *models.Task) (DriverHandle,
 
 func (kd *KafkaDriver) Validate(task *models.Task) (*models.TaskValidateResponse, error) {
 	reply := &models.TaskValidateResponse{}
+	if task.Type != models.TaskTypeSrc {
+		return reply, nil
+	}
 
+	var driverConfig kafka3.KafkaConfig
+	if err := mapstructure.WeakDecode(task.Config, &driverConfig); err != nil {
+		return reply, err
+	}
+	if err := driverConfig.ValidateSource(); err != nil {
+		return reply, err
+	}
+	if err := kafka3.CheckSourceConnection(&driverConfig); err != nil {
+		reply.Connection.Success = false
+		reply.Connection.Error = err.Error()
+	} else {
+		reply.Connection.Success = true
+	}
 	return reply, nil
 }
 
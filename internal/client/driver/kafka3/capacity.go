@@ -0,0 +1,125 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package kafka3
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/Shopify/sarama"
+
+	"github.com/actiontech/dtle/internal/models"
+)
+
+// TopicRetention returns the bytes the existing topics among some retain:
+// the retention.bytes of their partitions times the partitions. Topics
+// without a retention.bytes, and those not created yet, are left out.
+func (k *KafkaManager) TopicRetention(topics []string) (map[string]int64, error) {
+	config := sarama.NewConfig()
+	// DescribeConfigs needs 0.11
+	config.Version = sarama.V0_11_0_0
+	client, err := sarama.NewClient(k.Cfg.Brokers, config)
+	if err != nil {
+		return nil, err
+	}
+	defer client.Close()
+
+	existing, err := client.Topics()
+	if err != nil {
+		return nil, err
+	}
+	exists := make(map[string]bool)
+	for _, topic := range existing {
+		exists[topic] = true
+	}
+	req := &sarama.DescribeConfigsRequest{}
+	partitions := make(map[string]int)
+	for _, topic := range topics {
+		if !exists[topic] {
+			continue
+		}
+		ps, err := client.Partitions(topic)
+		if err != nil {
+			return nil, err
+		}
+		partitions[topic] = len(ps)
+		req.Resources = append(req.Resources, &sarama.ConfigResource{
+			Type:        sarama.TopicResource,
+			Name:        topic,
+			ConfigNames: []string{"retention.bytes"},
+		})
+	}
+	retention := make(map[string]int64)
+	if len(req.Resources) == 0 {
+		return retention, nil
+	}
+
+	broker, err := client.Controller()
+	if err != nil {
+		return nil, err
+	}
+	resp, err := broker.DescribeConfigs(req)
+	if err != nil {
+		return nil, err
+	}
+	for _, r := range resp.Resources {
+		if r.ErrorCode != 0 {
+			return nil, fmt.Errorf("topic %s: %s", r.Name, r.ErrorMsg)
+		}
+		for _, c := range r.Configs {
+			if c.Name != "retention.bytes" {
+				continue
+			}
+			bytes, err := strconv.ParseInt(c.Value, 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("topic %s: invalid retention.bytes %q", r.Name, c.Value)
+			}
+			if bytes > 0 {
+				retention[r.Name] = bytes * int64(partitions[r.Name])
+			}
+		}
+	}
+	return retention, nil
+}
+
+// retentionShortfalls lists the tables whose snapshot is larger than the
+// retention of their topic, which would delete the first records of the
+// snapshot before the last are written
+func retentionShortfalls(tables []*models.TableEstimate, topicOf func(schema, table string) string,
+	retention map[string]int64) string {
+	var shortfalls []string
+	for _, t := range tables {
+		topic := topicOf(t.TableSchema, t.TableName)
+		bytes, ok := retention[topic]
+		if ok && t.DataLength > bytes {
+			shortfalls = append(shortfalls, fmt.Sprintf("the snapshot of %s.%s is about %d bytes, topic %s retains %d bytes",
+				t.TableSchema, t.TableName, t.DataLength, topic, bytes))
+		}
+	}
+	return strings.Join(shortfalls, "; ")
+}
+
+// checkCapacity checks that the topics of the tables retain their snapshot
+func (kr *KafkaRunner) checkCapacity(tables []*models.TableEstimate) string {
+	if kr.kafkaConfig.DryRunFile != "" {
+		return ""
+	}
+	topicOf := func(schema, table string) string {
+		return fmt.Sprintf("%v.%v.%v", kr.kafkaConfig.Topic, schema, table)
+	}
+	var topics []string
+	for _, t := range tables {
+		topics = append(topics, topicOf(t.TableSchema, t.TableName))
+	}
+	retention, err := kr.kafkaMgr.TopicRetention(topics)
+	if err != nil {
+		kr.logger.Warnf("kafka: failed to read the retention of the topics, skipping the capacity check: %v", err)
+		return ""
+	}
+	return retentionShortfalls(tables, topicOf, retention)
+}
@@ -0,0 +1,38 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package kafka3
+
+import (
+	"testing"
+
+	"github.com/actiontech/dtle/internal/models"
+)
+
+func TestRetentionShortfalls(t *testing.T) {
+	tables := []*models.TableEstimate{
+		{TableSchema: "shop", TableName: "orders", DataLength: 5000},
+		{TableSchema: "shop", TableName: "items", DataLength: 800},
+		{TableSchema: "shop", TableName: "logs", DataLength: 1 << 30},
+	}
+	topicOf := func(schema, table string) string {
+		return "dbserver1." + schema + "." + table
+	}
+	retention := map[string]int64{
+		"dbserver1.shop.orders": 4096,
+		"dbserver1.shop.items":  4096,
+	}
+
+	got := retentionShortfalls(tables, topicOf, retention)
+	want := "the snapshot of shop.orders is about 5000 bytes, topic dbserver1.shop.orders retains 4096 bytes"
+	if got != want {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+
+	if got := retentionShortfalls(tables, topicOf, map[string]int64{}); got != "" {
+		t.Fatalf("unlimited topics: got %q", got)
+	}
+}
@@ -0,0 +1,267 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package kafka3
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/actiontech/dtle/internal/config"
+	"github.com/actiontech/dtle/internal/config/mysql"
+)
+
+// The types the columns are cast to
+const (
+	CAST_STRING        = "string"
+	CAST_INT64         = "int64"
+	CAST_FLOAT64       = "float64"
+	CAST_EPOCH_MILLIS  = "epoch_millis"
+	CAST_EPOCH_SECONDS = "epoch_seconds"
+)
+
+// CastConfig casts the values of a column of the records to another type,
+// for the consumers expecting it
+type CastConfig struct {
+	// Table is the "schema.table" of the column, any table if empty
+	Table string
+	// Column is the column cast
+	Column string
+	// To is the type the values are cast to:
+	//   string: from the numeric, temporal and character columns
+	//   int64: from the integer columns, except bigint unsigned
+	//   float64: from the integer and floating point columns
+	//   epoch_millis, epoch_seconds: from the date, datetime and timestamp
+	//     columns
+	To string
+	// Format formats the values cast to string: a Go time layout such as
+	// "2006-01-02T15:04:05Z07:00" for the temporal columns, a timestamp
+	// being in the TimeZone, or a fmt verb such as "%08d" or "%.2f" for
+	// the others. The values are written as read from the source if empty.
+	Format string
+}
+
+func (c *CastConfig) validate() error {
+	if c.Table != "" {
+		if _, _, err := splitTableName(c.Table); err != nil {
+			return fmt.Errorf("invalid cast Table: %v", err)
+		}
+	}
+	if c.Column == "" {
+		return fmt.Errorf("the cast of %s has no Column", c.Table)
+	}
+	switch c.To {
+	case CAST_STRING, CAST_INT64, CAST_FLOAT64, CAST_EPOCH_MILLIS, CAST_EPOCH_SECONDS:
+	default:
+		return fmt.Errorf("the cast of %s.%s has an invalid To %q", c.Table, c.Column, c.To)
+	}
+	if c.Format != "" && c.To != CAST_STRING {
+		return fmt.Errorf("the cast of %s.%s to %s has a Format, only for string", c.Table, c.Column, c.To)
+	}
+	return nil
+}
+
+func isIntegerColumn(col *mysql.Column) bool {
+	switch col.Type {
+	case mysql.TinyintColumnType, mysql.SmallintColumnType, mysql.MediumIntColumnType,
+		mysql.IntColumnType, mysql.BigIntColumnType, mysql.YearColumnType:
+		return true
+	}
+	return false
+}
+
+// temporalLayout returns the layout the values of a date, datetime or
+// timestamp column are read in
+func temporalLayout(col *mysql.Column) (string, bool) {
+	switch {
+	case strings.HasPrefix(col.ColumnType, "datetime"), strings.HasPrefix(col.ColumnType, "timestamp"):
+		return "2006-01-02 15:04:05.999999", true
+	case col.ColumnType == "date":
+		return "2006-01-02", true
+	}
+	return "", false
+}
+
+// isZeroDate tells whether a temporal value is a zero date of MySQL, such as
+// "0000-00-00 00:00:00.000"
+func isZeroDate(s string) bool {
+	return strings.HasPrefix(s, "0000-00-00") && strings.Trim(s[len("0000-00-00"):], "0: .") == ""
+}
+
+// validateCasts checks the Casts, those of the columns of a table being
+// checked against them once the table is known
+func (cfg *KafkaConfig) validateCasts() error {
+	for _, cast := range cfg.Casts {
+		if err := cast.validate(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkColumn tells whether the column can be cast
+func (c *CastConfig) checkColumn(col *mysql.Column) error {
+	_, temporal := temporalLayout(col)
+	var ok bool
+	switch c.To {
+	case CAST_STRING:
+		switch col.Type {
+		case mysql.FloatColumnType, mysql.DoubleColumnType, mysql.DecimalColumnType, mysql.TimeColumnType,
+			mysql.CharColumnType, mysql.VarcharColumnType, mysql.TextColumnType, mysql.TinytextColumnType:
+			ok = true
+		default:
+			ok = isIntegerColumn(col) || temporal
+		}
+	case CAST_INT64:
+		ok = isIntegerColumn(col) && !(col.Type == mysql.BigIntColumnType && col.IsUnsigned)
+	case CAST_FLOAT64:
+		ok = isIntegerColumn(col) || col.Type == mysql.FloatColumnType || col.Type == mysql.DoubleColumnType
+	case CAST_EPOCH_MILLIS, CAST_EPOCH_SECONDS:
+		ok = temporal
+	}
+	if !ok {
+		return fmt.Errorf("the column %s of type %s cannot be cast to %s", col.Name, col.ColumnType, c.To)
+	}
+	return nil
+}
+
+// schemaType returns the type of the field of the values cast
+func (c *CastConfig) schemaType() SchemaType {
+	switch c.To {
+	case CAST_INT64, CAST_EPOCH_MILLIS, CAST_EPOCH_SECONDS:
+		return SCHEMA_TYPE_INT64
+	case CAST_FLOAT64:
+		return SCHEMA_TYPE_FLOAT64
+	default:
+		return SCHEMA_TYPE_STRING
+	}
+}
+
+// castValue casts a value of a column as read from the source, a []byte in
+// the snapshot and typed in the binlog. A timestamp is read in UTC and
+// formatted in loc. A zero date is cast to the epoch, and kept as is when
+// formatted, while the other temporal values that do not parse are errors.
+func (c *CastConfig) castValue(col *mysql.Column, value interface{}, loc *time.Location) (interface{}, error) {
+	var s string
+	switch v := value.(type) {
+	case nil:
+		return nil, nil
+	case []byte:
+		s = string(v)
+	default:
+		s = fmt.Sprint(v)
+	}
+
+	layout, temporal := temporalLayout(col)
+	switch c.To {
+	case CAST_INT64:
+		return strconv.ParseInt(s, 10, 64)
+	case CAST_FLOAT64:
+		return strconv.ParseFloat(s, 64)
+	case CAST_EPOCH_MILLIS, CAST_EPOCH_SECONDS:
+		if isZeroDate(s) {
+			return int64(0), nil
+		}
+		t, err := time.ParseInLocation(layout, s, time.UTC)
+		if err != nil {
+			return nil, fmt.Errorf("the value %q of the column %s is not a %s", s, col.Name, col.ColumnType)
+		}
+		if c.To == CAST_EPOCH_SECONDS {
+			return t.Unix(), nil
+		}
+		return t.UnixNano() / int64(time.Millisecond), nil
+	}
+
+	if c.Format == "" {
+		return s, nil
+	}
+	switch {
+	case temporal:
+		if isZeroDate(s) {
+			return s, nil
+		}
+		t, err := time.ParseInLocation(layout, s, time.UTC)
+		if err != nil {
+			return nil, fmt.Errorf("the value %q of the column %s is not a %s", s, col.Name, col.ColumnType)
+		}
+		if strings.HasPrefix(col.ColumnType, "timestamp") {
+			t = t.In(loc)
+		}
+		return t.Format(c.Format), nil
+	case isIntegerColumn(col) && col.IsUnsigned:
+		n, err := strconv.ParseUint(s, 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		return fmt.Sprintf(c.Format, n), nil
+	case isIntegerColumn(col):
+		n, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		return fmt.Sprintf(c.Format, n), nil
+	case col.Type == mysql.FloatColumnType, col.Type == mysql.DoubleColumnType, col.Type == mysql.DecimalColumnType:
+		f, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return nil, err
+		}
+		return fmt.Sprintf(c.Format, f), nil
+	default:
+		return fmt.Sprintf(c.Format, s), nil
+	}
+}
+
+// tableCasts returns the Casts of the columns of a table by their ordinals,
+// nil for the columns not cast. A cast of the table overrides one of any
+// table.
+func (cfg *KafkaConfig) tableCasts(table *config.Table) ([]*CastConfig, error) {
+	var casts []*CastConfig
+	name := fmt.Sprintf("%s.%s", table.TableSchema, table.TableName)
+	cols := table.OriginalTableColumns.ColumnList()
+	for _, cast := range cfg.Casts {
+		if cast.Table != "" && cast.Table != name {
+			continue
+		}
+		i, ok := table.OriginalTableColumns.Ordinals[cast.Column]
+		if !ok {
+			continue
+		}
+		if casts != nil && casts[i] != nil && cast.Table == "" {
+			continue
+		}
+		if err := cast.checkColumn(&cols[i]); err != nil {
+			return nil, fmt.Errorf("kafka: %s: %v", name, err)
+		}
+		if casts == nil {
+			casts = make([]*CastConfig, len(cols))
+		}
+		casts[i] = cast
+	}
+	return casts, nil
+}
+
+// tableColDefs returns the fields of the values and keys of the records of
+// a table, with its casts and enrichments
+func (kr *KafkaRunner) tableColDefs(table *config.Table, casts []*CastConfig) (valueColDefs, keyColDefs ColDefs) {
+	valueColDefs, keyColDefs = kafkaColumnListToColDefs(table.OriginalTableColumns, kr.kafkaConfig)
+	cols := table.OriginalTableColumns.ColumnList()
+	for i, cast := range casts {
+		if cast == nil {
+			continue
+		}
+		for _, colDefs := range []ColDefs{valueColDefs, keyColDefs} {
+			for j, field := range colDefs {
+				if field.Field == cols[i].Name {
+					colDefs[j] = NewSimpleSchemaField(cast.schemaType(), field.Optional, field.Field)
+				}
+			}
+		}
+	}
+	valueColDefs = append(valueColDefs, kr.enrichedColDefs(table)...)
+	return valueColDefs, keyColDefs
+}
@@ -0,0 +1,154 @@
+package kafka3
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/actiontech/dtle/internal/config"
+	"github.com/actiontech/dtle/internal/config/mysql"
+	log "github.com/actiontech/dtle/internal/logger"
+)
+
+func TestCastConfig_validate(t *testing.T) {
+	for _, cfg := range []*CastConfig{
+		{Table: "tb", Column: "c", To: CAST_STRING},
+		{Table: "db.tb", To: CAST_STRING},
+		{Table: "db.tb", Column: "c", To: "uint8"},
+		{Table: "db.tb", Column: "c", To: CAST_INT64, Format: "%d"},
+	} {
+		if err := cfg.validate(); err == nil {
+			t.Errorf("validate(%+v) succeeded", cfg)
+		}
+	}
+	if err := (&CastConfig{Column: "c", To: CAST_EPOCH_MILLIS}).validate(); err != nil {
+		t.Errorf("validate() of a cast of any table: %v", err)
+	}
+}
+
+func TestCastConfig_castValue(t *testing.T) {
+	shanghai := time.FixedZone("CST", 8*3600)
+	bigint := &mysql.Column{Name: "c", Type: mysql.BigIntColumnType, ColumnType: "bigint"}
+	unsigned := &mysql.Column{Name: "c", Type: mysql.BigIntColumnType, ColumnType: "bigint", IsUnsigned: true}
+	float := &mysql.Column{Name: "c", Type: mysql.FloatColumnType, ColumnType: "float"}
+	datetime := &mysql.Column{Name: "c", Type: mysql.DateColumnType, ColumnType: "datetime"}
+	timestamp := &mysql.Column{Name: "c", Type: mysql.TimeColumnType, ColumnType: "timestamp(3)"}
+	date := &mysql.Column{Name: "c", Type: mysql.DateColumnType, ColumnType: "date"}
+
+	for _, c := range []struct {
+		cast  *CastConfig
+		col   *mysql.Column
+		value interface{}
+		want  interface{}
+	}{
+		{&CastConfig{To: CAST_STRING}, bigint, int64(-7), "-7"},
+		{&CastConfig{To: CAST_STRING}, bigint, []byte("-7"), "-7"},
+		{&CastConfig{To: CAST_STRING, Format: "%05d"}, bigint, int64(42), "00042"},
+		{&CastConfig{To: CAST_STRING, Format: "%d"}, unsigned, uint64(18446744073709551615), "18446744073709551615"},
+		{&CastConfig{To: CAST_STRING, Format: "%.2f"}, float, float32(1.5), "1.50"},
+		{&CastConfig{To: CAST_STRING}, bigint, nil, nil},
+		{&CastConfig{To: CAST_INT64}, bigint, []byte("9007199254740993"), int64(9007199254740993)},
+		{&CastConfig{To: CAST_FLOAT64}, float, float32(1.1), 1.1},
+		{&CastConfig{To: CAST_FLOAT64}, bigint, int64(3), float64(3)},
+		{&CastConfig{To: CAST_EPOCH_MILLIS}, datetime, "2020-01-02 03:04:05.5", int64(1577934245500)},
+		{&CastConfig{To: CAST_EPOCH_SECONDS}, datetime, []byte("2020-01-02 03:04:05"), int64(1577934245)},
+		{&CastConfig{To: CAST_EPOCH_SECONDS}, date, "2020-01-02", int64(1577923200)},
+		{&CastConfig{To: CAST_EPOCH_MILLIS}, datetime, "0000-00-00 00:00:00", int64(0)},
+		{&CastConfig{To: CAST_EPOCH_MILLIS}, timestamp, "0000-00-00 00:00:00.000", int64(0)},
+		{&CastConfig{To: CAST_EPOCH_SECONDS}, date, []byte("0000-00-00"), int64(0)},
+		{&CastConfig{To: CAST_STRING, Format: "2006-01-02T15:04:05Z07:00"}, timestamp, "2020-01-02 03:04:05.123", "2020-01-02T11:04:05+08:00"},
+		{&CastConfig{To: CAST_STRING, Format: "02/01/2006 15:04"}, datetime, "2020-01-02 03:04:05", "02/01/2020 03:04"},
+		{&CastConfig{To: CAST_STRING, Format: "2006"}, datetime, "0000-00-00 00:00:00", "0000-00-00 00:00:00"},
+	} {
+		got, err := c.cast.castValue(c.col, c.value, shanghai)
+		if err != nil {
+			t.Errorf("castValue(%v) of %s to %s: %v", c.value, c.col.ColumnType, c.cast.To, err)
+		} else if got != c.want {
+			t.Errorf("castValue(%v) of %s to %s = %#v, want %#v", c.value, c.col.ColumnType, c.cast.To, got, c.want)
+		}
+	}
+
+	// the temporal values other than the zero dates must parse
+	for _, c := range []struct {
+		cast  *CastConfig
+		col   *mysql.Column
+		value interface{}
+	}{
+		{&CastConfig{To: CAST_EPOCH_MILLIS}, datetime, "2020-13-02 03:04:05"},
+		{&CastConfig{To: CAST_EPOCH_SECONDS}, date, "2020-01-02 03:04:05"},
+		{&CastConfig{To: CAST_EPOCH_SECONDS}, date, "0000-00-00 x"},
+		{&CastConfig{To: CAST_EPOCH_MILLIS}, datetime, "2020-00-00 00:00:00"},
+		{&CastConfig{To: CAST_STRING, Format: "2006"}, timestamp, "garbage"},
+	} {
+		if got, err := c.cast.castValue(c.col, c.value, shanghai); err == nil {
+			t.Errorf("castValue(%v) of %s to %s = %#v, want an error", c.value, c.col.ColumnType, c.cast.To, got)
+		}
+	}
+}
+
+func TestKafkaConfig_validateCasts(t *testing.T) {
+	dir, err := ioutil.TempDir("", "kafka-casts")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	cfg := &KafkaConfig{
+		DryRunFile: filepath.Join(dir, "records.json"),
+		Casts:      []*CastConfig{{Column: "c", To: CAST_EPOCH_MILLIS}, {Column: "c", To: "uint8"}},
+	}
+	// a bad cast fails the job at start, before any table is known
+	if _, err := NewKafkaManager(cfg); err == nil {
+		t.Errorf("NewKafkaManager() accepted a cast to uint8")
+	}
+	cfg.Casts = cfg.Casts[:1]
+	k, err := NewKafkaManager(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	k.Close()
+}
+
+func TestKafkaConfig_tableCasts(t *testing.T) {
+	table := config.NewTable("db", "tb")
+	table.OriginalTableColumns = mysql.NewColumnList([]mysql.Column{
+		{Name: "id", Type: mysql.BigIntColumnType, ColumnType: "bigint", IsUnsigned: true, Key: "PRI"},
+		{Name: "created", Type: mysql.DateColumnType, ColumnType: "datetime", Nullable: true},
+		{Name: "name", Type: mysql.VarcharColumnType, ColumnType: "varchar(10)"},
+	})
+	anyTable := &CastConfig{Column: "created", To: CAST_STRING}
+	thisTable := &CastConfig{Table: "db.tb", Column: "created", To: CAST_EPOCH_MILLIS}
+	id := &CastConfig{Table: "db.tb", Column: "id", To: CAST_STRING}
+	cfg := &KafkaConfig{Casts: []*CastConfig{thisTable, anyTable, id,
+		{Table: "db.other", Column: "name", To: CAST_INT64}}}
+
+	casts, err := cfg.tableCasts(table)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(casts) != 3 || casts[0] != id || casts[1] != thisTable || casts[2] != nil {
+		t.Fatalf("tableCasts() = %v", casts)
+	}
+
+	kr := NewKafkaRunner("job", "kafka", 0, cfg, log.New(ioutil.Discard, log.InfoLevel))
+	valueColDefs, keyColDefs := kr.tableColDefs(table, casts)
+	if f := valueColDefs[0]; f.Type != SCHEMA_TYPE_STRING || f.Optional {
+		t.Errorf("the value field of id = %+v", f)
+	}
+	if f := valueColDefs[1]; f.Type != SCHEMA_TYPE_INT64 || !f.Optional || f.Name != "" {
+		t.Errorf("the value field of created = %+v", f)
+	}
+	if f := keyColDefs[0]; f.Field != "id" || f.Type != SCHEMA_TYPE_STRING {
+		t.Errorf("the key field of id = %+v", f)
+	}
+
+	cfg.Casts = []*CastConfig{{Column: "name", To: CAST_EPOCH_SECONDS}}
+	if _, err := cfg.tableCasts(table); err == nil {
+		t.Errorf("tableCasts() cast a varchar to %s", CAST_EPOCH_SECONDS)
+	}
+	cfg.Casts = []*CastConfig{{Column: "id", To: CAST_INT64}}
+	if _, err := cfg.tableCasts(table); err == nil {
+		t.Errorf("tableCasts() cast a bigint unsigned to %s", CAST_INT64)
+	}
+}
@@ -0,0 +1,253 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package kafka3
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/boltdb/bolt"
+)
+
+// EmbeddedConfig is the local store the Embedded driver applies the records
+// to, rather than sending them to Kafka, for the jobs to be tried without
+// a target database
+type EmbeddedConfig struct {
+	// Path is the bolt file of the store, a bucket per topic. Empty, the
+	// store is in memory, for the tests running the job in their process.
+	Path string
+}
+
+// embeddedOpenTimeout is how long opening a store in use may wait
+const embeddedOpenTimeout = 5 * time.Second
+
+// ValidateEmbedded checks the config of the Embedded driver
+func (c *KafkaConfig) ValidateEmbedded() error {
+	if c.Embedded == nil {
+		return fmt.Errorf("the Embedded block is required")
+	}
+	if c.flat() {
+		return fmt.Errorf("the Embedded driver applies the records of the envelope MessageFormat only")
+	}
+	if c.Converter == CONVERTER_PROTOBUF {
+		return fmt.Errorf("the Embedded driver applies JSON records, not those of the protobuf Converter")
+	}
+	return nil
+}
+
+// embeddedMemory is the in-memory store, shared by the jobs of the process,
+// whose topics differ. It outlives the jobs, to be read once they stop.
+var embeddedMemory = struct {
+	sync.Mutex
+	tables map[string]map[string]json.RawMessage
+}{tables: make(map[string]map[string]json.RawMessage)}
+
+// embeddedOp writes a row of a table, or deletes it if row is nil
+type embeddedOp struct {
+	topic string
+	key   string
+	row   json.RawMessage
+}
+
+// embeddedSink applies the records to the store, a topic being a table of
+// the rows by their key. The rows are the after images of the records, and
+// those of a table without a key are keyed by themselves. The records added
+// are applied on flush, at once.
+type embeddedSink struct {
+	// db is the bolt file, or nil for the in-memory store
+	db *bolt.DB
+	// heartbeatTopic has no rows, and is skipped
+	heartbeatTopic string
+
+	mutex sync.Mutex
+	ops   []embeddedOp
+}
+
+func newEmbeddedSink(cfg *EmbeddedConfig, heartbeatTopic string) (*embeddedSink, error) {
+	s := &embeddedSink{heartbeatTopic: heartbeatTopic}
+	if cfg.Path != "" {
+		db, err := bolt.Open(cfg.Path, 0640, &bolt.Options{Timeout: embeddedOpenTimeout})
+		if err != nil {
+			return nil, fmt.Errorf("embedded: opening %s: %v", cfg.Path, err)
+		}
+		s.db = db
+	}
+	return s, nil
+}
+
+// add decodes a record into the ops applying it, copying its value, whose
+// buffer is reused
+func (s *embeddedSink) add(topic string, key, value []byte) error {
+	if topic == s.heartbeatTopic {
+		return nil
+	}
+	ops, err := embeddedOps(topic, key, append([]byte(nil), value...))
+	if err != nil {
+		return fmt.Errorf("embedded: a record of %s: %v", topic, err)
+	}
+	s.mutex.Lock()
+	s.ops = append(s.ops, ops...)
+	s.mutex.Unlock()
+	return nil
+}
+
+// embeddedOps returns the ops applying a record. An update of a row
+// without a key deletes its before image and writes its after image.
+func embeddedOps(topic string, key, value []byte) ([]embeddedOp, error) {
+	key, err := embeddedPayload(key)
+	if err != nil {
+		return nil, err
+	}
+	if value, err = embeddedPayload(value); err != nil {
+		return nil, err
+	}
+	if value == nil {
+		// a tombstone, following the delete of its row
+		if len(key) == 0 {
+			return nil, nil
+		}
+		return []embeddedOp{{topic: topic, key: string(key)}}, nil
+	}
+	var payload struct {
+		Op     string          `json:"op"`
+		Before json.RawMessage `json:"before"`
+		After  json.RawMessage `json:"after"`
+	}
+	if err := json.Unmarshal(value, &payload); err != nil {
+		return nil, err
+	}
+	before, after := embeddedRow(payload.Before), embeddedRow(payload.After)
+	if payload.Op == RECORD_OP_DELETE {
+		after = nil
+	}
+	if len(key) > 0 {
+		return []embeddedOp{{topic: topic, key: string(key), row: after}}, nil
+	}
+	var ops []embeddedOp
+	if before != nil {
+		ops = append(ops, embeddedOp{topic: topic, key: string(before)})
+	}
+	if after != nil {
+		ops = append(ops, embeddedOp{topic: topic, key: string(after), row: after})
+	}
+	return ops, nil
+}
+
+// embeddedPayload returns the payload of a key or value, which has its
+// schema unless omitted. It is nil for a tombstone.
+func embeddedPayload(data []byte) ([]byte, error) {
+	if embeddedRow(data) == nil {
+		return nil, nil
+	}
+	var withSchema struct {
+		Schema  json.RawMessage `json:"schema"`
+		Payload json.RawMessage `json:"payload"`
+	}
+	if err := json.Unmarshal(data, &withSchema); err != nil {
+		return nil, err
+	}
+	if withSchema.Schema != nil {
+		return embeddedRow(withSchema.Payload), nil
+	}
+	return data, nil
+}
+
+// embeddedRow is nil for a JSON null
+func embeddedRow(data json.RawMessage) json.RawMessage {
+	if len(data) == 0 || string(data) == "null" {
+		return nil
+	}
+	return data
+}
+
+// flush applies the ops added, in a single transaction of the bolt file
+func (s *embeddedSink) flush() error {
+	s.mutex.Lock()
+	ops := s.ops
+	s.ops = nil
+	s.mutex.Unlock()
+	if len(ops) == 0 {
+		return nil
+	}
+	if s.db == nil {
+		embeddedMemory.Lock()
+		defer embeddedMemory.Unlock()
+		for _, op := range ops {
+			table, ok := embeddedMemory.tables[op.topic]
+			if !ok {
+				table = make(map[string]json.RawMessage)
+				embeddedMemory.tables[op.topic] = table
+			}
+			if op.row == nil {
+				delete(table, op.key)
+			} else {
+				table[op.key] = op.row
+			}
+		}
+		return nil
+	}
+	return s.db.Update(func(tx *bolt.Tx) error {
+		for _, op := range ops {
+			bucket, err := tx.CreateBucketIfNotExists([]byte(op.topic))
+			if err != nil {
+				return fmt.Errorf("embedded: bucket %s: %v", op.topic, err)
+			}
+			if op.row == nil {
+				err = bucket.Delete([]byte(op.key))
+			} else {
+				err = bucket.Put([]byte(op.key), op.row)
+			}
+			if err != nil {
+				return fmt.Errorf("embedded: table %s: %v", op.topic, err)
+			}
+		}
+		return nil
+	})
+}
+
+// close closes the bolt file. The in-memory store keeps its tables.
+func (s *embeddedSink) close() {
+	if s.db != nil {
+		s.db.Close()
+	}
+}
+
+// EmbeddedTable returns the rows of the table of a topic in the store of
+// the Embedded driver at path, the in-memory store if empty, by their key.
+// A bolt file can be read once the job using it stops.
+func EmbeddedTable(path, topic string) (map[string]json.RawMessage, error) {
+	rows := make(map[string]json.RawMessage)
+	if path == "" {
+		embeddedMemory.Lock()
+		defer embeddedMemory.Unlock()
+		for key, row := range embeddedMemory.tables[topic] {
+			rows[key] = row
+		}
+		return rows, nil
+	}
+	db, err := bolt.Open(path, 0640, &bolt.Options{Timeout: embeddedOpenTimeout, ReadOnly: true})
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+	err = db.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket([]byte(topic))
+		if bucket == nil {
+			return nil
+		}
+		return bucket.ForEach(func(k, v []byte) error {
+			rows[string(k)] = append(json.RawMessage(nil), v...)
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return rows, nil
+}
@@ -0,0 +1,100 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package kafka3
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestEmbeddedSink(t *testing.T) {
+	dir, err := ioutil.TempDir("", "embedded")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	for _, path := range []string{"", filepath.Join(dir, "store.db")} {
+		cfg := &KafkaConfig{Topic: "embedded-" + filepath.Base(path), Embedded: &EmbeddedConfig{Path: path}}
+		k, err := NewKafkaManager(cfg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		keyed, keyless := cfg.Topic+".db.keyed", cfg.Topic+".db.keyless"
+		records := []struct {
+			topic      string
+			key, value string
+		}{
+			{keyed, `{"schema":{},"payload":{"id":1}}`, `{"schema":{},"payload":{"op":"c","before":null,"after":{"id":1,"v":"a"}}}`},
+			{keyed, `{"id":2}`, `{"op":"c","before":null,"after":{"id":2,"v":"b"}}`},
+			{keyed, `{"id":1}`, `{"op":"u","before":{"id":1,"v":"a"},"after":{"id":1,"v":"c"}}`},
+			{keyed, `{"id":2}`, `{"op":"d","before":{"id":2,"v":"b"},"after":null}`},
+			{keyed, `{"id":2}`, ""},
+			{keyed, `{"schema":{},"payload":{"id":3}}`, `{"schema":{},"payload":{"op":"c","before":null,"after":{"id":3,"v":"d"}}}`},
+			{keyed, `{"schema":{},"payload":{"id":3}}`, `{"schema":{},"payload":{"op":"d","before":{"id":3,"v":"d"},"after":null}}`},
+			{keyed, `{"schema":{},"payload":{"id":3}}`, `{"schema":null,"payload":null}`},
+			{keyless, "", `{"op":"c","before":null,"after":{"v":"a"}}`},
+			{keyless, "", `{"op":"c","before":null,"after":{"v":"b"}}`},
+			{keyless, "", `{"op":"u","before":{"v":"a"},"after":{"v":"c"}}`},
+			{keyless, "", `{"op":"d","before":{"v":"b"},"after":null}`},
+			{cfg.HeartbeatTopic(), `{"serverName":"x"}`, `{"ts_ms":1}`},
+		}
+		for _, r := range records {
+			var value []byte
+			if r.value != "" {
+				value = []byte(r.value)
+			}
+			if err := k.Send(r.topic, []byte(r.key), value); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if path == "" {
+			if rows, _ := EmbeddedTable(path, keyed); len(rows) != 0 {
+				t.Fatalf("rows applied before the flush: %v", rows)
+			}
+		}
+		if err := k.Flush(); err != nil {
+			t.Fatal(err)
+		}
+		k.Close()
+
+		rows, err := EmbeddedTable(path, keyed)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(rows) != 1 || string(rows[`{"id":1}`]) != `{"id":1,"v":"c"}` {
+			t.Fatalf("keyed rows of %q: %v", path, rows)
+		}
+		rows, err = EmbeddedTable(path, keyless)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(rows) != 1 || string(rows[`{"v":"c"}`]) != `{"v":"c"}` {
+			t.Fatalf("keyless rows of %q: %v", path, rows)
+		}
+		if rows, _ := EmbeddedTable(path, cfg.HeartbeatTopic()); len(rows) != 0 {
+			t.Fatalf("heartbeats applied to %q: %v", path, rows)
+		}
+	}
+}
+
+func TestValidateEmbedded(t *testing.T) {
+	for _, cfg := range []*KafkaConfig{
+		{},
+		{Embedded: &EmbeddedConfig{}, MessageFormat: MessageFormatFlat},
+		{Embedded: &EmbeddedConfig{}, Converter: CONVERTER_PROTOBUF},
+	} {
+		if err := cfg.ValidateEmbedded(); err == nil {
+			t.Fatalf("%+v is valid", cfg)
+		}
+	}
+	if err := (&KafkaConfig{Embedded: &EmbeddedConfig{}}).ValidateEmbedded(); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,321 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package kafka3
+
+import (
+	gosql "database/sql"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/golang-lru/simplelru"
+
+	"github.com/actiontech/dtle/internal/client/driver/mysql/binlog"
+	usql "github.com/actiontech/dtle/internal/client/driver/mysql/sql"
+	"github.com/actiontech/dtle/internal/config"
+	"github.com/actiontech/dtle/internal/config/mysql"
+)
+
+// defaultEnrichmentCacheSize is the default CacheSize of an enrichment
+const defaultEnrichmentCacheSize = 10000
+
+// EnrichmentConfig adds to the records of a table columns of the row of a
+// lookup table matching one of its columns, such as the region of the user
+// of an order, looked up by its user_id
+type EnrichmentConfig struct {
+	// Table is the "schema.table" whose records are enriched
+	Table string
+	// Column is the column of Table the rows of LookupTable are looked up by
+	Column string
+	// ConnectionConfig is the MySQL server of LookupTable
+	ConnectionConfig *mysql.ConnectionConfig
+	// LookupTable is the "schema.table" the columns are looked up from
+	LookupTable string
+	// LookupColumn is the column of LookupTable matching Column. Defaults
+	// to Column.
+	LookupColumn string
+	// Columns map the columns of LookupTable to the fields added to the
+	// records, such as {"region": "user_region"}. The fields are optional
+	// strings, null if there is no matching row.
+	Columns map[string]string
+	// CacheSize is the rows of LookupTable kept, the least recently used
+	// being evicted beyond. Defaults to 10000.
+	CacheSize int
+	// CacheTTLSeconds is how long a row is kept, 0 for as long as it is not
+	// evicted. The rows changed by the transactions of LookupTable the job
+	// replicates are dropped on the change.
+	CacheTTLSeconds int
+}
+
+func (c *EnrichmentConfig) validate() error {
+	if _, _, err := splitTableName(c.Table); err != nil {
+		return fmt.Errorf("invalid enrichment Table: %v", err)
+	}
+	if _, _, err := splitTableName(c.LookupTable); err != nil {
+		return fmt.Errorf("invalid enrichment LookupTable: %v", err)
+	}
+	if c.Column == "" {
+		return fmt.Errorf("the enrichment of %s has no Column", c.Table)
+	}
+	if len(c.Columns) == 0 {
+		return fmt.Errorf("the enrichment of %s has no Columns", c.Table)
+	}
+	if c.ConnectionConfig == nil {
+		return fmt.Errorf("the enrichment of %s has no ConnectionConfig", c.Table)
+	}
+	if c.CacheSize < 0 || c.CacheTTLSeconds < 0 {
+		return fmt.Errorf("the enrichment of %s has a negative CacheSize or CacheTTLSeconds", c.Table)
+	}
+	return nil
+}
+
+// splitTableName splits a "schema.table"
+func splitTableName(name string) (string, string, error) {
+	parts := strings.SplitN(name, ".", 2)
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return "", "", fmt.Errorf("%q is not schema.table", name)
+	}
+	return parts[0], parts[1], nil
+}
+
+// enrichment looks up the columns added to the records of a table, caching
+// the rows of the lookup table
+type enrichment struct {
+	cfg *EnrichmentConfig
+	db  *gosql.DB
+	// fields are the fields added, in their order in the records, and
+	// columns the columns of the lookup table they are
+	fields  []string
+	columns []string
+	query   string
+	ttl     time.Duration
+
+	// mu guards the cache, not held while querying the lookup table
+	mu    sync.Mutex
+	cache *simplelru.LRU
+	// invalidations counts the calls to invalidate, a row being cached only
+	// if none happened while it was queried
+	invalidations uint64
+}
+
+// lookupRow is a cached row of the lookup table, values being nil if there
+// is no row
+type lookupRow struct {
+	values []interface{}
+	at     time.Time
+}
+
+func newEnrichment(cfg *EnrichmentConfig) (*enrichment, error) {
+	if err := cfg.validate(); err != nil {
+		return nil, err
+	}
+	cacheSize := cfg.CacheSize
+	if cacheSize == 0 {
+		cacheSize = defaultEnrichmentCacheSize
+	}
+	cache, err := simplelru.NewLRU(cacheSize, nil)
+	if err != nil {
+		return nil, err
+	}
+	e := &enrichment{
+		cfg:   cfg,
+		ttl:   time.Duration(cfg.CacheTTLSeconds) * time.Second,
+		cache: cache,
+	}
+	for column := range cfg.Columns {
+		e.columns = append(e.columns, column)
+	}
+	sort.Strings(e.columns)
+	quoted := make([]string, len(e.columns))
+	for i, column := range e.columns {
+		e.fields = append(e.fields, cfg.Columns[column])
+		quoted[i] = usql.EscapeName(column)
+	}
+	schemaName, tableName, _ := splitTableName(cfg.LookupTable)
+	e.query = fmt.Sprintf("select %s from %s.%s where %s = ? limit 1", strings.Join(quoted, ", "),
+		usql.EscapeName(schemaName), usql.EscapeName(tableName), usql.EscapeName(e.lookupColumn()))
+	return e, nil
+}
+
+// lookupColumn returns the LookupColumn, Column by default
+func (e *enrichment) lookupColumn() string {
+	if e.cfg.LookupColumn != "" {
+		return e.cfg.LookupColumn
+	}
+	return e.cfg.Column
+}
+
+// open connects to the server of the lookup table
+func (e *enrichment) open() (err error) {
+	if err = e.cfg.ConnectionConfig.Prepare(); err != nil {
+		return err
+	}
+	e.db, err = usql.CreateDB(e.cfg.ConnectionConfig.GetDBUri())
+	return err
+}
+
+func (e *enrichment) close() {
+	if e.db != nil {
+		usql.CloseDB(e.db)
+	}
+}
+
+// lookup returns the values of the columns of the row of the lookup table
+// matching a value of Column, nil if the value is NULL or there is no row
+func (e *enrichment) lookup(value interface{}) ([]interface{}, error) {
+	key, ok := lookupKey(value)
+	if !ok {
+		return nil, nil
+	}
+	e.mu.Lock()
+	if cached, ok := e.cache.Get(key); ok {
+		row := cached.(*lookupRow)
+		if e.ttl == 0 || time.Since(row.at) < e.ttl {
+			e.mu.Unlock()
+			return row.values, nil
+		}
+	}
+	invalidations := e.invalidations
+	e.mu.Unlock()
+
+	values := make([]gosql.NullString, len(e.columns))
+	dest := make([]interface{}, len(values))
+	for i := range values {
+		dest[i] = &values[i]
+	}
+	row := &lookupRow{at: time.Now()}
+	err := e.db.QueryRow(e.query, key).Scan(dest...)
+	switch err {
+	case nil:
+		row.values = make([]interface{}, len(values))
+		for i := range values {
+			if values[i].Valid {
+				row.values[i] = values[i].String
+			}
+		}
+	case gosql.ErrNoRows:
+	default:
+		return nil, fmt.Errorf("kafka: looking up %s in %s: %v", key, e.cfg.LookupTable, err)
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.invalidations == invalidations {
+		e.cache.Add(key, row)
+	}
+	return row.values, nil
+}
+
+// invalidate drops the cached rows changed by an event of the lookup table,
+// or all of them on a DDL
+func (e *enrichment) invalidate(table *config.Table, dataEvent *binlog.DataEvent) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.invalidations++
+	if dataEvent.DML == binlog.NotDML {
+		e.cache.Purge()
+		return
+	}
+	i, ok := table.OriginalTableColumns.Ordinals[e.lookupColumn()]
+	if !ok {
+		e.cache.Purge()
+		return
+	}
+	for _, values := range []*mysql.ColumnValues{dataEvent.WhereColumnValues, dataEvent.NewColumnValues} {
+		if values == nil || i >= len(values.AbstractValues) {
+			continue
+		}
+		if key, ok := lookupKey(*values.AbstractValues[i]); ok {
+			e.cache.Remove(key)
+		}
+	}
+}
+
+// lookupKey returns the string a value is looked up and cached by
+func lookupKey(value interface{}) (string, bool) {
+	switch v := value.(type) {
+	case nil:
+		return "", false
+	case []byte:
+		return string(v), true
+	default:
+		return fmt.Sprint(v), true
+	}
+}
+
+// initEnrichments connects to the lookup tables of the Enrichments
+func (kr *KafkaRunner) initEnrichments() error {
+	kr.enrichments = make(map[string][]*enrichment)
+	kr.lookupTables = make(map[string][]*enrichment)
+	for _, cfg := range kr.kafkaConfig.Enrichments {
+		e, err := newEnrichment(cfg)
+		if err != nil {
+			return err
+		}
+		if err := e.open(); err != nil {
+			return fmt.Errorf("kafka: connecting to the server of %s: %v", cfg.LookupTable, err)
+		}
+		kr.enrichments[cfg.Table] = append(kr.enrichments[cfg.Table], e)
+		kr.lookupTables[cfg.LookupTable] = append(kr.lookupTables[cfg.LookupTable], e)
+	}
+	return nil
+}
+
+// enrichedColDefs returns the fields added to the records of a table
+func (kr *KafkaRunner) enrichedColDefs(table *config.Table) ColDefs {
+	var colDefs ColDefs
+	for _, e := range kr.enrichments[fmt.Sprintf("%s.%s", table.TableSchema, table.TableName)] {
+		for _, field := range e.fields {
+			colDefs = append(colDefs, NewSimpleSchemaField(SCHEMA_TYPE_STRING, true, field))
+		}
+	}
+	return colDefs
+}
+
+// enrich adds the looked up fields to a row of a table, values being the
+// values of the columns of the row as read from the source
+func (kr *KafkaRunner) enrich(table *config.Table, row *Row, values []*interface{}) error {
+	if row == nil {
+		return nil
+	}
+	for _, e := range kr.enrichments[fmt.Sprintf("%s.%s", table.TableSchema, table.TableName)] {
+		var value interface{}
+		if i, ok := table.OriginalTableColumns.Ordinals[e.cfg.Column]; ok && i < len(values) {
+			value = *values[i]
+		}
+		looked, err := e.lookup(value)
+		if err != nil {
+			return err
+		}
+		for i, field := range e.fields {
+			if looked == nil {
+				row.AddField(field, nil)
+			} else {
+				row.AddField(field, looked[i])
+			}
+		}
+	}
+	return nil
+}
+
+// invalidateLookups drops the rows of the lookup tables changed by an
+// event of a table
+func (kr *KafkaRunner) invalidateLookups(table *config.Table, dataEvent *binlog.DataEvent) {
+	for _, e := range kr.lookupTables[fmt.Sprintf("%s.%s", table.TableSchema, table.TableName)] {
+		e.invalidate(table, dataEvent)
+	}
+}
+
+// closeEnrichments closes the connections to the lookup tables
+func (kr *KafkaRunner) closeEnrichments() {
+	for _, enrichments := range kr.enrichments {
+		for _, e := range enrichments {
+			e.close()
+		}
+	}
+}
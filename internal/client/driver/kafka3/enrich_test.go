@@ -0,0 +1,216 @@
+package kafka3
+
+import (
+	"context"
+	gosql "database/sql"
+	"database/sql/driver"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/actiontech/dtle/internal/client/driver/mysql/binlog"
+	"github.com/actiontech/dtle/internal/config"
+	"github.com/actiontech/dtle/internal/config/mysql"
+	log "github.com/actiontech/dtle/internal/logger"
+)
+
+func newTestEnrichment(t *testing.T) *enrichment {
+	e, err := newEnrichment(&EnrichmentConfig{
+		Table:            "db.tb",
+		Column:           "user_id",
+		ConnectionConfig: &mysql.ConnectionConfig{},
+		LookupTable:      "db.users",
+		LookupColumn:     "id",
+		Columns:          map[string]string{"region": "user_region", "name": "user_name"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return e
+}
+
+func TestEnrichmentConfig_validate(t *testing.T) {
+	for _, cfg := range []*EnrichmentConfig{
+		{Table: "tb", Column: "c", LookupTable: "db.l", Columns: map[string]string{"a": "b"}, ConnectionConfig: &mysql.ConnectionConfig{}},
+		{Table: "db.tb", Column: "c", LookupTable: "l", Columns: map[string]string{"a": "b"}, ConnectionConfig: &mysql.ConnectionConfig{}},
+		{Table: "db.tb", LookupTable: "db.l", Columns: map[string]string{"a": "b"}, ConnectionConfig: &mysql.ConnectionConfig{}},
+		{Table: "db.tb", Column: "c", LookupTable: "db.l", ConnectionConfig: &mysql.ConnectionConfig{}},
+		{Table: "db.tb", Column: "c", LookupTable: "db.l", Columns: map[string]string{"a": "b"}},
+		{Table: "db.tb", Column: "c", LookupTable: "db.l", Columns: map[string]string{"a": "b"}, ConnectionConfig: &mysql.ConnectionConfig{}, CacheSize: -1},
+	} {
+		if err := cfg.validate(); err == nil {
+			t.Errorf("validate(%+v) succeeded", cfg)
+		}
+	}
+}
+
+func TestNewEnrichment(t *testing.T) {
+	e := newTestEnrichment(t)
+	if want := []string{"user_name", "user_region"}; !reflect.DeepEqual(e.fields, want) {
+		t.Errorf("fields = %v, want %v", e.fields, want)
+	}
+	if want := "select `name`, `region` from `db`.`users` where `id` = ? limit 1"; e.query != want {
+		t.Errorf("query = %v, want %v", e.query, want)
+	}
+}
+
+func TestEnrichmentLookupCache(t *testing.T) {
+	e := newTestEnrichment(t)
+	e.cache.Add("7", &lookupRow{values: []interface{}{"alice", "eu"}, at: time.Now()})
+	e.cache.Add("8", &lookupRow{at: time.Now()})
+
+	if values, err := e.lookup(int64(7)); err != nil || !reflect.DeepEqual(values, []interface{}{"alice", "eu"}) {
+		t.Fatalf("lookup(7) = %v, %v", values, err)
+	}
+	if values, err := e.lookup([]byte("8")); err != nil || values != nil {
+		t.Fatalf("lookup(8) = %v, %v, want the cached missing row", values, err)
+	}
+	if values, err := e.lookup(nil); err != nil || values != nil {
+		t.Fatalf("lookup(nil) = %v, %v", values, err)
+	}
+
+	// A change of the lookup table drops the rows it changed
+	users := config.NewTable("db", "users")
+	users.OriginalTableColumns = mysql.NewColumnList([]mysql.Column{{Name: "id"}, {Name: "region"}})
+	var id, region interface{} = int64(7), "us"
+	e.invalidate(users, &binlog.DataEvent{
+		DML:             binlog.UpdateDML,
+		NewColumnValues: &mysql.ColumnValues{AbstractValues: []*interface{}{&id, &region}},
+	})
+	if e.cache.Contains("7") || !e.cache.Contains("8") {
+		t.Fatalf("the update of the row 7 invalidated %v", e.cache.Keys())
+	}
+	e.invalidate(users, &binlog.DataEvent{DML: binlog.NotDML})
+	if e.cache.Len() != 0 {
+		t.Fatalf("a DDL of the lookup table kept %v", e.cache.Keys())
+	}
+}
+
+func TestKafkaRunnerEnrich(t *testing.T) {
+	kr := NewKafkaRunner("job", "kafka", 0, &KafkaConfig{}, log.New(ioutil.Discard, log.InfoLevel))
+	e := newTestEnrichment(t)
+	kr.enrichments = map[string][]*enrichment{"db.tb": {e}}
+	kr.lookupTables = map[string][]*enrichment{"db.users": {e}}
+	e.cache.Add("7", &lookupRow{values: []interface{}{"alice", nil}, at: time.Now()})
+
+	table := newTestTable("id", "user_id")
+	colDefs := kr.enrichedColDefs(table)
+	if len(colDefs) != 2 || colDefs[0].Field != "user_name" || !colDefs[0].Optional || colDefs[1].Field != "user_region" {
+		t.Fatalf("enrichedColDefs() = %v", colDefs)
+	}
+	if colDefs := kr.enrichedColDefs(config.NewTable("db", "other")); len(colDefs) != 0 {
+		t.Fatalf("enrichedColDefs() of a table not enriched = %v", colDefs)
+	}
+
+	var id, userID interface{} = int64(1), int64(7)
+	row := &Row{}
+	if err := kr.enrich(table, row, []*interface{}{&id, &userID}); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(row.ColNames, []string{"user_name", "user_region"}) ||
+		!reflect.DeepEqual(row.Values, []interface{}{"alice", nil}) {
+		t.Fatalf("enrich() = %+v", row)
+	}
+
+	// A NULL user_id is not looked up
+	userID = nil
+	row = &Row{}
+	if err := kr.enrich(table, row, []*interface{}{&id, &userID}); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(row.Values, []interface{}{nil, nil}) {
+		t.Fatalf("enrich() of a NULL = %+v", row)
+	}
+}
+
+// blockingLookupDB answers the lookups with the row (bob, eu) once release
+// is closed, telling each query on started
+type blockingLookupDB struct {
+	started chan struct{}
+	release chan struct{}
+}
+
+func (db *blockingLookupDB) Connect(context.Context) (driver.Conn, error) { return db, nil }
+func (db *blockingLookupDB) Driver() driver.Driver                        { return nil }
+func (db *blockingLookupDB) Prepare(string) (driver.Stmt, error)          { return db, nil }
+func (db *blockingLookupDB) Close() error                                 { return nil }
+func (db *blockingLookupDB) Begin() (driver.Tx, error)                    { return nil, fmt.Errorf("no transactions") }
+func (db *blockingLookupDB) NumInput() int                                { return -1 }
+func (db *blockingLookupDB) Exec([]driver.Value) (driver.Result, error) {
+	return nil, fmt.Errorf("no statements")
+}
+func (db *blockingLookupDB) Query([]driver.Value) (driver.Rows, error) {
+	db.started <- struct{}{}
+	<-db.release
+	return &lookupRows{values: []driver.Value{"bob", "eu"}}, nil
+}
+
+type lookupRows struct {
+	values []driver.Value
+}
+
+func (r *lookupRows) Columns() []string { return []string{"name", "region"} }
+func (r *lookupRows) Close() error      { return nil }
+func (r *lookupRows) Next(dest []driver.Value) error {
+	if r.values == nil {
+		return io.EOF
+	}
+	copy(dest, r.values)
+	r.values = nil
+	return nil
+}
+
+func TestEnrichmentLookupConcurrent(t *testing.T) {
+	e := newTestEnrichment(t)
+	db := &blockingLookupDB{started: make(chan struct{}, 2), release: make(chan struct{})}
+	e.db = gosql.OpenDB(db)
+	defer e.db.Close()
+	e.cache.Add("7", &lookupRow{values: []interface{}{"alice", "eu"}, at: time.Now()})
+
+	done := make(chan []interface{})
+	go func() {
+		values, err := e.lookup(int64(9))
+		if err != nil {
+			t.Error(err)
+		}
+		done <- values
+	}()
+	<-db.started
+
+	// the cached rows are looked up while the lookup table is queried
+	cached := make(chan []interface{})
+	go func() {
+		values, _ := e.lookup(int64(7))
+		cached <- values
+	}()
+	select {
+	case values := <-cached:
+		if !reflect.DeepEqual(values, []interface{}{"alice", "eu"}) {
+			t.Errorf("lookup(7) = %v", values)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("lookup(7) waited for the query of another row")
+	}
+
+	// a change of the lookup table during the query keeps its row out of
+	// the cache, as it may be stale
+	users := config.NewTable("db", "users")
+	e.invalidate(users, &binlog.DataEvent{DML: binlog.NotDML})
+	close(db.release)
+	if values := <-done; !reflect.DeepEqual(values, []interface{}{"bob", "eu"}) {
+		t.Errorf("lookup(9) = %v", values)
+	}
+	if e.cache.Contains("9") {
+		t.Errorf("the row queried during an invalidation was cached")
+	}
+
+	if _, err := e.lookup(int64(10)); err != nil {
+		t.Fatal(err)
+	}
+	if !e.cache.Contains("10") {
+		t.Errorf("the row queried was not cached")
+	}
+}
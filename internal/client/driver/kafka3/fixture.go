@@ -0,0 +1,103 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package kafka3
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	log "github.com/actiontech/dtle/internal/logger"
+)
+
+// The kinds of the messages of the source, the suffixes of their subjects
+const (
+	fixtureFull         = "full"
+	fixtureFullComplete = "full_complete"
+	fixtureIncr         = "incr_hete"
+)
+
+// fixtureMessage is a message of the source in a RecordFile, one JSON
+// object per line
+type fixtureMessage struct {
+	Kind string `json:"kind"`
+	// Time is when the message was received, the time of its records once
+	// replayed, in Unix nanoseconds
+	Time int64 `json:"time"`
+	// Data is the message as sent, encoded
+	Data []byte `json:"data"`
+}
+
+// fixtureRecorder appends the messages received to a RecordFile
+type fixtureRecorder struct {
+	mutex sync.Mutex
+	file  *os.File
+}
+
+func newFixtureRecorder(path string) (*fixtureRecorder, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0640)
+	if err != nil {
+		return nil, err
+	}
+	return &fixtureRecorder{file: file}, nil
+}
+
+// record appends a message, before it is applied, so that the message
+// failing is recorded too
+func (r *fixtureRecorder) record(kind string, data []byte) error {
+	line, err := json.Marshal(&fixtureMessage{Kind: kind, Time: time.Now().UnixNano(), Data: data})
+	if err != nil {
+		return err
+	}
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if _, err := r.file.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("kafka: recording a message: %v", err)
+	}
+	return nil
+}
+
+func (r *fixtureRecorder) close() error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return r.file.Close()
+}
+
+// Replay sends the records of the messages of a RecordFile read from r as
+// the target of cfg would have, one message after the other, as received.
+// The records have the times the messages were received, for a replay to
+// send the records of the job. It returns the number of messages replayed.
+func Replay(r io.Reader, cfg *KafkaConfig, logger *log.Logger) (int, error) {
+	kr := NewKafkaRunner("replay", "", 0, cfg, logger)
+	if err := kr.initManager(); err != nil {
+		return 0, err
+	}
+	defer kr.kafkaMgr.Close()
+
+	reader := bufio.NewReader(r)
+	for n := 0; ; n++ {
+		line, err := reader.ReadBytes('\n')
+		if err == io.EOF && len(line) == 0 {
+			return n, nil
+		} else if err != nil && err != io.EOF {
+			return n, err
+		}
+		message := &fixtureMessage{}
+		if err := json.Unmarshal(line, message); err != nil {
+			return n, fmt.Errorf("message %d: %v", n+1, err)
+		}
+		received := time.Unix(0, message.Time)
+		kr.now = func() time.Time { return received }
+		if err := kr.handle(message.Kind, message.Data); err != nil {
+			return n, fmt.Errorf("message %d (%s): %v", n+1, message.Kind, err)
+		}
+	}
+}
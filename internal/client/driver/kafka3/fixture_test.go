@@ -0,0 +1,103 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package kafka3
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	mysqlDriver "github.com/actiontech/dtle/internal/client/driver/mysql"
+	"github.com/actiontech/dtle/internal/client/driver/mysql/binlog"
+	"github.com/actiontech/dtle/internal/config"
+	"github.com/actiontech/dtle/internal/config/mysql"
+	log "github.com/actiontech/dtle/internal/logger"
+)
+
+func fixtureEntry(t *testing.T, dml binlog.EventDML, table *config.Table, before, after []interface{}) []byte {
+	event := binlog.NewDataEvent("shop", "orders", dml, 2)
+	event.Table = table
+	if before != nil {
+		event.WhereColumnValues = mysql.ToColumnValues(before)
+	}
+	if after != nil {
+		event.NewColumnValues = mysql.ToColumnValues(after)
+	}
+	data, err := mysqlDriver.Encode(&binlog.BinlogEntries{Entries: []*binlog.BinlogEntry{{Events: []binlog.DataEvent{event}}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func TestRecordReplay(t *testing.T) {
+	dir, err := ioutil.TempDir("", "kafka-fixture")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	fixture := filepath.Join(dir, "fixture.json")
+
+	table := config.NewTable("shop", "orders")
+	table.OriginalTableColumns = mysql.NewColumnList([]mysql.Column{
+		{Name: "id", Type: mysql.IntColumnType, Key: "PRI"},
+		{Name: "status", Type: mysql.TextColumnType},
+	})
+	recorder, err := newFixtureRecorder(fixture)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, data := range [][]byte{
+		fixtureEntry(t, binlog.InsertDML, table, nil, []interface{}{int32(1), "new"}),
+		fixtureEntry(t, binlog.UpdateDML, nil, []interface{}{int32(1), "new"}, []interface{}{int32(1), "paid"}),
+	} {
+		if err := recorder.record(fixtureIncr, data); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := recorder.close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// the replays of a fixture send the same records
+	var records []string
+	for i := 0; i < 2; i++ {
+		output := filepath.Join(dir, "records.json")
+		os.Remove(output)
+		f, err := os.Open(fixture)
+		if err != nil {
+			t.Fatal(err)
+		}
+		n, err := Replay(f, &KafkaConfig{Topic: "db1", DryRunFile: output}, log.New(ioutil.Discard, log.InfoLevel))
+		f.Close()
+		if err != nil || n != 2 {
+			t.Fatalf("replayed %d messages: %v", n, err)
+		}
+		data, err := ioutil.ReadFile(output)
+		if err != nil {
+			t.Fatal(err)
+		}
+		records = append(records, string(data))
+	}
+	if records[0] != records[1] {
+		t.Fatalf("replays differ:\n%s\n%s", records[0], records[1])
+	}
+	if lines := strings.Split(strings.TrimSpace(records[0]), "\n"); len(lines) != 2 ||
+		!strings.Contains(lines[1], `\"status\":\"paid\"`) {
+		t.Fatalf("records: %s", records[0])
+	}
+
+	// a message failing is reported with its number
+	bad := bytes.NewBufferString(`{"kind":"incr_hete","time":0,"data":"AAAA"}` + "\n")
+	if _, err := Replay(bad, &KafkaConfig{Topic: "db1", DryRunFile: filepath.Join(dir, "bad.json")},
+		log.New(ioutil.Discard, log.InfoLevel)); err == nil || !strings.Contains(err.Error(), "message 1") {
+		t.Fatalf("err = %v", err)
+	}
+}
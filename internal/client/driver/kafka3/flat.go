@@ -0,0 +1,204 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package kafka3
+
+import (
+	"bytes"
+	"fmt"
+)
+
+// The formats of the messages
+const (
+	// MessageFormatEnvelope is the envelope of Debezium, with the before and
+	// after images and the source, and the schema unless omitted
+	MessageFormatEnvelope = "envelope"
+	// MessageFormatFlat is the after image alone, as a flat JSON object
+	MessageFormatFlat = "flat"
+)
+
+// How the deletes are written in the flat format
+const (
+	// FlatDeleteTombstone writes a delete as its tombstone only
+	FlatDeleteTombstone = "tombstone"
+	// FlatDeleteRewrite writes the before image of a delete, marked deleted,
+	// then its tombstone
+	FlatDeleteRewrite = "rewrite"
+)
+
+// defaultDeletedField is the key marking the deleted rows when rewritten
+const defaultDeletedField = "__deleted"
+
+// flatMetadataFields are the metadata the flat messages may have, in the
+// order they are written
+var flatMetadataFields = []string{"op", "db", "table", "ts_ms", "server_id", "gtid", "file", "pos", "snapshot"}
+
+// FlatFormatConfig is what the messages have besides the row in the flat
+// format
+type FlatFormatConfig struct {
+	// Metadata are the metadata added to a message, each the key it is
+	// written as by its field: op, db, table, ts_ms, server_id, gtid, file,
+	// pos or snapshot. E.g. {"op": "__op", "table": "__table"}.
+	Metadata map[string]string
+	// DeleteHandling is how a delete is written: "tombstone", its tombstone
+	// only, or "rewrite", its before image then its tombstone. Defaults to
+	// tombstone.
+	DeleteHandling string
+	// DeletedField is the key telling whether a rewritten row is deleted.
+	// Defaults to "__deleted".
+	DeletedField string
+}
+
+func (c *KafkaConfig) validateMessageFormat() error {
+	switch c.MessageFormat {
+	case "", MessageFormatEnvelope:
+		return nil
+	case MessageFormatFlat:
+	default:
+		return fmt.Errorf("invalid MessageFormat %q: not %s or %s", c.MessageFormat,
+			MessageFormatEnvelope, MessageFormatFlat)
+	}
+	if c.Flat == nil {
+		return nil
+	}
+	switch c.Flat.DeleteHandling {
+	case "", FlatDeleteTombstone, FlatDeleteRewrite:
+	default:
+		return fmt.Errorf("invalid Flat DeleteHandling %q: not %s or %s", c.Flat.DeleteHandling,
+			FlatDeleteTombstone, FlatDeleteRewrite)
+	}
+	keys := make(map[string]bool)
+	for field, key := range c.Flat.Metadata {
+		known := false
+		for _, f := range flatMetadataFields {
+			if f == field {
+				known = true
+				break
+			}
+		}
+		if !known {
+			return fmt.Errorf("invalid Flat Metadata %q: not one of %v", field, flatMetadataFields)
+		}
+		if key == "" {
+			return fmt.Errorf("invalid Flat Metadata %q: empty key", field)
+		}
+		if keys[key] {
+			return fmt.Errorf("invalid Flat Metadata %q: key %q is used twice", field, key)
+		}
+		keys[key] = true
+	}
+	return nil
+}
+
+// flat tells whether the messages are written in the flat format
+func (c *KafkaConfig) flat() bool {
+	return c.MessageFormat == MessageFormatFlat
+}
+
+// encodeFlatValue serializes the value of a record in the flat format, into
+// a pooled buffer as encodeRecord. It is nil for a delete to write as its
+// tombstone only.
+func encodeFlatValue(p *ValuePayload, cfg *FlatFormatConfig) (*bytes.Buffer, error) {
+	rewrite := cfg != nil && cfg.DeleteHandling == FlatDeleteRewrite
+	if p.Op == RECORD_OP_DELETE && !rewrite {
+		return nil, nil
+	}
+	buf := bufferPool.Get().(*bytes.Buffer)
+	buf.Reset()
+	if err := writeFlatValue(buf, p, cfg); err != nil {
+		releaseBuffer(buf)
+		return nil, err
+	}
+	return buf, nil
+}
+
+// writeFlatValue writes the after image of a row, or the before image of a
+// deleted one, followed by the metadata
+func writeFlatValue(buf *bytes.Buffer, p *ValuePayload, cfg *FlatFormatConfig) error {
+	row := p.After
+	if p.Op == RECORD_OP_DELETE {
+		row = p.Before
+	}
+	buf.WriteByte('{')
+	n := 0
+	if row != nil {
+		for i := range row.ColNames {
+			if n > 0 {
+				buf.WriteByte(',')
+			}
+			writeJSONString(buf, row.ColNames[i])
+			buf.WriteByte(':')
+			if err := writeJSONValue(buf, row.Values[i]); err != nil {
+				return err
+			}
+			n++
+		}
+	}
+	writeField := func(key string, value interface{}) error {
+		if n > 0 {
+			buf.WriteByte(',')
+		}
+		writeJSONString(buf, key)
+		buf.WriteByte(':')
+		n++
+		return writeJSONValue(buf, value)
+	}
+	if cfg == nil {
+		buf.WriteByte('}')
+		return nil
+	}
+	for _, field := range flatMetadataFields {
+		key, ok := cfg.Metadata[field]
+		if !ok {
+			continue
+		}
+		if err := writeField(key, flatMetadata(p, field)); err != nil {
+			return err
+		}
+	}
+	if cfg.DeleteHandling == FlatDeleteRewrite {
+		deletedField := cfg.DeletedField
+		if deletedField == "" {
+			deletedField = defaultDeletedField
+		}
+		if err := writeField(deletedField, p.Op == RECORD_OP_DELETE); err != nil {
+			return err
+		}
+	}
+	buf.WriteByte('}')
+	return nil
+}
+
+// flatMetadata returns the value of a metadata field of a record
+func flatMetadata(p *ValuePayload, field string) interface{} {
+	switch field {
+	case "op":
+		return p.Op
+	case "ts_ms":
+		return p.TsMs
+	}
+	s := p.Source
+	if s == nil {
+		return nil
+	}
+	switch field {
+	case "db":
+		return s.Db
+	case "table":
+		return s.Table
+	case "server_id":
+		return s.ServerID
+	case "gtid":
+		return s.Gtid
+	case "file":
+		return s.File
+	case "pos":
+		return s.Pos
+	case "snapshot":
+		return s.Snapshot
+	}
+	return nil
+}
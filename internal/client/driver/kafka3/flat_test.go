@@ -0,0 +1,88 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package kafka3
+
+import (
+	"testing"
+)
+
+func TestEncodeFlatValue(t *testing.T) {
+	payload := NewValuePayload()
+	payload.Before = NewRow()
+	payload.Before.AddField("id", int64(1))
+	payload.Before.AddField("name", "a")
+	payload.After = NewRow()
+	payload.After.AddField("id", int64(1))
+	payload.After.AddField("name", "b")
+	payload.Op = RECORD_OP_UPDATE
+	payload.TsMs = 1234
+	payload.Source.Db = "db"
+	payload.Source.Table = "tb"
+	payload.Source.Gtid = "8e1f3a16-0000-0000-0000-000000000000:12"
+	defer releaseValuePayload(payload)
+
+	test := func(cfg *FlatFormatConfig, want string) {
+		buf, err := encodeFlatValue(payload, cfg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want == "" {
+			if buf != nil {
+				t.Fatalf("op %s: got %s, want none", payload.Op, buf.String())
+			}
+			return
+		}
+		if buf == nil || buf.String() != want {
+			t.Fatalf("op %s: got %v, want %s", payload.Op, buf, want)
+		}
+		releaseBuffer(buf)
+	}
+
+	test(nil, `{"id":1,"name":"b"}`)
+	metadata := &FlatFormatConfig{Metadata: map[string]string{
+		"table": "__table", "op": "__op", "gtid": "__gtid", "ts_ms": "__ts_ms",
+	}}
+	test(metadata, `{"id":1,"name":"b","__op":"u","__table":"tb","__ts_ms":1234,`+
+		`"__gtid":"8e1f3a16-0000-0000-0000-000000000000:12"}`)
+	rewrite := &FlatFormatConfig{Metadata: map[string]string{"db": "_db"}, DeleteHandling: FlatDeleteRewrite}
+	test(rewrite, `{"id":1,"name":"b","_db":"db","__deleted":false}`)
+
+	payload.Op = RECORD_OP_DELETE
+	test(nil, "")
+	test(metadata, "")
+	test(rewrite, `{"id":1,"name":"a","_db":"db","__deleted":true}`)
+	rewrite.DeletedField = "deleted"
+	test(rewrite, `{"id":1,"name":"a","_db":"db","deleted":true}`)
+}
+
+func TestValidateMessageFormat(t *testing.T) {
+	valid := []*KafkaConfig{
+		{},
+		{MessageFormat: MessageFormatEnvelope},
+		{MessageFormat: MessageFormatFlat},
+		{MessageFormat: MessageFormatFlat, Flat: &FlatFormatConfig{
+			Metadata: map[string]string{"op": "__op", "pos": "__pos"}, DeleteHandling: FlatDeleteRewrite,
+		}},
+	}
+	for i, c := range valid {
+		if err := c.validateMessageFormat(); err != nil {
+			t.Fatalf("%d: %v", i, err)
+		}
+	}
+	invalid := []*KafkaConfig{
+		{MessageFormat: "avro"},
+		{MessageFormat: MessageFormatFlat, Flat: &FlatFormatConfig{DeleteHandling: "drop"}},
+		{MessageFormat: MessageFormatFlat, Flat: &FlatFormatConfig{Metadata: map[string]string{"row": "__row"}}},
+		{MessageFormat: MessageFormatFlat, Flat: &FlatFormatConfig{Metadata: map[string]string{"op": ""}}},
+		{MessageFormat: MessageFormatFlat, Flat: &FlatFormatConfig{Metadata: map[string]string{"db": "x", "table": "x"}}},
+	}
+	for i, c := range invalid {
+		if err := c.validateMessageFormat(); err == nil {
+			t.Fatalf("%d: no error", i)
+		}
+	}
+}
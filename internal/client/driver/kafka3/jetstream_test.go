@@ -0,0 +1,164 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package kafka3
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeJetStream is a NATS server acknowledging the records published, but
+// those of the subjects under "missing", as if no stream had them
+type fakeJetStream struct {
+	listener net.Listener
+	mutex    sync.Mutex
+	streams  map[string][]string
+	headers  []string
+	payloads []string
+}
+
+func newFakeJetStream(t *testing.T) *fakeJetStream {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := &fakeJetStream{listener: l, streams: make(map[string][]string)}
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go f.serve(conn)
+		}
+	}()
+	return f
+}
+
+func (f *fakeJetStream) serve(conn net.Conn) {
+	defer conn.Close()
+	fmt.Fprintf(conn, "INFO {\"headers\":true}\r\n")
+	r := bufio.NewReader(conn)
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		args := strings.Fields(line)
+		switch args[0] {
+		case "PING":
+			fmt.Fprintf(conn, "PONG\r\n")
+		case "PUB", "HPUB":
+			size, _ := strconv.Atoi(args[len(args)-1])
+			headerSize := 0
+			if args[0] == "HPUB" {
+				headerSize, _ = strconv.Atoi(args[len(args)-2])
+			}
+			data := make([]byte, size+2)
+			io.ReadFull(r, data)
+			subject, reply, header, payload := args[1], args[2], string(data[:headerSize]), data[headerSize:size]
+			f.mutex.Lock()
+			var resp string
+			switch {
+			case strings.HasPrefix(subject, "$JS.API.STREAM.INFO."):
+				if _, ok := f.streams[subject[20:]]; ok {
+					resp = `{"config":{}}`
+				} else {
+					resp = `{"error":{"code":404,"err_code":10059,"description":"stream not found"}}`
+				}
+			case strings.HasPrefix(subject, "$JS.API.STREAM.CREATE."):
+				var config struct {
+					Subjects []string `json:"subjects"`
+				}
+				json.Unmarshal(payload, &config)
+				f.streams[subject[22:]] = config.Subjects
+				resp = `{"config":{}}`
+			case strings.HasPrefix(subject, "missing."):
+				fmt.Fprintf(conn, "HMSG %s 1 16 16\r\nNATS/1.0 503\r\n\r\n\r\n", reply)
+			default:
+				f.headers = append(f.headers, header)
+				f.payloads = append(f.payloads, string(payload))
+				resp = fmt.Sprintf(`{"stream":"S","seq":%d}`, len(f.payloads))
+			}
+			f.mutex.Unlock()
+			if resp != "" {
+				fmt.Fprintf(conn, "MSG %s 1 %d\r\n%s\r\n", reply, len(resp), resp)
+			}
+		}
+	}
+}
+
+func TestJetStreamSink(t *testing.T) {
+	f := newFakeJetStream(t)
+	defer f.listener.Close()
+
+	cfg := &KafkaConfig{Topic: "dtle", JetStream: &JetStreamConfig{
+		Servers:    []string{"127.0.0.1:1", f.listener.Addr().String()},
+		Stream:     "S",
+		MaxPending: 2,
+	}}
+	if err := cfg.ValidateJetStream(); err != nil {
+		t.Fatal(err)
+	}
+	k, err := NewKafkaManager(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer k.Close()
+	if subjects := f.streams["S"]; len(subjects) != 2 || subjects[0] != "dtle.>" {
+		t.Fatalf("stream: got subjects %v", subjects)
+	}
+
+	for i := 0; i < 5; i++ {
+		if err := k.Send("dtle.db.tb", []byte(`{"id":1}`), []byte(strconv.Itoa(i%2))); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := k.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	f.mutex.Lock()
+	if len(f.payloads) != 5 || f.payloads[4] != "0" {
+		t.Fatalf("got %v", f.payloads)
+	}
+	if !strings.Contains(f.headers[0], "\r\nDtle-Key: {\"id\":1}\r\n") || f.headers[0] == f.headers[1] ||
+		f.headers[0] != f.headers[2] {
+		t.Fatalf("got headers %q", f.headers)
+	}
+	f.mutex.Unlock()
+
+	if err := k.Send("missing.db.tb", []byte(`{}`), nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := k.Flush(); err == nil || !strings.Contains(err.Error(), "no stream") {
+		t.Fatalf("no stream: got %v", err)
+	}
+}
+
+func TestValidateJetStream(t *testing.T) {
+	for i, c := range []*KafkaConfig{
+		{},
+		{JetStream: &JetStreamConfig{Servers: []string{"http://host"}}},
+		{JetStream: &JetStreamConfig{Servers: []string{"host"}, Stream: "a.b"}},
+		{JetStream: &JetStreamConfig{Servers: []string{"host"}, MaxPending: -1}},
+		{JetStream: &JetStreamConfig{Servers: []string{"host"}}, Converter: CONVERTER_PROTOBUF},
+	} {
+		if err := c.ValidateJetStream(); err == nil {
+			t.Fatalf("%d: no error", i)
+		}
+	}
+	if err := (&KafkaConfig{JetStream: &JetStreamConfig{Servers: []string{"tls://u:p@host:4443"}}}).ValidateJetStream(); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -9,16 +9,21 @@ package kafka3
 import (
 	"bytes"
 	"encoding/base64"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"math/big"
+	"os"
 	"strings"
+	"sync"
 
 	"strconv"
 
 	"time"
 
 	"github.com/Shopify/sarama"
+
+	"github.com/actiontech/dtle/internal/config"
 )
 
 type SchemaType string
@@ -26,6 +31,9 @@ type SchemaType string
 const (
 	CONVERTER_JSON = "json"
 	CONVERTER_AVRO = "avro"
+	// CONVERTER_PROTOBUF serializes the records with protobuf messages
+	// generated from the structure of the tables
+	CONVERTER_PROTOBUF = "protobuf"
 
 	SCHEMA_TYPE_STRUCT  = "struct"
 	SCHEMA_TYPE_STRING  = "string"
@@ -43,21 +51,195 @@ const (
 	RECORD_OP_UPDATE = "u"
 	RECORD_OP_DELETE = "d"
 	RECORD_OP_READ   = "r"
+	// RECORD_OP_TRUNCATE is read from Debezium, dtle sends no truncate
+	RECORD_OP_TRUNCATE = "t"
 )
 
 type ColDefs []*Schema
 
+const (
+	// DecimalHandlingPrecise encodes DECIMAL columns as Kafka Connect
+	// decimals, the bytes of their unscaled value
+	DecimalHandlingPrecise = "precise"
+
+	// DecimalHandlingString encodes DECIMAL columns as strings
+	DecimalHandlingString = "string"
+
+	// DecimalHandlingDouble encodes DECIMAL columns as doubles, which may
+	// lose precision
+	DecimalHandlingDouble = "double"
+)
+
+const (
+	// BinaryHandlingBase64 encodes BINARY, VARBINARY and BLOB columns as
+	// Kafka Connect bytes, which the JSON converter writes in base64
+	BinaryHandlingBase64 = "base64"
+
+	// BinaryHandlingHex encodes binary columns as hexadecimal strings
+	BinaryHandlingHex = "hex"
+
+	// BinaryHandlingSkip leaves binary columns out of the records, except
+	// those of the primary key, which are encoded in base64
+	BinaryHandlingSkip = "skip"
+)
+
 type KafkaConfig struct {
 	Brokers   []string
 	Topic     string
 	Converter string
 	NatsAddr  string
 	Gtid      string // TODO remove?
+
+	// DecimalHandlingMode is how DECIMAL columns are encoded, as the
+	// decimal.handling.mode of Debezium. Defaults to precise.
+	DecimalHandlingMode string
+
+	// BinaryHandlingMode is how binary columns are encoded, as the
+	// binary.handling.mode of Debezium. Defaults to base64.
+	BinaryHandlingMode string
+	// BinaryMaxBytes truncates the values of binary columns to this many
+	// bytes. The truncated columns are listed in the "truncated" field of
+	// the source. 0 does not truncate.
+	BinaryMaxBytes int
+
+	// IncludeGeneratedColumns writes the computed values of the generated
+	// columns of the source, which are left out otherwise
+	IncludeGeneratedColumns bool
+
+	// TimeZone is the time zone TIMESTAMP columns are written in, as
+	// "+08:00" or a name such as "Asia/Shanghai". Defaults to UTC.
+	TimeZone string
+
+	// HeartbeatTopicPrefix prefixes the topic the heartbeats of the source
+	// are written to, as the heartbeat.topics.prefix of Debezium. Defaults to
+	// DefaultHeartbeatTopicPrefix.
+	HeartbeatTopicPrefix string
+
+	// OmitSchema writes the payload of the records without their schema, as
+	// the JSON converter of Kafka Connect with schemas.enable=false, for the
+	// consumers getting the schemas from a schema registry
+	OmitSchema bool
+	// MessageFormat is the format of the messages: "envelope", that of
+	// Debezium, or "flat", the after image alone as a JSON object without
+	// schema, with the metadata of Flat. Defaults to envelope.
+	MessageFormat string
+	// Flat is what the messages have besides the row in the flat format
+	Flat *FlatFormatConfig
+	// Protobuf is how the records are serialized with the protobuf Converter
+	Protobuf *ProtobufConfig
+	// Webhook is the endpoint the Webhook driver posts the records to
+	Webhook *WebhookConfig
+	// JetStream is the server the JetStream driver publishes the records to
+	JetStream *JetStreamConfig
+	// Embedded is the local store the Embedded driver applies the records to
+	Embedded *EmbeddedConfig
+
+	// Topics are the topics a source reads the records of
+	Topics []string
+	// GroupID is the consumer group the offsets of a source are committed
+	// in. Defaults to "dtle-" and the job.
+	GroupID string
+	// InitialOffset is where a source reads a partition without offset
+	// committed from: oldest, or newest. Defaults to oldest.
+	InitialOffset string
+
+	// DryRunFile is a file on the node of the target task the records are
+	// written to, one JSON object per line, rather than sent to Kafka
+	DryRunFile string
+	// RecordFile is a file on the node of the target task the messages of
+	// the source are appended to, as received, for "dtle job replay" to
+	// send them again without the source
+	RecordFile string
+	// FaultInjection fails the sending of records, for tests only
+	FaultInjection *config.FaultInjectionConfig
+
+	// TopicCreation creates the topics of the tables which do not exist,
+	// with its partitions, replication factor and cleanup policy, rather
+	// than leaving them to the auto-creation of the brokers
+	TopicCreation *TopicCreationConfig
+	// TopicNaming is how the names of the databases and tables are made
+	// valid topic names
+	TopicNaming *TopicNamingConfig
+	// Enrichments add to the records of tables columns looked up from
+	// other tables
+	Enrichments []*EnrichmentConfig
+	// Casts cast columns of the records to the types their consumers
+	// expect
+	Casts []*CastConfig
+}
+
+// DefaultHeartbeatTopicPrefix is the default prefix of the heartbeat topic
+const DefaultHeartbeatTopicPrefix = "__debezium-heartbeat"
+
+// HeartbeatTopic returns the topic the heartbeats are written to
+func (c *KafkaConfig) HeartbeatTopic() string {
+	prefix := c.HeartbeatTopicPrefix
+	if prefix == "" {
+		prefix = DefaultHeartbeatTopicPrefix
+	}
+	return fmt.Sprintf("%v.%v", prefix, c.Topic)
+}
+
+// Location returns the location of the TimeZone
+func (c *KafkaConfig) Location() (*time.Location, error) {
+	return ParseTimeZone(c.TimeZone)
+}
+
+// ParseTimeZone parses an offset such as "+08:00", or a time zone name
+func ParseTimeZone(name string) (*time.Location, error) {
+	if name == "" || name == "UTC" {
+		return time.UTC, nil
+	}
+	if len(name) == 6 && (name[0] == '+' || name[0] == '-') && name[3] == ':' {
+		hours, errH := strconv.Atoi(name[1:3])
+		minutes, errM := strconv.Atoi(name[4:])
+		if errH != nil || errM != nil {
+			return nil, fmt.Errorf("invalid time zone offset %q", name)
+		}
+		offset := hours*3600 + minutes*60
+		if name[0] == '-' {
+			offset = -offset
+		}
+		return time.FixedZone(name, offset), nil
+	}
+	return time.LoadLocation(name)
+}
+
+// recordSink is where the records are written instead of Kafka
+type recordSink interface {
+	// add writes a record, or holds it until flush
+	add(topic string, key, value []byte) error
+	// flush writes the records held, and waits for them to be written
+	flush() error
+	close()
 }
 
 type KafkaManager struct {
 	Cfg      *KafkaConfig
 	producer sarama.SyncProducer
+
+	// dryRun is the DryRunFile, if the records are not sent
+	dryRunMu sync.Mutex
+	dryRun   *os.File
+	// sink, if any, is where the Webhook, JetStream and Embedded drivers
+	// write the records, rather than to Kafka
+	sink recordSink
+
+	// faults are those of FaultInjection, if allowed on the node
+	faults *config.FaultInjector
+
+	// admin creates the topics, which are known to exist once in topics
+	topicsMu sync.Mutex
+	admin    sarama.Client
+	topics   map[string]bool
+}
+
+// dryRunRecord is a record written to the DryRunFile
+type dryRunRecord struct {
+	Topic string `json:"topic"`
+	Key   string `json:"key"`
+	// Value is null for a tombstone
+	Value *string `json:"value"`
 }
 
 func NewKafkaManager(kcfg *KafkaConfig) (*KafkaManager, error) {
@@ -65,6 +247,61 @@ func NewKafkaManager(kcfg *KafkaConfig) (*KafkaManager, error) {
 	k := &KafkaManager{
 		Cfg: kcfg,
 	}
+	if kcfg.TopicCreation != nil {
+		if err := kcfg.TopicCreation.validate(); err != nil {
+			return nil, err
+		}
+	}
+	if err := kcfg.TopicNaming.validate(); err != nil {
+		return nil, err
+	}
+	if err := kcfg.validateMessageFormat(); err != nil {
+		return nil, err
+	}
+	if err := kcfg.validateConverter(); err != nil {
+		return nil, err
+	}
+	if err := kcfg.validateCasts(); err != nil {
+		return nil, err
+	}
+	if kcfg.FaultInjection != nil {
+		if err := kcfg.FaultInjection.Validate(); err != nil {
+			return nil, err
+		}
+		k.faults = config.NewFaultInjector(kcfg.FaultInjection)
+	}
+	if kcfg.Webhook != nil {
+		if err := kcfg.ValidateWebhook(); err != nil {
+			return nil, err
+		}
+		k.sink = newWebhookSink(kcfg.Webhook)
+		return k, nil
+	}
+	if kcfg.JetStream != nil {
+		if err := kcfg.ValidateJetStream(); err != nil {
+			return nil, err
+		}
+		if k.sink, err = newJetStreamSink(kcfg.JetStream, kcfg.jetStreamSubjects()); err != nil {
+			return nil, err
+		}
+		return k, nil
+	}
+	if kcfg.Embedded != nil {
+		if err := kcfg.ValidateEmbedded(); err != nil {
+			return nil, err
+		}
+		if k.sink, err = newEmbeddedSink(kcfg.Embedded, kcfg.HeartbeatTopic()); err != nil {
+			return nil, err
+		}
+		return k, nil
+	}
+	if kcfg.DryRunFile != "" {
+		k.dryRun, err = os.OpenFile(kcfg.DryRunFile, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0640)
+		if err != nil {
+			return nil, err
+		}
+		return k, nil
+	}
 	config := sarama.NewConfig()
 	config.Producer.Return.Successes = true
 
@@ -76,6 +313,15 @@ func NewKafkaManager(kcfg *KafkaConfig) (*KafkaManager, error) {
 }
 
 func (k *KafkaManager) Send(topic string, key []byte, value []byte) error {
+	if err := k.faults.Inject(config.FaultKafkaSend); err != nil {
+		return err
+	}
+	if k.dryRun != nil {
+		return k.writeDryRun(topic, key, value)
+	}
+	if k.sink != nil {
+		return k.sink.add(topic, key, value)
+	}
 	msg := &sarama.ProducerMessage{
 		Topic:     topic,
 		Partition: int32(-1),
@@ -92,6 +338,52 @@ func (k *KafkaManager) Send(topic string, key []byte, value []byte) error {
 	return nil
 }
 
+// writeDryRun writes a record to the DryRunFile
+func (k *KafkaManager) writeDryRun(topic string, key []byte, value []byte) error {
+	record := dryRunRecord{
+		Topic: topic,
+		Key:   string(key),
+	}
+	if value != nil {
+		v := string(value)
+		record.Value = &v
+	}
+	line, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	k.dryRunMu.Lock()
+	defer k.dryRunMu.Unlock()
+	_, err = k.dryRun.Write(append(line, '\n'))
+	return err
+}
+
+// Flush writes the records the sink of the Webhook, JetStream or Embedded
+// driver holds. The records sent to Kafka are sent already.
+func (k *KafkaManager) Flush() error {
+	if k.sink == nil {
+		return nil
+	}
+	return k.sink.flush()
+}
+
+// Close closes the DryRunFile, if any, and the client creating the topics
+func (k *KafkaManager) Close() error {
+	if k.sink != nil {
+		k.sink.close()
+	}
+	k.topicsMu.Lock()
+	if k.admin != nil {
+		k.admin.Close()
+		k.admin = nil
+	}
+	k.topicsMu.Unlock()
+	if k.dryRun == nil {
+		return nil
+	}
+	return k.dryRun.Close()
+}
+
 var (
 	SourceSchema = &Schema{
 		Fields: []*Schema{
@@ -114,6 +406,17 @@ var (
 		Field:    "source",
 		Type:     SCHEMA_TYPE_STRUCT,
 	}
+
+	// TruncatedSourceSchema is the SourceSchema of records whose binary
+	// columns may be truncated, listing them in the "truncated" field
+	TruncatedSourceSchema = &Schema{
+		Fields: append(append([]*Schema{}, SourceSchema.Fields...),
+			NewSimpleSchemaField(SCHEMA_TYPE_STRING, true, "truncated")),
+		Optional: false,
+		Name:     "io.debezium.connector.mysql.Source",
+		Field:    "source",
+		Type:     SCHEMA_TYPE_STRUCT,
+	}
 )
 
 func NewKeySchema(tableIdent string, fields ColDefs) *Schema {
@@ -142,13 +445,19 @@ func NewBeforeAfter(tableIdent string, fields []*Schema) (*Schema, *Schema) {
 	return before, after
 }
 func NewEnvelopeSchema(tableIdent string, colDefs ColDefs) *Schema {
+	return NewEnvelopeSchemaWithSource(tableIdent, colDefs, SourceSchema)
+}
+
+// NewEnvelopeSchemaWithSource returns the envelope schema of a table with
+// the given source schema
+func NewEnvelopeSchemaWithSource(tableIdent string, colDefs ColDefs, sourceSchema *Schema) *Schema {
 	before, after := NewBeforeAfter(tableIdent, colDefs)
 	return &Schema{
 		Type: SCHEMA_TYPE_STRUCT,
 		Fields: []*Schema{
 			before,
 			after,
-			SourceSchema,
+			sourceSchema,
 			NewSimpleSchemaField(SCHEMA_TYPE_STRING, false, "op"),
 			NewSimpleSchemaField(SCHEMA_TYPE_INT64, true, "ts_ms"),
 		},
@@ -158,10 +467,33 @@ func NewEnvelopeSchema(tableIdent string, colDefs ColDefs) *Schema {
 	}
 }
 
+var (
+	HeartbeatKeySchema = &Schema{
+		Type:     SCHEMA_TYPE_STRUCT,
+		Name:     "io.debezium.connector.mysql.ServerNameKey",
+		Optional: false,
+		Fields: []*Schema{
+			NewSimpleSchemaField(SCHEMA_TYPE_STRING, false, "serverName"),
+		},
+	}
+	HeartbeatValueSchema = &Schema{
+		Type:     SCHEMA_TYPE_STRUCT,
+		Name:     "io.debezium.connector.common.Heartbeat",
+		Optional: false,
+		Fields: []*Schema{
+			NewSimpleSchemaField(SCHEMA_TYPE_INT64, false, "ts_ms"),
+		},
+	}
+)
+
 type DbzOutput struct {
 	Schema *Schema `json:"schema"`
 	// ValuePayload or Row
 	Payload interface{} `json:"payload"`
+	// encodedSchema, if any, is written in place of Schema
+	encodedSchema []byte
+	// protobuf, if any, is the message the payload is serialized as
+	protobuf *protobufSchema
 }
 
 type ValuePayload struct {
@@ -172,10 +504,10 @@ type ValuePayload struct {
 	TsMs   int64          `json:"ts_ms"`
 }
 
+// NewValuePayload returns a payload from the pool, to give back with
+// releaseValuePayload once serialized
 func NewValuePayload() *ValuePayload { // TODO source
-	return &ValuePayload{
-		Source: &SourcePayload{},
-	}
+	return valuePayloadPool.Get().(*ValuePayload)
 }
 
 type SourcePayload struct {
@@ -193,6 +525,8 @@ type SourcePayload struct {
 	Thread   interface{} `json:"thread"` // real type: optional<int64>
 	Db       string      `json:"db"`
 	Table    string      `json:"table"`
+	// Truncated lists the binary columns truncated, comma separated
+	Truncated interface{} `json:"truncated,omitempty"` // real type: optional<string>
 }
 
 type Schema struct {
@@ -210,37 +544,24 @@ type Row struct {
 	Values   []interface{}
 }
 
+// NewRow returns a row from the pool, to give back with releaseRow once
+// serialized
 func NewRow() *Row {
-	return &Row{}
+	return rowPool.Get().(*Row)
 }
 func (r *Row) AddField(key string, value interface{}) {
 	r.ColNames = append(r.ColNames, key)
 	r.Values = append(r.Values, value)
 }
 func (r *Row) MarshalJSON() ([]byte, error) {
-	buf := bytes.NewBuffer(nil)
-	buf.WriteString("{")
-	first := true
-	for i, _ := range r.ColNames {
-		if first {
-			first = false
-		} else {
-			buf.WriteString(",")
-		}
-		bsKey, err := json.Marshal(r.ColNames[i])
-		if err != nil {
-			return nil, err
-		}
-		buf.Write(bsKey)
-		buf.WriteByte(byte(':'))
-		bsValue, err := json.Marshal(r.Values[i])
-		if err != nil {
-			return nil, err
-		}
-		buf.Write(bsValue)
+	buf := bufferPool.Get().(*bytes.Buffer)
+	buf.Reset()
+	defer releaseBuffer(buf)
+	if err := writeRow(buf, r); err != nil {
+		return nil, err
 	}
-	buf.WriteString("}")
-	return buf.Bytes(), nil
+	// The caller keeps the bytes after the buffer goes back to the pool
+	return append([]byte(nil), buf.Bytes()...), nil
 }
 
 func NewSimpleSchemaField(theType SchemaType, optional bool, field string) *Schema {
@@ -273,6 +594,60 @@ func NewDecimalField(precision int, scale int, optional bool, field string, defa
 	}
 }
 
+// NewDecimalFieldWithMode returns the schema of a DECIMAL column encoded
+// in the given decimal handling mode
+func NewDecimalFieldWithMode(mode string, precision int, scale int, optional bool, field string, defaultValue interface{}) *Schema {
+	switch mode {
+	case DecimalHandlingString:
+		return NewSimpleSchemaWithDefaultField(SCHEMA_TYPE_STRING, optional, field, defaultValue)
+	case DecimalHandlingDouble:
+		return NewSimpleSchemaWithDefaultField(SCHEMA_TYPE_FLOAT64, optional, field, defaultValue)
+	default:
+		return NewDecimalField(precision, scale, optional, field, defaultValue)
+	}
+}
+
+// DecimalValue encodes the value of a DECIMAL column in the given decimal
+// handling mode
+func DecimalValue(mode string, value string) (interface{}, error) {
+	switch mode {
+	case DecimalHandlingString:
+		return value, nil
+	case DecimalHandlingDouble:
+		return strconv.ParseFloat(value, 64)
+	default:
+		return DecimalValueFromStringMysql(value), nil
+	}
+}
+
+// NewBinaryFieldWithMode returns the schema of a binary column encoded in
+// the given binary handling mode
+func NewBinaryFieldWithMode(mode string, optional bool, field string, defaultValue interface{}) *Schema {
+	switch mode {
+	case BinaryHandlingHex:
+		return NewSimpleSchemaWithDefaultField(SCHEMA_TYPE_STRING, optional, field, defaultValue)
+	default:
+		return NewSimpleSchemaWithDefaultField(SCHEMA_TYPE_BYTES, optional, field, defaultValue)
+	}
+}
+
+// BinaryValue encodes the value of a binary column in the given binary
+// handling mode, truncated to maxBytes if positive. It tells whether the
+// value was truncated.
+func BinaryValue(mode string, maxBytes int, value []byte) (interface{}, bool) {
+	truncated := false
+	if maxBytes > 0 && len(value) > maxBytes {
+		value = value[:maxBytes]
+		truncated = true
+	}
+	switch mode {
+	case BinaryHandlingHex:
+		return hex.EncodeToString(value), truncated
+	default:
+		return base64.StdEncoding.EncodeToString(value), truncated
+	}
+}
+
 var (
 	decimalNums [11]*big.Int
 )
@@ -490,6 +865,20 @@ func NewTimeStampField(theType SchemaType, optional bool, field string, defaultV
 		Version:  1,
 	}
 }
+
+// ZonedTimestampValue formats a TIMESTAMP value, read in UTC, as the
+// ISO-8601 string of a ZonedTimestamp in the location
+func ZonedTimestampValue(value string, loc *time.Location) string {
+	t, err := time.ParseInLocation("2006-01-02 15:04:05.999999", value, time.UTC)
+	if err != nil {
+		// zero dates
+		if len(value) < 11 {
+			return value
+		}
+		return value[:10] + "T" + value[11:] + "Z"
+	}
+	return t.In(loc).Format(time.RFC3339Nano)
+}
 func NewYearField(theType SchemaType, optional bool, field string, defaultValue interface{}) *Schema {
 	return &Schema{
 		Field:    field,
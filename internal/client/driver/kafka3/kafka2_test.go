@@ -2,7 +2,15 @@ package kafka3
 
 import (
 	"encoding/base64"
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"testing"
+	"time"
+
+	"github.com/actiontech/dtle/internal/config"
+	"github.com/actiontech/dtle/internal/config/mysql"
+	"github.com/actiontech/dtle/internal/g"
 )
 
 func TestDecimalValueFromStringMysql(t *testing.T) {
@@ -11,6 +19,160 @@ func TestDecimalValueFromStringMysql(t *testing.T) {
 	}
 }
 
+func TestDecimalValue(t *testing.T) {
+	test := func(mode string, value string, want interface{}) {
+		got, err := DecimalValue(mode, value)
+		if err != nil {
+			t.Fatalf("failed for %v in mode %q: %v", value, mode, err)
+		}
+		if got != want {
+			t.Fatalf("failed for %v in mode %q: got %v, want %v", value, mode, got, want)
+		}
+	}
+	test("", "0", base64.StdEncoding.EncodeToString([]byte{0}))
+	test(DecimalHandlingPrecise, "0", base64.StdEncoding.EncodeToString([]byte{0}))
+	test(DecimalHandlingString, "-123.45000", "-123.45000")
+	test(DecimalHandlingDouble, "-123.45000", -123.45)
+}
+
+func TestBinaryValue(t *testing.T) {
+	test := func(mode string, maxBytes int, value string, want interface{}, wantTruncated bool) {
+		got, truncated := BinaryValue(mode, maxBytes, []byte(value))
+		if got != want || truncated != wantTruncated {
+			t.Fatalf("failed for %q in mode %q: got %v %v, want %v %v", value, mode, got, truncated, want, wantTruncated)
+		}
+	}
+	test("", 0, "\x00ab", base64.StdEncoding.EncodeToString([]byte("\x00ab")), false)
+	test(BinaryHandlingBase64, 2, "\x00ab", base64.StdEncoding.EncodeToString([]byte("\x00a")), true)
+	test(BinaryHandlingHex, 0, "\x00ab", "006162", false)
+	test(BinaryHandlingHex, 3, "\x00ab", "006162", false)
+	test(BinaryHandlingHex, 1, "\x00ab", "00", true)
+}
+
+func TestZonedTimestampValue(t *testing.T) {
+	shanghai, err := ParseTimeZone("+08:00")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		value string
+		loc   *time.Location
+		want  string
+	}{
+		{"2019-03-01 16:30:00", time.UTC, "2019-03-01T16:30:00Z"},
+		{"2019-03-01 16:30:00", shanghai, "2019-03-02T00:30:00+08:00"},
+		{"2019-03-01 16:30:00.250000", shanghai, "2019-03-02T00:30:00.25+08:00"},
+		{"0000-00-00 00:00:00", shanghai, "0000-00-00T00:00:00Z"},
+	}
+	for _, tt := range tests {
+		if got := ZonedTimestampValue(tt.value, tt.loc); got != tt.want {
+			t.Errorf("ZonedTimestampValue(%q, %v) = %q, want %q", tt.value, tt.loc, got, tt.want)
+		}
+	}
+	if _, err := ParseTimeZone("+8"); err == nil {
+		t.Errorf("ParseTimeZone accepted an invalid offset")
+	}
+}
+
+func TestSkipColumn(t *testing.T) {
+	generated := &mysql.Column{Name: "total", Type: mysql.IntColumnType, IsGenerated: true}
+	blob := &mysql.Column{Name: "data", Type: mysql.BlobColumnType}
+	binaryKey := &mysql.Column{Name: "id", Type: mysql.BinaryColumnType, Key: "PRI"}
+
+	cfg := &KafkaConfig{}
+	if !skipColumn(cfg, generated) || skipColumn(cfg, blob) || skipColumn(cfg, binaryKey) {
+		t.Fatalf("default config")
+	}
+	cfg = &KafkaConfig{IncludeGeneratedColumns: true, BinaryHandlingMode: BinaryHandlingSkip}
+	if skipColumn(cfg, generated) || !skipColumn(cfg, blob) || skipColumn(cfg, binaryKey) {
+		t.Fatalf("generated columns included, binary columns skipped")
+	}
+}
+
+func TestKafkaManagerDryRun(t *testing.T) {
+	dir, err := ioutil.TempDir("", "kafka-dry-run")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "records.json")
+
+	k, err := NewKafkaManager(&KafkaConfig{Brokers: []string{"127.0.0.1:1"}, DryRunFile: path})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := k.Send("db1.shop.orders", []byte(`{"id":1}`), []byte(`{"op":"c"}`)); err != nil {
+		t.Fatal(err)
+	}
+	if err := k.Send("db1.shop.orders", []byte(`{"id":1}`), nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := k.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{"topic":"db1.shop.orders","key":"{\"id\":1}","value":"{\"op\":\"c\"}"}
+{"topic":"db1.shop.orders","key":"{\"id\":1}","value":null}
+`
+	if string(got) != want {
+		t.Fatalf("got %s, want %s", got, want)
+	}
+}
+
+func TestKafkaManagerFaultInjection(t *testing.T) {
+	dir, err := ioutil.TempDir("", "kafka-fault")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	cfg := &KafkaConfig{
+		DryRunFile:     filepath.Join(dir, "records.json"),
+		FaultInjection: &config.FaultInjectionConfig{KafkaSendFailureRate: 1},
+	}
+	send := func() error {
+		k, err := NewKafkaManager(cfg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer k.Close()
+		return k.Send("db1.shop.orders", []byte(`{"id":1}`), []byte(`{"op":"c"}`))
+	}
+
+	// the faults are only injected on the nodes allowing them
+	if err := send(); err != nil {
+		t.Fatalf("fault injected without %s: %v", g.ENV_FAULT_INJECTION, err)
+	}
+	os.Setenv(g.ENV_FAULT_INJECTION, "1")
+	defer os.Unsetenv(g.ENV_FAULT_INJECTION)
+	if _, ok := send().(*config.FaultError); !ok {
+		t.Fatalf("no fault injected")
+	}
+	cfg.FaultInjection.KafkaSendFailureRate = 0
+	if err := send(); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg.FaultInjection.KafkaSendFailureRate = 1.5
+	if _, err := NewKafkaManager(cfg); err == nil {
+		t.Fatalf("rate 1.5 accepted")
+	}
+}
+
+func TestKafkaConfigHeartbeatTopic(t *testing.T) {
+	cfg := &KafkaConfig{Topic: "db1"}
+	if got := cfg.HeartbeatTopic(); got != "__debezium-heartbeat.db1" {
+		t.Fatalf("got %v", got)
+	}
+	cfg.HeartbeatTopicPrefix = "hb"
+	if got := cfg.HeartbeatTopic(); got != "hb.db1" {
+		t.Fatalf("got %v", got)
+	}
+}
+
 
 func TestTimeValue(t *testing.T) {
 	test := func(value string, h,m,s,microsec int64, isNeg bool) {
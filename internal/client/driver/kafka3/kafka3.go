@@ -23,14 +23,15 @@ import (
 	"encoding/base64"
 	"encoding/binary"
 	"strings"
+	"sync"
 
 	"time"
 
 	"github.com/actiontech/dtle/internal/client/driver/mysql/binlog"
 	"github.com/actiontech/dtle/internal/config"
+	"github.com/actiontech/dtle/internal/g"
 	log "github.com/actiontech/dtle/internal/logger"
 	"github.com/actiontech/dtle/internal/models"
-	"github.com/actiontech/dtle/utils"
 )
 
 const (
@@ -51,8 +52,31 @@ type KafkaRunner struct {
 
 	kafkaConfig *KafkaConfig
 	kafkaMgr    *KafkaManager
+	heartbeat   *binlog.HeartbeatMonitor
+	// location is that of the TimeZone of kafkaConfig
+	location *time.Location
 
 	tables map[string](map[string]*config.Table)
+	// schemas are the cached schemas of the tables, by "schema.table"
+	schemas map[string]*tableSchemas
+	// snapshotRows are the rows of the tables emitted by the snapshot
+	snapshotRows *snapshotRowCounts
+	// topicMappings are the tables whose topic name was made valid
+	topicMappings *topicMappings
+	// recorder writes the messages received to the RecordFile, if any
+	recorder *fixtureRecorder
+	// now is the time of the records, that of the messages replayed
+	now func() time.Time
+
+	// subs are the subscriptions to the messages of the source, stopped by Drain
+	subs []*gonats.Subscription
+	// handleMu is held while a message of the source is handled
+	handleMu sync.Mutex
+
+	// enrichments are those of the Enrichments by the "schema.table" they
+	// enrich, and lookupTables by the "schema.table" they look up
+	enrichments  map[string][]*enrichment
+	lookupTables map[string][]*enrichment
 }
 
 func NewKafkaRunner(subject, tp string, maxPayload int, cfg *KafkaConfig, logger *log.Logger) *KafkaRunner {
@@ -60,12 +84,17 @@ func NewKafkaRunner(subject, tp string, maxPayload int, cfg *KafkaConfig, logger
 		"job": subject,
 	})
 	return &KafkaRunner{
-		subject:     subject,
-		kafkaConfig: cfg,
-		logger:      entry,
-		waitCh:      make(chan *models.WaitResult, 1),
-		shutdownCh:  make(chan struct{}),
-		tables:      make(map[string](map[string]*config.Table)),
+		subject:       subject,
+		kafkaConfig:   cfg,
+		logger:        entry,
+		waitCh:        make(chan *models.WaitResult, 1),
+		shutdownCh:    make(chan struct{}),
+		tables:        make(map[string](map[string]*config.Table)),
+		schemas:       make(map[string]*tableSchemas),
+		heartbeat:     &binlog.HeartbeatMonitor{},
+		snapshotRows:  &snapshotRowCounts{},
+		topicMappings: &topicMappings{},
+		now:           time.Now,
 	}
 }
 func (kr *KafkaRunner) ID() string {
@@ -101,13 +130,29 @@ func (kr *KafkaRunner) Shutdown() error {
 	}
 	kr.shutdown = true
 	close(kr.shutdownCh)
+	if kr.kafkaMgr != nil {
+		if err := kr.kafkaMgr.Close(); err != nil {
+			kr.logger.Warnf("kafka: failed to close: %v", err)
+		}
+	}
+	if kr.recorder != nil {
+		if err := kr.recorder.close(); err != nil {
+			kr.logger.Warnf("kafka: failed to close the RecordFile: %v", err)
+		}
+	}
+	kr.closeEnrichments()
 
 	kr.logger.Printf("kafka: Shutting down")
 	return nil
 }
 
 func (kr *KafkaRunner) Stats() (*models.TaskStatistics, error) {
-	taskResUsage := &models.TaskStatistics{}
+	taskResUsage := &models.TaskStatistics{
+		Heartbeat:      kr.heartbeat.Stat(),
+		Reconciliation: kr.snapshotRows.get(),
+		TopicMappings:  kr.topicMappings.list(),
+		Timestamp:      time.Now().UTC().UnixNano(),
+	}
 	return taskResUsage, nil
 }
 func (kr *KafkaRunner) initNatSubClient() (err error) {
@@ -122,15 +167,26 @@ func (kr *KafkaRunner) initNatSubClient() (err error) {
 	return nil
 }
 func (kr *KafkaRunner) Run() {
+	defer models.RecoverPanic(kr.onPanic)
 	kr.logger.Debugf("kafka. broker: %v", kr.kafkaConfig.Brokers)
 
-	var err error
-	kr.kafkaMgr, err = NewKafkaManager(kr.kafkaConfig)
+	err := kr.initManager()
 	if err != nil {
-		kr.logger.Errorf("failed to initialize kafka: %v", err.Error())
 		kr.onError(TaskStateDead, err)
 		return
 	}
+	if err = kr.initEnrichments(); err != nil {
+		kr.logger.Errorf("kafka: failed to initialize the Enrichments: %v", err.Error())
+		kr.onError(TaskStateDead, err)
+		return
+	}
+	if kr.kafkaConfig.RecordFile != "" {
+		if kr.recorder, err = newFixtureRecorder(kr.kafkaConfig.RecordFile); err != nil {
+			kr.logger.Errorf("failed to open the RecordFile: %v", err.Error())
+			kr.onError(TaskStateDead, err)
+			return
+		}
+	}
 
 	err = kr.initNatSubClient()
 	if err != nil {
@@ -146,6 +202,31 @@ func (kr *KafkaRunner) Run() {
 	}
 }
 
+// initManager parses the TimeZone and creates the manager sending the records
+func (kr *KafkaRunner) initManager() (err error) {
+	kr.location, err = kr.kafkaConfig.Location()
+	if err != nil {
+		kr.logger.Errorf("invalid TimeZone: %v", err.Error())
+		return err
+	}
+	kr.kafkaMgr, err = NewKafkaManager(kr.kafkaConfig)
+	if err != nil {
+		kr.logger.Errorf("failed to initialize kafka: %v", err.Error())
+		return err
+	}
+	if webhook, ok := kr.kafkaMgr.sink.(*webhookSink); ok {
+		webhook.warnf = kr.logger.Warnf
+	}
+	if cfg := kr.kafkaConfig.FaultInjection; cfg != nil {
+		if kr.kafkaMgr.faults == nil {
+			kr.logger.Warnf("kafka: FaultInjection ignored, %s is not set on the node", g.ENV_FAULT_INJECTION)
+		} else {
+			kr.logger.Warnf("kafka: FaultInjection enabled: %+v", *cfg)
+		}
+	}
+	return nil
+}
+
 func (kr *KafkaRunner) getOrSetTable(schemaName string, tableName string, table *config.Table) (*config.Table, error) {
 	a, ok := kr.tables[schemaName]
 	if !ok {
@@ -164,6 +245,7 @@ func (kr *KafkaRunner) getOrSetTable(schemaName string, tableName string, table
 	} else {
 		kr.logger.Debugf("kafka: new table info %v.%v", schemaName, tableName)
 		a[tableName] = table
+		kr.invalidateTableSchemas(schemaName, tableName)
 		return table, nil
 	}
 }
@@ -171,69 +253,187 @@ func (kr *KafkaRunner) getOrSetTable(schemaName string, tableName string, table
 func (kr *KafkaRunner) initiateStreaming() error {
 	var err error
 
-	_, err = kr.natsConn.Subscribe(fmt.Sprintf("%s_full", kr.subject), func(m *gonats.Msg) {
-		kr.logger.Debugf("kafka: recv a msg")
-		dumpData := &mysqlDriver.DumpEntry{}
-		if err := Decode(m.Data, dumpData); err != nil {
+	_, err = kr.natsConn.Subscribe(fmt.Sprintf("%s_capacity", kr.subject), func(m *gonats.Msg) {
+		defer models.RecoverPanic(kr.onPanic)
+		req := &mysqlDriver.CapacityRequest{}
+		if err := Decode(m.Data, req); err != nil {
 			kr.onError(TaskStateDead, err)
 			return
 		}
+		msg, err := mysqlDriver.Encode(&mysqlDriver.CapacityResult{Error: kr.checkCapacity(req.Tables)})
+		if err != nil {
+			kr.onError(TaskStateDead, err)
+			return
+		}
+		if err := kr.natsConn.Publish(m.Reply, msg); err != nil {
+			kr.onError(TaskStateDead, err)
+		}
+	})
+	if err != nil {
+		return err
+	}
 
-		if dumpData.DbSQL != "" || len(dumpData.TbSQL) > 0 {
-			kr.logger.Debugf("kafka. a sql dumpEntry")
-		} else {
-			// TODO cache table
-			table, err := kr.getOrSetTable(dumpData.TableSchema, dumpData.TableName, dumpData.Table)
-			if err != nil {
-				kr.onError(TaskStateDead, fmt.Errorf("DTLE_BUG kafka: unknown table structure"))
-				return
+	for _, kind := range []string{fixtureFull, fixtureFullComplete, fixtureIncr} {
+		kind := kind
+		sub, err := kr.natsConn.Subscribe(fmt.Sprintf("%s_%s", kr.subject, kind), func(m *gonats.Msg) {
+			defer models.RecoverPanic(kr.onPanic)
+			kr.handleMu.Lock()
+			defer kr.handleMu.Unlock()
+			if kr.recorder != nil {
+				if err := kr.recorder.record(kind, m.Data); err != nil {
+					kr.onError(TaskStateDead, err)
+					return
+				}
 			}
-
-			err = kr.kafkaTransformSnapshotData(table, dumpData)
-			if err != nil {
+			if err := kr.handle(kind, m.Data); err != nil {
 				kr.onError(TaskStateDead, err)
 				return
 			}
+			if err := kr.natsConn.Publish(m.Reply, nil); err != nil {
+				kr.onError(TaskStateDead, err)
+			}
+		})
+		if err != nil {
+			return err
 		}
+		kr.subs = append(kr.subs, sub)
+	}
 
-		if err := kr.natsConn.Publish(m.Reply, nil); err != nil {
-			kr.onError(TaskStateDead, err)
+	return nil
+}
+
+// Drain stops the subscriptions to the source, so that no new message is
+// received, and waits up to timeout for the message being handled, if any,
+// to be written to the sink.
+func (kr *KafkaRunner) Drain(timeout time.Duration) error {
+	for _, sub := range kr.subs {
+		if err := sub.Unsubscribe(); err != nil {
+			kr.logger.Warnf("kafka: failed to unsubscribe %v: %v", sub.Subject, err)
+		}
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		kr.handleMu.Lock()
+		defer kr.handleMu.Unlock()
+		if kr.kafkaMgr == nil {
+			done <- nil
 			return
 		}
-		kr.logger.Debugf("kafka: after publish nats reply")
-	})
-	if err != nil {
+		done <- kr.kafkaMgr.Flush()
+	}()
+	select {
+	case err := <-done:
 		return err
+	case <-time.After(timeout):
+		return fmt.Errorf("kafka: drain timed out after %v", timeout)
 	}
+}
 
-	_, err = kr.natsConn.Subscribe(fmt.Sprintf("%s_full_complete", kr.subject), func(m *gonats.Msg) {
-		if err := kr.natsConn.Publish(m.Reply, nil); err != nil {
-			kr.onError(TaskStateDead, err)
-		}
-	})
+// handle applies a message of the source, of the subject of kind. The
+// records are written to the sink before the message is acknowledged.
+func (kr *KafkaRunner) handle(kind string, data []byte) error {
+	switch kind {
+	case fixtureFull:
+		return kr.handleFull(data)
+	case fixtureFullComplete:
+		return kr.handleFullComplete(data)
+	case fixtureIncr:
+		return kr.handleIncr(data)
+	default:
+		return fmt.Errorf("kafka: unknown message kind %q", kind)
+	}
+}
 
-	_, err = kr.natsConn.Subscribe(fmt.Sprintf("%s_incr_hete", kr.subject), func(m *gonats.Msg) {
-		var binlogEntries binlog.BinlogEntries
-		if err := Decode(m.Data, &binlogEntries); err != nil {
-			kr.onError(TaskStateDead, err)
+func (kr *KafkaRunner) handleFull(data []byte) error {
+	kr.logger.Debugf("kafka: recv a msg")
+	dumpData := &mysqlDriver.DumpEntry{}
+	if err := Decode(data, dumpData); err != nil {
+		return err
+	}
+
+	if dumpData.DbSQL != "" || len(dumpData.TbSQL) > 0 {
+		kr.logger.Debugf("kafka. a sql dumpEntry")
+	} else {
+		// TODO cache table
+		table, err := kr.getOrSetTable(dumpData.TableSchema, dumpData.TableName, dumpData.Table)
+		if err != nil {
+			return fmt.Errorf("DTLE_BUG kafka: unknown table structure")
 		}
 
-		for _, binlogEntry := range binlogEntries.Entries {
-			err = kr.kafkaTransformDMLEventQuery(binlogEntry)
+		err = kr.kafkaTransformSnapshotData(table, dumpData)
+		if err != nil {
+			return err
 		}
+		kr.snapshotRows.add(dumpData.TableSchema, dumpData.TableName, int64(len(dumpData.ValuesX)))
+	}
+	return kr.kafkaMgr.Flush()
+}
 
-		if err := kr.natsConn.Publish(m.Reply, nil); err != nil {
-			kr.onError(TaskStateDead, err)
+func (kr *KafkaRunner) handleFullComplete(data []byte) error {
+	dumpData := &dumpStatResult{}
+	if err := Decode(data, dumpData); err != nil {
+		return err
+	}
+	if reconciliation := kr.snapshotRows.reconcile(dumpData); reconciliation != nil {
+		if reconciliation.Mismatches > 0 {
+			kr.logger.Warnf("kafka: %s", reconciliation.Message)
+		} else {
+			kr.logger.Printf("kafka: %s", reconciliation.Message)
 		}
-		kr.logger.Debugf("applier. incr. ack-recv. nEntries: %v", len(binlogEntries.Entries))
-	})
-	if err != nil {
+	}
+	return nil
+}
+
+func (kr *KafkaRunner) handleIncr(data []byte) error {
+	var binlogEntries binlog.BinlogEntries
+	if err := Decode(data, &binlogEntries); err != nil {
 		return err
 	}
 
+	for _, binlogEntry := range binlogEntries.Entries {
+		if err := kr.kafkaTransformDMLEventQuery(binlogEntry); err != nil {
+			return err
+		}
+	}
+	if binlogEntries.Heartbeat != nil {
+		if err := kr.sendHeartbeat(binlogEntries.Heartbeat); err != nil {
+			return err
+		}
+		kr.heartbeat.Observe(binlogEntries.Heartbeat)
+	}
+	// the batched records are posted before the entries are acknowledged
+	if err := kr.kafkaMgr.Flush(); err != nil {
+		return err
+	}
+	kr.logger.Debugf("applier. incr. ack-recv. nEntries: %v", len(binlogEntries.Entries))
 	return nil
 }
 
+// sendHeartbeat writes a heartbeat to the heartbeat topic, in the format of
+// the Debezium heartbeats
+func (kr *KafkaRunner) sendHeartbeat(h *binlog.Heartbeat) error {
+	keyPayload := NewRow()
+	keyPayload.AddField("serverName", kr.kafkaConfig.Topic)
+	valuePayload := NewRow()
+	valuePayload.AddField("ts_ms", h.Time/int64(time.Millisecond))
+
+	defer releaseRow(keyPayload)
+	defer releaseRow(valuePayload)
+
+	kBuf, err := kr.encodeRecord(DbzOutput{Schema: HeartbeatKeySchema, Payload: keyPayload})
+	if err != nil {
+		return err
+	}
+	defer releaseBuffer(kBuf)
+	vBuf, err := kr.encodeRecord(DbzOutput{Schema: HeartbeatValueSchema, Payload: valuePayload})
+	if err != nil {
+		return err
+	}
+	defer releaseBuffer(vBuf)
+	return kr.kafkaMgr.Send(kr.kafkaConfig.HeartbeatTopic(), kBuf.Bytes(), vBuf.Bytes())
+}
+
 // TODO move to one place
 func Decode(data []byte, vPtr interface{}) (err error) {
 	msg, err := snappy.Decode(nil, data)
@@ -244,6 +444,10 @@ func Decode(data []byte, vPtr interface{}) (err error) {
 	return gob.NewDecoder(bytes.NewBuffer(msg)).Decode(vPtr)
 }
 
+func (kr *KafkaRunner) onPanic(err *models.PanicError) {
+	kr.onError(TaskStateRestart, err)
+}
+
 func (kr *KafkaRunner) onError(state int, err error) {
 	if kr.shutdown {
 		return
@@ -273,6 +477,10 @@ func (kr *KafkaRunner) kafkaTransformSnapshotData(table *config.Table, value *my
 	var err error
 
 	tableIdent := fmt.Sprintf("%v.%v.%v", kr.kafkaMgr.Cfg.Topic, table.TableSchema, table.TableName)
+	schemas, err := kr.getTableSchemas(table, tableIdent)
+	if err != nil {
+		return fmt.Errorf("kafka: serialization error: %v", err)
+	}
 	kr.logger.Debugf("kafka: kafkaTransformSnapshotData value: %v", value.ValuesX)
 	for _, rowValues := range value.ValuesX {
 		keyPayload := NewRow()
@@ -291,16 +499,18 @@ func (kr *KafkaRunner) kafkaTransformSnapshotData(table *config.Table, value *my
 		valuePayload.Source.Table = table.TableName
 		valuePayload.Op = RECORD_OP_INSERT
 		valuePayload.Source.Query = nil
-		valuePayload.TsMs = utils.CurrentTimeMillis()
+		valuePayload.TsMs = kr.now().UnixNano() / int64(time.Millisecond)
 
 		valuePayload.Before = nil
 		valuePayload.After = NewRow()
 
 		columnList := table.OriginalTableColumns.ColumnList()
-		valueColDef, keyColDef := kafkaColumnListToColDefs(table.OriginalTableColumns)
-		keySchema := NewKeySchema(tableIdent, keyColDef)
 
+		var truncated []string
 		for i, _ := range columnList {
+			if skipColumn(kr.kafkaConfig, &columnList[i]) {
+				continue
+			}
 			var value interface{}
 
 			if *rowValues[i] != nil {
@@ -335,22 +545,21 @@ func (kr *KafkaRunner) kafkaTransformSnapshotData(table *config.Table, value *my
 						return err
 					}
 				case mysql.DecimalColumnType:
-					value = DecimalValueFromStringMysql(valueStr)
+					value, err = DecimalValue(kr.kafkaConfig.DecimalHandlingMode, valueStr)
+					if err != nil {
+						return err
+					}
 				case mysql.TimeColumnType:
 					if valueStr != "" && columnList[i].ColumnType == "timestamp" {
-						value = valueStr[:10] + "T" + valueStr[11:] + "Z"
+						value = ZonedTimestampValue(valueStr, kr.location)
 					} else {
 						value = TimeValue(valueStr)
 					}
 
-				case mysql.BinaryColumnType:
-					value = base64.StdEncoding.EncodeToString([]byte(valueStr))
+				case mysql.BinaryColumnType, mysql.BlobColumnType, mysql.VarbinaryColumnType:
+					value = kr.binaryValue(&columnList[i], []byte(valueStr), &truncated)
 				case mysql.BitColumnType:
 					value = base64.StdEncoding.EncodeToString([]byte(valueStr))
-				case mysql.BlobColumnType:
-					value = base64.StdEncoding.EncodeToString([]byte(valueStr))
-				case mysql.VarbinaryColumnType:
-					value = base64.StdEncoding.EncodeToString([]byte(valueStr))
 				case mysql.DateColumnType, mysql.DateTimeColumnType:
 					if valueStr != "" && columnList[i].ColumnType == "datetime" {
 						value = DateTimeValue(valueStr)
@@ -370,6 +579,12 @@ func (kr *KafkaRunner) kafkaTransformSnapshotData(table *config.Table, value *my
 			} else {
 				value = nil
 			}
+			if schemas.casts != nil && schemas.casts[i] != nil {
+				value, err = schemas.casts[i].castValue(&columnList[i], *rowValues[i], kr.location)
+				if err != nil {
+					return err
+				}
+			}
 
 			if columnList[i].IsPk() {
 				keyPayload.AddField(columnList[i].Name, value)
@@ -378,28 +593,39 @@ func (kr *KafkaRunner) kafkaTransformSnapshotData(table *config.Table, value *my
 			kr.logger.Debugf("kafka: kafkaTransformSnapshotData rowvalue: %v", value)
 			valuePayload.After.AddField(columnList[i].Name, value)
 		}
+		if err := kr.enrich(table, valuePayload.After, rowValues); err != nil {
+			return err
+		}
 
-		valueSchema := NewEnvelopeSchema(tableIdent, valueColDef)
-
+		if len(truncated) > 0 {
+			valuePayload.Source.Truncated = strings.Join(truncated, ",")
+		}
 		k := DbzOutput{
-			Schema:  keySchema,
-			Payload: keyPayload,
+			encodedSchema: schemas.key,
+			protobuf:      schemas.protobufKey,
+			Payload:       keyPayload,
 		}
 		v := DbzOutput{
-			Schema:  valueSchema,
-			Payload: valuePayload,
+			encodedSchema: schemas.value,
+			protobuf:      schemas.protobufValue,
+			Payload:       valuePayload,
 		}
 
-		kBs, err := json.Marshal(k)
+		kBuf, err := kr.encodeRecord(k)
 		if err != nil {
 			return fmt.Errorf("kafka: serialization error: %v", err)
 		}
-		vBs, err := json.Marshal(v)
+		vBuf, err := kr.encodeRecord(v)
 		if err != nil {
+			releaseBuffer(kBuf)
 			return fmt.Errorf("kafka: serialization error: %v", err)
 		}
+		releaseRow(keyPayload)
+		releaseValuePayload(valuePayload)
 		//vBs = []byte(strings.Replace(string(vBs), "\"field\":\"snapshot\"", "\"default\":false,\"field\":\"snapshot\"", -1))
-		err = kr.kafkaMgr.Send(tableIdent, kBs, vBs)
+		err = kr.kafkaMgr.Send(schemas.topic, kBuf.Bytes(), vBuf.Bytes())
+		releaseBuffer(kBuf)
+		releaseBuffer(vBuf)
 		if err != nil {
 			return err
 		}
@@ -417,6 +643,8 @@ func (kr *KafkaRunner) kafkaTransformDMLEventQuery(dmlEvent *binlog.BinlogEntry)
 			return err
 		}
 
+		kr.invalidateLookups(table, dataEvent)
+
 		// skipping DDL
 		if dataEvent.DML == binlog.NotDML {
 			continue
@@ -443,11 +671,19 @@ func (kr *KafkaRunner) kafkaTransformDMLEventQuery(dmlEvent *binlog.BinlogEntry)
 
 		tableIdent := fmt.Sprintf("%v.%v.%v", kr.kafkaMgr.Cfg.Topic, table.TableSchema, table.TableName)
 
+		schemas, err := kr.getTableSchemas(table, tableIdent)
+		if err != nil {
+			return err
+		}
+
 		keyPayload := NewRow()
 		colList := table.OriginalTableColumns.ColumnList()
-		colDefs, keyColDefs := kafkaColumnListToColDefs(table.OriginalTableColumns)
 
+		var truncated []string
 		for i, _ := range colList {
+			if skipColumn(kr.kafkaConfig, &colList[i]) {
+				continue
+			}
 			colName := colList[i].Name
 
 			var beforeValue interface{}
@@ -464,10 +700,16 @@ func (kr *KafkaRunner) kafkaTransformDMLEventQuery(dmlEvent *binlog.BinlogEntry)
 			case mysql.DecimalColumnType:
 				// nil: either entire row does not exist or this field is NULL
 				if beforeValue != nil {
-					beforeValue = DecimalValueFromStringMysql(beforeValue.(string))
+					beforeValue, err = DecimalValue(kr.kafkaConfig.DecimalHandlingMode, beforeValue.(string))
+					if err != nil {
+						return err
+					}
 				}
 				if afterValue != nil {
-					afterValue = DecimalValueFromStringMysql(afterValue.(string))
+					afterValue, err = DecimalValue(kr.kafkaConfig.DecimalHandlingMode, afterValue.(string))
+					if err != nil {
+						return err
+					}
 				}
 			case mysql.BigIntColumnType:
 				if colList[i].IsUnsigned {
@@ -480,12 +722,12 @@ func (kr *KafkaRunner) kafkaTransformDMLEventQuery(dmlEvent *binlog.BinlogEntry)
 				}
 			case mysql.TimeColumnType, mysql.TimestampColumnType:
 				if beforeValue != nil && colList[i].ColumnType == "timestamp" {
-					beforeValue = beforeValue.(string)[:10] + "T" + beforeValue.(string)[11:] + "Z"
+					beforeValue = ZonedTimestampValue(beforeValue.(string), kr.location)
 				} else if beforeValue != nil {
 					beforeValue = TimeValue(beforeValue.(string))
 				}
 				if afterValue != nil && colList[i].ColumnType == "timestamp" {
-					afterValue = afterValue.(string)[:10] + "T" + afterValue.(string)[11:] + "Z"
+					afterValue = ZonedTimestampValue(afterValue.(string), kr.location)
 				} else if afterValue != nil {
 					afterValue = TimeValue(afterValue.(string))
 				}
@@ -500,20 +742,20 @@ func (kr *KafkaRunner) kafkaTransformDMLEventQuery(dmlEvent *binlog.BinlogEntry)
 				} else if afterValue != nil {
 					afterValue = DateValue(afterValue.(string))
 				}
-			case mysql.VarbinaryColumnType:
+			case mysql.VarbinaryColumnType, mysql.BlobColumnType:
 				if beforeValue != nil {
-					beforeValue = beforeValue.(string)
+					beforeValue = kr.binaryValue(&colList[i], binaryBytes(beforeValue), &truncated)
 				}
 				if afterValue != nil {
-					afterValue = afterValue.(string)
+					afterValue = kr.binaryValue(&colList[i], binaryBytes(afterValue), &truncated)
 				}
 			case mysql.BinaryColumnType:
 
 				if beforeValue != nil {
-					beforeValue = getBinaryValue(colList[i].ColumnType, beforeValue.(string))
+					beforeValue = kr.binaryValue(&colList[i], getBinaryValue(colList[i].ColumnType, beforeValue.(string)), &truncated)
 				}
 				if afterValue != nil {
-					afterValue = getBinaryValue(colList[i].ColumnType, afterValue.(string))
+					afterValue = kr.binaryValue(&colList[i], getBinaryValue(colList[i].ColumnType, afterValue.(string)), &truncated)
 				}
 			case mysql.TinytextColumnType:
 				//println("beforeValue:",string(beforeValue.([]uint8)))
@@ -549,6 +791,12 @@ func (kr *KafkaRunner) kafkaTransformDMLEventQuery(dmlEvent *binlog.BinlogEntry)
 					afterValue = getSetValue(num, columnType)
 				}
 
+			case mysql.JSONColumnType:
+				// A partial update replicated as a patch: the changes are
+				// written instead of the value
+				if patch, ok := afterValue.(mysql.JSONPatch); ok {
+					afterValue = fmt.Sprintf(`{"$patch":%s}`, patch.String())
+				}
 			case mysql.BitColumnType:
 				if beforeValue != nil {
 					beforeValue = getBitValue(colList[i].ColumnType, beforeValue.(int64))
@@ -559,6 +807,19 @@ func (kr *KafkaRunner) kafkaTransformDMLEventQuery(dmlEvent *binlog.BinlogEntry)
 			default:
 				// do nothing
 			}
+			if schemas.casts != nil && schemas.casts[i] != nil {
+				cast := schemas.casts[i]
+				if before != nil {
+					if beforeValue, err = cast.castValue(&colList[i], *dataEvent.WhereColumnValues.AbstractValues[i], kr.location); err != nil {
+						return err
+					}
+				}
+				if after != nil {
+					if afterValue, err = cast.castValue(&colList[i], *dataEvent.NewColumnValues.AbstractValues[i], kr.location); err != nil {
+						return err
+					}
+				}
+			}
 
 			if colList[i].IsPk() {
 				if before != nil {
@@ -579,6 +840,16 @@ func (kr *KafkaRunner) kafkaTransformDMLEventQuery(dmlEvent *binlog.BinlogEntry)
 				after.AddField(colName, afterValue)
 			}
 		}
+		if before != nil {
+			if err := kr.enrich(table, before, dataEvent.WhereColumnValues.AbstractValues); err != nil {
+				return err
+			}
+		}
+		if after != nil {
+			if err := kr.enrich(table, after, dataEvent.NewColumnValues.AbstractValues); err != nil {
+				return err
+			}
+		}
 
 		valuePayload := NewValuePayload()
 		valuePayload.Before = before
@@ -587,7 +858,7 @@ func (kr *KafkaRunner) kafkaTransformDMLEventQuery(dmlEvent *binlog.BinlogEntry)
 		valuePayload.Source.Version = "0.0.1"
 		valuePayload.Source.Name = kr.kafkaMgr.Cfg.Topic
 		valuePayload.Source.ServerID = 1 // TODO
-		valuePayload.Source.TsSec = time.Now().Unix()
+		valuePayload.Source.TsSec = kr.now().Unix()
 		valuePayload.Source.Gtid = dmlEvent.Coordinates.GetGtidForThisTx()
 		valuePayload.Source.File = dmlEvent.Coordinates.LogFile
 		valuePayload.Source.Pos = dataEvent.LogPos
@@ -600,50 +871,54 @@ func (kr *KafkaRunner) kafkaTransformDMLEventQuery(dmlEvent *binlog.BinlogEntry)
 		valuePayload.Source.Db = dataEvent.DatabaseName
 		valuePayload.Source.Table = dataEvent.TableName
 		valuePayload.Op = op
-		valuePayload.TsMs = utils.CurrentTimeMillis()
-
-		valueSchema := NewEnvelopeSchema(tableIdent, colDefs)
+		valuePayload.TsMs = kr.now().UnixNano() / int64(time.Millisecond)
+		if len(truncated) > 0 {
+			valuePayload.Source.Truncated = strings.Join(truncated, ",")
+		}
 
-		keySchema := NewKeySchema(tableIdent, keyColDefs)
 		k := DbzOutput{
-			Schema:  keySchema,
-			Payload: keyPayload,
+			encodedSchema: schemas.key,
+			protobuf:      schemas.protobufKey,
+			Payload:       keyPayload,
 		}
 		v := DbzOutput{
-			Schema:  valueSchema,
-			Payload: valuePayload,
+			encodedSchema: schemas.value,
+			protobuf:      schemas.protobufValue,
+			Payload:       valuePayload,
 		}
-		kBs, err := json.Marshal(k)
+		kBuf, err := kr.encodeRecord(k)
 		if err != nil {
 			return err
 		}
-		vBs, err := json.Marshal(v)
+		vBuf, err := kr.encodeRecord(v)
 		if err != nil {
+			releaseBuffer(kBuf)
 			return err
 		}
+		releaseRow(keyPayload)
+		releaseValuePayload(valuePayload)
 		//	vBs = []byte(strings.Replace(string(vBs), "\"field\":\"snapshot\"", "\"default\":false,\"field\":\"snapshot\"", -1))
-		err = kr.kafkaMgr.Send(tableIdent, kBs, vBs)
-		if err != nil {
-			return err
+		// a flat delete may be written as its tombstone only
+		if vBuf != nil {
+			err = kr.kafkaMgr.Send(schemas.topic, kBuf.Bytes(), vBuf.Bytes())
+			releaseBuffer(vBuf)
+			if err != nil {
+				releaseBuffer(kBuf)
+				return err
+			}
+			kr.logger.Debugf("kafka: sent one msg")
 		}
-		kr.logger.Debugf("kafka: sent one msg")
 
 		// tombstone event for DELETE
 		if dataEvent.DML == binlog.DeleteDML {
-			v2 := DbzOutput{
-				Schema:  nil,
-				Payload: nil,
-			}
-			v2Bs, err := json.Marshal(v2)
-			if err != nil {
-				return err
-			}
-			err = kr.kafkaMgr.Send(tableIdent, kBs, v2Bs)
+			err = kr.kafkaMgr.Send(schemas.topic, kBuf.Bytes(), kr.tombstoneValue())
 			if err != nil {
+				releaseBuffer(kBuf)
 				return err
 			}
 			kr.logger.Debugf("kafka: sent one msg")
 		}
+		releaseBuffer(kBuf)
 	}
 
 	return nil
@@ -663,11 +938,12 @@ func getSetValue(num int64, set string) string {
 	return value[0 : len(value)-1]
 }
 
-func getBinaryValue(binary string, value string) string {
+// getBinaryValue pads the value of a BINARY column to its length
+func getBinaryValue(binary string, value string) []byte {
 	binaryLen := binary[7 : len(binary)-1]
 	lens, err := strconv.Atoi(binaryLen)
 	if err != nil {
-		return ""
+		return nil
 	}
 	valueLen := len(value)
 	for i := 0; i < lens-valueLen; i++ {
@@ -678,7 +954,65 @@ func getBinaryValue(binary string, value string) string {
 	if lens-valueLen > 0 {
 		buffer.Write(make([]byte, lens-valueLen))
 	}
-	return base64.StdEncoding.EncodeToString(buffer.Bytes())
+	return buffer.Bytes()
+}
+
+// binaryBytes returns the bytes of a binary value read from the binlog
+func binaryBytes(value interface{}) []byte {
+	switch v := value.(type) {
+	case []byte:
+		return v
+	case string:
+		return []byte(v)
+	default:
+		return []byte(fmt.Sprintf("%v", v))
+	}
+}
+
+// isBinaryColumn tells whether a column is encoded in the binary handling
+// mode
+func isBinaryColumn(col *mysql.Column) bool {
+	switch col.Type {
+	case mysql.BinaryColumnType, mysql.VarbinaryColumnType, mysql.BlobColumnType:
+		return true
+	default:
+		return false
+	}
+}
+
+// skipColumn tells whether a column is left out of the records
+func skipColumn(cfg *KafkaConfig, col *mysql.Column) bool {
+	if col.IsGenerated && !col.IsPk() && !cfg.IncludeGeneratedColumns {
+		return true
+	}
+	return cfg.BinaryHandlingMode == BinaryHandlingSkip && isBinaryColumn(col) && !col.IsPk()
+}
+
+// binaryValue encodes the value of a binary column, adding the column to
+// truncated if it was. The columns of the primary key are not truncated.
+func (kr *KafkaRunner) binaryValue(col *mysql.Column, value []byte, truncated *[]string) interface{} {
+	maxBytes := kr.kafkaConfig.BinaryMaxBytes
+	if col.IsPk() {
+		maxBytes = 0
+	}
+	v, isTruncated := BinaryValue(kr.kafkaConfig.BinaryHandlingMode, maxBytes, value)
+	if isTruncated {
+		for _, name := range *truncated {
+			if name == col.Name {
+				return v
+			}
+		}
+		*truncated = append(*truncated, col.Name)
+	}
+	return v
+}
+
+// sourceSchema returns the schema of the source of the records
+func (kr *KafkaRunner) sourceSchema() *Schema {
+	if kr.kafkaConfig.BinaryMaxBytes > 0 {
+		return TruncatedSourceSchema
+	}
+	return SourceSchema
 }
 func getBitValue(bit string, value int64) string {
 	bitLen := bit[4 : len(bit)-1]
@@ -693,9 +1027,12 @@ func getBitValue(bit string, value int64) string {
 	return base64.StdEncoding.EncodeToString(buf[0:bitNumber])
 }
 
-func kafkaColumnListToColDefs(colList *mysql.ColumnList) (valColDefs ColDefs, keyColDefs ColDefs) {
+func kafkaColumnListToColDefs(colList *mysql.ColumnList, cfg *KafkaConfig) (valColDefs ColDefs, keyColDefs ColDefs) {
 	cols := colList.ColumnList()
 	for i, _ := range cols {
+		if skipColumn(cfg, &cols[i]) {
+			continue
+		}
 		var field *Schema
 		defaultValue := cols[i].Default
 		if defaultValue == "" {
@@ -710,12 +1047,8 @@ func kafkaColumnListToColDefs(colList *mysql.ColumnList) (valColDefs ColDefs, ke
 
 		case mysql.BitColumnType:
 			field = NewBitsField(optional, fieldName, cols[i].ColumnType[4:len(cols[i].ColumnType)-1], defaultValue)
-		case mysql.BlobColumnType:
-			field = NewSimpleSchemaWithDefaultField(SCHEMA_TYPE_BYTES, optional, fieldName, defaultValue)
-		case mysql.BinaryColumnType:
-			field = NewSimpleSchemaWithDefaultField(SCHEMA_TYPE_BYTES, optional, fieldName, defaultValue)
-		case mysql.VarbinaryColumnType:
-			field = NewSimpleSchemaWithDefaultField(SCHEMA_TYPE_BYTES, optional, fieldName, defaultValue)
+		case mysql.BlobColumnType, mysql.BinaryColumnType, mysql.VarbinaryColumnType:
+			field = NewBinaryFieldWithMode(cfg.BinaryHandlingMode, optional, fieldName, defaultValue)
 
 		case mysql.TextColumnType:
 			fallthrough
@@ -754,7 +1087,7 @@ func kafkaColumnListToColDefs(colList *mysql.ColumnList) (valColDefs ColDefs, ke
 			field = NewSimpleSchemaWithDefaultField(SCHEMA_TYPE_FLOAT64, optional, fieldName, defaultValue)
 
 		case mysql.DecimalColumnType:
-			field = NewDecimalField(cols[i].Precision, cols[i].Scale, optional, fieldName, defaultValue)
+			field = NewDecimalFieldWithMode(cfg.DecimalHandlingMode, cols[i].Precision, cols[i].Scale, optional, fieldName, defaultValue)
 
 		case mysql.DateColumnType:
 			if cols[i].ColumnType == "datetime" {
@@ -0,0 +1,556 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package kafka3
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/gogo/protobuf/proto"
+	"github.com/gogo/protobuf/protoc-gen-gogo/descriptor"
+)
+
+// DefaultDescriptorTopicSuffix suffixes the Topic of the job to name the
+// topic the protobuf descriptors are written to without a schema registry
+const DefaultDescriptorTopicSuffix = ".protobuf-descriptors"
+
+// ProtobufConfig is how the records are serialized with the protobuf
+// converter
+type ProtobufConfig struct {
+	// SchemaRegistryURL is the URL of a Confluent schema registry the
+	// schemas of a topic are registered in, under the subjects "<topic>-key"
+	// and "<topic>-value". The records are then framed as by the protobuf
+	// serializer of Confluent. Credentials may be given in the URL.
+	SchemaRegistryURL string
+	// DescriptorTopic is the topic the descriptors of the tables are written
+	// to without a schema registry, keyed by the topic of a table. Defaults
+	// to the Topic of the job suffixed with DefaultDescriptorTopicSuffix.
+	DescriptorTopic string
+}
+
+func (c *KafkaConfig) validateConverter() error {
+	if c.Converter != CONVERTER_PROTOBUF {
+		return nil
+	}
+	if c.flat() {
+		return fmt.Errorf("the protobuf Converter writes the %s MessageFormat only", MessageFormatEnvelope)
+	}
+	if c.Protobuf != nil && c.Protobuf.SchemaRegistryURL != "" {
+		u, err := url.Parse(c.Protobuf.SchemaRegistryURL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+			return fmt.Errorf("invalid Protobuf SchemaRegistryURL %q: not an http or https URL",
+				c.Protobuf.SchemaRegistryURL)
+		}
+	}
+	return nil
+}
+
+// descriptorTopic returns the topic the descriptors are written to
+func (c *KafkaConfig) descriptorTopic() string {
+	if c.Protobuf != nil && c.Protobuf.DescriptorTopic != "" {
+		return c.Protobuf.DescriptorTopic
+	}
+	return c.Topic + DefaultDescriptorTopicSuffix
+}
+
+// protoField is a field of a generated message. All are optional, a null
+// column being an absent field.
+type protoField struct {
+	name   string
+	number int32
+	typ    descriptor.FieldDescriptorProto_Type
+	// message is the type of a message field
+	message *protoMessage
+}
+
+// protoMessage is a message generated from the schema of a table
+type protoMessage struct {
+	name   string
+	fields []*protoField
+	nested []*protoMessage
+	// byColumn are the fields of a row message by the name of their column
+	byColumn map[string]*protoField
+}
+
+// protobufSchema is a generated message the key or value of the records of
+// a table are encoded as
+type protobufSchema struct {
+	message *protoMessage
+	// proto is the file of the message, in the protobuf language
+	proto string
+	// file is the descriptor of the file
+	file *descriptor.FileDescriptorProto
+	// header prefixes the records registered in a schema registry: the magic
+	// byte, the schema id and the index of the first message
+	header []byte
+}
+
+// newRowMessage returns the message of the rows with the columns of colDefs
+func newRowMessage(name string, colDefs ColDefs) *protoMessage {
+	m := &protoMessage{name: name, byColumn: make(map[string]*protoField)}
+	names := make(map[string]bool)
+	for i, col := range colDefs {
+		fieldName := protoIdentifier(col.Field)
+		for names[fieldName] {
+			fieldName += "_"
+		}
+		names[fieldName] = true
+		f := &protoField{name: fieldName, number: int32(i + 1), typ: protoType(col.Type)}
+		m.fields = append(m.fields, f)
+		m.byColumn[col.Field] = f
+	}
+	return m
+}
+
+// newSourceMessage returns the message of the source, its fields in the
+// order writeProtoSource writes them
+func newSourceMessage() *protoMessage {
+	m := &protoMessage{name: "Source"}
+	for i, f := range []struct {
+		name string
+		typ  descriptor.FieldDescriptorProto_Type
+	}{
+		{"version", descriptor.FieldDescriptorProto_TYPE_STRING},
+		{"name", descriptor.FieldDescriptorProto_TYPE_STRING},
+		{"server_id", descriptor.FieldDescriptorProto_TYPE_INT64},
+		{"ts_sec", descriptor.FieldDescriptorProto_TYPE_INT64},
+		{"gtid", descriptor.FieldDescriptorProto_TYPE_STRING},
+		{"file", descriptor.FieldDescriptorProto_TYPE_STRING},
+		{"pos", descriptor.FieldDescriptorProto_TYPE_INT64},
+		{"query", descriptor.FieldDescriptorProto_TYPE_STRING},
+		{"row", descriptor.FieldDescriptorProto_TYPE_INT32},
+		{"snapshot", descriptor.FieldDescriptorProto_TYPE_BOOL},
+		{"thread", descriptor.FieldDescriptorProto_TYPE_INT64},
+		{"db", descriptor.FieldDescriptorProto_TYPE_STRING},
+		{"table", descriptor.FieldDescriptorProto_TYPE_STRING},
+		{"truncated", descriptor.FieldDescriptorProto_TYPE_STRING},
+	} {
+		m.fields = append(m.fields, &protoField{name: f.name, number: int32(i + 1), typ: f.typ})
+	}
+	return m
+}
+
+// newEnvelopeMessage returns the message of the values, the envelope of
+// Debezium: the before and after images, the source, op and ts_ms
+func newEnvelopeMessage(valueColDefs ColDefs) *protoMessage {
+	value := newRowMessage("Value", valueColDefs)
+	source := newSourceMessage()
+	return &protoMessage{
+		name:   "Envelope",
+		nested: []*protoMessage{value, source},
+		fields: []*protoField{
+			{name: "before", number: 1, typ: descriptor.FieldDescriptorProto_TYPE_MESSAGE, message: value},
+			{name: "after", number: 2, typ: descriptor.FieldDescriptorProto_TYPE_MESSAGE, message: value},
+			{name: "source", number: 3, typ: descriptor.FieldDescriptorProto_TYPE_MESSAGE, message: source},
+			{name: "op", number: 4, typ: descriptor.FieldDescriptorProto_TYPE_STRING},
+			{name: "ts_ms", number: 5, typ: descriptor.FieldDescriptorProto_TYPE_INT64},
+		},
+	}
+}
+
+// newProtobufSchemas generates the key and value messages of the records of
+// a table sent to topic
+func newProtobufSchemas(topic string, valueColDefs, keyColDefs ColDefs) (key, value *protobufSchema) {
+	pkg := protoPackage(topic)
+	return newProtobufSchema(topic+"-key.proto", pkg, newRowMessage("Key", keyColDefs)),
+		newProtobufSchema(topic+"-value.proto", pkg, newEnvelopeMessage(valueColDefs))
+}
+
+func newProtobufSchema(fileName, pkg string, m *protoMessage) *protobufSchema {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "syntax = \"proto2\";\n\npackage %s;\n\n", pkg)
+	m.writeProto(&b, "")
+	return &protobufSchema{
+		message: m,
+		proto:   b.String(),
+		file: &descriptor.FileDescriptorProto{
+			Name:        proto.String(fileName),
+			Package:     proto.String(pkg),
+			Syntax:      proto.String("proto2"),
+			MessageType: []*descriptor.DescriptorProto{m.descriptor("." + pkg)},
+		},
+	}
+}
+
+func (m *protoMessage) writeProto(b *bytes.Buffer, indent string) {
+	fmt.Fprintf(b, "%smessage %s {\n", indent, m.name)
+	for _, nested := range m.nested {
+		nested.writeProto(b, indent+"  ")
+	}
+	for _, f := range m.fields {
+		typ := strings.ToLower(strings.TrimPrefix(f.typ.String(), "TYPE_"))
+		if f.message != nil {
+			typ = f.message.name
+		}
+		fmt.Fprintf(b, "%s  optional %s %s = %d;\n", indent, typ, f.name, f.number)
+	}
+	fmt.Fprintf(b, "%s}\n", indent)
+}
+
+// descriptor returns the descriptor of the message, declared in scope
+func (m *protoMessage) descriptor(scope string) *descriptor.DescriptorProto {
+	name := scope + "." + m.name
+	d := &descriptor.DescriptorProto{Name: proto.String(m.name)}
+	for _, nested := range m.nested {
+		d.NestedType = append(d.NestedType, nested.descriptor(name))
+	}
+	for _, f := range m.fields {
+		field := &descriptor.FieldDescriptorProto{
+			Name:     proto.String(f.name),
+			JsonName: proto.String(f.name),
+			Number:   proto.Int32(f.number),
+			Label:    descriptor.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+			Type:     f.typ.Enum(),
+		}
+		if f.message != nil {
+			// the messages of the fields are nested in that of the field
+			field.TypeName = proto.String(name + "." + f.message.name)
+		}
+		d.Field = append(d.Field, field)
+	}
+	return d
+}
+
+// protobufDescriptorSet serializes the descriptors of the key and value of
+// a table, as a FileDescriptorSet
+func protobufDescriptorSet(key, value *protobufSchema) ([]byte, error) {
+	return proto.Marshal(&descriptor.FileDescriptorSet{
+		File: []*descriptor.FileDescriptorProto{key.file, value.file},
+	})
+}
+
+// initProtobufSchemas generates the messages of the records of a table, and
+// registers them, or writes their descriptors to the descriptor topic
+// without a schema registry
+func (kr *KafkaRunner) initProtobufSchemas(schemas *tableSchemas) error {
+	valueColDefs, keyColDefs := kr.tableColDefs(schemas.table, schemas.casts)
+	key, value := newProtobufSchemas(schemas.topic, valueColDefs, keyColDefs)
+	if cfg := kr.kafkaConfig.Protobuf; cfg != nil && cfg.SchemaRegistryURL != "" {
+		for _, s := range []struct {
+			subject string
+			schema  *protobufSchema
+		}{{schemas.topic + "-key", key}, {schemas.topic + "-value", value}} {
+			id, err := registerProtobufSchema(cfg.SchemaRegistryURL, s.subject, s.schema.proto)
+			if err != nil {
+				return fmt.Errorf("kafka: %v", err)
+			}
+			s.schema.header = registryHeader(id)
+		}
+	} else {
+		descriptorSet, err := protobufDescriptorSet(key, value)
+		if err != nil {
+			return err
+		}
+		err = kr.kafkaMgr.Send(kr.kafkaConfig.descriptorTopic(), []byte(schemas.topic), descriptorSet)
+		if err != nil {
+			return fmt.Errorf("kafka: writing the descriptors of %s: %v", schemas.topic, err)
+		}
+	}
+	schemas.protobufKey, schemas.protobufValue = key, value
+	return nil
+}
+
+// protoType returns the protobuf type of a field of a schema type
+func protoType(t SchemaType) descriptor.FieldDescriptorProto_Type {
+	switch t {
+	case SCHEMA_TYPE_INT8, SCHEMA_TYPE_INT16, SCHEMA_TYPE_INT32:
+		return descriptor.FieldDescriptorProto_TYPE_INT32
+	case SCHEMA_TYPE_INT64:
+		return descriptor.FieldDescriptorProto_TYPE_INT64
+	case SCHEMA_TYPE_FLOAT32:
+		return descriptor.FieldDescriptorProto_TYPE_FLOAT
+	case SCHEMA_TYPE_FLOAT64:
+		return descriptor.FieldDescriptorProto_TYPE_DOUBLE
+	case SCHEMA_TYPE_BOOLEAN:
+		return descriptor.FieldDescriptorProto_TYPE_BOOL
+	case SCHEMA_TYPE_BYTES:
+		return descriptor.FieldDescriptorProto_TYPE_BYTES
+	default:
+		return descriptor.FieldDescriptorProto_TYPE_STRING
+	}
+}
+
+// protoIdentifier makes a valid protobuf identifier of a name, replacing
+// the characters other than ASCII letters, digits and "_"
+func protoIdentifier(name string) string {
+	var b bytes.Buffer
+	for _, r := range name {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '_' {
+			b.WriteRune(r)
+		} else {
+			b.WriteByte('_')
+		}
+	}
+	id := b.String()
+	if id == "" || id[0] >= '0' && id[0] <= '9' {
+		id = "_" + id
+	}
+	return id
+}
+
+// protoPackage returns the package of the messages of a topic
+func protoPackage(topic string) string {
+	parts := strings.Split(topic, ".")
+	for i := range parts {
+		parts[i] = protoIdentifier(parts[i])
+	}
+	return strings.Join(parts, ".")
+}
+
+// The wire types of protobuf
+const (
+	protoWireVarint  = 0
+	protoWireFixed64 = 1
+	protoWireBytes   = 2
+	protoWireFixed32 = 5
+)
+
+// encodeProtobuf serializes the key or value payload of a record with the
+// message of s, into a pooled buffer as encodeRecord
+func encodeProtobuf(s *protobufSchema, payload interface{}) (*bytes.Buffer, error) {
+	buf := bufferPool.Get().(*bytes.Buffer)
+	buf.Reset()
+	buf.Write(s.header)
+	var err error
+	switch p := payload.(type) {
+	case *Row:
+		err = writeProtoRow(buf, s.message, p)
+	case *ValuePayload:
+		err = writeProtoEnvelope(buf, s.message, p)
+	default:
+		err = fmt.Errorf("protobuf: unexpected payload %T", payload)
+	}
+	if err != nil {
+		releaseBuffer(buf)
+		return nil, err
+	}
+	return buf, nil
+}
+
+func writeProtoRow(buf *bytes.Buffer, m *protoMessage, r *Row) error {
+	for i, name := range r.ColNames {
+		f, ok := m.byColumn[name]
+		if !ok {
+			return fmt.Errorf("protobuf: column %s is not in message %s", name, m.name)
+		}
+		if r.Values[i] == nil {
+			continue
+		}
+		if err := writeProtoValue(buf, f, r.Values[i]); err != nil {
+			return fmt.Errorf("protobuf: column %s: %v", name, err)
+		}
+	}
+	return nil
+}
+
+func writeProtoEnvelope(buf *bytes.Buffer, m *protoMessage, p *ValuePayload) error {
+	value, source := m.nested[0], m.nested[1]
+	if p.Before != nil {
+		if err := writeProtoNested(buf, 1, func(b *bytes.Buffer) error {
+			return writeProtoRow(b, value, p.Before)
+		}); err != nil {
+			return err
+		}
+	}
+	if p.After != nil {
+		if err := writeProtoNested(buf, 2, func(b *bytes.Buffer) error {
+			return writeProtoRow(b, value, p.After)
+		}); err != nil {
+			return err
+		}
+	}
+	if p.Source != nil {
+		if err := writeProtoNested(buf, 3, func(b *bytes.Buffer) error {
+			return writeProtoSource(b, source, p.Source)
+		}); err != nil {
+			return err
+		}
+	}
+	if err := writeProtoValue(buf, m.fields[3], p.Op); err != nil {
+		return err
+	}
+	return writeProtoValue(buf, m.fields[4], p.TsMs)
+}
+
+func writeProtoSource(buf *bytes.Buffer, m *protoMessage, s *SourcePayload) error {
+	values := []interface{}{s.Version, s.Name, s.ServerID, s.TsSec, s.Gtid, s.File, s.Pos, s.Query,
+		s.Row, s.Snapshot, s.Thread, s.Db, s.Table, s.Truncated}
+	for i, value := range values {
+		if value == nil {
+			continue
+		}
+		if err := writeProtoValue(buf, m.fields[i], value); err != nil {
+			return fmt.Errorf("protobuf: source %s: %v", m.fields[i].name, err)
+		}
+	}
+	return nil
+}
+
+// writeProtoNested writes a message field, its message written by write
+func writeProtoNested(buf *bytes.Buffer, number int32, write func(b *bytes.Buffer) error) error {
+	nested := bufferPool.Get().(*bytes.Buffer)
+	nested.Reset()
+	defer releaseBuffer(nested)
+	if err := write(nested); err != nil {
+		return err
+	}
+	writeProtoTag(buf, number, protoWireBytes)
+	writeProtoVarint(buf, uint64(nested.Len()))
+	buf.Write(nested.Bytes())
+	return nil
+}
+
+// writeProtoValue writes a field of a value, converted to the type of the
+// field as the JSON converter writes it
+func writeProtoValue(buf *bytes.Buffer, f *protoField, value interface{}) error {
+	switch f.typ {
+	case descriptor.FieldDescriptorProto_TYPE_INT32, descriptor.FieldDescriptorProto_TYPE_INT64:
+		n, err := protoInt(value)
+		if err != nil {
+			return err
+		}
+		writeProtoTag(buf, f.number, protoWireVarint)
+		writeProtoVarint(buf, uint64(n))
+	case descriptor.FieldDescriptorProto_TYPE_BOOL:
+		b, ok := value.(bool)
+		if !ok {
+			n, err := protoInt(value)
+			if err != nil {
+				return err
+			}
+			b = n != 0
+		}
+		writeProtoTag(buf, f.number, protoWireVarint)
+		if b {
+			buf.WriteByte(1)
+		} else {
+			buf.WriteByte(0)
+		}
+	case descriptor.FieldDescriptorProto_TYPE_DOUBLE:
+		v, err := protoFloat(value)
+		if err != nil {
+			return err
+		}
+		var b [8]byte
+		binary.LittleEndian.PutUint64(b[:], math.Float64bits(v))
+		writeProtoTag(buf, f.number, protoWireFixed64)
+		buf.Write(b[:])
+	case descriptor.FieldDescriptorProto_TYPE_FLOAT:
+		v, err := protoFloat(value)
+		if err != nil {
+			return err
+		}
+		var b [4]byte
+		binary.LittleEndian.PutUint32(b[:], math.Float32bits(float32(v)))
+		writeProtoTag(buf, f.number, protoWireFixed32)
+		buf.Write(b[:])
+	case descriptor.FieldDescriptorProto_TYPE_BYTES:
+		var bs []byte
+		switch v := value.(type) {
+		case []byte:
+			bs = v
+		case string:
+			// the JSON converter writes the bytes in base64
+			decoded, err := base64.StdEncoding.DecodeString(v)
+			if err != nil {
+				decoded = []byte(v)
+			}
+			bs = decoded
+		default:
+			return fmt.Errorf("%T is not bytes", value)
+		}
+		writeProtoTag(buf, f.number, protoWireBytes)
+		writeProtoVarint(buf, uint64(len(bs)))
+		buf.Write(bs)
+	default:
+		var s string
+		switch v := value.(type) {
+		case string:
+			s = v
+		case []byte:
+			s = string(v)
+		default:
+			bs, err := json.Marshal(v)
+			if err != nil {
+				return err
+			}
+			s = string(bs)
+		}
+		writeProtoTag(buf, f.number, protoWireBytes)
+		writeProtoVarint(buf, uint64(len(s)))
+		buf.WriteString(s)
+	}
+	return nil
+}
+
+func protoInt(value interface{}) (int64, error) {
+	switch v := value.(type) {
+	case int64:
+		return v, nil
+	case int:
+		return int64(v), nil
+	case int32:
+		return int64(v), nil
+	case int16:
+		return int64(v), nil
+	case int8:
+		return int64(v), nil
+	case uint64:
+		return int64(v), nil
+	case uint32:
+		return int64(v), nil
+	case uint16:
+		return int64(v), nil
+	case uint8:
+		return int64(v), nil
+	case bool:
+		if v {
+			return 1, nil
+		}
+		return 0, nil
+	case float64:
+		return int64(v), nil
+	case float32:
+		return int64(v), nil
+	case string:
+		return strconv.ParseInt(v, 10, 64)
+	}
+	return 0, fmt.Errorf("%T is not an integer", value)
+}
+
+func protoFloat(value interface{}) (float64, error) {
+	switch v := value.(type) {
+	case float64:
+		return v, nil
+	case float32:
+		return float64(v), nil
+	case string:
+		return strconv.ParseFloat(v, 64)
+	}
+	n, err := protoInt(value)
+	if err != nil {
+		return 0, fmt.Errorf("%T is not a number", value)
+	}
+	return float64(n), nil
+}
+
+func writeProtoTag(buf *bytes.Buffer, number int32, wireType int) {
+	writeProtoVarint(buf, uint64(number)<<3|uint64(wireType))
+}
+
+func writeProtoVarint(buf *bytes.Buffer, v uint64) {
+	var b [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(b[:], v)
+	buf.Write(b[:n])
+}
@@ -0,0 +1,125 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package kafka3
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gogo/protobuf/proto"
+	"github.com/gogo/protobuf/protoc-gen-gogo/descriptor"
+)
+
+func TestProtobufSchemas(t *testing.T) {
+	colDefs := ColDefs{
+		NewSimpleSchemaWithDefaultField(SCHEMA_TYPE_INT64, false, "id", nil),
+		NewSimpleSchemaWithDefaultField(SCHEMA_TYPE_STRING, true, "first name", nil),
+		NewSimpleSchemaWithDefaultField(SCHEMA_TYPE_FLOAT64, true, "first-name", nil),
+	}
+	key, value := newProtobufSchemas("dtle.shop.2orders", colDefs, colDefs[:1])
+	if !strings.Contains(key.proto, "package dtle.shop._2orders;") ||
+		!strings.Contains(key.proto, "message Key {\n  optional int64 id = 1;\n}") {
+		t.Fatalf("key: got %s", key.proto)
+	}
+	for _, want := range []string{
+		"  message Value {\n    optional int64 id = 1;\n    optional string first_name = 2;\n" +
+			"    optional double first_name_ = 3;\n  }",
+		"  optional Value before = 1;",
+		"  optional Source source = 3;",
+		"    optional bool snapshot = 10;",
+	} {
+		if !strings.Contains(value.proto, want) {
+			t.Fatalf("value: got %s, want %s", value.proto, want)
+		}
+	}
+
+	bs, err := protobufDescriptorSet(key, value)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var set descriptor.FileDescriptorSet
+	if err := proto.Unmarshal(bs, &set); err != nil {
+		t.Fatal(err)
+	}
+	envelope := set.File[1].MessageType[0]
+	if envelope.GetName() != "Envelope" || envelope.Field[1].GetTypeName() != ".dtle.shop._2orders.Envelope.Value" ||
+		envelope.NestedType[0].Field[2].GetType() != descriptor.FieldDescriptorProto_TYPE_DOUBLE {
+		t.Fatalf("descriptor: got %v", envelope)
+	}
+}
+
+func TestEncodeProtobuf(t *testing.T) {
+	colDefs := ColDefs{
+		NewSimpleSchemaWithDefaultField(SCHEMA_TYPE_INT32, false, "id", nil),
+		NewSimpleSchemaWithDefaultField(SCHEMA_TYPE_STRING, true, "name", nil),
+		NewSimpleSchemaWithDefaultField(SCHEMA_TYPE_BYTES, true, "data", nil),
+	}
+	key, value := newProtobufSchemas("dtle.db.tb", colDefs, colDefs[:1])
+
+	row := NewRow()
+	row.AddField("id", int64(-1))
+	row.AddField("name", nil)
+	row.AddField("data", "AQI=")
+	buf, err := encodeProtobuf(value, &ValuePayload{After: row, Op: RECORD_OP_INSERT, TsMs: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []byte{
+		// after: id -1 as ten bytes, data 0x01 0x02
+		0x12, 15, 0x08, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x01, 0x1a, 2, 1, 2,
+		// op, ts_ms
+		0x22, 1, 'c', 0x28, 1,
+	}
+	if !bytes.Equal(buf.Bytes(), want) {
+		t.Fatalf("value: got %x, want %x", buf.Bytes(), want)
+	}
+	releaseBuffer(buf)
+
+	key.header = registryHeader(258)
+	keyRow := NewRow()
+	keyRow.AddField("id", int64(3))
+	buf, err = encodeProtobuf(key, keyRow)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []byte{0, 0, 0, 1, 2, 0, 0x08, 3}; !bytes.Equal(buf.Bytes(), want) {
+		t.Fatalf("key: got %x, want %x", buf.Bytes(), want)
+	}
+	releaseBuffer(buf)
+
+	keyRow.AddField("other", int64(1))
+	if _, err := encodeProtobuf(key, keyRow); err == nil {
+		t.Fatal("a column not in the message: no error")
+	}
+}
+
+func TestRegisterProtobufSchema(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]string
+		json.NewDecoder(r.Body).Decode(&body)
+		if r.URL.Path != "/subjects/dtle.db.tb-value/versions" || body["schemaType"] != "PROTOBUF" {
+			w.WriteHeader(http.StatusUnprocessableEntity)
+			w.Write([]byte(`{"error_code":42201,"message":"Invalid schema"}`))
+			return
+		}
+		w.Write([]byte(`{"id":7}`))
+	}))
+	defer server.Close()
+
+	id, err := registerProtobufSchema(server.URL+"/", "dtle.db.tb-value", "syntax = \"proto2\";")
+	if err != nil || id != 7 {
+		t.Fatalf("got %d, %v", id, err)
+	}
+	if _, err := registerProtobufSchema(server.URL, "other", ""); err == nil ||
+		!strings.Contains(err.Error(), "Invalid schema") {
+		t.Fatalf("got %v", err)
+	}
+}
@@ -0,0 +1,60 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package kafka3
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/actiontech/dtle/internal/models"
+)
+
+// dumpStatResult ends the snapshot, as that of the MySQL extractor
+type dumpStatResult struct {
+	Gtid       string
+	TotalCount int64
+	TableRows  []*models.TableRowCount
+}
+
+// snapshotRowCounts counts the rows of each table emitted by the snapshot,
+// reconciled with those the source read once the snapshot is complete
+type snapshotRowCounts struct {
+	mutex sync.Mutex
+	// emitted are by "schema.table"
+	emitted        map[string]int64
+	reconciliation *models.RowCountReconciliation
+}
+
+func (s *snapshotRowCounts) add(schema, table string, rows int64) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.emitted == nil {
+		s.emitted = make(map[string]int64)
+	}
+	s.emitted[fmt.Sprintf("%s.%s", schema, table)] += rows
+}
+
+// reconcile compares the rows emitted to those the source read. The
+// reconciliation is nil if the source did not count them.
+func (s *snapshotRowCounts) reconcile(result *dumpStatResult) *models.RowCountReconciliation {
+	if result.TableRows == nil {
+		return nil
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	for _, t := range result.TableRows {
+		t.TargetRows = s.emitted[fmt.Sprintf("%s.%s", t.TableSchema, t.TableName)]
+	}
+	s.reconciliation = models.NewRowCountReconciliation(result.Gtid, result.TableRows)
+	return s.reconciliation
+}
+
+func (s *snapshotRowCounts) get() *models.RowCountReconciliation {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.reconciliation
+}
@@ -0,0 +1,42 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package kafka3
+
+import (
+	"testing"
+
+	"github.com/actiontech/dtle/internal/models"
+)
+
+func TestSnapshotRowCountsReconcile(t *testing.T) {
+	s := &snapshotRowCounts{}
+	if r := s.reconcile(&dumpStatResult{Gtid: "a:1-10"}); r != nil {
+		t.Fatalf("uncounted snapshot: got %+v", r)
+	}
+
+	s.add("shop", "orders", 100)
+	s.add("shop", "orders", 20)
+	s.add("shop", "items", 7)
+	r := s.reconcile(&dumpStatResult{
+		Gtid: "a:1-10",
+		TableRows: []*models.TableRowCount{
+			{TableSchema: "shop", TableName: "orders", SourceRows: 120},
+			{TableSchema: "shop", TableName: "items", SourceRows: 8},
+			{TableSchema: "shop", TableName: "empty"},
+		},
+	})
+	if r.Mismatches != 1 {
+		t.Fatalf("got %d mismatches, want 1: %s", r.Mismatches, r.Message)
+	}
+	want := "the row counts of 1 of the 3 tables of the snapshot differ: shop.items (8 rows on the source, 7 on the target)"
+	if r.Message != want {
+		t.Fatalf("got %q, want %q", r.Message, want)
+	}
+	if s.get() != r {
+		t.Fatalf("the reconciliation is not kept for the stats")
+	}
+}
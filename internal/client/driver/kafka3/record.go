@@ -0,0 +1,510 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package kafka3
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+	"time"
+
+	usql "github.com/actiontech/dtle/internal/client/driver/mysql/sql"
+)
+
+// field is a field of the before or after of a record, in the order of the
+// columns of the table
+type field struct {
+	name  string
+	value json.RawMessage
+}
+
+// Record is a change event of a Debezium topic, as written by the Kafka
+// target of dtle or by Debezium
+type Record struct {
+	Op     string
+	Db     string
+	Table  string
+	Before []field
+	After  []field
+	// Schema is that of the columns, nil if the records are written
+	// without their schema
+	Schema ColDefs
+	// Key is the names of the columns of the key of the record
+	Key []string
+}
+
+// envelope is the value of a record, with or without its schema
+type envelope struct {
+	Schema  *Schema         `json:"schema"`
+	Payload json.RawMessage `json:"payload"`
+}
+
+type recordPayload struct {
+	Before json.RawMessage `json:"before"`
+	After  json.RawMessage `json:"after"`
+	Source struct {
+		Db     string `json:"db"`
+		Schema string `json:"schema"`
+		Table  string `json:"table"`
+	} `json:"source"`
+	Op string `json:"op"`
+}
+
+// ParseRecord parses the key and value of a message of a topic. It returns
+// nil for the messages without a change, such as the tombstones, the
+// heartbeats and the schema changes.
+func ParseRecord(topic string, key, value []byte) (*Record, error) {
+	if len(value) == 0 {
+		return nil, nil
+	}
+	if value[0] == 0 {
+		return nil, fmt.Errorf("topic %s: the Avro and protobuf converters are not supported, only the JSON one", topic)
+	}
+	var env envelope
+	if err := json.Unmarshal(value, &env); err != nil {
+		return nil, fmt.Errorf("topic %s: %v", topic, err)
+	}
+	// Without schema, the value is the payload
+	payload := json.RawMessage(value)
+	if env.Payload != nil {
+		payload = env.Payload
+	}
+	var p recordPayload
+	if err := json.Unmarshal(payload, &p); err != nil {
+		return nil, fmt.Errorf("topic %s: %v", topic, err)
+	}
+	if p.Op == "" {
+		return nil, nil
+	}
+
+	r := &Record{Op: p.Op, Db: p.Source.Db, Table: p.Source.Table}
+	if r.Db == "" {
+		r.Db = p.Source.Schema
+	}
+	if r.Db == "" || r.Table == "" {
+		// <server>.<database>.<table>
+		parts := strings.Split(topic, ".")
+		if len(parts) < 3 {
+			return nil, fmt.Errorf("topic %s: the source of the record has no db and table", topic)
+		}
+		r.Db, r.Table = parts[len(parts)-2], parts[len(parts)-1]
+	}
+	var err error
+	if r.Before, err = parseFields(p.Before); err != nil {
+		return nil, fmt.Errorf("topic %s: before: %v", topic, err)
+	}
+	if r.After, err = parseFields(p.After); err != nil {
+		return nil, fmt.Errorf("topic %s: after: %v", topic, err)
+	}
+	if env.Schema != nil {
+		for _, s := range env.Schema.Fields {
+			if s.Field == "after" || s.Field == "before" && r.Schema == nil {
+				r.Schema = s.Fields
+			}
+		}
+	}
+	if r.Key, err = parseKey(key); err != nil {
+		return nil, fmt.Errorf("topic %s: key: %v", topic, err)
+	}
+	return r, nil
+}
+
+func isNull(raw json.RawMessage) bool {
+	return len(raw) == 0 || string(bytes.TrimSpace(raw)) == "null"
+}
+
+// parseFields parses a JSON object keeping the order of its fields, nil
+// for null
+func parseFields(raw json.RawMessage) ([]field, error) {
+	if isNull(raw) {
+		return nil, nil
+	}
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	if t, err := dec.Token(); err != nil {
+		return nil, err
+	} else if t != json.Delim('{') {
+		return nil, fmt.Errorf("not an object")
+	}
+	var fields []field
+	for dec.More() {
+		t, err := dec.Token()
+		if err != nil {
+			return nil, err
+		}
+		f := field{name: t.(string)}
+		if err := dec.Decode(&f.value); err != nil {
+			return nil, err
+		}
+		fields = append(fields, f)
+	}
+	return fields, nil
+}
+
+// parseKey returns the names of the fields of the key of a record
+func parseKey(key []byte) ([]string, error) {
+	if isNull(key) {
+		return nil, nil
+	}
+	var env envelope
+	if err := json.Unmarshal(key, &env); err != nil {
+		return nil, err
+	}
+	payload := json.RawMessage(key)
+	if env.Payload != nil {
+		payload = env.Payload
+	}
+	fields, err := parseFields(payload)
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, f := range fields {
+		names = append(names, f.name)
+	}
+	return names, nil
+}
+
+// Columns returns the names of the columns of the record
+func (r *Record) Columns() []string {
+	fields := r.After
+	if fields == nil {
+		fields = r.Before
+	}
+	var names []string
+	for _, f := range fields {
+		names = append(names, f.name)
+	}
+	return names
+}
+
+// schemaOf returns the schema of a column, nil if unknown
+func (r *Record) schemaOf(name string) *Schema {
+	for _, s := range r.Schema {
+		if s.Field == name {
+			return s
+		}
+	}
+	return nil
+}
+
+// RowValues converts the fields of the before or after of a record to the
+// values of a binlog event, nil if there are none
+func (r *Record) RowValues(fields []field) ([]interface{}, error) {
+	if fields == nil {
+		return nil, nil
+	}
+	values := make([]interface{}, len(fields))
+	for i, f := range fields {
+		v, err := columnValue(r.schemaOf(f.name), f.value)
+		if err != nil {
+			return nil, fmt.Errorf("column %s of %s.%s: %v", f.name, r.Db, r.Table, err)
+		}
+		values[i] = v
+	}
+	return values, nil
+}
+
+// The names of the logical types of Kafka Connect and Debezium
+const (
+	logicalDecimal        = "org.apache.kafka.connect.data.Decimal"
+	logicalDate           = "io.debezium.time.Date"
+	logicalTime           = "io.debezium.time.Time"
+	logicalMicroTime      = "io.debezium.time.MicroTime"
+	logicalTimestamp      = "io.debezium.time.Timestamp"
+	logicalMicroTimestamp = "io.debezium.time.MicroTimestamp"
+	logicalNanoTimestamp  = "io.debezium.time.NanoTimestamp"
+	logicalZonedTimestamp = "io.debezium.time.ZonedTimestamp"
+	logicalYear           = "io.debezium.time.Year"
+	logicalJson           = "io.debezium.data.Json"
+	logicalBits           = "io.debezium.data.Bits"
+	logicalEnum           = "io.debezium.data.Enum"
+	logicalEnumSet        = "io.debezium.data.EnumSet"
+)
+
+// columnValue converts the value of a column to that of a binlog event, as
+// read from a MySQL source: the times and decimals as text, in UTC. Without
+// schema, the value is converted from its JSON type.
+func columnValue(s *Schema, raw json.RawMessage) (interface{}, error) {
+	if isNull(raw) {
+		return nil, nil
+	}
+	if s == nil {
+		return jsonValue(raw)
+	}
+	switch s.Name {
+	case logicalDecimal:
+		if s.Type != SCHEMA_TYPE_BYTES {
+			break
+		}
+		b, err := bytesValue(raw)
+		if err != nil {
+			return nil, err
+		}
+		scale, _ := strconv.Atoi(fmt.Sprint(s.Parameters["scale"]))
+		return decimalString(b, scale), nil
+	case logicalDate:
+		days, err := intValue(raw)
+		if err != nil {
+			return nil, err
+		}
+		return time.Unix(days*24*3600, 0).UTC().Format("2006-01-02"), nil
+	case logicalTime, logicalMicroTime:
+		n, err := intValue(raw)
+		if err != nil {
+			return nil, err
+		}
+		if s.Name == logicalTime {
+			n *= 1000
+		}
+		return microTimeString(n), nil
+	case logicalTimestamp, logicalMicroTimestamp, logicalNanoTimestamp:
+		n, err := intValue(raw)
+		if err != nil {
+			return nil, err
+		}
+		var t time.Time
+		switch s.Name {
+		case logicalTimestamp:
+			t = time.Unix(0, n*int64(time.Millisecond))
+		case logicalMicroTimestamp:
+			t = time.Unix(0, n*int64(time.Microsecond))
+		default:
+			t = time.Unix(0, n)
+		}
+		return t.UTC().Format("2006-01-02 15:04:05.999999"), nil
+	case logicalZonedTimestamp:
+		var v string
+		if err := json.Unmarshal(raw, &v); err != nil {
+			return nil, err
+		}
+		t, err := time.Parse(time.RFC3339Nano, v)
+		if err != nil {
+			return nil, err
+		}
+		return t.UTC().Format("2006-01-02 15:04:05.999999"), nil
+	case logicalBits:
+		b, err := bytesValue(raw)
+		if err != nil {
+			return nil, err
+		}
+		// Big-endian, as written by dtle
+		var n int64
+		for _, c := range b {
+			n = n<<8 | int64(c)
+		}
+		return n, nil
+	}
+	switch s.Type {
+	case SCHEMA_TYPE_INT8, SCHEMA_TYPE_INT16, SCHEMA_TYPE_INT32, SCHEMA_TYPE_INT64:
+		return intValue(raw)
+	case SCHEMA_TYPE_FLOAT32, SCHEMA_TYPE_FLOAT64:
+		var v float64
+		err := json.Unmarshal(raw, &v)
+		return v, err
+	case SCHEMA_TYPE_BOOLEAN:
+		var v bool
+		if err := json.Unmarshal(raw, &v); err != nil {
+			return nil, err
+		}
+		if v {
+			return int64(1), nil
+		}
+		return int64(0), nil
+	case SCHEMA_TYPE_BYTES:
+		return bytesValue(raw)
+	case SCHEMA_TYPE_STRING:
+		var v string
+		err := json.Unmarshal(raw, &v)
+		return v, err
+	}
+	return nil, fmt.Errorf("unsupported type %s %s", s.Type, s.Name)
+}
+
+// jsonValue converts a value of a record without schema from its JSON type
+func jsonValue(raw json.RawMessage) (interface{}, error) {
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.UseNumber()
+	var v interface{}
+	if err := dec.Decode(&v); err != nil {
+		return nil, err
+	}
+	switch v := v.(type) {
+	case json.Number:
+		if n, err := v.Int64(); err == nil {
+			return n, nil
+		}
+		return v.String(), nil
+	case bool:
+		if v {
+			return int64(1), nil
+		}
+		return int64(0), nil
+	case string:
+		return v, nil
+	default:
+		var buf bytes.Buffer
+		if err := json.Compact(&buf, raw); err != nil {
+			return nil, err
+		}
+		return buf.String(), nil
+	}
+}
+
+func intValue(raw json.RawMessage) (int64, error) {
+	var n json.Number
+	if err := json.Unmarshal(raw, &n); err != nil {
+		return 0, err
+	}
+	return n.Int64()
+}
+
+// bytesValue decodes bytes, written in base64
+func bytesValue(raw json.RawMessage) ([]byte, error) {
+	var v string
+	if err := json.Unmarshal(raw, &v); err != nil {
+		return nil, err
+	}
+	return base64.StdEncoding.DecodeString(v)
+}
+
+// decimalString returns the text of a Kafka Connect decimal, the
+// big-endian two's complement of its unscaled value
+func decimalString(b []byte, scale int) string {
+	n := new(big.Int).SetBytes(b)
+	if len(b) > 0 && b[0]&0x80 != 0 {
+		n.Sub(n, new(big.Int).Lsh(big.NewInt(1), uint(len(b)*8)))
+	}
+	s := n.String()
+	if scale <= 0 {
+		return s
+	}
+	sign := ""
+	if strings.HasPrefix(s, "-") {
+		sign, s = "-", s[1:]
+	}
+	if len(s) <= scale {
+		s = strings.Repeat("0", scale-len(s)+1) + s
+	}
+	return sign + s[:len(s)-scale] + "." + s[len(s)-scale:]
+}
+
+// microTimeString returns the text of a time in microseconds
+func microTimeString(n int64) string {
+	sign := ""
+	if n < 0 {
+		sign, n = "-", -n
+	}
+	s := fmt.Sprintf("%s%02d:%02d:%02d", sign, n/3600000000, n/60000000%60, n/1000000%60)
+	if us := n % 1000000; us != 0 {
+		s += strings.TrimRight(fmt.Sprintf(".%06d", us), "0")
+	}
+	return s
+}
+
+// mysqlType returns the MySQL type of a column of a schema, key telling
+// whether the column is in the key of the table
+func mysqlType(s *Schema, key bool) string {
+	param := func(name string) string {
+		return fmt.Sprint(s.Parameters[name])
+	}
+	switch s.Name {
+	case logicalDecimal:
+		precision := param("connect.decimal.precision")
+		if _, err := strconv.Atoi(precision); err != nil {
+			precision = "65"
+		}
+		return fmt.Sprintf("decimal(%s,%s)", precision, param("scale"))
+	case logicalDate:
+		return "date"
+	case logicalTime:
+		return "time(3)"
+	case logicalMicroTime:
+		return "time(6)"
+	case logicalTimestamp:
+		return "datetime(3)"
+	case logicalMicroTimestamp, logicalNanoTimestamp:
+		return "datetime(6)"
+	case logicalZonedTimestamp:
+		return "timestamp(6)"
+	case logicalYear:
+		return "year"
+	case logicalJson:
+		return "json"
+	case logicalBits:
+		if _, err := strconv.Atoi(param("length")); err == nil {
+			return fmt.Sprintf("bit(%s)", param("length"))
+		}
+		return "bit(64)"
+	case logicalEnum, logicalEnumSet:
+		if allowed, ok := s.Parameters["allowed"].(string); ok && allowed != "" {
+			var values []string
+			for _, v := range strings.Split(allowed, ",") {
+				values = append(values, "'"+strings.Replace(v, "'", "''", -1)+"'")
+			}
+			if s.Name == logicalEnum {
+				return fmt.Sprintf("enum(%s)", strings.Join(values, ","))
+			}
+			return fmt.Sprintf("set(%s)", strings.Join(values, ","))
+		}
+	}
+	switch s.Type {
+	case SCHEMA_TYPE_INT8:
+		return "tinyint"
+	case SCHEMA_TYPE_INT16:
+		return "smallint"
+	case SCHEMA_TYPE_INT32:
+		return "int"
+	case SCHEMA_TYPE_INT64:
+		return "bigint"
+	case SCHEMA_TYPE_FLOAT32:
+		return "float"
+	case SCHEMA_TYPE_FLOAT64:
+		return "double"
+	case SCHEMA_TYPE_BOOLEAN:
+		return "tinyint(1)"
+	case SCHEMA_TYPE_BYTES:
+		if key {
+			return "varbinary(255)"
+		}
+		return "longblob"
+	default:
+		if key {
+			return "varchar(255)"
+		}
+		return "longtext"
+	}
+}
+
+// CreateTableSQL returns the CREATE TABLE of the table of a record, from
+// its schema, empty without
+func (r *Record) CreateTableSQL() string {
+	if r.Schema == nil {
+		return ""
+	}
+	isKey := make(map[string]bool)
+	var keys []string
+	for _, k := range r.Key {
+		isKey[k] = true
+		keys = append(keys, usql.EscapeName(k))
+	}
+	var definitions []string
+	for _, s := range r.Schema {
+		definition := fmt.Sprintf("  %s %s", usql.EscapeName(s.Field), mysqlType(s, isKey[s.Field]))
+		if !s.Optional || isKey[s.Field] {
+			definition += " NOT NULL"
+		}
+		definitions = append(definitions, definition)
+	}
+	if len(keys) > 0 {
+		definitions = append(definitions, fmt.Sprintf("  PRIMARY KEY (%s)", strings.Join(keys, ",")))
+	}
+	return fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (\n%s\n)", usql.EscapeName(r.Table), strings.Join(definitions, ",\n"))
+}
@@ -0,0 +1,184 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package kafka3
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"reflect"
+	"testing"
+
+	"github.com/actiontech/dtle/internal/client/driver/mysql/binlog"
+	log "github.com/actiontech/dtle/internal/logger"
+)
+
+const testKey = `{"schema": {"type": "struct", "name": "dbserver1.shop.orders.Key", "optional": false,
+	"fields": [{"type": "int32", "optional": false, "field": "id"}]}, "payload": {"id": 7}}`
+
+const testValue = `{"schema": {"type": "struct", "name": "dbserver1.shop.orders.Envelope", "fields": [
+	{"type": "struct", "optional": true, "field": "before", "fields": [
+		{"type": "int32", "optional": false, "field": "id"},
+		{"type": "bytes", "optional": true, "field": "total", "name": "org.apache.kafka.connect.data.Decimal",
+			"parameters": {"scale": "2", "connect.decimal.precision": "10"}},
+		{"type": "int32", "optional": true, "field": "day", "name": "io.debezium.time.Date"},
+		{"type": "string", "optional": true, "field": "note"}]},
+	{"type": "struct", "optional": true, "field": "after", "fields": [
+		{"type": "int32", "optional": false, "field": "id"},
+		{"type": "bytes", "optional": true, "field": "total", "name": "org.apache.kafka.connect.data.Decimal",
+			"parameters": {"scale": "2", "connect.decimal.precision": "10"}},
+		{"type": "int32", "optional": true, "field": "day", "name": "io.debezium.time.Date"},
+		{"type": "string", "optional": true, "field": "note"}]},
+	{"type": "string", "optional": false, "field": "op"}]},
+	"payload": {"before": {"id": 7, "total": "BNI=", "day": 17897, "note": null},
+		"after": {"id": 7, "total": "+yw=", "day": 17898, "note": "gift"},
+		"source": {"name": "dbserver1", "db": "shop", "table": "orders"}, "op": "u", "ts_ms": 1546300800000}}`
+
+func TestParseRecord(t *testing.T) {
+	r, err := ParseRecord("dbserver1.shop.orders", []byte(testKey), []byte(testValue))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Op != RECORD_OP_UPDATE || r.Db != "shop" || r.Table != "orders" || !reflect.DeepEqual(r.Key, []string{"id"}) {
+		t.Fatalf("ParseRecord() = %+v", r)
+	}
+	if want := []string{"id", "total", "day", "note"}; !reflect.DeepEqual(r.Columns(), want) {
+		t.Errorf("Columns() = %v, want %v", r.Columns(), want)
+	}
+	before, err := r.RowValues(r.Before)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []interface{}{int64(7), "12.34", "2019-01-01", nil}; !reflect.DeepEqual(before, want) {
+		t.Errorf("RowValues(before) = %#v, want %#v", before, want)
+	}
+	after, err := r.RowValues(r.After)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []interface{}{int64(7), "-12.36", "2019-01-02", "gift"}; !reflect.DeepEqual(after, want) {
+		t.Errorf("RowValues(after) = %#v, want %#v", after, want)
+	}
+	want := "CREATE TABLE IF NOT EXISTS `orders` (\n" +
+		"  `id` int NOT NULL,\n" +
+		"  `total` decimal(10,2),\n" +
+		"  `day` date,\n" +
+		"  `note` longtext,\n" +
+		"  PRIMARY KEY (`id`)\n)"
+	if got := r.CreateTableSQL(); got != want {
+		t.Errorf("CreateTableSQL() = %v, want %v", got, want)
+	}
+
+	// Without schema, nor db and table in the source
+	r, err = ParseRecord("dbserver1.shop.items", []byte(`{"id": 3}`),
+		[]byte(`{"before": null, "after": {"id": 3, "price": 1.5, "tags": ["a"], "ok": true}, "source": {}, "op": "c"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Db != "shop" || r.Table != "items" || r.Before != nil || r.CreateTableSQL() != "" {
+		t.Fatalf("ParseRecord() = %+v", r)
+	}
+	after, err = r.RowValues(r.After)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []interface{}{int64(3), "1.5", `["a"]`, int64(1)}; !reflect.DeepEqual(after, want) {
+		t.Errorf("RowValues(after) = %#v, want %#v", after, want)
+	}
+
+	for _, value := range []string{"", `{"schema": {"type": "struct"}, "payload": {"ts_ms": 1}}`, `{"ddl": "DROP TABLE t"}`} {
+		if r, err := ParseRecord("t", nil, []byte(value)); r != nil || err != nil {
+			t.Errorf("ParseRecord(%q) = %+v, %v, want nil", value, r, err)
+		}
+	}
+	if _, err := ParseRecord("t", nil, []byte{0, 0, 0, 0, 1}); err == nil {
+		t.Errorf("ParseRecord() of an Avro record, got no error")
+	}
+}
+
+func TestColumnValue(t *testing.T) {
+	tests := []struct {
+		schema *Schema
+		raw    string
+		want   interface{}
+	}{
+		{&Schema{Type: SCHEMA_TYPE_INT64, Name: logicalTimestamp}, `1546300800123`, "2019-01-01 00:00:00.123"},
+		{&Schema{Type: SCHEMA_TYPE_INT64, Name: logicalMicroTimestamp}, `1546300800000001`, "2019-01-01 00:00:00.000001"},
+		{&Schema{Type: SCHEMA_TYPE_STRING, Name: logicalZonedTimestamp}, `"2019-01-01T08:00:00.5+08:00"`, "2019-01-01 00:00:00.5"},
+		{&Schema{Type: SCHEMA_TYPE_INT64, Name: logicalMicroTime}, `-3723000001`, "-01:02:03.000001"},
+		{&Schema{Type: SCHEMA_TYPE_INT32, Name: logicalTime}, `3723500`, "01:02:03.5"},
+		{&Schema{Type: SCHEMA_TYPE_BYTES, Name: logicalDecimal, Parameters: map[string]interface{}{"scale": "3"}}, `"AQ=="`, "0.001"},
+		{&Schema{Type: SCHEMA_TYPE_STRING, Name: logicalDecimal}, `"1.50"`, "1.50"},
+		{&Schema{Type: SCHEMA_TYPE_BYTES, Name: logicalBits}, `"AQI="`, int64(258)},
+		{&Schema{Type: SCHEMA_TYPE_BOOLEAN}, `false`, int64(0)},
+		{&Schema{Type: SCHEMA_TYPE_FLOAT32}, `1.5`, float64(1.5)},
+		{&Schema{Type: SCHEMA_TYPE_BYTES}, `"AQI="`, []byte{1, 2}},
+		{&Schema{Type: SCHEMA_TYPE_STRING}, `null`, nil},
+	}
+	for _, tt := range tests {
+		got, err := columnValue(tt.schema, json.RawMessage(tt.raw))
+		if err != nil {
+			t.Errorf("columnValue(%s) of %v error = %v", tt.raw, tt.schema.Name, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("columnValue(%s) of %v = %#v, want %#v", tt.raw, tt.schema.Name, got, tt.want)
+		}
+	}
+}
+
+func TestRecordEvents(t *testing.T) {
+	s, err := NewKafkaSource("job", "", 1<<20, &KafkaConfig{Brokers: []string{"kafka:9092"}, Topics: []string{"t"}},
+		log.New(ioutil.Discard, log.InfoLevel))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.cfg.GroupID != "dtle-job" {
+		t.Errorf("GroupID = %v, want dtle-job", s.cfg.GroupID)
+	}
+	r, err := ParseRecord("dbserver1.shop.orders", []byte(testKey), []byte(testValue))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The first record of a table creates it
+	events, err := s.recordEvents(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 3 || events[0].DML != binlog.NotDML || events[1].TableName != "orders" || events[2].DML != binlog.UpdateDML {
+		t.Fatalf("recordEvents() = %+v", events)
+	}
+	if got := *events[2].WhereColumnValues.AbstractValues[1]; got != "12.34" {
+		t.Errorf("where total = %v", got)
+	}
+
+	// A delete with the key only, in the order of the columns
+	r, err = ParseRecord("dbserver1.shop.orders", nil,
+		[]byte(`{"before": {"note": "x", "id": 7}, "after": null, "source": {"db": "shop", "table": "orders"}, "op": "d"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	events, err = s.recordEvents(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 1 || events[0].DML != binlog.DeleteDML {
+		t.Fatalf("recordEvents() = %+v", events)
+	}
+	var where []interface{}
+	for _, v := range events[0].WhereColumnValues.GetAbstractValues() {
+		where = append(where, *v)
+	}
+	if want := []interface{}{int64(7), nil, nil, "x"}; !reflect.DeepEqual(where, want) {
+		t.Errorf("where = %#v, want %#v", where, want)
+	}
+
+	r.Before = append(r.Before, field{name: "added", value: json.RawMessage(`1`)})
+	if _, err := s.recordEvents(r); err == nil {
+		t.Errorf("recordEvents() of a record with a new column, got no error")
+	}
+}
@@ -0,0 +1,124 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package kafka3
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+
+	"github.com/actiontech/dtle/internal/config"
+)
+
+// tableSchemas are the encoded key and value schemas of the records of a
+// table. They are built once for a structure of the table, and dropped when
+// a DDL brings a new one.
+type tableSchemas struct {
+	// table is the structure the schemas are built from
+	table *config.Table
+	// topic is that the records of the table are sent to
+	topic string
+	// casts are the Casts of the columns of the table by their ordinals
+	casts []*CastConfig
+	key   []byte
+	value []byte
+	// protobufKey and protobufValue are the messages of the protobuf
+	// converter
+	protobufKey   *protobufSchema
+	protobufValue *protobufSchema
+}
+
+// getTableSchemas returns the schemas of the records of a table, from the
+// cache if they are for its current structure
+func (kr *KafkaRunner) getTableSchemas(table *config.Table, tableIdent string) (*tableSchemas, error) {
+	key := fmt.Sprintf("%s.%s", table.TableSchema, table.TableName)
+	if schemas, ok := kr.schemas[key]; ok && schemas.table == table {
+		return schemas, nil
+	}
+
+	schemas := &tableSchemas{
+		table: table,
+		topic: kr.kafkaConfig.tableTopic(table.TableSchema, table.TableName),
+	}
+	if schemas.topic != tableIdent {
+		kr.topicMappings.add(table.TableSchema, table.TableName, schemas.topic)
+	}
+	var err error
+	if schemas.casts, err = kr.kafkaConfig.tableCasts(table); err != nil {
+		return nil, err
+	}
+	// the topic of the table is created, if missing, with its first schemas
+	if err := kr.ensureTableTopic(table, schemas.topic); err != nil {
+		return nil, err
+	}
+	if kr.kafkaConfig.Converter == CONVERTER_PROTOBUF {
+		if err := kr.initProtobufSchemas(schemas); err != nil {
+			return nil, err
+		}
+	} else if !kr.omitSchema() {
+		valueColDefs, keyColDefs := kr.tableColDefs(table, schemas.casts)
+		if schemas.key, err = encodeSchema(NewKeySchema(tableIdent, keyColDefs)); err != nil {
+			return nil, err
+		}
+		schemas.value, err = encodeSchema(NewEnvelopeSchemaWithSource(tableIdent, valueColDefs, kr.sourceSchema()))
+		if err != nil {
+			return nil, err
+		}
+	}
+	kr.schemas[key] = schemas
+	return schemas, nil
+}
+
+// invalidateTableSchemas drops the cached schemas of a table, on a new
+// structure of it
+func (kr *KafkaRunner) invalidateTableSchemas(schemaName, tableName string) {
+	delete(kr.schemas, fmt.Sprintf("%s.%s", schemaName, tableName))
+}
+
+// encodeSchema encodes a schema as writeDbzOutput writes it
+func encodeSchema(schema *Schema) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := json.NewEncoder(&buf).Encode(schema); err != nil {
+		return nil, err
+	}
+	// Encode ends the value with a newline
+	return buf.Bytes()[:buf.Len()-1], nil
+}
+
+// omitSchema tells whether the records are written without their schema
+func (kr *KafkaRunner) omitSchema() bool {
+	return kr.kafkaConfig.OmitSchema || kr.kafkaConfig.flat()
+}
+
+// encodeRecord serializes a record, with its protobuf message if any, only
+// its payload if the schemas are omitted, or its flat value in the flat
+// format. The value of a delete written as its tombstone only is nil.
+func (kr *KafkaRunner) encodeRecord(v DbzOutput) (*bytes.Buffer, error) {
+	if v.protobuf != nil {
+		return encodeProtobuf(v.protobuf, v.Payload)
+	}
+	if kr.kafkaConfig.flat() {
+		if p, ok := v.Payload.(*ValuePayload); ok {
+			return encodeFlatValue(p, kr.kafkaConfig.Flat)
+		}
+	}
+	if kr.omitSchema() {
+		return encodePayload(v.Payload)
+	}
+	return encodeRecord(v)
+}
+
+// tombstoneValue returns the value of the record following that of a delete
+func (kr *KafkaRunner) tombstoneValue() []byte {
+	if kr.kafkaConfig.Converter == CONVERTER_PROTOBUF {
+		return nil
+	}
+	if kr.omitSchema() {
+		return nullPayload
+	}
+	return tombstoneValue
+}
@@ -0,0 +1,120 @@
+package kafka3
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"testing"
+
+	"github.com/actiontech/dtle/internal/config"
+	"github.com/actiontech/dtle/internal/config/mysql"
+	log "github.com/actiontech/dtle/internal/logger"
+)
+
+func newTestTable(columns ...string) *config.Table {
+	table := config.NewTable("db", "tb")
+	var cols []mysql.Column
+	for _, name := range columns {
+		cols = append(cols, mysql.Column{Name: name, Type: mysql.IntColumnType, ColumnType: "int"})
+	}
+	table.OriginalTableColumns = mysql.NewColumnList(cols)
+	return table
+}
+
+func TestGetTableSchemas(t *testing.T) {
+	kr := NewKafkaRunner("job", "kafka", 0, &KafkaConfig{}, log.New(ioutil.Discard, log.InfoLevel))
+	table := newTestTable("id", "a")
+	if _, err := kr.getOrSetTable("db", "tb", table); err != nil {
+		t.Fatal(err)
+	}
+	schemas, err := kr.getTableSchemas(table, "topic.db.tb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	valueColDefs, keyColDefs := kafkaColumnListToColDefs(table.OriginalTableColumns, kr.kafkaConfig)
+	want, _ := json.Marshal(NewEnvelopeSchemaWithSource("topic.db.tb", valueColDefs, kr.sourceSchema()))
+	if string(schemas.value) != string(want) {
+		t.Fatalf("got the value schema %s, want %s", schemas.value, want)
+	}
+	want, _ = json.Marshal(NewKeySchema("topic.db.tb", keyColDefs))
+	if string(schemas.key) != string(want) {
+		t.Fatalf("got the key schema %s, want %s", schemas.key, want)
+	}
+
+	if cached, _ := kr.getTableSchemas(table, "topic.db.tb"); cached != schemas {
+		t.Fatalf("the schemas are not cached")
+	}
+
+	// A DDL brings a new structure of the table
+	altered := newTestTable("id", "a", "b")
+	if _, err := kr.getOrSetTable("db", "tb", altered); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := kr.schemas["db.tb"]; ok {
+		t.Fatalf("the schemas are not invalidated by a new structure")
+	}
+	rebuilt, err := kr.getTableSchemas(altered, "topic.db.tb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rebuilt == schemas || string(rebuilt.value) == string(schemas.value) {
+		t.Fatalf("the schemas are not rebuilt for the new structure")
+	}
+}
+
+func TestEncodeRecordOmitSchema(t *testing.T) {
+	kr := NewKafkaRunner("job", "kafka", 0, &KafkaConfig{}, log.New(ioutil.Discard, log.InfoLevel))
+	table := newTestTable("id")
+	schemas, err := kr.getTableSchemas(table, "topic.db.tb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	row := NewRow()
+	row.AddField("id", int64(1))
+
+	buf, err := kr.encodeRecord(DbzOutput{encodedSchema: schemas.key, Payload: row})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, keyColDefs := kafkaColumnListToColDefs(table.OriginalTableColumns, kr.kafkaConfig)
+	want, _ := json.Marshal(DbzOutput{Schema: NewKeySchema("topic.db.tb", keyColDefs), Payload: row})
+	if buf.String() != string(want) {
+		t.Fatalf("got %s, want %s", buf.String(), want)
+	}
+	releaseBuffer(buf)
+
+	kr = NewKafkaRunner("job", "kafka", 0, &KafkaConfig{OmitSchema: true}, log.New(ioutil.Discard, log.InfoLevel))
+	if schemas, err = kr.getTableSchemas(table, "topic.db.tb"); err != nil {
+		t.Fatal(err)
+	}
+	if schemas.key != nil || schemas.value != nil {
+		t.Fatalf("got schemas built while omitted")
+	}
+	buf, err = kr.encodeRecord(DbzOutput{encodedSchema: schemas.key, Payload: row})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if buf.String() != `{"id":1}` {
+		t.Fatalf("got %s, want the payload alone", buf.String())
+	}
+	releaseBuffer(buf)
+	if string(kr.tombstoneValue()) != "null" {
+		t.Fatalf("got the tombstone %s", kr.tombstoneValue())
+	}
+}
+
+// BenchmarkEnvelopeSchema builds and encodes the schemas of a table for each
+// record, as done before they were cached
+func BenchmarkEnvelopeSchema(b *testing.B) {
+	table := newTestTable("id", "name", "email", "created_at", "balance", "note")
+	cfg := &KafkaConfig{}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		valueColDefs, keyColDefs := kafkaColumnListToColDefs(table.OriginalTableColumns, cfg)
+		if _, err := encodeSchema(NewKeySchema("topic.db.tb", keyColDefs)); err != nil {
+			b.Fatal(err)
+		}
+		if _, err := encodeSchema(NewEnvelopeSchemaWithSource("topic.db.tb", valueColDefs, SourceSchema)); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
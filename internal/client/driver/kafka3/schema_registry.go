@@ -0,0 +1,75 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package kafka3
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// schemaRegistryClient is the client of the Confluent schema registries
+var schemaRegistryClient = &http.Client{Timeout: 30 * time.Second}
+
+// registerProtobufSchema registers a protobuf schema under a subject of the
+// schema registry at registryURL, returning its id. Registering a schema
+// the subject has returns its id as well.
+func registerProtobufSchema(registryURL, subject, schema string) (int32, error) {
+	body, err := json.Marshal(map[string]string{
+		"schemaType": "PROTOBUF",
+		"schema":     schema,
+	})
+	if err != nil {
+		return 0, err
+	}
+	u := strings.TrimSuffix(registryURL, "/") + "/subjects/" + url.PathEscape(subject) + "/versions"
+	req, err := http.NewRequest("POST", u, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/vnd.schemaregistry.v1+json")
+	resp, err := schemaRegistryClient.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		var registryErr struct {
+			ErrorCode int    `json:"error_code"`
+			Message   string `json:"message"`
+		}
+		msg, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 4096))
+		if json.Unmarshal(msg, &registryErr) == nil && registryErr.Message != "" {
+			return 0, fmt.Errorf("registering subject %s: %s (error %d)", subject, registryErr.Message,
+				registryErr.ErrorCode)
+		}
+		return 0, fmt.Errorf("registering subject %s: %s", subject, resp.Status)
+	}
+	var result struct {
+		ID int32 `json:"id"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return 0, fmt.Errorf("registering subject %s: %v", subject, err)
+	}
+	return result.ID, nil
+}
+
+// registryHeader returns what prefixes the records of a registered
+// protobuf schema: the magic byte 0, the schema id, and the index of the
+// first message of the schema, as a single 0
+func registryHeader(id int32) []byte {
+	header := make([]byte, 6)
+	binary.BigEndian.PutUint32(header[1:5], uint32(id))
+	return header
+}
@@ -0,0 +1,332 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package kafka3
+
+import (
+	"bytes"
+	"encoding/json"
+	"math"
+	"strconv"
+	"sync"
+	"unicode/utf8"
+)
+
+// The records are serialized into pooled buffers, and their rows and
+// payloads reused, as each event otherwise allocates them anew.
+var (
+	bufferPool = sync.Pool{
+		New: func() interface{} {
+			return new(bytes.Buffer)
+		},
+	}
+	rowPool = sync.Pool{
+		New: func() interface{} {
+			return &Row{}
+		},
+	}
+	valuePayloadPool = sync.Pool{
+		New: func() interface{} {
+			return &ValuePayload{Source: &SourcePayload{}}
+		},
+	}
+)
+
+// encodeRecord serializes a record as json.Marshal does, streaming its rows
+// into a pooled buffer rather than marshaling each apart. The buffer is to
+// give back with releaseBuffer once its bytes are sent.
+func encodeRecord(v DbzOutput) (*bytes.Buffer, error) {
+	buf := bufferPool.Get().(*bytes.Buffer)
+	buf.Reset()
+	if err := writeDbzOutput(buf, &v); err != nil {
+		releaseBuffer(buf)
+		return nil, err
+	}
+	return buf, nil
+}
+
+func writeDbzOutput(buf *bytes.Buffer, v *DbzOutput) error {
+	buf.WriteString(`{"schema":`)
+	if v.encodedSchema != nil {
+		buf.Write(v.encodedSchema)
+	} else if v.Schema == nil {
+		buf.WriteString("null")
+	} else {
+		// The schema has no rows: the encoder writes it in one pass
+		if err := json.NewEncoder(buf).Encode(v.Schema); err != nil {
+			return err
+		}
+		// Encode ends the value with a newline
+		buf.Truncate(buf.Len() - 1)
+	}
+	buf.WriteString(`,"payload":`)
+	if err := writePayload(buf, v.Payload); err != nil {
+		return err
+	}
+	buf.WriteByte('}')
+	return nil
+}
+
+// encodePayload serializes the payload of a record alone, into a pooled
+// buffer as encodeRecord
+func encodePayload(payload interface{}) (*bytes.Buffer, error) {
+	buf := bufferPool.Get().(*bytes.Buffer)
+	buf.Reset()
+	if err := writePayload(buf, payload); err != nil {
+		releaseBuffer(buf)
+		return nil, err
+	}
+	return buf, nil
+}
+
+func writePayload(buf *bytes.Buffer, payload interface{}) error {
+	switch payload := payload.(type) {
+	case *Row:
+		return writeRow(buf, payload)
+	case *ValuePayload:
+		return writeValuePayload(buf, payload)
+	default:
+		return writeJSONValue(buf, payload)
+	}
+}
+
+func writeRow(buf *bytes.Buffer, r *Row) error {
+	if r == nil {
+		buf.WriteString("null")
+		return nil
+	}
+	buf.WriteByte('{')
+	for i := range r.ColNames {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		writeJSONString(buf, r.ColNames[i])
+		buf.WriteByte(':')
+		if err := writeJSONValue(buf, r.Values[i]); err != nil {
+			return err
+		}
+	}
+	buf.WriteByte('}')
+	return nil
+}
+
+func writeValuePayload(buf *bytes.Buffer, p *ValuePayload) error {
+	if p == nil {
+		buf.WriteString("null")
+		return nil
+	}
+	buf.WriteString(`{"before":`)
+	if err := writeRow(buf, p.Before); err != nil {
+		return err
+	}
+	buf.WriteString(`,"after":`)
+	if err := writeRow(buf, p.After); err != nil {
+		return err
+	}
+	buf.WriteString(`,"source":`)
+	if err := writeSourcePayload(buf, p.Source); err != nil {
+		return err
+	}
+	buf.WriteString(`,"op":`)
+	writeJSONString(buf, p.Op)
+	buf.WriteString(`,"ts_ms":`)
+	writeJSONValue(buf, p.TsMs)
+	buf.WriteByte('}')
+	return nil
+}
+
+func writeSourcePayload(buf *bytes.Buffer, s *SourcePayload) error {
+	if s == nil {
+		buf.WriteString("null")
+		return nil
+	}
+	fields := []struct {
+		name  string
+		value interface{}
+	}{
+		{`{"version":`, s.Version},
+		{`,"name":`, s.Name},
+		{`,"server_id":`, s.ServerID},
+		{`,"ts_sec":`, s.TsSec},
+		{`,"gtid":`, s.Gtid},
+		{`,"file":`, s.File},
+		{`,"pos":`, s.Pos},
+		{`,"query":`, s.Query},
+		{`,"row":`, s.Row},
+		{`,"snapshot":`, s.Snapshot},
+		{`,"thread":`, s.Thread},
+		{`,"db":`, s.Db},
+		{`,"table":`, s.Table},
+	}
+	for _, field := range fields {
+		buf.WriteString(field.name)
+		if err := writeJSONValue(buf, field.value); err != nil {
+			return err
+		}
+	}
+	if s.Truncated != nil {
+		buf.WriteString(`,"truncated":`)
+		if err := writeJSONValue(buf, s.Truncated); err != nil {
+			return err
+		}
+	}
+	buf.WriteByte('}')
+	return nil
+}
+
+func releaseBuffer(buf *bytes.Buffer) {
+	if buf != nil {
+		bufferPool.Put(buf)
+	}
+}
+
+// releaseRow gives back a row got from NewRow once it is serialized
+func releaseRow(r *Row) {
+	if r == nil {
+		return
+	}
+	for i := range r.Values {
+		r.Values[i] = nil
+	}
+	r.ColNames = r.ColNames[:0]
+	r.Values = r.Values[:0]
+	rowPool.Put(r)
+}
+
+// releaseValuePayload gives back a payload got from NewValuePayload, along
+// with its rows, once it is serialized
+func releaseValuePayload(p *ValuePayload) {
+	releaseRow(p.Before)
+	releaseRow(p.After)
+	source := p.Source
+	*source = SourcePayload{}
+	*p = ValuePayload{Source: source}
+	valuePayloadPool.Put(p)
+}
+
+// writeJSONValue writes a value of a row as json.Marshal does, without
+// allocating for the common types
+func writeJSONValue(buf *bytes.Buffer, value interface{}) error {
+	var scratch [24]byte
+	switch v := value.(type) {
+	case nil:
+		buf.WriteString("null")
+	case string:
+		writeJSONString(buf, v)
+	case int64:
+		buf.Write(strconv.AppendInt(scratch[:0], v, 10))
+	case int:
+		buf.Write(strconv.AppendInt(scratch[:0], int64(v), 10))
+	case int32:
+		buf.Write(strconv.AppendInt(scratch[:0], int64(v), 10))
+	case uint64:
+		buf.Write(strconv.AppendUint(scratch[:0], v, 10))
+	case bool:
+		buf.Write(strconv.AppendBool(scratch[:0], v))
+	case float64:
+		return writeJSONFloat(buf, v, 64)
+	case float32:
+		return writeJSONFloat(buf, float64(v), 32)
+	default:
+		bs, err := json.Marshal(v)
+		if err != nil {
+			return err
+		}
+		buf.Write(bs)
+	}
+	return nil
+}
+
+// writeJSONFloat writes a float as json.Marshal does
+func writeJSONFloat(buf *bytes.Buffer, f float64, bits int) error {
+	if math.IsInf(f, 0) || math.IsNaN(f) {
+		return &json.UnsupportedValueError{Str: strconv.FormatFloat(f, 'g', -1, bits)}
+	}
+	var scratch [32]byte
+	format := byte('f')
+	if abs := math.Abs(f); abs != 0 {
+		if bits == 64 && (abs < 1e-6 || abs >= 1e21) || bits == 32 && (float32(abs) < 1e-6 || float32(abs) >= 1e21) {
+			format = 'e'
+		}
+	}
+	b := strconv.AppendFloat(scratch[:0], f, format, -1, bits)
+	if format == 'e' {
+		// clean up e-09 to e-9
+		if n := len(b); n >= 4 && b[n-4] == 'e' && b[n-3] == '-' && b[n-2] == '0' {
+			b[n-2] = b[n-1]
+			b = b[:n-1]
+		}
+	}
+	buf.Write(b)
+	return nil
+}
+
+const hexDigits = "0123456789abcdef"
+
+// writeJSONString writes a quoted JSON string with the escaping of
+// json.Marshal, which includes that of <, > and & for HTML
+func writeJSONString(buf *bytes.Buffer, s string) {
+	buf.WriteByte('"')
+	start := 0
+	for i := 0; i < len(s); {
+		if c := s[i]; c < utf8.RuneSelf {
+			if c >= 0x20 && c != '"' && c != '\\' && c != '<' && c != '>' && c != '&' {
+				i++
+				continue
+			}
+			buf.WriteString(s[start:i])
+			switch c {
+			case '"', '\\':
+				buf.WriteByte('\\')
+				buf.WriteByte(c)
+			case '\n':
+				buf.WriteString(`\n`)
+			case '\r':
+				buf.WriteString(`\r`)
+			case '\t':
+				buf.WriteString(`\t`)
+			case '\b':
+				buf.WriteString(`\b`)
+			case '\f':
+				buf.WriteString(`\f`)
+			default:
+				buf.WriteString(`\u00`)
+				buf.WriteByte(hexDigits[c>>4])
+				buf.WriteByte(hexDigits[c&0xF])
+			}
+			i++
+			start = i
+			continue
+		}
+		r, size := utf8.DecodeRuneInString(s[i:])
+		if r == utf8.RuneError && size == 1 {
+			buf.WriteString(s[start:i])
+			buf.WriteString("\ufffd")
+			i += size
+			start = i
+			continue
+		}
+		// U+2028 and U+2029 end lines in JavaScript
+		if r == '\u2028' || r == '\u2029' {
+			buf.WriteString(s[start:i])
+			buf.WriteString(`\u202`)
+			buf.WriteByte(hexDigits[r&0xF])
+			i += size
+			start = i
+			continue
+		}
+		i += size
+	}
+	buf.WriteString(s[start:])
+	buf.WriteByte('"')
+}
+
+// tombstoneValue is the value of the record following that of a delete, an
+// empty DbzOutput
+var tombstoneValue = []byte(`{"schema":null,"payload":null}`)
+
+// nullPayload is the tombstone value when the schemas are omitted
+var nullPayload = []byte("null")
@@ -0,0 +1,166 @@
+package kafka3
+
+import (
+	"encoding/json"
+	"math"
+	"testing"
+)
+
+func TestRowMarshalJSON(t *testing.T) {
+	values := []interface{}{
+		nil, "", "abc", "a\"b\\c", "<a&b>", "\x00\x01\x1f\b\f\n\r\t", "\xff\xfeok", "\u2028\u2029", "中文",
+		int64(-1), int64(math.MaxInt64), 7, int32(-7), uint64(math.MaxUint64), true, false,
+		1.5, float32(2.25), float32(0.1), 1e-7, 1e21, -0.0, []byte("bytes"), map[string]interface{}{"$patch": "x"},
+	}
+	row := NewRow()
+	for i, value := range values {
+		name := string(rune('a'+i)) + "<&>"
+		row.AddField(name, value)
+
+		got, err := json.Marshal(row)
+		if err != nil {
+			t.Fatalf("marshaling %#v: %v", value, err)
+		}
+		bs, err := json.Marshal(value)
+		if err != nil {
+			t.Fatal(err)
+		}
+		key, _ := json.Marshal(name)
+		suffix := string(key) + ":" + string(bs) + "}"
+		if s := string(got); len(s) < len(suffix) || s[len(s)-len(suffix):] != suffix {
+			t.Fatalf("marshaling %#v: got %s, want the suffix %s", value, got, suffix)
+		}
+	}
+	releaseRow(row)
+}
+
+func TestEncodeRecord(t *testing.T) {
+	test := func(v DbzOutput) {
+		want, err := json.Marshal(v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		buf, err := encodeRecord(v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if buf.String() != string(want) {
+			t.Fatalf("got %s, want %s", buf.String(), want)
+		}
+		releaseBuffer(buf)
+	}
+
+	row := NewRow()
+	row.AddField("id", int64(1))
+	row.AddField("name", "<x>")
+	test(DbzOutput{Schema: HeartbeatKeySchema, Payload: row})
+	test(DbzOutput{Schema: HeartbeatKeySchema, Payload: NewRow()})
+	test(DbzOutput{})
+
+	payload := NewValuePayload()
+	test(DbzOutput{Schema: HeartbeatValueSchema, Payload: payload})
+	payload.Before = row
+	payload.After = NewRow()
+	payload.After.AddField("id", int64(2))
+	payload.After.AddField("amount", 2.5)
+	payload.Op = RECORD_OP_UPDATE
+	payload.TsMs = 1234
+	payload.Source.Version = "0.0.1"
+	payload.Source.Name = "topic"
+	payload.Source.ServerID = 1
+	payload.Source.TsSec = 1234
+	payload.Source.Gtid = "8e1f3a16-0000-0000-0000-000000000000:12"
+	payload.Source.File = "bin.000001"
+	payload.Source.Pos = 4
+	payload.Source.Snapshot = true
+	payload.Source.Db = "db"
+	payload.Source.Table = "tb"
+	payload.Source.Truncated = "a,b"
+	test(DbzOutput{Schema: HeartbeatValueSchema, Payload: payload})
+	releaseValuePayload(payload)
+
+	if want, _ := json.Marshal(DbzOutput{}); string(want) != string(tombstoneValue) {
+		t.Fatalf("tombstone: got %s, want %s", tombstoneValue, want)
+	}
+}
+
+func TestReleaseValuePayload(t *testing.T) {
+	p := NewValuePayload()
+	p.After = NewRow()
+	p.After.AddField("id", int64(1))
+	p.Op = RECORD_OP_INSERT
+	p.Source.Db = "db"
+	releaseValuePayload(p)
+
+	p = NewValuePayload()
+	if p.Before != nil || p.After != nil || p.Op != "" || p.Source == nil || p.Source.Db != "" {
+		t.Fatalf("got a payload not reset: %+v %+v", p, p.Source)
+	}
+	row := NewRow()
+	if len(row.ColNames) != 0 || len(row.Values) != 0 {
+		t.Fatalf("got a row not reset: %+v", row)
+	}
+}
+
+func benchmarkRecord() DbzOutput {
+	payload := NewValuePayload()
+	payload.After = NewRow()
+	for _, name := range []string{"id", "name", "email", "created_at", "balance", "note"} {
+		payload.After.AddField(name+"_int", int64(1234567890))
+		payload.After.AddField(name, "some text value <with> \"quotes\"")
+	}
+	payload.Op = RECORD_OP_INSERT
+	payload.Source.Db = "db"
+	payload.Source.Table = "tb"
+	return DbzOutput{Schema: HeartbeatValueSchema, Payload: payload}
+}
+
+// BenchmarkMarshalRecord serializes a record with json.Marshal, which
+// marshals each row apart before the envelope
+func BenchmarkMarshalRecord(b *testing.B) {
+	v := benchmarkRecord()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := json.Marshal(v); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkEncodeRecord(b *testing.B) {
+	v := benchmarkRecord()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		buf, err := encodeRecord(v)
+		if err != nil {
+			b.Fatal(err)
+		}
+		releaseBuffer(buf)
+	}
+}
+
+// BenchmarkRecordRows builds, serializes and releases the rows of a record
+// for each event, as the Kafka runner does
+func BenchmarkRecordRows(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		key := NewRow()
+		key.AddField("id", int64(i))
+		payload := NewValuePayload()
+		payload.After = NewRow()
+		payload.After.AddField("id", int64(i))
+		payload.After.AddField("name", "value")
+		kBuf, err := encodeRecord(DbzOutput{Payload: key})
+		if err != nil {
+			b.Fatal(err)
+		}
+		vBuf, err := encodeRecord(DbzOutput{Payload: payload})
+		if err != nil {
+			b.Fatal(err)
+		}
+		releaseRow(key)
+		releaseValuePayload(payload)
+		releaseBuffer(kBuf)
+		releaseBuffer(vBuf)
+	}
+}
@@ -0,0 +1,543 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package kafka3
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/Shopify/sarama"
+	gonats "github.com/nats-io/go-nats"
+	"github.com/satori/go.uuid"
+
+	mysqlDriver "github.com/actiontech/dtle/internal/client/driver/mysql"
+	"github.com/actiontech/dtle/internal/client/driver/mysql/base"
+	"github.com/actiontech/dtle/internal/client/driver/mysql/binlog"
+	usql "github.com/actiontech/dtle/internal/client/driver/mysql/sql"
+	"github.com/actiontech/dtle/internal/config/mysql"
+	log "github.com/actiontech/dtle/internal/logger"
+	"github.com/actiontech/dtle/internal/models"
+)
+
+// The initial offsets of a source
+const (
+	InitialOffsetOldest = "oldest"
+	InitialOffsetNewest = "newest"
+)
+
+// maxRecordsPerMessage is the largest number of records sent at a time
+const maxRecordsPerMessage = 100
+
+// ValidateSource checks the config of a source task
+func (c *KafkaConfig) ValidateSource() error {
+	if len(c.Brokers) == 0 {
+		return fmt.Errorf("Brokers is empty")
+	}
+	if len(c.Topics) == 0 {
+		return fmt.Errorf("Topics is empty: the topics read must be listed")
+	}
+	switch c.InitialOffset {
+	case "", InitialOffsetOldest, InitialOffsetNewest:
+	default:
+		return fmt.Errorf("invalid InitialOffset %q: %s or %s", c.InitialOffset, InitialOffsetOldest, InitialOffsetNewest)
+	}
+	return nil
+}
+
+// consumerConfig returns the config of the client of a source
+func (c *KafkaConfig) consumerConfig() *sarama.Config {
+	config := sarama.NewConfig()
+	// The offsets committed in Kafka
+	config.Version = sarama.V0_10_0_0
+	config.Consumer.Return.Errors = true
+	config.Consumer.Offsets.Initial = sarama.OffsetOldest
+	if c.InitialOffset == InitialOffsetNewest {
+		config.Consumer.Offsets.Initial = sarama.OffsetNewest
+	}
+	return config
+}
+
+// CheckSourceConnection connects to the brokers of a source, and checks
+// that its topics exist
+func CheckSourceConnection(c *KafkaConfig) error {
+	client, err := sarama.NewClient(c.Brokers, c.consumerConfig())
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+	for _, topic := range c.Topics {
+		if _, err := client.Partitions(topic); err != nil {
+			return fmt.Errorf("topic %s: %v", topic, err)
+		}
+	}
+	return nil
+}
+
+// sourcePartition is a partition of a topic read by a source
+type sourcePartition struct {
+	topic     string
+	partition int32
+	// sid stands for the partition in the GTIDs of its records, whose GNO
+	// is made of their offset, so that the target skips the records it
+	// applied already
+	sid      uuid.UUID
+	consumer sarama.PartitionConsumer
+	offsets  sarama.PartitionOffsetManager
+}
+
+func partitionSid(topic string, partition int32) uuid.UUID {
+	return uuid.NewV5(uuid.NamespaceOID, fmt.Sprintf("kafka:%s:%d", topic, partition))
+}
+
+// sourceMessage is a message read from a partition
+type sourceMessage struct {
+	partition *sourcePartition
+	*sarama.ConsumerMessage
+}
+
+// KafkaSource reads Debezium change events from Kafka topics, as written
+// by the Kafka target of dtle or by Debezium, and sends them to a MySQL
+// target. The offsets are committed in a consumer group once sent.
+type KafkaSource struct {
+	logger     *log.Entry
+	subject    string
+	maxPayload int
+	cfg        *KafkaConfig
+	natsConn   *gonats.Conn
+	waitCh     chan *models.WaitResult
+
+	client     sarama.Client
+	consumer   sarama.Consumer
+	offsets    sarama.OffsetManager
+	partitions []*sourcePartition
+	messages   chan *sourceMessage
+
+	// columns are those of the tables read, by "schema.table"
+	columns map[string][]string
+
+	// positionLock guards position and gtid
+	positionLock sync.Mutex
+	// position is the partition and offset of the last record sent
+	position string
+	gtid     string
+
+	txCount int64
+	stage   string
+
+	shutdown     bool
+	shutdownCh   chan struct{}
+	shutdownLock sync.Mutex
+}
+
+func NewKafkaSource(subject, tp string, maxPayload int, cfg *KafkaConfig, logger *log.Logger) (*KafkaSource, error) {
+	if err := cfg.ValidateSource(); err != nil {
+		return nil, err
+	}
+	result := *cfg
+	if result.GroupID == "" {
+		result.GroupID = fmt.Sprintf("dtle-%s", subject)
+	}
+	entry := log.NewEntry(logger).WithFields(log.Fields{
+		"job": subject,
+	})
+	return &KafkaSource{
+		logger:     entry,
+		subject:    subject,
+		maxPayload: maxPayload,
+		cfg:        &result,
+		waitCh:     make(chan *models.WaitResult, 1),
+		messages:   make(chan *sourceMessage, maxRecordsPerMessage),
+		columns:    make(map[string][]string),
+		shutdownCh: make(chan struct{}),
+	}, nil
+}
+
+func (s *KafkaSource) Run() {
+	defer models.RecoverPanic(s.onPanic)
+	s.logger.Printf("kafka.source: Read topics %s as group %s", strings.Join(s.cfg.Topics, ", "), s.cfg.GroupID)
+
+	if err := s.initNatsPubClient(); err != nil {
+		s.onError(TaskStateDead, err)
+		return
+	}
+	if err := s.initConsumer(); err != nil {
+		s.onError(TaskStateDead, err)
+		return
+	}
+	if err := s.readRecords(); err != nil {
+		s.onError(TaskStateDead, err)
+	}
+}
+
+func (s *KafkaSource) initNatsPubClient() (err error) {
+	natsAddr := fmt.Sprintf("nats://%s", s.cfg.NatsAddr)
+	if s.natsConn, err = gonats.Connect(natsAddr); err != nil {
+		s.logger.Errorf("kafka.source: Can't connect nats server %v: %v", natsAddr, err)
+		return err
+	}
+	s.logger.Debugf("kafka.source: Connect nats server %v", natsAddr)
+	return nil
+}
+
+// initConsumer reads each partition of the topics from the offset
+// committed in the group, or from InitialOffset
+func (s *KafkaSource) initConsumer() (err error) {
+	if s.client, err = sarama.NewClient(s.cfg.Brokers, s.cfg.consumerConfig()); err != nil {
+		return err
+	}
+	if s.offsets, err = sarama.NewOffsetManagerFromClient(s.cfg.GroupID, s.client); err != nil {
+		return err
+	}
+	if s.consumer, err = sarama.NewConsumerFromClient(s.client); err != nil {
+		return err
+	}
+	for _, topic := range s.cfg.Topics {
+		partitions, err := s.client.Partitions(topic)
+		if err != nil {
+			return fmt.Errorf("topic %s: %v", topic, err)
+		}
+		for _, partition := range partitions {
+			p := &sourcePartition{topic: topic, partition: partition, sid: partitionSid(topic, partition)}
+			if p.offsets, err = s.offsets.ManagePartition(topic, partition); err != nil {
+				return err
+			}
+			s.partitions = append(s.partitions, p)
+			offset, _ := p.offsets.NextOffset()
+			if p.consumer, err = s.consumer.ConsumePartition(topic, partition, offset); err != nil {
+				return fmt.Errorf("topic %s partition %d from offset %d: %v", topic, partition, offset, err)
+			}
+			s.logger.Printf("kafka.source: Reading topic %s partition %d from offset %d", topic, partition, offset)
+			go s.forward(p)
+		}
+	}
+	return nil
+}
+
+// forward passes the messages of a partition to readRecords
+func (s *KafkaSource) forward(p *sourcePartition) {
+	defer models.RecoverPanic(s.onPanic)
+	for {
+		select {
+		case <-s.shutdownCh:
+			return
+		case msg, ok := <-p.consumer.Messages():
+			if !ok {
+				return
+			}
+			select {
+			case s.messages <- &sourceMessage{partition: p, ConsumerMessage: msg}:
+			case <-s.shutdownCh:
+				return
+			}
+		case err, ok := <-p.consumer.Errors():
+			if !ok {
+				return
+			}
+			s.onError(TaskStateDead, err)
+			return
+		case err, ok := <-p.offsets.Errors():
+			if !ok {
+				return
+			}
+			s.logger.Warnf("kafka.source: committing the offset of topic %s partition %d: %v", p.topic, p.partition, err)
+		}
+	}
+}
+
+// readRecords sends the records read, up to maxRecordsPerMessage at a
+// time, then marks their offsets to be committed
+func (s *KafkaSource) readRecords() error {
+	for {
+		s.stage = models.StageMasterHasSentAllBinlogToSlave
+		var batch []*sourceMessage
+		select {
+		case <-s.shutdownCh:
+			return nil
+		case msg := <-s.messages:
+			batch = append(batch, msg)
+		}
+	drain:
+		for len(batch) < maxRecordsPerMessage {
+			select {
+			case msg := <-s.messages:
+				batch = append(batch, msg)
+			default:
+				break drain
+			}
+		}
+
+		s.stage = models.StageSendingBinlogEventToSlave
+		var entries []*binlog.BinlogEntry
+		for _, msg := range batch {
+			r, err := ParseRecord(msg.Topic, msg.Key, msg.Value)
+			if err != nil {
+				return fmt.Errorf("%v, at partition %d offset %d", err, msg.Partition, msg.Offset)
+			}
+			if r == nil {
+				continue
+			}
+			events, err := s.recordEvents(r)
+			if err != nil {
+				return fmt.Errorf("%v, at topic %s partition %d offset %d", err, msg.Topic, msg.Partition, msg.Offset)
+			}
+			if len(events) == 0 {
+				continue
+			}
+			entry := binlog.NewBinlogEntryAt(base.BinlogCoordinateTx{
+				SID: msg.partition.sid,
+				GNO: msg.Offset + 1,
+			})
+			entry.Events = events
+			entries = append(entries, entry)
+		}
+		if len(entries) > 0 {
+			if err := s.publishEntries(entries); err != nil {
+				return err
+			}
+		}
+		for _, msg := range batch {
+			msg.partition.offsets.MarkOffset(msg.Offset+1, "")
+		}
+		last := batch[len(batch)-1]
+		s.positionLock.Lock()
+		s.position = fmt.Sprintf("%s/%d@%d", last.Topic, last.Partition, last.Offset)
+		s.positionLock.Unlock()
+	}
+}
+
+// recordEvents returns the events of a record, after those creating its
+// table on the target with the first record of the table, if it has a
+// schema. The values are sent in the order of the columns of the first
+// record.
+func (s *KafkaSource) recordEvents(r *Record) ([]binlog.DataEvent, error) {
+	var events []binlog.DataEvent
+	table := fmt.Sprintf("%s.%s", r.Db, r.Table)
+	columns, seen := s.columns[table]
+	if !seen && r.Columns() != nil {
+		if ddl := r.CreateTableSQL(); ddl != "" {
+			events = append(events,
+				binlog.NewQueryEventAffectTable("", fmt.Sprintf("CREATE DATABASE IF NOT EXISTS %s", usql.EscapeName(r.Db)),
+					binlog.NotDML, binlog.SchemaTable{Schema: r.Db}),
+				binlog.NewQueryEventAffectTable(usql.EscapeName(r.Db), ddl, binlog.NotDML,
+					binlog.SchemaTable{Schema: r.Db, Table: r.Table}))
+		}
+		columns = r.Columns()
+		s.columns[table] = columns
+	}
+	for _, name := range r.Columns() {
+		if !containsColumn(columns, name) {
+			return nil, fmt.Errorf("the columns of %s.%s changed from %s: change the table on the target, then restart the job",
+				r.Db, r.Table, strings.Join(columns, ", "))
+		}
+	}
+
+	rowValues := func(fields []field) ([]interface{}, error) {
+		if fields == nil {
+			return nil, nil
+		}
+		values, err := r.RowValues(fields)
+		if err != nil {
+			return nil, err
+		}
+		// In the order of the columns, those missing null
+		row := make([]interface{}, len(columns))
+		for i, f := range fields {
+			for j, name := range columns {
+				if name == f.name {
+					row[j] = values[i]
+				}
+			}
+		}
+		return row, nil
+	}
+	before, err := rowValues(r.Before)
+	if err != nil {
+		return nil, err
+	}
+	after, err := rowValues(r.After)
+	if err != nil {
+		return nil, err
+	}
+
+	var event binlog.DataEvent
+	switch r.Op {
+	case RECORD_OP_INSERT, RECORD_OP_READ:
+		event = binlog.NewDataEvent(r.Db, r.Table, binlog.InsertDML, len(columns))
+		event.NewColumnValues = mysql.ToColumnValues(after)
+	case RECORD_OP_UPDATE:
+		event = binlog.NewDataEvent(r.Db, r.Table, binlog.UpdateDML, len(columns))
+		if before == nil {
+			// The row is found by its key
+			before = after
+		}
+		event.WhereColumnValues = mysql.ToColumnValues(before)
+		event.NewColumnValues = mysql.ToColumnValues(after)
+	case RECORD_OP_DELETE:
+		event = binlog.NewDataEvent(r.Db, r.Table, binlog.DeleteDML, len(columns))
+		event.WhereColumnValues = mysql.ToColumnValues(before)
+	case RECORD_OP_TRUNCATE:
+		event = binlog.NewQueryEventAffectTable(usql.EscapeName(r.Db), fmt.Sprintf("TRUNCATE TABLE %s", usql.EscapeName(r.Table)),
+			binlog.NotDML, binlog.SchemaTable{Schema: r.Db, Table: r.Table})
+	default:
+		s.logger.Warnf("kafka.source: skipping operation %q of %s.%s", r.Op, r.Db, r.Table)
+		return events, nil
+	}
+	if event.DML == binlog.UpdateDML && after == nil || event.DML == binlog.DeleteDML && before == nil ||
+		event.DML == binlog.InsertDML && after == nil {
+		return nil, fmt.Errorf("operation %q of %s.%s without the row", r.Op, r.Db, r.Table)
+	}
+	return append(events, event), nil
+}
+
+func containsColumn(columns []string, name string) bool {
+	for _, c := range columns {
+		if c == name {
+			return true
+		}
+	}
+	return false
+}
+
+// publish sends a message to the target task, waiting for its reply
+func (s *KafkaSource) publish(subject string, msg []byte) (err error) {
+	if len(msg) > s.maxPayload {
+		return gonats.ErrMaxPayload
+	}
+	for {
+		_, err = s.natsConn.Request(subject, msg, mysqlDriver.DefaultConnectWait)
+		if err != gonats.ErrTimeout {
+			return err
+		}
+		s.logger.Debugf("kafka.source: publish timeout, got %v", err)
+		select {
+		case <-s.shutdownCh:
+			return err
+		default:
+		}
+	}
+}
+
+// publishEntries sends the records read, split in halves while too large
+// for a message
+func (s *KafkaSource) publishEntries(entries []*binlog.BinlogEntry) error {
+	msg, err := mysqlDriver.Encode(&binlog.BinlogEntries{Entries: entries})
+	if err != nil {
+		return err
+	}
+	err = s.publish(fmt.Sprintf("%s_incr_hete", s.subject), msg)
+	if err == gonats.ErrMaxPayload && len(entries) > 1 {
+		half := len(entries) / 2
+		if err := s.publishEntries(entries[:half]); err != nil {
+			return err
+		}
+		return s.publishEntries(entries[half:])
+	}
+	if err != nil {
+		return err
+	}
+	last := entries[len(entries)-1].Coordinates
+	s.positionLock.Lock()
+	s.gtid = fmt.Sprintf("%s:1-%d", last.SID, last.GNO)
+	s.positionLock.Unlock()
+	atomic.AddInt64(&s.txCount, int64(len(entries)))
+	return nil
+}
+
+func (s *KafkaSource) Stats() (*models.TaskStatistics, error) {
+	txCount := atomic.LoadInt64(&s.txCount)
+	stats := &models.TaskStatistics{
+		ExecMasterTxCount:  txCount,
+		ReadMasterTxCount:  txCount,
+		ProgressPct:        "0.0",
+		ETA:                "N/A",
+		Stage:              s.stage,
+		Timestamp:          time.Now().UTC().UnixNano(),
+		CurrentCoordinates: &models.CurrentCoordinates{},
+	}
+	s.positionLock.Lock()
+	// File is the topic, partition and offset of the last record read
+	stats.CurrentCoordinates.File = s.position
+	stats.CurrentCoordinates.GtidSet = s.gtid
+	s.positionLock.Unlock()
+	if s.natsConn != nil {
+		stats.MsgStat = s.natsConn.Statistics
+	}
+	return stats, nil
+}
+
+// ID returns the config to restart with, as config.DriverCtx is read back.
+// The offsets are those committed in the group.
+func (s *KafkaSource) ID() string {
+	id := struct {
+		DriverConfig *KafkaConfig
+	}{s.cfg}
+	data, err := json.Marshal(id)
+	if err != nil {
+		s.logger.Errorf("kafka.source: Failed to marshal ID to JSON: %s", err)
+	}
+	return string(data)
+}
+
+func (s *KafkaSource) onPanic(err *models.PanicError) {
+	s.onError(TaskStateRestart, err)
+}
+
+func (s *KafkaSource) onError(state int, err error) {
+	s.logger.Errorf("kafka.source. error: %v", err.Error())
+	s.shutdownLock.Lock()
+	shutdown := s.shutdown
+	s.shutdownLock.Unlock()
+	if shutdown {
+		return
+	}
+	select {
+	case s.waitCh <- models.NewWaitResult(state, err):
+	default:
+	}
+	s.Shutdown()
+}
+
+func (s *KafkaSource) WaitCh() chan *models.WaitResult {
+	return s.waitCh
+}
+
+// Shutdown is used to tear down the source, committing the offsets marked
+func (s *KafkaSource) Shutdown() error {
+	s.shutdownLock.Lock()
+	defer s.shutdownLock.Unlock()
+
+	if s.shutdown {
+		return nil
+	}
+	s.shutdown = true
+	close(s.shutdownCh)
+
+	for _, p := range s.partitions {
+		if p.consumer != nil {
+			p.consumer.AsyncClose()
+		}
+		p.offsets.Close()
+	}
+	if s.offsets != nil {
+		s.offsets.Close()
+	}
+	if s.consumer != nil {
+		s.consumer.Close()
+	}
+	if s.client != nil {
+		s.client.Close()
+	}
+	if s.natsConn != nil {
+		s.natsConn.Close()
+	}
+	s.logger.Printf("kafka.source: Shutting down")
+	return nil
+}
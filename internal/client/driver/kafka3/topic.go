@@ -0,0 +1,204 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package kafka3
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/Shopify/sarama"
+
+	"github.com/actiontech/dtle/internal/config"
+)
+
+// The cleanup.policy of the topics created
+const (
+	CleanupPolicyCompact = "compact"
+	CleanupPolicyDelete  = "delete"
+)
+
+// topicCreationTimeout is how long the controller may take to create a topic
+const topicCreationTimeout = 30 * time.Second
+
+// TopicCreationConfig is how the topics of the tables are created when they
+// do not exist, rather than by the auto-creation of the brokers
+type TopicCreationConfig struct {
+	// Partitions is the number of partitions of a topic. Defaults to 1.
+	Partitions int32
+	// ReplicationFactor is the number of replicas of the partitions.
+	// Defaults to 1.
+	ReplicationFactor int16
+	// CleanupPolicy is the cleanup.policy of a topic: "compact", "delete" or
+	// "compact,delete". Defaults to compact for the tables with a primary
+	// key, whose records are keyed, and to delete for the others.
+	CleanupPolicy string
+	// Configs are other configs of a topic, such as retention.ms
+	Configs map[string]string
+	// Tables override the settings above for the topics of some tables, by
+	// "schema.table". Their own Tables are ignored.
+	Tables map[string]*TopicCreationConfig
+}
+
+// settings returns the settings of the topic of a table, keyed telling
+// whether its records are keyed
+func (c *TopicCreationConfig) settings(schemaName, tableName string, keyed bool) *sarama.TopicDetail {
+	partitions, replicationFactor, cleanupPolicy := c.Partitions, c.ReplicationFactor, c.CleanupPolicy
+	configs := make(map[string]string)
+	for k, v := range c.Configs {
+		configs[k] = v
+	}
+	if table, ok := c.Tables[fmt.Sprintf("%s.%s", schemaName, tableName)]; ok && table != nil {
+		if table.Partitions > 0 {
+			partitions = table.Partitions
+		}
+		if table.ReplicationFactor > 0 {
+			replicationFactor = table.ReplicationFactor
+		}
+		if table.CleanupPolicy != "" {
+			cleanupPolicy = table.CleanupPolicy
+		}
+		for k, v := range table.Configs {
+			configs[k] = v
+		}
+	}
+
+	if partitions <= 0 {
+		partitions = 1
+	}
+	if replicationFactor <= 0 {
+		replicationFactor = 1
+	}
+	if cleanupPolicy == "" {
+		if keyed {
+			cleanupPolicy = CleanupPolicyCompact
+		} else {
+			cleanupPolicy = CleanupPolicyDelete
+		}
+	}
+	configs["cleanup.policy"] = cleanupPolicy
+
+	detail := &sarama.TopicDetail{
+		NumPartitions:     partitions,
+		ReplicationFactor: replicationFactor,
+		ConfigEntries:     make(map[string]*string),
+	}
+	for k, v := range configs {
+		v := v
+		detail.ConfigEntries[k] = &v
+	}
+	return detail
+}
+
+// validate checks the cleanup policies
+func (c *TopicCreationConfig) validate() error {
+	check := func(policy string) error {
+		switch policy {
+		case "", CleanupPolicyCompact, CleanupPolicyDelete, "compact,delete", "delete,compact":
+			return nil
+		}
+		return fmt.Errorf("invalid TopicCreation CleanupPolicy %q", policy)
+	}
+	if err := check(c.CleanupPolicy); err != nil {
+		return err
+	}
+	for _, table := range c.Tables {
+		if table == nil {
+			continue
+		}
+		if err := check(table.CleanupPolicy); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ensureTableTopic creates the topic of a table if TopicCreation is set and
+// the topic does not exist
+func (kr *KafkaRunner) ensureTableTopic(table *config.Table, topic string) error {
+	creation := kr.kafkaConfig.TopicCreation
+	if creation == nil || kr.kafkaMgr == nil {
+		return nil
+	}
+	keyed := false
+	for _, col := range table.OriginalTableColumns.ColumnList() {
+		if col.IsPk() {
+			keyed = true
+			break
+		}
+	}
+	created, err := kr.kafkaMgr.EnsureTopic(topic, creation.settings(table.TableSchema, table.TableName, keyed))
+	if err != nil {
+		return fmt.Errorf("kafka: creating topic %s: %v", topic, err)
+	}
+	if created {
+		kr.logger.Printf("kafka: created topic %s", topic)
+	}
+	return nil
+}
+
+// EnsureTopic creates a topic with the given settings unless it exists,
+// telling whether it did. The topics of the cluster are listed without
+// naming the topic, for the brokers not to auto-create it.
+func (k *KafkaManager) EnsureTopic(topic string, detail *sarama.TopicDetail) (bool, error) {
+	if k.dryRun != nil || k.sink != nil {
+		return false, nil
+	}
+	k.topicsMu.Lock()
+	defer k.topicsMu.Unlock()
+	if k.topics[topic] {
+		return false, nil
+	}
+
+	if k.admin == nil {
+		cfg := sarama.NewConfig()
+		// CreateTopics needs 0.10.1
+		cfg.Version = sarama.V0_10_1_0
+		admin, err := sarama.NewClient(k.Cfg.Brokers, cfg)
+		if err != nil {
+			return false, err
+		}
+		k.admin = admin
+		k.topics = make(map[string]bool)
+	}
+	if err := k.admin.RefreshMetadata(); err != nil {
+		return false, err
+	}
+	topics, err := k.admin.Topics()
+	if err != nil {
+		return false, err
+	}
+	for _, t := range topics {
+		k.topics[t] = true
+	}
+	if k.topics[topic] {
+		return false, nil
+	}
+
+	controller, err := k.admin.Controller()
+	if err != nil {
+		return false, err
+	}
+	resp, err := controller.CreateTopics(&sarama.CreateTopicsRequest{
+		TopicDetails: map[string]*sarama.TopicDetail{topic: detail},
+		Timeout:      topicCreationTimeout,
+	})
+	if err != nil {
+		return false, err
+	}
+	created := true
+	if topicErr, ok := resp.TopicErrors[topic]; ok && topicErr.Err != sarama.ErrNoError {
+		if topicErr.Err != sarama.ErrTopicAlreadyExists {
+			if topicErr.ErrMsg != nil {
+				return false, fmt.Errorf("%v: %s", topicErr.Err, *topicErr.ErrMsg)
+			}
+			return false, topicErr.Err
+		}
+		created = false
+	}
+	k.topics[topic] = true
+	return created, nil
+}
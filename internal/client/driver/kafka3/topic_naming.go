@@ -0,0 +1,128 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package kafka3
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/actiontech/dtle/internal/models"
+)
+
+const (
+	// maxTopicLength is the longest topic name Kafka accepts
+	maxTopicLength = 249
+	// topicHashLength is the hex digits of the hash suffixing a truncated
+	// topic name
+	topicHashLength = 8
+)
+
+// TopicNamingConfig is how the names of the databases and tables are made
+// valid topic names
+type TopicNamingConfig struct {
+	// ReplaceChar replaces the characters invalid in a topic name, those
+	// other than ASCII letters, digits, ".", "_" and "-". Defaults to "_".
+	ReplaceChar string
+	// MaxLength is the length beyond which a topic name is truncated and
+	// suffixed with "_" and a hash of the whole name, for distinct names to
+	// stay distinct. Defaults to and at most 249.
+	MaxLength int
+}
+
+func (c *TopicNamingConfig) validate() error {
+	if c == nil {
+		return nil
+	}
+	for _, r := range c.ReplaceChar {
+		if !validTopicChar(r) {
+			return fmt.Errorf("invalid TopicNaming ReplaceChar %q: not valid in a topic name", c.ReplaceChar)
+		}
+	}
+	if c.MaxLength != 0 && (c.MaxLength <= topicHashLength+1 || c.MaxLength > maxTopicLength) {
+		return fmt.Errorf("invalid TopicNaming MaxLength %d: not between %d and %d",
+			c.MaxLength, topicHashLength+2, maxTopicLength)
+	}
+	return nil
+}
+
+// topicName makes a valid topic name of name, the same name always giving
+// the same topic
+func (c *TopicNamingConfig) topicName(name string) string {
+	replaceChar, maxLength := "_", maxTopicLength
+	if c != nil {
+		if c.ReplaceChar != "" {
+			replaceChar = c.ReplaceChar
+		}
+		if c.MaxLength > 0 {
+			maxLength = c.MaxLength
+		}
+	}
+
+	var b bytes.Buffer
+	for _, r := range name {
+		if validTopicChar(r) {
+			b.WriteRune(r)
+		} else {
+			b.WriteString(replaceChar)
+		}
+	}
+	topic := b.String()
+	if len(topic) > maxLength {
+		sum := sha1.Sum([]byte(name))
+		suffix := "_" + hex.EncodeToString(sum[:])[:topicHashLength]
+		topic = topic[:maxLength-len(suffix)] + suffix
+	}
+	return topic
+}
+
+func validTopicChar(r rune) bool {
+	return r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' ||
+		r == '.' || r == '_' || r == '-'
+}
+
+// tableTopic returns the topic of the records of a table
+func (c *KafkaConfig) tableTopic(schemaName, tableName string) string {
+	return c.TopicNaming.topicName(fmt.Sprintf("%v.%v.%v", c.Topic, schemaName, tableName))
+}
+
+// topicMappings are the tables whose topic is not named after them as is,
+// reported in the stats for the consumers to find their topics
+type topicMappings struct {
+	mutex sync.Mutex
+	// mappings are by "schema.table"
+	mappings map[string]*models.TopicMapping
+}
+
+func (t *topicMappings) add(schemaName, tableName, topic string) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	if t.mappings == nil {
+		t.mappings = make(map[string]*models.TopicMapping)
+	}
+	t.mappings[fmt.Sprintf("%s.%s", schemaName, tableName)] = &models.TopicMapping{
+		TableSchema: schemaName,
+		TableName:   tableName,
+		Topic:       topic,
+	}
+}
+
+func (t *topicMappings) list() []*models.TopicMapping {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	list := make([]*models.TopicMapping, 0, len(t.mappings))
+	for _, m := range t.mappings {
+		list = append(list, m)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].TableSchema+"."+list[i].TableName < list[j].TableSchema+"."+list[j].TableName
+	})
+	return list
+}
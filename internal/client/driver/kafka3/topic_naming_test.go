@@ -0,0 +1,46 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package kafka3
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestTableTopic(t *testing.T) {
+	c := &KafkaConfig{Topic: "dtle"}
+	if got := c.tableTopic("shop", "order_items-2"); got != "dtle.shop.order_items-2" {
+		t.Fatalf("valid name: got %q", got)
+	}
+	if got := c.tableTopic("shop", "订单 $2"); got != "dtle.shop.____2" {
+		t.Fatalf("invalid characters: got %q", got)
+	}
+	c.TopicNaming = &TopicNamingConfig{ReplaceChar: "-"}
+	if got := c.tableTopic("my db", "t"); got != "dtle.my-db.t" {
+		t.Fatalf("ReplaceChar: got %q", got)
+	}
+
+	c.TopicNaming = &TopicNamingConfig{MaxLength: 30}
+	long1 := c.tableTopic("shop", strings.Repeat("a", 40)+"1")
+	long2 := c.tableTopic("shop", strings.Repeat("a", 40)+"2")
+	if len(long1) != 30 || len(long2) != 30 {
+		t.Fatalf("got %q and %q, want 30 characters", long1, long2)
+	}
+	if long1 == long2 {
+		t.Fatalf("distinct names truncated to the same topic %q", long1)
+	}
+	if again := c.tableTopic("shop", strings.Repeat("a", 40)+"1"); again != long1 {
+		t.Fatalf("got %q, then %q", long1, again)
+	}
+
+	if err := (&TopicNamingConfig{ReplaceChar: "$"}).validate(); err == nil {
+		t.Fatalf("invalid ReplaceChar accepted")
+	}
+	if err := (&TopicNamingConfig{MaxLength: 300}).validate(); err == nil {
+		t.Fatalf("MaxLength beyond the limit of Kafka accepted")
+	}
+}
@@ -0,0 +1,50 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package kafka3
+
+import (
+	"testing"
+)
+
+func TestTopicCreationSettings(t *testing.T) {
+	c := &TopicCreationConfig{
+		Partitions: 6,
+		Configs:    map[string]string{"retention.ms": "86400000"},
+		Tables: map[string]*TopicCreationConfig{
+			"shop.orders": {Partitions: 12, ReplicationFactor: 3, CleanupPolicy: "compact,delete"},
+		},
+	}
+
+	keyed := c.settings("shop", "items", true)
+	if keyed.NumPartitions != 6 || keyed.ReplicationFactor != 1 {
+		t.Fatalf("got %d partitions and %d replicas, want 6 and 1", keyed.NumPartitions, keyed.ReplicationFactor)
+	}
+	if got := *keyed.ConfigEntries["cleanup.policy"]; got != CleanupPolicyCompact {
+		t.Fatalf("keyed table: got cleanup.policy %q", got)
+	}
+	if got := *keyed.ConfigEntries["retention.ms"]; got != "86400000" {
+		t.Fatalf("got retention.ms %q", got)
+	}
+	if got := *c.settings("shop", "logs", false).ConfigEntries["cleanup.policy"]; got != CleanupPolicyDelete {
+		t.Fatalf("table without key: got cleanup.policy %q", got)
+	}
+
+	orders := c.settings("shop", "orders", true)
+	if orders.NumPartitions != 12 || orders.ReplicationFactor != 3 {
+		t.Fatalf("got %d partitions and %d replicas, want 12 and 3", orders.NumPartitions, orders.ReplicationFactor)
+	}
+	if got := *orders.ConfigEntries["cleanup.policy"]; got != "compact,delete" {
+		t.Fatalf("overridden table: got cleanup.policy %q", got)
+	}
+	if _, ok := orders.ConfigEntries["retention.ms"]; !ok {
+		t.Fatalf("the configs of the topics are not kept for an overridden table")
+	}
+
+	if err := (&TopicCreationConfig{CleanupPolicy: "compacted"}).validate(); err == nil {
+		t.Fatalf("invalid cleanup policy accepted")
+	}
+}
@@ -0,0 +1,305 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package kafka3
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/satori/go.uuid"
+)
+
+// The defaults of the webhook sink
+const (
+	DefaultWebhookBatchSize      = 500
+	DefaultWebhookMaxRetries     = 10
+	DefaultWebhookConcurrency    = 1
+	DefaultWebhookTimeoutSeconds = 30
+)
+
+// The backoff between the attempts to post a batch, doubling from
+// webhookInitialBackoff
+const (
+	webhookInitialBackoff = time.Second
+	webhookMaxBackoff     = time.Minute
+)
+
+// The headers of the requests of the webhook sink
+const (
+	WebhookBatchIDHeader   = "X-Dtle-Batch-Id"
+	WebhookTimestampHeader = "X-Dtle-Timestamp"
+	WebhookSignatureHeader = "X-Dtle-Signature"
+)
+
+// WebhookConfig is the HTTP endpoint the Webhook driver posts the records
+// to, in batches, rather than sending them to Kafka
+type WebhookConfig struct {
+	// URL is the endpoint the batches are posted to
+	URL string
+	// Headers are added to the requests, such as Authorization
+	Headers map[string]string
+	// Secret signs the batches: the WebhookSignatureHeader is "sha256=" and
+	// the hex HMAC-SHA256 of the WebhookTimestampHeader, "." and the body
+	Secret string
+	// BatchSize is the most records of a batch. Defaults to
+	// DefaultWebhookBatchSize.
+	BatchSize int
+	// MaxRetries is how many times a batch is posted again on a network
+	// error, a 429 or a 5xx response, before the task fails. Defaults to
+	// DefaultWebhookMaxRetries.
+	MaxRetries int
+	// Concurrency is the most batches posted at once. Above 1, the records
+	// are ordered by topic only, the batches of a topic being posted one
+	// after the other. Defaults to DefaultWebhookConcurrency.
+	Concurrency int
+	// TimeoutSeconds is the timeout of a request. Defaults to
+	// DefaultWebhookTimeoutSeconds.
+	TimeoutSeconds int
+}
+
+// ValidateWebhook checks the config of the Webhook driver
+func (c *KafkaConfig) ValidateWebhook() error {
+	if c.Webhook == nil || c.Webhook.URL == "" {
+		return fmt.Errorf("the Webhook URL is required")
+	}
+	u, err := url.Parse(c.Webhook.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return fmt.Errorf("invalid Webhook URL %q: not an http or https URL", c.Webhook.URL)
+	}
+	if c.Webhook.BatchSize < 0 || c.Webhook.MaxRetries < 0 || c.Webhook.Concurrency < 0 ||
+		c.Webhook.TimeoutSeconds < 0 {
+		return fmt.Errorf("invalid Webhook: BatchSize, MaxRetries, Concurrency and TimeoutSeconds can not be negative")
+	}
+	if c.Converter == CONVERTER_PROTOBUF {
+		return fmt.Errorf("the Webhook driver posts JSON records, not those of the protobuf Converter")
+	}
+	return nil
+}
+
+// webhookEvent is a record in a batch
+type webhookEvent struct {
+	Topic string          `json:"topic"`
+	Key   json.RawMessage `json:"key"`
+	// Value is null for a tombstone
+	Value json.RawMessage `json:"value"`
+}
+
+// webhookSink posts the records to the Webhook URL. They are posted when a
+// batch is full, and on flush, which the runner calls before acknowledging
+// the messages of the source.
+type webhookSink struct {
+	cfg    WebhookConfig
+	client *http.Client
+	// warnf, if set, logs the retries
+	warnf func(format string, args ...interface{})
+
+	mutex  sync.Mutex
+	events []*webhookEvent
+
+	closeCh   chan struct{}
+	closeOnce sync.Once
+}
+
+func newWebhookSink(cfg *WebhookConfig) *webhookSink {
+	s := &webhookSink{
+		cfg:     *cfg,
+		closeCh: make(chan struct{}),
+	}
+	if s.cfg.BatchSize == 0 {
+		s.cfg.BatchSize = DefaultWebhookBatchSize
+	}
+	if s.cfg.MaxRetries == 0 {
+		s.cfg.MaxRetries = DefaultWebhookMaxRetries
+	}
+	if s.cfg.Concurrency == 0 {
+		s.cfg.Concurrency = DefaultWebhookConcurrency
+	}
+	if s.cfg.TimeoutSeconds == 0 {
+		s.cfg.TimeoutSeconds = DefaultWebhookTimeoutSeconds
+	}
+	s.client = &http.Client{Timeout: time.Duration(s.cfg.TimeoutSeconds) * time.Second}
+	return s
+}
+
+// add adds a record to the batch, copying its key and value. The batches
+// are posted once BatchSize records are added per concurrent request.
+func (s *webhookSink) add(topic string, key, value []byte) error {
+	event := &webhookEvent{Topic: topic}
+	if len(key) > 0 {
+		event.Key = append(json.RawMessage(nil), key...)
+	}
+	if value != nil {
+		event.Value = append(json.RawMessage(nil), value...)
+	}
+	s.mutex.Lock()
+	s.events = append(s.events, event)
+	full := len(s.events) >= s.cfg.BatchSize*s.cfg.Concurrency
+	s.mutex.Unlock()
+	if full {
+		return s.flush()
+	}
+	return nil
+}
+
+// flush posts the records added, in batches of at most BatchSize
+func (s *webhookSink) flush() error {
+	s.mutex.Lock()
+	events := s.events
+	s.events = nil
+	s.mutex.Unlock()
+	if len(events) == 0 {
+		return nil
+	}
+	if s.cfg.Concurrency <= 1 {
+		return s.postAll(events)
+	}
+
+	// the records of a topic stay in order, its batches posted one by one
+	var topics []string
+	byTopic := make(map[string][]*webhookEvent)
+	for _, event := range events {
+		if _, ok := byTopic[event.Topic]; !ok {
+			topics = append(topics, event.Topic)
+		}
+		byTopic[event.Topic] = append(byTopic[event.Topic], event)
+	}
+	sem := make(chan struct{}, s.cfg.Concurrency)
+	errCh := make(chan error, len(topics))
+	var wg sync.WaitGroup
+	for _, topic := range topics {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(events []*webhookEvent) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			errCh <- s.postAll(events)
+		}(byTopic[topic])
+	}
+	wg.Wait()
+	close(errCh)
+	for err := range errCh {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *webhookSink) postAll(events []*webhookEvent) error {
+	for len(events) > 0 {
+		n := len(events)
+		if n > s.cfg.BatchSize {
+			n = s.cfg.BatchSize
+		}
+		if err := s.post(events[:n]); err != nil {
+			return err
+		}
+		events = events[n:]
+	}
+	return nil
+}
+
+// post posts a batch, retrying with a backoff. The retries of a batch have
+// its WebhookBatchIDHeader, for the endpoint to tell them.
+func (s *webhookSink) post(events []*webhookEvent) error {
+	body, err := json.Marshal(map[string]interface{}{"events": events})
+	if err != nil {
+		return err
+	}
+	batchID := uuid.NewV4().String()
+	backoff := webhookInitialBackoff
+	for attempt := 0; ; attempt++ {
+		retryAfter, retryable, err := s.postOnce(batchID, body)
+		if err == nil {
+			return nil
+		}
+		if !retryable || attempt >= s.cfg.MaxRetries {
+			return fmt.Errorf("webhook: posting a batch of %d records: %v", len(events), err)
+		}
+		wait := backoff
+		if retryAfter > wait {
+			wait = retryAfter
+		}
+		if s.warnf != nil {
+			s.warnf("webhook: posting a batch of %d records: %v. Retrying in %v", len(events), err, wait)
+		}
+		select {
+		case <-time.After(wait):
+		case <-s.closeCh:
+			return fmt.Errorf("webhook: closed posting a batch of %d records: %v", len(events), err)
+		}
+		if backoff *= 2; backoff > webhookMaxBackoff {
+			backoff = webhookMaxBackoff
+		}
+	}
+}
+
+// postOnce posts a batch, telling whether a failure is to retry and after
+// how long the endpoint asked to
+func (s *webhookSink) postOnce(batchID string, body []byte) (retryAfter time.Duration, retryable bool, err error) {
+	req, err := http.NewRequest("POST", s.cfg.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, false, err
+	}
+	for k, v := range s.cfg.Headers {
+		req.Header.Set(k, v)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(WebhookBatchIDHeader, batchID)
+	if s.cfg.Secret != "" {
+		timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+		req.Header.Set(WebhookTimestampHeader, timestamp)
+		req.Header.Set(WebhookSignatureHeader, "sha256="+webhookSignature(s.cfg.Secret, timestamp, body))
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return 0, true, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 == 2 {
+		io.Copy(ioutil.Discard, resp.Body)
+		return 0, false, nil
+	}
+	msg, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 512))
+	err = fmt.Errorf("%s: %s", resp.Status, strings.TrimSpace(string(msg)))
+	if resp.StatusCode != http.StatusTooManyRequests && resp.StatusCode/100 != 5 {
+		return 0, false, err
+	}
+	if seconds, parseErr := strconv.Atoi(resp.Header.Get("Retry-After")); parseErr == nil && seconds > 0 {
+		retryAfter = time.Duration(seconds) * time.Second
+	}
+	return retryAfter, true, err
+}
+
+// webhookSignature returns the hex HMAC-SHA256 of a request
+func webhookSignature(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// close stops the retries in progress
+func (s *webhookSink) close() {
+	s.closeOnce.Do(func() {
+		close(s.closeCh)
+	})
+}
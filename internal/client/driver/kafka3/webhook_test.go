@@ -0,0 +1,155 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package kafka3
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+func TestWebhookSink(t *testing.T) {
+	var mutex sync.Mutex
+	var batches [][]webhookEvent
+	var batchIDs []string
+	fail := 1
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		body, _ := ioutil.ReadAll(r.Body)
+		batchIDs = append(batchIDs, r.Header.Get(WebhookBatchIDHeader))
+		signature := "sha256=" + webhookSignature("secret", r.Header.Get(WebhookTimestampHeader), body)
+		if r.Header.Get(WebhookSignatureHeader) != signature || r.Header.Get("Authorization") != "Bearer x" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if fail > 0 {
+			fail--
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		var batch struct {
+			Events []webhookEvent `json:"events"`
+		}
+		if err := json.Unmarshal(body, &batch); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		batches = append(batches, batch.Events)
+	}))
+	defer server.Close()
+
+	cfg := &KafkaConfig{Webhook: &WebhookConfig{
+		URL:       server.URL,
+		Headers:   map[string]string{"Authorization": "Bearer x"},
+		Secret:    "secret",
+		BatchSize: 2,
+	}}
+	if err := cfg.ValidateWebhook(); err != nil {
+		t.Fatal(err)
+	}
+	k, err := NewKafkaManager(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer k.Close()
+	if created, err := k.EnsureTopic("t", nil); created || err != nil {
+		t.Fatalf("EnsureTopic: got %v, %v", created, err)
+	}
+
+	for _, r := range []struct{ key, value string }{{`{"id":1}`, `{"v":1}`}, {`{"id":2}`, `{"v":2}`}, {`{"id":2}`, ""}} {
+		var value []byte
+		if r.value != "" {
+			value = []byte(r.value)
+		}
+		if err := k.Send("dtle.db.tb", []byte(r.key), value); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// the first batch is full and posted, after a retry
+	if len(batches) != 1 || len(batches[0]) != 2 || len(batchIDs) != 2 || batchIDs[0] != batchIDs[1] {
+		t.Fatalf("got batches %v, ids %v", batches, batchIDs)
+	}
+	if err := k.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if len(batches) != 2 || len(batches[1]) != 1 || string(batches[1][0].Key) != `{"id":2}` ||
+		string(batches[1][0].Value) != "null" || batches[1][0].Topic != "dtle.db.tb" {
+		t.Fatalf("got batches %v", batches)
+	}
+
+	// a 4xx other than 429 is not retried
+	cfg.Webhook.Secret = "other"
+	k2, err := NewKafkaManager(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer k2.Close()
+	k2.Send("dtle.db.tb", []byte(`{"id":3}`), []byte(`{}`))
+	if err := k2.Flush(); err == nil {
+		t.Fatal("unauthorized: no error")
+	}
+	if len(batchIDs) != 4 {
+		t.Fatalf("unauthorized: got %d requests, want 4", len(batchIDs))
+	}
+}
+
+func TestWebhookSinkConcurrency(t *testing.T) {
+	var mutex sync.Mutex
+	events := make(map[string][]string)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var batch struct {
+			Events []webhookEvent `json:"events"`
+		}
+		json.NewDecoder(r.Body).Decode(&batch)
+		mutex.Lock()
+		defer mutex.Unlock()
+		for _, event := range batch.Events {
+			events[event.Topic] = append(events[event.Topic], string(event.Value))
+		}
+	}))
+	defer server.Close()
+
+	s := newWebhookSink(&WebhookConfig{URL: server.URL, BatchSize: 1, Concurrency: 3})
+	for i := 0; i < 5; i++ {
+		for _, topic := range []string{"a", "b"} {
+			if err := s.add(topic, []byte("{}"), []byte{'0' + byte(i)}); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	if err := s.flush(); err != nil {
+		t.Fatal(err)
+	}
+	for _, topic := range []string{"a", "b"} {
+		got := events[topic]
+		if len(got) != 5 {
+			t.Fatalf("topic %s: got %v", topic, got)
+		}
+		for i, v := range got {
+			if v != string('0'+rune(i)) {
+				t.Fatalf("topic %s: out of order: %v", topic, got)
+			}
+		}
+	}
+}
+
+func TestValidateWebhook(t *testing.T) {
+	for i, c := range []*KafkaConfig{
+		{},
+		{Webhook: &WebhookConfig{URL: "ftp://host"}},
+		{Webhook: &WebhookConfig{URL: "http://host", BatchSize: -1}},
+		{Webhook: &WebhookConfig{URL: "http://host"}, Converter: CONVERTER_PROTOBUF},
+	} {
+		if err := c.ValidateWebhook(); err == nil {
+			t.Fatalf("%d: no error", i)
+		}
+	}
+}
@@ -0,0 +1,68 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package driver
+
+import (
+	"fmt"
+
+	"github.com/mitchellh/mapstructure"
+
+	"github.com/actiontech/dtle/internal/client/driver/mongodb"
+	"github.com/actiontech/dtle/internal/models"
+)
+
+// MongoDBDriver reads MongoDB collections with change streams, as the
+// source of a MySQL or Kafka target
+type MongoDBDriver struct {
+	DriverContext
+}
+
+func (md *MongoDBDriver) Start(ctx *ExecContext, task *models.Task) (DriverHandle, error) {
+	var driverConfig mongodb.MongoDBConfig
+	if err := mapstructure.WeakDecode(task.Config, &driverConfig); err != nil {
+		return nil, err
+	}
+
+	switch task.Type {
+	case models.TaskTypeSrc:
+		e, err := mongodb.NewExtractor(ctx.Subject, ctx.Tp, ctx.MaxPayload, &driverConfig, md.logger)
+		if err != nil {
+			return nil, err
+		}
+		go e.Run()
+		return e, nil
+	case models.TaskTypeDest:
+		return nil, fmt.Errorf("MongoDB can only be used on 'Src'")
+	default:
+		return nil, fmt.Errorf("unknown processor type : %+v", task.Type)
+	}
+}
+
+func (md *MongoDBDriver) Validate(task *models.Task) (*models.TaskValidateResponse, error) {
+	var driverConfig mongodb.MongoDBConfig
+	reply := &models.TaskValidateResponse{}
+	if err := mapstructure.WeakDecode(task.Config, &driverConfig); err != nil {
+		return reply, err
+	}
+	if task.Type != models.TaskTypeSrc {
+		return reply, fmt.Errorf("MongoDB can only be used on 'Src'")
+	}
+	if err := driverConfig.SetDefault().Validate(); err != nil {
+		return reply, err
+	}
+	if err := mongodb.CheckConnection(driverConfig.ConnectionConfig); err != nil {
+		reply.Connection.Success = false
+		reply.Connection.Error = err.Error()
+	} else {
+		reply.Connection.Success = true
+	}
+	return reply, nil
+}
+
+func NewMongoDBDriver(ctx *DriverContext) Driver {
+	return &MongoDBDriver{DriverContext: *ctx}
+}
@@ -0,0 +1,143 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package mongodb
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+)
+
+// client is the part of the MongoDB driver the extractor uses. Documents
+// and change events are read as relaxed extended JSON.
+type client interface {
+	// SourceID returns the name of the replica set, or the ID of the
+	// sharded cluster
+	SourceID(ctx context.Context) (string, error)
+	CollectionNames(ctx context.Context, database string) ([]string, error)
+	// OperationTime returns the cluster time of the last write
+	OperationTime(ctx context.Context) (timestamp, error)
+	EstimatedCount(ctx context.Context, database, collection string) (int64, error)
+	Find(ctx context.Context, database, collection string) (cursor, error)
+	// Watch opens a change stream of the databases, with the full document
+	// of the updates, after a resume token or else from a cluster time
+	Watch(ctx context.Context, databases []string, resumeToken string, from timestamp) (changeStream, error)
+	Close(ctx context.Context) error
+}
+
+type cursor interface {
+	Next(ctx context.Context) bool
+	Document() ([]byte, error)
+	Err() error
+	Close(ctx context.Context) error
+}
+
+type changeStream interface {
+	// Next waits for the next event
+	Next(ctx context.Context) bool
+	// TryNext reads the next event if one was received
+	TryNext(ctx context.Context) bool
+	Event() ([]byte, error)
+	Err() error
+	Close(ctx context.Context) error
+}
+
+// dial connects to MongoDB, set by the build with the "mongodb" tag
+var dial func(ctx context.Context, uri string) (client, error)
+
+// connect connects to a MongoDB replica set or sharded cluster
+func connect(ctx context.Context, c *ConnectionConfig) (client, error) {
+	if dial == nil {
+		return nil, fmt.Errorf("this build has no MongoDB driver: build dtle with the %q tag", "mongodb")
+	}
+	return dial(ctx, c.URI())
+}
+
+// timestamp is a cluster time: the seconds and the ordinal of the operation
+// in the second
+type timestamp struct {
+	T uint32
+	I uint32
+}
+
+func (t *timestamp) UnmarshalJSON(data []byte) error {
+	var v struct {
+		Timestamp *struct {
+			T uint32 `json:"t"`
+			I uint32 `json:"i"`
+		} `json:"$timestamp"`
+	}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	if v.Timestamp == nil {
+		return fmt.Errorf("invalid timestamp %s", data)
+	}
+	t.T, t.I = v.Timestamp.T, v.Timestamp.I
+	return nil
+}
+
+func (t timestamp) String() string {
+	return fmt.Sprintf("%d.%d", t.T, t.I)
+}
+
+func (t timestamp) isZero() bool {
+	return t.T == 0 && t.I == 0
+}
+
+func (t timestamp) after(other timestamp) bool {
+	return t.T > other.T || t.T == other.T && t.I > other.I
+}
+
+// gno returns the GNO of the GTID of the transaction at the cluster time.
+// Both grow together, and either is found from the other.
+func (t timestamp) gno() int64 {
+	return int64(t.T)<<32 | int64(t.I)
+}
+
+func timestampOfGno(gno int64) timestamp {
+	return timestamp{T: uint32(gno >> 32), I: uint32(gno)}
+}
+
+// The operationType of the change events
+const (
+	operationInsert       = "insert"
+	operationUpdate       = "update"
+	operationReplace      = "replace"
+	operationDelete       = "delete"
+	operationDrop         = "drop"
+	operationRename       = "rename"
+	operationDropDatabase = "dropDatabase"
+	operationInvalidate   = "invalidate"
+)
+
+// changeEvent is an event of a change stream
+type changeEvent struct {
+	ID struct {
+		Data string `json:"_data"`
+	} `json:"_id"`
+	OperationType string    `json:"operationType"`
+	ClusterTime   timestamp `json:"clusterTime"`
+	Ns            struct {
+		Db   string `json:"db"`
+		Coll string `json:"coll"`
+	} `json:"ns"`
+	DocumentKey  json.RawMessage `json:"documentKey"`
+	FullDocument json.RawMessage `json:"fullDocument"`
+}
+
+// parseChangeEvent parses a change event read as relaxed extended JSON
+func parseChangeEvent(data []byte) (*changeEvent, error) {
+	event := &changeEvent{}
+	if err := json.Unmarshal(data, event); err != nil {
+		return nil, fmt.Errorf("invalid change event: %v", err)
+	}
+	if string(event.FullDocument) == "null" {
+		event.FullDocument = nil
+	}
+	return event, nil
+}
@@ -0,0 +1,175 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package mongodb
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/actiontech/dtle/internal/config"
+)
+
+const (
+	defaultPort      = 27017
+	defaultChunkSize = 2000
+)
+
+// ConnectionConfig is the MongoDB replica set or sharded cluster a task
+// connects to
+type ConnectionConfig struct {
+	Host     string
+	Port     int
+	User     string
+	Password string
+	// AuthSource is the database the user is defined in, admin by default
+	AuthSource string
+	ReplicaSet string
+}
+
+// URI returns the connection string the MongoDB driver connects with
+func (c *ConnectionConfig) URI() string {
+	port := c.Port
+	if port == 0 {
+		port = defaultPort
+	}
+	u := &url.URL{
+		Scheme: "mongodb",
+		Host:   fmt.Sprintf("%s:%d", c.Host, port),
+		Path:   "/",
+	}
+	if c.User != "" {
+		u.User = url.UserPassword(c.User, c.Password)
+	}
+	query := url.Values{}
+	if c.AuthSource != "" {
+		query.Set("authSource", c.AuthSource)
+	}
+	if c.ReplicaSet != "" {
+		query.Set("replicaSet", c.ReplicaSet)
+	}
+	u.RawQuery = query.Encode()
+	return u.String()
+}
+
+// CollectionMapping flattens the documents of a collection into the
+// columns of a table
+type CollectionMapping struct {
+	Database   string
+	Collection string
+	// Table is the table created on the target, the collection by default
+	Table  string
+	Fields []*FieldMapping
+}
+
+// FieldMapping is a field of the documents copied to a column
+type FieldMapping struct {
+	// Path is the field in the document, with a dot between the names of
+	// the nested documents and the indexes in the arrays, such as
+	// address.city or items.0.sku
+	Path string
+	// Column is the column, the path with _ instead of the dots by default
+	Column string
+	// Type is the MySQL type of the column, longtext by default. A field
+	// holding a document or an array is written as JSON.
+	Type string
+}
+
+// MongoDBConfig is the config of a MongoDB source task
+type MongoDBConfig struct {
+	ConnectionConfig *ConnectionConfig
+	// ReplicateDoDb are the databases, as TableSchema, and their
+	// collections, as TableName, replicated. A database without
+	// collections is replicated whole, with the collections created later.
+	ReplicateDoDb []*config.DataSource
+	NatsAddr      string
+	// ChunkSize is the number of documents of a collection sent at a time
+	// in the snapshot
+	ChunkSize int64
+	// Mappings flatten the documents of some collections into tables. The
+	// other collections are replicated to a table of their _id and the
+	// document as JSON, which the Kafka target sends as a JSON payload.
+	Mappings []*CollectionMapping
+
+	// ResumeToken is the resume token of the change stream the changes are
+	// read after, skipping the snapshot
+	ResumeToken string
+	// Gtid is the last transaction replicated, as reported in the
+	// statistics: "uuid:1-n", n standing for its cluster time. The changes
+	// are read after it if ResumeToken is not set. With neither, a snapshot
+	// of the collections is copied, then the changes are read from its
+	// cluster time.
+	Gtid string
+}
+
+// SetDefault returns a copy of the config with the defaults set
+func (c *MongoDBConfig) SetDefault() *MongoDBConfig {
+	result := *c
+	if result.ChunkSize <= 0 {
+		result.ChunkSize = defaultChunkSize
+	}
+	return &result
+}
+
+// Validate checks the config of a source task
+func (c *MongoDBConfig) Validate() error {
+	if c.ConnectionConfig == nil {
+		return fmt.Errorf("ConnectionConfig is missing")
+	}
+	if len(c.ReplicateDoDb) == 0 {
+		return fmt.Errorf("ReplicateDoDb is empty: the databases of a MongoDB source must be listed")
+	}
+	mapped := make(map[string]bool)
+	for _, m := range c.Mappings {
+		if m.Database == "" || m.Collection == "" {
+			return fmt.Errorf("the Database and Collection of a mapping are missing")
+		}
+		ns := fmt.Sprintf("%s.%s", m.Database, m.Collection)
+		if mapped[ns] {
+			return fmt.Errorf("collection %s is mapped twice", ns)
+		}
+		mapped[ns] = true
+		if !c.replicated(m.Database, m.Collection) {
+			return fmt.Errorf("collection %s is mapped but not in ReplicateDoDb", ns)
+		}
+		if _, err := newMappedCollection(m.Database, m.Collection, m); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// replicated tells whether a collection is in ReplicateDoDb
+func (c *MongoDBConfig) replicated(database, collection string) bool {
+	if strings.HasPrefix(collection, "system.") {
+		return false
+	}
+	for _, ds := range c.ReplicateDoDb {
+		if ds.TableSchema != database {
+			continue
+		}
+		if len(ds.Tables) == 0 {
+			return true
+		}
+		for _, t := range ds.Tables {
+			if t.TableName == collection {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// mapping returns the mapping of a collection, nil if it has none
+func (c *MongoDBConfig) mapping(database, collection string) *CollectionMapping {
+	for _, m := range c.Mappings {
+		if m.Database == database && m.Collection == collection {
+			return m
+		}
+	}
+	return nil
+}
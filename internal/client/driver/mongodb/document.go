@@ -0,0 +1,467 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package mongodb
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	usql "github.com/actiontech/dtle/internal/client/driver/mysql/sql"
+	"github.com/actiontech/dtle/internal/config"
+	umconf "github.com/actiontech/dtle/internal/config/mysql"
+)
+
+// The columns of the table of a collection without mapping
+const (
+	idColumn       = "_id"
+	documentColumn = "document"
+)
+
+// column is a column of the table of a collection, and the field of the
+// documents it is read from
+type column struct {
+	name string
+	// path is the field, the whole document if empty
+	path      string
+	mysqlType string
+	kind      umconf.ColumnType
+	key       bool
+}
+
+// collection is a MongoDB collection replicated, and the table it is
+// replicated to
+type collection struct {
+	database string
+	name     string
+	table    string
+	columns  []*column
+	// def is the structure of the table as sent along with the rows, for
+	// the Kafka target
+	def *config.Table
+}
+
+// newCollection returns a collection replicated as its _id and the
+// document as JSON, or as mapped if m is not nil
+func newCollection(database, name string, m *CollectionMapping) (*collection, error) {
+	if m != nil {
+		return newMappedCollection(database, name, m)
+	}
+	c := &collection{
+		database: database,
+		name:     name,
+		table:    name,
+		columns: []*column{
+			{name: idColumn, path: "_id", mysqlType: "varchar(255)", kind: umconf.VarcharColumnType, key: true},
+			{name: documentColumn, mysqlType: "json", kind: umconf.JSONColumnType},
+		},
+	}
+	c.def = c.tableDef()
+	return c, nil
+}
+
+// newMappedCollection returns a collection flattened by a mapping. Its key
+// is the column of _id, added as varchar(255) if not mapped.
+func newMappedCollection(database, name string, m *CollectionMapping) (*collection, error) {
+	if len(m.Fields) == 0 {
+		return nil, fmt.Errorf("the mapping of %s.%s has no fields", database, name)
+	}
+	c := &collection{
+		database: database,
+		name:     name,
+		table:    m.Table,
+	}
+	if c.table == "" {
+		c.table = name
+	}
+	names := make(map[string]bool)
+	hasKey := false
+	for _, f := range m.Fields {
+		if f.Path == "" {
+			return nil, fmt.Errorf("a field of the mapping of %s.%s has no path", database, name)
+		}
+		col := &column{name: f.Column, path: f.Path, key: f.Path == "_id"}
+		if col.name == "" {
+			col.name = strings.Replace(f.Path, ".", "_", -1)
+		}
+		if names[strings.ToLower(col.name)] {
+			return nil, fmt.Errorf("column %s of the mapping of %s.%s is mapped twice", col.name, database, name)
+		}
+		names[strings.ToLower(col.name)] = true
+		mysqlType := f.Type
+		if mysqlType == "" {
+			mysqlType = "longtext"
+		}
+		var err error
+		if col.mysqlType, col.kind, err = parseColumnType(mysqlType); err != nil {
+			return nil, fmt.Errorf("field %s of the mapping of %s.%s: %v", f.Path, database, name, err)
+		}
+		hasKey = hasKey || col.key
+		c.columns = append(c.columns, col)
+	}
+	if !hasKey {
+		if names[idColumn] {
+			return nil, fmt.Errorf("column %s of the mapping of %s.%s is not the _id", idColumn, database, name)
+		}
+		key := &column{name: idColumn, path: "_id", mysqlType: "varchar(255)", kind: umconf.VarcharColumnType, key: true}
+		c.columns = append([]*column{key}, c.columns...)
+	}
+	c.def = c.tableDef()
+	return c, nil
+}
+
+var columnTypeRegexp = regexp.MustCompile(`^([a-z]+)(?:\(\s*(\d+)\s*(?:,\s*(\d+)\s*)?\))?$`)
+
+// parseColumnType checks the MySQL type of a mapped column, and returns it
+// as created on the target
+func parseColumnType(s string) (string, umconf.ColumnType, error) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	m := columnTypeRegexp.FindStringSubmatch(s)
+	if m == nil {
+		return "", umconf.UnknownColumnType, fmt.Errorf("unsupported column type %q", s)
+	}
+	name, length, scale := m[1], m[2], m[3]
+	noArgs := length == ""
+	switch {
+	case (name == "bool" || name == "boolean") && noArgs:
+		return "tinyint(1)", umconf.TinyintColumnType, nil
+	case name == "tinyint" && scale == "":
+		return s, umconf.TinyintColumnType, nil
+	case name == "smallint" && scale == "":
+		return s, umconf.SmallintColumnType, nil
+	case (name == "int" || name == "integer") && scale == "":
+		return s, umconf.IntColumnType, nil
+	case name == "bigint" && scale == "":
+		return s, umconf.BigIntColumnType, nil
+	case name == "decimal" || name == "numeric":
+		if noArgs {
+			return "decimal(10,0)", umconf.DecimalColumnType, nil
+		}
+		if scale == "" {
+			scale = "0"
+		}
+		return fmt.Sprintf("decimal(%s,%s)", length, scale), umconf.DecimalColumnType, nil
+	case name == "float" && noArgs:
+		return s, umconf.FloatColumnType, nil
+	case name == "double" && noArgs:
+		return s, umconf.DoubleColumnType, nil
+	case name == "char" && scale == "":
+		return s, umconf.CharColumnType, nil
+	case name == "varchar" && !noArgs && scale == "":
+		return s, umconf.VarcharColumnType, nil
+	case (name == "text" || name == "mediumtext" || name == "longtext") && noArgs:
+		return s, umconf.TextColumnType, nil
+	case name == "json" && noArgs:
+		return s, umconf.JSONColumnType, nil
+	case name == "date" && noArgs:
+		return s, umconf.DateColumnType, nil
+	case name == "datetime" && scale == "":
+		return s, umconf.DateTimeColumnType, nil
+	}
+	return "", umconf.UnknownColumnType, fmt.Errorf("unsupported column type %q", s)
+}
+
+// rowValues returns the values of the columns of a document, as those of a
+// binlog event
+func (c *collection) rowValues(document json.RawMessage) ([]interface{}, error) {
+	values := make([]interface{}, len(c.columns))
+	for i, col := range c.columns {
+		raw := document
+		if col.path != "" {
+			var err error
+			if raw, err = lookup(document, col.path); err != nil {
+				return nil, fmt.Errorf("field %s of %s.%s: %v", col.path, c.database, c.name, err)
+			}
+		}
+		value, err := col.eventValue(raw)
+		if err != nil {
+			return nil, fmt.Errorf("field %s of %s.%s: %v", col.path, c.database, c.name, err)
+		}
+		values[i] = value
+	}
+	return values, nil
+}
+
+// keyValues returns the values of the key of a document, from the
+// documentKey of a change event, the other columns being NULL
+func (c *collection) keyValues(documentKey json.RawMessage) ([]interface{}, error) {
+	values := make([]interface{}, len(c.columns))
+	for i, col := range c.columns {
+		if !col.key {
+			continue
+		}
+		raw, err := lookup(documentKey, col.path)
+		if err != nil {
+			return nil, fmt.Errorf("key of %s.%s: %v", c.database, c.name, err)
+		}
+		if values[i], err = col.eventValue(raw); err != nil {
+			return nil, fmt.Errorf("key of %s.%s: %v", c.database, c.name, err)
+		}
+	}
+	return values, nil
+}
+
+// lookup returns a field of a document, nil if missing
+func lookup(document json.RawMessage, path string) (json.RawMessage, error) {
+	raw := document
+	for _, name := range strings.Split(path, ".") {
+		trimmed := bytes.TrimSpace(raw)
+		switch {
+		case len(trimmed) > 0 && trimmed[0] == '{':
+			var fields map[string]json.RawMessage
+			if err := json.Unmarshal(trimmed, &fields); err != nil {
+				return nil, err
+			}
+			raw = fields[name]
+		case len(trimmed) > 0 && trimmed[0] == '[':
+			index, err := strconv.Atoi(name)
+			if err != nil {
+				return nil, nil
+			}
+			var items []json.RawMessage
+			if err := json.Unmarshal(trimmed, &items); err != nil {
+				return nil, err
+			}
+			if index < 0 || index >= len(items) {
+				return nil, nil
+			}
+			raw = items[index]
+		default:
+			return nil, nil
+		}
+	}
+	return raw, nil
+}
+
+// scalar decodes a value of relaxed extended JSON: a string, json.Number,
+// bool, time.Time, or nil. The other values, documents, arrays and the
+// BSON types without their JSON counterpart, are returned as is.
+func scalar(raw json.RawMessage) (interface{}, error) {
+	raw = bytes.TrimSpace(raw)
+	if len(raw) == 0 {
+		return nil, nil
+	}
+	switch raw[0] {
+	case '[':
+		return raw, nil
+	case '{':
+		var wrapper map[string]json.RawMessage
+		if err := json.Unmarshal(raw, &wrapper); err != nil {
+			return nil, err
+		}
+		if len(wrapper) != 1 {
+			return raw, nil
+		}
+		for key, value := range wrapper {
+			switch key {
+			case "$oid", "$symbol":
+				var s string
+				err := json.Unmarshal(value, &s)
+				return s, err
+			case "$numberDecimal", "$numberLong", "$numberInt", "$numberDouble":
+				var s string
+				err := json.Unmarshal(value, &s)
+				return json.Number(s), err
+			case "$date":
+				return dateValue(value)
+			}
+		}
+		return raw, nil
+	}
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+	decoder.UseNumber()
+	var v interface{}
+	if err := decoder.Decode(&v); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// dateValue decodes the value of a $date, in ISO-8601 or else in
+// milliseconds
+func dateValue(raw json.RawMessage) (time.Time, error) {
+	var s string
+	if err := json.Unmarshal(raw, &s); err == nil {
+		return time.Parse(time.RFC3339Nano, s)
+	}
+	var millis struct {
+		NumberLong string `json:"$numberLong"`
+	}
+	if err := json.Unmarshal(raw, &millis); err != nil {
+		return time.Time{}, fmt.Errorf("invalid date %s", raw)
+	}
+	ms, err := strconv.ParseInt(millis.NumberLong, 10, 64)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid date %s", raw)
+	}
+	return time.Unix(0, 0).Add(time.Duration(ms) * time.Millisecond).UTC(), nil
+}
+
+// eventValue converts a field of a document to the value of a binlog event
+// of the MySQL type of the column
+func (col *column) eventValue(raw json.RawMessage) (interface{}, error) {
+	if col.kind == umconf.JSONColumnType {
+		raw = bytes.TrimSpace(raw)
+		if len(raw) == 0 || string(raw) == "null" {
+			return nil, nil
+		}
+		var compact bytes.Buffer
+		if err := json.Compact(&compact, raw); err != nil {
+			return nil, err
+		}
+		return compact.String(), nil
+	}
+
+	v, err := scalar(raw)
+	if err != nil || v == nil {
+		return nil, err
+	}
+	switch col.kind {
+	case umconf.TinyintColumnType, umconf.SmallintColumnType, umconf.IntColumnType, umconf.BigIntColumnType:
+		switch v := v.(type) {
+		case bool:
+			if v {
+				return int64(1), nil
+			}
+			return int64(0), nil
+		case json.Number:
+			if i, err := v.Int64(); err == nil {
+				return i, nil
+			}
+			f, err := strconv.ParseFloat(string(v), 64)
+			if err != nil || f != float64(int64(f)) {
+				return nil, fmt.Errorf("%s is not an integer", v)
+			}
+			return int64(f), nil
+		case string:
+			return strconv.ParseInt(v, 10, 64)
+		}
+	case umconf.FloatColumnType, umconf.DoubleColumnType:
+		var f float64
+		switch v := v.(type) {
+		case json.Number:
+			f, err = strconv.ParseFloat(string(v), 64)
+		case string:
+			f, err = strconv.ParseFloat(v, 64)
+		default:
+			return nil, fmt.Errorf("%v is not a number", v)
+		}
+		if err != nil {
+			return nil, err
+		}
+		if col.kind == umconf.FloatColumnType {
+			return float32(f), nil
+		}
+		return f, nil
+	case umconf.DecimalColumnType:
+		switch v := v.(type) {
+		case json.Number:
+			return string(v), nil
+		case string:
+			if _, err := strconv.ParseFloat(v, 64); err != nil {
+				return nil, fmt.Errorf("%q is not a number", v)
+			}
+			return v, nil
+		case bool:
+			if v {
+				return "1", nil
+			}
+			return "0", nil
+		}
+	case umconf.DateColumnType, umconf.DateTimeColumnType:
+		switch v := v.(type) {
+		case time.Time:
+			if col.kind == umconf.DateColumnType {
+				return v.UTC().Format("2006-01-02"), nil
+			}
+			return v.UTC().Format("2006-01-02 15:04:05.999999"), nil
+		case string:
+			return v, nil
+		}
+	default:
+		// Text
+		switch v := v.(type) {
+		case string:
+			return v, nil
+		case json.Number:
+			return string(v), nil
+		case bool:
+			return strconv.FormatBool(v), nil
+		case time.Time:
+			return v.UTC().Format(time.RFC3339Nano), nil
+		case json.RawMessage:
+			var compact bytes.Buffer
+			if err := json.Compact(&compact, v); err != nil {
+				return nil, err
+			}
+			return compact.String(), nil
+		}
+	}
+	return nil, fmt.Errorf("%s can not be written as %s", raw, col.mysqlType)
+}
+
+// dumpValue converts a field of a document to the text the snapshot sends
+// it as
+func (col *column) dumpValue(raw json.RawMessage) ([]byte, error) {
+	value, err := col.eventValue(raw)
+	switch value := value.(type) {
+	case nil:
+		return nil, err
+	case string:
+		return []byte(value), err
+	case int64:
+		return []byte(strconv.FormatInt(value, 10)), err
+	case float32:
+		return []byte(strconv.FormatFloat(float64(value), 'g', -1, 32)), err
+	case float64:
+		return []byte(strconv.FormatFloat(value, 'g', -1, 64)), err
+	default:
+		return []byte(fmt.Sprint(value)), err
+	}
+}
+
+// createTableSQL returns the CREATE TABLE of the table on the target
+func (c *collection) createTableSQL() string {
+	var definitions, keys []string
+	for _, col := range c.columns {
+		definition := fmt.Sprintf("  %s %s", usql.EscapeName(col.name), col.mysqlType)
+		if col.key {
+			definition += " NOT NULL"
+			keys = append(keys, usql.EscapeName(col.name))
+		}
+		definitions = append(definitions, definition)
+	}
+	definitions = append(definitions, fmt.Sprintf("  PRIMARY KEY (%s)", strings.Join(keys, ",")))
+	return fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (\n%s\n)", usql.EscapeName(c.table), strings.Join(definitions, ",\n"))
+}
+
+// tableDef returns the structure of the table as read from a MySQL source
+func (c *collection) tableDef() *config.Table {
+	def := config.NewTable(c.database, c.table)
+	var columns []umconf.Column
+	for _, col := range c.columns {
+		column := umconf.Column{
+			Name:       col.name,
+			Type:       col.kind,
+			ColumnType: col.mysqlType,
+			Nullable:   !col.key,
+		}
+		if col.key {
+			column.Key = "PRI"
+		}
+		if col.kind == umconf.DecimalColumnType {
+			fmt.Sscanf(col.mysqlType, "decimal(%d,%d)", &column.Precision, &column.Scale)
+		}
+		columns = append(columns, column)
+	}
+	def.OriginalTableColumns = umconf.NewColumnList(columns)
+	return def
+}
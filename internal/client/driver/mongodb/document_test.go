@@ -0,0 +1,165 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package mongodb
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	umconf "github.com/actiontech/dtle/internal/config/mysql"
+)
+
+func TestParseColumnType(t *testing.T) {
+	tests := []struct {
+		s        string
+		want     string
+		wantKind umconf.ColumnType
+		wantErr  bool
+	}{
+		{s: "BIGINT", want: "bigint", wantKind: umconf.BigIntColumnType},
+		{s: "boolean", want: "tinyint(1)", wantKind: umconf.TinyintColumnType},
+		{s: "decimal(12, 2)", want: "decimal(12,2)", wantKind: umconf.DecimalColumnType},
+		{s: "numeric", want: "decimal(10,0)", wantKind: umconf.DecimalColumnType},
+		{s: "varchar(64)", want: "varchar(64)", wantKind: umconf.VarcharColumnType},
+		{s: "datetime(3)", want: "datetime(3)", wantKind: umconf.DateTimeColumnType},
+		{s: "json", want: "json", wantKind: umconf.JSONColumnType},
+		{s: "varchar", wantErr: true},
+		{s: "int unsigned", wantErr: true},
+		{s: "geometry", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, gotKind, err := parseColumnType(tt.s)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseColumnType(%q) error = %v, wantErr %v", tt.s, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want || gotKind != tt.wantKind {
+			t.Errorf("parseColumnType(%q) = %v, %v, want %v, %v", tt.s, got, gotKind, tt.want, tt.wantKind)
+		}
+	}
+}
+
+func TestLookup(t *testing.T) {
+	document := json.RawMessage(`{"_id": {"$oid": "5c8f1b2e9d1f4a0001a1b2c3"}, "address": {"city": "Shanghai"},
+		"items": [{"sku": "A-1"}, {"sku": "B-2"}]}`)
+	tests := []struct {
+		path string
+		want string
+	}{
+		{"address.city", `"Shanghai"`},
+		{"items.1.sku", `"B-2"`},
+		{"items.2.sku", ""},
+		{"address.city.name", ""},
+		{"missing", ""},
+	}
+	for _, tt := range tests {
+		got, err := lookup(document, tt.path)
+		if err != nil {
+			t.Errorf("lookup(%q) error = %v", tt.path, err)
+			continue
+		}
+		if string(got) != tt.want {
+			t.Errorf("lookup(%q) = %s, want %s", tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestEventValue(t *testing.T) {
+	newColumn := func(mysqlType string) *column {
+		c := &column{}
+		c.mysqlType, c.kind, _ = parseColumnType(mysqlType)
+		return c
+	}
+	tests := []struct {
+		column  *column
+		raw     string
+		want    interface{}
+		wantErr bool
+	}{
+		{column: newColumn("varchar(255)"), raw: `{"$oid": "5c8f1b2e9d1f4a0001a1b2c3"}`, want: "5c8f1b2e9d1f4a0001a1b2c3"},
+		{column: newColumn("varchar(255)"), raw: `{"a": 1, "b": [true]}`, want: `{"a":1,"b":[true]}`},
+		{column: newColumn("longtext"), raw: `12.5`, want: "12.5"},
+		{column: newColumn("bigint"), raw: `9007199254740993`, want: int64(9007199254740993)},
+		{column: newColumn("int"), raw: `{"$numberLong": "42"}`, want: int64(42)},
+		{column: newColumn("int"), raw: `4.0`, want: int64(4)},
+		{column: newColumn("int"), raw: `4.5`, wantErr: true},
+		{column: newColumn("boolean"), raw: `true`, want: int64(1)},
+		{column: newColumn("decimal(10,2)"), raw: `{"$numberDecimal": "19.90"}`, want: "19.90"},
+		{column: newColumn("double"), raw: `1.5`, want: float64(1.5)},
+		{column: newColumn("float"), raw: `1.5`, want: float32(1.5)},
+		{column: newColumn("datetime(3)"), raw: `{"$date": "2019-03-18T04:05:06.789+08:00"}`, want: "2019-03-17 20:05:06.789"},
+		{column: newColumn("date"), raw: `{"$date": {"$numberLong": "-86400000"}}`, want: "1969-12-31"},
+		{column: newColumn("json"), raw: `{ "tags": [ "a" ] }`, want: `{"tags":["a"]}`},
+		{column: newColumn("json"), raw: `"text"`, want: `"text"`},
+		{column: newColumn("varchar(16)"), raw: `null`, want: nil},
+		{column: newColumn("varchar(16)"), raw: ``, want: nil},
+		{column: newColumn("datetime"), raw: `true`, wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := tt.column.eventValue(json.RawMessage(tt.raw))
+		if (err != nil) != tt.wantErr {
+			t.Errorf("eventValue(%s) of %v error = %v, wantErr %v", tt.raw, tt.column.mysqlType, err, tt.wantErr)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("eventValue(%s) of %v = %#v, want %#v", tt.raw, tt.column.mysqlType, got, tt.want)
+		}
+	}
+}
+
+func TestNewMappedCollection(t *testing.T) {
+	m := &CollectionMapping{
+		Database:   "shop",
+		Collection: "orders",
+		Fields: []*FieldMapping{
+			{Path: "customer.name", Type: "varchar(64)"},
+			{Path: "total", Type: "decimal(10,2)"},
+			{Path: "items", Type: "json"},
+		},
+	}
+	c, err := newMappedCollection("shop", "orders", m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, col := range c.columns {
+		names = append(names, col.name)
+	}
+	if want := []string{"_id", "customer_name", "total", "items"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("columns = %v, want %v", names, want)
+	}
+	want := "CREATE TABLE IF NOT EXISTS `orders` (\n" +
+		"  `_id` varchar(255) NOT NULL,\n" +
+		"  `customer_name` varchar(64),\n" +
+		"  `total` decimal(10,2),\n" +
+		"  `items` json,\n" +
+		"  PRIMARY KEY (`_id`)\n)"
+	if got := c.createTableSQL(); got != want {
+		t.Errorf("createTableSQL() = %v, want %v", got, want)
+	}
+
+	values, err := c.rowValues(json.RawMessage(`{"_id": 7, "customer": {"name": "Ann"}, "total": 12.5, "items": [{"sku": "A-1"}]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []interface{}{"7", "Ann", "12.5", `[{"sku":"A-1"}]`}; !reflect.DeepEqual(values, want) {
+		t.Errorf("rowValues() = %#v, want %#v", values, want)
+	}
+	keys, err := c.keyValues(json.RawMessage(`{"_id": 7}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []interface{}{"7", nil, nil, nil}; !reflect.DeepEqual(keys, want) {
+		t.Errorf("keyValues() = %#v, want %#v", keys, want)
+	}
+
+	m.Fields = append(m.Fields, &FieldMapping{Path: "customer_name"})
+	if _, err := newMappedCollection("shop", "orders", m); err == nil {
+		t.Errorf("newMappedCollection() with a column mapped twice, got no error")
+	}
+}
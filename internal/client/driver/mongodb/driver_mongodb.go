@@ -0,0 +1,164 @@
+//go:build mongodb
+// +build mongodb
+
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package mongodb
+
+import (
+	"context"
+	"fmt"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func init() {
+	dial = dialMongo
+}
+
+// mongoClient reads MongoDB with the official driver
+type mongoClient struct {
+	client *mongo.Client
+}
+
+func dialMongo(ctx context.Context, uri string) (client, error) {
+	c, err := mongo.Connect(ctx, options.Client().ApplyURI(uri))
+	if err != nil {
+		return nil, err
+	}
+	if err := c.Ping(ctx, nil); err != nil {
+		c.Disconnect(ctx)
+		return nil, err
+	}
+	return &mongoClient{client: c}, nil
+}
+
+func (c *mongoClient) SourceID(ctx context.Context) (string, error) {
+	var isMaster struct {
+		SetName string `bson:"setName"`
+		Msg     string `bson:"msg"`
+	}
+	if err := c.client.Database("admin").RunCommand(ctx, bson.D{{Key: "isMaster", Value: 1}}).Decode(&isMaster); err != nil {
+		return "", err
+	}
+	if isMaster.SetName != "" {
+		return isMaster.SetName, nil
+	}
+	if isMaster.Msg == "isdbgrid" {
+		var version struct {
+			ClusterID primitive.ObjectID `bson:"clusterId"`
+		}
+		if err := c.client.Database("config").Collection("version").FindOne(ctx, bson.D{}).Decode(&version); err != nil {
+			return "", err
+		}
+		return version.ClusterID.Hex(), nil
+	}
+	return "", fmt.Errorf("change streams need a replica set or a sharded cluster")
+}
+
+func (c *mongoClient) CollectionNames(ctx context.Context, database string) ([]string, error) {
+	return c.client.Database(database).ListCollectionNames(ctx, bson.D{{Key: "type", Value: "collection"}})
+}
+
+func (c *mongoClient) OperationTime(ctx context.Context) (timestamp, error) {
+	session, err := c.client.StartSession()
+	if err != nil {
+		return timestamp{}, err
+	}
+	defer session.EndSession(ctx)
+	err = mongo.WithSession(ctx, session, func(sc mongo.SessionContext) error {
+		return c.client.Database("admin").RunCommand(sc, bson.D{{Key: "ping", Value: 1}}).Err()
+	})
+	if err != nil {
+		return timestamp{}, err
+	}
+	t := session.OperationTime()
+	if t == nil {
+		return timestamp{}, fmt.Errorf("no operation time: a replica set or a sharded cluster is needed")
+	}
+	return timestamp{T: t.T, I: t.I}, nil
+}
+
+func (c *mongoClient) EstimatedCount(ctx context.Context, database, collection string) (int64, error) {
+	return c.client.Database(database).Collection(collection).EstimatedDocumentCount(ctx)
+}
+
+func (c *mongoClient) Find(ctx context.Context, database, collection string) (cursor, error) {
+	cur, err := c.client.Database(database).Collection(collection).Find(ctx, bson.D{})
+	if err != nil {
+		return nil, err
+	}
+	return &mongoCursor{cursor: cur}, nil
+}
+
+func (c *mongoClient) Watch(ctx context.Context, databases []string, resumeToken string, from timestamp) (changeStream, error) {
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: bson.D{{Key: "ns.db", Value: bson.D{{Key: "$in", Value: databases}}}}}},
+	}
+	opts := options.ChangeStream().SetFullDocument(options.UpdateLookup)
+	if resumeToken != "" {
+		opts.SetResumeAfter(bson.D{{Key: "_data", Value: resumeToken}})
+	} else {
+		opts.SetStartAtOperationTime(&primitive.Timestamp{T: from.T, I: from.I})
+	}
+	cs, err := c.client.Watch(ctx, pipeline, opts)
+	if err != nil {
+		return nil, err
+	}
+	return &mongoChangeStream{stream: cs}, nil
+}
+
+func (c *mongoClient) Close(ctx context.Context) error {
+	return c.client.Disconnect(ctx)
+}
+
+type mongoCursor struct {
+	cursor *mongo.Cursor
+}
+
+func (c *mongoCursor) Next(ctx context.Context) bool {
+	return c.cursor.Next(ctx)
+}
+
+func (c *mongoCursor) Document() ([]byte, error) {
+	return bson.MarshalExtJSON(c.cursor.Current, false, false)
+}
+
+func (c *mongoCursor) Err() error {
+	return c.cursor.Err()
+}
+
+func (c *mongoCursor) Close(ctx context.Context) error {
+	return c.cursor.Close(ctx)
+}
+
+type mongoChangeStream struct {
+	stream *mongo.ChangeStream
+}
+
+func (s *mongoChangeStream) Next(ctx context.Context) bool {
+	return s.stream.Next(ctx)
+}
+
+func (s *mongoChangeStream) TryNext(ctx context.Context) bool {
+	return s.stream.TryNext(ctx)
+}
+
+func (s *mongoChangeStream) Event() ([]byte, error) {
+	return bson.MarshalExtJSON(s.stream.Current, false, false)
+}
+
+func (s *mongoChangeStream) Err() error {
+	return s.stream.Err()
+}
+
+func (s *mongoChangeStream) Close(ctx context.Context) error {
+	return s.stream.Close(ctx)
+}
@@ -0,0 +1,309 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package mongodb
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/satori/go.uuid"
+
+	"github.com/actiontech/dtle/internal/client/driver/hete"
+	"github.com/actiontech/dtle/internal/client/driver/mysql"
+	log "github.com/actiontech/dtle/internal/logger"
+	"github.com/actiontech/dtle/internal/models"
+)
+
+// Extractor reads the collections of MongoDB databases and their changes
+// from a change stream, and sends them to the target task as the MySQL
+// extractor does
+type Extractor struct {
+	logger     *log.Entry
+	subject    string
+	maxPayload int
+	cfg        *MongoDBConfig
+	client     client
+	publisher  *hete.Publisher
+	waitCh     chan *models.WaitResult
+
+	// ctx is canceled on shutdown, ending the reads of MongoDB
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	// sid stands for the replica set or cluster in the GTIDs of the
+	// transactions, whose GNO is made of the cluster time
+	sid uuid.UUID
+	// collections are those replicated, by namespace
+	collections map[string]*collection
+	// collectionSent tells whether the structure of the table of a
+	// collection was sent along with its rows
+	collectionSent map[*collection]bool
+
+	// positionLock guards clusterTime and resumeToken
+	positionLock sync.Mutex
+	// clusterTime is that of the last change read, or of the snapshot
+	clusterTime timestamp
+	// resumeToken is that of the last change read
+	resumeToken string
+
+	stage string
+
+	shutdown     bool
+	shutdownCh   chan struct{}
+	shutdownLock sync.Mutex
+}
+
+func NewExtractor(subject, tp string, maxPayload int, cfg *MongoDBConfig, logger *log.Logger) (*Extractor, error) {
+	cfg = cfg.SetDefault()
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	entry := log.NewEntry(logger).WithFields(log.Fields{
+		"job": subject,
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	e := &Extractor{
+		logger:         entry,
+		subject:        subject,
+		maxPayload:     maxPayload,
+		cfg:            cfg,
+		waitCh:         make(chan *models.WaitResult, 1),
+		ctx:            ctx,
+		cancel:         cancel,
+		collections:    make(map[string]*collection),
+		collectionSent: make(map[*collection]bool),
+		shutdownCh:     make(chan struct{}),
+	}
+	return e, nil
+}
+
+func (e *Extractor) Run() {
+	defer models.RecoverPanic(e.onPanic)
+	e.logger.Printf("mongodb.extractor: Extract changes from %s:%d", e.cfg.ConnectionConfig.Host,
+		e.cfg.ConnectionConfig.Port)
+
+	if err := e.initClient(); err != nil {
+		e.onError(mysql.TaskStateDead, err)
+		return
+	}
+	if err := e.initNatsPubClient(); err != nil {
+		e.onError(mysql.TaskStateDead, err)
+		return
+	}
+	if err := e.loadCollections(); err != nil {
+		e.onError(mysql.TaskStateDead, err)
+		return
+	}
+
+	switch {
+	case e.cfg.ResumeToken != "":
+		e.setPosition(timestamp{}, e.cfg.ResumeToken)
+	case e.cfg.Gtid != "":
+		gno, err := hete.ParseGtid(e.sid, e.cfg.Gtid)
+		if err != nil {
+			e.onError(mysql.TaskStateDead, err)
+			return
+		}
+		e.setPosition(timestampOfGno(gno), "")
+	default:
+		if err := e.snapshot(); err != nil {
+			e.onError(mysql.TaskStateDead, err)
+			return
+		}
+	}
+
+	if err := e.streamChanges(); err != nil {
+		e.onError(mysql.TaskStateDead, err)
+	}
+}
+
+// CheckConnection connects to MongoDB and checks that it has change
+// streams: a replica set or a sharded cluster
+func CheckConnection(c *ConnectionConfig) error {
+	ctx, cancel := context.WithTimeout(context.Background(), mysql.DefaultConnectWait)
+	defer cancel()
+	cl, err := connect(ctx, c)
+	if err != nil {
+		return err
+	}
+	defer cl.Close(ctx)
+	_, err = cl.SourceID(ctx)
+	return err
+}
+
+// initClient connects to MongoDB, and reads the replica set or cluster the
+// GTIDs of the transactions are made of
+func (e *Extractor) initClient() (err error) {
+	if e.client, err = connect(e.ctx, e.cfg.ConnectionConfig); err != nil {
+		return err
+	}
+	source, err := e.client.SourceID(e.ctx)
+	if err != nil {
+		return err
+	}
+	e.sid = uuid.NewV5(uuid.NamespaceOID, fmt.Sprintf("mongodb:%s", source))
+	e.logger.Printf("mongodb.extractor: Source %s, transactions sent as %s", source, e.sid)
+	return nil
+}
+
+func (e *Extractor) initNatsPubClient() (err error) {
+	e.publisher, err = hete.NewPublisher("mongodb.extractor", e.subject, e.maxPayload, e.cfg.NatsAddr, e.shutdownCh, e.logger)
+	return err
+}
+
+// loadCollections lists the collections of ReplicateDoDb, all those of a
+// database listed without collections
+func (e *Extractor) loadCollections() error {
+	for _, ds := range e.cfg.ReplicateDoDb {
+		var names []string
+		for _, t := range ds.Tables {
+			names = append(names, t.TableName)
+		}
+		if len(names) == 0 {
+			var err error
+			if names, err = e.client.CollectionNames(e.ctx, ds.TableSchema); err != nil {
+				return err
+			}
+		}
+		for _, name := range names {
+			if !e.cfg.replicated(ds.TableSchema, name) {
+				continue
+			}
+			if _, err := e.addCollection(ds.TableSchema, name); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// addCollection adds a collection replicated, with its mapping if any
+func (e *Extractor) addCollection(database, name string) (*collection, error) {
+	c, err := newCollection(database, name, e.cfg.mapping(database, name))
+	if err != nil {
+		return nil, err
+	}
+	e.collections[fmt.Sprintf("%s.%s", database, name)] = c
+	e.logger.Printf("mongodb.extractor: Collection %s.%s replicated to table %s", database, name, c.table)
+	return c, nil
+}
+
+// sortedCollections returns the collections replicated by namespace
+func (e *Extractor) sortedCollections() []*collection {
+	var names []string
+	for ns := range e.collections {
+		names = append(names, ns)
+	}
+	sort.Strings(names)
+	var result []*collection
+	for _, ns := range names {
+		result = append(result, e.collections[ns])
+	}
+	return result
+}
+
+// databases returns the databases of ReplicateDoDb
+func (e *Extractor) databases() []string {
+	var result []string
+	for _, ds := range e.cfg.ReplicateDoDb {
+		result = append(result, ds.TableSchema)
+	}
+	return result
+}
+
+func (e *Extractor) getPosition() (timestamp, string) {
+	e.positionLock.Lock()
+	defer e.positionLock.Unlock()
+	return e.clusterTime, e.resumeToken
+}
+
+func (e *Extractor) setPosition(clusterTime timestamp, resumeToken string) {
+	e.positionLock.Lock()
+	defer e.positionLock.Unlock()
+	e.clusterTime, e.resumeToken = clusterTime, resumeToken
+}
+
+// gtid returns the GTID set of the transactions sent up to a cluster time
+func (e *Extractor) gtid(t timestamp) string {
+	if t.isZero() {
+		return ""
+	}
+	return hete.Gtid(e.sid, t.gno())
+}
+
+func (e *Extractor) Stats() (*models.TaskStatistics, error) {
+	clusterTime, resumeToken := e.getPosition()
+	coordinates := &models.CurrentCoordinates{
+		// File is the resume token the changes are read after
+		File:    resumeToken,
+		GtidSet: e.gtid(clusterTime),
+	}
+	return e.publisher.Statistics(e.stage, coordinates), nil
+}
+
+// ID returns the config to restart with, as config.DriverCtx is read back
+func (e *Extractor) ID() string {
+	cfg := *e.cfg
+	if clusterTime, _ := e.getPosition(); !clusterTime.isZero() {
+		cfg.ResumeToken = ""
+		cfg.Gtid = e.gtid(clusterTime)
+	}
+	id := struct {
+		DriverConfig *MongoDBConfig
+	}{&cfg}
+	data, err := json.Marshal(id)
+	if err != nil {
+		e.logger.Errorf("mongodb.extractor: Failed to marshal ID to JSON: %s", err)
+	}
+	return string(data)
+}
+
+func (e *Extractor) onPanic(err *models.PanicError) {
+	e.onError(mysql.TaskStateRestart, err)
+}
+
+func (e *Extractor) onError(state int, err error) {
+	e.logger.Errorf("mongodb.extractor. error: %v", err.Error())
+	if e.shutdown {
+		return
+	}
+	e.waitCh <- models.NewWaitResult(state, err)
+	e.Shutdown()
+}
+
+func (e *Extractor) WaitCh() chan *models.WaitResult {
+	return e.waitCh
+}
+
+// Shutdown is used to tear down the extractor
+func (e *Extractor) Shutdown() error {
+	e.shutdownLock.Lock()
+	defer e.shutdownLock.Unlock()
+
+	if e.shutdown {
+		return nil
+	}
+	e.shutdown = true
+	close(e.shutdownCh)
+	e.cancel()
+
+	if e.publisher != nil {
+		e.publisher.Close()
+	}
+	if e.client != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), mysql.DefaultConnectWait)
+		defer cancel()
+		if err := e.client.Close(ctx); err != nil {
+			return err
+		}
+	}
+	e.logger.Printf("mongodb.extractor: Shutting down")
+	return nil
+}
@@ -0,0 +1,92 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package mongodb
+
+import (
+	"fmt"
+
+	"github.com/actiontech/dtle/internal/models"
+)
+
+// snapshot copies the collections, then sets the changes to be read after
+// the cluster time at its start. MongoDB has no snapshot over collections:
+// the changes made during the copy are applied again over it, the
+// documents being written by their _id.
+func (e *Extractor) snapshot() error {
+	e.stage = models.StageSendingData
+	clusterTime, err := e.client.OperationTime(e.ctx)
+	if err != nil {
+		return err
+	}
+	e.logger.Printf("mongodb.extractor: Step 1: copying the collections from cluster time %s", clusterTime)
+
+	collections := e.sortedCollections()
+	for _, c := range collections {
+		if count, err := e.client.EstimatedCount(e.ctx, c.database, c.name); err == nil {
+			e.publisher.AddRowsEstimate(count)
+		}
+	}
+
+	for i, c := range collections {
+		if err := e.publisher.PublishCreateTable(c.database, c.createTableSQL()); err != nil {
+			return err
+		}
+		e.collectionSent[c] = true
+		e.logger.Printf("mongodb.extractor: Step 1: - copying collection %s.%s (%d of %d collections)",
+			c.database, c.name, i+1, len(collections))
+		if err := e.dumpCollection(c); err != nil {
+			return err
+		}
+	}
+
+	if err := e.publisher.PublishDumpComplete(e.gtid(clusterTime)); err != nil {
+		return err
+	}
+	e.setPosition(clusterTime, "")
+	e.logger.Printf("mongodb.extractor: Step 2: the changes are read after cluster time %s", clusterTime)
+	return nil
+}
+
+// dumpCollection sends the documents of a collection, ChunkSize at a time
+func (e *Extractor) dumpCollection(c *collection) error {
+	cur, err := e.client.Find(e.ctx, c.database, c.name)
+	if err != nil {
+		return err
+	}
+	defer cur.Close(e.ctx)
+
+	var names []string
+	for _, col := range c.columns {
+		names = append(names, col.name)
+	}
+	dumper := e.publisher.NewTableDumper(c.database, c.table, names, c.def, e.cfg.ChunkSize)
+	for cur.Next(e.ctx) {
+		document, err := cur.Document()
+		if err != nil {
+			return err
+		}
+		row := make([][]byte, len(c.columns))
+		for i, col := range c.columns {
+			raw := document
+			if col.path != "" {
+				if raw, err = lookup(document, col.path); err != nil {
+					return fmt.Errorf("field %s of %s.%s: %v", col.path, c.database, c.name, err)
+				}
+			}
+			if row[i], err = col.dumpValue(raw); err != nil {
+				return fmt.Errorf("field %s of %s.%s: %v", col.path, c.database, c.name, err)
+			}
+		}
+		if err := dumper.Add(row); err != nil {
+			return err
+		}
+	}
+	if err := cur.Err(); err != nil {
+		return err
+	}
+	return dumper.Flush()
+}
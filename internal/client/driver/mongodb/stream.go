@@ -0,0 +1,222 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package mongodb
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/actiontech/dtle/internal/client/driver/mysql/base"
+	"github.com/actiontech/dtle/internal/client/driver/mysql/binlog"
+	usql "github.com/actiontech/dtle/internal/client/driver/mysql/sql"
+	umconf "github.com/actiontech/dtle/internal/config/mysql"
+	"github.com/actiontech/dtle/internal/models"
+)
+
+// maxEventsPerRead is the number of change events read before they are
+// sent, unless a transaction is larger
+const maxEventsPerRead = 1000
+
+// streamChanges reads the change stream of the databases replicated after
+// the last change sent, and sends the changes by cluster time: the changes
+// of a transaction share the cluster time of its commit
+func (e *Extractor) streamChanges() error {
+	clusterTime, resumeToken := e.getPosition()
+	stream, err := e.client.Watch(e.ctx, e.databases(), resumeToken, clusterTime)
+	if err != nil {
+		return err
+	}
+	defer stream.Close(context.Background())
+
+	var pending []*changeEvent
+	limit := maxEventsPerRead
+	for {
+		if len(pending) == 0 {
+			e.stage = models.StageMasterHasSentAllBinlogToSlave
+			if !stream.Next(e.ctx) {
+				return e.streamError(stream)
+			}
+			event, err := e.readEvent(stream)
+			if err != nil {
+				return err
+			}
+			pending = append(pending, event)
+		}
+		e.stage = models.StageSendingBinlogEventToSlave
+
+		drained := false
+		for !drained && len(pending) < limit {
+			if !stream.TryNext(e.ctx) {
+				if stream.Err() != nil {
+					return e.streamError(stream)
+				}
+				drained = true
+				continue
+			}
+			event, err := e.readEvent(stream)
+			if err != nil {
+				return err
+			}
+			pending = append(pending, event)
+		}
+
+		events := pending
+		pending = nil
+		if !drained {
+			// The changes of the last cluster time may go on in the next
+			// read
+			n := len(events)
+			for n > 0 && events[n-1].ClusterTime == events[len(events)-1].ClusterTime {
+				n--
+			}
+			if n == 0 {
+				// A transaction larger than a read
+				pending = events
+				limit += maxEventsPerRead
+				continue
+			}
+			events, pending = events[:n], append([]*changeEvent(nil), events[n:]...)
+		}
+		limit = maxEventsPerRead
+		if err := e.sendChanges(events); err != nil {
+			return err
+		}
+	}
+}
+
+// streamError returns the error ending a change stream, nil on shutdown
+func (e *Extractor) streamError(stream changeStream) error {
+	if e.ctx.Err() != nil {
+		return nil
+	}
+	if err := stream.Err(); err != nil {
+		return err
+	}
+	return fmt.Errorf("the change stream was closed")
+}
+
+func (e *Extractor) readEvent(stream changeStream) (*changeEvent, error) {
+	data, err := stream.Event()
+	if err != nil {
+		return nil, err
+	}
+	return parseChangeEvent(data)
+}
+
+// sendChanges sends the changes of the collections replicated after the
+// cluster time of the last change sent, a transaction by cluster time
+func (e *Extractor) sendChanges(events []*changeEvent) error {
+	watermark, _ := e.getPosition()
+	var entries []*binlog.BinlogEntry
+	for _, event := range events {
+		if !event.ClusterTime.after(watermark) {
+			// Sent before the restart
+			continue
+		}
+		dataEvents, err := e.dataEvents(event)
+		if err != nil {
+			return err
+		}
+		if len(dataEvents) == 0 {
+			continue
+		}
+		gno := event.ClusterTime.gno()
+		if len(entries) == 0 || entries[len(entries)-1].Coordinates.GNO != gno {
+			entries = append(entries, binlog.NewBinlogEntryAt(base.BinlogCoordinateTx{
+				SID: e.sid,
+				GNO: gno,
+			}))
+		}
+		entry := entries[len(entries)-1]
+		entry.Events = append(entry.Events, dataEvents...)
+	}
+
+	if err := e.publisher.PublishEntries(entries, nil); err != nil {
+		return err
+	}
+	// The changes read not of the collections replicated
+	if last := events[len(events)-1]; last.ClusterTime.after(watermark) {
+		e.setPosition(last.ClusterTime, last.ID.Data)
+	}
+	return nil
+}
+
+// dataEvents returns the binlog events of a change event: none if its
+// collection is not replicated, and the creation of the table before the
+// first change of a collection created after the start of the task
+func (e *Extractor) dataEvents(event *changeEvent) ([]binlog.DataEvent, error) {
+	database, name := event.Ns.Db, event.Ns.Coll
+	switch event.OperationType {
+	case operationInvalidate:
+		return nil, fmt.Errorf("the change stream was invalidated at cluster time %s", event.ClusterTime)
+	case operationDrop, operationRename, operationDropDatabase:
+		if database != "" && (name == "" || e.cfg.replicated(database, name)) {
+			e.logger.Warnf("mongodb.extractor: %s of %s.%s is not replicated", event.OperationType, database, name)
+		}
+		return nil, nil
+	case operationInsert, operationUpdate, operationReplace, operationDelete:
+	default:
+		return nil, nil
+	}
+
+	var result []binlog.DataEvent
+	c, ok := e.collections[fmt.Sprintf("%s.%s", database, name)]
+	if !ok {
+		if !e.cfg.replicated(database, name) {
+			return nil, nil
+		}
+		var err error
+		if c, err = e.addCollection(database, name); err != nil {
+			return nil, err
+		}
+		ddl := binlog.NewQueryEventAffectTable(usql.EscapeName(database), c.createTableSQL(), binlog.NotDML,
+			binlog.SchemaTable{Schema: database, Table: c.table})
+		ddl.Table = c.def
+		e.collectionSent[c] = true
+		result = append(result, ddl)
+	}
+
+	var dataEvent binlog.DataEvent
+	switch event.OperationType {
+	case operationInsert:
+		values, err := c.rowValues(event.FullDocument)
+		if err != nil {
+			return nil, err
+		}
+		dataEvent = binlog.NewDataEvent(database, c.table, binlog.InsertDML, len(c.columns))
+		dataEvent.NewColumnValues = umconf.ToColumnValues(values)
+	case operationUpdate, operationReplace:
+		if event.FullDocument == nil {
+			// Deleted since, the delete follows
+			e.logger.Debugf("mongodb.extractor: %s of a document of %s.%s deleted since", event.OperationType, database, name)
+			return result, nil
+		}
+		keys, err := c.keyValues(event.DocumentKey)
+		if err != nil {
+			return nil, err
+		}
+		values, err := c.rowValues(event.FullDocument)
+		if err != nil {
+			return nil, err
+		}
+		dataEvent = binlog.NewDataEvent(database, c.table, binlog.UpdateDML, len(c.columns))
+		dataEvent.WhereColumnValues = umconf.ToColumnValues(keys)
+		dataEvent.NewColumnValues = umconf.ToColumnValues(values)
+	case operationDelete:
+		keys, err := c.keyValues(event.DocumentKey)
+		if err != nil {
+			return nil, err
+		}
+		dataEvent = binlog.NewDataEvent(database, c.table, binlog.DeleteDML, len(c.columns))
+		dataEvent.WhereColumnValues = umconf.ToColumnValues(keys)
+	}
+	if !e.collectionSent[c] {
+		dataEvent.Table = c.def
+		e.collectionSent[c] = true
+	}
+	return append(result, dataEvent), nil
+}
@@ -0,0 +1,116 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package mongodb
+
+import (
+	"io/ioutil"
+	"testing"
+
+	"github.com/actiontech/dtle/internal/client/driver/mysql/binlog"
+	"github.com/actiontech/dtle/internal/config"
+	log "github.com/actiontech/dtle/internal/logger"
+)
+
+func TestParseChangeEvent(t *testing.T) {
+	event, err := parseChangeEvent([]byte(`{"_id": {"_data": "825C8F1B2E000000012B022C0100296E5A1004"},
+		"operationType": "update", "clusterTime": {"$timestamp": {"t": 1552882478, "i": 1}},
+		"ns": {"db": "shop", "coll": "orders"}, "documentKey": {"_id": 7}, "fullDocument": null,
+		"updateDescription": {"updatedFields": {"total": 12.5}, "removedFields": []}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if event.ID.Data != "825C8F1B2E000000012B022C0100296E5A1004" || event.OperationType != operationUpdate ||
+		event.Ns.Db != "shop" || event.Ns.Coll != "orders" || string(event.DocumentKey) != `{"_id": 7}` {
+		t.Errorf("parseChangeEvent() = %+v", event)
+	}
+	if event.FullDocument != nil {
+		t.Errorf("FullDocument = %s, want nil", event.FullDocument)
+	}
+	if want := (timestamp{T: 1552882478, I: 1}); event.ClusterTime != want {
+		t.Errorf("ClusterTime = %v, want %v", event.ClusterTime, want)
+	}
+	if gno := event.ClusterTime.gno(); timestampOfGno(gno) != event.ClusterTime || gno != 1552882478<<32|1 {
+		t.Errorf("gno() = %v", gno)
+	}
+
+	if _, err := parseChangeEvent([]byte(`{"clusterTime": 1}`)); err == nil {
+		t.Errorf("parseChangeEvent() of an invalid cluster time, got no error")
+	}
+}
+
+func TestDataEvents(t *testing.T) {
+	e := &Extractor{
+		logger: log.NewEntry(log.New(ioutil.Discard, log.InfoLevel)),
+		cfg: &MongoDBConfig{
+			ReplicateDoDb: []*config.DataSource{
+				{TableSchema: "shop"},
+				{TableSchema: "crm", Tables: []*config.Table{{TableName: "contacts"}}},
+			},
+		},
+		collections:    make(map[string]*collection),
+		collectionSent: make(map[*collection]bool),
+	}
+	if _, err := e.addCollection("shop", "orders"); err != nil {
+		t.Fatal(err)
+	}
+	event := func(op, db, coll, key, document string) *changeEvent {
+		data := `{"operationType": "` + op + `", "clusterTime": {"$timestamp": {"t": 1, "i": 1}},
+			"ns": {"db": "` + db + `", "coll": "` + coll + `"}, "documentKey": ` + key
+		if document != "" {
+			data += `, "fullDocument": ` + document
+		}
+		ev, err := parseChangeEvent([]byte(data + "}"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return ev
+	}
+
+	// The first change of a table sends its structure
+	events, err := e.dataEvents(event(operationInsert, "shop", "orders", `{"_id": 1}`, `{"_id": 1, "total": 2}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 1 || events[0].DML != binlog.InsertDML || events[0].Table == nil {
+		t.Fatalf("insert: %+v", events)
+	}
+	if got := *events[0].NewColumnValues.AbstractValues[1]; got != `{"_id":1,"total":2}` {
+		t.Errorf("insert: document = %v", got)
+	}
+
+	events, err = e.dataEvents(event(operationUpdate, "shop", "orders", `{"_id": 1}`, `{"_id": 1, "total": 3}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 1 || events[0].DML != binlog.UpdateDML || events[0].Table != nil {
+		t.Fatalf("update: %+v", events)
+	}
+	if got := *events[0].WhereColumnValues.AbstractValues[0]; got != "1" {
+		t.Errorf("update: key = %v", got)
+	}
+
+	// Deleted since: the delete follows
+	if events, err = e.dataEvents(event(operationUpdate, "shop", "orders", `{"_id": 1}`, "null")); err != nil || len(events) != 0 {
+		t.Errorf("update of a deleted document: %+v, %v", events, err)
+	}
+
+	// A collection created after the start in a database replicated whole
+	events, err = e.dataEvents(event(operationDelete, "shop", "carts", `{"_id": 2}`, ""))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 2 || events[0].DML != binlog.NotDML || events[0].TableName != "carts" || events[1].DML != binlog.DeleteDML {
+		t.Fatalf("delete: %+v", events)
+	}
+
+	if events, err = e.dataEvents(event(operationInsert, "crm", "leads", `{"_id": 3}`, `{"_id": 3}`)); err != nil || len(events) != 0 {
+		t.Errorf("insert of a collection not replicated: %+v, %v", events, err)
+	}
+	if _, err = e.dataEvents(event(operationInvalidate, "", "", `null`, "")); err == nil {
+		t.Errorf("invalidate: got no error")
+	}
+}
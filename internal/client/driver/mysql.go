@@ -7,6 +7,7 @@
 package driver
 
 import (
+	gosql "database/sql"
 	"fmt"
 	"strings"
 
@@ -37,6 +38,9 @@ func (m *MySQLDriver) Validate(task *models.Task) (*models.TaskValidateResponse,
 	if err := mapstructure.WeakDecode(task.Config, &driverConfig); err != nil {
 		return reply, err
 	}
+	if err := driverConfig.ConnectionConfig.Prepare(); err != nil {
+		return reply, err
+	}
 	uri := driverConfig.ConnectionConfig.GetDBUri()
 	db, err := usql.CreateDB(uri)
 	if err != nil {
@@ -55,6 +59,12 @@ func (m *MySQLDriver) Validate(task *models.Task) (*models.TaskValidateResponse,
 	if task.Type == models.TaskTypeSrc {
 		var query string
 
+		managed, err := mysql.DetectManagedMySQL(db, driverConfig.ManagedMySQL)
+		if err != nil {
+			reply.Connection.Success = false
+			reply.Connection.Error = err.Error()
+		}
+
 		// Get max allowed packet size
 		/*query = `select @@global.max_allowed_packet;`
 		var maxAllowedPacket int
@@ -77,7 +87,8 @@ func (m *MySQLDriver) Validate(task *models.Task) (*models.TaskValidateResponse,
 		}
 		if gtidMode != "ON" {
 			reply.GtidMode.Success = false
-			reply.GtidMode.Error = fmt.Sprintf("Must have GTID enabled: %+v", gtidMode)
+			reply.GtidMode.Error = fmt.Sprintf("Must have GTID enabled: %+v%s", gtidMode,
+				mysql.ManagedMySQLHint(managed, "gtid_mode and enforce_gtid_consistency to ON"))
 		} else {
 			rows, err := db.Query("show master status")
 			if err != nil {
@@ -117,9 +128,13 @@ func (m *MySQLDriver) Validate(task *models.Task) (*models.TaskValidateResponse,
 		if !hasBinaryLogs {
 			reply.Binlog.Success = false
 			reply.Binlog.Error = fmt.Sprintf("%s:%d must have binary logs enabled", driverConfig.ConnectionConfig.Host, driverConfig.ConnectionConfig.Port)
+			if managed == config.ManagedMySQLRDS || managed == config.ManagedMySQLAurora {
+				reply.Binlog.Error += ": on Amazon RDS and Aurora, enable automated backups"
+			}
 		} else if driverConfig.RequiresBinlogFormatChange() {
 			reply.Binlog.Success = false
-			reply.Binlog.Error = fmt.Sprintf("You must be using ROW binlog format. I can switch it for you, provided --switch-to-rbr and that %s:%d doesn't have replicas", driverConfig.ConnectionConfig.Host, driverConfig.ConnectionConfig.Port)
+			reply.Binlog.Error = fmt.Sprintf("You must be using ROW binlog format. I can switch it for you, provided --switch-to-rbr and that %s:%d doesn't have replicas%s", driverConfig.ConnectionConfig.Host, driverConfig.ConnectionConfig.Port,
+				mysql.ManagedMySQLHint(managed, "binlog_format to ROW"))
 		} else {
 			reply.Binlog.Success = true
 		}
@@ -169,7 +184,8 @@ func (m *MySQLDriver) Validate(task *models.Task) (*models.TaskValidateResponse,
 			reply.Privileges.Success = true
 		} else {
 			reply.Privileges.Success = false
-			reply.Privileges.Error = fmt.Sprintf("User has insufficient privileges for extractor. Needed: SUPER|REPLICATION CLIENT, REPLICATION SLAVE and ALL on *.*")
+			reply.Privileges.Error = fmt.Sprintf("User has insufficient privileges for extractor. Needed: SUPER|REPLICATION CLIENT, REPLICATION SLAVE and ALL on *.*%s",
+				mysql.ManagedMySQLGrantsHint(managed))
 		}
 	} else {
 		query := `show grants for current_user()`
@@ -222,6 +238,123 @@ func (m *MySQLDriver) Validate(task *models.Task) (*models.TaskValidateResponse,
 	return reply, nil
 }
 
+// EstimateTables returns the estimated size of the tables a source task
+// replicates
+func (m *MySQLDriver) EstimateTables(task *models.Task) ([]*models.TableEstimate, error) {
+	var driverConfig config.MySQLDriverConfig
+	if err := mapstructure.WeakDecode(task.Config, &driverConfig); err != nil {
+		return nil, err
+	}
+	if err := driverConfig.ConnectionConfig.Prepare(); err != nil {
+		return nil, err
+	}
+	db, err := usql.CreateDB(driverConfig.ConnectionConfig.GetDBUri())
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+	return mysql.EstimateTables(db, &driverConfig)
+}
+
+// CheckBinlogRetention tells whether the source of a task still has the
+// binlogs after its checkpoint
+func (m *MySQLDriver) CheckBinlogRetention(task *models.Task) (string, error) {
+	var driverConfig config.MySQLDriverConfig
+	if err := mapstructure.WeakDecode(task.Config, &driverConfig); err != nil {
+		return "", err
+	}
+	if driverConfig.Gtid == "" {
+		return "", nil
+	}
+	if err := driverConfig.ConnectionConfig.Prepare(); err != nil {
+		return "", err
+	}
+	db, err := usql.CreateDB(driverConfig.ConnectionConfig.GetDBUri())
+	if err != nil {
+		return "", err
+	}
+	defer db.Close()
+	return mysql.CheckBinlogRetention(db, driverConfig.Gtid)
+}
+
+// GtidExecuted returns the GTID set executed on the database of a task
+func (m *MySQLDriver) GtidExecuted(task *models.Task) (string, error) {
+	db, err := openTaskDB(task)
+	if err != nil {
+		return "", err
+	}
+	defer db.Close()
+	return mysql.GtidExecuted(db)
+}
+
+// SetSourceReadOnly sets the source of a task read-only
+func (m *MySQLDriver) SetSourceReadOnly(src *models.Task) error {
+	db, err := openTaskDB(src)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	return mysql.SetSourceReadOnly(db)
+}
+
+// TransactionsBehind returns the transactions of a GTID set of the source of
+// a job the target has yet to apply, since the checkpoint of the source task
+func (m *MySQLDriver) TransactionsBehind(jobID string, src, dest *models.Task, gtidSet string) (int64, error) {
+	var srcConfig config.MySQLDriverConfig
+	if err := mapstructure.WeakDecode(src.Config, &srcConfig); err != nil {
+		return 0, err
+	}
+	db, err := openTaskDB(dest)
+	if err != nil {
+		return 0, err
+	}
+	defer db.Close()
+	return mysql.TransactionsBehind(db, jobID, srcConfig.Gtid, gtidSet)
+}
+
+// SkipDDL records on the target of a job a DDL applied manually there
+func (m *MySQLDriver) SkipDDL(jobID string, dest *models.Task, query string) (string, error) {
+	db, err := openTaskDB(dest)
+	if err != nil {
+		return "", err
+	}
+	defer db.Close()
+	return mysql.SkipDDL(db, jobID, query)
+}
+
+// SkippedDDLs returns the DDLs recorded on the target of a job it has yet to
+// skip
+func (m *MySQLDriver) SkippedDDLs(jobID string, dest *models.Task) ([]*models.SkippedDDL, error) {
+	db, err := openTaskDB(dest)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+	return mysql.SkippedDDLs(db, jobID)
+}
+
+// DDLHistory returns the last DDLs a job replicated to its target
+func (m *MySQLDriver) DDLHistory(jobID string, dest *models.Task) ([]*models.DDLRecord, error) {
+	db, err := openTaskDB(dest)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+	return mysql.DDLHistory(db, jobID)
+}
+
+// openTaskDB connects to the database of a task
+func openTaskDB(task *models.Task) (*gosql.DB, error) {
+	var driverConfig config.MySQLDriverConfig
+	if err := mapstructure.WeakDecode(task.Config, &driverConfig); err != nil {
+		return nil, err
+	}
+	if err := driverConfig.ConnectionConfig.Prepare(); err != nil {
+		return nil, err
+	}
+	return usql.CreateDB(driverConfig.ConnectionConfig.GetDBUri())
+}
+
 func (m *MySQLDriver) Start(ctx *ExecContext, task *models.Task) (DriverHandle, error) {
 	var driverConfig config.MySQLDriverConfig
 	if err := mapstructure.WeakDecode(task.Config, &driverConfig); err != nil {
@@ -19,6 +19,7 @@ import (
 
 	//"encoding/base64"
 	"math"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
@@ -49,6 +50,12 @@ import (
 const (
 	cleanupGtidExecutedLimit = 4096
 	pingInterval             = 10 * time.Second
+	// skipTriggersVariable is set to 1 in the sessions of the applier with
+	// SkipTriggers, for triggers to check
+	skipTriggersVariable = "dtle_skip_triggers"
+	// txRetryInitialBackoff is the wait before the first retry of a
+	// transaction, doubled at each next one
+	txRetryInitialBackoff = 100 * time.Millisecond
 )
 const (
 	TaskStateComplete int = iota
@@ -75,36 +82,92 @@ func (q *Int64PriQueue) Pop() interface{} {
 
 type applierTableItem struct {
 	columns  *umconf.ColumnList
-	psInsert []*gosql.Stmt
-	psDelete []*gosql.Stmt
-	psUpdate []*gosql.Stmt
+	psInsert preparedStmts
+	psDelete preparedStmts
+	psUpdate preparedStmts
+	// psHistory insert the changes into the history table of the table
+	psHistory preparedStmts
+	// psConns are the connections the statements of each worker were
+	// prepared on, prepared again once the worker reconnected to the target
+	psConns []*gosql.Conn
+
+	// unindexed is set if no column of the table is indexed, so that its
+	// rows are updated and deleted by full scans
+	unindexed bool
 }
 
 func newApplierTableItem(parallelWorkers int) *applierTableItem {
 	return &applierTableItem{
-		columns:  nil,
-		psInsert: make([]*gosql.Stmt, parallelWorkers),
-		psDelete: make([]*gosql.Stmt, parallelWorkers),
-		psUpdate: make([]*gosql.Stmt, parallelWorkers),
+		columns:   nil,
+		psInsert:  newPreparedStmts(parallelWorkers),
+		psDelete:  newPreparedStmts(parallelWorkers),
+		psUpdate:  newPreparedStmts(parallelWorkers),
+		psHistory: newPreparedStmts(parallelWorkers),
+		psConns:   make([]*gosql.Conn, parallelWorkers),
 	}
 }
 func (ait *applierTableItem) Reset() {
-	// TODO handle err of `.Close()`?
-	closeStmts := func(stmts []*gosql.Stmt) {
-		for i := range stmts {
-			if stmts[i] != nil {
-				stmts[i].Close()
-				stmts[i] = nil
-			}
-		}
-	}
-	closeStmts(ait.psInsert)
-	closeStmts(ait.psDelete)
-	closeStmts(ait.psUpdate)
+	ait.psInsert.close()
+	ait.psDelete.close()
+	ait.psUpdate.close()
+	ait.psHistory.close()
 
 	ait.columns = nil
 }
 
+// preparedStmts are the statements of a kind prepared by each worker, with
+// the queries they were prepared from. The query of a kind varies between
+// rows, e.g. with the NULLs in the key of a delete or the JSON patches of an
+// update, so a statement is only reused for the same query.
+type preparedStmts struct {
+	stmts   []*gosql.Stmt
+	queries []string
+}
+
+func newPreparedStmts(parallelWorkers int) preparedStmts {
+	return preparedStmts{
+		stmts:   make([]*gosql.Stmt, parallelWorkers),
+		queries: make([]string, parallelWorkers),
+	}
+}
+
+// prepare returns the statement of the query on the connection of the
+// worker, prepared again if its last statement was of another query
+func (ps *preparedStmts) prepare(conn *gosql.Conn, workerIdx int, query string) (*gosql.Stmt, error) {
+	if ps.stmts[workerIdx] != nil && ps.queries[workerIdx] == query {
+		return ps.stmts[workerIdx], nil
+	}
+	ps.closeWorker(workerIdx)
+	stmt, err := conn.PrepareContext(context.Background(), query)
+	if err != nil {
+		return nil, err
+	}
+	ps.stmts[workerIdx] = stmt
+	ps.queries[workerIdx] = query
+	return stmt, nil
+}
+
+// forget drops the statement of the worker, without closing it as it was
+// closed with its connection
+func (ps *preparedStmts) forget(workerIdx int) {
+	ps.stmts[workerIdx] = nil
+	ps.queries[workerIdx] = ""
+}
+
+func (ps *preparedStmts) closeWorker(workerIdx int) {
+	if ps.stmts[workerIdx] != nil {
+		// TODO handle err of `.Close()`?
+		ps.stmts[workerIdx].Close()
+	}
+	ps.forget(workerIdx)
+}
+
+func (ps *preparedStmts) close() {
+	for i := range ps.stmts {
+		ps.closeWorker(i)
+	}
+}
+
 type mapSchemaTableItems map[string](map[string](*applierTableItem))
 
 // Applier connects and writes the the applier-server, which is the server where
@@ -119,6 +182,8 @@ type MtsManager struct {
 	// SeqNum executed but not added to LC
 	m          Int64PriQueue
 	chExecuted chan int64
+	// onPanic is called if LcUpdater panics, by the applier
+	onPanic func(err *models.PanicError)
 }
 
 //  shutdownCh: close to indicate a shutdown
@@ -170,6 +235,7 @@ func (mm *MtsManager) WaitForExecution(binlogEntry *binlog.BinlogEntry) bool {
 }
 
 func (mm *MtsManager) LcUpdater() {
+	defer models.RecoverPanic(mm.onPanic)
 	for {
 		select {
 		case seqNum := <-mm.chExecuted:
@@ -226,6 +292,16 @@ type Applier struct {
 	// only TX can be executed should be put into this chan
 	applyBinlogMtsTxQueue chan *binlog.BinlogEntry
 	lastAppliedBinlogTx   *binlog.BinlogTx
+	heartbeat             *binlog.HeartbeatMonitor
+
+	// tableStats counts the rows applied to each table
+	tableStats *tableApplyStats
+	// schemaMappings are the changes made to the tables created
+	schemaMappings *schemaMappings
+	// ddlRewriter adapts the DDLs to the version of the target
+	ddlRewriter *ddlRewriter
+	// tidb is set if the target is TiDB
+	tidb bool
 
 	natsConn *gonats.Conn
 	waitCh   chan *models.WaitResult
@@ -241,10 +317,53 @@ type Applier struct {
 	nDumpEntry     int64
 
 	stubFullApplyDelay bool
+	// faults are those of FaultInjection, if allowed on the node
+	faults *config.FaultInjector
+	// errorClasses classify the errors of the target, those of ErrorClasses
+	// overridden
+	errorClasses *sql.ErrorClassifier
+
+	// loadDataFailed is set once LOAD DATA failed, the rest of the snapshot
+	// being inserted
+	loadDataFailed int32
+
+	// dryRun is set if the statements are written to the DryRunFile rather
+	// than executed
+	dryRun *dryRunWriter
+	// slowApplies are the statements slower than the SlowApplyThreshold
+	slowApplies *slowApplyLog
+	// txGroup coalesces the transactions applied if TxGroup is set
+	txGroup *txGroup
+	// latency scales the batching to the LatencyTargetMs, nil without one
+	latency *latencyController
+	// schemaHistory is the versions of the tables applied, reported with
+	// the Gtid
+	schemaHistory *schemaHistory
+	// snapshotRowCounts are the rows of each table read by the snapshot of
+	// the source, reconciled once the snapshot is loaded
+	snapshotRowCounts []*models.TableRowCount
+	reconciliation    *rowCountReconciliation
+
+	// subs are the subscriptions to the data of the source, stopped by
+	// Drain, guarded by subsMu
+	subs   []*gonats.Subscription
+	subsMu sync.Mutex
+	// handleMu is held while the entries of a message are enqueued
+	handleMu sync.Mutex
+	// drainCh asks heterogeneousReplay to close the channel sent once the
+	// entries enqueued are applied
+	drainCh chan chan struct{}
+	// nApplyingTx is the transactions received for the homogeneous replay
+	// and not applied yet, waited for by Drain
+	nApplyingTx int64
 }
 
 func NewApplier(subject, tp string, cfg *config.MySQLDriverConfig, logger *log.Logger) (*Applier, error) {
 	cfg = cfg.SetDefault()
+	if err := cfg.ConnectionConfig.Prepare(); err != nil {
+		return nil, err
+	}
+	cfg.ConnectionConfig.CountTraffic()
 	entry := log.NewEntry(logger).WithFields(log.Fields{
 		"job": subject,
 	})
@@ -261,24 +380,43 @@ func NewApplier(subject, tp string, cfg *config.MySQLDriverConfig, logger *log.L
 		tp:                      tp,
 		mysqlContext:            cfg,
 		currentCoordinates:      &models.CurrentCoordinates{},
+		heartbeat:               &binlog.HeartbeatMonitor{},
+		tableStats:              newTableApplyStats(),
+		slowApplies:             &slowApplyLog{},
+		schemaHistory:           &schemaHistory{versions: cfg.SchemaHistory},
+		reconciliation:          &rowCountReconciliation{},
+		schemaMappings:          &schemaMappings{},
 		tableItems:              make(mapSchemaTableItems),
+		latency:                 newLatencyController(cfg.LatencyTargetMs),
 		rowCopyComplete:         make(chan bool, 1),
-		copyRowsQueue:           make(chan *DumpEntry, 24),
-		applyDataEntryQueue:     make(chan *binlog.BinlogEntry, cfg.ReplChanBufferSize*2),
-		applyBinlogMtsTxQueue:   make(chan *binlog.BinlogEntry, cfg.ReplChanBufferSize*2),
-		applyBinlogTxQueue:      make(chan *binlog.BinlogTx, cfg.ReplChanBufferSize*2),
-		applyBinlogGroupTxQueue: make(chan []*binlog.BinlogTx, cfg.ReplChanBufferSize*2),
+		copyRowsQueue:           make(chan *DumpEntry, cfg.QueueSizes.SnapshotChunks),
+		applyDataEntryQueue:     make(chan *binlog.BinlogEntry, cfg.QueueSizes.ApplyEntries),
+		applyBinlogMtsTxQueue:   make(chan *binlog.BinlogEntry, cfg.QueueSizes.ApplyEntries),
+		applyBinlogTxQueue:      make(chan *binlog.BinlogTx, cfg.QueueSizes.ApplyEntries),
+		applyBinlogGroupTxQueue: make(chan []*binlog.BinlogTx, cfg.QueueSizes.ApplyEntries),
 		waitCh:                  make(chan *models.WaitResult, 1),
 		shutdownCh:              make(chan struct{}),
+		drainCh:                 make(chan chan struct{}, 1),
 		printTps:                os.Getenv(g.ENV_PRINT_TPS) != "",
 		stubFullApplyDelay:      os.Getenv(g.ENV_FULL_APPLY_DELAY) != "",
 	}
+	if cfg.TxGroup != nil {
+		a.txGroup = &txGroup{cfg: cfg.TxGroup}
+	}
+	if a.faults, err = newFaultInjector(cfg.FaultInjection, a.logger); err != nil {
+		return nil, err
+	}
+	if a.errorClasses, err = sql.NewErrorClassifier(cfg.ErrorClasses); err != nil {
+		return nil, err
+	}
 	a.mtsManager = NewMtsManager(a.shutdownCh)
+	a.mtsManager.onPanic = a.onPanic
 	go a.mtsManager.LcUpdater()
 	return a, nil
 }
 
 func (a *Applier) MtsWorker(workerIndex int) {
+	defer models.RecoverPanic(a.onPanic)
 	keepLoop := true
 
 	for keepLoop {
@@ -291,7 +429,7 @@ func (a *Applier) MtsWorker(workerIndex int) {
 				a.onError(TaskStateDead, err) // TODO coordinate with other goroutine
 				keepLoop = false
 			} else {
-				// do nothing
+				a.mtsManager.Executed(tx)
 			}
 			a.logger.Debugf("mysql.applier: worker: %v. after ApplyBinlogEvent. GNO: %v",
 				workerIndex, tx.Coordinates.GNO)
@@ -310,6 +448,7 @@ func (a *Applier) MtsWorker(workerIndex int) {
 
 // Run executes the complete apply logic.
 func (a *Applier) Run() {
+	defer models.RecoverPanic(a.onPanic)
 	if a.printTps {
 		go func() {
 			for {
@@ -401,8 +540,10 @@ func (a *Applier) onApplyTxStructWithSuper(dbApplier *sql.Conn, binlogTx *binlog
 // This is where the ghost table gets the data. The function fills the data single-threaded.
 // Both event backlog and rowcopy events are polled; the backlog events have precedence.
 func (a *Applier) executeWriteFuncs() {
+	defer models.RecoverPanic(a.onPanic)
 	if a.mysqlContext.Gtid == "" {
 		go func() {
+			defer models.RecoverPanic(a.onPanic)
 			var stopLoop = false
 			for !stopLoop {
 				select {
@@ -436,6 +577,7 @@ func (a *Applier) executeWriteFuncs() {
 			if atomic.LoadInt64(&a.rowCopyCompleteFlag) == 1 && a.mysqlContext.TotalRowsCopied == a.mysqlContext.TotalRowsReplay {
 				a.rowCopyComplete <- true
 				a.logger.Printf("mysql.applier: Rows copy complete.number of rows:%d", a.mysqlContext.TotalRowsReplay)
+				a.reconcileRowCounts(a.currentCoordinates.RetrievedGtidSet, a.snapshotRowCounts)
 				a.mysqlContext.Gtid = a.currentCoordinates.RetrievedGtidSet
 				break
 			}
@@ -460,7 +602,14 @@ func (a *Applier) executeWriteFuncs() {
 			}
 			for idx, binlogTx := range groupTx {
 				dbApplier = a.dbs[idx%a.mysqlContext.ParallelWorkers]
+				if a.mysqlContext.StrictOrder {
+					if err := a.onApplyTxStructWithSuper(dbApplier, binlogTx); err != nil {
+						a.onError(TaskStateDead, err)
+					}
+					continue
+				}
 				go func(tx *binlog.BinlogTx) {
+					defer models.RecoverPanic(a.onPanic)
 					a.wg.Add(1)
 					if err := a.onApplyTxStructWithSuper(dbApplier, tx); err != nil {
 						a.onError(TaskStateDead, err)
@@ -474,6 +623,7 @@ func (a *Applier) executeWriteFuncs() {
 				a.lastAppliedBinlogTx = groupTx[len(groupTx)-1]
 				a.mysqlContext.Gtid = fmt.Sprintf("%s:1-%d", a.lastAppliedBinlogTx.SID, a.lastAppliedBinlogTx.GNO)
 			}
+			atomic.AddInt64(&a.nApplyingTx, -int64(len(groupTx)))
 		case <-time.After(1 * time.Second):
 			// do nothing
 		}
@@ -482,7 +632,7 @@ func (a *Applier) executeWriteFuncs() {
 
 func (a *Applier) initNatSubClient() (err error) {
 	natsAddr := fmt.Sprintf("nats://%s", a.mysqlContext.NatsAddr)
-	sc, err := gonats.Connect(natsAddr)
+	sc, err := natsConnect(natsAddr, a.mysqlContext)
 	if err != nil {
 		a.logger.Errorf("mysql.applier: Can't connect nats server %v. make sure a nats streaming server is running.%v", natsAddr, err)
 		return err
@@ -512,11 +662,33 @@ func (a *Applier) setTableItemForBinlogEntry(binlogEntry *binlog.BinlogEntry) er
 		default:
 			tableItem := a.getTableItem(dmlEvent.DatabaseName, dmlEvent.TableName)
 			if tableItem.columns == nil {
-				a.logger.Debugf("mysql.applier: get tableColumns %v.%v", dmlEvent.DatabaseName, dmlEvent.TableName)
-				tableItem.columns, err = base.GetTableColumns(a.db, dmlEvent.DatabaseName, dmlEvent.TableName)
-				if err != nil {
-					a.logger.Errorf("mysql.applier. GetTableColumns error. err: %v", err)
-					return err
+				if a.dryRun != nil && dmlEvent.Table != nil && dmlEvent.Table.OriginalTableColumns != nil {
+					// The tables a dry run creates are not on the target
+					tableItem.columns = dmlEvent.Table.OriginalTableColumns
+				} else {
+					a.logger.Debugf("mysql.applier: get tableColumns %v.%v", dmlEvent.DatabaseName, dmlEvent.TableName)
+					tableItem.columns, err = base.GetTableColumns(a.db, dmlEvent.DatabaseName, dmlEvent.TableName)
+					if err != nil {
+						a.logger.Errorf("mysql.applier. GetTableColumns error. err: %v", err)
+						return err
+					}
+				}
+				tableItem.unindexed = true
+				// TIMESTAMP values are read in UTC on the source
+				for _, column := range tableItem.columns.ColumnList() {
+					if strings.HasPrefix(column.ColumnType, "timestamp") {
+						tableItem.columns.SetConvertDatetimeToTimestamp(column.Name, "+00:00")
+					}
+					if column.Key != "" {
+						tableItem.unindexed = false
+					}
+				}
+				if a.dryRun == nil && a.mysqlContext.History.Applies(dmlEvent.DatabaseName, dmlEvent.TableName) {
+					err = a.ensureHistoryTable(dmlEvent.DatabaseName, dmlEvent.TableName, tableItem.columns)
+					if err != nil {
+						a.logger.Errorf("mysql.applier. ensureHistoryTable error. err: %v", err)
+						return err
+					}
 				}
 			} else {
 				a.logger.Debugf("mysql.applier: reuse tableColumns %v.%v", dmlEvent.DatabaseName, dmlEvent.TableName)
@@ -565,10 +737,26 @@ func (a *Applier) cleanGtidExecuted(sid uuid.UUID, intervalStr string) error {
 }
 
 func (a *Applier) heterogeneousReplay() {
+	defer models.RecoverPanic(a.onPanic)
 	var err error
 	stopSomeLoop := false
 	prevDDL := false
+	// draining is closed once the entries enqueued are applied
+	var draining chan struct{}
 	for !stopSomeLoop {
+		if draining != nil && len(a.applyDataEntryQueue) == 0 {
+			if a.txGroup != nil {
+				if err := a.flushTxGroup(); err != nil {
+					a.onError(TaskStateDead, err)
+					return
+				}
+			}
+			if !a.mtsManager.WaitForAllCommitted() {
+				return // shutdown
+			}
+			close(draining)
+			draining = nil
+		}
 		select {
 		case binlogEntry := <-a.applyDataEntryQueue:
 			if nil == binlogEntry {
@@ -601,7 +789,8 @@ func (a *Applier) heterogeneousReplay() {
 				gtidSetItem = &base.GtidExecutedItem{}
 				a.gtidExecuted[binlogEntry.Coordinates.SID] = gtidSetItem
 			}
-			if base.IntervalSlicesContainOne(gtidSetItem.Intervals, binlogEntry.Coordinates.GNO) {
+			if base.IntervalSlicesContainOne(gtidSetItem.Intervals, binlogEntry.Coordinates.GNO) ||
+				a.txGroup.has(binlogEntry) {
 				// entry executed
 				a.logger.Debugf("mysql.applier: skip an executed tx: %v:%v", txSid, binlogEntry.Coordinates.GNO)
 				continue
@@ -618,7 +807,7 @@ func (a *Applier) heterogeneousReplay() {
 			}
 
 			a.logger.Debugf("mysql.applier. gtidSetItem.NRow: %v", gtidSetItem.NRow)
-			if gtidSetItem.NRow >= cleanupGtidExecutedLimit {
+			if a.dryRun == nil && gtidSetItem.NRow >= cleanupGtidExecutedLimit {
 				err = a.cleanGtidExecuted(binlogEntry.Coordinates.SID, base.StringInterval(gtidSetItem.Intervals))
 				if err != nil {
 					a.onError(TaskStateDead, err)
@@ -627,15 +816,16 @@ func (a *Applier) heterogeneousReplay() {
 				gtidSetItem.NRow = 1
 			}
 
-			thisInterval := gomysql.Interval{Start: binlogEntry.Coordinates.GNO, Stop: binlogEntry.Coordinates.GNO + 1}
-
-			gtidSetItem.NRow += 1
-			// TODO normalize may affect oringinal intervals
-			newInterval := append(gtidSetItem.Intervals, thisInterval).Normalize()
-			// TODO this is assigned before real execution
-			gtidSetItem.Intervals = newInterval
-
-			if binlogEntry.Coordinates.SeqenceNumber == 0 {
+			if a.txGroup != nil {
+				// the groups are applied in the order of the source, and
+				// the transactions recorded as executed once committed
+				if err := a.groupBinlogEntry(binlogEntry); err != nil {
+					a.onError(TaskStateDead, err)
+					return
+				}
+			} else if binlogEntry.Coordinates.SeqenceNumber == 0 {
+				// TODO this is assigned before real execution
+				a.addGtidExecuted(binlogEntry)
 				// MySQL 5.6: non mts
 				err := a.setTableItemForBinlogEntry(binlogEntry)
 				if err != nil {
@@ -647,6 +837,8 @@ func (a *Applier) heterogeneousReplay() {
 					return
 				}
 			} else {
+				// TODO this is assigned before real execution
+				a.addGtidExecuted(binlogEntry)
 				if rotated {
 					a.logger.Debugf("mysql.applier: binlog rotated to %v", a.currentCoordinates.File)
 					if !a.mtsManager.WaitForAllCommitted() {
@@ -665,17 +857,7 @@ func (a *Applier) heterogeneousReplay() {
 					a.mtsManager.chExecuted <- a.mtsManager.lastEnqueue
 				}
 
-				hasDDL := func() bool {
-					for i := range binlogEntry.Events {
-						dmlEvent := &binlogEntry.Events[i]
-						switch dmlEvent.DML {
-						case binlog.NotDML:
-							return true
-						default:
-						}
-					}
-					return false
-				}()
+				hasDDL := hasDDL(binlogEntry)
 
 				// DDL must be executed separatedly
 				if hasDDL || prevDDL {
@@ -704,10 +886,16 @@ func (a *Applier) heterogeneousReplay() {
 				}
 				a.applyBinlogMtsTxQueue <- binlogEntry
 			}
-			if !a.shutdown {
+			if a.txGroup == nil && !a.shutdown {
 				// TODO what is this used for?
 				a.mysqlContext.Gtid = fmt.Sprintf("%s:1-%d", txSid, binlogEntry.Coordinates.GNO)
 			}
+		case <-a.txGroup.timeout():
+			if err := a.flushTxGroup(); err != nil {
+				a.onError(TaskStateDead, err)
+				return
+			}
+		case draining = <-a.drainCh:
 		case <-time.After(10 * time.Second):
 			a.logger.Debugf("mysql.applier: no binlogEntry for 10s")
 		case <-a.shutdownCh:
@@ -715,7 +903,22 @@ func (a *Applier) heterogeneousReplay() {
 		}
 	}
 }
+// addGtidExecuted records a source transaction in the gtid_executed
+// intervals the transactions received are checked against
+func (a *Applier) addGtidExecuted(binlogEntry *binlog.BinlogEntry) {
+	gtidSetItem, ok := a.gtidExecuted[binlogEntry.Coordinates.SID]
+	if !ok {
+		gtidSetItem = &base.GtidExecutedItem{}
+		a.gtidExecuted[binlogEntry.Coordinates.SID] = gtidSetItem
+	}
+	thisInterval := gomysql.Interval{Start: binlogEntry.Coordinates.GNO, Stop: binlogEntry.Coordinates.GNO + 1}
+	gtidSetItem.NRow += 1
+	// TODO normalize may affect oringinal intervals
+	gtidSetItem.Intervals = append(gtidSetItem.Intervals, thisInterval).Normalize()
+}
+
 func (a *Applier) homogeneousReplay() {
+	defer models.RecoverPanic(a.onPanic)
 	var lastCommitted int64
 	var err error
 	//timeout := time.After(100 * time.Millisecond)
@@ -724,10 +927,8 @@ OUTER:
 	for {
 		select {
 		case binlogTx := <-a.applyBinlogTxQueue:
-			if nil == binlogTx {
-				continue
-			}
-			if a.mysqlContext.MySQLServerUuid == binlogTx.SID {
+			if nil == binlogTx || a.mysqlContext.MySQLServerUuid == binlogTx.SID {
+				atomic.AddInt64(&a.nApplyingTx, -1)
 				continue
 			}
 			if a.mysqlContext.ParallelWorkers <= 1 {
@@ -740,6 +941,7 @@ OUTER:
 					a.lastAppliedBinlogTx = binlogTx
 					a.mysqlContext.Gtid = fmt.Sprintf("%s:1-%d", a.lastAppliedBinlogTx.SID, a.lastAppliedBinlogTx.GNO)
 				}
+				atomic.AddInt64(&a.nApplyingTx, -1)
 			} else {
 				if binlogTx.LastCommitted == lastCommitted {
 					groupTx = append(groupTx, binlogTx)
@@ -768,7 +970,30 @@ func (a *Applier) initiateStreaming() error {
 	if a.mysqlContext.Gtid == "" {
 		a.mysqlContext.MarkRowCopyStartTime()
 		a.logger.Debugf("mysql.applier: nats subscribe")
-		_, err := a.natsConn.Subscribe(fmt.Sprintf("%s_full", a.subject), func(m *gonats.Msg) {
+		_, err := a.natsConn.Subscribe(fmt.Sprintf("%s_capacity", a.subject), func(m *gonats.Msg) {
+			defer models.RecoverPanic(a.onPanic)
+			req := &CapacityRequest{}
+			if err := Decode(m.Data, req); err != nil {
+				a.onError(TaskStateDead, err)
+				return
+			}
+			msg, err := Encode(&CapacityResult{Error: a.checkCapacity(req)})
+			if err != nil {
+				a.onError(TaskStateDead, err)
+				return
+			}
+			if err := a.natsConn.Publish(m.Reply, msg); err != nil {
+				a.onError(TaskStateDead, err)
+			}
+		})
+		if err != nil {
+			return err
+		}
+
+		sub, err := a.natsConn.Subscribe(fmt.Sprintf("%s_full", a.subject), func(m *gonats.Msg) {
+			defer models.RecoverPanic(a.onPanic)
+			a.handleMu.Lock()
+			defer a.handleMu.Unlock()
 			a.logger.Debugf("mysql.applier: full. recv a msg. copyRowsQueue: %v", len(a.copyRowsQueue))
 
 			dumpData := &DumpEntry{}
@@ -795,16 +1020,24 @@ func (a *Applier) initiateStreaming() error {
 				a.mysqlContext.Stage = models.StageSlaveWaitingForWorkersToProcessQueue
 			}
 		})
+		if err != nil {
+			return err
+		}
+		if err := a.addSub(sub); err != nil {
+			return err
+		}
 		/*if err := sub.SetPendingLimits(a.mysqlContext.MsgsLimit, a.mysqlContext.BytesLimit); err != nil {
 			return err
 		}*/
 
 		_, err = a.natsConn.Subscribe(fmt.Sprintf("%s_full_complete", a.subject), func(m *gonats.Msg) {
+			defer models.RecoverPanic(a.onPanic)
 			dumpData := &dumpStatResult{}
 			if err := Decode(m.Data, dumpData); err != nil {
 				a.onError(TaskStateDead, err)
 			}
 			a.currentCoordinates.RetrievedGtidSet = dumpData.Gtid
+			a.snapshotRowCounts = dumpData.TableRows
 			a.mysqlContext.Stage = models.StageSlaveWaitingForWorkersToProcessQueue
 
 			for atomic.LoadInt64(&a.nDumpEntry) != 0 {
@@ -828,7 +1061,10 @@ func (a *Applier) initiateStreaming() error {
 	}
 
 	if a.mysqlContext.ApproveHeterogeneous {
-		_, err := a.natsConn.Subscribe(fmt.Sprintf("%s_incr_hete", a.subject), func(m *gonats.Msg) {
+		sub, err := a.natsConn.Subscribe(fmt.Sprintf("%s_incr_hete", a.subject), func(m *gonats.Msg) {
+			defer models.RecoverPanic(a.onPanic)
+			a.handleMu.Lock()
+			defer a.handleMu.Unlock()
 			var binlogEntries binlog.BinlogEntries
 			if err := Decode(m.Data, &binlogEntries); err != nil {
 				a.onError(TaskStateDead, err)
@@ -845,14 +1081,19 @@ func (a *Applier) initiateStreaming() error {
 					time.Sleep(1 * time.Second) // It will wait an second at the end, but seems no hurt.
 				} else {
 					a.logger.Debugf("applier. incr. applyDataEntryQueue enqueue")
+					receiveTime := time.Now().UnixNano()
 					for _, binlogEntry := range binlogEntries.Entries {
+						binlogEntry.ReceiveTime = receiveTime
 						a.applyDataEntryQueue <- binlogEntry
 						a.currentCoordinates.RetrievedGtidSet = binlogEntry.Coordinates.GetGtidForThisTx()
 						atomic.AddInt64(&a.mysqlContext.DeltaEstimate, 1)
 					}
+					if binlogEntries.Heartbeat != nil {
+						a.heartbeat.Observe(binlogEntries.Heartbeat)
+					}
 					a.mysqlContext.Stage = models.StageWaitingForMasterToSendEvent
 
-					if err := a.natsConn.Publish(m.Reply, nil); err != nil {
+					if err := a.natsConn.Publish(m.Reply, a.latency.ack()); err != nil {
 						a.onError(TaskStateDead, err)
 					}
 					a.logger.Debugf("applier. incr. ack-recv. nEntries: %v", nEntries)
@@ -869,15 +1110,25 @@ func (a *Applier) initiateStreaming() error {
 		if err != nil {
 			return err
 		}
+		if err := a.addSub(sub); err != nil {
+			return err
+		}
 
 		go a.heterogeneousReplay()
+		if a.latency != nil {
+			go a.adjustBatching()
+		}
 	} else {
-		_, err := a.natsConn.Subscribe(fmt.Sprintf("%s_incr", a.subject), func(m *gonats.Msg) {
+		sub, err := a.natsConn.Subscribe(fmt.Sprintf("%s_incr", a.subject), func(m *gonats.Msg) {
+			defer models.RecoverPanic(a.onPanic)
+			a.handleMu.Lock()
+			defer a.handleMu.Unlock()
 			var binlogTx []*binlog.BinlogTx
 			if err := Decode(m.Data, &binlogTx); err != nil {
 				a.onError(TaskStateDead, err)
 			}
 			for _, tx := range binlogTx {
+				atomic.AddInt64(&a.nApplyingTx, 1)
 				a.applyBinlogTxQueue <- tx
 			}
 			if err := a.natsConn.Publish(m.Reply, nil); err != nil {
@@ -887,6 +1138,9 @@ func (a *Applier) initiateStreaming() error {
 		if err != nil {
 			return err
 		}
+		if err := a.addSub(sub); err != nil {
+			return err
+		}
 		/*if err := sub.SetPendingLimits(a.mysqlContext.MsgsLimit, a.mysqlContext.BytesLimit); err != nil {
 			return err
 		}*/
@@ -902,10 +1156,23 @@ func (a *Applier) initDBConnections() (err error) {
 		return err
 	}
 	a.db.SetMaxOpenConns(10 + a.mysqlContext.ParallelWorkers)
+	if err := a.detectTargetServer(); err != nil {
+		return err
+	}
 
 	if a.dbs, err = sql.CreateConns(a.db, a.mysqlContext.ParallelWorkers); err != nil {
 		return err
 	}
+	if err := a.validateSessionVariables(); err != nil {
+		return err
+	}
+	for _, conn := range a.dbs {
+		for _, query := range a.sessionStatements() {
+			if _, err := conn.Db.ExecContext(context.Background(), query); err != nil {
+				return err
+			}
+		}
+	}
 
 	if err := a.validateConnection(a.db); err != nil {
 		return err
@@ -913,6 +1180,11 @@ func (a *Applier) initDBConnections() (err error) {
 	if err := a.validateServerUUID(); err != nil {
 		return err
 	}
+	if a.ddlRewriter, err = newDDLRewriter(a.mysqlContext.MySQLVersion, a.tidb, a.mysqlContext.DDLRewriteRules,
+		a.mysqlContext.DDLRewriteDryRun); err != nil {
+		return err
+	}
+	a.logger.Printf("mysql.applier: DDL rewrite rules for the target: %v", a.ddlRewriter.ruleNames())
 	if err := a.validateGrants(); err != nil {
 		a.logger.Errorf("mysql.applier: Unexpected error on validateGrants, got %v", err)
 		return err
@@ -922,28 +1194,36 @@ func (a *Applier) initDBConnections() (err error) {
 		return err
 	}
 	a.logger.Debugf("mysql.applier. after validateAndReadTimeZone")
+	if a.mysqlContext.SkipTriggers {
+		if err := a.checkTriggers(); err != nil {
+			return err
+		}
+	}
 
-	if a.mysqlContext.ApproveHeterogeneous {
+	if a.mysqlContext.DryRunFile != "" {
+		if a.dryRun, err = newDryRunWriter(a.mysqlContext.DryRunFile); err != nil {
+			return err
+		}
+		// Nothing is recorded on the target, the transactions are written
+		// from the checkpoint again after a restart
+		a.gtidExecuted = make(base.GtidSet)
+		a.logger.Printf("mysql.applier: Dry run, writing the statements to %s", a.mysqlContext.DryRunFile)
+	} else if a.mysqlContext.ApproveHeterogeneous {
 		if err := a.createTableGtidExecutedV3(); err != nil {
 			return err
 		}
 		a.logger.Debugf("mysql.applier. after createTableGtidExecutedV2")
+		if err := createSkippedDDLTable(a.db); err != nil {
+			return err
+		}
+		if err := createDDLHistoryTable(a.db); err != nil {
+			return err
+		}
 
 		for i := range a.dbs {
-			a.dbs[i].PsDeleteExecutedGtid, err = a.dbs[i].Db.PrepareContext(context.Background(), fmt.Sprintf("delete from %v.%v where job_uuid = unhex('%s') and source_uuid = ?",
-				g.DtleSchemaName, g.GtidExecutedTableV3, hex.EncodeToString(a.subjectUUID.Bytes())))
-			if err != nil {
+			if err := a.prepareGtidExecutedStmts(a.dbs[i]); err != nil {
 				return err
 			}
-			a.dbs[i].PsInsertExecutedGtid, err = a.dbs[i].Db.PrepareContext(context.Background(), fmt.Sprintf("replace into %v.%v "+
-				"(job_uuid,source_uuid,interval_gtid) "+
-				"values (unhex('%s'), ?, ?)",
-				g.DtleSchemaName, g.GtidExecutedTableV3,
-				hex.EncodeToString(a.subjectUUID.Bytes())))
-			if err != nil {
-				return err
-			}
-
 		}
 		a.logger.Debugf("mysql.applier. after prepare stmt for gtid_executed table")
 	}
@@ -957,6 +1237,87 @@ func (a *Applier) initDBConnections() (err error) {
 	return nil
 }
 
+// prepareGtidExecutedStmts prepares the statements recording the applied
+// transactions in the gtid_executed table on a connection of a worker
+func (a *Applier) prepareGtidExecutedStmts(conn *sql.Conn) (err error) {
+	conn.PsDeleteExecutedGtid, err = conn.Db.PrepareContext(context.Background(), fmt.Sprintf("delete from %v.%v where job_uuid = unhex('%s') and source_uuid = ?",
+		g.DtleSchemaName, g.GtidExecutedTableV3, hex.EncodeToString(a.subjectUUID.Bytes())))
+	if err != nil {
+		return err
+	}
+	conn.PsInsertExecutedGtid, err = conn.Db.PrepareContext(context.Background(), fmt.Sprintf("replace into %v.%v "+
+		"(job_uuid,source_uuid,interval_gtid) "+
+		"values (unhex('%s'), ?, ?)",
+		g.DtleSchemaName, g.GtidExecutedTableV3,
+		hex.EncodeToString(a.subjectUUID.Bytes())))
+	return err
+}
+
+// sessionStatements returns the statements setting up the sessions applying
+// the snapshot and the binlog entries
+func (a *Applier) sessionStatements() []string {
+	foreignKeyChecks := 0
+	if a.mysqlContext.ForeignKeyChecks {
+		foreignKeyChecks = 1
+	}
+	queries := []string{fmt.Sprintf("SET @@session.foreign_key_checks = %d", foreignKeyChecks)}
+	if a.mysqlContext.SkipTriggers {
+		queries = append(queries, fmt.Sprintf("SET @%s = 1", skipTriggersVariable))
+	}
+	if a.tidb {
+		// TiDB refuses by default the DDLs removing the AUTO_INCREMENT of a
+		// column, which MySQL allows
+		queries = append(queries, "SET @@session.tidb_allow_remove_auto_inc = 1")
+	}
+
+	if _, ok := a.mysqlContext.SessionVariables["time_zone"]; !ok && a.mysqlContext.TimeZone != "" {
+		queries = append(queries, fmt.Sprintf("SET @@session.time_zone = '%s'", sql.EscapeValue(a.mysqlContext.TimeZone)))
+	}
+
+	var names []string
+	for name := range a.mysqlContext.SessionVariables {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		value := a.mysqlContext.SessionVariables[name]
+		// Integer variables reject quoted values
+		if _, err := strconv.ParseInt(value, 10, 64); err != nil {
+			value = fmt.Sprintf("'%s'", sql.EscapeValue(value))
+		}
+		queries = append(queries, fmt.Sprintf("SET @@session.%s = %s", name, value))
+	}
+	return queries
+}
+
+// validateSessionVariables checks the names of the SessionVariables, which
+// are written as is in the SET statements
+func (a *Applier) validateSessionVariables() error {
+	for name, value := range a.mysqlContext.SessionVariables {
+		for _, c := range name {
+			if !(c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9') {
+				return fmt.Errorf("invalid session variable name %q", name)
+			}
+		}
+		a.logger.Printf("mysql.applier: Will use %s='%s' on applier", name, value)
+	}
+	return nil
+}
+
+// checkTriggers warns of the triggers of the target which do not check
+// @dtle_skip_triggers, and so fire on the rows applied despite SkipTriggers
+func (a *Applier) checkTriggers() error {
+	query := `select TRIGGER_SCHEMA, TRIGGER_NAME, ACTION_STATEMENT from information_schema.TRIGGERS
+		where TRIGGER_SCHEMA not in ('mysql', 'sys', 'information_schema', 'performance_schema')`
+	return sql.QueryRowsMap(a.db, query, func(m sql.RowMap) error {
+		if !strings.Contains(strings.ToLower(m.GetString("ACTION_STATEMENT")), "@"+skipTriggersVariable) {
+			a.logger.Warnf("mysql.applier: trigger %s.%s does not check @%s and fires on the rows applied",
+				m.GetString("TRIGGER_SCHEMA"), m.GetString("TRIGGER_NAME"), skipTriggersVariable)
+		}
+		return nil
+	})
+}
+
 func (a *Applier) validateServerUUID() error {
 	query := `SELECT @@SERVER_UUID`
 	if err := a.db.QueryRow(query).Scan(&a.mysqlContext.MySQLServerUuid); err != nil {
@@ -1034,9 +1395,14 @@ func (a *Applier) validateGrants() error {
 
 // validateAndReadTimeZone potentially reads server time-zone
 func (a *Applier) validateAndReadTimeZone() error {
-	query := `select @@global.time_zone`
-	if err := a.db.QueryRow(query).Scan(&a.mysqlContext.TimeZone); err != nil {
-		return err
+	if timeZone, ok := a.mysqlContext.SessionVariables["time_zone"]; ok {
+		a.mysqlContext.TimeZone = timeZone
+	}
+	if a.mysqlContext.TimeZone == "" {
+		query := `select @@global.time_zone`
+		if err := a.db.QueryRow(query).Scan(&a.mysqlContext.TimeZone); err != nil {
+			return err
+		}
 	}
 
 	a.logger.Printf("mysql.applier: Will use time_zone='%s' on applier", a.mysqlContext.TimeZone)
@@ -1152,31 +1518,60 @@ func (a *Applier) getTableItem(schema string, table string) *applierTableItem {
 
 // buildDMLEventQuery creates a query to operate on the ghost table, based on an intercepted binlog
 // event entry on the original table.
-func (a *Applier) buildDMLEventQuery(dmlEvent binlog.DataEvent, workerIdx int) (query *gosql.Stmt, args []interface{}, rowsDelta int64, err error) {
+func (a *Applier) buildDMLEventQuery(binlogEntry *binlog.BinlogEntry, dmlEvent binlog.DataEvent, workerIdx int) (query *gosql.Stmt, args []interface{}, rowsDelta int64, err error) {
 	// Large piece of code deleted here. See git annotate.
 	tableItem := dmlEvent.TableItem.(*applierTableItem)
 	var tableColumns = tableItem.columns
 
-	doPrepareIfNil := func(stmts []*gosql.Stmt, query string) (*gosql.Stmt, error) {
-		var err error
-		if stmts[workerIdx] == nil {
-			a.logger.Debugf("mysql.applier buildDMLEventQuery prepare query %v", query)
-			stmts[workerIdx], err = a.dbs[workerIdx].Db.PrepareContext(context.Background(), query)
-			if err != nil {
-				a.logger.Errorf("mysql.applier buildDMLEventQuery prepare query %v err %v", query, err)
-			}
+	if conn := a.dbs[workerIdx].Db; tableItem.psConns[workerIdx] != conn {
+		// The statements prepared on a lost connection are closed with it
+		tableItem.psInsert.forget(workerIdx)
+		tableItem.psDelete.forget(workerIdx)
+		tableItem.psUpdate.forget(workerIdx)
+		tableItem.psHistory.forget(workerIdx)
+		tableItem.psConns[workerIdx] = conn
+	}
+	doPrepare := func(stmts *preparedStmts, query string) (*gosql.Stmt, error) {
+		stmt, err := stmts.prepare(a.dbs[workerIdx].Db, workerIdx, query)
+		if err != nil {
+			a.logger.Errorf("mysql.applier buildDMLEventQuery prepare query %v err %v", query, err)
 		}
-		return stmts[workerIdx], err
+		return stmt, err
+	}
+
+	if a.mysqlContext.History.Applies(dmlEvent.DatabaseName, dmlEvent.TableName) {
+		query, args, err := a.historyQuery(binlogEntry, &dmlEvent, tableColumns)
+		if err != nil {
+			return nil, nil, -1, err
+		}
+		stmt, err := doPrepare(&tableItem.psHistory, query)
+		if err != nil {
+			return nil, nil, -1, err
+		}
+		return stmt, args, 1, err
 	}
 
 	switch dmlEvent.DML {
 	case binlog.DeleteDML:
+		if softDelete := a.mysqlContext.SoftDelete; softDelete.Applies(dmlEvent.DatabaseName, dmlEvent.TableName) {
+			query, uniqueKeyArgs, err := sql.BuildDMLSoftDeleteQuery(dmlEvent.DatabaseName, dmlEvent.TableName, tableColumns,
+				softDelete.Column, softDelete.Value, dmlEvent.WhereColumnValues.GetAbstractValues())
+			if err != nil {
+				return nil, nil, -1, err
+			}
+			stmt, err := doPrepare(&tableItem.psDelete, query)
+			if err != nil {
+				return nil, nil, -1, err
+			}
+			// the row is kept
+			return stmt, uniqueKeyArgs, 0, err
+		}
 		{
 			query, uniqueKeyArgs, err := sql.BuildDMLDeleteQuery(dmlEvent.DatabaseName, dmlEvent.TableName, tableColumns, dmlEvent.WhereColumnValues.GetAbstractValues())
 			if err != nil {
 				return nil, nil, -1, err
 			}
-			stmt, err := doPrepareIfNil(tableItem.psDelete, query)
+			stmt, err := doPrepare(&tableItem.psDelete, query)
 			if err != nil {
 				return nil, nil, -1, err
 			}
@@ -1189,7 +1584,7 @@ func (a *Applier) buildDMLEventQuery(dmlEvent binlog.DataEvent, workerIdx int) (
 			if err != nil {
 				return nil, nil, -1, err
 			}
-			stmt, err := doPrepareIfNil(tableItem.psInsert, query)
+			stmt, err := doPrepare(&tableItem.psInsert, query)
 			if err != nil {
 				return nil, nil, -1, err
 			}
@@ -1204,7 +1599,7 @@ func (a *Applier) buildDMLEventQuery(dmlEvent binlog.DataEvent, workerIdx int) (
 			args = append(args, sharedArgs...)
 			args = append(args, uniqueKeyArgs...)
 
-			stmt, err := doPrepareIfNil(tableItem.psUpdate, query)
+			stmt, err := doPrepare(&tableItem.psUpdate, query)
 			if err != nil {
 				return nil, nil, -1, err
 			}
@@ -1215,122 +1610,218 @@ func (a *Applier) buildDMLEventQuery(dmlEvent binlog.DataEvent, workerIdx int) (
 	return nil, args, 0, fmt.Errorf("Unknown dml event type: %+v", dmlEvent.DML)
 }
 
-// ApplyEventQueries applies multiple DML queries onto the dest table
+// ApplyBinlogEvent applies a source transaction, retrying it when it fails
+// on a deadlock or a lock wait timeout, or on TiDB a write conflict, and
+// once reconnected when the connection to the target is lost. The
+// transactions depending on it wait for it to be executed, so the retries
+// keep them in order.
 func (a *Applier) ApplyBinlogEvent(workerIdx int, binlogEntry *binlog.BinlogEntry) error {
+	return a.applyBinlogEntries(workerIdx, []*binlog.BinlogEntry{binlogEntry})
+}
+
+// applyBinlogEntries applies source transactions in a transaction of the
+// target, retried as the TxRetries and the TargetOutageTimeout allow
+func (a *Applier) applyBinlogEntries(workerIdx int, binlogEntries []*binlog.BinlogEntry) error {
+	dbApplier := a.dbs[workerIdx]
+	dbApplier.DbMutex.Lock()
+	defer dbApplier.DbMutex.Unlock()
+
+	if a.dryRun != nil {
+		return a.dryRunBinlogTx(binlogEntries)
+	}
+	// the transactions are committed together, as the last one is
+	binlogEntry := binlogEntries[len(binlogEntries)-1]
+	backoff := txRetryInitialBackoff
+	maxBackoff := time.Duration(a.mysqlContext.TxRetryMaxBackoff) * time.Millisecond
+	for retries := 0; ; retries++ {
+		err := a.faults.Inject(config.FaultApply)
+		if err == nil {
+			err = a.applyBinlogTx(workerIdx, binlogEntries)
+		}
+		if err != nil && a.mysqlContext.TargetOutageTimeout > 0 && sql.ConnectionError(err) {
+			if err := a.waitForTarget(workerIdx, err); err != nil {
+				return err
+			}
+			// The target may have committed the transaction before the
+			// connection was lost
+			committed, err := a.txCommitted(workerIdx, binlogEntry)
+			if err != nil {
+				return err
+			}
+			if committed {
+				atomic.AddInt64(&a.mysqlContext.TotalDeltaCopied, int64(len(binlogEntries)))
+				return nil
+			}
+			retries--
+			continue
+		}
+		if err == nil || !a.retryableError(err) || retries >= a.mysqlContext.TxRetries {
+			return a.errorClasses.Wrap(err)
+		}
+		a.logger.Warnf("mysql.applier: gtid: %s:%d, retry %d of %d in %v: %v",
+			binlogEntry.Coordinates.GetSid(), binlogEntry.Coordinates.GNO, retries+1, a.mysqlContext.TxRetries, backoff, err)
+		select {
+		case <-time.After(backoff):
+		case <-a.shutdownCh:
+			return err
+		}
+		if backoff *= 2; backoff > maxBackoff {
+			backoff = maxBackoff
+		}
+	}
+}
+
+// applyBinlogTx applies source transactions in a transaction of the
+// target, which is rolled back on error
+func (a *Applier) applyBinlogTx(workerIdx int, binlogEntries []*binlog.BinlogEntry) error {
 	dbApplier := a.dbs[workerIdx]
 
 	var totalDelta int64
 	var err error
 
-	txSid := binlogEntry.Coordinates.GetSid()
-
-	dbApplier.DbMutex.Lock()
 	tx, err := dbApplier.Db.BeginTx(context.Background(), &gosql.TxOptions{})
 	if err != nil {
 		return err
 	}
+	committed := false
 	defer func() {
-		if err := tx.Commit(); err != nil {
-			a.onError(TaskStateDead, err)
-		} else {
-			a.mtsManager.Executed(binlogEntry)
-		}
-		if a.printTps {
-			atomic.AddUint32(&a.txLastNSeconds, 1)
+		if !committed {
+			tx.Rollback()
 		}
-
-		dbApplier.DbMutex.Unlock()
 	}()
+	txStats := make(txTableStats)
+
+	for _, binlogEntry := range binlogEntries {
+		txSid := binlogEntry.Coordinates.GetSid()
+		for i, event := range binlogEntry.Events {
+			a.logger.Debugf("mysql.applier: ApplyBinlogEvent. gno: %v, event: %v",
+				binlogEntry.Coordinates.GNO, i)
+			switch event.DML {
+			case binlog.NotDML:
+				var err error
+				a.logger.Debugf("mysql.applier: ApplyBinlogEvent: not dml: %v", event.Query)
+
+				if event.CurrentSchema != "" {
+					// TODO escape schema name?
+					query := fmt.Sprintf("USE %s", event.CurrentSchema)
+					a.logger.Debugf("mysql.applier: query: %v", query)
+					_, err = tx.Exec(query)
+					if err != nil {
+						if !sql.IgnoreError(err) {
+							a.logger.Errorf("mysql.applier: Exec sql error: %v", err)
+							return err
+						} else {
+							a.logger.Warnf("mysql.applier: Ignore error: %v", err)
+						}
+					}
+				}
 
-	for i, event := range binlogEntry.Events {
-		a.logger.Debugf("mysql.applier: ApplyBinlogEvent. gno: %v, event: %v",
-			binlogEntry.Coordinates.GNO, i)
-		switch event.DML {
-		case binlog.NotDML:
-			var err error
-			a.logger.Debugf("mysql.applier: ApplyBinlogEvent: not dml: %v", event.Query)
+				if event.TableName != "" {
+					var schema string
+					if event.DatabaseName != "" {
+						schema = event.DatabaseName
+					} else {
+						schema = event.CurrentSchema
+					}
+					a.logger.Debugf("mysql.applier: reset tableItem %v.%v", schema, event.TableName)
+					a.getTableItem(schema, event.TableName).Reset()
+				} else { // TableName == ""
+					if event.DatabaseName != "" {
+						if schemaItem, ok := a.tableItems[event.DatabaseName]; ok {
+							for tableName, v := range schemaItem {
+								a.logger.Debugf("mysql.applier: reset tableItem %v.%v", event.DatabaseName, tableName)
+								v.Reset()
+							}
+						}
+						delete(a.tableItems, event.DatabaseName)
+					}
+				}
 
-			if event.CurrentSchema != "" {
-				// TODO escape schema name?
-				query := fmt.Sprintf("USE %s", event.CurrentSchema)
-				a.logger.Debugf("mysql.applier: query: %v", query)
+				if a.skipsDDL(event.Query) {
+					a.recordDDL(tx, binlogEntry, &binlogEntry.Events[i], models.DDLStatusSkipped, 0, nil)
+					continue
+				}
+				if skipped, err := a.skipsDeclaredDDL(tx, event.Query); err != nil {
+					return err
+				} else if skipped {
+					a.recordDDL(tx, binlogEntry, &binlogEntry.Events[i], models.DDLStatusSkipped, 0, nil)
+					continue
+				}
+				query := a.rewriteDDL(event.Query)
+				execStart := time.Now()
 				_, err = tx.Exec(query)
+				execTime := time.Since(execStart)
+				a.checkSlowApply(binlogEntry, event.DatabaseName, event.TableName, execTime,
+					func() (string, error) { return query, nil })
 				if err != nil {
-					if !sql.IgnoreError(err) {
+					if !sql.IgnoreError(err) && a.errorClasses.Classify(err) != sql.ErrorClassIgnorable {
 						a.logger.Errorf("mysql.applier: Exec sql error: %v", err)
+						// the transaction is rolled back
+						a.recordDDL(a.db, binlogEntry, &binlogEntry.Events[i], models.DDLStatusFailed, execTime, err)
 						return err
 					} else {
 						a.logger.Warnf("mysql.applier: Ignore error: %v", err)
 					}
 				}
-			}
-
-			if event.TableName != "" {
-				var schema string
-				if event.DatabaseName != "" {
-					schema = event.DatabaseName
-				} else {
-					schema = event.CurrentSchema
+				a.recordDDL(tx, binlogEntry, &binlogEntry.Events[i], models.DDLStatusApplied, execTime, err)
+				a.logger.Debugf("mysql.applier: Exec [%s]", event.Query)
+			default:
+				a.logger.Debugf("mysql.applier: ApplyBinlogEvent: a dml event")
+				stmt, args, rowDelta, err := a.buildDMLEventQuery(binlogEntry, event, workerIdx)
+				if err != nil {
+					a.logger.Errorf("mysql.applier: Build dml query error: %v", err)
+					return err
 				}
-				a.logger.Debugf("mysql.applier: reset tableItem %v.%v", schema, event.TableName)
-				a.getTableItem(schema, event.TableName).Reset()
-			} else { // TableName == ""
-				if event.DatabaseName != "" {
-					if schemaItem, ok := a.tableItems[event.DatabaseName]; ok {
-						for tableName, v := range schemaItem {
-							a.logger.Debugf("mysql.applier: reset tableItem %v.%v", event.DatabaseName, tableName)
-							v.Reset()
-						}
+
+				a.logger.Debugf("ApplyBinlogEvent. args: %v", args)
+
+				var r gosql.Result
+				execStart := time.Now()
+				r, err = stmt.Exec(args...)
+				if err != nil {
+					if a.errorClasses.Classify(err) != sql.ErrorClassIgnorable {
+						a.logger.Errorf("mysql.applier: gtid: %s:%d, error: %v", txSid, binlogEntry.Coordinates.GNO, err)
+						return err
 					}
-					delete(a.tableItems, event.DatabaseName)
+					a.logger.Warnf("mysql.applier: gtid: %s:%d, ignoring the statement: %v", txSid, binlogEntry.Coordinates.GNO, err)
+					continue
 				}
-			}
-
-			_, err = tx.Exec(event.Query)
-			if err != nil {
-				if !sql.IgnoreError(err) {
-					a.logger.Errorf("mysql.applier: Exec sql error: %v", err)
-					return err
+				applyTime := time.Since(execStart)
+				txStats.add(&binlogEntry.Events[i], args, applyTime)
+				a.checkSlowApply(binlogEntry, event.DatabaseName, event.TableName, applyTime,
+					func() (string, error) { return a.dmlQueryText(binlogEntry, &binlogEntry.Events[i]) })
+				nr, err := r.RowsAffected()
+				if err != nil {
+					a.logger.Debugf("ApplyBinlogEvent executed gno %v event %v rows_affected_err %v schema", binlogEntry.Coordinates.GNO, i, err)
 				} else {
-					a.logger.Warnf("mysql.applier: Ignore error: %v", err)
+					a.logger.Debugf("ApplyBinlogEvent executed gno %v event %v rows_affected %v", binlogEntry.Coordinates.GNO, i, nr)
 				}
+				totalDelta += rowDelta
 			}
-			a.logger.Debugf("mysql.applier: Exec [%s]", event.Query)
-		default:
-			a.logger.Debugf("mysql.applier: ApplyBinlogEvent: a dml event")
-			stmt, args, rowDelta, err := a.buildDMLEventQuery(event, workerIdx)
-			if err != nil {
-				a.logger.Errorf("mysql.applier: Build dml query error: %v", err)
-				return err
-			}
-
-			a.logger.Debugf("ApplyBinlogEvent. args: %v", args)
+		}
 
-			var r gosql.Result
-			r, err = stmt.Exec(args...)
-			if err != nil {
-				a.logger.Errorf("mysql.applier: gtid: %s:%d, error: %v", txSid, binlogEntry.Coordinates.GNO, err)
-				return err
-			}
-			nr, err := r.RowsAffected()
-			if err != nil {
-				a.logger.Debugf("ApplyBinlogEvent executed gno %v event %v rows_affected_err %v schema", binlogEntry.Coordinates.GNO, i, err)
-			} else {
-				a.logger.Debugf("ApplyBinlogEvent executed gno %v event %v rows_affected %v", binlogEntry.Coordinates.GNO, i, nr)
-			}
-			totalDelta += rowDelta
+		a.logger.Debugf("ApplyBinlogEvent. insert gno: %v", binlogEntry.Coordinates.GNO)
+		_, err = dbApplier.PsInsertExecutedGtid.Exec(binlogEntry.Coordinates.SID.Bytes(), binlogEntry.Coordinates.GNO)
+		if err != nil {
+			return err
 		}
 	}
-
-	a.logger.Debugf("ApplyBinlogEvent. insert gno: %v", binlogEntry.Coordinates.GNO)
-	_, err = dbApplier.PsInsertExecutedGtid.Exec(binlogEntry.Coordinates.SID.Bytes(), binlogEntry.Coordinates.GNO)
-	if err != nil {
+	if err := tx.Commit(); err != nil {
 		return err
 	}
+	committed = true
+	a.tableStats.addTx(txStats)
+	a.latency.observe(binlogEntries)
+	for _, binlogEntry := range binlogEntries {
+		a.schemaHistory.add(schemaVersions(binlogEntry)...)
+	}
+	if a.printTps {
+		atomic.AddUint32(&a.txLastNSeconds, uint32(len(binlogEntries)))
+	}
 
 	// no error
 	a.mysqlContext.Stage = models.StageWaitingForGtidToBeCommitted
-	atomic.AddInt64(&a.mysqlContext.TotalDeltaCopied, 1)
+	atomic.AddInt64(&a.mysqlContext.TotalDeltaCopied, int64(len(binlogEntries)))
 	return nil
 }
 
@@ -1342,60 +1833,128 @@ func (a *Applier) ApplyEventQueries(db *gosql.DB, entry *DumpEntry) error {
 	}
 
 	queries := []string{}
-	queries = append(queries, entry.SystemVariablesStatement, entry.SqlMode, entry.DbSQL)
+	queries = append(queries, entry.SystemVariablesStatement)
+	if _, ok := a.mysqlContext.SessionVariables["sql_mode"]; !ok {
+		queries = append(queries, entry.SqlMode)
+	}
+	queries = append(queries, entry.DbSQL)
 	queries = append(queries, entry.TbSQL...)
-	tx, err := db.Begin()
-	if err != nil {
-		return err
+	var tx *gosql.Tx
+	if a.dryRun == nil {
+		var err error
+		tx, err = db.Begin()
+		if err != nil {
+			return err
+		}
 	}
 	defer func() {
-		if err := tx.Commit(); err != nil {
-			a.onError(TaskStateDead, err)
+		if tx != nil {
+			if err := tx.Commit(); err != nil {
+				a.onError(TaskStateDead, err)
+			}
 		}
 		atomic.AddInt64(&a.mysqlContext.TotalRowsReplay, entry.RowsCount)
 	}()
-	sessionQuery := `SET @@session.foreign_key_checks = 0`
-	if _, err := tx.Exec(sessionQuery); err != nil {
-		return err
+	if tx != nil {
+		// The TIMESTAMP values of the snapshot are read in UTC on the source
+		sessionQueries := append(a.sessionStatements(), "SET @@session.time_zone = '+00:00'")
+		for _, sessionQuery := range sessionQueries {
+			if _, err := tx.Exec(sessionQuery); err != nil {
+				return err
+			}
+		}
 	}
-	execQuery := func(query string) error {
+	// execQuery returns whether the query was executed, its ignored errors
+	// aside
+	execQuery := func(query string) (bool, error) {
+		if a.dryRun != nil {
+			return true, a.dryRun.write(query)
+		}
 		a.logger.Debugf("mysql.applier: Exec [%s]", utils.StrLim(query, 256))
 		_, err := tx.Exec(query)
 		if err != nil {
 			if !sql.IgnoreError(err) {
 				a.logger.Errorf("mysql.applier: Exec [%s] error: %v", utils.StrLim(query, 10), err)
-				return err
+				return false, err
 			}
 			if !sql.IgnoreExistsError(err) {
 				a.logger.Warnf("mysql.applier: Ignore error: %v", err)
 			}
+			return false, nil
 		}
-		return nil
+		return true, nil
 	}
 
+	var schema string
 	for _, query := range queries {
-		if query == "" {
+		if query == "" || a.skipsDDL(query) {
 			continue
 		}
-		err := execQuery(query)
+		var mappings []*models.SchemaMapping
+		switch {
+		case strings.HasPrefix(query, "USE "):
+			schema = strings.Trim(strings.TrimPrefix(query, "USE "), "`")
+		case strings.HasPrefix(query, "CREATE DATABASE "):
+			query = a.rewriteCreateDatabase(a.rewriteDDL(query))
+		case strings.HasPrefix(query, "CREATE TABLE "):
+			query, mappings = a.rewriteCreateTable(schema, a.rewriteDDL(query))
+		default:
+			query = a.rewriteDDL(query)
+		}
+		executed, err := execQuery(query)
 		if err != nil {
 			return err
 		}
+		if executed {
+			a.reportSchemaMappings(mappings)
+		}
+	}
+
+	// Generated columns are left out, and computed by the target
+	var columns string
+	generated := make(map[int]bool)
+	if len(entry.GeneratedColumns) > 0 {
+		var names []string
+		for _, j := range entry.GeneratedColumns {
+			generated[j] = true
+		}
+		for j, name := range entry.ColumnNames {
+			if !generated[j] {
+				names = append(names, sql.EscapeName(name))
+			}
+		}
+		columns = fmt.Sprintf(" (%s)", strings.Join(names, ","))
+	}
+
+	if a.mysqlContext.SnapshotLoadData && a.dryRun == nil && atomic.LoadInt32(&a.loadDataFailed) == 0 && len(entry.ValuesX) > 0 {
+		err := a.loadData(tx, entry, columns, generated)
+		if err == nil {
+			return nil
+		}
+		atomic.StoreInt32(&a.loadDataFailed, 1)
+		a.logger.Warnf("mysql.applier: LOAD DATA into %s.%s failed, inserting the rows of the snapshot instead: %v",
+			entry.TableSchema, entry.TableName, err)
 	}
+	insertInto := fmt.Sprintf(`replace into %s.%s%s values (`, entry.TableSchema, entry.TableName, columns)
 
 	var buf bytes.Buffer
 	BufSizeLimit := 1 * 1024 * 1024 // 1MB. TODO parameterize it
 	BufSizeLimitDelta := 1024
 	buf.Grow(BufSizeLimit + BufSizeLimitDelta)
+	batchRows := a.snapshotBatchRows()
+	rows := 0
 	for i, _ := range entry.ValuesX {
 		if buf.Len() == 0 {
-			buf.WriteString(fmt.Sprintf(`replace into %s.%s values (`, entry.TableSchema, entry.TableName))
+			buf.WriteString(insertInto)
 		} else {
 			buf.WriteString(",(")
 		}
 
 		firstCol := true
 		for j := range entry.ValuesX[i] {
+			if generated[j] {
+				continue
+			}
 			if firstCol {
 				firstCol = false
 			} else {
@@ -1412,13 +1971,15 @@ func (a *Applier) ApplyEventQueries(db *gosql.DB, entry *DumpEntry) error {
 			}
 		}
 		buf.WriteByte(')')
+		rows++
 
-		needInsert := (i == len(entry.ValuesX)-1) || (buf.Len() >= BufSizeLimit)
+		needInsert := (i == len(entry.ValuesX)-1) || (buf.Len() >= BufSizeLimit) || (batchRows > 0 && rows >= batchRows)
 		// last rows or sql too large
 
 		if needInsert {
-			err := execQuery(buf.String())
+			_, err := execQuery(buf.String())
 			buf.Reset()
+			rows = 0
 			if err != nil {
 				return err
 			}
@@ -1478,26 +2039,47 @@ func (a *Applier) Stats() (*models.TaskStatistics, error) {
 		ETA:                eta,
 		Backlog:            backlog,
 		Stage:              a.mysqlContext.Stage,
+		ApplyMode:          a.applyMode(),
 		CurrentCoordinates: a.currentCoordinates,
 		BufferStat: models.BufferStat{
 			ApplierTxQueueSize:      len(a.applyBinlogTxQueue),
 			ApplierGroupTxQueueSize: len(a.applyBinlogGroupTxQueue),
+			Queues:                  a.queueStats(),
 		},
-		Timestamp: time.Now().UTC().UnixNano(),
+		Heartbeat:       a.heartbeat.Stat(),
+		Latency:         a.latency.stat(),
+		TableApplyStats: a.tableStats.stats(),
+		SchemaMappings:  a.schemaMappings.list(),
+		DDLRewrites:     a.ddlRewrites(),
+		SlowApplies:     a.slowApplies.list(),
+		IndexAdvisories: a.tableStats.indexAdvisories(),
+		Reconciliation:  a.reconciliation.get(),
+		Timestamp:       time.Now().UTC().UnixNano(),
 	}
 	if a.natsConn != nil {
 		taskResUsage.MsgStat = a.natsConn.Statistics
 	}
+	taskResUsage.NetworkStat = networkStat(a.mysqlContext.ConnectionConfig.Traffic(), taskResUsage.MsgStat)
 
 	return &taskResUsage, nil
 }
 
+// applyMode returns how the transactions are applied, in parallel or in
+// the commit order of the source
+func (a *Applier) applyMode() string {
+	if a.mysqlContext.StrictOrder {
+		return models.ApplyModeStrictOrder
+	}
+	return models.ApplyModeParallel
+}
+
 func (a *Applier) ID() string {
 	id := config.DriverCtx{
 		DriverConfig: &config.MySQLDriverConfig{
 			ReplicateDoDb:     a.mysqlContext.ReplicateDoDb,
 			ReplicateIgnoreDb: a.mysqlContext.ReplicateIgnoreDb,
 			Gtid:              a.mysqlContext.Gtid,
+			SchemaHistory:     a.schemaHistory.list(),
 			NatsAddr:          a.mysqlContext.NatsAddr,
 			ParallelWorkers:   a.mysqlContext.ParallelWorkers,
 			ConnectionConfig:  a.mysqlContext.ConnectionConfig,
@@ -1536,10 +2118,109 @@ func (a *Applier) onError(state int, err error) {
 	a.Shutdown()
 }
 
+func (a *Applier) onPanic(err *models.PanicError) {
+	a.onError(TaskStateRestart, err)
+}
+
+// addSub records a subscription to the data of the source, limiting its
+// pending messages to the NatsPendingMsgs of the job
+func (a *Applier) addSub(sub *gonats.Subscription) error {
+	if n := a.mysqlContext.QueueSizes.NatsPendingMsgs; n > 0 {
+		_, bytesLimit, err := sub.PendingLimits()
+		if err != nil {
+			return err
+		}
+		if err := sub.SetPendingLimits(n, bytesLimit); err != nil {
+			return err
+		}
+	}
+	a.subsMu.Lock()
+	defer a.subsMu.Unlock()
+	a.subs = append(a.subs, sub)
+	return nil
+}
+
+// queueStats returns the depths of the queues of the applier
+func (a *Applier) queueStats() []models.QueueStat {
+	var pending models.QueueStat
+	a.subsMu.Lock()
+	for _, sub := range a.subs {
+		if msgs, _, err := sub.Pending(); err == nil {
+			pending.Depth += msgs
+		}
+		if msgsLimit, _, err := sub.PendingLimits(); err == nil {
+			pending.Capacity += msgsLimit
+		}
+	}
+	a.subsMu.Unlock()
+
+	queues := []models.QueueStat{
+		{Name: "nats_pending", Depth: pending.Depth, Capacity: pending.Capacity},
+		{Name: "snapshot_chunks", Depth: len(a.copyRowsQueue), Capacity: cap(a.copyRowsQueue)},
+	}
+	if a.mysqlContext.ApproveHeterogeneous {
+		return append(queues,
+			models.QueueStat{Name: "apply_entries", Depth: len(a.applyDataEntryQueue), Capacity: cap(a.applyDataEntryQueue)},
+			models.QueueStat{Name: "apply_workers", Depth: len(a.applyBinlogMtsTxQueue), Capacity: cap(a.applyBinlogMtsTxQueue)})
+	}
+	return append(queues,
+		models.QueueStat{Name: "apply_txs", Depth: len(a.applyBinlogTxQueue), Capacity: cap(a.applyBinlogTxQueue)},
+		models.QueueStat{Name: "apply_tx_groups", Depth: len(a.applyBinlogGroupTxQueue), Capacity: cap(a.applyBinlogGroupTxQueue)})
+}
+
 func (a *Applier) WaitCh() chan *models.WaitResult {
 	return a.waitCh
 }
 
+// Drain stops the subscriptions to the data of the source, so that no new
+// entry is received, and waits up to timeout for the entries received to be
+// applied along with their Gtid.
+func (a *Applier) Drain(timeout time.Duration) error {
+	a.subsMu.Lock()
+	subs := a.subs
+	a.subsMu.Unlock()
+	for _, sub := range subs {
+		if err := sub.Unsubscribe(); err != nil {
+			a.logger.Warnf("mysql.applier: failed to unsubscribe %v: %v", sub.Subject, err)
+		}
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		// the message being handled, if any, is enqueued
+		a.handleMu.Lock()
+		a.handleMu.Unlock()
+
+		// the homogeneous replay counts the transactions until they are
+		// applied, including those being executed
+		for !a.shutdown && (atomic.LoadInt64(&a.nDumpEntry) != 0 ||
+			atomic.LoadInt64(&a.nApplyingTx) != 0) {
+			time.Sleep(100 * time.Millisecond)
+		}
+		if !a.mysqlContext.ApproveHeterogeneous {
+			return
+		}
+		drained := make(chan struct{})
+		select {
+		case a.drainCh <- drained:
+		case <-a.shutdownCh:
+			return
+		}
+		select {
+		case <-drained:
+		case <-a.shutdownCh:
+		}
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-time.After(timeout):
+		return fmt.Errorf("mysql.applier: drain timed out after %v", timeout)
+	}
+}
+
 func (a *Applier) Shutdown() error {
 	a.shutdownLock.Lock()
 	defer a.shutdownLock.Unlock()
@@ -1560,6 +2241,11 @@ func (a *Applier) Shutdown() error {
 	if err := sql.CloseConns(a.dbs...); err != nil {
 		return err
 	}
+	if a.dryRun != nil {
+		if err := a.dryRun.Close(); err != nil {
+			return err
+		}
+	}
 
 	//close(a.applyBinlogTxQueue)
 	//close(a.applyBinlogGroupTxQueue)
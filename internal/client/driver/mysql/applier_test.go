@@ -7,17 +7,22 @@
 package mysql
 
 import (
+	"context"
 	gosql "database/sql"
+	"fmt"
+	"io"
+	"os"
 	"reflect"
-	"sync"
+	"strings"
 	"testing"
+
 	"github.com/actiontech/dtle/internal/client/driver/mysql/binlog"
 	"github.com/actiontech/dtle/internal/client/driver/mysql/sql"
 	"github.com/actiontech/dtle/internal/config"
+	umconf "github.com/actiontech/dtle/internal/config/mysql"
 	log "github.com/actiontech/dtle/internal/logger"
 	"github.com/actiontech/dtle/internal/models"
-
-	gonats "github.com/nats-io/go-nats"
+	gomysqldriver "github.com/go-sql-driver/mysql"
 )
 
 func TestNewApplier(t *testing.T) {
@@ -28,15 +33,21 @@ func TestNewApplier(t *testing.T) {
 		logger  *log.Logger
 	}
 	tests := []struct {
-		name string
-		args args
-		want *Applier
+		name    string
+		args    args
+		want    *Applier
+		wantErr bool
 	}{
 		// TODO: Add test cases.
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			if got := NewApplier(tt.args.subject, tt.args.tp, tt.args.cfg, tt.args.logger); !reflect.DeepEqual(got, tt.want) {
+			got, err := NewApplier(tt.args.subject, tt.args.tp, tt.args.cfg, tt.args.logger)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("NewApplier() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
 				t.Errorf("NewApplier() = %v, want %v", got, tt.want)
 			}
 		})
@@ -57,28 +68,6 @@ func TestApplier_Run(t *testing.T) {
 	}
 }
 
-func TestApplier_onApplyTxStruct(t *testing.T) {
-	type args struct {
-		dbApplier *sql.DB
-		binlogTx  *binlog.BinlogTx
-	}
-	tests := []struct {
-		name    string
-		a       *Applier
-		args    args
-		wantErr bool
-	}{
-		// TODO: Add test cases.
-	}
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			if err := tt.a.onApplyTxStruct(tt.args.dbApplier, tt.args.binlogTx); (err != nil) != tt.wantErr {
-				t.Errorf("Applier.onApplyTxStruct() error = %v, wantErr %v", err, tt.wantErr)
-			}
-		})
-	}
-}
-
 func TestApplier_executeWriteFuncs(t *testing.T) {
 	tests := []struct {
 		name string
@@ -167,16 +156,16 @@ func TestApplier_initDBConnections(t *testing.T) {
 
 func TestApplier_validateServerUUID(t *testing.T) {
 	tests := []struct {
-		name string
-		a    *Applier
-		want string
+		name    string
+		a       *Applier
+		wantErr bool
 	}{
 		// TODO: Add test cases.
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			if got := tt.a.validateServerUUID(); got != tt.want {
-				t.Errorf("Applier.validateServerUUID() = %v, want %v", got, tt.want)
+			if err := tt.a.validateServerUUID(); (err != nil) != tt.wantErr {
+				t.Errorf("Applier.validateServerUUID() error = %v, wantErr %v", err, tt.wantErr)
 			}
 		})
 	}
@@ -222,13 +211,15 @@ func TestApplier_validateAndReadTimeZone(t *testing.T) {
 
 func TestApplier_buildDMLEventQuery(t *testing.T) {
 	type args struct {
-		dmlEvent binlog.DataEvent
+		binlogEntry *binlog.BinlogEntry
+		dmlEvent    binlog.DataEvent
+		workerIdx   int
 	}
 	tests := []struct {
 		name          string
 		a             *Applier
 		args          args
-		wantQuery     string
+		wantQuery     *gosql.Stmt
 		wantArgs      []interface{}
 		wantRowsDelta int64
 		wantErr       bool
@@ -237,7 +228,7 @@ func TestApplier_buildDMLEventQuery(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			gotQuery, gotArgs, gotRowsDelta, err := tt.a.buildDMLEventQuery(tt.args.dmlEvent)
+			gotQuery, gotArgs, gotRowsDelta, err := tt.a.buildDMLEventQuery(tt.args.binlogEntry, tt.args.dmlEvent, tt.args.workerIdx)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("Applier.buildDMLEventQuery() error = %v, wantErr %v", err, tt.wantErr)
 				return
@@ -255,10 +246,80 @@ func TestApplier_buildDMLEventQuery(t *testing.T) {
 	}
 }
 
+func TestApplier_buildDMLEventQuery_jsonPatch(t *testing.T) {
+	fake := newFakeDB()
+	conn, err := fake.open().Conn(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	a := &Applier{
+		logger:       log.NewEntry(log.New(os.Stdout, log.DebugLevel)),
+		mysqlContext: &config.MySQLDriverConfig{},
+		dbs:          []*sql.Conn{{Db: conn}},
+	}
+	tableItem := newApplierTableItem(1)
+	tableItem.columns = umconf.NewColumnList([]umconf.Column{
+		{Name: "id", ColumnType: "int", Key: "PRI"},
+		{Name: "doc", ColumnType: "json"},
+	})
+	patch := umconf.JSONPatch{{Op: umconf.JSONDiffReplace, Path: "$.a", Value: []byte("2")}}
+
+	// full values and patches alternate, the statement of each update
+	// being that of its own query
+	for _, doc := range []interface{}{`{"a":1}`, patch, patch, `{"a":3}`} {
+		event := binlog.NewDataEvent("db", "tbl", binlog.UpdateDML, 2)
+		event.TableItem = tableItem
+		event.WhereColumnValues = umconf.ToColumnValues([]interface{}{1, `{"a":0}`})
+		event.NewColumnValues = umconf.ToColumnValues([]interface{}{1, doc})
+		stmt, args, _, err := a.buildDMLEventQuery(&binlog.BinlogEntry{}, event, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := stmt.Exec(args...); err != nil {
+			t.Fatalf("updating doc to %v: %v", doc, err)
+		}
+	}
+
+	execs := fake.executed()
+	if len(execs) != 4 {
+		t.Fatalf("executed %d updates, want 4", len(execs))
+	}
+	for i, want := range []string{"`doc`=?", "`doc`=JSON_REPLACE(`doc`, ?, CAST(? AS JSON))",
+		"`doc`=JSON_REPLACE(`doc`, ?, CAST(? AS JSON))", "`doc`=?"} {
+		if !strings.Contains(execs[i].query, want) {
+			t.Errorf("update %d is %s, want it setting %s", i, execs[i].query, want)
+		}
+	}
+	// the patch is prepared once, then the full value again
+	if len(fake.prepares) != 3 {
+		t.Errorf("prepared %d statements, want 3", len(fake.prepares))
+	}
+}
+
+func TestApplier_retryableError(t *testing.T) {
+	a := &Applier{}
+	for _, tt := range []struct {
+		err  error
+		want bool
+	}{
+		{&config.FaultError{Point: config.FaultApply}, true},
+		{&gomysqldriver.MySQLError{Number: sql.ErrLockDeadlock}, true},
+		{&sql.ClassifiedError{Class: sql.ErrorClassRetryable, Err: io.EOF}, true},
+		// the transaction may have been committed
+		{io.ErrUnexpectedEOF, false},
+		{&gomysqldriver.MySQLError{Number: 1146}, false},
+		{fmt.Errorf("unknown"), false},
+	} {
+		if got := a.retryableError(tt.err); got != tt.want {
+			t.Errorf("retryableError(%v) = %v, want %v", tt.err, got, tt.want)
+		}
+	}
+}
+
 func TestApplier_ApplyBinlogEvent(t *testing.T) {
 	type args struct {
-		db     *gosql.DB
-		events [](binlog.DataEvent)
+		workerIdx   int
+		binlogEntry *binlog.BinlogEntry
 	}
 	tests := []struct {
 		name    string
@@ -270,7 +331,7 @@ func TestApplier_ApplyBinlogEvent(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			if err := tt.a.ApplyBinlogEvent(tt.args.db, tt.args.events); (err != nil) != tt.wantErr {
+			if err := tt.a.ApplyBinlogEvent(tt.args.workerIdx, tt.args.binlogEntry); (err != nil) != tt.wantErr {
 				t.Errorf("Applier.ApplyBinlogEvent() error = %v, wantErr %v", err, tt.wantErr)
 			}
 		})
@@ -341,7 +402,8 @@ func TestApplier_ID(t *testing.T) {
 
 func TestApplier_onError(t *testing.T) {
 	type args struct {
-		err error
+		state int
+		err   error
 	}
 	tests := []struct {
 		name string
@@ -352,7 +414,7 @@ func TestApplier_onError(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			tt.a.onError(tt.args.err)
+			tt.a.onError(tt.args.state, tt.args.err)
 		})
 	}
 }
@@ -391,131 +453,17 @@ func TestApplier_Shutdown(t *testing.T) {
 	}
 }
 
-func TestApplier_onApplyTxStructWithSetGtid(t *testing.T) {
-	type fields struct {
-		logger                  *log.Entry
-		subject                 string
-		tp                      string
-		mysqlContext            *config.MySQLDriverConfig
-		dbs                     []*sql.DB
-		singletonDB             *gosql.DB
-		totalRowCount           int
-		applyRowCount           int
-		rowCopyComplete         chan bool
-		rowCopyCompleteFlag     int64
-		copyRowsQueue           chan *DumpEntry
-		applyDataEntryQueue     chan *binlog.BinlogEntry
-		applyBinlogTxQueue      chan *binlog.BinlogTx
-		applyBinlogGroupTxQueue chan []*binlog.BinlogTx
-		lastAppliedBinlogTx     *binlog.BinlogTx
-		natsConn                *gonats.Conn
-		waitCh                  chan *models.WaitResult
-		wg                      sync.WaitGroup
-		shutdown                bool
-		shutdownCh              chan struct{}
-		shutdownLock            sync.Mutex
-	}
-	type args struct {
-		dbApplier *sql.DB
-		binlogTx  *binlog.BinlogTx
-	}
-	tests := []struct {
-		name    string
-		fields  fields
-		args    args
-		wantErr bool
-	}{
-		// TODO: Add test cases.
-	}
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			a := &Applier{
-				logger:                  tt.fields.logger,
-				subject:                 tt.fields.subject,
-				tp:                      tt.fields.tp,
-				mysqlContext:            tt.fields.mysqlContext,
-				dbs:                     tt.fields.dbs,
-				singletonDB:             tt.fields.singletonDB,
-				totalRowCount:           tt.fields.totalRowCount,
-				applyRowCount:           tt.fields.applyRowCount,
-				rowCopyComplete:         tt.fields.rowCopyComplete,
-				rowCopyCompleteFlag:     tt.fields.rowCopyCompleteFlag,
-				copyRowsQueue:           tt.fields.copyRowsQueue,
-				applyDataEntryQueue:     tt.fields.applyDataEntryQueue,
-				applyBinlogTxQueue:      tt.fields.applyBinlogTxQueue,
-				applyBinlogGroupTxQueue: tt.fields.applyBinlogGroupTxQueue,
-				lastAppliedBinlogTx:     tt.fields.lastAppliedBinlogTx,
-				natsConn:                tt.fields.natsConn,
-				waitCh:                  tt.fields.waitCh,
-				wg:                      tt.fields.wg,
-				shutdown:                tt.fields.shutdown,
-				shutdownCh:              tt.fields.shutdownCh,
-				shutdownLock:            tt.fields.shutdownLock,
-			}
-			if err := a.onApplyTxStructWithSetGtid(tt.args.dbApplier, tt.args.binlogTx); (err != nil) != tt.wantErr {
-				t.Errorf("Applier.onApplyTxStructWithSetGtid() error = %v, wantErr %v", err, tt.wantErr)
-			}
-		})
-	}
-}
-
 func TestApplier_validateGrants(t *testing.T) {
-	type fields struct {
-		logger                  *log.Entry
-		subject                 string
-		tp                      string
-		mysqlContext            *config.MySQLDriverConfig
-		dbs                     []*sql.DB
-		singletonDB             *gosql.DB
-		totalRowCount           int
-		applyRowCount           int
-		rowCopyComplete         chan bool
-		rowCopyCompleteFlag     int64
-		copyRowsQueue           chan *DumpEntry
-		applyDataEntryQueue     chan *binlog.BinlogEntry
-		applyBinlogTxQueue      chan *binlog.BinlogTx
-		applyBinlogGroupTxQueue chan []*binlog.BinlogTx
-		lastAppliedBinlogTx     *binlog.BinlogTx
-		natsConn                *gonats.Conn
-		waitCh                  chan *models.WaitResult
-		wg                      sync.WaitGroup
-		shutdown                bool
-		shutdownCh              chan struct{}
-		shutdownLock            sync.Mutex
-	}
 	tests := []struct {
 		name    string
-		fields  fields
+		a       *Applier
 		wantErr bool
 	}{
 		// TODO: Add test cases.
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			a := &Applier{
-				logger:                  tt.fields.logger,
-				subject:                 tt.fields.subject,
-				tp:                      tt.fields.tp,
-				mysqlContext:            tt.fields.mysqlContext,
-				dbs:                     tt.fields.dbs,
-				singletonDB:             tt.fields.singletonDB,
-				totalRowCount:           tt.fields.totalRowCount,
-				applyRowCount:           tt.fields.applyRowCount,
-				rowCopyComplete:         tt.fields.rowCopyComplete,
-				rowCopyCompleteFlag:     tt.fields.rowCopyCompleteFlag,
-				copyRowsQueue:           tt.fields.copyRowsQueue,
-				applyDataEntryQueue:     tt.fields.applyDataEntryQueue,
-				applyBinlogTxQueue:      tt.fields.applyBinlogTxQueue,
-				applyBinlogGroupTxQueue: tt.fields.applyBinlogGroupTxQueue,
-				lastAppliedBinlogTx:     tt.fields.lastAppliedBinlogTx,
-				natsConn:                tt.fields.natsConn,
-				waitCh:                  tt.fields.waitCh,
-				wg:                      tt.fields.wg,
-				shutdown:                tt.fields.shutdown,
-				shutdownCh:              tt.fields.shutdownCh,
-				shutdownLock:            tt.fields.shutdownLock,
-			}
-			if err := a.validateGrants(); (err != nil) != tt.wantErr {
+			if err := tt.a.validateGrants(); (err != nil) != tt.wantErr {
 				t.Errorf("Applier.validateGrants() error = %v, wantErr %v", err, tt.wantErr)
 			}
 		})
@@ -524,7 +472,7 @@ func TestApplier_validateGrants(t *testing.T) {
 
 func TestApplier_onApplyTxStructWithSuper(t *testing.T) {
 	type args struct {
-		dbApplier *sql.DB
+		dbApplier *sql.Conn
 		binlogTx  *binlog.BinlogTx
 	}
 	tests := []struct {
@@ -543,17 +491,3 @@ func TestApplier_onApplyTxStructWithSuper(t *testing.T) {
 		})
 	}
 }
-
-func TestApplier_Test008ProvidesFlowControlToThrottleOverSending(t *testing.T) {
-	tests := []struct {
-		name string
-		a    *Applier
-	}{
-		// TODO: Add test cases.
-	}
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			tt.a.Test008ProvidesFlowControlToThrottleOverSending()
-		})
-	}
-}
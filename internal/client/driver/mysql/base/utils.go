@@ -97,6 +97,9 @@ func GetTableColumns(db usql.QueryAble, databaseName, tableName string) (*umconf
 			Default:    rowMap.GetString("Default"),
 			Key:        strings.ToUpper(rowMap.GetString("Key")),
 			Nullable:   strings.ToUpper(rowMap.GetString("Null")) == "YES",
+			// "VIRTUAL GENERATED" or "STORED GENERATED", but not the
+			// "DEFAULT_GENERATED" of expression defaults
+			IsGenerated: strings.Contains(strings.ToUpper(rowMap.GetString("Extra")), " GENERATED"),
 		})
 		return nil
 	})
@@ -124,15 +127,17 @@ func ShowCreateTable(db *gosql.DB, databaseName, tableName string, dropTableIfEx
 	return statement, err
 }
 
-func ShowCreateView(db *gosql.DB, databaseName, tableName string, dropTableIfExists bool) (createTableStatement string, err error) {
-	var dummy, character_set_client, collation_connection string
-	query := fmt.Sprintf(`show create table %s.%s`, usql.EscapeName(databaseName), usql.EscapeName(tableName))
-	err = db.QueryRow(query).Scan(&dummy, &createTableStatement, &character_set_client, &collation_connection)
-	statement := fmt.Sprintf("USE %s", databaseName)
+// ShowCreateView returns the statements creating a view
+func ShowCreateView(db *gosql.DB, databaseName, tableName string, dropTableIfExists bool) (statement []string, err error) {
+	var dummy, createViewStatement, characterSetClient, collationConnection string
+	query := fmt.Sprintf(`show create view %s.%s`, usql.EscapeName(databaseName), usql.EscapeName(tableName))
+	err = db.QueryRow(query).Scan(&dummy, &createViewStatement, &characterSetClient, &collationConnection)
+	statement = append(statement, fmt.Sprintf("USE %s", databaseName))
 	if dropTableIfExists {
-		statement = fmt.Sprintf("%s;DROP TABLE IF EXISTS `%s`", statement, tableName)
+		statement = append(statement, fmt.Sprintf("DROP VIEW IF EXISTS `%s`", tableName))
 	}
-	return fmt.Sprintf("%s;%s", statement, createTableStatement), err
+	statement = append(statement, createViewStatement)
+	return statement, err
 }
 
 // Interval is [start, stop), but the GTID string's format is [n] or [n1-n2], closed interval
@@ -8,18 +8,81 @@ package binlog
 
 import (
 	"fmt"
+	"sync"
+	"time"
 
 	"github.com/actiontech/dtle/internal/client/driver/mysql/base"
+	"github.com/actiontech/dtle/internal/models"
 )
 
 type BinlogEntries struct {
 	Entries []*BinlogEntry
+
+	// Heartbeat is set on the entries sent when a heartbeat is due
+	Heartbeat *Heartbeat
+}
+
+// Heartbeat is sent by the extractor at a fixed interval, in order with the
+// binlog entries. A receiver getting heartbeats but no entries is seeing an
+// idle source, while missing heartbeats mean the replication is stuck.
+type Heartbeat struct {
+	// Time is the unix nano time the heartbeat was sent at
+	Time int64
+
+	// ReadTime is the unix nano time the binlog reader last received an
+	// event from the source, including the heartbeats of the source itself
+	ReadTime int64
+
+	// Gtid is the last transaction read from the source
+	Gtid string
+}
+
+// HeartbeatMonitor keeps the last heartbeat sent or received by a task
+type HeartbeatMonitor struct {
+	mutex      sync.Mutex
+	last       *Heartbeat
+	observedAt time.Time
+}
+
+// Observe records a heartbeat
+func (m *HeartbeatMonitor) Observe(h *Heartbeat) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.last = h
+	m.observedAt = time.Now()
+}
+
+// Stat returns the stat of the last heartbeat, or nil if there was none
+func (m *HeartbeatMonitor) Stat() *models.HeartbeatStat {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if m.last == nil {
+		return nil
+	}
+	return &models.HeartbeatStat{
+		Time: m.observedAt.UnixNano(),
+		Age:  int64(time.Since(m.observedAt) / time.Millisecond),
+		Lag:  int64(time.Duration(m.observedAt.UnixNano()-m.last.ReadTime) / time.Millisecond),
+		Gtid: m.last.Gtid,
+	}
 }
 
 // BinlogEntry describes an entry in the binary log
 type BinlogEntry struct {
 	hasBeginQuery bool
 	Coordinates   base.BinlogCoordinateTx
+	// Timestamp is the time the transaction was committed on the source,
+	// in unix seconds
+	Timestamp uint32
+	// ReadTime is the unix nano time the transaction was read from the
+	// binlog of the source, on the clock of the extractor
+	ReadTime int64
+	// SourceDelay is the nanoseconds from the transaction being read to it
+	// being received by the applier, as timed by the extractor
+	SourceDelay int64
+	// ReceiveTime is the unix nano time the applier received the
+	// transaction, on its own clock
+	ReceiveTime int64
 
 	Events       []DataEvent
 	OriginalSize int // size of binlog entry
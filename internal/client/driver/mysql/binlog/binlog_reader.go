@@ -14,14 +14,18 @@ import (
 
 	//"encoding/hex"
 	"fmt"
+	"net"
 	"regexp"
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	//"os"
 
 	"github.com/issuj/gofaster/base64"
+	"github.com/juju/errors"
 	"github.com/pingcap/tidb/ast"
 	"github.com/pingcap/tidb/parser"
 	"github.com/satori/go.uuid"
@@ -45,8 +49,10 @@ type BinlogReader struct {
 	logger                   *log.Entry
 	connectionConfig         *mysql.ConnectionConfig
 	db                       *gosql.DB
+	binlogSyncerConfig       replication.BinlogSyncerConfig
 	binlogSyncer             *replication.BinlogSyncer
 	binlogStreamer           *replication.BinlogStreamer
+	partialRows              *partialRowsDecoder
 	currentCoordinates       base.BinlogCoordinateTx
 	currentCoordinatesMutex  *sync.Mutex
 	LastAppliedRowsEventHint base.BinlogCoordinateTx
@@ -70,6 +76,22 @@ type BinlogReader struct {
 	shutdownLock sync.Mutex
 
 	sqlFilter    *SqlFilter
+
+	// lastEventTime is the unix nano time the last event was received at
+	lastEventTime int64
+
+	// committedGtidSet is the GTID set of the transactions read up to their
+	// commit, which is where the reading resumes after a failover
+	committedGtidSet string
+
+	txControlStat models.TxControlStat
+
+	// tableWatermarks is the GTID set each table was copied at, for the
+	// tables copied in snapshots of their own
+	tableWatermarks map[string]*gomysql.MysqlGTIDSet
+
+	// rawEventWriter is written the events read, for the binlog server
+	rawEventWriter RawEventWriter
 }
 
 type SqlFilter struct {
@@ -138,37 +160,73 @@ func NewMySQLReader(cfg *config.MySQLDriverConfig, logger *log.Entry, replicateD
 		return nil, err
 	}
 
-	id, err := util.NewIdWorker(2, 3, util.SnsEpoch)
+	// support regex
+	binlogReader.genRegexMap()
+
+	binlogReader.binlogSyncerConfig, err = newBinlogSyncerConfig(cfg, logger)
 	if err != nil {
 		return nil, err
 	}
+	binlogReader.binlogSyncer = replication.NewBinlogSyncer(binlogReader.binlogSyncerConfig)
+	binlogReader.partialRows = newPartialRowsDecoder(binlogReader.binlogSyncerConfig)
+	binlogReader.mysqlContext.Stage = models.StageRegisteringSlaveOnMaster
+
+	return binlogReader, err
+}
+
+// newBinlogSyncerConfig returns the config of a syncer reading the binlog of
+// the source, under a server_id of its own
+func newBinlogSyncerConfig(cfg *config.MySQLDriverConfig, logger *log.Entry) (replication.BinlogSyncerConfig, error) {
+	id, err := util.NewIdWorker(2, 3, util.SnsEpoch)
+	if err != nil {
+		return replication.BinlogSyncerConfig{}, err
+	}
 	sid, err := id.NextId()
 	if err != nil {
-		return nil, err
+		return replication.BinlogSyncerConfig{}, err
 	}
 	bid := []byte(strconv.FormatUint(uint64(sid), 10))
 	serverId, err := strconv.ParseUint(string(bid), 10, 32)
 	if err != nil {
-		return nil, err
+		return replication.BinlogSyncerConfig{}, err
 	}
 	logger.Debug("job.start: debug server id is :", serverId)
-	// support regex
-	binlogReader.genRegexMap()
-
-	binlogSyncerConfig := replication.BinlogSyncerConfig{
+	// Connect to the local end of the tunnel if there is one
+	host, portStr, err := net.SplitHostPort(cfg.ConnectionConfig.DialAddress())
+	if err != nil {
+		return replication.BinlogSyncerConfig{}, err
+	}
+	port, err := strconv.Atoi(portStr)
+	if err != nil {
+		return replication.BinlogSyncerConfig{}, err
+	}
+	syncerConfig := replication.BinlogSyncerConfig{
 		ServerID:       uint32(serverId),
 		Flavor:         "mysql",
-		Host:           cfg.ConnectionConfig.Host,
-		Port:           uint16(cfg.ConnectionConfig.Port),
+		Host:           host,
+		Port:           uint16(port),
+		TLSConfig:      cfg.ConnectionConfig.TLSClientConfig(),
 		User:           cfg.ConnectionConfig.User,
 		Password:       cfg.ConnectionConfig.Password,
 		RawModeEnabled: false,
 		UseDecimal:     true,
+		// Ask the source for heartbeats while it is idle, so that a silent
+		// connection can be told from an idle source.
+		HeartbeatPeriod: time.Duration(cfg.HeartbeatInterval) * time.Millisecond,
+		// Give up on a lost source after a few attempts, so that the
+		// extractor can fail over to another one.
+		MaxReconnectAttempts: int(cfg.MaxRetries),
+		// Check the CRC32 of the events when binlog_checksum=CRC32, rather
+		// than parsing corrupted ones
+		VerifyChecksum: true,
+		// Format TIMESTAMP values in UTC rather than in the zone of the agent.
+		// The applier and Kafka convert them to their own time zone.
+		TimestampStringLocation: time.UTC,
 	}
-	binlogReader.binlogSyncer = replication.NewBinlogSyncer(binlogSyncerConfig)
-	binlogReader.mysqlContext.Stage = models.StageRegisteringSlaveOnMaster
-
-	return binlogReader, err
+	if cfg.HeartbeatInterval > 0 {
+		syncerConfig.ReadTimeout = 3 * syncerConfig.HeartbeatPeriod
+	}
+	return syncerConfig, nil
 }
 
 func (b *BinlogReader) getDbTableMap(schemaName string) map[string]*config.TableContext {
@@ -204,6 +262,7 @@ func (b *BinlogReader) ConnectBinlogStreamer(coordinates base.BinlogCoordinatesX
 		LogFile: coordinates.LogFile,
 		LogPos:  coordinates.LogPos,
 	}
+	b.committedGtidSet = coordinates.GtidSet
 
 	b.logger.Printf("mysql.reader: Connecting binlog streamer at %+v", coordinates)
 
@@ -222,6 +281,82 @@ func (b *BinlogReader) ConnectBinlogStreamer(coordinates base.BinlogCoordinatesX
 	return err
 }
 
+// CommittedGtidSet returns the GTID set of the transactions read up to their
+// commit
+func (b *BinlogReader) CommittedGtidSet() string {
+	b.currentCoordinatesMutex.Lock()
+	defer b.currentCoordinatesMutex.Unlock()
+	return b.committedGtidSet
+}
+
+// updateCommittedGtidSet records the GTID set of a transaction once its
+// commit has been handled
+func (b *BinlogReader) updateCommittedGtidSet(ev *replication.BinlogEvent) {
+	var gset gomysql.GTIDSet
+	switch evt := ev.Event.(type) {
+	case *replication.XIDEvent:
+		gset = evt.GSet
+	case *replication.QueryEvent:
+		// BEGIN starts a transaction, other queries are DDLs committing
+		// on their own, or commit XA transactions
+		query := string(evt.Query)
+		if strings.ToUpper(strings.TrimSpace(query)) == "BEGIN" {
+			return
+		}
+		switch txControlStatement(query) {
+		case txControlXAStart, txControlXAEnd, txControlSavepoint:
+			return
+		}
+		gset = evt.GSet
+	}
+	if gset == nil {
+		return
+	}
+	b.currentCoordinatesMutex.Lock()
+	defer b.currentCoordinatesMutex.Unlock()
+	b.committedGtidSet = gset.String()
+}
+
+// ChecksumError is returned when a binlog event fails its CRC32 checksum
+type ChecksumError struct {
+	LogFile string
+	// LogPos is the position the event starts at
+	LogPos int64
+}
+
+func (e *ChecksumError) Error() string {
+	return fmt.Sprintf("binlog event at %s:%d fails its CRC32 checksum: the binlog or the connection to the source is corrupted",
+		e.LogFile, e.LogPos)
+}
+
+// countEvent counts the bytes of an event in the traffic of the source, as
+// the connection of the binlog syncer can not be counted
+func (b *BinlogReader) countEvent(ev *replication.BinlogEvent) {
+	if traffic := b.mysqlContext.ConnectionConfig.Traffic(); traffic != nil {
+		traffic.AddIn(int64(ev.Header.EventSize))
+	}
+}
+
+// checksumError returns a ChecksumError for a checksum mismatch of the
+// event following the last one read, and other errors as they are
+func (b *BinlogReader) checksumError(err error) error {
+	if errors.Cause(err) != replication.ErrChecksumMismatch {
+		return err
+	}
+	b.currentCoordinatesMutex.Lock()
+	defer b.currentCoordinatesMutex.Unlock()
+	return &ChecksumError{
+		LogFile: b.currentCoordinates.LogFile,
+		LogPos:  b.currentCoordinates.LogPos,
+	}
+}
+
+// LastEventTime returns the unix nano time the last event, including the
+// heartbeats of the source, was received at
+func (b *BinlogReader) LastEventTime() int64 {
+	return atomic.LoadInt64(&b.lastEventTime)
+}
+
 func (b *BinlogReader) GetCurrentBinlogCoordinates() *base.BinlogCoordinateTx {
 	b.currentCoordinatesMutex.Lock()
 	defer b.currentCoordinatesMutex.Unlock()
@@ -306,12 +441,18 @@ func (b *BinlogReader) handleEvent(ev *replication.BinlogEvent, entriesChannel c
 		b.currentCoordinates.LastCommitted = evt.LastCommitted
 		b.currentCoordinates.SeqenceNumber = evt.SequenceNumber
 		b.currentBinlogEntry = NewBinlogEntryAt(b.currentCoordinates)
+		b.currentBinlogEntry.Timestamp = ev.Header.Timestamp
+		b.currentBinlogEntry.ReadTime = time.Now().UnixNano()
 	case replication.QUERY_EVENT:
 		evt := ev.Event.(*replication.QueryEvent)
 		query := string(evt.Query)
 
 		b.logger.Debugf("mysql.reader: query event: schema: %s, query: %s", evt.Schema, query)
 
+		if b.handleTxControlQuery(query, entriesChannel) {
+			return nil
+		}
+
 		if strings.ToUpper(query) == "BEGIN" {
 			b.currentBinlogEntry.hasBeginQuery = true
 		} else {
@@ -368,6 +509,7 @@ func (b *BinlogReader) handleEvent(ev *replication.BinlogEvent, entriesChannel c
 						return nil
 					}
 
+					var tableColumns *mysql.ColumnList
 					switch ddlInfo.ddlType {
 					case DDLCreateTable, DDLAlterTable:
 						// create table is not ignored
@@ -375,6 +517,8 @@ func (b *BinlogReader) handleEvent(ev *replication.BinlogEvent, entriesChannel c
 						columns, err := base.GetTableColumns(b.db, realSchema, tableName)
 						if err != nil {
 							b.logger.Warnf("error handle create table in binlog: GetTableColumns: %v", err.Error())
+						} else {
+							tableColumns = columns
 						}
 						err = base.ApplyColumnTypes(b.db, realSchema, tableName, columns)
 						if err != nil {
@@ -402,7 +546,7 @@ func (b *BinlogReader) handleEvent(ev *replication.BinlogEvent, entriesChannel c
 						tableMap := b.getDbTableMap(realSchema)
 						err = b.addTableToTableMap(tableMap, table)
 						if err != nil {
-							b.logger.Error("failed to make table context: %v", err)
+							b.logger.Errorf("failed to make table context: %v", err)
 							return err
 						}
 					}
@@ -413,6 +557,7 @@ func (b *BinlogReader) handleEvent(ev *replication.BinlogEvent, entriesChannel c
 						NotDML,
 						ddlInfo.tables[i],
 					)
+					event.TableColumns = tableColumns
 					b.currentBinlogEntry.Events = append(b.currentBinlogEntry.Events, event)
 				}
 				entriesChannel <- b.currentBinlogEntry
@@ -422,6 +567,8 @@ func (b *BinlogReader) handleEvent(ev *replication.BinlogEvent, entriesChannel c
 	case replication.XID_EVENT:
 		entriesChannel <- b.currentBinlogEntry
 		b.LastAppliedRowsEventHint = b.currentCoordinates
+	case xaPrepareLogEvent:
+		b.handleXAPrepare(entriesChannel)
 	default:
 		if rowsEvent, ok := ev.Event.(*replication.RowsEvent); ok {
 			dml := ToEventDML(ev.Header.EventType)
@@ -433,6 +580,10 @@ func (b *BinlogReader) handleEvent(ev *replication.BinlogEvent, entriesChannel c
 
 			schemaName := string(rowsEvent.Table.Schema)
 			tableName := string(rowsEvent.Table.Table)
+			if b.inTableWatermark(schemaName, tableName) {
+				b.logger.Debugf("mysql.reader: skip rowsEvent %s.%s %v, in the copy of the table", schemaName, tableName, b.currentCoordinates.GNO)
+				return nil
+			}
 
 			if b.sqlFilter.NoDML ||
 				(b.sqlFilter.NoDMLDelete && dml == DeleteDML) ||
@@ -481,6 +632,9 @@ func (b *BinlogReader) handleEvent(ev *replication.BinlogEvent, entriesChannel c
 					{
 						dmlEvent.WhereColumnValues = ToColumnValuesV2(row, table)
 						dmlEvent.NewColumnValues = ToColumnValuesV2(rowsEvent.Rows[i+1], table)
+						if err := b.resolvePartialJSON(&dmlEvent); err != nil {
+							return err
+						}
 					}
 				case DeleteDML:
 					{
@@ -548,6 +702,14 @@ func (b *BinlogReader) DataStreamEvents(entriesChannel chan<- *BinlogEntry) erro
 
 		ev, err := b.binlogStreamer.GetEvent(context.Background())
 		if err != nil {
+			return b.checksumError(err)
+		}
+		atomic.StoreInt64(&b.lastEventTime, time.Now().UnixNano())
+		b.countEvent(ev)
+		if err := b.writeRawEvent(ev); err != nil {
+			return err
+		}
+		if err := b.partialRows.observe(ev); err != nil {
 			return err
 		}
 		if ev.Header.EventType == replication.HEARTBEAT_EVENT {
@@ -577,6 +739,7 @@ func (b *BinlogReader) DataStreamEvents(entriesChannel chan<- *BinlogEntry) erro
 			if err := b.handleEvent(ev, entriesChannel); err != nil {
 				return err
 			}
+			b.updateCommittedGtidSet(ev)
 		}
 	}
 
@@ -592,6 +755,14 @@ func (b *BinlogReader) BinlogStreamEvents(txChannel chan<- *BinlogTx) error {
 
 		ev, err := b.binlogStreamer.GetEvent(context.Background())
 		if err != nil {
+			return b.checksumError(err)
+		}
+		atomic.StoreInt64(&b.lastEventTime, time.Now().UnixNano())
+		b.countEvent(ev)
+		if err := b.writeRawEvent(ev); err != nil {
+			return err
+		}
+		if err := b.partialRows.observe(ev); err != nil {
 			return err
 		}
 
@@ -621,7 +792,8 @@ func (b *BinlogReader) BinlogStreamEvents(txChannel chan<- *BinlogTx) error {
 			//b.logger.Printf( "Status vars: \n%s", hex.Dump(e.StatusVars))
 			b.logger.Debugf("mysql.reader: Schema: %s", evt.Schema)
 			b.logger.Debugf("mysql.reader: Query: %s", evt.Query)
-		case replication.WRITE_ROWS_EVENTv2, replication.UPDATE_ROWS_EVENTv2, replication.DELETE_ROWS_EVENTv2:
+		case replication.WRITE_ROWS_EVENTv2, replication.UPDATE_ROWS_EVENTv2, replication.DELETE_ROWS_EVENTv2,
+			partialUpdateRowsEvent:
 			evt := ev.Event.(*replication.RowsEvent)
 			b.logger.Debugf("mysql.reader: TableID: %d", evt.TableID)
 			b.logger.Debugf("mysql.reader: Flags: %d", evt.Flags)
@@ -814,7 +986,8 @@ func (b *BinlogReader) handleBinlogRowsEvent(ev *replication.BinlogEvent, txChan
 		})
 		//tb.addCount(Insert)
 
-	case replication.UPDATE_ROWS_EVENTv0, replication.UPDATE_ROWS_EVENTv1, replication.UPDATE_ROWS_EVENTv2:
+	case replication.UPDATE_ROWS_EVENTv0, replication.UPDATE_ROWS_EVENTv1, replication.UPDATE_ROWS_EVENTv2,
+		partialUpdateRowsEvent:
 		evt := ev.Event.(*replication.RowsEvent)
 		if b.skipEvent(string(evt.Table.Schema), string(evt.Table.Table)) {
 			//b.logger.Debugf("mysql.reader: skip RowsEvent at schema: %s,table: %s", fmt.Sprintf("%s", evt.Table.Schema), fmt.Sprintf("%s", evt.Table.Table))
@@ -13,6 +13,7 @@ import (
 	"regexp"
 	"sync"
 	"testing"
+
 	"github.com/actiontech/dtle/internal/client/driver/mysql/base"
 	"github.com/actiontech/dtle/internal/config"
 	"github.com/actiontech/dtle/internal/config/mysql"
@@ -56,8 +57,9 @@ func TestParseMysqlGTIDSet(t *testing.T) {
 
 func TestNewMySQLReader(t *testing.T) {
 	type args struct {
-		cfg    *config.MySQLDriverConfig
-		logger *log.Entry
+		cfg           *config.MySQLDriverConfig
+		logger        *log.Entry
+		replicateDoDb []*config.DataSource
 	}
 	tests := []struct {
 		name             string
@@ -69,7 +71,7 @@ func TestNewMySQLReader(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			gotBinlogReader, err := NewMySQLReader(tt.args.cfg, tt.args.logger)
+			gotBinlogReader, err := NewMySQLReader(tt.args.cfg, tt.args.logger, tt.args.replicateDoDb)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("NewMySQLReader() error = %v, wantErr %v", err, tt.wantErr)
 				return
@@ -100,13 +102,11 @@ func TestBinlogReader_ConnectBinlogStreamer(t *testing.T) {
 		currentSqlB64            *bytes.Buffer
 		appendB64SqlBs           []byte
 		ReMap                    map[string]*regexp.Regexp
-		wg                       sync.WaitGroup
 		shutdown                 bool
 		shutdownCh               chan struct{}
-		shutdownLock             sync.Mutex
 	}
 	type args struct {
-		coordinates base.BinlogCoordinateTx
+		coordinates base.BinlogCoordinatesX
 	}
 	tests := []struct {
 		name    string
@@ -127,7 +127,7 @@ func TestBinlogReader_ConnectBinlogStreamer(t *testing.T) {
 				currentCoordinates:       tt.fields.currentCoordinates,
 				currentCoordinatesMutex:  tt.fields.currentCoordinatesMutex,
 				LastAppliedRowsEventHint: tt.fields.LastAppliedRowsEventHint,
-				MysqlContext:             tt.fields.MysqlContext,
+				mysqlContext:             tt.fields.MysqlContext,
 				currentTx:                tt.fields.currentTx,
 				currentBinlogEntry:       tt.fields.currentBinlogEntry,
 				txCount:                  tt.fields.txCount,
@@ -136,10 +136,8 @@ func TestBinlogReader_ConnectBinlogStreamer(t *testing.T) {
 				currentSqlB64:            tt.fields.currentSqlB64,
 				appendB64SqlBs:           tt.fields.appendB64SqlBs,
 				ReMap:                    tt.fields.ReMap,
-				wg:                       tt.fields.wg,
 				shutdown:                 tt.fields.shutdown,
 				shutdownCh:               tt.fields.shutdownCh,
-				shutdownLock:             tt.fields.shutdownLock,
 			}
 			if err := b.ConnectBinlogStreamer(tt.args.coordinates); (err != nil) != tt.wantErr {
 				t.Errorf("BinlogReader.ConnectBinlogStreamer() error = %v, wantErr %v", err, tt.wantErr)
@@ -167,10 +165,8 @@ func TestBinlogReader_GetCurrentBinlogCoordinates(t *testing.T) {
 		currentSqlB64            *bytes.Buffer
 		appendB64SqlBs           []byte
 		ReMap                    map[string]*regexp.Regexp
-		wg                       sync.WaitGroup
 		shutdown                 bool
 		shutdownCh               chan struct{}
-		shutdownLock             sync.Mutex
 	}
 	tests := []struct {
 		name   string
@@ -190,7 +186,7 @@ func TestBinlogReader_GetCurrentBinlogCoordinates(t *testing.T) {
 				currentCoordinates:       tt.fields.currentCoordinates,
 				currentCoordinatesMutex:  tt.fields.currentCoordinatesMutex,
 				LastAppliedRowsEventHint: tt.fields.LastAppliedRowsEventHint,
-				MysqlContext:             tt.fields.MysqlContext,
+				mysqlContext:             tt.fields.MysqlContext,
 				currentTx:                tt.fields.currentTx,
 				currentBinlogEntry:       tt.fields.currentBinlogEntry,
 				txCount:                  tt.fields.txCount,
@@ -199,10 +195,8 @@ func TestBinlogReader_GetCurrentBinlogCoordinates(t *testing.T) {
 				currentSqlB64:            tt.fields.currentSqlB64,
 				appendB64SqlBs:           tt.fields.appendB64SqlBs,
 				ReMap:                    tt.fields.ReMap,
-				wg:                       tt.fields.wg,
 				shutdown:                 tt.fields.shutdown,
 				shutdownCh:               tt.fields.shutdownCh,
-				shutdownLock:             tt.fields.shutdownLock,
 			}
 			if got := b.GetCurrentBinlogCoordinates(); !reflect.DeepEqual(got, tt.want) {
 				t.Errorf("BinlogReader.GetCurrentBinlogCoordinates() = %v, want %v", got, tt.want)
@@ -230,10 +224,8 @@ func TestBinlogReader_handleRowsEvent(t *testing.T) {
 		currentSqlB64            *bytes.Buffer
 		appendB64SqlBs           []byte
 		ReMap                    map[string]*regexp.Regexp
-		wg                       sync.WaitGroup
 		shutdown                 bool
 		shutdownCh               chan struct{}
-		shutdownLock             sync.Mutex
 	}
 	type args struct {
 		ev             *replication.BinlogEvent
@@ -258,7 +250,7 @@ func TestBinlogReader_handleRowsEvent(t *testing.T) {
 				currentCoordinates:       tt.fields.currentCoordinates,
 				currentCoordinatesMutex:  tt.fields.currentCoordinatesMutex,
 				LastAppliedRowsEventHint: tt.fields.LastAppliedRowsEventHint,
-				MysqlContext:             tt.fields.MysqlContext,
+				mysqlContext:             tt.fields.MysqlContext,
 				currentTx:                tt.fields.currentTx,
 				currentBinlogEntry:       tt.fields.currentBinlogEntry,
 				txCount:                  tt.fields.txCount,
@@ -267,10 +259,8 @@ func TestBinlogReader_handleRowsEvent(t *testing.T) {
 				currentSqlB64:            tt.fields.currentSqlB64,
 				appendB64SqlBs:           tt.fields.appendB64SqlBs,
 				ReMap:                    tt.fields.ReMap,
-				wg:                       tt.fields.wg,
 				shutdown:                 tt.fields.shutdown,
 				shutdownCh:               tt.fields.shutdownCh,
-				shutdownLock:             tt.fields.shutdownLock,
 			}
 			if err := b.handleEvent(tt.args.ev, tt.args.entriesChannel); (err != nil) != tt.wantErr {
 				t.Errorf("BinlogReader.handleRowsEvent() error = %v, wantErr %v", err, tt.wantErr)
@@ -298,10 +288,8 @@ func TestBinlogReader_DataStreamEvents(t *testing.T) {
 		currentSqlB64            *bytes.Buffer
 		appendB64SqlBs           []byte
 		ReMap                    map[string]*regexp.Regexp
-		wg                       sync.WaitGroup
 		shutdown                 bool
 		shutdownCh               chan struct{}
-		shutdownLock             sync.Mutex
 	}
 	type args struct {
 		entriesChannel chan<- *BinlogEntry
@@ -325,7 +313,7 @@ func TestBinlogReader_DataStreamEvents(t *testing.T) {
 				currentCoordinates:       tt.fields.currentCoordinates,
 				currentCoordinatesMutex:  tt.fields.currentCoordinatesMutex,
 				LastAppliedRowsEventHint: tt.fields.LastAppliedRowsEventHint,
-				MysqlContext:             tt.fields.MysqlContext,
+				mysqlContext:             tt.fields.MysqlContext,
 				currentTx:                tt.fields.currentTx,
 				currentBinlogEntry:       tt.fields.currentBinlogEntry,
 				txCount:                  tt.fields.txCount,
@@ -334,10 +322,8 @@ func TestBinlogReader_DataStreamEvents(t *testing.T) {
 				currentSqlB64:            tt.fields.currentSqlB64,
 				appendB64SqlBs:           tt.fields.appendB64SqlBs,
 				ReMap:                    tt.fields.ReMap,
-				wg:                       tt.fields.wg,
 				shutdown:                 tt.fields.shutdown,
 				shutdownCh:               tt.fields.shutdownCh,
-				shutdownLock:             tt.fields.shutdownLock,
 			}
 			if err := b.DataStreamEvents(tt.args.entriesChannel); (err != nil) != tt.wantErr {
 				t.Errorf("BinlogReader.DataStreamEvents() error = %v, wantErr %v", err, tt.wantErr)
@@ -365,10 +351,8 @@ func TestBinlogReader_BinlogStreamEvents(t *testing.T) {
 		currentSqlB64            *bytes.Buffer
 		appendB64SqlBs           []byte
 		ReMap                    map[string]*regexp.Regexp
-		wg                       sync.WaitGroup
 		shutdown                 bool
 		shutdownCh               chan struct{}
-		shutdownLock             sync.Mutex
 	}
 	type args struct {
 		txChannel chan<- *BinlogTx
@@ -392,7 +376,7 @@ func TestBinlogReader_BinlogStreamEvents(t *testing.T) {
 				currentCoordinates:       tt.fields.currentCoordinates,
 				currentCoordinatesMutex:  tt.fields.currentCoordinatesMutex,
 				LastAppliedRowsEventHint: tt.fields.LastAppliedRowsEventHint,
-				MysqlContext:             tt.fields.MysqlContext,
+				mysqlContext:             tt.fields.MysqlContext,
 				currentTx:                tt.fields.currentTx,
 				currentBinlogEntry:       tt.fields.currentBinlogEntry,
 				txCount:                  tt.fields.txCount,
@@ -401,10 +385,8 @@ func TestBinlogReader_BinlogStreamEvents(t *testing.T) {
 				currentSqlB64:            tt.fields.currentSqlB64,
 				appendB64SqlBs:           tt.fields.appendB64SqlBs,
 				ReMap:                    tt.fields.ReMap,
-				wg:                       tt.fields.wg,
 				shutdown:                 tt.fields.shutdown,
 				shutdownCh:               tt.fields.shutdownCh,
-				shutdownLock:             tt.fields.shutdownLock,
 			}
 			if err := b.BinlogStreamEvents(tt.args.txChannel); (err != nil) != tt.wantErr {
 				t.Errorf("BinlogReader.BinlogStreamEvents() error = %v, wantErr %v", err, tt.wantErr)
@@ -432,10 +414,8 @@ func TestBinlogReader_handleBinlogRowsEvent(t *testing.T) {
 		currentSqlB64            *bytes.Buffer
 		appendB64SqlBs           []byte
 		ReMap                    map[string]*regexp.Regexp
-		wg                       sync.WaitGroup
 		shutdown                 bool
 		shutdownCh               chan struct{}
-		shutdownLock             sync.Mutex
 	}
 	type args struct {
 		ev        *replication.BinlogEvent
@@ -460,7 +440,7 @@ func TestBinlogReader_handleBinlogRowsEvent(t *testing.T) {
 				currentCoordinates:       tt.fields.currentCoordinates,
 				currentCoordinatesMutex:  tt.fields.currentCoordinatesMutex,
 				LastAppliedRowsEventHint: tt.fields.LastAppliedRowsEventHint,
-				MysqlContext:             tt.fields.MysqlContext,
+				mysqlContext:             tt.fields.MysqlContext,
 				currentTx:                tt.fields.currentTx,
 				currentBinlogEntry:       tt.fields.currentBinlogEntry,
 				txCount:                  tt.fields.txCount,
@@ -469,10 +449,8 @@ func TestBinlogReader_handleBinlogRowsEvent(t *testing.T) {
 				currentSqlB64:            tt.fields.currentSqlB64,
 				appendB64SqlBs:           tt.fields.appendB64SqlBs,
 				ReMap:                    tt.fields.ReMap,
-				wg:                       tt.fields.wg,
 				shutdown:                 tt.fields.shutdown,
 				shutdownCh:               tt.fields.shutdownCh,
-				shutdownLock:             tt.fields.shutdownLock,
 			}
 			if err := b.handleBinlogRowsEvent(tt.args.ev, tt.args.txChannel); (err != nil) != tt.wantErr {
 				t.Errorf("BinlogReader.handleBinlogRowsEvent() error = %v, wantErr %v", err, tt.wantErr)
@@ -500,10 +478,8 @@ func TestBinlogReader_appendQuery(t *testing.T) {
 		currentSqlB64            *bytes.Buffer
 		appendB64SqlBs           []byte
 		ReMap                    map[string]*regexp.Regexp
-		wg                       sync.WaitGroup
 		shutdown                 bool
 		shutdownCh               chan struct{}
-		shutdownLock             sync.Mutex
 	}
 	type args struct {
 		query string
@@ -526,7 +502,7 @@ func TestBinlogReader_appendQuery(t *testing.T) {
 				currentCoordinates:       tt.fields.currentCoordinates,
 				currentCoordinatesMutex:  tt.fields.currentCoordinatesMutex,
 				LastAppliedRowsEventHint: tt.fields.LastAppliedRowsEventHint,
-				MysqlContext:             tt.fields.MysqlContext,
+				mysqlContext:             tt.fields.MysqlContext,
 				currentTx:                tt.fields.currentTx,
 				currentBinlogEntry:       tt.fields.currentBinlogEntry,
 				txCount:                  tt.fields.txCount,
@@ -535,10 +511,8 @@ func TestBinlogReader_appendQuery(t *testing.T) {
 				currentSqlB64:            tt.fields.currentSqlB64,
 				appendB64SqlBs:           tt.fields.appendB64SqlBs,
 				ReMap:                    tt.fields.ReMap,
-				wg:                       tt.fields.wg,
 				shutdown:                 tt.fields.shutdown,
 				shutdownCh:               tt.fields.shutdownCh,
-				shutdownLock:             tt.fields.shutdownLock,
 			}
 			b.appendQuery(tt.args.query)
 		})
@@ -584,10 +558,8 @@ func TestBinlogReader_clearB64Sql(t *testing.T) {
 		currentSqlB64            *bytes.Buffer
 		appendB64SqlBs           []byte
 		ReMap                    map[string]*regexp.Regexp
-		wg                       sync.WaitGroup
 		shutdown                 bool
 		shutdownCh               chan struct{}
-		shutdownLock             sync.Mutex
 	}
 	tests := []struct {
 		name   string
@@ -606,7 +578,7 @@ func TestBinlogReader_clearB64Sql(t *testing.T) {
 				currentCoordinates:       tt.fields.currentCoordinates,
 				currentCoordinatesMutex:  tt.fields.currentCoordinatesMutex,
 				LastAppliedRowsEventHint: tt.fields.LastAppliedRowsEventHint,
-				MysqlContext:             tt.fields.MysqlContext,
+				mysqlContext:             tt.fields.MysqlContext,
 				currentTx:                tt.fields.currentTx,
 				currentBinlogEntry:       tt.fields.currentBinlogEntry,
 				txCount:                  tt.fields.txCount,
@@ -615,10 +587,8 @@ func TestBinlogReader_clearB64Sql(t *testing.T) {
 				currentSqlB64:            tt.fields.currentSqlB64,
 				appendB64SqlBs:           tt.fields.appendB64SqlBs,
 				ReMap:                    tt.fields.ReMap,
-				wg:                       tt.fields.wg,
 				shutdown:                 tt.fields.shutdown,
 				shutdownCh:               tt.fields.shutdownCh,
-				shutdownLock:             tt.fields.shutdownLock,
 			}
 			b.clearB64Sql()
 		})
@@ -644,10 +614,8 @@ func TestBinlogReader_appendB64Sql(t *testing.T) {
 		currentSqlB64            *bytes.Buffer
 		appendB64SqlBs           []byte
 		ReMap                    map[string]*regexp.Regexp
-		wg                       sync.WaitGroup
 		shutdown                 bool
 		shutdownCh               chan struct{}
-		shutdownLock             sync.Mutex
 	}
 	type args struct {
 		event *BinlogEvent
@@ -670,7 +638,7 @@ func TestBinlogReader_appendB64Sql(t *testing.T) {
 				currentCoordinates:       tt.fields.currentCoordinates,
 				currentCoordinatesMutex:  tt.fields.currentCoordinatesMutex,
 				LastAppliedRowsEventHint: tt.fields.LastAppliedRowsEventHint,
-				MysqlContext:             tt.fields.MysqlContext,
+				mysqlContext:             tt.fields.MysqlContext,
 				currentTx:                tt.fields.currentTx,
 				currentBinlogEntry:       tt.fields.currentBinlogEntry,
 				txCount:                  tt.fields.txCount,
@@ -679,10 +647,8 @@ func TestBinlogReader_appendB64Sql(t *testing.T) {
 				currentSqlB64:            tt.fields.currentSqlB64,
 				appendB64SqlBs:           tt.fields.appendB64SqlBs,
 				ReMap:                    tt.fields.ReMap,
-				wg:                       tt.fields.wg,
 				shutdown:                 tt.fields.shutdown,
 				shutdownCh:               tt.fields.shutdownCh,
-				shutdownLock:             tt.fields.shutdownLock,
 			}
 			b.appendB64Sql(tt.args.event)
 		})
@@ -708,10 +674,8 @@ func TestBinlogReader_onCommit(t *testing.T) {
 		currentSqlB64            *bytes.Buffer
 		appendB64SqlBs           []byte
 		ReMap                    map[string]*regexp.Regexp
-		wg                       sync.WaitGroup
 		shutdown                 bool
 		shutdownCh               chan struct{}
-		shutdownLock             sync.Mutex
 	}
 	type args struct {
 		lastEvent *BinlogEvent
@@ -735,7 +699,7 @@ func TestBinlogReader_onCommit(t *testing.T) {
 				currentCoordinates:       tt.fields.currentCoordinates,
 				currentCoordinatesMutex:  tt.fields.currentCoordinatesMutex,
 				LastAppliedRowsEventHint: tt.fields.LastAppliedRowsEventHint,
-				MysqlContext:             tt.fields.MysqlContext,
+				mysqlContext:             tt.fields.MysqlContext,
 				currentTx:                tt.fields.currentTx,
 				currentBinlogEntry:       tt.fields.currentBinlogEntry,
 				txCount:                  tt.fields.txCount,
@@ -744,10 +708,8 @@ func TestBinlogReader_onCommit(t *testing.T) {
 				currentSqlB64:            tt.fields.currentSqlB64,
 				appendB64SqlBs:           tt.fields.appendB64SqlBs,
 				ReMap:                    tt.fields.ReMap,
-				wg:                       tt.fields.wg,
 				shutdown:                 tt.fields.shutdown,
 				shutdownCh:               tt.fields.shutdownCh,
-				shutdownLock:             tt.fields.shutdownLock,
 			}
 			b.onCommit(tt.args.lastEvent, tt.args.txChannel)
 		})
@@ -786,27 +748,28 @@ func Test_resolveDDLSQL(t *testing.T) {
 		sql string
 	}
 	tests := []struct {
-		name     string
-		args     args
-		wantSqls []string
-		wantOk   bool
-		wantErr  bool
+		name      string
+		args      args
+		wantSqls  []string
+		wantIsDDL bool
+		wantErr   bool
 	}{
 		// TODO: Add test cases.
-		{"t1", args{"alter TABLE aly_test ADD COLUMN (name5 CHAR(5) ,name6 char(6));"}, []string{}, true, false},
+		{"t1", args{"alter TABLE aly_test ADD COLUMN (name5 CHAR(5) ,name6 char(6));"},
+			[]string{"alter TABLE aly_test ADD COLUMN (name5 CHAR(5) ,name6 char(6));"}, true, false},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			gotSqls, gotOk, err := resolveDDLSQL(tt.args.sql)
+			got, err := resolveDDLSQL(tt.args.sql)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("resolveDDLSQL() error = %v, wantErr %v", err, tt.wantErr)
 				return
 			}
-			if !reflect.DeepEqual(gotSqls, tt.wantSqls) {
-				t.Errorf("resolveDDLSQL() gotSqls = %v, want %v", gotSqls, tt.wantSqls)
+			if !reflect.DeepEqual(got.sqls, tt.wantSqls) {
+				t.Errorf("resolveDDLSQL() sqls = %v, want %v", got.sqls, tt.wantSqls)
 			}
-			if gotOk != tt.wantOk {
-				t.Errorf("resolveDDLSQL() gotOk = %v, want %v", gotOk, tt.wantOk)
+			if got.isDDL != tt.wantIsDDL {
+				t.Errorf("resolveDDLSQL() isDDL = %v, want %v", got.isDDL, tt.wantIsDDL)
 			}
 		})
 	}
@@ -831,14 +794,13 @@ func TestBinlogReader_skipQueryDDL(t *testing.T) {
 		currentSqlB64            *bytes.Buffer
 		appendB64SqlBs           []byte
 		ReMap                    map[string]*regexp.Regexp
-		wg                       sync.WaitGroup
 		shutdown                 bool
 		shutdownCh               chan struct{}
-		shutdownLock             sync.Mutex
 	}
 	type args struct {
-		sql    string
-		schema string
+		sql       string
+		schema    string
+		tableName string
 	}
 	tests := []struct {
 		name   string
@@ -859,7 +821,7 @@ func TestBinlogReader_skipQueryDDL(t *testing.T) {
 				currentCoordinates:       tt.fields.currentCoordinates,
 				currentCoordinatesMutex:  tt.fields.currentCoordinatesMutex,
 				LastAppliedRowsEventHint: tt.fields.LastAppliedRowsEventHint,
-				MysqlContext:             tt.fields.MysqlContext,
+				mysqlContext:             tt.fields.MysqlContext,
 				currentTx:                tt.fields.currentTx,
 				currentBinlogEntry:       tt.fields.currentBinlogEntry,
 				txCount:                  tt.fields.txCount,
@@ -868,12 +830,10 @@ func TestBinlogReader_skipQueryDDL(t *testing.T) {
 				currentSqlB64:            tt.fields.currentSqlB64,
 				appendB64SqlBs:           tt.fields.appendB64SqlBs,
 				ReMap:                    tt.fields.ReMap,
-				wg:                       tt.fields.wg,
 				shutdown:                 tt.fields.shutdown,
 				shutdownCh:               tt.fields.shutdownCh,
-				shutdownLock:             tt.fields.shutdownLock,
 			}
-			if got := b.skipQueryDDL(tt.args.sql, tt.args.schema); got != tt.want {
+			if got := b.skipQueryDDL(tt.args.sql, tt.args.schema, tt.args.tableName); got != tt.want {
 				t.Errorf("BinlogReader.skipQueryDDL() = %v, want %v", got, tt.want)
 			}
 		})
@@ -919,10 +879,8 @@ func TestBinlogReader_skipRowEvent(t *testing.T) {
 		currentSqlB64            *bytes.Buffer
 		appendB64SqlBs           []byte
 		ReMap                    map[string]*regexp.Regexp
-		wg                       sync.WaitGroup
 		shutdown                 bool
 		shutdownCh               chan struct{}
-		shutdownLock             sync.Mutex
 	}
 	type args struct {
 		schema string
@@ -947,7 +905,7 @@ func TestBinlogReader_skipRowEvent(t *testing.T) {
 				currentCoordinates:       tt.fields.currentCoordinates,
 				currentCoordinatesMutex:  tt.fields.currentCoordinatesMutex,
 				LastAppliedRowsEventHint: tt.fields.LastAppliedRowsEventHint,
-				MysqlContext:             tt.fields.MysqlContext,
+				mysqlContext:             tt.fields.MysqlContext,
 				currentTx:                tt.fields.currentTx,
 				currentBinlogEntry:       tt.fields.currentBinlogEntry,
 				txCount:                  tt.fields.txCount,
@@ -956,10 +914,8 @@ func TestBinlogReader_skipRowEvent(t *testing.T) {
 				currentSqlB64:            tt.fields.currentSqlB64,
 				appendB64SqlBs:           tt.fields.appendB64SqlBs,
 				ReMap:                    tt.fields.ReMap,
-				wg:                       tt.fields.wg,
 				shutdown:                 tt.fields.shutdown,
 				shutdownCh:               tt.fields.shutdownCh,
-				shutdownLock:             tt.fields.shutdownLock,
 			}
 			if got := b.skipEvent(tt.args.schema, tt.args.table); got != tt.want {
 				t.Errorf("BinlogReader.skipRowEvent() = %v, want %v", got, tt.want)
@@ -987,10 +943,8 @@ func TestBinlogReader_matchString(t *testing.T) {
 		currentSqlB64            *bytes.Buffer
 		appendB64SqlBs           []byte
 		ReMap                    map[string]*regexp.Regexp
-		wg                       sync.WaitGroup
 		shutdown                 bool
 		shutdownCh               chan struct{}
-		shutdownLock             sync.Mutex
 	}
 	type args struct {
 		pattern string
@@ -1015,7 +969,7 @@ func TestBinlogReader_matchString(t *testing.T) {
 				currentCoordinates:       tt.fields.currentCoordinates,
 				currentCoordinatesMutex:  tt.fields.currentCoordinatesMutex,
 				LastAppliedRowsEventHint: tt.fields.LastAppliedRowsEventHint,
-				MysqlContext:             tt.fields.MysqlContext,
+				mysqlContext:             tt.fields.MysqlContext,
 				currentTx:                tt.fields.currentTx,
 				currentBinlogEntry:       tt.fields.currentBinlogEntry,
 				txCount:                  tt.fields.txCount,
@@ -1024,10 +978,8 @@ func TestBinlogReader_matchString(t *testing.T) {
 				currentSqlB64:            tt.fields.currentSqlB64,
 				appendB64SqlBs:           tt.fields.appendB64SqlBs,
 				ReMap:                    tt.fields.ReMap,
-				wg:                       tt.fields.wg,
 				shutdown:                 tt.fields.shutdown,
 				shutdownCh:               tt.fields.shutdownCh,
-				shutdownLock:             tt.fields.shutdownLock,
 			}
 			if got := b.matchString(tt.args.pattern, tt.args.t); got != tt.want {
 				t.Errorf("BinlogReader.matchString() = %v, want %v", got, tt.want)
@@ -1055,10 +1007,8 @@ func TestBinlogReader_matchDB(t *testing.T) {
 		currentSqlB64            *bytes.Buffer
 		appendB64SqlBs           []byte
 		ReMap                    map[string]*regexp.Regexp
-		wg                       sync.WaitGroup
 		shutdown                 bool
 		shutdownCh               chan struct{}
-		shutdownLock             sync.Mutex
 	}
 	type args struct {
 		patternDBS []*config.DataSource
@@ -1083,7 +1033,7 @@ func TestBinlogReader_matchDB(t *testing.T) {
 				currentCoordinates:       tt.fields.currentCoordinates,
 				currentCoordinatesMutex:  tt.fields.currentCoordinatesMutex,
 				LastAppliedRowsEventHint: tt.fields.LastAppliedRowsEventHint,
-				MysqlContext:             tt.fields.MysqlContext,
+				mysqlContext:             tt.fields.MysqlContext,
 				currentTx:                tt.fields.currentTx,
 				currentBinlogEntry:       tt.fields.currentBinlogEntry,
 				txCount:                  tt.fields.txCount,
@@ -1092,10 +1042,8 @@ func TestBinlogReader_matchDB(t *testing.T) {
 				currentSqlB64:            tt.fields.currentSqlB64,
 				appendB64SqlBs:           tt.fields.appendB64SqlBs,
 				ReMap:                    tt.fields.ReMap,
-				wg:                       tt.fields.wg,
 				shutdown:                 tt.fields.shutdown,
 				shutdownCh:               tt.fields.shutdownCh,
-				shutdownLock:             tt.fields.shutdownLock,
 			}
 			if got := b.matchDB(tt.args.patternDBS, tt.args.a); got != tt.want {
 				t.Errorf("BinlogReader.matchDB() = %v, want %v", got, tt.want)
@@ -1123,10 +1071,8 @@ func TestBinlogReader_Close(t *testing.T) {
 		currentSqlB64            *bytes.Buffer
 		appendB64SqlBs           []byte
 		ReMap                    map[string]*regexp.Regexp
-		wg                       sync.WaitGroup
 		shutdown                 bool
 		shutdownCh               chan struct{}
-		shutdownLock             sync.Mutex
 	}
 	tests := []struct {
 		name    string
@@ -1146,7 +1092,7 @@ func TestBinlogReader_Close(t *testing.T) {
 				currentCoordinates:       tt.fields.currentCoordinates,
 				currentCoordinatesMutex:  tt.fields.currentCoordinatesMutex,
 				LastAppliedRowsEventHint: tt.fields.LastAppliedRowsEventHint,
-				MysqlContext:             tt.fields.MysqlContext,
+				mysqlContext:             tt.fields.MysqlContext,
 				currentTx:                tt.fields.currentTx,
 				currentBinlogEntry:       tt.fields.currentBinlogEntry,
 				txCount:                  tt.fields.txCount,
@@ -1155,10 +1101,8 @@ func TestBinlogReader_Close(t *testing.T) {
 				currentSqlB64:            tt.fields.currentSqlB64,
 				appendB64SqlBs:           tt.fields.appendB64SqlBs,
 				ReMap:                    tt.fields.ReMap,
-				wg:                       tt.fields.wg,
 				shutdown:                 tt.fields.shutdown,
 				shutdownCh:               tt.fields.shutdownCh,
-				shutdownLock:             tt.fields.shutdownLock,
 			}
 			if err := b.Close(); (err != nil) != tt.wantErr {
 				t.Errorf("BinlogReader.Close() error = %v, wantErr %v", err, tt.wantErr)
@@ -1186,14 +1130,13 @@ func TestBinlogReader_matchTable(t *testing.T) {
 		currentSqlB64            *bytes.Buffer
 		appendB64SqlBs           []byte
 		ReMap                    map[string]*regexp.Regexp
-		wg                       sync.WaitGroup
 		shutdown                 bool
 		shutdownCh               chan struct{}
-		shutdownLock             sync.Mutex
 	}
 	type args struct {
 		patternTBS []*config.DataSource
-		t          config.DataSource
+		schemaName string
+		tableName  string
 	}
 	tests := []struct {
 		name   string
@@ -1214,7 +1157,7 @@ func TestBinlogReader_matchTable(t *testing.T) {
 				currentCoordinates:       tt.fields.currentCoordinates,
 				currentCoordinatesMutex:  tt.fields.currentCoordinatesMutex,
 				LastAppliedRowsEventHint: tt.fields.LastAppliedRowsEventHint,
-				MysqlContext:             tt.fields.MysqlContext,
+				mysqlContext:             tt.fields.MysqlContext,
 				currentTx:                tt.fields.currentTx,
 				currentBinlogEntry:       tt.fields.currentBinlogEntry,
 				txCount:                  tt.fields.txCount,
@@ -1223,12 +1166,10 @@ func TestBinlogReader_matchTable(t *testing.T) {
 				currentSqlB64:            tt.fields.currentSqlB64,
 				appendB64SqlBs:           tt.fields.appendB64SqlBs,
 				ReMap:                    tt.fields.ReMap,
-				wg:                       tt.fields.wg,
 				shutdown:                 tt.fields.shutdown,
 				shutdownCh:               tt.fields.shutdownCh,
-				shutdownLock:             tt.fields.shutdownLock,
 			}
-			if got := b.matchTable(tt.args.patternTBS, config.Table{}); got != tt.want {
+			if got := b.matchTable(tt.args.patternTBS, tt.args.schemaName, tt.args.tableName); got != tt.want {
 				t.Errorf("BinlogReader.matchTable() = %v, want %v", got, tt.want)
 			}
 		})
@@ -0,0 +1,47 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package binlog
+
+import (
+	"fmt"
+
+	"github.com/actiontech/dtle/internal/config"
+	"github.com/actiontech/dtle/internal/config/mysql"
+)
+
+// resolvePartialJSON replaces the diffs of the JSON columns partially
+// updated by an update event with their full values, or with JSON patches,
+// as of PartialJSONUpdates
+func (b *BinlogReader) resolvePartialJSON(dmlEvent *DataEvent) error {
+	for i, value := range dmlEvent.NewColumnValues.AbstractValues {
+		patch, ok := (*value).(mysql.JSONPatch)
+		if !ok {
+			continue
+		}
+		if b.mysqlContext.PartialJSONUpdates == config.PartialJSONPatch {
+			continue
+		}
+
+		var doc string
+		switch before := (*dmlEvent.WhereColumnValues.AbstractValues[i]).(type) {
+		case []byte:
+			doc = string(before)
+		case string:
+			doc = before
+		default:
+			return fmt.Errorf("partial JSON update of column %d of %s.%s without its previous value: set binlog_row_image to FULL on the source, or PartialJSONUpdates to %q",
+				i+1, dmlEvent.DatabaseName, dmlEvent.TableName, config.PartialJSONPatch)
+		}
+		full, err := patch.Apply(doc)
+		if err != nil {
+			return fmt.Errorf("applying the partial JSON update of column %d of %s.%s: %v",
+				i+1, dmlEvent.DatabaseName, dmlEvent.TableName, err)
+		}
+		*value = []byte(full)
+	}
+	return nil
+}
@@ -0,0 +1,226 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package binlog
+
+import (
+	"encoding/binary"
+	"reflect"
+	"testing"
+
+	"github.com/actiontech/dtle/internal/config"
+	"github.com/actiontech/dtle/internal/config/mysql"
+
+	gomysql "github.com/siddontang/go-mysql/mysql"
+	"github.com/siddontang/go-mysql/replication"
+)
+
+// the JSON binary of {"a":1}
+var jsonbDoc = []byte{0x00, 0x01, 0x00, 0x0c, 0x00, 0x0b, 0x00, 0x01, 0x00, 0x05, 0x01, 0x00, 'a'}
+
+// binlogEvent returns an event of the type with its common header
+func binlogEvent(eventType replication.EventType, body []byte) []byte {
+	header := make([]byte, replication.EventHeaderSize)
+	header[4] = byte(eventType)
+	binary.LittleEndian.PutUint32(header[9:], uint32(len(header)+len(body)))
+	return append(header, body...)
+}
+
+// partialUpdateEvents returns the events of a partial update of the JSON
+// column of a table (id INT, doc JSON) whose doc was {"a":1}
+func partialUpdateEvents(diffs []byte) [][]byte {
+	// a format description of a server without checksums, with 8 bytes
+	// post-headers for the table map and rows events
+	fde := make([]byte, 2+50+4+1, 2+50+4+1+40)
+	binary.LittleEndian.PutUint16(fde, 4)
+	copy(fde[2:], "5.5.0")
+	fde[56] = byte(replication.EventHeaderSize)
+	for i := 0; i < 40; i++ {
+		fde = append(fde, 8)
+	}
+
+	tableMap := []byte{1, 0, 0, 0, 0, 0, 0, 0}
+	tableMap = append(tableMap, 2, 'd', 'b', 0, 3, 't', 'b', 'l', 0)
+	tableMap = append(tableMap, 2, gomysql.MYSQL_TYPE_LONG, gomysql.MYSQL_TYPE_JSON)
+	tableMap = append(tableMap, 1, 4, 0)
+
+	rows := []byte{1, 0, 0, 0, 0, 0, 1, 0, 2, 0, 2, 0x03, 0x03}
+	// the before image: the null bitmap, id 1 and the doc
+	rows = append(rows, 0, 1, 0, 0, 0)
+	rows = append(rows, byte(len(jsonbDoc)), 0, 0, 0)
+	rows = append(rows, jsonbDoc...)
+	// the after image: the partial JSON updates option, the partial bitmap,
+	// the null bitmap, id 1 and the diffs
+	rows = append(rows, 1, 1, 0, 1, 0, 0, 0)
+	rows = append(rows, byte(len(diffs)), 0, 0, 0)
+	rows = append(rows, diffs...)
+
+	return [][]byte{
+		binlogEvent(replication.FORMAT_DESCRIPTION_EVENT, fde),
+		binlogEvent(replication.TABLE_MAP_EVENT, tableMap),
+		binlogEvent(partialUpdateRowsEvent, rows),
+	}
+}
+
+// parsePartialUpdate parses the events, decoding the partial updates, and
+// returns the rows of the last one
+func parsePartialUpdate(events [][]byte) ([][]interface{}, error) {
+	parser := replication.NewBinlogParser()
+	parser.SetUseDecimal(true)
+	decoder := newPartialRowsDecoder(replication.BinlogSyncerConfig{UseDecimal: true})
+	var rows [][]interface{}
+	for _, event := range events {
+		e, err := parser.Parse(event)
+		if err != nil {
+			return nil, err
+		}
+		if err := decoder.observe(e); err != nil {
+			return nil, err
+		}
+		if rowsEvent, ok := e.Event.(*replication.RowsEvent); ok {
+			rows = rowsEvent.Rows
+		}
+	}
+	return rows, nil
+}
+
+func TestPartialRowsDecoder(t *testing.T) {
+	var diffs []byte
+	// insert 2 at $."b c"
+	diffs = append(diffs, jsonDiffInsert, 7)
+	diffs = append(diffs, `$."b c"`...)
+	diffs = append(diffs, 3, 0x05, 0x02, 0x00)
+	// replace $.a with "x"
+	diffs = append(diffs, jsonDiffReplace, 3)
+	diffs = append(diffs, `$.a`...)
+	diffs = append(diffs, 3, 0x0c, 0x01, 'x')
+	// remove $[0], which has no value
+	diffs = append(diffs, jsonDiffRemove, 4)
+	diffs = append(diffs, `$[0]`...)
+
+	rows, err := parsePartialUpdate(partialUpdateEvents(diffs))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 2 {
+		t.Fatalf("got %d rows, want the before and after images", len(rows))
+	}
+	if got, want := rows[0], []interface{}{int32(1), []byte(`{"a":1}`)}; !reflect.DeepEqual(got, want) {
+		t.Errorf("before image %v, want %v", got, want)
+	}
+	want := mysql.JSONPatch{
+		{Op: mysql.JSONDiffInsert, Path: `$."b c"`, Value: []byte(`2`)},
+		{Op: mysql.JSONDiffReplace, Path: `$.a`, Value: []byte(`"x"`)},
+		{Op: mysql.JSONDiffRemove, Path: `$[0]`},
+	}
+	if got := rows[1][1]; !reflect.DeepEqual(got, want) {
+		t.Errorf("after image doc %#v, want %#v", got, want)
+	}
+	if got := rows[1][0]; got != int32(1) {
+		t.Errorf("after image id %v, want 1", got)
+	}
+
+	// an unknown operation is an error
+	rows, err = parsePartialUpdate(partialUpdateEvents([]byte{3, 3, '$', '.', 'a'}))
+	if err == nil {
+		t.Errorf("parsed an unknown JSON diff operation as %v", rows)
+	}
+	// so is a path past the end of the diffs
+	rows, err = parsePartialUpdate(partialUpdateEvents([]byte{jsonDiffRemove, 9, '$', '.', 'a'}))
+	if err == nil {
+		t.Errorf("parsed a truncated JSON diff as %v", rows)
+	}
+	// and a partial update without its table map
+	events := partialUpdateEvents(diffs)
+	rows, err = parsePartialUpdate([][]byte{events[0], events[2]})
+	if err == nil {
+		t.Errorf("parsed a partial update without its table map as %v", rows)
+	}
+}
+
+func TestValueLength(t *testing.T) {
+	cases := []struct {
+		data   []byte
+		tp     byte
+		meta   uint16
+		length int
+	}{
+		{nil, gomysql.MYSQL_TYPE_LONGLONG, 0, 8},
+		{nil, gomysql.MYSQL_TYPE_DATETIME2, 3, 7},
+		{nil, gomysql.MYSQL_TYPE_TIMESTAMP2, 0, 4},
+		// DECIMAL(10,2): 8 integral digits in 4 bytes, 2 fractional in 1
+		{nil, gomysql.MYSQL_TYPE_NEWDECIMAL, 10<<8 | 2, 5},
+		// DECIMAL(20,10): 9 digits in 4 bytes, 1 in 1, 9 in 4 and 1 in 1
+		{nil, gomysql.MYSQL_TYPE_NEWDECIMAL, 20<<8 | 10, 10},
+		// BIT(10)
+		{nil, gomysql.MYSQL_TYPE_BIT, 1<<8 | 2, 2},
+		{[]byte{3, 'a', 'b', 'c'}, gomysql.MYSQL_TYPE_VARCHAR, 20, 4},
+		{[]byte{3, 0, 'a', 'b', 'c'}, gomysql.MYSQL_TYPE_VARCHAR, 300, 5},
+		// CHAR(10) of utf8mb4, 40 bytes
+		{[]byte{2, 'a', 'b'}, gomysql.MYSQL_TYPE_STRING, uint16(gomysql.MYSQL_TYPE_STRING)<<8 | 40, 3},
+		// ENUM of 1 byte
+		{nil, gomysql.MYSQL_TYPE_STRING, uint16(gomysql.MYSQL_TYPE_ENUM)<<8 | 1, 1},
+		{[]byte{2, 0, 'a', 'b'}, gomysql.MYSQL_TYPE_BLOB, 2, 4},
+		{[]byte{1, 0, 0, 0, 0}, gomysql.MYSQL_TYPE_JSON, 4, 5},
+	}
+	for _, c := range cases {
+		length, err := valueLength(c.data, c.tp, c.meta)
+		if err != nil {
+			t.Errorf("type %d meta %d: %v", c.tp, c.meta, err)
+		} else if length != c.length {
+			t.Errorf("type %d meta %d: length %d, want %d", c.tp, c.meta, length, c.length)
+		}
+	}
+	if _, err := valueLength(nil, 0xf0, 0); err == nil {
+		t.Errorf("length of a value of an unknown type")
+	}
+}
+
+func TestBinlogReader_resolvePartialJSON(t *testing.T) {
+	diffs := mysql.JSONPatch{
+		{Op: mysql.JSONDiffInsert, Path: `$."b c"`, Value: []byte(`2`)},
+		{Op: mysql.JSONDiffRemove, Path: `$.a`},
+	}
+	newEvent := func() *DataEvent {
+		event := NewDataEvent("db", "tbl", UpdateDML, 2)
+		event.WhereColumnValues = mysql.ToColumnValues([]interface{}{int32(1), []byte(`{"a":1}`)})
+		event.NewColumnValues = mysql.ToColumnValues([]interface{}{int32(1), diffs})
+		return &event
+	}
+	{
+		b := &BinlogReader{mysqlContext: &config.MySQLDriverConfig{PartialJSONUpdates: config.PartialJSONFull}}
+		event := newEvent()
+		if err := b.resolvePartialJSON(event); err != nil {
+			t.Fatal(err)
+		}
+		if got := event.NewColumnValues.AbstractValues[1]; !reflect.DeepEqual(*got, []byte(`{"b c":2}`)) {
+			t.Errorf("full value %s", *got)
+		}
+	}
+	{
+		b := &BinlogReader{mysqlContext: &config.MySQLDriverConfig{PartialJSONUpdates: config.PartialJSONPatch}}
+		event := newEvent()
+		if err := b.resolvePartialJSON(event); err != nil {
+			t.Fatal(err)
+		}
+		want := mysql.JSONPatch{
+			{Op: mysql.JSONDiffInsert, Path: `$."b c"`, Value: []byte(`2`)},
+			{Op: mysql.JSONDiffRemove, Path: `$.a`},
+		}
+		if got := event.NewColumnValues.AbstractValues[1]; !reflect.DeepEqual(*got, want) {
+			t.Errorf("patch %v, want %v", *got, want)
+		}
+	}
+	{
+		// without the before image the full value can not be made
+		b := &BinlogReader{mysqlContext: &config.MySQLDriverConfig{PartialJSONUpdates: config.PartialJSONFull}}
+		event := newEvent()
+		event.WhereColumnValues = mysql.ToColumnValues([]interface{}{int32(1), nil})
+		if err := b.resolvePartialJSON(event); err == nil {
+			t.Errorf("resolved without the previous value")
+		}
+	}
+}
@@ -0,0 +1,510 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package binlog
+
+import (
+	"encoding/binary"
+	"fmt"
+
+	gomysql "github.com/siddontang/go-mysql/mysql"
+	"github.com/siddontang/go-mysql/replication"
+
+	"github.com/actiontech/dtle/internal/config/mysql"
+)
+
+// The events of MySQL 8 go-mysql does not know, which it reads as
+// GenericEvents
+const (
+	xaPrepareLogEvent      replication.EventType = 38
+	partialUpdateRowsEvent replication.EventType = 39
+)
+
+// partialJSONUpdates is the value option of the after images of
+// PARTIAL_UPDATE_ROWS_EVENT telling that their JSON columns may hold diffs
+const partialJSONUpdates = 1
+
+// The operations of the JSON diffs of a partial update. See
+// enum_json_diff_operation of MySQL.
+const (
+	jsonDiffReplace = iota
+	jsonDiffInsert
+	jsonDiffRemove
+)
+
+// jsonTableID is the ID of the table of a JSON column the values of the
+// JSON diffs are decoded as rows of
+const jsonTableID = 0xfffffffffffe
+
+// partialRowsDecoder decodes the PARTIAL_UPDATE_ROWS_EVENTs, logged by
+// MySQL 8 with binlog_row_value_options=PARTIAL_JSON, into the RowsEvents of
+// updates whose JSON columns partially updated hold the mysql.JSONPatch of
+// the update. The other values are decoded by go-mysql, from an
+// UPDATE_ROWS_EVENTv2 of each row without its JSON diffs, so that the
+// vendored go-mysql is kept as it is.
+type partialRowsDecoder struct {
+	cfg replication.BinlogSyncerConfig
+	// checksum tells whether the events end with a CRC32
+	checksum bool
+	// tableMaps are the table map events of the statement, without their
+	// header and checksum, by table ID
+	tableMaps map[uint64][]byte
+	// parser decodes the events made of the partial updates, nil until the
+	// first one
+	parser *replication.BinlogParser
+}
+
+func newPartialRowsDecoder(cfg replication.BinlogSyncerConfig) *partialRowsDecoder {
+	return &partialRowsDecoder{cfg: cfg, tableMaps: make(map[uint64][]byte)}
+}
+
+// observe keeps the table maps of the events read, and replaces the partial
+// updates read as GenericEvents with their RowsEvents
+func (d *partialRowsDecoder) observe(ev *replication.BinlogEvent) error {
+	if d == nil {
+		return nil
+	}
+	switch e := ev.Event.(type) {
+	case *replication.FormatDescriptionEvent:
+		d.checksum = e.ChecksumAlgorithm == replication.BINLOG_CHECKSUM_ALG_CRC32
+	case *replication.TableMapEvent:
+		body := ev.RawData[replication.EventHeaderSize:]
+		if d.checksum {
+			body = body[:len(body)-replication.BinlogChecksumLength]
+		}
+		d.tableMaps[e.TableID] = body
+	case *replication.GenericEvent:
+		if ev.Header.EventType != partialUpdateRowsEvent {
+			return nil
+		}
+		rowsEvent, err := d.decode(e.Data)
+		if err != nil {
+			return fmt.Errorf("decoding the partial update at %v: %v", ev.Header.LogPos, err)
+		}
+		ev.Event = rowsEvent
+		d.endStatement(rowsEvent)
+	case *replication.RowsEvent:
+		d.endStatement(e)
+	}
+	return nil
+}
+
+// endStatement forgets the table maps once the statement of a rows event
+// ends, as go-mysql does
+func (d *partialRowsDecoder) endStatement(e *replication.RowsEvent) {
+	if e.Flags&replication.RowsEventStmtEndFlag != 0 {
+		d.tableMaps = make(map[uint64][]byte)
+		d.parser = nil
+	}
+}
+
+// decode decodes the body of a PARTIAL_UPDATE_ROWS_EVENT. See
+// Rows_log_event::write_row() of MySQL.
+func (d *partialRowsDecoder) decode(data []byte) (e *replication.RowsEvent, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("corrupted event: %v", r)
+		}
+	}()
+
+	e = &replication.RowsEvent{Version: 2}
+	e.TableID = gomysql.FixedLengthInt(data[0:6])
+	e.Flags = binary.LittleEndian.Uint16(data[6:])
+	extraLength := int(binary.LittleEndian.Uint16(data[8:]))
+	e.ExtraData = data[10 : 8+extraLength]
+	pos := 8 + extraLength
+
+	var n int
+	e.ColumnCount, _, n = gomysql.LengthEncodedInt(data[pos:])
+	columnCount := data[pos : pos+n]
+	pos += n
+	bitmapSize := bitmapByteSize(int(e.ColumnCount))
+	e.ColumnBitmap1 = data[pos : pos+bitmapSize]
+	pos += bitmapSize
+	e.ColumnBitmap2 = data[pos : pos+bitmapSize]
+	pos += bitmapSize
+
+	tableMap, ok := d.tableMaps[e.TableID]
+	if !ok {
+		return nil, fmt.Errorf("no table map of table id %d", e.TableID)
+	}
+	if d.parser == nil {
+		if d.parser, err = d.newParser(); err != nil {
+			return nil, err
+		}
+	}
+	tableEvent, err := d.parse(replication.TABLE_MAP_EVENT, tableMap)
+	if err != nil {
+		return nil, err
+	}
+	e.Table = tableEvent.(*replication.TableMapEvent)
+	if int(e.Table.ColumnCount) != int(e.ColumnCount) {
+		return nil, fmt.Errorf("%d columns, %d in the table map", e.ColumnCount, e.Table.ColumnCount)
+	}
+
+	for pos < len(data) {
+		_, n, err := scanRowImage(data[pos:], e.Table, e.ColumnBitmap1, nil)
+		if err != nil {
+			return nil, err
+		}
+		beforeImage := data[pos : pos+n]
+		pos += n
+
+		options, _, n := gomysql.LengthEncodedInt(data[pos:])
+		pos += n
+		var partialBitmap []byte
+		if options&partialJSONUpdates != 0 {
+			size := bitmapByteSize(jsonColumnCount(e.Table))
+			partialBitmap = data[pos : pos+size]
+			pos += size
+		}
+		after, n, err := scanRowImage(data[pos:], e.Table, e.ColumnBitmap2, partialBitmap)
+		if err != nil {
+			return nil, err
+		}
+		pos += n
+
+		// the row as an update of the columns not partially updated
+		body := append([]byte{}, data[:6]...)
+		body = append(body, 0, 0, 2, 0) // no flags nor extra data
+		body = append(body, columnCount...)
+		body = append(body, e.ColumnBitmap1...)
+		body = append(body, after.bitmap(bitmapSize)...)
+		body = append(body, beforeImage...)
+		body = append(body, after.withoutPartial()...)
+		rowsEvent, err := d.parse(replication.UPDATE_ROWS_EVENTv2, body)
+		if err != nil {
+			return nil, err
+		}
+		rows := rowsEvent.(*replication.RowsEvent).Rows
+		if len(rows) != 2 {
+			return nil, fmt.Errorf("%d images of an update", len(rows))
+		}
+		for _, i := range after.columns {
+			if !after.partial[i] {
+				continue
+			}
+			patch, err := d.decodeJSONDiffs(after.values[i], e.Table.ColumnMeta[i])
+			if err != nil {
+				return nil, fmt.Errorf("column %d: %v", i+1, err)
+			}
+			rows[1][i] = patch
+		}
+		e.Rows = append(e.Rows, rows...)
+	}
+	return e, nil
+}
+
+// rowImage is a row image of a rows event, split into its values
+type rowImage struct {
+	// columns are the columns in the image
+	columns []int
+	// null, values and partial are by column: whether the value is NULL, its
+	// bytes, and whether they are the JSON diffs of a partial update
+	null    []bool
+	values  [][]byte
+	partial []bool
+}
+
+// bitmap returns the bitmap of the columns of the image not partially updated
+func (img *rowImage) bitmap(size int) []byte {
+	bitmap := make([]byte, size)
+	for _, i := range img.columns {
+		if !img.partial[i] {
+			bitmap[i>>3] |= 1 << (uint(i) & 7)
+		}
+	}
+	return bitmap
+}
+
+// withoutPartial returns the image of the columns not partially updated
+func (img *rowImage) withoutPartial() []byte {
+	var columns []int
+	for _, i := range img.columns {
+		if !img.partial[i] {
+			columns = append(columns, i)
+		}
+	}
+	image := make([]byte, bitmapByteSize(len(columns)))
+	for j, i := range columns {
+		if img.null[i] {
+			image[j>>3] |= 1 << (uint(j) & 7)
+			continue
+		}
+		image = append(image, img.values[i]...)
+	}
+	return image
+}
+
+// scanRowImage splits a row image of the columns of bitmap into its values,
+// returning its length. The JSON columns of partialBitmap, in the order of
+// the JSON columns of the table, hold JSON diffs.
+func scanRowImage(data []byte, table *replication.TableMapEvent, bitmap []byte, partialBitmap []byte) (*rowImage, int, error) {
+	count := int(table.ColumnCount)
+	img := &rowImage{
+		null:    make([]bool, count),
+		values:  make([][]byte, count),
+		partial: make([]bool, count),
+	}
+	partialColumns := make([]bool, count)
+	jsonColumn := 0
+	for i := 0; i < count; i++ {
+		if table.ColumnType[i] == gomysql.MYSQL_TYPE_JSON {
+			partialColumns[i] = partialBitmap != nil && isBitSet(partialBitmap, jsonColumn)
+			jsonColumn++
+		}
+		if isBitSet(bitmap, i) {
+			img.columns = append(img.columns, i)
+		}
+	}
+
+	pos := bitmapByteSize(len(img.columns))
+	nullBitmap := data[:pos]
+	for j, i := range img.columns {
+		if isBitSet(nullBitmap, j) {
+			img.null[i] = true
+			continue
+		}
+		n, err := valueLength(data[pos:], table.ColumnType[i], table.ColumnMeta[i])
+		if err != nil {
+			return nil, 0, fmt.Errorf("column %d: %v", i+1, err)
+		}
+		img.values[i] = data[pos : pos+n]
+		img.partial[i] = partialColumns[i]
+		pos += n
+	}
+	return img, pos, nil
+}
+
+// compressedBytes are the bytes of the leftover digits of a decimal
+var compressedBytes = []int{0, 1, 1, 2, 2, 3, 3, 4, 4, 4}
+
+// valueLength returns the length of a value of a row image, as go-mysql
+// decodes it
+func valueLength(data []byte, tp byte, meta uint16) (int, error) {
+	length := 0
+	if tp == gomysql.MYSQL_TYPE_STRING {
+		length = int(meta)
+		if meta >= 256 {
+			b0 := uint8(meta >> 8)
+			b1 := uint8(meta & 0xFF)
+			if b0&0x30 != 0x30 {
+				length = int(uint16(b1) | (uint16((b0&0x30)^0x30) << 4))
+				tp = b0 | 0x30
+			} else {
+				length = int(b1)
+				tp = b0
+			}
+		}
+	}
+
+	switch tp {
+	case gomysql.MYSQL_TYPE_NULL:
+		return 0, nil
+	case gomysql.MYSQL_TYPE_TINY, gomysql.MYSQL_TYPE_YEAR:
+		return 1, nil
+	case gomysql.MYSQL_TYPE_SHORT:
+		return 2, nil
+	case gomysql.MYSQL_TYPE_INT24, gomysql.MYSQL_TYPE_DATE, gomysql.MYSQL_TYPE_TIME:
+		return 3, nil
+	case gomysql.MYSQL_TYPE_LONG, gomysql.MYSQL_TYPE_FLOAT, gomysql.MYSQL_TYPE_TIMESTAMP:
+		return 4, nil
+	case gomysql.MYSQL_TYPE_LONGLONG, gomysql.MYSQL_TYPE_DOUBLE, gomysql.MYSQL_TYPE_DATETIME:
+		return 8, nil
+	case gomysql.MYSQL_TYPE_TIMESTAMP2:
+		return 4 + int(meta+1)/2, nil
+	case gomysql.MYSQL_TYPE_DATETIME2:
+		return 5 + int(meta+1)/2, nil
+	case gomysql.MYSQL_TYPE_TIME2:
+		return 3 + int(meta+1)/2, nil
+	case gomysql.MYSQL_TYPE_NEWDECIMAL:
+		integral := int(meta>>8) - int(meta&0xFF)
+		scale := int(meta & 0xFF)
+		return integral/9*4 + compressedBytes[integral%9] + scale/9*4 + compressedBytes[scale%9], nil
+	case gomysql.MYSQL_TYPE_BIT:
+		nbits := int(meta>>8)*8 + int(meta&0xFF)
+		return (nbits + 7) / 8, nil
+	case gomysql.MYSQL_TYPE_ENUM, gomysql.MYSQL_TYPE_SET:
+		return int(meta & 0xFF), nil
+	case gomysql.MYSQL_TYPE_BLOB, gomysql.MYSQL_TYPE_GEOMETRY, gomysql.MYSQL_TYPE_JSON:
+		prefix := int(meta)
+		return prefix + int(gomysql.FixedLengthInt(data[:prefix])), nil
+	case gomysql.MYSQL_TYPE_VARCHAR, gomysql.MYSQL_TYPE_VAR_STRING:
+		length = int(meta)
+	case gomysql.MYSQL_TYPE_STRING:
+	default:
+		return 0, fmt.Errorf("unsupported type %d", tp)
+	}
+	if length < 256 {
+		return 1 + int(data[0]), nil
+	}
+	return 2 + int(binary.LittleEndian.Uint16(data)), nil
+}
+
+func jsonColumnCount(table *replication.TableMapEvent) int {
+	count := 0
+	for _, tp := range table.ColumnType {
+		if tp == gomysql.MYSQL_TYPE_JSON {
+			count++
+		}
+	}
+	return count
+}
+
+func bitmapByteSize(columnCount int) int {
+	return (columnCount + 7) / 8
+}
+
+func isBitSet(bitmap []byte, i int) bool {
+	return bitmap[i>>3]&(1<<(uint(i)&7)) != 0
+}
+
+// newParser returns a parser of the events made of the partial updates,
+// without checksums, decoding the values as the syncer does
+func (d *partialRowsDecoder) newParser() (*replication.BinlogParser, error) {
+	parser := replication.NewBinlogParser()
+	parser.SetParseTime(d.cfg.ParseTime)
+	parser.SetTimestampStringLocation(d.cfg.TimestampStringLocation)
+	parser.SetUseDecimal(d.cfg.UseDecimal)
+
+	// the format of a server before checksums, with the post headers of the
+	// table map and rows events of 6 bytes table IDs
+	fde := make([]byte, 2+50+4)
+	binary.LittleEndian.PutUint16(fde, 4)
+	copy(fde[2:], "5.5.0")
+	fde = append(fde, replication.EventHeaderSize)
+	for i := replication.START_EVENT_V3; i <= replication.PREVIOUS_GTIDS_EVENT; i++ {
+		fde = append(fde, 8)
+	}
+	if _, err := parser.Parse(eventBytes(replication.FORMAT_DESCRIPTION_EVENT, fde)); err != nil {
+		return nil, err
+	}
+	return parser, nil
+}
+
+// parse decodes an event body made of the partial updates
+func (d *partialRowsDecoder) parse(eventType replication.EventType, body []byte) (replication.Event, error) {
+	ev, err := d.parser.Parse(eventBytes(eventType, body))
+	if err != nil {
+		return nil, err
+	}
+	return ev.Event, nil
+}
+
+// eventBytes returns an event of a body, behind a header of its type and size
+func eventBytes(eventType replication.EventType, body []byte) []byte {
+	data := make([]byte, replication.EventHeaderSize, replication.EventHeaderSize+len(body))
+	data[4] = byte(eventType)
+	binary.LittleEndian.PutUint32(data[9:], uint32(len(data)+len(body)))
+	return append(data, body...)
+}
+
+// decodeJSONDiffs decodes the JSON diffs of a column partially updated. See
+// Json_diff_vector::read_binary() of MySQL.
+func (d *partialRowsDecoder) decodeJSONDiffs(value []byte, meta uint16) (mysql.JSONPatch, error) {
+	data := value[meta:]
+	var patch mysql.JSONPatch
+	var values [][]byte
+	for len(data) > 0 {
+		var diff mysql.JSONDiff
+		switch data[0] {
+		case jsonDiffReplace:
+			diff.Op = mysql.JSONDiffReplace
+		case jsonDiffInsert:
+			diff.Op = mysql.JSONDiffInsert
+		case jsonDiffRemove:
+			diff.Op = mysql.JSONDiffRemove
+		default:
+			return nil, fmt.Errorf("unknown JSON diff operation %d", data[0])
+		}
+		data = data[1:]
+
+		length, _, n := gomysql.LengthEncodedInt(data)
+		data = data[n:]
+		if uint64(len(data)) < length {
+			return nil, fmt.Errorf("truncated JSON diff path, %d bytes of %d", len(data), length)
+		}
+		diff.Path = string(data[:length])
+		data = data[length:]
+
+		if diff.Op != mysql.JSONDiffRemove {
+			length, _, n = gomysql.LengthEncodedInt(data)
+			data = data[n:]
+			if uint64(len(data)) < length {
+				return nil, fmt.Errorf("truncated JSON diff value, %d bytes of %d", len(data), length)
+			}
+			values = append(values, data[:length])
+			data = data[length:]
+		}
+		patch = append(patch, diff)
+	}
+
+	docs, err := d.decodeJSON(values)
+	if err != nil {
+		return nil, err
+	}
+	for i := range patch {
+		if patch[i].Op != mysql.JSONDiffRemove {
+			patch[i].Value, docs = docs[0], docs[1:]
+		}
+	}
+	return patch, nil
+}
+
+// decodeJSON decodes JSON binary values, as the rows of a table of a JSON
+// column
+func (d *partialRowsDecoder) decodeJSON(values [][]byte) ([][]byte, error) {
+	if len(values) == 0 {
+		return nil, nil
+	}
+	tableMap := tableIDBytes(jsonTableID)
+	tableMap = append(tableMap,
+		0, 0, // flags
+		0, 0, // empty schema
+		0, 0, // empty table
+		1, gomysql.MYSQL_TYPE_JSON, // a JSON column
+		1, 4, // of 4 bytes lengths
+		1) // nullable
+	if _, err := d.parse(replication.TABLE_MAP_EVENT, tableMap); err != nil {
+		return nil, err
+	}
+
+	rows := tableIDBytes(jsonTableID)
+	rows = append(rows,
+		0, 0, // flags
+		2, 0, // no extra data
+		1, 1) // a column, in the image
+	for _, value := range values {
+		rows = append(rows, 0) // not NULL
+		length := make([]byte, 4)
+		binary.LittleEndian.PutUint32(length, uint32(len(value)))
+		rows = append(rows, length...)
+		rows = append(rows, value...)
+	}
+	ev, err := d.parse(replication.WRITE_ROWS_EVENTv2, rows)
+	if err != nil {
+		return nil, err
+	}
+
+	docs := make([][]byte, 0, len(values))
+	for _, row := range ev.(*replication.RowsEvent).Rows {
+		doc, ok := row[0].([]byte)
+		if !ok {
+			return nil, fmt.Errorf("JSON value decoded as %T", row[0])
+		}
+		docs = append(docs, doc)
+	}
+	if len(docs) != len(values) {
+		return nil, fmt.Errorf("%d JSON values decoded of %d", len(docs), len(values))
+	}
+	return docs, nil
+}
+
+func tableIDBytes(id uint64) []byte {
+	bs := make([]byte, 8)
+	binary.LittleEndian.PutUint64(bs, id)
+	return bs[:6]
+}
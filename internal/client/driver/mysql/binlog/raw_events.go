@@ -0,0 +1,34 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package binlog
+
+import (
+	"fmt"
+
+	"github.com/siddontang/go-mysql/replication"
+)
+
+// RawEventWriter is written the events read from the source as they are
+// received, such as the store of the binlog server
+type RawEventWriter interface {
+	WriteEvent(raw []byte) error
+}
+
+// SetRawEventWriter sets the writer the events read are copied to
+func (b *BinlogReader) SetRawEventWriter(w RawEventWriter) {
+	b.rawEventWriter = w
+}
+
+func (b *BinlogReader) writeRawEvent(ev *replication.BinlogEvent) error {
+	if b.rawEventWriter == nil {
+		return nil
+	}
+	if err := b.rawEventWriter.WriteEvent(ev.RawData); err != nil {
+		return fmt.Errorf("writing the event to the binlog server: %v", err)
+	}
+	return nil
+}
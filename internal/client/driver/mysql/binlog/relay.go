@@ -0,0 +1,94 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package binlog
+
+import (
+	"context"
+	"fmt"
+	"net"
+
+	gomysql "github.com/siddontang/go-mysql/mysql"
+	"github.com/siddontang/go-mysql/replication"
+
+	"github.com/actiontech/dtle/internal/config"
+	log "github.com/actiontech/dtle/internal/logger"
+)
+
+// Relay copies the binlog of the source to a RawEventWriter as it is
+// received, whatever the pace of the reader, which reads the binlog from the
+// relay rather than from the source
+type Relay struct {
+	logger *log.Entry
+	syncer *replication.BinlogSyncer
+	writer RawEventWriter
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+// NewRelay returns a relay of the source of cfg to w
+func NewRelay(cfg *config.MySQLDriverConfig, w RawEventWriter, logger *log.Entry) (*Relay, error) {
+	syncerConfig, err := newBinlogSyncerConfig(cfg, logger)
+	if err != nil {
+		return nil, err
+	}
+	// The events are written as they are read, and parsed by the reader
+	syncerConfig.RawModeEnabled = true
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Relay{
+		logger: logger,
+		syncer: replication.NewBinlogSyncer(syncerConfig),
+		writer: w,
+		ctx:    ctx,
+		cancel: cancel,
+	}, nil
+}
+
+// Run copies the binlog following a GTID set until the relay is closed or
+// the source is lost
+func (r *Relay) Run(gtidSet string) error {
+	set, err := gomysql.ParseMysqlGTIDSet(gtidSet)
+	if err != nil {
+		return err
+	}
+	r.logger.Printf("mysql.relay: Relaying binlog from %v", gtidSet)
+	streamer, err := r.syncer.StartSyncGTID(set)
+	if err != nil {
+		return err
+	}
+	for {
+		ev, err := streamer.GetEvent(r.ctx)
+		if r.ctx.Err() != nil {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		if err := r.writer.WriteEvent(ev.RawData); err != nil {
+			return fmt.Errorf("writing the event to the relay: %v", err)
+		}
+	}
+}
+
+// Close stops the relay
+func (r *Relay) Close() {
+	r.cancel()
+	r.syncer.Close()
+}
+
+// ReadFromRelay makes the reader read the binlog from the server of a relay
+// listening on addr, as it does from the source, before it is connected
+func (b *BinlogReader) ReadFromRelay(addr net.Addr, user, password string) {
+	tcpAddr := addr.(*net.TCPAddr)
+	syncerConfig := b.binlogSyncerConfig
+	syncerConfig.Host = tcpAddr.IP.String()
+	syncerConfig.Port = uint16(tcpAddr.Port)
+	syncerConfig.User = user
+	syncerConfig.Password = password
+	syncerConfig.TLSConfig = nil
+	b.binlogSyncer.Close()
+	b.binlogSyncerConfig = syncerConfig
+	b.binlogSyncer = replication.NewBinlogSyncer(syncerConfig)
+}
@@ -0,0 +1,50 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package binlog
+
+import (
+	"fmt"
+
+	gomysql "github.com/siddontang/go-mysql/mysql"
+)
+
+// SetTableWatermarks sets the GTID sets the tables were copied at, by
+// "schema.table", when they were copied in snapshots of their own. The row
+// events of a table in its set are already in the copy, and are skipped.
+func (b *BinlogReader) SetTableWatermarks(watermarks map[string]string) error {
+	b.tableWatermarks = make(map[string]*gomysql.MysqlGTIDSet)
+	for table, gtidSet := range watermarks {
+		set, err := gomysql.ParseMysqlGTIDSet(gtidSet)
+		if err != nil {
+			return fmt.Errorf("parsing the GTID set %s was copied at: %v", table, err)
+		}
+		b.tableWatermarks[table] = set.(*gomysql.MysqlGTIDSet)
+	}
+	return nil
+}
+
+// inTableWatermark tells whether the current transaction is in the copy of a
+// table
+func (b *BinlogReader) inTableWatermark(schemaName string, tableName string) bool {
+	if len(b.tableWatermarks) == 0 {
+		return false
+	}
+	set, ok := b.tableWatermarks[fmt.Sprintf("%s.%s", schemaName, tableName)]
+	if !ok {
+		return false
+	}
+	uuidSet, ok := set.Sets[b.currentCoordinates.SID.String()]
+	if !ok {
+		return false
+	}
+	for _, interval := range uuidSet.Intervals {
+		if b.currentCoordinates.GNO >= interval.Start && b.currentCoordinates.GNO < interval.Stop {
+			return true
+		}
+	}
+	return false
+}
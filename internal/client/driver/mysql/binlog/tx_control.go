@@ -0,0 +1,115 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package binlog
+
+import (
+	"strings"
+	"sync/atomic"
+
+	"github.com/actiontech/dtle/internal/models"
+)
+
+// The transaction control statements logged as query events, besides BEGIN
+// and COMMIT
+const (
+	txControlNone = iota
+	// txControlXAStart starts an XA transaction, as BEGIN
+	txControlXAStart
+	// txControlXAEnd ends the statements of an XA transaction
+	txControlXAEnd
+	// txControlXACommit commits a prepared XA transaction, in a transaction
+	// of its own, or with ONE PHASE one not prepared, as COMMIT
+	txControlXACommit
+	// txControlXARollback rolls back a prepared XA transaction, in a
+	// transaction of its own
+	txControlXARollback
+	// txControlSavepoint sets, rolls back to or releases a savepoint. Rows
+	// rolled back to a savepoint are not logged.
+	txControlSavepoint
+)
+
+// txControlStatement returns the kind of transaction control statement a
+// query is, or txControlNone
+func txControlStatement(query string) int {
+	words := strings.Fields(strings.ToUpper(query))
+	if len(words) < 2 {
+		return txControlNone
+	}
+	switch words[0] {
+	case "XA":
+		switch words[1] {
+		case "START", "BEGIN":
+			return txControlXAStart
+		case "END":
+			return txControlXAEnd
+		case "COMMIT":
+			return txControlXACommit
+		case "ROLLBACK":
+			return txControlXARollback
+		}
+	case "SAVEPOINT":
+		return txControlSavepoint
+	case "ROLLBACK":
+		// ROLLBACK [WORK] TO [SAVEPOINT] name
+		if words[1] == "TO" || len(words) > 2 && words[1] == "WORK" && words[2] == "TO" {
+			return txControlSavepoint
+		}
+	case "RELEASE":
+		if words[1] == "SAVEPOINT" {
+			return txControlSavepoint
+		}
+	}
+	return txControlNone
+}
+
+// handleTxControlQuery handles a transaction control query. A prepared XA
+// transaction is applied on XA_PREPARE_LOG_EVENT, as any transaction, so
+// its XA COMMIT and XA ROLLBACK only record their GTID on the target.
+// It returns false if the query is not a transaction control statement.
+func (b *BinlogReader) handleTxControlQuery(query string, entriesChannel chan<- *BinlogEntry) bool {
+	switch txControlStatement(query) {
+	case txControlXAStart:
+		b.currentBinlogEntry.hasBeginQuery = true
+	case txControlXAEnd:
+	case txControlXACommit:
+		atomic.AddInt64(&b.txControlStat.XaCommitted, 1)
+		b.sendEntry(entriesChannel)
+	case txControlXARollback:
+		atomic.AddInt64(&b.txControlStat.XaRolledBack, 1)
+		b.logger.Errorf("mysql.reader: %s:%d rolls back a prepared XA transaction already applied on the target: %s",
+			b.currentCoordinates.SID, b.currentCoordinates.GNO, query)
+		b.sendEntry(entriesChannel)
+	case txControlSavepoint:
+		atomic.AddInt64(&b.txControlStat.Savepoints, 1)
+		b.logger.Debugf("mysql.reader: skip savepoint statement %s", query)
+	default:
+		return false
+	}
+	return true
+}
+
+// handleXAPrepare sends an XA transaction on its prepare, its rows being
+// logged before it
+func (b *BinlogReader) handleXAPrepare(entriesChannel chan<- *BinlogEntry) {
+	atomic.AddInt64(&b.txControlStat.XaPrepared, 1)
+	b.sendEntry(entriesChannel)
+}
+
+func (b *BinlogReader) sendEntry(entriesChannel chan<- *BinlogEntry) {
+	entriesChannel <- b.currentBinlogEntry
+	b.LastAppliedRowsEventHint = b.currentCoordinates
+}
+
+// TxControlStat returns the counts of the XA and savepoint statements read
+func (b *BinlogReader) TxControlStat() *models.TxControlStat {
+	return &models.TxControlStat{
+		XaPrepared:   atomic.LoadInt64(&b.txControlStat.XaPrepared),
+		XaCommitted:  atomic.LoadInt64(&b.txControlStat.XaCommitted),
+		XaRolledBack: atomic.LoadInt64(&b.txControlStat.XaRolledBack),
+		Savepoints:   atomic.LoadInt64(&b.txControlStat.Savepoints),
+	}
+}
@@ -0,0 +1,88 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package binlog
+
+import (
+	"sync"
+	"testing"
+
+	gomysql "github.com/siddontang/go-mysql/mysql"
+	"github.com/siddontang/go-mysql/replication"
+)
+
+func Test_txControlStatement(t *testing.T) {
+	for _, tt := range []struct {
+		query string
+		want  int
+	}{
+		{"XA START 'xid1'", txControlXAStart},
+		{"XA BEGIN 'xid1'", txControlXAStart},
+		{"XA END 'xid1'", txControlXAEnd},
+		{"XA COMMIT 'xid1'", txControlXACommit},
+		{"XA COMMIT 'xid1' ONE PHASE", txControlXACommit},
+		{"XA ROLLBACK 'xid1'", txControlXARollback},
+		{"xa start 'xid1'", txControlXAStart},
+		{"  XA\n\tEND   'xid1' ", txControlXAEnd},
+		{"XA PREPARE 'xid1'", txControlNone},
+		{"SAVEPOINT sp1", txControlSavepoint},
+		{"savepoint sp1", txControlSavepoint},
+		{"ROLLBACK TO sp1", txControlSavepoint},
+		{"ROLLBACK TO SAVEPOINT sp1", txControlSavepoint},
+		{"ROLLBACK WORK TO SAVEPOINT sp1", txControlSavepoint},
+		{"rollback\twork to sp1", txControlSavepoint},
+		{"RELEASE SAVEPOINT sp1", txControlSavepoint},
+		{"release  savepoint sp1", txControlSavepoint},
+		{"ROLLBACK", txControlNone},
+		{"ROLLBACK WORK", txControlNone},
+		{"RELEASE sp1", txControlNone},
+		{"BEGIN", txControlNone},
+		{"COMMIT", txControlNone},
+		{"XA", txControlNone},
+		{"", txControlNone},
+		{"CREATE TABLE savepoint (id int)", txControlNone},
+	} {
+		if got := txControlStatement(tt.query); got != tt.want {
+			t.Errorf("txControlStatement(%q) = %v, want %v", tt.query, got, tt.want)
+		}
+	}
+}
+
+func TestBinlogReader_updateCommittedGtidSet(t *testing.T) {
+	gset, err := gomysql.ParseMysqlGTIDSet("00000000-0000-0000-0000-000000000001:1-10")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range []struct {
+		query string
+		want  bool
+	}{
+		{"BEGIN", false},
+		{" begin ", false},
+		{"XA START 'xid1'", false},
+		{"XA END 'xid1'", false},
+		{"SAVEPOINT sp1", false},
+		{"ROLLBACK WORK TO sp1", false},
+		{"RELEASE SAVEPOINT sp1", false},
+		{"XA COMMIT 'xid1'", true},
+		{"XA ROLLBACK 'xid1'", true},
+		{"CREATE TABLE t (id int)", true},
+	} {
+		b := &BinlogReader{currentCoordinatesMutex: &sync.Mutex{}, committedGtidSet: "before"}
+		b.updateCommittedGtidSet(&replication.BinlogEvent{
+			Event: &replication.QueryEvent{Query: []byte(tt.query), GSet: gset},
+		})
+		if got := b.CommittedGtidSet() != "before"; got != tt.want {
+			t.Errorf("updateCommittedGtidSet(%q) updated = %v, want %v", tt.query, got, tt.want)
+		}
+	}
+
+	b := &BinlogReader{currentCoordinatesMutex: &sync.Mutex{}}
+	b.updateCommittedGtidSet(&replication.BinlogEvent{Event: &replication.XIDEvent{GSet: gset}})
+	if got := b.CommittedGtidSet(); got != gset.String() {
+		t.Errorf("updateCommittedGtidSet() of a commit = %q, want %q", got, gset.String())
+	}
+}
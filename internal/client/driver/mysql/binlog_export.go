@@ -0,0 +1,286 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package mysql
+
+import (
+	gosql "database/sql"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	gomysql "github.com/siddontang/go-mysql/mysql"
+
+	"github.com/actiontech/dtle/internal/client/driver/mysql/base"
+	"github.com/actiontech/dtle/internal/client/driver/mysql/binlog"
+	"github.com/actiontech/dtle/internal/client/driver/mysql/sql"
+	"github.com/actiontech/dtle/internal/config"
+	umconf "github.com/actiontech/dtle/internal/config/mysql"
+	log "github.com/actiontech/dtle/internal/logger"
+	"github.com/actiontech/dtle/internal/models"
+)
+
+// BinlogExportOptions bounds the transactions ExportBinlog exports
+type BinlogExportOptions struct {
+	// StartGtid is the GTID set executed before the first transaction
+	// exported. The binlog is read from the oldest one kept if it is empty.
+	StartGtid string
+	// StopGtid is the GTID set executed once the last transaction is
+	// exported, that executed by the source when the export starts if it is
+	// empty
+	StopGtid string
+	// StartTime and StopTime bound the commit times of the transactions
+	// exported, if not zero
+	StartTime time.Time
+	StopTime  time.Time
+	// Flashback exports the statements reverting the transactions, the last
+	// one first, instead of those applying them
+	Flashback bool
+}
+
+// ExportBinlog writes to w the SQL of the transactions of the binlog of a
+// source passing the filters of its config, for ad-hoc recoveries. The rows
+// are decoded with the columns the tables have now.
+func ExportBinlog(cfg *config.MySQLDriverConfig, opts *BinlogExportOptions, w io.Writer, logger *log.Logger) error {
+	cfg = cfg.SetDefault()
+	if err := cfg.ConnectionConfig.Prepare(); err != nil {
+		return err
+	}
+	entry := log.NewEntry(logger).WithFields(log.Fields{
+		"binlog": "export",
+	})
+	db, err := sql.CreateDB(cfg.ConnectionConfig.GetDBUri())
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	startGtid := opts.StartGtid
+	if startGtid == "" {
+		if err := db.QueryRow("select @@global.gtid_purged").Scan(&startGtid); err != nil {
+			return err
+		}
+	}
+	stopGtid := opts.StopGtid
+	if stopGtid == "" {
+		coordinates, err := base.GetSelfBinlogCoordinates(db)
+		if err != nil {
+			return err
+		}
+		stopGtid = coordinates.GtidSet
+	}
+	stopSet, err := gomysql.ParseMysqlGTIDSet(stopGtid)
+	if err != nil {
+		return fmt.Errorf("invalid stop GTID set %q: %v", stopGtid, err)
+	}
+
+	// The 'where' of the tables are resolved with their columns
+	for _, doDb := range cfg.ReplicateDoDb {
+		for _, table := range doDb.Tables {
+			if table.OriginalTableColumns != nil {
+				continue
+			}
+			table.TableSchema = doDb.TableSchema
+			columns, err := base.GetTableColumns(db, doDb.TableSchema, table.TableName)
+			if err != nil {
+				entry.Warnf("mysql.export: inspecting %s.%s: %v", doDb.TableSchema, table.TableName, err)
+				continue
+			}
+			if err := base.ApplyColumnTypes(db, doDb.TableSchema, table.TableName, columns); err != nil {
+				return err
+			}
+			table.OriginalTableColumns = columns
+		}
+	}
+
+	reader, err := binlog.NewMySQLReader(cfg, entry, cfg.ReplicateDoDb)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+	if err := reader.ConnectBinlogStreamer(base.BinlogCoordinatesX{GtidSet: startGtid}); err != nil {
+		return err
+	}
+	entry.Printf("mysql.export: exporting the transactions after %s up to %s", startGtid, stopGtid)
+
+	exporter := &binlogExporter{
+		db:        db,
+		w:         w,
+		flashback: opts.Flashback,
+		columns:   make(map[string]*umconf.ColumnList),
+	}
+	entriesCh := make(chan *binlog.BinlogEntry)
+	errCh := make(chan error, 1)
+	go func() {
+		defer models.RecoverPanic(func(err *models.PanicError) {
+			errCh <- err
+		})
+		errCh <- reader.DataStreamEvents(entriesCh)
+	}()
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case binlogEntry := <-entriesCh:
+			gtidSet, err := gomysql.ParseMysqlGTIDSet(
+				fmt.Sprintf("%s:%d", binlogEntry.Coordinates.GetSid(), binlogEntry.Coordinates.GNO))
+			if err != nil {
+				return err
+			}
+			commitTime := time.Unix(int64(binlogEntry.Timestamp), 0)
+			if !stopSet.Contain(gtidSet) || !opts.StopTime.IsZero() && commitTime.After(opts.StopTime) {
+				return exporter.finish()
+			}
+			if !opts.StartTime.IsZero() && commitTime.Before(opts.StartTime) {
+				continue
+			}
+			if err := exporter.add(binlogEntry); err != nil {
+				return err
+			}
+		case err := <-errCh:
+			return err
+		case <-ticker.C:
+			// The last transactions may be filtered out
+			committed, err := gomysql.ParseMysqlGTIDSet(reader.CommittedGtidSet())
+			if err != nil {
+				return err
+			}
+			if committed.Contain(stopSet) {
+				return exporter.finish()
+			}
+		}
+	}
+}
+
+// binlogExporter writes the SQL of the transactions of a binlog export
+type binlogExporter struct {
+	db        *gosql.DB
+	w         io.Writer
+	flashback bool
+	// columns are the columns of the tables, by schema and name
+	columns map[string]*umconf.ColumnList
+	// txs are the statements of the transactions to revert, written the
+	// last one first once all are read
+	txs [][]string
+}
+
+// add writes the SQL of a transaction, or keeps that reverting it
+func (x *binlogExporter) add(binlogEntry *binlog.BinlogEntry) error {
+	if len(binlogEntry.Events) == 0 {
+		return nil
+	}
+	var statements []string
+	for i := range binlogEntry.Events {
+		event := &binlogEntry.Events[i]
+		if event.DML == binlog.NotDML {
+			schema := event.DatabaseName
+			if schema == "" {
+				schema = event.CurrentSchema
+			}
+			// A table altered is inspected again
+			delete(x.columns, fmt.Sprintf("%s.%s", schema, event.TableName))
+			if x.flashback {
+				statements = append(statements,
+					fmt.Sprintf("-- not reverted: %s", strings.Join(strings.Fields(event.Query), " ")))
+				continue
+			}
+			if event.CurrentSchema != "" {
+				statements = append(statements, fmt.Sprintf("USE %s", sql.EscapeName(event.CurrentSchema)))
+			}
+			statements = append(statements, event.Query)
+			continue
+		}
+		columns, err := x.tableColumns(event.DatabaseName, event.TableName)
+		if err != nil {
+			return err
+		}
+		query, err := dmlEventQueryText(exportedEvent(event, columns, x.flashback), columns)
+		if err != nil {
+			return err
+		}
+		statements = append(statements, query)
+	}
+	if x.flashback {
+		for i, j := 0, len(statements)-1; i < j; i, j = i+1, j-1 {
+			statements[i], statements[j] = statements[j], statements[i]
+		}
+	}
+
+	queries := []string{fmt.Sprintf("-- gtid %s:%d committed at %s",
+		binlogEntry.Coordinates.GetSid(), binlogEntry.Coordinates.GNO,
+		time.Unix(int64(binlogEntry.Timestamp), 0).Format("2006-01-02 15:04:05"))}
+	queries = append(queries, "BEGIN")
+	queries = append(queries, statements...)
+	queries = append(queries, "COMMIT")
+	if x.flashback {
+		x.txs = append(x.txs, queries)
+		return nil
+	}
+	_, err := x.w.Write(formatStatements(queries...))
+	return err
+}
+
+// finish writes the transactions reverting those exported, the last one
+// first
+func (x *binlogExporter) finish() error {
+	for i := len(x.txs) - 1; i >= 0; i-- {
+		if _, err := x.w.Write(formatStatements(x.txs[i]...)); err != nil {
+			return err
+		}
+	}
+	x.txs = nil
+	return nil
+}
+
+// tableColumns returns the columns of a table, as the source has them now
+func (x *binlogExporter) tableColumns(schema, table string) (*umconf.ColumnList, error) {
+	key := fmt.Sprintf("%s.%s", schema, table)
+	if columns, ok := x.columns[key]; ok {
+		return columns, nil
+	}
+	columns, err := base.GetTableColumns(x.db, schema, table)
+	if err != nil {
+		return nil, err
+	}
+	if err := base.ApplyColumnTypes(x.db, schema, table, columns); err != nil {
+		return nil, err
+	}
+	x.columns[key] = columns
+	return columns, nil
+}
+
+// exportedEvent returns the row event applying, or reverting, a row event
+// of the binlog, with its unsigned values converted per the columns of the
+// table
+func exportedEvent(event *binlog.DataEvent, columns *umconf.ColumnList, flashback bool) *binlog.DataEvent {
+	table := &config.TableContext{Table: &config.Table{OriginalTableColumns: columns}}
+	exported := *event
+	exported.WhereColumnValues = unsignedValues(event.WhereColumnValues, table)
+	exported.NewColumnValues = unsignedValues(event.NewColumnValues, table)
+	if !flashback {
+		return &exported
+	}
+	exported.WhereColumnValues, exported.NewColumnValues = exported.NewColumnValues, exported.WhereColumnValues
+	switch event.DML {
+	case binlog.InsertDML:
+		exported.DML = binlog.DeleteDML
+	case binlog.DeleteDML:
+		exported.DML = binlog.InsertDML
+	}
+	return &exported
+}
+
+func unsignedValues(values *umconf.ColumnValues, table *config.TableContext) *umconf.ColumnValues {
+	if values == nil {
+		return nil
+	}
+	abstractValues := make([]interface{}, len(values.AbstractValues))
+	for i, v := range values.AbstractValues {
+		abstractValues[i] = *v
+	}
+	return binlog.ToColumnValuesV2(abstractValues, table)
+}
@@ -0,0 +1,219 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package mysql
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/actiontech/dtle/internal/client/driver/mysql/base"
+	"github.com/actiontech/dtle/internal/client/driver/mysql/binlog"
+	"github.com/actiontech/dtle/internal/client/driver/mysql/binlogserver"
+	"github.com/actiontech/dtle/internal/config"
+	"github.com/actiontech/dtle/internal/models"
+)
+
+const (
+	// binlogRelayFileSize is the bytes after which a binlog of the relay is
+	// rotated, small enough for MaxSize to be kept close
+	binlogRelayFileSize = 64 << 20
+	// binlogRelayAddr is the address the binlog readers read the relay from
+	binlogRelayAddr = "127.0.0.1:0"
+	// binlogRelayUser is the user the binlog readers read the relay as, with
+	// the password generated by the relay
+	binlogRelayUser = "dtle"
+)
+
+// binlogRelays are the extractors of the agent relaying their source, by
+// job ID, whose relays the extractors of other jobs may read
+var binlogRelays = struct {
+	sync.Mutex
+	m map[string]*Extractor
+}{m: make(map[string]*Extractor)}
+
+// validateBinlogRelay checks BinlogRelay against the other job arguments
+func (e *Extractor) validateBinlogRelay() error {
+	cfg := e.mysqlContext.BinlogRelay
+	if cfg == nil {
+		return nil
+	}
+	if cfg.Dir == "" && cfg.Job == "" {
+		return fmt.Errorf("job argument BinlogRelay requires Dir or Job")
+	}
+	if cfg.Job == e.subject {
+		return fmt.Errorf("job argument BinlogRelay.Job is the job itself")
+	}
+	if e.mysqlContext.SkipIncrementalCopy || e.mysqlContext.CopyMode == config.CopyModeSchemaOnly {
+		return fmt.Errorf("conflicting job argument: BinlogRelay and no incremental copy")
+	}
+	return nil
+}
+
+// startBinlogRelay opens the relay, which the binlog reader reads from
+// gtidSet on, and starts relaying the source from the last transaction the
+// relay has. A relay which purged transactions following gtidSet is read
+// from the source again.
+func (e *Extractor) startBinlogRelay(gtidSet string) error {
+	cfg := e.mysqlContext.BinlogRelay
+	store, err := binlogserver.OpenStore(cfg.Dir, binlogRelayFileSize, 0, e.logger)
+	if err != nil {
+		return fmt.Errorf("opening the binlog relay: %v", err)
+	}
+	if has, err := store.Has(gtidSet); err != nil {
+		store.Close()
+		return err
+	} else if !has {
+		e.logger.Warnf("mysql.extractor: the binlog relay purged transactions following %v, relaying the source again",
+			gtidSet)
+		if err := store.Reset(); err != nil {
+			store.Close()
+			return err
+		}
+	}
+	if err := store.Start(gtidSet); err != nil {
+		store.Close()
+		return err
+	}
+
+	password := models.GenerateUUID()
+	server := binlogserver.NewServer(&binlogserver.Config{
+		Addr:          binlogRelayAddr,
+		User:          binlogRelayUser,
+		Password:      password,
+		ServerVersion: e.mysqlContext.MySQLVersion,
+	}, store, e.logger)
+	if err := server.Start(); err != nil {
+		store.Close()
+		return fmt.Errorf("serving the binlog relay: %v", err)
+	}
+	relay, err := binlog.NewRelay(e.mysqlContext, store, e.logger)
+	if err != nil {
+		server.Shutdown()
+		store.Close()
+		return err
+	}
+	e.relayStore = store
+	e.relayServer = server
+	e.relayPassword = password
+	e.relay = relay
+	e.relayFrom = e
+
+	binlogRelays.Lock()
+	binlogRelays.m[e.subject] = e
+	binlogRelays.Unlock()
+
+	go e.runBinlogRelay()
+	go e.purgeBinlogRelay(time.Duration(cfg.RetentionHours)*time.Hour, cfg.MaxSize)
+	return nil
+}
+
+// attachBinlogRelay makes the binlog reader read the relay of another job
+// of the agent, from a GTID set the relay has
+func (e *Extractor) attachBinlogRelay(gtidSet string) error {
+	job := e.mysqlContext.BinlogRelay.Job
+	binlogRelays.Lock()
+	from, ok := binlogRelays.m[job]
+	binlogRelays.Unlock()
+	if !ok {
+		return fmt.Errorf("job %s has no binlog relay on this node", job)
+	}
+	if has, err := from.relayStore.Has(gtidSet); err != nil {
+		return err
+	} else if !has {
+		return fmt.Errorf("the binlog relay of job %s purged transactions following %v", job, gtidSet)
+	}
+	e.relayFrom = from
+	e.logger.Printf("mysql.extractor: reading the binlog relay of job %s from %v", job, gtidSet)
+	return nil
+}
+
+// readFromRelay makes a binlog reader read the relay the extractor reads
+func (e *Extractor) readFromRelay(reader *binlog.BinlogReader) error {
+	from := e.relayFrom
+	if from.shutdown {
+		return fmt.Errorf("job %s stopped relaying its source", from.subject)
+	}
+	reader.ReadFromRelay(from.relayServer.Addr(), binlogRelayUser, from.relayPassword)
+	return nil
+}
+
+// runBinlogRelay relays the source until the extractor shuts down. The task
+// is restarted if the source is lost, resuming the relay on the source
+// selected then.
+func (e *Extractor) runBinlogRelay() {
+	defer models.RecoverPanic(e.onPanic)
+	if err := e.relay.Run(e.relayStore.Executed()); err != nil && !e.shutdown {
+		e.onError(TaskStateRestart, fmt.Errorf("mysql.extractor: relaying the binlog: %v", err))
+	}
+}
+
+// purgeBinlogRelay removes the transactions of the relay past its retention
+// or its size until the extractor shuts down
+func (e *Extractor) purgeBinlogRelay(retention time.Duration, maxSize int64) {
+	defer models.RecoverPanic(e.onPanic)
+	ticker := time.NewTicker(binlogServerPurgeInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if err := e.relayStore.Purge(retention, maxSize); err != nil {
+				e.logger.Warnf("mysql.extractor: purging the binlog relay: %v", err)
+			}
+		case <-e.shutdownCh:
+			return
+		}
+	}
+}
+
+// reconnectRelay replaces the binlog reader with one reading the relay
+// again, from the given GTID set. The reader of the relay of another job
+// fails once the other job stops.
+func (e *Extractor) reconnectRelay(gtidSet string) error {
+	reader, err := binlog.NewMySQLReader(e.mysqlContext, e.logger, e.replicateDoDb)
+	if err != nil {
+		return err
+	}
+	if err := e.readFromRelay(reader); err != nil {
+		reader.Close()
+		return err
+	}
+	if e.binlogStore != nil {
+		reader.SetRawEventWriter(e.binlogStore)
+	}
+	if err := reader.ConnectBinlogStreamer(base.BinlogCoordinatesX{GtidSet: gtidSet}); err != nil {
+		reader.Close()
+		return err
+	}
+
+	if err := e.binlogReader.Close(); err != nil {
+		e.logger.Warnf("mysql.extractor: closing the lost binlog reader: %v", err)
+	}
+	e.binlogReader = reader
+	e.logger.Printf("mysql.extractor: resumed binlog streaming on the relay from %v", gtidSet)
+	return nil
+}
+
+// stopBinlogRelay stops relaying the source and closes the relay, once the
+// binlog reader is closed
+func (e *Extractor) stopBinlogRelay() error {
+	binlogRelays.Lock()
+	if binlogRelays.m[e.subject] == e {
+		delete(binlogRelays.m, e.subject)
+	}
+	binlogRelays.Unlock()
+	if e.relay != nil {
+		e.relay.Close()
+	}
+	if e.relayServer != nil {
+		e.relayServer.Shutdown()
+	}
+	if e.relayStore != nil {
+		return e.relayStore.Close()
+	}
+	return nil
+}
@@ -0,0 +1,135 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package mysql
+
+import (
+	gosql "database/sql"
+	"fmt"
+	"strings"
+
+	gomysql "github.com/siddontang/go-mysql/mysql"
+)
+
+// binaryLog is a binlog file listed by SHOW BINARY LOGS
+type binaryLog struct {
+	name string
+	size int64
+}
+
+// CheckBinlogRetention tells whether the source still has the binlogs a job
+// resuming from a GTID set needs. It returns a warning if they have been
+// purged, or if they start in the oldest binlog of the source, which the
+// next purge removes. It returns an empty string if the binlogs are safe.
+// The warnings stay the same until the binlogs change, for the callers to
+// tell new warnings apart.
+func CheckBinlogRetention(db *gosql.DB, gtidSet string) (string, error) {
+	checkpoint, err := gomysql.ParseMysqlGTIDSet(gtidSet)
+	if err != nil {
+		return "", err
+	}
+
+	var purgedStr string
+	if err := db.QueryRow("SELECT @@global.gtid_purged").Scan(&purgedStr); err != nil {
+		return "", err
+	}
+	purged, err := gomysql.ParseMysqlGTIDSet(purgedStr)
+	if err != nil {
+		return "", err
+	}
+	if !checkpoint.Contain(purged) {
+		return fmt.Sprintf("the binlogs after the checkpoint %v of the job have been purged from the source", gtidSet), nil
+	}
+
+	logs, err := showBinaryLogs(db)
+	if err != nil {
+		return "", err
+	}
+	if len(logs) < 2 {
+		return "", nil
+	}
+	// The job needs the newest binlog starting before the checkpoint, and
+	// those after it
+	needed := -1
+	for i := len(logs) - 1; i >= 0; i-- {
+		previous, err := previousGtids(db, logs[i].name)
+		if err != nil {
+			return "", err
+		}
+		set, err := gomysql.ParseMysqlGTIDSet(previous)
+		if err != nil {
+			return "", err
+		}
+		if checkpoint.Contain(set) {
+			needed = i
+			break
+		}
+	}
+	if needed != 0 {
+		return "", nil
+	}
+	return fmt.Sprintf("the job needs %s, the oldest binlog of the source, which the next purge removes", logs[0].name), nil
+}
+
+func showBinaryLogs(db *gosql.DB) ([]*binaryLog, error) {
+	rows, err := db.Query("SHOW BINARY LOGS")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+
+	var logs []*binaryLog
+	for rows.Next() {
+		// 8.0 adds an Encrypted column
+		l := &binaryLog{}
+		dest := []interface{}{&l.name, &l.size}
+		for len(dest) < len(columns) {
+			dest = append(dest, new(gosql.RawBytes))
+		}
+		if err := rows.Scan(dest...); err != nil {
+			return nil, err
+		}
+		logs = append(logs, l)
+	}
+	return logs, rows.Err()
+}
+
+// previousGtids returns the GTID set executed before a binlog, logged in
+// its Previous_gtids event
+func previousGtids(db *gosql.DB, name string) (string, error) {
+	rows, err := db.Query(fmt.Sprintf("SHOW BINLOG EVENTS IN '%s' LIMIT 4", strings.Replace(name, "'", "''", -1)))
+	if err != nil {
+		return "", err
+	}
+	defer rows.Close()
+	columns, err := rows.Columns()
+	if err != nil {
+		return "", err
+	}
+
+	for rows.Next() {
+		values := make([]gosql.NullString, len(columns))
+		dest := make([]interface{}, len(columns))
+		for i := range values {
+			dest[i] = &values[i]
+		}
+		if err := rows.Scan(dest...); err != nil {
+			return "", err
+		}
+		// Log_name, Pos, Event_type, Server_id, End_log_pos, Info
+		if values[2].String == "Previous_gtids" {
+			return values[5].String, nil
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return "", err
+	}
+	return "", fmt.Errorf("no Previous_gtids event in %s", name)
+}
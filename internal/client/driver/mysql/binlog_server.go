@@ -0,0 +1,95 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package mysql
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/actiontech/dtle/internal/client/driver/mysql/binlogserver"
+	"github.com/actiontech/dtle/internal/config"
+	"github.com/actiontech/dtle/internal/models"
+)
+
+const (
+	// binlogServerPurgeInterval is how often the binlogs of the binlog
+	// server past their retention are removed
+	binlogServerPurgeInterval = 10 * time.Minute
+)
+
+// validateBinlogServer checks BinlogServer against the other job arguments
+func (e *Extractor) validateBinlogServer() error {
+	cfg := e.mysqlContext.BinlogServer
+	if cfg == nil {
+		return nil
+	}
+	if cfg.Addr == "" || cfg.Dir == "" {
+		return fmt.Errorf("job argument BinlogServer requires Addr and Dir")
+	}
+	if e.mysqlContext.SkipIncrementalCopy || e.mysqlContext.CopyMode == config.CopyModeSchemaOnly {
+		return fmt.Errorf("conflicting job argument: BinlogServer and no incremental copy")
+	}
+	return nil
+}
+
+// startBinlogServer opens the store of the binlog server, which keeps the
+// binlog read from gtidSet on, and serves it to the replicas
+func (e *Extractor) startBinlogServer(gtidSet string) error {
+	cfg := e.mysqlContext.BinlogServer
+	store, err := binlogserver.OpenStore(cfg.Dir, cfg.MaxBinlogSize, cfg.ServerID, e.logger)
+	if err != nil {
+		return fmt.Errorf("opening the binlogs of the binlog server: %v", err)
+	}
+	if err := store.Start(gtidSet); err != nil {
+		store.Close()
+		return err
+	}
+	server := binlogserver.NewServer(&binlogserver.Config{
+		Addr:          cfg.Addr,
+		User:          cfg.User,
+		Password:      cfg.Password,
+		ServerVersion: e.mysqlContext.MySQLVersion,
+	}, store, e.logger)
+	if err := server.Start(); err != nil {
+		store.Close()
+		return fmt.Errorf("starting the binlog server: %v", err)
+	}
+	e.binlogStore = store
+	e.binlogServer = server
+	go e.purgeBinlogServer(time.Duration(cfg.RetentionHours) * time.Hour)
+	return nil
+}
+
+// purgeBinlogServer removes the binlogs of the binlog server past their
+// retention until the extractor shuts down
+func (e *Extractor) purgeBinlogServer(retention time.Duration) {
+	defer models.RecoverPanic(e.onPanic)
+	ticker := time.NewTicker(binlogServerPurgeInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if err := e.binlogStore.Purge(retention, 0); err != nil {
+				e.logger.Warnf("mysql.extractor: purging the binlogs of the binlog server: %v", err)
+			}
+		case <-e.shutdownCh:
+			return
+		}
+	}
+}
+
+// stopBinlogServer disconnects the replicas and closes the store of the
+// binlog server, once the binlog reader is closed
+func (e *Extractor) stopBinlogServer() error {
+	if e.binlogServer != nil {
+		e.binlogServer.Shutdown()
+	}
+	if e.binlogStore != nil {
+		return e.binlogStore.Close()
+	}
+	return nil
+}
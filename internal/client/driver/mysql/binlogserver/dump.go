@@ -0,0 +1,263 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package binlogserver
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	gomysql "github.com/siddontang/go-mysql/mysql"
+	"github.com/siddontang/go-mysql/replication"
+)
+
+const (
+	binlogDumpNonBlock = 0x01
+)
+
+// handleBinlogDump dumps the binlog from a binlog and a position of the
+// store
+func (c *conn) handleBinlogDump(data []byte) error {
+	if len(data) < 10 {
+		return c.writeError(gomysql.NewDefaultError(gomysql.ER_MALFORMED_PACKET))
+	}
+	pos := int64(binary.LittleEndian.Uint32(data))
+	flags := binary.LittleEndian.Uint16(data[4:])
+	name := string(data[10:])
+
+	binlogs := c.waitBinlogs()
+	if binlogs == nil {
+		return nil
+	}
+	if name == "" {
+		name = binlogs[0]
+	} else if !contains(binlogs, name) {
+		return c.writeDumpError("Could not find first log file name in binary log index file")
+	}
+	if pos < int64(len(replication.BinLogFileHeader)) {
+		pos = int64(len(replication.BinLogFileHeader))
+	}
+	return c.dump(name, pos, nil, flags&binlogDumpNonBlock != 0)
+}
+
+// handleBinlogDumpGTID dumps the binlog from the first transaction not in
+// the GTID set executed by the replica
+func (c *conn) handleBinlogDumpGTID(data []byte) error {
+	if len(data) < 10 {
+		return c.writeError(gomysql.NewDefaultError(gomysql.ER_MALFORMED_PACKET))
+	}
+	flags := binary.LittleEndian.Uint16(data)
+	nameLen := int(binary.LittleEndian.Uint32(data[6:]))
+	pos := 10 + nameLen + 8
+	if len(data) < pos {
+		return c.writeError(gomysql.NewDefaultError(gomysql.ER_MALFORMED_PACKET))
+	}
+
+	replicaSet := &gomysql.MysqlGTIDSet{Sets: make(map[string]*gomysql.UUIDSet)}
+	// The GTID set is read whatever the flags, as MySQL does
+	if len(data) >= pos+4 {
+		size := int(binary.LittleEndian.Uint32(data[pos:]))
+		pos += 4
+		if len(data) < pos+size {
+			return c.writeError(gomysql.NewDefaultError(gomysql.ER_MALFORMED_PACKET))
+		}
+		set, err := gomysql.DecodeMysqlGTIDSet(data[pos : pos+size])
+		if err != nil {
+			return c.writeDumpError(fmt.Sprintf("Invalid GTID set: %v", err))
+		}
+		replicaSet = set
+	}
+
+	binlogs := c.waitBinlogs()
+	if binlogs == nil {
+		return nil
+	}
+	_, purged := c.server.store.gtidSets()
+	if !replicaSet.Contain(purged) {
+		return c.writeDumpError("The slave is connecting using CHANGE MASTER TO MASTER_AUTO_POSITION = 1, " +
+			"but the master has purged binary logs containing GTIDs that the slave requires.")
+	}
+	// Start from the last binlog the replica has the previous transactions of
+	for i := len(binlogs) - 1; i >= 0; i-- {
+		previous, err := c.server.store.previousGTIDs(binlogs[i])
+		if err != nil {
+			return c.writeDumpError(err.Error())
+		}
+		if replicaSet.Contain(previous) {
+			return c.dump(binlogs[i], int64(len(replication.BinLogFileHeader)), replicaSet,
+				flags&binlogDumpNonBlock != 0)
+		}
+	}
+	return c.writeDumpError("The slave is connecting using CHANGE MASTER TO MASTER_AUTO_POSITION = 1, " +
+		"but the master has purged binary logs containing GTIDs that the slave requires.")
+}
+
+// waitBinlogs waits for the store to have a binlog, returning nil if the
+// server is shut down
+func (c *conn) waitBinlogs() []string {
+	for {
+		name, _, changed := c.server.store.position()
+		if name != "" {
+			return c.server.store.binlogs()
+		}
+		select {
+		case <-changed:
+		case <-c.server.shutdownCh:
+			return nil
+		}
+	}
+}
+
+// dump sends the binlog from a binlog and a position, following the
+// rotations and waiting for the events written to the last binlog. The
+// transactions of skip are not sent.
+func (c *conn) dump(name string, pos int64, skip *gomysql.MysqlGTIDSet, nonBlock bool) error {
+	serverID := c.server.store.ServerID()
+	checksum := strings.ToUpper(fmt.Sprint(c.vars["@master_binlog_checksum"])) == "CRC32"
+	if err := c.writeEvent(newRotateEvent(serverID, 0, name, uint64(pos), true, checksum)); err != nil {
+		return err
+	}
+
+	var heartbeat time.Duration
+	if period, err := strconv.ParseInt(fmt.Sprint(c.vars["@master_heartbeat_period"]), 10, 64); err == nil {
+		heartbeat = time.Duration(period)
+	}
+
+	for {
+		next, err := c.dumpBinlog(name, pos, skip, nonBlock, heartbeat)
+		if err != nil || next == "" {
+			return err
+		}
+		name, pos = next, int64(len(replication.BinLogFileHeader))
+	}
+}
+
+// dumpBinlog sends the events of a binlog from a position, and returns the
+// next binlog once it has sent its rotate event
+func (c *conn) dumpBinlog(name string, pos int64, skip *gomysql.MysqlGTIDSet, nonBlock bool,
+	heartbeat time.Duration) (next string, err error) {
+	f, err := os.Open(c.server.store.path(name))
+	if os.IsNotExist(err) {
+		return "", c.writeDumpError(fmt.Sprintf("Could not open log file %s, purged", name))
+	} else if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	var fde event
+	if err := readEvents(f, 0, func(ev event, end int64) (bool, error) {
+		fde = ev
+		return false, nil
+	}); err != nil {
+		return "", err
+	}
+	if fde == nil || fde.eventType() != replication.FORMAT_DESCRIPTION_EVENT {
+		return "", fmt.Errorf("no format description event in %s", name)
+	}
+	checksum := fde.checksumAlg() == replication.BINLOG_CHECKSUM_ALG_CRC32
+	if pos > int64(len(replication.BinLogFileHeader)) {
+		// The replica does not take the position of the format description
+		// event sent out of its place
+		if err := c.writeEvent(fde.withLogPos(0, checksum)); err != nil {
+			return "", err
+		}
+	}
+
+	var skipping, inBegin bool
+	for {
+		current, end, changed := c.server.store.position()
+		if name != current {
+			fi, err := f.Stat()
+			if err != nil {
+				return "", err
+			}
+			end = fi.Size()
+		}
+
+		err := readEvents(io.NewSectionReader(f, 0, end), pos, func(ev event, evEnd int64) (bool, error) {
+			pos = evEnd
+			switch ev.eventType() {
+			case replication.ROTATE_EVENT:
+				next = string(ev.body(checksum)[8:])
+				return false, c.writeEvent(ev)
+			case replication.GTID_EVENT:
+				if skip != nil {
+					gtid, err := ev.gtid()
+					if err != nil {
+						return false, err
+					}
+					skipping = skip.Contain(&gomysql.MysqlGTIDSet{
+						Sets: map[string]*gomysql.UUIDSet{gtid.SID.String(): gtid},
+					})
+				}
+				inBegin = false
+			}
+			if !skipping {
+				if err := c.writeEvent(ev); err != nil {
+					return false, err
+				}
+			}
+			txEnd, begin := transactionEnd(ev, inBegin, checksum)
+			inBegin = inBegin || begin
+			if txEnd {
+				skipping = false
+				inBegin = false
+			}
+			return true, nil
+		})
+		if err != nil || next != "" {
+			return next, err
+		}
+		if name != current {
+			// A binlog not ended by a rotate event, which the store does not
+			// write
+			return "", fmt.Errorf("binlog %s ends without a rotate event", name)
+		}
+
+		if nonBlock {
+			return "", c.writeEOF()
+		}
+		var heartbeatCh <-chan time.Time
+		if heartbeat > 0 {
+			heartbeatCh = time.After(heartbeat)
+		}
+		select {
+		case <-changed:
+		case <-heartbeatCh:
+			if err := c.writeEvent(newHeartbeatEvent(c.server.store.ServerID(), name, uint32(pos), checksum)); err != nil {
+				return "", err
+			}
+		case <-c.server.shutdownCh:
+			return "", nil
+		}
+	}
+}
+
+func (c *conn) writeEvent(ev event) error {
+	data := make([]byte, 5, 5+len(ev))
+	data[4] = gomysql.OK_HEADER
+	data = append(data, ev...)
+	return c.WritePacket(data)
+}
+
+// writeDumpError ends a dump with the error the replicas stop on
+func (c *conn) writeDumpError(message string) error {
+	return c.writeError(gomysql.NewError(gomysql.ER_MASTER_FATAL_ERROR_READING_BINLOG, message))
+}
+
+func contains(names []string, name string) bool {
+	for _, n := range names {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
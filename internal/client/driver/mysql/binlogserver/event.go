@@ -0,0 +1,191 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package binlogserver
+
+import (
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"strings"
+	"time"
+
+	"github.com/satori/go.uuid"
+	gomysql "github.com/siddontang/go-mysql/mysql"
+	"github.com/siddontang/go-mysql/replication"
+)
+
+const (
+	headerSize   = replication.EventHeaderSize
+	checksumSize = replication.BinlogChecksumLength
+
+	// logEventArtificialF flags the events made up by the server, such as
+	// the rotate starting a dump, which the replica does not log
+	logEventArtificialF = 0x20
+
+	// xaPrepareLogEvent ends the XA transactions prepared, which go-mysql
+	// does not know
+	xaPrepareLogEvent replication.EventType = 38
+)
+
+// event is a raw binlog event: its 19 bytes header, its body and its
+// checksum, if any
+type event []byte
+
+func (ev event) eventType() replication.EventType {
+	return replication.EventType(ev[4])
+}
+
+func (ev event) size() uint32 {
+	return binary.LittleEndian.Uint32(ev[9:])
+}
+
+func (ev event) logPos() uint32 {
+	return binary.LittleEndian.Uint32(ev[13:])
+}
+
+// body returns the body of an event without its checksum
+func (ev event) body(checksum bool) []byte {
+	if checksum {
+		return ev[headerSize : len(ev)-checksumSize]
+	}
+	return ev[headerSize:]
+}
+
+// withLogPos returns a copy of an event with another end position, whose
+// checksum is computed again
+func (ev event) withLogPos(pos uint32, checksum bool) event {
+	c := make(event, len(ev))
+	copy(c, ev)
+	binary.LittleEndian.PutUint32(c[13:], pos)
+	if checksum {
+		c.updateChecksum()
+	}
+	return c
+}
+
+func (ev event) updateChecksum() {
+	n := len(ev) - checksumSize
+	binary.LittleEndian.PutUint32(ev[n:], crc32.ChecksumIEEE(ev[:n]))
+}
+
+// checksumAlg returns the checksum algorithm of the events following a
+// format description event
+func (ev event) checksumAlg() byte {
+	return ev[len(ev)-checksumSize-1]
+}
+
+// gtid returns the GTID of a GTID event
+func (ev event) gtid() (*gomysql.UUIDSet, error) {
+	body := ev[headerSize:]
+	if len(body) < 25 {
+		return nil, fmt.Errorf("invalid GTID event of %d bytes", len(ev))
+	}
+	sid, err := uuid.FromBytes(body[1:17])
+	if err != nil {
+		return nil, err
+	}
+	gno := int64(binary.LittleEndian.Uint64(body[17:25]))
+	return gomysql.NewUUIDSet(sid, gomysql.Interval{Start: gno, Stop: gno + 1}), nil
+}
+
+// query returns the statement of a query event
+func (ev event) query(checksum bool) string {
+	body := ev.body(checksum)
+	// slave_proxy_id, exec_time, schema_length, error_code and
+	// status_vars_length, then the status vars and the schema
+	if len(body) < 13 {
+		return ""
+	}
+	start := 13 + int(binary.LittleEndian.Uint16(body[11:])) + int(body[8]) + 1
+	if start > len(body) {
+		return ""
+	}
+	return string(body[start:])
+}
+
+// newEvent builds an event of a body, with a checksum if asked
+func newEvent(t replication.EventType, serverID uint32, timestamp uint32, logPos uint32, flags uint16,
+	body []byte, checksum bool) event {
+	size := headerSize + len(body)
+	if checksum {
+		size += checksumSize
+	}
+	ev := make(event, size)
+	binary.LittleEndian.PutUint32(ev[0:], timestamp)
+	ev[4] = byte(t)
+	binary.LittleEndian.PutUint32(ev[5:], serverID)
+	binary.LittleEndian.PutUint32(ev[9:], uint32(size))
+	binary.LittleEndian.PutUint32(ev[13:], logPos)
+	binary.LittleEndian.PutUint16(ev[17:], flags)
+	copy(ev[headerSize:], body)
+	if checksum {
+		ev.updateChecksum()
+	}
+	return ev
+}
+
+// newRotateEvent builds the rotate event to a binlog. The rotate ending a
+// binlog ends at endPos, the artificial one starting a dump at 0.
+func newRotateEvent(serverID uint32, endPos uint32, name string, pos uint64, artificial bool, checksum bool) event {
+	body := make([]byte, 8+len(name))
+	binary.LittleEndian.PutUint64(body, pos)
+	copy(body[8:], name)
+	var timestamp uint32
+	var flags uint16
+	if artificial {
+		flags = logEventArtificialF
+	} else {
+		timestamp = uint32(time.Now().Unix())
+		endPos += uint32(headerSize + len(body))
+		if checksum {
+			endPos += checksumSize
+		}
+	}
+	return newEvent(replication.ROTATE_EVENT, serverID, timestamp, endPos, flags, body, checksum)
+}
+
+// newPreviousGTIDsEvent builds the event starting a binlog with the GTID
+// set executed before it, the event starting at pos
+func newPreviousGTIDsEvent(serverID uint32, pos uint32, gtidSet *gomysql.MysqlGTIDSet, checksum bool) event {
+	body := gtidSet.Encode()
+	size := uint32(headerSize + len(body))
+	if checksum {
+		size += checksumSize
+	}
+	return newEvent(replication.PREVIOUS_GTIDS_EVENT, serverID, uint32(time.Now().Unix()), pos+size, 0,
+		body, checksum)
+}
+
+// newHeartbeatEvent builds the event a dump sends while it has nothing to
+// send, at the position of the binlog it is at
+func newHeartbeatEvent(serverID uint32, name string, pos uint32, checksum bool) event {
+	return newEvent(replication.HEARTBEAT_EVENT, serverID, 0, pos, logEventArtificialF, []byte(name), checksum)
+}
+
+// transactionEnd tells whether an event ends the transaction it is in,
+// given whether the transaction started with a BEGIN
+func transactionEnd(ev event, inBegin bool, checksum bool) (end bool, begin bool) {
+	switch ev.eventType() {
+	case replication.XID_EVENT, xaPrepareLogEvent:
+		return true, false
+	case replication.QUERY_EVENT:
+		query := strings.ToUpper(strings.TrimSpace(ev.query(checksum)))
+		if !inBegin {
+			// A DDL is a transaction of its own
+			return query != "BEGIN", query == "BEGIN"
+		}
+		return query == "COMMIT" || query == "ROLLBACK", false
+	}
+	return false, false
+}
+
+// cloneGTIDSet returns a deep copy of a GTID set, whose Clone shares the
+// UUID sets
+func cloneGTIDSet(set *gomysql.MysqlGTIDSet) *gomysql.MysqlGTIDSet {
+	c, _ := gomysql.ParseMysqlGTIDSet(set.String())
+	return c.(*gomysql.MysqlGTIDSet)
+}
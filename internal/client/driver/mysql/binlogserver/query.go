@@ -0,0 +1,192 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package binlogserver
+
+import (
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	gomysql "github.com/siddontang/go-mysql/mysql"
+	"github.com/siddontang/go-mysql/replication"
+)
+
+var (
+	setRegexp          = regexp.MustCompile(`(?is)^SET\s+(.*)$`)
+	selectRegexp       = regexp.MustCompile(`(?is)^SELECT\s+(.*)$`)
+	showVariablesRegex = regexp.MustCompile(`(?is)^SHOW\s+(?:GLOBAL\s+|SESSION\s+)?VARIABLES(?:\s+LIKE\s+'([^']*)')?$`)
+	showMasterStatus   = regexp.MustCompile(`(?is)^SHOW\s+MASTER\s+STATUS$`)
+	showBinaryLogs     = regexp.MustCompile(`(?is)^SHOW\s+(?:BINARY|MASTER)\s+LOGS$`)
+)
+
+// handleQuery answers the queries a replica sends before dumping the
+// binlog: the variables of the server, and the user variables the replica
+// sets for the dump
+func (c *conn) handleQuery(query string) error {
+	query = strings.TrimSpace(strings.TrimRight(strings.TrimSpace(query), ";"))
+
+	if m := setRegexp.FindStringSubmatch(query); m != nil {
+		c.setVariables(m[1])
+		return c.writeOK()
+	}
+	if m := showVariablesRegex.FindStringSubmatch(query); m != nil {
+		return c.showVariables(m[1])
+	}
+	if showMasterStatus.MatchString(query) {
+		return c.showMasterStatus()
+	}
+	if showBinaryLogs.MatchString(query) {
+		return c.showBinaryLogs()
+	}
+	if m := selectRegexp.FindStringSubmatch(query); m != nil {
+		return c.selectExpressions(m[1])
+	}
+	return c.writeError(gomysql.NewDefaultError(gomysql.ER_NOT_SUPPORTED_YET, query))
+}
+
+// setVariables sets the user variables of "SET @a = 1, @b = @@global.c".
+// The other variables, such as those of SET NAMES, are ignored.
+func (c *conn) setVariables(assignments string) {
+	for _, assignment := range strings.Split(assignments, ",") {
+		parts := strings.SplitN(assignment, "=", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		name := strings.ToLower(strings.TrimSpace(parts[0]))
+		if !strings.HasPrefix(name, "@") || strings.HasPrefix(name, "@@") {
+			continue
+		}
+		value, _ := c.evaluate(strings.TrimSpace(parts[1]))
+		c.vars[name] = value
+	}
+}
+
+// evaluate returns the value of an expression of a SELECT or a SET: a
+// literal, a variable or UNIX_TIMESTAMP(). ok is false for an unknown
+// system variable.
+func (c *conn) evaluate(expr string) (value interface{}, ok bool) {
+	lower := strings.ToLower(expr)
+	switch {
+	case lower == "unix_timestamp()":
+		return time.Now().Unix(), true
+	case lower == "version()":
+		return c.server.cfg.ServerVersion, true
+	case strings.HasPrefix(lower, "@@"):
+		name := strings.TrimPrefix(lower, "@@")
+		name = strings.TrimPrefix(strings.TrimPrefix(name, "global."), "session.")
+		value, ok := c.systemVariables()[name]
+		return value, ok
+	case strings.HasPrefix(lower, "@"):
+		return c.vars[lower], true
+	case len(expr) >= 2 && (expr[0] == '\'' || expr[0] == '"') && expr[len(expr)-1] == expr[0]:
+		return expr[1 : len(expr)-1], true
+	}
+	if n, err := strconv.ParseInt(expr, 10, 64); err == nil {
+		return n, true
+	}
+	return expr, true
+}
+
+// systemVariables are the variables of the binlog server the replicas read
+func (c *conn) systemVariables() map[string]interface{} {
+	executed, purged := c.server.store.gtidSets()
+	checksum := "NONE"
+	if alg, ok := c.server.store.checksumAlg(); ok && alg == replication.BINLOG_CHECKSUM_ALG_CRC32 {
+		checksum = "CRC32"
+	}
+	return map[string]interface{}{
+		"server_id":                int64(c.server.store.ServerID()),
+		"server_uuid":              c.server.store.ServerUUID(),
+		"version":                  c.server.cfg.ServerVersion,
+		"version_comment":          "dtle binlog server",
+		"gtid_mode":                "ON",
+		"enforce_gtid_consistency": "ON",
+		"gtid_executed":            executed.String(),
+		"gtid_purged":              purged.String(),
+		"log_bin":                  "ON",
+		"binlog_checksum":          checksum,
+		"collation_server":         "utf8_general_ci",
+		"character_set_server":     "utf8",
+		"time_zone":                "SYSTEM",
+		"system_time_zone":         time.Now().Format("MST"),
+	}
+}
+
+// selectExpressions answers a SELECT of expressions, without FROM
+func (c *conn) selectExpressions(list string) error {
+	exprs := strings.Split(list, ",")
+	names := make([]string, len(exprs))
+	row := make([]interface{}, len(exprs))
+	for i, expr := range exprs {
+		expr = strings.TrimSpace(expr)
+		names[i] = expr
+		value, ok := c.evaluate(expr)
+		if !ok {
+			return c.writeError(gomysql.NewDefaultError(gomysql.ER_UNKNOWN_SYSTEM_VARIABLE,
+				strings.TrimPrefix(strings.TrimPrefix(strings.ToLower(expr), "@@"), "global.")))
+		}
+		if n, isInt := value.(int64); isInt {
+			value = strconv.FormatInt(n, 10)
+		}
+		row[i] = value
+	}
+	return c.writeRows(names, [][]interface{}{row})
+}
+
+func (c *conn) showVariables(like string) error {
+	pattern := regexp.MustCompile("(?i)^" + strings.Replace(strings.Replace(regexp.QuoteMeta(like),
+		"%", ".*", -1), "_", ".", -1) + "$")
+	vars := c.systemVariables()
+	var names []string
+	for name := range vars {
+		if like == "" || pattern.MatchString(name) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	var rows [][]interface{}
+	for _, name := range names {
+		value := vars[name]
+		if n, isInt := value.(int64); isInt {
+			value = strconv.FormatInt(n, 10)
+		}
+		rows = append(rows, []interface{}{name, value})
+	}
+	return c.writeRows([]string{"Variable_name", "Value"}, rows)
+}
+
+func (c *conn) showMasterStatus() error {
+	name, pos, _ := c.server.store.position()
+	if name == "" {
+		return c.writeRows([]string{"File", "Position", "Binlog_Do_DB", "Binlog_Ignore_DB", "Executed_Gtid_Set"}, nil)
+	}
+	executed, _ := c.server.store.gtidSets()
+	return c.writeRows([]string{"File", "Position", "Binlog_Do_DB", "Binlog_Ignore_DB", "Executed_Gtid_Set"},
+		[][]interface{}{{name, strconv.FormatInt(pos, 10), "", "", executed.String()}})
+}
+
+func (c *conn) showBinaryLogs() error {
+	var rows [][]interface{}
+	for _, name := range c.server.store.binlogs() {
+		size, err := c.server.store.size(name)
+		if err != nil {
+			continue
+		}
+		rows = append(rows, []interface{}{name, strconv.FormatInt(size, 10)})
+	}
+	return c.writeRows([]string{"Log_name", "File_size"}, rows)
+}
+
+func (c *conn) writeRows(names []string, rows [][]interface{}) error {
+	r, err := gomysql.BuildSimpleTextResultset(names, rows)
+	if err != nil {
+		return err
+	}
+	return c.writeResultset(r)
+}
@@ -0,0 +1,402 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package binlogserver
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"sync/atomic"
+
+	gomysql "github.com/siddontang/go-mysql/mysql"
+	"github.com/siddontang/go-mysql/packet"
+
+	log "github.com/actiontech/dtle/internal/logger"
+	"github.com/actiontech/dtle/internal/models"
+)
+
+const (
+	nativePasswordPlugin = "mysql_native_password"
+
+	serverCapabilities = gomysql.CLIENT_LONG_PASSWORD | gomysql.CLIENT_LONG_FLAG |
+		gomysql.CLIENT_CONNECT_WITH_DB | gomysql.CLIENT_PROTOCOL_41 | gomysql.CLIENT_TRANSACTIONS |
+		gomysql.CLIENT_SECURE_CONNECTION | gomysql.CLIENT_PLUGIN_AUTH
+
+	// utf8GeneralCI is the collation of the connections
+	utf8GeneralCI = 33
+)
+
+// Config is the config of a binlog server
+type Config struct {
+	// Addr is the address the replicas connect to
+	Addr string
+	// User and Password are those the replicas connect with. Any user is
+	// allowed if User is empty.
+	User     string
+	Password string
+	// ServerVersion is the version the binlog server reports, that of the
+	// source
+	ServerVersion string
+}
+
+// Server serves the binlogs of a Store to MySQL replicas, over the
+// replication protocol of MySQL. The replicas replicate from it as from a
+// MySQL source, by GTID auto-positioning or by the positions of its
+// binlogs.
+type Server struct {
+	cfg    *Config
+	store  *Store
+	logger *log.Entry
+
+	listener net.Listener
+	connID   uint32
+
+	connsLock sync.Mutex
+	conns     map[net.Conn]struct{}
+
+	wg           sync.WaitGroup
+	shutdown     bool
+	shutdownCh   chan struct{}
+	shutdownLock sync.Mutex
+}
+
+// NewServer returns the server of the binlogs of a store
+func NewServer(cfg *Config, store *Store, logger *log.Entry) *Server {
+	return &Server{
+		cfg:        cfg,
+		store:      store,
+		logger:     logger,
+		conns:      make(map[net.Conn]struct{}),
+		shutdownCh: make(chan struct{}),
+	}
+}
+
+// Start listens for the replicas
+func (s *Server) Start() error {
+	l, err := net.Listen("tcp", s.cfg.Addr)
+	if err != nil {
+		return err
+	}
+	s.listener = l
+	s.logger.Printf("binlogserver: Serving binlogs on %s, server_id %d, server_uuid %s",
+		l.Addr(), s.store.ServerID(), s.store.ServerUUID())
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		defer models.RecoverPanic(s.onPanic)
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				select {
+				case <-s.shutdownCh:
+				default:
+					s.logger.Errorf("binlogserver: Accepting replicas: %v", err)
+				}
+				return
+			}
+			s.wg.Add(1)
+			go func() {
+				defer s.wg.Done()
+				defer models.RecoverPanic(s.onPanic)
+				s.serve(conn)
+			}()
+		}
+	}()
+	return nil
+}
+
+// onPanic logs a panic of the server, the connection of the replica it
+// served being closed rather than the agent stopped
+func (s *Server) onPanic(err *models.PanicError) {
+	s.logger.Errorf("binlogserver: %v\n%s", err, err.Stack)
+}
+
+// Addr is the address the server listens on
+func (s *Server) Addr() net.Addr {
+	return s.listener.Addr()
+}
+
+// Shutdown closes the connections of the replicas
+func (s *Server) Shutdown() {
+	s.shutdownLock.Lock()
+	defer s.shutdownLock.Unlock()
+	if s.shutdown {
+		return
+	}
+	s.shutdown = true
+	close(s.shutdownCh)
+
+	if s.listener != nil {
+		s.listener.Close()
+	}
+	s.connsLock.Lock()
+	for conn := range s.conns {
+		conn.Close()
+	}
+	s.connsLock.Unlock()
+	s.wg.Wait()
+}
+
+// conn is the connection of a replica
+type conn struct {
+	*packet.Conn
+	server *Server
+	id     uint32
+	salt   []byte
+	user   string
+
+	// vars are the user variables the replica sets, such as
+	// @master_heartbeat_period and @master_binlog_checksum
+	vars map[string]interface{}
+}
+
+func (s *Server) serve(netConn net.Conn) {
+	s.connsLock.Lock()
+	if s.shutdown {
+		s.connsLock.Unlock()
+		netConn.Close()
+		return
+	}
+	s.conns[netConn] = struct{}{}
+	s.connsLock.Unlock()
+	defer func() {
+		s.connsLock.Lock()
+		delete(s.conns, netConn)
+		s.connsLock.Unlock()
+		netConn.Close()
+	}()
+
+	c := &conn{
+		Conn:   packet.NewConn(netConn),
+		server: s,
+		id:     atomic.AddUint32(&s.connID, 1),
+		vars:   make(map[string]interface{}),
+	}
+	if err := c.handshake(); err != nil {
+		s.logger.Warnf("binlogserver: Replica %s failed to connect: %v", netConn.RemoteAddr(), err)
+		return
+	}
+	s.logger.Debugf("binlogserver: Replica %s connected as %s", netConn.RemoteAddr(), c.user)
+
+	for {
+		c.ResetSequence()
+		data, err := c.ReadPacket()
+		if err != nil {
+			return
+		}
+		if err := c.dispatch(data); err == io.EOF {
+			return
+		} else if err != nil {
+			select {
+			case <-s.shutdownCh:
+			default:
+				s.logger.Warnf("binlogserver: Replica %s: %v", netConn.RemoteAddr(), err)
+			}
+			return
+		}
+	}
+}
+
+func (c *conn) dispatch(data []byte) error {
+	switch data[0] {
+	case gomysql.COM_QUIT:
+		return io.EOF
+	case gomysql.COM_PING, gomysql.COM_INIT_DB, gomysql.COM_REGISTER_SLAVE:
+		return c.writeOK()
+	case gomysql.COM_QUERY:
+		return c.handleQuery(string(data[1:]))
+	case gomysql.COM_BINLOG_DUMP:
+		return c.handleBinlogDump(data[1:])
+	case gomysql.COM_BINLOG_DUMP_GTID:
+		return c.handleBinlogDumpGTID(data[1:])
+	}
+	return c.writeError(gomysql.NewDefaultError(gomysql.ER_UNKNOWN_COM_ERROR))
+}
+
+// handshake authenticates the replica with mysql_native_password, asking
+// the replicas connecting with another plugin to switch to it
+func (c *conn) handshake() error {
+	salt, err := gomysql.RandomBuf(20)
+	if err != nil {
+		return err
+	}
+	c.salt = salt
+
+	capabilities := uint32(serverCapabilities)
+	data := make([]byte, 4, 128)
+	data = append(data, gomysql.MinProtocolVersion)
+	data = append(data, c.server.cfg.ServerVersion...)
+	data = append(data, 0)
+	data = append(data, byte(c.id), byte(c.id>>8), byte(c.id>>16), byte(c.id>>24))
+	data = append(data, salt[:8]...)
+	data = append(data, 0)
+	data = append(data, byte(capabilities), byte(capabilities>>8))
+	data = append(data, utf8GeneralCI)
+	data = append(data, byte(gomysql.SERVER_STATUS_AUTOCOMMIT), byte(gomysql.SERVER_STATUS_AUTOCOMMIT>>8))
+	data = append(data, byte(capabilities>>16), byte(capabilities>>24))
+	data = append(data, byte(len(salt)+1))
+	data = append(data, make([]byte, 10)...)
+	data = append(data, salt[8:]...)
+	data = append(data, 0)
+	data = append(data, nativePasswordPlugin...)
+	data = append(data, 0)
+	if err := c.WritePacket(data); err != nil {
+		return err
+	}
+
+	resp, err := c.ReadPacket()
+	if err != nil {
+		return err
+	}
+	auth, plugin, err := c.readHandshakeResponse(resp)
+	if err != nil {
+		return err
+	}
+	if plugin != "" && plugin != nativePasswordPlugin {
+		data = make([]byte, 4, 64)
+		data = append(data, gomysql.EOF_HEADER)
+		data = append(data, nativePasswordPlugin...)
+		data = append(data, 0)
+		data = append(data, salt...)
+		data = append(data, 0)
+		if err := c.WritePacket(data); err != nil {
+			return err
+		}
+		if auth, err = c.ReadPacket(); err != nil {
+			return err
+		}
+	}
+
+	if !c.authenticated(auth) {
+		using := "NO"
+		if len(auth) > 0 {
+			using = "YES"
+		}
+		host, _, _ := net.SplitHostPort(c.RemoteAddr().String())
+		c.writeError(gomysql.NewDefaultError(gomysql.ER_ACCESS_DENIED_ERROR, c.user, host, using))
+		return fmt.Errorf("access denied for user %q", c.user)
+	}
+	return c.writeOK()
+}
+
+// readHandshakeResponse reads the user, the auth response and the auth
+// plugin of the handshake response of a replica
+func (c *conn) readHandshakeResponse(data []byte) (auth []byte, plugin string, err error) {
+	if len(data) < 32 {
+		return nil, "", fmt.Errorf("invalid handshake response")
+	}
+	capabilities := binary.LittleEndian.Uint32(data)
+	// capabilities, max packet size, charset and the reserved bytes
+	pos := 32
+
+	readString := func() string {
+		i := bytes.IndexByte(data[pos:], 0)
+		if i < 0 {
+			s := string(data[pos:])
+			pos = len(data)
+			return s
+		}
+		s := string(data[pos : pos+i])
+		pos += i + 1
+		return s
+	}
+
+	c.user = readString()
+	switch {
+	case capabilities&gomysql.CLIENT_PLUGIN_AUTH_LENENC_CLIENT_DATA != 0:
+		n, _, size := gomysql.LengthEncodedInt(data[pos:])
+		pos += size
+		if pos+int(n) > len(data) {
+			return nil, "", fmt.Errorf("invalid handshake response")
+		}
+		auth = data[pos : pos+int(n)]
+		pos += int(n)
+	case capabilities&gomysql.CLIENT_SECURE_CONNECTION != 0:
+		if pos >= len(data) {
+			return nil, "", fmt.Errorf("invalid handshake response")
+		}
+		n := int(data[pos])
+		pos++
+		if pos+n > len(data) {
+			return nil, "", fmt.Errorf("invalid handshake response")
+		}
+		auth = data[pos : pos+n]
+		pos += n
+	default:
+		auth = []byte(readString())
+	}
+	if capabilities&gomysql.CLIENT_CONNECT_WITH_DB != 0 && pos < len(data) {
+		readString()
+	}
+	if capabilities&gomysql.CLIENT_PLUGIN_AUTH != 0 && pos < len(data) {
+		plugin = readString()
+	}
+	return auth, plugin, nil
+}
+
+func (c *conn) authenticated(auth []byte) bool {
+	cfg := c.server.cfg
+	if cfg.User != "" && c.user != cfg.User {
+		return false
+	}
+	if cfg.Password == "" {
+		return len(auth) == 0
+	}
+	return bytes.Equal(auth, gomysql.CalcPassword(c.salt, []byte(cfg.Password)))
+}
+
+func (c *conn) writeOK() error {
+	data := make([]byte, 4, 11)
+	data = append(data, gomysql.OK_HEADER, 0, 0)
+	data = append(data, byte(gomysql.SERVER_STATUS_AUTOCOMMIT), byte(gomysql.SERVER_STATUS_AUTOCOMMIT>>8))
+	data = append(data, 0, 0)
+	return c.WritePacket(data)
+}
+
+func (c *conn) writeError(e *gomysql.MyError) error {
+	data := make([]byte, 4, 16+len(e.Message))
+	data = append(data, gomysql.ERR_HEADER, byte(e.Code), byte(e.Code>>8))
+	data = append(data, '#')
+	data = append(data, e.State...)
+	data = append(data, e.Message...)
+	return c.WritePacket(data)
+}
+
+func (c *conn) writeEOF() error {
+	data := make([]byte, 4, 9)
+	data = append(data, gomysql.EOF_HEADER, 0, 0)
+	data = append(data, byte(gomysql.SERVER_STATUS_AUTOCOMMIT), byte(gomysql.SERVER_STATUS_AUTOCOMMIT>>8))
+	return c.WritePacket(data)
+}
+
+func (c *conn) writeResultset(r *gomysql.Resultset) error {
+	data := make([]byte, 4, 16)
+	data = append(data, gomysql.PutLengthEncodedInt(uint64(len(r.Fields)))...)
+	if err := c.WritePacket(data); err != nil {
+		return err
+	}
+	for _, field := range r.Fields {
+		data = append(make([]byte, 4, 64), field.Dump()...)
+		if err := c.WritePacket(data); err != nil {
+			return err
+		}
+	}
+	if err := c.writeEOF(); err != nil {
+		return err
+	}
+	for _, row := range r.RowDatas {
+		data = append(make([]byte, 4, 4+len(row)), row...)
+		if err := c.WritePacket(data); err != nil {
+			return err
+		}
+	}
+	return c.writeEOF()
+}
@@ -0,0 +1,121 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package binlogserver
+
+import (
+	"context"
+	"io/ioutil"
+	"net"
+	"os"
+	"strconv"
+	"testing"
+	"time"
+
+	gomysql "github.com/siddontang/go-mysql/mysql"
+	"github.com/siddontang/go-mysql/replication"
+)
+
+func TestServer(t *testing.T) {
+	dir, err := ioutil.TempDir("", "binlogserver")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	store, err := OpenStore(dir, 1<<20, 1000, testLogger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	if err := store.Start(testSID + ":1-5"); err != nil {
+		t.Fatal(err)
+	}
+	writeEvents(t, store, testFDE())
+	writeEvents(t, store, testTransaction(6, "")...)
+	writeEvents(t, store, testTransaction(7, "CREATE TABLE items (id int)")...)
+
+	server := NewServer(&Config{Addr: "127.0.0.1:0", User: "repl", Password: "secret",
+		ServerVersion: "5.7.25-log"}, store, testLogger)
+	if err := server.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer server.Shutdown()
+	host, port, _ := net.SplitHostPort(server.Addr().String())
+	portNum, _ := strconv.Atoi(port)
+
+	tests := []struct {
+		name     string
+		password string
+		gtidSet  string
+		wantErr  bool
+		wantGnos []int64
+	}{
+		{"from the purged set", "secret", testSID + ":1-5", false, []int64{6, 7, 8}},
+		{"skipping the executed transactions", "secret", testSID + ":1-6", false, []int64{7, 8}},
+		{"missing purged transactions", "secret", testSID + ":1-4", true, nil},
+		{"wrong password", "wrong", testSID + ":1-5", true, nil},
+	}
+	for i, tt := range tests {
+		syncer := replication.NewBinlogSyncer(replication.BinlogSyncerConfig{
+			ServerID:        uint32(100 + i),
+			Flavor:          "mysql",
+			Host:            host,
+			Port:            uint16(portNum),
+			User:            "repl",
+			Password:        tt.password,
+			VerifyChecksum:  true,
+			HeartbeatPeriod: time.Second,
+		})
+		set, _ := gomysql.ParseMysqlGTIDSet(tt.gtidSet)
+		streamer, err := syncer.StartSyncGTID(set)
+		if err == nil && i == 0 {
+			// A transaction written while the replica waits is sent
+			writeEvents(t, store, testTransaction(8, "")...)
+		}
+
+		var gnos []int64
+		for err == nil && len(gnos) < len(tt.wantGnos) {
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			var ev *replication.BinlogEvent
+			ev, err = streamer.GetEvent(ctx)
+			cancel()
+			if err != nil {
+				break
+			}
+			if gtid, ok := ev.Event.(*replication.GTIDEvent); ok {
+				gnos = append(gnos, gtid.GNO)
+			}
+		}
+		if tt.wantErr && err == nil && tt.wantGnos == nil {
+			// The dump errors are returned by the stream
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			_, err = streamer.GetEvent(ctx)
+			cancel()
+		}
+		syncer.Close()
+
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: error = %v, wantErr %v", tt.name, err, tt.wantErr)
+			continue
+		}
+		if !tt.wantErr && !equalGnos(gnos, tt.wantGnos) {
+			t.Errorf("%s: GTIDs = %v, want %v", tt.name, gnos, tt.wantGnos)
+		}
+	}
+}
+
+func equalGnos(a, b []int64) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,726 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package binlogserver
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/satori/go.uuid"
+	gomysql "github.com/siddontang/go-mysql/mysql"
+	"github.com/siddontang/go-mysql/replication"
+
+	log "github.com/actiontech/dtle/internal/logger"
+)
+
+const (
+	// binlogBaseName is the name of the binlogs of the store, numbered as
+	// those of MySQL
+	binlogBaseName = "dtle-bin"
+	indexFileName  = binlogBaseName + ".index"
+	purgedFileName = "gtid_purged"
+	uuidFileName   = "server_uuid"
+)
+
+// Store keeps the binlog read from the source in binlogs of its own, which
+// the Server serves to the replicas. The events are written by whole
+// transactions, with the positions of the binlog they are written to, each
+// binlog starting with the format description event of the source and the
+// GTID set executed before it.
+type Store struct {
+	logger      *log.Entry
+	dir         string
+	maxFileSize int64
+	serverID    uint32
+	serverUUID  string
+
+	mu sync.Mutex
+	// files are the binlogs, oldest first, the last one being written to
+	files []string
+	file  *os.File
+	// pos is the end of the last transaction written to the last binlog
+	pos int64
+	// fde is the format description event of the last binlog
+	fde      event
+	checksum bool
+	executed *gomysql.MysqlGTIDSet
+	// purged is the GTID set of the transactions the store does not have
+	purged *gomysql.MysqlGTIDSet
+	// changed is closed and replaced when a transaction is written
+	changed chan struct{}
+	closed  bool
+
+	tx      bytes.Buffer
+	txGTID  *gomysql.UUIDSet
+	inTx    bool
+	inBegin bool
+	skipTx  bool
+}
+
+// OpenStore opens the store of a directory, created if missing. The last
+// binlog is truncated after its last complete transaction. The server_id
+// of the store differs from those of the source and of the replicas, and
+// is derived from its server_uuid if 0.
+func OpenStore(dir string, maxFileSize int64, serverID uint32, logger *log.Entry) (*Store, error) {
+	if err := os.MkdirAll(dir, 0750); err != nil {
+		return nil, err
+	}
+	s := &Store{
+		logger:      logger,
+		dir:         dir,
+		maxFileSize: maxFileSize,
+		serverID:    serverID,
+		changed:     make(chan struct{}),
+	}
+	if err := s.loadServerUUID(); err != nil {
+		return nil, err
+	}
+	if s.serverID == 0 {
+		s.serverID = crc32.ChecksumIEEE([]byte(s.serverUUID))
+	}
+	if err := s.loadIndex(); err != nil {
+		return nil, err
+	}
+	if err := s.loadPurged(); err != nil {
+		return nil, err
+	}
+	if len(s.files) > 0 {
+		if err := s.recover(); err != nil {
+			return nil, fmt.Errorf("recovering %s: %v", s.files[len(s.files)-1], err)
+		}
+	}
+	return s, nil
+}
+
+// ServerUUID is the server_uuid of the store, generated when it is created
+func (s *Store) ServerUUID() string {
+	return s.serverUUID
+}
+
+// ServerID is the server_id of the store
+func (s *Store) ServerID() uint32 {
+	return s.serverID
+}
+
+// Start sets the GTID set the source is read from. The transactions of the
+// set the store does not have are added to its purged GTID set.
+func (s *Store) Start(gtidSet string) error {
+	set, err := gomysql.ParseMysqlGTIDSet(gtidSet)
+	if err != nil {
+		return err
+	}
+	start := set.(*gomysql.MysqlGTIDSet)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.executed == nil {
+		s.executed = cloneGTIDSet(start)
+	} else if !s.executed.Contain(start) {
+		// The store can not tell which transactions of the set it misses
+		// without a subtraction of GTID sets: the replicas are required to
+		// have the whole set
+		s.logger.Warnf("binlogserver: Reading from %v, which the store at %v does not have, "+
+			"the replicas need to have it", gtidSet, s.executed)
+		for _, uuidSet := range start.Sets {
+			s.executed.AddSet(uuidSet)
+		}
+	} else {
+		return nil
+	}
+	for _, uuidSet := range cloneGTIDSet(start).Sets {
+		s.purged.AddSet(uuidSet)
+	}
+	return s.savePurged()
+}
+
+// WriteEvent writes an event read from the source. The events of a
+// transaction are written when it ends, those of the transactions the store
+// already has are dropped, as are the events outside transactions but the
+// format description events.
+func (s *Store) WriteEvent(raw []byte) error {
+	ev := event(raw)
+	if len(ev) < headerSize {
+		return fmt.Errorf("invalid event of %d bytes", len(ev))
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return fmt.Errorf("binlog store closed")
+	}
+	switch ev.eventType() {
+	case replication.FORMAT_DESCRIPTION_EVENT:
+		// The source is read again from the start of a transaction
+		s.resetTx()
+		return s.setFormatDescription(ev)
+	case replication.ROTATE_EVENT, replication.PREVIOUS_GTIDS_EVENT, replication.HEARTBEAT_EVENT,
+		replication.STOP_EVENT:
+		return nil
+	case replication.GTID_EVENT, replication.ANONYMOUS_GTID_EVENT:
+		s.resetTx()
+		s.inTx = true
+		if ev.eventType() == replication.GTID_EVENT {
+			gtid, err := ev.gtid()
+			if err != nil {
+				return err
+			}
+			s.txGTID = gtid
+			s.skipTx = s.executed != nil && s.executed.Contain(&gomysql.MysqlGTIDSet{
+				Sets: map[string]*gomysql.UUIDSet{gtid.SID.String(): gtid},
+			})
+		}
+	default:
+		if !s.inTx {
+			return nil
+		}
+	}
+	if s.fde == nil {
+		s.resetTx()
+		return nil
+	}
+
+	if !s.skipTx {
+		s.tx.Write(ev)
+	}
+	end, begin := transactionEnd(ev, s.inBegin, s.checksum)
+	s.inBegin = s.inBegin || begin
+	if !end {
+		return nil
+	}
+	defer s.resetTx()
+	if s.skipTx {
+		return nil
+	}
+	return s.commit()
+}
+
+func (s *Store) resetTx() {
+	s.tx.Reset()
+	s.txGTID = nil
+	s.inTx = false
+	s.inBegin = false
+	s.skipTx = false
+}
+
+// commit writes the transaction received to the last binlog, rotated first
+// if it is full
+func (s *Store) commit() error {
+	if s.pos >= s.maxFileSize {
+		if err := s.rotate(s.fde); err != nil {
+			return err
+		}
+	}
+
+	var buf bytes.Buffer
+	pos := s.pos
+	data := s.tx.Bytes()
+	for len(data) > 0 {
+		ev := event(data[:event(data).size()])
+		data = data[len(ev):]
+		pos += int64(len(ev))
+		buf.Write(ev.withLogPos(uint32(pos), s.checksum))
+	}
+	if _, err := s.file.WriteAt(buf.Bytes(), s.pos); err != nil {
+		return err
+	}
+	s.pos = pos
+	if s.txGTID != nil {
+		if s.executed == nil {
+			s.executed = &gomysql.MysqlGTIDSet{Sets: make(map[string]*gomysql.UUIDSet)}
+		}
+		s.executed.AddSet(s.txGTID)
+	}
+	s.notify()
+	return nil
+}
+
+func (s *Store) notify() {
+	close(s.changed)
+	s.changed = make(chan struct{})
+}
+
+// setFormatDescription keeps the format description event of the source,
+// starting the first binlog with it, or another binlog if its checksum
+// changes
+func (s *Store) setFormatDescription(ev event) error {
+	fde := make(event, len(ev))
+	copy(fde, ev)
+	// A format description event created at the start of the source makes
+	// the replicas drop their temporary tables
+	binary.LittleEndian.PutUint32(fde[headerSize+2+50:], 0)
+	if fde.checksumAlg() == replication.BINLOG_CHECKSUM_ALG_CRC32 {
+		fde.updateChecksum()
+	}
+
+	if s.file == nil {
+		return s.create(fde)
+	}
+	if fde.checksumAlg() != s.fde.checksumAlg() {
+		return s.rotate(fde)
+	}
+	s.fde = fde
+	return nil
+}
+
+// rotate ends the last binlog with a rotate event and starts the next one
+func (s *Store) rotate(fde event) error {
+	next := s.nextFileName()
+	rotate := newRotateEvent(s.serverID, uint32(s.pos), next, 4, false, s.checksum)
+	if _, err := s.file.WriteAt(rotate, s.pos); err != nil {
+		return err
+	}
+	if err := s.file.Sync(); err != nil {
+		return err
+	}
+	s.file.Close()
+	s.file = nil
+	return s.create(fde)
+}
+
+// create starts a binlog with a format description event and the GTID set
+// executed before it
+func (s *Store) create(fde event) error {
+	name := s.nextFileName()
+	f, err := os.OpenFile(filepath.Join(s.dir, name), os.O_CREATE|os.O_TRUNC|os.O_RDWR, 0640)
+	if err != nil {
+		return err
+	}
+	checksum := fde.checksumAlg() == replication.BINLOG_CHECKSUM_ALG_CRC32
+	if s.executed == nil {
+		s.executed = &gomysql.MysqlGTIDSet{Sets: make(map[string]*gomysql.UUIDSet)}
+	}
+
+	var buf bytes.Buffer
+	buf.Write(replication.BinLogFileHeader)
+	pos := uint32(len(replication.BinLogFileHeader))
+	fde = fde.withLogPos(pos+fde.size(), checksum)
+	buf.Write(fde)
+	buf.Write(newPreviousGTIDsEvent(s.serverID, fde.logPos(), s.executed, checksum))
+	if _, err := f.Write(buf.Bytes()); err != nil {
+		f.Close()
+		return err
+	}
+
+	s.files = append(s.files, name)
+	if err := s.saveIndex(); err != nil {
+		f.Close()
+		s.files = s.files[:len(s.files)-1]
+		return err
+	}
+	s.file = f
+	s.pos = int64(buf.Len())
+	s.fde = fde
+	s.checksum = checksum
+	s.logger.Printf("binlogserver: Writing binlog %s", name)
+	s.notify()
+	return nil
+}
+
+func (s *Store) nextFileName() string {
+	n := 1
+	if len(s.files) > 0 {
+		last := s.files[len(s.files)-1]
+		i, _ := strconv.Atoi(strings.TrimPrefix(filepath.Ext(last), "."))
+		n = i + 1
+	}
+	return fmt.Sprintf("%s.%06d", binlogBaseName, n)
+}
+
+// recover reads the last binlog, for the GTID set executed and the end of
+// its last complete transaction, where it is truncated
+func (s *Store) recover() error {
+	name := s.files[len(s.files)-1]
+	f, err := os.OpenFile(filepath.Join(s.dir, name), os.O_RDWR, 0640)
+	if err != nil {
+		return err
+	}
+
+	var end int64
+	var inBegin bool
+	var txGTID *gomysql.UUIDSet
+	err = readEvents(f, 0, func(ev event, pos int64) (bool, error) {
+		switch ev.eventType() {
+		case replication.FORMAT_DESCRIPTION_EVENT:
+			s.fde = append(event{}, ev...)
+			s.checksum = ev.checksumAlg() == replication.BINLOG_CHECKSUM_ALG_CRC32
+			end = pos
+			return true, nil
+		case replication.PREVIOUS_GTIDS_EVENT:
+			set, err := gomysql.DecodeMysqlGTIDSet(ev.body(s.checksum))
+			if err != nil {
+				return false, err
+			}
+			s.executed = set
+			end = pos
+			return true, nil
+		case replication.GTID_EVENT:
+			gtid, err := ev.gtid()
+			if err != nil {
+				return false, err
+			}
+			txGTID = gtid
+		}
+		e, begin := transactionEnd(ev, inBegin, s.checksum)
+		inBegin = inBegin || begin
+		if e {
+			if txGTID != nil && s.executed != nil {
+				s.executed.AddSet(txGTID)
+			}
+			txGTID = nil
+			inBegin = false
+			end = pos
+		}
+		return true, nil
+	})
+	if err != nil {
+		f.Close()
+		return err
+	}
+	if s.fde == nil || s.executed == nil {
+		f.Close()
+		return fmt.Errorf("no format description or previous GTIDs event")
+	}
+	if err := f.Truncate(end); err != nil {
+		f.Close()
+		return err
+	}
+	s.file = f
+	s.pos = end
+	return nil
+}
+
+// readEvents calls fn with the events of a binlog from an offset, and the
+// position they end at, until fn returns false. An incomplete event at the
+// end of the binlog is ignored.
+func readEvents(r io.ReaderAt, offset int64, fn func(ev event, pos int64) (bool, error)) error {
+	if offset < int64(len(replication.BinLogFileHeader)) {
+		magic := make([]byte, len(replication.BinLogFileHeader))
+		if _, err := r.ReadAt(magic, 0); err != nil {
+			return err
+		}
+		if !bytes.Equal(magic, replication.BinLogFileHeader) {
+			return fmt.Errorf("not a binlog")
+		}
+		offset = int64(len(magic))
+	}
+	br := bufio.NewReader(io.NewSectionReader(r, offset, 1<<62))
+	header := make([]byte, headerSize)
+	for {
+		if _, err := io.ReadFull(br, header); err == io.EOF || err == io.ErrUnexpectedEOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		size := event(header).size()
+		if size < headerSize {
+			return fmt.Errorf("invalid event of %d bytes at %d", size, offset)
+		}
+		ev := make(event, size)
+		copy(ev, header)
+		if _, err := io.ReadFull(br, ev[headerSize:]); err == io.EOF || err == io.ErrUnexpectedEOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		offset += int64(size)
+		if more, err := fn(ev, offset); err != nil || !more {
+			return err
+		}
+	}
+}
+
+// Purge removes the binlogs older than the retention, then the oldest ones
+// while the binlogs take more than maxSize bytes if it is not 0, but the
+// last one, adding their transactions to the purged GTID set
+func (s *Store) Purge(retention time.Duration, maxSize int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var total int64
+	sizes := make([]int64, len(s.files))
+	expired := make([]bool, len(s.files))
+	for i, name := range s.files {
+		fi, err := os.Stat(filepath.Join(s.dir, name))
+		if os.IsNotExist(err) {
+			expired[i] = true
+			continue
+		} else if err != nil {
+			return err
+		}
+		sizes[i] = fi.Size()
+		total += sizes[i]
+		expired[i] = time.Since(fi.ModTime()) >= retention
+	}
+	n := 0
+	for ; n < len(s.files)-1; n++ {
+		if !expired[n] && (maxSize == 0 || total <= maxSize) {
+			break
+		}
+		total -= sizes[n]
+	}
+	if n == 0 {
+		return nil
+	}
+
+	previous, err := s.previousGTIDs(s.files[n])
+	if err != nil {
+		return err
+	}
+	for _, uuidSet := range previous.Sets {
+		s.purged.AddSet(uuidSet)
+	}
+	if err := s.savePurged(); err != nil {
+		return err
+	}
+	purged := s.files[:n]
+	s.files = append([]string{}, s.files[n:]...)
+	if err := s.saveIndex(); err != nil {
+		return err
+	}
+	for _, name := range purged {
+		// A binlog a replica reads may not be removed on Windows until the
+		// next purge
+		if err := os.Remove(filepath.Join(s.dir, name)); err != nil && !os.IsNotExist(err) {
+			s.logger.Warnf("binlogserver: Removing %s: %v", name, err)
+		}
+		s.logger.Printf("binlogserver: Purged binlog %s", name)
+	}
+	return nil
+}
+
+// Reset removes the binlogs of the store and its purged GTID set, for the
+// source to be read again from the GTID set given to Start
+func (s *Store) Reset() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.file != nil {
+		s.file.Close()
+		s.file = nil
+	}
+	for _, name := range s.files {
+		if err := os.Remove(filepath.Join(s.dir, name)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	s.files = nil
+	if err := s.saveIndex(); err != nil {
+		return err
+	}
+	s.purged = &gomysql.MysqlGTIDSet{Sets: make(map[string]*gomysql.UUIDSet)}
+	if err := s.savePurged(); err != nil {
+		return err
+	}
+	s.resetTx()
+	s.pos = 0
+	s.fde = nil
+	s.checksum = false
+	s.executed = nil
+	s.notify()
+	return nil
+}
+
+// Has tells whether the store has the transactions following a GTID set,
+// none of them being purged
+func (s *Store) Has(gtidSet string) (bool, error) {
+	set, err := gomysql.ParseMysqlGTIDSet(gtidSet)
+	if err != nil {
+		return false, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return set.Contain(s.purged), nil
+}
+
+// Executed returns the GTID set of the transactions the store has written,
+// with those it has purged
+func (s *Store) Executed() string {
+	executed, _ := s.gtidSets()
+	return executed.String()
+}
+
+// previousGTIDs returns the GTID set executed before a binlog
+func (s *Store) previousGTIDs(name string) (*gomysql.MysqlGTIDSet, error) {
+	f, err := os.Open(filepath.Join(s.dir, name))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var set *gomysql.MysqlGTIDSet
+	var checksum bool
+	err = readEvents(f, 0, func(ev event, pos int64) (bool, error) {
+		switch ev.eventType() {
+		case replication.FORMAT_DESCRIPTION_EVENT:
+			checksum = ev.checksumAlg() == replication.BINLOG_CHECKSUM_ALG_CRC32
+			return true, nil
+		case replication.PREVIOUS_GTIDS_EVENT:
+			var err error
+			set, err = gomysql.DecodeMysqlGTIDSet(ev.body(checksum))
+			return false, err
+		}
+		return false, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if set == nil {
+		return nil, fmt.Errorf("no previous GTIDs event in %s", name)
+	}
+	return set, nil
+}
+
+// Close closes the last binlog. The transaction being received is lost.
+func (s *Store) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.closed = true
+	s.resetTx()
+	if s.file == nil {
+		return nil
+	}
+	err := s.file.Sync()
+	if cerr := s.file.Close(); err == nil {
+		err = cerr
+	}
+	s.file = nil
+	return err
+}
+
+// position returns the last binlog and the end of its last transaction,
+// with a channel closed once another transaction is written
+func (s *Store) position() (name string, pos int64, changed <-chan struct{}) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.files) > 0 {
+		name = s.files[len(s.files)-1]
+	}
+	return name, s.pos, s.changed
+}
+
+// binlogs returns the binlogs of the store, oldest first
+func (s *Store) binlogs() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string{}, s.files...)
+}
+
+// gtidSets returns the GTID sets the store has executed and purged
+func (s *Store) gtidSets() (executed, purged *gomysql.MysqlGTIDSet) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	executed = &gomysql.MysqlGTIDSet{Sets: make(map[string]*gomysql.UUIDSet)}
+	if s.executed != nil {
+		executed = cloneGTIDSet(s.executed)
+	}
+	return executed, cloneGTIDSet(s.purged)
+}
+
+// checksumAlg returns the checksum algorithm of the last binlog
+func (s *Store) checksumAlg() (alg byte, ok bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.fde == nil {
+		return 0, false
+	}
+	return s.fde.checksumAlg(), true
+}
+
+func (s *Store) path(name string) string {
+	return filepath.Join(s.dir, name)
+}
+
+func (s *Store) loadIndex() error {
+	buf, err := ioutil.ReadFile(filepath.Join(s.dir, indexFileName))
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	for _, line := range strings.Split(string(buf), "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			s.files = append(s.files, filepath.Base(line))
+		}
+	}
+	return nil
+}
+
+func (s *Store) saveIndex() error {
+	var buf bytes.Buffer
+	for _, name := range s.files {
+		fmt.Fprintf(&buf, "./%s\n", name)
+	}
+	return writeFileAtomic(filepath.Join(s.dir, indexFileName), buf.Bytes())
+}
+
+func (s *Store) loadPurged() error {
+	buf, err := ioutil.ReadFile(filepath.Join(s.dir, purgedFileName))
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	set, err := gomysql.ParseMysqlGTIDSet(strings.TrimSpace(string(buf)))
+	if err != nil {
+		return fmt.Errorf("parsing %s: %v", purgedFileName, err)
+	}
+	s.purged = set.(*gomysql.MysqlGTIDSet)
+	return nil
+}
+
+func (s *Store) savePurged() error {
+	return writeFileAtomic(filepath.Join(s.dir, purgedFileName), []byte(s.purged.String()+"\n"))
+}
+
+func (s *Store) loadServerUUID() error {
+	path := filepath.Join(s.dir, uuidFileName)
+	buf, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		s.serverUUID = uuid.NewV4().String()
+		return writeFileAtomic(path, []byte(s.serverUUID+"\n"))
+	} else if err != nil {
+		return err
+	}
+	id, err := uuid.FromString(strings.TrimSpace(string(buf)))
+	if err != nil {
+		return fmt.Errorf("parsing %s: %v", uuidFileName, err)
+	}
+	s.serverUUID = id.String()
+	return nil
+}
+
+func writeFileAtomic(path string, data []byte) error {
+	tmp := path + ".tmp"
+	if err := ioutil.WriteFile(tmp, data, 0640); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// size returns the size of a binlog, up to the last transaction written to
+// the last one
+func (s *Store) size(name string) (int64, error) {
+	s.mu.Lock()
+	if len(s.files) > 0 && name == s.files[len(s.files)-1] {
+		defer s.mu.Unlock()
+		return s.pos, nil
+	}
+	s.mu.Unlock()
+	fi, err := os.Stat(s.path(name))
+	if err != nil {
+		return 0, err
+	}
+	return fi.Size(), nil
+}
@@ -0,0 +1,188 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package binlogserver
+
+import (
+	"encoding/binary"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/satori/go.uuid"
+	"github.com/siddontang/go-mysql/replication"
+
+	log "github.com/actiontech/dtle/internal/logger"
+)
+
+const testSID = "3e11fa47-71ca-11e1-9e33-c80aa9429562"
+
+var testLogger = log.NewEntry(log.New(ioutil.Discard, log.ParseLevel("ERROR")))
+
+// The events of a source with binlog_checksum=CRC32
+
+func testFDE() event {
+	body := make([]byte, 2+50+4+1)
+	binary.LittleEndian.PutUint16(body, 4)
+	copy(body[2:], "5.7.25-log")
+	binary.LittleEndian.PutUint32(body[52:], 1500000000)
+	body[56] = headerSize
+	body = append(body, make([]byte, 38)...)
+	body = append(body, replication.BINLOG_CHECKSUM_ALG_CRC32)
+	return newEvent(replication.FORMAT_DESCRIPTION_EVENT, 1, 1500000000, 0, 0, body, true)
+}
+
+func testGTID(gno int64) event {
+	body := make([]byte, 25)
+	body[0] = 1
+	copy(body[1:], uuid.FromStringOrNil(testSID).Bytes())
+	binary.LittleEndian.PutUint64(body[17:], uint64(gno))
+	return newEvent(replication.GTID_EVENT, 1, 1500000000, 0, 0, body, true)
+}
+
+func testQuery(query string) event {
+	body := make([]byte, 13)
+	body[8] = 4
+	body = append(body, "shop"...)
+	body = append(body, 0)
+	body = append(body, query...)
+	return newEvent(replication.QUERY_EVENT, 1, 1500000000, 0, 0, body, true)
+}
+
+func testXID() event {
+	return newEvent(replication.XID_EVENT, 1, 1500000000, 0, 0, make([]byte, 8), true)
+}
+
+// testTransaction returns the events of an insert if ddl is empty
+func testTransaction(gno int64, ddl string) []event {
+	if ddl != "" {
+		return []event{testGTID(gno), testQuery(ddl)}
+	}
+	return []event{testGTID(gno), testQuery("BEGIN"), testQuery("INSERT INTO orders VALUES (1)"), testXID()}
+}
+
+func writeEvents(t *testing.T, s *Store, events ...event) {
+	for _, ev := range events {
+		if err := s.WriteEvent(ev); err != nil {
+			t.Fatalf("WriteEvent() error = %v", err)
+		}
+	}
+}
+
+func TestStore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "binlogserver")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	s, err := OpenStore(dir, 1<<20, 1000, testLogger)
+	if err != nil {
+		t.Fatalf("OpenStore() error = %v", err)
+	}
+	if err := s.Start(testSID + ":1-5"); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	writeEvents(t, s, testFDE())
+	writeEvents(t, s, testTransaction(6, "")...)
+	writeEvents(t, s, testTransaction(7, "CREATE TABLE items (id int)")...)
+	// An incomplete transaction is not written
+	writeEvents(t, s, testGTID(8), testQuery("BEGIN"))
+	_, committed, _ := s.position()
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if s, err = OpenStore(dir, 1<<20, 1000, testLogger); err != nil {
+		t.Fatalf("OpenStore() error = %v", err)
+	}
+	name, pos, _ := s.position()
+	if name != "dtle-bin.000001" || pos != committed {
+		t.Errorf("position() = %s, %d, want dtle-bin.000001, %d", name, pos, committed)
+	}
+	executed, purged := s.gtidSets()
+	if executed.String() != testSID+":1-7" || purged.String() != testSID+":1-5" {
+		t.Errorf("gtidSets() = %v, %v, want %s:1-7, %s:1-5", executed, purged, testSID, testSID)
+	}
+
+	// The source is read again from the checkpoint of the job: the
+	// transactions the store has are dropped
+	if err := s.Start(testSID + ":1-6"); err != nil {
+		t.Fatal(err)
+	}
+	writeEvents(t, s, testFDE())
+	writeEvents(t, s, testTransaction(7, "CREATE TABLE items (id int)")...)
+	if _, pos, _ = s.position(); pos != committed {
+		t.Errorf("position() = %d after a transaction the store has, want %d", pos, committed)
+	}
+	writeEvents(t, s, testTransaction(8, "")...)
+
+	// A full binlog is rotated before the next transaction
+	s.maxFileSize = 1
+	writeEvents(t, s, testTransaction(9, "")...)
+	if binlogs := s.binlogs(); len(binlogs) != 2 || binlogs[1] != "dtle-bin.000002" {
+		t.Fatalf("binlogs() = %v, want dtle-bin.000001 and dtle-bin.000002", binlogs)
+	}
+	previous, err := s.previousGTIDs("dtle-bin.000002")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if previous.String() != testSID+":1-8" {
+		t.Errorf("previousGTIDs() = %v, want %s:1-8", previous, testSID)
+	}
+
+	if err := s.Purge(0, 0); err != nil {
+		t.Fatalf("Purge() error = %v", err)
+	}
+	if binlogs := s.binlogs(); len(binlogs) != 1 || binlogs[0] != "dtle-bin.000002" {
+		t.Errorf("binlogs() = %v after purge, want dtle-bin.000002", binlogs)
+	}
+	if _, purged = s.gtidSets(); purged.String() != testSID+":1-8" {
+		t.Errorf("purged = %v, want %s:1-8", purged, testSID)
+	}
+	s.Close()
+
+	// The binlogs are read by mysqlbinlog and the replicas
+	var types []replication.EventType
+	parser := replication.NewBinlogParser()
+	parser.SetVerifyChecksum(true)
+	err = parser.ParseFile(s.path("dtle-bin.000002"), 0, func(e *replication.BinlogEvent) error {
+		types = append(types, e.Header.EventType)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("ParseFile() error = %v", err)
+	}
+	if len(types) != 6 || types[0] != replication.FORMAT_DESCRIPTION_EVENT ||
+		types[1] != replication.PREVIOUS_GTIDS_EVENT || types[5] != replication.XID_EVENT {
+		t.Errorf("events = %v", types)
+	}
+
+	// A store purged of transactions following a GTID set is reset to be
+	// read from it again
+	if s, err = OpenStore(dir, 1<<20, 1000, testLogger); err != nil {
+		t.Fatalf("OpenStore() error = %v", err)
+	}
+	defer s.Close()
+	if has, _ := s.Has(testSID + ":1-7"); has {
+		t.Errorf("Has(%s:1-7) = true with %s:1-8 purged", testSID, testSID)
+	}
+	if err := s.Reset(); err != nil {
+		t.Fatalf("Reset() error = %v", err)
+	}
+	if has, _ := s.Has(testSID + ":1-7"); !has {
+		t.Errorf("Has(%s:1-7) = false after Reset()", testSID)
+	}
+	if err := s.Start(testSID + ":1-7"); err != nil {
+		t.Fatal(err)
+	}
+	writeEvents(t, s, testFDE())
+	writeEvents(t, s, testTransaction(8, "")...)
+	if binlogs := s.binlogs(); len(binlogs) != 1 || s.Executed() != testSID+":1-8" {
+		t.Errorf("binlogs() = %v, Executed() = %s after Reset(), want one binlog, %s:1-8", binlogs,
+			s.Executed(), testSID)
+	}
+}
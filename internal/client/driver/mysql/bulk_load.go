@@ -0,0 +1,264 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package mysql
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"sync/atomic"
+
+	gomysql "github.com/siddontang/go-mysql/mysql"
+
+	"github.com/actiontech/dtle/internal/client/driver/bulkload"
+	"github.com/actiontech/dtle/internal/client/driver/mysql/base"
+	"github.com/actiontech/dtle/internal/client/driver/mysql/util"
+	"github.com/actiontech/dtle/internal/config"
+	umconf "github.com/actiontech/dtle/internal/config/mysql"
+)
+
+// validateBulkLoad checks BulkLoad against the other job arguments
+func (e *Extractor) validateBulkLoad() error {
+	if e.mysqlContext.BulkLoad == nil {
+		return nil
+	}
+	if e.mysqlContext.GtidStart != "" {
+		return fmt.Errorf("conflicting job argument: BulkLoad and GtidStart")
+	}
+	return bulkload.Validate(e.mysqlContext.BulkLoad)
+}
+
+// bulkLoad copies the tables as mysqlDump does, but with the rows of the
+// files of BulkLoad rather than of the source, and reads the binlog from
+// the GTID set the files were exported at. The tables of the files must
+// be replicated, and those without files are created empty.
+func (e *Extractor) bulkLoad() error {
+	defer e.singletonDB.Close()
+	cfg := e.mysqlContext.BulkLoad
+
+	gtidSet, err := gomysql.ParseMysqlGTIDSet(cfg.Gtid)
+	if err != nil {
+		return err
+	}
+	if err := e.waitForReplica(cfg.Gtid); err != nil {
+		return err
+	}
+	e.initialBinlogCoordinates = &base.BinlogCoordinatesX{
+		GtidSet: gtidSet.String(),
+	}
+
+	if err := e.readMySqlCharsetSystemVariables(); err != nil {
+		return err
+	}
+	setSystemVariablesStatement := e.setStatementFor()
+	if err := e.selectSqlMode(); err != nil {
+		return err
+	}
+	setSqlMode := fmt.Sprintf("SET @@session.sql_mode = '%s'", e.mysqlContext.SqlMode)
+
+	tables := make(map[string]*config.Table)
+	var views []*config.Table
+	for _, db := range e.replicateDoDb {
+		for _, tb := range db.Tables {
+			if isView(tb) {
+				views = append(views, tb)
+				continue
+			}
+			tables[fmt.Sprintf("%s.%s", tb.TableSchema, tb.TableName)] = tb
+			e.tableCount++
+			if !e.copiesSchema() {
+				continue
+			}
+			entry := &DumpEntry{
+				SystemVariablesStatement: setSystemVariablesStatement,
+				SqlMode:                  setSqlMode,
+				TotalCount:               1,
+				RowsCount:                1,
+			}
+			if strings.ToLower(tb.TableSchema) != "mysql" {
+				entry.DbSQL = fmt.Sprintf("CREATE DATABASE IF NOT EXISTS %s", tb.TableSchema)
+				entry.TbSQL, err = base.ShowCreateTable(e.singletonDB, tb.TableSchema, tb.TableName, e.mysqlContext.DropTableIfExists)
+				if err != nil {
+					return err
+				}
+			}
+			atomic.AddInt64(&e.mysqlContext.RowsEstimate, 1)
+			atomic.AddInt64(&e.mysqlContext.TotalRowsCopied, 1)
+			if err := e.encodeDumpEntry(entry); err != nil {
+				return err
+			}
+		}
+	}
+	if e.copiesSchema() {
+		objects, err := e.schemaObjects(views)
+		if err != nil {
+			return err
+		}
+		for _, object := range objects {
+			entry := &DumpEntry{
+				SystemVariablesStatement: setSystemVariablesStatement,
+				SqlMode:                  setSqlMode,
+				DbSQL:                    fmt.Sprintf("CREATE DATABASE IF NOT EXISTS %s", object.schema),
+				TbSQL:                    object.tbSQL,
+				TotalCount:               1,
+				RowsCount:                1,
+			}
+			if object.sqlMode != "" {
+				entry.SqlMode = fmt.Sprintf("SET @@session.sql_mode = '%s'", object.sqlMode)
+			}
+			if err := e.encodeDumpEntry(entry); err != nil {
+				return err
+			}
+		}
+	}
+
+	if !e.copiesData() {
+		e.logger.Printf("mysql.extractor: skip loading the rows of the tables: CopyMode is %q", e.mysqlContext.CopyMode)
+		return nil
+	}
+	loader := bulkload.NewLoader(cfg)
+	for _, t := range cfg.Tables {
+		tb, ok := tables[fmt.Sprintf("%s.%s", t.Schema, t.Table)]
+		if !ok {
+			return fmt.Errorf("BulkLoad: table %s.%s is not replicated", t.Schema, t.Table)
+		}
+		files, err := loader.Files(t)
+		if err != nil {
+			return fmt.Errorf("BulkLoad: %s.%s: %v", t.Schema, t.Table, err)
+		}
+		for _, file := range files {
+			e.logger.Printf("mysql.extractor: loading table '%s.%s' from %s", t.Schema, t.Table, file)
+			if err := e.loadFile(loader, t, tb, file, setSystemVariablesStatement, setSqlMode); err != nil {
+				return fmt.Errorf("BulkLoad: %s: %v", file, err)
+			}
+		}
+	}
+	e.logger.Printf("mysql.extractor: loaded %d rows of %d tables", e.mysqlContext.TotalRowsCopied, len(cfg.Tables))
+	return nil
+}
+
+// loadFile sends the rows of a file as the dump entries of a table, with
+// the values in the order of the columns of the table on the source
+func (e *Extractor) loadFile(loader *bulkload.Loader, t *config.BulkLoadTable, tb *config.Table, file string,
+	setSystemVariablesStatement, setSqlMode string) error {
+
+	columns, err := base.GetTableColumns(e.singletonDB, tb.TableSchema, tb.TableName)
+	if err != nil {
+		return err
+	}
+	r, err := loader.Open(t, file)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	positions, err := filePositions(columns, r.Columns())
+	if err != nil {
+		return err
+	}
+
+	var generatedColumns []int
+	var columnNames []string
+	for i, col := range columns.Columns {
+		if col.IsGenerated {
+			generatedColumns = append(generatedColumns, i)
+		}
+	}
+	if len(generatedColumns) > 0 {
+		columnNames = columns.Names()
+	}
+	newEntry := func() *DumpEntry {
+		entry := &DumpEntry{
+			SystemVariablesStatement: setSystemVariablesStatement,
+			SqlMode:                  setSqlMode,
+			TableSchema:              tb.TableSchema,
+			TableName:                tb.TableName,
+			GeneratedColumns:         generatedColumns,
+			ColumnNames:              columnNames,
+		}
+		if e.needToSendTabelDef() {
+			entry.Table = tb
+		}
+		return entry
+	}
+
+	limiter := util.NewRateLimiter(e.mysqlContext.DumpConnectionRate)
+	send := func(entry *DumpEntry) error {
+		if e.shutdown {
+			return fmt.Errorf("shut down while loading")
+		}
+		limiter.Wait(dumpEntrySize(entry), e.shutdownCh)
+		atomic.AddInt64(&e.mysqlContext.RowsEstimate, entry.RowsCount)
+		if err := e.encodeDumpEntry(entry); err != nil {
+			return err
+		}
+		atomic.AddInt64(&e.mysqlContext.TotalRowsCopied, entry.RowsCount)
+		return nil
+	}
+
+	entry := newEntry()
+	for {
+		values, err := r.Next()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return err
+		}
+		row := make([]*interface{}, len(columns.Columns))
+		for i := range row {
+			row[i] = new(interface{})
+		}
+		for i, v := range values {
+			if v != nil {
+				*row[positions[i]] = v
+			}
+		}
+		entry.ValuesX = append(entry.ValuesX, row)
+		entry.incrementCounter()
+		if entry.RowsCount >= e.mysqlContext.ChunkSize {
+			if err := send(entry); err != nil {
+				return err
+			}
+			entry = newEntry()
+		}
+	}
+	if entry.RowsCount > 0 {
+		return send(entry)
+	}
+	return nil
+}
+
+// filePositions returns the positions in the columns of a table of those
+// of a file, which must hold all but the generated columns
+func filePositions(columns *umconf.ColumnList, fileColumns []string) ([]int, error) {
+	positions := make([]int, len(fileColumns))
+	found := make(map[int]bool)
+	for i, name := range fileColumns {
+		positions[i] = -1
+		for j, col := range columns.Columns {
+			if strings.EqualFold(col.Name, name) {
+				positions[i] = j
+				break
+			}
+		}
+		if positions[i] < 0 {
+			return nil, fmt.Errorf("column %s is not in the table", name)
+		}
+		if columns.Columns[positions[i]].IsGenerated {
+			return nil, fmt.Errorf("column %s is generated", name)
+		}
+		if found[positions[i]] {
+			return nil, fmt.Errorf("column %s is in the file twice", name)
+		}
+		found[positions[i]] = true
+	}
+	for j, col := range columns.Columns {
+		if !found[j] && !col.IsGenerated {
+			return nil, fmt.Errorf("column %s is not in the file", col.Name)
+		}
+	}
+	return positions, nil
+}
@@ -0,0 +1,164 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package mysql
+
+import (
+	"fmt"
+	"net"
+
+	gonats "github.com/nats-io/go-nats"
+
+	"github.com/actiontech/dtle/internal/config"
+	"github.com/actiontech/dtle/internal/models"
+)
+
+// capacityRequests is how many times the source asks the target for its
+// capacity before skipping the check, the target possibly not subscribed yet
+const capacityRequests = 3
+
+// CapacityRequest is the estimated size of the snapshot, sent to the target
+// before copying for it to check that it can hold it
+type CapacityRequest struct {
+	Tables []*models.TableEstimate
+}
+
+// CapacityResult is the answer of the target to a CapacityRequest. Error is
+// empty if the snapshot fits, or if the target does not know its capacity.
+type CapacityResult struct {
+	Error string
+}
+
+// SnapshotSize returns the estimated bytes of the tables of a request
+func (r *CapacityRequest) SnapshotSize() (size int64) {
+	for _, t := range r.Tables {
+		size += t.DataLength + t.IndexLength
+	}
+	return size
+}
+
+func (e *Extractor) validateCapacityCheck() error {
+	switch e.mysqlContext.CapacityCheck {
+	case "", config.CapacityCheckWarn, config.CapacityCheckFail, config.CapacityCheckOff:
+		return nil
+	default:
+		return fmt.Errorf("unknown CapacityCheck %q: must be %q, %q, %q or empty", e.mysqlContext.CapacityCheck,
+			config.CapacityCheckWarn, config.CapacityCheckFail, config.CapacityCheckOff)
+	}
+}
+
+// checkTargetCapacity sends the estimated size of the replicated tables to
+// the target before the snapshot, so that a target too small for it is
+// found before its disk is filled rather than at the end of the copy
+func (e *Extractor) checkTargetCapacity() error {
+	if e.mysqlContext.CapacityCheck == config.CapacityCheckOff || !e.copiesData() {
+		return nil
+	}
+
+	estimates, err := EstimateTables(e.db, &config.MySQLDriverConfig{})
+	if err != nil {
+		return fmt.Errorf("failed to estimate the size of the snapshot: %v", err)
+	}
+	replicated := make(map[string]bool)
+	for _, db := range e.replicateDoDb {
+		for _, tb := range db.Tables {
+			if !isView(tb) {
+				replicated[fmt.Sprintf("%s.%s", tb.TableSchema, tb.TableName)] = true
+			}
+		}
+	}
+	req := &CapacityRequest{}
+	for _, t := range estimates {
+		if replicated[fmt.Sprintf("%s.%s", t.TableSchema, t.TableName)] {
+			req.Tables = append(req.Tables, t)
+		}
+	}
+	msg, err := Encode(req)
+	if err != nil {
+		return err
+	}
+
+	var reply *gonats.Msg
+	for i := 0; i < capacityRequests; i++ {
+		reply, err = e.natsConn.Request(fmt.Sprintf("%s_capacity", e.subject), msg, DefaultConnectWait)
+		if err != gonats.ErrTimeout {
+			break
+		}
+	}
+	if err == gonats.ErrTimeout {
+		e.logger.Warnf("mysql.extractor: the target did not check its capacity, skipping the check")
+		return nil
+	} else if err != nil {
+		return err
+	}
+	result := &CapacityResult{}
+	if err := Decode(reply.Data, result); err != nil {
+		return err
+	}
+	if result.Error == "" {
+		e.logger.Printf("mysql.extractor: the target can hold the snapshot of about %d bytes", req.SnapshotSize())
+		return nil
+	}
+	if e.mysqlContext.CapacityCheck == config.CapacityCheckFail {
+		return fmt.Errorf("target capacity check: %v", result.Error)
+	}
+	e.logger.Warnf("mysql.extractor: target capacity check: %v", result.Error)
+	return nil
+}
+
+// checkCapacity checks that the target has the free disk for a snapshot.
+// The free disk is known if the target runs on the host of the applier,
+// where its data directory can be read.
+func (a *Applier) checkCapacity(req *CapacityRequest) string {
+	if a.dryRun != nil {
+		return ""
+	}
+	conn := a.mysqlContext.ConnectionConfig
+	if conn.SSHTunnel != nil || conn.SocksProxy != nil || !isLocalHost(conn.Host) {
+		a.logger.Printf("mysql.applier: the free disk of the remote target is unknown, skipping the capacity check")
+		return ""
+	}
+	var dataDir string
+	if err := a.db.QueryRow("SELECT @@datadir").Scan(&dataDir); err != nil {
+		a.logger.Warnf("mysql.applier: failed to read the data directory of the target: %v", err)
+		return ""
+	}
+	free, err := diskFree(dataDir)
+	if err != nil {
+		a.logger.Warnf("mysql.applier: failed to read the free disk of %s: %v", dataDir, err)
+		return ""
+	}
+	size := req.SnapshotSize()
+	a.logger.Printf("mysql.applier: the snapshot is about %d bytes, %s has %d bytes free", size, dataDir, free)
+	if size > free {
+		return fmt.Sprintf("the snapshot is about %d bytes, the disk of the target has %d bytes free", size, free)
+	}
+	return ""
+}
+
+// isLocalHost tells whether a host is an address of this host
+func isLocalHost(host string) bool {
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return false
+	}
+	if ip.IsLoopback() {
+		return true
+	}
+	addrs, err := net.InterfaceAddrs()
+	if err != nil {
+		return false
+	}
+	for _, addr := range addrs {
+		if ipNet, ok := addr.(*net.IPNet); ok && ipNet.IP.Equal(ip) {
+			return true
+		}
+	}
+	return false
+}
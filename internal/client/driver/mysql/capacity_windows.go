@@ -0,0 +1,13 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package mysql
+
+import "fmt"
+
+func diskFree(path string) (int64, error) {
+	return 0, fmt.Errorf("not supported on windows")
+}
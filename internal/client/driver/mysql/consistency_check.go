@@ -0,0 +1,369 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package mysql
+
+import (
+	gosql "database/sql"
+	"fmt"
+	"math/rand"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/actiontech/dtle/internal/client/driver/mysql/sql"
+	"github.com/actiontech/dtle/internal/config"
+	umconf "github.com/actiontech/dtle/internal/config/mysql"
+	log "github.com/actiontech/dtle/internal/logger"
+	"github.com/actiontech/dtle/internal/models"
+)
+
+const (
+	// consistencyRechecks is how many more times a range differing on the
+	// target is compared before it counts as a mismatch, for the target may
+	// not have applied the last changes of the range yet
+	consistencyRechecks = 3
+	// consistencyRecheckDelay is the wait before a range is compared again
+	consistencyRecheckDelay = 10 * time.Second
+)
+
+// consistencyCheck compares random ranges of the rows of the replicated
+// tables on the source and the target, by their count and checksum
+type consistencyCheck struct {
+	logger     *log.Entry
+	cfg        *config.ConsistencyCheckConfig
+	source     *gosql.DB
+	target     *gosql.DB
+	tables     []*config.Table
+	shutdownCh chan struct{}
+	onPanic    func(err *models.PanicError)
+
+	mutex sync.Mutex
+	// scores are by "schema.table"
+	scores map[string]*models.TableConsistency
+}
+
+// validateConsistencyCheck checks the job argument ConsistencyCheck
+func (e *Extractor) validateConsistencyCheck() error {
+	cfg := e.mysqlContext.ConsistencyCheck
+	if cfg == nil {
+		return nil
+	}
+	if cfg.TargetConnectionConfig == nil {
+		return fmt.Errorf("job argument ConsistencyCheck requires a MySQL target")
+	}
+	if e.mysqlContext.SkipIncrementalCopy || e.mysqlContext.CopyMode == config.CopyModeSchemaOnly {
+		return fmt.Errorf("conflicting job argument: ConsistencyCheck and no incremental copy")
+	}
+	return cfg.TargetConnectionConfig.Prepare()
+}
+
+// startConsistencyCheck connects to the target and samples the tables with
+// a unique key in the background, until the extractor shuts down
+func (e *Extractor) startConsistencyCheck() error {
+	cfg := e.mysqlContext.ConsistencyCheck
+	if cfg == nil {
+		return nil
+	}
+	target, err := sql.CreateDB(cfg.TargetConnectionConfig.GetDBUri())
+	if err != nil {
+		return err
+	}
+	c := &consistencyCheck{
+		logger:     e.logger,
+		cfg:        cfg,
+		source:     e.db,
+		target:     target,
+		shutdownCh: e.shutdownCh,
+		onPanic:    e.onPanic,
+		scores:     make(map[string]*models.TableConsistency),
+	}
+	for _, doDb := range e.replicateDoDb {
+		for _, doTb := range doDb.Tables {
+			if isView(doTb) {
+				continue
+			}
+			if doTb.UseUniqueKey == nil || doTb.OriginalTableColumns == nil {
+				e.logger.Warnf("mysql.extractor: table %s.%s has no unique key, its consistency is not checked",
+					doTb.TableSchema, doTb.TableName)
+				continue
+			}
+			c.tables = append(c.tables, doTb)
+		}
+	}
+	e.consistencyCheck = c
+	go c.run()
+	return nil
+}
+
+func (c *consistencyCheck) run() {
+	defer models.RecoverPanic(c.onPanic)
+	ticker := time.NewTicker(time.Hour / time.Duration(c.cfg.SamplesPerHour))
+	defer ticker.Stop()
+	for {
+		select {
+		case <-c.shutdownCh:
+			return
+		case <-ticker.C:
+		}
+		for _, table := range c.tables {
+			select {
+			case <-c.shutdownCh:
+				return
+			default:
+			}
+			c.sample(table)
+		}
+	}
+}
+
+// sample compares a random range of the rows of a table, again if it
+// differs, and scores the table
+func (c *consistencyCheck) sample(table *config.Table) {
+	lo, hi, err := c.sampleRange(table)
+	if err != nil {
+		c.record(table, false, lo, hi, err)
+		return
+	}
+	for i := 0; ; i++ {
+		matched, err := c.compare(table, lo, hi)
+		if err != nil || matched || i == consistencyRechecks {
+			c.record(table, matched, lo, hi, err)
+			return
+		}
+		select {
+		case <-c.shutdownCh:
+			return
+		case <-time.After(consistencyRecheckDelay):
+		}
+	}
+}
+
+// sampleRange picks the unique key values bounding a random range of
+// RangeRows rows of a table on the source. A nil bound is the start or the
+// end of the table.
+func (c *consistencyCheck) sampleRange(table *config.Table) (lo, hi []interface{}, err error) {
+	keyColumns := table.UseUniqueKey.Columns.Columns
+	from := fmt.Sprintf("%s.%s", sql.EscapeName(table.TableSchema), sql.EscapeName(table.TableName))
+	keys := escapedNames(keyColumns)
+	where := tableWhere(table)
+
+	first := sql.EscapeName(keyColumns[0].Name)
+	if isIntegerColumn(&keyColumns[0]) {
+		var start gosql.NullString
+		query := fmt.Sprintf("SELECT MIN(%s) + FLOOR(RAND() * (MAX(%s) - MIN(%s) + 1)) FROM %s WHERE %s",
+			first, first, first, from, where)
+		if err := c.source.QueryRow(query).Scan(&start); err != nil {
+			return nil, nil, err
+		}
+		if start.Valid {
+			query = fmt.Sprintf("SELECT %s FROM %s WHERE %s >= ? AND (%s) ORDER BY %s LIMIT 1",
+				keys, from, first, where, keys)
+			if lo, err = c.queryKey(query, start.String); err != nil {
+				return nil, nil, err
+			}
+		}
+	} else {
+		query := fmt.Sprintf("SELECT %s FROM %s WHERE %s ORDER BY %s LIMIT 1 OFFSET ?", keys, from, where, keys)
+		offset := int64(0)
+		if table.RowsEstimate > 0 {
+			offset = rand.Int63n(table.RowsEstimate)
+		}
+		if lo, err = c.queryKey(query, offset); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	cond, args := keyRangeCondition(keyColumns, lo, nil)
+	query := fmt.Sprintf("SELECT %s FROM %s WHERE (%s) AND (%s) ORDER BY %s LIMIT 1 OFFSET %d",
+		keys, from, cond, where, keys, c.cfg.RangeRows-1)
+	if hi, err = c.queryKey(query, args...); err != nil {
+		return nil, nil, err
+	}
+	return lo, hi, nil
+}
+
+// queryKey returns the values of the key the query selects, or nil if it
+// selects no row
+func (c *consistencyCheck) queryKey(query string, args ...interface{}) ([]interface{}, error) {
+	rows, err := c.source.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	if !rows.Next() {
+		return nil, rows.Err()
+	}
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+	raw := make([]gosql.RawBytes, len(columns))
+	dest := make([]interface{}, len(columns))
+	for i := range raw {
+		dest[i] = &raw[i]
+	}
+	if err := rows.Scan(dest...); err != nil {
+		return nil, err
+	}
+	values := make([]interface{}, len(raw))
+	for i, v := range raw {
+		values[i] = append([]byte{}, v...)
+	}
+	return values, nil
+}
+
+// compare tells whether the range of a table has the same count and
+// checksum of rows on the source and the target
+func (c *consistencyCheck) compare(table *config.Table, lo, hi []interface{}) (bool, error) {
+	columns := table.OriginalTableColumns.Columns
+	names := make([]string, len(columns))
+	nulls := make([]string, len(columns))
+	for i := range columns {
+		names[i] = sql.EscapeName(columns[i].Name)
+		nulls[i] = fmt.Sprintf("ISNULL(%s)", names[i])
+	}
+	cond, args := keyRangeCondition(table.UseUniqueKey.Columns.Columns, lo, hi)
+	query := fmt.Sprintf("SELECT COUNT(*), COALESCE(BIT_XOR(CRC32(CONCAT_WS('#', %s, CONCAT(%s)))), 0)"+
+		" FROM %s.%s WHERE (%s) AND (%s)",
+		strings.Join(names, ", "), strings.Join(nulls, ", "),
+		sql.EscapeName(table.TableSchema), sql.EscapeName(table.TableName), cond, tableWhere(table))
+
+	var sourceCount, targetCount int64
+	var sourceSum, targetSum uint64
+	if err := c.source.QueryRow(query, args...).Scan(&sourceCount, &sourceSum); err != nil {
+		return false, fmt.Errorf("on the source: %v", err)
+	}
+	if err := c.target.QueryRow(query, args...).Scan(&targetCount, &targetSum); err != nil {
+		return false, fmt.Errorf("on the target: %v", err)
+	}
+	return sourceCount == targetCount && sourceSum == targetSum, nil
+}
+
+// record scores a table by a sample, which is not scored if it failed
+func (c *consistencyCheck) record(table *config.Table, matched bool, lo, hi []interface{}, err error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	key := fmt.Sprintf("%s.%s", table.TableSchema, table.TableName)
+	score, ok := c.scores[key]
+	if !ok {
+		score = &models.TableConsistency{
+			TableSchema: table.TableSchema,
+			TableName:   table.TableName,
+		}
+		c.scores[key] = score
+	}
+	score.LastSampleTime = time.Now().Unix()
+	if err != nil {
+		score.Error = err.Error()
+		c.logger.Warnf("mysql.extractor: sampling the consistency of table %s: %v", key, err)
+		return
+	}
+	score.Error = ""
+	score.Samples++
+	if !matched {
+		score.Mismatches++
+		score.LastMismatch = describeKeyRange(lo, hi)
+		c.logger.Warnf("mysql.extractor: the rows of table %s in the range %s differ on the target",
+			key, score.LastMismatch)
+	}
+	score.Score = 100 * float64(score.Samples-score.Mismatches) / float64(score.Samples)
+}
+
+// stats returns the scores of the sampled tables
+func (c *consistencyCheck) stats() []*models.TableConsistency {
+	if c == nil {
+		return nil
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	stats := make([]*models.TableConsistency, 0, len(c.scores))
+	for _, score := range c.scores {
+		result := *score
+		stats = append(stats, &result)
+	}
+	sort.Slice(stats, func(i, j int) bool {
+		return stats[i].TableSchema+"."+stats[i].TableName < stats[j].TableSchema+"."+stats[j].TableName
+	})
+	return stats
+}
+
+// keyRangeCondition returns the condition of the rows whose unique key is
+// between lo and hi, both included, in the form of the chunks of the dumper:
+// (A > a) or (A = a and B >= b)
+func keyRangeCondition(keyColumns []umconf.Column, lo, hi []interface{}) (string, []interface{}) {
+	var conds []string
+	var args []interface{}
+	bound := func(op string, values []interface{}) {
+		items := make([]string, len(keyColumns))
+		for x := range keyColumns {
+			inner := make([]string, x+1)
+			for y := 0; y < x; y++ {
+				inner[y] = fmt.Sprintf("(%s = ?)", sql.EscapeName(keyColumns[y].Name))
+				args = append(args, values[y])
+			}
+			itemOp := op
+			if x == len(keyColumns)-1 {
+				itemOp += "="
+			}
+			inner[x] = fmt.Sprintf("(%s %s ?)", sql.EscapeName(keyColumns[x].Name), itemOp)
+			args = append(args, values[x])
+			items[x] = fmt.Sprintf("(%s)", strings.Join(inner, " and "))
+		}
+		conds = append(conds, fmt.Sprintf("(%s)", strings.Join(items, " or ")))
+	}
+	if lo != nil {
+		bound(">", lo)
+	}
+	if hi != nil {
+		bound("<", hi)
+	}
+	if len(conds) == 0 {
+		return "true", nil
+	}
+	return strings.Join(conds, " and "), args
+}
+
+// describeKeyRange formats the bounds of a range of unique key values
+func describeKeyRange(lo, hi []interface{}) string {
+	format := func(values []interface{}, none string) string {
+		if values == nil {
+			return none
+		}
+		items := make([]string, len(values))
+		for i, v := range values {
+			items[i] = fmt.Sprintf("%s", v)
+		}
+		return fmt.Sprintf("(%s)", strings.Join(items, ", "))
+	}
+	return fmt.Sprintf("%s to %s", format(lo, "start"), format(hi, "end"))
+}
+
+func escapedNames(columns []umconf.Column) string {
+	names := make([]string, len(columns))
+	for i := range columns {
+		names[i] = sql.EscapeName(columns[i].Name)
+	}
+	return strings.Join(names, ", ")
+}
+
+// tableWhere is the 'where' of the rows of a table the job replicates
+func tableWhere(table *config.Table) string {
+	if table.Where == "" {
+		return "true"
+	}
+	return table.Where
+}
+
+func isIntegerColumn(column *umconf.Column) bool {
+	switch column.Type {
+	case umconf.TinyintColumnType, umconf.SmallintColumnType, umconf.MediumIntColumnType,
+		umconf.IntColumnType, umconf.BigIntColumnType:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,48 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package mysql
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/actiontech/dtle/internal/config"
+)
+
+// validateCopyMode checks CopyMode against the other job arguments
+func (e *Extractor) validateCopyMode() error {
+	switch e.mysqlContext.CopyMode {
+	case "":
+	case config.CopyModeSchemaOnly:
+		if e.mysqlContext.SkipCreateDbTable {
+			return fmt.Errorf("conflicting job argument: CopyMode=%v and SkipCreateDbTable=true", e.mysqlContext.CopyMode)
+		}
+	case config.CopyModeDataOnly:
+		if e.mysqlContext.DropTableIfExists {
+			return fmt.Errorf("conflicting job argument: CopyMode=%v and DropTableIfExists=true", e.mysqlContext.CopyMode)
+		}
+	default:
+		return fmt.Errorf("unknown CopyMode %q: must be %q, %q or empty", e.mysqlContext.CopyMode,
+			config.CopyModeSchemaOnly, config.CopyModeDataOnly)
+	}
+	return nil
+}
+
+// copiesSchema tells whether the snapshot creates the databases, tables and
+// views on the target
+func (e *Extractor) copiesSchema() bool {
+	return !e.mysqlContext.SkipCreateDbTable && e.mysqlContext.CopyMode != config.CopyModeDataOnly
+}
+
+// copiesData tells whether the snapshot copies the rows of the tables
+func (e *Extractor) copiesData() bool {
+	return e.mysqlContext.CopyMode != config.CopyModeSchemaOnly
+}
+
+func isView(tb *config.Table) bool {
+	return strings.ToLower(tb.TableType) == "view"
+}
@@ -0,0 +1,158 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package mysql
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/actiontech/dtle/internal/models"
+)
+
+var (
+	tableEngineRegexp     = regexp.MustCompile(`\bENGINE=\w+`)
+	partitionEngineRegexp = regexp.MustCompile(`\bENGINE = \w+`)
+	tableCharsetRegexp    = regexp.MustCompile(`\bDEFAULT CHARSET=\w+( COLLATE=\w+)?`)
+	columnCharsetRegexp   = regexp.MustCompile(` CHARACTER SET \w+( COLLATE \w+)?| COLLATE \w+`)
+	tableNameRegexp       = regexp.MustCompile("^CREATE TABLE (`(?:[^`]|``)*`)")
+)
+
+// schemaMappings are the changes made to the definitions of the tables the
+// applier created, as of CreateTableEngine, CreateTableCharset and
+// CreateTableStripPartitions
+type schemaMappings struct {
+	mutex    sync.Mutex
+	mappings []*models.SchemaMapping
+}
+
+func (s *schemaMappings) add(mappings []*models.SchemaMapping) {
+	if len(mappings) == 0 {
+		return
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.mappings = append(s.mappings, mappings...)
+}
+
+func (s *schemaMappings) list() []*models.SchemaMapping {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return append([]*models.SchemaMapping(nil), s.mappings...)
+}
+
+// rewriteCreateDatabase sets the charset of a database created, which is
+// that of the target by default
+func (a *Applier) rewriteCreateDatabase(statement string) string {
+	if a.mysqlContext.CreateTableCharset == "" {
+		return statement
+	}
+	return fmt.Sprintf("%s DEFAULT CHARACTER SET %s", statement, a.mysqlContext.CreateTableCharset)
+}
+
+// rewriteCreateTable rewrites a CREATE TABLE of the snapshot, as printed by
+// SHOW CREATE TABLE, and returns the changes made to the definition
+func (a *Applier) rewriteCreateTable(schema, statement string) (string, []*models.SchemaMapping) {
+	engine := a.mysqlContext.CreateTableEngine
+	charset := a.mysqlContext.CreateTableCharset
+	stripPartitions := a.mysqlContext.CreateTableStripPartitions
+	if engine == "" && charset == "" && !stripPartitions {
+		return statement, nil
+	}
+
+	var table string
+	if m := tableNameRegexp.FindStringSubmatch(statement); m != nil {
+		table = strings.Replace(m[1][1:len(m[1])-1], "``", "`", -1)
+	}
+	var mappings []*models.SchemaMapping
+	mapping := func(column, source, target string) {
+		mappings = append(mappings, &models.SchemaMapping{
+			TableSchema: schema,
+			TableName:   table,
+			ColumnName:  column,
+			Source:      source,
+			Target:      target,
+		})
+	}
+
+	lines := strings.Split(statement, "\n")
+	// The table options follow the last column or key, on the line starting
+	// with ")", and the partitioning after them
+	optionsLine := len(lines)
+	for i := 1; i < len(lines); i++ {
+		if strings.HasPrefix(lines[i], ")") {
+			optionsLine = i
+			break
+		}
+	}
+	for i := 1; i < optionsLine; i++ {
+		line := lines[i]
+		if charset == "" || !strings.HasPrefix(line, "  `") {
+			continue
+		}
+		// A column: "  `name` definition,"
+		end := strings.Index(line[3:], "` ")
+		if end < 0 {
+			continue
+		}
+		column := strings.Replace(line[3:3+end], "``", "`", -1)
+		definition := strings.TrimSuffix(line[3+end+2:], ",")
+		rewritten := columnCharsetRegexp.ReplaceAllStringFunc(definition, func(clause string) string {
+			if strings.HasPrefix(clause, " CHARACTER SET ") {
+				return " CHARACTER SET " + charset
+			}
+			// The collation is that of the source charset
+			return ""
+		})
+		if rewritten != definition {
+			lines[i] = strings.Replace(line, definition, rewritten, 1)
+			mapping(column, definition, rewritten)
+		}
+	}
+
+	if optionsLine < len(lines) {
+		options := lines[optionsLine]
+		rewritten := options
+		if engine != "" {
+			rewritten = tableEngineRegexp.ReplaceAllString(rewritten, "ENGINE="+engine)
+		}
+		if charset != "" {
+			rewritten = tableCharsetRegexp.ReplaceAllString(rewritten, "DEFAULT CHARSET="+charset)
+		}
+		if rewritten != options {
+			lines[optionsLine] = rewritten
+			mapping("", strings.TrimPrefix(options, ") "), strings.TrimPrefix(rewritten, ") "))
+		}
+
+		if partitioning := lines[optionsLine+1:]; len(partitioning) > 0 {
+			if stripPartitions {
+				mapping("", strings.Join(partitioning, "\n"), "")
+				lines = lines[:optionsLine+1]
+			} else if engine != "" {
+				for i := range partitioning {
+					partitioning[i] = partitionEngineRegexp.ReplaceAllString(partitioning[i], "ENGINE = "+engine)
+				}
+			}
+		}
+	}
+
+	return strings.Join(lines, "\n"), mappings
+}
+
+// reportSchemaMappings logs and keeps the changes made to the definition of
+// a table created
+func (a *Applier) reportSchemaMappings(mappings []*models.SchemaMapping) {
+	for _, m := range mappings {
+		if m.ColumnName == "" {
+			a.logger.Infof("mysql.applier: table %s.%s created with %q for %q", m.TableSchema, m.TableName, m.Target, m.Source)
+		} else {
+			a.logger.Infof("mysql.applier: column %s.%s.%s created as %q for %q", m.TableSchema, m.TableName, m.ColumnName, m.Target, m.Source)
+		}
+	}
+	a.schemaMappings.add(mappings)
+}
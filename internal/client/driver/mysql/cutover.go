@@ -0,0 +1,91 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package mysql
+
+import (
+	gosql "database/sql"
+
+	gomysqldriver "github.com/go-sql-driver/mysql"
+	"github.com/satori/go.uuid"
+	gomysql "github.com/siddontang/go-mysql/mysql"
+
+	"github.com/actiontech/dtle/internal/client/driver/mysql/base"
+	"github.com/actiontech/dtle/internal/client/driver/mysql/sql"
+)
+
+// GtidExecuted returns the GTID set executed on a server
+func GtidExecuted(db *gosql.DB) (string, error) {
+	var gtidSet string
+	if err := db.QueryRow("SELECT @@global.gtid_executed").Scan(&gtidSet); err != nil {
+		return "", err
+	}
+	return gtidSet, nil
+}
+
+// SetSourceReadOnly sets a source super_read_only, or read_only before
+// 5.7.8, so that no more transactions are executed on it
+func SetSourceReadOnly(db *gosql.DB) error {
+	_, err := db.Exec("SET GLOBAL super_read_only = ON")
+	if mysqlErr, ok := err.(*gomysqldriver.MySQLError); ok && mysqlErr.Number == sql.ErrUnknownSystemVariable {
+		_, err = db.Exec("SET GLOBAL read_only = ON")
+	}
+	return err
+}
+
+// TransactionsBehind returns the transactions of a source GTID set a job
+// has yet to apply to its target. The job has applied its checkpoint and
+// the transactions recorded in the target since. As the transactions of a
+// source server are applied in order, those after the last applied one are
+// counted.
+func TransactionsBehind(target *gosql.DB, jobID string, checkpoint string, sourceGtidSet string) (int64, error) {
+	source, err := gomysql.ParseMysqlGTIDSet(sourceGtidSet)
+	if err != nil {
+		return 0, err
+	}
+	applied := make(map[string]int64)
+	if checkpoint != "" {
+		set, err := gomysql.ParseMysqlGTIDSet(checkpoint)
+		if err != nil {
+			return 0, err
+		}
+		for sid, uuidSet := range set.(*gomysql.MysqlGTIDSet).Sets {
+			applied[sid] = lastGno(uuidSet.Intervals)
+		}
+	}
+	jobUUID, err := uuid.FromString(jobID)
+	if err != nil {
+		return 0, err
+	}
+	executed, err := base.SelectAllGtidExecuted(target, jobUUID)
+	if err != nil {
+		return 0, err
+	}
+	for sid, item := range executed {
+		if gno := lastGno(item.Intervals); gno > applied[sid.String()] {
+			applied[sid.String()] = gno
+		}
+	}
+
+	var behind int64
+	for sid, uuidSet := range source.(*gomysql.MysqlGTIDSet).Sets {
+		if gno := lastGno(uuidSet.Intervals); gno > applied[sid] {
+			behind += gno - applied[sid]
+		}
+	}
+	return behind, nil
+}
+
+// lastGno returns the last GNO of some intervals, 0 if there is none
+func lastGno(intervals gomysql.IntervalSlice) int64 {
+	var gno int64
+	for _, i := range intervals {
+		if i.Stop-1 > gno {
+			gno = i.Stop - 1
+		}
+	}
+	return gno
+}
@@ -0,0 +1,112 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package mysql
+
+import (
+	gosql "database/sql"
+	"fmt"
+	"time"
+
+	"github.com/satori/go.uuid"
+
+	"github.com/actiontech/dtle/internal/client/driver/mysql/binlog"
+	"github.com/actiontech/dtle/internal/g"
+	"github.com/actiontech/dtle/internal/models"
+)
+
+// ddlHistoryLimit is the number of DDLs listed, the last ones
+const ddlHistoryLimit = 1000
+
+// execer is a connection or a transaction of the target
+type execer interface {
+	Exec(query string, args ...interface{}) (gosql.Result, error)
+}
+
+// createDDLHistoryTable creates the table of the DDLs the jobs replicated to
+// a target, if missing
+func createDDLHistoryTable(db *gosql.DB) error {
+	if _, err := db.Exec(fmt.Sprintf("CREATE DATABASE IF NOT EXISTS %v", g.DtleSchemaName)); err != nil {
+		return err
+	}
+	_, err := db.Exec(fmt.Sprintf(`
+			CREATE TABLE IF NOT EXISTS %v.%v (
+				id bigint NOT NULL AUTO_INCREMENT,
+				job_uuid binary(16) NOT NULL COMMENT 'unique identifier of job',
+				gtid varchar(64) NOT NULL COMMENT 'gtid of the DDL on the source',
+				table_schema varchar(64) NOT NULL,
+				table_name varchar(64) NOT NULL,
+				query longtext NOT NULL,
+				status varchar(16) NOT NULL COMMENT 'applied, skipped or failed',
+				exec_millis bigint NOT NULL,
+				error text NOT NULL,
+				replicated_at timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP,
+				PRIMARY KEY (id),
+				KEY job_uuid (job_uuid, id)
+			)
+		`, g.DtleSchemaName, g.DDLHistoryTableV1))
+	return err
+}
+
+// recordDDL records a DDL of a source transaction in the history of the
+// target, in the transaction applying it unless the DDL failed. The DDL is
+// applied whether it is recorded or not.
+func (a *Applier) recordDDL(db execer, binlogEntry *binlog.BinlogEntry, event *binlog.DataEvent,
+	status string, execTime time.Duration, ddlErr error) {
+
+	schema := event.DatabaseName
+	if schema == "" {
+		schema = event.CurrentSchema
+	}
+	var errText string
+	if ddlErr != nil {
+		errText = ddlErr.Error()
+	}
+	_, err := db.Exec(fmt.Sprintf("INSERT INTO %v.%v "+
+		"(job_uuid, gtid, table_schema, table_name, query, status, exec_millis, error) "+
+		"VALUES (?, ?, ?, ?, ?, ?, ?, ?)", g.DtleSchemaName, g.DDLHistoryTableV1),
+		a.subjectUUID.Bytes(),
+		fmt.Sprintf("%s:%d", binlogEntry.Coordinates.GetSid(), binlogEntry.Coordinates.GNO),
+		schema, event.TableName, event.Query, status, int64(execTime/time.Millisecond), errText)
+	if err != nil {
+		a.logger.Warnf("mysql.applier: recording the %s DDL of gno %d: %v", status, binlogEntry.Coordinates.GNO, err)
+	}
+}
+
+// DDLHistory returns the last DDLs a job replicated to its target, the
+// oldest first
+func DDLHistory(target *gosql.DB, jobID string) ([]*models.DDLRecord, error) {
+	jobUUID, err := uuid.FromString(jobID)
+	if err != nil {
+		return nil, err
+	}
+	if err := createDDLHistoryTable(target); err != nil {
+		return nil, err
+	}
+	rows, err := target.Query(fmt.Sprintf("SELECT gtid, table_schema, table_name, query, status, exec_millis, error, replicated_at "+
+		"FROM %v.%v WHERE job_uuid = ? ORDER BY id DESC LIMIT %d",
+		g.DtleSchemaName, g.DDLHistoryTableV1, ddlHistoryLimit), jobUUID.Bytes())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var ddls []*models.DDLRecord
+	for rows.Next() {
+		var ddl models.DDLRecord
+		if err := rows.Scan(&ddl.Gtid, &ddl.TableSchema, &ddl.TableName, &ddl.Query, &ddl.Status,
+			&ddl.ExecMillis, &ddl.Error, &ddl.Time); err != nil {
+			return nil, err
+		}
+		ddls = append(ddls, &ddl)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	for i, j := 0, len(ddls)-1; i < j; i, j = i+1, j-1 {
+		ddls[i], ddls[j] = ddls[j], ddls[i]
+	}
+	return ddls, nil
+}
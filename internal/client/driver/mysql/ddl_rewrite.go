@@ -0,0 +1,227 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package mysql
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/actiontech/dtle/internal/models"
+)
+
+// maxDDLRewrites is the number of the last rewritten DDLs kept for the task
+// statistics
+const maxDDLRewrites = 100
+
+// ddlRewriteRule adapts the DDLs of the source to the version of the target
+type ddlRewriteRule struct {
+	name string
+	// defaultFor tells if the rule applies by default to a target
+	defaultFor func(target ddlTarget) bool
+	rewrite    func(query string) string
+}
+
+var (
+	collation0900Regexp  = regexp.MustCompile(`(?i)\butf8mb4_0900_\w+`)
+	invisibleIndexRegexp = regexp.MustCompile(`(?i)\)\s+(?:IN)?VISIBLE\b`)
+	sridRegexp           = regexp.MustCompile(`(?i)\s+SRID\s+\d+`)
+	utf8mb3Regexp        = regexp.MustCompile(`(?i)\butf8(?:mb3)?(_\w+)?\b`)
+)
+
+// ddlTarget is the server the DDLs are adapted to
+type ddlTarget struct {
+	// version is as returned by parseMySQLVersion
+	version int
+	tidb    bool
+}
+
+func before80(target ddlTarget) bool {
+	return target.version < 80000
+}
+
+// ddlRewriteRules are the rules known, by the name DDLRewriteRules refers to
+var ddlRewriteRules = []*ddlRewriteRule{
+	{
+		// The 8.0 collations, default of utf8mb4 since 8.0.1
+		name:       "collation_0900",
+		defaultFor: before80,
+		rewrite: func(query string) string {
+			return collation0900Regexp.ReplaceAllString(query, "utf8mb4_general_ci")
+		},
+	},
+	{
+		// The visibility of the indexes, since 8.0.0
+		name:       "invisible_index",
+		defaultFor: before80,
+		rewrite: func(query string) string {
+			return invisibleIndexRegexp.ReplaceAllString(query, ")")
+		},
+	},
+	{
+		// The SRID of the spatial columns, since 8.0.3
+		name:       "srid",
+		defaultFor: before80,
+		rewrite: func(query string) string {
+			return sridRegexp.ReplaceAllString(query, "")
+		},
+	},
+	{
+		// utf8, an alias of utf8mb3 deprecated in 8.0, and its collations to
+		// utf8mb4. Not a default, as it enlarges the columns and the keys.
+		name: "utf8mb3",
+		defaultFor: func(target ddlTarget) bool {
+			return false
+		},
+		rewrite: func(query string) string {
+			return utf8mb3Regexp.ReplaceAllString(query, "utf8mb4$1")
+		},
+	},
+	{
+		// The full-text and spatial indexes, which TiDB does not support
+		name: "tidb_fulltext_index",
+		defaultFor: func(target ddlTarget) bool {
+			return target.tidb
+		},
+		rewrite: stripTiDBUnsupportedIndexes,
+	},
+}
+
+// parseMySQLVersion returns a version such as "5.7.22-log" as 50722
+func parseMySQLVersion(version string) int {
+	parts := strings.SplitN(version, ".", 3)
+	n := 0
+	for i := 0; i < 3; i++ {
+		n *= 100
+		if i < len(parts) {
+			digits := parts[i]
+			if end := strings.IndexFunc(digits, func(r rune) bool { return r < '0' || r > '9' }); end >= 0 {
+				digits = digits[:end]
+			}
+			v, _ := strconv.Atoi(digits)
+			n += v
+		}
+	}
+	return n
+}
+
+// ddlRewriter applies the rules enabled for the target to the DDLs. In dry
+// run, the DDLs are applied as is, the rewrites being only reported.
+type ddlRewriter struct {
+	rules  []*ddlRewriteRule
+	dryRun bool
+
+	mutex    sync.Mutex
+	rewrites []*models.DDLRewrite
+}
+
+// newDDLRewriter picks the rules applying to the target by default, then
+// enables or disables those named in enabled
+func newDDLRewriter(targetVersion string, tidb bool, enabled map[string]bool, dryRun bool) (*ddlRewriter, error) {
+	known := make(map[string]bool)
+	for _, rule := range ddlRewriteRules {
+		known[rule.name] = true
+	}
+	for name := range enabled {
+		if !known[name] {
+			return nil, fmt.Errorf("unknown DDL rewrite rule %q", name)
+		}
+	}
+
+	target := ddlTarget{version: parseMySQLVersion(targetVersion), tidb: tidb}
+	r := &ddlRewriter{dryRun: dryRun}
+	for _, rule := range ddlRewriteRules {
+		on, ok := enabled[rule.name]
+		if !ok {
+			on = rule.defaultFor(target)
+		}
+		if on {
+			r.rules = append(r.rules, rule)
+		}
+	}
+	return r, nil
+}
+
+// ruleNames returns the names of the rules enabled
+func (r *ddlRewriter) ruleNames() []string {
+	var names []string
+	for _, rule := range r.rules {
+		names = append(names, rule.name)
+	}
+	return names
+}
+
+// rewrite returns the query to apply and the rewrite made, nil if none
+func (r *ddlRewriter) rewrite(query string) (string, *models.DDLRewrite) {
+	rewritten := query
+	var applied []string
+	for _, rule := range r.rules {
+		if q := rule.rewrite(rewritten); q != rewritten {
+			rewritten = q
+			applied = append(applied, rule.name)
+		}
+	}
+	if len(applied) == 0 {
+		return query, nil
+	}
+	rewrite := &models.DDLRewrite{
+		Rules:     applied,
+		Query:     query,
+		Rewritten: rewritten,
+		DryRun:    r.dryRun,
+	}
+	if r.dryRun {
+		return query, rewrite
+	}
+	return rewritten, rewrite
+}
+
+func (r *ddlRewriter) add(rewrite *models.DDLRewrite) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.rewrites = append(r.rewrites, rewrite)
+	if len(r.rewrites) > maxDDLRewrites {
+		r.rewrites = r.rewrites[len(r.rewrites)-maxDDLRewrites:]
+	}
+}
+
+func (r *ddlRewriter) list() []*models.DDLRewrite {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return append([]*models.DDLRewrite(nil), r.rewrites...)
+}
+
+// rewriteDDL adapts a DDL to the version of the target, logging and keeping
+// the rewrite made
+func (a *Applier) rewriteDDL(query string) string {
+	if a.ddlRewriter == nil {
+		return query
+	}
+	query, rewrite := a.ddlRewriter.rewrite(query)
+	if rewrite == nil {
+		return query
+	}
+	if rewrite.DryRun {
+		a.logger.Infof("mysql.applier: DDL would be rewritten by %v as %q: %q",
+			rewrite.Rules, rewrite.Rewritten, rewrite.Query)
+	} else {
+		a.logger.Infof("mysql.applier: DDL rewritten by %v as %q: %q",
+			rewrite.Rules, rewrite.Rewritten, rewrite.Query)
+	}
+	a.ddlRewriter.add(rewrite)
+	return query
+}
+
+// ddlRewrites returns the last DDLs rewritten, for the task statistics
+func (a *Applier) ddlRewrites() []*models.DDLRewrite {
+	if a.ddlRewriter == nil {
+		return nil
+	}
+	return a.ddlRewriter.list()
+}
@@ -0,0 +1,210 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package mysql
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/actiontech/dtle/internal/client/driver/mysql/binlog"
+	"github.com/actiontech/dtle/internal/client/driver/mysql/sql"
+	umconf "github.com/actiontech/dtle/internal/config/mysql"
+	"github.com/actiontech/dtle/internal/models"
+)
+
+// dryRunWriter writes the statements a dry-run applier would execute to a
+// file, one per line
+type dryRunWriter struct {
+	mu   sync.Mutex
+	file *os.File
+}
+
+func newDryRunWriter(path string) (*dryRunWriter, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0640)
+	if err != nil {
+		return nil, err
+	}
+	return &dryRunWriter{file: file}, nil
+}
+
+// write writes some statements at once, so that those of a transaction are
+// not mixed with those of another worker
+func (w *dryRunWriter) write(queries ...string) error {
+	bs := formatStatements(queries...)
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	_, err := w.file.Write(bs)
+	return err
+}
+
+// formatStatements returns some statements one per line, terminated by a
+// semicolon unless they are comments
+func formatStatements(queries ...string) []byte {
+	var buf bytes.Buffer
+	for _, query := range queries {
+		buf.WriteString(query)
+		if !strings.HasPrefix(query, "--") {
+			buf.WriteByte(';')
+		}
+		buf.WriteByte('\n')
+	}
+	return buf.Bytes()
+}
+
+func (w *dryRunWriter) Close() error {
+	return w.file.Close()
+}
+
+// dryRunBinlogTx writes the statements applying source transactions in a
+// transaction of the target instead of executing them
+func (a *Applier) dryRunBinlogTx(binlogEntries []*binlog.BinlogEntry) error {
+	var queries []string
+	for _, binlogEntry := range binlogEntries {
+		queries = append(queries,
+			fmt.Sprintf("-- gtid %s:%d", binlogEntry.Coordinates.GetSid(), binlogEntry.Coordinates.GNO))
+	}
+	queries = append(queries, "BEGIN")
+	for _, binlogEntry := range binlogEntries {
+		for i := range binlogEntry.Events {
+			event := &binlogEntry.Events[i]
+			if event.DML == binlog.NotDML {
+				if event.CurrentSchema != "" {
+					queries = append(queries, fmt.Sprintf("USE %s", event.CurrentSchema))
+				}
+				if a.skipsDDL(event.Query) {
+					continue
+				}
+				queries = append(queries, a.rewriteDDL(event.Query))
+				continue
+			}
+			query, err := a.dmlQueryText(binlogEntry, event)
+			if err != nil {
+				return err
+			}
+			queries = append(queries, query)
+		}
+	}
+	queries = append(queries, "COMMIT")
+	if err := a.dryRun.write(queries...); err != nil {
+		return err
+	}
+
+	a.mysqlContext.Stage = models.StageWaitingForGtidToBeCommitted
+	atomic.AddInt64(&a.mysqlContext.TotalDeltaCopied, int64(len(binlogEntries)))
+	return nil
+}
+
+// dmlQueryText returns the statement applying a row event, with its
+// arguments inlined, for the dry run and the slow apply log
+func (a *Applier) dmlQueryText(binlogEntry *binlog.BinlogEntry, dmlEvent *binlog.DataEvent) (string, error) {
+	tableColumns := dmlEvent.TableItem.(*applierTableItem).columns
+	if a.mysqlContext.History.Applies(dmlEvent.DatabaseName, dmlEvent.TableName) {
+		query, args, err := a.historyQuery(binlogEntry, dmlEvent, tableColumns)
+		if err != nil {
+			return "", err
+		}
+		return inlineArgs(query, args)
+	}
+	softDelete := a.mysqlContext.SoftDelete
+	if dmlEvent.DML == binlog.DeleteDML && softDelete.Applies(dmlEvent.DatabaseName, dmlEvent.TableName) {
+		query, args, err := sql.BuildDMLSoftDeleteQuery(dmlEvent.DatabaseName, dmlEvent.TableName, tableColumns,
+			softDelete.Column, softDelete.Value, dmlEvent.WhereColumnValues.GetAbstractValues())
+		if err != nil {
+			return "", err
+		}
+		return inlineArgs(query, args)
+	}
+	return dmlEventQueryText(dmlEvent, tableColumns)
+}
+
+// dmlEventQueryText returns the statement applying a row event to a table
+// of the given columns, with its arguments inlined
+func dmlEventQueryText(dmlEvent *binlog.DataEvent, tableColumns *umconf.ColumnList) (string, error) {
+	var query string
+	var args []interface{}
+	var err error
+	switch dmlEvent.DML {
+	case binlog.DeleteDML:
+		query, args, err = sql.BuildDMLDeleteQuery(dmlEvent.DatabaseName, dmlEvent.TableName, tableColumns,
+			dmlEvent.WhereColumnValues.GetAbstractValues())
+	case binlog.InsertDML:
+		query, args, err = sql.BuildDMLInsertQuery(dmlEvent.DatabaseName, dmlEvent.TableName, tableColumns,
+			tableColumns, tableColumns, dmlEvent.NewColumnValues.GetAbstractValues())
+	case binlog.UpdateDML:
+		var uniqueKeyArgs []interface{}
+		query, args, uniqueKeyArgs, err = sql.BuildDMLUpdateQuery(dmlEvent.DatabaseName, dmlEvent.TableName,
+			tableColumns, tableColumns, tableColumns, tableColumns,
+			dmlEvent.NewColumnValues.GetAbstractValues(), dmlEvent.WhereColumnValues.GetAbstractValues())
+		args = append(args, uniqueKeyArgs...)
+	default:
+		return "", fmt.Errorf("Unknown dml event type: %+v", dmlEvent.DML)
+	}
+	if err != nil {
+		return "", err
+	}
+	return inlineArgs(query, args)
+}
+
+// inlineArgs replaces the placeholders of a statement with its arguments.
+// The question marks in quoted names and strings are left alone.
+func inlineArgs(query string, args []interface{}) (string, error) {
+	var buf bytes.Buffer
+	var quote byte
+	n := 0
+	for i := 0; i < len(query); i++ {
+		c := query[i]
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			} else if c == '\\' && quote != '`' && i+1 < len(query) {
+				buf.WriteByte(c)
+				i++
+				c = query[i]
+			}
+		case c == '`' || c == '\'' || c == '"':
+			quote = c
+		case c == '?':
+			if n >= len(args) {
+				return "", fmt.Errorf("more placeholders than the %d arguments in %s", len(args), query)
+			}
+			buf.WriteString(sqlLiteral(args[n]))
+			n++
+			continue
+		}
+		buf.WriteByte(c)
+	}
+	if n != len(args) {
+		return "", fmt.Errorf("%d placeholders for %d arguments in %s", n, len(args), query)
+	}
+	return buf.String(), nil
+}
+
+// sqlLiteral returns the SQL literal of an argument of a statement
+func sqlLiteral(arg interface{}) string {
+	switch v := arg.(type) {
+	case nil:
+		return "NULL"
+	case []byte:
+		return fmt.Sprintf("'%s'", sql.EscapeValue(string(v)))
+	case string:
+		return fmt.Sprintf("'%s'", sql.EscapeValue(v))
+	case bool:
+		if v {
+			return "1"
+		}
+		return "0"
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64, float32, float64:
+		return fmt.Sprintf("%v", v)
+	default:
+		return fmt.Sprintf("'%s'", sql.EscapeValue(fmt.Sprintf("%v", v)))
+	}
+}
@@ -0,0 +1,190 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package mysql
+
+import (
+	gosql "database/sql"
+	"fmt"
+	"sync"
+	"sync/atomic"
+
+	"github.com/actiontech/dtle/internal/client/driver/mysql/util"
+	"github.com/actiontech/dtle/internal/config"
+	"github.com/actiontech/dtle/internal/models"
+)
+
+// dumpConnections returns the number of connections copying the tables:
+// DumpConnections, at most MaxDumpConnections and the number of tables, and
+// leaving the source at least half of its free connections.
+func (e *Extractor) dumpConnections() (int, error) {
+	n := e.mysqlContext.DumpConnections
+	if n > config.MaxDumpConnections {
+		e.logger.Warnf("mysql.extractor: DumpConnections %d lowered to %d", n, config.MaxDumpConnections)
+		n = config.MaxDumpConnections
+	}
+	nTables := 0
+	for _, db := range e.replicateDoDb {
+		for _, tb := range db.Tables {
+			if !isView(tb) {
+				nTables++
+			}
+		}
+	}
+	if n > nTables {
+		n = nTables
+	}
+	if n <= 1 || !e.copiesData() {
+		return 1, nil
+	}
+	if e.mysqlContext.SnapshotOrder == config.SnapshotOrderForeignKey {
+		e.logger.Warnf("mysql.extractor: the tables are copied with 1 connection: SnapshotOrder %q needs them copied in order",
+			e.mysqlContext.SnapshotOrder)
+		return 1, nil
+	}
+
+	var maxConnections, connected int64
+	if err := e.db.QueryRow("select @@global.max_connections").Scan(&maxConnections); err != nil {
+		return 0, err
+	}
+	var variableName string
+	if err := e.db.QueryRow("show global status like 'Threads_connected'").Scan(&variableName, &connected); err != nil {
+		return 0, err
+	}
+	if free := (maxConnections - connected) / 2; int64(n) > free {
+		e.logger.Warnf("mysql.extractor: DumpConnections %d lowered to %d: %d of the %d connections of the source are in use",
+			n, free, connected, maxConnections)
+		n = int(free)
+		if n < 1 {
+			n = 1
+		}
+	}
+	return n, nil
+}
+
+// dumpTables copies the rows of the tables, each connection reading in its
+// transaction of txs and taking the next table once done with one. In
+// per_table mode, a connection reads each of its tables after the first in a
+// new snapshot, replacing its transaction in txs.
+func (e *Extractor) dumpTables(txs []*gosql.Tx, setSystemVariablesStatement, setSqlMode string, step int) error {
+	var tables []*config.Table
+	var rowCounts []*models.TableRowCount
+	for _, db := range e.replicateDoDb {
+		for _, t := range db.Tables {
+			if !isView(t) {
+				tables = append(tables, t)
+				rowCounts = append(rowCounts, &models.TableRowCount{
+					TableSchema: t.TableSchema,
+					TableName:   t.TableName,
+				})
+			}
+		}
+	}
+
+	// mutex guards next and e.tableWatermarks
+	var mutex sync.Mutex
+	next := 0
+	dumpTable := func(i int, t *config.Table, counter int, limiter *util.RateLimiter) error {
+		rowCount := rowCounts[counter-1]
+		e.logger.Printf("mysql.extractor: Step %d: - scanning table '%s.%s' (%d of %d tables)", step, t.TableSchema, t.TableName, counter, e.tableCount)
+		d := NewDumper(txs[i], t, e.mysqlContext.ChunkSize, e.mysqlContext.QueueSizes.SnapshotChunks, e.logger)
+		d.onPanic = e.onPanic
+		if err := d.Dump(); err != nil {
+			e.onError(TaskStateDead, err)
+			return err
+		}
+		e.addDumper(d)
+		defer e.removeDumper(d)
+		// Scan the rows in the table ...
+		for entry := range d.resultsChannel {
+			if entry.err != nil {
+				e.onError(TaskStateDead, entry.err)
+			} else {
+				limiter.Wait(dumpEntrySize(entry), e.shutdownCh)
+				entry.SystemVariablesStatement = setSystemVariablesStatement
+				entry.SqlMode = setSqlMode
+
+				if e.needToSendTabelDef() {
+					entry.Table = d.table
+				}
+				if err := e.encodeDumpEntry(entry); err != nil {
+					e.onError(TaskStateRestart, err)
+				}
+				atomic.AddInt64(&e.mysqlContext.TotalRowsCopied, entry.RowsCount)
+				atomic.AddInt64(&rowCount.SourceRows, entry.RowsCount)
+			}
+		}
+		return nil
+	}
+	dump := func(i int) error {
+		limiter := util.NewRateLimiter(e.mysqlContext.DumpConnectionRate)
+		for first := true; ; first = false {
+			mutex.Lock()
+			if next == len(tables) {
+				mutex.Unlock()
+				return nil
+			}
+			t := tables[next]
+			next++
+			counter := next
+			mutex.Unlock()
+
+			if e.mysqlContext.SnapshotMode == config.SnapshotModePerTable && !first {
+				// Each table is copied in a snapshot of its own. The binlog
+				// transactions of a table before its snapshot are in the copy.
+				if err := txs[i].Commit(); err != nil {
+					return err
+				}
+				snapshotTxs, binlogCoordinates, err := e.consistentSnapshot(1)
+				if err != nil {
+					return err
+				}
+				txs[i] = snapshotTxs[0]
+				mutex.Lock()
+				e.tableWatermarks[fmt.Sprintf("%s.%s", t.TableSchema, t.TableName)] = binlogCoordinates.GtidSet
+				mutex.Unlock()
+				e.logger.Debugf("mysql.extractor: table '%s.%s' copied at %s", t.TableSchema, t.TableName, binlogCoordinates.GtidSet)
+			}
+			if err := dumpTable(i, t, counter, limiter); err != nil {
+				return err
+			}
+		}
+	}
+
+	errs := make([]error, len(txs))
+	var wg sync.WaitGroup
+	for i := range txs {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			defer models.RecoverPanic(func(err *models.PanicError) {
+				e.onPanic(err)
+				errs[i] = err
+			})
+			errs[i] = dump(i)
+		}(i)
+	}
+	wg.Wait()
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	e.snapshotRowCounts = rowCounts
+	return nil
+}
+
+// dumpEntrySize returns the bytes of the values of the rows of an entry
+func dumpEntrySize(entry *DumpEntry) (size int) {
+	for _, row := range entry.ValuesX {
+		for _, value := range row {
+			if bs, ok := (*value).([]byte); ok {
+				size += len(bs)
+			}
+		}
+	}
+	return size
+}
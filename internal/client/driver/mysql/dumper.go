@@ -22,6 +22,7 @@ import (
 	"github.com/actiontech/dtle/internal/config"
 	umconf "github.com/actiontech/dtle/internal/config/mysql"
 	log "github.com/actiontech/dtle/internal/logger"
+	"github.com/actiontech/dtle/internal/models"
 )
 
 type dumper struct {
@@ -43,9 +44,17 @@ type dumper struct {
 	// 0: don't checksum; 1: checksum once; 2: checksum every time
 	doChecksum int
 	oldWayDump bool
+
+	// columnNames and generatedColumns are set if the table has generated
+	// columns, see DumpEntry
+	columnNames      []string
+	generatedColumns []int
+
+	// onPanic is called if the dump panics, by the extractor
+	onPanic func(err *models.PanicError)
 }
 
-func NewDumper(db usql.QueryAble, table *config.Table, chunkSize int64,
+func NewDumper(db usql.QueryAble, table *config.Table, chunkSize int64, queueSize int,
 	logger *log.Entry) *dumper {
 
 	dumper := &dumper{
@@ -54,7 +63,7 @@ func NewDumper(db usql.QueryAble, table *config.Table, chunkSize int64,
 		TableSchema:    table.TableSchema,
 		TableName:      table.TableName,
 		table:          table,
-		resultsChannel: make(chan *DumpEntry, 24),
+		resultsChannel: make(chan *DumpEntry, queueSize),
 		chunkSize:      chunkSize,
 		shutdownCh:     make(chan struct{}),
 	}
@@ -76,6 +85,9 @@ func NewDumper(db usql.QueryAble, table *config.Table, chunkSize int64,
 type dumpStatResult struct {
 	Gtid       string
 	TotalCount int64
+	// TableRows are the rows of each table read by the snapshot, for the
+	// target to reconcile the rows it loaded
+	TableRows []*models.TableRowCount
 }
 
 type DumpEntry struct {
@@ -94,6 +106,12 @@ type DumpEntry struct {
 	colBuffer  bytes.Buffer
 	err        error
 	Table      *config.Table
+
+	// GeneratedColumns are the indexes in ValuesX of the generated columns,
+	// which the MySQL applier leaves out, inserting the other columns of
+	// ColumnNames by name
+	GeneratedColumns []int
+	ColumnNames      []string
 }
 
 func (e *DumpEntry) incrementCounter() {
@@ -110,6 +128,15 @@ func (d *dumper) prepareForDumping() error {
 		return err
 	}
 
+	for i, col := range columnList.Columns {
+		if col.IsGenerated {
+			d.generatedColumns = append(d.generatedColumns, i)
+		}
+	}
+	if len(d.generatedColumns) > 0 {
+		d.columnNames = columnList.Names()
+	}
+
 	needPm := false
 	columns := make([]string, 0)
 	for _, col := range columnList.Columns {
@@ -198,9 +225,11 @@ func (d *dumper) buildQueryOnUniqueKey() string {
 // dumps a specific chunk, reading chunk info from the channel
 func (d *dumper) getChunkData() (nRows int64, err error) {
 	entry := &DumpEntry{
-		TableSchema: d.TableSchema,
-		TableName:   d.TableName,
-		RowsCount:   0,
+		TableSchema:      d.TableSchema,
+		TableName:        d.TableName,
+		RowsCount:        0,
+		GeneratedColumns: d.generatedColumns,
+		ColumnNames:      d.columnNames,
 	}
 	// TODO use PS
 	// TODO escape schema/table/column name once and save
@@ -330,6 +359,8 @@ func (d *dumper) Dump() error {
 	}
 
 	go func() {
+		defer close(d.resultsChannel)
+		defer models.RecoverPanic(d.onPanic)
 		for {
 			select {
 			case <-d.shutdownCh:
@@ -352,7 +383,6 @@ func (d *dumper) Dump() error {
 				break
 			}
 		}
-		close(d.resultsChannel)
 	}()
 
 	return nil
@@ -0,0 +1,86 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package mysql
+
+import (
+	gosql "database/sql"
+
+	"github.com/actiontech/dtle/internal/config"
+	"github.com/actiontech/dtle/internal/g"
+	"github.com/actiontech/dtle/internal/models"
+)
+
+// EstimateTables returns the estimated size of the tables a source config
+// replicates, read from information_schema without scanning the tables.
+// The rows and sizes of InnoDB tables are approximations.
+func EstimateTables(db *gosql.DB, cfg *config.MySQLDriverConfig) ([]*models.TableEstimate, error) {
+	query := `SELECT t.TABLE_SCHEMA, t.TABLE_NAME, IFNULL(t.ENGINE, ''),
+		IFNULL(t.TABLE_ROWS, 0), IFNULL(t.DATA_LENGTH, 0), IFNULL(t.INDEX_LENGTH, 0),
+		EXISTS (SELECT 1 FROM information_schema.TABLE_CONSTRAINTS c
+			WHERE c.TABLE_SCHEMA = t.TABLE_SCHEMA AND c.TABLE_NAME = t.TABLE_NAME
+				AND c.CONSTRAINT_TYPE = 'PRIMARY KEY'),
+		EXISTS (SELECT 1 FROM information_schema.STATISTICS s
+			WHERE s.TABLE_SCHEMA = t.TABLE_SCHEMA AND s.TABLE_NAME = t.TABLE_NAME AND s.NON_UNIQUE = 0
+			GROUP BY s.INDEX_NAME HAVING SUM(s.NULLABLE = 'YES') = 0)
+		FROM information_schema.TABLES t
+		WHERE t.TABLE_TYPE = 'BASE TABLE'
+			AND t.TABLE_SCHEMA NOT IN ('sys', 'mysql', 'information_schema', 'performance_schema', ?)
+		ORDER BY t.TABLE_SCHEMA, t.TABLE_NAME`
+	rows, err := db.Query(query, g.DtleSchemaName)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var tables []*models.TableEstimate
+	for rows.Next() {
+		t := &models.TableEstimate{}
+		if err := rows.Scan(&t.TableSchema, &t.TableName, &t.Engine, &t.EstimatedRows, &t.DataLength, &t.IndexLength,
+			&t.HasPrimaryKey, &t.HasUniqueKey); err != nil {
+			return nil, err
+		}
+		if replicatesTable(cfg, t.TableSchema, t.TableName) {
+			tables = append(tables, t)
+		}
+	}
+	return tables, rows.Err()
+}
+
+// replicatesTable returns whether the ReplicateDoDb and ReplicateIgnoreDb
+// of a config select a table, as inspectTables does
+func replicatesTable(cfg *config.MySQLDriverConfig, schema, table string) bool {
+	if len(cfg.ReplicateDoDb) > 0 {
+		for _, doDb := range cfg.ReplicateDoDb {
+			if doDb.TableSchema == "" || doDb.TableSchema != schema {
+				continue
+			}
+			if len(doDb.Tables) == 0 {
+				return true
+			}
+			for _, doTb := range doDb.Tables {
+				if doTb.TableName == table {
+					return true
+				}
+			}
+		}
+		return false
+	}
+	for _, ignoreDb := range cfg.ReplicateIgnoreDb {
+		if ignoreDb.TableSchema != schema {
+			continue
+		}
+		if len(ignoreDb.Tables) == 0 {
+			return false
+		}
+		for _, ignoreTb := range ignoreDb.Tables {
+			if ignoreTb.TableName == table {
+				return false
+			}
+		}
+	}
+	return true
+}
@@ -17,6 +17,7 @@ import (
 	"bytes"
 	"encoding/gob"
 	"math"
+	"net/url"
 	"strconv"
 	"strings"
 	"sync"
@@ -31,7 +32,9 @@ import (
 
 	"github.com/actiontech/dtle/internal/client/driver/mysql/base"
 	"github.com/actiontech/dtle/internal/client/driver/mysql/binlog"
+	"github.com/actiontech/dtle/internal/client/driver/mysql/binlogserver"
 	"github.com/actiontech/dtle/internal/client/driver/mysql/sql"
+	"github.com/actiontech/dtle/internal/client/driver/mysql/util"
 	"github.com/actiontech/dtle/internal/config"
 	log "github.com/actiontech/dtle/internal/logger"
 	"github.com/actiontech/dtle/internal/models"
@@ -54,7 +57,11 @@ type Extractor struct {
 	mysqlContext *config.MySQLDriverConfig
 	db           *gosql.DB
 	singletonDB  *gosql.DB
-	dumpers      []*dumper
+	// primaryDB is the primary of the source, when reading from a replica
+	primaryDB *gosql.DB
+	// dumpers are those copying a table, guarded by dumpersMu
+	dumpers   []*dumper
+	dumpersMu sync.Mutex
 	// db.tb exists when creating the job, for full-copy.
 	// vs e.mysqlContext.ReplicateDoDb: all user assigned db.tb
 	replicateDoDb            []*config.DataSource
@@ -70,20 +77,77 @@ type Extractor struct {
 
 	sendByTimeoutCounter  int
 	sendBySizeFullCounter int
+	// sendQueue is the groups of entries encoded ahead of the one being
+	// sent, so that reading the binlog and grouping go on during the round
+	// trips to the applier
+	sendQueue chan *entriesMsg
 
 	natsConn *gonats.Conn
 	waitCh   chan *models.WaitResult
 
+	// bandwidth limits the bytes published per second, as assigned by the
+	// quota of the job namespace
+	bandwidth *util.RateLimiter
+
+	heartbeat *binlog.HeartbeatMonitor
+
+	// groupSizer sizes the groups of entries sent to the applier
+	groupSizer *groupSizer
+
+	// tableWatermarks is the GTID set each table was copied at by "schema.table",
+	// for the tables copied after the initial binlog coordinates
+	tableWatermarks map[string]string
+
+	// snapshotRowCounts are the rows of each table read by the snapshot
+	snapshotRowCounts []*models.TableRowCount
+
+	// consistencyCheck samples the tables on the source and the target
+	consistencyCheck *consistencyCheck
+
+	// binlogStore and binlogServer serve the binlog read to the replicas of
+	// the BinlogServer
+	binlogStore  *binlogserver.Store
+	binlogServer *binlogserver.Server
+	// relayStore keeps the binlog relayed from the source by relay, which
+	// the binlog readers read from relayServer, with BinlogRelay
+	relayStore    *binlogserver.Store
+	relayServer   *binlogserver.Server
+	relayPassword string
+	relay         *binlog.Relay
+	// relayFrom is the extractor whose relay the binlog reader reads, the
+	// extractor itself or that of BinlogRelay.Job
+	relayFrom *Extractor
+
 	shutdown     bool
 	shutdownCh   chan struct{}
 	shutdownLock sync.Mutex
 
+	// draining is set by Drain, which closes the binlog reader so that no
+	// new event is read
+	draining int32
+	// drainCh asks the goroutine grouping the events read to send them,
+	// closing the channel sent once they are
+	drainCh chan chan struct{}
+	// streamStopped is closed once StreamEvents stops reading the binlog
+	streamStopped chan struct{}
+
 	testStub1Delay int64
+	// faults are those of FaultInjection, if allowed on the node
+	faults *config.FaultInjector
 }
 
 func NewExtractor(subject, tp string, maxPayload int, cfg *config.MySQLDriverConfig, logger *log.Logger) (*Extractor, error) {
 
 	cfg = cfg.SetDefault()
+	if err := cfg.ConnectionConfig.Prepare(); err != nil {
+		return nil, err
+	}
+	cfg.ConnectionConfig.CountTraffic()
+	if cfg.PrimaryConnectionConfig != nil {
+		if err := cfg.PrimaryConnectionConfig.Prepare(); err != nil {
+			return nil, err
+		}
+	}
 	entry := log.NewEntry(logger).WithFields(log.Fields{
 		"job": subject,
 	})
@@ -93,11 +157,18 @@ func NewExtractor(subject, tp string, maxPayload int, cfg *config.MySQLDriverCon
 		tp:              tp,
 		maxPayload:      maxPayload,
 		mysqlContext:    cfg,
-		binlogChannel:   make(chan *binlog.BinlogTx, cfg.ReplChanBufferSize),
-		dataChannel:     make(chan *binlog.BinlogEntry, cfg.ReplChanBufferSize),
+		binlogChannel:   make(chan *binlog.BinlogTx, cfg.QueueSizes.BinlogEntries),
+		dataChannel:     make(chan *binlog.BinlogEntry, cfg.QueueSizes.BinlogEntries),
+		sendQueue:       make(chan *entriesMsg, cfg.QueueSizes.SendGroups),
 		rowCopyComplete: make(chan bool),
 		waitCh:          make(chan *models.WaitResult, 1),
 		shutdownCh:      make(chan struct{}),
+		drainCh:         make(chan chan struct{}, 1),
+		streamStopped:   make(chan struct{}),
+		bandwidth:       util.NewRateLimiter(0),
+		heartbeat:       &binlog.HeartbeatMonitor{},
+		groupSizer:      newGroupSizer(cfg.GroupMaxSize, cfg.AdaptiveGroupMaxSize, maxPayload),
+		tableWatermarks: make(map[string]string),
 		testStub1Delay:  0,
 	}
 
@@ -105,6 +176,11 @@ func NewExtractor(subject, tp string, maxPayload int, cfg *config.MySQLDriverCon
 		e.logger.Infof("%v = %v", g.ENV_TESTSTUB1_DELAY, delay)
 		e.testStub1Delay = delay
 	}
+	faults, err := newFaultInjector(cfg.FaultInjection, e.logger)
+	if err != nil {
+		return nil, err
+	}
+	e.faults = faults
 
 	return e, nil
 }
@@ -146,6 +222,7 @@ func (e *Extractor) retryOperation(operation func() error, notFatalHint ...bool)
 
 // Run executes the complete extract logic.
 func (e *Extractor) Run() {
+	defer models.RecoverPanic(e.onPanic)
 	e.logger.Printf("mysql.extractor: Extract binlog events from %s.%d", e.mysqlContext.ConnectionConfig.Host, e.mysqlContext.ConnectionConfig.Port)
 	e.mysqlContext.StartTime = time.Now()
 
@@ -156,8 +233,40 @@ func (e *Extractor) Run() {
 				fmt.Errorf("conflicting job argument: SkipCreateDbTable=true and DropTableIfExists=true"))
 			return
 		}
+		if err := e.validateCopyMode(); err != nil {
+			e.onError(TaskStateDead, err)
+			return
+		}
+		if err := e.validateCopyObjects(); err != nil {
+			e.onError(TaskStateDead, err)
+			return
+		}
+		if err := e.validateBulkLoad(); err != nil {
+			e.onError(TaskStateDead, err)
+			return
+		}
+		if err := e.validateCapacityCheck(); err != nil {
+			e.onError(TaskStateDead, err)
+			return
+		}
+		if err := e.validateBinlogServer(); err != nil {
+			e.onError(TaskStateDead, err)
+			return
+		}
+		if err := e.validateBinlogRelay(); err != nil {
+			e.onError(TaskStateDead, err)
+			return
+		}
+		if err := e.validateConsistencyCheck(); err != nil {
+			e.onError(TaskStateDead, err)
+			return
+		}
 	}
 
+	if err := e.selectSource(); err != nil {
+		e.onError(TaskStateDead, err)
+		return
+	}
 	if err := e.initiateInspector(); err != nil {
 		e.onError(TaskStateDead, err)
 		return
@@ -173,21 +282,23 @@ func (e *Extractor) Run() {
 
 	if e.mysqlContext.Gtid == "" {
 		if e.mysqlContext.AutoGtid {
-			coord, err := base.GetSelfBinlogCoordinates(e.db)
+			gtidSet, err := e.currentGtidSet()
 			if err != nil {
 				e.onError(TaskStateDead, err)
+				return
 			}
-			e.mysqlContext.Gtid = coord.GtidSet
-			e.logger.Debugf("mysql.extractor: use auto gtid: %v", coord.GtidSet)
+			e.mysqlContext.Gtid = gtidSet
+			e.logger.Debugf("mysql.extractor: use auto gtid: %v", gtidSet)
 		}
 
 		if e.mysqlContext.GtidStart != "" {
-			coord, err := base.GetSelfBinlogCoordinates(e.db)
+			gtidSet, err := e.currentGtidSet()
 			if err != nil {
 				e.onError(TaskStateDead, err)
+				return
 			}
 
-			e.mysqlContext.Gtid, err = base.GtidSetDiff(coord.GtidSet, e.mysqlContext.GtidStart)
+			e.mysqlContext.Gtid, err = base.GtidSetDiff(gtidSet, e.mysqlContext.GtidStart)
 			if err != nil {
 				e.onError(TaskStateDead, err)
 			}
@@ -195,12 +306,22 @@ func (e *Extractor) Run() {
 	}
 
 	if e.mysqlContext.Gtid == "" { // still empty: full copy
+		if err := e.checkTargetCapacity(); err != nil {
+			e.onError(TaskStateDead, err)
+			return
+		}
 		e.mysqlContext.MarkRowCopyStartTime()
-		if err := e.mysqlDump(); err != nil {
+		if e.mysqlContext.BulkLoad != nil {
+			if err := e.bulkLoad(); err != nil {
+				e.onError(TaskStateDead, err)
+				return
+			}
+		} else if err := e.mysqlDump(); err != nil {
 			e.onError(TaskStateDead, err)
 			return
 		}
-		dumpMsg, err := Encode(&dumpStatResult{Gtid: e.initialBinlogCoordinates.GtidSet, TotalCount: e.mysqlContext.RowsEstimate})
+		dumpMsg, err := Encode(&dumpStatResult{Gtid: e.initialBinlogCoordinates.GtidSet, TotalCount: e.mysqlContext.RowsEstimate,
+			TableRows: e.snapshotRowCounts})
 		if err != nil {
 			e.onError(TaskStateDead, err)
 		}
@@ -212,9 +333,12 @@ func (e *Extractor) Run() {
 			e.onError(TaskStateDead, err)
 			return
 		}
+		e.applySchemaHistory()
 	}
 
-	if e.mysqlContext.SkipIncrementalCopy {
+	if e.mysqlContext.CopyMode == config.CopyModeSchemaOnly {
+		e.logger.Infof("mysql.extractor. schema copied, CopyMode is %v", e.mysqlContext.CopyMode)
+	} else if e.mysqlContext.SkipIncrementalCopy {
 		e.logger.Infof("mysql.extractor. SkipIncrementalCopy")
 	} else {
 		if err := e.initBinlogReader(e.initialBinlogCoordinates); err != nil {
@@ -228,6 +352,11 @@ func (e *Extractor) Run() {
 			e.onError(TaskStateDead, err)
 			return
 		}
+
+		if err := e.startConsistencyCheck(); err != nil {
+			e.onError(TaskStateDead, err)
+			return
+		}
 	}
 }
 
@@ -380,7 +509,7 @@ func (e *Extractor) readTableColumns() (err error) {
 
 func (e *Extractor) initNatsPubClient() (err error) {
 	natsAddr := fmt.Sprintf("nats://%s", e.mysqlContext.NatsAddr)
-	sc, err := gonats.Connect(natsAddr)
+	sc, err := natsConnect(natsAddr, e.mysqlContext)
 	if err != nil {
 		e.logger.Errorf("mysql.extractor: Can't connect nats server %v. make sure a nats streaming server is running.%v", natsAddr, err)
 		return err
@@ -393,7 +522,12 @@ func (e *Extractor) initNatsPubClient() (err error) {
 
 // initiateStreaming begins treaming of binary log events and registers listeners for such events
 func (e *Extractor) initiateStreaming() error {
+	if err := e.initHeartbeatTable(); err != nil {
+		return err
+	}
+
 	go func() {
+		defer models.RecoverPanic(e.onPanic)
 		e.logger.Printf("mysql.extractor: Beginning streaming")
 		err := e.StreamEvents()
 		if err != nil {
@@ -402,6 +536,7 @@ func (e *Extractor) initiateStreaming() error {
 	}()
 
 	go func() {
+		defer models.RecoverPanic(e.onPanic)
 		_, err := e.natsConn.Subscribe(fmt.Sprintf("%s_restart", e.subject), func(m *gonats.Msg) {
 			e.mysqlContext.Gtid = string(m.Data)
 			e.onError(TaskStateRestart, fmt.Errorf("restart"))
@@ -421,6 +556,60 @@ func (e *Extractor) initiateStreaming() error {
 	return nil
 }
 
+// heartbeatTableName returns the escaped name of the heartbeat table
+func (e *Extractor) heartbeatTableName() (string, error) {
+	parts := strings.SplitN(e.mysqlContext.HeartbeatTable, ".", 2)
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return "", fmt.Errorf("HeartbeatTable %q is not of the form schema.table", e.mysqlContext.HeartbeatTable)
+	}
+	return fmt.Sprintf("%s.%s", sql.EscapeName(parts[0]), sql.EscapeName(parts[1])), nil
+}
+
+// initHeartbeatTable creates the heartbeat table on the source if needed
+func (e *Extractor) initHeartbeatTable() error {
+	if e.mysqlContext.HeartbeatInterval <= 0 || e.mysqlContext.HeartbeatTable == "" {
+		return nil
+	}
+	table, err := e.heartbeatTableName()
+	if err != nil {
+		return err
+	}
+	query := fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (id INT NOT NULL PRIMARY KEY, ts DATETIME(6) NOT NULL)", table)
+	if _, err := e.db.Exec(query); err != nil {
+		return fmt.Errorf("failed to create heartbeat table %s: %v", table, err)
+	}
+	return nil
+}
+
+// writeHeartbeatTable updates the heartbeat table on the source. A failed
+// write is only logged, as the heartbeats still go to the receivers.
+func (e *Extractor) writeHeartbeatTable() {
+	if e.mysqlContext.HeartbeatTable == "" {
+		return
+	}
+	table, err := e.heartbeatTableName()
+	if err != nil {
+		return
+	}
+	query := fmt.Sprintf("INSERT INTO %s (id, ts) VALUES (1, NOW(6)) ON DUPLICATE KEY UPDATE ts = VALUES(ts)", table)
+	if _, err := e.db.Exec(query); err != nil {
+		e.logger.Warnf("mysql.extractor: failed to write heartbeat table %s: %v", table, err)
+	}
+}
+
+// newHeartbeat returns a heartbeat describing the binlog reader
+func (e *Extractor) newHeartbeat() *binlog.Heartbeat {
+	coordinates := e.binlogReader.GetCurrentBinlogCoordinates()
+	h := &binlog.Heartbeat{
+		Time:     time.Now().UnixNano(),
+		ReadTime: e.binlogReader.LastEventTime(),
+	}
+	if coordinates.GNO != 0 {
+		h.Gtid = coordinates.GetGtidForThisTx()
+	}
+	return h
+}
+
 //--EventsStreamer--
 func (e *Extractor) initDBConnections() (err error) {
 	eventsStreamerUri := e.mysqlContext.ConnectionConfig.GetDBUri()
@@ -428,13 +617,19 @@ func (e *Extractor) initDBConnections() (err error) {
 		return err
 	}
 	//https://github.com/go-sql-driver/mysql#system-variables
-	dumpUri := fmt.Sprintf("%s&tx_isolation='REPEATABLE-READ'", e.mysqlContext.ConnectionConfig.GetSingletonDBUri())
+	// TIMESTAMP values are read in UTC, as in the binlog, whatever the time
+	// zone of the source
+	dumpUri := fmt.Sprintf("%s&tx_isolation='REPEATABLE-READ'&time_zone=%s",
+		e.mysqlContext.ConnectionConfig.GetSingletonDBUri(), url.QueryEscape("'+00:00'"))
 	if e.singletonDB, err = sql.CreateDB(dumpUri); err != nil {
 		return err
 	}
 	if err := e.validateConnection(); err != nil {
 		return err
 	}
+	if err := e.initPrimaryConnection(); err != nil {
+		return err
+	}
 	if err := e.validateAndReadTimeZone(); err != nil {
 		return err
 	}
@@ -455,6 +650,28 @@ func (e *Extractor) initBinlogReader(binlogCoordinates *base.BinlogCoordinatesX)
 		e.logger.Debugf("mysql.extractor: err at initBinlogReader: NewMySQLReader: %v", err.Error())
 		return err
 	}
+	if err := binlogReader.SetTableWatermarks(e.tableWatermarks); err != nil {
+		return err
+	}
+	if e.mysqlContext.BinlogServer != nil {
+		if err := e.startBinlogServer(binlogCoordinates.GtidSet); err != nil {
+			return err
+		}
+		binlogReader.SetRawEventWriter(e.binlogStore)
+	}
+	if relay := e.mysqlContext.BinlogRelay; relay != nil {
+		if relay.Job != "" {
+			err = e.attachBinlogRelay(binlogCoordinates.GtidSet)
+		} else {
+			err = e.startBinlogRelay(binlogCoordinates.GtidSet)
+		}
+		if err != nil {
+			return err
+		}
+		if err := e.readFromRelay(binlogReader); err != nil {
+			return err
+		}
+	}
 	if err := binlogReader.ConnectBinlogStreamer(*binlogCoordinates); err != nil {
 		e.logger.Debugf("mysql.extractor: err at initBinlogReader: ConnectBinlogStreamer: %v", err.Error())
 		return err
@@ -484,6 +701,9 @@ func (e *Extractor) selectSqlMode() error {
 // readCurrentBinlogCoordinates reads master status from hooked server
 func (e *Extractor) readCurrentBinlogCoordinates() error {
 	if e.mysqlContext.Gtid != "" {
+		if err := e.waitForReplica(e.mysqlContext.Gtid); err != nil {
+			return err
+		}
 		gtidSet, err := gomysql.ParseMysqlGTIDSet(e.mysqlContext.Gtid)
 		if err != nil {
 			return err
@@ -614,31 +834,48 @@ func Encode(v interface{}) ([]byte, error) {
 // StreamEvents will begin streaming events. It will be blocking, so should be
 // executed by a goroutine
 func (e *Extractor) StreamEvents() error {
+	defer close(e.streamStopped)
 	if e.mysqlContext.ApproveHeterogeneous {
 		go func() {
+			defer models.RecoverPanic(e.onPanic)
 			defer e.logger.Debugf("extractor. StreamEvents goroutine exited")
 
 			entries := binlog.BinlogEntries{}
 			entriesSize := 0
 
+			// The groups are sent by another goroutine, while the next ones
+			// are read and encoded
+			sendQueue := e.sendQueue
+			defer close(sendQueue)
+			go e.sendEntriesMsgs(sendQueue)
+
 			sendEntries := func() error {
 				var gno int64 = 0
 				if len(entries.Entries) > 0 {
 					gno = entries.Entries[0].Coordinates.GNO
 				}
 
+				e.groupSizer.stampSourceDelays(entries.Entries)
 				txMsg, err := Encode(entries)
 				if err != nil {
 					return err
 				}
 
-				e.logger.Debugf("mysql.extractor: sending gno: %v, n: %v", gno, len(entries.Entries))
-				if err = e.publish(fmt.Sprintf("%s_incr_hete", e.subject), "", txMsg); err != nil {
-					return err
+				msg := &entriesMsg{
+					txMsg:     txMsg,
+					gno:       gno,
+					nEntries:  len(entries.Entries),
+					heartbeat: entries.Heartbeat,
 				}
-				e.logger.Debugf("mysql.extractor: send acked gno: %v, n: %v", gno, len(entries.Entries))
+				select {
+				case sendQueue <- msg:
+				case <-e.shutdownCh:
+					return nil
+				}
+				e.groupSizer.grouped(entriesSize)
 
 				entries.Entries = nil
+				entries.Heartbeat = nil
 				entriesSize = 0
 
 				return nil
@@ -647,9 +884,18 @@ func (e *Extractor) StreamEvents() error {
 			keepGoing := true
 
 			groupTimeoutDuration := time.Duration(e.mysqlContext.GroupTimeout) * time.Millisecond
-			timer := time.NewTimer(groupTimeoutDuration)
+			timer := time.NewTimer(e.groupSizer.Timeout(groupTimeoutDuration))
 			defer timer.Stop()
 
+			// The heartbeats go with the pending entries, so that they
+			// arrive in order with the transactions.
+			var heartbeatCh <-chan time.Time
+			if e.mysqlContext.HeartbeatInterval > 0 {
+				ticker := time.NewTicker(time.Duration(e.mysqlContext.HeartbeatInterval) * time.Millisecond)
+				defer ticker.Stop()
+				heartbeatCh = ticker.C
+			}
+
 			for keepGoing && !e.shutdown {
 				var err error
 				select {
@@ -657,13 +903,13 @@ func (e *Extractor) StreamEvents() error {
 					entries.Entries = append(entries.Entries, binlogEntry)
 					entriesSize += binlogEntry.OriginalSize
 
-					if entriesSize >= e.mysqlContext.GroupMaxSize {
+					if entriesSize >= e.groupSizer.Limit() {
 						e.logger.Debugf("extractor. incr. send by GroupLimit. entriesSize: %v", entriesSize)
 						err = sendEntries()
 						if !timer.Stop() {
 							<-timer.C
 						}
-						timer.Reset(groupTimeoutDuration)
+						timer.Reset(e.groupSizer.Timeout(groupTimeoutDuration))
 					}
 				case <-timer.C:
 					nEntries := len(entries.Entries)
@@ -671,7 +917,30 @@ func (e *Extractor) StreamEvents() error {
 						e.logger.Debugf("extractor. incr. send by timeout. entriesSize: %v", entriesSize)
 						err = sendEntries()
 					}
-					timer.Reset(groupTimeoutDuration)
+					timer.Reset(e.groupSizer.Timeout(groupTimeoutDuration))
+				case <-heartbeatCh:
+					e.writeHeartbeatTable()
+					entries.Heartbeat = e.newHeartbeat()
+					err = sendEntries()
+				case drained := <-e.drainCh:
+					// the binlog reader is stopped: send what it read
+					for err == nil && len(e.dataChannel) > 0 {
+						binlogEntry := <-e.dataChannel
+						entries.Entries = append(entries.Entries, binlogEntry)
+						entriesSize += binlogEntry.OriginalSize
+						if entriesSize >= e.groupSizer.Limit() {
+							err = sendEntries()
+						}
+					}
+					if err == nil && len(entries.Entries) > 0 {
+						err = sendEntries()
+					}
+					if err == nil {
+						select {
+						case sendQueue <- &entriesMsg{drained: drained}:
+						case <-e.shutdownCh:
+						}
+					}
 				}
 				if err != nil {
 					e.onError(TaskStateDead, err)
@@ -687,6 +956,7 @@ func (e *Extractor) StreamEvents() error {
 		subject := fmt.Sprintf("%s_incr_hete", e.subject)
 
 		go func() {
+			defer models.RecoverPanic(e.onPanic)
 		L:
 			for {
 				select {
@@ -745,11 +1015,21 @@ func (e *Extractor) StreamEvents() error {
 		}()*/
 		// endregion
 		// The next should block and execute forever, unless there's a serious error
-		if err := e.binlogReader.DataStreamEvents(e.dataChannel); err != nil {
-			if e.shutdown {
-				return nil
+		for {
+			err := e.binlogReader.DataStreamEvents(e.dataChannel)
+			if err == nil || e.shutdown || atomic.LoadInt32(&e.draining) == 1 {
+				break
+			}
+			if _, ok := err.(*binlog.ChecksumError); ok {
+				// Reading it again over a new connection tells a corrupted
+				// transfer from a corrupted binlog
+				e.logger.Errorf("mysql.extractor: %v. Reconnecting", err)
+			} else {
+				e.logger.Warnf("mysql.extractor: binlog streaming stopped, failing over: %v", err)
+			}
+			if ferr := e.failover(); ferr != nil {
+				return fmt.Errorf("mysql.extractor: StreamEvents encountered unexpected error: %+v, failover: %v", err, ferr)
 			}
-			return fmt.Errorf("mysql.extractor: StreamEvents encountered unexpected error: %+v", err)
 		}
 	} else {
 		// region homogeneous
@@ -758,7 +1038,23 @@ func (e *Extractor) StreamEvents() error {
 		txBytes := 0
 		subject := fmt.Sprintf("%s_incr", e.subject)
 
+		publishTxArray := func() error {
+			txMsg, err := Encode(&txArray)
+			if err != nil {
+				return err
+			}
+			if len(txMsg) > e.maxPayload {
+				e.onError(TaskStateDead, gonats.ErrMaxPayload)
+			}
+			return e.publish(subject,
+				fmt.Sprintf("%s:1-%d",
+					txArray[len(txArray)-1].SID,
+					txArray[len(txArray)-1].GNO),
+				txMsg)
+		}
+
 		go func() {
+			defer models.RecoverPanic(e.onPanic)
 		L:
 			for {
 				select {
@@ -770,15 +1066,7 @@ func (e *Extractor) StreamEvents() error {
 						txArray = append(txArray, binlogTx)
 						txBytes += len([]byte(binlogTx.Query))
 						if txBytes > e.mysqlContext.MsgBytesLimit {
-							txMsg, err := Encode(&txArray)
-							if err != nil {
-								e.onError(TaskStateDead, err)
-								break L
-							}
-							if len(txMsg) > e.maxPayload {
-								e.onError(TaskStateDead, gonats.ErrMaxPayload)
-							}
-							if err = e.publish(subject, fmt.Sprintf("%s:1-%d", binlogTx.SID, binlogTx.GNO), txMsg); err != nil {
+							if err := publishTxArray(); err != nil {
 								e.onError(TaskStateDead, err)
 								break L
 							}
@@ -791,19 +1079,7 @@ func (e *Extractor) StreamEvents() error {
 				case <-time.After(100 * time.Millisecond):
 					{
 						if len(txArray) != 0 {
-							txMsg, err := Encode(&txArray)
-							if err != nil {
-								e.onError(TaskStateDead, err)
-								break L
-							}
-							if len(txMsg) > e.maxPayload {
-								e.onError(TaskStateDead, gonats.ErrMaxPayload)
-							}
-							if err = e.publish(subject,
-								fmt.Sprintf("%s:1-%d",
-									txArray[len(txArray)-1].SID,
-									txArray[len(txArray)-1].GNO),
-								txMsg); err != nil {
+							if err := publishTxArray(); err != nil {
 								e.onError(TaskStateDead, err)
 								break L
 							}
@@ -813,6 +1089,22 @@ func (e *Extractor) StreamEvents() error {
 							txBytes = 0
 						}
 					}
+				case drained := <-e.drainCh:
+					// the binlog reader is stopped: send what it read
+					for len(e.binlogChannel) > 0 {
+						if binlogTx := <-e.binlogChannel; binlogTx != nil {
+							txArray = append(txArray, binlogTx)
+						}
+					}
+					if len(txArray) != 0 {
+						if err := publishTxArray(); err != nil {
+							e.onError(TaskStateDead, err)
+							break L
+						}
+						txArray = []*binlog.BinlogTx{}
+						txBytes = 0
+					}
+					close(drained)
 				case <-e.shutdownCh:
 					break L
 				}
@@ -820,7 +1112,7 @@ func (e *Extractor) StreamEvents() error {
 		}()
 		// The next should block and execute forever, unless there's a serious error
 		if err := e.binlogReader.BinlogStreamEvents(e.binlogChannel); err != nil {
-			if e.shutdown {
+			if e.shutdown || atomic.LoadInt32(&e.draining) == 1 {
 				return nil
 			}
 			return fmt.Errorf("mysql.extractor: StreamEvents encountered unexpected error: %+v", err)
@@ -834,13 +1126,21 @@ func (e *Extractor) StreamEvents() error {
 // retryOperation attempts up to `count` attempts at running given function,
 // exiting as soon as it returns with non-error.
 func (e *Extractor) publish(subject, gtid string, txMsg []byte) (err error) {
+	e.bandwidth.Wait(len(txMsg), e.shutdownCh)
 	for {
 		e.logger.Debugf("mysql.extractor: publish. gtid: %v, msg_len: %v", gtid, len(txMsg))
-		_, err = e.natsConn.Request(subject, txMsg, DefaultConnectWait)
+		if err = e.faults.Inject(config.FaultNatsDisconnect); err != nil {
+			e.logger.Warnf("mysql.extractor: closing the connection to nats: %v", err)
+			e.natsConn.Close()
+			return err
+		}
+		var reply *gonats.Msg
+		reply, err = e.natsConn.Request(subject, txMsg, DefaultConnectWait)
 		if err == nil {
 			if gtid != "" {
 				e.mysqlContext.Gtid = gtid
 			}
+			e.groupSizer.observeAck(reply.Data)
 			break
 		} else if err == gonats.ErrTimeout {
 			e.logger.Debugf("mysql.extractor: publish timeout, got %v", err)
@@ -849,13 +1149,19 @@ func (e *Extractor) publish(subject, gtid string, txMsg []byte) (err error) {
 			e.logger.Errorf("mysql.extractor: unexpected error on publish, got %v", err)
 			break
 		}
-		// there's an error. Let's try again.
-		e.logger.Debugf(fmt.Sprintf("mysql.extractor: there's an error [%v]. Let's try again", err))
-		time.Sleep(1 * time.Second)
 	}
 	return err
 }
 
+// SetBandwidthLimit limits the bytes published per second. A limit of 0
+// removes the limit.
+func (e *Extractor) SetBandwidthLimit(bytesPerSecond int64) {
+	if e.bandwidth.Rate() != bytesPerSecond {
+		e.logger.Printf("mysql.extractor: bandwidth limit set to %d bytes/s", bytesPerSecond)
+	}
+	e.bandwidth.SetRate(bytesPerSecond)
+}
+
 func (e *Extractor) testStub1() {
 	if e.testStub1Delay > 0 {
 		e.logger.Info("teststub1 delay start")
@@ -867,7 +1173,6 @@ func (e *Extractor) testStub1() {
 //Perform the snapshot using the same logic as the "mysqldump" utility.
 func (e *Extractor) mysqlDump() error {
 	defer e.singletonDB.Close()
-	var tx sql.QueryAble
 	var err error
 	step := 0
 	// ------
@@ -896,6 +1201,15 @@ func (e *Extractor) mysqlDump() error {
 	setSqlMode := fmt.Sprintf("SET @@session.sql_mode = '%s'", e.mysqlContext.SqlMode)
 	step++
 
+	if e.readsFromReplica() {
+		// Do not take the snapshot on a lagging replica: it would miss
+		// transactions the primary executed before the job started.
+		e.logger.Printf("mysql.extractor: Step %d: waiting for the replica to catch up with the primary", step)
+		if _, err := e.catchUpWithPrimary(); err != nil {
+			return err
+		}
+	}
+
 	// ------
 	// STEP ?
 	// ------
@@ -917,103 +1231,43 @@ func (e *Extractor) mysqlDump() error {
 	// First, start a transaction and request that a consistent MVCC snapshot is obtained immediately.
 	// See http://dev.mysql.com/doc/refman/5.7/en/commit.html
 
-	var needConsistentSnapshot = true // TODO determine by table characteristic (has-PK or not)
-	if needConsistentSnapshot {
-		e.logger.Printf("mysql.extractor: Step %d: start transaction with consistent snapshot", step)
-		gtidMatch := false
-		gtidMatchRound := 0
-		delayBetweenRetries := 200 * time.Millisecond
-		for !gtidMatch {
-			gtidMatchRound += 1
-
-			// 1
-			rows1, err := e.singletonDB.Query("show master status")
-			if err != nil {
-				e.logger.Errorf("mysql.extractor: get gtid, round: %v, phase 1, err: %v", gtidMatchRound, err)
-				return err
-			}
-
-			e.testStub1()
-
-			// 2
-			// TODO it seems that two 'start transaction' will be sent.
-			// https://github.com/golang/go/issues/19981
-			realTx, err := e.singletonDB.Begin()
-			tx = realTx
-			if err != nil {
-				return err
-			}
-			query := "START TRANSACTION WITH CONSISTENT SNAPSHOT"
-			_, err = realTx.Exec(query)
-			if err != nil {
-				e.logger.Printf("[ERR] mysql.extractor: exec %+v, error: %v", query, err)
-				return err
-			}
-
-			e.testStub1()
-
-			// 3
-			rows2, err := realTx.Query("show master status")
-
-			// 4
-			binlogCoordinates1, err := base.ParseBinlogCoordinatesFromRows(rows1)
-			if err != nil {
-				return err
-			}
-			binlogCoordinates2, err := base.ParseBinlogCoordinatesFromRows(rows2)
-			if err != nil {
-				return err
-			}
-			e.logger.Debugf("mysql.extractor: binlog coordinates 1: %+v", binlogCoordinates1)
-			e.logger.Debugf("mysql.extractor: binlog coordinates 2: %+v", binlogCoordinates2)
-
-			if binlogCoordinates1.GtidSet == binlogCoordinates2.GtidSet {
-				gtidMatch = true
-				e.logger.Infof("Got gtid after %v rounds", gtidMatchRound)
-
-				// Obtain the binlog position and update the SourceInfo in the context. This means that all source records generated
-				// as part of the snapshot will contain the binlog position of the snapshot.
-				//binlogCoordinates, err := base.GetSelfBinlogCoordinatesWithTx(tx)
-
-				e.initialBinlogCoordinates = binlogCoordinates2
-				e.logger.Printf("mysql.extractor: Step %d: read binlog coordinates of MySQL master: %+v", step, *e.initialBinlogCoordinates)
-
-				defer func() {
-					/*e.logger.Printf("mysql.extractor: Step %d: releasing global read lock to enable MySQL writes", step)
-					query := "UNLOCK TABLES"
-					_, err := tx.Exec(query)
-					if err != nil {
-						e.logger.Printf("[ERR] mysql.extractor: exec %+v, error: %v", query, err)
-					}
-					step++*/
-					e.logger.Printf("mysql.extractor: Step %d: committing transaction", step)
-					if err := realTx.Commit(); err != nil {
-						e.onError(TaskStateDead, err)
-					}
-				}()
-			} else {
-				e.logger.Warningf("Failed got a consistenct TX with GTID in %v rounds. Will retry.", gtidMatchRound)
-				err = realTx.Rollback()
-				if err != nil {
-					return err
-				}
-				time.Sleep(delayBetweenRetries)
-			}
-		}
+	if err := e.validateSnapshotMode(); err != nil {
+		return err
+	}
+	if e.mysqlContext.SnapshotMode == config.SnapshotModeLockTables {
+		e.logger.Printf("mysql.extractor: Step %d: lock the tables to copy for read", step)
 	} else {
-		e.logger.Debugf("mysql.extractor: no need to get consistent snapshot")
-		tx = e.singletonDB
-		rows1, err := tx.Query("show master status")
-		if err != nil {
-			return err
+		e.logger.Printf("mysql.extractor: Step %d: start transaction with consistent snapshot", step)
+	}
+	nConns, err := e.dumpConnections()
+	if err != nil {
+		return err
+	}
+	snapshotTxs, binlogCoordinates, err := e.startSnapshot(nConns)
+	if err != nil {
+		return err
+	}
+	// Obtain the binlog position and update the SourceInfo in the context. This means that all source records generated
+	// as part of the snapshot will contain the binlog position of the snapshot.
+	e.initialBinlogCoordinates = binlogCoordinates
+	e.logger.Printf("mysql.extractor: Step %d: read binlog coordinates of MySQL master: %+v", step, *e.initialBinlogCoordinates)
+	defer func() {
+		e.logger.Printf("mysql.extractor: Step %d: committing transaction", step)
+		if err := e.endSnapshot(snapshotTxs); err != nil {
+			e.onError(TaskStateDead, err)
 		}
-		e.initialBinlogCoordinates, err = base.ParseBinlogCoordinatesFromRows(rows1)
-		if err != nil {
+	}()
+	step++
+
+	switch e.mysqlContext.SnapshotOrder {
+	case "":
+	case config.SnapshotOrderForeignKey:
+		if err := e.orderByForeignKeys(); err != nil {
 			return err
 		}
-		e.logger.Debugf("mysql.extractor: got gtid")
+	default:
+		return fmt.Errorf("unknown SnapshotOrder %q: must be %q or empty", e.mysqlContext.SnapshotOrder, config.SnapshotOrderForeignKey)
 	}
-	step++
 
 	// ------
 	// STEP 4
@@ -1025,34 +1279,37 @@ func (e *Extractor) mysqlDump() error {
 
 	// Transform the current schema so that it reflects the *current* state of the MySQL server's contents.
 	// First, get the DROP TABLE and CREATE TABLE statement (with keys and constraint definitions) for our tables ...
-	if !e.mysqlContext.SkipCreateDbTable {
+	if e.copiesSchema() {
 		e.logger.Printf("mysql.extractor: Step %d: - generating DROP and CREATE statements to reflect current database schemas:%v", step, e.replicateDoDb)
 	}
+	var views []*config.Table
 	for _, db := range e.replicateDoDb {
 		if len(db.Tables) > 0 {
 			for _, tb := range db.Tables {
 				if tb.TableSchema != db.TableSchema {
 					continue
 				}
-				total, err := e.CountTableRows(tb)
-				if err != nil {
-					return err
+				if isView(tb) {
+					// Views are created after the tables they may select from
+					views = append(views, tb)
+					continue
+				}
+				if e.copiesData() {
+					total, err := e.CountTableRows(tb)
+					if err != nil {
+						return err
+					}
+					tb.Counter = total
 				}
-				tb.Counter = total
 				var dbSQL string
 				var tbSQL []string
-				if !e.mysqlContext.SkipCreateDbTable {
+				if e.copiesSchema() {
 					var err error
 					if strings.ToLower(tb.TableSchema) != "mysql" {
 						dbSQL = fmt.Sprintf("CREATE DATABASE IF NOT EXISTS %s", tb.TableSchema)
 					}
 
-					if strings.ToLower(tb.TableType) == "view" {
-						/*tbSQL, err = base.ShowCreateView(e.singletonDB, tb.TableSchema, tb.TableName, e.mysqlContext.DropTableIfExists)
-						if err != nil {
-							return err
-						}*/
-					} else if strings.ToLower(tb.TableSchema) != "mysql" {
+					if strings.ToLower(tb.TableSchema) != "mysql" {
 						tbSQL, err = base.ShowCreateTable(e.singletonDB, tb.TableSchema, tb.TableName, e.mysqlContext.DropTableIfExists)
 						if err != nil {
 							return err
@@ -1076,7 +1333,7 @@ func (e *Extractor) mysqlDump() error {
 			e.tableCount += len(db.Tables)
 		} else {
 			var dbSQL string
-			if !e.mysqlContext.SkipCreateDbTable {
+			if e.copiesSchema() {
 				if strings.ToLower(db.TableSchema) != "mysql" {
 					dbSQL = fmt.Sprintf("CREATE DATABASE IF NOT EXISTS %s", db.TableSchema)
 				}
@@ -1095,53 +1352,46 @@ func (e *Extractor) mysqlDump() error {
 			}
 		}
 	}
+	if e.copiesSchema() {
+		objects, err := e.schemaObjects(views)
+		if err != nil {
+			return err
+		}
+		for _, object := range objects {
+			entry := &DumpEntry{
+				SystemVariablesStatement: setSystemVariablesStatement,
+				SqlMode:                  setSqlMode,
+				DbSQL:                    fmt.Sprintf("CREATE DATABASE IF NOT EXISTS %s", object.schema),
+				TbSQL:                    object.tbSQL,
+				TotalCount:               1,
+				RowsCount:                1,
+			}
+			if object.sqlMode != "" {
+				entry.SqlMode = fmt.Sprintf("SET @@session.sql_mode = '%s'", object.sqlMode)
+			}
+			atomic.AddInt64(&e.mysqlContext.RowsEstimate, 1)
+			atomic.AddInt64(&e.mysqlContext.TotalRowsCopied, 1)
+			if err := e.encodeDumpEntry(entry); err != nil {
+				e.onError(TaskStateRestart, err)
+			}
+		}
+	}
 	step++
 
+	if !e.copiesData() {
+		e.logger.Printf("mysql.extractor: Step %d: skip copying the rows of the tables: CopyMode is %q", step, e.mysqlContext.CopyMode)
+		return nil
+	}
+
 	// ------
 	// STEP 5
 	// ------
 	// Dump all of the tables and generate source records ...
-	e.logger.Printf("mysql.extractor: Step %d: scanning contents of %d tables", step, e.tableCount)
+	e.logger.Printf("mysql.extractor: Step %d: scanning contents of %d tables with %d connections", step, e.tableCount, len(snapshotTxs))
 	startScan := utils.CurrentTimeMillis()
-	counter := 0
-	//pool := models.NewPool(10)
-	for _, db := range e.replicateDoDb {
-		for _, t := range db.Tables {
-			//pool.Add(1)
-			//go func(t *config.Table) {
-			counter++
-			// Obtain a record maker for this table, which knows about the schema ...
-			// Choose how we create statements based on the # of rows ...
-			e.logger.Printf("mysql.extractor: Step %d: - scanning table '%s.%s' (%d of %d tables)", step, t.TableSchema, t.TableName, counter, e.tableCount)
-
-			d := NewDumper(tx, t, e.mysqlContext.ChunkSize, e.logger)
-			if err := d.Dump(); err != nil {
-				e.onError(TaskStateDead, err)
-			}
-			e.dumpers = append(e.dumpers, d)
-			// Scan the rows in the table ...
-			for entry := range d.resultsChannel {
-				if entry.err != nil {
-					e.onError(TaskStateDead, entry.err)
-				} else {
-					entry.SystemVariablesStatement = setSystemVariablesStatement
-					entry.SqlMode = setSqlMode
-
-					if e.needToSendTabelDef() {
-						entry.Table = d.table
-					}
-					if err = e.encodeDumpEntry(entry); err != nil {
-						e.onError(TaskStateRestart, err)
-					}
-					atomic.AddInt64(&e.mysqlContext.TotalRowsCopied, entry.RowsCount)
-				}
-			}
-
-			//pool.Done()
-			//}(tb)
-		}
+	if err := e.dumpTables(snapshotTxs, setSystemVariablesStatement, setSqlMode, step); err != nil {
+		return err
 	}
-	//pool.Wait()
 	step++
 
 	// We've copied all of the tables, but our buffer holds onto the very last record.
@@ -1212,8 +1462,12 @@ func (e *Extractor) Stats() (*models.TaskStatistics, error) {
 			ExtractorTxQueueSize: len(e.binlogChannel),
 			SendByTimeout:        e.sendByTimeoutCounter,
 			SendBySizeFull:       e.sendBySizeFullCounter,
+			GroupSize:            e.groupSizer.Limit(),
+			Queues:               e.queueStats(),
 		},
-		Timestamp: time.Now().UTC().UnixNano(),
+		Heartbeat:   e.heartbeat.Stat(),
+		Consistency: e.consistencyCheck.stats(),
+		Timestamp:   time.Now().UTC().UnixNano(),
 	}
 	if e.natsConn != nil {
 		taskResUsage.MsgStat = e.natsConn.Statistics
@@ -1222,10 +1476,12 @@ func (e *Extractor) Stats() (*models.TaskStatistics, error) {
 			e.onError(TaskStateDead, fmt.Errorf("traffic limit exceeded : %d/%d", e.mysqlContext.TrafficAgainstLimits, int(taskResUsage.MsgStat.OutBytes)/1024/1024/1024))
 		}
 	}
+	taskResUsage.NetworkStat = networkStat(e.mysqlContext.ConnectionConfig.Traffic(), taskResUsage.MsgStat)
 
 	currentBinlogCoordinates := &base.BinlogCoordinateTx{}
 	if e.binlogReader != nil {
 		currentBinlogCoordinates = e.binlogReader.GetCurrentBinlogCoordinates()
+		taskResUsage.TxControl = e.binlogReader.TxControlStat()
 		taskResUsage.CurrentCoordinates = &models.CurrentCoordinates{
 			File:     currentBinlogCoordinates.LogFile,
 			Position: currentBinlogCoordinates.LogPos,
@@ -1270,10 +1526,55 @@ func (e *Extractor) onError(state int, err error) {
 	e.Shutdown()
 }
 
+func (e *Extractor) onPanic(err *models.PanicError) {
+	e.onError(TaskStateRestart, err)
+}
+
 func (e *Extractor) WaitCh() chan *models.WaitResult {
 	return e.waitCh
 }
 
+// Drain stops reading the binlog of the source, so that no new event is
+// read, and waits up to timeout for the events read to be sent to the
+// applier.
+func (e *Extractor) Drain(timeout time.Duration) error {
+	if e.binlogReader == nil {
+		// the binlog is not read yet
+		return nil
+	}
+	atomic.StoreInt32(&e.draining, 1)
+	if err := e.binlogReader.Close(); err != nil {
+		e.logger.Warnf("mysql.extractor: failed to close the binlog reader: %v", err)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		select {
+		case <-e.streamStopped:
+		case <-e.shutdownCh:
+			return
+		}
+		drained := make(chan struct{})
+		select {
+		case e.drainCh <- drained:
+		case <-e.shutdownCh:
+			return
+		}
+		select {
+		case <-drained:
+		case <-e.shutdownCh:
+		}
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-time.After(timeout):
+		return fmt.Errorf("mysql.extractor: drain timed out after %v", timeout)
+	}
+}
+
 // Shutdown is used to tear down the extractor
 func (e *Extractor) Shutdown() error {
 	e.shutdownLock.Lock()
@@ -1289,9 +1590,11 @@ func (e *Extractor) Shutdown() error {
 		e.natsConn.Close()
 	}
 
+	e.dumpersMu.Lock()
 	for _, d := range e.dumpers {
 		d.Close()
 	}
+	e.dumpersMu.Unlock()
 
 	if err := sql.CloseDB(e.singletonDB); err != nil {
 		return err
@@ -1302,10 +1605,26 @@ func (e *Extractor) Shutdown() error {
 			return err
 		}
 	}
+	if err := e.stopBinlogServer(); err != nil {
+		return err
+	}
+	if err := e.stopBinlogRelay(); err != nil {
+		return err
+	}
+	if e.consistencyCheck != nil {
+		if err := sql.CloseDB(e.consistencyCheck.target); err != nil {
+			return err
+		}
+	}
 
 	if err := sql.CloseDB(e.db); err != nil {
 		return err
 	}
+	if e.primaryDB != nil {
+		if err := sql.CloseDB(e.primaryDB); err != nil {
+			return err
+		}
+	}
 
 	//close(e.binlogChannel)
 	e.logger.Printf("mysql.extractor: Shutting down")
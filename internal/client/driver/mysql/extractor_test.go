@@ -7,8 +7,11 @@
 package mysql
 
 import (
+	"os"
 	"reflect"
 	"testing"
+	"time"
+
 	"github.com/actiontech/dtle/internal/client/driver/mysql/base"
 	"github.com/actiontech/dtle/internal/config"
 	log "github.com/actiontech/dtle/internal/logger"
@@ -17,10 +20,13 @@ import (
 
 func TestGtidSetDiff(t *testing.T) {
 	// TODO
-	g, err := GtidSetDiff(
+	_, err := base.GtidSetDiff(
 		"113fa2ce-c8e6-11e7-b894-67ad30e6f107:1-100:200:300-400,f2a4aa16-c8e6-11e7-9ff0-e19f7778f563:100-200:300-400,8888aa16-c8e6-11e7-9ff0-e19f7778f563:1-1000",
 		"113fa2ce-c8e6-11e7-b894-67ad30e6f107:330,f2a4aa16-c8e6-11e7-9ff0-e19f7778f563:301",
 	)
+	if err != nil {
+		t.Fatal(err)
+	}
 }
 
 func TestNewExtractor(t *testing.T) {
@@ -32,15 +38,21 @@ func TestNewExtractor(t *testing.T) {
 		logger     *log.Logger
 	}
 	tests := []struct {
-		name string
-		args args
-		want *Extractor
+		name    string
+		args    args
+		want    *Extractor
+		wantErr bool
 	}{
 		// TODO: Add test cases.
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			if got := NewExtractor(tt.args.subject, tt.args.tp, tt.args.maxPayload, tt.args.cfg, tt.args.logger); !reflect.DeepEqual(got, tt.want) {
+			got, err := NewExtractor(tt.args.subject, tt.args.tp, tt.args.maxPayload, tt.args.cfg, tt.args.logger)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("NewExtractor() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
 				t.Errorf("NewExtractor() = %v, want %v", got, tt.want)
 			}
 		})
@@ -174,7 +186,7 @@ func TestExtractor_initDBConnections(t *testing.T) {
 
 func TestExtractor_initBinlogReader(t *testing.T) {
 	type args struct {
-		binlogCoordinates *base.BinlogCoordinateTx
+		binlogCoordinates *base.BinlogCoordinatesX
 	}
 	tests := []struct {
 		name    string
@@ -324,29 +336,6 @@ func TestExtractor_StreamEvents(t *testing.T) {
 	}
 }
 
-func TestExtractor_requestMsg(t *testing.T) {
-	type args struct {
-		subject string
-		gtid    string
-		txMsg   []byte
-	}
-	tests := []struct {
-		name    string
-		e       *Extractor
-		args    args
-		wantErr bool
-	}{
-		// TODO: Add test cases.
-	}
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			if err := tt.e.requestMsg(tt.args.subject, tt.args.gtid, tt.args.txMsg); (err != nil) != tt.wantErr {
-				t.Errorf("Extractor.requestMsg() error = %v, wantErr %v", err, tt.wantErr)
-			}
-		})
-	}
-}
-
 func TestExtractor_mysqlDump(t *testing.T) {
 	tests := []struct {
 		name    string
@@ -385,22 +374,6 @@ func TestExtractor_encodeDumpEntry(t *testing.T) {
 	}
 }
 
-func Test_currentTimeMillis(t *testing.T) {
-	tests := []struct {
-		name string
-		want int64
-	}{
-		// TODO: Add test cases.
-	}
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			if got := currentTimeMillis(); got != tt.want {
-				t.Errorf("currentTimeMillis() = %v, want %v", got, tt.want)
-			}
-		})
-	}
-}
-
 func TestExtractor_Stats(t *testing.T) {
 	tests := []struct {
 		name    string
@@ -443,7 +416,8 @@ func TestExtractor_ID(t *testing.T) {
 
 func TestExtractor_onError(t *testing.T) {
 	type args struct {
-		err error
+		state int
+		err   error
 	}
 	tests := []struct {
 		name string
@@ -454,7 +428,7 @@ func TestExtractor_onError(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			tt.e.onError(tt.args.err)
+			tt.e.onError(tt.args.state, tt.args.err)
 		})
 	}
 }
@@ -563,8 +537,7 @@ func TestExtractor_validateAndReadTimeZone(t *testing.T) {
 
 func TestExtractor_CountTableRows(t *testing.T) {
 	type args struct {
-		tableSchema string
-		tableName   string
+		table *config.Table
 	}
 	tests := []struct {
 		name    string
@@ -577,7 +550,7 @@ func TestExtractor_CountTableRows(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got, err := tt.e.CountTableRows(tt.args.tableSchema, tt.args.tableName)
+			got, err := tt.e.CountTableRows(tt.args.table)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("Extractor.CountTableRows() error = %v, wantErr %v", err, tt.wantErr)
 				return
@@ -588,3 +561,26 @@ func TestExtractor_CountTableRows(t *testing.T) {
 		})
 	}
 }
+
+func TestExtractor_Drain(t *testing.T) {
+	e := &Extractor{
+		logger:     log.NewEntry(log.New(os.Stdout, log.DebugLevel)),
+		shutdownCh: make(chan struct{}),
+	}
+	// nothing is read before the binlog reader is started
+	if err := e.Drain(time.Second); err != nil {
+		t.Fatal(err)
+	}
+
+	// the groups queued before the drain are sent first
+	queue := make(chan *entriesMsg, 1)
+	drained := make(chan struct{})
+	queue <- &entriesMsg{drained: drained}
+	go e.sendEntriesMsgs(queue)
+	select {
+	case <-drained:
+	case <-time.After(time.Second):
+		t.Errorf("sendEntriesMsgs did not close the drained channel")
+	}
+	close(queue)
+}
@@ -0,0 +1,188 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package mysql
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"time"
+
+	gomysql "github.com/siddontang/go-mysql/mysql"
+
+	"github.com/actiontech/dtle/internal/client/driver/mysql/base"
+	"github.com/actiontech/dtle/internal/client/driver/mysql/binlog"
+	"github.com/actiontech/dtle/internal/client/driver/mysql/sql"
+	umconf "github.com/actiontech/dtle/internal/config/mysql"
+)
+
+// sourceCandidates returns the connection configs of the source followed by
+// those of its failover hosts
+func (e *Extractor) sourceCandidates() ([]*umconf.ConnectionConfig, error) {
+	source := e.mysqlContext.ConnectionConfig
+	candidates := []*umconf.ConnectionConfig{source}
+	for _, hostPort := range e.mysqlContext.FailoverHosts {
+		candidate := *source
+		host, port, err := net.SplitHostPort(hostPort)
+		if err != nil {
+			// no port: same port as the source
+			host = hostPort
+		} else if candidate.Port, err = strconv.Atoi(port); err != nil {
+			return nil, fmt.Errorf("invalid port in failover host %q", hostPort)
+		}
+		candidate.Host = host
+		if candidate.Host == source.Host && candidate.Port == source.Port {
+			continue
+		}
+		if err := candidate.Prepare(); err != nil {
+			return nil, err
+		}
+		candidates = append(candidates, &candidate)
+	}
+	return candidates, nil
+}
+
+// checkSourceCandidate checks that replication can resume on a candidate
+// source from the given GTID set: the candidate must have executed all of
+// those transactions, and must not have purged the binlogs of the others.
+func (e *Extractor) checkSourceCandidate(candidate *umconf.ConnectionConfig, gtidSet string) error {
+	db, err := sql.CreateDB(candidate.GetDBUri())
+	if err != nil {
+		return err
+	}
+	defer sql.CloseDB(db)
+
+	if gtidSet == "" {
+		return db.Ping()
+	}
+	resume, err := gomysql.ParseMysqlGTIDSet(gtidSet)
+	if err != nil {
+		return err
+	}
+
+	var executedStr, purgedStr string
+	if err := db.QueryRow("SELECT @@global.gtid_executed, @@global.gtid_purged").Scan(&executedStr, &purgedStr); err != nil {
+		return err
+	}
+	executed, err := gomysql.ParseMysqlGTIDSet(executedStr)
+	if err != nil {
+		return err
+	}
+	if !executed.Contain(resume) {
+		return fmt.Errorf("executed GTID set %v lacks transactions already replicated (%v)", executedStr, gtidSet)
+	}
+	purged, err := gomysql.ParseMysqlGTIDSet(purgedStr)
+	if err != nil {
+		return err
+	}
+	if !resume.Contain(purged) {
+		return fmt.Errorf("purged GTID set %v holds transactions not replicated yet (%v)", purgedStr, gtidSet)
+	}
+	return nil
+}
+
+// selectSource picks the first reachable candidate source able to resume
+// from the GTID set of the job, so that a job restarted after a failover
+// starts on the new primary.
+func (e *Extractor) selectSource() error {
+	if len(e.mysqlContext.FailoverHosts) == 0 {
+		return nil
+	}
+	candidates, err := e.sourceCandidates()
+	if err != nil {
+		return err
+	}
+	var errs []error
+	for _, candidate := range candidates {
+		err := e.checkSourceCandidate(candidate, e.mysqlContext.Gtid)
+		if err == nil {
+			e.useSource(candidate)
+			return nil
+		}
+		e.logger.Warnf("mysql.extractor: source %s:%d can not be used: %v", candidate.Host, candidate.Port, err)
+		errs = append(errs, err)
+	}
+	return fmt.Errorf("no usable source among %d candidates: %v", len(candidates), errs)
+}
+
+// useSource switches the extractor to a source
+func (e *Extractor) useSource(candidate *umconf.ConnectionConfig) {
+	if candidate == e.mysqlContext.ConnectionConfig {
+		return
+	}
+	e.logger.Printf("mysql.extractor: switching source from %s:%d to %s:%d",
+		e.mysqlContext.ConnectionConfig.Host, e.mysqlContext.ConnectionConfig.Port, candidate.Host, candidate.Port)
+	e.mysqlContext.ConnectionConfig = candidate
+}
+
+// failover reconnects the binlog reader after the source was lost, to the
+// source itself or to one of its failover hosts, resuming right after the
+// last transaction read.
+func (e *Extractor) failover() error {
+	gtidSet := e.binlogReader.CommittedGtidSet()
+	if e.relayFrom != nil {
+		// The relay fails over itself, restarting the task
+		return e.reconnectRelay(gtidSet)
+	}
+	candidates, err := e.sourceCandidates()
+	if err != nil {
+		return err
+	}
+
+	for i := int64(0); i < e.mysqlContext.MaxRetries; i++ {
+		for _, candidate := range candidates {
+			if e.shutdown {
+				return fmt.Errorf("shutdown during failover")
+			}
+			if err = e.reconnectSource(candidate, gtidSet); err == nil {
+				return nil
+			}
+			e.logger.Warnf("mysql.extractor: failover to %s:%d failed: %v", candidate.Host, candidate.Port, err)
+		}
+		select {
+		case <-time.After(ReconnectStreamerSleepSeconds * time.Second):
+		case <-e.shutdownCh:
+		}
+	}
+	return fmt.Errorf("no source to resume from %v after %d attempts, last error: %v", gtidSet, e.mysqlContext.MaxRetries, err)
+}
+
+// reconnectSource replaces the binlog reader with one reading from the
+// candidate source
+func (e *Extractor) reconnectSource(candidate *umconf.ConnectionConfig, gtidSet string) error {
+	if err := e.checkSourceCandidate(candidate, gtidSet); err != nil {
+		return err
+	}
+	db, err := sql.CreateDB(candidate.GetDBUri())
+	if err != nil {
+		return err
+	}
+
+	previous := e.mysqlContext.ConnectionConfig
+	e.useSource(candidate)
+	reader, err := binlog.NewMySQLReader(e.mysqlContext, e.logger, e.replicateDoDb)
+	if err == nil {
+		if e.binlogStore != nil {
+			reader.SetRawEventWriter(e.binlogStore)
+		}
+		err = reader.ConnectBinlogStreamer(base.BinlogCoordinatesX{GtidSet: gtidSet})
+	}
+	if err != nil {
+		e.mysqlContext.ConnectionConfig = previous
+		sql.CloseDB(db)
+		return err
+	}
+
+	if err := e.binlogReader.Close(); err != nil {
+		e.logger.Warnf("mysql.extractor: closing the lost binlog reader: %v", err)
+	}
+	sql.CloseDB(e.db)
+	e.db = db
+	e.binlogReader = reader
+	e.logger.Printf("mysql.extractor: resumed binlog streaming on %s:%d from %v", candidate.Host, candidate.Port, gtidSet)
+	return nil
+}
@@ -0,0 +1,148 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package mysql
+
+import (
+	"context"
+	gosql "database/sql"
+	"database/sql/driver"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+)
+
+// fakeDB is a database of the tests, recording the statements executed on
+// it and answering the queries with canned rows
+type fakeDB struct {
+	mu sync.Mutex
+	// execs are the statements executed, with their args
+	execs []fakeExec
+	// prepares are the queries prepared
+	prepares []string
+	// rows answer the queries containing their key
+	rows map[string]*fakeRows
+}
+
+type fakeExec struct {
+	query string
+	args  []driver.Value
+}
+
+type fakeRows struct {
+	columns []string
+	values  [][]driver.Value
+	next    int
+}
+
+func newFakeDB() *fakeDB {
+	return &fakeDB{rows: make(map[string]*fakeRows)}
+}
+
+// open returns a handle of the database
+func (f *fakeDB) open() *gosql.DB {
+	return gosql.OpenDB(f)
+}
+
+// answer makes the queries containing key return the rows of values
+func (f *fakeDB) answer(key string, columns []string, values ...[]driver.Value) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.rows[key] = &fakeRows{columns: columns, values: values}
+}
+
+// executed returns the statements executed so far
+func (f *fakeDB) executed() []fakeExec {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]fakeExec(nil), f.execs...)
+}
+
+func (f *fakeDB) Connect(context.Context) (driver.Conn, error) {
+	return &fakeConn{db: f}, nil
+}
+
+func (f *fakeDB) Driver() driver.Driver {
+	return nil
+}
+
+type fakeConn struct {
+	db *fakeDB
+}
+
+func (c *fakeConn) Prepare(query string) (driver.Stmt, error) {
+	c.db.mu.Lock()
+	defer c.db.mu.Unlock()
+	c.db.prepares = append(c.db.prepares, query)
+	return &fakeStmt{db: c.db, query: query}, nil
+}
+
+func (c *fakeConn) Close() error {
+	return nil
+}
+
+func (c *fakeConn) Begin() (driver.Tx, error) {
+	return c, nil
+}
+
+func (c *fakeConn) Commit() error {
+	return nil
+}
+
+func (c *fakeConn) Rollback() error {
+	return nil
+}
+
+// fakeStmt takes as many args as its query has placeholders, as MySQL
+// does
+type fakeStmt struct {
+	db    *fakeDB
+	query string
+}
+
+func (s *fakeStmt) Close() error {
+	return nil
+}
+
+func (s *fakeStmt) NumInput() int {
+	return strings.Count(s.query, "?")
+}
+
+func (s *fakeStmt) Exec(args []driver.Value) (driver.Result, error) {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+	s.db.execs = append(s.db.execs, fakeExec{query: s.query, args: args})
+	return driver.RowsAffected(1), nil
+}
+
+func (s *fakeStmt) Query(args []driver.Value) (driver.Rows, error) {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+	for key, rows := range s.db.rows {
+		if strings.Contains(s.query, key) {
+			return &fakeRows{columns: rows.columns, values: rows.values}, nil
+		}
+	}
+	return nil, fmt.Errorf("fakeDB: unexpected query %s", s.query)
+}
+
+func (r *fakeRows) Columns() []string {
+	return r.columns
+}
+
+func (r *fakeRows) Close() error {
+	return nil
+}
+
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if r.next == len(r.values) {
+		return io.EOF
+	}
+	copy(dest, r.values[r.next])
+	r.next++
+	return nil
+}
@@ -0,0 +1,31 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package mysql
+
+import (
+	"github.com/actiontech/dtle/internal/config"
+	"github.com/actiontech/dtle/internal/g"
+	log "github.com/actiontech/dtle/internal/logger"
+)
+
+// newFaultInjector validates the FaultInjection of a task and returns its
+// injector, nil if it has none or the node does not allow it
+func newFaultInjector(cfg *config.FaultInjectionConfig, logger *log.Entry) (*config.FaultInjector, error) {
+	if cfg == nil {
+		return nil, nil
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	faults := config.NewFaultInjector(cfg)
+	if faults == nil {
+		logger.Warnf("mysql: FaultInjection ignored, %s is not set on the node", g.ENV_FAULT_INJECTION)
+	} else {
+		logger.Warnf("mysql: FaultInjection enabled: %+v", *cfg)
+	}
+	return faults, nil
+}
@@ -0,0 +1,118 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package mysql
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/actiontech/dtle/internal/client/driver/mysql/sql"
+	"github.com/actiontech/dtle/internal/config"
+)
+
+// orderByForeignKeys reorders the schemas and tables to copy so that the
+// tables referenced by foreign keys are created and loaded before the tables
+// referencing them, letting the target keep foreign_key_checks on.
+func (e *Extractor) orderByForeignKeys() error {
+	var schemas []string
+	for _, db := range e.replicateDoDb {
+		schemas = append(schemas, db.TableSchema)
+	}
+	if len(schemas) == 0 {
+		return nil
+	}
+
+	// "schema.table" and "schema" of the children to those of their parents
+	tableParents := make(map[string][]string)
+	schemaParents := make(map[string][]string)
+	query := fmt.Sprintf(`select distinct TABLE_SCHEMA, TABLE_NAME, REFERENCED_TABLE_SCHEMA, REFERENCED_TABLE_NAME
+		from information_schema.KEY_COLUMN_USAGE
+		where REFERENCED_TABLE_NAME is not null and TABLE_SCHEMA in (%s)`, sql.InClauseStringValues(schemas))
+	err := sql.QueryRowsMap(e.db, query, func(m sql.RowMap) error {
+		schema, parentSchema := m.GetString("TABLE_SCHEMA"), m.GetString("REFERENCED_TABLE_SCHEMA")
+		child := fmt.Sprintf("%s.%s", schema, m.GetString("TABLE_NAME"))
+		parent := fmt.Sprintf("%s.%s", parentSchema, m.GetString("REFERENCED_TABLE_NAME"))
+		tableParents[child] = append(tableParents[child], parent)
+		if schema != parentSchema {
+			schemaParents[schema] = append(schemaParents[schema], parentSchema)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	sorted, cyclic := sortByDependencies(schemas, schemaParents)
+	if len(cyclic) > 0 {
+		e.logger.Warnf("mysql.extractor: schemas %v reference each other: they are copied in the configured order", cyclic)
+	}
+	dbs := make(map[string]*config.DataSource)
+	for _, db := range e.replicateDoDb {
+		dbs[db.TableSchema] = db
+	}
+	for i, schema := range sorted {
+		e.replicateDoDb[i] = dbs[schema]
+	}
+
+	for _, db := range e.replicateDoDb {
+		var names []string
+		tables := make(map[string]*config.Table)
+		for _, tb := range db.Tables {
+			name := fmt.Sprintf("%s.%s", tb.TableSchema, tb.TableName)
+			names = append(names, name)
+			tables[name] = tb
+		}
+		sorted, cyclic := sortByDependencies(names, tableParents)
+		if len(cyclic) > 0 {
+			e.logger.Warnf("mysql.extractor: tables %v reference each other: loading them needs foreign_key_checks off on the target",
+				cyclic)
+		}
+		for i, name := range sorted {
+			db.Tables[i] = tables[name]
+		}
+		e.logger.Debugf("mysql.extractor: tables of %s in foreign key order: %s", db.TableSchema, strings.Join(sorted, ", "))
+	}
+	return nil
+}
+
+// sortByDependencies sorts names so that each comes after those it depends
+// on, keeping the given order otherwise. Dependencies outside names are
+// ignored. The names in or depending on a cycle are appended in the given
+// order and also returned as cyclic.
+func sortByDependencies(names []string, dependencies map[string][]string) (sorted []string, cyclic []string) {
+	pending := make(map[string]bool)
+	for _, name := range names {
+		pending[name] = true
+	}
+	for progress := true; progress; {
+		progress = false
+		for _, name := range names {
+			if !pending[name] {
+				continue
+			}
+			ready := true
+			for _, dependency := range dependencies[name] {
+				if dependency != name && pending[dependency] {
+					ready = false
+					break
+				}
+			}
+			if ready {
+				sorted = append(sorted, name)
+				delete(pending, name)
+				progress = true
+			}
+		}
+	}
+	for _, name := range names {
+		if pending[name] {
+			sorted = append(sorted, name)
+			cyclic = append(cyclic, name)
+		}
+	}
+	return sorted, cyclic
+}
@@ -0,0 +1,97 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package mysql
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/actiontech/dtle/internal/client/driver/mysql/base"
+	"github.com/actiontech/dtle/internal/client/driver/mysql/binlog"
+	"github.com/actiontech/dtle/internal/client/driver/mysql/sql"
+	umconf "github.com/actiontech/dtle/internal/config/mysql"
+)
+
+// historyOps are the values of the OpColumn of the history tables
+var historyOps = map[binlog.EventDML]string{
+	binlog.InsertDML: "insert",
+	binlog.UpdateDML: "update",
+	binlog.DeleteDML: "delete",
+}
+
+// historyQuery returns the insert of a row event into the history table of
+// its table, and its arguments: the row after an insert or update, before
+// a delete, then its op, the commit time and the GTID of its transaction
+func (a *Applier) historyQuery(binlogEntry *binlog.BinlogEntry, dmlEvent *binlog.DataEvent,
+	tableColumns *umconf.ColumnList) (string, []interface{}, error) {
+
+	history := a.mysqlContext.History
+	values := dmlEvent.NewColumnValues
+	if dmlEvent.DML == binlog.DeleteDML {
+		values = dmlEvent.WhereColumnValues
+	}
+	query, args, err := sql.BuildDMLHistoryQuery(dmlEvent.DatabaseName, dmlEvent.TableName+history.TableSuffix,
+		tableColumns, []string{history.OpColumn, history.TsColumn, history.GtidColumn}, values.GetAbstractValues())
+	if err != nil {
+		return "", nil, err
+	}
+	args = append(args, historyOps[dmlEvent.DML],
+		time.Unix(int64(binlogEntry.Timestamp), 0).UTC().Format("2006-01-02 15:04:05"),
+		fmt.Sprintf("%s:%d", binlogEntry.Coordinates.GetSid(), binlogEntry.Coordinates.GNO))
+	return query, args, nil
+}
+
+// ensureHistoryTable creates the history table of a table of the given
+// columns, or adds to it the columns it lacks once a DDL added them to the
+// table. The columns of the table are nullable in the history table, for
+// the changes from before they were added.
+func (a *Applier) ensureHistoryTable(schemaName, tableName string, tableColumns *umconf.ColumnList) error {
+	history := a.mysqlContext.History
+	historyTable := tableName + history.TableSuffix
+	var count int
+	err := a.db.QueryRow("select count(*) from information_schema.tables where table_schema = ? and table_name = ?",
+		schemaName, historyTable).Scan(&count)
+	if err != nil {
+		return err
+	}
+
+	if count == 0 {
+		var defs []string
+		for _, column := range tableColumns.ColumnList() {
+			if !column.IsGenerated {
+				defs = append(defs, fmt.Sprintf("%s %s null", sql.EscapeName(column.Name), column.ColumnType))
+			}
+		}
+		defs = append(defs,
+			fmt.Sprintf("%s varchar(6) not null", sql.EscapeName(history.OpColumn)),
+			fmt.Sprintf("%s datetime not null", sql.EscapeName(history.TsColumn)),
+			fmt.Sprintf("%s varchar(64) not null", sql.EscapeName(history.GtidColumn)))
+		query := fmt.Sprintf("create table if not exists %s.%s (%s)",
+			sql.EscapeName(schemaName), sql.EscapeName(historyTable), strings.Join(defs, ", "))
+		a.logger.Infof("mysql.applier: creating the history table %s.%s", schemaName, historyTable)
+		_, err = a.db.Exec(query)
+		return err
+	}
+
+	historyColumns, err := base.GetTableColumns(a.db, schemaName, historyTable)
+	if err != nil {
+		return err
+	}
+	for _, column := range tableColumns.ColumnList() {
+		if _, ok := historyColumns.Ordinals[column.Name]; ok || column.IsGenerated {
+			continue
+		}
+		query := fmt.Sprintf("alter table %s.%s add column %s %s null", sql.EscapeName(schemaName),
+			sql.EscapeName(historyTable), sql.EscapeName(column.Name), column.ColumnType)
+		a.logger.Infof("mysql.applier: adding the column %s to the history table %s.%s", column.Name, schemaName, historyTable)
+		if _, err := a.db.Exec(query); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,136 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package mysql
+
+import (
+	"database/sql/driver"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/actiontech/dtle/internal/client/driver/mysql/base"
+	"github.com/actiontech/dtle/internal/client/driver/mysql/binlog"
+	"github.com/actiontech/dtle/internal/config"
+	umconf "github.com/actiontech/dtle/internal/config/mysql"
+	log "github.com/actiontech/dtle/internal/logger"
+	"github.com/satori/go.uuid"
+)
+
+func newHistoryApplier() *Applier {
+	return &Applier{
+		logger: log.NewEntry(log.New(os.Stdout, log.DebugLevel)),
+		mysqlContext: &config.MySQLDriverConfig{
+			History: &config.HistoryConfig{TableSuffix: "_history", OpColumn: "_op", TsColumn: "_ts", GtidColumn: "_gtid"},
+		},
+	}
+}
+
+func newHistoryColumns() *umconf.ColumnList {
+	columns := umconf.NewColumnList([]umconf.Column{
+		{Name: "id", ColumnType: "int", Key: "PRI"},
+		{Name: "name", ColumnType: "varchar(10)"},
+		{Name: "name_len", ColumnType: "int", IsGenerated: true},
+		{Name: "age", ColumnType: "int"},
+	})
+	return columns
+}
+
+func TestApplier_historyQuery(t *testing.T) {
+	a := newHistoryApplier()
+	sid := "113fa2ce-c8e6-11e7-b894-67ad30e6f107"
+	entry := &binlog.BinlogEntry{
+		Coordinates: base.BinlogCoordinateTx{SID: uuid.FromStringOrNil(sid), GNO: 42},
+		Timestamp:   uint32(time.Date(2018, 1, 2, 3, 4, 5, 0, time.UTC).Unix()),
+	}
+	before := umconf.ToColumnValues([]interface{}{1, "old", 3, 20})
+	after := umconf.ToColumnValues([]interface{}{1, "new", 3, 21})
+	for _, tt := range []struct {
+		dml      binlog.EventDML
+		wantArgs []interface{}
+	}{
+		// the row after an insert or update, before a delete, then the op,
+		// the commit time and the GTID
+		{binlog.InsertDML, []interface{}{1, "new", 21, "insert", "2018-01-02 03:04:05", sid + ":42"}},
+		{binlog.UpdateDML, []interface{}{1, "new", 21, "update", "2018-01-02 03:04:05", sid + ":42"}},
+		{binlog.DeleteDML, []interface{}{1, "old", 20, "delete", "2018-01-02 03:04:05", sid + ":42"}},
+	} {
+		event := binlog.NewDataEvent("mydb", "tbl", tt.dml, 4)
+		event.WhereColumnValues = before
+		event.NewColumnValues = after
+		if tt.dml == binlog.InsertDML {
+			event.WhereColumnValues = nil
+		} else if tt.dml == binlog.DeleteDML {
+			event.NewColumnValues = nil
+		}
+		query, args, err := a.historyQuery(entry, &event, newHistoryColumns())
+		if err != nil {
+			t.Fatalf("%v: %v", tt.dml, err)
+		}
+		if want := "insert into `mydb`.`tbl_history` (`id`, `name`, `age`, `_op`, `_ts`, `_gtid`) values (?, ?, ?, ?, ?, ?)"; normalizeHistoryQuery(query) != want {
+			t.Errorf("%v: query %s", tt.dml, query)
+		}
+		if !reflect.DeepEqual(args, tt.wantArgs) {
+			t.Errorf("%v: args %v, want %v", tt.dml, args, tt.wantArgs)
+		}
+	}
+}
+
+func TestApplier_ensureHistoryTable(t *testing.T) {
+	count := []string{"count(*)"}
+	{
+		// the table is created with the columns nullable, but the
+		// generated ones
+		fake := newFakeDB()
+		fake.answer("information_schema.tables", count, []driver.Value{int64(0)})
+		a := newHistoryApplier()
+		a.db = fake.open()
+		if err := a.ensureHistoryTable("mydb", "tbl", newHistoryColumns()); err != nil {
+			t.Fatal(err)
+		}
+		want := []string{"create table if not exists `mydb`.`tbl_history` (`id` int null, `name` varchar(10) null, " +
+			"`age` int null, `_op` varchar(6) not null, `_ts` datetime not null, `_gtid` varchar(64) not null)"}
+		if got := fakeQueries(fake); !reflect.DeepEqual(got, want) {
+			t.Errorf("created with %v, want %v", got, want)
+		}
+	}
+	{
+		// the columns the table lacks are added
+		fake := newFakeDB()
+		fake.answer("information_schema.tables", count, []driver.Value{int64(1)})
+		columns := []string{"Field", "Type", "Null", "Key", "Default", "Extra"}
+		fake.answer("show columns", columns,
+			[]driver.Value{"id", "int", "YES", "", nil, ""},
+			[]driver.Value{"_op", "varchar(6)", "NO", "", nil, ""},
+			[]driver.Value{"_ts", "datetime", "NO", "", nil, ""},
+			[]driver.Value{"_gtid", "varchar(64)", "NO", "", nil, ""})
+		a := newHistoryApplier()
+		a.db = fake.open()
+		if err := a.ensureHistoryTable("mydb", "tbl", newHistoryColumns()); err != nil {
+			t.Fatal(err)
+		}
+		want := []string{
+			"alter table `mydb`.`tbl_history` add column `name` varchar(10) null",
+			"alter table `mydb`.`tbl_history` add column `age` int null",
+		}
+		if got := fakeQueries(fake); !reflect.DeepEqual(got, want) {
+			t.Errorf("altered with %v, want %v", got, want)
+		}
+	}
+}
+
+func fakeQueries(fake *fakeDB) (queries []string) {
+	for _, exec := range fake.executed() {
+		queries = append(queries, exec.query)
+	}
+	return queries
+}
+
+func normalizeHistoryQuery(query string) string {
+	return strings.Join(strings.Fields(query), " ")
+}
@@ -44,6 +44,9 @@ func (i *Inspector) InitDBConnections() (err error) {
 	if err := i.validateConnection(); err != nil {
 		return err
 	}
+	if err := i.validateManagedMySQL(); err != nil {
+		return err
+	}
 	if err := i.validateGrants(); err != nil {
 		i.logger.Errorf("mysql.inspector: Unexpected error on validateGrants, got %v", err)
 		return err
@@ -65,6 +68,11 @@ func (i *Inspector) InitDBConnections() (err error) {
 	if err := i.validateBinlogs(); err != nil {
 		return err
 	}
+	if i.mysqlContext.PrimaryConnectionConfig != nil {
+		if err := i.validateReplicaBinlogs(); err != nil {
+			return err
+		}
+	}
 	i.logger.Printf("mysql.inspector: Initiated on %s:%d, version %+v", i.mysqlContext.ConnectionConfig.Host, i.mysqlContext.ConnectionConfig.Port, i.mysqlContext.MySQLVersion)
 	return nil
 }
@@ -232,7 +240,8 @@ func (i *Inspector) validateGrants() error {
 		return nil
 	}
 	i.logger.Debugf("mysql.inspector: Privileges: super: %t, REPLICATION CLIENT: %t, REPLICATION SLAVE: %t, ALL on *.*: %t, ALL on *.*: %t", foundSuper, foundReplicationClient, foundReplicationSlave, foundAll, foundDBAll)
-	return fmt.Errorf("user has insufficient privileges for extractor. Needed: SUPER|REPLICATION CLIENT, REPLICATION SLAVE and ALL on *.*")
+	return fmt.Errorf("user has insufficient privileges for extractor. Needed: SUPER|REPLICATION CLIENT, REPLICATION SLAVE and ALL on *.*%s",
+		ManagedMySQLGrantsHint(i.mysqlContext.ManagedMySQL))
 }
 
 func (i *Inspector) validateGTIDMode() error {
@@ -242,7 +251,8 @@ func (i *Inspector) validateGTIDMode() error {
 		return err
 	}
 	if gtidMode != "ON" {
-		return fmt.Errorf("must have GTID enabled: %+v", gtidMode)
+		return fmt.Errorf("must have GTID enabled: %+v%s", gtidMode,
+			ManagedMySQLHint(i.mysqlContext.ManagedMySQL, "gtid_mode and enforce_gtid_consistency to ON"))
 	}
 	return nil
 }
@@ -255,9 +265,16 @@ func (i *Inspector) validateBinlogs() error {
 		return err
 	}
 	if !hasBinaryLogs {
+		if i.isManaged() {
+			return fmt.Errorf("%s:%d must have binary logs enabled: on Amazon RDS and Aurora, enable automated backups", i.mysqlContext.ConnectionConfig.Host, i.mysqlContext.ConnectionConfig.Port)
+		}
 		return fmt.Errorf("%s:%d must have binary logs enabled", i.mysqlContext.ConnectionConfig.Host, i.mysqlContext.ConnectionConfig.Port)
 	}
 	if i.mysqlContext.RequiresBinlogFormatChange() {
+		if i.isManaged() {
+			return fmt.Errorf("%s:%d must be using ROW binlog format%s", i.mysqlContext.ConnectionConfig.Host, i.mysqlContext.ConnectionConfig.Port,
+				ManagedMySQLHint(i.mysqlContext.ManagedMySQL, "binlog_format to ROW"))
+		}
 		return fmt.Errorf("You must be using ROW binlog format. I can switch it for you, provided --switch-to-rbr and that %s:%d doesn't have replicas", i.mysqlContext.ConnectionConfig.Host, i.mysqlContext.ConnectionConfig.Port)
 	}
 	query = `select @@global.binlog_row_image`
@@ -271,6 +288,21 @@ func (i *Inspector) validateBinlogs() error {
 	return nil
 }
 
+// validateReplicaBinlogs checks that a replica source logs the transactions
+// it replicates from its primary, so that they can be read from its binlog
+func (i *Inspector) validateReplicaBinlogs() error {
+	query := `select @@global.log_slave_updates`
+	var logSlaveUpdates bool
+	if err := i.db.QueryRow(query).Scan(&logSlaveUpdates); err != nil {
+		return err
+	}
+	if !logSlaveUpdates {
+		return fmt.Errorf("%s:%d must have log_slave_updates enabled to be read as a replica%s", i.mysqlContext.ConnectionConfig.Host, i.mysqlContext.ConnectionConfig.Port,
+			ManagedMySQLHint(i.mysqlContext.ManagedMySQL, "log_slave_updates to 1"))
+	}
+	return nil
+}
+
 // validateTable makes sure the table we need to operate on actually exists
 func (i *Inspector) validateTable(databaseName, tableName string) error {
 	query := fmt.Sprintf(`show table status from %s like '%s'`, usql.EscapeName(databaseName), tableName)
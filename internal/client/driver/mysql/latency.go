@@ -0,0 +1,174 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package mysql
+
+import (
+	"math"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/actiontech/dtle/internal/client/driver/mysql/binlog"
+	"github.com/actiontech/dtle/internal/models"
+)
+
+const (
+	// latencyAdjustInterval is how often the batching is adjusted to the
+	// latency observed
+	latencyAdjustInterval = 5 * time.Second
+	// maxLatencySamples bounds the latencies kept over an interval, the
+	// oldest being replaced beyond
+	maxLatencySamples = 10000
+	// minLatencyScale bounds how far the batching is scaled down
+	minLatencyScale = 1.0 / 64
+)
+
+// latencyController scales the batching of a job to the LatencyTargetMs of
+// the p99 of the latency from the transactions being read from the source
+// to them being committed on the target. The clocks of the source and the
+// target are not compared: the latency of a transaction is its SourceDelay,
+// timed by the extractor, and the time from it being received to it being
+// committed, timed by the applier. The batches are halved while the p99 is
+// above the target, unless the target is falling behind, as larger batches
+// are then needed for the throughput, and grow by a quarter while the p99
+// is within half the target. The scale is sent back to the extractor with
+// the acks of the groups of entries.
+// The methods are no-ops on a nil controller, without a LatencyTargetMs.
+type latencyController struct {
+	target time.Duration
+
+	mutex   sync.Mutex
+	samples []time.Duration
+	// next is where the next sample replaces an old one once there are
+	// maxLatencySamples
+	next  int
+	p99   time.Duration
+	scale float64
+}
+
+func newLatencyController(targetMs int) *latencyController {
+	if targetMs <= 0 {
+		return nil
+	}
+	return &latencyController{
+		target: time.Duration(targetMs) * time.Millisecond,
+		scale:  1,
+	}
+}
+
+// observe records the latency of the transactions committed
+func (c *latencyController) observe(binlogEntries []*binlog.BinlogEntry) {
+	if c == nil {
+		return
+	}
+	now := time.Now().UnixNano()
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	for _, binlogEntry := range binlogEntries {
+		if binlogEntry.SourceDelay == 0 || binlogEntry.ReceiveTime == 0 {
+			// sent by an extractor not timing it
+			continue
+		}
+		latency := time.Duration(binlogEntry.SourceDelay + now - binlogEntry.ReceiveTime)
+		if len(c.samples) < maxLatencySamples {
+			c.samples = append(c.samples, latency)
+		} else {
+			c.samples[c.next] = latency
+			c.next = (c.next + 1) % maxLatencySamples
+		}
+	}
+}
+
+// adjust scales the batching to the p99 of the latencies observed since the
+// last adjustment. backlogged tells whether the target is falling behind.
+func (c *latencyController) adjust(backlogged bool) {
+	if c == nil {
+		return
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if len(c.samples) == 0 {
+		return
+	}
+	sort.Slice(c.samples, func(i, j int) bool { return c.samples[i] < c.samples[j] })
+	c.p99 = c.samples[int(math.Ceil(float64(len(c.samples))*0.99))-1]
+	c.samples, c.next = c.samples[:0], 0
+
+	switch {
+	case c.p99 > c.target && !backlogged:
+		c.scale /= 2
+	case c.p99 > c.target || c.p99 < c.target/2:
+		c.scale *= 1.25
+	}
+	if c.scale < minLatencyScale {
+		c.scale = minLatencyScale
+	} else if c.scale > 1 {
+		c.scale = 1
+	}
+}
+
+// Scale returns the factor the batching is scaled by, 1 without a target
+func (c *latencyController) Scale() float64 {
+	if c == nil {
+		return 1
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.scale
+}
+
+// scaled returns n scaled, at least 1
+func (c *latencyController) scaled(n int) int {
+	if c == nil {
+		return n
+	}
+	if n = int(float64(n) * c.Scale()); n < 1 {
+		n = 1
+	}
+	return n
+}
+
+// ack returns the data of the acks of the groups of entries, the scale for
+// the extractor to size its groups with, nil without a target
+func (c *latencyController) ack() []byte {
+	if c == nil {
+		return nil
+	}
+	return []byte(strconv.FormatFloat(c.Scale(), 'f', -1, 64))
+}
+
+// stat returns the latency stat of the task, nil without a target
+func (c *latencyController) stat() *models.LatencyStat {
+	if c == nil {
+		return nil
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return &models.LatencyStat{
+		TargetMs: int64(c.target / time.Millisecond),
+		P99Ms:    int64(c.p99 / time.Millisecond),
+		Scale:    c.scale,
+	}
+}
+
+// adjustBatching adjusts the batching of the applier to its LatencyTargetMs
+// until it shuts down
+func (a *Applier) adjustBatching() {
+	defer models.RecoverPanic(a.onPanic)
+	ticker := time.NewTicker(latencyAdjustInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			backlogged := len(a.applyDataEntryQueue) > cap(a.applyDataEntryQueue)/2
+			a.latency.adjust(backlogged)
+		case <-a.shutdownCh:
+			return
+		}
+	}
+}
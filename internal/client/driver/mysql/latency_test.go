@@ -0,0 +1,159 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package mysql
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/actiontech/dtle/internal/client/driver/mysql/binlog"
+)
+
+func TestLatencyController_adjust(t *testing.T) {
+	for _, tt := range []struct {
+		name       string
+		scale      float64
+		samples    []time.Duration
+		backlogged bool
+		want       float64
+		wantP99    time.Duration
+	}{
+		{"above target halves", 1, []time.Duration{150 * time.Millisecond}, false, 0.5, 150 * time.Millisecond},
+		{"above target backlogged grows", 0.5, []time.Duration{150 * time.Millisecond}, true, 0.625, 150 * time.Millisecond},
+		{"within half the target grows", 0.5, []time.Duration{40 * time.Millisecond}, false, 0.625, 40 * time.Millisecond},
+		{"between half and the target holds", 0.5, []time.Duration{80 * time.Millisecond}, false, 0.5, 80 * time.Millisecond},
+		{"clamped to the minimum", minLatencyScale, []time.Duration{time.Second}, false, minLatencyScale, time.Second},
+		{"clamped to 1", 0.9, []time.Duration{time.Millisecond}, false, 1, time.Millisecond},
+		{"without samples holds", 0.5, nil, false, 0.5, 0},
+		{"p99 ignores the slowest percent", 1, append(make([]time.Duration, 99), time.Second), false, 1, 0},
+	} {
+		c := newLatencyController(100)
+		c.scale = tt.scale
+		c.samples = append(c.samples, tt.samples...)
+		c.adjust(tt.backlogged)
+		if got := c.Scale(); math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("%s: scale %v, want %v", tt.name, got, tt.want)
+		}
+		if c.p99 != tt.wantP99 {
+			t.Errorf("%s: p99 %v, want %v", tt.name, c.p99, tt.wantP99)
+		}
+		if len(c.samples) != 0 {
+			t.Errorf("%s: %d samples kept after adjusting", tt.name, len(c.samples))
+		}
+	}
+
+	var c *latencyController
+	c.adjust(false)
+	if got := c.Scale(); got != 1 {
+		t.Errorf("nil controller scale %v, want 1", got)
+	}
+	if newLatencyController(0) != nil {
+		t.Errorf("controller without a target")
+	}
+}
+
+func TestLatencyController_observe(t *testing.T) {
+	c := newLatencyController(100)
+	now := time.Now().UnixNano()
+	c.observe([]*binlog.BinlogEntry{
+		// 40ms before being received, 10ms before the commit
+		{SourceDelay: int64(40 * time.Millisecond), ReceiveTime: now - int64(10*time.Millisecond)},
+		// not timed by the extractor
+		{ReceiveTime: now},
+		// timed by the extractor only
+		{SourceDelay: int64(time.Second)},
+	})
+	if len(c.samples) != 1 {
+		t.Fatalf("%d samples, want 1", len(c.samples))
+	}
+	if got := c.samples[0]; got < 50*time.Millisecond || got > time.Second {
+		t.Errorf("latency %v, want about 50ms", got)
+	}
+
+	// beyond maxLatencySamples the oldest are replaced
+	entry := &binlog.BinlogEntry{SourceDelay: 1, ReceiveTime: time.Now().UnixNano()}
+	entries := make([]*binlog.BinlogEntry, maxLatencySamples+1)
+	for i := range entries {
+		entries[i] = entry
+	}
+	c.observe(entries)
+	if len(c.samples) != maxLatencySamples {
+		t.Errorf("%d samples, want %d", len(c.samples), maxLatencySamples)
+	}
+	if c.next != 2 {
+		t.Errorf("next sample at %d, want 2", c.next)
+	}
+}
+
+func TestGroupSizer_observeAck(t *testing.T) {
+	for _, tt := range []struct {
+		data string
+		want float64
+	}{
+		{"0.25", 0.25},
+		{"1", 1},
+		// ignored: the scale stays as it was
+		{"", 0.5},
+		{"x", 0.5},
+		{"0", 0.5},
+		{"-0.5", 0.5},
+		{"1.5", 0.5},
+	} {
+		s := newGroupSizer(10, 10, 0)
+		s.observeAck([]byte("0.5"))
+		s.observeAck([]byte(tt.data))
+		if got := s.Scale(); got != tt.want {
+			t.Errorf("ack %q: scale %v, want %v", tt.data, got, tt.want)
+		}
+	}
+}
+
+func TestGroupSizer_Timeout(t *testing.T) {
+	for _, tt := range []struct {
+		scale        string
+		groupTimeout time.Duration
+		want         time.Duration
+	}{
+		{"1", 100 * time.Millisecond, 100 * time.Millisecond},
+		{"0.25", 100 * time.Millisecond, 25 * time.Millisecond},
+		// at least 1ms
+		{"0.015625", 10 * time.Millisecond, time.Millisecond},
+		{"1", 0, time.Millisecond},
+	} {
+		s := newGroupSizer(64, 64, 0)
+		s.observeAck([]byte(tt.scale))
+		if got := s.Timeout(tt.groupTimeout); got != tt.want {
+			t.Errorf("scale %s: timeout %v, want %v", tt.scale, got, tt.want)
+		}
+	}
+
+	// the size is scaled too, at least 1
+	s := newGroupSizer(64, 64, 0)
+	s.observeAck([]byte("0.25"))
+	if got := s.Limit(); got != 16 {
+		t.Errorf("limit %d, want 16", got)
+	}
+	s.observeAck([]byte("0.001"))
+	if got := s.Limit(); got != 1 {
+		t.Errorf("limit %d, want 1", got)
+	}
+}
+
+func TestGroupSizer_stampSourceDelays(t *testing.T) {
+	s := newGroupSizer(10, 10, 0)
+	s.observeLatency(20 * time.Millisecond)
+	read := &binlog.BinlogEntry{ReadTime: time.Now().UnixNano() - int64(30*time.Millisecond)}
+	unread := &binlog.BinlogEntry{}
+	s.stampSourceDelays([]*binlog.BinlogEntry{read, unread})
+	if got := time.Duration(read.SourceDelay); got < 50*time.Millisecond || got > time.Second {
+		t.Errorf("source delay %v, want about 50ms", got)
+	}
+	if unread.SourceDelay != 0 {
+		t.Errorf("source delay %v without a read time", unread.SourceDelay)
+	}
+}
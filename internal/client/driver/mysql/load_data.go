@@ -0,0 +1,88 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package mysql
+
+import (
+	"bytes"
+	gosql "database/sql"
+	"fmt"
+	"io"
+	"sync/atomic"
+
+	gomysqldriver "github.com/go-sql-driver/mysql"
+)
+
+// loadDataCount numbers the readers registered for LOAD DATA
+var loadDataCount int64
+
+// loadData loads the rows of a snapshot entry with LOAD DATA LOCAL INFILE,
+// streaming them as CSV to the target rather than building statements
+func (a *Applier) loadData(tx *gosql.Tx, entry *DumpEntry, columns string, generated map[int]bool) error {
+	buf := &bytes.Buffer{}
+	for _, row := range entry.ValuesX {
+		first := true
+		for j, colData := range row {
+			if generated[j] {
+				continue
+			}
+			if first {
+				first = false
+			} else {
+				buf.WriteByte(',')
+			}
+			if *colData == nil {
+				buf.WriteString(`\N`)
+				continue
+			}
+			buf.WriteByte('"')
+			writeLoadDataValue(buf, (*colData).([]byte))
+			buf.WriteByte('"')
+		}
+		buf.WriteByte('\n')
+	}
+
+	name := fmt.Sprintf("dtle_%s_%d", a.subject, atomic.AddInt64(&loadDataCount, 1))
+	gomysqldriver.RegisterReaderHandler(name, func() io.Reader {
+		return buf
+	})
+	defer gomysqldriver.DeregisterReaderHandler(name)
+
+	query := fmt.Sprintf(`LOAD DATA LOCAL INFILE 'Reader::%s' REPLACE INTO TABLE %s.%s CHARACTER SET %s `+
+		`FIELDS TERMINATED BY ',' ENCLOSED BY '"' ESCAPED BY '\\' LINES TERMINATED BY '\n'%s`,
+		name, entry.TableSchema, entry.TableName, a.mysqlContext.ConnectionConfig.Charset, columns)
+	_, err := tx.Exec(query)
+	return err
+}
+
+// writeLoadDataValue writes a value enclosed by '"' with '\\' as the escape
+// character
+func writeLoadDataValue(buf *bytes.Buffer, value []byte) {
+	last := 0
+	for i, c := range value {
+		var esc string
+		switch c {
+		case 0:
+			esc = `\0`
+		case '\n':
+			esc = `\n`
+		case '\r':
+			esc = `\r`
+		case '\\':
+			esc = `\\`
+		case '"':
+			esc = `\"`
+		case '\032':
+			esc = `\Z`
+		default:
+			continue
+		}
+		buf.Write(value[last:i])
+		buf.WriteString(esc)
+		last = i + 1
+	}
+	buf.Write(value[last:])
+}
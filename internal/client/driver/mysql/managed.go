@@ -0,0 +1,173 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package mysql
+
+import (
+	gosql "database/sql"
+	"fmt"
+	"strconv"
+	"strings"
+
+	usql "github.com/actiontech/dtle/internal/client/driver/mysql/sql"
+	uconf "github.com/actiontech/dtle/internal/config"
+)
+
+// rdsBinlogRetentionHours is the name of the binlog retention setting of
+// Amazon RDS and Aurora
+const rdsBinlogRetentionHours = "binlog retention hours"
+
+// DetectManagedMySQL returns the managed MySQL offering of a server: the
+// configured one if any, else the one detected.
+func DetectManagedMySQL(db *gosql.DB, configured string) (string, error) {
+	switch configured {
+	case uconf.ManagedMySQLNone, uconf.ManagedMySQLRDS, uconf.ManagedMySQLAurora:
+		return configured, nil
+	case "":
+	default:
+		return "", fmt.Errorf("unknown ManagedMySQL %q: must be %q, %q or %q",
+			configured, uconf.ManagedMySQLRDS, uconf.ManagedMySQLAurora, uconf.ManagedMySQLNone)
+	}
+
+	// Only Aurora has this variable; it shares the base directory of RDS.
+	var auroraVersion string
+	if err := db.QueryRow("select @@aurora_version").Scan(&auroraVersion); err == nil {
+		return uconf.ManagedMySQLAurora, nil
+	}
+	var basedir string
+	if err := db.QueryRow("select @@global.basedir").Scan(&basedir); err != nil {
+		return "", err
+	}
+	if strings.HasPrefix(basedir, "/rdsdbbin/") {
+		return uconf.ManagedMySQLRDS, nil
+	}
+	return uconf.ManagedMySQLNone, nil
+}
+
+// ManagedMySQLHint tells how to change a server parameter on a managed MySQL
+// offering, to be appended to validation messages
+func ManagedMySQLHint(managed string, parameter string) string {
+	switch managed {
+	case uconf.ManagedMySQLRDS:
+		return fmt.Sprintf(". On Amazon RDS, set %s in the DB parameter group of the instance", parameter)
+	case uconf.ManagedMySQLAurora:
+		return fmt.Sprintf(". On Amazon Aurora, set %s in the DB cluster parameter group and reboot the writer", parameter)
+	default:
+		return ""
+	}
+}
+
+// ManagedMySQLGrantsHint tells which privileges to grant on a managed MySQL
+// offering, where SUPER can not be granted
+func ManagedMySQLGrantsHint(managed string) string {
+	switch managed {
+	case uconf.ManagedMySQLRDS, uconf.ManagedMySQLAurora:
+		return ". SUPER can not be granted on Amazon RDS and Aurora: as the master user, grant REPLICATION CLIENT, REPLICATION SLAVE and SELECT on *.*"
+	default:
+		return ""
+	}
+}
+
+// isManaged tells whether the source is a managed MySQL offering
+func (i *Inspector) isManaged() bool {
+	return i.mysqlContext.ManagedMySQL == uconf.ManagedMySQLRDS ||
+		i.mysqlContext.ManagedMySQL == uconf.ManagedMySQLAurora
+}
+
+// validateManagedMySQL detects the managed MySQL offering of the source, and
+// checks it can be replicated from
+func (i *Inspector) validateManagedMySQL() (err error) {
+	if i.mysqlContext.ManagedMySQL, err = DetectManagedMySQL(i.db, i.mysqlContext.ManagedMySQL); err != nil {
+		return err
+	}
+	if !i.isManaged() {
+		return nil
+	}
+	i.logger.Printf("mysql.inspector: %s:%d is managed MySQL (%s)",
+		i.mysqlContext.ConnectionConfig.Host, i.mysqlContext.ConnectionConfig.Port, i.mysqlContext.ManagedMySQL)
+
+	if i.mysqlContext.ManagedMySQL == uconf.ManagedMySQLAurora {
+		if err := i.validateAurora(); err != nil {
+			return err
+		}
+	}
+	return i.validateBinlogRetention()
+}
+
+// validateAurora checks the source is an Aurora writer instance with GTIDs
+func (i *Inspector) validateAurora() error {
+	var auroraVersion string
+	var readOnly bool
+	query := `select @@aurora_version, @@global.innodb_read_only`
+	if err := i.db.QueryRow(query).Scan(&auroraVersion, &readOnly); err != nil {
+		return err
+	}
+	// Aurora MySQL 1.x is MySQL 5.6 without GTIDs, which came with 2.04
+	major, minor := parseAuroraVersion(auroraVersion)
+	if major < 2 || (major == 2 && minor < 4) {
+		return fmt.Errorf("Aurora MySQL %s does not support GTIDs: upgrade to 2.04 or later", auroraVersion)
+	}
+	// Readers share the storage of the writer but have no binlog
+	if readOnly {
+		return fmt.Errorf("%s:%d is an Aurora reader instance, which has no binlog: connect to the cluster endpoint instead",
+			i.mysqlContext.ConnectionConfig.Host, i.mysqlContext.ConnectionConfig.Port)
+	}
+	return nil
+}
+
+// parseAuroraVersion returns the major and minor numbers of an Aurora
+// version such as "2.07.2"
+func parseAuroraVersion(version string) (major int, minor int) {
+	parts := strings.Split(version, ".")
+	major, _ = strconv.Atoi(parts[0])
+	if len(parts) > 1 {
+		minor, _ = strconv.Atoi(parts[1])
+	}
+	return major, minor
+}
+
+// validateBinlogRetention makes sure a managed source keeps its binlogs
+// long enough for the job to resume, RDS and Aurora purging them as soon as
+// they can by default
+func (i *Inspector) validateBinlogRetention() error {
+	hours := gosql.NullInt64{}
+	err := usql.QueryRowsMap(i.db, "call mysql.rds_show_configuration", func(m usql.RowMap) error {
+		if m.GetString("name") != rdsBinlogRetentionHours {
+			return nil
+		}
+		value := m["value"]
+		if value.Valid {
+			h, err := strconv.ParseInt(value.String, 10, 64)
+			if err != nil {
+				return fmt.Errorf("unexpected %s: %s", rdsBinlogRetentionHours, value.String)
+			}
+			hours = gosql.NullInt64{Int64: h, Valid: true}
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to read the binlog retention with mysql.rds_show_configuration: %v", err)
+	}
+
+	want := int64(i.mysqlContext.BinlogRetentionHours)
+	if want == 0 {
+		if !hours.Valid {
+			i.logger.Warnf("mysql.inspector: %s is not set: binlogs are purged as soon as possible and the job may not resume after a pause. Set BinlogRetentionHours",
+				rdsBinlogRetentionHours)
+		}
+		return nil
+	}
+	if hours.Valid && hours.Int64 >= want {
+		return nil
+	}
+	query := fmt.Sprintf("call mysql.rds_set_configuration('%s', %d)", rdsBinlogRetentionHours, want)
+	if _, err := i.db.Exec(query); err != nil {
+		return fmt.Errorf("failed to set the %s to %d: %v. Grant EXECUTE on mysql.rds_set_configuration to the user, or call it as the master user",
+			rdsBinlogRetentionHours, want, err)
+	}
+	i.logger.Printf("mysql.inspector: set the %s to %d", rdsBinlogRetentionHours, want)
+	return nil
+}
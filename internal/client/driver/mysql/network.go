@@ -0,0 +1,48 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package mysql
+
+import (
+	"net"
+
+	"github.com/actiontech/dtle/internal/config"
+	umconf "github.com/actiontech/dtle/internal/config/mysql"
+	"github.com/actiontech/dtle/internal/models"
+	gonats "github.com/nats-io/go-nats"
+)
+
+// natsConnect connects to the NATS server of the job, from NatsBindAddress
+// if set
+func natsConnect(natsAddr string, cfg *config.MySQLDriverConfig) (*gonats.Conn, error) {
+	var options []gonats.Option
+	if cfg.NatsBindAddress != "" {
+		ip, err := umconf.ResolveBindAddress(cfg.NatsBindAddress)
+		if err != nil {
+			return nil, err
+		}
+		options = append(options, gonats.Dialer(&net.Dialer{
+			Timeout:   gonats.DefaultTimeout,
+			LocalAddr: &net.TCPAddr{IP: net.ParseIP(ip)},
+		}))
+	}
+	return gonats.Connect(natsAddr, options...)
+}
+
+// networkStat adds up the bytes exchanged with MySQL and the messages
+func networkStat(traffic *umconf.Traffic, msgStat gonats.Statistics) *models.NetworkStat {
+	stat := &models.NetworkStat{
+		BytesIn:  int64(msgStat.InBytes),
+		BytesOut: int64(msgStat.OutBytes),
+	}
+	if traffic != nil {
+		stat.MySQLBytesIn = traffic.BytesIn()
+		stat.MySQLBytesOut = traffic.BytesOut()
+		stat.BytesIn += stat.MySQLBytesIn
+		stat.BytesOut += stat.MySQLBytesOut
+	}
+	return stat
+}
@@ -0,0 +1,249 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package mysql
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/actiontech/dtle/internal/client/driver/mysql/base"
+	"github.com/actiontech/dtle/internal/client/driver/mysql/sql"
+	"github.com/actiontech/dtle/internal/config"
+)
+
+var definerRegexp = regexp.MustCompile("DEFINER=(`(?:[^`]|``)*`|'[^']*'|[^ @]*)@(`(?:[^`]|``)*`|'[^']*'|[^ ]*) ")
+
+// schemaObject is a view, routine, trigger or event to create on the target
+type schemaObject struct {
+	schema string
+	name   string
+	// tbSQL creates the object, after "USE schema"
+	tbSQL   []string
+	sqlMode string
+}
+
+// validateCopyObjects checks CopyObjects and DefinerRewrite
+func (e *Extractor) validateCopyObjects() error {
+	for _, object := range e.mysqlContext.CopyObjects {
+		switch object {
+		case config.CopyObjectViews, config.CopyObjectRoutines, config.CopyObjectTriggers, config.CopyObjectEvents:
+		default:
+			return fmt.Errorf("unknown object %q in CopyObjects: must be %q, %q, %q or %q", object,
+				config.CopyObjectViews, config.CopyObjectRoutines, config.CopyObjectTriggers, config.CopyObjectEvents)
+		}
+	}
+	for from, to := range e.mysqlContext.DefinerRewrite {
+		if from != "*" && !strings.Contains(from, "@") {
+			return fmt.Errorf("invalid DefinerRewrite source %q: must be user@host or *", from)
+		}
+		if to != "" && !strings.Contains(to, "@") {
+			return fmt.Errorf("invalid DefinerRewrite target %q for %q: must be user@host or empty", to, from)
+		}
+	}
+	return nil
+}
+
+func (e *Extractor) copiesObjects(object string) bool {
+	for _, o := range e.mysqlContext.CopyObjects {
+		if o == object {
+			return true
+		}
+	}
+	return false
+}
+
+// schemaObjects returns the objects to create after the tables: the routines
+// first, views and triggers may call them, then the views, the triggers of
+// the copied tables and the events. The views given are those listed among
+// the tables.
+func (e *Extractor) schemaObjects(views []*config.Table) (objects []*schemaObject, err error) {
+	var schemas []string
+	tables := make(map[string]bool)
+	for _, db := range e.replicateDoDb {
+		schemas = append(schemas, db.TableSchema)
+		for _, tb := range db.Tables {
+			tables[fmt.Sprintf("%s.%s", tb.TableSchema, tb.TableName)] = true
+		}
+	}
+	if len(schemas) == 0 {
+		return nil, nil
+	}
+	inSchemas := sql.InClauseStringValues(schemas)
+
+	if e.copiesObjects(config.CopyObjectRoutines) {
+		query := fmt.Sprintf(`select ROUTINE_SCHEMA, ROUTINE_NAME, ROUTINE_TYPE from information_schema.ROUTINES
+			where ROUTINE_SCHEMA in (%s) order by ROUTINE_SCHEMA, ROUTINE_NAME`, inSchemas)
+		err := sql.QueryRowsMap(e.db, query, func(m sql.RowMap) error {
+			routineType := m.GetString("ROUTINE_TYPE") // PROCEDURE or FUNCTION
+			object, err := e.showCreateObject(m.GetString("ROUTINE_SCHEMA"), m.GetString("ROUTINE_NAME"), routineType,
+				"Create "+strings.Title(strings.ToLower(routineType)))
+			if err != nil {
+				return err
+			}
+			objects = append(objects, object)
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	listed := make(map[string]bool)
+	var viewNames []string
+	viewDefinitions := make(map[string]string)
+	for _, tb := range views {
+		name := fmt.Sprintf("%s.%s", tb.TableSchema, tb.TableName)
+		listed[name] = true
+		viewNames = append(viewNames, name)
+	}
+	query := fmt.Sprintf(`select TABLE_SCHEMA, TABLE_NAME, VIEW_DEFINITION from information_schema.VIEWS
+		where TABLE_SCHEMA in (%s) order by TABLE_SCHEMA, TABLE_NAME`, inSchemas)
+	err = sql.QueryRowsMap(e.db, query, func(m sql.RowMap) error {
+		name := fmt.Sprintf("%s.%s", m.GetString("TABLE_SCHEMA"), m.GetString("TABLE_NAME"))
+		if !listed[name] {
+			if !e.copiesObjects(config.CopyObjectViews) {
+				return nil
+			}
+			viewNames = append(viewNames, name)
+		}
+		viewDefinitions[name] = m.GetString("VIEW_DEFINITION")
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	// A view is created after the views it selects from
+	viewParents := make(map[string][]string)
+	for _, name := range viewNames {
+		for _, other := range viewNames {
+			parts := strings.SplitN(other, ".", 2)
+			if other != name && strings.Contains(viewDefinitions[name], fmt.Sprintf("`%s`.`%s`", parts[0], parts[1])) {
+				viewParents[name] = append(viewParents[name], other)
+			}
+		}
+	}
+	sortedViews, cyclic := sortByDependencies(viewNames, viewParents)
+	if len(cyclic) > 0 {
+		e.logger.Warnf("mysql.extractor: views %v select from each other: they are created in their listed order", cyclic)
+	}
+	for _, name := range sortedViews {
+		parts := strings.SplitN(name, ".", 2)
+		tbSQL, err := base.ShowCreateView(e.singletonDB, parts[0], parts[1], e.mysqlContext.DropTableIfExists)
+		if err != nil {
+			return nil, err
+		}
+		tbSQL[len(tbSQL)-1] = e.rewriteDefiner(tbSQL[len(tbSQL)-1])
+		objects = append(objects, &schemaObject{schema: parts[0], name: parts[1], tbSQL: tbSQL})
+	}
+
+	if e.copiesObjects(config.CopyObjectTriggers) {
+		query := fmt.Sprintf(`select TRIGGER_SCHEMA, TRIGGER_NAME, EVENT_OBJECT_SCHEMA, EVENT_OBJECT_TABLE from information_schema.TRIGGERS
+			where TRIGGER_SCHEMA in (%s) order by TRIGGER_SCHEMA, EVENT_OBJECT_TABLE, ACTION_TIMING, EVENT_MANIPULATION, ACTION_ORDER`, inSchemas)
+		err := sql.QueryRowsMap(e.db, query, func(m sql.RowMap) error {
+			table := fmt.Sprintf("%s.%s", m.GetString("EVENT_OBJECT_SCHEMA"), m.GetString("EVENT_OBJECT_TABLE"))
+			if !tables[table] {
+				return nil
+			}
+			object, err := e.showCreateObject(m.GetString("TRIGGER_SCHEMA"), m.GetString("TRIGGER_NAME"), "TRIGGER",
+				"SQL Original Statement")
+			if err != nil {
+				return err
+			}
+			objects = append(objects, object)
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if e.copiesObjects(config.CopyObjectEvents) {
+		query := fmt.Sprintf(`select EVENT_SCHEMA, EVENT_NAME from information_schema.EVENTS
+			where EVENT_SCHEMA in (%s) order by EVENT_SCHEMA, EVENT_NAME`, inSchemas)
+		err := sql.QueryRowsMap(e.db, query, func(m sql.RowMap) error {
+			object, err := e.showCreateObject(m.GetString("EVENT_SCHEMA"), m.GetString("EVENT_NAME"), "EVENT", "Create Event")
+			if err != nil {
+				return err
+			}
+			objects = append(objects, object)
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return objects, nil
+}
+
+// showCreateObject reads the statement creating a routine, trigger or event
+func (e *Extractor) showCreateObject(schema, name, objectType, createColumn string) (*schemaObject, error) {
+	object := &schemaObject{
+		schema: schema,
+		name:   name,
+		tbSQL:  []string{fmt.Sprintf("USE %s", sql.EscapeName(schema))},
+	}
+	escapedName := fmt.Sprintf("%s.%s", sql.EscapeName(schema), sql.EscapeName(name))
+	if e.mysqlContext.DropTableIfExists {
+		object.tbSQL = append(object.tbSQL, fmt.Sprintf("DROP %s IF EXISTS %s", objectType, escapedName))
+	}
+
+	var createStatement, timeZone string
+	query := fmt.Sprintf("SHOW CREATE %s %s", objectType, escapedName)
+	err := sql.QueryRowsMap(e.db, query, func(m sql.RowMap) error {
+		createStatement = m.GetString(createColumn)
+		object.sqlMode = m.GetString("sql_mode")
+		timeZone = m.GetString("time_zone")
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if createStatement == "" {
+		return nil, fmt.Errorf("%s: no statement in its %s column: missing privileges to read its definition?", query, createColumn)
+	}
+
+	if objectType == "EVENT" {
+		// The schedule of an event is in the time zone it was created in. The
+		// event is created disabled on the target, which it is replicated to.
+		object.tbSQL = append(object.tbSQL,
+			fmt.Sprintf("SET @@session.time_zone = '%s'", sql.EscapeValue(timeZone)),
+			e.rewriteDefiner(createStatement),
+			fmt.Sprintf("ALTER EVENT %s DISABLE ON SLAVE", escapedName),
+			"SET @@session.time_zone = '+00:00'")
+	} else {
+		object.tbSQL = append(object.tbSQL, e.rewriteDefiner(createStatement))
+	}
+	return object, nil
+}
+
+// rewriteDefiner rewrites the DEFINER of a statement as of DefinerRewrite:
+// to the target of the rule for the definer, or of the rule for "*". The
+// DEFINER is removed if no rule matches or the target is empty, for the
+// user creating the object to be its definer.
+func (e *Extractor) rewriteDefiner(statement string) string {
+	return definerRegexp.ReplaceAllStringFunc(statement, func(clause string) string {
+		m := definerRegexp.FindStringSubmatch(clause)
+		definer := fmt.Sprintf("%s@%s", unquoteDefinerPart(m[1]), unquoteDefinerPart(m[2]))
+		to, ok := e.mysqlContext.DefinerRewrite[definer]
+		if !ok {
+			to = e.mysqlContext.DefinerRewrite["*"]
+		}
+		if to == "" {
+			return ""
+		}
+		i := strings.LastIndex(to, "@")
+		return fmt.Sprintf("DEFINER=%s@%s ", sql.EscapeName(to[:i]), sql.EscapeName(to[i+1:]))
+	})
+}
+
+func unquoteDefinerPart(s string) string {
+	if len(s) >= 2 && (s[0] == '`' || s[0] == '\'') && s[len(s)-1] == s[0] {
+		return strings.Replace(s[1:len(s)-1], "``", "`", -1)
+	}
+	return s
+}
@@ -0,0 +1,223 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package mysql
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/actiontech/dtle/internal/client/driver/mysql/binlog"
+	"github.com/actiontech/dtle/internal/models"
+)
+
+// entriesMsg is an encoded group of entries waiting to be sent
+type entriesMsg struct {
+	txMsg     []byte
+	gno       int64
+	nEntries  int
+	heartbeat *binlog.Heartbeat
+	// drained is closed once the groups queued before are sent, for Drain
+	drained chan struct{}
+}
+
+// sendEntriesMsgs sends the queued groups in order, one at a time, until the
+// queue is closed or the extractor shuts down
+func (e *Extractor) sendEntriesMsgs(queue <-chan *entriesMsg) {
+	defer models.RecoverPanic(e.onPanic)
+	defer e.logger.Debugf("extractor. sendEntriesMsgs goroutine exited")
+	for {
+		var msg *entriesMsg
+		select {
+		case msg = <-queue:
+		case <-e.shutdownCh:
+			return
+		}
+		if msg == nil {
+			return
+		}
+		if msg.drained != nil {
+			close(msg.drained)
+			continue
+		}
+
+		e.logger.Debugf("mysql.extractor: sending gno: %v, n: %v", msg.gno, msg.nEntries)
+		start := time.Now()
+		if err := e.publish(fmt.Sprintf("%s_incr_hete", e.subject), "", msg.txMsg); err != nil {
+			e.onError(TaskStateDead, err)
+			return
+		}
+		e.groupSizer.observeLatency(time.Since(start))
+		e.logger.Debugf("mysql.extractor: send acked gno: %v, n: %v", msg.gno, msg.nEntries)
+		if msg.heartbeat != nil {
+			e.heartbeat.Observe(msg.heartbeat)
+		}
+	}
+}
+
+// groupSizer adapts the size of the groups of entries to the time taken to
+// send them: a group holds about the bytes read from the binlog during the
+// sending of a group, so that the round trips do not bound the throughput on
+// high latency links. The size stays between GroupMaxSize and
+// AdaptiveGroupMaxSize; it is fixed to GroupMaxSize if the latter is 0.
+// The size and the timeout are then scaled by the applier, to keep to the
+// LatencyTargetMs of the job.
+type groupSizer struct {
+	min int
+	max int
+	// latency is the moving average of the nanoseconds to send a group
+	latency int64
+	// rate is the moving average of the bytes read per second
+	rate float64
+	// limit is the current size
+	limit       int64
+	lastGroupAt time.Time
+	// scale is the float64 bits of the scale set by the applier
+	scale uint64
+}
+
+func newGroupSizer(min, max, maxPayload int) *groupSizer {
+	// Leave room for the encoding of the entries in the messages
+	if maxPayload > 0 && max > maxPayload/2 {
+		max = maxPayload / 2
+	}
+	if max < min {
+		max = min
+	}
+	return &groupSizer{
+		min:         min,
+		max:         max,
+		limit:       int64(min),
+		lastGroupAt: time.Now(),
+		scale:       math.Float64bits(1),
+	}
+}
+
+// Limit returns the size a group is sent at
+func (s *groupSizer) Limit() int {
+	limit := int(float64(atomic.LoadInt64(&s.limit)) * s.Scale())
+	if limit < 1 {
+		limit = 1
+	}
+	return limit
+}
+
+// Timeout returns the time a group waits for more entries, GroupTimeout
+// scaled
+func (s *groupSizer) Timeout(groupTimeout time.Duration) time.Duration {
+	timeout := time.Duration(float64(groupTimeout) * s.Scale())
+	if timeout < time.Millisecond {
+		timeout = time.Millisecond
+	}
+	return timeout
+}
+
+// Scale returns the scale set by the applier, 1 by default
+func (s *groupSizer) Scale() float64 {
+	return math.Float64frombits(atomic.LoadUint64(&s.scale))
+}
+
+// observeAck records the scale the applier acknowledged a group with, if
+// it has a LatencyTargetMs
+func (s *groupSizer) observeAck(data []byte) {
+	if len(data) == 0 {
+		return
+	}
+	scale, err := strconv.ParseFloat(string(data), 64)
+	if err != nil || scale <= 0 || scale > 1 {
+		return
+	}
+	atomic.StoreUint64(&s.scale, math.Float64bits(scale))
+}
+
+// stampSourceDelays sets the SourceDelay of the entries of a group about to
+// be sent: the time since they were read, and the time taken to send a
+// group, which also covers the ack coming back
+func (s *groupSizer) stampSourceDelays(binlogEntries []*binlog.BinlogEntry) {
+	now := time.Now().UnixNano()
+	sendLatency := atomic.LoadInt64(&s.latency)
+	for _, binlogEntry := range binlogEntries {
+		if binlogEntry.ReadTime != 0 {
+			binlogEntry.SourceDelay = now - binlogEntry.ReadTime + sendLatency
+		}
+	}
+}
+
+// observeLatency records the time taken to send a group
+func (s *groupSizer) observeLatency(d time.Duration) {
+	old := atomic.LoadInt64(&s.latency)
+	if old == 0 {
+		atomic.StoreInt64(&s.latency, int64(d))
+	} else {
+		atomic.StoreInt64(&s.latency, old+(int64(d)-old)/4)
+	}
+}
+
+// grouped records a group of size bytes queued for sending, and adapts the
+// size of the next groups
+func (s *groupSizer) grouped(size int) {
+	now := time.Now()
+	elapsed := now.Sub(s.lastGroupAt).Seconds()
+	s.lastGroupAt = now
+	if s.max == s.min || elapsed <= 0 {
+		return
+	}
+	rate := float64(size) / elapsed
+	if s.rate == 0 {
+		s.rate = rate
+	} else {
+		s.rate += (rate - s.rate) / 4
+	}
+
+	limit := int64(s.rate * time.Duration(atomic.LoadInt64(&s.latency)).Seconds())
+	if limit < int64(s.min) {
+		limit = int64(s.min)
+	} else if limit > int64(s.max) {
+		limit = int64(s.max)
+	}
+	atomic.StoreInt64(&s.limit, limit)
+}
+
+// addDumper and removeDumper track the dumpers copying a table
+func (e *Extractor) addDumper(d *dumper) {
+	e.dumpersMu.Lock()
+	defer e.dumpersMu.Unlock()
+	e.dumpers = append(e.dumpers, d)
+}
+
+func (e *Extractor) removeDumper(d *dumper) {
+	e.dumpersMu.Lock()
+	defer e.dumpersMu.Unlock()
+	for i := range e.dumpers {
+		if e.dumpers[i] == d {
+			e.dumpers = append(e.dumpers[:i], e.dumpers[i+1:]...)
+			return
+		}
+	}
+}
+
+// queueStats returns the depths of the queues of the extractor. The
+// snapshot_chunks queue is that of the tables being copied.
+func (e *Extractor) queueStats() []models.QueueStat {
+	var chunks models.QueueStat
+	e.dumpersMu.Lock()
+	for _, d := range e.dumpers {
+		chunks.Depth += len(d.resultsChannel)
+		chunks.Capacity += cap(d.resultsChannel)
+	}
+	e.dumpersMu.Unlock()
+
+	queues := []models.QueueStat{{Name: "snapshot_chunks", Depth: chunks.Depth, Capacity: chunks.Capacity}}
+	if e.mysqlContext.ApproveHeterogeneous {
+		queues = append(queues, models.QueueStat{Name: "binlog_entries", Depth: len(e.dataChannel), Capacity: cap(e.dataChannel)})
+	} else {
+		queues = append(queues, models.QueueStat{Name: "binlog_txs", Depth: len(e.binlogChannel), Capacity: cap(e.binlogChannel)})
+	}
+	return append(queues, models.QueueStat{Name: "send_groups", Depth: len(e.sendQueue), Capacity: cap(e.sendQueue)})
+}
@@ -0,0 +1,58 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package mysql
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/actiontech/dtle/internal/client/driver/mysql/sql"
+	"github.com/actiontech/dtle/internal/models"
+)
+
+// rowCountReconciliation keeps the reconciliation of the rows of the
+// snapshot, reported in the stats of the task
+type rowCountReconciliation struct {
+	mutex sync.Mutex
+	last  *models.RowCountReconciliation
+}
+
+func (r *rowCountReconciliation) set(reconciliation *models.RowCountReconciliation) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.last = reconciliation
+}
+
+func (r *rowCountReconciliation) get() *models.RowCountReconciliation {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return r.last
+}
+
+// reconcileRowCounts compares the rows of each table on the target, once
+// the snapshot is loaded, to those the source read, before the binlog is
+// applied. The mismatches are logged and reported in the stats.
+func (a *Applier) reconcileRowCounts(gtid string, tableRows []*models.TableRowCount) {
+	if tableRows == nil {
+		// The source did not count the rows of its snapshot
+		return
+	}
+	for _, t := range tableRows {
+		err := a.db.QueryRow(fmt.Sprintf("SELECT COUNT(*) FROM %s.%s",
+			sql.EscapeName(t.TableSchema), sql.EscapeName(t.TableName))).Scan(&t.TargetRows)
+		if err != nil {
+			t.Error = err.Error()
+		}
+	}
+	reconciliation := models.NewRowCountReconciliation(gtid, tableRows)
+	if reconciliation.Mismatches > 0 {
+		a.logger.Warnf("mysql.applier: %s", reconciliation.Message)
+	} else {
+		a.logger.Printf("mysql.applier: %s", reconciliation.Message)
+	}
+	a.reconciliation.set(reconciliation)
+}
@@ -0,0 +1,82 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package mysql
+
+import (
+	gosql "database/sql"
+	"fmt"
+
+	"github.com/actiontech/dtle/internal/client/driver/mysql/base"
+	"github.com/actiontech/dtle/internal/client/driver/mysql/sql"
+)
+
+// readsFromReplica tells whether the source is a read replica of a primary
+func (e *Extractor) readsFromReplica() bool {
+	return e.mysqlContext.PrimaryConnectionConfig != nil
+}
+
+// initPrimaryConnection connects to the primary of a replica source
+func (e *Extractor) initPrimaryConnection() (err error) {
+	if !e.readsFromReplica() {
+		return nil
+	}
+	if e.primaryDB, err = sql.CreateDB(e.mysqlContext.PrimaryConnectionConfig.GetDBUri()); err != nil {
+		return err
+	}
+	if err := e.primaryDB.Ping(); err != nil {
+		return fmt.Errorf("failed to connect to the primary %s:%d: %v",
+			e.mysqlContext.PrimaryConnectionConfig.Host, e.mysqlContext.PrimaryConnectionConfig.Port, err)
+	}
+	return nil
+}
+
+// currentGtidSet returns the GTID set executed by the source. When reading
+// from a replica, it is that of the primary, once the replica caught up
+// with it.
+func (e *Extractor) currentGtidSet() (string, error) {
+	if !e.readsFromReplica() {
+		coord, err := base.GetSelfBinlogCoordinates(e.db)
+		if err != nil {
+			return "", err
+		}
+		return coord.GtidSet, nil
+	}
+	return e.catchUpWithPrimary()
+}
+
+// catchUpWithPrimary waits for the replica to execute all the transactions
+// the primary has executed so far, and returns their GTID set
+func (e *Extractor) catchUpWithPrimary() (string, error) {
+	coord, err := base.GetSelfBinlogCoordinates(e.primaryDB)
+	if err != nil {
+		return "", err
+	}
+	if err := e.waitForReplica(coord.GtidSet); err != nil {
+		return "", err
+	}
+	return coord.GtidSet, nil
+}
+
+// waitForReplica waits for the replica to execute a GTID set, so that the
+// binlog can be read from it from that point
+func (e *Extractor) waitForReplica(gtidSet string) error {
+	if !e.readsFromReplica() || gtidSet == "" {
+		return nil
+	}
+	e.logger.Printf("mysql.extractor: waiting up to %ds for the replica to execute %v",
+		e.mysqlContext.ReplicaWaitTimeout, gtidSet)
+
+	var timedOut gosql.NullInt64
+	query := "SELECT WAIT_FOR_EXECUTED_GTID_SET(?, ?)"
+	if err := e.db.QueryRow(query, gtidSet, e.mysqlContext.ReplicaWaitTimeout).Scan(&timedOut); err != nil {
+		return err
+	}
+	if !timedOut.Valid || timedOut.Int64 != 0 {
+		return fmt.Errorf("the replica did not execute %v within %ds", gtidSet, e.mysqlContext.ReplicaWaitTimeout)
+	}
+	return nil
+}
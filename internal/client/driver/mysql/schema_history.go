@@ -0,0 +1,114 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package mysql
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/actiontech/dtle/internal/client/driver/mysql/binlog"
+	"github.com/actiontech/dtle/internal/models"
+)
+
+// schemaHistoryLimit is the number of versions kept, beyond which the
+// oldest versions of the tables having later ones are dropped
+const schemaHistoryLimit = 1000
+
+// schemaHistory is the versions of the tables the applier applied, kept
+// with the Gtid by the job
+type schemaHistory struct {
+	mutex    sync.Mutex
+	versions []*models.SchemaVersion
+}
+
+func (h *schemaHistory) add(versions ...*models.SchemaVersion) {
+	if len(versions) == 0 {
+		return
+	}
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	h.versions = append(h.versions, versions...)
+	for len(h.versions) > schemaHistoryLimit {
+		i := h.supersededVersion()
+		if i < 0 {
+			break
+		}
+		h.versions = append(h.versions[:i], h.versions[i+1:]...)
+	}
+}
+
+// supersededVersion returns the index of the oldest version having a later
+// version of the same table, -1 if there is none
+func (h *schemaHistory) supersededVersion() int {
+	for i, v := range h.versions {
+		for _, later := range h.versions[i+1:] {
+			if later.TableSchema == v.TableSchema && later.TableName == v.TableName {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// list returns the versions, the oldest first
+func (h *schemaHistory) list() []*models.SchemaVersion {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	versions := make([]*models.SchemaVersion, len(h.versions))
+	copy(versions, h.versions)
+	return versions
+}
+
+// schemaVersions returns the versions of the tables created or altered by
+// a source transaction
+func schemaVersions(binlogEntry *binlog.BinlogEntry) []*models.SchemaVersion {
+	var versions []*models.SchemaVersion
+	for i := range binlogEntry.Events {
+		event := &binlogEntry.Events[i]
+		if event.DML != binlog.NotDML || event.TableColumns == nil {
+			continue
+		}
+		schema := event.DatabaseName
+		if schema == "" {
+			schema = event.CurrentSchema
+		}
+		versions = append(versions, &models.SchemaVersion{
+			Gtid:        fmt.Sprintf("%s:%d", binlogEntry.Coordinates.GetSid(), binlogEntry.Coordinates.GNO),
+			TableSchema: schema,
+			TableName:   event.TableName,
+			Query:       event.Query,
+			Columns:     event.TableColumns,
+			Time:        time.Now().UnixNano(),
+		})
+	}
+	return versions
+}
+
+// applySchemaHistory makes the binlog be decoded from the Gtid with the
+// last versions of the tables the job applied, rather than with the tables
+// as the source has them now, which may have been altered since
+func (e *Extractor) applySchemaHistory() {
+	history := e.mysqlContext.SchemaHistory
+	if len(history) == 0 {
+		return
+	}
+	for _, doDb := range e.replicateDoDb {
+		for _, doTb := range doDb.Tables {
+			for i := len(history) - 1; i >= 0; i-- {
+				v := history[i]
+				if v.TableSchema != doTb.TableSchema || v.TableName != doTb.TableName || v.Columns == nil {
+					continue
+				}
+				e.logger.Printf("mysql.extractor: decoding %s.%s with its version of gtid %s",
+					doTb.TableSchema, doTb.TableName, v.Gtid)
+				doTb.OriginalTableColumns = v.Columns
+				break
+			}
+		}
+	}
+}
@@ -0,0 +1,108 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package mysql
+
+import (
+	"crypto/sha1"
+	gosql "database/sql"
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	"github.com/satori/go.uuid"
+
+	"github.com/actiontech/dtle/internal/g"
+	"github.com/actiontech/dtle/internal/models"
+)
+
+// DDLChecksum returns the checksum a DDL is matched by, that of its text
+// with the whitespace collapsed and without the trailing semicolons
+func DDLChecksum(query string) string {
+	query = strings.TrimRight(strings.TrimSpace(query), "; \t\r\n")
+	sum := sha1.Sum([]byte(strings.Join(strings.Fields(query), " ")))
+	return hex.EncodeToString(sum[:])
+}
+
+// createSkippedDDLTable creates the table of the DDLs applied manually on a
+// target, if missing
+func createSkippedDDLTable(db *gosql.DB) error {
+	if _, err := db.Exec(fmt.Sprintf("CREATE DATABASE IF NOT EXISTS %v", g.DtleSchemaName)); err != nil {
+		return err
+	}
+	_, err := db.Exec(fmt.Sprintf(`
+			CREATE TABLE IF NOT EXISTS %v.%v (
+				job_uuid binary(16) NOT NULL COMMENT 'unique identifier of job',
+				checksum char(40) NOT NULL COMMENT 'checksum of the DDL',
+				query longtext NOT NULL COMMENT 'DDL applied manually',
+				declared_at timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP,
+				PRIMARY KEY (job_uuid, checksum)
+			)
+		`, g.DtleSchemaName, g.SkippedDDLTableV1))
+	return err
+}
+
+// SkipDDL records on the target of a job a DDL applied manually there,
+// which the job skips once it replicates it, and returns its checksum
+func SkipDDL(target *gosql.DB, jobID string, query string) (string, error) {
+	jobUUID, err := uuid.FromString(jobID)
+	if err != nil {
+		return "", err
+	}
+	if err := createSkippedDDLTable(target); err != nil {
+		return "", err
+	}
+	checksum := DDLChecksum(query)
+	_, err = target.Exec(fmt.Sprintf("REPLACE INTO %v.%v (job_uuid, checksum, query) VALUES (?, ?, ?)",
+		g.DtleSchemaName, g.SkippedDDLTableV1), jobUUID.Bytes(), checksum, query)
+	if err != nil {
+		return "", err
+	}
+	return checksum, nil
+}
+
+// SkippedDDLs returns the DDLs recorded on the target of a job that the job
+// has yet to skip
+func SkippedDDLs(target *gosql.DB, jobID string) ([]*models.SkippedDDL, error) {
+	jobUUID, err := uuid.FromString(jobID)
+	if err != nil {
+		return nil, err
+	}
+	if err := createSkippedDDLTable(target); err != nil {
+		return nil, err
+	}
+	rows, err := target.Query(fmt.Sprintf("SELECT checksum, query, declared_at FROM %v.%v WHERE job_uuid = ? ORDER BY declared_at",
+		g.DtleSchemaName, g.SkippedDDLTableV1), jobUUID.Bytes())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var ddls []*models.SkippedDDL
+	for rows.Next() {
+		var ddl models.SkippedDDL
+		if err := rows.Scan(&ddl.Checksum, &ddl.Query, &ddl.DeclaredAt); err != nil {
+			return nil, err
+		}
+		ddls = append(ddls, &ddl)
+	}
+	return ddls, rows.Err()
+}
+
+// skipsDeclaredDDL tells whether a DDL was applied manually on the target,
+// in which case it is removed from the DDLs to skip in the transaction it is
+// skipped in
+func (a *Applier) skipsDeclaredDDL(tx *gosql.Tx, query string) (bool, error) {
+	r, err := tx.Exec(fmt.Sprintf("DELETE FROM %v.%v WHERE job_uuid = ? AND checksum = ?",
+		g.DtleSchemaName, g.SkippedDDLTableV1), a.subjectUUID.Bytes(), DDLChecksum(query))
+	if err != nil {
+		return false, err
+	}
+	if n, err := r.RowsAffected(); err != nil || n == 0 {
+		return false, err
+	}
+	a.logger.Printf("mysql.applier: skipping a DDL applied manually on the target: %s", query)
+	return true, nil
+}
@@ -0,0 +1,44 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package mysql
+
+import (
+	"testing"
+)
+
+func TestDDLChecksum(t *testing.T) {
+	const ddl = "ALTER TABLE t ADD COLUMN c int"
+	want := DDLChecksum(ddl)
+	if len(want) != 40 {
+		t.Fatalf("DDLChecksum(%q) = %q, want a SHA-1 in hex", ddl, want)
+	}
+
+	for _, query := range []string{
+		"ALTER TABLE t ADD COLUMN c int;",
+		"ALTER TABLE t ADD COLUMN c int ;;",
+		"ALTER TABLE t ADD COLUMN c int;\n",
+		"  ALTER TABLE t ADD COLUMN c int  ",
+		"ALTER TABLE t\nADD COLUMN c int",
+		"ALTER TABLE t\r\n\tADD COLUMN c int;\r\n",
+		"ALTER   TABLE t ADD\t\tCOLUMN  c int",
+	} {
+		if got := DDLChecksum(query); got != want {
+			t.Errorf("DDLChecksum(%q) = %s, want that of %q %s", query, got, ddl, want)
+		}
+	}
+
+	for _, query := range []string{
+		"ALTER TABLE t ADD COLUMN c bigint",
+		"alter table t add column c int",
+		"ALTER TABLE t ADD COLUMN c int; DROP TABLE t",
+		"ALTER TABLE t ADD COLUMNc int",
+	} {
+		if got := DDLChecksum(query); got == want {
+			t.Errorf("DDLChecksum(%q) is that of %q", query, ddl)
+		}
+	}
+}
@@ -0,0 +1,76 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package mysql
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/actiontech/dtle/internal/client/driver/mysql/binlog"
+	"github.com/actiontech/dtle/internal/models"
+	"github.com/actiontech/dtle/utils"
+)
+
+const (
+	// maxSlowApplies is the number of slow statements kept, the oldest
+	// being dropped
+	maxSlowApplies = 100
+	// slowApplyQueryLimit truncates the statements kept
+	slowApplyQueryLimit = 1024
+)
+
+// slowApplyLog keeps the last statements that took longer than the
+// SlowApplyThreshold to apply
+type slowApplyLog struct {
+	mutex   sync.Mutex
+	applies []*models.SlowApply
+}
+
+func (l *slowApplyLog) add(apply *models.SlowApply) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	if len(l.applies) >= maxSlowApplies {
+		l.applies = append(l.applies[:0], l.applies[1:]...)
+	}
+	l.applies = append(l.applies, apply)
+}
+
+// list returns the statements kept, the oldest first
+func (l *slowApplyLog) list() []*models.SlowApply {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	applies := make([]*models.SlowApply, len(l.applies))
+	copy(applies, l.applies)
+	return applies
+}
+
+// checkSlowApply keeps a statement of a transaction if it took longer than
+// the SlowApplyThreshold to apply. The statement text is only built then.
+func (a *Applier) checkSlowApply(binlogEntry *binlog.BinlogEntry, schema string, table string,
+	applyTime time.Duration, queryText func() (string, error)) {
+
+	threshold := a.mysqlContext.SlowApplyThreshold
+	if threshold <= 0 || applyTime < time.Duration(threshold)*time.Millisecond {
+		return
+	}
+	query, err := queryText()
+	if err != nil {
+		query = fmt.Sprintf("-- %v", err)
+	}
+	gtid := fmt.Sprintf("%s:%d", binlogEntry.Coordinates.GetSid(), binlogEntry.Coordinates.GNO)
+	a.logger.Warnf("mysql.applier: slow apply of %v ms: gtid: %s, table: %s.%s",
+		applyTime.Nanoseconds()/int64(time.Millisecond), gtid, schema, table)
+	a.slowApplies.add(&models.SlowApply{
+		Time:        time.Now().UnixNano(),
+		Gtid:        gtid,
+		TableSchema: schema,
+		TableName:   table,
+		Query:       utils.StrLim(query, slowApplyQueryLimit),
+		ApplyMillis: applyTime.Nanoseconds() / int64(time.Millisecond),
+	})
+}
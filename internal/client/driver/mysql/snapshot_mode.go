@@ -0,0 +1,193 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package mysql
+
+import (
+	gosql "database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/actiontech/dtle/internal/client/driver/mysql/base"
+	"github.com/actiontech/dtle/internal/client/driver/mysql/sql"
+	"github.com/actiontech/dtle/internal/config"
+)
+
+// validateSnapshotMode checks SnapshotMode before the copy starts
+func (e *Extractor) validateSnapshotMode() error {
+	switch e.mysqlContext.SnapshotMode {
+	case "", config.SnapshotModeConsistent, config.SnapshotModePerTable, config.SnapshotModeLockTables:
+		return nil
+	default:
+		return fmt.Errorf("unknown SnapshotMode %q: must be %q, %q or %q", e.mysqlContext.SnapshotMode,
+			config.SnapshotModeConsistent, config.SnapshotModePerTable, config.SnapshotModeLockTables)
+	}
+}
+
+// startSnapshot opens the transactions of the n connections the tables are
+// copied with, as of SnapshotMode, all reading the same data, and returns
+// them with the binlog coordinates they read at
+func (e *Extractor) startSnapshot(n int) ([]*gosql.Tx, *base.BinlogCoordinatesX, error) {
+	if e.mysqlContext.SnapshotMode == config.SnapshotModeLockTables {
+		return e.lockTablesSnapshot(n)
+	}
+	return e.consistentSnapshot(n)
+}
+
+// endSnapshot ends the transactions opened by startSnapshot
+func (e *Extractor) endSnapshot(txs []*gosql.Tx) error {
+	if e.mysqlContext.SnapshotMode == config.SnapshotModeLockTables {
+		query := "UNLOCK TABLES"
+		if _, err := txs[0].Exec(query); err != nil {
+			e.logger.Errorf("mysql.extractor: exec %+v, error: %v", query, err)
+			rollbackAll(txs)
+			return err
+		}
+	}
+	var firstErr error
+	for _, tx := range txs {
+		if err := tx.Commit(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+func rollbackAll(txs []*gosql.Tx) {
+	for _, tx := range txs {
+		tx.Rollback()
+	}
+}
+
+// consistentSnapshot starts n transactions with a consistent snapshot,
+// retrying until no transaction commits while they start, so that the
+// snapshots are the same, that of the GTID set it reads
+func (e *Extractor) consistentSnapshot(n int) ([]*gosql.Tx, *base.BinlogCoordinatesX, error) {
+	gtidMatchRound := 0
+	delayBetweenRetries := 200 * time.Millisecond
+	for {
+		gtidMatchRound += 1
+
+		// 1
+		rows1, err := e.singletonDB.Query("show master status")
+		if err != nil {
+			e.logger.Errorf("mysql.extractor: get gtid, round: %v, phase 1, err: %v", gtidMatchRound, err)
+			return nil, nil, err
+		}
+
+		e.testStub1()
+
+		// 2
+		// TODO it seems that two 'start transaction' will be sent.
+		// https://github.com/golang/go/issues/19981
+		var txs []*gosql.Tx
+		for i := 0; i < n; i++ {
+			realTx, err := e.singletonDB.Begin()
+			if err != nil {
+				rows1.Close()
+				rollbackAll(txs)
+				return nil, nil, err
+			}
+			txs = append(txs, realTx)
+			query := "START TRANSACTION WITH CONSISTENT SNAPSHOT"
+			_, err = realTx.Exec(query)
+			if err != nil {
+				e.logger.Printf("[ERR] mysql.extractor: exec %+v, error: %v", query, err)
+				rows1.Close()
+				rollbackAll(txs)
+				return nil, nil, err
+			}
+		}
+
+		e.testStub1()
+
+		// 3
+		rows2, err := txs[n-1].Query("show master status")
+		if err != nil {
+			rows1.Close()
+			rollbackAll(txs)
+			return nil, nil, err
+		}
+
+		// 4
+		binlogCoordinates1, err := base.ParseBinlogCoordinatesFromRows(rows1)
+		if err != nil {
+			rollbackAll(txs)
+			return nil, nil, err
+		}
+		binlogCoordinates2, err := base.ParseBinlogCoordinatesFromRows(rows2)
+		if err != nil {
+			rollbackAll(txs)
+			return nil, nil, err
+		}
+		e.logger.Debugf("mysql.extractor: binlog coordinates 1: %+v", binlogCoordinates1)
+		e.logger.Debugf("mysql.extractor: binlog coordinates 2: %+v", binlogCoordinates2)
+
+		if binlogCoordinates1.GtidSet == binlogCoordinates2.GtidSet {
+			e.logger.Infof("Got gtid after %v rounds", gtidMatchRound)
+			return txs, binlogCoordinates2, nil
+		}
+
+		e.logger.Warningf("Failed got a consistenct TX with GTID in %v rounds. Will retry.", gtidMatchRound)
+		for _, tx := range txs {
+			if err := tx.Rollback(); err != nil {
+				return nil, nil, err
+			}
+		}
+		time.Sleep(delayBetweenRetries)
+	}
+}
+
+// lockTablesSnapshot locks the tables to copy for read, for the engines
+// without consistent snapshots (e.g. MyISAM). Writes to the tables wait
+// until the copy ends, but no global read lock is taken. The connections
+// other than the locking one read the tables while they are locked.
+func (e *Extractor) lockTablesSnapshot(n int) ([]*gosql.Tx, *base.BinlogCoordinatesX, error) {
+	var tables []string
+	for _, db := range e.replicateDoDb {
+		for _, tb := range db.Tables {
+			tables = append(tables, fmt.Sprintf("%s.%s READ", sql.EscapeName(tb.TableSchema), sql.EscapeName(tb.TableName)))
+		}
+	}
+
+	realTx, err := e.singletonDB.Begin()
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(tables) > 0 {
+		// LOCK TABLES commits the transaction begun, and the reads after it
+		// start another one on the same connection
+		query := fmt.Sprintf("LOCK TABLES %s", strings.Join(tables, ", "))
+		if _, err := realTx.Exec(query); err != nil {
+			e.logger.Errorf("mysql.extractor: exec %+v, error: %v", query, err)
+			realTx.Rollback()
+			return nil, nil, err
+		}
+	}
+
+	rows, err := realTx.Query("show master status")
+	if err != nil {
+		realTx.Rollback()
+		return nil, nil, err
+	}
+	binlogCoordinates, err := base.ParseBinlogCoordinatesFromRows(rows)
+	if err != nil {
+		realTx.Rollback()
+		return nil, nil, err
+	}
+	e.logger.Printf("mysql.extractor: locked %d tables for read", len(tables))
+	txs := []*gosql.Tx{realTx}
+	for len(txs) < n {
+		tx, err := e.singletonDB.Begin()
+		if err != nil {
+			rollbackAll(txs)
+			return nil, nil, err
+		}
+		txs = append(txs, tx)
+	}
+	return txs, binlogCoordinates, nil
+}
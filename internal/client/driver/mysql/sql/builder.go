@@ -77,15 +77,18 @@ func EscapeValue(colValue string) string {
 }
 
 func buildColumnsPreparedValues(columns *umconf.ColumnList) []string {
-	values := make([]string, columns.Len(), columns.Len())
-	for i, column := range columns.ColumnList() {
+	values := make([]string, 0, columns.Len())
+	for _, column := range columns.ColumnList() {
+		if column.IsGenerated {
+			continue
+		}
 		var token string
 		if column.TimezoneConversion != nil {
-			token = fmt.Sprintf("convert_tz(?, '%s', '%s')", column.TimezoneConversion.ToTimezone, "+00:00")
+			token = fmt.Sprintf("convert_tz(?, '%s', @@session.time_zone)", column.TimezoneConversion.ToTimezone)
 		} else {
 			token = "?"
 		}
-		values[i] = token
+		values = append(values, token)
 	}
 	return values
 }
@@ -108,14 +111,25 @@ func BuildValueComparison(column string, value string, comparisonSign ValueCompa
 }
 
 func BuildSetPreparedClause(columns *umconf.ColumnList) (result string, err error) {
+	return buildSetPreparedClause(columns, nil)
+}
+
+// buildSetPreparedClause builds the set clause of the columns, setting those
+// in exprs to their expression instead of a placeholder
+func buildSetPreparedClause(columns *umconf.ColumnList, exprs map[string]string) (result string, err error) {
 	if columns.Len() == 0 {
 		return "", fmt.Errorf("Got 0 columns in BuildSetPreparedClause")
 	}
 	setTokens := []string{}
 	for _, column := range columns.ColumnList() {
+		if column.IsGenerated {
+			continue
+		}
 		var setToken string
-		if column.TimezoneConversion != nil {
-			setToken = fmt.Sprintf("%s=convert_tz(?, '%s', '%s')", EscapeName(column.Name), column.TimezoneConversion.ToTimezone, "+00:00")
+		if expr, ok := exprs[column.Name]; ok {
+			setToken = fmt.Sprintf("%s=%s", EscapeName(column.Name), expr)
+		} else if column.TimezoneConversion != nil {
+			setToken = fmt.Sprintf("%s=convert_tz(?, '%s', @@session.time_zone)", EscapeName(column.Name), column.TimezoneConversion.ToTimezone)
 		} else {
 			setToken = fmt.Sprintf("%s=?", EscapeName(column.Name))
 		}
@@ -129,6 +143,47 @@ func BuildDMLDeleteQuery(databaseName, tableName string, tableColumns *umconf.Co
 		return result, columnArgs, fmt.Errorf("args count differs from table column count in BuildDMLDeleteQuery %v, %v",
 			len(args), tableColumns.Len())
 	}
+	where, columnArgs, err := buildDMLDeleteWhere(tableColumns, args)
+	if err != nil {
+		return result, columnArgs, err
+	}
+	result = fmt.Sprintf(`
+			delete
+				from
+					%s.%s
+				where
+					%s
+		`, EscapeName(databaseName), EscapeName(tableName), where,
+	)
+	return result, columnArgs, nil
+}
+
+// BuildDMLSoftDeleteQuery builds the update marking deleted the row a
+// delete would remove, by setting column to value, an SQL expression
+func BuildDMLSoftDeleteQuery(databaseName, tableName string, tableColumns *umconf.ColumnList, column, value string, args []*interface{}) (result string, columnArgs []interface{}, err error) {
+	if len(args) < tableColumns.Len() {
+		return result, columnArgs, fmt.Errorf("args count differs from table column count in BuildDMLSoftDeleteQuery %v, %v",
+			len(args), tableColumns.Len())
+	}
+	where, columnArgs, err := buildDMLDeleteWhere(tableColumns, args)
+	if err != nil {
+		return result, columnArgs, err
+	}
+	result = fmt.Sprintf(`
+			update
+					%s.%s
+				set
+					%s=%s
+				where
+					%s
+		`, EscapeName(databaseName), EscapeName(tableName), EscapeName(column), value, where,
+	)
+	return result, columnArgs, nil
+}
+
+// buildDMLDeleteWhere builds the condition matching the row a delete
+// removes, by its primary key if the table has one
+func buildDMLDeleteWhere(tableColumns *umconf.ColumnList, args []*interface{}) (result string, columnArgs []interface{}, err error) {
 	comparisons := []string{}
 	uniqueKeyComparisons := []string{}
 	uniqueKeyArgs := make([]interface{}, 0)
@@ -174,21 +229,7 @@ func BuildDMLDeleteQuery(databaseName, tableName string, tableColumns *umconf.Co
 	if len(uniqueKeyArgs) > 0 {
 		columnArgs = uniqueKeyArgs
 	}
-	databaseName = EscapeName(databaseName)
-	tableName = EscapeName(tableName)
-	if err != nil {
-		return result, columnArgs, err
-	}
-	result = fmt.Sprintf(`
-			delete
-				from
-					%s.%s
-				where
-					%s
-		`, databaseName, tableName,
-		fmt.Sprintf("(%s)", strings.Join(comparisons, " and ")),
-	)
-	return result, columnArgs, nil
+	return fmt.Sprintf("(%s)", strings.Join(comparisons, " and ")), columnArgs, nil
 }
 
 func BuildDMLInsertQuery(databaseName, tableName string, tableColumns, sharedColumns, mappedSharedColumns *umconf.ColumnList, args []*interface{}) (result string, sharedArgs []interface{}, err error) {
@@ -206,7 +247,13 @@ func BuildDMLInsertQuery(databaseName, tableName string, tableColumns, sharedCol
 	databaseName = EscapeName(databaseName)
 	tableName = EscapeName(tableName)
 
+	// Generated columns are computed by the target
+	var insertedNames []string
 	for _, column := range tableColumns.ColumnList() {
+		if column.IsGenerated {
+			continue
+		}
+		insertedNames = append(insertedNames, column.Name)
 		tableOrdinal := tableColumns.Ordinals[column.Name]
 		if *args[tableOrdinal] == nil {
 			sharedArgs = append(sharedArgs, *args[tableOrdinal])
@@ -216,7 +263,7 @@ func BuildDMLInsertQuery(databaseName, tableName string, tableColumns, sharedCol
 		}
 	}
 
-	mappedSharedColumnNames := duplicateNames(tableColumns.Names())
+	mappedSharedColumnNames := duplicateNames(insertedNames)
 	for i := range mappedSharedColumnNames {
 		mappedSharedColumnNames[i] = EscapeName(mappedSharedColumnNames[i])
 	}
@@ -235,6 +282,46 @@ func BuildDMLInsertQuery(databaseName, tableName string, tableColumns, sharedCol
 	return result, sharedArgs, nil
 }
 
+// BuildDMLHistoryQuery builds the insert of a row image into the history
+// table of a table, followed by the values of metaColumns, which the
+// caller appends to the returned args
+func BuildDMLHistoryQuery(databaseName, historyTableName string, tableColumns *umconf.ColumnList, metaColumns []string, args []*interface{}) (result string, sharedArgs []interface{}, err error) {
+	if len(args) < tableColumns.Len() {
+		return result, sharedArgs, fmt.Errorf("args count differs from table column count in BuildDMLHistoryQuery %v, %v",
+			len(args), tableColumns.Len())
+	}
+
+	var names []string
+	for _, column := range tableColumns.ColumnList() {
+		if column.IsGenerated {
+			continue
+		}
+		names = append(names, EscapeName(column.Name))
+		if arg := *args[tableColumns.Ordinals[column.Name]]; arg == nil {
+			sharedArgs = append(sharedArgs, nil)
+		} else {
+			sharedArgs = append(sharedArgs, column.ConvertArg(arg))
+		}
+	}
+	preparedValues := buildColumnsPreparedValues(tableColumns)
+	for _, name := range metaColumns {
+		names = append(names, EscapeName(name))
+		preparedValues = append(preparedValues, "?")
+	}
+
+	result = fmt.Sprintf(`
+			insert into
+				%s.%s
+					(%s)
+				values
+					(%s)
+		`, EscapeName(databaseName), EscapeName(historyTableName),
+		strings.Join(names, ", "),
+		strings.Join(preparedValues, ", "),
+	)
+	return result, sharedArgs, nil
+}
+
 func BuildDMLUpdateQuery(databaseName, tableName string, tableColumns, sharedColumns, mappedSharedColumns, uniqueKeyColumns *umconf.ColumnList, valueArgs, whereArgs []*interface{}) (result string, sharedArgs, columnArgs []interface{}, err error) {
 	if len(valueArgs) < tableColumns.Len() {
 		return result, sharedArgs, columnArgs, fmt.Errorf("value args count differs from table column count in BuildDMLUpdateQuery %v, %v",
@@ -253,9 +340,18 @@ func BuildDMLUpdateQuery(databaseName, tableName string, tableColumns, sharedCol
 	databaseName = EscapeName(databaseName)
 	tableName = EscapeName(tableName)
 
+	// JSON columns partially updated are set by applying their patch
+	patchExprs := make(map[string]string)
 	for _, column := range tableColumns.ColumnList() {
+		if column.IsGenerated {
+			continue
+		}
 		tableOrdinal := tableColumns.Ordinals[column.Name]
-		if *valueArgs[tableOrdinal] == nil || *valueArgs[tableOrdinal] == "NULL" ||
+		if patch, ok := (*valueArgs[tableOrdinal]).(umconf.JSONPatch); ok {
+			expr, args := patch.SQLExpression(EscapeName(column.Name))
+			patchExprs[column.Name] = expr
+			sharedArgs = append(sharedArgs, args...)
+		} else if *valueArgs[tableOrdinal] == nil || *valueArgs[tableOrdinal] == "NULL" ||
 			fmt.Sprintf("%v", *valueArgs[tableOrdinal]) == "" {
 			sharedArgs = append(sharedArgs, *valueArgs[tableOrdinal])
 		} else {
@@ -309,7 +405,7 @@ func BuildDMLUpdateQuery(databaseName, tableName string, tableColumns, sharedCol
 	if len(uniqueKeyArgs) > 0 {
 		columnArgs = uniqueKeyArgs
 	}
-	setClause, err := BuildSetPreparedClause(mappedSharedColumns)
+	setClause, err := buildSetPreparedClause(mappedSharedColumns, patchExprs)
 
 	result = fmt.Sprintf(`
  			update
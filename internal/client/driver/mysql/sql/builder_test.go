@@ -13,6 +13,7 @@ import (
 	"regexp"
 	"strings"
 
+	umconf "github.com/actiontech/dtle/internal/config/mysql"
 	test "github.com/outbrain/golib/tests"
 )
 
@@ -27,6 +28,20 @@ func normalizeQuery(name string) string {
 	return name
 }
 
+// newColumnList returns the columns of names, those in keys being of the
+// primary key
+func newColumnList(names []string, keys ...string) *umconf.ColumnList {
+	columns := umconf.NewColumnList(umconf.NewColumns(names))
+	for _, key := range keys {
+		columns.GetColumn(key).Key = "PRI"
+	}
+	return columns
+}
+
+func newArgs(values ...interface{}) []*interface{} {
+	return umconf.ToColumnValues(values).GetAbstractValues()
+}
+
 func TestEscapeName(t *testing.T) {
 	names := []string{"my_table", `"my_table"`, "`my_table`"}
 	for _, name := range names {
@@ -37,215 +52,36 @@ func TestEscapeName(t *testing.T) {
 
 func TestBuildSetPreparedClause(t *testing.T) {
 	{
-		columns := NewColumnList([]string{"c1"})
+		columns := newColumnList([]string{"c1"})
 		clause, err := BuildSetPreparedClause(columns)
 		test.S(t).ExpectNil(err)
 		test.S(t).ExpectEquals(clause, "`c1`=?")
 	}
 	{
-		columns := NewColumnList([]string{"c1", "c2"})
+		columns := newColumnList([]string{"c1", "c2"})
 		clause, err := BuildSetPreparedClause(columns)
 		test.S(t).ExpectNil(err)
 		test.S(t).ExpectEquals(clause, "`c1`=?, `c2`=?")
 	}
 	{
-		columns := NewColumnList([]string{})
+		columns := newColumnList([]string{})
 		_, err := BuildSetPreparedClause(columns)
 		test.S(t).ExpectNotNil(err)
 	}
 }
 
-func TestBuildRangeInsertQuery(t *testing.T) {
-	databaseName := "mydb"
-	originalTableName := "tbl"
-	ghostTableName := "ghost"
-	sharedColumns := []string{"id", "name", "position"}
-	{
-		uniqueKey := "PRIMARY"
-		uniqueKeyColumns := NewColumnList([]string{"id"})
-		rangeStartValues := []string{"@v1s"}
-		rangeEndValues := []string{"@v1e"}
-		rangeStartArgs := []interface{}{3}
-		rangeEndArgs := []interface{}{103}
-
-		query, explodedArgs, err := BuildRangeInsertQuery(databaseName, originalTableName, ghostTableName, sharedColumns, sharedColumns, uniqueKey, uniqueKeyColumns, rangeStartValues, rangeEndValues, rangeStartArgs, rangeEndArgs, true, false)
-		test.S(t).ExpectNil(err)
-		expected := `
-				insert /* udup mydb.tbl */ ignore into mydb.ghost (id, name, position)
-				(select id, name, position from mydb.tbl force index (PRIMARY)
-					where (((id > @v1s) or ((id = @v1s))) and ((id < @v1e) or ((id = @v1e))))
-				)
-		`
-		test.S(t).ExpectEquals(normalizeQuery(query), normalizeQuery(expected))
-		test.S(t).ExpectTrue(reflect.DeepEqual(explodedArgs, []interface{}{3, 3, 103, 103}))
-	}
-	{
-		uniqueKey := "name_position_uidx"
-		uniqueKeyColumns := NewColumnList([]string{"name", "position"})
-		rangeStartValues := []string{"@v1s", "@v2s"}
-		rangeEndValues := []string{"@v1e", "@v2e"}
-		rangeStartArgs := []interface{}{3, 17}
-		rangeEndArgs := []interface{}{103, 117}
-
-		query, explodedArgs, err := BuildRangeInsertQuery(databaseName, originalTableName, ghostTableName, sharedColumns, sharedColumns, uniqueKey, uniqueKeyColumns, rangeStartValues, rangeEndValues, rangeStartArgs, rangeEndArgs, true, false)
-		test.S(t).ExpectNil(err)
-		expected := `
-				insert /* udup mydb.tbl */ ignore into mydb.ghost (id, name, position)
-				(select id, name, position from mydb.tbl force index (name_position_uidx)
-				  where (((name > @v1s) or (((name = @v1s)) AND (position > @v2s)) or ((name = @v1s) and (position = @v2s))) and ((name < @v1e) or (((name = @v1e)) AND (position < @v2e)) or ((name = @v1e) and (position = @v2e))))
-				)
-		`
-		test.S(t).ExpectEquals(normalizeQuery(query), normalizeQuery(expected))
-		test.S(t).ExpectTrue(reflect.DeepEqual(explodedArgs, []interface{}{3, 3, 17, 3, 17, 103, 103, 117, 103, 117}))
-	}
-}
-
-func TestBuildRangeInsertQueryRenameMap(t *testing.T) {
-	databaseName := "mydb"
-	originalTableName := "tbl"
-	ghostTableName := "ghost"
-	sharedColumns := []string{"id", "name", "position"}
-	mappedSharedColumns := []string{"id", "name", "location"}
-	{
-		uniqueKey := "PRIMARY"
-		uniqueKeyColumns := NewColumnList([]string{"id"})
-		rangeStartValues := []string{"@v1s"}
-		rangeEndValues := []string{"@v1e"}
-		rangeStartArgs := []interface{}{3}
-		rangeEndArgs := []interface{}{103}
-
-		query, explodedArgs, err := BuildRangeInsertQuery(databaseName, originalTableName, ghostTableName, sharedColumns, mappedSharedColumns, uniqueKey, uniqueKeyColumns, rangeStartValues, rangeEndValues, rangeStartArgs, rangeEndArgs, true, false)
-		test.S(t).ExpectNil(err)
-		expected := `
-				insert /* udup mydb.tbl */ ignore into mydb.ghost (id, name, location)
-				(select id, name, position from mydb.tbl force index (PRIMARY)
-					where (((id > @v1s) or ((id = @v1s))) and ((id < @v1e) or ((id = @v1e))))
-				)
-		`
-		test.S(t).ExpectEquals(normalizeQuery(query), normalizeQuery(expected))
-		test.S(t).ExpectTrue(reflect.DeepEqual(explodedArgs, []interface{}{3, 3, 103, 103}))
-	}
-	{
-		uniqueKey := "name_position_uidx"
-		uniqueKeyColumns := NewColumnList([]string{"name", "position"})
-		rangeStartValues := []string{"@v1s", "@v2s"}
-		rangeEndValues := []string{"@v1e", "@v2e"}
-		rangeStartArgs := []interface{}{3, 17}
-		rangeEndArgs := []interface{}{103, 117}
-
-		query, explodedArgs, err := BuildRangeInsertQuery(databaseName, originalTableName, ghostTableName, sharedColumns, mappedSharedColumns, uniqueKey, uniqueKeyColumns, rangeStartValues, rangeEndValues, rangeStartArgs, rangeEndArgs, true, false)
-		test.S(t).ExpectNil(err)
-		expected := `
-				insert /* udup mydb.tbl */ ignore into mydb.ghost (id, name, location)
-				(select id, name, position from mydb.tbl force index (name_position_uidx)
-				  where (((name > @v1s) or (((name = @v1s)) AND (position > @v2s)) or ((name = @v1s) and (position = @v2s))) and ((name < @v1e) or (((name = @v1e)) AND (position < @v2e)) or ((name = @v1e) and (position = @v2e))))
-				)
-		`
-		test.S(t).ExpectEquals(normalizeQuery(query), normalizeQuery(expected))
-		test.S(t).ExpectTrue(reflect.DeepEqual(explodedArgs, []interface{}{3, 3, 17, 3, 17, 103, 103, 117, 103, 117}))
-	}
-}
-
-func TestBuildRangeInsertPreparedQuery(t *testing.T) {
-	databaseName := "mydb"
-	originalTableName := "tbl"
-	ghostTableName := "ghost"
-	sharedColumns := []string{"id", "name", "position"}
-	{
-		uniqueKey := "name_position_uidx"
-		uniqueKeyColumns := NewColumnList([]string{"name", "position"})
-		rangeStartArgs := []interface{}{3, 17}
-		rangeEndArgs := []interface{}{103, 117}
-
-		query, explodedArgs, err := BuildRangeInsertPreparedQuery(databaseName, originalTableName, ghostTableName, sharedColumns, sharedColumns, uniqueKey, uniqueKeyColumns, rangeStartArgs, rangeEndArgs, true, true)
-		test.S(t).ExpectNil(err)
-		expected := `
-				insert /* udup mydb.tbl */ ignore into mydb.ghost (id, name, position)
-				(select id, name, position from mydb.tbl force index (name_position_uidx)
-				  where (((name > ?) or (((name = ?)) AND (position > ?)) or ((name = ?) and (position = ?))) and ((name < ?) or (((name = ?)) AND (position < ?)) or ((name = ?) and (position = ?))))
-				lock in share mode )
-		`
-		test.S(t).ExpectEquals(normalizeQuery(query), normalizeQuery(expected))
-		test.S(t).ExpectTrue(reflect.DeepEqual(explodedArgs, []interface{}{3, 3, 17, 3, 17, 103, 103, 117, 103, 117}))
-	}
-}
-
-func TestBuildUniqueKeyRangeEndPreparedQuery(t *testing.T) {
-	databaseName := "mydb"
-	originalTableName := "tbl"
-	var chunkSize int64 = 500
-	{
-		uniqueKeyColumns := NewColumnList([]string{"name", "position"})
-		rangeStartArgs := []interface{}{3, 17}
-		rangeEndArgs := []interface{}{103, 117}
-
-		query, explodedArgs, err := BuildUniqueKeyRangeEndPreparedQuery(databaseName, originalTableName, uniqueKeyColumns, rangeStartArgs, rangeEndArgs, chunkSize, false, "test")
-		test.S(t).ExpectNil(err)
-		expected := `
-				select /* udup mydb.tbl test */ name, position
-				  from (
-				    select
-				        name, position
-				      from
-				        mydb.tbl
-				      where ((name > ?) or (((name = ?)) AND (position > ?))) and ((name < ?) or (((name = ?)) AND (position < ?)) or ((name = ?) and (position = ?)))
-				      order by
-				        name asc, position asc
-				      limit 500
-				  ) select_osc_chunk
-				order by
-				  name desc, position desc
-				limit 1
-		`
-		test.S(t).ExpectEquals(normalizeQuery(query), normalizeQuery(expected))
-		test.S(t).ExpectTrue(reflect.DeepEqual(explodedArgs, []interface{}{3, 3, 17, 103, 103, 117, 103, 117}))
-	}
-}
-
-func TestBuildUniqueKeyMinValuesPreparedQuery(t *testing.T) {
-	databaseName := "mydb"
-	originalTableName := "tbl"
-	uniqueKeyColumns := NewColumnList([]string{"name", "position"})
-	{
-		query, err := BuildUniqueKeyMinValuesPreparedQuery(databaseName, originalTableName, uniqueKeyColumns)
-		test.S(t).ExpectNil(err)
-		expected := `
-			select /* udup mydb.tbl */ name, position
-			  from
-			    mydb.tbl
-			  order by
-			    name asc, position asc
-			  limit 1
-		`
-		test.S(t).ExpectEquals(normalizeQuery(query), normalizeQuery(expected))
-	}
-	{
-		query, err := BuildUniqueKeyMaxValuesPreparedQuery(databaseName, originalTableName, uniqueKeyColumns)
-		test.S(t).ExpectNil(err)
-		expected := `
-			select /* udup mydb.tbl */ name, position
-			  from
-			    mydb.tbl
-			  order by
-			    name desc, position desc
-			  limit 1
-		`
-		test.S(t).ExpectEquals(normalizeQuery(query), normalizeQuery(expected))
-	}
-}
-
 func TestBuildDMLDeleteQuery(t *testing.T) {
 	databaseName := "mydb"
 	tableName := "tbl"
-	tableColumns := NewColumnList([]string{"id", "name", "rank", "position", "age"})
-	args := []interface{}{3, "testname", "first", 17, 23}
+	names := []string{"id", "name", "rank", "position", "age"}
+	args := newArgs(3, "testname", "first", 17, 23)
 	{
-		uniqueKeyColumns := NewColumnList([]string{"position"})
+		tableColumns := newColumnList(names, "position")
 
-		query, uniqueKeyArgs, err := BuildDMLDeleteQuery(databaseName, tableName, tableColumns, uniqueKeyColumns, args)
+		query, uniqueKeyArgs, err := BuildDMLDeleteQuery(databaseName, tableName, tableColumns, args)
 		test.S(t).ExpectNil(err)
 		expected := `
-			delete /* udup mydb.tbl */
+			delete
 				from
 					mydb.tbl
 				where
@@ -255,12 +91,12 @@ func TestBuildDMLDeleteQuery(t *testing.T) {
 		test.S(t).ExpectTrue(reflect.DeepEqual(uniqueKeyArgs, []interface{}{17}))
 	}
 	{
-		uniqueKeyColumns := NewColumnList([]string{"name", "position"})
+		tableColumns := newColumnList(names, "name", "position")
 
-		query, uniqueKeyArgs, err := BuildDMLDeleteQuery(databaseName, tableName, tableColumns, uniqueKeyColumns, args)
+		query, uniqueKeyArgs, err := BuildDMLDeleteQuery(databaseName, tableName, tableColumns, args)
 		test.S(t).ExpectNil(err)
 		expected := `
-			delete /* udup mydb.tbl */
+			delete
 				from
 					mydb.tbl
 				where
@@ -270,25 +106,26 @@ func TestBuildDMLDeleteQuery(t *testing.T) {
 		test.S(t).ExpectTrue(reflect.DeepEqual(uniqueKeyArgs, []interface{}{"testname", 17}))
 	}
 	{
-		uniqueKeyColumns := NewColumnList([]string{"position", "name"})
+		// without a primary key all the columns are compared
+		tableColumns := newColumnList(names)
 
-		query, uniqueKeyArgs, err := BuildDMLDeleteQuery(databaseName, tableName, tableColumns, uniqueKeyColumns, args)
+		query, uniqueKeyArgs, err := BuildDMLDeleteQuery(databaseName, tableName, tableColumns, newArgs(3, "testname", nil, 17, 23))
 		test.S(t).ExpectNil(err)
 		expected := `
-			delete /* udup mydb.tbl */
+			delete
 				from
 					mydb.tbl
 				where
-					((position = ?) and (name = ?))
+					((id = ?) and (name = ?) and (rank is NULL) and (position = ?) and (age = ?))
 		`
 		test.S(t).ExpectEquals(normalizeQuery(query), normalizeQuery(expected))
-		test.S(t).ExpectTrue(reflect.DeepEqual(uniqueKeyArgs, []interface{}{17, "testname"}))
+		test.S(t).ExpectTrue(reflect.DeepEqual(uniqueKeyArgs, []interface{}{3, "testname", 17, 23}))
 	}
 	{
-		uniqueKeyColumns := NewColumnList([]string{"position", "name"})
-		args := []interface{}{"first", 17}
+		tableColumns := newColumnList(names, "position", "name")
+		args := newArgs("first", 17)
 
-		_, _, err := BuildDMLDeleteQuery(databaseName, tableName, tableColumns, uniqueKeyColumns, args)
+		_, _, err := BuildDMLDeleteQuery(databaseName, tableName, tableColumns, args)
 		test.S(t).ExpectNotNil(err)
 	}
 }
@@ -296,15 +133,14 @@ func TestBuildDMLDeleteQuery(t *testing.T) {
 func TestBuildDMLDeleteQuerySignedUnsigned(t *testing.T) {
 	databaseName := "mydb"
 	tableName := "tbl"
-	tableColumns := NewColumnList([]string{"id", "name", "rank", "position", "age"})
-	uniqueKeyColumns := NewColumnList([]string{"position"})
+	tableColumns := newColumnList([]string{"id", "name", "rank", "position", "age"}, "position")
 	{
 		// test signed (expect no change)
-		args := []interface{}{3, "testname", "first", -1, 23}
-		query, uniqueKeyArgs, err := BuildDMLDeleteQuery(databaseName, tableName, tableColumns, uniqueKeyColumns, args)
+		args := newArgs(3, "testname", "first", -1, 23)
+		query, uniqueKeyArgs, err := BuildDMLDeleteQuery(databaseName, tableName, tableColumns, args)
 		test.S(t).ExpectNil(err)
 		expected := `
-			delete /* udup mydb.tbl */
+			delete
 				from
 					mydb.tbl
 				where
@@ -315,12 +151,12 @@ func TestBuildDMLDeleteQuerySignedUnsigned(t *testing.T) {
 	}
 	{
 		// test unsigned
-		args := []interface{}{3, "testname", "first", int8(-1), 23}
-		uniqueKeyColumns.SetUnsigned("position")
-		query, uniqueKeyArgs, err := BuildDMLDeleteQuery(databaseName, tableName, tableColumns, uniqueKeyColumns, args)
+		args := newArgs(3, "testname", "first", int8(-1), 23)
+		tableColumns.SetUnsigned("position")
+		query, uniqueKeyArgs, err := BuildDMLDeleteQuery(databaseName, tableName, tableColumns, args)
 		test.S(t).ExpectNil(err)
 		expected := `
-			delete /* udup mydb.tbl */
+			delete
 				from
 					mydb.tbl
 				where
@@ -331,46 +167,143 @@ func TestBuildDMLDeleteQuerySignedUnsigned(t *testing.T) {
 	}
 }
 
+func TestBuildDMLSoftDeleteQuery(t *testing.T) {
+	databaseName := "mydb"
+	tableName := "tbl"
+	tableColumns := newColumnList([]string{"id", "name", "deleted_at"}, "id")
+	{
+		query, uniqueKeyArgs, err := BuildDMLSoftDeleteQuery(databaseName, tableName, tableColumns,
+			"deleted_at", "now()", newArgs(3, "testname", nil))
+		test.S(t).ExpectNil(err)
+		expected := `
+			update
+					mydb.tbl
+				set
+					deleted_at=now()
+				where
+					((id = ?))
+		`
+		test.S(t).ExpectEquals(normalizeQuery(query), normalizeQuery(expected))
+		test.S(t).ExpectTrue(reflect.DeepEqual(uniqueKeyArgs, []interface{}{3}))
+	}
+	{
+		// the row is matched as a delete would
+		tableColumns := newColumnList([]string{"id", "name", "is_deleted"})
+		query, columnArgs, err := BuildDMLSoftDeleteQuery(databaseName, tableName, tableColumns,
+			"is_deleted", "1", newArgs(3, nil, 0))
+		test.S(t).ExpectNil(err)
+		expected := `
+			update
+					mydb.tbl
+				set
+					is_deleted=1
+				where
+					((id = ?) and (name is NULL) and (is_deleted = ?))
+		`
+		test.S(t).ExpectEquals(normalizeQuery(query), normalizeQuery(expected))
+		test.S(t).ExpectTrue(reflect.DeepEqual(columnArgs, []interface{}{3, 0}))
+	}
+	{
+		_, _, err := BuildDMLSoftDeleteQuery(databaseName, tableName, tableColumns, "deleted_at", "now()", newArgs(3))
+		test.S(t).ExpectNotNil(err)
+	}
+}
+
+func TestBuildDMLDeleteWhere(t *testing.T) {
+	tableColumns := newColumnList([]string{"id", "code", "name"})
+	tableColumns.GetColumn("code").ColumnType = "binary(2)"
+	for _, tt := range []struct {
+		keys     []string
+		args     []*interface{}
+		expected string
+		wantArgs []interface{}
+	}{
+		{
+			// all the columns without a primary key, binaries cast
+			args:     newArgs(3, "ab", "testname"),
+			expected: "((id = ?) and (code = cast('ab' as binary(2))) and (name = ?))",
+			wantArgs: []interface{}{3, "testname"},
+		},
+		{
+			args:     newArgs(3, nil, nil),
+			expected: "((id = ?) and (code is NULL) and (name is NULL))",
+			wantArgs: []interface{}{3},
+		},
+		{
+			// only the primary key
+			keys:     []string{"id"},
+			args:     newArgs(3, "ab", "testname"),
+			expected: "((id = ?))",
+			wantArgs: []interface{}{3},
+		},
+		{
+			keys:     []string{"id", "code"},
+			args:     newArgs(3, "ab", "testname"),
+			expected: "((id = ?) and (code = cast('ab' as binary(2))))",
+			wantArgs: []interface{}{3},
+		},
+		{
+			// all the columns if the key is NULL
+			keys:     []string{"id"},
+			args:     newArgs(nil, "ab", "testname"),
+			expected: "((id is NULL) and (code = cast('ab' as binary(2))) and (name = ?))",
+			wantArgs: []interface{}{"testname"},
+		},
+	} {
+		for _, column := range tableColumns.Columns {
+			tableColumns.GetColumn(column.Name).Key = ""
+		}
+		for _, key := range tt.keys {
+			tableColumns.GetColumn(key).Key = "PRI"
+		}
+		where, columnArgs, err := buildDMLDeleteWhere(tableColumns, tt.args)
+		test.S(t).ExpectNil(err)
+		test.S(t).ExpectEquals(normalizeQuery(where), tt.expected)
+		test.S(t).ExpectTrue(reflect.DeepEqual(columnArgs, tt.wantArgs))
+	}
+}
+
 func TestBuildDMLInsertQuery(t *testing.T) {
 	databaseName := "mydb"
 	tableName := "tbl"
-	tableColumns := NewColumnList([]string{"id", "name", "rank", "position", "age"})
-	args := []interface{}{3, "testname", "first", 17, 23}
+	tableColumns := newColumnList([]string{"id", "name", "rank", "position", "age"})
+	args := newArgs(3, "testname", "first", 17, 23)
 	{
-		sharedColumns := NewColumnList([]string{"id", "name", "position", "age"})
-		query, sharedArgs, err := BuildDMLInsertQuery(databaseName, tableName, tableColumns, sharedColumns, sharedColumns, args)
+		query, sharedArgs, err := BuildDMLInsertQuery(databaseName, tableName, tableColumns, tableColumns, tableColumns, args)
 		test.S(t).ExpectNil(err)
 		expected := `
-			replace /* udup mydb.tbl */
-				into mydb.tbl
-					(id, name, position, age)
+			replace into
+				mydb.tbl
+					(id, name, rank, position, age)
 				values
-					(?, ?, ?, ?)
+					(?, ?, ?, ?, ?)
 		`
 		test.S(t).ExpectEquals(normalizeQuery(query), normalizeQuery(expected))
-		test.S(t).ExpectTrue(reflect.DeepEqual(sharedArgs, []interface{}{3, "testname", 17, 23}))
+		test.S(t).ExpectTrue(reflect.DeepEqual(sharedArgs, []interface{}{3, "testname", "first", 17, 23}))
 	}
 	{
-		sharedColumns := NewColumnList([]string{"position", "name", "age", "id"})
-		query, sharedArgs, err := BuildDMLInsertQuery(databaseName, tableName, tableColumns, sharedColumns, sharedColumns, args)
+		// generated columns are computed by the target
+		tableColumns := newColumnList([]string{"id", "name", "rank", "position", "age"})
+		tableColumns.GetColumn("rank").IsGenerated = true
+		query, sharedArgs, err := BuildDMLInsertQuery(databaseName, tableName, tableColumns, tableColumns, tableColumns, args)
 		test.S(t).ExpectNil(err)
 		expected := `
-			replace /* udup mydb.tbl */
-				into mydb.tbl
-					(position, name, age, id)
+			replace into
+				mydb.tbl
+					(id, name, position, age)
 				values
 					(?, ?, ?, ?)
 		`
 		test.S(t).ExpectEquals(normalizeQuery(query), normalizeQuery(expected))
-		test.S(t).ExpectTrue(reflect.DeepEqual(sharedArgs, []interface{}{17, "testname", 23, 3}))
+		test.S(t).ExpectTrue(reflect.DeepEqual(sharedArgs, []interface{}{3, "testname", 17, 23}))
 	}
 	{
-		sharedColumns := NewColumnList([]string{"position", "name", "surprise", "id"})
+		sharedColumns := newColumnList([]string{"position", "name", "surprise", "id"})
 		_, _, err := BuildDMLInsertQuery(databaseName, tableName, tableColumns, sharedColumns, sharedColumns, args)
 		test.S(t).ExpectNotNil(err)
 	}
 	{
-		sharedColumns := NewColumnList([]string{})
+		sharedColumns := newColumnList([]string{})
 		_, _, err := BuildDMLInsertQuery(databaseName, tableName, tableColumns, sharedColumns, sharedColumns, args)
 		test.S(t).ExpectNotNil(err)
 	}
@@ -379,197 +312,179 @@ func TestBuildDMLInsertQuery(t *testing.T) {
 func TestBuildDMLInsertQuerySignedUnsigned(t *testing.T) {
 	databaseName := "mydb"
 	tableName := "tbl"
-	tableColumns := NewColumnList([]string{"id", "name", "rank", "position", "age"})
-	sharedColumns := NewColumnList([]string{"id", "name", "position", "age"})
+	tableColumns := newColumnList([]string{"id", "name", "rank", "position", "age"})
+	expected := `
+			replace into
+				mydb.tbl
+					(id, name, rank, position, age)
+				values
+					(?, ?, ?, ?, ?)
+		`
 	{
 		// testing signed
-		args := []interface{}{3, "testname", "first", int8(-1), 23}
-		sharedColumns := NewColumnList([]string{"id", "name", "position", "age"})
-		query, sharedArgs, err := BuildDMLInsertQuery(databaseName, tableName, tableColumns, sharedColumns, sharedColumns, args)
+		args := newArgs(3, "testname", "first", int8(-1), 23)
+		query, sharedArgs, err := BuildDMLInsertQuery(databaseName, tableName, tableColumns, tableColumns, tableColumns, args)
+		test.S(t).ExpectNil(err)
+		test.S(t).ExpectEquals(normalizeQuery(query), normalizeQuery(expected))
+		test.S(t).ExpectTrue(reflect.DeepEqual(sharedArgs, []interface{}{3, "testname", "first", int8(-1), 23}))
+	}
+	{
+		// testing unsigned
+		args := newArgs(3, "testname", "first", int8(-1), 23)
+		tableColumns.SetUnsigned("position")
+		query, sharedArgs, err := BuildDMLInsertQuery(databaseName, tableName, tableColumns, tableColumns, tableColumns, args)
 		test.S(t).ExpectNil(err)
-		expected := `
-			replace /* udup mydb.tbl */
-				into mydb.tbl
-					(id, name, position, age)
-				values
-					(?, ?, ?, ?)
-		`
 		test.S(t).ExpectEquals(normalizeQuery(query), normalizeQuery(expected))
-		test.S(t).ExpectTrue(reflect.DeepEqual(sharedArgs, []interface{}{3, "testname", int8(-1), 23}))
+		test.S(t).ExpectTrue(reflect.DeepEqual(sharedArgs, []interface{}{3, "testname", "first", uint8(255), 23}))
 	}
 	{
 		// testing unsigned
-		args := []interface{}{3, "testname", "first", int8(-1), 23}
-		sharedColumns.SetUnsigned("position")
-		query, sharedArgs, err := BuildDMLInsertQuery(databaseName, tableName, tableColumns, sharedColumns, sharedColumns, args)
+		args := newArgs(3, "testname", "first", int32(-1), 23)
+		tableColumns.SetUnsigned("position")
+		query, sharedArgs, err := BuildDMLInsertQuery(databaseName, tableName, tableColumns, tableColumns, tableColumns, args)
+		test.S(t).ExpectNil(err)
+		test.S(t).ExpectEquals(normalizeQuery(query), normalizeQuery(expected))
+		test.S(t).ExpectTrue(reflect.DeepEqual(sharedArgs, []interface{}{3, "testname", "first", uint32(4294967295), 23}))
+	}
+}
+
+func TestBuildDMLHistoryQuery(t *testing.T) {
+	tableColumns := newColumnList([]string{"id", "name", "name_len", "age"}, "id")
+	tableColumns.GetColumn("name_len").IsGenerated = true
+	tableColumns.SetUnsigned("age")
+	{
+		// generated columns are not kept, and the meta columns follow
+		query, sharedArgs, err := BuildDMLHistoryQuery("mydb", "tbl_history", tableColumns,
+			[]string{"_op", "_ts", "_gtid"}, newArgs(3, "testname", 8, int8(-1)))
 		test.S(t).ExpectNil(err)
 		expected := `
-			replace /* udup mydb.tbl */
-				into mydb.tbl
-					(id, name, position, age)
+			insert into
+				mydb.tbl_history
+					(id, name, age, _op, _ts, _gtid)
 				values
-					(?, ?, ?, ?)
+					(?, ?, ?, ?, ?, ?)
 		`
 		test.S(t).ExpectEquals(normalizeQuery(query), normalizeQuery(expected))
-		test.S(t).ExpectTrue(reflect.DeepEqual(sharedArgs, []interface{}{3, "testname", uint8(255), 23}))
+		test.S(t).ExpectTrue(reflect.DeepEqual(sharedArgs, []interface{}{3, "testname", uint8(255)}))
 	}
 	{
-		// testing unsigned
-		args := []interface{}{3, "testname", "first", int32(-1), 23}
-		sharedColumns.SetUnsigned("position")
-		query, sharedArgs, err := BuildDMLInsertQuery(databaseName, tableName, tableColumns, sharedColumns, sharedColumns, args)
+		query, sharedArgs, err := BuildDMLHistoryQuery("mydb", "tbl_history", tableColumns,
+			nil, newArgs(3, nil, nil, nil))
 		test.S(t).ExpectNil(err)
 		expected := `
-			replace /* udup mydb.tbl */
-				into mydb.tbl
-					(id, name, position, age)
+			insert into
+				mydb.tbl_history
+					(id, name, age)
 				values
-					(?, ?, ?, ?)
+					(?, ?, ?)
 		`
 		test.S(t).ExpectEquals(normalizeQuery(query), normalizeQuery(expected))
-		test.S(t).ExpectTrue(reflect.DeepEqual(sharedArgs, []interface{}{3, "testname", uint32(4294967295), 23}))
+		test.S(t).ExpectTrue(reflect.DeepEqual(sharedArgs, []interface{}{3, nil, nil}))
+	}
+	{
+		_, _, err := BuildDMLHistoryQuery("mydb", "tbl_history", tableColumns, nil, newArgs(3, "testname"))
+		test.S(t).ExpectNotNil(err)
 	}
 }
 
 func TestBuildDMLUpdateQuery(t *testing.T) {
 	databaseName := "mydb"
 	tableName := "tbl"
-	tableColumns := NewColumnList([]string{"id", "name", "rank", "position", "age"})
-	valueArgs := []interface{}{3, "testname", "newval", 17, 23}
-	whereArgs := []interface{}{3, "testname", "findme", 17, 56}
+	names := []string{"id", "name", "rank", "position", "age"}
+	valueArgs := newArgs(3, "testname", "newval", 17, 23)
+	whereArgs := newArgs(3, "testname", "findme", 17, 56)
 	{
-		sharedColumns := NewColumnList([]string{"id", "name", "position", "age"})
-		uniqueKeyColumns := NewColumnList([]string{"position"})
-		query, sharedArgs, uniqueKeyArgs, err := BuildDMLUpdateQuery(databaseName, tableName, tableColumns, sharedColumns, sharedColumns, uniqueKeyColumns, valueArgs, whereArgs)
+		tableColumns := newColumnList(names, "position")
+		query, sharedArgs, uniqueKeyArgs, err := BuildDMLUpdateQuery(databaseName, tableName, tableColumns, tableColumns, tableColumns, tableColumns, valueArgs, whereArgs)
 		test.S(t).ExpectNil(err)
 		expected := `
-			update /* udup mydb.tbl */
+			update
 			  mydb.tbl
-					set id=?, name=?, position=?, age=?
+					set id=?, name=?, rank=?, position=?, age=?
 				where
 					((position = ?))
+				limit 1
 		`
 		test.S(t).ExpectEquals(normalizeQuery(query), normalizeQuery(expected))
-		test.S(t).ExpectTrue(reflect.DeepEqual(sharedArgs, []interface{}{3, "testname", 17, 23}))
+		test.S(t).ExpectTrue(reflect.DeepEqual(sharedArgs, []interface{}{3, "testname", "newval", 17, 23}))
 		test.S(t).ExpectTrue(reflect.DeepEqual(uniqueKeyArgs, []interface{}{17}))
 	}
 	{
-		sharedColumns := NewColumnList([]string{"id", "name", "position", "age"})
-		uniqueKeyColumns := NewColumnList([]string{"position", "name"})
-		query, sharedArgs, uniqueKeyArgs, err := BuildDMLUpdateQuery(databaseName, tableName, tableColumns, sharedColumns, sharedColumns, uniqueKeyColumns, valueArgs, whereArgs)
-		test.S(t).ExpectNil(err)
-		expected := `
-			update /* udup mydb.tbl */
-			  mydb.tbl
-					set id=?, name=?, position=?, age=?
-				where
-					((position = ?) and (name = ?))
-		`
-		test.S(t).ExpectEquals(normalizeQuery(query), normalizeQuery(expected))
-		test.S(t).ExpectTrue(reflect.DeepEqual(sharedArgs, []interface{}{3, "testname", 17, 23}))
-		test.S(t).ExpectTrue(reflect.DeepEqual(uniqueKeyArgs, []interface{}{17, "testname"}))
-	}
-	{
-		sharedColumns := NewColumnList([]string{"id", "name", "position", "age"})
-		uniqueKeyColumns := NewColumnList([]string{"age"})
-		query, sharedArgs, uniqueKeyArgs, err := BuildDMLUpdateQuery(databaseName, tableName, tableColumns, sharedColumns, sharedColumns, uniqueKeyColumns, valueArgs, whereArgs)
+		tableColumns := newColumnList(names, "name", "position")
+		query, sharedArgs, uniqueKeyArgs, err := BuildDMLUpdateQuery(databaseName, tableName, tableColumns, tableColumns, tableColumns, tableColumns, valueArgs, whereArgs)
 		test.S(t).ExpectNil(err)
 		expected := `
-			update /* udup mydb.tbl */
+			update
 			  mydb.tbl
-					set id=?, name=?, position=?, age=?
+					set id=?, name=?, rank=?, position=?, age=?
 				where
-					((age = ?))
+					((name = ?) and (position = ?))
+				limit 1
 		`
 		test.S(t).ExpectEquals(normalizeQuery(query), normalizeQuery(expected))
-		test.S(t).ExpectTrue(reflect.DeepEqual(sharedArgs, []interface{}{3, "testname", 17, 23}))
-		test.S(t).ExpectTrue(reflect.DeepEqual(uniqueKeyArgs, []interface{}{56}))
+		test.S(t).ExpectTrue(reflect.DeepEqual(sharedArgs, []interface{}{3, "testname", "newval", 17, 23}))
+		test.S(t).ExpectTrue(reflect.DeepEqual(uniqueKeyArgs, []interface{}{"testname", 17}))
 	}
 	{
-		sharedColumns := NewColumnList([]string{"id", "name", "position", "age"})
-		uniqueKeyColumns := NewColumnList([]string{"age", "position", "id", "name"})
-		query, sharedArgs, uniqueKeyArgs, err := BuildDMLUpdateQuery(databaseName, tableName, tableColumns, sharedColumns, sharedColumns, uniqueKeyColumns, valueArgs, whereArgs)
+		// without a primary key all the columns are compared
+		tableColumns := newColumnList(names)
+		query, sharedArgs, columnArgs, err := BuildDMLUpdateQuery(databaseName, tableName, tableColumns, tableColumns, tableColumns, tableColumns, valueArgs, whereArgs)
 		test.S(t).ExpectNil(err)
 		expected := `
-			update /* udup mydb.tbl */
+			update
 			  mydb.tbl
-					set id=?, name=?, position=?, age=?
+					set id=?, name=?, rank=?, position=?, age=?
 				where
-					((age = ?) and (position = ?) and (id = ?) and (name = ?))
+					((id = ?) and (name = ?) and (rank = ?) and (position = ?) and (age = ?))
+				limit 1
 		`
 		test.S(t).ExpectEquals(normalizeQuery(query), normalizeQuery(expected))
-		test.S(t).ExpectTrue(reflect.DeepEqual(sharedArgs, []interface{}{3, "testname", 17, 23}))
-		test.S(t).ExpectTrue(reflect.DeepEqual(uniqueKeyArgs, []interface{}{56, 17, 3, "testname"}))
+		test.S(t).ExpectTrue(reflect.DeepEqual(sharedArgs, []interface{}{3, "testname", "newval", 17, 23}))
+		test.S(t).ExpectTrue(reflect.DeepEqual(columnArgs, []interface{}{3, "testname", "findme", 17, 56}))
 	}
 	{
-		sharedColumns := NewColumnList([]string{"id", "name", "position", "age"})
-		uniqueKeyColumns := NewColumnList([]string{"age", "surprise"})
-		_, _, _, err := BuildDMLUpdateQuery(databaseName, tableName, tableColumns, sharedColumns, sharedColumns, uniqueKeyColumns, valueArgs, whereArgs)
+		tableColumns := newColumnList(names, "id")
+		sharedColumns := newColumnList([]string{"id", "name", "surprise", "age"})
+		_, _, _, err := BuildDMLUpdateQuery(databaseName, tableName, tableColumns, sharedColumns, sharedColumns, tableColumns, valueArgs, whereArgs)
 		test.S(t).ExpectNotNil(err)
 	}
 	{
-		sharedColumns := NewColumnList([]string{"id", "name", "position", "age"})
-		uniqueKeyColumns := NewColumnList([]string{})
-		_, _, _, err := BuildDMLUpdateQuery(databaseName, tableName, tableColumns, sharedColumns, sharedColumns, uniqueKeyColumns, valueArgs, whereArgs)
+		tableColumns := newColumnList(names, "id")
+		_, _, _, err := BuildDMLUpdateQuery(databaseName, tableName, tableColumns, tableColumns, tableColumns, tableColumns, newArgs(3, "testname"), whereArgs)
 		test.S(t).ExpectNotNil(err)
 	}
-	{
-		sharedColumns := NewColumnList([]string{"id", "name", "position", "age"})
-		mappedColumns := NewColumnList([]string{"id", "name", "role", "age"})
-		uniqueKeyColumns := NewColumnList([]string{"id"})
-		query, sharedArgs, uniqueKeyArgs, err := BuildDMLUpdateQuery(databaseName, tableName, tableColumns, sharedColumns, mappedColumns, uniqueKeyColumns, valueArgs, whereArgs)
-		test.S(t).ExpectNil(err)
-		expected := `
-			update /* udup mydb.tbl */
-			  mydb.tbl
-					set id=?, name=?, role=?, age=?
-				where
-					((id = ?))
-		`
-		test.S(t).ExpectEquals(normalizeQuery(query), normalizeQuery(expected))
-		test.S(t).ExpectTrue(reflect.DeepEqual(sharedArgs, []interface{}{3, "testname", 17, 23}))
-		test.S(t).ExpectTrue(reflect.DeepEqual(uniqueKeyArgs, []interface{}{3}))
-	}
 }
 
 func TestBuildDMLUpdateQuerySignedUnsigned(t *testing.T) {
 	databaseName := "mydb"
 	tableName := "tbl"
-	tableColumns := NewColumnList([]string{"id", "name", "rank", "position", "age"})
-	valueArgs := []interface{}{3, "testname", "newval", int8(-17), int8(-2)}
-	whereArgs := []interface{}{3, "testname", "findme", int8(-3), 56}
-	sharedColumns := NewColumnList([]string{"id", "name", "position", "age"})
-	uniqueKeyColumns := NewColumnList([]string{"position"})
-	{
-		// test signed
-		query, sharedArgs, uniqueKeyArgs, err := BuildDMLUpdateQuery(databaseName, tableName, tableColumns, sharedColumns, sharedColumns, uniqueKeyColumns, valueArgs, whereArgs)
-		test.S(t).ExpectNil(err)
-		expected := `
-			update /* udup mydb.tbl */
+	tableColumns := newColumnList([]string{"id", "name", "rank", "position", "age"}, "position")
+	valueArgs := newArgs(3, "testname", "newval", int8(-17), int8(-2))
+	whereArgs := newArgs(3, "testname", "findme", int8(-3), 56)
+	expected := `
+			update
 			  mydb.tbl
-					set id=?, name=?, position=?, age=?
+					set id=?, name=?, rank=?, position=?, age=?
 				where
 					((position = ?))
+				limit 1
 		`
+	{
+		// test signed
+		query, sharedArgs, uniqueKeyArgs, err := BuildDMLUpdateQuery(databaseName, tableName, tableColumns, tableColumns, tableColumns, tableColumns, valueArgs, whereArgs)
+		test.S(t).ExpectNil(err)
 		test.S(t).ExpectEquals(normalizeQuery(query), normalizeQuery(expected))
-		test.S(t).ExpectTrue(reflect.DeepEqual(sharedArgs, []interface{}{3, "testname", int8(-17), int8(-2)}))
+		test.S(t).ExpectTrue(reflect.DeepEqual(sharedArgs, []interface{}{3, "testname", "newval", int8(-17), int8(-2)}))
 		test.S(t).ExpectTrue(reflect.DeepEqual(uniqueKeyArgs, []interface{}{int8(-3)}))
 	}
 	{
 		// test unsigned
-		sharedColumns.SetUnsigned("age")
-		uniqueKeyColumns.SetUnsigned("position")
-		query, sharedArgs, uniqueKeyArgs, err := BuildDMLUpdateQuery(databaseName, tableName, tableColumns, sharedColumns, sharedColumns, uniqueKeyColumns, valueArgs, whereArgs)
+		tableColumns.SetUnsigned("age")
+		tableColumns.SetUnsigned("position")
+		query, sharedArgs, uniqueKeyArgs, err := BuildDMLUpdateQuery(databaseName, tableName, tableColumns, tableColumns, tableColumns, tableColumns, valueArgs, whereArgs)
 		test.S(t).ExpectNil(err)
-		expected := `
-			update /* udup mydb.tbl */
-			  mydb.tbl
-					set id=?, name=?, position=?, age=?
-				where
-					((position = ?))
-		`
 		test.S(t).ExpectEquals(normalizeQuery(query), normalizeQuery(expected))
-		test.S(t).ExpectTrue(reflect.DeepEqual(sharedArgs, []interface{}{3, "testname", int8(-17), uint8(254)}))
+		test.S(t).ExpectTrue(reflect.DeepEqual(sharedArgs, []interface{}{3, "testname", "newval", uint8(239), uint8(254)}))
 		test.S(t).ExpectTrue(reflect.DeepEqual(uniqueKeyArgs, []interface{}{uint8(253)}))
 	}
 }
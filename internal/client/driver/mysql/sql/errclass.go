@@ -0,0 +1,140 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package sql
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// The classes of the errors of a target
+const (
+	// ErrorClassRetryable is an error a transaction may succeed on when
+	// retried, such as a deadlock or the loss of the connection
+	ErrorClassRetryable = "retryable"
+	// ErrorClassData is an error of the rows applied conflicting with those
+	// of the target, or not fitting its columns, such as a duplicate key
+	ErrorClassData = "data"
+	// ErrorClassTerminal is an error retrying does not help with, such as a
+	// missing table or privilege. It is the class of the errors not in
+	// ErrorClasses.
+	ErrorClassTerminal = "terminal"
+	// ErrorClassIgnorable is an error the statement failing with is skipped
+	// on, the rest of its transaction being applied. No error is ignorable
+	// unless overridden by the ErrorClasses of a job.
+	ErrorClassIgnorable = "ignorable"
+)
+
+// ErrorClasses are the classes of the errors of MySQL by their code. The
+// connection errors are retryable, and the others terminal.
+var ErrorClasses = map[uint16]string{
+	ErrLockDeadlock:     ErrorClassRetryable,
+	ErrLockWaitTimeout:  ErrorClassRetryable,
+	ErrServerShutdown:   ErrorClassRetryable,
+	ErrQueryInterrupted: ErrorClassRetryable,
+	ErrConCount:         ErrorClassRetryable,
+
+	ErrTiDBCantRetry:         ErrorClassRetryable,
+	ErrTiDBTxnRetryable:      ErrorClassRetryable,
+	ErrTiDBInfoSchemaExpired: ErrorClassRetryable,
+	ErrTiDBInfoSchemaChanged: ErrorClassRetryable,
+	ErrTiDBPDServerTimeout:   ErrorClassRetryable,
+	ErrTiDBTiKVServerTimeout: ErrorClassRetryable,
+	ErrTiDBTiKVServerBusy:    ErrorClassRetryable,
+	ErrTiDBRegionUnavailable: ErrorClassRetryable,
+	ErrTiDBWriteConflict:     ErrorClassRetryable,
+
+	ErrDupEntry:                    ErrorClassData,
+	ErrDupUnique:                   ErrorClassData,
+	ErrDupEntryWithKeyName:         ErrorClassData,
+	ErrNoReferencedRow:             ErrorClassData,
+	ErrRowIsReferenced:             ErrorClassData,
+	ErrNoReferencedRow2:            ErrorClassData,
+	ErrRowIsReferenced2:            ErrorClassData,
+	ErrBadNull:                     ErrorClassData,
+	ErrNoDefaultForField:           ErrorClassData,
+	ErrDataTooLong:                 ErrorClassData,
+	ErrWarnDataOutOfRange:          ErrorClassData,
+	ErrDataOutOfRange:              ErrorClassData,
+	ErrTruncatedWrongValue:         ErrorClassData,
+	ErrTruncatedWrongValueForField: ErrorClassData,
+}
+
+// ClassifiedError is an error along with its class
+type ClassifiedError struct {
+	Class string
+	Err   error
+}
+
+func (e *ClassifiedError) Error() string {
+	return fmt.Sprintf("%s error: %v", e.Class, e.Err)
+}
+
+// ErrorClass returns the class of the error
+func (e *ClassifiedError) ErrorClass() string {
+	return e.Class
+}
+
+// ErrorClassifier classifies the errors by ErrorClasses, overridden by the
+// classes of some codes
+type ErrorClassifier struct {
+	overrides map[uint16]string
+}
+
+// NewErrorClassifier returns the classifier of the classes of a job, by
+// the codes of the errors, such as {"1062": "ignorable"}
+func NewErrorClassifier(classes map[string]string) (*ErrorClassifier, error) {
+	c := &ErrorClassifier{overrides: make(map[uint16]string)}
+	for code, class := range classes {
+		n, err := strconv.ParseUint(code, 10, 16)
+		if err != nil {
+			return nil, fmt.Errorf("ErrorClasses: invalid error code %q", code)
+		}
+		switch class {
+		case ErrorClassRetryable, ErrorClassData, ErrorClassTerminal, ErrorClassIgnorable:
+		default:
+			return nil, fmt.Errorf("ErrorClasses: invalid class %q of error %s", class, code)
+		}
+		c.overrides[uint16(n)] = class
+	}
+	return c, nil
+}
+
+// Classify returns the class of an error. A nil classifier has no
+// overrides.
+func (c *ErrorClassifier) Classify(err error) string {
+	if e, ok := err.(*ClassifiedError); ok {
+		return e.Class
+	}
+	if mysqlErr, ok := err.(*mysql.MySQLError); ok {
+		if c != nil {
+			if class, ok := c.overrides[mysqlErr.Number]; ok {
+				return class
+			}
+		}
+		if class, ok := ErrorClasses[mysqlErr.Number]; ok {
+			return class
+		}
+	}
+	if ConnectionError(err) {
+		return ErrorClassRetryable
+	}
+	return ErrorClassTerminal
+}
+
+// Wrap returns the error with its class, nil if nil
+func (c *ErrorClassifier) Wrap(err error) error {
+	if err == nil {
+		return nil
+	}
+	if _, ok := err.(*ClassifiedError); ok {
+		return err
+	}
+	return &ClassifiedError{Class: c.Classify(err), Err: err}
+}
@@ -0,0 +1,62 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package sql
+
+import (
+	"fmt"
+	"io"
+	"testing"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+func TestErrorClassifier(t *testing.T) {
+	dup := &mysql.MySQLError{Number: ErrDupEntry, Message: "Duplicate entry '1' for key 'PRIMARY'"}
+	deadlock := &mysql.MySQLError{Number: ErrLockDeadlock}
+	noTable := &mysql.MySQLError{Number: 1146}
+
+	var c *ErrorClassifier
+	for _, tt := range []struct {
+		err  error
+		want string
+	}{
+		{dup, ErrorClassData},
+		{deadlock, ErrorClassRetryable},
+		{noTable, ErrorClassTerminal},
+		{io.ErrUnexpectedEOF, ErrorClassRetryable},
+		{fmt.Errorf("unknown"), ErrorClassTerminal},
+	} {
+		if got := c.Classify(tt.err); got != tt.want {
+			t.Errorf("Classify(%v) = %s, want %s", tt.err, got, tt.want)
+		}
+	}
+
+	c, err := NewErrorClassifier(map[string]string{"1062": "ignorable", "1146": "retryable"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := c.Classify(dup); got != ErrorClassIgnorable {
+		t.Errorf("overridden 1062 is %s", got)
+	}
+	if got := c.Classify(noTable); got != ErrorClassRetryable {
+		t.Errorf("overridden 1146 is %s", got)
+	}
+	if got := c.Classify(deadlock); got != ErrorClassRetryable {
+		t.Errorf("1213 is %s", got)
+	}
+
+	wrapped := c.Wrap(deadlock)
+	if wrapped.Error() != "retryable error: "+deadlock.Error() || c.Wrap(wrapped) != wrapped || c.Wrap(nil) != nil {
+		t.Errorf("Wrap: %v", wrapped)
+	}
+
+	for _, classes := range []map[string]string{{"dup": "data"}, {"1062": "skip"}, {"70000": "data"}} {
+		if _, err := NewErrorClassifier(classes); err == nil {
+			t.Errorf("%v accepted", classes)
+		}
+	}
+}
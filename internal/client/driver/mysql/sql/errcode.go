@@ -7,6 +7,11 @@
 package sql
 
 import (
+	gosql "database/sql"
+	"database/sql/driver"
+	"io"
+	"net"
+
 	"github.com/go-sql-driver/mysql"
 )
 
@@ -925,3 +930,40 @@ func IgnoreExistsError(err error) bool {
 		return false
 	}
 }
+
+// The errors of TiDB a transaction may succeed on when retried
+const (
+	ErrTiDBCantRetry         = 8002
+	ErrTiDBTxnRetryable      = 8022
+	ErrTiDBInfoSchemaExpired = 8027
+	ErrTiDBInfoSchemaChanged = 8028
+	ErrTiDBPDServerTimeout   = 9001
+	ErrTiDBTiKVServerTimeout = 9002
+	ErrTiDBTiKVServerBusy    = 9003
+	ErrTiDBRegionUnavailable = 9005
+	ErrTiDBWriteConflict     = 9007
+)
+
+// ConnectionError tells whether an error is the loss of the connection to
+// the server, such as on its restart, after which a new connection may
+// succeed
+func ConnectionError(err error) bool {
+	switch err {
+	case driver.ErrBadConn, gosql.ErrConnDone, mysql.ErrInvalidConn, io.EOF, io.ErrUnexpectedEOF:
+		return true
+	}
+	if _, ok := err.(net.Error); ok {
+		return true
+	}
+	mysqlErr, ok := err.(*mysql.MySQLError)
+	if !ok {
+		return false
+	}
+
+	switch mysqlErr.Number {
+	case ErrServerShutdown, ErrQueryInterrupted, ErrConCount:
+		return true
+	default:
+		return false
+	}
+}
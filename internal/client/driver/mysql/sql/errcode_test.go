@@ -0,0 +1,44 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package sql
+
+import (
+	gosql "database/sql"
+	"database/sql/driver"
+	"fmt"
+	"io"
+	"net"
+	"testing"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+func TestConnectionError(t *testing.T) {
+	for _, tt := range []struct {
+		err  error
+		want bool
+	}{
+		{driver.ErrBadConn, true},
+		{gosql.ErrConnDone, true},
+		{mysql.ErrInvalidConn, true},
+		{io.EOF, true},
+		{io.ErrUnexpectedEOF, true},
+		{&net.OpError{Op: "read", Net: "tcp", Err: fmt.Errorf("connection reset by peer")}, true},
+		{&mysql.MySQLError{Number: ErrServerShutdown}, true},
+		{&mysql.MySQLError{Number: ErrQueryInterrupted}, true},
+		{&mysql.MySQLError{Number: ErrConCount}, true},
+		{&mysql.MySQLError{Number: ErrDupEntry}, false},
+		{&mysql.MySQLError{Number: ErrLockDeadlock}, false},
+		{gosql.ErrNoRows, false},
+		{fmt.Errorf("unknown"), false},
+		{nil, false},
+	} {
+		if got := ConnectionError(tt.err); got != tt.want {
+			t.Errorf("ConnectionError(%v) = %v, want %v", tt.err, got, tt.want)
+		}
+	}
+}
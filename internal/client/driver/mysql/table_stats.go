@@ -0,0 +1,171 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package mysql
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/actiontech/dtle/internal/client/driver/mysql/binlog"
+	"github.com/actiontech/dtle/internal/models"
+)
+
+const (
+	// indexAdvisoryMinScans is the rows of a table applied by full scans
+	// before an index is advised
+	indexAdvisoryMinScans = 100
+	// indexAdvisoryMinAvgScan is the average time of the scans before an
+	// index is advised, for the scans of small tables are cheap
+	indexAdvisoryMinAvgScan = 5 * time.Millisecond
+)
+
+// tableApplyStats counts the rows applied to each table by all workers
+type tableApplyStats struct {
+	mutex sync.Mutex
+	// tables are by "schema.table"
+	tables map[string]*tableApplyStat
+}
+
+type tableApplyStat struct {
+	models.TableApplyStat
+	applyTime time.Duration
+	// scanCount and scanTime are those of the rows updated or deleted by a
+	// full scan
+	scanCount int64
+	scanTime  time.Duration
+}
+
+func newTableApplyStats() *tableApplyStats {
+	return &tableApplyStats{
+		tables: make(map[string]*tableApplyStat),
+	}
+}
+
+// txTableStats counts the rows of a transaction, added to tableApplyStats
+// once it commits, so that retried transactions are counted once
+type txTableStats map[string]*tableApplyStat
+
+func (t txTableStats) add(event *binlog.DataEvent, args []interface{}, applyTime time.Duration) {
+	key := fmt.Sprintf("%s.%s", event.DatabaseName, event.TableName)
+	stat, ok := t[key]
+	if !ok {
+		stat = &tableApplyStat{}
+		stat.TableSchema = event.DatabaseName
+		stat.TableName = event.TableName
+		t[key] = stat
+	}
+	switch event.DML {
+	case binlog.InsertDML:
+		stat.InsertCount++
+	case binlog.UpdateDML:
+		stat.UpdateCount++
+	case binlog.DeleteDML:
+		stat.DeleteCount++
+	}
+	stat.Bytes += argsSize(args)
+	stat.applyTime += applyTime
+	if event.DML != binlog.InsertDML {
+		if item, ok := event.TableItem.(*applierTableItem); ok && item.unindexed {
+			stat.scanCount++
+			stat.scanTime += applyTime
+		}
+	}
+}
+
+func (s *tableApplyStats) addTx(t txTableStats) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	for key, txStat := range t {
+		stat, ok := s.tables[key]
+		if !ok {
+			stat = &tableApplyStat{}
+			stat.TableSchema = txStat.TableSchema
+			stat.TableName = txStat.TableName
+			s.tables[key] = stat
+		}
+		stat.InsertCount += txStat.InsertCount
+		stat.UpdateCount += txStat.UpdateCount
+		stat.DeleteCount += txStat.DeleteCount
+		stat.Bytes += txStat.Bytes
+		stat.applyTime += txStat.applyTime
+		stat.scanCount += txStat.scanCount
+		stat.scanTime += txStat.scanTime
+	}
+}
+
+// stats returns the counts of the tables, the longest to apply first
+func (s *tableApplyStats) stats() []*models.TableApplyStat {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	stats := make([]*models.TableApplyStat, 0, len(s.tables))
+	for _, stat := range s.tables {
+		result := stat.TableApplyStat
+		result.TotalApplyMillis = int64(stat.applyTime / time.Millisecond)
+		if rows := stat.InsertCount + stat.UpdateCount + stat.DeleteCount; rows > 0 {
+			result.AvgApplyMicros = int64(stat.applyTime/time.Microsecond) / rows
+		}
+		stats = append(stats, &result)
+	}
+	sort.Slice(stats, func(i, j int) bool {
+		if stats[i].TotalApplyMillis != stats[j].TotalApplyMillis {
+			return stats[i].TotalApplyMillis > stats[j].TotalApplyMillis
+		}
+		return stats[i].TableSchema+"."+stats[i].TableName < stats[j].TableSchema+"."+stats[j].TableName
+	})
+	return stats
+}
+
+// indexAdvisories returns an advisory for each table whose updates and
+// deletes were repeatedly applied by slow full scans, the slowest first
+func (s *tableApplyStats) indexAdvisories() []*models.IndexAdvisory {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	var advisories []*models.IndexAdvisory
+	for _, stat := range s.tables {
+		if stat.scanCount < indexAdvisoryMinScans {
+			continue
+		}
+		avgScan := stat.scanTime / time.Duration(stat.scanCount)
+		if avgScan < indexAdvisoryMinAvgScan {
+			continue
+		}
+		advisories = append(advisories, &models.IndexAdvisory{
+			TableSchema:   stat.TableSchema,
+			TableName:     stat.TableName,
+			ScanCount:     stat.scanCount,
+			AvgScanMillis: int64(avgScan / time.Millisecond),
+			Message: fmt.Sprintf("no column of table %s.%s is indexed on the target, %d rows were updated or deleted"+
+				" by full scans of %v on average: add a primary key, a unique key or an index to the table on the target",
+				stat.TableSchema, stat.TableName, stat.scanCount, avgScan-avgScan%time.Millisecond),
+		})
+	}
+	sort.Slice(advisories, func(i, j int) bool {
+		if advisories[i].AvgScanMillis != advisories[j].AvgScanMillis {
+			return advisories[i].AvgScanMillis > advisories[j].AvgScanMillis
+		}
+		return advisories[i].TableSchema+"."+advisories[i].TableName < advisories[j].TableSchema+"."+advisories[j].TableName
+	})
+	return advisories
+}
+
+// argsSize estimates the bytes of the arguments of a statement
+func argsSize(args []interface{}) (size int64) {
+	for _, arg := range args {
+		switch v := arg.(type) {
+		case nil:
+		case []byte:
+			size += int64(len(v))
+		case string:
+			size += int64(len(v))
+		default:
+			size += 8
+		}
+	}
+	return size
+}
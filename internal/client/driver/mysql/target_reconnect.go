@@ -0,0 +1,106 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package mysql
+
+import (
+	"context"
+	"time"
+
+	"github.com/actiontech/dtle/internal/client/driver/mysql/base"
+	"github.com/actiontech/dtle/internal/client/driver/mysql/binlog"
+	"github.com/actiontech/dtle/internal/client/driver/mysql/sql"
+	"github.com/actiontech/dtle/internal/models"
+)
+
+// targetReconnectInitialBackoff is the wait before the first reconnection
+// to a lost target, doubled at each next one
+const targetReconnectInitialBackoff = time.Second
+
+// waitForTarget reconnects a worker which lost its connection to the
+// target, waiting longer between each attempt. Past the TargetOutageTimeout
+// the job is blocked rather than failed: the stage tells so and the
+// reconnections go on until the target is back or the task is shut down.
+func (a *Applier) waitForTarget(workerIdx int, cause error) error {
+	start := time.Now()
+	outage := time.Duration(a.mysqlContext.TargetOutageTimeout) * time.Second
+	backoff := targetReconnectInitialBackoff
+	maxBackoff := time.Duration(a.mysqlContext.TargetReconnectMaxBackoff) * time.Millisecond
+	blocked := false
+	for {
+		a.logger.Warnf("mysql.applier: worker %d lost the connection to the target, reconnecting in %v: %v",
+			workerIdx, backoff, cause)
+		select {
+		case <-time.After(backoff):
+		case <-a.shutdownCh:
+			return cause
+		}
+		err := a.reconnectWorker(workerIdx)
+		if err == nil {
+			a.logger.Printf("mysql.applier: worker %d reconnected to the target after %v",
+				workerIdx, time.Since(start))
+			return nil
+		}
+		cause = err
+		if !blocked && time.Since(start) >= outage {
+			blocked = true
+			a.mysqlContext.Stage = models.StageBlockedOnTarget
+			a.logger.Errorf("mysql.applier: the target is unreachable for %v, the job is blocked until it is back: %v",
+				time.Since(start), err)
+		}
+		if backoff *= 2; backoff > maxBackoff {
+			backoff = maxBackoff
+		}
+	}
+}
+
+// reconnectWorker replaces the connection of a worker with a new one, set
+// up as in initDBConnections. The statements of the tables are prepared
+// again on their next use.
+func (a *Applier) reconnectWorker(workerIdx int) error {
+	conns, err := sql.CreateConns(a.db, 1)
+	if err != nil {
+		return err
+	}
+	conn := conns[0]
+	for _, query := range a.sessionStatements() {
+		if _, err := conn.Db.ExecContext(context.Background(), query); err != nil {
+			conn.Db.Close()
+			return err
+		}
+	}
+	if err := a.prepareGtidExecutedStmts(conn); err != nil {
+		conn.Db.Close()
+		return err
+	}
+
+	dbApplier := a.dbs[workerIdx]
+	dbApplier.Db.Close()
+	dbApplier.Db = conn.Db
+	dbApplier.PsDeleteExecutedGtid = conn.PsDeleteExecutedGtid
+	dbApplier.PsInsertExecutedGtid = conn.PsInsertExecutedGtid
+	return nil
+}
+
+// txCommitted tells whether the target committed a transaction, as
+// recorded in the gtid_executed table along with it, for a transaction
+// whose connection was lost may have committed
+func (a *Applier) txCommitted(workerIdx int, binlogEntry *binlog.BinlogEntry) (bool, error) {
+	gtidSet, err := base.SelectAllGtidExecuted(a.db, a.subjectUUID)
+	if err != nil {
+		return false, err
+	}
+	item, ok := gtidSet[binlogEntry.Coordinates.SID]
+	if !ok {
+		return false, nil
+	}
+	committed := base.IntervalSlicesContainOne(item.Intervals, binlogEntry.Coordinates.GNO)
+	if committed {
+		a.logger.Printf("mysql.applier: worker %d: gtid %s:%d was committed before the connection was lost",
+			workerIdx, binlogEntry.Coordinates.GetSid(), binlogEntry.Coordinates.GNO)
+	}
+	return committed, nil
+}
@@ -0,0 +1,60 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package mysql
+
+import (
+	"database/sql/driver"
+	"io/ioutil"
+	"testing"
+
+	"github.com/satori/go.uuid"
+
+	"github.com/actiontech/dtle/internal/client/driver/mysql/base"
+	"github.com/actiontech/dtle/internal/client/driver/mysql/binlog"
+	log "github.com/actiontech/dtle/internal/logger"
+)
+
+func TestApplier_txCommitted(t *testing.T) {
+	sid := uuid.NewV5(uuid.NamespaceOID, "source")
+	other := uuid.NewV5(uuid.NamespaceOID, "other")
+	fake := newFakeDB()
+	fake.answer("SELECT source_uuid,interval_gtid", []string{"source_uuid", "interval_gtid"},
+		[]driver.Value{sid.Bytes(), "1-10:15-20"})
+	a := &Applier{
+		logger:      log.NewEntry(log.New(ioutil.Discard, log.InfoLevel)),
+		db:          fake.open(),
+		subjectUUID: uuid.NewV5(uuid.NamespaceOID, "job"),
+	}
+
+	for _, tt := range []struct {
+		sid  uuid.UUID
+		gno  int64
+		want bool
+	}{
+		{sid, 1, true},
+		{sid, 10, true},
+		{sid, 11, false},
+		{sid, 17, true},
+		{sid, 21, false},
+		{other, 5, false},
+	} {
+		entry := binlog.NewBinlogEntryAt(base.BinlogCoordinateTx{SID: tt.sid, GNO: tt.gno})
+		got, err := a.txCommitted(0, entry)
+		if err != nil {
+			t.Fatalf("Applier.txCommitted() error = %v", err)
+		}
+		if got != tt.want {
+			t.Errorf("Applier.txCommitted(%s:%d) = %v, want %v", tt.sid, tt.gno, got, tt.want)
+		}
+	}
+
+	// The gtid_executed table not being readable is an error
+	a.db = newFakeDB().open()
+	if _, err := a.txCommitted(0, binlog.NewBinlogEntryAt(base.BinlogCoordinateTx{SID: sid, GNO: 1})); err == nil {
+		t.Errorf("Applier.txCommitted() of an unreadable gtid_executed got no error")
+	}
+}
@@ -0,0 +1,122 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package mysql
+
+import (
+	gosql "database/sql"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/actiontech/dtle/internal/client/driver/mysql/sql"
+	"github.com/actiontech/dtle/internal/config"
+)
+
+// tidbUnsupportedDDLRegexp matches the statements TiDB does not support:
+// those of the stored programs, and the full-text and spatial indexes
+var tidbUnsupportedDDLRegexp = regexp.MustCompile(`(?is)^\s*(?:` +
+	`CREATE\s+(?:DEFINER\s*=\s*\S+\s+)?(?:AGGREGATE\s+)?(?:TRIGGER|PROCEDURE|FUNCTION|EVENT)` +
+	`|DROP\s+(?:TRIGGER|PROCEDURE|FUNCTION|EVENT)` +
+	`|ALTER\s+(?:DEFINER\s*=\s*\S+\s+)?(?:PROCEDURE|FUNCTION|EVENT)` +
+	`|CREATE\s+(?:FULLTEXT|SPATIAL)\s+INDEX)\b`)
+
+// DetectTargetServer returns the server of a target: the configured one if
+// any, else the one detected from its version, which TiDB suffixes with
+// "-TiDB-" and its own version.
+func DetectTargetServer(db *gosql.DB, configured string) (string, error) {
+	switch configured {
+	case config.TargetServerMySQL, config.TargetServerTiDB:
+		return configured, nil
+	case "":
+	default:
+		return "", fmt.Errorf("unknown TargetServer %q: must be %q or %q",
+			configured, config.TargetServerMySQL, config.TargetServerTiDB)
+	}
+
+	var version string
+	if err := db.QueryRow("select @@global.version").Scan(&version); err != nil {
+		return "", err
+	}
+	if strings.Contains(version, "TiDB") {
+		return config.TargetServerTiDB, nil
+	}
+	return config.TargetServerMySQL, nil
+}
+
+// detectTargetServer sets whether the target is TiDB, before the sessions
+// of the applier are set up
+func (a *Applier) detectTargetServer() (err error) {
+	if a.mysqlContext.TargetServer, err = DetectTargetServer(a.db, a.mysqlContext.TargetServer); err != nil {
+		return err
+	}
+	a.tidb = a.mysqlContext.TargetServer == config.TargetServerTiDB
+	if a.tidb {
+		a.logger.Printf("mysql.applier: %s:%d is TiDB",
+			a.mysqlContext.ConnectionConfig.Host, a.mysqlContext.ConnectionConfig.Port)
+	}
+	return nil
+}
+
+// skipsDDL tells whether a DDL is skipped, as not supported by the target
+func (a *Applier) skipsDDL(query string) bool {
+	if !a.tidb || !tidbUnsupportedDDLRegexp.MatchString(query) {
+		return false
+	}
+	a.logger.Warnf("mysql.applier: skipping a statement TiDB does not support: %s", query)
+	return true
+}
+
+// retryableError tells whether a transaction failing with the error may
+// succeed when retried, by the class of the error. A transaction whose
+// connection was lost may have been committed, and is only retried once
+// checked, with the TargetOutageTimeout. The injected faults are retryable.
+func (a *Applier) retryableError(err error) bool {
+	if _, ok := err.(*config.FaultError); ok {
+		return true
+	}
+	return a.errorClasses.Classify(err) == sql.ErrorClassRetryable && !sql.ConnectionError(err)
+}
+
+// snapshotBatchRows returns the maximum number of rows of an insert of the
+// snapshot, 0 for no limit but the size of the statement. TiDB takes smaller
+// batches, each one being split into requests to its storage.
+func (a *Applier) snapshotBatchRows() int {
+	if a.tidb {
+		return a.mysqlContext.TiDBBatchRows
+	}
+	return 0
+}
+
+// stripTiDBUnsupportedIndexes removes the full-text and spatial indexes from
+// a CREATE TABLE, as printed by SHOW CREATE TABLE
+func stripTiDBUnsupportedIndexes(query string) string {
+	if !strings.HasPrefix(query, "CREATE TABLE ") {
+		return query
+	}
+	lines := strings.Split(query, "\n")
+	kept := lines[:0]
+	stripped := false
+	for _, line := range lines {
+		definition := strings.TrimSpace(line)
+		if strings.HasPrefix(definition, "FULLTEXT KEY ") || strings.HasPrefix(definition, "SPATIAL KEY ") {
+			stripped = true
+			continue
+		}
+		kept = append(kept, line)
+	}
+	if !stripped {
+		return query
+	}
+	// The definition before the table options ends without a comma
+	for i := 1; i < len(kept); i++ {
+		if strings.HasPrefix(kept[i], ")") {
+			kept[i-1] = strings.TrimSuffix(kept[i-1], ",")
+			break
+		}
+	}
+	return strings.Join(kept, "\n")
+}
@@ -0,0 +1,127 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package mysql
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/actiontech/dtle/internal/client/driver/mysql/binlog"
+	"github.com/actiontech/dtle/internal/config"
+)
+
+// txGroup is the source transactions coalesced into a transaction of the
+// target, committed once it has MaxRows rows or MaxBytes bytes, or after
+// MaxWaitMs, as scaled to the LatencyTargetMs of the job. The gtid_executed
+// rows of the transactions are written in the transaction of the target, a
+// group being applied entirely or not at all.
+type txGroup struct {
+	cfg     *config.TxGroupConfig
+	entries []*binlog.BinlogEntry
+	rows    int
+	bytes   int
+	// deadline fires once the group waited MaxWaitMs, nil if it is empty
+	deadline <-chan time.Time
+}
+
+// has tells whether a source transaction is in the group, yet to be
+// committed
+func (g *txGroup) has(binlogEntry *binlog.BinlogEntry) bool {
+	if g == nil {
+		return false
+	}
+	for _, entry := range g.entries {
+		if entry.Coordinates.SID == binlogEntry.Coordinates.SID && entry.Coordinates.GNO == binlogEntry.Coordinates.GNO {
+			return true
+		}
+	}
+	return false
+}
+
+// timeout returns the channel the group is committed on if it waits too
+// long, nil if there is no group
+func (g *txGroup) timeout() <-chan time.Time {
+	if g == nil {
+		return nil
+	}
+	return g.deadline
+}
+
+// groupBinlogEntry adds a source transaction to the group, which is
+// committed once full. A transaction with a DDL is applied alone, after the
+// group.
+func (a *Applier) groupBinlogEntry(binlogEntry *binlog.BinlogEntry) error {
+	g := a.txGroup
+	if hasDDL(binlogEntry) {
+		if err := a.flushTxGroup(); err != nil {
+			return err
+		}
+		if err := a.setTableItemForBinlogEntry(binlogEntry); err != nil {
+			return err
+		}
+		if err := a.ApplyBinlogEvent(0, binlogEntry); err != nil {
+			return err
+		}
+		a.txGroupCommitted([]*binlog.BinlogEntry{binlogEntry})
+		return nil
+	}
+
+	if err := a.setTableItemForBinlogEntry(binlogEntry); err != nil {
+		return err
+	}
+	if len(g.entries) == 0 {
+		g.deadline = time.After(time.Duration(a.latency.scaled(g.cfg.MaxWaitMs)) * time.Millisecond)
+	}
+	g.entries = append(g.entries, binlogEntry)
+	g.rows += len(binlogEntry.Events)
+	g.bytes += binlogEntry.OriginalSize
+	if g.rows >= a.latency.scaled(g.cfg.MaxRows) || g.bytes >= a.latency.scaled(g.cfg.MaxBytes) {
+		return a.flushTxGroup()
+	}
+	return nil
+}
+
+// flushTxGroup commits the transactions of the group in a transaction of
+// the target. They are recorded as executed once committed only, the group
+// being applied entirely or not at all.
+func (a *Applier) flushTxGroup() error {
+	g := a.txGroup
+	if len(g.entries) == 0 {
+		return nil
+	}
+	entries := g.entries
+	g.entries, g.rows, g.bytes, g.deadline = nil, 0, 0, nil
+	a.logger.Debugf("mysql.applier: committing a group of %d transactions, up to gno %d",
+		len(entries), entries[len(entries)-1].Coordinates.GNO)
+	if err := a.applyBinlogEntries(0, entries); err != nil {
+		return err
+	}
+	a.txGroupCommitted(entries)
+	return nil
+}
+
+// txGroupCommitted records the source transactions committed as executed
+func (a *Applier) txGroupCommitted(entries []*binlog.BinlogEntry) {
+	for _, entry := range entries {
+		a.addGtidExecuted(entry)
+	}
+	if !a.shutdown {
+		last := entries[len(entries)-1]
+		a.mysqlContext.Gtid = fmt.Sprintf("%s:1-%d", last.Coordinates.GetSid(), last.Coordinates.GNO)
+	}
+}
+
+// hasDDL tells whether a source transaction has a statement other than
+// row events
+func hasDDL(binlogEntry *binlog.BinlogEntry) bool {
+	for i := range binlogEntry.Events {
+		if binlogEntry.Events[i].DML == binlog.NotDML {
+			return true
+		}
+	}
+	return false
+}
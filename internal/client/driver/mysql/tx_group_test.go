@@ -0,0 +1,150 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package mysql
+
+import (
+	"io/ioutil"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/actiontech/dtle/internal/client/driver/mysql/base"
+	"github.com/actiontech/dtle/internal/client/driver/mysql/binlog"
+	"github.com/actiontech/dtle/internal/client/driver/mysql/sql"
+	"github.com/actiontech/dtle/internal/config"
+	log "github.com/actiontech/dtle/internal/logger"
+	"github.com/satori/go.uuid"
+)
+
+var txGroupTestSID = uuid.NewV4()
+
+// newTxGroupApplier returns an applier grouping the transactions in a dry
+// run, committing the groups of maxBytes bytes
+func newTxGroupApplier(t *testing.T, maxBytes int) *Applier {
+	file, err := ioutil.TempFile("", "tx_group")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		file.Close()
+		os.Remove(file.Name())
+	})
+	return &Applier{
+		logger:       log.NewEntry(log.New(os.Stdout, log.DebugLevel)),
+		mysqlContext: &config.MySQLDriverConfig{},
+		dbs:          []*sql.Conn{{DbMutex: &sync.Mutex{}}},
+		gtidExecuted: base.GtidSet{},
+		dryRun:       &dryRunWriter{file: file},
+		txGroup: &txGroup{cfg: &config.TxGroupConfig{
+			MaxRows: 1000, MaxBytes: maxBytes, MaxWaitMs: 60000,
+		}},
+	}
+}
+
+func newTxGroupEntry(gno int64, events ...binlog.DataEvent) *binlog.BinlogEntry {
+	entry := &binlog.BinlogEntry{Events: events, OriginalSize: 10}
+	entry.Coordinates.SID = txGroupTestSID
+	entry.Coordinates.GNO = gno
+	return entry
+}
+
+// executedGtids returns the intervals the applier has recorded as executed
+func executedGtids(a *Applier) string {
+	item, ok := a.gtidExecuted[txGroupTestSID]
+	if !ok {
+		return ""
+	}
+	return base.StringInterval(item.Intervals)
+}
+
+func TestApplier_groupBinlogEntry(t *testing.T) {
+	a := newTxGroupApplier(t, 20)
+
+	if err := a.groupBinlogEntry(newTxGroupEntry(1)); err != nil {
+		t.Fatal(err)
+	}
+	// pending: not recorded as executed yet
+	if got := executedGtids(a); got != "" {
+		t.Errorf("executed %q with a pending group", got)
+	}
+	if a.mysqlContext.Gtid != "" {
+		t.Errorf("gtid %q with a pending group", a.mysqlContext.Gtid)
+	}
+	if !a.txGroup.has(newTxGroupEntry(1)) || a.txGroup.has(newTxGroupEntry(2)) {
+		t.Errorf("pending transactions %v, want gno 1", a.txGroup.entries)
+	}
+
+	// full: committed
+	if err := a.groupBinlogEntry(newTxGroupEntry(2)); err != nil {
+		t.Fatal(err)
+	}
+	if len(a.txGroup.entries) != 0 {
+		t.Errorf("%d transactions pending after the group is full", len(a.txGroup.entries))
+	}
+	if got, want := executedGtids(a), "1-2"; got != want {
+		t.Errorf("executed %q, want %q", got, want)
+	}
+	if got, want := a.mysqlContext.Gtid, txGroupTestSID.String()+":1-2"; got != want {
+		t.Errorf("gtid %q, want %q", got, want)
+	}
+	if a.txGroup.has(newTxGroupEntry(1)) {
+		t.Errorf("gno 1 pending after the group is committed")
+	}
+
+	// a DDL commits the group before it
+	if err := a.groupBinlogEntry(newTxGroupEntry(3)); err != nil {
+		t.Fatal(err)
+	}
+	ddl := newTxGroupEntry(4, binlog.DataEvent{DML: binlog.NotDML, Query: "CREATE TABLE t (id INT)"})
+	if err := a.groupBinlogEntry(ddl); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := executedGtids(a), "1-4"; got != want {
+		t.Errorf("executed %q, want %q", got, want)
+	}
+	if got, want := a.mysqlContext.Gtid, txGroupTestSID.String()+":1-4"; got != want {
+		t.Errorf("gtid %q, want %q", got, want)
+	}
+	bs, err := ioutil.ReadFile(a.dryRun.file.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := strings.Count(string(bs), "COMMIT;"), 3; got != want {
+		t.Errorf("%d transactions committed, want %d:\n%s", got, want, bs)
+	}
+}
+
+func TestApplier_flushTxGroup(t *testing.T) {
+	a := newTxGroupApplier(t, 100)
+	for gno := int64(1); gno <= 3; gno++ {
+		if err := a.groupBinlogEntry(newTxGroupEntry(gno)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// a group failing to commit is not recorded as executed
+	a.dryRun.file.Close()
+	if err := a.flushTxGroup(); err == nil {
+		t.Fatalf("no error committing a group on a closed file")
+	}
+	if got := executedGtids(a); got != "" {
+		t.Errorf("executed %q after the group failed", got)
+	}
+	if a.mysqlContext.Gtid != "" {
+		t.Errorf("gtid %q after the group failed", a.mysqlContext.Gtid)
+	}
+
+	// nothing to commit
+	if err := a.flushTxGroup(); err != nil {
+		t.Errorf("committing an empty group: %v", err)
+	}
+	var g *txGroup
+	if g.has(newTxGroupEntry(1)) || g.timeout() != nil {
+		t.Errorf("nil group has a transaction or a timeout")
+	}
+}
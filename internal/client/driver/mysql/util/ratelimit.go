@@ -0,0 +1,90 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package util
+
+import (
+	"sync"
+	"time"
+)
+
+// RateLimiter is a token bucket limiting the number of bytes sent per
+// second. Up to one second worth of bytes may be sent in a burst. The rate
+// can be changed while the limiter is in use, a rate of 0 is unlimited.
+type RateLimiter struct {
+	mu     sync.Mutex
+	rate   int64
+	tokens float64
+	last   time.Time
+}
+
+// NewRateLimiter returns a limiter allowing rate bytes per second
+func NewRateLimiter(rate int64) *RateLimiter {
+	return &RateLimiter{rate: rate}
+}
+
+// SetRate changes the number of bytes allowed per second
+func (l *RateLimiter) SetRate(rate int64) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if rate == l.rate {
+		return
+	}
+	l.rate = rate
+	if l.tokens > float64(rate) {
+		l.tokens = float64(rate)
+	}
+}
+
+// Rate returns the number of bytes allowed per second
+func (l *RateLimiter) Rate() int64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.rate
+}
+
+// Wait blocks until n bytes may be sent or cancelCh is closed
+func (l *RateLimiter) Wait(n int, cancelCh <-chan struct{}) {
+	delay := l.reserve(n, time.Now())
+	if delay <= 0 {
+		return
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+	case <-cancelCh:
+	}
+}
+
+// reserve takes n bytes from the bucket and returns how long the caller has
+// to wait before sending them
+func (l *RateLimiter) reserve(n int, now time.Time) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.rate <= 0 {
+		l.last = time.Time{}
+		return 0
+	}
+
+	// Refill the bucket for the time elapsed since the last call
+	if l.last.IsZero() {
+		l.tokens = float64(l.rate)
+	} else if elapsed := now.Sub(l.last); elapsed > 0 {
+		l.tokens += elapsed.Seconds() * float64(l.rate)
+		if l.tokens > float64(l.rate) {
+			l.tokens = float64(l.rate)
+		}
+	}
+	l.last = now
+
+	l.tokens -= float64(n)
+	if l.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-l.tokens / float64(l.rate) * float64(time.Second))
+}
@@ -0,0 +1,61 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package util
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRateLimiter_Unlimited(t *testing.T) {
+	l := NewRateLimiter(0)
+	if d := l.reserve(1<<30, time.Now()); d != 0 {
+		t.Fatalf("expected no delay, got %v", d)
+	}
+}
+
+func TestRateLimiter_Reserve(t *testing.T) {
+	l := NewRateLimiter(1000)
+	now := time.Unix(0, 0)
+
+	// The first second worth of bytes is sent right away
+	if d := l.reserve(1000, now); d != 0 {
+		t.Fatalf("expected no delay, got %v", d)
+	}
+
+	// The bucket is empty, 500 more bytes take half a second
+	if d := l.reserve(500, now); d != 500*time.Millisecond {
+		t.Fatalf("expected 500ms delay, got %v", d)
+	}
+
+	// After the wait the bucket is refilled by the elapsed time
+	now = now.Add(1500 * time.Millisecond)
+	if d := l.reserve(1000, now); d != 0 {
+		t.Fatalf("expected no delay, got %v", d)
+	}
+}
+
+func TestRateLimiter_SetRate(t *testing.T) {
+	l := NewRateLimiter(1000)
+	now := time.Unix(0, 0)
+	l.reserve(0, now)
+
+	// Lowering the rate also lowers the burst
+	l.SetRate(100)
+	if d := l.reserve(200, now); d != time.Second {
+		t.Fatalf("expected 1s delay, got %v", d)
+	}
+
+	// Removing the limit lets everything through
+	l.SetRate(0)
+	if d := l.reserve(1<<20, now); d != 0 {
+		t.Fatalf("expected no delay, got %v", d)
+	}
+	if l.Rate() != 0 {
+		t.Fatalf("bad rate: %d", l.Rate())
+	}
+}
@@ -0,0 +1,119 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package oracle
+
+import (
+	"fmt"
+	"net/url"
+
+	"github.com/actiontech/dtle/internal/config"
+)
+
+const (
+	defaultPort             = 1521
+	defaultChunkSize        = 2000
+	defaultLogMinerInterval = 1000
+	defaultLogMinerMaxScns  = 100000
+)
+
+// The ways the changes are read from the redo logs
+const (
+	// CaptureModeLogMiner reads the changes with LogMiner, as any Oracle
+	// edition from 11g on allows
+	CaptureModeLogMiner = "logminer"
+	// CaptureModeXStream reads them from an XStream outbound server, which
+	// needs a GoldenGate license and the OCI client
+	CaptureModeXStream = "xstream"
+)
+
+// ConnectionConfig is the Oracle database a task connects to
+type ConnectionConfig struct {
+	Host        string
+	Port        int
+	ServiceName string
+	User        string
+	Password    string
+}
+
+// DSN returns the URL the Oracle driver connects with
+func (c *ConnectionConfig) DSN() string {
+	port := c.Port
+	if port == 0 {
+		port = defaultPort
+	}
+	return fmt.Sprintf("oracle://%s:%s@%s:%d/%s", url.PathEscape(c.User), url.PathEscape(c.Password),
+		c.Host, port, url.PathEscape(c.ServiceName))
+}
+
+// OracleConfig is the config of an Oracle source task
+type OracleConfig struct {
+	ConnectionConfig *ConnectionConfig
+	// ReplicateDoDb are the schemas, and their tables, replicated. A schema
+	// without tables is replicated whole.
+	ReplicateDoDb []*config.DataSource
+	NatsAddr      string
+	// ChunkSize is the number of rows of a table sent at a time in the
+	// snapshot
+	ChunkSize int64
+
+	// CaptureMode is how the changes are read from the redo logs:
+	// "logminer", the default, or "xstream"
+	CaptureMode string
+	// Scn is the SCN the changes are read from, skipping the snapshot. With
+	// neither Scn nor Gtid, a snapshot of the tables is copied, then the
+	// changes are read from its SCN.
+	Scn int64
+	// Gtid is the last transaction replicated, as reported in the
+	// statistics: "uuid:1-commit SCN". The transactions committed at or
+	// before it are not sent again when the changes are read from Scn.
+	// Without Scn, the changes are read from the start of the oldest
+	// transaction open, if before it: those of the transactions committed
+	// in between are lost.
+	Gtid string
+	// LogMinerInterval is the milliseconds between two reads of the redo
+	// logs with LogMiner, once it caught up with the database
+	LogMinerInterval int
+	// LogMinerMaxScns is the largest range of SCNs LogMiner reads at a time
+	LogMinerMaxScns int64
+}
+
+// SetDefault returns a copy of the config with the defaults set
+func (c *OracleConfig) SetDefault() *OracleConfig {
+	result := *c
+	if result.ChunkSize <= 0 {
+		result.ChunkSize = defaultChunkSize
+	}
+	if result.CaptureMode == "" {
+		result.CaptureMode = CaptureModeLogMiner
+	}
+	if result.LogMinerInterval <= 0 {
+		result.LogMinerInterval = defaultLogMinerInterval
+	}
+	if result.LogMinerMaxScns <= 0 {
+		result.LogMinerMaxScns = defaultLogMinerMaxScns
+	}
+	return &result
+}
+
+// Validate checks the config of a source task
+func (c *OracleConfig) Validate() error {
+	if c.ConnectionConfig == nil {
+		return fmt.Errorf("ConnectionConfig is missing")
+	}
+	switch c.CaptureMode {
+	case CaptureModeLogMiner:
+	case CaptureModeXStream:
+		return fmt.Errorf("CaptureMode %q is not supported by this build, which reads the changes with LogMiner",
+			CaptureModeXStream)
+	default:
+		return fmt.Errorf("unknown CaptureMode %q: must be %q or %q", c.CaptureMode, CaptureModeLogMiner, CaptureModeXStream)
+	}
+	if len(c.ReplicateDoDb) == 0 {
+		return fmt.Errorf("ReplicateDoDb is empty: the schemas of an Oracle source must be listed")
+	}
+	return nil
+}
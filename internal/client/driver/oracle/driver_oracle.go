@@ -0,0 +1,15 @@
+//go:build oracle
+// +build oracle
+
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package oracle
+
+import (
+	// The pure Go driver, registered as "oracle"
+	_ "github.com/sijms/go-ora"
+)
@@ -0,0 +1,240 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package oracle
+
+import (
+	gosql "database/sql"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"sync/atomic"
+
+	"github.com/satori/go.uuid"
+
+	"github.com/actiontech/dtle/internal/client/driver/hete"
+	"github.com/actiontech/dtle/internal/client/driver/mysql"
+	log "github.com/actiontech/dtle/internal/logger"
+	"github.com/actiontech/dtle/internal/models"
+)
+
+// driverName is the database/sql driver connecting to Oracle, registered by
+// the build with the "oracle" tag
+const driverName = "oracle"
+
+// Extractor reads the tables and the changes of an Oracle database, and
+// sends them to the target task as the MySQL extractor does
+type Extractor struct {
+	logger     *log.Entry
+	subject    string
+	maxPayload int
+	cfg        *OracleConfig
+	db         *gosql.DB
+	publisher  *hete.Publisher
+	waitCh     chan *models.WaitResult
+
+	// sid stands for the database in the GTIDs of the transactions, whose
+	// GNO is the commit SCN
+	sid uuid.UUID
+	// tables are those replicated, by "schema.table"
+	tables map[string]*table
+	// tableSent tells whether the structure of a table was sent along with
+	// its rows since it was loaded
+	tableSent map[string]bool
+
+	// restartScn is the SCN the changes are read from on a restart: the
+	// start of the oldest transaction not committed yet
+	restartScn int64
+	// watermark is the commit SCN of the last transaction sent
+	watermark int64
+
+	stage string
+
+	shutdown     bool
+	shutdownCh   chan struct{}
+	shutdownLock sync.Mutex
+}
+
+func NewExtractor(subject, tp string, maxPayload int, cfg *OracleConfig, logger *log.Logger) (*Extractor, error) {
+	cfg = cfg.SetDefault()
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	entry := log.NewEntry(logger).WithFields(log.Fields{
+		"job": subject,
+	})
+	e := &Extractor{
+		logger:     entry,
+		subject:    subject,
+		maxPayload: maxPayload,
+		cfg:        cfg,
+		waitCh:     make(chan *models.WaitResult, 1),
+		tables:     make(map[string]*table),
+		tableSent:  make(map[string]bool),
+		shutdownCh: make(chan struct{}),
+	}
+	return e, nil
+}
+
+func (e *Extractor) Run() {
+	defer models.RecoverPanic(e.onPanic)
+	e.logger.Printf("oracle.extractor: Extract changes from %s:%d/%s", e.cfg.ConnectionConfig.Host,
+		e.cfg.ConnectionConfig.Port, e.cfg.ConnectionConfig.ServiceName)
+
+	if err := e.initDBConnection(); err != nil {
+		e.onError(mysql.TaskStateDead, err)
+		return
+	}
+	if err := e.initNatsPubClient(); err != nil {
+		e.onError(mysql.TaskStateDead, err)
+		return
+	}
+
+	if e.cfg.Scn == 0 && e.cfg.Gtid == "" {
+		if err := e.snapshot(); err != nil {
+			e.onError(mysql.TaskStateDead, err)
+			return
+		}
+	} else {
+		watermark, err := hete.ParseGtid(e.sid, e.cfg.Gtid)
+		if err != nil {
+			e.onError(mysql.TaskStateDead, err)
+			return
+		}
+		e.watermark = watermark
+		e.restartScn = e.cfg.Scn
+		if e.restartScn == 0 {
+			// Restarted with the Gtid saved only
+			e.restartScn = e.oldestOpenScn(watermark + 1)
+		}
+	}
+
+	if err := e.streamChanges(); err != nil {
+		e.onError(mysql.TaskStateDead, err)
+	}
+}
+
+// openDB connects to an Oracle database
+func openDB(c *ConnectionConfig) (*gosql.DB, error) {
+	found := false
+	for _, name := range gosql.Drivers() {
+		found = found || name == driverName
+	}
+	if !found {
+		return nil, fmt.Errorf("this build has no Oracle driver: build dtle with the %q tag", "oracle")
+	}
+	return gosql.Open(driverName, c.DSN())
+}
+
+// CheckConnection connects to an Oracle database and checks that LogMiner
+// can read its changes
+func CheckConnection(c *ConnectionConfig) error {
+	db, err := openDB(c)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	var logMode, supplementalLogging string
+	if err := db.QueryRow("SELECT LOG_MODE, SUPPLEMENTAL_LOG_DATA_MIN FROM V$DATABASE").Scan(&logMode, &supplementalLogging); err != nil {
+		return err
+	}
+	if logMode != "ARCHIVELOG" {
+		return fmt.Errorf("the database must be in ARCHIVELOG mode, got %s", logMode)
+	}
+	if supplementalLogging == "NO" {
+		return fmt.Errorf("the database must have minimal supplemental logging: ALTER DATABASE ADD SUPPLEMENTAL LOG DATA")
+	}
+	return nil
+}
+
+// initDBConnection connects to Oracle, and reads the DBID the GTIDs of the
+// transactions are made of
+func (e *Extractor) initDBConnection() (err error) {
+	if err := CheckConnection(e.cfg.ConnectionConfig); err != nil {
+		return err
+	}
+	if e.db, err = openDB(e.cfg.ConnectionConfig); err != nil {
+		return err
+	}
+	var dbid int64
+	if err := e.db.QueryRow("SELECT DBID FROM V$DATABASE").Scan(&dbid); err != nil {
+		return err
+	}
+	e.sid = uuid.NewV5(uuid.NamespaceOID, fmt.Sprintf("oracle:%d", dbid))
+	e.logger.Printf("oracle.extractor: Database %d, transactions sent as %s", dbid, e.sid)
+	return nil
+}
+
+func (e *Extractor) initNatsPubClient() (err error) {
+	e.publisher, err = hete.NewPublisher("oracle.extractor", e.subject, e.maxPayload, e.cfg.NatsAddr, e.shutdownCh, e.logger)
+	return err
+}
+
+func (e *Extractor) Stats() (*models.TaskStatistics, error) {
+	// Position is the SCN to set in Scn to resume from
+	coordinates := &models.CurrentCoordinates{
+		Position: atomic.LoadInt64(&e.restartScn),
+		GtidSet:  hete.Gtid(e.sid, atomic.LoadInt64(&e.watermark)),
+	}
+	return e.publisher.Statistics(e.stage, coordinates), nil
+}
+
+// ID returns the config to restart with, as config.DriverCtx is read back
+func (e *Extractor) ID() string {
+	cfg := *e.cfg
+	cfg.Scn = atomic.LoadInt64(&e.restartScn)
+	if watermark := atomic.LoadInt64(&e.watermark); watermark > 0 {
+		cfg.Gtid = hete.Gtid(e.sid, watermark)
+	}
+	id := struct {
+		DriverConfig *OracleConfig
+	}{&cfg}
+	data, err := json.Marshal(id)
+	if err != nil {
+		e.logger.Errorf("oracle.extractor: Failed to marshal ID to JSON: %s", err)
+	}
+	return string(data)
+}
+
+func (e *Extractor) onPanic(err *models.PanicError) {
+	e.onError(mysql.TaskStateRestart, err)
+}
+
+func (e *Extractor) onError(state int, err error) {
+	e.logger.Errorf("oracle.extractor. error: %v", err.Error())
+	if e.shutdown {
+		return
+	}
+	e.waitCh <- models.NewWaitResult(state, err)
+	e.Shutdown()
+}
+
+func (e *Extractor) WaitCh() chan *models.WaitResult {
+	return e.waitCh
+}
+
+// Shutdown is used to tear down the extractor
+func (e *Extractor) Shutdown() error {
+	e.shutdownLock.Lock()
+	defer e.shutdownLock.Unlock()
+
+	if e.shutdown {
+		return nil
+	}
+	e.shutdown = true
+	close(e.shutdownCh)
+
+	if e.publisher != nil {
+		e.publisher.Close()
+	}
+	if e.db != nil {
+		if err := e.db.Close(); err != nil {
+			return err
+		}
+	}
+	e.logger.Printf("oracle.extractor: Shutting down")
+	return nil
+}
@@ -0,0 +1,454 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package oracle
+
+import (
+	"context"
+	gosql "database/sql"
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/actiontech/dtle/internal/client/driver/mysql/base"
+	"github.com/actiontech/dtle/internal/client/driver/mysql/binlog"
+	umconf "github.com/actiontech/dtle/internal/config/mysql"
+	"github.com/actiontech/dtle/internal/models"
+)
+
+// The operations of V$LOGMNR_CONTENTS read
+const (
+	operationInsert   = 1
+	operationDelete   = 2
+	operationUpdate   = 3
+	operationDDL      = 5
+	operationStart    = 6
+	operationCommit   = 7
+	operationRollback = 36
+)
+
+// miningSessionStatements set the formats LogMiner prints the values in,
+// those the MySQL target reads
+var miningSessionStatements = []string{
+	"ALTER SESSION SET NLS_DATE_FORMAT = 'YYYY-MM-DD HH24:MI:SS'",
+	"ALTER SESSION SET NLS_TIMESTAMP_FORMAT = 'YYYY-MM-DD HH24:MI:SS.FF6'",
+	"ALTER SESSION SET NLS_TIMESTAMP_TZ_FORMAT = 'YYYY-MM-DD HH24:MI:SS.FF6 TZR'",
+	"ALTER SESSION SET NLS_NUMERIC_CHARACTERS = '.,'",
+	"ALTER SESSION SET TIME_ZONE = '+00:00'",
+}
+
+// redoChange is a change of a transaction not committed yet
+type redoChange struct {
+	table *table
+	stmt  *redoStatement
+}
+
+// redoTransaction is a transaction read from the redo logs, kept until its
+// commit or rollback
+type redoTransaction struct {
+	// startScn is the SCN of its first change read
+	startScn int64
+	changes  []*redoChange
+}
+
+// streamChanges reads the changes of the redo logs with LogMiner, from
+// restartScn on, and sends the transactions committed after the watermark
+func (e *Extractor) streamChanges() error {
+	ctx := context.Background()
+	// LogMiner runs in a session
+	conn, err := e.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	for _, query := range miningSessionStatements {
+		if _, err := conn.ExecContext(ctx, query); err != nil {
+			return fmt.Errorf("%v: %v", query, err)
+		}
+	}
+
+	for {
+		select {
+		case <-e.shutdownCh:
+			return nil
+		default:
+		}
+		scn, err := currentScn(e.db)
+		if err != nil {
+			return err
+		}
+		// The transactions committed at the current SCN may not all be in
+		// the redo logs yet
+		startScn := atomic.LoadInt64(&e.restartScn)
+		endScn := scn - 1
+		if endScn >= startScn+e.cfg.LogMinerMaxScns {
+			endScn = startScn + e.cfg.LogMinerMaxScns - 1
+		}
+		if endScn >= startScn {
+			e.stage = models.StageSendingBinlogEventToSlave
+			if err := e.mine(ctx, conn, startScn, endScn); err != nil {
+				return err
+			}
+			if endScn < scn-1 {
+				continue
+			}
+		}
+		e.stage = models.StageMasterHasSentAllBinlogToSlave
+		select {
+		case <-e.shutdownCh:
+			return nil
+		case <-time.After(time.Duration(e.cfg.LogMinerInterval) * time.Millisecond):
+		}
+	}
+}
+
+// addLogFiles adds to LogMiner the online and archived logs with changes
+// after an SCN
+func (e *Extractor) addLogFiles(ctx context.Context, conn *gosql.Conn, scn int64) error {
+	query := `SELECT NAME FROM (
+		SELECT MIN(F.MEMBER) AS NAME, L.FIRST_CHANGE# AS FIRST_CHANGE FROM V$LOG L
+			JOIN V$LOGFILE F ON F.GROUP# = L.GROUP#
+			WHERE L.NEXT_CHANGE# > :1 OR L.STATUS = 'CURRENT'
+			GROUP BY L.GROUP#, L.FIRST_CHANGE#
+		UNION ALL
+		SELECT MIN(A.NAME), MIN(A.FIRST_CHANGE#) FROM V$ARCHIVED_LOG A
+			WHERE A.NAME IS NOT NULL AND A.DELETED = 'NO' AND A.NEXT_CHANGE# > :2
+			AND A.RESETLOGS_ID = (SELECT RESETLOGS_ID FROM V$DATABASE_INCARNATION WHERE STATUS = 'CURRENT')
+			AND NOT EXISTS (SELECT 1 FROM V$LOG L WHERE L.THREAD# = A.THREAD# AND L.SEQUENCE# = A.SEQUENCE#)
+			GROUP BY A.THREAD#, A.SEQUENCE#
+	) ORDER BY FIRST_CHANGE`
+	rows, err := conn.QueryContext(ctx, query, scn, scn)
+	if err != nil {
+		return err
+	}
+	var names []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			rows.Close()
+			return err
+		}
+		names = append(names, name)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	if len(names) == 0 {
+		return fmt.Errorf("no redo log has the changes from SCN %d", scn)
+	}
+	option := "DBMS_LOGMNR.NEW"
+	for _, name := range names {
+		query := fmt.Sprintf("BEGIN DBMS_LOGMNR.ADD_LOGFILE(LOGFILENAME => :1, OPTIONS => %s); END;", option)
+		if _, err := conn.ExecContext(ctx, query, name); err != nil {
+			return fmt.Errorf("adding log file %s: %v", name, err)
+		}
+		option = "DBMS_LOGMNR.ADDFILE"
+	}
+	return nil
+}
+
+// mine reads the changes of a range of SCNs, sends the transactions
+// committed in it, and moves restartScn to the start of the oldest left
+// open
+func (e *Extractor) mine(ctx context.Context, conn *gosql.Conn, startScn, endScn int64) error {
+	if err := e.addLogFiles(ctx, conn, startScn); err != nil {
+		return err
+	}
+	if _, err := conn.ExecContext(ctx, `BEGIN DBMS_LOGMNR.START_LOGMNR(STARTSCN => :1, ENDSCN => :2,
+		OPTIONS => DBMS_LOGMNR.DICT_FROM_ONLINE_CATALOG + DBMS_LOGMNR.NO_ROWID_IN_STMT); END;`, startScn, endScn); err != nil {
+		return fmt.Errorf("starting LogMiner from SCN %d to %d: %v", startScn, endScn, err)
+	}
+	defer func() {
+		if _, err := conn.ExecContext(ctx, "BEGIN DBMS_LOGMNR.END_LOGMNR; END;"); err != nil {
+			e.logger.Warnf("oracle.extractor: ending LogMiner: %v", err)
+		}
+	}()
+
+	var owners []string
+	var args []interface{}
+	for _, ds := range e.cfg.ReplicateDoDb {
+		args = append(args, ds.TableSchema)
+		owners = append(owners, fmt.Sprintf(":%d", len(args)))
+	}
+	query := fmt.Sprintf(`SELECT SCN, OPERATION_CODE, RAWTOHEX(XID), SEG_OWNER, TABLE_NAME, SQL_REDO, CSF
+		FROM V$LOGMNR_CONTENTS WHERE OPERATION_CODE IN (%d, %d, %d)
+		OR (OPERATION_CODE IN (%d, %d, %d, %d) AND SEG_OWNER IN (%s))`,
+		operationStart, operationCommit, operationRollback,
+		operationInsert, operationDelete, operationUpdate, operationDDL, strings.Join(owners, ", "))
+	rows, err := conn.QueryContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	watermark := atomic.LoadInt64(&e.watermark)
+	transactions := make(map[string]*redoTransaction)
+	var entries []*binlog.BinlogEntry
+	var redo strings.Builder
+	for rows.Next() {
+		var scn int64
+		var operation, csf int
+		var xid string
+		var owner, tableName, sqlRedo gosql.NullString
+		if err := rows.Scan(&scn, &operation, &xid, &owner, &tableName, &sqlRedo, &csf); err != nil {
+			return err
+		}
+		// A statement longer than 4000 bytes is continued on the next rows
+		redo.WriteString(sqlRedo.String)
+		if csf == 1 {
+			continue
+		}
+		sql := redo.String()
+		redo.Reset()
+
+		tx := transactions[xid]
+		switch operation {
+		case operationStart:
+			if tx == nil {
+				transactions[xid] = &redoTransaction{startScn: scn}
+			}
+		case operationRollback:
+			delete(transactions, xid)
+		case operationCommit:
+			delete(transactions, xid)
+			if tx == nil || len(tx.changes) == 0 || scn <= watermark {
+				continue
+			}
+			entry, err := e.binlogEntry(tx, scn)
+			if err != nil {
+				return err
+			}
+			// The transactions committed at the same SCN are sent as one
+			if n := len(entries); n > 0 && entries[n-1].Coordinates.GNO == scn {
+				entries[n-1].Events = append(entries[n-1].Events, entry.Events...)
+			} else {
+				entries = append(entries, entry)
+			}
+		case operationDDL:
+			if !e.replicated(owner.String, tableName.String) {
+				continue
+			}
+			// The target table is left as it is: its structure is read anew
+			// for the next changes
+			e.logger.Warnf("oracle.extractor: DDL on %s.%s at SCN %d is not replicated: %s",
+				owner.String, tableName.String, scn, sql)
+			key := fmt.Sprintf("%s.%s", owner.String, tableName.String)
+			delete(e.tables, key)
+			delete(e.tableSent, key)
+		default:
+			if !e.replicated(owner.String, tableName.String) {
+				continue
+			}
+			if strings.HasPrefix(sql, "Unsupported") {
+				e.logger.Warnf("oracle.extractor: change on %s.%s at SCN %d not supported by LogMiner, skipped",
+					owner.String, tableName.String, scn)
+				continue
+			}
+			t, err := e.getTable(owner.String, tableName.String)
+			if err != nil {
+				return err
+			}
+			stmt, err := parseRedoSQL(sql)
+			if err != nil {
+				return err
+			}
+			if tx == nil {
+				tx = &redoTransaction{startScn: scn}
+				transactions[xid] = tx
+			}
+			tx.changes = append(tx.changes, &redoChange{table: t, stmt: stmt})
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	if err := e.publisher.PublishEntries(entries, func(last *binlog.BinlogEntry) {
+		atomic.StoreInt64(&e.watermark, last.Coordinates.GNO)
+	}); err != nil {
+		return err
+	}
+
+	restartScn := endScn + 1
+	for _, tx := range transactions {
+		if tx.startScn < restartScn {
+			restartScn = tx.startScn
+		}
+	}
+	atomic.StoreInt64(&e.restartScn, restartScn)
+	return nil
+}
+
+// replicated tells whether a table is in ReplicateDoDb
+func (e *Extractor) replicated(schema, name string) bool {
+	for _, ds := range e.cfg.ReplicateDoDb {
+		if ds.TableSchema != schema {
+			continue
+		}
+		if len(ds.Tables) == 0 {
+			return true
+		}
+		for _, t := range ds.Tables {
+			if t.TableName == name {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// binlogEntry returns a committed transaction as the MySQL extractor sends
+// it. Its GTID is made of the commit SCN.
+func (e *Extractor) binlogEntry(tx *redoTransaction, commitScn int64) (*binlog.BinlogEntry, error) {
+	entry := binlog.NewBinlogEntryAt(base.BinlogCoordinateTx{
+		SID: e.sid,
+		GNO: commitScn,
+	})
+	for _, change := range tx.changes {
+		event, err := e.dataEvent(change, commitScn)
+		if err != nil {
+			return nil, err
+		}
+		entry.Events = append(entry.Events, event)
+	}
+	return entry, nil
+}
+
+// dataEvent converts a change to a row event. The row after an insert or an
+// update is needed in full: the columns not in the redo, such as the LOBs,
+// are read as of the commit by the primary key.
+func (e *Extractor) dataEvent(change *redoChange, commitScn int64) (binlog.DataEvent, error) {
+	t, stmt := change.table, change.stmt
+	event := binlog.NewDataEvent(t.schema, t.name, stmt.dml, len(t.columns))
+	key := fmt.Sprintf("%s.%s", t.schema, t.name)
+	if !e.tableSent[key] {
+		event.Table = t.def
+		e.tableSent[key] = true
+	}
+
+	var image map[string]redoValue
+	switch stmt.dml {
+	case binlog.InsertDML:
+		image = stmt.values
+	case binlog.UpdateDML:
+		image = make(map[string]redoValue)
+		for name, v := range stmt.where {
+			image[name] = v
+		}
+		for name, v := range stmt.values {
+			image[name] = v
+		}
+	}
+
+	if stmt.dml != binlog.InsertDML {
+		values, err := e.rowValues(t, stmt.where, stmt.where, commitScn, true)
+		if err != nil {
+			return event, err
+		}
+		event.WhereColumnValues = umconf.ToColumnValues(values)
+	}
+	if stmt.dml != binlog.DeleteDML {
+		values, err := e.rowValues(t, image, stmt.where, commitScn, false)
+		if err != nil {
+			return event, err
+		}
+		event.NewColumnValues = umconf.ToColumnValues(values)
+	}
+	return event, nil
+}
+
+// rowValues returns the values of a row by ordinal. The columns left out,
+// or written as an empty LOB to be filled later, are read as of the commit
+// SCN by the primary key in key. In the row before an update or a delete,
+// only the primary key is needed and the columns left out are NULL.
+func (e *Extractor) rowValues(t *table, image, key map[string]redoValue, commitScn int64, before bool) ([]interface{}, error) {
+	values := make([]interface{}, len(t.columns))
+	var missing []*column
+	for i, c := range t.columns {
+		v, ok := image[c.name]
+		if !ok || v.function == "EMPTY_CLOB" || v.function == "EMPTY_BLOB" {
+			missing = append(missing, c)
+			continue
+		}
+		value, err := c.eventValue(v)
+		if err != nil {
+			return nil, fmt.Errorf("column %s of %s.%s: %v", c.name, t.schema, t.name, err)
+		}
+		values[i] = value
+	}
+	if len(missing) == 0 {
+		return values, nil
+	}
+	if len(t.primaryKey) == 0 {
+		return nil, fmt.Errorf("column %s of %s.%s is not in the redo logs: the table has no primary key, "+
+			"and must have supplemental logging of all its columns", missing[0].name, t.schema, t.name)
+	}
+	if before {
+		for _, name := range t.primaryKey {
+			if _, ok := image[name]; !ok {
+				return nil, fmt.Errorf("primary key column %s of %s.%s is not in the redo logs: "+
+					"the table must have supplemental logging of its primary key", name, t.schema, t.name)
+			}
+		}
+		return values, nil
+	}
+	if v, ok := key[t.primaryKey[0]]; !ok || v.null {
+		// An insert: its key is the inserted one
+		key = image
+	}
+	if err := e.readColumns(t, missing, key, commitScn, values); err != nil {
+		return nil, err
+	}
+	return values, nil
+}
+
+// readColumns reads columns of a row as of an SCN by its primary key
+func (e *Extractor) readColumns(t *table, columns []*column, key map[string]redoValue, scn int64, values []interface{}) error {
+	var expressions, conditions []string
+	var args []interface{}
+	for _, c := range columns {
+		expressions = append(expressions, c.selectExpression())
+	}
+	for _, name := range t.primaryKey {
+		v, ok := key[name]
+		if !ok {
+			return fmt.Errorf("primary key column %s of %s.%s is not in the redo logs: "+
+				"the table must have supplemental logging of its primary key", name, t.schema, t.name)
+		}
+		c := t.columns[t.ordinals[name]]
+		value, err := c.eventValue(v)
+		if err != nil {
+			return err
+		}
+		args = append(args, value)
+		conditions = append(conditions, fmt.Sprintf("%s = :%d", quoteIdentifier(name), len(args)))
+	}
+	query := fmt.Sprintf("SELECT %s FROM %s.%s AS OF SCN %d WHERE %s", strings.Join(expressions, ", "),
+		quoteIdentifier(t.schema), quoteIdentifier(t.name), scn, strings.Join(conditions, " AND "))
+	dest := make([]interface{}, len(columns))
+	scanned := make([]interface{}, len(columns))
+	for i := range dest {
+		dest[i] = &scanned[i]
+	}
+	err := e.db.QueryRow(query, args...).Scan(dest...)
+	if err == gosql.ErrNoRows {
+		// Deleted later in the same transaction
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("reading %s.%s as of SCN %d: %v", t.schema, t.name, scn, err)
+	}
+	for i, c := range columns {
+		value, err := c.scannedValue(scanned[i])
+		if err != nil {
+			return fmt.Errorf("column %s of %s.%s: %v", c.name, t.schema, t.name, err)
+		}
+		values[t.ordinals[c.name]] = value
+	}
+	return nil
+}
@@ -0,0 +1,387 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package oracle
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/actiontech/dtle/internal/client/driver/mysql/binlog"
+)
+
+// redoValue is a value of a statement of LogMiner: NULL, a literal, or a
+// function of literals such as TO_DATE('...', '...') or HEXTORAW('...')
+type redoValue struct {
+	null bool
+	// function is the upper case name of the function, if any
+	function string
+	// text is the literal, or the first argument of the function
+	text string
+}
+
+// redoStatement is a DML as LogMiner prints it in SQL_REDO, such as
+//
+//	insert into "S"."T"("A","B") values ('1','x');
+//	update "S"."T" set "B" = 'y' where "A" = '1' and "B" = 'x';
+//	delete from "S"."T" where "A" = '1' and "B" IS NULL;
+type redoStatement struct {
+	dml    binlog.EventDML
+	schema string
+	table  string
+	// values are those inserted, or set by an update, by column
+	values map[string]redoValue
+	// where are the values of the row before an update or a delete, by
+	// column
+	where map[string]redoValue
+}
+
+type redoTokenKind int
+
+const (
+	tokenEnd redoTokenKind = iota
+	tokenIdentifier
+	tokenString
+	tokenWord
+	tokenPunct
+)
+
+type redoToken struct {
+	kind redoTokenKind
+	text string
+}
+
+// redoLexer splits a statement into identifiers, literals, words and
+// punctuation
+type redoLexer struct {
+	s   string
+	pos int
+}
+
+func (l *redoLexer) next() (redoToken, error) {
+	for l.pos < len(l.s) && (l.s[l.pos] == ' ' || l.s[l.pos] == '\n' || l.s[l.pos] == '\r' || l.s[l.pos] == '\t') {
+		l.pos++
+	}
+	if l.pos == len(l.s) {
+		return redoToken{kind: tokenEnd}, nil
+	}
+	c := l.s[l.pos]
+	switch {
+	case c == '"' || c == '\'':
+		kind := tokenIdentifier
+		if c == '\'' {
+			kind = tokenString
+		}
+		var text strings.Builder
+		for i := l.pos + 1; i < len(l.s); i++ {
+			if l.s[i] != c {
+				text.WriteByte(l.s[i])
+				continue
+			}
+			// A doubled quote is a quote
+			if i+1 < len(l.s) && l.s[i+1] == c {
+				text.WriteByte(c)
+				i++
+				continue
+			}
+			l.pos = i + 1
+			return redoToken{kind: kind, text: text.String()}, nil
+		}
+		return redoToken{}, fmt.Errorf("unterminated %c at %d", c, l.pos)
+	case strings.IndexByte("(),=;.", c) >= 0:
+		l.pos++
+		return redoToken{kind: tokenPunct, text: string(c)}, nil
+	default:
+		start := l.pos
+		for l.pos < len(l.s) && strings.IndexByte(" \n\r\t\"'(),=;", l.s[l.pos]) < 0 {
+			l.pos++
+		}
+		return redoToken{kind: tokenWord, text: l.s[start:l.pos]}, nil
+	}
+}
+
+// redoParser parses a statement, one token ahead
+type redoParser struct {
+	lexer redoLexer
+	token redoToken
+}
+
+func (p *redoParser) advance() error {
+	token, err := p.lexer.next()
+	if err != nil {
+		return err
+	}
+	p.token = token
+	return nil
+}
+
+// isWord tells whether the current token is the keyword
+func (p *redoParser) isWord(word string) bool {
+	return p.token.kind == tokenWord && strings.EqualFold(p.token.text, word)
+}
+
+func (p *redoParser) expectWord(word string) error {
+	if !p.isWord(word) {
+		return fmt.Errorf("expected %s, got %q", word, p.token.text)
+	}
+	return p.advance()
+}
+
+func (p *redoParser) expectPunct(punct string) error {
+	if p.token.kind != tokenPunct || p.token.text != punct {
+		return fmt.Errorf("expected %q, got %q", punct, p.token.text)
+	}
+	return p.advance()
+}
+
+func (p *redoParser) identifier() (string, error) {
+	if p.token.kind != tokenIdentifier {
+		return "", fmt.Errorf("expected an identifier, got %q", p.token.text)
+	}
+	name := p.token.text
+	return name, p.advance()
+}
+
+// tableName parses "S"."T"
+func (p *redoParser) tableName(stmt *redoStatement) (err error) {
+	if stmt.schema, err = p.identifier(); err != nil {
+		return err
+	}
+	if err := p.expectPunct("."); err != nil {
+		return err
+	}
+	stmt.table, err = p.identifier()
+	return err
+}
+
+// value parses NULL, a literal or a function call
+func (p *redoParser) value() (redoValue, error) {
+	switch p.token.kind {
+	case tokenString:
+		v := redoValue{text: p.token.text}
+		return v, p.advance()
+	case tokenWord:
+		word := p.token.text
+		if err := p.advance(); err != nil {
+			return redoValue{}, err
+		}
+		if strings.EqualFold(word, "NULL") {
+			return redoValue{null: true}, nil
+		}
+		if p.token.kind != tokenPunct || p.token.text != "(" {
+			// A number
+			return redoValue{text: word}, nil
+		}
+		v := redoValue{function: strings.ToUpper(word)}
+		if err := p.advance(); err != nil {
+			return redoValue{}, err
+		}
+		for first := true; p.token.kind != tokenPunct || p.token.text != ")"; first = false {
+			if !first {
+				if err := p.expectPunct(","); err != nil {
+					return redoValue{}, err
+				}
+			}
+			arg, err := p.value()
+			if err != nil {
+				return redoValue{}, err
+			}
+			if first {
+				v.text = arg.text
+			}
+		}
+		return v, p.advance()
+	default:
+		return redoValue{}, fmt.Errorf("expected a value, got %q", p.token.text)
+	}
+}
+
+// assignments parses "A" = v, "B" = v of an update
+func (p *redoParser) assignments(values map[string]redoValue) error {
+	for {
+		column, err := p.identifier()
+		if err != nil {
+			return err
+		}
+		if err := p.expectPunct("="); err != nil {
+			return err
+		}
+		if values[column], err = p.value(); err != nil {
+			return err
+		}
+		if p.token.kind != tokenPunct || p.token.text != "," {
+			return nil
+		}
+		if err := p.advance(); err != nil {
+			return err
+		}
+	}
+}
+
+// conditions parses "A" = v and "B" IS NULL of a where clause. The ROWID, if
+// printed, is left out.
+func (p *redoParser) conditions(where map[string]redoValue) error {
+	for {
+		var column string
+		if p.isWord("ROWID") {
+			column = "ROWID"
+			if err := p.advance(); err != nil {
+				return err
+			}
+		} else {
+			var err error
+			if column, err = p.identifier(); err != nil {
+				return err
+			}
+		}
+		if p.isWord("IS") {
+			if err := p.advance(); err != nil {
+				return err
+			}
+			if err := p.expectWord("NULL"); err != nil {
+				return err
+			}
+			where[column] = redoValue{null: true}
+		} else {
+			if err := p.expectPunct("="); err != nil {
+				return err
+			}
+			v, err := p.value()
+			if err != nil {
+				return err
+			}
+			where[column] = v
+		}
+		if column == "ROWID" {
+			delete(where, column)
+		}
+		if !p.isWord("and") {
+			return nil
+		}
+		if err := p.advance(); err != nil {
+			return err
+		}
+	}
+}
+
+// parseRedoSQL parses an insert, update or delete of SQL_REDO
+func parseRedoSQL(sql string) (*redoStatement, error) {
+	p := &redoParser{lexer: redoLexer{s: sql}}
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+	stmt := &redoStatement{values: make(map[string]redoValue), where: make(map[string]redoValue)}
+	var err error
+	switch {
+	case p.isWord("insert"):
+		stmt.dml = binlog.InsertDML
+		err = p.parseInsert(stmt)
+	case p.isWord("update"):
+		stmt.dml = binlog.UpdateDML
+		err = p.parseUpdate(stmt)
+	case p.isWord("delete"):
+		stmt.dml = binlog.DeleteDML
+		err = p.parseDelete(stmt)
+	default:
+		err = fmt.Errorf("not a DML")
+	}
+	if err == nil && p.token.kind == tokenPunct && p.token.text == ";" {
+		err = p.advance()
+	}
+	if err == nil && p.token.kind != tokenEnd {
+		err = fmt.Errorf("unexpected %q", p.token.text)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("can not parse %q: %v", sql, err)
+	}
+	return stmt, nil
+}
+
+func (p *redoParser) parseInsert(stmt *redoStatement) error {
+	if err := p.advance(); err != nil {
+		return err
+	}
+	if err := p.expectWord("into"); err != nil {
+		return err
+	}
+	if err := p.tableName(stmt); err != nil {
+		return err
+	}
+	var columns []string
+	if err := p.expectPunct("("); err != nil {
+		return err
+	}
+	for {
+		column, err := p.identifier()
+		if err != nil {
+			return err
+		}
+		columns = append(columns, column)
+		if p.token.kind == tokenPunct && p.token.text == ")" {
+			break
+		}
+		if err := p.expectPunct(","); err != nil {
+			return err
+		}
+	}
+	if err := p.advance(); err != nil {
+		return err
+	}
+	if err := p.expectWord("values"); err != nil {
+		return err
+	}
+	if err := p.expectPunct("("); err != nil {
+		return err
+	}
+	for i, column := range columns {
+		if i > 0 {
+			if err := p.expectPunct(","); err != nil {
+				return err
+			}
+		}
+		v, err := p.value()
+		if err != nil {
+			return err
+		}
+		stmt.values[column] = v
+	}
+	return p.expectPunct(")")
+}
+
+func (p *redoParser) parseUpdate(stmt *redoStatement) error {
+	if err := p.advance(); err != nil {
+		return err
+	}
+	if err := p.tableName(stmt); err != nil {
+		return err
+	}
+	if err := p.expectWord("set"); err != nil {
+		return err
+	}
+	if err := p.assignments(stmt.values); err != nil {
+		return err
+	}
+	if err := p.expectWord("where"); err != nil {
+		return err
+	}
+	return p.conditions(stmt.where)
+}
+
+func (p *redoParser) parseDelete(stmt *redoStatement) error {
+	if err := p.advance(); err != nil {
+		return err
+	}
+	if err := p.expectWord("from"); err != nil {
+		return err
+	}
+	if err := p.tableName(stmt); err != nil {
+		return err
+	}
+	if err := p.expectWord("where"); err != nil {
+		return err
+	}
+	return p.conditions(stmt.where)
+}
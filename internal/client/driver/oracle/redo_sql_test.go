@@ -0,0 +1,158 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package oracle
+
+import (
+	gosql "database/sql"
+	"reflect"
+	"testing"
+
+	"github.com/actiontech/dtle/internal/client/driver/mysql/binlog"
+	umconf "github.com/actiontech/dtle/internal/config/mysql"
+)
+
+func TestParseRedoSQL(t *testing.T) {
+	tests := []struct {
+		name    string
+		sql     string
+		want    *redoStatement
+		wantErr bool
+	}{
+		{
+			name: "insert",
+			sql:  `insert into "HR"."EMP"("ID","NAME","HIRED","NOTE") values ('1','O''Brien',TO_DATE('2018-01-02 03:04:05', 'YYYY-MM-DD HH24:MI:SS'),NULL);`,
+			want: &redoStatement{
+				dml:    binlog.InsertDML,
+				schema: "HR",
+				table:  "EMP",
+				values: map[string]redoValue{
+					"ID":    {text: "1"},
+					"NAME":  {text: "O'Brien"},
+					"HIRED": {function: "TO_DATE", text: "2018-01-02 03:04:05"},
+					"NOTE":  {null: true},
+				},
+				where: map[string]redoValue{},
+			},
+		},
+		{
+			name: "update",
+			sql:  `update "HR"."EMP" set "NAME" = UNISTR('\00e9'), "NOTE" = NULL where "ID" = '1' and "NAME" = 'e' and "NOTE" IS NULL;`,
+			want: &redoStatement{
+				dml:    binlog.UpdateDML,
+				schema: "HR",
+				table:  "EMP",
+				values: map[string]redoValue{
+					"NAME": {function: "UNISTR", text: `\00e9`},
+					"NOTE": {null: true},
+				},
+				where: map[string]redoValue{
+					"ID":   {text: "1"},
+					"NAME": {text: "e"},
+					"NOTE": {null: true},
+				},
+			},
+		},
+		{
+			name: "delete with a rowid",
+			sql:  `delete from "HR"."EMP" where "ID" = '1' and ROWID = 'AAAR3sAAEAAAACXAAA';`,
+			want: &redoStatement{
+				dml:    binlog.DeleteDML,
+				schema: "HR",
+				table:  "EMP",
+				values: map[string]redoValue{},
+				where: map[string]redoValue{
+					"ID": {text: "1"},
+				},
+			},
+		},
+		{
+			name:    "DDL",
+			sql:     `create table "HR"."T" ("A" number);`,
+			wantErr: true,
+		},
+		{
+			name:    "unterminated literal",
+			sql:     `insert into "HR"."EMP"("ID") values ('1);`,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseRedoSQL(tt.sql)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("parseRedoSQL() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseRedoSQL() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUnistr(t *testing.T) {
+	tests := []struct {
+		s       string
+		want    string
+		wantErr bool
+	}{
+		{s: `caf\00e9`, want: "café"},
+		{s: `\d83d\de00!`, want: "😀!"},
+		{s: `a\\b`, want: `a\b`},
+		{s: `\00e`, wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := unistr(tt.s)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("unistr(%q) error = %v, wantErr %v", tt.s, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("unistr(%q) = %q, want %q", tt.s, got, tt.want)
+		}
+	}
+}
+
+func TestMysqlColumnType(t *testing.T) {
+	number := func(precision, scale int64, precisionValid bool) *column {
+		return &column{
+			dataType:  "NUMBER",
+			precision: gosql.NullInt64{Int64: precision, Valid: precisionValid},
+			scale:     gosql.NullInt64{Int64: scale, Valid: true},
+		}
+	}
+	tests := []struct {
+		name     string
+		column   *column
+		wantType string
+		wantKind umconf.ColumnType
+	}{
+		{"NUMBER(2)", number(2, 0, true), "tinyint", umconf.TinyintColumnType},
+		{"NUMBER(9)", number(9, 0, true), "int", umconf.IntColumnType},
+		{"NUMBER(18)", number(18, 0, true), "bigint", umconf.BigIntColumnType},
+		{"NUMBER(20)", number(20, 0, true), "decimal(20,0)", umconf.DecimalColumnType},
+		{"NUMBER(10,2)", number(10, 2, true), "decimal(10,2)", umconf.DecimalColumnType},
+		{"NUMBER(5,-2)", number(5, -2, true), "decimal(7,0)", umconf.DecimalColumnType},
+		{"NUMBER(*,0)", number(0, 0, false), "decimal(38,0)", umconf.DecimalColumnType},
+		{"VARCHAR2(30 CHAR)", &column{dataType: "VARCHAR2", length: 30}, "varchar(30)", umconf.VarcharColumnType},
+		{"CHAR(300)", &column{dataType: "CHAR", length: 300}, "varchar(300)", umconf.VarcharColumnType},
+		{"DATE", &column{dataType: "DATE"}, "datetime", umconf.DateTimeColumnType},
+		{"TIMESTAMP(3)", &column{dataType: "TIMESTAMP(3)", scale: gosql.NullInt64{Int64: 3, Valid: true}},
+			"datetime(3)", umconf.DateTimeColumnType},
+		{"TIMESTAMP(6) WITH TIME ZONE", &column{dataType: "TIMESTAMP(6) WITH TIME ZONE"}, "varchar(64)", umconf.VarcharColumnType},
+		{"RAW(16)", &column{dataType: "RAW", length: 16}, "varbinary(16)", umconf.VarbinaryColumnType},
+		{"CLOB", &column{dataType: "CLOB"}, "longtext", umconf.TextColumnType},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotType, gotKind := mysqlColumnType(tt.column)
+			if gotType != tt.wantType || gotKind != tt.wantKind {
+				t.Errorf("mysqlColumnType() = %v, %v, want %v, %v", gotType, gotKind, tt.wantType, tt.wantKind)
+			}
+		})
+	}
+}
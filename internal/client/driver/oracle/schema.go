@@ -0,0 +1,339 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package oracle
+
+import (
+	gosql "database/sql"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+
+	usql "github.com/actiontech/dtle/internal/client/driver/mysql/sql"
+	"github.com/actiontech/dtle/internal/config"
+	umconf "github.com/actiontech/dtle/internal/config/mysql"
+)
+
+// column is a column of an Oracle table, and the MySQL type it is created
+// with on the target
+type column struct {
+	name      string
+	dataType  string
+	precision gosql.NullInt64
+	scale     gosql.NullInt64
+	length    int64
+	nullable  bool
+
+	mysqlType string
+	kind      umconf.ColumnType
+}
+
+// table is an Oracle table replicated
+type table struct {
+	schema     string
+	name       string
+	columns    []*column
+	ordinals   map[string]int
+	primaryKey []string
+	// def is the structure of the table as sent along with the rows, for
+	// the Kafka target
+	def *config.Table
+}
+
+// loadTable reads the columns and the primary key of a table
+func loadTable(db *gosql.DB, schema, name string) (*table, error) {
+	t := &table{schema: schema, name: name, ordinals: make(map[string]int)}
+	query := `SELECT COLUMN_NAME, DATA_TYPE, DATA_PRECISION, DATA_SCALE, DATA_LENGTH, CHAR_LENGTH, NULLABLE
+		FROM ALL_TAB_COLUMNS WHERE OWNER = :1 AND TABLE_NAME = :2 ORDER BY COLUMN_ID`
+	rows, err := db.Query(query, schema, name)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		c := &column{}
+		var dataLength, charLength int64
+		var nullable string
+		if err := rows.Scan(&c.name, &c.dataType, &c.precision, &c.scale, &dataLength, &charLength, &nullable); err != nil {
+			return nil, err
+		}
+		c.length = charLength
+		if c.length == 0 {
+			c.length = dataLength
+		}
+		c.nullable = nullable == "Y"
+		c.mysqlType, c.kind = mysqlColumnType(c)
+		t.ordinals[c.name] = len(t.columns)
+		t.columns = append(t.columns, c)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(t.columns) == 0 {
+		return nil, fmt.Errorf("table %s.%s not found", schema, name)
+	}
+
+	query = `SELECT CC.COLUMN_NAME FROM ALL_CONSTRAINTS C
+		JOIN ALL_CONS_COLUMNS CC ON CC.OWNER = C.OWNER AND CC.CONSTRAINT_NAME = C.CONSTRAINT_NAME
+		WHERE C.OWNER = :1 AND C.TABLE_NAME = :2 AND C.CONSTRAINT_TYPE = 'P' ORDER BY CC.POSITION`
+	pkRows, err := db.Query(query, schema, name)
+	if err != nil {
+		return nil, err
+	}
+	defer pkRows.Close()
+	for pkRows.Next() {
+		var columnName string
+		if err := pkRows.Scan(&columnName); err != nil {
+			return nil, err
+		}
+		t.primaryKey = append(t.primaryKey, columnName)
+	}
+	if err := pkRows.Err(); err != nil {
+		return nil, err
+	}
+
+	t.def = t.tableDef()
+	return t, nil
+}
+
+// mysqlColumnType returns the MySQL type a column is created with
+func mysqlColumnType(c *column) (string, umconf.ColumnType) {
+	switch {
+	case c.dataType == "NUMBER":
+		if !c.precision.Valid {
+			if c.scale.Valid && c.scale.Int64 == 0 {
+				// NUMBER(*,0)
+				return "decimal(38,0)", umconf.DecimalColumnType
+			}
+			return "decimal(65,30)", umconf.DecimalColumnType
+		}
+		p, s := c.precision.Int64, c.scale.Int64
+		switch {
+		case s == 0 && p < 3:
+			return "tinyint", umconf.TinyintColumnType
+		case s == 0 && p < 5:
+			return "smallint", umconf.SmallintColumnType
+		case s == 0 && p < 10:
+			return "int", umconf.IntColumnType
+		case s == 0 && p < 19:
+			return "bigint", umconf.BigIntColumnType
+		case s < 0:
+			// Rounded to the left of the decimal point
+			p -= s
+			s = 0
+		case s > p:
+			// NUMBER(2,5) holds 0.000nn
+			p = s
+		}
+		if p > 65 {
+			p = 65
+		}
+		return fmt.Sprintf("decimal(%d,%d)", p, s), umconf.DecimalColumnType
+	case c.dataType == "FLOAT" || c.dataType == "BINARY_DOUBLE":
+		return "double", umconf.DoubleColumnType
+	case c.dataType == "BINARY_FLOAT":
+		return "float", umconf.FloatColumnType
+	case c.dataType == "VARCHAR2" || c.dataType == "NVARCHAR2":
+		return fmt.Sprintf("varchar(%d)", c.length), umconf.VarcharColumnType
+	case c.dataType == "CHAR" || c.dataType == "NCHAR":
+		if c.length > 255 {
+			return fmt.Sprintf("varchar(%d)", c.length), umconf.VarcharColumnType
+		}
+		return fmt.Sprintf("char(%d)", c.length), umconf.CharColumnType
+	case c.dataType == "DATE":
+		return "datetime", umconf.DateTimeColumnType
+	case strings.HasPrefix(c.dataType, "TIMESTAMP") && strings.HasSuffix(c.dataType, " WITH TIME ZONE") &&
+		!strings.HasSuffix(c.dataType, "LOCAL TIME ZONE"):
+		// The time zone is kept in the text of the value
+		return "varchar(64)", umconf.VarcharColumnType
+	case strings.HasPrefix(c.dataType, "TIMESTAMP"):
+		// The fractional precision is the scale of TIMESTAMP(n)
+		fsp := int64(6)
+		if c.scale.Valid && c.scale.Int64 < fsp {
+			fsp = c.scale.Int64
+		}
+		if fsp == 0 {
+			return "datetime", umconf.DateTimeColumnType
+		}
+		return fmt.Sprintf("datetime(%d)", fsp), umconf.DateTimeColumnType
+	case c.dataType == "RAW":
+		return fmt.Sprintf("varbinary(%d)", c.length), umconf.VarbinaryColumnType
+	case c.dataType == "BLOB" || c.dataType == "LONG RAW":
+		return "longblob", umconf.BlobColumnType
+	case c.dataType == "ROWID" || c.dataType == "UROWID":
+		return "varchar(4000)", umconf.VarcharColumnType
+	case strings.HasPrefix(c.dataType, "INTERVAL"):
+		return "varchar(64)", umconf.VarcharColumnType
+	default:
+		// CLOB, NCLOB, LONG, XMLTYPE and the types unknown
+		return "longtext", umconf.TextColumnType
+	}
+}
+
+// selectExpression returns the expression a column is read with in the
+// snapshot: numbers and times as text, for their precision to be kept
+func (c *column) selectExpression() string {
+	name := quoteIdentifier(c.name)
+	switch {
+	case c.dataType == "NUMBER" || c.dataType == "FLOAT" ||
+		c.dataType == "BINARY_FLOAT" || c.dataType == "BINARY_DOUBLE":
+		return fmt.Sprintf("TO_CHAR(%s, 'TM9', 'NLS_NUMERIC_CHARACTERS=''.,''')", name)
+	case c.dataType == "DATE":
+		return fmt.Sprintf("TO_CHAR(%s, 'YYYY-MM-DD HH24:MI:SS')", name)
+	case strings.HasPrefix(c.dataType, "TIMESTAMP") && c.kind == umconf.VarcharColumnType:
+		return fmt.Sprintf("TO_CHAR(%s, 'YYYY-MM-DD HH24:MI:SS.FF6 TZR')", name)
+	case strings.HasPrefix(c.dataType, "TIMESTAMP"):
+		return fmt.Sprintf("TO_CHAR(%s, 'YYYY-MM-DD HH24:MI:SS.FF6')", name)
+	case strings.HasPrefix(c.dataType, "INTERVAL") || c.dataType == "ROWID" || c.dataType == "UROWID":
+		return fmt.Sprintf("TO_CHAR(%s)", name)
+	default:
+		return name
+	}
+}
+
+// eventValue converts a value of LogMiner to that of a binlog event of the
+// MySQL type of the column
+func (c *column) eventValue(v redoValue) (interface{}, error) {
+	if v.null {
+		return nil, nil
+	}
+	switch c.kind {
+	case umconf.TinyintColumnType, umconf.SmallintColumnType, umconf.IntColumnType, umconf.BigIntColumnType:
+		return strconv.ParseInt(v.text, 10, 64)
+	case umconf.FloatColumnType:
+		f, err := strconv.ParseFloat(v.text, 32)
+		return float32(f), err
+	case umconf.DoubleColumnType:
+		return strconv.ParseFloat(v.text, 64)
+	case umconf.VarbinaryColumnType, umconf.BlobColumnType:
+		if v.function == "EMPTY_BLOB" {
+			return []byte{}, nil
+		}
+		return hex.DecodeString(v.text)
+	default:
+		switch v.function {
+		case "EMPTY_CLOB":
+			return "", nil
+		case "UNISTR":
+			return unistr(v.text)
+		}
+		// The times are printed in the formats of the session of LogMiner
+		return v.text, nil
+	}
+}
+
+// scannedValue converts a value read by selectExpression to that of a
+// binlog event
+func (c *column) scannedValue(v interface{}) (interface{}, error) {
+	binary := c.kind == umconf.VarbinaryColumnType || c.kind == umconf.BlobColumnType
+	var text string
+	switch v := v.(type) {
+	case nil:
+		return nil, nil
+	case []byte:
+		if binary {
+			return v, nil
+		}
+		text = string(v)
+	case string:
+		if binary {
+			return []byte(v), nil
+		}
+		text = v
+	default:
+		text = fmt.Sprint(v)
+	}
+	return c.eventValue(redoValue{text: text})
+}
+
+// unistr decodes the \XXXX escapes of UNISTR
+func unistr(s string) (string, error) {
+	var b strings.Builder
+	var surrogate rune
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' {
+			b.WriteByte(s[i])
+			continue
+		}
+		if i+1 < len(s) && s[i+1] == '\\' {
+			b.WriteByte('\\')
+			i++
+			continue
+		}
+		if i+4 >= len(s) {
+			return "", fmt.Errorf("invalid UNISTR escape in %q", s)
+		}
+		code, err := strconv.ParseUint(s[i+1:i+5], 16, 16)
+		if err != nil {
+			return "", fmt.Errorf("invalid UNISTR escape in %q", s)
+		}
+		r := rune(code)
+		switch {
+		case r >= 0xD800 && r < 0xDC00:
+			surrogate = r
+		case r >= 0xDC00 && r < 0xE000 && surrogate != 0:
+			b.WriteRune((surrogate-0xD800)<<10 + (r - 0xDC00) + 0x10000)
+			surrogate = 0
+		default:
+			b.WriteRune(r)
+		}
+		i += 4
+	}
+	return b.String(), nil
+}
+
+// createTableSQL returns the CREATE TABLE of the table on the target
+func (t *table) createTableSQL() string {
+	var definitions []string
+	for _, c := range t.columns {
+		definition := fmt.Sprintf("  %s %s", usql.EscapeName(c.name), c.mysqlType)
+		if !c.nullable {
+			definition += " NOT NULL"
+		}
+		definitions = append(definitions, definition)
+	}
+	if len(t.primaryKey) > 0 {
+		var names []string
+		for _, name := range t.primaryKey {
+			names = append(names, usql.EscapeName(name))
+		}
+		definitions = append(definitions, fmt.Sprintf("  PRIMARY KEY (%s)", strings.Join(names, ",")))
+	}
+	return fmt.Sprintf("CREATE TABLE %s (\n%s\n)", usql.EscapeName(t.name), strings.Join(definitions, ",\n"))
+}
+
+// tableDef returns the structure of the table as read from a MySQL source
+func (t *table) tableDef() *config.Table {
+	def := config.NewTable(t.schema, t.name)
+	primaryKey := make(map[string]bool)
+	for _, name := range t.primaryKey {
+		primaryKey[name] = true
+	}
+	var columns []umconf.Column
+	for _, c := range t.columns {
+		col := umconf.Column{
+			Name:       c.name,
+			Type:       c.kind,
+			ColumnType: c.mysqlType,
+			Nullable:   c.nullable,
+		}
+		if primaryKey[c.name] {
+			col.Key = "PRI"
+		}
+		if c.kind == umconf.DecimalColumnType {
+			fmt.Sscanf(c.mysqlType, "decimal(%d,%d)", &col.Precision, &col.Scale)
+		}
+		columns = append(columns, col)
+	}
+	def.OriginalTableColumns = umconf.NewColumnList(columns)
+	return def
+}
+
+// quoteIdentifier quotes an Oracle identifier
+func quoteIdentifier(name string) string {
+	return `"` + strings.Replace(name, `"`, `""`, -1) + `"`
+}
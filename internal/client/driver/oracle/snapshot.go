@@ -0,0 +1,173 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package oracle
+
+import (
+	gosql "database/sql"
+	"fmt"
+	"strings"
+
+	"github.com/actiontech/dtle/internal/client/driver/hete"
+	"github.com/actiontech/dtle/internal/models"
+)
+
+// currentScn returns the current SCN of the database
+func currentScn(db *gosql.DB) (scn int64, err error) {
+	err = db.QueryRow("SELECT CURRENT_SCN FROM V$DATABASE").Scan(&scn)
+	return scn, err
+}
+
+// oldestOpenScn returns the start SCN of the oldest transaction open, if
+// before an SCN, or that SCN
+func (e *Extractor) oldestOpenScn(scn int64) int64 {
+	var oldestStart gosql.NullInt64
+	if err := e.db.QueryRow("SELECT MIN(START_SCN) FROM V$TRANSACTION").Scan(&oldestStart); err != nil {
+		e.logger.Warnf("oracle.extractor: can not read the open transactions, whose changes before SCN %d are lost: %v", scn, err)
+	} else if oldestStart.Valid && oldestStart.Int64 < scn {
+		return oldestStart.Int64
+	}
+	return scn
+}
+
+// replicatedTables returns the tables of ReplicateDoDb, all those of a
+// schema listed without tables
+func (e *Extractor) replicatedTables() ([]*table, error) {
+	var tables []*table
+	for _, ds := range e.cfg.ReplicateDoDb {
+		var names []string
+		for _, t := range ds.Tables {
+			names = append(names, t.TableName)
+		}
+		if len(names) == 0 {
+			rows, err := e.db.Query(`SELECT TABLE_NAME FROM ALL_TABLES
+				WHERE OWNER = :1 AND NESTED = 'NO' AND IOT_TYPE IS NULL ORDER BY TABLE_NAME`, ds.TableSchema)
+			if err != nil {
+				return nil, err
+			}
+			for rows.Next() {
+				var name string
+				if err := rows.Scan(&name); err != nil {
+					rows.Close()
+					return nil, err
+				}
+				names = append(names, name)
+			}
+			rows.Close()
+			if err := rows.Err(); err != nil {
+				return nil, err
+			}
+		}
+		for _, name := range names {
+			t, err := e.getTable(ds.TableSchema, name)
+			if err != nil {
+				return nil, err
+			}
+			tables = append(tables, t)
+		}
+	}
+	return tables, nil
+}
+
+// getTable returns a replicated table, loading it if not yet
+func (e *Extractor) getTable(schema, name string) (*table, error) {
+	key := fmt.Sprintf("%s.%s", schema, name)
+	if t, ok := e.tables[key]; ok {
+		return t, nil
+	}
+	t, err := loadTable(e.db, schema, name)
+	if err != nil {
+		return nil, err
+	}
+	e.tables[key] = t
+	e.tableSent[key] = false
+	return t, nil
+}
+
+// snapshot copies the tables as of the current SCN, then sets the changes
+// to be read from the start of the oldest transaction open at that SCN
+func (e *Extractor) snapshot() error {
+	e.stage = models.StageSendingData
+	scn, err := currentScn(e.db)
+	if err != nil {
+		return err
+	}
+	// The transactions committed later are not in the snapshot, but may
+	// have started earlier
+	e.restartScn = e.oldestOpenScn(scn)
+	e.watermark = scn
+	e.logger.Printf("oracle.extractor: Step 1: copying the tables as of SCN %d", scn)
+
+	tables, err := e.replicatedTables()
+	if err != nil {
+		return err
+	}
+	for _, t := range tables {
+		var numRows gosql.NullInt64
+		if err := e.db.QueryRow("SELECT NUM_ROWS FROM ALL_TABLES WHERE OWNER = :1 AND TABLE_NAME = :2",
+			t.schema, t.name).Scan(&numRows); err == nil && numRows.Valid {
+			e.publisher.AddRowsEstimate(numRows.Int64)
+		}
+	}
+
+	for i, t := range tables {
+		if err := e.publisher.PublishCreateTable(t.schema, t.createTableSQL()); err != nil {
+			return err
+		}
+		e.logger.Printf("oracle.extractor: Step 1: - copying table %s.%s (%d of %d tables)", t.schema, t.name, i+1, len(tables))
+		if err := e.dumpTable(t, scn); err != nil {
+			return err
+		}
+	}
+
+	if err := e.publisher.PublishDumpComplete(hete.Gtid(e.sid, scn)); err != nil {
+		return err
+	}
+	e.logger.Printf("oracle.extractor: Step 2: the changes are read from SCN %d", e.restartScn)
+	return nil
+}
+
+// dumpTable sends the rows of a table as of an SCN, ChunkSize at a time
+func (e *Extractor) dumpTable(t *table, scn int64) error {
+	var expressions, names []string
+	for _, c := range t.columns {
+		expressions = append(expressions, c.selectExpression())
+		names = append(names, c.name)
+	}
+	query := fmt.Sprintf("SELECT %s FROM %s.%s AS OF SCN %d", strings.Join(expressions, ", "),
+		quoteIdentifier(t.schema), quoteIdentifier(t.name), scn)
+	rows, err := e.db.Query(query)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	dumper := e.publisher.NewTableDumper(t.schema, t.name, names, t.def, e.cfg.ChunkSize)
+	for rows.Next() {
+		raw := make([]gosql.RawBytes, len(t.columns))
+		dest := make([]interface{}, len(raw))
+		for i := range raw {
+			dest[i] = &raw[i]
+		}
+		if err := rows.Scan(dest...); err != nil {
+			return err
+		}
+		row := make([][]byte, len(raw))
+		for i, b := range raw {
+			if b != nil {
+				// RawBytes are reused by the next row
+				row[i] = append([]byte{}, b...)
+			}
+		}
+		if err := dumper.Add(row); err != nil {
+			return err
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	return dumper.Flush()
+}
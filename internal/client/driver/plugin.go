@@ -0,0 +1,187 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package driver
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/mitchellh/mapstructure"
+
+	uconf "github.com/actiontech/dtle/internal/config"
+	log "github.com/actiontech/dtle/internal/logger"
+	"github.com/actiontech/dtle/internal/models"
+	"github.com/actiontech/dtle/plugin"
+)
+
+// LoadPlugins registers the drivers of the plugins in a directory: an
+// executable named dtle-driver-<name> serves the driver <name>
+func LoadPlugins(dir string, logger *log.Logger) error {
+	plugins, err := plugin.Discover(dir)
+	if err != nil {
+		return fmt.Errorf("failed to read the plugin directory: %v", err)
+	}
+	for name, path := range plugins {
+		if _, ok := BuiltinDrivers[name]; ok {
+			logger.Warnf("agent: Ignoring plugin %s: %s is a built-in driver", path, name)
+			continue
+		}
+		path := path
+		PluginDrivers[name] = func(ctx *DriverContext) Driver {
+			return &PluginDriver{DriverContext: *ctx, path: path}
+		}
+		logger.Printf("agent: Loaded driver %s from plugin %s", name, path)
+	}
+	return nil
+}
+
+// PluginDriver runs the tasks of a driver served by a plugin, each in a
+// process of the plugin
+type PluginDriver struct {
+	DriverContext
+	path string
+}
+
+func (pd *PluginDriver) Start(ctx *ExecContext, task *models.Task) (DriverHandle, error) {
+	var driverConfig struct {
+		NatsAddr string
+	}
+	if err := mapstructure.WeakDecode(task.Config, &driverConfig); err != nil {
+		return nil, err
+	}
+
+	client, err := plugin.Open(pd.path)
+	if err != nil {
+		return nil, err
+	}
+	h := &pluginHandle{
+		client:   client,
+		id:       pd.allocID,
+		natsAddr: driverConfig.NatsAddr,
+		waitCh:   make(chan *models.WaitResult, 1),
+	}
+	if gtid, ok := task.Config["Gtid"].(string); ok {
+		h.gtid = gtid
+	}
+	err = client.Start(&plugin.Task{
+		ID:         h.id,
+		Type:       task.Type,
+		Config:     task.Config,
+		Subject:    ctx.Subject,
+		Tp:         ctx.Tp,
+		MaxPayload: ctx.MaxPayload,
+	})
+	if err != nil {
+		client.Close()
+		return nil, err
+	}
+	go h.run()
+	return h, nil
+}
+
+func (pd *PluginDriver) Validate(task *models.Task) (*models.TaskValidateResponse, error) {
+	reply := &models.TaskValidateResponse{}
+	client, err := plugin.Open(pd.path)
+	if err != nil {
+		return reply, err
+	}
+	defer client.Close()
+
+	connErr, err := client.Validate(task.Type, task.Config)
+	if err != nil {
+		return reply, err
+	}
+	if connErr != "" {
+		reply.Connection.Success = false
+		reply.Connection.Error = connErr
+	} else {
+		reply.Connection.Success = true
+	}
+	return reply, nil
+}
+
+// pluginHandle is a task run by a plugin
+type pluginHandle struct {
+	client   *plugin.Client
+	id       string
+	natsAddr string
+	waitCh   chan *models.WaitResult
+
+	// gtid is the last GTID set the task reported, kept once the plugin
+	// has stopped
+	gtidLock sync.Mutex
+	gtid     string
+}
+
+// run waits for the task to end, then stops the plugin
+func (h *pluginHandle) run() {
+	err := h.client.Wait(h.id)
+	h.updateGtid()
+	h.client.Close()
+	if err != nil {
+		h.waitCh <- models.NewWaitResult(1, err)
+	} else {
+		h.waitCh <- models.NewWaitResult(0, nil)
+	}
+}
+
+func (h *pluginHandle) updateGtid() string {
+	h.gtidLock.Lock()
+	defer h.gtidLock.Unlock()
+	if stats, err := h.client.Stats(h.id); err == nil && stats.Gtid != "" {
+		h.gtid = stats.Gtid
+	}
+	return h.gtid
+}
+
+func (h *pluginHandle) ID() string {
+	id := &uconf.DriverCtx{
+		DriverConfig: &uconf.MySQLDriverConfig{
+			Gtid:     h.updateGtid(),
+			NatsAddr: h.natsAddr,
+		},
+	}
+	data, err := json.Marshal(id)
+	if err != nil {
+		return ""
+	}
+	return string(data)
+}
+
+func (h *pluginHandle) WaitCh() chan *models.WaitResult {
+	return h.waitCh
+}
+
+func (h *pluginHandle) Shutdown() error {
+	if err := h.client.Shutdown(h.id); err != nil {
+		// Stopping the plugin ends the task
+		h.client.Close()
+		return err
+	}
+	return nil
+}
+
+func (h *pluginHandle) Stats() (*models.TaskStatistics, error) {
+	stats, err := h.client.Stats(h.id)
+	if err != nil {
+		return nil, err
+	}
+	return &models.TaskStatistics{
+		CurrentCoordinates: &models.CurrentCoordinates{GtidSet: stats.Gtid},
+		Stage:              stats.Stage,
+		ProgressPct:        stats.ProgressPct,
+		ETA:                stats.ETA,
+		Backlog:            stats.Backlog,
+		ExecMasterRowCount: stats.ExecMasterRowCount,
+		ExecMasterTxCount:  stats.ExecMasterTxCount,
+		ReadMasterRowCount: stats.ReadMasterRowCount,
+		ReadMasterTxCount:  stats.ReadMasterTxCount,
+		Timestamp:          time.Now().UTC().UnixNano(),
+	}, nil
+}
@@ -0,0 +1,129 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package polling
+
+import (
+	"fmt"
+)
+
+const (
+	defaultChunkSize    = 2000
+	defaultPollInterval = 5000
+)
+
+// The modes of the watermark columns
+const (
+	// ModeTimestamp is a column set to the current time by each insert and
+	// update
+	ModeTimestamp = "timestamp"
+	// ModeIncrement is an auto-increment column of a table only inserted
+	// into
+	ModeIncrement = "increment"
+)
+
+// ConnectionConfig is the database a task polls, by a database/sql driver
+type ConnectionConfig struct {
+	// DriverName is the database/sql driver: mysql, or sqlserver and
+	// oracle in the builds with their tags
+	DriverName string
+	// DSN is the data source name of the driver
+	DSN string
+}
+
+// PolledTable is a table polled, and the columns its rows changed are
+// found and written by
+type PolledTable struct {
+	// Schema is the database, schema or owner of the table, created as a
+	// database of the same name on the target
+	Schema string
+	Table  string
+	// Column is the watermark of the rows changed
+	Column string
+	// Key is the columns identifying a row, the primary key of the table
+	// on the target. The watermark column in increment mode by default.
+	Key []string
+}
+
+// PollingConfig is the config of a polling source task
+type PollingConfig struct {
+	ConnectionConfig *ConnectionConfig
+	Tables           []*PolledTable
+	// Mode is that of the watermark columns: timestamp, or increment
+	// with a single table. Timestamp by default.
+	Mode     string
+	NatsAddr string
+	// ChunkSize is the number of rows of a table sent at a time in the
+	// snapshot
+	ChunkSize int64
+	// PollInterval is the milliseconds between two polls of the tables
+	PollInterval int
+
+	// Gtid is the last transaction replicated, as reported in the
+	// statistics: "uuid:1-n", n standing for the watermark of its rows,
+	// in microseconds since the epoch in timestamp mode. The rows are
+	// polled from it on. Without it, the tables are copied first.
+	Gtid string
+}
+
+// SetDefault returns a copy of the config with the defaults set
+func (c *PollingConfig) SetDefault() *PollingConfig {
+	result := *c
+	if result.Mode == "" {
+		result.Mode = ModeTimestamp
+	}
+	if result.ChunkSize <= 0 {
+		result.ChunkSize = defaultChunkSize
+	}
+	if result.PollInterval <= 0 {
+		result.PollInterval = defaultPollInterval
+	}
+	var tables []*PolledTable
+	for _, t := range result.Tables {
+		table := *t
+		if len(table.Key) == 0 && result.Mode == ModeIncrement {
+			table.Key = []string{table.Column}
+		}
+		tables = append(tables, &table)
+	}
+	result.Tables = tables
+	return &result
+}
+
+// Validate checks the config of a source task
+func (c *PollingConfig) Validate() error {
+	if c.ConnectionConfig == nil {
+		return fmt.Errorf("ConnectionConfig is missing")
+	}
+	if _, err := dialectOf(c.ConnectionConfig.DriverName); err != nil {
+		return err
+	}
+	if c.ConnectionConfig.DSN == "" {
+		return fmt.Errorf("ConnectionConfig.DSN is missing")
+	}
+	switch c.Mode {
+	case ModeTimestamp:
+	case ModeIncrement:
+		// The GTIDs are made of the watermark, of a single table
+		if len(c.Tables) > 1 {
+			return fmt.Errorf("Mode %s polls a single table: create a job by table", ModeIncrement)
+		}
+	default:
+		return fmt.Errorf("invalid Mode %q: %s or %s", c.Mode, ModeTimestamp, ModeIncrement)
+	}
+	if len(c.Tables) == 0 {
+		return fmt.Errorf("Tables is empty: the tables polled must be listed")
+	}
+	for _, t := range c.Tables {
+		if t.Schema == "" || t.Table == "" || t.Column == "" {
+			return fmt.Errorf("the Schema, Table and Column of a table polled are missing")
+		}
+		if len(t.Key) == 0 {
+			return fmt.Errorf("the Key of table %s.%s is missing", t.Schema, t.Table)
+		}
+	}
+	return nil
+}
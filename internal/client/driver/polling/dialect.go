@@ -0,0 +1,56 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package polling
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// dialect is the SQL of a database/sql driver the queries are written in
+type dialect struct {
+	// placeholder returns the placeholder of the i-th parameter, from 1
+	placeholder func(i int) string
+	quote       func(name string) string
+	// dateTime tells whether DATE has a time, as in Oracle
+	dateTime bool
+}
+
+func quoteWith(open, close string) func(string) string {
+	return func(name string) string {
+		return open + strings.Replace(name, close, close+close, -1) + close
+	}
+}
+
+var dialects = map[string]*dialect{
+	"mysql": {
+		placeholder: func(int) string { return "?" },
+		quote:       quoteWith("`", "`"),
+	},
+	"sqlserver": {
+		placeholder: func(i int) string { return fmt.Sprintf("@p%d", i) },
+		quote:       quoteWith("[", "]"),
+	},
+	"oracle": {
+		placeholder: func(i int) string { return fmt.Sprintf(":%d", i) },
+		quote:       quoteWith(`"`, `"`),
+		dateTime:    true,
+	},
+}
+
+func dialectOf(driverName string) (*dialect, error) {
+	if d, ok := dialects[driverName]; ok {
+		return d, nil
+	}
+	var names []string
+	for name := range dialects {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return nil, fmt.Errorf("unsupported DriverName %q: %s", driverName, strings.Join(names, ", "))
+}
@@ -0,0 +1,265 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package polling
+
+import (
+	gosql "database/sql"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/satori/go.uuid"
+
+	"github.com/actiontech/dtle/internal/client/driver/hete"
+	"github.com/actiontech/dtle/internal/client/driver/mysql"
+	log "github.com/actiontech/dtle/internal/logger"
+	"github.com/actiontech/dtle/internal/models"
+)
+
+// Extractor copies tables of a database without a change log, then polls
+// the rows inserted or updated from the watermark column of each table,
+// and sends them to the target task as the MySQL extractor does
+type Extractor struct {
+	logger     *log.Entry
+	subject    string
+	maxPayload int
+	cfg        *PollingConfig
+	dialect    *dialect
+	db         *gosql.DB
+	publisher  *hete.Publisher
+	waitCh     chan *models.WaitResult
+
+	// sid stands for the tables polled in the GTIDs of the transactions,
+	// whose GNO is made of the position of their rows
+	sid uuid.UUID
+	// tables are those polled
+	tables []*table
+	// tableSent tells whether the structure of a table was sent along with
+	// its rows
+	tableSent map[*table]bool
+
+	// gnoLock guards gno
+	gnoLock sync.Mutex
+	// gno is that of the last transaction sent
+	gno int64
+
+	stage string
+
+	shutdown     bool
+	shutdownCh   chan struct{}
+	shutdownLock sync.Mutex
+}
+
+func NewExtractor(subject, tp string, maxPayload int, cfg *PollingConfig, logger *log.Logger) (*Extractor, error) {
+	cfg = cfg.SetDefault()
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	d, err := dialectOf(cfg.ConnectionConfig.DriverName)
+	if err != nil {
+		return nil, err
+	}
+	entry := log.NewEntry(logger).WithFields(log.Fields{
+		"job": subject,
+	})
+	e := &Extractor{
+		logger:     entry,
+		subject:    subject,
+		maxPayload: maxPayload,
+		cfg:        cfg,
+		dialect:    d,
+		sid:        tablesSid(cfg),
+		waitCh:     make(chan *models.WaitResult, 1),
+		tableSent:  make(map[*table]bool),
+		shutdownCh: make(chan struct{}),
+	}
+	return e, nil
+}
+
+// tablesSid returns the SID of the GTIDs of a job, made of its tables, as
+// the DSN may change with the password
+func tablesSid(cfg *PollingConfig) uuid.UUID {
+	var names []string
+	for _, t := range cfg.Tables {
+		names = append(names, fmt.Sprintf("%s.%s", t.Schema, t.Table))
+	}
+	sort.Strings(names)
+	return uuid.NewV5(uuid.NamespaceOID, fmt.Sprintf("polling:%s:%s",
+		cfg.ConnectionConfig.DriverName, strings.Join(names, ",")))
+}
+
+func (e *Extractor) Run() {
+	defer models.RecoverPanic(e.onPanic)
+	e.logger.Printf("polling.extractor: Poll %d tables by %s every %dms", len(e.cfg.Tables),
+		e.cfg.ConnectionConfig.DriverName, e.cfg.PollInterval)
+
+	if err := e.initDBConnection(); err != nil {
+		e.onError(mysql.TaskStateDead, err)
+		return
+	}
+	if err := e.initNatsPubClient(); err != nil {
+		e.onError(mysql.TaskStateDead, err)
+		return
+	}
+	if err := e.loadTables(); err != nil {
+		e.onError(mysql.TaskStateDead, err)
+		return
+	}
+
+	if e.cfg.Gtid != "" {
+		gno, err := hete.ParseGtid(e.sid, e.cfg.Gtid)
+		if err != nil {
+			e.onError(mysql.TaskStateDead, err)
+			return
+		}
+		// The rows are polled from the position of the last transaction
+		// on, those at it again
+		for _, t := range e.tables {
+			t.last = gno
+		}
+		e.setGno(gno)
+		e.logger.Printf("polling.extractor: Polling the rows from position %d", gno)
+	} else if err := e.snapshot(); err != nil {
+		e.onError(mysql.TaskStateDead, err)
+		return
+	}
+
+	if err := e.pollChanges(); err != nil {
+		e.onError(mysql.TaskStateDead, err)
+	}
+}
+
+// openDB connects to a database by its database/sql driver
+func openDB(c *ConnectionConfig) (*gosql.DB, error) {
+	found := false
+	for _, name := range gosql.Drivers() {
+		found = found || name == c.DriverName
+	}
+	if !found {
+		return nil, fmt.Errorf("this build has no %s driver: build dtle with the %q tag", c.DriverName, c.DriverName)
+	}
+	return gosql.Open(c.DriverName, c.DSN)
+}
+
+// CheckConnection connects to a database
+func CheckConnection(c *ConnectionConfig) error {
+	db, err := openDB(c)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	return db.Ping()
+}
+
+func (e *Extractor) initDBConnection() (err error) {
+	if err := CheckConnection(e.cfg.ConnectionConfig); err != nil {
+		return err
+	}
+	if e.db, err = openDB(e.cfg.ConnectionConfig); err != nil {
+		return err
+	}
+	e.logger.Printf("polling.extractor: Transactions sent as %s", e.sid)
+	return nil
+}
+
+func (e *Extractor) initNatsPubClient() (err error) {
+	e.publisher, err = hete.NewPublisher("polling.extractor", e.subject, e.maxPayload, e.cfg.NatsAddr, e.shutdownCh, e.logger)
+	return err
+}
+
+// loadTables reads the columns of the tables polled
+func (e *Extractor) loadTables() error {
+	for _, pt := range e.cfg.Tables {
+		t, err := loadTable(e.db, e.dialect, pt)
+		if err != nil {
+			return err
+		}
+		e.logger.Printf("polling.extractor: Table %s.%s polled by %s", t.Schema, t.Table, t.Column)
+		e.tables = append(e.tables, t)
+	}
+	return nil
+}
+
+func (e *Extractor) getGno() int64 {
+	e.gnoLock.Lock()
+	defer e.gnoLock.Unlock()
+	return e.gno
+}
+
+func (e *Extractor) setGno(gno int64) {
+	e.gnoLock.Lock()
+	defer e.gnoLock.Unlock()
+	e.gno = gno
+}
+
+func (e *Extractor) Stats() (*models.TaskStatistics, error) {
+	coordinates := &models.CurrentCoordinates{}
+	if gno := e.getGno(); gno > 0 {
+		// Position is that the rows are polled from
+		coordinates.Position = gno
+		coordinates.GtidSet = hete.Gtid(e.sid, gno)
+	}
+	return e.publisher.Statistics(e.stage, coordinates), nil
+}
+
+// ID returns the config to restart with, as config.DriverCtx is read back
+func (e *Extractor) ID() string {
+	cfg := *e.cfg
+	if gno := e.getGno(); gno > 0 {
+		cfg.Gtid = hete.Gtid(e.sid, gno)
+	}
+	id := struct {
+		DriverConfig *PollingConfig
+	}{&cfg}
+	data, err := json.Marshal(id)
+	if err != nil {
+		e.logger.Errorf("polling.extractor: Failed to marshal ID to JSON: %s", err)
+	}
+	return string(data)
+}
+
+func (e *Extractor) onPanic(err *models.PanicError) {
+	e.onError(mysql.TaskStateRestart, err)
+}
+
+func (e *Extractor) onError(state int, err error) {
+	e.logger.Errorf("polling.extractor. error: %v", err.Error())
+	if e.shutdown {
+		return
+	}
+	e.waitCh <- models.NewWaitResult(state, err)
+	e.Shutdown()
+}
+
+func (e *Extractor) WaitCh() chan *models.WaitResult {
+	return e.waitCh
+}
+
+// Shutdown is used to tear down the extractor
+func (e *Extractor) Shutdown() error {
+	e.shutdownLock.Lock()
+	defer e.shutdownLock.Unlock()
+
+	if e.shutdown {
+		return nil
+	}
+	e.shutdown = true
+	close(e.shutdownCh)
+
+	if e.publisher != nil {
+		e.publisher.Close()
+	}
+	if e.db != nil {
+		if err := e.db.Close(); err != nil {
+			return err
+		}
+	}
+	e.logger.Printf("polling.extractor: Shutting down")
+	return nil
+}
@@ -0,0 +1,257 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package polling
+
+import (
+	gosql "database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/satori/go.uuid"
+
+	"github.com/actiontech/dtle/internal/client/driver/hete"
+	"github.com/actiontech/dtle/internal/client/driver/mysql/base"
+	"github.com/actiontech/dtle/internal/client/driver/mysql/binlog"
+	umconf "github.com/actiontech/dtle/internal/config/mysql"
+	"github.com/actiontech/dtle/internal/models"
+)
+
+// selectSQL returns the query of the rows of a table, ordered from a
+// position if where is set
+func (e *Extractor) selectSQL(t *table, where bool) string {
+	var quoted []string
+	for _, c := range t.columns {
+		quoted = append(quoted, e.dialect.quote(c.name))
+	}
+	query := fmt.Sprintf("SELECT %s FROM %s.%s", strings.Join(quoted, ", "),
+		e.dialect.quote(t.Schema), e.dialect.quote(t.Table))
+	if where {
+		op := ">="
+		if e.cfg.Mode == ModeIncrement {
+			op = ">"
+		}
+		column := e.dialect.quote(t.Column)
+		query += fmt.Sprintf(" WHERE %s %s %s ORDER BY %s", column, op, e.dialect.placeholder(1), column)
+	}
+	return query
+}
+
+// rowValues converts the values of a row read to those of a binlog event,
+// and returns the position of the row, false if its watermark is null
+func (t *table) rowValues(mode string, scanned []interface{}) (values []interface{}, pos int64, ok bool, err error) {
+	values = make([]interface{}, len(t.columns))
+	for i, c := range t.columns {
+		if values[i], err = c.eventValue(scanned[i]); err != nil {
+			return nil, 0, false, fmt.Errorf("column %s of %s.%s: %v", c.name, t.Schema, t.Table, err)
+		}
+	}
+	if scanned[t.watermark] == nil {
+		return values, 0, false, nil
+	}
+	if pos, err = position(mode, scanned[t.watermark]); err != nil {
+		return nil, 0, false, fmt.Errorf("column %s of %s.%s: %v", t.Column, t.Schema, t.Table, err)
+	}
+	return values, pos, true, nil
+}
+
+// snapshot copies the tables, and sets the rows to be polled from the
+// largest position of each. The rows changed during the copy are polled
+// again, with a later position; the GTID set sent is that of the smallest
+// of the largest positions, the tables are polled from after a restart.
+func (e *Extractor) snapshot() error {
+	e.stage = models.StageSendingData
+	e.logger.Printf("polling.extractor: Step 1: copying the tables")
+
+	for _, t := range e.tables {
+		var count int64
+		query := fmt.Sprintf("SELECT COUNT(*) FROM %s.%s", e.dialect.quote(t.Schema), e.dialect.quote(t.Table))
+		if err := e.db.QueryRow(query).Scan(&count); err == nil {
+			e.publisher.AddRowsEstimate(count)
+		}
+	}
+
+	var gno int64
+	for i, t := range e.tables {
+		if err := e.publisher.PublishCreateTable(t.Schema, t.createTableSQL()); err != nil {
+			return err
+		}
+		e.tableSent[t] = true
+		e.logger.Printf("polling.extractor: Step 1: - copying table %s.%s (%d of %d tables)", t.Schema, t.Table, i+1, len(e.tables))
+		if err := e.dumpTable(t); err != nil {
+			return err
+		}
+		if t.last > 0 && (gno == 0 || t.last < gno) {
+			gno = t.last
+		}
+	}
+
+	if err := e.publisher.PublishDumpComplete(hete.Gtid(e.sid, gno)); err != nil {
+		return err
+	}
+	e.setGno(gno)
+	e.logger.Printf("polling.extractor: Step 2: polling the rows every %dms", e.cfg.PollInterval)
+	return nil
+}
+
+// dumpTable sends the rows of a table, ChunkSize at a time, and records
+// the largest position read
+func (e *Extractor) dumpTable(t *table) error {
+	rows, err := e.db.Query(e.selectSQL(t, false))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	var names []string
+	for _, c := range t.columns {
+		names = append(names, c.name)
+	}
+	dumper := e.publisher.NewTableDumper(t.Schema, t.Table, names, t.def, e.cfg.ChunkSize)
+	for rows.Next() {
+		scanned, err := scanRow(rows, len(t.columns))
+		if err != nil {
+			return err
+		}
+		eventValues, pos, ok, err := t.rowValues(e.cfg.Mode, scanned)
+		if err != nil {
+			return err
+		}
+		if ok {
+			t.observe(pos, eventValues)
+		}
+		row := make([][]byte, len(scanned))
+		for i, c := range t.columns {
+			if row[i], err = c.dumpValue(scanned[i]); err != nil {
+				return fmt.Errorf("column %s of %s.%s: %v", c.name, t.Schema, t.Table, err)
+			}
+		}
+		if err := dumper.Add(row); err != nil {
+			return err
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	return dumper.Flush()
+}
+
+func scanRow(rows *gosql.Rows, n int) ([]interface{}, error) {
+	scanned := make([]interface{}, n)
+	dest := make([]interface{}, n)
+	for i := range scanned {
+		dest[i] = &scanned[i]
+	}
+	return scanned, rows.Scan(dest...)
+}
+
+// pollChanges polls the tables every PollInterval, and sends the rows
+// inserted or updated since the last poll
+func (e *Extractor) pollChanges() error {
+	for {
+		select {
+		case <-e.shutdownCh:
+			return nil
+		default:
+		}
+		e.stage = models.StageSendingBinlogEventToSlave
+		for _, t := range e.tables {
+			if err := e.pollTable(t); err != nil {
+				return err
+			}
+		}
+		e.stage = models.StageMasterHasSentAllBinlogToSlave
+		select {
+		case <-e.shutdownCh:
+			return nil
+		case <-time.After(time.Duration(e.cfg.PollInterval) * time.Millisecond):
+		}
+	}
+}
+
+// transactions groups the rows polled in transactions by position
+type transactions struct {
+	sid     uuid.UUID
+	entries []*binlog.BinlogEntry
+	// pos is the position of the rows of the last transaction
+	pos int64
+	// gno is that of the last transaction, made of the position of its
+	// rows, and after that of the transaction before
+	gno int64
+}
+
+func (txs *transactions) add(pos int64, event binlog.DataEvent) {
+	if len(txs.entries) == 0 || pos != txs.pos {
+		gno := pos
+		if gno <= txs.gno {
+			gno = txs.gno + 1
+		}
+		txs.entries = append(txs.entries, binlog.NewBinlogEntryAt(base.BinlogCoordinateTx{
+			SID: txs.sid,
+			GNO: gno,
+		}))
+		txs.pos, txs.gno = pos, gno
+	}
+	entry := txs.entries[len(txs.entries)-1]
+	entry.Events = append(entry.Events, event)
+}
+
+// rowEvent returns the event of a row polled, an insert replacing the row
+// of the same key on the target
+func (e *Extractor) rowEvent(t *table, values []interface{}) binlog.DataEvent {
+	event := binlog.NewDataEvent(t.Schema, t.Table, binlog.InsertDML, len(t.columns))
+	event.NewColumnValues = umconf.ToColumnValues(values)
+	if !e.tableSent[t] {
+		event.Table = t.def
+		e.tableSent[t] = true
+	}
+	return event
+}
+
+// pollTable sends the rows of a table from its last position, but those
+// read at it already
+func (e *Extractor) pollTable(t *table) error {
+	rows, err := e.db.Query(e.selectSQL(t, true), positionArg(e.cfg.Mode, t.last))
+	if err != nil {
+		return fmt.Errorf("polling %s.%s: %v", t.Schema, t.Table, err)
+	}
+	defer rows.Close()
+
+	txs := &transactions{sid: e.sid, gno: e.getGno()}
+	for rows.Next() {
+		scanned, err := scanRow(rows, len(t.columns))
+		if err != nil {
+			return err
+		}
+		values, pos, ok, err := t.rowValues(e.cfg.Mode, scanned)
+		if err != nil {
+			return err
+		}
+		if !ok || !t.observe(pos, values) {
+			continue
+		}
+		txs.add(pos, e.rowEvent(t, values))
+		// The last transaction may get more rows
+		if n := len(txs.entries) - 1; n >= hete.MaxEntriesPerMessage {
+			if err := e.publishEntries(txs.entries[:n]); err != nil {
+				return err
+			}
+			txs.entries = txs.entries[n:]
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	return e.publishEntries(txs.entries)
+}
+
+// publishEntries sends transactions of the rows polled
+func (e *Extractor) publishEntries(entries []*binlog.BinlogEntry) error {
+	return e.publisher.PublishEntries(entries, func(last *binlog.BinlogEntry) {
+		e.setGno(last.Coordinates.GNO)
+	})
+}
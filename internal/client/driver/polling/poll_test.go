@@ -0,0 +1,138 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package polling
+
+import (
+	"io/ioutil"
+	"reflect"
+	"testing"
+
+	"github.com/actiontech/dtle/internal/client/driver/hete"
+	"github.com/actiontech/dtle/internal/client/driver/mysql/binlog"
+	log "github.com/actiontech/dtle/internal/logger"
+)
+
+func newTestExtractor(t *testing.T, cfg *PollingConfig) *Extractor {
+	e, err := NewExtractor("job", "", 1<<20, cfg, log.New(ioutil.Discard, log.InfoLevel))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return e
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     *PollingConfig
+		wantErr bool
+	}{
+		{"timestamp", &PollingConfig{
+			ConnectionConfig: &ConnectionConfig{DriverName: "mysql", DSN: "root@/shop"},
+			Tables: []*PolledTable{
+				{Schema: "shop", Table: "orders", Column: "updated_at", Key: []string{"id"}},
+				{Schema: "shop", Table: "items", Column: "updated_at", Key: []string{"order_id", "sku"}},
+			},
+		}, false},
+		{"increment with the watermark as key", &PollingConfig{
+			ConnectionConfig: &ConnectionConfig{DriverName: "oracle", DSN: "oracle://scott@db/orcl"},
+			Mode:             ModeIncrement,
+			Tables:           []*PolledTable{{Schema: "SCOTT", Table: "EVENTS", Column: "ID"}},
+		}, false},
+		{"increment of two tables", &PollingConfig{
+			ConnectionConfig: &ConnectionConfig{DriverName: "mysql", DSN: "root@/shop"},
+			Mode:             ModeIncrement,
+			Tables: []*PolledTable{
+				{Schema: "shop", Table: "orders", Column: "id"},
+				{Schema: "shop", Table: "items", Column: "id"},
+			},
+		}, true},
+		{"timestamp without key", &PollingConfig{
+			ConnectionConfig: &ConnectionConfig{DriverName: "mysql", DSN: "root@/shop"},
+			Tables:           []*PolledTable{{Schema: "shop", Table: "orders", Column: "updated_at"}},
+		}, true},
+		{"unknown driver", &PollingConfig{
+			ConnectionConfig: &ConnectionConfig{DriverName: "db2", DSN: "db2://db"},
+			Tables:           []*PolledTable{{Schema: "shop", Table: "orders", Column: "id", Key: []string{"id"}}},
+		}, true},
+		{"no table", &PollingConfig{
+			ConnectionConfig: &ConnectionConfig{DriverName: "mysql", DSN: "root@/shop"},
+		}, true},
+	}
+	for _, tt := range tests {
+		if err := tt.cfg.SetDefault().Validate(); (err != nil) != tt.wantErr {
+			t.Errorf("%s: Validate() error = %v, wantErr %v", tt.name, err, tt.wantErr)
+		}
+	}
+}
+
+func TestSelectSQL(t *testing.T) {
+	tb := &table{
+		PolledTable: &PolledTable{Schema: "dbo", Table: "orders", Column: "updated_at"},
+		columns:     []*column{{name: "id"}, {name: "updated_at"}},
+	}
+	e := newTestExtractor(t, &PollingConfig{
+		ConnectionConfig: &ConnectionConfig{DriverName: "sqlserver", DSN: "sqlserver://sa@db"},
+		Tables:           []*PolledTable{{Schema: "dbo", Table: "orders", Column: "updated_at", Key: []string{"id"}}},
+	})
+	if got, want := e.selectSQL(tb, true),
+		"SELECT [id], [updated_at] FROM [dbo].[orders] WHERE [updated_at] >= @p1 ORDER BY [updated_at]"; got != want {
+		t.Errorf("selectSQL() = %v, want %v", got, want)
+	}
+
+	e.cfg.Mode = ModeIncrement
+	e.dialect = dialects["oracle"]
+	if got, want := e.selectSQL(tb, true),
+		`SELECT "id", "updated_at" FROM "dbo"."orders" WHERE "updated_at" > :1 ORDER BY "updated_at"`; got != want {
+		t.Errorf("selectSQL() = %v, want %v", got, want)
+	}
+}
+
+func TestTransactions(t *testing.T) {
+	e := newTestExtractor(t, &PollingConfig{
+		ConnectionConfig: &ConnectionConfig{DriverName: "mysql", DSN: "root@/shop"},
+		Tables:           []*PolledTable{{Schema: "shop", Table: "orders", Column: "updated_at", Key: []string{"id"}}},
+	})
+	tb := &table{
+		PolledTable: e.cfg.Tables[0],
+		columns:     []*column{{name: "id"}, {name: "updated_at"}},
+		key:         []int{0},
+	}
+	tb.def = tb.tableDef()
+	txs := &transactions{sid: e.sid, gno: 100}
+	for _, pos := range []int64{90, 90, 150, 151, 151} {
+		txs.add(pos, e.rowEvent(tb, []interface{}{int64(1), "2018-01-02"}))
+	}
+	var gnos []int64
+	var events []int
+	for _, entry := range txs.entries {
+		gnos = append(gnos, entry.Coordinates.GNO)
+		events = append(events, len(entry.Events))
+	}
+	// Those of the positions, after the last sent
+	if want := []int64{101, 150, 151}; !reflect.DeepEqual(gnos, want) {
+		t.Errorf("GNOs = %v, want %v", gnos, want)
+	}
+	if want := []int{2, 1, 2}; !reflect.DeepEqual(events, want) {
+		t.Errorf("events = %v, want %v", events, want)
+	}
+	first := txs.entries[0].Events
+	if first[0].DML != binlog.InsertDML || first[0].Table == nil || first[1].Table != nil {
+		t.Errorf("events = %+v", first)
+	}
+
+	gtid := hete.Gtid(e.sid, 151)
+	if gno, err := hete.ParseGtid(e.sid, gtid); err != nil || gno != 151 {
+		t.Errorf("ParseGtid(%v) = %v, %v", gtid, gno, err)
+	}
+	other := newTestExtractor(t, &PollingConfig{
+		ConnectionConfig: &ConnectionConfig{DriverName: "mysql", DSN: "root@/shop"},
+		Tables:           []*PolledTable{{Schema: "shop", Table: "items", Column: "updated_at", Key: []string{"id"}}},
+	})
+	if _, err := hete.ParseGtid(other.sid, gtid); err == nil {
+		t.Errorf("ParseGtid() of the GTID of other tables, got no error")
+	}
+}
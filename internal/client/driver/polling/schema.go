@@ -0,0 +1,325 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package polling
+
+import (
+	"crypto/sha1"
+	gosql "database/sql"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	usql "github.com/actiontech/dtle/internal/client/driver/mysql/sql"
+	"github.com/actiontech/dtle/internal/config"
+	umconf "github.com/actiontech/dtle/internal/config/mysql"
+)
+
+// column is a column of a table polled, and the MySQL type it is created
+// with on the target
+type column struct {
+	name      string
+	mysqlType string
+	kind      umconf.ColumnType
+}
+
+// table is a table polled
+type table struct {
+	*PolledTable
+	columns []*column
+	// watermark is the index of the watermark column
+	watermark int
+	// key are the indexes of the key columns
+	key []int
+	// def is the structure of the table as sent along with the rows, for
+	// the Kafka target
+	def *config.Table
+
+	// last is the position of the last rows read
+	last int64
+	// seen are the digests of the rows read at last, which the next poll
+	// reads again
+	seen map[string]bool
+}
+
+// loadTable reads the columns of a table polled
+func loadTable(db *gosql.DB, d *dialect, pt *PolledTable) (*table, error) {
+	query := fmt.Sprintf("SELECT * FROM %s.%s WHERE 1 = 0", d.quote(pt.Schema), d.quote(pt.Table))
+	rows, err := db.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	types, err := rows.ColumnTypes()
+	if err != nil {
+		return nil, err
+	}
+
+	t := &table{PolledTable: pt, watermark: -1, seen: make(map[string]bool)}
+	indexes := make(map[string]int)
+	for i, ct := range types {
+		c := &column{name: ct.Name()}
+		c.mysqlType, c.kind = mysqlColumnType(d, ct.DatabaseTypeName(), ct)
+		t.columns = append(t.columns, c)
+		indexes[strings.ToLower(c.name)] = i
+	}
+	i, ok := indexes[strings.ToLower(pt.Column)]
+	if !ok {
+		return nil, fmt.Errorf("watermark column %s of %s.%s not found", pt.Column, pt.Schema, pt.Table)
+	}
+	t.watermark = i
+	for _, name := range pt.Key {
+		i, ok := indexes[strings.ToLower(name)]
+		if !ok {
+			return nil, fmt.Errorf("key column %s of %s.%s not found", name, pt.Schema, pt.Table)
+		}
+		t.key = append(t.key, i)
+	}
+	t.def = t.tableDef()
+	return t, nil
+}
+
+// columnSize is the size of a column as reported by the driver
+type columnSize interface {
+	Length() (int64, bool)
+	DecimalSize() (int64, int64, bool)
+}
+
+// mysqlColumnType returns the MySQL type a column is created with on the
+// target, from the name of its type reported by the driver
+func mysqlColumnType(d *dialect, typeName string, size columnSize) (string, umconf.ColumnType) {
+	typeName = strings.ToUpper(typeName)
+	switch {
+	case typeName == "UNSIGNED BIGINT":
+		return "decimal(20,0)", umconf.DecimalColumnType
+	case strings.HasSuffix(typeName, "INT") || typeName == "INTEGER":
+		return "bigint", umconf.BigIntColumnType
+	case typeName == "DECIMAL" || typeName == "NUMERIC" || typeName == "NUMBER" ||
+		typeName == "MONEY" || typeName == "SMALLMONEY":
+		if precision, scale, ok := size.DecimalSize(); ok && precision > 0 && precision <= 65 && scale >= 0 && scale <= 30 {
+			return fmt.Sprintf("decimal(%d,%d)", precision, scale), umconf.DecimalColumnType
+		}
+		return "longtext", umconf.TextColumnType
+	case typeName == "FLOAT" || typeName == "REAL" || typeName == "DOUBLE" ||
+		typeName == "BINARY_FLOAT" || typeName == "BINARY_DOUBLE":
+		return "double", umconf.DoubleColumnType
+	case typeName == "DATE" && !d.dateTime:
+		return "date", umconf.DateColumnType
+	case typeName == "DATE" || typeName == "DATETIME" || typeName == "DATETIME2" ||
+		typeName == "SMALLDATETIME" || strings.HasPrefix(typeName, "TIMESTAMP") && !strings.Contains(typeName, "ZONE"):
+		return "datetime(6)", umconf.DateTimeColumnType
+	case typeName == "TIME":
+		return "time(6)", umconf.TimeColumnType
+	case strings.Contains(typeName, "CHAR"):
+		if length, ok := size.Length(); ok && length > 0 && length <= 16383 {
+			return fmt.Sprintf("varchar(%d)", length), umconf.VarcharColumnType
+		}
+		return "longtext", umconf.TextColumnType
+	case strings.Contains(typeName, "BINARY") || strings.Contains(typeName, "BLOB") ||
+		typeName == "RAW" || typeName == "IMAGE" || typeName == "LONG RAW":
+		return "longblob", umconf.BlobColumnType
+	default:
+		return "longtext", umconf.TextColumnType
+	}
+}
+
+// eventValue converts a value read to that of a binlog event of the MySQL
+// type of the column
+func (c *column) eventValue(v interface{}) (interface{}, error) {
+	if b, ok := v.([]byte); ok && c.kind != umconf.BlobColumnType {
+		v = string(b)
+	}
+	switch v := v.(type) {
+	case nil:
+		return nil, nil
+	case time.Time:
+		switch c.kind {
+		case umconf.DateColumnType:
+			return v.Format("2006-01-02"), nil
+		case umconf.TimeColumnType:
+			return v.Format("15:04:05.999999"), nil
+		}
+		return v.Format("2006-01-02 15:04:05.999999"), nil
+	case bool:
+		if v {
+			return c.eventValue(int64(1))
+		}
+		return c.eventValue(int64(0))
+	}
+	switch c.kind {
+	case umconf.BigIntColumnType:
+		switch v := v.(type) {
+		case int64:
+			return v, nil
+		case string:
+			return strconv.ParseInt(v, 10, 64)
+		}
+		return strconv.ParseInt(fmt.Sprint(v), 10, 64)
+	case umconf.DoubleColumnType:
+		switch v := v.(type) {
+		case float64:
+			return v, nil
+		case float32:
+			return float64(v), nil
+		}
+		return strconv.ParseFloat(fmt.Sprint(v), 64)
+	case umconf.BlobColumnType:
+		switch v := v.(type) {
+		case []byte:
+			return v, nil
+		case string:
+			return []byte(v), nil
+		}
+	}
+	switch v := v.(type) {
+	case string:
+		return v, nil
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), nil
+	case float32:
+		return strconv.FormatFloat(float64(v), 'f', -1, 32), nil
+	default:
+		return fmt.Sprint(v), nil
+	}
+}
+
+// dumpValue converts a value read to the text the snapshot sends it as
+func (c *column) dumpValue(v interface{}) ([]byte, error) {
+	value, err := c.eventValue(v)
+	switch value := value.(type) {
+	case nil:
+		return nil, err
+	case []byte:
+		return value, err
+	case string:
+		return []byte(value), err
+	case int64:
+		return []byte(strconv.FormatInt(value, 10)), err
+	case float64:
+		return []byte(strconv.FormatFloat(value, 'g', -1, 64)), err
+	default:
+		return []byte(fmt.Sprint(value)), err
+	}
+}
+
+// timeLayouts are those a time read as text is parsed with
+var timeLayouts = []string{
+	"2006-01-02 15:04:05.999999999",
+	time.RFC3339Nano,
+	"2006-01-02",
+}
+
+// position returns the position of a value of the watermark column: the
+// value in increment mode, the microseconds since the epoch in timestamp
+// mode. A time without zone is in UTC.
+func position(mode string, v interface{}) (int64, error) {
+	if b, ok := v.([]byte); ok {
+		v = string(b)
+	}
+	if mode == ModeIncrement {
+		switch v := v.(type) {
+		case int64:
+			return v, nil
+		case string:
+			return strconv.ParseInt(v, 10, 64)
+		default:
+			return 0, fmt.Errorf("invalid increment %v", v)
+		}
+	}
+	switch v := v.(type) {
+	case time.Time:
+		return v.UnixNano() / int64(time.Microsecond), nil
+	case string:
+		for _, layout := range timeLayouts {
+			if t, err := time.Parse(layout, v); err == nil {
+				return t.UnixNano() / int64(time.Microsecond), nil
+			}
+		}
+	}
+	return 0, fmt.Errorf("invalid timestamp %v", v)
+}
+
+// positionArg returns the parameter of the queries comparing the watermark
+// column to a position
+func positionArg(mode string, pos int64) interface{} {
+	if mode == ModeIncrement {
+		return pos
+	}
+	return time.Unix(0, pos*int64(time.Microsecond)).UTC()
+}
+
+// observe records a row read at a position, and tells whether it was not
+// read yet: the rows before the last position were, and those at it if
+// their values are the same
+func (t *table) observe(pos int64, values []interface{}) bool {
+	switch {
+	case pos < t.last:
+		return false
+	case pos > t.last:
+		t.last = pos
+		t.seen = make(map[string]bool)
+	}
+	digest := rowDigest(values)
+	if t.seen[digest] {
+		return false
+	}
+	t.seen[digest] = true
+	return true
+}
+
+// rowDigest returns the digest of the values of a row, as returned by
+// eventValue
+func rowDigest(values []interface{}) string {
+	sum := sha1.Sum([]byte(fmt.Sprintf("%#v", values)))
+	return string(sum[:])
+}
+
+// createTableSQL returns the CREATE TABLE of the table on the target
+func (t *table) createTableSQL() string {
+	var definitions, keys []string
+	isKey := make(map[int]bool)
+	for _, i := range t.key {
+		isKey[i] = true
+		keys = append(keys, usql.EscapeName(t.columns[i].name))
+	}
+	for i, c := range t.columns {
+		definition := fmt.Sprintf("  %s %s", usql.EscapeName(c.name), c.mysqlType)
+		if isKey[i] {
+			definition += " NOT NULL"
+		}
+		definitions = append(definitions, definition)
+	}
+	definitions = append(definitions, fmt.Sprintf("  PRIMARY KEY (%s)", strings.Join(keys, ",")))
+	return fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (\n%s\n)", usql.EscapeName(t.Table), strings.Join(definitions, ",\n"))
+}
+
+// tableDef returns the structure of the table as read from a MySQL source
+func (t *table) tableDef() *config.Table {
+	def := config.NewTable(t.Schema, t.Table)
+	isKey := make(map[int]bool)
+	for _, i := range t.key {
+		isKey[i] = true
+	}
+	var columns []umconf.Column
+	for i, c := range t.columns {
+		col := umconf.Column{
+			Name:       c.name,
+			Type:       c.kind,
+			ColumnType: c.mysqlType,
+			Nullable:   !isKey[i],
+		}
+		if isKey[i] {
+			col.Key = "PRI"
+		}
+		if c.kind == umconf.DecimalColumnType {
+			fmt.Sscanf(c.mysqlType, "decimal(%d,%d)", &col.Precision, &col.Scale)
+		}
+		columns = append(columns, col)
+	}
+	def.OriginalTableColumns = umconf.NewColumnList(columns)
+	return def
+}
@@ -0,0 +1,157 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package polling
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	umconf "github.com/actiontech/dtle/internal/config/mysql"
+)
+
+// size is the size of a column as reported by a driver
+type size struct {
+	length, precision, scale int64
+}
+
+func (s size) Length() (int64, bool) {
+	return s.length, s.length != 0
+}
+
+func (s size) DecimalSize() (int64, int64, bool) {
+	return s.precision, s.scale, s.precision != 0
+}
+
+func TestMysqlColumnType(t *testing.T) {
+	tests := []struct {
+		driverName string
+		typeName   string
+		size       size
+		wantType   string
+		wantKind   umconf.ColumnType
+	}{
+		{"mysql", "INT", size{}, "bigint", umconf.BigIntColumnType},
+		{"mysql", "UNSIGNED BIGINT", size{}, "decimal(20,0)", umconf.DecimalColumnType},
+		{"mysql", "DECIMAL", size{precision: 12, scale: 2}, "decimal(12,2)", umconf.DecimalColumnType},
+		{"oracle", "NUMBER", size{}, "longtext", umconf.TextColumnType},
+		{"sqlserver", "FLOAT", size{}, "double", umconf.DoubleColumnType},
+		{"mysql", "DATE", size{}, "date", umconf.DateColumnType},
+		{"oracle", "DATE", size{}, "datetime(6)", umconf.DateTimeColumnType},
+		{"oracle", "TIMESTAMP", size{}, "datetime(6)", umconf.DateTimeColumnType},
+		{"oracle", "TIMESTAMP WITH TIME ZONE", size{}, "longtext", umconf.TextColumnType},
+		{"sqlserver", "NVARCHAR", size{length: 50}, "varchar(50)", umconf.VarcharColumnType},
+		{"sqlserver", "NVARCHAR", size{length: 1 << 30}, "longtext", umconf.TextColumnType},
+		{"sqlserver", "VARBINARY", size{length: 16}, "longblob", umconf.BlobColumnType},
+		{"oracle", "RAW", size{}, "longblob", umconf.BlobColumnType},
+		{"sqlserver", "XML", size{}, "longtext", umconf.TextColumnType},
+	}
+	for _, tt := range tests {
+		d, err := dialectOf(tt.driverName)
+		if err != nil {
+			t.Fatal(err)
+		}
+		gotType, gotKind := mysqlColumnType(d, tt.typeName, tt.size)
+		if gotType != tt.wantType || gotKind != tt.wantKind {
+			t.Errorf("mysqlColumnType(%v, %v) = %v, %v, want %v, %v", tt.driverName, tt.typeName, gotType, gotKind, tt.wantType, tt.wantKind)
+		}
+	}
+}
+
+func TestEventValue(t *testing.T) {
+	newColumn := func(typeName string) *column {
+		c := &column{}
+		c.mysqlType, c.kind = mysqlColumnType(dialects["mysql"], typeName, size{length: 16, precision: 10, scale: 2})
+		return c
+	}
+	at := time.Date(2018, 1, 2, 3, 4, 5, 600000000, time.UTC)
+	tests := []struct {
+		column *column
+		value  interface{}
+		want   interface{}
+	}{
+		{newColumn("INT"), int64(7), int64(7)},
+		{newColumn("INT"), []byte("7"), int64(7)},
+		{newColumn("BIGINT"), true, int64(1)},
+		{newColumn("DECIMAL"), []byte("12.50"), "12.50"},
+		{newColumn("DOUBLE"), float32(1.5), float64(1.5)},
+		{newColumn("DATE"), at, "2018-01-02"},
+		{newColumn("DATETIME"), at, "2018-01-02 03:04:05.6"},
+		{newColumn("VARCHAR"), []byte("text"), "text"},
+		{newColumn("BLOB"), []byte{1, 2}, []byte{1, 2}},
+		{newColumn("JSON"), true, "1"},
+		{newColumn("VARCHAR"), nil, nil},
+	}
+	for _, tt := range tests {
+		got, err := tt.column.eventValue(tt.value)
+		if err != nil {
+			t.Errorf("eventValue(%v) of %v error = %v", tt.value, tt.column.mysqlType, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("eventValue(%v) of %v = %#v, want %#v", tt.value, tt.column.mysqlType, got, tt.want)
+		}
+	}
+}
+
+func TestPosition(t *testing.T) {
+	at := time.Date(2018, 1, 2, 3, 4, 5, 600000000, time.UTC)
+	tests := []struct {
+		mode    string
+		value   interface{}
+		want    int64
+		wantErr bool
+	}{
+		{mode: ModeIncrement, value: int64(42), want: 42},
+		{mode: ModeIncrement, value: []byte("42"), want: 42},
+		{mode: ModeIncrement, value: at, wantErr: true},
+		{mode: ModeTimestamp, value: at, want: at.UnixNano() / 1000},
+		{mode: ModeTimestamp, value: at.In(time.FixedZone("", 8*3600)), want: at.UnixNano() / 1000},
+		{mode: ModeTimestamp, value: []byte("2018-01-02 03:04:05.6"), want: at.UnixNano() / 1000},
+		{mode: ModeTimestamp, value: "2018-01-02", want: time.Date(2018, 1, 2, 0, 0, 0, 0, time.UTC).UnixNano() / 1000},
+		{mode: ModeTimestamp, value: int64(42), wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := position(tt.mode, tt.value)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("position(%v, %v) error = %v, wantErr %v", tt.mode, tt.value, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("position(%v, %v) = %v, want %v", tt.mode, tt.value, got, tt.want)
+		}
+	}
+	if arg := positionArg(ModeTimestamp, at.UnixNano()/1000); !arg.(time.Time).Equal(at) {
+		t.Errorf("positionArg() = %v, want %v", arg, at)
+	}
+}
+
+func TestObserve(t *testing.T) {
+	tb := &table{seen: make(map[string]bool)}
+	steps := []struct {
+		pos    int64
+		values []interface{}
+		want   bool
+	}{
+		{10, []interface{}{int64(1), "a"}, true},
+		{10, []interface{}{int64(2), "b"}, true},
+		// Read again by the next poll, from 10 on
+		{10, []interface{}{int64(1), "a"}, false},
+		// Updated again in the same microsecond
+		{10, []interface{}{int64(1), "c"}, true},
+		{12, []interface{}{int64(1), "a"}, true},
+		{10, []interface{}{int64(2), "b"}, false},
+	}
+	for i, step := range steps {
+		if got := tb.observe(step.pos, step.values); got != step.want {
+			t.Errorf("step %d: observe(%v, %v) = %v, want %v", i, step.pos, step.values, got, step.want)
+		}
+	}
+	if tb.last != 12 || len(tb.seen) != 1 {
+		t.Errorf("last = %v, seen %v, want 12 and 1 row", tb.last, len(tb.seen))
+	}
+}
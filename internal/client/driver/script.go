@@ -0,0 +1,63 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package driver
+
+import (
+	"fmt"
+
+	"github.com/mitchellh/mapstructure"
+
+	"github.com/actiontech/dtle/internal/client/driver/script"
+	"github.com/actiontech/dtle/internal/models"
+)
+
+// ScriptDriver replays the tables and events of a script rather than
+// reading a database, as the source of the jobs to be tested
+type ScriptDriver struct {
+	DriverContext
+}
+
+func (sd *ScriptDriver) Start(ctx *ExecContext, task *models.Task) (DriverHandle, error) {
+	var driverConfig script.ScriptConfig
+	if err := mapstructure.WeakDecode(task.Config, &driverConfig); err != nil {
+		return nil, err
+	}
+
+	switch task.Type {
+	case models.TaskTypeSrc:
+		e, err := script.NewExtractor(ctx.Subject, ctx.Tp, ctx.MaxPayload, &driverConfig, sd.logger)
+		if err != nil {
+			return nil, err
+		}
+		go e.Run()
+		return e, nil
+	case models.TaskTypeDest:
+		return nil, fmt.Errorf("Script can only be used on 'Src'")
+	default:
+		return nil, fmt.Errorf("unknown processor type : %+v", task.Type)
+	}
+}
+
+func (sd *ScriptDriver) Validate(task *models.Task) (*models.TaskValidateResponse, error) {
+	var driverConfig script.ScriptConfig
+	reply := &models.TaskValidateResponse{}
+	if err := mapstructure.WeakDecode(task.Config, &driverConfig); err != nil {
+		return reply, err
+	}
+	if task.Type != models.TaskTypeSrc {
+		return reply, fmt.Errorf("Script can only be used on 'Src'")
+	}
+	if err := driverConfig.Validate(); err != nil {
+		return reply, err
+	}
+	reply.Connection.Success = true
+	return reply, nil
+}
+
+func NewScriptDriver(ctx *DriverContext) Driver {
+	return &ScriptDriver{DriverContext: *ctx}
+}
@@ -0,0 +1,136 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package script
+
+import (
+	"fmt"
+)
+
+// The operations of the events
+const (
+	OpInsert = "insert"
+	OpUpdate = "update"
+	OpDelete = "delete"
+)
+
+// ScriptColumn is a column of a scripted table
+type ScriptColumn struct {
+	Name string
+	// Type is the MySQL type of the column: bigint, int, double, float,
+	// decimal(p,s), varchar(n), char(n), text, datetime, date, time or blob
+	Type string
+	// Key tells whether the column is in the primary key of the table
+	Key bool
+}
+
+// ScriptTable is a table of the source, and its rows when the job starts
+type ScriptTable struct {
+	Schema  string
+	Table   string
+	Columns []*ScriptColumn
+	// Rows are copied first, as the rows of a MySQL source are, each the
+	// values by column. The columns missing are null.
+	Rows []map[string]interface{}
+}
+
+// ScriptEvent is a row changed once the tables are copied, sent as a
+// transaction of its own
+type ScriptEvent struct {
+	Schema string
+	Table  string
+	// Op is insert, update or delete
+	Op string
+	// Before is the row updated or deleted
+	Before map[string]interface{}
+	// After is the row inserted or updated
+	After map[string]interface{}
+}
+
+// ScriptConfig is the config of a scripted source task, replaying the
+// tables and events it lists rather than reading a database, for the jobs
+// to be tested
+type ScriptConfig struct {
+	Tables   []*ScriptTable
+	Events   []*ScriptEvent
+	NatsAddr string
+
+	// Gtid is the last event replicated, "uuid:1-n" for the nth event. The
+	// events after it are sent again, without copying the tables.
+	Gtid string
+}
+
+// Validate checks the config of a source task
+func (c *ScriptConfig) Validate() error {
+	if len(c.Tables) == 0 {
+		return fmt.Errorf("Tables is empty: the tables of the script must be listed")
+	}
+	tables := make(map[string]*ScriptTable)
+	for _, t := range c.Tables {
+		if t.Schema == "" || t.Table == "" {
+			return fmt.Errorf("a table of the script has no Schema or Table")
+		}
+		name := fmt.Sprintf("%s.%s", t.Schema, t.Table)
+		if tables[name] != nil {
+			return fmt.Errorf("table %s is listed twice", name)
+		}
+		tables[name] = t
+		if len(t.Columns) == 0 {
+			return fmt.Errorf("table %s has no Columns", name)
+		}
+		for _, col := range t.Columns {
+			if col.Name == "" {
+				return fmt.Errorf("a column of table %s has no Name", name)
+			}
+			if _, _, err := columnType(col.Type); err != nil {
+				return fmt.Errorf("column %s of table %s: %v", col.Name, name, err)
+			}
+		}
+		for _, row := range t.Rows {
+			if err := checkRow(t, row); err != nil {
+				return fmt.Errorf("a row of table %s: %v", name, err)
+			}
+		}
+	}
+	for i, e := range c.Events {
+		t := tables[fmt.Sprintf("%s.%s", e.Schema, e.Table)]
+		if t == nil {
+			return fmt.Errorf("event %d: table %s.%s is not listed", i+1, e.Schema, e.Table)
+		}
+		switch e.Op {
+		case OpInsert, OpUpdate, OpDelete:
+		default:
+			return fmt.Errorf("event %d: invalid Op %q: %s, %s or %s", i+1, e.Op, OpInsert, OpUpdate, OpDelete)
+		}
+		if e.Op != OpInsert && e.Before == nil {
+			return fmt.Errorf("event %d: %s without the row Before", i+1, e.Op)
+		}
+		if e.Op != OpDelete && e.After == nil {
+			return fmt.Errorf("event %d: %s without the row After", i+1, e.Op)
+		}
+		for _, row := range []map[string]interface{}{e.Before, e.After} {
+			if err := checkRow(t, row); err != nil {
+				return fmt.Errorf("event %d: %v", i+1, err)
+			}
+		}
+	}
+	return nil
+}
+
+// checkRow checks that the values of a row are those of columns of its
+// table
+func checkRow(t *ScriptTable, row map[string]interface{}) error {
+	for name := range row {
+		found := false
+		for _, col := range t.Columns {
+			found = found || col.Name == name
+		}
+		if !found {
+			return fmt.Errorf("no column %s", name)
+		}
+	}
+	return nil
+}
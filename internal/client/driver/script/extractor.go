@@ -0,0 +1,281 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package script
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+	"sync/atomic"
+
+	"github.com/satori/go.uuid"
+
+	"github.com/actiontech/dtle/internal/client/driver/hete"
+	"github.com/actiontech/dtle/internal/client/driver/mysql"
+	"github.com/actiontech/dtle/internal/client/driver/mysql/base"
+	"github.com/actiontech/dtle/internal/client/driver/mysql/binlog"
+	umconf "github.com/actiontech/dtle/internal/config/mysql"
+	log "github.com/actiontech/dtle/internal/logger"
+	"github.com/actiontech/dtle/internal/models"
+)
+
+// Extractor copies the rows of the tables of a script, then sends its
+// events, to the target task as the MySQL extractor does. It keeps running
+// once the events are sent, as a source waiting for changes.
+type Extractor struct {
+	logger     *log.Entry
+	subject    string
+	maxPayload int
+	cfg        *ScriptConfig
+	publisher  *hete.Publisher
+	waitCh     chan *models.WaitResult
+
+	// sid stands for the script in the GTIDs of the transactions, whose GNO
+	// is the number of their event
+	sid    uuid.UUID
+	tables map[string]*table
+	// tableSent tells whether the structure of a table was sent along with
+	// its rows
+	tableSent map[*table]bool
+
+	// gno is that of the last event sent
+	gno int64
+
+	stage string
+
+	shutdown     bool
+	shutdownCh   chan struct{}
+	shutdownLock sync.Mutex
+}
+
+func NewExtractor(subject, tp string, maxPayload int, cfg *ScriptConfig, logger *log.Logger) (*Extractor, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	entry := log.NewEntry(logger).WithFields(log.Fields{
+		"job": subject,
+	})
+	e := &Extractor{
+		logger:     entry,
+		subject:    subject,
+		maxPayload: maxPayload,
+		cfg:        cfg,
+		sid:        uuid.NewV5(uuid.NamespaceOID, "script:"+subject),
+		tables:     make(map[string]*table),
+		tableSent:  make(map[*table]bool),
+		waitCh:     make(chan *models.WaitResult, 1),
+		shutdownCh: make(chan struct{}),
+	}
+	for _, st := range cfg.Tables {
+		e.tables[fmt.Sprintf("%s.%s", st.Schema, st.Table)] = newTable(st)
+	}
+	return e, nil
+}
+
+func (e *Extractor) Run() {
+	defer models.RecoverPanic(e.onPanic)
+	e.logger.Printf("script.extractor: Replay %d tables and %d events", len(e.cfg.Tables), len(e.cfg.Events))
+
+	if err := e.initNatsPubClient(); err != nil {
+		e.onError(mysql.TaskStateDead, err)
+		return
+	}
+
+	if e.cfg.Gtid != "" {
+		gno, err := hete.ParseGtid(e.sid, e.cfg.Gtid)
+		if err != nil {
+			e.onError(mysql.TaskStateDead, err)
+			return
+		}
+		atomic.StoreInt64(&e.gno, gno)
+		e.logger.Printf("script.extractor: Sending the events after event %d", gno)
+	} else if err := e.copyTables(); err != nil {
+		e.onError(mysql.TaskStateDead, err)
+		return
+	}
+
+	if err := e.sendEvents(); err != nil {
+		e.onError(mysql.TaskStateDead, err)
+		return
+	}
+	e.stage = models.StageMasterHasSentAllBinlogToSlave
+	e.logger.Printf("script.extractor: All the events are sent")
+}
+
+func (e *Extractor) initNatsPubClient() (err error) {
+	e.publisher, err = hete.NewPublisher("script.extractor", e.subject, e.maxPayload, e.cfg.NatsAddr, e.shutdownCh, e.logger)
+	return err
+}
+
+// copyTables sends the rows of the tables, a table at a time
+func (e *Extractor) copyTables() error {
+	e.stage = models.StageSendingData
+	e.logger.Printf("script.extractor: Step 1: copying the tables")
+
+	for _, st := range e.cfg.Tables {
+		e.publisher.AddRowsEstimate(int64(len(st.Rows)))
+	}
+	for _, st := range e.cfg.Tables {
+		t := e.tables[fmt.Sprintf("%s.%s", st.Schema, st.Table)]
+		if err := e.publisher.PublishCreateTable(t.Schema, t.createTableSQL()); err != nil {
+			return err
+		}
+		e.tableSent[t] = true
+		if len(t.Rows) == 0 {
+			continue
+		}
+
+		var names []string
+		for _, c := range t.columns {
+			names = append(names, c.name)
+		}
+		// The rows of a table are sent at once
+		dumper := e.publisher.NewTableDumper(t.Schema, t.Table, names, t.def, int64(len(t.Rows)))
+		for _, row := range t.Rows {
+			eventValues, err := t.rowValues(row)
+			if err != nil {
+				return err
+			}
+			values := make([][]byte, len(eventValues))
+			for i, v := range eventValues {
+				values[i] = dumpValue(v)
+			}
+			if err := dumper.Add(values); err != nil {
+				return err
+			}
+		}
+		if err := dumper.Flush(); err != nil {
+			return err
+		}
+	}
+
+	if err := e.publisher.PublishDumpComplete(""); err != nil {
+		return err
+	}
+	e.logger.Printf("script.extractor: Step 2: sending the events")
+	return nil
+}
+
+// sendEvents sends the events after the last one sent, each as a
+// transaction
+func (e *Extractor) sendEvents() error {
+	e.stage = models.StageSendingBinlogEventToSlave
+	var entries []*binlog.BinlogEntry
+	for i := atomic.LoadInt64(&e.gno); i < int64(len(e.cfg.Events)); i++ {
+		event, err := e.dataEvent(e.cfg.Events[i])
+		if err != nil {
+			return fmt.Errorf("event %d: %v", i+1, err)
+		}
+		entry := binlog.NewBinlogEntryAt(base.BinlogCoordinateTx{SID: e.sid, GNO: i + 1})
+		entry.Events = append(entry.Events, event)
+		entries = append(entries, entry)
+		if len(entries) >= hete.MaxEntriesPerMessage {
+			if err := e.publishEntries(entries); err != nil {
+				return err
+			}
+			entries = nil
+		}
+	}
+	return e.publishEntries(entries)
+}
+
+// dataEvent returns the binlog event of an event of the script
+func (e *Extractor) dataEvent(se *ScriptEvent) (binlog.DataEvent, error) {
+	t := e.tables[fmt.Sprintf("%s.%s", se.Schema, se.Table)]
+	dml := map[string]binlog.EventDML{
+		OpInsert: binlog.InsertDML,
+		OpUpdate: binlog.UpdateDML,
+		OpDelete: binlog.DeleteDML,
+	}[se.Op]
+	event := binlog.NewDataEvent(t.Schema, t.Table, dml, len(t.columns))
+	if se.Before != nil && se.Op != OpInsert {
+		values, err := t.rowValues(se.Before)
+		if err != nil {
+			return event, err
+		}
+		event.WhereColumnValues = umconf.ToColumnValues(values)
+	}
+	if se.After != nil && se.Op != OpDelete {
+		values, err := t.rowValues(se.After)
+		if err != nil {
+			return event, err
+		}
+		event.NewColumnValues = umconf.ToColumnValues(values)
+	}
+	if !e.tableSent[t] {
+		event.Table = t.def
+		e.tableSent[t] = true
+	}
+	return event, nil
+}
+
+// publishEntries sends transactions of events
+func (e *Extractor) publishEntries(entries []*binlog.BinlogEntry) error {
+	return e.publisher.PublishEntries(entries, func(last *binlog.BinlogEntry) {
+		atomic.StoreInt64(&e.gno, last.Coordinates.GNO)
+	})
+}
+
+func (e *Extractor) Stats() (*models.TaskStatistics, error) {
+	coordinates := &models.CurrentCoordinates{}
+	if gno := atomic.LoadInt64(&e.gno); gno > 0 {
+		coordinates.Position = gno
+		coordinates.GtidSet = hete.Gtid(e.sid, gno)
+	}
+	return e.publisher.Statistics(e.stage, coordinates), nil
+}
+
+// ID returns the config to restart with, as config.DriverCtx is read back
+func (e *Extractor) ID() string {
+	cfg := *e.cfg
+	if gno := atomic.LoadInt64(&e.gno); gno > 0 {
+		cfg.Gtid = hete.Gtid(e.sid, gno)
+	}
+	id := struct {
+		DriverConfig *ScriptConfig
+	}{&cfg}
+	data, err := json.Marshal(id)
+	if err != nil {
+		e.logger.Errorf("script.extractor: Failed to marshal ID to JSON: %s", err)
+	}
+	return string(data)
+}
+
+func (e *Extractor) onPanic(err *models.PanicError) {
+	e.onError(mysql.TaskStateRestart, err)
+}
+
+func (e *Extractor) onError(state int, err error) {
+	e.logger.Errorf("script.extractor. error: %v", err.Error())
+	if e.shutdown {
+		return
+	}
+	e.waitCh <- models.NewWaitResult(state, err)
+	e.Shutdown()
+}
+
+func (e *Extractor) WaitCh() chan *models.WaitResult {
+	return e.waitCh
+}
+
+// Shutdown is used to tear down the extractor
+func (e *Extractor) Shutdown() error {
+	e.shutdownLock.Lock()
+	defer e.shutdownLock.Unlock()
+
+	if e.shutdown {
+		return nil
+	}
+	e.shutdown = true
+	close(e.shutdownCh)
+
+	if e.publisher != nil {
+		e.publisher.Close()
+	}
+	e.logger.Printf("script.extractor: Shutting down")
+	return nil
+}
@@ -0,0 +1,206 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package script
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	usql "github.com/actiontech/dtle/internal/client/driver/mysql/sql"
+	"github.com/actiontech/dtle/internal/config"
+	umconf "github.com/actiontech/dtle/internal/config/mysql"
+)
+
+// column is a column of a scripted table, and the MySQL type it is created
+// with on the target
+type column struct {
+	name      string
+	mysqlType string
+	kind      umconf.ColumnType
+}
+
+// table is a scripted table
+type table struct {
+	*ScriptTable
+	columns []*column
+	// key are the indexes of the key columns
+	key []int
+	// def is the structure of the table as sent along with the rows, for
+	// the Kafka target
+	def *config.Table
+}
+
+// columnType returns the MySQL type a column of a script is created with,
+// from its Type
+func columnType(typ string) (string, umconf.ColumnType, error) {
+	typ = strings.ToLower(strings.TrimSpace(typ))
+	switch {
+	case typ == "bigint" || typ == "int" || typ == "integer":
+		return "bigint", umconf.BigIntColumnType, nil
+	case typ == "double" || typ == "float":
+		return "double", umconf.DoubleColumnType, nil
+	case strings.HasPrefix(typ, "decimal("):
+		var precision, scale int
+		if n, _ := fmt.Sscanf(typ, "decimal(%d,%d)", &precision, &scale); n == 2 {
+			return fmt.Sprintf("decimal(%d,%d)", precision, scale), umconf.DecimalColumnType, nil
+		}
+	case strings.HasPrefix(typ, "varchar(") || strings.HasPrefix(typ, "char("):
+		var length int
+		if _, err := fmt.Sscanf(typ[strings.Index(typ, "(")+1:], "%d)", &length); err == nil {
+			return fmt.Sprintf("varchar(%d)", length), umconf.VarcharColumnType, nil
+		}
+	case typ == "text":
+		return "longtext", umconf.TextColumnType, nil
+	case typ == "datetime":
+		return "datetime(6)", umconf.DateTimeColumnType, nil
+	case typ == "date":
+		return "date", umconf.DateColumnType, nil
+	case typ == "time":
+		return "time(6)", umconf.TimeColumnType, nil
+	case typ == "blob":
+		return "longblob", umconf.BlobColumnType, nil
+	}
+	return "", umconf.UnknownColumnType, fmt.Errorf("invalid Type %q", typ)
+}
+
+// newTable returns the table of a script, whose config is valid
+func newTable(st *ScriptTable) *table {
+	t := &table{ScriptTable: st}
+	for i, c := range st.Columns {
+		mysqlType, kind, _ := columnType(c.Type)
+		t.columns = append(t.columns, &column{name: c.Name, mysqlType: mysqlType, kind: kind})
+		if c.Key {
+			t.key = append(t.key, i)
+		}
+	}
+	t.def = t.tableDef()
+	return t
+}
+
+// rowValues returns the values of a row of the script, as those of a
+// binlog event
+func (t *table) rowValues(row map[string]interface{}) ([]interface{}, error) {
+	values := make([]interface{}, len(t.columns))
+	for i, c := range t.columns {
+		v, err := c.eventValue(row[c.name])
+		if err != nil {
+			return nil, fmt.Errorf("column %s of %s.%s: %v", c.name, t.Schema, t.Table, err)
+		}
+		values[i] = v
+	}
+	return values, nil
+}
+
+// eventValue converts a value of the script, as decoded from JSON or HCL,
+// to that of a binlog event of the MySQL type of the column
+func (c *column) eventValue(v interface{}) (interface{}, error) {
+	if v == nil {
+		return nil, nil
+	}
+	switch c.kind {
+	case umconf.BigIntColumnType:
+		switch v := v.(type) {
+		case float64:
+			if v != math.Trunc(v) {
+				return nil, fmt.Errorf("%v is not an integer", v)
+			}
+			return int64(v), nil
+		case int:
+			return int64(v), nil
+		case int64:
+			return v, nil
+		}
+		return strconv.ParseInt(fmt.Sprint(v), 10, 64)
+	case umconf.DoubleColumnType:
+		if f, ok := v.(float64); ok {
+			return f, nil
+		}
+		return strconv.ParseFloat(fmt.Sprint(v), 64)
+	case umconf.BlobColumnType:
+		if b, ok := v.([]byte); ok {
+			return b, nil
+		}
+		return []byte(fmt.Sprint(v)), nil
+	}
+	switch v := v.(type) {
+	case string:
+		return v, nil
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), nil
+	default:
+		return fmt.Sprint(v), nil
+	}
+}
+
+// dumpValue converts a value of a binlog event to the text the tables are
+// copied as
+func dumpValue(value interface{}) []byte {
+	switch value := value.(type) {
+	case nil:
+		return nil
+	case []byte:
+		return value
+	case string:
+		return []byte(value)
+	case int64:
+		return []byte(strconv.FormatInt(value, 10))
+	case float64:
+		return []byte(strconv.FormatFloat(value, 'g', -1, 64))
+	default:
+		return []byte(fmt.Sprint(value))
+	}
+}
+
+// createTableSQL returns the CREATE TABLE of the table on the target
+func (t *table) createTableSQL() string {
+	var definitions, keys []string
+	isKey := make(map[int]bool)
+	for _, i := range t.key {
+		isKey[i] = true
+		keys = append(keys, usql.EscapeName(t.columns[i].name))
+	}
+	for i, c := range t.columns {
+		definition := fmt.Sprintf("  %s %s", usql.EscapeName(c.name), c.mysqlType)
+		if isKey[i] {
+			definition += " NOT NULL"
+		}
+		definitions = append(definitions, definition)
+	}
+	if len(keys) > 0 {
+		definitions = append(definitions, fmt.Sprintf("  PRIMARY KEY (%s)", strings.Join(keys, ",")))
+	}
+	return fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (\n%s\n)", usql.EscapeName(t.Table), strings.Join(definitions, ",\n"))
+}
+
+// tableDef returns the structure of the table as read from a MySQL source
+func (t *table) tableDef() *config.Table {
+	def := config.NewTable(t.Schema, t.Table)
+	isKey := make(map[int]bool)
+	for _, i := range t.key {
+		isKey[i] = true
+	}
+	var columns []umconf.Column
+	for i, c := range t.columns {
+		col := umconf.Column{
+			Name:       c.name,
+			Type:       c.kind,
+			ColumnType: c.mysqlType,
+			Nullable:   !isKey[i],
+		}
+		if isKey[i] {
+			col.Key = "PRI"
+		}
+		if c.kind == umconf.DecimalColumnType {
+			fmt.Sscanf(c.mysqlType, "decimal(%d,%d)", &col.Precision, &col.Scale)
+		}
+		columns = append(columns, col)
+	}
+	def.OriginalTableColumns = umconf.NewColumnList(columns)
+	return def
+}
@@ -0,0 +1,105 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package script
+
+import (
+	"io/ioutil"
+	"reflect"
+	"testing"
+
+	"github.com/actiontech/dtle/internal/client/driver/mysql/binlog"
+	umconf "github.com/actiontech/dtle/internal/config/mysql"
+	log "github.com/actiontech/dtle/internal/logger"
+)
+
+var testTable = &ScriptTable{
+	Schema: "shop",
+	Table:  "orders",
+	Columns: []*ScriptColumn{
+		{Name: "id", Type: "bigint", Key: true},
+		{Name: "amount", Type: "decimal(10,2)"},
+		{Name: "note", Type: "varchar(64)"},
+	},
+	Rows: []map[string]interface{}{{"id": 1, "amount": 9.5}},
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     *ScriptConfig
+		wantErr bool
+	}{
+		{"events", &ScriptConfig{
+			Tables: []*ScriptTable{testTable},
+			Events: []*ScriptEvent{
+				{Schema: "shop", Table: "orders", Op: OpInsert, After: map[string]interface{}{"id": 2}},
+				{Schema: "shop", Table: "orders", Op: OpDelete, Before: map[string]interface{}{"id": 2}},
+			},
+		}, false},
+		{"no table", &ScriptConfig{}, true},
+		{"unknown type", &ScriptConfig{Tables: []*ScriptTable{{Schema: "shop", Table: "t",
+			Columns: []*ScriptColumn{{Name: "id", Type: "geometry"}}}}}, true},
+		{"unknown column", &ScriptConfig{Tables: []*ScriptTable{testTable}, Events: []*ScriptEvent{
+			{Schema: "shop", Table: "orders", Op: OpInsert, After: map[string]interface{}{"sku": "a"}},
+		}}, true},
+		{"unknown table", &ScriptConfig{Tables: []*ScriptTable{testTable}, Events: []*ScriptEvent{
+			{Schema: "shop", Table: "items", Op: OpInsert, After: map[string]interface{}{"id": 1}},
+		}}, true},
+		{"update without before", &ScriptConfig{Tables: []*ScriptTable{testTable}, Events: []*ScriptEvent{
+			{Schema: "shop", Table: "orders", Op: OpUpdate, After: map[string]interface{}{"id": 1}},
+		}}, true},
+	}
+	for _, tt := range tests {
+		if err := tt.cfg.Validate(); (err != nil) != tt.wantErr {
+			t.Errorf("%s: Validate() error = %v, wantErr %v", tt.name, err, tt.wantErr)
+		}
+	}
+}
+
+func values(cv *umconf.ColumnValues) []interface{} {
+	var result []interface{}
+	for _, v := range cv.AbstractValues {
+		result = append(result, *v)
+	}
+	return result
+}
+
+func TestDataEvent(t *testing.T) {
+	e, err := NewExtractor("job", "", 1<<20, &ScriptConfig{Tables: []*ScriptTable{testTable}},
+		log.New(ioutil.Discard, log.InfoLevel))
+	if err != nil {
+		t.Fatal(err)
+	}
+	event, err := e.dataEvent(&ScriptEvent{
+		Schema: "shop",
+		Table:  "orders",
+		Op:     OpUpdate,
+		Before: map[string]interface{}{"id": float64(1), "amount": 9.5},
+		After:  map[string]interface{}{"id": "1", "amount": "10.25", "note": "paid"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if event.DML != binlog.UpdateDML || event.Table == nil {
+		t.Fatalf("event %+v", event)
+	}
+	if got, want := values(event.WhereColumnValues), []interface{}{int64(1), "9.5", nil}; !reflect.DeepEqual(got, want) {
+		t.Errorf("before = %#v, want %#v", got, want)
+	}
+	if got, want := values(event.NewColumnValues), []interface{}{int64(1), "10.25", "paid"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("after = %#v, want %#v", got, want)
+	}
+	// the structure of a table is sent with its first rows only
+	if event, _ = e.dataEvent(&ScriptEvent{Schema: "shop", Table: "orders", Op: OpInsert,
+		After: map[string]interface{}{"id": 2}}); event.Table != nil {
+		t.Errorf("structure sent twice")
+	}
+	if _, err := e.dataEvent(&ScriptEvent{Schema: "shop", Table: "orders", Op: OpInsert,
+		After: map[string]interface{}{"id": 2.5}}); err == nil {
+		t.Errorf("2.5 is a bigint")
+	}
+}
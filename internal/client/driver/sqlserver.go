@@ -0,0 +1,68 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package driver
+
+import (
+	"fmt"
+
+	"github.com/mitchellh/mapstructure"
+
+	"github.com/actiontech/dtle/internal/client/driver/sqlserver"
+	"github.com/actiontech/dtle/internal/models"
+)
+
+// SQLServerDriver reads a SQL Server database with CDC, as the source of a
+// MySQL or Kafka target
+type SQLServerDriver struct {
+	DriverContext
+}
+
+func (sd *SQLServerDriver) Start(ctx *ExecContext, task *models.Task) (DriverHandle, error) {
+	var driverConfig sqlserver.SQLServerConfig
+	if err := mapstructure.WeakDecode(task.Config, &driverConfig); err != nil {
+		return nil, err
+	}
+
+	switch task.Type {
+	case models.TaskTypeSrc:
+		e, err := sqlserver.NewExtractor(ctx.Subject, ctx.Tp, ctx.MaxPayload, &driverConfig, sd.logger)
+		if err != nil {
+			return nil, err
+		}
+		go e.Run()
+		return e, nil
+	case models.TaskTypeDest:
+		return nil, fmt.Errorf("SQL Server can only be used on 'Src'")
+	default:
+		return nil, fmt.Errorf("unknown processor type : %+v", task.Type)
+	}
+}
+
+func (sd *SQLServerDriver) Validate(task *models.Task) (*models.TaskValidateResponse, error) {
+	var driverConfig sqlserver.SQLServerConfig
+	reply := &models.TaskValidateResponse{}
+	if err := mapstructure.WeakDecode(task.Config, &driverConfig); err != nil {
+		return reply, err
+	}
+	if task.Type != models.TaskTypeSrc {
+		return reply, fmt.Errorf("SQL Server can only be used on 'Src'")
+	}
+	if err := driverConfig.SetDefault().Validate(); err != nil {
+		return reply, err
+	}
+	if err := sqlserver.CheckConnection(driverConfig.ConnectionConfig); err != nil {
+		reply.Connection.Success = false
+		reply.Connection.Error = err.Error()
+	} else {
+		reply.Connection.Success = true
+	}
+	return reply, nil
+}
+
+func NewSQLServerDriver(ctx *DriverContext) Driver {
+	return &SQLServerDriver{DriverContext: *ctx}
+}
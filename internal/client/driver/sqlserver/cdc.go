@@ -0,0 +1,259 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package sqlserver
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/actiontech/dtle/internal/client/driver/mysql/base"
+	"github.com/actiontech/dtle/internal/client/driver/mysql/binlog"
+	umconf "github.com/actiontech/dtle/internal/config/mysql"
+	"github.com/actiontech/dtle/internal/models"
+)
+
+// The __$operation of the rows of the CDC tables
+const (
+	operationDelete       = 1
+	operationInsert       = 2
+	operationUpdateBefore = 3
+	operationUpdateAfter  = 4
+)
+
+// cdcRow is a row of a CDC table
+type cdcRow struct {
+	table     *table
+	commitLsn lsn
+	seqval    lsn
+	operation int
+	values    []interface{}
+}
+
+// streamChanges reads the CDC tables after the LSN of the last transaction
+// sent, BatchTransactions at a time, and sends the transactions in the
+// order of their commit
+func (e *Extractor) streamChanges() error {
+	for {
+		select {
+		case <-e.shutdownCh:
+			return nil
+		default:
+		}
+		from := e.getLsn().increment()
+		to, more, err := e.batchEnd(from)
+		if err != nil {
+			return err
+		}
+		if to != nil {
+			e.stage = models.StageSendingBinlogEventToSlave
+			if err := e.checkRetention(from); err != nil {
+				return err
+			}
+			e.logSchemaChanges(from, to)
+			if err := e.readChanges(from, to); err != nil {
+				return err
+			}
+			if more {
+				continue
+			}
+		}
+		e.stage = models.StageMasterHasSentAllBinlogToSlave
+		select {
+		case <-e.shutdownCh:
+			return nil
+		case <-time.After(time.Duration(e.cfg.PollInterval) * time.Millisecond):
+		}
+	}
+}
+
+// batchEnd returns the commit LSN of the last of the next BatchTransactions
+// transactions from an LSN, nil if none, and whether more follow
+func (e *Extractor) batchEnd(from lsn) (to lsn, more bool, err error) {
+	rows, err := e.db.Query(`SELECT TOP (@p1) start_lsn FROM cdc.lsn_time_mapping
+		WHERE start_lsn >= @p2 AND start_lsn <= sys.fn_cdc_get_max_lsn() AND tran_id <> 0x00 ORDER BY start_lsn`,
+		e.cfg.BatchTransactions+1, []byte(from))
+	if err != nil {
+		return nil, false, err
+	}
+	defer rows.Close()
+	n := 0
+	for rows.Next() {
+		var commitLsn []byte
+		if err := rows.Scan(&commitLsn); err != nil {
+			return nil, false, err
+		}
+		if n++; n > e.cfg.BatchTransactions {
+			more = true
+			break
+		}
+		to = lsn(commitLsn)
+	}
+	return to, more, rows.Err()
+}
+
+// checkRetention checks that the changes from an LSN are still in the CDC
+// tables, not cleaned up
+func (e *Extractor) checkRetention(from lsn) error {
+	for _, t := range e.tables {
+		var minLsn []byte
+		if err := e.db.QueryRow("SELECT sys.fn_cdc_get_min_lsn(@p1)", t.captureInstance).Scan(&minLsn); err != nil {
+			return err
+		}
+		if len(minLsn) == lsnLength && lsn(minLsn).compare(from) > 0 {
+			return fmt.Errorf("the changes of %s from LSN %s were cleaned up, the oldest is at %s",
+				t.captureInstance, from, lsn(minLsn))
+		}
+	}
+	return nil
+}
+
+// logSchemaChanges warns of the DDLs on the replicated tables, which CDC
+// does not capture
+func (e *Extractor) logSchemaChanges(from, to lsn) {
+	rows, err := e.db.Query(`SELECT OBJECT_SCHEMA_NAME(source_object_id), OBJECT_NAME(source_object_id), ddl_command
+		FROM cdc.ddl_history WHERE ddl_lsn >= @p1 AND ddl_lsn <= @p2 ORDER BY ddl_lsn`, []byte(from), []byte(to))
+	if err != nil {
+		e.logger.Warnf("sqlserver.extractor: can not read cdc.ddl_history: %v", err)
+		return
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var schema, name, command string
+		if err := rows.Scan(&schema, &name, &command); err != nil {
+			e.logger.Warnf("sqlserver.extractor: can not read cdc.ddl_history: %v", err)
+			return
+		}
+		e.logger.Warnf("sqlserver.extractor: DDL on %s.%s is not replicated, and the columns captured "+
+			"are those of the capture instance: %s", schema, name, command)
+	}
+}
+
+// readChanges reads the changes of all the tables committed in a range of
+// LSNs, and sends them by transaction
+func (e *Extractor) readChanges(from, to lsn) error {
+	var changes []*cdcRow
+	for _, t := range e.tables {
+		rows, err := e.tableChanges(t, from, to)
+		if err != nil {
+			return err
+		}
+		changes = append(changes, rows...)
+	}
+	sort.SliceStable(changes, func(i, j int) bool {
+		if c := changes[i].commitLsn.compare(changes[j].commitLsn); c != 0 {
+			return c < 0
+		}
+		if c := changes[i].seqval.compare(changes[j].seqval); c != 0 {
+			return c < 0
+		}
+		return changes[i].operation < changes[j].operation
+	})
+
+	var entries []*binlog.BinlogEntry
+	// commitLsn is that of the last transaction
+	var commitLsn lsn
+	for i := 0; i < len(changes); i++ {
+		change := changes[i]
+		if len(entries) == 0 || commitLsn.compare(change.commitLsn) != 0 {
+			gno, err := change.commitLsn.gno()
+			if err != nil {
+				return err
+			}
+			entries = append(entries, binlog.NewBinlogEntryAt(base.BinlogCoordinateTx{
+				SID: e.sid,
+				GNO: gno,
+			}))
+			commitLsn = change.commitLsn
+		}
+		entry := entries[len(entries)-1]
+
+		t := change.table
+		var event binlog.DataEvent
+		switch change.operation {
+		case operationInsert:
+			event = binlog.NewDataEvent(t.schema, t.name, binlog.InsertDML, len(t.columns))
+			event.NewColumnValues = umconf.ToColumnValues(change.values)
+		case operationDelete:
+			event = binlog.NewDataEvent(t.schema, t.name, binlog.DeleteDML, len(t.columns))
+			event.WhereColumnValues = umconf.ToColumnValues(change.values)
+		case operationUpdateBefore:
+			// Followed by the row after the update
+			if i+1 == len(changes) || changes[i+1].operation != operationUpdateAfter {
+				return fmt.Errorf("update of %s.%s at LSN %s without the row after it", t.schema, t.name, change.commitLsn)
+			}
+			i++
+			event = binlog.NewDataEvent(t.schema, t.name, binlog.UpdateDML, len(t.columns))
+			event.WhereColumnValues = umconf.ToColumnValues(change.values)
+			event.NewColumnValues = umconf.ToColumnValues(changes[i].values)
+		default:
+			return fmt.Errorf("unexpected operation %d of %s.%s at LSN %s", change.operation, t.schema, t.name, change.commitLsn)
+		}
+		if !e.tableSent[t] {
+			event.Table = t.def
+			e.tableSent[t] = true
+		}
+		entry.Events = append(entry.Events, event)
+	}
+
+	if err := e.publisher.PublishEntries(entries, func(last *binlog.BinlogEntry) {
+		e.setLsn(lsnOfGno(last.Coordinates.GNO))
+	}); err != nil {
+		return err
+	}
+	// The transactions of the range not touching the tables replicated
+	e.setLsn(to)
+	return nil
+}
+
+// tableChanges reads the changes of a table in a range of LSNs, with the
+// row before each update
+func (e *Extractor) tableChanges(t *table, from, to lsn) ([]*cdcRow, error) {
+	var columns []string
+	for _, c := range t.columns {
+		columns = append(columns, quoteIdentifier(c.name))
+	}
+	query := fmt.Sprintf(`SELECT __$start_lsn, __$seqval, __$operation, %s
+		FROM cdc.%s(@p1, @p2, N'all update old') ORDER BY __$start_lsn, __$seqval, __$operation`,
+		strings.Join(columns, ", "), quoteIdentifier("fn_cdc_get_all_changes_"+t.captureInstance))
+	rows, err := e.db.Query(query, []byte(from), []byte(to))
+	if err != nil {
+		return nil, fmt.Errorf("reading the changes of %s: %v", t.captureInstance, err)
+	}
+	defer rows.Close()
+
+	var changes []*cdcRow
+	for rows.Next() {
+		var commitLsn, seqval []byte
+		var operation int
+		scanned := make([]interface{}, len(t.columns))
+		dest := []interface{}{&commitLsn, &seqval, &operation}
+		for i := range scanned {
+			dest = append(dest, &scanned[i])
+		}
+		if err := rows.Scan(dest...); err != nil {
+			return nil, err
+		}
+		values := make([]interface{}, len(t.columns))
+		for i, c := range t.columns {
+			value, err := c.eventValue(scanned[i])
+			if err != nil {
+				return nil, fmt.Errorf("column %s of %s.%s: %v", c.name, t.schema, t.name, err)
+			}
+			values[i] = value
+		}
+		changes = append(changes, &cdcRow{
+			table:     t,
+			commitLsn: lsn(commitLsn),
+			seqval:    lsn(seqval),
+			operation: operation,
+			values:    values,
+		})
+	}
+	return changes, rows.Err()
+}
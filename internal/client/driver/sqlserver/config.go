@@ -0,0 +1,106 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package sqlserver
+
+import (
+	"fmt"
+	"net/url"
+
+	"github.com/actiontech/dtle/internal/config"
+)
+
+const (
+	defaultPort              = 1433
+	defaultChunkSize         = 2000
+	defaultPollInterval      = 1000
+	defaultBatchTransactions = 1000
+)
+
+// ConnectionConfig is the SQL Server database a task connects to
+type ConnectionConfig struct {
+	Host     string
+	Port     int
+	Database string
+	User     string
+	Password string
+}
+
+// DSN returns the URL the SQL Server driver connects with
+func (c *ConnectionConfig) DSN() string {
+	port := c.Port
+	if port == 0 {
+		port = defaultPort
+	}
+	u := &url.URL{
+		Scheme:   "sqlserver",
+		User:     url.UserPassword(c.User, c.Password),
+		Host:     fmt.Sprintf("%s:%d", c.Host, port),
+		RawQuery: url.Values{"database": {c.Database}}.Encode(),
+	}
+	return u.String()
+}
+
+// SQLServerConfig is the config of a SQL Server source task
+type SQLServerConfig struct {
+	ConnectionConfig *ConnectionConfig
+	// ReplicateDoDb are the schemas, such as dbo, and their tables
+	// replicated. A schema without tables is replicated whole.
+	ReplicateDoDb []*config.DataSource
+	NatsAddr      string
+	// ChunkSize is the number of rows of a table sent at a time in the
+	// snapshot
+	ChunkSize int64
+
+	// Lsn is the LSN the changes are read from, in hex as in the CDC
+	// tables, skipping the snapshot
+	Lsn string
+	// Gtid is the last transaction replicated, as reported in the
+	// statistics: "uuid:1-n", n standing for its commit LSN. The changes are
+	// read after it if Lsn is not set. With neither, a snapshot of the tables
+	// is copied, then the changes are read from its LSN.
+	Gtid string
+	// PollInterval is the milliseconds between two reads of the CDC tables,
+	// once the task caught up with them
+	PollInterval int
+	// BatchTransactions is the largest number of transactions read at a
+	// time
+	BatchTransactions int
+}
+
+// SetDefault returns a copy of the config with the defaults set
+func (c *SQLServerConfig) SetDefault() *SQLServerConfig {
+	result := *c
+	if result.ChunkSize <= 0 {
+		result.ChunkSize = defaultChunkSize
+	}
+	if result.PollInterval <= 0 {
+		result.PollInterval = defaultPollInterval
+	}
+	if result.BatchTransactions <= 0 {
+		result.BatchTransactions = defaultBatchTransactions
+	}
+	return &result
+}
+
+// Validate checks the config of a source task
+func (c *SQLServerConfig) Validate() error {
+	if c.ConnectionConfig == nil {
+		return fmt.Errorf("ConnectionConfig is missing")
+	}
+	if c.ConnectionConfig.Database == "" {
+		return fmt.Errorf("ConnectionConfig.Database is missing")
+	}
+	if len(c.ReplicateDoDb) == 0 {
+		return fmt.Errorf("ReplicateDoDb is empty: the schemas of a SQL Server source must be listed")
+	}
+	if c.Lsn != "" {
+		if _, err := parseLsn(c.Lsn); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,15 @@
+//go:build sqlserver
+// +build sqlserver
+
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package sqlserver
+
+import (
+	// The pure Go driver, registered as "sqlserver"
+	_ "github.com/denisenkom/go-mssqldb"
+)
@@ -0,0 +1,310 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package sqlserver
+
+import (
+	gosql "database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/satori/go.uuid"
+
+	"github.com/actiontech/dtle/internal/client/driver/hete"
+	"github.com/actiontech/dtle/internal/client/driver/mysql"
+	log "github.com/actiontech/dtle/internal/logger"
+	"github.com/actiontech/dtle/internal/models"
+)
+
+// driverName is the database/sql driver connecting to SQL Server,
+// registered by the build with the "sqlserver" tag
+const driverName = "sqlserver"
+
+// Extractor reads the tables of a SQL Server database and their changes
+// from the CDC tables, and sends them to the target task as the MySQL
+// extractor does
+type Extractor struct {
+	logger     *log.Entry
+	subject    string
+	maxPayload int
+	cfg        *SQLServerConfig
+	db         *gosql.DB
+	publisher  *hete.Publisher
+	waitCh     chan *models.WaitResult
+
+	// sid stands for the database in the GTIDs of the transactions, whose
+	// GNO is made of the commit LSN
+	sid uuid.UUID
+	// tables are those replicated
+	tables []*table
+	// tableSent tells whether the structure of a table was sent along with
+	// its rows
+	tableSent map[*table]bool
+
+	// lsnLock guards lsn
+	lsnLock sync.Mutex
+	// lsn is the commit LSN of the last transaction sent, or the LSN of the
+	// snapshot
+	lsn lsn
+
+	stage string
+
+	shutdown     bool
+	shutdownCh   chan struct{}
+	shutdownLock sync.Mutex
+}
+
+func NewExtractor(subject, tp string, maxPayload int, cfg *SQLServerConfig, logger *log.Logger) (*Extractor, error) {
+	cfg = cfg.SetDefault()
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	entry := log.NewEntry(logger).WithFields(log.Fields{
+		"job": subject,
+	})
+	e := &Extractor{
+		logger:     entry,
+		subject:    subject,
+		maxPayload: maxPayload,
+		cfg:        cfg,
+		waitCh:     make(chan *models.WaitResult, 1),
+		tableSent:  make(map[*table]bool),
+		shutdownCh: make(chan struct{}),
+	}
+	return e, nil
+}
+
+func (e *Extractor) Run() {
+	defer models.RecoverPanic(e.onPanic)
+	e.logger.Printf("sqlserver.extractor: Extract changes from %s:%d/%s", e.cfg.ConnectionConfig.Host,
+		e.cfg.ConnectionConfig.Port, e.cfg.ConnectionConfig.Database)
+
+	if err := e.initDBConnection(); err != nil {
+		e.onError(mysql.TaskStateDead, err)
+		return
+	}
+	if err := e.initNatsPubClient(); err != nil {
+		e.onError(mysql.TaskStateDead, err)
+		return
+	}
+	if err := e.loadTables(); err != nil {
+		e.onError(mysql.TaskStateDead, err)
+		return
+	}
+
+	switch {
+	case e.cfg.Lsn != "":
+		l, err := parseLsn(e.cfg.Lsn)
+		if err != nil {
+			e.onError(mysql.TaskStateDead, err)
+			return
+		}
+		// The changes are read from Lsn on
+		e.setLsn(l.decrement())
+	case e.cfg.Gtid != "":
+		gno, err := hete.ParseGtid(e.sid, e.cfg.Gtid)
+		if err != nil {
+			e.onError(mysql.TaskStateDead, err)
+			return
+		}
+		e.setLsn(lsnOfGno(gno))
+	default:
+		if err := e.snapshot(); err != nil {
+			e.onError(mysql.TaskStateDead, err)
+			return
+		}
+	}
+
+	if err := e.streamChanges(); err != nil {
+		e.onError(mysql.TaskStateDead, err)
+	}
+}
+
+// openDB connects to a SQL Server database
+func openDB(c *ConnectionConfig) (*gosql.DB, error) {
+	found := false
+	for _, name := range gosql.Drivers() {
+		found = found || name == driverName
+	}
+	if !found {
+		return nil, fmt.Errorf("this build has no SQL Server driver: build dtle with the %q tag", "sqlserver")
+	}
+	return gosql.Open(driverName, c.DSN())
+}
+
+// CheckConnection connects to a SQL Server database and checks that CDC is
+// enabled on it
+func CheckConnection(c *ConnectionConfig) error {
+	db, err := openDB(c)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	var enabled bool
+	if err := db.QueryRow("SELECT is_cdc_enabled FROM sys.databases WHERE name = DB_NAME()").Scan(&enabled); err != nil {
+		return err
+	}
+	if !enabled {
+		return fmt.Errorf("CDC is not enabled on database %s: EXEC sys.sp_cdc_enable_db", c.Database)
+	}
+	return nil
+}
+
+// initDBConnection connects to SQL Server, and reads the GUID of the
+// database the GTIDs of the transactions are made of
+func (e *Extractor) initDBConnection() (err error) {
+	if err := CheckConnection(e.cfg.ConnectionConfig); err != nil {
+		return err
+	}
+	if e.db, err = openDB(e.cfg.ConnectionConfig); err != nil {
+		return err
+	}
+	var guid string
+	if err := e.db.QueryRow("SELECT CONVERT(varchar(36), database_guid) FROM sys.database_recovery_status WHERE database_id = DB_ID()").Scan(&guid); err != nil {
+		return err
+	}
+	e.sid = uuid.NewV5(uuid.NamespaceOID, fmt.Sprintf("sqlserver:%s", strings.ToLower(guid)))
+	e.logger.Printf("sqlserver.extractor: Database %s, transactions sent as %s", guid, e.sid)
+	return nil
+}
+
+func (e *Extractor) initNatsPubClient() (err error) {
+	e.publisher, err = hete.NewPublisher("sqlserver.extractor", e.subject, e.maxPayload, e.cfg.NatsAddr, e.shutdownCh, e.logger)
+	return err
+}
+
+// loadTables reads the tables of ReplicateDoDb, all those of a schema
+// listed without tables
+func (e *Extractor) loadTables() error {
+	for _, ds := range e.cfg.ReplicateDoDb {
+		var names []string
+		for _, t := range ds.Tables {
+			names = append(names, t.TableName)
+		}
+		if len(names) == 0 {
+			rows, err := e.db.Query(`SELECT DISTINCT T.name FROM cdc.change_tables CT
+				JOIN sys.tables T ON T.object_id = CT.source_object_id
+				WHERE SCHEMA_NAME(T.schema_id) = @p1 ORDER BY T.name`, ds.TableSchema)
+			if err != nil {
+				return err
+			}
+			for rows.Next() {
+				var name string
+				if err := rows.Scan(&name); err != nil {
+					rows.Close()
+					return err
+				}
+				names = append(names, name)
+			}
+			rows.Close()
+			if err := rows.Err(); err != nil {
+				return err
+			}
+		}
+		for _, name := range names {
+			t, err := loadTable(e.db, ds.TableSchema, name)
+			if err != nil {
+				return err
+			}
+			e.logger.Printf("sqlserver.extractor: Table %s.%s captured by %s", t.schema, t.name, t.captureInstance)
+			e.tables = append(e.tables, t)
+		}
+	}
+	if len(e.tables) == 0 {
+		return fmt.Errorf("no table of ReplicateDoDb has CDC enabled")
+	}
+	return nil
+}
+
+func (e *Extractor) getLsn() lsn {
+	e.lsnLock.Lock()
+	defer e.lsnLock.Unlock()
+	return e.lsn
+}
+
+func (e *Extractor) setLsn(l lsn) {
+	e.lsnLock.Lock()
+	defer e.lsnLock.Unlock()
+	e.lsn = l
+}
+
+// gtid returns the GTID set of the transactions sent up to a commit LSN
+func (e *Extractor) gtid(l lsn) string {
+	gno, err := l.gno()
+	if err != nil {
+		return ""
+	}
+	return hete.Gtid(e.sid, gno)
+}
+
+func (e *Extractor) Stats() (*models.TaskStatistics, error) {
+	coordinates := &models.CurrentCoordinates{}
+	if l := e.getLsn(); l != nil {
+		// File is the LSN the changes are read after
+		coordinates.File = l.String()
+		coordinates.GtidSet = e.gtid(l)
+	}
+	return e.publisher.Statistics(e.stage, coordinates), nil
+}
+
+// ID returns the config to restart with, as config.DriverCtx is read back
+func (e *Extractor) ID() string {
+	cfg := *e.cfg
+	if l := e.getLsn(); l != nil {
+		cfg.Lsn = ""
+		cfg.Gtid = e.gtid(l)
+	}
+	id := struct {
+		DriverConfig *SQLServerConfig
+	}{&cfg}
+	data, err := json.Marshal(id)
+	if err != nil {
+		e.logger.Errorf("sqlserver.extractor: Failed to marshal ID to JSON: %s", err)
+	}
+	return string(data)
+}
+
+func (e *Extractor) onPanic(err *models.PanicError) {
+	e.onError(mysql.TaskStateRestart, err)
+}
+
+func (e *Extractor) onError(state int, err error) {
+	e.logger.Errorf("sqlserver.extractor. error: %v", err.Error())
+	if e.shutdown {
+		return
+	}
+	e.waitCh <- models.NewWaitResult(state, err)
+	e.Shutdown()
+}
+
+func (e *Extractor) WaitCh() chan *models.WaitResult {
+	return e.waitCh
+}
+
+// Shutdown is used to tear down the extractor
+func (e *Extractor) Shutdown() error {
+	e.shutdownLock.Lock()
+	defer e.shutdownLock.Unlock()
+
+	if e.shutdown {
+		return nil
+	}
+	e.shutdown = true
+	close(e.shutdownCh)
+
+	if e.publisher != nil {
+		e.publisher.Close()
+	}
+	if e.db != nil {
+		if err := e.db.Close(); err != nil {
+			return err
+		}
+	}
+	e.logger.Printf("sqlserver.extractor: Shutting down")
+	return nil
+}
@@ -0,0 +1,91 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package sqlserver
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+// lsn is a log sequence number of SQL Server: the sequence number of the
+// virtual log file, the offset of the log block in it, and the slot of the
+// record in the block
+type lsn []byte
+
+const lsnLength = 10
+
+// The widths of the parts of an LSN in a GNO
+const (
+	gnoBlockBits = 24
+	gnoSlotBits  = 16
+)
+
+// parseLsn parses an LSN in hex, with or without 0x and colons
+func parseLsn(s string) (lsn, error) {
+	s = strings.Replace(strings.TrimPrefix(strings.TrimPrefix(s, "0x"), "0X"), ":", "", -1)
+	b, err := hex.DecodeString(s)
+	if err != nil || len(b) != lsnLength {
+		return nil, fmt.Errorf("invalid LSN %q", s)
+	}
+	return lsn(b), nil
+}
+
+func (l lsn) String() string {
+	return strings.ToUpper(hex.EncodeToString(l))
+}
+
+func (l lsn) compare(other lsn) int {
+	return bytes.Compare(l, other)
+}
+
+// gno returns the GNO of the GTID of the transaction committed at the LSN.
+// Both grow together, and either is found from the other.
+func (l lsn) gno() (int64, error) {
+	vlf := int64(binary.BigEndian.Uint32(l[0:4]))
+	block := int64(binary.BigEndian.Uint32(l[4:8]))
+	slot := int64(binary.BigEndian.Uint16(l[8:10]))
+	if vlf >= 1<<(63-gnoBlockBits-gnoSlotBits) || block >= 1<<gnoBlockBits {
+		return 0, fmt.Errorf("LSN %s out of the range of the GTIDs", l)
+	}
+	return vlf<<(gnoBlockBits+gnoSlotBits) | block<<gnoSlotBits | slot, nil
+}
+
+// lsnOfGno returns the LSN a GNO is made of
+func lsnOfGno(gno int64) lsn {
+	l := make(lsn, lsnLength)
+	binary.BigEndian.PutUint32(l[0:4], uint32(gno>>(gnoBlockBits+gnoSlotBits)))
+	binary.BigEndian.PutUint32(l[4:8], uint32(gno>>gnoSlotBits&(1<<gnoBlockBits-1)))
+	binary.BigEndian.PutUint16(l[8:10], uint16(gno&(1<<gnoSlotBits-1)))
+	return l
+}
+
+// increment returns the next LSN
+func (l lsn) increment() lsn {
+	next := append(lsn{}, l...)
+	for i := len(next) - 1; i >= 0; i-- {
+		next[i]++
+		if next[i] != 0 {
+			break
+		}
+	}
+	return next
+}
+
+// decrement returns the previous LSN
+func (l lsn) decrement() lsn {
+	previous := append(lsn{}, l...)
+	for i := len(previous) - 1; i >= 0; i-- {
+		previous[i]--
+		if previous[i] != 0xff {
+			break
+		}
+	}
+	return previous
+}
@@ -0,0 +1,74 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package sqlserver
+
+import (
+	"testing"
+)
+
+func TestParseLsn(t *testing.T) {
+	tests := []struct {
+		s       string
+		want    string
+		wantErr bool
+	}{
+		{s: "0x0000002A000001F00003", want: "0000002A000001F00003"},
+		{s: "0000002a:000001f0:0003", want: "0000002A000001F00003"},
+		{s: "0000002A000001F000", wantErr: true},
+		{s: "not an lsn", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := parseLsn(tt.s)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseLsn(%q) error = %v, wantErr %v", tt.s, err, tt.wantErr)
+			continue
+		}
+		if err == nil && got.String() != tt.want {
+			t.Errorf("parseLsn(%q) = %v, want %v", tt.s, got, tt.want)
+		}
+	}
+}
+
+func TestLsnGno(t *testing.T) {
+	var previous int64
+	for _, s := range []string{"0000002A000001F00003", "0000002A000001F00004", "0000002A000001F10000", "0000002B000000100001"} {
+		l, err := parseLsn(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		gno, err := l.gno()
+		if err != nil {
+			t.Fatalf("%v.gno() error = %v", l, err)
+		}
+		if gno <= previous {
+			t.Errorf("%v.gno() = %v, not after %v", l, gno, previous)
+		}
+		previous = gno
+		if back := lsnOfGno(gno); back.compare(l) != 0 {
+			t.Errorf("lsnOfGno(%v) = %v, want %v", gno, back, l)
+		}
+	}
+
+	l, _ := parseLsn("00800000000001F00003")
+	if _, err := l.gno(); err == nil {
+		t.Errorf("%v.gno() out of range, got no error", l)
+	}
+}
+
+func TestLsnIncrement(t *testing.T) {
+	l, _ := parseLsn("0000002A000001F0FFFF")
+	next := l.increment()
+	if next.String() != "0000002A000001F10000" {
+		t.Errorf("%v.increment() = %v", l, next)
+	}
+	if previous := next.decrement(); previous.compare(l) != 0 {
+		t.Errorf("%v.decrement() = %v, want %v", next, previous, l)
+	}
+	if l.String() != "0000002A000001F0FFFF" {
+		t.Errorf("increment changed the LSN to %v", l)
+	}
+}
@@ -0,0 +1,343 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package sqlserver
+
+import (
+	gosql "database/sql"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	usql "github.com/actiontech/dtle/internal/client/driver/mysql/sql"
+	"github.com/actiontech/dtle/internal/config"
+	umconf "github.com/actiontech/dtle/internal/config/mysql"
+)
+
+// column is a column of a SQL Server table, and the MySQL type it is created
+// with on the target
+type column struct {
+	name      string
+	dataType  string
+	length    int64
+	precision int64
+	scale     int64
+	nullable  bool
+
+	mysqlType string
+	kind      umconf.ColumnType
+}
+
+// table is a SQL Server table replicated, by its CDC capture instance
+type table struct {
+	schema          string
+	name            string
+	captureInstance string
+	columns         []*column
+	primaryKey      []string
+	// def is the structure of the table as sent along with the rows, for
+	// the Kafka target
+	def *config.Table
+}
+
+// loadTable reads the newest capture instance of a table, and its columns
+// captured
+func loadTable(db *gosql.DB, schema, name string) (*table, error) {
+	t := &table{schema: schema, name: name}
+	query := `SELECT TOP 1 CT.capture_instance FROM cdc.change_tables CT
+		JOIN sys.tables T ON T.object_id = CT.source_object_id
+		WHERE SCHEMA_NAME(T.schema_id) = @p1 AND T.name = @p2 ORDER BY CT.create_date DESC`
+	if err := db.QueryRow(query, schema, name).Scan(&t.captureInstance); err == gosql.ErrNoRows {
+		return nil, fmt.Errorf("CDC is not enabled on table %s.%s: EXEC sys.sp_cdc_enable_table", schema, name)
+	} else if err != nil {
+		return nil, err
+	}
+
+	query = `SELECT C.COLUMN_NAME, C.DATA_TYPE, COALESCE(C.CHARACTER_MAXIMUM_LENGTH, 0),
+			COALESCE(C.NUMERIC_PRECISION, 0), COALESCE(C.NUMERIC_SCALE, C.DATETIME_PRECISION, 0), C.IS_NULLABLE
+		FROM cdc.captured_columns CC
+		JOIN cdc.change_tables CT ON CT.object_id = CC.object_id
+		JOIN INFORMATION_SCHEMA.COLUMNS C ON C.TABLE_SCHEMA = @p1 AND C.TABLE_NAME = @p2 AND C.COLUMN_NAME = CC.column_name
+		WHERE CT.capture_instance = @p3 ORDER BY CC.column_ordinal`
+	rows, err := db.Query(query, schema, name, t.captureInstance)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		c := &column{}
+		var precision, scale int64
+		var nullable string
+		if err := rows.Scan(&c.name, &c.dataType, &c.length, &precision, &scale, &nullable); err != nil {
+			return nil, err
+		}
+		c.precision, c.scale = precision, scale
+		c.nullable = nullable == "YES"
+		c.mysqlType, c.kind = mysqlColumnType(c)
+		t.columns = append(t.columns, c)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(t.columns) == 0 {
+		return nil, fmt.Errorf("table %s.%s has no column captured by %s", schema, name, t.captureInstance)
+	}
+
+	query = `SELECT KU.COLUMN_NAME FROM INFORMATION_SCHEMA.TABLE_CONSTRAINTS TC
+		JOIN INFORMATION_SCHEMA.KEY_COLUMN_USAGE KU ON KU.CONSTRAINT_SCHEMA = TC.CONSTRAINT_SCHEMA
+			AND KU.CONSTRAINT_NAME = TC.CONSTRAINT_NAME
+		WHERE TC.TABLE_SCHEMA = @p1 AND TC.TABLE_NAME = @p2 AND TC.CONSTRAINT_TYPE = 'PRIMARY KEY'
+		ORDER BY KU.ORDINAL_POSITION`
+	pkRows, err := db.Query(query, schema, name)
+	if err != nil {
+		return nil, err
+	}
+	defer pkRows.Close()
+	for pkRows.Next() {
+		var columnName string
+		if err := pkRows.Scan(&columnName); err != nil {
+			return nil, err
+		}
+		t.primaryKey = append(t.primaryKey, columnName)
+	}
+	if err := pkRows.Err(); err != nil {
+		return nil, err
+	}
+
+	t.def = t.tableDef()
+	return t, nil
+}
+
+// mysqlColumnType returns the MySQL type a column is created with
+func mysqlColumnType(c *column) (string, umconf.ColumnType) {
+	switch c.dataType {
+	case "tinyint":
+		// Unsigned in SQL Server
+		return "tinyint unsigned", umconf.TinyintColumnType
+	case "smallint":
+		return "smallint", umconf.SmallintColumnType
+	case "int":
+		return "int", umconf.IntColumnType
+	case "bigint":
+		return "bigint", umconf.BigIntColumnType
+	case "bit":
+		return "tinyint(1)", umconf.TinyintColumnType
+	case "decimal", "numeric":
+		return fmt.Sprintf("decimal(%d,%d)", c.precision, c.scale), umconf.DecimalColumnType
+	case "money":
+		return "decimal(19,4)", umconf.DecimalColumnType
+	case "smallmoney":
+		return "decimal(10,4)", umconf.DecimalColumnType
+	case "float":
+		if c.precision <= 24 {
+			return "float", umconf.FloatColumnType
+		}
+		return "double", umconf.DoubleColumnType
+	case "real":
+		return "float", umconf.FloatColumnType
+	case "char", "nchar":
+		if c.length > 255 {
+			return fmt.Sprintf("varchar(%d)", c.length), umconf.VarcharColumnType
+		}
+		return fmt.Sprintf("char(%d)", c.length), umconf.CharColumnType
+	case "varchar", "nvarchar":
+		// -1 is max
+		if c.length < 0 || c.length > 16383 {
+			return "longtext", umconf.TextColumnType
+		}
+		return fmt.Sprintf("varchar(%d)", c.length), umconf.VarcharColumnType
+	case "binary":
+		return fmt.Sprintf("binary(%d)", c.length), umconf.BinaryColumnType
+	case "varbinary":
+		if c.length < 0 || c.length > 65535 {
+			return "longblob", umconf.BlobColumnType
+		}
+		return fmt.Sprintf("varbinary(%d)", c.length), umconf.VarbinaryColumnType
+	case "image":
+		return "longblob", umconf.BlobColumnType
+	case "timestamp", "rowversion":
+		return "binary(8)", umconf.BinaryColumnType
+	case "uniqueidentifier":
+		return "char(36)", umconf.CharColumnType
+	case "date":
+		return "date", umconf.DateColumnType
+	case "time":
+		return fmt.Sprintf("time(%d)", fsp(c.scale)), umconf.TimeColumnType
+	case "smalldatetime":
+		return "datetime", umconf.DateTimeColumnType
+	case "datetime":
+		return "datetime(3)", umconf.DateTimeColumnType
+	case "datetime2":
+		if fsp(c.scale) == 0 {
+			return "datetime", umconf.DateTimeColumnType
+		}
+		return fmt.Sprintf("datetime(%d)", fsp(c.scale)), umconf.DateTimeColumnType
+	case "datetimeoffset":
+		// The offset is kept in the text of the value
+		return "varchar(64)", umconf.VarcharColumnType
+	default:
+		// text, ntext, xml, sql_variant, hierarchyid, geography and geometry
+		return "longtext", umconf.TextColumnType
+	}
+}
+
+// fsp returns the fractional seconds of MySQL of a scale of SQL Server,
+// which goes up to 7
+func fsp(scale int64) int64 {
+	if scale > 6 {
+		return 6
+	}
+	return scale
+}
+
+// timeLayout returns the layout a time value of a column is printed with
+func (c *column) timeLayout() string {
+	switch c.kind {
+	case umconf.DateColumnType:
+		return "2006-01-02"
+	case umconf.TimeColumnType:
+		return "15:04:05.999999"
+	case umconf.VarcharColumnType:
+		return "2006-01-02 15:04:05.999999 -07:00"
+	default:
+		return "2006-01-02 15:04:05.999999"
+	}
+}
+
+// eventValue converts a value read from SQL Server to that of a binlog event
+// of the MySQL type of the column
+func (c *column) eventValue(v interface{}) (interface{}, error) {
+	switch v := v.(type) {
+	case nil:
+		return nil, nil
+	case time.Time:
+		return v.Format(c.timeLayout()), nil
+	case bool:
+		if v {
+			return int64(1), nil
+		}
+		return int64(0), nil
+	case int64:
+		return v, nil
+	case float64:
+		if c.kind == umconf.FloatColumnType {
+			return float32(v), nil
+		}
+		return v, nil
+	case float32:
+		if c.kind == umconf.DoubleColumnType {
+			return float64(v), nil
+		}
+		return v, nil
+	case []byte:
+		switch c.kind {
+		case umconf.BinaryColumnType:
+			// As binary(n) is read from the binlog
+			return string(v), nil
+		case umconf.VarbinaryColumnType, umconf.BlobColumnType:
+			return v, nil
+		}
+		if c.dataType == "uniqueidentifier" {
+			return uniqueIdentifier(v)
+		}
+		// Decimals and money
+		return string(v), nil
+	case string:
+		return v, nil
+	default:
+		switch c.kind {
+		case umconf.TinyintColumnType, umconf.SmallintColumnType, umconf.IntColumnType, umconf.BigIntColumnType:
+			return strconv.ParseInt(fmt.Sprint(v), 10, 64)
+		}
+		return fmt.Sprint(v), nil
+	}
+}
+
+// dumpValue converts a value read from SQL Server to the text the snapshot
+// sends it as
+func (c *column) dumpValue(v interface{}) ([]byte, error) {
+	value, err := c.eventValue(v)
+	switch value := value.(type) {
+	case nil:
+		return nil, err
+	case []byte:
+		return value, err
+	case string:
+		return []byte(value), err
+	case int64:
+		return []byte(strconv.FormatInt(value, 10)), err
+	case float32:
+		return []byte(strconv.FormatFloat(float64(value), 'g', -1, 32)), err
+	case float64:
+		return []byte(strconv.FormatFloat(value, 'g', -1, 64)), err
+	default:
+		return []byte(fmt.Sprint(value)), err
+	}
+}
+
+// uniqueIdentifier prints a uniqueidentifier, whose first three groups are
+// little endian
+func uniqueIdentifier(b []byte) (string, error) {
+	if len(b) != 16 {
+		return "", fmt.Errorf("invalid uniqueidentifier %x", b)
+	}
+	return fmt.Sprintf("%X-%X-%X-%X-%X",
+		[]byte{b[3], b[2], b[1], b[0]}, []byte{b[5], b[4]}, []byte{b[7], b[6]}, b[8:10], b[10:]), nil
+}
+
+// createTableSQL returns the CREATE TABLE of the table on the target
+func (t *table) createTableSQL() string {
+	var definitions []string
+	for _, c := range t.columns {
+		definition := fmt.Sprintf("  %s %s", usql.EscapeName(c.name), c.mysqlType)
+		if !c.nullable {
+			definition += " NOT NULL"
+		}
+		definitions = append(definitions, definition)
+	}
+	if len(t.primaryKey) > 0 {
+		var names []string
+		for _, name := range t.primaryKey {
+			names = append(names, usql.EscapeName(name))
+		}
+		definitions = append(definitions, fmt.Sprintf("  PRIMARY KEY (%s)", strings.Join(names, ",")))
+	}
+	return fmt.Sprintf("CREATE TABLE %s (\n%s\n)", usql.EscapeName(t.name), strings.Join(definitions, ",\n"))
+}
+
+// tableDef returns the structure of the table as read from a MySQL source
+func (t *table) tableDef() *config.Table {
+	def := config.NewTable(t.schema, t.name)
+	primaryKey := make(map[string]bool)
+	for _, name := range t.primaryKey {
+		primaryKey[name] = true
+	}
+	var columns []umconf.Column
+	for _, c := range t.columns {
+		col := umconf.Column{
+			Name:       c.name,
+			Type:       c.kind,
+			ColumnType: c.mysqlType,
+			Nullable:   c.nullable,
+			IsUnsigned: strings.HasSuffix(c.mysqlType, " unsigned"),
+		}
+		if primaryKey[c.name] {
+			col.Key = "PRI"
+		}
+		if c.kind == umconf.DecimalColumnType {
+			fmt.Sscanf(c.mysqlType, "decimal(%d,%d)", &col.Precision, &col.Scale)
+		}
+		columns = append(columns, col)
+	}
+	def.OriginalTableColumns = umconf.NewColumnList(columns)
+	return def
+}
+
+// quoteIdentifier quotes a SQL Server identifier
+func quoteIdentifier(name string) string {
+	return "[" + strings.Replace(name, "]", "]]", -1) + "]"
+}
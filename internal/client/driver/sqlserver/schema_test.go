@@ -0,0 +1,79 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package sqlserver
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	umconf "github.com/actiontech/dtle/internal/config/mysql"
+)
+
+func TestMysqlColumnType(t *testing.T) {
+	tests := []struct {
+		column   *column
+		wantType string
+		wantKind umconf.ColumnType
+	}{
+		{&column{dataType: "tinyint"}, "tinyint unsigned", umconf.TinyintColumnType},
+		{&column{dataType: "bit"}, "tinyint(1)", umconf.TinyintColumnType},
+		{&column{dataType: "decimal", precision: 12, scale: 2}, "decimal(12,2)", umconf.DecimalColumnType},
+		{&column{dataType: "money"}, "decimal(19,4)", umconf.DecimalColumnType},
+		{&column{dataType: "float", precision: 53}, "double", umconf.DoubleColumnType},
+		{&column{dataType: "nvarchar", length: 50}, "varchar(50)", umconf.VarcharColumnType},
+		{&column{dataType: "nvarchar", length: -1}, "longtext", umconf.TextColumnType},
+		{&column{dataType: "varbinary", length: -1}, "longblob", umconf.BlobColumnType},
+		{&column{dataType: "datetime2", scale: 7}, "datetime(6)", umconf.DateTimeColumnType},
+		{&column{dataType: "datetime2", scale: 0}, "datetime", umconf.DateTimeColumnType},
+		{&column{dataType: "uniqueidentifier"}, "char(36)", umconf.CharColumnType},
+		{&column{dataType: "xml"}, "longtext", umconf.TextColumnType},
+	}
+	for _, tt := range tests {
+		gotType, gotKind := mysqlColumnType(tt.column)
+		if gotType != tt.wantType || gotKind != tt.wantKind {
+			t.Errorf("mysqlColumnType(%v) = %v, %v, want %v, %v", tt.column.dataType, gotType, gotKind, tt.wantType, tt.wantKind)
+		}
+	}
+}
+
+func TestEventValue(t *testing.T) {
+	newColumn := func(dataType string) *column {
+		c := &column{dataType: dataType, length: 16, precision: 10, scale: 2}
+		c.mysqlType, c.kind = mysqlColumnType(c)
+		return c
+	}
+	at := time.Date(2018, 1, 2, 3, 4, 5, 600000000, time.FixedZone("", 8*3600))
+	tests := []struct {
+		column *column
+		value  interface{}
+		want   interface{}
+	}{
+		{newColumn("int"), int64(7), int64(7)},
+		{newColumn("bit"), true, int64(1)},
+		{newColumn("decimal"), []byte("12.50"), "12.50"},
+		{newColumn("real"), float64(1.5), float32(1.5)},
+		{newColumn("date"), at, "2018-01-02"},
+		{newColumn("datetime2"), at, "2018-01-02 03:04:05.6"},
+		{newColumn("datetimeoffset"), at, "2018-01-02 03:04:05.6 +08:00"},
+		{newColumn("uniqueidentifier"), []byte{0x33, 0x22, 0x11, 0x00, 0x55, 0x44, 0x77, 0x66, 0x88, 0x99, 0xaa, 0xbb, 0xcc, 0xdd, 0xee, 0xff},
+			"00112233-4455-6677-8899-AABBCCDDEEFF"},
+		{newColumn("varbinary"), []byte{1, 2}, []byte{1, 2}},
+		{newColumn("binary"), []byte{1, 2}, "\x01\x02"},
+		{newColumn("nvarchar"), nil, nil},
+	}
+	for _, tt := range tests {
+		got, err := tt.column.eventValue(tt.value)
+		if err != nil {
+			t.Errorf("eventValue(%v) of %v error = %v", tt.value, tt.column.dataType, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("eventValue(%v) of %v = %#v, want %#v", tt.value, tt.column.dataType, got, tt.want)
+		}
+	}
+}
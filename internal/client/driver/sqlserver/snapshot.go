@@ -0,0 +1,111 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package sqlserver
+
+import (
+	"context"
+	gosql "database/sql"
+	"fmt"
+	"strings"
+
+	"github.com/actiontech/dtle/internal/models"
+)
+
+// snapshot copies the tables in a transaction of snapshot isolation, then
+// sets the changes to be read after the largest LSN of the CDC tables at
+// its start. The changes committed before the snapshot but not in the CDC
+// tables yet are applied again over it.
+func (e *Extractor) snapshot() error {
+	e.stage = models.StageSendingData
+	ctx := context.Background()
+	tx, err := e.db.BeginTx(ctx, &gosql.TxOptions{Isolation: gosql.LevelSnapshot})
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var maxLsn []byte
+	if err := tx.QueryRowContext(ctx, "SELECT sys.fn_cdc_get_max_lsn()").Scan(&maxLsn); err != nil {
+		return fmt.Errorf("%v: the snapshot needs ALTER DATABASE SET ALLOW_SNAPSHOT_ISOLATION ON", err)
+	}
+	if len(maxLsn) != lsnLength {
+		return fmt.Errorf("the CDC capture job has not run yet")
+	}
+	snapshotLsn := lsn(maxLsn)
+	if _, err := snapshotLsn.gno(); err != nil {
+		return err
+	}
+	e.logger.Printf("sqlserver.extractor: Step 1: copying the tables as of LSN %s", snapshotLsn)
+
+	for _, t := range e.tables {
+		var count int64
+		query := "SELECT SUM(row_count) FROM sys.dm_db_partition_stats WHERE object_id = OBJECT_ID(@p1) AND index_id < 2"
+		if err := tx.QueryRowContext(ctx, query, fmt.Sprintf("%s.%s", quoteIdentifier(t.schema),
+			quoteIdentifier(t.name))).Scan(&count); err == nil {
+			e.publisher.AddRowsEstimate(count)
+		}
+	}
+
+	for i, t := range e.tables {
+		if err := e.publisher.PublishCreateTable(t.schema, t.createTableSQL()); err != nil {
+			return err
+		}
+		e.tableSent[t] = true
+		e.logger.Printf("sqlserver.extractor: Step 1: - copying table %s.%s (%d of %d tables)", t.schema, t.name, i+1, len(e.tables))
+		if err := e.dumpTable(ctx, tx, t); err != nil {
+			return err
+		}
+	}
+
+	if err := e.publisher.PublishDumpComplete(e.gtid(snapshotLsn)); err != nil {
+		return err
+	}
+	e.setLsn(snapshotLsn)
+	e.logger.Printf("sqlserver.extractor: Step 2: the changes are read after LSN %s", snapshotLsn)
+	return nil
+}
+
+// dumpTable sends the rows of a table, ChunkSize at a time
+func (e *Extractor) dumpTable(ctx context.Context, tx *gosql.Tx, t *table) error {
+	var names, quoted []string
+	for _, c := range t.columns {
+		names = append(names, c.name)
+		quoted = append(quoted, quoteIdentifier(c.name))
+	}
+	query := fmt.Sprintf("SELECT %s FROM %s.%s", strings.Join(quoted, ", "),
+		quoteIdentifier(t.schema), quoteIdentifier(t.name))
+	rows, err := tx.QueryContext(ctx, query)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	dumper := e.publisher.NewTableDumper(t.schema, t.name, names, t.def, e.cfg.ChunkSize)
+	for rows.Next() {
+		scanned := make([]interface{}, len(t.columns))
+		dest := make([]interface{}, len(scanned))
+		for i := range scanned {
+			dest[i] = &scanned[i]
+		}
+		if err := rows.Scan(dest...); err != nil {
+			return err
+		}
+		row := make([][]byte, len(scanned))
+		for i, c := range t.columns {
+			if row[i], err = c.dumpValue(scanned[i]); err != nil {
+				return fmt.Errorf("column %s of %s.%s: %v", c.name, t.schema, t.name, err)
+			}
+		}
+		if err := dumper.Add(row); err != nil {
+			return err
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	return dumper.Flush()
+}
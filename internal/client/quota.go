@@ -0,0 +1,73 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package client
+
+import (
+	"time"
+
+	"github.com/actiontech/dtle/internal/models"
+)
+
+const (
+	// quotaSyncIntv is how often the client refreshes the share of the
+	// namespace quotas of its tasks
+	quotaSyncIntv = 10 * time.Second
+)
+
+// watchQuotas is a long lived goroutine applying the bandwidth quota of the
+// namespaces to the tasks running on this client. The servers split the
+// quota of a namespace between its running source tasks, and each task
+// limits itself to its share, so one namespace can not starve the others
+// on shared network links.
+func (c *Client) watchQuotas() {
+	ticker := time.NewTicker(quotaSyncIntv)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			c.syncQuotas()
+		case <-c.shutdownCh:
+			return
+		}
+	}
+}
+
+// syncQuotas fetches the allowance of each namespace with source tasks on
+// this client and applies it to the tasks
+func (c *Client) syncQuotas() {
+	workers := make(map[string][]*Worker)
+	for _, ar := range c.getAllocRunners() {
+		alloc := ar.Alloc()
+		if alloc.Job == nil || alloc.Task != models.TaskTypeSrc || alloc.TerminalStatus() {
+			continue
+		}
+		namespace := alloc.Job.Namespace
+		if namespace == "" {
+			namespace = models.DefaultNamespace
+		}
+		workers[namespace] = append(workers[namespace], ar.getWorkers()...)
+	}
+
+	for namespace, ws := range workers {
+		req := models.NamespaceAllowanceRequest{
+			Namespace: namespace,
+			QueryOptions: models.QueryOptions{
+				Region:     c.Region(),
+				AllowStale: true,
+			},
+		}
+		var resp models.NamespaceAllowanceResponse
+		if err := c.RPC("Namespace.Allowance", &req, &resp); err != nil {
+			c.logger.Warnf("agent: Failed to fetch the quota of namespace %q: %v", namespace, err)
+			continue
+		}
+		for _, w := range ws {
+			w.SetBandwidthLimit(resp.TaskBandwidth)
+		}
+	}
+}
@@ -18,16 +18,21 @@ const (
 	// jitter is the percent of jitter added to restart delays.
 	jitter = 0.25
 
+	ReasonNoRestartsAllowed   = "Policy allows no restarts"
 	ReasonUnrecoverableErrror = "Error was unrecoverable"
 	ReasonWithinPolicy        = "Restart within policy"
 	ReasonDelay               = "Exceeded allowed attempts, applying a delay"
 )
 
-func newRestartTracker() *RestartTracker {
+func newRestartTracker(policy *models.RestartPolicy) *RestartTracker {
+	if policy == nil {
+		policy = models.DefaultRestartPolicy()
+	}
 	onSuccess := true
 	return &RestartTracker{
 		startTime: time.Now(),
 		onSuccess: onSuccess,
+		policy:    policy,
 		rand:      rand.New(rand.NewSource(time.Now().Unix())),
 	}
 }
@@ -40,6 +45,7 @@ type RestartTracker struct {
 	onSuccess        bool      // Whether to restart on successful exit code.
 	startTime        time.Time // When the interval began
 	reason           string    // The reason for the last store
+	policy           *models.RestartPolicy
 	rand             *rand.Rand
 	lock             sync.Mutex
 }
@@ -116,7 +122,7 @@ func (r *RestartTracker) GetState() (string, time.Duration) {
 	r.count++
 
 	// Check if we have entered a new interval.
-	end := r.startTime.Add(1 * time.Minute)
+	end := r.startTime.Add(r.getPolicy().Interval)
 	now := time.Now()
 	if now.After(end) {
 		r.count = 0
@@ -143,13 +149,7 @@ func (r *RestartTracker) handleStartError() (string, time.Duration) {
 		return models.TaskNotRestarting, 0
 	}
 
-	if r.count > 5 {
-		r.reason = ReasonDelay
-		return models.TaskRestarting, r.getDelay()
-	}
-
-	r.reason = ReasonWithinPolicy
-	return models.TaskRestarting, r.jitter()
+	return r.handleAttempt()
 }
 
 // handleWaitResult returns the new store and potential wait duration for
@@ -162,7 +162,23 @@ func (r *RestartTracker) handleWaitResult() (string, time.Duration) {
 		return models.TaskTerminated, 0
 	}
 
-	if r.count > 5 {
+	return r.handleAttempt()
+}
+
+// handleAttempt returns the new store and wait duration once the current
+// attempt has been counted, according to the restart policy.
+func (r *RestartTracker) handleAttempt() (string, time.Duration) {
+	policy := r.getPolicy()
+	if policy.Attempts == 0 {
+		r.reason = ReasonNoRestartsAllowed
+		return models.TaskNotRestarting, 0
+	}
+
+	if r.count > policy.Attempts {
+		if policy.Mode == models.RestartPolicyModeFail {
+			r.reason = `Exceeded allowed attempts in interval and mode is "fail"`
+			return models.TaskNotRestarting, 0
+		}
 		r.reason = ReasonDelay
 		return models.TaskRestarting, r.getDelay()
 	}
@@ -171,17 +187,25 @@ func (r *RestartTracker) handleWaitResult() (string, time.Duration) {
 	return models.TaskRestarting, r.jitter()
 }
 
+// getPolicy returns the restart policy, falling back to the default one.
+func (r *RestartTracker) getPolicy() *models.RestartPolicy {
+	if r.policy == nil {
+		r.policy = models.DefaultRestartPolicy()
+	}
+	return r.policy
+}
+
 // getDelay returns the delay time to enter the next interval.
 func (r *RestartTracker) getDelay() time.Duration {
-	end := r.startTime.Add(1 * time.Minute)
+	end := r.startTime.Add(r.getPolicy().Interval)
 	now := time.Now()
 	return end.Sub(now)
 }
 
-// jitter returns the delay time plus a jitter.
+// jitter returns the backoff delay of the current attempt plus a jitter.
 func (r *RestartTracker) jitter() time.Duration {
 	// Get the delay and ensure it is valid.
-	d := 15 * time.Second.Nanoseconds()
+	d := r.getPolicy().Backoff(r.count).Nanoseconds()
 	if d == 0 {
 		d = 1
 	}
@@ -17,14 +17,15 @@ import (
 
 func Test_newRestartTracker(t *testing.T) {
 	tests := []struct {
-		name string
-		want *RestartTracker
+		name   string
+		policy *models.RestartPolicy
+		want   *RestartTracker
 	}{
 		// TODO: Add test cases.
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			if got := newRestartTracker(); !reflect.DeepEqual(got, tt.want) {
+			if got := newRestartTracker(tt.policy); !reflect.DeepEqual(got, tt.want) {
 				t.Errorf("newRestartTracker() = %v, want %v", got, tt.want)
 			}
 		})
@@ -404,3 +405,46 @@ func TestRestartTracker_jitter(t *testing.T) {
 		})
 	}
 }
+
+func TestRestartTracker_handleAttempt(t *testing.T) {
+	tests := []struct {
+		name      string
+		policy    *models.RestartPolicy
+		count     int
+		wantState string
+	}{
+		{
+			name:      "within policy",
+			policy:    &models.RestartPolicy{Attempts: 2, Interval: time.Minute, Delay: time.Second, Mode: models.RestartPolicyModeDelay},
+			count:     1,
+			wantState: models.TaskRestarting,
+		},
+		{
+			name:      "exceeded in delay mode",
+			policy:    &models.RestartPolicy{Attempts: 2, Interval: time.Minute, Delay: time.Second, Mode: models.RestartPolicyModeDelay},
+			count:     3,
+			wantState: models.TaskRestarting,
+		},
+		{
+			name:      "exceeded in fail mode",
+			policy:    &models.RestartPolicy{Attempts: 2, Interval: time.Minute, Delay: time.Second, Mode: models.RestartPolicyModeFail},
+			count:     3,
+			wantState: models.TaskNotRestarting,
+		},
+		{
+			name:      "no restarts allowed",
+			policy:    &models.RestartPolicy{Attempts: 0, Mode: models.RestartPolicyModeDelay},
+			count:     1,
+			wantState: models.TaskNotRestarting,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := newRestartTracker(tt.policy)
+			r.count = tt.count
+			if got, _ := r.handleAttempt(); got != tt.wantState {
+				t.Errorf("RestartTracker.handleAttempt() = %v, want %v", got, tt.wantState)
+			}
+		})
+	}
+}
@@ -54,6 +54,18 @@ type Worker struct {
 	taskStats     *models.TaskStatistics
 	taskStatsLock sync.RWMutex
 
+	// phase is the last replication phase reported to the servers
+	phase string
+	// advisedTables are the "schema.table" an index was advised for
+	advisedTables map[string]bool
+	// blocked is set while the task waits for its unreachable target
+	blocked bool
+	// lagging is set while the task is further behind its source than the
+	// alert policy of its job allows
+	lagging bool
+	// reconciledAt is the time of the last row count reconciliation reported
+	reconciledAt int64
+
 	task *models.Task
 
 	handle     driver.DriverHandle
@@ -79,6 +91,11 @@ type Worker struct {
 	destroyEvent *models.TaskEvent
 	workUpdates  chan *models.TaskUpdate
 
+	// checkpointed is set once the task is stopped by the shutdown of the
+	// agent, which leaves its state as is on the servers
+	checkpointed     bool
+	checkpointedLock sync.Mutex
+
 	// waitCh closing marks the run loop as having exited
 	waitCh chan struct{}
 
@@ -112,7 +129,7 @@ func NewWorker(logger *log.Logger, config *config.ClientConfig,
 		return nil
 	}
 
-	restartTracker := newRestartTracker()
+	restartTracker := newRestartTracker(alloc.Job.Restart)
 
 	tc := &Worker{
 		config:         config,
@@ -172,9 +189,10 @@ func (r *Worker) SaveState() error {
 		if id.DriverConfig.Gtid != "" {
 			if r.task.Type == models.TaskTypeDest {
 				r.workUpdates <- &models.TaskUpdate{
-					JobID:    r.alloc.JobID,
-					Gtid:     id.DriverConfig.Gtid,
-					NatsAddr: id.DriverConfig.NatsAddr,
+					JobID:         r.alloc.JobID,
+					Gtid:          id.DriverConfig.Gtid,
+					NatsAddr:      id.DriverConfig.NatsAddr,
+					SchemaHistory: id.DriverConfig.SchemaHistory,
 				}
 			}
 		} else {
@@ -188,6 +206,9 @@ func (r *Worker) SaveState() error {
 		r.logger.Debugf("Worker.SaveState: after lock: %p", r.task)
 		r.task.Config["Gtid"] = id.DriverConfig.Gtid
 		r.task.Config["NatsAddr"] = id.DriverConfig.NatsAddr
+		if len(id.DriverConfig.SchemaHistory) > 0 {
+			r.task.Config["SchemaHistory"] = id.DriverConfig.SchemaHistory
+		}
 		r.task.ConfigLock.Unlock()
 		r.logger.Debugf("Worker.SaveState: after unlock: %p", r.task)
 	}
@@ -230,6 +251,8 @@ func (r *Worker) createDriver() (driver.Driver, error) {
 // Run is a long running routine used to manage the task
 func (r *Worker) Run() {
 	defer close(r.waitCh)
+	// A panic of the worker fails its task, rather than the agent
+	defer models.RecoverPanic(r.onPanic)
 	r.logger.Debugf("agent: Starting task context for '%s' (alloc '%s')",
 		r.task.Type, r.alloc.ID)
 
@@ -331,6 +354,16 @@ func (r *Worker) run() {
 					panic("nil wait")
 				}
 
+				// The agent is shutting down: the task is neither dead nor
+				// restarted, it resumes when the agent restarts
+				r.checkpointedLock.Lock()
+				checkpointed := r.checkpointed
+				r.checkpointedLock.Unlock()
+				if checkpointed {
+					close(stopCollection)
+					return
+				}
+
 				r.runningLock.Lock()
 				r.running = false
 				r.runningLock.Unlock()
@@ -344,6 +377,9 @@ func (r *Worker) run() {
 				r.setState("", r.waitErrorToEvent(waitRes))
 				if !waitRes.Successful() {
 					r.logger.Errorf("agent: Task %q for alloc %q failed: %v", r.task.Type, r.alloc.ID, waitRes)
+					if crashed, ok := waitRes.Err.(*models.PanicError); ok {
+						r.logger.Errorf("agent: Task %q for alloc %q panicked:\n%s", r.task.Type, r.alloc.ID, crashed.Stack)
+					}
 				} else {
 					r.logger.Printf("agent: Task %q for alloc %q completed successfully", r.task.Type, r.alloc.ID)
 				}
@@ -516,8 +552,31 @@ func (r *Worker) killTask(killingEvent *models.TaskEvent) {
 	r.setState("", models.NewTaskEvent(models.TaskKilled).SetKillError(err))
 }
 
-// startTask creates the driver, task dir, and starts the task.
-func (r *Worker) startTask() error {
+// onPanic stops the task of the worker that panicked and marks it failed
+func (r *Worker) onPanic(err *models.PanicError) {
+	r.logger.Errorf("agent: Task %q for alloc %q panicked: %v\n%s", r.task.Type, r.alloc.ID, err, err.Stack)
+	r.handleLock.Lock()
+	handle := r.handle
+	r.handleLock.Unlock()
+	if handle != nil {
+		if err := handle.Shutdown(); err != nil {
+			r.logger.Warnf("agent: Failed to stop task %q for alloc %q: %v", r.task.Type, r.alloc.ID, err)
+		}
+	}
+	r.setState(models.TaskStateDead, models.NewTaskEvent(models.TaskTerminated).
+		SetExitCode(1).SetExitMessage(err).SetFailsTask())
+}
+
+// startTask creates the driver, task dir, and starts the task. A panic of
+// the driver is returned as a recoverable error, for the restart policy of
+// the job to apply.
+func (r *Worker) startTask() (err error) {
+	defer models.RecoverPanic(func(panicErr *models.PanicError) {
+		r.logger.Errorf("agent: Task %q for alloc %q panicked starting: %v\n%s",
+			r.task.Type, r.alloc.ID, panicErr, panicErr.Stack)
+		err = panicErr
+	})
+
 	// Create a driver
 	drv, err := r.createDriver()
 	if err != nil {
@@ -581,6 +640,11 @@ func (r *Worker) collectResourceUsageStats(stopCollection <-chan struct{}) {
 			r.taskStatsLock.Unlock()
 			if ru != nil {
 				r.emitStats(ru)
+				r.updatePhase(ru.Stage)
+				r.adviseIndexes(ru.IndexAdvisories)
+				r.updateBlocked(ru.Stage)
+				r.updateLagging(ru.Heartbeat)
+				r.reportReconciliation(ru.Reconciliation)
 			}
 		case <-stopCollection:
 			return
@@ -588,6 +652,118 @@ func (r *Worker) collectResourceUsageStats(stopCollection <-chan struct{}) {
 	}
 }
 
+// updatePhase emits task events when the driver stage moves the task into a
+// new replication phase, so the transition shows up in the job timeline.
+func (r *Worker) updatePhase(stage string) {
+	phase := models.StagePhase(stage)
+	if phase == "" || phase == r.phase {
+		return
+	}
+
+	switch phase {
+	case models.JobEventSnapshotStarted:
+		r.setState(models.TaskStateRunning,
+			models.NewTaskEvent(models.TaskSnapshotStarted).SetDriverMessage(stage))
+	case models.JobEventStreaming:
+		if r.phase == models.JobEventSnapshotStarted {
+			r.setState(models.TaskStateRunning,
+				models.NewTaskEvent(models.TaskSnapshotFinished).SetDriverMessage(stage))
+		}
+		r.setState(models.TaskStateRunning,
+			models.NewTaskEvent(models.TaskStreaming).SetDriverMessage(stage))
+	}
+	r.phase = phase
+}
+
+// adviseIndexes emits a task event for each table newly advised an index,
+// so that the advisory shows up as a warning in the job timeline.
+func (r *Worker) adviseIndexes(advisories []*models.IndexAdvisory) {
+	for _, advisory := range advisories {
+		table := fmt.Sprintf("%s.%s", advisory.TableSchema, advisory.TableName)
+		if r.advisedTables[table] {
+			continue
+		}
+		if r.advisedTables == nil {
+			r.advisedTables = make(map[string]bool)
+		}
+		r.advisedTables[table] = true
+		r.logger.Warnf("agent: Task %v: %v", r.task.Type, advisory.Message)
+		r.setState(models.TaskStateRunning,
+			models.NewTaskEvent(models.TaskIndexAdvisory).SetDriverMessage(advisory.Message))
+	}
+}
+
+// updateBlocked emits a task event when the driver stage tells the task got
+// blocked on its unreachable target, and when it is no longer.
+func (r *Worker) updateBlocked(stage string) {
+	blocked := stage == models.StageBlockedOnTarget
+	if blocked == r.blocked {
+		return
+	}
+	r.blocked = blocked
+	if blocked {
+		r.setState(models.TaskStateRunning,
+			models.NewTaskEvent(models.TaskBlocked).SetDriverMessage(stage))
+	} else {
+		r.setState(models.TaskStateRunning,
+			models.NewTaskEvent(models.TaskUnblocked).SetDriverMessage(stage))
+	}
+}
+
+// updateLagging emits a task event when the target task gets further behind
+// its source than the MaxSecondsBehind of the alert policy of its job, as
+// measured by the last heartbeat, and when it catches up.
+func (r *Worker) updateLagging(heartbeat *models.HeartbeatStat) {
+	if r.task.Type != models.TaskTypeDest || heartbeat == nil {
+		return
+	}
+	alert := r.alloc.Job.Alert
+	if alert == nil || alert.MaxSecondsBehind <= 0 {
+		return
+	}
+	behind := time.Duration(heartbeat.Age+heartbeat.Lag) * time.Millisecond
+	lagging := behind > time.Duration(alert.MaxSecondsBehind)*time.Second
+	if lagging == r.lagging {
+		return
+	}
+	r.lagging = lagging
+	message := fmt.Sprintf("%v behind the source, alerting past %ds", behind-behind%time.Second, alert.MaxSecondsBehind)
+	if lagging {
+		r.setState(models.TaskStateRunning,
+			models.NewTaskEvent(models.TaskLagging).SetDriverMessage(message))
+	} else {
+		r.setState(models.TaskStateRunning,
+			models.NewTaskEvent(models.TaskCaughtUp).SetDriverMessage(message))
+	}
+}
+
+// reportReconciliation emits a task event once the row counts of the
+// snapshot are reconciled, as a warning if some tables differ.
+func (r *Worker) reportReconciliation(reconciliation *models.RowCountReconciliation) {
+	if reconciliation == nil || reconciliation.Time == r.reconciledAt {
+		return
+	}
+	r.reconciledAt = reconciliation.Time
+	if reconciliation.Mismatches > 0 {
+		r.logger.Warnf("agent: Task %v: %v", r.task.Type, reconciliation.Message)
+		r.setState(models.TaskStateRunning,
+			models.NewTaskEvent(models.TaskRowCountMismatch).SetDriverMessage(reconciliation.Message))
+	} else {
+		r.setState(models.TaskStateRunning,
+			models.NewTaskEvent(models.TaskRowCountsReconciled).SetDriverMessage(reconciliation.Message))
+	}
+}
+
+// SetBandwidthLimit limits the bytes the task sends per second, if its
+// driver supports it. A limit of 0 removes the limit.
+func (r *Worker) SetBandwidthLimit(bytesPerSecond int64) {
+	r.handleLock.Lock()
+	defer r.handleLock.Unlock()
+	if limiter, ok := r.handle.(driver.BandwidthLimiter); ok {
+		limiter.SetBandwidthLimit(bytesPerSecond)
+	}
+}
+
 // LatestResourceUsage returns the last resource utilization datapoint collected
 func (r *Worker) LatestTaskStats() *models.TaskStatistics {
 	r.taskStatsLock.RLock()
@@ -628,6 +804,33 @@ func (r *Worker) handleDestroy() (destroyed bool, err error) {
 	return
 }
 
+// Checkpoint stops the task for the shutdown of the agent and saves the
+// position it stopped at. The task is not marked dead, and resumes from the
+// position when the agent restarts.
+func (r *Worker) Checkpoint() error {
+	r.handleLock.Lock()
+	handle := r.handle
+	r.handleLock.Unlock()
+	if handle == nil {
+		return nil
+	}
+
+	r.checkpointedLock.Lock()
+	r.checkpointed = true
+	r.checkpointedLock.Unlock()
+
+	if drainer, ok := handle.(driver.Drainer); ok && r.config != nil && r.config.DrainTimeout > 0 {
+		if err := drainer.Drain(r.config.DrainTimeout); err != nil {
+			r.logger.Warnf("agent: Failed to drain task %q for alloc %q: %v", r.task.Type, r.alloc.ID, err)
+		}
+	}
+
+	if destroyed, err := r.handleDestroy(); !destroyed {
+		return fmt.Errorf("failed to stop task %q for alloc %q: %v", r.task.Type, r.alloc.ID, err)
+	}
+	return r.SaveState()
+}
+
 // Restart will restart the task
 func (r *Worker) Restart(source, reason string) {
 	reasonStr := fmt.Sprintf("%s: %s", source, reason)
@@ -713,8 +916,24 @@ func (r *Worker) emitStats(ru *models.TaskStatistics) {
 		metrics.SetGaugeWithLabels([]string{"delay", "time"}, float32(ru.DelayCount.Time), labels)
 	}
 
+	if ru.Heartbeat != nil && r.config.PublishAllocationMetrics {
+		metrics.SetGaugeWithLabels([]string{"heartbeat", "age"}, float32(ru.Heartbeat.Age), labels)
+		metrics.SetGaugeWithLabels([]string{"heartbeat", "lag"}, float32(ru.Heartbeat.Lag), labels)
+	}
+
 	if ru.ThroughputStat != nil && r.config.PublishAllocationMetrics {
 		metrics.SetGaugeWithLabels([]string{"throughput", "num"}, float32(ru.ThroughputStat.Num), labels)
 		metrics.SetGaugeWithLabels([]string{"throughput", "time"}, float32(ru.ThroughputStat.Time), labels)
 	}
+
+	if r.config.PublishAllocationMetrics {
+		for _, c := range ru.Consistency {
+			if c.Samples == 0 {
+				continue
+			}
+			tableLabels := append([]metrics.Label{{Name: "table", Value: c.TableSchema + "." + c.TableName}}, labels...)
+			metrics.SetGaugeWithLabels([]string{"consistency", "score"}, float32(c.Score), tableLabels)
+			metrics.SetGaugeWithLabels([]string{"consistency", "mismatches"}, float32(c.Mismatches), tableLabels)
+		}
+	}
 }
@@ -7,9 +7,12 @@
 package client
 
 import (
+	"io/ioutil"
 	"reflect"
+	"strings"
 	"sync"
 	"testing"
+	"time"
 	"github.com/actiontech/dtle/internal/client/driver"
 	"github.com/actiontech/dtle/internal/config"
 	log "github.com/actiontech/dtle/internal/logger"
@@ -1622,3 +1625,168 @@ func TestWorker_emitStats(t *testing.T) {
 		})
 	}
 }
+
+// checkpointHandle is a driver handle reporting a GTID set
+type checkpointHandle struct {
+	gtid     string
+	shutdown bool
+}
+
+func (h *checkpointHandle) ID() string {
+	return `{"DriverConfig":{"Gtid":"` + h.gtid + `","NatsAddr":"127.0.0.1:8193"}}`
+}
+func (h *checkpointHandle) WaitCh() chan *models.WaitResult { return nil }
+func (h *checkpointHandle) Shutdown() error {
+	h.shutdown = true
+	return nil
+}
+func (h *checkpointHandle) Stats() (*models.TaskStatistics, error) { return nil, nil }
+
+func TestWorker_Checkpoint(t *testing.T) {
+	handle := &checkpointHandle{gtid: "00000000-0000-0000-0000-000000000001:1-10"}
+	r := &Worker{
+		logger:      log.New(ioutil.Discard, log.ParseLevel("ERROR")),
+		alloc:       &models.Allocation{ID: "alloc", JobID: "job"},
+		task:        &models.Task{Type: models.TaskTypeDest, Config: map[string]interface{}{}, ConfigLock: &sync.RWMutex{}},
+		handle:      handle,
+		workUpdates: make(chan *models.TaskUpdate, 1),
+	}
+	if err := r.Checkpoint(); err != nil {
+		t.Fatalf("Worker.Checkpoint() error = %v", err)
+	}
+	if !handle.shutdown || !r.checkpointed {
+		t.Errorf("Worker.Checkpoint() did not stop the task")
+	}
+	select {
+	case update := <-r.workUpdates:
+		if update.JobID != "job" || update.Gtid != handle.gtid {
+			t.Errorf("Worker.Checkpoint() update = %+v, want the GTID set of the task", update)
+		}
+	default:
+		t.Errorf("Worker.Checkpoint() sent no update")
+	}
+	if r.task.Config["Gtid"] != handle.gtid {
+		t.Errorf("Worker.Checkpoint() Gtid = %v, want %v", r.task.Config["Gtid"], handle.gtid)
+	}
+
+	// A task that is not running is left alone
+	idle := &Worker{task: &models.Task{Type: models.TaskTypeSrc}}
+	if err := idle.Checkpoint(); err != nil || idle.checkpointed {
+		t.Errorf("Worker.Checkpoint() of an idle task = %v, checkpointed %v", err, idle.checkpointed)
+	}
+}
+
+// drainHandle is a checkpointHandle recording the order it is drained and
+// stopped in
+type drainHandle struct {
+	checkpointHandle
+	timeout      time.Duration
+	drainedFirst bool
+}
+
+func (h *drainHandle) Drain(timeout time.Duration) error {
+	h.timeout = timeout
+	h.drainedFirst = !h.shutdown
+	return nil
+}
+
+func TestWorker_CheckpointDrain(t *testing.T) {
+	handle := &drainHandle{checkpointHandle: checkpointHandle{gtid: "00000000-0000-0000-0000-000000000001:1-10"}}
+	r := &Worker{
+		config:      &config.ClientConfig{DrainTimeout: 5 * time.Second},
+		logger:      log.New(ioutil.Discard, log.ParseLevel("ERROR")),
+		alloc:       &models.Allocation{ID: "alloc", JobID: "job"},
+		task:        &models.Task{Type: models.TaskTypeDest, Config: map[string]interface{}{}, ConfigLock: &sync.RWMutex{}},
+		handle:      handle,
+		workUpdates: make(chan *models.TaskUpdate, 1),
+	}
+	if err := r.Checkpoint(); err != nil {
+		t.Fatalf("Worker.Checkpoint() error = %v", err)
+	}
+	if handle.timeout != 5*time.Second || !handle.drainedFirst {
+		t.Errorf("Worker.Checkpoint() drained with %v before the shutdown: %v, want 5s, true", handle.timeout, handle.drainedFirst)
+	}
+	if !handle.shutdown {
+		t.Errorf("Worker.Checkpoint() did not stop the task")
+	}
+
+	// A DrainTimeout of 0 stops the task at once
+	handle = &drainHandle{checkpointHandle: checkpointHandle{gtid: handle.gtid}}
+	r.handle, r.config = handle, &config.ClientConfig{}
+	<-r.workUpdates
+	if err := r.Checkpoint(); err != nil {
+		t.Fatalf("Worker.Checkpoint() error = %v", err)
+	}
+	if handle.timeout != 0 || !handle.shutdown {
+		t.Errorf("Worker.Checkpoint() with no DrainTimeout drained with %v", handle.timeout)
+	}
+}
+
+func TestWorker_onPanic(t *testing.T) {
+	handle := &checkpointHandle{gtid: "00000000-0000-0000-0000-000000000001:1-10"}
+	var states []string
+	var events []*models.TaskEvent
+	r := &Worker{
+		logger:      log.New(ioutil.Discard, log.ParseLevel("ERROR")),
+		alloc:       &models.Allocation{ID: "alloc", JobID: "job"},
+		task:        &models.Task{Type: models.TaskTypeDest, Config: map[string]interface{}{}, ConfigLock: &sync.RWMutex{}},
+		handle:      handle,
+		workUpdates: make(chan *models.TaskUpdate, 1),
+		updater: func(taskName, state string, event *models.TaskEvent) {
+			states = append(states, state)
+			events = append(events, event)
+		},
+	}
+	func() {
+		defer models.RecoverPanic(r.onPanic)
+		panic("boom")
+	}()
+
+	if !handle.shutdown {
+		t.Errorf("Worker.onPanic() did not stop the task")
+	}
+	if len(events) != 1 || states[0] != models.TaskStateDead {
+		t.Fatalf("Worker.onPanic() states = %v, want %v", states, []string{models.TaskStateDead})
+	}
+	event := events[0]
+	if !event.FailsTask || event.Message != "panic: boom" || !strings.Contains(event.CrashReport, "TestWorker_onPanic") {
+		t.Errorf("Worker.onPanic() event = %+v, want a failed task with its crash report", event)
+	}
+	if eventType, _ := models.TaskEventToJobEvent(event); eventType != models.JobEventCrashed {
+		t.Errorf("Worker.onPanic() job event = %v, want %v", eventType, models.JobEventCrashed)
+	}
+}
+
+func TestWorker_updateBlocked(t *testing.T) {
+	var events []string
+	r := &Worker{
+		logger: log.New(ioutil.Discard, log.ParseLevel("ERROR")),
+		alloc:  &models.Allocation{ID: "alloc", JobID: "job"},
+		task:   &models.Task{Type: models.TaskTypeDest},
+		updater: func(taskName, state string, event *models.TaskEvent) {
+			if state != models.TaskStateRunning {
+				t.Errorf("Worker.updateBlocked() state = %v, want %v", state, models.TaskStateRunning)
+			}
+			events = append(events, event.Type)
+		},
+	}
+	for _, tt := range []struct {
+		stage string
+		want  []string
+	}{
+		{"Applying binlog", nil},
+		{models.StageBlockedOnTarget, []string{models.TaskBlocked}},
+		{models.StageBlockedOnTarget, []string{models.TaskBlocked}},
+		{"Applying binlog", []string{models.TaskBlocked, models.TaskUnblocked}},
+		{"Applying binlog", []string{models.TaskBlocked, models.TaskUnblocked}},
+		{models.StageBlockedOnTarget, []string{models.TaskBlocked, models.TaskUnblocked, models.TaskBlocked}},
+	} {
+		r.updateBlocked(tt.stage)
+		if !reflect.DeepEqual(events, tt.want) {
+			t.Errorf("Worker.updateBlocked(%q) events = %v, want %v", tt.stage, events, tt.want)
+		}
+		if r.blocked != (tt.stage == models.StageBlockedOnTarget) {
+			t.Errorf("Worker.updateBlocked(%q) blocked = %v", tt.stage, r.blocked)
+		}
+	}
+}
@@ -35,6 +35,103 @@ const (
 	defaultChunkSize  = 2000
 	defaultNumWorkers = 1
 	defaultMsgBytes   = 20 * 1024
+
+	defaultReplicaWaitTimeout = 300
+
+	defaultTxRetries         = 5
+	defaultTxRetryMaxBackoff = 10000
+	defaultTiDBBatchRows     = 256
+
+	defaultTargetOutageTimeout       = 600
+	defaultTargetReconnectMaxBackoff = 30000
+
+	defaultBinlogServerMaxBinlogSize  = 1 << 30
+	defaultBinlogServerRetentionHours = 72
+
+	defaultBinlogRelayMaxSize        = 10 << 30
+	defaultBinlogRelayRetentionHours = 72
+
+	defaultTxGroupMaxRows   = 1000
+	defaultTxGroupMaxBytes  = 4 << 20
+	defaultTxGroupMaxWaitMs = 10
+
+	defaultSoftDeleteValue = "now()"
+
+	defaultHistoryTableSuffix = "_history"
+	defaultHistoryOpColumn    = "_op"
+	defaultHistoryTsColumn    = "_ts"
+	defaultHistoryGtidColumn  = "_gtid"
+
+	defaultSendGroupsQueueSize     = 2
+	defaultSnapshotChunksQueueSize = 24
+
+	defaultConsistencyCheckSamplesPerHour = 4
+	defaultConsistencyCheckRangeRows      = 1000
+)
+
+// How the partial updates of JSON columns are replicated
+const (
+	// PartialJSONFull applies the changes to the value in the before image
+	// and replicates the full value
+	PartialJSONFull = "full"
+	// PartialJSONPatch replicates the changes as a mysql.JSONPatch
+	PartialJSONPatch = "patch"
+)
+
+// The managed MySQL offerings the source may be
+const (
+	ManagedMySQLNone   = "none"
+	ManagedMySQLRDS    = "rds"
+	ManagedMySQLAurora = "aurora"
+)
+
+// The servers the target may be
+const (
+	TargetServerMySQL = "mysql"
+	TargetServerTiDB  = "tidb"
+)
+
+// SnapshotOrderForeignKey copies referenced tables before the tables
+// referencing them
+const SnapshotOrderForeignKey = "foreign_key"
+
+// MaxDumpConnections caps DumpConnections
+const MaxDumpConnections = 32
+
+// The snapshot modes: how the tables are read consistently while copied
+const (
+	// SnapshotModeConsistent copies all tables in one transaction with a
+	// consistent snapshot, without locking them
+	SnapshotModeConsistent = "consistent"
+	// SnapshotModePerTable copies each table in a consistent snapshot of its
+	// own, skipping the binlog transactions already in its copy
+	SnapshotModePerTable = "per_table"
+	// SnapshotModeLockTables locks the tables for read while they are
+	// copied, for the engines without consistent snapshots
+	SnapshotModeLockTables = "lock_tables"
+)
+
+// The copy modes, for the jobs copying part of the source
+const (
+	// CopyModeSchemaOnly copies the schema but not the rows, nor the binlog
+	CopyModeSchemaOnly = "schema_only"
+	// CopyModeDataOnly copies the rows and the binlog into existing tables
+	CopyModeDataOnly = "data_only"
+)
+
+// The capacity checks, of the target against the size of the snapshot
+const (
+	CapacityCheckWarn = "warn"
+	CapacityCheckFail = "fail"
+	CapacityCheckOff  = "off"
+)
+
+// The kinds of objects CopyObjects may list
+const (
+	CopyObjectViews    = "views"
+	CopyObjectRoutines = "routines"
+	CopyObjectTriggers = "triggers"
+	CopyObjectEvents   = "events"
 )
 
 // RPCHandler can be provided to the Client if there is a local server
@@ -88,6 +185,11 @@ type ClientConfig struct {
 	// collects resource usage stats
 	StatsCollectionInterval time.Duration
 
+	// DrainTimeout is how long each task may take on the shutdown of the
+	// client to write the events it received to its target before it is
+	// stopped. 0 stops the tasks at once.
+	DrainTimeout time.Duration
+
 	// PublishNodeMetrics determines whether server is going to publish node
 	// level metrics to remote Metric sinks
 	PublishNodeMetrics bool
@@ -143,11 +245,208 @@ type MySQLDriverConfig struct {
 	criticalLoad                        umconf.LoadMap
 	RowsEstimate                        int64
 	DeltaEstimate                       int64
-	TimeZone                            string
+	TimeZone                            string // time_zone of the applier sessions on the target
 	GroupCount                          int
 	GroupMaxSize                        int
 	GroupTimeout                        int // millisecond
 
+	// AdaptiveGroupMaxSize lets the size of the groups of binlog entries sent
+	// to the applier grow from GroupMaxSize up to it, as the bytes read
+	// during the sending of a group, for the round trips of high latency
+	// links not to bound the throughput. 0 keeps the size at GroupMaxSize.
+	AdaptiveGroupMaxSize int
+
+	// HeartbeatInterval is the milliseconds between the heartbeats the
+	// extractor sends along with the binlog entries. 0 disables heartbeats.
+	HeartbeatInterval int
+	// HeartbeatTable is the "schema.table" the extractor writes to on the
+	// source at each heartbeat, so that the binlog moves on even if the
+	// replicated tables are idle. Empty disables the writes.
+	HeartbeatTable string
+
+	// FailoverHosts are the "host:port" of the replicas the source may fail
+	// over to. They are tried, with the user and password of the source,
+	// when the source is lost.
+	FailoverHosts []string
+
+	// PrimaryConnectionConfig is the primary of the source, when the source
+	// is a read replica offloading the primary. GTID sets taken on the
+	// primary are waited for on the replica before reading from it.
+	PrimaryConnectionConfig *umconf.ConnectionConfig
+	// ReplicaWaitTimeout is the seconds to wait for the replica to catch up
+	// with a GTID set of the primary. Defaults to 300.
+	ReplicaWaitTimeout int
+
+	// ManagedMySQL is the managed MySQL offering of the source: "rds",
+	// "aurora" or "none". It is detected if empty.
+	ManagedMySQL string
+	// BinlogRetentionHours is the binlog retention set on a managed source,
+	// which otherwise purges binlogs as soon as it can. 0 leaves it as is.
+	BinlogRetentionHours int
+
+	// PartialJSONUpdates is how the partial updates of JSON columns, logged
+	// with binlog_row_value_options=PARTIAL_JSON, are replicated: "full" (the
+	// default) or "patch".
+	PartialJSONUpdates string
+
+	// ForeignKeyChecks keeps foreign_key_checks on while applying, which are
+	// off by default. Set SnapshotOrder to "foreign_key" on the source for
+	// the snapshot to load with them on.
+	ForeignKeyChecks bool
+	// SkipTriggers sets @dtle_skip_triggers to 1 in the sessions of the
+	// applier. MySQL can not turn triggers off for a session: the triggers
+	// to skip have to check the variable.
+	SkipTriggers bool
+	// SnapshotOrder is the order the tables are copied in: as configured
+	// if empty, or "foreign_key" for referenced tables to come first.
+	SnapshotOrder string
+	// SessionVariables are set in the sessions of the applier, such as
+	// sql_mode, time_zone, lock_wait_timeout or innodb_lock_wait_timeout.
+	// The sql_mode set here replaces that of the source for the snapshot.
+	SessionVariables map[string]string
+	// TxRetries is how many times the applier retries a source transaction
+	// failing on the target with a deadlock or a lock wait timeout. Defaults
+	// to 5, a negative value disables the retries.
+	TxRetries int
+	// TxRetryMaxBackoff is the maximum milliseconds between the retries of a
+	// transaction, the first waiting 100ms and each next twice longer.
+	// Defaults to 10000.
+	TxRetryMaxBackoff int
+	// TargetOutageTimeout is the seconds the applier reconnects to a target
+	// it lost the connection to, such as on its restart, before the job is
+	// blocked: the task does not fail but keeps reconnecting in the
+	// StageBlockedOnTarget stage. The transaction being applied is applied
+	// again unless the target committed it. Defaults to 600, a negative
+	// value fails the task on the loss of the connection.
+	TargetOutageTimeout int
+	// TargetReconnectMaxBackoff is the maximum milliseconds between the
+	// reconnections to a lost target, the first waiting 1s and each next
+	// twice longer. Defaults to 30000.
+	TargetReconnectMaxBackoff int
+	// SnapshotLoadData loads the snapshot on the target with LOAD DATA LOCAL
+	// INFILE, streaming the rows as CSV, which needs local_infile=ON on the
+	// target. The rows are inserted if it fails.
+	SnapshotLoadData bool
+	// SnapshotMode is how the tables are read consistently while copied:
+	// "consistent" (the default), "per_table" for shorter transactions on
+	// the source, or "lock_tables" for tables without MVCC such as MyISAM.
+	SnapshotMode string
+	// CopyMode limits what the job copies: "schema_only" creates the
+	// databases, tables and views on the target and ends, "data_only" copies
+	// the rows into the existing tables, then the binlog. Empty copies both.
+	CopyMode string
+	// CopyObjects are the kinds of objects of the replicated schemas created
+	// on the target after the tables: "views", "routines", "triggers" (of
+	// the copied tables) and "events" (disabled on the target).
+	CopyObjects []string
+	// DefinerRewrite maps the DEFINER of the copied objects, as user@host or
+	// "*" for any, to that on the target, as user@host, or to "" to remove
+	// it, for the applier user to be the definer, as are unmatched definers.
+	DefinerRewrite map[string]string
+	// DumpConnections is the number of source connections copying tables at
+	// a time, 1 by default and at most MaxDumpConnections. Fewer are opened
+	// if the source has less than twice as many connections free.
+	DumpConnections int
+	// DumpConnectionRate limits the bytes read per second by each dump
+	// connection. 0 does not limit.
+	DumpConnectionRate int64
+	// NatsBindAddress is the local IP, or the name of the network interface,
+	// the task connects to the NATS server of the job from, for the traffic
+	// between the source and the target to take another link than MySQL's
+	NatsBindAddress string
+	// CreateTableEngine is the storage engine of the tables created on the
+	// target, instead of that of the source
+	CreateTableEngine string
+	// CreateTableCharset is the charset of the databases, tables and columns
+	// created on the target, instead of that of the source
+	CreateTableCharset string
+	// CreateTableStripPartitions creates the tables on the target without
+	// the partitioning of the source
+	CreateTableStripPartitions bool
+	// DDLRewriteRules enables (true) or disables (false) the rules adapting
+	// the DDLs to the version of the target, by name: "collation_0900",
+	// "invisible_index" and "srid", on by default for targets before 8.0,
+	// and "utf8mb3", off by default.
+	DDLRewriteRules map[string]bool
+	// DDLRewriteDryRun applies the DDLs as is, only logging and reporting
+	// the rewrites the rules would make
+	DDLRewriteDryRun bool
+	// TargetServer is the server of the target: "mysql" or "tidb", for the
+	// applier to avoid the DDLs TiDB does not support, retry on the errors
+	// of its optimistic transactions and insert the snapshot in smaller
+	// batches. It is detected if empty.
+	TargetServer string
+	// TiDBBatchRows is the maximum number of rows of an insert of the
+	// snapshot into TiDB. Defaults to 256.
+	TiDBBatchRows int
+	// BulkLoad copies the rows of the snapshot from files exported from the
+	// source, rather than from the source, then the binlog from the GTID
+	// set the export was taken at
+	BulkLoad *BulkLoadConfig
+	// CapacityCheck is what the source does if the estimated size of the
+	// snapshot exceeds the free disk of the target, or the retention of its
+	// Kafka topics: "warn" (the default) logs a warning, "fail" fails the
+	// job before copying and "off" skips the check
+	CapacityCheck string
+	// DryRunFile is a file on the node of the target task the applier
+	// writes the statements it would execute to, executing none. The
+	// target is only read, for the columns of the tables.
+	DryRunFile string
+	// SlowApplyThreshold is the milliseconds a statement of the incremental
+	// copy may take to apply before it is kept in the slow apply log of the
+	// task statistics. 0 keeps none.
+	SlowApplyThreshold int
+	// BinlogServer keeps the binlog the source task reads on its node and
+	// serves it to MySQL replicas, for them to replicate from dtle rather
+	// than from the source
+	BinlogServer *BinlogServerConfig
+	// BinlogRelay keeps the binlog of the source on the node of the source
+	// task as it is read, the task reading it from there, so that a
+	// restarted or slow job does not read it from the source again
+	BinlogRelay *BinlogRelayConfig
+	// StrictOrder makes the target apply the transactions one at a time in
+	// the commit order of the source, whatever ParallelWorkers, for the
+	// target never to see a state the source did not have
+	StrictOrder bool
+	// TxGroup coalesces the small transactions of the source into larger
+	// transactions of the target, for fewer commits on the target
+	TxGroup *TxGroupConfig
+	// LatencyTargetMs is the p99 of the milliseconds from a transaction
+	// being read from the binlog of the source to it being committed on the
+	// target the job adjusts its batching to: the sizes and timeouts of the
+	// groups of entries sent by the source and of the TxGroup of the target
+	// are scaled down while the p99 is above it, unless the target is
+	// falling behind, and back up while it is well within. 0 keeps the
+	// configured sizes.
+	LatencyTargetMs int
+	// QueueSizes are the capacities of the queues the entries go through,
+	// from the binlog of the source to the target. They are reported with
+	// their depths in the statistics of the tasks.
+	QueueSizes *QueueSizesConfig
+	// SoftDelete makes a MySQL target mark the rows the source deletes as
+	// deleted, by updating a column of them, instead of deleting them
+	SoftDelete *SoftDeleteConfig
+	// History makes a MySQL target append the changes of the rows of the
+	// source to history tables instead of applying them to the tables, for
+	// the history tables to keep every version of the rows
+	History *HistoryConfig
+	// ConsistencyCheck compares random ranges of the rows of the replicated
+	// tables on the source and a MySQL target in the background, scoring
+	// the consistency of each table in the statistics and metrics of the
+	// source task
+	ConsistencyCheck *ConsistencyCheckConfig
+	// FaultInjection injects faults into the job, for tests only
+	FaultInjection *FaultInjectionConfig
+	// ErrorClasses override the classes of the errors of a MySQL target by
+	// their code, such as {"1062": "ignorable"}: retryable, data, terminal
+	// or ignorable, the statements failing with an ignorable error being
+	// skipped
+	ErrorClasses map[string]string
+	// SchemaHistory is the versions of the replicated tables the target
+	// applied, kept with the Gtid by the job, which the source decodes the
+	// binlog from the Gtid with. For internal use.
+	SchemaHistory []*models.SchemaVersion
+
 	Gtid                     string
 	GtidStart                string
 	AutoGtid                 bool // For internal use. Might be changed without notification.
@@ -200,6 +499,9 @@ func (a *MySQLDriverConfig) SetDefault() *MySQLDriverConfig {
 	if result.ParallelWorkers <= 0 {
 		result.ParallelWorkers = defaultNumWorkers
 	}
+	if result.StrictOrder {
+		result.ParallelWorkers = 1
+	}
 	if result.MsgBytesLimit <= 0 {
 		result.MsgBytesLimit = defaultMsgBytes
 	}
@@ -212,6 +514,21 @@ func (a *MySQLDriverConfig) SetDefault() *MySQLDriverConfig {
 	if result.GroupTimeout == 0 {
 		result.GroupTimeout = 100
 	}
+	if result.TxRetries == 0 {
+		result.TxRetries = defaultTxRetries
+	}
+	if result.TxRetryMaxBackoff <= 0 {
+		result.TxRetryMaxBackoff = defaultTxRetryMaxBackoff
+	}
+	if result.TargetOutageTimeout == 0 {
+		result.TargetOutageTimeout = defaultTargetOutageTimeout
+	}
+	if result.TargetReconnectMaxBackoff <= 0 {
+		result.TargetReconnectMaxBackoff = defaultTargetReconnectMaxBackoff
+	}
+	if result.TiDBBatchRows <= 0 {
+		result.TiDBBatchRows = defaultTiDBBatchRows
+	}
 
 	// TODO temporarily (or permanently) disable homogeneous replication, hetero only.
 	result.ApproveHeterogeneous = true
@@ -219,6 +536,103 @@ func (a *MySQLDriverConfig) SetDefault() *MySQLDriverConfig {
 	if "" == result.ConnectionConfig.Charset {
 		result.ConnectionConfig.Charset = "utf8mb4"
 	}
+	if result.PrimaryConnectionConfig != nil {
+		primary := *result.PrimaryConnectionConfig
+		if primary.User == "" {
+			primary.User = result.ConnectionConfig.User
+			primary.Password = result.ConnectionConfig.Password
+		}
+		if primary.Charset == "" {
+			primary.Charset = result.ConnectionConfig.Charset
+		}
+		result.PrimaryConnectionConfig = &primary
+		if result.ReplicaWaitTimeout <= 0 {
+			result.ReplicaWaitTimeout = defaultReplicaWaitTimeout
+		}
+	}
+	if result.BinlogServer != nil {
+		binlogServer := *result.BinlogServer
+		if binlogServer.MaxBinlogSize <= 0 {
+			binlogServer.MaxBinlogSize = defaultBinlogServerMaxBinlogSize
+		}
+		if binlogServer.RetentionHours <= 0 {
+			binlogServer.RetentionHours = defaultBinlogServerRetentionHours
+		}
+		result.BinlogServer = &binlogServer
+	}
+	if result.BinlogRelay != nil {
+		binlogRelay := *result.BinlogRelay
+		if binlogRelay.MaxSize <= 0 {
+			binlogRelay.MaxSize = defaultBinlogRelayMaxSize
+		}
+		if binlogRelay.RetentionHours <= 0 {
+			binlogRelay.RetentionHours = defaultBinlogRelayRetentionHours
+		}
+		result.BinlogRelay = &binlogRelay
+	}
+	if result.TxGroup != nil {
+		txGroup := *result.TxGroup
+		if txGroup.MaxRows <= 0 {
+			txGroup.MaxRows = defaultTxGroupMaxRows
+		}
+		if txGroup.MaxBytes <= 0 {
+			txGroup.MaxBytes = defaultTxGroupMaxBytes
+		}
+		if txGroup.MaxWaitMs <= 0 {
+			txGroup.MaxWaitMs = defaultTxGroupMaxWaitMs
+		}
+		result.TxGroup = &txGroup
+	}
+	var queueSizes QueueSizesConfig
+	if result.QueueSizes != nil {
+		queueSizes = *result.QueueSizes
+	}
+	if queueSizes.BinlogEntries <= 0 {
+		queueSizes.BinlogEntries = int(result.ReplChanBufferSize)
+	}
+	if queueSizes.SendGroups <= 0 {
+		queueSizes.SendGroups = defaultSendGroupsQueueSize
+	}
+	if queueSizes.SnapshotChunks <= 0 {
+		queueSizes.SnapshotChunks = defaultSnapshotChunksQueueSize
+	}
+	if queueSizes.ApplyEntries <= 0 {
+		queueSizes.ApplyEntries = int(result.ReplChanBufferSize) * 2
+	}
+	result.QueueSizes = &queueSizes
+	if result.SoftDelete != nil {
+		softDelete := *result.SoftDelete
+		if softDelete.Value == "" {
+			softDelete.Value = defaultSoftDeleteValue
+		}
+		result.SoftDelete = &softDelete
+	}
+	if result.History != nil {
+		history := *result.History
+		if history.TableSuffix == "" {
+			history.TableSuffix = defaultHistoryTableSuffix
+		}
+		if history.OpColumn == "" {
+			history.OpColumn = defaultHistoryOpColumn
+		}
+		if history.TsColumn == "" {
+			history.TsColumn = defaultHistoryTsColumn
+		}
+		if history.GtidColumn == "" {
+			history.GtidColumn = defaultHistoryGtidColumn
+		}
+		result.History = &history
+	}
+	if result.ConsistencyCheck != nil {
+		consistencyCheck := *result.ConsistencyCheck
+		if consistencyCheck.SamplesPerHour <= 0 {
+			consistencyCheck.SamplesPerHour = defaultConsistencyCheckSamplesPerHour
+		}
+		if consistencyCheck.RangeRows <= 0 {
+			consistencyCheck.RangeRows = defaultConsistencyCheckRangeRows
+		}
+		result.ConsistencyCheck = &consistencyCheck
+	}
 	return &result
 }
 
@@ -297,6 +711,207 @@ func (m *MySQLDriverConfig) GetCriticalLoad() umconf.LoadMap {
 	return m.criticalLoad.Duplicate()
 }
 
+// BinlogServerConfig is the binlog server of a source task
+type BinlogServerConfig struct {
+	// Addr is the address the replicas connect to, such as "0.0.0.0:3307"
+	Addr string
+	// Dir is the directory on the node of the source task the binlogs are
+	// kept in. The task resumes the binlogs of the directory when it is
+	// restarted on the same node.
+	Dir string
+	// User and Password are those the replicas connect with, by
+	// mysql_native_password. Any user may connect if User is empty.
+	User     string
+	Password string
+	// ServerID is the server_id of the binlog server, different from those
+	// of the source and of the replicas. It is derived from the server_uuid
+	// of the binlog server if 0.
+	ServerID uint32
+	// MaxBinlogSize is the bytes after which a binlog is rotated. Defaults
+	// to 1G.
+	MaxBinlogSize int64
+	// RetentionHours is how long the binlogs are kept after they are
+	// rotated. Defaults to 72.
+	RetentionHours int
+}
+
+// BinlogRelayConfig is the local relay of the binlog of a source task
+type BinlogRelayConfig struct {
+	// Dir is the directory on the node of the source task the relayed
+	// binlog is kept in. A task restarted on the same node reads the
+	// transactions the directory has from there.
+	Dir string
+	// MaxSize is the bytes the relayed binlog may take, its oldest
+	// transactions being removed beyond. Defaults to 10G.
+	MaxSize int64
+	// RetentionHours is how long the relayed transactions are kept.
+	// Defaults to 72.
+	RetentionHours int
+	// Job is the ID of another job of the node whose relay the task reads,
+	// rather than relaying its source, from the Gtid of the job. The relay
+	// is that of the other job, whatever Dir, MaxSize and RetentionHours.
+	Job string
+}
+
+// TxGroupConfig bounds the transactions of the target the transactions of
+// the source are coalesced into
+type TxGroupConfig struct {
+	// MaxRows is the rows after which a group is committed. Defaults to
+	// 1000.
+	MaxRows int
+	// MaxBytes is the binlog bytes after which a group is committed.
+	// Defaults to 4M.
+	MaxBytes int
+	// MaxWaitMs is how long a group waits for more transactions before it
+	// is committed. Defaults to 10.
+	MaxWaitMs int
+}
+
+// QueueSizesConfig are the capacities of the queues of a job
+type QueueSizesConfig struct {
+	// BinlogEntries is the transactions read from the binlog of the source
+	// waiting to be grouped. Defaults to ReplChanBufferSize.
+	BinlogEntries int
+	// SendGroups is the encoded groups of entries waiting to be sent to the
+	// target. Defaults to 2.
+	SendGroups int
+	// SnapshotChunks is the chunks of rows of the snapshot read ahead of
+	// the one being sent, and received ahead of the one being applied.
+	// Defaults to 24.
+	SnapshotChunks int
+	// NatsPendingMsgs is the messages received by the target waiting to be
+	// enqueued, beyond which NATS drops them and the source resends them.
+	// The NATS default of 65536 is kept if 0.
+	NatsPendingMsgs int
+	// ApplyEntries is the entries received by the target waiting to be
+	// applied. Defaults to twice ReplChanBufferSize.
+	ApplyEntries int
+}
+
+// SoftDeleteConfig is the column marking the rows deleted on the source
+type SoftDeleteConfig struct {
+	// Column is the column of the target tables updated on a delete, such
+	// as deleted_at or is_deleted. The source tables need not have it.
+	Column string
+	// Value is the SQL expression Column is set to, such as 1. Defaults to
+	// now().
+	Value string
+	// Tables are the "schema.table" whose rows are soft deleted, all the
+	// tables of the job if empty
+	Tables []string
+}
+
+// Applies tells whether the rows of a table are soft deleted
+func (c *SoftDeleteConfig) Applies(schemaName, tableName string) bool {
+	if c == nil || c.Column == "" {
+		return false
+	}
+	return tableListed(c.Tables, schemaName, tableName)
+}
+
+// HistoryConfig is the history tables the changes of the rows are appended
+// to. The history table of a table has its columns, the values of the row
+// after an insert or update and before a delete, followed by OpColumn,
+// TsColumn and GtidColumn.
+type HistoryConfig struct {
+	// TableSuffix is appended to the name of a table for that of its
+	// history table, in the same schema. Defaults to "_history".
+	TableSuffix string
+	// OpColumn is the change of the row: insert, update or delete.
+	// Defaults to "_op".
+	OpColumn string
+	// TsColumn is the UTC datetime the change was committed on the source.
+	// Defaults to "_ts".
+	TsColumn string
+	// GtidColumn is the GTID of the transaction of the change. Defaults to
+	// "_gtid".
+	GtidColumn string
+	// Tables are the "schema.table" whose changes are appended to history
+	// tables, all the tables of the job if empty
+	Tables []string
+}
+
+// Applies tells whether the changes of a table are appended to its history
+// table
+func (c *HistoryConfig) Applies(schemaName, tableName string) bool {
+	if c == nil {
+		return false
+	}
+	return tableListed(c.Tables, schemaName, tableName)
+}
+
+// tableListed tells whether a table is one of the "schema.table" of a list,
+// any table being if it is empty
+func tableListed(tables []string, schemaName, tableName string) bool {
+	if len(tables) == 0 {
+		return true
+	}
+	name := fmt.Sprintf("%s.%s", schemaName, tableName)
+	for _, table := range tables {
+		if table == name {
+			return true
+		}
+	}
+	return false
+}
+
+// ConsistencyCheckConfig is how often and how much of the tables the
+// consistency check samples
+type ConsistencyCheckConfig struct {
+	// SamplesPerHour is the ranges of rows of each table compared per hour.
+	// Defaults to 4.
+	SamplesPerHour int
+	// RangeRows is the rows of a range, by the unique key of the table.
+	// Defaults to 1000.
+	RangeRows int
+	// TargetConnectionConfig is that of the target of the job, set when the
+	// job is submitted. For internal use.
+	TargetConnectionConfig *umconf.ConnectionConfig
+}
+
+// The formats of the files of a bulk load
+const (
+	BulkLoadFormatCSV     = "csv"
+	BulkLoadFormatParquet = "parquet"
+)
+
+// BulkLoadConfig is the export the snapshot is loaded from
+type BulkLoadConfig struct {
+	// Gtid is the GTID set of the source the files were exported at
+	Gtid   string
+	Tables []*BulkLoadTable
+	// S3 is the endpoint of the files on S3, whose credentials are those
+	// of the environment, the shared config or the instance role
+	S3 *S3Config
+}
+
+// BulkLoadTable is a replicated table and the files holding its rows
+type BulkLoadTable struct {
+	Schema string
+	Table  string
+	// Files are the paths of the files, or glob patterns, or S3 URLs such
+	// as s3://bucket/key, a key ending with "/" being all the files under it
+	Files []string
+	// Format is "csv" or "parquet", by default that of the extension of the
+	// files. CSV files may be gzipped, ending with ".gz".
+	Format string
+	// Columns are the columns of the values of a CSV file, which names them
+	// on its first line if empty
+	Columns []string
+	// Delimiter is the character between the values of a CSV file, "," by
+	// default
+	Delimiter string
+	// Null is the value of NULL in a CSV file, `\N` by default
+	Null string
+}
+
+type S3Config struct {
+	Region string
+	// Endpoint is that of an S3 compatible storage, addressing the buckets
+	// by path
+	Endpoint string
+}
+
 // TableName is the table configuration
 // slave restrict replication to a given table
 type DataSource struct {
@@ -424,6 +1039,9 @@ func NewWhereCtx(where string, table *Table) (*WhereContext, error) {
 	}
 }
 
+// DefaultDrainTimeout is the default DrainTimeout of a client
+const DefaultDrainTimeout = 30 * time.Second
+
 // DefaultConfig returns the default configuration
 func DefaultClientConfig() *ClientConfig {
 	return &ClientConfig{
@@ -432,6 +1050,7 @@ func DefaultClientConfig() *ClientConfig {
 		LogOutput:               os.Stderr,
 		Region:                  "global",
 		StatsCollectionInterval: 1 * time.Second,
+		DrainTimeout:            DefaultDrainTimeout,
 		LogLevel:                "INFO",
 	}
 }
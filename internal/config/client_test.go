@@ -0,0 +1,69 @@
+package config
+
+import (
+	"github.com/actiontech/dtle/internal/config/mysql"
+	"reflect"
+	"testing"
+)
+
+func TestMySQLDriverConfig_SetDefaultQueueSizes(t *testing.T) {
+	cfg := (&MySQLDriverConfig{ReplChanBufferSize: 100, ConnectionConfig: &mysql.ConnectionConfig{}}).SetDefault()
+	want := &QueueSizesConfig{
+		BinlogEntries:  100,
+		SendGroups:     defaultSendGroupsQueueSize,
+		SnapshotChunks: defaultSnapshotChunksQueueSize,
+		ApplyEntries:   200,
+	}
+	if !reflect.DeepEqual(cfg.QueueSizes, want) {
+		t.Fatalf("QueueSizes = %+v, want %+v", cfg.QueueSizes, want)
+	}
+
+	queueSizes := &QueueSizesConfig{BinlogEntries: 10, SendGroups: 4, NatsPendingMsgs: 1000}
+	cfg = (&MySQLDriverConfig{QueueSizes: queueSizes, ConnectionConfig: &mysql.ConnectionConfig{}}).SetDefault()
+	want = &QueueSizesConfig{
+		BinlogEntries:   10,
+		SendGroups:      4,
+		SnapshotChunks:  defaultSnapshotChunksQueueSize,
+		NatsPendingMsgs: 1000,
+		ApplyEntries:    channelBufferSize * 2,
+	}
+	if !reflect.DeepEqual(cfg.QueueSizes, want) {
+		t.Fatalf("QueueSizes = %+v, want %+v", cfg.QueueSizes, want)
+	}
+	if queueSizes.SnapshotChunks != 0 {
+		t.Fatalf("SetDefault changed the QueueSizes of the job: %+v", queueSizes)
+	}
+}
+
+func TestSoftDeleteConfig_Applies(t *testing.T) {
+	cfg := (&MySQLDriverConfig{SoftDelete: &SoftDeleteConfig{Column: "deleted_at"}, ConnectionConfig: &mysql.ConnectionConfig{}}).SetDefault()
+	if cfg.SoftDelete.Value != defaultSoftDeleteValue {
+		t.Fatalf("Value = %q, want %q", cfg.SoftDelete.Value, defaultSoftDeleteValue)
+	}
+	if !cfg.SoftDelete.Applies("db", "tb") {
+		t.Fatal("a soft delete of all the tables does not apply to db.tb")
+	}
+
+	softDelete := &SoftDeleteConfig{Column: "is_deleted", Value: "1", Tables: []string{"db.tb"}}
+	if !softDelete.Applies("db", "tb") || softDelete.Applies("db", "other") {
+		t.Fatalf("Applies() of %v", softDelete.Tables)
+	}
+	if (*SoftDeleteConfig)(nil).Applies("db", "tb") || (&SoftDeleteConfig{}).Applies("db", "tb") {
+		t.Fatal("Applies() without a Column")
+	}
+}
+
+func TestHistoryConfig_Applies(t *testing.T) {
+	cfg := (&MySQLDriverConfig{History: &HistoryConfig{GtidColumn: "gtid"}, ConnectionConfig: &mysql.ConnectionConfig{}}).SetDefault()
+	want := &HistoryConfig{TableSuffix: "_history", OpColumn: "_op", TsColumn: "_ts", GtidColumn: "gtid"}
+	if !reflect.DeepEqual(cfg.History, want) {
+		t.Fatalf("History = %+v, want %+v", cfg.History, want)
+	}
+	if !cfg.History.Applies("db", "tb") {
+		t.Fatal("a history of all the tables does not apply to db.tb")
+	}
+	history := &HistoryConfig{Tables: []string{"db.tb"}}
+	if !history.Applies("db", "tb") || history.Applies("db", "other") || (*HistoryConfig)(nil).Applies("db", "tb") {
+		t.Fatalf("Applies() of %v", history.Tables)
+	}
+}
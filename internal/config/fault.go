@@ -0,0 +1,117 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package config
+
+import (
+	"fmt"
+	"math/rand"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/actiontech/dtle/internal/g"
+)
+
+// The points faults are injected at
+const (
+	// FaultNatsDisconnect closes the connection of a source to NATS as it
+	// publishes a message
+	FaultNatsDisconnect = "nats-disconnect"
+	// FaultKafkaSend fails the sending of a record
+	FaultKafkaSend = "kafka-send"
+	// FaultApply fails the applying of a transaction on a MySQL target
+	FaultApply = "apply"
+)
+
+// FaultInjectionConfig injects faults into a job at random, for the alerting
+// and the recovery of the jobs to be tested before production. It is for
+// tests only: the faults are injected on the nodes whose DTLE_FAULT_INJECTION
+// environment variable is set, and the config is ignored elsewhere.
+type FaultInjectionConfig struct {
+	// NatsDisconnectRate is the ratio of the messages of a MySQL source
+	// whose publishing closes the connection to NATS instead
+	NatsDisconnectRate float64
+	// KafkaSendFailureRate is the ratio of the records whose sending fails
+	KafkaSendFailureRate float64
+	// ApplyErrorRate is the ratio of the transactions whose applying on a
+	// MySQL target fails
+	ApplyErrorRate float64
+	// Seed seeds the faults, for a run to be repeated. 0 seeds them at
+	// random.
+	Seed int64
+}
+
+// Validate checks that the rates are between 0 and 1
+func (c *FaultInjectionConfig) Validate() error {
+	for name, rate := range map[string]float64{
+		"NatsDisconnectRate":   c.NatsDisconnectRate,
+		"KafkaSendFailureRate": c.KafkaSendFailureRate,
+		"ApplyErrorRate":       c.ApplyErrorRate,
+	} {
+		if rate < 0 || rate > 1 {
+			return fmt.Errorf("FaultInjection: %s must be between 0 and 1, got %v", name, rate)
+		}
+	}
+	return nil
+}
+
+// FaultInjectionAllowed tells if the node injects the faults of the jobs
+func FaultInjectionAllowed() bool {
+	return os.Getenv(g.ENV_FAULT_INJECTION) != ""
+}
+
+// FaultError is the error of an injected fault
+type FaultError struct {
+	Point string
+}
+
+func (e *FaultError) Error() string {
+	return fmt.Sprintf("fault injection: %s", e.Point)
+}
+
+// FaultInjector injects the faults of a FaultInjectionConfig. A nil
+// FaultInjector injects none.
+type FaultInjector struct {
+	rates map[string]float64
+
+	mutex sync.Mutex
+	rand  *rand.Rand
+}
+
+// NewFaultInjector returns the injector of cfg, or nil if cfg is nil or the
+// node does not allow fault injection
+func NewFaultInjector(cfg *FaultInjectionConfig) *FaultInjector {
+	if cfg == nil || !FaultInjectionAllowed() {
+		return nil
+	}
+	seed := cfg.Seed
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+	return &FaultInjector{
+		rates: map[string]float64{
+			FaultNatsDisconnect: cfg.NatsDisconnectRate,
+			FaultKafkaSend:      cfg.KafkaSendFailureRate,
+			FaultApply:          cfg.ApplyErrorRate,
+		},
+		rand: rand.New(rand.NewSource(seed)),
+	}
+}
+
+// Inject returns a FaultError at the rate of point, or nil
+func (f *FaultInjector) Inject(point string) error {
+	if f == nil || f.rates[point] == 0 {
+		return nil
+	}
+	f.mutex.Lock()
+	n := f.rand.Float64()
+	f.mutex.Unlock()
+	if n < f.rates[point] {
+		return &FaultError{Point: point}
+	}
+	return nil
+}
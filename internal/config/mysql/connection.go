@@ -8,6 +8,8 @@ package mysql
 
 import (
 	"fmt"
+	"net"
+	"strconv"
 )
 
 // ConnectionConfig is the minimal configuration required to connect to a MySQL server
@@ -17,19 +19,92 @@ type ConnectionConfig struct {
 	User     string
 	Password string
 	Charset  string
+
+	// TLS encrypts the connection if set
+	TLS *TLSConfig
+	// SSHTunnel connects through an SSH server if set
+	SSHTunnel *SSHTunnelConfig
+	// SocksProxy connects through a SOCKS5 proxy if set
+	SocksProxy *SocksProxyConfig
+	// BindAddress is the local IP, or the name of the network interface,
+	// the connections to MySQL are made from, through the tunnel if any
+	BindAddress string
+
+	// set by Prepare
+	dialAddr string
+	tlsName  string
+	// set by CountTraffic
+	traffic *Traffic
+	dialNet string
+}
+
+// Prepare sets up the TLS config and the tunnel of the connection, if any.
+// It must be called before connecting, and again after changing the host.
+func (c *ConnectionConfig) Prepare() error {
+	c.dialAddr = ""
+	c.tlsName = ""
+	if c.SSHTunnel != nil && c.SocksProxy != nil {
+		return fmt.Errorf("SSHTunnel and SocksProxy can not be both set")
+	}
+	target := net.JoinHostPort(c.Host, strconv.Itoa(c.Port))
+	var bindIP string
+	var err error
+	if c.BindAddress != "" {
+		if bindIP, err = ResolveBindAddress(c.BindAddress); err != nil {
+			return err
+		}
+	}
+	switch {
+	case c.SSHTunnel != nil:
+		sshTunnel := *c.SSHTunnel
+		sshTunnel.bindIP = bindIP
+		c.dialAddr, err = forward(target, &sshTunnel)
+	case c.SocksProxy != nil:
+		socksProxy := *c.SocksProxy
+		socksProxy.bindIP = bindIP
+		c.dialAddr, err = forward(target, &socksProxy)
+	case bindIP != "":
+		c.dialAddr, err = forward(target, &bindConfig{ip: bindIP})
+	}
+	if err != nil {
+		return err
+	}
+	if c.TLS != nil {
+		if c.tlsName, err = c.TLS.register(c.Host); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DialAddress returns the "host:port" to connect to, which is a local end of
+// the tunnel if there is one
+func (c *ConnectionConfig) DialAddress() string {
+	if c.dialAddr != "" {
+		return c.dialAddr
+	}
+	return net.JoinHostPort(c.Host, strconv.Itoa(c.Port))
+}
+
+// tlsParam returns the tls parameter of the DSN
+func (c *ConnectionConfig) tlsParam() string {
+	if c.tlsName != "" {
+		return c.tlsName
+	}
+	return "false"
 }
 
 func (c *ConnectionConfig) GetDBUriByDbName(databaseName string) string {
-	return fmt.Sprintf("%s:%s@tcp(%s:%d)/%s?charset=%v&maxAllowedPacket=0", c.User, c.Password, c.Host, c.Port, databaseName, c.Charset)
+	return fmt.Sprintf("%s:%s@%s(%s)/%s?charset=%v&tls=%s&maxAllowedPacket=0", c.User, c.Password, c.dialNetwork(), c.DialAddress(), databaseName, c.Charset, c.tlsParam())
 }
 
 func (c *ConnectionConfig) GetDBUri() string {
 	if "" == c.Charset {
 		c.Charset = "utf8mb4"
 	}
-	return fmt.Sprintf("%s:%s@tcp(%s:%d)/?timeout=5s&tls=false&autocommit=true&charset=%v&multiStatements=true&maxAllowedPacket=0", c.User, c.Password, c.Host, c.Port, c.Charset)
+	return fmt.Sprintf("%s:%s@%s(%s)/?timeout=5s&tls=%s&autocommit=true&charset=%v&multiStatements=true&maxAllowedPacket=0", c.User, c.Password, c.dialNetwork(), c.DialAddress(), c.tlsParam(), c.Charset)
 }
 
 func (c *ConnectionConfig) GetSingletonDBUri() string {
-	return fmt.Sprintf("%s:%s@tcp(%s:%d)/?timeout=5s&tls=false&autocommit=false&charset=%v&multiStatements=true&maxAllowedPacket=0", c.User, c.Password, c.Host, c.Port, c.Charset)
+	return fmt.Sprintf("%s:%s@%s(%s)/?timeout=5s&tls=%s&autocommit=false&charset=%v&multiStatements=true&maxAllowedPacket=0", c.User, c.Password, c.dialNetwork(), c.DialAddress(), c.tlsParam(), c.Charset)
 }
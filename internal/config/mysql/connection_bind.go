@@ -0,0 +1,166 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package mysql
+
+import (
+	"fmt"
+	"net"
+	"sync/atomic"
+	"time"
+
+	gomysqldriver "github.com/go-sql-driver/mysql"
+)
+
+// dialTimeout is that of the connections dialed by countingDial, as the
+// timeout of the DSNs
+const dialTimeout = 5 * time.Second
+
+// ResolveBindAddress returns the local IP to connect from for a BindAddress:
+// the IP itself, or the first IPv4 address, else IPv6, of the network
+// interface of that name
+func ResolveBindAddress(bind string) (string, error) {
+	if ip := net.ParseIP(bind); ip != nil {
+		return ip.String(), nil
+	}
+	iface, err := net.InterfaceByName(bind)
+	if err != nil {
+		return "", fmt.Errorf("BindAddress %q is neither an IP nor a network interface: %v", bind, err)
+	}
+	addrs, err := iface.Addrs()
+	if err != nil {
+		return "", err
+	}
+	var ipv6 string
+	for _, addr := range addrs {
+		ipNet, ok := addr.(*net.IPNet)
+		if !ok {
+			continue
+		}
+		if ipNet.IP.To4() != nil {
+			return ipNet.IP.String(), nil
+		}
+		if ipv6 == "" && !ipNet.IP.IsLinkLocalUnicast() {
+			ipv6 = ipNet.IP.String()
+		}
+	}
+	if ipv6 == "" {
+		return "", fmt.Errorf("network interface %q has no IP address", bind)
+	}
+	return ipv6, nil
+}
+
+// bindDialer returns a dialer connecting from the IP, or from any if empty
+func bindDialer(ip string, timeout time.Duration) *net.Dialer {
+	dialer := &net.Dialer{Timeout: timeout}
+	if ip != "" {
+		dialer.LocalAddr = &net.TCPAddr{IP: net.ParseIP(ip)}
+	}
+	return dialer
+}
+
+// bindConfig opens the tunnels connecting to MySQL from a local IP, for the
+// connections which can not choose their local address
+type bindConfig struct {
+	ip string
+}
+
+func (b *bindConfig) key(target string) string {
+	return fmt.Sprintf("bind|%s|%s", b.ip, target)
+}
+
+func (b *bindConfig) open(target string) (tunnel, error) {
+	dial := func() (net.Conn, error) {
+		return bindDialer(b.ip, tunnelStartTimeout).Dial("tcp", target)
+	}
+	conn, err := dial()
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to %s from %s: %v", target, b.ip, err)
+	}
+	conn.Close()
+	return openRelayTunnel(dial)
+}
+
+// Traffic counts the bytes exchanged with a MySQL server
+type Traffic struct {
+	bytesIn  int64
+	bytesOut int64
+}
+
+// AddIn counts n bytes received from the server
+func (t *Traffic) AddIn(n int64) {
+	atomic.AddInt64(&t.bytesIn, n)
+}
+
+// AddOut counts n bytes sent to the server
+func (t *Traffic) AddOut(n int64) {
+	atomic.AddInt64(&t.bytesOut, n)
+}
+
+// BytesIn returns the bytes received from the server
+func (t *Traffic) BytesIn() int64 {
+	return atomic.LoadInt64(&t.bytesIn)
+}
+
+// BytesOut returns the bytes sent to the server
+func (t *Traffic) BytesOut() int64 {
+	return atomic.LoadInt64(&t.bytesOut)
+}
+
+// countingConn counts the bytes read and written on a connection
+type countingConn struct {
+	net.Conn
+	traffic *Traffic
+}
+
+func (c *countingConn) Read(b []byte) (int, error) {
+	n, err := c.Conn.Read(b)
+	c.traffic.AddIn(int64(n))
+	return n, err
+}
+
+func (c *countingConn) Write(b []byte) (int, error) {
+	n, err := c.Conn.Write(b)
+	c.traffic.AddOut(int64(n))
+	return n, err
+}
+
+var countingDials int64
+
+// CountTraffic has the connections of the MySQL driver made from the DSNs
+// of the config counted in the Traffic returned, which copies of the config
+// share. The binlog connection is not counted: its reader counts the events
+// it reads.
+func (c *ConnectionConfig) CountTraffic() *Traffic {
+	if c.traffic != nil {
+		return c.traffic
+	}
+	traffic := &Traffic{}
+	name := fmt.Sprintf("dtle-counted-%d", atomic.AddInt64(&countingDials, 1))
+	gomysqldriver.RegisterDial(name, func(addr string) (net.Conn, error) {
+		conn, err := net.DialTimeout("tcp", addr, dialTimeout)
+		if err != nil {
+			return nil, err
+		}
+		return &countingConn{Conn: conn, traffic: traffic}, nil
+	})
+	c.traffic = traffic
+	c.dialNet = name
+	return traffic
+}
+
+// Traffic returns the counter set by CountTraffic, nil if not counted
+func (c *ConnectionConfig) Traffic() *Traffic {
+	return c.traffic
+}
+
+// dialNetwork returns the network of the DSNs
+func (c *ConnectionConfig) dialNetwork() string {
+	if c.dialNet != "" {
+		return c.dialNet
+	}
+	return "tcp"
+}
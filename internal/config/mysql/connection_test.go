@@ -12,42 +12,21 @@ import (
 	test "github.com/outbrain/golib/tests"
 )
 
-func TestNewConnectionConfig(t *testing.T) {
-	c := NewConnectionConfig()
-	test.S(t).ExpectEquals(c.Key.Hostname, "")
-	test.S(t).ExpectEquals(c.Key.Port, 0)
-	test.S(t).ExpectEquals(c.ImpliedKey.Hostname, "")
-	test.S(t).ExpectEquals(c.ImpliedKey.Port, 0)
-	test.S(t).ExpectEquals(c.User, "")
-	test.S(t).ExpectEquals(c.Password, "")
-}
+func TestConnectionConfig_DialAddress(t *testing.T) {
+	c := &ConnectionConfig{Host: "myhost", Port: 3306}
+	test.S(t).ExpectEquals(c.DialAddress(), "myhost:3306")
 
-func TestDuplicateCredentials(t *testing.T) {
-	c := NewConnectionConfig()
-	c.Key = InstanceKey{Hostname: "myhost", Port: 3306}
-	c.User = "gromit"
-	c.Password = "penguin"
+	c = &ConnectionConfig{Host: "::1", Port: 3310}
+	test.S(t).ExpectEquals(c.DialAddress(), "[::1]:3310")
 
-	dup := c.DuplicateCredentials(InstanceKey{Hostname: "otherhost", Port: 3310})
-	test.S(t).ExpectEquals(dup.Key.Hostname, "otherhost")
-	test.S(t).ExpectEquals(dup.Key.Port, 3310)
-	test.S(t).ExpectEquals(dup.ImpliedKey.Hostname, "otherhost")
-	test.S(t).ExpectEquals(dup.ImpliedKey.Port, 3310)
-	test.S(t).ExpectEquals(dup.User, "gromit")
-	test.S(t).ExpectEquals(dup.Password, "penguin")
+	c.dialAddr = "127.0.0.1:40000"
+	test.S(t).ExpectEquals(c.DialAddress(), "127.0.0.1:40000")
 }
 
-func TestDuplicate(t *testing.T) {
-	c := NewConnectionConfig()
-	c.Key = InstanceKey{Hostname: "myhost", Port: 3306}
-	c.User = "gromit"
-	c.Password = "penguin"
-
-	dup := c.Duplicate()
-	test.S(t).ExpectEquals(dup.Key.Hostname, "myhost")
-	test.S(t).ExpectEquals(dup.Key.Port, 3306)
-	test.S(t).ExpectEquals(dup.ImpliedKey.Hostname, "myhost")
-	test.S(t).ExpectEquals(dup.ImpliedKey.Port, 3306)
-	test.S(t).ExpectEquals(dup.User, "gromit")
-	test.S(t).ExpectEquals(dup.Password, "penguin")
+func TestConnectionConfig_GetDBUri(t *testing.T) {
+	c := &ConnectionConfig{Host: "myhost", Port: 3306, User: "gromit", Password: "penguin"}
+	test.S(t).ExpectEquals(c.GetDBUri(),
+		"gromit:penguin@tcp(myhost:3306)/?timeout=5s&tls=false&autocommit=true&charset=utf8mb4&multiStatements=true&maxAllowedPacket=0")
+	test.S(t).ExpectEquals(c.GetDBUriByDbName("mydb"),
+		"gromit:penguin@tcp(myhost:3306)/mydb?charset=utf8mb4&tls=false&maxAllowedPacket=0")
 }
@@ -0,0 +1,146 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package mysql
+
+import (
+	"crypto/sha1"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"sync"
+
+	gomysqldriver "github.com/go-sql-driver/mysql"
+)
+
+const (
+	// TLSVerifyFull verifies the certificate of the server and its host name
+	TLSVerifyFull = "full"
+	// TLSVerifyCA verifies the certificate of the server but not its host
+	// name, for servers reached by an address their certificate lacks
+	TLSVerifyCA = "ca"
+	// TLSVerifyNone only encrypts the connection
+	TLSVerifyNone = "none"
+)
+
+// TLSConfig is the TLS configuration of a MySQL connection
+type TLSConfig struct {
+	// CAFile is the PEM file of the CAs the certificate of the server is
+	// verified against. The CAs of the system are used if empty.
+	CAFile string
+	// CertFile and KeyFile are the PEM files of the client certificate
+	CertFile string
+	KeyFile  string
+	// VerifyMode is "full" (the default), "ca" or "none"
+	VerifyMode string
+	// ServerName is the host name verified, defaults to the host
+	ServerName string
+}
+
+var (
+	tlsConfigs     = make(map[string]*tls.Config)
+	tlsConfigsLock sync.Mutex
+)
+
+// register builds the TLS config and registers it to the MySQL driver,
+// returning its name
+func (t *TLSConfig) register(host string) (string, error) {
+	serverName := t.ServerName
+	if serverName == "" {
+		serverName = host
+	}
+	sum := sha1.Sum([]byte(fmt.Sprintf("%s|%s|%s|%s|%s", t.CAFile, t.CertFile, t.KeyFile, t.VerifyMode, serverName)))
+	name := "dtle-" + hex.EncodeToString(sum[:8])
+
+	tlsConfigsLock.Lock()
+	defer tlsConfigsLock.Unlock()
+	if _, ok := tlsConfigs[name]; ok {
+		return name, nil
+	}
+	config, err := t.build(serverName)
+	if err != nil {
+		return "", err
+	}
+	if err := gomysqldriver.RegisterTLSConfig(name, config); err != nil {
+		return "", err
+	}
+	tlsConfigs[name] = config
+	return name, nil
+}
+
+func (t *TLSConfig) build(serverName string) (*tls.Config, error) {
+	config := &tls.Config{ServerName: serverName}
+	if t.CAFile != "" {
+		pem, err := ioutil.ReadFile(t.CAFile)
+		if err != nil {
+			return nil, err
+		}
+		config.RootCAs = x509.NewCertPool()
+		if !config.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificate found in %s", t.CAFile)
+		}
+	}
+	if t.CertFile != "" || t.KeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(t.CertFile, t.KeyFile)
+		if err != nil {
+			return nil, err
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+
+	switch t.VerifyMode {
+	case "", TLSVerifyFull:
+	case TLSVerifyCA:
+		// Verify the chain by hand, as the standard verification also
+		// checks the host name
+		roots := config.RootCAs
+		config.InsecureSkipVerify = true
+		config.VerifyPeerCertificate = func(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+			return verifyChain(rawCerts, roots)
+		}
+	case TLSVerifyNone:
+		config.InsecureSkipVerify = true
+	default:
+		return nil, fmt.Errorf("unknown TLS VerifyMode %q: must be %q, %q or %q", t.VerifyMode, TLSVerifyFull, TLSVerifyCA, TLSVerifyNone)
+	}
+	return config, nil
+}
+
+func verifyChain(rawCerts [][]byte, roots *x509.CertPool) error {
+	if len(rawCerts) == 0 {
+		return fmt.Errorf("the server sent no certificate")
+	}
+	certs := make([]*x509.Certificate, len(rawCerts))
+	for i, raw := range rawCerts {
+		cert, err := x509.ParseCertificate(raw)
+		if err != nil {
+			return err
+		}
+		certs[i] = cert
+	}
+	opts := x509.VerifyOptions{
+		Roots:         roots,
+		Intermediates: x509.NewCertPool(),
+	}
+	for _, cert := range certs[1:] {
+		opts.Intermediates.AddCert(cert)
+	}
+	_, err := certs[0].Verify(opts)
+	return err
+}
+
+// TLSClientConfig returns the TLS config of the connection set up by
+// Prepare, or nil if it is not encrypted
+func (c *ConnectionConfig) TLSClientConfig() *tls.Config {
+	if c.tlsName == "" {
+		return nil
+	}
+	tlsConfigsLock.Lock()
+	defer tlsConfigsLock.Unlock()
+	return tlsConfigs[c.tlsName]
+}
@@ -0,0 +1,395 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package mysql
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"os/exec"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// tunnelStartTimeout is how long a tunnel may take to accept connections
+const tunnelStartTimeout = 15 * time.Second
+
+// SSHTunnelConfig connects to MySQL through a port forwarded by an SSH
+// server. The ssh client of the system is used, authenticating with a
+// private key.
+type SSHTunnelConfig struct {
+	Host string
+	// Port defaults to 22
+	Port           int
+	User           string
+	PrivateKeyFile string
+	// KnownHostsFile holds the host key of the SSH server, defaults to the
+	// known_hosts of the user running the agent. Unknown host keys are
+	// rejected.
+	KnownHostsFile string
+
+	// bindIP is that of the BindAddress of the connection
+	bindIP string
+}
+
+// SocksProxyConfig connects to MySQL through a SOCKS5 proxy
+type SocksProxyConfig struct {
+	// Address is the "host:port" of the proxy
+	Address string
+	// User and Password authenticate to the proxy, if set
+	User     string
+	Password string
+
+	// bindIP is that of the BindAddress of the connection
+	bindIP string
+}
+
+// tunnel is an open tunnel, whose local address connects to the target
+type tunnel interface {
+	localAddr() string
+	alive() bool
+}
+
+// tunnelConfig opens tunnels to targets
+type tunnelConfig interface {
+	key(target string) string
+	open(target string) (tunnel, error)
+}
+
+var (
+	tunnels     = make(map[string]tunnel)
+	tunnelsLock sync.Mutex
+)
+
+// forward returns the local address of a tunnel to the target, opening the
+// tunnel unless an open one exists
+func forward(target string, config tunnelConfig) (string, error) {
+	key := config.key(target)
+
+	tunnelsLock.Lock()
+	defer tunnelsLock.Unlock()
+	if t, ok := tunnels[key]; ok && t.alive() {
+		return t.localAddr(), nil
+	}
+	t, err := config.open(target)
+	if err != nil {
+		return "", err
+	}
+	tunnels[key] = t
+	return t.localAddr(), nil
+}
+
+func (s *SSHTunnelConfig) key(target string) string {
+	return fmt.Sprintf("ssh|%s@%s:%d|%s|%s|%s|%s", s.User, s.Host, s.Port, s.PrivateKeyFile, s.KnownHostsFile, s.bindIP, target)
+}
+
+type sshTunnel struct {
+	addr string
+	cmd  *exec.Cmd
+	done chan struct{}
+}
+
+func (s *SSHTunnelConfig) open(target string) (tunnel, error) {
+	if s.Host == "" || s.User == "" {
+		return nil, fmt.Errorf("SSHTunnel needs a Host and a User")
+	}
+	port := s.Port
+	if port == 0 {
+		port = 22
+	}
+	local, err := freeLocalAddr()
+	if err != nil {
+		return nil, err
+	}
+
+	args := []string{
+		"-N",
+		"-o", "BatchMode=yes",
+		"-o", "ExitOnForwardFailure=yes",
+		"-o", "StrictHostKeyChecking=yes",
+		"-o", "ServerAliveInterval=10",
+		"-p", strconv.Itoa(port),
+		"-L", fmt.Sprintf("%s:%s", local, target),
+	}
+	if s.PrivateKeyFile != "" {
+		args = append(args, "-i", s.PrivateKeyFile)
+	}
+	if s.KnownHostsFile != "" {
+		args = append(args, "-o", "UserKnownHostsFile="+s.KnownHostsFile)
+	}
+	if s.bindIP != "" {
+		args = append(args, "-b", s.bindIP)
+	}
+	args = append(args, fmt.Sprintf("%s@%s", s.User, s.Host))
+
+	t := &sshTunnel{
+		addr: local,
+		cmd:  exec.Command("ssh", args...),
+		done: make(chan struct{}),
+	}
+	var stderr bytes.Buffer
+	t.cmd.Stderr = &stderr
+	if err := t.cmd.Start(); err != nil {
+		return nil, fmt.Errorf("failed to start the SSH tunnel: %v", err)
+	}
+	go func() {
+		t.cmd.Wait()
+		close(t.done)
+	}()
+
+	// Wait for the forwarded port to accept connections
+	deadline := time.Now().Add(tunnelStartTimeout)
+	for {
+		select {
+		case <-t.done:
+			return nil, fmt.Errorf("the SSH tunnel to %s@%s:%d exited: %s", s.User, s.Host, port, bytes.TrimSpace(stderr.Bytes()))
+		default:
+		}
+		if conn, err := net.DialTimeout("tcp", local, time.Second); err == nil {
+			conn.Close()
+			return t, nil
+		}
+		if time.Now().After(deadline) {
+			t.cmd.Process.Kill()
+			return nil, fmt.Errorf("the SSH tunnel to %s@%s:%d did not open within %v", s.User, s.Host, port, tunnelStartTimeout)
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+}
+
+func (t *sshTunnel) localAddr() string {
+	return t.addr
+}
+
+func (t *sshTunnel) alive() bool {
+	select {
+	case <-t.done:
+		return false
+	default:
+		return true
+	}
+}
+
+// freeLocalAddr returns a loopback address with a free port
+func freeLocalAddr() (string, error) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return "", err
+	}
+	defer l.Close()
+	return l.Addr().String(), nil
+}
+
+func (p *SocksProxyConfig) key(target string) string {
+	return fmt.Sprintf("socks5|%s@%s|%s|%s", p.User, p.Address, p.bindIP, target)
+}
+
+// relayTunnel accepts local connections and relays them to those it dials
+type relayTunnel struct {
+	listener net.Listener
+	dial     func() (net.Conn, error)
+	closed   chan struct{}
+}
+
+func (p *SocksProxyConfig) open(target string) (tunnel, error) {
+	if p.Address == "" {
+		return nil, fmt.Errorf("SocksProxy needs an Address")
+	}
+	// Check the proxy before handing out the tunnel
+	conn, err := p.dial(target)
+	if err != nil {
+		return nil, err
+	}
+	conn.Close()
+
+	return openRelayTunnel(func() (net.Conn, error) {
+		return p.dial(target)
+	})
+}
+
+func openRelayTunnel(dial func() (net.Conn, error)) (*relayTunnel, error) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+	t := &relayTunnel{
+		listener: l,
+		dial:     dial,
+		closed:   make(chan struct{}),
+	}
+	go t.serve()
+	return t, nil
+}
+
+func (t *relayTunnel) serve() {
+	defer close(t.closed)
+	for {
+		local, err := t.listener.Accept()
+		if err != nil {
+			return
+		}
+		go func() {
+			remote, err := t.dial()
+			if err != nil {
+				local.Close()
+				return
+			}
+			relay(local, remote)
+		}()
+	}
+}
+
+func (t *relayTunnel) localAddr() string {
+	return t.listener.Addr().String()
+}
+
+func (t *relayTunnel) alive() bool {
+	select {
+	case <-t.closed:
+		return false
+	default:
+		return true
+	}
+}
+
+// relay copies between two connections until either is closed
+func relay(a, b net.Conn) {
+	done := make(chan struct{}, 2)
+	cp := func(dst, src net.Conn) {
+		io.Copy(dst, src)
+		done <- struct{}{}
+	}
+	go cp(a, b)
+	go cp(b, a)
+	<-done
+	a.Close()
+	b.Close()
+}
+
+// dial connects to the target through the proxy, following RFC 1928 and
+// RFC 1929 for the user and password
+func (p *SocksProxyConfig) dial(target string) (net.Conn, error) {
+	host, portStr, err := net.SplitHostPort(target)
+	if err != nil {
+		return nil, err
+	}
+	port, err := strconv.Atoi(portStr)
+	if err != nil {
+		return nil, err
+	}
+	if len(host) > 255 {
+		return nil, fmt.Errorf("host name too long: %s", host)
+	}
+
+	conn, err := bindDialer(p.bindIP, tunnelStartTimeout).Dial("tcp", p.Address)
+	if err != nil {
+		return nil, err
+	}
+	conn.SetDeadline(time.Now().Add(tunnelStartTimeout))
+	if err := p.handshake(conn, host, port); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("SOCKS5 proxy %s: %v", p.Address, err)
+	}
+	conn.SetDeadline(time.Time{})
+	return conn, nil
+}
+
+func (p *SocksProxyConfig) handshake(conn net.Conn, host string, port int) error {
+	const (
+		socksVersion     = 5
+		authNone         = 0
+		authPassword     = 2
+		authNoAcceptable = 0xff
+		cmdConnect       = 1
+		addrIPv4         = 1
+		addrDomain       = 3
+		addrIPv6         = 4
+	)
+
+	methods := []byte{authNone}
+	if p.User != "" {
+		methods = append(methods, authPassword)
+	}
+	if _, err := conn.Write(append([]byte{socksVersion, byte(len(methods))}, methods...)); err != nil {
+		return err
+	}
+	reply := make([]byte, 2)
+	if _, err := io.ReadFull(conn, reply); err != nil {
+		return err
+	}
+	switch reply[1] {
+	case authNone:
+	case authPassword:
+		if len(p.User) > 255 || len(p.Password) > 255 {
+			return fmt.Errorf("user or password too long")
+		}
+		req := []byte{1, byte(len(p.User))}
+		req = append(req, p.User...)
+		req = append(req, byte(len(p.Password)))
+		req = append(req, p.Password...)
+		if _, err := conn.Write(req); err != nil {
+			return err
+		}
+		if _, err := io.ReadFull(conn, reply); err != nil {
+			return err
+		}
+		if reply[1] != 0 {
+			return fmt.Errorf("authentication failed")
+		}
+	case authNoAcceptable:
+		return fmt.Errorf("no acceptable authentication method")
+	default:
+		return fmt.Errorf("unexpected authentication method %d", reply[1])
+	}
+
+	req := []byte{socksVersion, cmdConnect, 0}
+	if ip := net.ParseIP(host); ip != nil && ip.To4() != nil {
+		req = append(req, addrIPv4)
+		req = append(req, ip.To4()...)
+	} else if ip != nil {
+		req = append(req, addrIPv6)
+		req = append(req, ip.To16()...)
+	} else {
+		req = append(req, addrDomain, byte(len(host)))
+		req = append(req, host...)
+	}
+	portBytes := make([]byte, 2)
+	binary.BigEndian.PutUint16(portBytes, uint16(port))
+	req = append(req, portBytes...)
+	if _, err := conn.Write(req); err != nil {
+		return err
+	}
+
+	head := make([]byte, 4)
+	if _, err := io.ReadFull(conn, head); err != nil {
+		return err
+	}
+	if head[1] != 0 {
+		return fmt.Errorf("connect to %s:%d failed with code %d", host, port, head[1])
+	}
+	var skip int
+	switch head[3] {
+	case addrIPv4:
+		skip = net.IPv4len
+	case addrIPv6:
+		skip = net.IPv6len
+	case addrDomain:
+		l := make([]byte, 1)
+		if _, err := io.ReadFull(conn, l); err != nil {
+			return err
+		}
+		skip = int(l[0])
+	default:
+		return fmt.Errorf("unexpected address type %d", head[3])
+	}
+	// the bound address and port
+	_, err := io.ReadFull(conn, make([]byte, skip+2))
+	return err
+}
@@ -0,0 +1,258 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package mysql
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// The operations of a JSONDiff, as the JSON functions of MySQL
+const (
+	// JSONDiffReplace replaces the value at the path, as JSON_REPLACE
+	JSONDiffReplace = "replace"
+	// JSONDiffInsert inserts the value at the path, as JSON_ARRAY_INSERT for
+	// an array element and JSON_INSERT for an object member
+	JSONDiffInsert = "insert"
+	// JSONDiffRemove removes the value at the path, as JSON_REMOVE
+	JSONDiffRemove = "remove"
+)
+
+// JSONDiff is a change of a JSON document, logged by a partial update of a
+// JSON column
+type JSONDiff struct {
+	Op   string `json:"op"`
+	Path string `json:"path"`
+	// Value is the JSON text of the new value, empty for a removal
+	Value json.RawMessage `json:"value,omitempty"`
+}
+
+// JSONPatch is the value of a JSON column partially updated: the changes to
+// apply to its previous value, in order
+type JSONPatch []JSONDiff
+
+func init() {
+	// JSONPatch travels in the column values of binlog entries
+	gob.Register(JSONPatch{})
+}
+
+// String returns the JSON text of the patch
+func (p JSONPatch) String() string {
+	bs, err := json.Marshal(p)
+	if err != nil {
+		return fmt.Sprintf("%v", []JSONDiff(p))
+	}
+	return string(bs)
+}
+
+// SQLExpression returns the expression applying the patch to a column, and
+// its arguments
+func (p JSONPatch) SQLExpression(column string) (string, []interface{}) {
+	expr := column
+	var args []interface{}
+	for _, d := range p {
+		switch d.Op {
+		case JSONDiffReplace:
+			expr = fmt.Sprintf("JSON_REPLACE(%s, ?, CAST(? AS JSON))", expr)
+			args = append(args, d.Path, string(d.Value))
+		case JSONDiffInsert:
+			if strings.HasSuffix(d.Path, "]") {
+				expr = fmt.Sprintf("JSON_ARRAY_INSERT(%s, ?, CAST(? AS JSON))", expr)
+			} else {
+				expr = fmt.Sprintf("JSON_INSERT(%s, ?, CAST(? AS JSON))", expr)
+			}
+			args = append(args, d.Path, string(d.Value))
+		case JSONDiffRemove:
+			expr = fmt.Sprintf("JSON_REMOVE(%s, ?)", expr)
+			args = append(args, d.Path)
+		}
+	}
+	return expr, args
+}
+
+// Apply applies the patch to a JSON document and returns the result
+func (p JSONPatch) Apply(doc string) (string, error) {
+	value, err := decodeJSON([]byte(doc))
+	if err != nil {
+		return "", err
+	}
+	for _, d := range p {
+		legs, err := parseJSONPath(d.Path)
+		if err != nil {
+			return "", err
+		}
+		var newValue interface{}
+		if d.Op != JSONDiffRemove {
+			if newValue, err = decodeJSON(d.Value); err != nil {
+				return "", err
+			}
+		}
+		if value, err = applyJSONDiff(value, legs, d.Op, newValue); err != nil {
+			return "", fmt.Errorf("%s at %s: %v", d.Op, d.Path, err)
+		}
+	}
+
+	buf := &bytes.Buffer{}
+	enc := json.NewEncoder(buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(value); err != nil {
+		return "", err
+	}
+	return strings.TrimSuffix(buf.String(), "\n"), nil
+}
+
+// decodeJSON decodes a JSON text, keeping numbers as they are written
+func decodeJSON(bs []byte) (interface{}, error) {
+	dec := json.NewDecoder(bytes.NewReader(bs))
+	dec.UseNumber()
+	var v interface{}
+	if err := dec.Decode(&v); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// jsonPathLeg is a step of a JSON path: an object member or an array index
+type jsonPathLeg struct {
+	member  string
+	index   int
+	isIndex bool
+}
+
+// parseJSONPath parses the paths of JSON diffs, such as `$.a[2]."b c"`
+func parseJSONPath(path string) ([]jsonPathLeg, error) {
+	if !strings.HasPrefix(path, "$") {
+		return nil, fmt.Errorf("invalid JSON path %q", path)
+	}
+	var legs []jsonPathLeg
+	rest := path[1:]
+	for len(rest) > 0 {
+		switch rest[0] {
+		case '.':
+			rest = rest[1:]
+			if strings.HasPrefix(rest, `"`) {
+				end := 1
+				for end < len(rest) && rest[end] != '"' {
+					if rest[end] == '\\' {
+						end++
+					}
+					end++
+				}
+				if end >= len(rest) {
+					return nil, fmt.Errorf("invalid JSON path %q", path)
+				}
+				member, err := strconv.Unquote(rest[:end+1])
+				if err != nil {
+					return nil, fmt.Errorf("invalid JSON path %q: %v", path, err)
+				}
+				legs = append(legs, jsonPathLeg{member: member})
+				rest = rest[end+1:]
+			} else {
+				end := strings.IndexAny(rest, ".[")
+				if end < 0 {
+					end = len(rest)
+				}
+				if end == 0 {
+					return nil, fmt.Errorf("invalid JSON path %q", path)
+				}
+				legs = append(legs, jsonPathLeg{member: rest[:end]})
+				rest = rest[end:]
+			}
+		case '[':
+			end := strings.IndexByte(rest, ']')
+			if end < 0 {
+				return nil, fmt.Errorf("invalid JSON path %q", path)
+			}
+			index, err := strconv.Atoi(strings.TrimSpace(rest[1:end]))
+			if err != nil || index < 0 {
+				return nil, fmt.Errorf("invalid array index in JSON path %q", path)
+			}
+			legs = append(legs, jsonPathLeg{index: index, isIndex: true})
+			rest = rest[end+1:]
+		default:
+			return nil, fmt.Errorf("invalid JSON path %q", path)
+		}
+	}
+	return legs, nil
+}
+
+// applyJSONDiff applies an operation at a path of a decoded JSON document
+func applyJSONDiff(doc interface{}, legs []jsonPathLeg, op string, value interface{}) (interface{}, error) {
+	if len(legs) == 0 {
+		if op != JSONDiffReplace {
+			return nil, fmt.Errorf("can not %s the whole document", op)
+		}
+		return value, nil
+	}
+	leg := legs[0]
+
+	switch container := doc.(type) {
+	case map[string]interface{}:
+		if leg.isIndex {
+			return nil, fmt.Errorf("array index on an object")
+		}
+		if len(legs) > 1 {
+			child, ok := container[leg.member]
+			if !ok {
+				return nil, fmt.Errorf("no member %q", leg.member)
+			}
+			newChild, err := applyJSONDiff(child, legs[1:], op, value)
+			if err != nil {
+				return nil, err
+			}
+			container[leg.member] = newChild
+			return container, nil
+		}
+		switch op {
+		case JSONDiffReplace, JSONDiffInsert:
+			container[leg.member] = value
+		case JSONDiffRemove:
+			delete(container, leg.member)
+		}
+		return container, nil
+
+	case []interface{}:
+		if !leg.isIndex {
+			return nil, fmt.Errorf("member %q of an array", leg.member)
+		}
+		if len(legs) > 1 || op == JSONDiffReplace || op == JSONDiffRemove {
+			if leg.index >= len(container) {
+				return nil, fmt.Errorf("index %d out of %d elements", leg.index, len(container))
+			}
+		}
+		if len(legs) > 1 {
+			newChild, err := applyJSONDiff(container[leg.index], legs[1:], op, value)
+			if err != nil {
+				return nil, err
+			}
+			container[leg.index] = newChild
+			return container, nil
+		}
+		switch op {
+		case JSONDiffReplace:
+			container[leg.index] = value
+		case JSONDiffInsert:
+			index := leg.index
+			if index > len(container) {
+				index = len(container)
+			}
+			container = append(container, nil)
+			copy(container[index+1:], container[index:])
+			container[index] = value
+		case JSONDiffRemove:
+			container = append(container[:leg.index], container[leg.index+1:]...)
+		}
+		return container, nil
+
+	default:
+		return nil, fmt.Errorf("path goes through a scalar")
+	}
+}
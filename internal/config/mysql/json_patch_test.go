@@ -0,0 +1,100 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package mysql
+
+import (
+	"reflect"
+	"testing"
+
+	test "github.com/outbrain/golib/tests"
+)
+
+func TestParseJSONPath(t *testing.T) {
+	{
+		legs, err := parseJSONPath(`$.a[2]."b c".d`)
+		test.S(t).ExpectNil(err)
+		test.S(t).ExpectTrue(reflect.DeepEqual(legs, []jsonPathLeg{
+			{member: "a"}, {index: 2, isIndex: true}, {member: "b c"}, {member: "d"},
+		}))
+	}
+	{
+		// escaped quotes and backslashes in a quoted member
+		legs, err := parseJSONPath(`$."a\"b\\c"[0]`)
+		test.S(t).ExpectNil(err)
+		test.S(t).ExpectTrue(reflect.DeepEqual(legs, []jsonPathLeg{
+			{member: `a"b\c`}, {index: 0, isIndex: true},
+		}))
+	}
+	{
+		legs, err := parseJSONPath(`$`)
+		test.S(t).ExpectNil(err)
+		test.S(t).ExpectEquals(len(legs), 0)
+	}
+	for _, path := range []string{`a.b`, `$.`, `$.a[`, `$.a[-1]`, `$.a[x]`, `$."a`, `$a`} {
+		_, err := parseJSONPath(path)
+		test.S(t).ExpectNotNil(err)
+	}
+}
+
+func TestJSONPatch_Apply(t *testing.T) {
+	for _, tt := range []struct {
+		doc   string
+		patch JSONPatch
+		want  string
+	}{
+		{`{"a":1,"b":[1,2]}`, JSONPatch{{Op: JSONDiffReplace, Path: `$.a`, Value: []byte(`"x"`)}}, `{"a":"x","b":[1,2]}`},
+		{`{"a":1}`, JSONPatch{{Op: JSONDiffInsert, Path: `$."b c"`, Value: []byte(`{"d":null}`)}}, `{"a":1,"b c":{"d":null}}`},
+		{`{"a\"b":1}`, JSONPatch{{Op: JSONDiffRemove, Path: `$."a\"b"`}}, `{}`},
+		{`[1,2,3]`, JSONPatch{{Op: JSONDiffInsert, Path: `$[1]`, Value: []byte(`9`)}}, `[1,9,2,3]`},
+		// an insert past the end of an array appends, as JSON_ARRAY_INSERT
+		{`[1,2]`, JSONPatch{{Op: JSONDiffInsert, Path: `$[5]`, Value: []byte(`3`)}}, `[1,2,3]`},
+		{`{"a":[{"b":1},{"b":2}]}`, JSONPatch{{Op: JSONDiffRemove, Path: `$.a[0]`}}, `{"a":[{"b":2}]}`},
+		// the diffs apply in order, and numbers keep their text
+		{`{"a":[1.50]}`, JSONPatch{
+			{Op: JSONDiffInsert, Path: `$.a[0]`, Value: []byte(`12345678901234567890`)},
+			{Op: JSONDiffReplace, Path: `$.a[1]`, Value: []byte(`"<&>"`)},
+		}, `{"a":[12345678901234567890,"<&>"]}`},
+		{`{"a":1}`, JSONPatch{{Op: JSONDiffReplace, Path: `$`, Value: []byte(`[]`)}}, `[]`},
+	} {
+		got, err := tt.patch.Apply(tt.doc)
+		test.S(t).ExpectNil(err)
+		test.S(t).ExpectEquals(got, tt.want)
+	}
+
+	for _, tt := range []struct {
+		doc   string
+		patch JSONPatch
+	}{
+		// a remove or a replace out of the array errors
+		{`[1,2]`, JSONPatch{{Op: JSONDiffRemove, Path: `$[2]`}}},
+		{`[1,2]`, JSONPatch{{Op: JSONDiffReplace, Path: `$[2]`, Value: []byte(`3`)}}},
+		{`{"a":[]}`, JSONPatch{{Op: JSONDiffInsert, Path: `$.a[1].b`, Value: []byte(`3`)}}},
+		{`{"a":1}`, JSONPatch{{Op: JSONDiffReplace, Path: `$.b.c`, Value: []byte(`3`)}}},
+		{`{"a":1}`, JSONPatch{{Op: JSONDiffReplace, Path: `$[0]`, Value: []byte(`3`)}}},
+		{`[1]`, JSONPatch{{Op: JSONDiffReplace, Path: `$.a`, Value: []byte(`3`)}}},
+		{`{"a":1}`, JSONPatch{{Op: JSONDiffReplace, Path: `$.a.b`, Value: []byte(`3`)}}},
+		{`{"a":1}`, JSONPatch{{Op: JSONDiffRemove, Path: `$`}}},
+		{`{"a":1}`, JSONPatch{{Op: JSONDiffReplace, Path: `$.a`, Value: []byte(`{`)}}},
+		{`{`, JSONPatch{}},
+	} {
+		_, err := tt.patch.Apply(tt.doc)
+		test.S(t).ExpectNotNil(err)
+	}
+}
+
+func TestJSONPatch_SQLExpression(t *testing.T) {
+	patch := JSONPatch{
+		{Op: JSONDiffReplace, Path: `$.a`, Value: []byte(`1`)},
+		{Op: JSONDiffInsert, Path: `$.b[0]`, Value: []byte(`"x"`)},
+		{Op: JSONDiffInsert, Path: `$.c`, Value: []byte(`[]`)},
+		{Op: JSONDiffRemove, Path: `$.d`},
+	}
+	expr, args := patch.SQLExpression("`doc`")
+	test.S(t).ExpectEquals(expr, "JSON_REMOVE(JSON_INSERT(JSON_ARRAY_INSERT(JSON_REPLACE(`doc`, ?, CAST(? AS JSON)), "+
+		"?, CAST(? AS JSON)), ?, CAST(? AS JSON)), ?)")
+	test.S(t).ExpectTrue(reflect.DeepEqual(args, []interface{}{`$.a`, `1`, `$.b[0]`, `"x"`, `$.c`, `[]`, `$.d`}))
+}
@@ -69,6 +69,9 @@ type Column struct {
 	Nullable           bool
 	Precision          int // for decimal, time or datetime
 	Scale              int // for decimal
+	// IsGenerated is set for virtual and stored generated columns, whose
+	// values can not be written
+	IsGenerated bool
 	// somehow ugly. A better solution might be MetaInfo with subtypes
 }
 
@@ -138,6 +138,27 @@ type ServerConfig struct {
 	// This period is meant to be long enough for a leader election to take
 	// place, and a small jitter is applied to avoid a thundering herd.
 	RPCHoldTimeout time.Duration
+
+	// CleanupDeadServers enables the leader to remove servers that have
+	// been failed for longer than DeadServerThreshold from the Raft
+	// configuration, as long as the remaining servers keep a quorum.
+	CleanupDeadServers bool
+
+	// DeadServerThreshold is how long a server must be failed before it
+	// is removed by CleanupDeadServers.
+	DeadServerThreshold time.Duration
+
+	// AutopilotInterval is how often the leader checks the health of the
+	// servers for CleanupDeadServers.
+	AutopilotInterval time.Duration
+
+	// Webhooks are the endpoints the leader notifies of job events
+	Webhooks []*WebhookConfig
+
+	// BinlogRetentionInterval is how often the leader checks that the
+	// sources of the jobs still have the binlogs after their checkpoints.
+	// 0 disables the checks.
+	BinlogRetentionInterval time.Duration
 }
 
 // DefaultConfig returns the default configuration
@@ -166,6 +187,10 @@ func DefaultServerConfig() *ServerConfig {
 		FailoverHeartbeatTTL:   300 * time.Second,
 		ConsulConfig:           DefaultConsulConfig(),
 		RPCHoldTimeout:         5 * time.Second,
+		DeadServerThreshold:    10 * time.Minute,
+		AutopilotInterval:      10 * time.Second,
+
+		BinlogRetentionInterval: 5 * time.Minute,
 	}
 
 	// Enable all known schedulers by default
@@ -0,0 +1,76 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package config
+
+import (
+	"fmt"
+	"net/url"
+	"time"
+)
+
+const (
+	// WebhookFormatJSON posts the job event as a JSON object
+	WebhookFormatJSON = "json"
+
+	// WebhookFormatSlack posts a Slack incoming webhook message
+	WebhookFormatSlack = "slack"
+
+	// WebhookFormatDingTalk posts a DingTalk robot text message
+	WebhookFormatDingTalk = "dingtalk"
+)
+
+// WebhookConfig configures an HTTP endpoint the leader notifies of job
+// events.
+type WebhookConfig struct {
+	// URL is the endpoint the events are posted to
+	URL string `mapstructure:"url"`
+
+	// Events are the job event types to notify, such as "error" or
+	// "snapshot-finished". Job failures, finished snapshots, pauses and
+	// warnings are notified when empty.
+	Events []string `mapstructure:"events"`
+
+	// Format is the body format, one of json, slack or dingtalk. The
+	// default is json.
+	Format string `mapstructure:"format"`
+
+	// Secret signs the requests when set. JSON and Slack requests carry an
+	// HMAC-SHA256 of the body in the X-Dtle-Signature header, DingTalk
+	// requests are signed the way DingTalk robots expect.
+	Secret string `mapstructure:"secret"`
+
+	// MaxRetries is how many times a failed delivery is retried, with an
+	// exponential backoff. The default is 3.
+	MaxRetries int `mapstructure:"max_retries"`
+
+	// Timeout bounds each delivery attempt. The default is 10s.
+	Timeout time.Duration `mapstructure:"timeout"`
+}
+
+// Validate returns an error if the webhook config is invalid
+func (c *WebhookConfig) Validate() error {
+	u, err := url.Parse(c.URL)
+	if err != nil {
+		return fmt.Errorf("invalid webhook url %q: %v", c.URL, err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("invalid webhook url %q: scheme must be http or https", c.URL)
+	}
+	switch c.Format {
+	case "", WebhookFormatJSON, WebhookFormatSlack, WebhookFormatDingTalk:
+	default:
+		return fmt.Errorf("invalid webhook format %q: must be one of %s, %s or %s",
+			c.Format, WebhookFormatJSON, WebhookFormatSlack, WebhookFormatDingTalk)
+	}
+	if c.MaxRetries < 0 {
+		return fmt.Errorf("webhook max_retries must not be negative")
+	}
+	if c.Timeout < 0 {
+		return fmt.Errorf("webhook timeout must not be negative")
+	}
+	return nil
+}
@@ -11,6 +11,8 @@ const (
 	GtidExecutedTablePrefix     string = "gtid_executed_"
 	GtidExecutedTableV2         string = "gtid_executed_v2"
 	GtidExecutedTableV3         string = "gtid_executed_v3"
+	SkippedDDLTableV1           string = "skipped_ddl_v1"
+	DDLHistoryTableV1           string = "ddl_history_v1"
 
 	ENV_PRINT_TPS         = "UDUP_PRINT_TPS"
 	ENV_DUMP_CHECKSUM     = "DTLE_DUMP_CHECKSUM"
@@ -18,4 +20,6 @@ const (
 	ENV_TESTSTUB1_DELAY   = "UDUP_TESTSTUB1_DELAY"
 	ENV_FULL_APPLY_DELAY  = "DTLE_FULL_APPLY_DELAY"
 	ENV_COUNT_INFO_SCHEMA = "DTLE_COUNT_INFO_SCHEMA"
+	// ENV_FAULT_INJECTION allows the FaultInjection of the jobs on a node
+	ENV_FAULT_INJECTION = "DTLE_FAULT_INJECTION"
 )
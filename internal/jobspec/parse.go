@@ -0,0 +1,825 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+// Package jobspec parses job specifications written in HCL.
+package jobspec
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+
+	"github.com/hashicorp/go-multierror"
+	"github.com/hashicorp/hcl"
+	"github.com/hashicorp/hcl/hcl/ast"
+	"github.com/mitchellh/mapstructure"
+
+	"github.com/actiontech/dtle/api"
+	"github.com/actiontech/dtle/internal"
+	"github.com/actiontech/dtle/internal/client/driver/kafka3"
+	"github.com/actiontech/dtle/internal/client/driver/mongodb"
+	"github.com/actiontech/dtle/internal/client/driver/oracle"
+	"github.com/actiontech/dtle/internal/client/driver/polling"
+	"github.com/actiontech/dtle/internal/client/driver/script"
+	"github.com/actiontech/dtle/internal/client/driver/sqlserver"
+	uconf "github.com/actiontech/dtle/internal/config"
+	"github.com/actiontech/dtle/internal/models"
+)
+
+// Parse parses the job spec from the given io.Reader.
+//
+// Due to current internal limitations, the entire contents of the
+// io.Reader will be copied into memory first before parsing.
+func Parse(r io.Reader) (*api.Job, error) {
+	// Copy the reader into an in-memory buffer first since HCL requires it.
+	var buf bytes.Buffer
+	if _, err := io.Copy(&buf, r); err != nil {
+		return nil, err
+	}
+
+	// Parse the buffer
+	root, err := hcl.Parse(buf.String())
+	if err != nil {
+		return nil, fmt.Errorf("error parsing: %s", err)
+	}
+	buf.Reset()
+
+	// Top-level item should be a list
+	list, ok := root.Node.(*ast.ObjectList)
+	if !ok {
+		return nil, fmt.Errorf("error parsing: root should be an object")
+	}
+
+	// Check for invalid keys
+	valid := []string{
+		"job",
+	}
+	if err := checkHCLKeys(list, valid); err != nil {
+		return nil, err
+	}
+
+	var job api.Job
+
+	// Parse the job out
+	matches := list.Filter("job")
+	if len(matches.Items) == 0 {
+		return nil, fmt.Errorf("'job' stanza not found")
+	}
+
+	if err := parseJob(&job, matches); err != nil {
+		return nil, fmt.Errorf("error parsing 'job': %s", err)
+	}
+
+	return &job, nil
+}
+
+// ParseFile parses the given path as a job spec.
+func ParseFile(path string) (*api.Job, error) {
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return Parse(f)
+}
+
+func parseJob(result *api.Job, list *ast.ObjectList) error {
+	if len(list.Items) != 1 {
+		return fmt.Errorf("only one 'job' block allowed")
+	}
+	list = list.Children()
+	if len(list.Items) != 1 {
+		return fmt.Errorf("'job' block missing name")
+	}
+
+	// Get our job object
+	obj := list.Items[0]
+
+	// Decode the full thing into a map[string]interface for ease
+	var m map[string]interface{}
+	if err := hcl.DecodeObject(&m, obj.Val); err != nil {
+		return err
+	}
+	for _, block := range []string{"labels", "restart", "reschedule", "alert", "task", "source", "target", "tables", "transforms"} {
+		delete(m, block)
+	}
+
+	// Set the ID and name to the object key
+	result.ID = internal.StringToPtr(obj.Keys[0].Token.Value().(string))
+	result.Name = internal.StringToPtr(*result.ID)
+
+	// Decode the rest
+	if err := mapstructure.WeakDecode(m, result); err != nil {
+		return err
+	}
+
+	// Value should be an object
+	var listVal *ast.ObjectList
+	if ot, ok := obj.Val.(*ast.ObjectType); ok {
+		listVal = ot.List
+	} else {
+		return fmt.Errorf("job '%s' value: should be an object", *result.ID)
+	}
+
+	// Check for invalid keys
+	valid := []string{
+		"region",
+		"namespace",
+		"datacenters",
+		"name",
+		"labels",
+		"task",
+		"type",
+		"failover",
+		"restart",
+		"reschedule",
+		"alert",
+		"source",
+		"target",
+		"tables",
+		"transforms",
+	}
+	if err := checkHCLKeys(listVal, valid); err != nil {
+		return multierror.Prefix(err, "job:")
+	}
+
+	// Parse the labels
+	if o := listVal.Filter("labels"); len(o.Items) > 0 {
+		if len(o.Items) > 1 {
+			return fmt.Errorf("only one 'labels' block allowed")
+		}
+		if err := hcl.DecodeObject(&result.Labels, o.Items[0].Val); err != nil {
+			return multierror.Prefix(err, "labels ->")
+		}
+	}
+
+	// Parse the restart, reschedule and alert policies
+	if o := listVal.Filter("restart"); len(o.Items) > 0 {
+		result.Restart = &api.RestartPolicy{}
+		if err := parsePolicy(result.Restart, o, []string{
+			"attempts", "interval", "delay", "max_delay", "mode"}); err != nil {
+			return multierror.Prefix(err, "restart ->")
+		}
+	}
+	if o := listVal.Filter("reschedule"); len(o.Items) > 0 {
+		result.Reschedule = &api.ReschedulePolicy{}
+		if err := parsePolicy(result.Reschedule, o, []string{
+			"attempts", "interval", "delay", "max_delay", "on_failure", "on_lost_node"}); err != nil {
+			return multierror.Prefix(err, "reschedule ->")
+		}
+	}
+	if o := listVal.Filter("alert"); len(o.Items) > 0 {
+		result.Alert = &api.AlertPolicy{}
+		if err := parsePolicy(result.Alert, o, []string{
+			"max_seconds_behind", "max_errors_per_minute"}); err != nil {
+			return multierror.Prefix(err, "alert ->")
+		}
+	}
+
+	// Parse the task groups
+	if o := listVal.Filter("task"); len(o.Items) > 0 {
+		if err := parseTasks(result, o); err != nil {
+			return multierror.Prefix(err, "task:")
+		}
+	}
+
+	// Parse the source and target, which are the Src and Dest tasks
+	if o := listVal.Filter("source"); len(o.Items) > 0 {
+		if err := parseEndpoint(result, models.TaskTypeSrc, o); err != nil {
+			return multierror.Prefix(err, "source ->")
+		}
+	}
+	if o := listVal.Filter("target"); len(o.Items) > 0 {
+		if err := parseEndpoint(result, models.TaskTypeDest, o); err != nil {
+			return multierror.Prefix(err, "target ->")
+		}
+	}
+
+	// Parse the tables and transforms into the config of the Src task
+	if o := listVal.Filter("tables"); len(o.Items) > 0 {
+		if err := parseTables(result, o); err != nil {
+			return multierror.Prefix(err, "tables ->")
+		}
+	}
+	if o := listVal.Filter("transforms"); len(o.Items) > 0 {
+		if err := parseTransforms(result, o); err != nil {
+			return multierror.Prefix(err, "transforms ->")
+		}
+	}
+
+	return nil
+}
+
+// parsePolicy decodes a single policy block into result. Durations may be
+// given as strings such as "30s".
+func parsePolicy(result interface{}, list *ast.ObjectList, valid []string) error {
+	if len(list.Items) > 1 {
+		return fmt.Errorf("only one block allowed")
+	}
+	obj := list.Items[0]
+	if err := checkHCLKeys(obj.Val, valid); err != nil {
+		return err
+	}
+
+	var m map[string]interface{}
+	if err := hcl.DecodeObject(&m, obj.Val); err != nil {
+		return err
+	}
+	for k, v := range m {
+		if strings.Contains(k, "_") {
+			delete(m, k)
+			m[strings.Replace(k, "_", "", -1)] = v
+		}
+	}
+
+	dec, err := mapstructure.NewDecoder(&mapstructure.DecoderConfig{
+		DecodeHook:       mapstructure.StringToTimeDurationHookFunc(),
+		WeaklyTypedInput: true,
+		Result:           result,
+	})
+	if err != nil {
+		return err
+	}
+	return dec.Decode(m)
+}
+
+func parseTasks(result *api.Job, list *ast.ObjectList) error {
+	list = list.Children()
+	if len(list.Items) == 0 {
+		return nil
+	}
+
+	// Go through each object and turn it into an actual result.
+	collection := make([]*api.Task, 0, len(list.Items))
+	seen := make(map[string]struct{})
+	for _, item := range list.Items {
+		n := item.Keys[0].Token.Value().(string)
+
+		// Make sure we haven't already found this
+		if _, ok := seen[n]; ok {
+			return fmt.Errorf("task '%s' defined more than once", n)
+		}
+		seen[n] = struct{}{}
+
+		// We need this later
+		var listVal *ast.ObjectList
+		if ot, ok := item.Val.(*ast.ObjectType); ok {
+			listVal = ot.List
+		} else {
+			return fmt.Errorf("task '%s': should be an object", n)
+		}
+
+		// Check for invalid keys
+		valid := []string{
+			"node_id",
+			"node_name",
+			"config",
+			"driver",
+		}
+		if err := checkHCLKeys(listVal, valid); err != nil {
+			return multierror.Prefix(err, fmt.Sprintf("'%s' ->", n))
+		}
+
+		var m map[string]interface{}
+		if err := hcl.DecodeObject(&m, item.Val); err != nil {
+			return err
+		}
+		delete(m, "config")
+
+		// Build the group with the basic decode
+		var t api.Task
+		t.Type = *internal.StringToPtr(n)
+		if err := mapstructure.WeakDecode(m, &t); err != nil {
+			return err
+		}
+
+		// If we have config, then parse that
+		if o := listVal.Filter("config"); len(o.Items) > 0 {
+			for _, o := range o.Elem().Items {
+				var m map[string]interface{}
+				if err := hcl.DecodeObject(&m, o.Val); err != nil {
+					return err
+				}
+
+				if err := mapstructure.WeakDecode(m, &t.Config); err != nil {
+					return err
+				}
+			}
+		}
+
+		collection = append(collection, &t)
+	}
+
+	result.Tasks = append(result.Tasks, collection...)
+	return nil
+}
+
+// parseEndpoint parses a source or target block into the task of the given
+// type. The connection block and the other keys of the block, written in
+// snake case, make up the task config.
+func parseEndpoint(result *api.Job, taskType string, list *ast.ObjectList) error {
+	if len(list.Items) > 1 {
+		return fmt.Errorf("only one block allowed")
+	}
+	item := list.Items[0]
+	if len(item.Keys) > 0 {
+		return fmt.Errorf("block does not take a name")
+	}
+	for _, t := range result.Tasks {
+		if t.Type == taskType {
+			return fmt.Errorf("task '%s' defined more than once", taskType)
+		}
+	}
+	listVal, ok := item.Val.(*ast.ObjectType)
+	if !ok {
+		return fmt.Errorf("should be an object")
+	}
+
+	var m map[string]interface{}
+	if err := hcl.DecodeObject(&m, item.Val); err != nil {
+		return err
+	}
+	delete(m, "connection")
+	delete(m, "primary")
+	delete(m, "bulk_load")
+	delete(m, "config")
+
+	t := &api.Task{
+		Type:   taskType,
+		Driver: models.TaskDriverMySQL,
+		Config: make(map[string]interface{}),
+	}
+	if v, ok := m["driver"]; ok {
+		driver, err := driverName(fmt.Sprint(v))
+		if err != nil {
+			return err
+		}
+		t.Driver = driver
+		delete(m, "driver")
+	}
+	if v, ok := m["node_id"]; ok {
+		t.NodeID = fmt.Sprint(v)
+		delete(m, "node_id")
+	}
+	if v, ok := m["node_name"]; ok {
+		t.NodeName = fmt.Sprint(v)
+		delete(m, "node_name")
+	}
+
+	var errs error
+	for k, v := range m {
+		name, err := configFieldName(t.Driver, k)
+		if err != nil {
+			errs = multierror.Append(errs, err)
+			continue
+		}
+		t.Config[name] = v
+	}
+	if errs != nil {
+		return errs
+	}
+
+	connection, err := parseConnection(listVal, "connection")
+	if err != nil {
+		return err
+	}
+	if connection != nil {
+		t.Config["ConnectionConfig"] = connection
+	}
+	// The primary block is the primary of a source read from a replica
+	primary, err := parseConnection(listVal, "primary")
+	if err != nil {
+		return err
+	}
+	if primary != nil {
+		if taskType != models.TaskTypeSrc {
+			return fmt.Errorf("'primary' block is only allowed in the source")
+		}
+		t.Config["PrimaryConnectionConfig"] = primary
+	}
+	bulkLoad, err := parseBulkLoad(listVal)
+	if err != nil {
+		return multierror.Prefix(err, "bulk_load ->")
+	}
+	if bulkLoad != nil {
+		if taskType != models.TaskTypeSrc || t.Driver != models.TaskDriverMySQL {
+			return fmt.Errorf("'bulk_load' block is only allowed in a MySQL source")
+		}
+		t.Config["BulkLoad"] = bulkLoad
+	}
+
+	// The config block passes keys to the task config as is
+	if o := listVal.List.Filter("config"); len(o.Items) > 0 {
+		for _, o := range o.Elem().Items {
+			var cm map[string]interface{}
+			if err := hcl.DecodeObject(&cm, o.Val); err != nil {
+				return err
+			}
+			if err := mapstructure.WeakDecode(cm, &t.Config); err != nil {
+				return err
+			}
+		}
+	}
+
+	result.Tasks = append(result.Tasks, t)
+	return nil
+}
+
+// connectionFields are the fields of the connection blocks
+var connectionFields = map[string]string{
+	"host":         "Host",
+	"port":         "Port",
+	"user":         "User",
+	"password":     "Password",
+	"charset":      "Charset",
+	"tls":          "TLS",
+	"ssh_tunnel":   "SSHTunnel",
+	"socks_proxy":  "SocksProxy",
+	"service_name": "ServiceName",
+	"database":     "Database",
+	"auth_source":  "AuthSource",
+	"replica_set":  "ReplicaSet",
+	"driver_name":  "DriverName",
+	"dsn":          "DSN",
+}
+
+// connectionBlockFields are the fields of the blocks nested in connection
+// blocks
+var connectionBlockFields = map[string]map[string]string{
+	"tls": {
+		"ca_file":     "CAFile",
+		"cert_file":   "CertFile",
+		"key_file":    "KeyFile",
+		"verify_mode": "VerifyMode",
+		"server_name": "ServerName",
+	},
+	"ssh_tunnel": {
+		"host":             "Host",
+		"port":             "Port",
+		"user":             "User",
+		"private_key_file": "PrivateKeyFile",
+		"known_hosts_file": "KnownHostsFile",
+	},
+	"socks_proxy": {
+		"address":  "Address",
+		"user":     "User",
+		"password": "Password",
+	},
+}
+
+// parseConnection returns the connection config of the named block of an
+// endpoint, or nil if there is no such block
+func parseConnection(listVal *ast.ObjectType, name string) (map[string]interface{}, error) {
+	o := listVal.List.Filter(name)
+	if len(o.Items) == 0 {
+		return nil, nil
+	}
+	if len(o.Items) > 1 {
+		return nil, fmt.Errorf("only one '%s' block allowed", name)
+	}
+	conn := o.Items[0]
+	valid := make([]string, 0, len(connectionFields))
+	for k := range connectionFields {
+		valid = append(valid, k)
+	}
+	if err := checkHCLKeys(conn.Val, valid); err != nil {
+		return nil, multierror.Prefix(err, name+" ->")
+	}
+	var cm map[string]interface{}
+	if err := hcl.DecodeObject(&cm, conn.Val); err != nil {
+		return nil, err
+	}
+	connection := make(map[string]interface{})
+	for k, v := range cm {
+		fields, nested := connectionBlockFields[k]
+		if !nested {
+			connection[connectionFields[k]] = v
+			continue
+		}
+		blocks, ok := v.([]map[string]interface{})
+		if !ok || len(blocks) != 1 {
+			return nil, fmt.Errorf("%s -> only one '%s' block allowed", name, k)
+		}
+		block := make(map[string]interface{})
+		for bk, bv := range blocks[0] {
+			field, ok := fields[bk]
+			if !ok {
+				return nil, fmt.Errorf("%s -> %s -> invalid key: %s", name, k, bk)
+			}
+			block[field] = bv
+		}
+		connection[connectionFields[k]] = block
+	}
+	return connection, nil
+}
+
+// bulkLoadFields are the keys of the bulk_load block and of its nested
+// blocks
+var bulkLoadFields = map[string][]string{
+	"bulk_load": {"gtid", "tables", "s3"},
+	"tables":    {"schema", "table", "files", "format", "columns", "delimiter", "null"},
+	"s3":        {"region", "endpoint"},
+}
+
+// parseBulkLoad returns the BulkLoad of the bulk_load block of an endpoint,
+// or nil if there is no such block. Its tables blocks are a table each.
+func parseBulkLoad(listVal *ast.ObjectType) (map[string]interface{}, error) {
+	o := listVal.List.Filter("bulk_load")
+	if len(o.Items) == 0 {
+		return nil, nil
+	}
+	if len(o.Items) > 1 {
+		return nil, fmt.Errorf("only one block allowed")
+	}
+	block, ok := o.Items[0].Val.(*ast.ObjectType)
+	if !ok {
+		return nil, fmt.Errorf("should be an object")
+	}
+	if err := checkHCLKeys(block, bulkLoadFields["bulk_load"]); err != nil {
+		return nil, err
+	}
+	for _, name := range []string{"tables", "s3"} {
+		for _, item := range block.List.Filter(name).Items {
+			if err := checkHCLKeys(item.Val, bulkLoadFields[name]); err != nil {
+				return nil, multierror.Prefix(err, name+" ->")
+			}
+		}
+	}
+
+	var m map[string]interface{}
+	if err := hcl.DecodeObject(&m, block); err != nil {
+		return nil, err
+	}
+	bulkLoad := make(map[string]interface{})
+	for k, v := range m {
+		if k == "s3" {
+			blocks, ok := v.([]map[string]interface{})
+			if !ok || len(blocks) != 1 {
+				return nil, fmt.Errorf("only one 's3' block allowed")
+			}
+			v = blocks[0]
+		}
+		bulkLoad[strings.Title(k)] = v
+	}
+	return bulkLoad, nil
+}
+
+// parseTables parses the tables block into the ReplicateDoDb and
+// ReplicateIgnoreDb of the Src task. Each schema block replicates a schema,
+// either whole or only the listed tables, and each ignore block leaves out
+// a schema or some of its tables.
+func parseTables(result *api.Job, list *ast.ObjectList) error {
+	if len(list.Items) > 1 {
+		return fmt.Errorf("only one block allowed")
+	}
+	listVal, ok := list.Items[0].Val.(*ast.ObjectType)
+	if !ok {
+		return fmt.Errorf("should be an object")
+	}
+	if err := checkHCLKeys(listVal, []string{"schema", "ignore"}); err != nil {
+		return err
+	}
+	src := srcTask(result)
+	if src == nil {
+		return fmt.Errorf("a source is required")
+	}
+
+	for _, f := range []struct {
+		block string
+		key   string
+	}{
+		{"schema", "ReplicateDoDb"},
+		{"ignore", "ReplicateIgnoreDb"},
+	} {
+		o := listVal.List.Filter(f.block)
+		if len(o.Items) == 0 {
+			continue
+		}
+		if _, ok := src.Config[f.key]; ok {
+			return fmt.Errorf("%s is also set in the source config", f.key)
+		}
+		schemas, err := parseSchemas(o)
+		if err != nil {
+			return multierror.Prefix(err, fmt.Sprintf("%s:", f.block))
+		}
+		src.Config[f.key] = schemas
+	}
+	return nil
+}
+
+func parseSchemas(list *ast.ObjectList) ([]map[string]interface{}, error) {
+	var schemas []map[string]interface{}
+	seen := make(map[string]struct{})
+	for _, item := range list.Items {
+		if len(item.Keys) != 1 {
+			return nil, fmt.Errorf("block needs a schema name")
+		}
+		name := item.Keys[0].Token.Value().(string)
+		if _, ok := seen[name]; ok {
+			return nil, fmt.Errorf("schema '%s' defined more than once", name)
+		}
+		seen[name] = struct{}{}
+
+		listVal, ok := item.Val.(*ast.ObjectType)
+		if !ok {
+			return nil, fmt.Errorf("schema '%s': should be an object", name)
+		}
+		if err := checkHCLKeys(listVal, []string{"tables", "table"}); err != nil {
+			return nil, multierror.Prefix(err, fmt.Sprintf("'%s' ->", name))
+		}
+		var m struct {
+			Tables []string
+		}
+		if err := hcl.DecodeObject(&m, item.Val); err != nil {
+			return nil, err
+		}
+
+		var tables []map[string]interface{}
+		for _, table := range m.Tables {
+			tables = append(tables, map[string]interface{}{"TableName": table})
+		}
+		for _, t := range listVal.List.Filter("table").Items {
+			if len(t.Keys) != 1 {
+				return nil, fmt.Errorf("schema '%s': table block needs a table name", name)
+			}
+			table := t.Keys[0].Token.Value().(string)
+			if err := checkHCLKeys(t.Val, []string{"where"}); err != nil {
+				return nil, multierror.Prefix(err, fmt.Sprintf("'%s.%s' ->", name, table))
+			}
+			var tm struct {
+				Where string
+			}
+			if err := hcl.DecodeObject(&tm, t.Val); err != nil {
+				return nil, err
+			}
+			entry := map[string]interface{}{"TableName": table}
+			if tm.Where != "" {
+				entry["Where"] = tm.Where
+			}
+			tables = append(tables, entry)
+		}
+
+		schema := map[string]interface{}{"TableSchema": name}
+		if len(tables) > 0 {
+			schema["Tables"] = tables
+		}
+		schemas = append(schemas, schema)
+	}
+	return schemas, nil
+}
+
+// sqlFilters maps the statements a transforms block may skip to the
+// SqlFilter items of the Src task
+var sqlFilters = map[string]string{
+	"dml":        "NoDML",
+	"dml-insert": "NoDMLInsert",
+	"dml-update": "NoDMLUpdate",
+	"dml-delete": "NoDMLDelete",
+	"ddl":        "NoDDL",
+}
+
+// parseTransforms parses the transforms block into the config of the Src
+// task
+func parseTransforms(result *api.Job, list *ast.ObjectList) error {
+	if len(list.Items) > 1 {
+		return fmt.Errorf("only one block allowed")
+	}
+	item := list.Items[0]
+	if err := checkHCLKeys(item.Val, []string{"skip"}); err != nil {
+		return err
+	}
+	src := srcTask(result)
+	if src == nil {
+		return fmt.Errorf("a source is required")
+	}
+
+	var m struct {
+		Skip []string
+	}
+	if err := hcl.DecodeObject(&m, item.Val); err != nil {
+		return err
+	}
+	if len(m.Skip) == 0 {
+		return nil
+	}
+	if _, ok := src.Config["SqlFilter"]; ok {
+		return fmt.Errorf("SqlFilter is also set in the source config")
+	}
+	var filters []string
+	for _, skip := range m.Skip {
+		filter, ok := sqlFilters[strings.ToLower(skip)]
+		if !ok {
+			return fmt.Errorf("unknown statement to skip %q: must be one of dml, dml-insert, dml-update, dml-delete or ddl", skip)
+		}
+		filters = append(filters, filter)
+	}
+	src.Config["SqlFilter"] = filters
+	return nil
+}
+
+func srcTask(job *api.Job) *api.Task {
+	for _, t := range job.Tasks {
+		if t.Type == models.TaskTypeSrc {
+			if t.Config == nil {
+				t.Config = make(map[string]interface{})
+			}
+			return t
+		}
+	}
+	return nil
+}
+
+// driverName returns the built-in driver of the given name, ignoring case.
+// Other names are those of plugin drivers, which the agents check.
+func driverName(name string) (string, error) {
+	for _, driver := range []string{models.TaskDriverMySQL, models.TaskDriverKafka, models.TaskDriverOracle,
+		models.TaskDriverSQLServer, models.TaskDriverMongoDB, models.TaskDriverPolling, models.TaskDriverWebhook,
+		models.TaskDriverJetStream, models.TaskDriverEmbedded, models.TaskDriverScript} {
+		if strings.EqualFold(name, driver) {
+			return driver, nil
+		}
+	}
+	if name == "" || strings.ContainsAny(name, `/\ `) {
+		return "", fmt.Errorf("invalid driver %q", name)
+	}
+	return name, nil
+}
+
+// configFieldName returns the task config field of a snake case key, such
+// as ParallelWorkers for parallel_workers
+func configFieldName(driver, key string) (string, error) {
+	var config interface{}
+	switch driver {
+	case models.TaskDriverMySQL:
+		config = uconf.MySQLDriverConfig{}
+	case models.TaskDriverKafka, models.TaskDriverWebhook, models.TaskDriverJetStream, models.TaskDriverEmbedded:
+		config = kafka3.KafkaConfig{}
+	case models.TaskDriverOracle:
+		config = oracle.OracleConfig{}
+	case models.TaskDriverSQLServer:
+		config = sqlserver.SQLServerConfig{}
+	case models.TaskDriverMongoDB:
+		config = mongodb.MongoDBConfig{}
+	case models.TaskDriverPolling:
+		config = polling.PollingConfig{}
+	case models.TaskDriverScript:
+		config = script.ScriptConfig{}
+	default:
+		parts := strings.Split(key, "_")
+		for i, part := range parts {
+			parts[i] = strings.Title(part)
+		}
+		return strings.Join(parts, ""), nil
+	}
+
+	name := strings.Replace(key, "_", "", -1)
+	typ := reflect.TypeOf(config)
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		if field.PkgPath == "" && strings.EqualFold(field.Name, name) {
+			return field.Name, nil
+		}
+	}
+	return "", fmt.Errorf("invalid key: %s", key)
+}
+
+func checkHCLKeys(node ast.Node, valid []string) error {
+	var list *ast.ObjectList
+	switch n := node.(type) {
+	case *ast.ObjectList:
+		list = n
+	case *ast.ObjectType:
+		list = n.List
+	default:
+		return fmt.Errorf("cannot check HCL keys of type %T", n)
+	}
+
+	validMap := make(map[string]struct{}, len(valid))
+	for _, v := range valid {
+		validMap[v] = struct{}{}
+	}
+
+	var result error
+	for _, item := range list.Items {
+		key := item.Keys[0].Token.Value().(string)
+		if _, ok := validMap[key]; !ok {
+			result = multierror.Append(result, fmt.Errorf(
+				"invalid key: %s", key))
+		}
+	}
+
+	return result
+}
@@ -0,0 +1,14 @@
+job "shop-alert" {
+  alert {
+    max_seconds_behind    = 60
+    max_errors_per_minute = 3
+  }
+
+  task "Src" {
+    driver = "MySQL"
+  }
+
+  task "Dest" {
+    driver = "MySQL"
+  }
+}
@@ -0,0 +1,44 @@
+# Seeds the target from an export of the source, then follows its binlog
+job "shop-seed" {
+  source {
+    connection {
+      host = "10.0.0.1"
+      port = 3306
+    }
+
+    bulk_load {
+      gtid = "3e11fa47-71ca-11e1-9e33-c80aa9429562:1-42"
+
+      tables {
+        schema = "shop"
+        table  = "orders"
+        files  = ["s3://exports/shop/orders/"]
+        format = "parquet"
+      }
+
+      tables {
+        schema    = "shop"
+        table     = "items"
+        files     = ["/data/export/items-*.csv.gz"]
+        delimiter = "|"
+      }
+
+      s3 {
+        region = "us-east-1"
+      }
+    }
+  }
+
+  target {
+    connection {
+      host = "10.0.0.2"
+      port = 3306
+    }
+  }
+
+  tables {
+    schema "shop" {
+      tables = ["orders", "items"]
+    }
+  }
+}
@@ -0,0 +1,17 @@
+# Lands the change events of a Kafka topic into MySQL
+job "shop-land" {
+  source {
+    driver         = "kafka"
+    brokers        = ["10.0.0.2:9092"]
+    topics         = ["dbserver1.shop.orders", "dbserver1.shop.items"]
+    group_id       = "shop-land"
+    initial_offset = "oldest"
+  }
+
+  target {
+    connection {
+      host = "10.0.0.3"
+      port = 3306
+    }
+  }
+}
@@ -0,0 +1,14 @@
+job "shop-labels" {
+  labels {
+    team = "sales"
+    env  = "prod"
+  }
+
+  task "Src" {
+    driver = "MySQL"
+  }
+
+  task "Dest" {
+    driver = "MySQL"
+  }
+}
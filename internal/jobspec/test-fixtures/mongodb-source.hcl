@@ -0,0 +1,36 @@
+# Replicates a MongoDB database to MySQL, flattening the orders
+job "shop-migrate" {
+  source {
+    driver = "mongodb"
+
+    connection {
+      host        = "10.0.0.7"
+      port        = 27017
+      user        = "dtle"
+      password    = "secret"
+      auth_source = "admin"
+      replica_set = "rs0"
+    }
+
+    mappings {
+      database   = "shop"
+      collection = "orders"
+
+      fields {
+        path = "customer.name"
+        type = "varchar(64)"
+      }
+    }
+  }
+
+  target {
+    connection {
+      host = "10.0.0.2"
+      port = 3306
+    }
+  }
+
+  tables {
+    schema "shop" {}
+  }
+}
@@ -0,0 +1,27 @@
+# Migrates an Oracle schema to MySQL
+job "erp-migrate" {
+  source {
+    driver             = "oracle"
+    chunk_size         = 5000
+    log_miner_interval = 500
+
+    connection {
+      host         = "10.0.0.5"
+      port         = 1521
+      service_name = "ORCLPDB1"
+      user         = "dtle"
+      password     = "secret"
+    }
+  }
+
+  target {
+    connection {
+      host = "10.0.0.2"
+      port = 3306
+    }
+  }
+
+  tables {
+    schema "ERP" {}
+  }
+}
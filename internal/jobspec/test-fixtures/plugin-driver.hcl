@@ -0,0 +1,19 @@
+# Replicates to the Redis driver of a plugin
+job "shop-cache" {
+  source {
+    connection {
+      host = "10.0.0.1"
+      port = 3306
+    }
+  }
+
+  target {
+    driver     = "Redis"
+    key_prefix = "shop:"
+
+    connection {
+      host = "10.0.0.9"
+      port = 6379
+    }
+  }
+}
@@ -0,0 +1,22 @@
+# Streams the orders of the shop from a replica, offloading the primary
+job "shop-replica" {
+  source {
+    replica_wait_timeout = 60
+
+    connection {
+      host = "10.0.0.11"
+      port = 3306
+      user = "repl"
+    }
+
+    primary {
+      host = "10.0.0.1"
+      port = 3306
+    }
+  }
+
+  target {
+    driver = "kafka"
+    topic  = "shop"
+  }
+}
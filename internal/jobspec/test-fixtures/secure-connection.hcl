@@ -0,0 +1,31 @@
+# Migrates a cloud database only reachable through a bastion, over TLS
+job "cloud-migrate" {
+  source {
+    connection {
+      host = "db.internal"
+      port = 3306
+
+      tls {
+        ca_file     = "/etc/dtle/ca.pem"
+        verify_mode = "ca"
+      }
+
+      ssh_tunnel {
+        host             = "bastion.example.com"
+        user             = "dtle"
+        private_key_file = "/etc/dtle/id_rsa"
+      }
+    }
+  }
+
+  target {
+    connection {
+      host = "10.0.0.2"
+      port = 3306
+
+      socks_proxy {
+        address = "127.0.0.1:1080"
+      }
+    }
+  }
+}
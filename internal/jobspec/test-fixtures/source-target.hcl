@@ -0,0 +1,43 @@
+# Streams the orders of the shop to Kafka
+job "shop-orders" {
+  namespace = "sales"
+
+  source {
+    node_name        = "node1"
+    parallel_workers = 8
+    gtid             = "" # start from the current position
+
+    connection {
+      host     = "10.0.0.1"
+      port     = 3306
+      user     = "repl"
+      password = "secret"
+    }
+  }
+
+  target {
+    driver  = "kafka"
+    brokers = ["10.0.0.2:9092", "10.0.0.3:9092"]
+    topic   = "shop"
+  }
+
+  tables {
+    schema "shop" {
+      tables = ["items"]
+
+      table "orders" {
+        where = "id > 1000"
+      }
+    }
+
+    schema "crm" {}
+
+    ignore "crm" {
+      tables = ["tmp_log"]
+    }
+  }
+
+  transforms {
+    skip = ["ddl", "dml-delete"]
+  }
+}
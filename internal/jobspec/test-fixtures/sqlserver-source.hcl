@@ -0,0 +1,26 @@
+# Migrates a SQL Server database to MySQL from its CDC tables
+job "crm-migrate" {
+  source {
+    driver        = "sqlserver"
+    poll_interval = 500
+
+    connection {
+      host     = "10.0.0.6"
+      port     = 1433
+      database = "CRM"
+      user     = "dtle"
+      password = "secret"
+    }
+  }
+
+  target {
+    connection {
+      host = "10.0.0.2"
+      port = 3306
+    }
+  }
+
+  tables {
+    schema "dbo" {}
+  }
+}
@@ -0,0 +1,15 @@
+job "shop-migrate" {
+  task "Src" {
+    driver = "MySQL"
+    config {
+      ParallelWorkers = 4
+    }
+  }
+
+  task "Dest" {
+    driver = "MySQL"
+    config {
+      ParallelWorkers = 4
+    }
+  }
+}
@@ -0,0 +1,5 @@
+job "shop-migrate" {
+  source {
+    parallel_wrokers = 4
+  }
+}
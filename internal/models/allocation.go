@@ -74,6 +74,9 @@ type Allocation struct {
 	// PreviousAllocation is the allocation that this allocation is replacing
 	PreviousAllocation string
 
+	// RescheduleTracker records the reschedules that led to this allocation
+	RescheduleTracker *RescheduleTracker
+
 	// Raft Indexes
 	CreateIndex uint64
 	ModifyIndex uint64
@@ -96,6 +99,7 @@ func (a *Allocation) Copy() *Allocation {
 
 	na.Job = na.Job.Copy()
 	na.Metrics = na.Metrics.Copy()
+	na.RescheduleTracker = na.RescheduleTracker.Copy()
 
 	if a.TaskStates != nil {
 		ts := make(map[string]*TaskState, len(na.TaskStates))
@@ -153,6 +157,39 @@ func (a *Allocation) Terminated() bool {
 	return false
 }
 
+// RescheduleCount returns the number of reschedules that happened within
+// interval before now.
+func (a *Allocation) RescheduleCount(interval time.Duration, now time.Time) int {
+	if a.RescheduleTracker == nil {
+		return 0
+	}
+	count := 0
+	for _, e := range a.RescheduleTracker.Events {
+		if interval <= 0 || now.Sub(time.Unix(0, e.RescheduleTime)) <= interval {
+			count++
+		}
+	}
+	return count
+}
+
+// RescheduleEligible returns whether another reschedule is allowed by the
+// policy at the given time.
+func (a *Allocation) RescheduleEligible(policy *ReschedulePolicy, now time.Time) bool {
+	if policy == nil || policy.Attempts == 0 {
+		return false
+	}
+	return a.RescheduleCount(policy.Interval, now) < policy.Attempts
+}
+
+// NextRescheduleDelay returns how long to wait before placing the replacement
+// of this allocation.
+func (a *Allocation) NextRescheduleDelay(policy *ReschedulePolicy, now time.Time) time.Duration {
+	if policy == nil {
+		return 0
+	}
+	return policy.Backoff(a.RescheduleCount(policy.Interval, now) + 1)
+}
+
 // RanSuccessfully returns whether the client has ran the allocation and all
 // tasks finished successfully
 func (a *Allocation) RanSuccessfully() bool {
@@ -220,6 +257,60 @@ func (a *Allocation) Index() int {
 	return index
 }
 
+// RescheduleTracker encapsulates previous reschedule events
+type RescheduleTracker struct {
+	Events []*RescheduleEvent
+}
+
+func (rt *RescheduleTracker) Copy() *RescheduleTracker {
+	if rt == nil {
+		return nil
+	}
+	nt := &RescheduleTracker{}
+	*nt = *rt
+	rescheduleEvents := make([]*RescheduleEvent, 0, len(rt.Events))
+	for _, tracker := range rt.Events {
+		rescheduleEvents = append(rescheduleEvents, tracker.Copy())
+	}
+	nt.Events = rescheduleEvents
+	return nt
+}
+
+// RescheduleEvent is used to keep track of previous attempts at rescheduling an allocation
+type RescheduleEvent struct {
+	// RescheduleTime is the timestamp of a reschedule attempt
+	RescheduleTime int64
+
+	// PrevAllocID is the ID of the previous allocation being restarted
+	PrevAllocID string
+
+	// PrevNodeID is the node ID of the previous allocation
+	PrevNodeID string
+
+	// Reason is why the previous allocation was replaced
+	Reason string
+
+	// Delay is the reschedule delay associated with the attempt
+	Delay time.Duration
+}
+
+func NewRescheduleEvent(rescheduleTime int64, prevAllocID, prevNodeID, reason string, delay time.Duration) *RescheduleEvent {
+	return &RescheduleEvent{RescheduleTime: rescheduleTime,
+		PrevAllocID: prevAllocID,
+		PrevNodeID:  prevNodeID,
+		Reason:      reason,
+		Delay:       delay}
+}
+
+func (re *RescheduleEvent) Copy() *RescheduleEvent {
+	if re == nil {
+		return nil
+	}
+	copy := new(RescheduleEvent)
+	*copy = *re
+	return copy
+}
+
 // AllocListStub is used to return a subset of alloc information
 type AllocListStub struct {
 	ID                 string
@@ -357,6 +448,8 @@ type AllocUpdateRequest struct {
 
 // AllocListRequest is used to request a list of allocations
 type AllocListRequest struct {
+	// ClientStatuses lists only the allocations in one of the statuses
+	ClientStatuses []string
 	QueryOptions
 }
 
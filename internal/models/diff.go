@@ -0,0 +1,290 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+)
+
+const (
+	DiffTypeNone    = "None"
+	DiffTypeAdded   = "Added"
+	DiffTypeDeleted = "Deleted"
+	DiffTypeEdited  = "Edited"
+)
+
+const (
+	// AnnotationForcesCreate marks a task that is started by the update
+	AnnotationForcesCreate = "forces create"
+
+	// AnnotationForcesDestroy marks a task that is stopped by the update
+	AnnotationForcesDestroy = "forces destroy"
+
+	// AnnotationForcesRestart marks a change that is only applied by
+	// restarting the task, such as a change of its driver or config
+	AnnotationForcesRestart = "forces restart"
+
+	// AnnotationInPlace marks a change that is applied without restarting
+	// the tasks
+	AnnotationInPlace = "in-place"
+)
+
+// JobDiff contains the diff of two versions of a job
+type JobDiff struct {
+	Type   string
+	ID     string
+	Fields []*FieldDiff
+	Tasks  []*TaskDiff
+}
+
+// TaskDiff contains the diff of two versions of a task. Nested task config
+// values are flattened into fields such as "ConnectionConfig.Host" or
+// "ReplicateDoDb[0].TableSchema".
+type TaskDiff struct {
+	Type        string
+	Name        string
+	Fields      []*FieldDiff
+	Annotations []string
+}
+
+// FieldDiff contains the diff of a single field
+type FieldDiff struct {
+	Type        string
+	Name        string
+	Old, New    string
+	Annotations []string
+}
+
+// Diff returns the diff from the job j to the job other. j may be nil if
+// the job does not exist yet.
+func (j *Job) Diff(other *Job) (*JobDiff, error) {
+	if other == nil {
+		return nil, fmt.Errorf("nil job to diff against")
+	}
+	diff := &JobDiff{
+		Type: DiffTypeNone,
+		ID:   other.ID,
+	}
+
+	var oldFields map[string]string
+	if j != nil {
+		if j.ID != other.ID {
+			return nil, fmt.Errorf("can not diff jobs with different IDs: %q and %q", j.ID, other.ID)
+		}
+		var err error
+		if oldFields, err = flattenDiffValue(jobDiffValue(j)); err != nil {
+			return nil, err
+		}
+	}
+	newFields, err := flattenDiffValue(jobDiffValue(other))
+	if err != nil {
+		return nil, err
+	}
+	diff.Fields = fieldDiffs(oldFields, newFields, AnnotationInPlace)
+
+	for _, task := range other.Tasks {
+		var old *Task
+		if j != nil {
+			old = j.LookupTask(task.Type)
+		}
+		taskDiff, err := old.diff(task)
+		if err != nil {
+			return nil, err
+		}
+		if taskDiff.Type != DiffTypeNone {
+			diff.Tasks = append(diff.Tasks, taskDiff)
+		}
+	}
+	if j != nil {
+		for _, task := range j.Tasks {
+			if other.LookupTask(task.Type) != nil {
+				continue
+			}
+			taskDiff, err := task.diff(nil)
+			if err != nil {
+				return nil, err
+			}
+			diff.Tasks = append(diff.Tasks, taskDiff)
+		}
+	}
+
+	switch {
+	case j == nil:
+		diff.Type = DiffTypeAdded
+	case len(diff.Fields) > 0 || len(diff.Tasks) > 0:
+		diff.Type = DiffTypeEdited
+	}
+	return diff, nil
+}
+
+// diff returns the diff from the task t to the task other. Either may be
+// nil if the task is added or deleted.
+func (t *Task) diff(other *Task) (*TaskDiff, error) {
+	diff := &TaskDiff{
+		Type: DiffTypeNone,
+	}
+
+	var oldFields, newFields, oldConfig, newConfig map[string]string
+	var err error
+	if t != nil {
+		diff.Name = t.Type
+		if oldFields, err = flattenDiffValue(taskDiffValue(t)); err != nil {
+			return nil, err
+		}
+		if oldConfig, err = flattenDiffValue(t.Config); err != nil {
+			return nil, err
+		}
+	}
+	if other != nil {
+		diff.Name = other.Type
+		if newFields, err = flattenDiffValue(taskDiffValue(other)); err != nil {
+			return nil, err
+		}
+		if newConfig, err = flattenDiffValue(other.Config); err != nil {
+			return nil, err
+		}
+	}
+
+	restart := false
+	for _, f := range fieldDiffs(oldFields, newFields, AnnotationInPlace) {
+		if f.Name == "Driver" {
+			f.Annotations = []string{AnnotationForcesRestart}
+			restart = true
+		}
+		diff.Fields = append(diff.Fields, f)
+	}
+	for _, f := range fieldDiffs(oldConfig, newConfig, AnnotationForcesRestart) {
+		f.Name = "Config." + f.Name
+		diff.Fields = append(diff.Fields, f)
+		restart = true
+	}
+
+	switch {
+	case t == nil:
+		diff.Type = DiffTypeAdded
+		diff.Annotations = []string{AnnotationForcesCreate}
+	case other == nil:
+		diff.Type = DiffTypeDeleted
+		diff.Annotations = []string{AnnotationForcesDestroy}
+	case restart:
+		diff.Type = DiffTypeEdited
+		diff.Annotations = []string{AnnotationForcesRestart}
+	case len(diff.Fields) > 0:
+		diff.Type = DiffTypeEdited
+		diff.Annotations = []string{AnnotationInPlace}
+	}
+	return diff, nil
+}
+
+// jobDiffValue returns the fields of a job that are compared by a diff
+func jobDiffValue(j *Job) map[string]interface{} {
+	return map[string]interface{}{
+		"Name":        j.Name,
+		"Namespace":   j.Namespace,
+		"Type":        j.Type,
+		"Datacenters": j.Datacenters,
+		"Orders":      j.Orders,
+		"Failover":    j.Failover,
+		"Restart":     j.Restart,
+		"Reschedule":  j.Reschedule,
+	}
+}
+
+// taskDiffValue returns the fields of a task, besides its config, that are
+// compared by a diff
+func taskDiffValue(t *Task) map[string]interface{} {
+	return map[string]interface{}{
+		"Driver":   t.Driver,
+		"NodeID":   t.NodeID,
+		"NodeName": t.NodeName,
+	}
+}
+
+// fieldDiffs compares two flattened values, returning the changed fields
+// sorted by name
+func fieldDiffs(old, new map[string]string, annotation string) []*FieldDiff {
+	var names []string
+	for name := range old {
+		names = append(names, name)
+	}
+	for name := range new {
+		if _, ok := old[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	var diffs []*FieldDiff
+	for _, name := range names {
+		o, inOld := old[name]
+		n, inNew := new[name]
+		f := &FieldDiff{
+			Name:        name,
+			Old:         o,
+			New:         n,
+			Annotations: []string{annotation},
+		}
+		switch {
+		case !inOld:
+			f.Type = DiffTypeAdded
+		case !inNew:
+			f.Type = DiffTypeDeleted
+		case o != n:
+			f.Type = DiffTypeEdited
+		default:
+			continue
+		}
+		diffs = append(diffs, f)
+	}
+	return diffs
+}
+
+// flattenDiffValue flattens a value into its leaf fields. The value goes
+// through json first, so that configs decoded from json and from the raft
+// log compare equal.
+func flattenDiffValue(v interface{}) (map[string]string, error) {
+	buf, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var generic interface{}
+	if err := json.Unmarshal(buf, &generic); err != nil {
+		return nil, err
+	}
+	fields := make(map[string]string)
+	flattenDiffFields("", generic, fields)
+	return fields, nil
+}
+
+func flattenDiffFields(prefix string, v interface{}, fields map[string]string) {
+	switch v := v.(type) {
+	case nil:
+	case map[string]interface{}:
+		for k, e := range v {
+			name := k
+			if prefix != "" {
+				name = prefix + "." + k
+			}
+			flattenDiffFields(name, e, fields)
+		}
+	case []interface{}:
+		for i, e := range v {
+			flattenDiffFields(fmt.Sprintf("%s[%d]", prefix, i), e, fields)
+		}
+	case string:
+		if v != "" {
+			fields[prefix] = v
+		}
+	case float64:
+		fields[prefix] = strconv.FormatFloat(v, 'f', -1, 64)
+	default:
+		fields[prefix] = fmt.Sprint(v)
+	}
+}
@@ -28,6 +28,7 @@ const (
 	EvalTriggerScheduled     = "scheduled"
 	EvalTriggerRollingUpdate = "rolling-update"
 	EvalTriggerMaxPlans      = "max-plan-attempts"
+	EvalTriggerRetryFailed   = "alloc-failure"
 )
 
 // Evaluation is used anytime we need to apply business logic as a result
@@ -0,0 +1,196 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package models
+
+import (
+	"fmt"
+)
+
+const (
+	JobEventSubmitted        = "submitted"
+	JobEventPlaced           = "placed"
+	JobEventRescheduled      = "rescheduled"
+	JobEventSnapshotStarted  = "snapshot-started"
+	JobEventSnapshotFinished = "snapshot-finished"
+	JobEventStreaming        = "streaming"
+	JobEventPaused           = "paused"
+	JobEventResumed          = "resumed"
+	JobEventError            = "error"
+	JobEventCrashed          = "crashed"
+	JobEventWarning          = "warning"
+	JobEventCutover          = "cutover"
+	JobEventBlocked          = "blocked"
+	JobEventUnblocked        = "unblocked"
+	JobEventLagging          = "lagging"
+	JobEventCaughtUp         = "caught-up"
+	JobEventDegraded         = "degraded"
+	JobEventRecovered        = "recovered"
+	JobEventReconciled       = "reconciled"
+)
+
+const (
+	// JobEventDetailCutoverGtid and JobEventDetailTargetGtid are the
+	// details of a cutover event: the GTID set of the source the target
+	// applied, and the GTID set executed on the target then
+	JobEventDetailCutoverGtid = "cutover_gtid"
+	JobEventDetailTargetGtid  = "target_gtid"
+)
+
+// JobEventDetailErrorClass is the detail of an error event with the class
+// of the error the task failed with, such as "retryable", "data" or
+// "terminal"
+const JobEventDetailErrorClass = "error_class"
+
+// JobEventDetailCrashReport is the detail of a crashed event with the stack
+// trace of the panic of the task
+const JobEventDetailCrashReport = "crash_report"
+
+const (
+	// MaxJobEvents is the number of events retained per job. Older events
+	// are dropped once the limit is reached.
+	MaxJobEvents = 200
+)
+
+// JobEvent is an entry in the timeline of a job. Events are recorded by the
+// servers as the job moves through its lifecycle, so the history of a job
+// can be inspected without collecting the logs of every node.
+type JobEvent struct {
+	// ID is unique per event and orders the events of a job
+	ID string
+
+	JobID   string
+	Type    string
+	Task    string
+	AllocID string
+	NodeID  string
+	Message string
+
+	// Details holds the values of the event meant for tools, such as the
+	// GTID sets of a cutover
+	Details map[string]string
+
+	// Time is the unix nano timestamp the event happened at
+	Time int64
+
+	CreateIndex uint64
+}
+
+// NewJobEvent returns a job event created at the given raft index and unix
+// nano time. The events created by the FSM take the time of the write the
+// leader stamped, for every server to record the same time. The ID is
+// assigned when the event is inserted into the state store.
+func NewJobEvent(jobID, eventType, message string, index uint64, at int64) *JobEvent {
+	return &JobEvent{
+		JobID:       jobID,
+		Message:     message,
+		Type:        eventType,
+		Time:        at,
+		CreateIndex: index,
+	}
+}
+
+// JobEventID returns the ID of the seq-th event of a job created at the
+// given raft index.
+func JobEventID(jobID string, index uint64, seq int) string {
+	return fmt.Sprintf("%s/%020d/%04d", jobID, index, seq)
+}
+
+func (e *JobEvent) Copy() *JobEvent {
+	if e == nil {
+		return nil
+	}
+	ne := new(JobEvent)
+	*ne = *e
+	if e.Details != nil {
+		ne.Details = make(map[string]string, len(e.Details))
+		for k, v := range e.Details {
+			ne.Details[k] = v
+		}
+	}
+	return ne
+}
+
+// TaskEventToJobEvent maps a task event reported by a client to the type of
+// job event it represents. An empty string is returned for task events that
+// are not part of the job timeline.
+func TaskEventToJobEvent(te *TaskEvent) (string, string) {
+	switch te.Type {
+	case TaskSnapshotStarted:
+		return JobEventSnapshotStarted, te.DriverMessage
+	case TaskSnapshotFinished:
+		return JobEventSnapshotFinished, te.DriverMessage
+	case TaskStreaming:
+		return JobEventStreaming, te.DriverMessage
+	case TaskSetupFailure:
+		return JobEventError, te.SetupError
+	case TaskDriverFailure:
+		return te.errorJobEvent(), te.DriverError
+	case TaskKilled:
+		if te.KillError != "" {
+			return JobEventError, te.KillError
+		}
+	case TaskTerminated:
+		if te.ExitCode != 0 || te.Message != "" {
+			return te.errorJobEvent(), te.Message
+		}
+	case TaskNotRestarting:
+		return JobEventError, te.RestartReason
+	case TaskIndexAdvisory:
+		return JobEventWarning, te.DriverMessage
+	case TaskBlocked:
+		return JobEventBlocked, te.DriverMessage
+	case TaskUnblocked:
+		return JobEventUnblocked, te.DriverMessage
+	case TaskLagging:
+		return JobEventLagging, te.DriverMessage
+	case TaskCaughtUp:
+		return JobEventCaughtUp, te.DriverMessage
+	case TaskRowCountsReconciled:
+		return JobEventReconciled, te.DriverMessage
+	case TaskRowCountMismatch:
+		return JobEventWarning, te.DriverMessage
+	}
+	return "", ""
+}
+
+// errorJobEvent returns the type of the job event of a task that failed:
+// JobEventCrashed if it panicked, JobEventError otherwise
+func (te *TaskEvent) errorJobEvent() string {
+	if te.CrashReport != "" {
+		return JobEventCrashed
+	}
+	return JobEventError
+}
+
+// StagePhase returns the replication phase a driver stage belongs to, either
+// JobEventSnapshotStarted or JobEventStreaming. An empty string is returned
+// when the stage does not tell the phase apart.
+func StagePhase(stage string) string {
+	switch stage {
+	case StageSearchingRowsForUpdate, StageSendingData:
+		return JobEventSnapshotStarted
+	case StageRegisteringSlaveOnMaster, StageRequestingBinlogDump,
+		StageSendingBinlogEventToSlave, StageFinishedReadingOneBinlogSwitchingToNextBinlog,
+		StageMasterHasSentAllBinlogToSlave, StageWaitingForMasterToSendEvent,
+		StageWaitingForGtidToBeCommitted, StageSlaveHasReadAllRelayLog:
+		return JobEventStreaming
+	}
+	return ""
+}
+
+// JobEventsUpsertRequest is used by the leader to record the events it
+// raises itself, such as the warnings of its checks of the sources
+type JobEventsUpsertRequest struct {
+	Events []*JobEvent
+	WriteRequest
+}
+
+// JobEventsResponse is used to return the timeline of a job
+type JobEventsResponse struct {
+	Events []*JobEvent
+	QueryMeta
+}
@@ -10,10 +10,12 @@ import (
 	"errors"
 	"fmt"
 	"strings"
+	"time"
 
 	"github.com/hashicorp/go-multierror"
 
 	"github.com/actiontech/dtle/internal"
+	umconf "github.com/actiontech/dtle/internal/config/mysql"
 )
 
 const (
@@ -28,6 +30,11 @@ const (
 	JobStatusComplete = "complete" // Complete means all evaluation's and allocations are terminal
 )
 
+const (
+	JobHealthHealthy  = "healthy"  // Healthy means no alert threshold is exceeded
+	JobHealthDegraded = "degraded" // Degraded means an alert threshold is exceeded
+)
+
 func ValidJobStatus(status string) bool {
 	switch status {
 	case JobStatusPending, JobStatusRunning, JobStatusPause, JobStatusDead, JobStatusComplete:
@@ -49,14 +56,33 @@ type Job struct {
 	// specified hierarchically like LineOfBiz/OrgName/Team/Project
 	ID string
 
+	// Namespace is the namespace the job belongs to. Job names are unique
+	// within a namespace.
+	Namespace string
+
 	Orders []string
 
 	// Name is the logical name of the job used to refer to it. This is unique
-	// per region, but not unique globally.
+	// per namespace, but not unique globally.
 	Name string
 
+	// Labels are arbitrary key/value pairs job lists can be filtered by.
+	Labels map[string]string
+
+	// Failover is kept for old job specs. It is equivalent to setting
+	// Reschedule.OnLostNode.
 	Failover bool
 
+	// Restart controls how a failed task is restarted on the same node.
+	Restart *RestartPolicy
+
+	// Reschedule controls whether a task is moved to another node when it
+	// fails permanently or its node is lost.
+	Reschedule *ReschedulePolicy
+
+	// Alert sets the thresholds past which the job is degraded.
+	Alert *AlertPolicy
+
 	// Type is used to control various behaviors about the job. Most jobs
 	// are service jobs, meaning they are expected to be long lived.
 	// Some jobs are batch oriented meaning they run and then terminate.
@@ -80,6 +106,11 @@ type Job struct {
 	// StatusDescription is meant to provide more human useful information
 	StatusDescription string
 
+	// Health is JobHealthDegraded while a threshold of the Alert policy is
+	// exceeded, HealthDescription telling which. The job keeps replicating.
+	Health            string
+	HealthDescription string
+
 	EnforceIndex bool
 
 	// Raft Indexes
@@ -91,9 +122,23 @@ type Job struct {
 // Canonicalize is used to canonicalize fields in the Job. This should be called
 // when registering a Job.
 func (j *Job) Canonicalize() {
+	if j.Namespace == "" {
+		j.Namespace = DefaultNamespace
+	}
+
 	for _, t := range j.Tasks {
 		t.Canonicalize(j)
 	}
+
+	if j.Restart == nil {
+		j.Restart = DefaultRestartPolicy()
+	}
+	j.Restart.Canonicalize()
+	if j.Reschedule == nil {
+		j.Reschedule = DefaultReschedulePolicy()
+	}
+	j.Reschedule.Canonicalize()
+	j.Reschedule.OnLostNode = j.Reschedule.OnLostNode || j.Failover
 }
 
 // Copy returns a deep copy of the Job. It is expected that callers use recover.
@@ -104,8 +149,12 @@ func (j *Job) Copy() *Job {
 	}
 	nj := new(Job)
 	*nj = *j
+	nj.Labels = internal.CopyMapStringString(nj.Labels)
 	nj.Datacenters = internal.CopySliceString(nj.Datacenters)
 	nj.Constraints = CopySliceConstraints(nj.Constraints)
+	nj.Restart = nj.Restart.Copy()
+	nj.Reschedule = nj.Reschedule.Copy()
+	nj.Alert = nj.Alert.Copy()
 
 	if j.Tasks != nil {
 		ts := make([]*Task, len(nj.Tasks))
@@ -149,6 +198,22 @@ func (j *Job) Validate() error {
 		}
 	}
 
+	if j.Restart != nil {
+		if err := j.Restart.Validate(); err != nil {
+			mErr.Errors = append(mErr.Errors, fmt.Errorf("Restart policy validation failed: %v", err))
+		}
+	}
+	if j.Reschedule != nil {
+		if err := j.Reschedule.Validate(); err != nil {
+			mErr.Errors = append(mErr.Errors, fmt.Errorf("Reschedule policy validation failed: %v", err))
+		}
+	}
+	if j.Alert != nil {
+		if err := j.Alert.Validate(); err != nil {
+			mErr.Errors = append(mErr.Errors, fmt.Errorf("Alert policy validation failed: %v", err))
+		}
+	}
+
 	// Check for duplicate tasks
 	tasks := make(map[string]int)
 	for idx, t := range j.Tasks {
@@ -172,6 +237,15 @@ func (j *Job) Validate() error {
 	return mErr.ErrorOrNil()
 }
 
+// RescheduleOnLostNode returns whether tasks of the job should be moved to
+// another node when their node goes down.
+func (j *Job) RescheduleOnLostNode() bool {
+	if j.Reschedule == nil {
+		return j.Failover
+	}
+	return j.Reschedule.OnLostNode
+}
+
 // LookupTask finds a task by name
 func (j *Job) LookupTask(tp string) *Task {
 	for _, t := range j.Tasks {
@@ -186,10 +260,13 @@ func (j *Job) LookupTask(tp string) *Task {
 func (j *Job) Stub(job *Job) *JobListStub {
 	return &JobListStub{
 		ID:                j.ID,
+		Namespace:         j.Namespace,
 		Name:              j.Name,
+		Labels:            j.Labels,
 		Type:              j.Type,
 		Status:            j.Status,
 		StatusDescription: j.StatusDescription,
+		Health:            j.Health,
 		CreateIndex:       j.CreateIndex,
 		ModifyIndex:       j.ModifyIndex,
 		JobModifyIndex:    j.JobModifyIndex,
@@ -197,14 +274,253 @@ func (j *Job) Stub(job *Job) *JobListStub {
 	}
 }
 
+const (
+	// RestartPolicyModeDelay causes an artificial delay till the next interval is
+	// reached when the specified attempts have been reached in the interval.
+	RestartPolicyModeDelay = "delay"
+
+	// RestartPolicyModeFail causes a task to fail if the specified attempts are
+	// reached within an interval.
+	RestartPolicyModeFail = "fail"
+)
+
+// RestartPolicy configures how tasks are restarted on the client when they
+// fail or exit.
+type RestartPolicy struct {
+	// Attempts is the number of restarts that will occur in an interval.
+	Attempts int
+
+	// Interval is a duration in which we can limit the number of restarts
+	// within.
+	Interval time.Duration
+
+	// Delay is the base time to wait before restarting a task. It is doubled
+	// on each consecutive attempt within an interval.
+	Delay time.Duration
+
+	// MaxDelay caps the backoff computed from Delay.
+	MaxDelay time.Duration
+
+	// Mode controls what happens when the task restarts more than attempt times
+	// in an interval.
+	Mode string
+}
+
+// DefaultRestartPolicy returns the restart policy used when a job doesn't
+// specify one.
+func DefaultRestartPolicy() *RestartPolicy {
+	return &RestartPolicy{
+		Attempts: 5,
+		Interval: 1 * time.Minute,
+		Delay:    15 * time.Second,
+		MaxDelay: 15 * time.Second,
+		Mode:     RestartPolicyModeDelay,
+	}
+}
+
+// Canonicalize sets the fields of the policy left unset to those of the
+// DefaultRestartPolicy. MaxDelay is at least Delay.
+func (r *RestartPolicy) Canonicalize() {
+	d := DefaultRestartPolicy()
+	if r.Attempts == 0 {
+		r.Attempts = d.Attempts
+	}
+	if r.Interval == 0 {
+		r.Interval = d.Interval
+	}
+	if r.Delay == 0 {
+		r.Delay = d.Delay
+	}
+	if r.MaxDelay == 0 {
+		r.MaxDelay = d.MaxDelay
+		if r.MaxDelay < r.Delay {
+			r.MaxDelay = r.Delay
+		}
+	}
+	if r.Mode == "" {
+		r.Mode = d.Mode
+	}
+}
+
+func (r *RestartPolicy) Copy() *RestartPolicy {
+	if r == nil {
+		return nil
+	}
+	nr := new(RestartPolicy)
+	*nr = *r
+	return nr
+}
+
+func (r *RestartPolicy) Validate() error {
+	var mErr multierror.Error
+	switch r.Mode {
+	case RestartPolicyModeDelay, RestartPolicyModeFail:
+	default:
+		mErr.Errors = append(mErr.Errors, fmt.Errorf("Unsupported restart mode: %q", r.Mode))
+	}
+
+	if r.Attempts < 0 {
+		mErr.Errors = append(mErr.Errors, fmt.Errorf("Restart attempts can't be negative: %v", r.Attempts))
+	}
+	if r.Attempts > 0 && r.Interval <= 0 {
+		mErr.Errors = append(mErr.Errors, fmt.Errorf("Restart interval must be positive: %v", r.Interval))
+	}
+	if r.Delay < 0 {
+		mErr.Errors = append(mErr.Errors, fmt.Errorf("Restart delay can't be negative: %v", r.Delay))
+	}
+	if r.MaxDelay != 0 && r.MaxDelay < r.Delay {
+		mErr.Errors = append(mErr.Errors, fmt.Errorf("Restart max delay (%v) is less than delay (%v)", r.MaxDelay, r.Delay))
+	}
+	return mErr.ErrorOrNil()
+}
+
+// Backoff returns the delay before the given restart attempt, doubling Delay
+// for each previous attempt and capping it at MaxDelay.
+func (r *RestartPolicy) Backoff(attempt int) time.Duration {
+	d := r.Delay
+	for i := 1; i < attempt; i++ {
+		d *= 2
+		if r.MaxDelay > 0 && d >= r.MaxDelay {
+			return r.MaxDelay
+		}
+	}
+	if r.MaxDelay > 0 && d > r.MaxDelay {
+		return r.MaxDelay
+	}
+	return d
+}
+
+// ReschedulePolicy configures how the server places a task on another node
+// after it failed on its current one.
+type ReschedulePolicy struct {
+	// Attempts is the number of reschedules allowed in an interval.
+	Attempts int
+
+	// Interval is the window in which Attempts is counted.
+	Interval time.Duration
+
+	// Delay is the base time to wait before placing the replacement. It is
+	// doubled for each previous reschedule within the interval.
+	Delay time.Duration
+
+	// MaxDelay caps the backoff computed from Delay.
+	MaxDelay time.Duration
+
+	// OnFailure reschedules tasks that failed on the client after the
+	// restart policy was exhausted.
+	OnFailure bool
+
+	// OnLostNode reschedules tasks whose node went down.
+	OnLostNode bool
+}
+
+// DefaultReschedulePolicy returns the reschedule policy used when a job
+// doesn't specify one.
+func DefaultReschedulePolicy() *ReschedulePolicy {
+	return &ReschedulePolicy{
+		Attempts: 2,
+		Interval: 1 * time.Hour,
+		Delay:    30 * time.Second,
+		MaxDelay: 10 * time.Minute,
+	}
+}
+
+// Canonicalize sets the fields of the policy left unset to those of the
+// DefaultReschedulePolicy. MaxDelay is at least Delay.
+func (r *ReschedulePolicy) Canonicalize() {
+	d := DefaultReschedulePolicy()
+	if r.Attempts == 0 {
+		r.Attempts = d.Attempts
+	}
+	if r.Interval == 0 {
+		r.Interval = d.Interval
+	}
+	if r.Delay == 0 {
+		r.Delay = d.Delay
+	}
+	if r.MaxDelay == 0 {
+		r.MaxDelay = d.MaxDelay
+		if r.MaxDelay < r.Delay {
+			r.MaxDelay = r.Delay
+		}
+	}
+}
+
+func (r *ReschedulePolicy) Copy() *ReschedulePolicy {
+	if r == nil {
+		return nil
+	}
+	nr := new(ReschedulePolicy)
+	*nr = *r
+	return nr
+}
+
+func (r *ReschedulePolicy) Validate() error {
+	var mErr multierror.Error
+	if r.Attempts < 0 {
+		mErr.Errors = append(mErr.Errors, fmt.Errorf("Reschedule attempts can't be negative: %v", r.Attempts))
+	}
+	if r.Attempts > 0 && r.Interval <= 0 {
+		mErr.Errors = append(mErr.Errors, fmt.Errorf("Reschedule interval must be positive: %v", r.Interval))
+	}
+	if r.Delay < 0 {
+		mErr.Errors = append(mErr.Errors, fmt.Errorf("Reschedule delay can't be negative: %v", r.Delay))
+	}
+	if r.MaxDelay != 0 && r.MaxDelay < r.Delay {
+		mErr.Errors = append(mErr.Errors, fmt.Errorf("Reschedule max delay (%v) is less than delay (%v)", r.MaxDelay, r.Delay))
+	}
+	return mErr.ErrorOrNil()
+}
+
+// Backoff returns the delay before the given reschedule attempt.
+func (r *ReschedulePolicy) Backoff(attempt int) time.Duration {
+	rp := &RestartPolicy{Delay: r.Delay, MaxDelay: r.MaxDelay}
+	return rp.Backoff(attempt)
+}
+
+// AlertPolicy sets the thresholds past which a job is degraded, which is
+// notified without stopping the replication. A zero threshold is not
+// checked.
+type AlertPolicy struct {
+	// MaxSecondsBehind is how far behind its source the target of the job
+	// may be, measured by the heartbeats of the source.
+	MaxSecondsBehind int64
+
+	// MaxErrorsPerMinute is the error events the job may have in a minute.
+	MaxErrorsPerMinute int
+}
+
+func (a *AlertPolicy) Copy() *AlertPolicy {
+	if a == nil {
+		return nil
+	}
+	na := new(AlertPolicy)
+	*na = *a
+	return na
+}
+
+func (a *AlertPolicy) Validate() error {
+	var mErr multierror.Error
+	if a.MaxSecondsBehind < 0 {
+		mErr.Errors = append(mErr.Errors, fmt.Errorf("Max seconds behind can't be negative: %v", a.MaxSecondsBehind))
+	}
+	if a.MaxErrorsPerMinute < 0 {
+		mErr.Errors = append(mErr.Errors, fmt.Errorf("Max errors per minute can't be negative: %v", a.MaxErrorsPerMinute))
+	}
+	return mErr.ErrorOrNil()
+}
+
 // JobListStub is used to return a subset of job information
 // for the job list
 type JobListStub struct {
 	ID                string
+	Namespace         string
 	Name              string
+	Labels            map[string]string
 	Type              string
 	Status            string
 	StatusDescription string
+	Health            string
 	JobSummary        *Job
 	CreateIndex       uint64
 	ModifyIndex       uint64
@@ -247,6 +563,10 @@ type JobPlanResponse struct {
 	// since the last plan. If the job is being created, the value is zero.
 	JobModifyIndex uint64
 
+	// Diff is the annotated diff from the registered job to the planned
+	// one. It is only set if requested.
+	Diff *JobDiff
+
 	// CreatedEvals is the set of evaluations created by the scheduler. The
 	// reasons for this can be rolling-updates or blocked evals.
 	CreatedEvals []*Evaluation
@@ -304,14 +624,45 @@ type JobSpecificRequest struct {
 
 // JobListRequest is used to parameterize a list request
 type JobListRequest struct {
+	// NamePrefix, Statuses and Labels filter the listed jobs. A job matches
+	// if its name has the prefix, its status is one of the statuses and it
+	// has every label.
+	NamePrefix string
+	Statuses   []string
+	Labels     map[string]string
 	QueryOptions
 }
 
+// Matches returns whether a job passes the filters of the request
+func (r *JobListRequest) Matches(job *Job) bool {
+	if !strings.HasPrefix(job.Name, r.NamePrefix) {
+		return false
+	}
+	if len(r.Statuses) > 0 {
+		found := false
+		for _, status := range r.Statuses {
+			if job.Status == status {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	for k, v := range r.Labels {
+		if lv, ok := job.Labels[k]; !ok || lv != v {
+			return false
+		}
+	}
+	return true
+}
+
 // JobPlanRequest is used for the Job.Plan endpoint to trigger a dry-run
 // evaluation of the Job.
 type JobPlanRequest struct {
-	Job *Job
-	//Diff bool // Toggles an annotated diff
+	Job  *Job
+	Diff bool // Toggles an annotated diff
 	WriteRequest
 }
 
@@ -339,6 +690,151 @@ type JobValidateResponse struct {
 	Error string
 }
 
+// TableEstimateRequest is used to estimate the tables a source task would
+// replicate, before submitting its job
+type TableEstimateRequest struct {
+	Task *Task
+	WriteRequest
+}
+
+// TableEstimateResponse is the response from an estimate request
+type TableEstimateResponse struct {
+	Tables []*TableEstimate
+
+	// TotalRows and TotalDataLength sum those of the tables
+	TotalRows       int64
+	TotalDataLength int64
+}
+
+// TableEstimate is the estimated size of a table, read from the statistics
+// of the source rather than by counting its rows
+type TableEstimate struct {
+	TableSchema string
+	TableName   string
+	Engine      string
+
+	EstimatedRows int64
+	// DataLength and IndexLength are in bytes
+	DataLength  int64
+	IndexLength int64
+
+	HasPrimaryKey bool
+	// HasUniqueKey is set if the table has a primary key or a unique key
+	// of non-nullable columns. The full copy reads the chunks of the other
+	// tables with LIMIT and OFFSET, which gets slow on large tables.
+	HasUniqueKey bool
+}
+
+// JobCutoverRequest is used to cut the applications of a job over from its
+// source to its target
+type JobCutoverRequest struct {
+	JobID string
+	// MaxLag is the transactions the job may be behind before the source is
+	// set read-only
+	MaxLag int64
+	// ReadOnly sets the source super_read_only once the job has caught up
+	ReadOnly bool
+	// Timeout bounds each wait, in seconds
+	Timeout int
+	WriteRequest
+}
+
+// JobCutoverResponse is the response from a cutover request
+type JobCutoverResponse struct {
+	// CutoverGtid is the GTID set of the source the target has applied
+	CutoverGtid string
+	// TargetGtid is the GTID set executed on the target at the cutover
+	TargetGtid string
+	// SourceReadOnly is set if the source was set read-only
+	SourceReadOnly bool
+	// LagWait and SyncWait are the seconds spent waiting for the job to
+	// catch up and to apply the last transactions
+	LagWait  float64
+	SyncWait float64
+}
+
+// JobSkipDDLRequest declares a DDL applied manually on the target of a job,
+// which the job skips once it replicates it
+type JobSkipDDLRequest struct {
+	JobID string
+	// Query is the DDL as executed on the source
+	Query string
+	WriteRequest
+}
+
+// JobSkipDDLResponse is the response from a skip DDL request
+type JobSkipDDLResponse struct {
+	// Checksum is that of the DDL, which the DDLs replicated are matched by
+	Checksum string
+}
+
+// JobSkippedDDLsResponse lists the DDLs a job has yet to skip
+type JobSkippedDDLsResponse struct {
+	DDLs []*SkippedDDL
+	QueryMeta
+}
+
+// SkippedDDL is a DDL applied manually on the target of a job
+type SkippedDDL struct {
+	Checksum string
+	Query    string
+	// DeclaredAt is the time the DDL was declared, as the target shows it
+	DeclaredAt string
+}
+
+// The statuses of the DDLs a job replicates
+const (
+	// DDLStatusApplied is a DDL executed on the target. Its Error is set if
+	// the target failed it with an error that is ignored.
+	DDLStatusApplied = "applied"
+	// DDLStatusSkipped is a DDL not executed on the target, applied
+	// manually there or unsupported by it
+	DDLStatusSkipped = "skipped"
+	// DDLStatusFailed is a DDL the target failed, stopping the job
+	DDLStatusFailed = "failed"
+)
+
+// DDLRecord is a DDL a job replicated to its target
+type DDLRecord struct {
+	// Gtid is that of the transaction of the DDL on the source
+	Gtid        string
+	TableSchema string
+	TableName   string
+	Query       string
+	Status      string
+	// ExecMillis is how long the target took to execute the DDL
+	ExecMillis int64
+	Error      string
+	// Time is when the DDL was replicated, as the target shows it
+	Time string
+}
+
+// SchemaVersion is the definition of a table as the source had it once it
+// executed a DDL replicated by a job
+type SchemaVersion struct {
+	// Gtid is that of the transaction of the DDL on the source
+	Gtid        string
+	TableSchema string
+	TableName   string
+	Query       string
+	Columns     *umconf.ColumnList
+	// Time is the unix nano time the DDL was applied on the target at
+	Time int64
+}
+
+// JobSchemaHistoryResponse lists the versions of the tables of a job, the
+// oldest first
+type JobSchemaHistoryResponse struct {
+	Versions []*SchemaVersion
+	QueryMeta
+}
+
+// JobDDLHistoryResponse lists the last DDLs a job replicated
+type JobDDLHistoryResponse struct {
+	DDLs []*DDLRecord
+	QueryMeta
+}
+
 type TaskValidateResponse struct {
 	Type string
 
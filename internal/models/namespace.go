@@ -0,0 +1,143 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package models
+
+import (
+	"fmt"
+	"regexp"
+)
+
+const (
+	// DefaultNamespace is the namespace jobs are registered in when none
+	// is given. It always exists and can not be deleted.
+	DefaultNamespace = "default"
+
+	// maxNamespaceDescriptionLength limits the size of a namespace description
+	maxNamespaceDescriptionLength = 256
+)
+
+var (
+	// validNamespaceName is used to validate a namespace name
+	validNamespaceName = regexp.MustCompile("^[a-zA-Z0-9-]{1,128}$")
+)
+
+// Namespace allows several teams to share a cluster. Job names are unique
+// within a namespace and the jobs of a namespace are limited by its quota.
+type Namespace struct {
+	// Name is the name of the namespace
+	Name string
+
+	// Description is a human readable description of the namespace
+	Description string
+
+	// Quota limits the jobs of the namespace. A nil quota is unlimited.
+	Quota *NamespaceQuota
+
+	// Raft Indexes
+	CreateIndex uint64
+	ModifyIndex uint64
+}
+
+// NamespaceQuota limits the resources used by the jobs of a namespace. A
+// zero value means no limit.
+type NamespaceQuota struct {
+	// MaxJobs is the number of jobs that may be pending or running at the
+	// same time
+	MaxJobs int
+
+	// MaxBandwidth is the aggregate replication bandwidth of the jobs, in
+	// bytes per second
+	MaxBandwidth int64
+}
+
+// Validate returns an error if the namespace is invalid
+func (n *Namespace) Validate() error {
+	if !validNamespaceName.MatchString(n.Name) {
+		return fmt.Errorf("invalid namespace name %q: must match %s", n.Name, validNamespaceName)
+	}
+	if len(n.Description) > maxNamespaceDescriptionLength {
+		return fmt.Errorf("namespace description longer than %d", maxNamespaceDescriptionLength)
+	}
+	if q := n.Quota; q != nil {
+		if q.MaxJobs < 0 {
+			return fmt.Errorf("quota max_jobs must not be negative")
+		}
+		if q.MaxBandwidth < 0 {
+			return fmt.Errorf("quota max_bandwidth must not be negative")
+		}
+	}
+	return nil
+}
+
+func (n *Namespace) Copy() *Namespace {
+	if n == nil {
+		return nil
+	}
+	nn := new(Namespace)
+	*nn = *n
+	if n.Quota != nil {
+		nq := *n.Quota
+		nn.Quota = &nq
+	}
+	return nn
+}
+
+// NamespaceUpsertRequest is used to create or update a set of namespaces
+type NamespaceUpsertRequest struct {
+	Namespaces []*Namespace
+	WriteRequest
+}
+
+// NamespaceDeleteRequest is used to delete a set of namespaces
+type NamespaceDeleteRequest struct {
+	Namespaces []string
+	WriteRequest
+}
+
+// NamespaceListRequest is used to request a list of namespaces
+type NamespaceListRequest struct {
+	QueryOptions
+}
+
+// NamespaceSpecificRequest is used to query a specific namespace
+type NamespaceSpecificRequest struct {
+	Name string
+	QueryOptions
+}
+
+// NamespaceListResponse is used for a list request
+type NamespaceListResponse struct {
+	Namespaces []*Namespace
+	QueryMeta
+}
+
+// SingleNamespaceResponse is used to return a single namespace
+type SingleNamespaceResponse struct {
+	Namespace *Namespace
+	QueryMeta
+}
+
+// NamespaceAllowanceRequest is used by the clients to query the share of
+// the namespace quota each of its tasks may use
+type NamespaceAllowanceRequest struct {
+	Namespace string
+	QueryOptions
+}
+
+// NamespaceAllowanceResponse returns the share of the namespace quota of
+// each task. The bandwidth quota is split evenly between the running
+// source tasks of the namespace, since they publish the replicated data.
+type NamespaceAllowanceResponse struct {
+	// Tasks is the number of source tasks sharing the quota
+	Tasks int
+
+	// TaskBandwidth is the bandwidth of each task in bytes per second, 0
+	// when the namespace has no bandwidth quota
+	TaskBandwidth int64
+
+	QueryMeta
+}
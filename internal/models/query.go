@@ -39,6 +39,9 @@ const (
 	EvalDeleteRequestType
 	AllocUpdateRequestType
 	AllocClientUpdateRequestType
+	NamespaceUpsertRequestType
+	NamespaceDeleteRequestType
+	JobEventsUpsertRequestType
 )
 
 const (
@@ -75,6 +78,14 @@ type QueryOptions struct {
 
 	// If set, used as prefix for resource list searches
 	Prefix string
+
+	// If set, only the resources of the namespace are listed
+	Namespace string
+
+	// PerPage is the most resources a list returns, 0 for all. The next
+	// page starts at the NextToken of the QueryMeta of the previous one.
+	PerPage   int
+	NextToken string
 }
 
 func (q QueryOptions) RequestRegion() string {
@@ -93,6 +104,18 @@ func (q QueryOptions) AllowStaleRead() bool {
 type WriteRequest struct {
 	// The target region for this write
 	Region string
+
+	// Time is the unix nano time the leader applied the write at, stamped
+	// before it goes through raft for every server to apply it at the same
+	// time
+	Time int64
+}
+
+// StampTime sets the Time of the write, unless it is already set
+func (w *WriteRequest) StampTime(now int64) {
+	if w.Time == 0 {
+		w.Time = now
+	}
 }
 
 func (w WriteRequest) RequestRegion() string {
@@ -122,6 +145,10 @@ type QueryMeta struct {
 
 	// Used to indicate if there is a known leader node
 	KnownLeader bool
+
+	// NextToken is the token of the next page of a paginated list, empty
+	// on the last page
+	NextToken string
 }
 
 // WriteMeta allows a write response to include potentially
@@ -294,3 +321,23 @@ type RaftRemovePeerRequest struct {
 	// WriteRequest holds the ACL token to go along with this request.
 	WriteRequest
 }
+
+// SnapshotSaveResponse is used by the Operator endpoint to return a snapshot
+// of the server state.
+type SnapshotSaveResponse struct {
+	// Index is the raft index the snapshot was taken at.
+	Index uint64
+
+	// Data is the gzipped snapshot archive.
+	Data []byte
+}
+
+// SnapshotRestoreRequest is used by the Operator endpoint to replace the
+// server state with a snapshot taken by SnapshotSave.
+type SnapshotRestoreRequest struct {
+	// Data is the gzipped snapshot archive.
+	Data []byte
+
+	// WriteRequest holds the Region for this request.
+	WriteRequest
+}
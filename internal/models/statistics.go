@@ -7,10 +7,15 @@
 package models
 
 import (
+	"fmt"
+	"strings"
+	"time"
+
 	gonats "github.com/nats-io/go-nats"
 )
 
 const (
+	StageBlockedOnTarget                               = "Blocked: waiting for the target to be reachable"
 	StageFinishedReadingOneBinlogSwitchingToNextBinlog = "Finished reading one binlog; switching to next binlog"
 	StageMasterHasSentAllBinlogToSlave                 = "Master has sent all binlog to slave; waiting for more updates"
 	StageRegisteringSlaveOnMaster                      = "Registering slave on master"
@@ -24,6 +29,16 @@ const (
 	StageWaitingForMasterToSendEvent                   = "Waiting for master to send event"
 )
 
+// The apply modes of the target tasks
+const (
+	// ApplyModeParallel applies the transactions which do not depend on
+	// each other on the source concurrently, with ParallelWorkers workers
+	ApplyModeParallel = "parallel"
+	// ApplyModeStrictOrder applies the transactions one at a time, in the
+	// commit order of the source
+	ApplyModeStrictOrder = "strict-order"
+)
+
 type TableStats struct {
 	InsertCount int64
 	UpdateCount int64
@@ -53,6 +68,208 @@ type BufferStat struct {
 	ApplierGroupTxQueueSize int
 	SendByTimeout           int
 	SendBySizeFull          int
+	// GroupSize is the size the extractor sends the groups of entries at
+	GroupSize int
+	// Queues are the depths and capacities of the queues of the task, in
+	// the order the entries go through them
+	Queues []QueueStat
+}
+
+// QueueStat is the depth of a queue of a task
+type QueueStat struct {
+	Name     string
+	Depth    int
+	Capacity int
+}
+
+// SchemaMapping is a change made to the definition of a table the applier
+// created, as of the CreateTable* parameters. ColumnName is empty for the
+// table options and the partitioning, Target empty for a stripped clause.
+type SchemaMapping struct {
+	TableSchema string
+	TableName   string
+	ColumnName  string
+	Source      string
+	Target      string
+}
+
+// DDLRewrite is a DDL of the source the applier adapted to the version of
+// the target, by the rules named. In dry run, Query was applied as is.
+type DDLRewrite struct {
+	Rules     []string
+	Query     string
+	Rewritten string
+	DryRun    bool
+}
+
+// NetworkStat counts the bytes a task exchanged with MySQL, and in total
+// along with the messages with the other task of the job, as in MsgStat
+type NetworkStat struct {
+	BytesIn       int64
+	BytesOut      int64
+	MySQLBytesIn  int64
+	MySQLBytesOut int64
+}
+
+// HeartbeatStat describes the last heartbeat of the extractor a task sent or
+// received. A growing Age means the replication is stuck, while a small Age
+// along with no new transactions means the source is idle.
+type HeartbeatStat struct {
+	// Time is the unix nano time of the last heartbeat
+	Time int64
+
+	// Age is the milliseconds since the last heartbeat
+	Age int64
+
+	// Lag is the milliseconds between the source last being read and the
+	// last heartbeat
+	Lag int64
+
+	// Gtid is the last transaction read from the source at the heartbeat
+	Gtid string
+}
+
+// LatencyStat is the end-to-end latency of the transactions applied by a
+// task, and the scale of the batching adjusted to the LatencyTargetMs of the
+// job
+type LatencyStat struct {
+	// TargetMs is the LatencyTargetMs of the job
+	TargetMs int64
+
+	// P99Ms is the p99 of the milliseconds from the transactions being read
+	// from the source to them being committed, over the last interval
+	P99Ms int64
+
+	// Scale is the factor the configured batch sizes and timeouts are
+	// scaled by, within 1/64 and 1
+	Scale float64
+}
+
+// TxControlStat counts the XA and savepoint statements the extractor read.
+// Prepared XA transactions are applied on prepare, so a rolled back one
+// leaves its rows on the target.
+type TxControlStat struct {
+	XaPrepared   int64
+	XaCommitted  int64
+	XaRolledBack int64
+	// Savepoints counts the savepoint statements skipped
+	Savepoints int64
+}
+
+// TableApplyStat counts the rows the applier applied to a table in the
+// incremental copy. The tables taking the most time to apply come first in
+// TaskStatistics.
+type TableApplyStat struct {
+	TableSchema string
+	TableName   string
+	InsertCount int64
+	UpdateCount int64
+	DeleteCount int64
+	// Bytes is the size of the values applied
+	Bytes int64
+	// TotalApplyMillis is the time spent executing the rows on the target
+	TotalApplyMillis int64
+	// AvgApplyMicros is the average time to execute a row on the target
+	AvgApplyMicros int64
+}
+
+// SlowApply is a statement of the incremental copy that took longer than
+// the SlowApplyThreshold to apply, such as one missing an index on the
+// target
+type SlowApply struct {
+	// Time is the unix nano time the statement was applied at
+	Time        int64
+	Gtid        string
+	TableSchema string
+	TableName   string
+	// Query is the statement with its arguments, truncated
+	Query       string
+	ApplyMillis int64
+}
+
+// TopicMapping is the topic of a table whose name is not valid as is in a
+// topic name, made valid by the TopicNaming of a Kafka target
+type TopicMapping struct {
+	TableSchema string
+	TableName   string
+	Topic       string
+}
+
+// TableConsistency scores the consistency of a table by the ranges of its
+// rows the consistency check sampled on the source and the target
+type TableConsistency struct {
+	TableSchema string
+	TableName   string
+	Samples     int64
+	Mismatches  int64
+	// Score is the percentage of the samples which matched
+	Score float64
+	// LastMismatch is the last range which differed, as its bounds
+	LastMismatch string
+	// Error is that of the last sample, which is not scored
+	Error          string
+	LastSampleTime int64
+}
+
+// RowCountReconciliation compares the rows of each table read by the
+// snapshot of a job, on the source at the snapshot GTID, to the rows on the
+// target once loaded, or to the messages emitted to it
+type RowCountReconciliation struct {
+	// Gtid is the GTID set of the snapshot
+	Gtid   string
+	Tables []*TableRowCount
+	// Mismatches is the number of tables whose counts differ
+	Mismatches int
+	Message    string
+	// Time is the unix nano time the counts were compared at
+	Time int64
+}
+
+// TableRowCount is the rows of a table read by a snapshot and those loaded
+type TableRowCount struct {
+	TableSchema string
+	TableName   string
+	SourceRows  int64
+	TargetRows  int64
+	// Error is that of counting the rows of the target, if any
+	Error string
+}
+
+// NewRowCountReconciliation compares the source and target rows of the
+// tables of a snapshot
+func NewRowCountReconciliation(gtid string, tables []*TableRowCount) *RowCountReconciliation {
+	r := &RowCountReconciliation{
+		Gtid:   gtid,
+		Tables: tables,
+		Time:   time.Now().UnixNano(),
+	}
+	var mismatches []string
+	for _, t := range tables {
+		if t.SourceRows != t.TargetRows || t.Error != "" {
+			mismatches = append(mismatches, fmt.Sprintf("%s.%s (%d rows on the source, %d on the target)",
+				t.TableSchema, t.TableName, t.SourceRows, t.TargetRows))
+		}
+	}
+	r.Mismatches = len(mismatches)
+	if r.Mismatches == 0 {
+		r.Message = fmt.Sprintf("the row counts of the %d tables of the snapshot match", len(tables))
+	} else {
+		r.Message = fmt.Sprintf("the row counts of %d of the %d tables of the snapshot differ: %s",
+			r.Mismatches, len(tables), strings.Join(mismatches, ", "))
+	}
+	return r
+}
+
+// IndexAdvisory suggests an index on a table of the target whose updates
+// and deletes are applied by full scans, none of its columns being indexed
+type IndexAdvisory struct {
+	TableSchema string
+	TableName   string
+	// ScanCount is the rows updated or deleted by a full scan
+	ScanCount int64
+	// AvgScanMillis is the average time of the scans
+	AvgScanMillis int64
+	Message       string
 }
 
 type CurrentCoordinates struct {
@@ -70,6 +287,17 @@ type TaskStatistics struct {
 	CurrentCoordinates *CurrentCoordinates
 	TableStats         *TableStats
 	DelayCount         *DelayCount
+	Heartbeat          *HeartbeatStat
+	Latency            *LatencyStat
+	TxControl          *TxControlStat
+	TableApplyStats    []*TableApplyStat
+	SchemaMappings     []*SchemaMapping
+	DDLRewrites        []*DDLRewrite
+	SlowApplies        []*SlowApply
+	IndexAdvisories    []*IndexAdvisory
+	Reconciliation     *RowCountReconciliation
+	Consistency        []*TableConsistency
+	TopicMappings      []*TopicMapping
 	ProgressPct        string
 	ExecMasterRowCount int64
 	ExecMasterTxCount  int64
@@ -80,7 +308,9 @@ type TaskStatistics struct {
 	ThroughputStat     *ThroughputStat
 	MsgStat            gonats.Statistics
 	BufferStat         BufferStat
+	NetworkStat        *NetworkStat
 	Stage              string
+	ApplyMode          string
 	Timestamp          int64
 }
 
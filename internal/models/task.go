@@ -10,6 +10,7 @@ import (
 	"errors"
 	"fmt"
 	"os"
+	"runtime/debug"
 	"strings"
 	"time"
 
@@ -22,9 +23,16 @@ const (
 	TaskTypeSrc  = "Src"
 	TaskTypeDest = "Dest"
 
-	TaskDriverMySQL  = "MySQL"
-	TaskDriverKafka  = "Kafka"
-	TaskDriverOracle = "Oracle"
+	TaskDriverMySQL     = "MySQL"
+	TaskDriverKafka     = "Kafka"
+	TaskDriverOracle    = "Oracle"
+	TaskDriverSQLServer = "SQLServer"
+	TaskDriverMongoDB   = "MongoDB"
+	TaskDriverPolling   = "Polling"
+	TaskDriverWebhook   = "Webhook"
+	TaskDriverJetStream = "JetStream"
+	TaskDriverEmbedded  = "Embedded"
+	TaskDriverScript    = "Script"
 )
 
 // Task is a single process typically that is executed as part of a task.
@@ -219,6 +227,44 @@ const (
 
 	// TaskLeaderDead indicates that the leader task within the has finished.
 	TaskLeaderDead = "Leader Task Dead"
+
+	// TaskSnapshotStarted indicates that the task started copying the
+	// existing rows of the source.
+	TaskSnapshotStarted = "Snapshot Started"
+
+	// TaskSnapshotFinished indicates that the task finished copying the
+	// existing rows of the source.
+	TaskSnapshotFinished = "Snapshot Finished"
+
+	// TaskStreaming indicates that the task is replicating binlog events.
+	TaskStreaming = "Streaming"
+
+	// TaskIndexAdvisory indicates that the task applies the changes of a
+	// table by full scans, for lack of an index on the target.
+	TaskIndexAdvisory = "Index Advisory"
+
+	// TaskBlocked indicates that the task waits for its target, unreachable
+	// for longer than its outage timeout.
+	TaskBlocked = "Blocked"
+
+	// TaskUnblocked indicates that the target of a blocked task is back.
+	TaskUnblocked = "Unblocked"
+
+	// TaskLagging indicates that the task is further behind its source
+	// than the MaxSecondsBehind of the alert policy of its job.
+	TaskLagging = "Lagging"
+
+	// TaskCaughtUp indicates that a lagging task is back within the
+	// MaxSecondsBehind of the alert policy of its job.
+	TaskCaughtUp = "Caught Up"
+
+	// TaskRowCountsReconciled indicates that the rows of the tables loaded
+	// by the snapshot match those read from the source.
+	TaskRowCountsReconciled = "Row Counts Reconciled"
+
+	// TaskRowCountMismatch indicates that the rows of some tables loaded by
+	// the snapshot differ from those read from the source.
+	TaskRowCountMismatch = "Row Count Mismatch"
 )
 
 // TaskEvent is an event that effects the state of a task and contains meta-data
@@ -242,6 +288,12 @@ type TaskEvent struct {
 	// Task Terminated Fields.
 	ExitCode int    // The exit code of the task.
 	Message  string // A possible message explaining the termination of the task.
+	// ErrorClass is the class of the error the task terminated with, such
+	// as "retryable", "data" or "terminal", if known
+	ErrorClass string
+	// CrashReport is the stack trace of the panic the task terminated with,
+	// if it panicked
+	CrashReport string
 
 	// Killing fields
 	KillTimeout time.Duration
@@ -315,6 +367,7 @@ func (e *TaskEvent) SetFailsTask() *TaskEvent {
 func (e *TaskEvent) SetDriverError(err error) *TaskEvent {
 	if err != nil {
 		e.DriverError = err.Error()
+		e.setCrashReport(err)
 	}
 	return e
 }
@@ -327,10 +380,21 @@ func (e *TaskEvent) SetExitCode(c int) *TaskEvent {
 func (e *TaskEvent) SetExitMessage(err error) *TaskEvent {
 	if err != nil {
 		e.Message = err.Error()
+		if classified, ok := err.(interface{ ErrorClass() string }); ok {
+			e.ErrorClass = classified.ErrorClass()
+		}
+		e.setCrashReport(err)
 	}
 	return e
 }
 
+// setCrashReport sets the CrashReport of the event if err is a PanicError
+func (e *TaskEvent) setCrashReport(err error) {
+	if crashed, ok := err.(*PanicError); ok {
+		e.CrashReport = crashed.Stack
+	}
+}
+
 func (e *TaskEvent) SetKillError(err error) *TaskEvent {
 	if err != nil {
 		e.KillError = err.Error()
@@ -387,6 +451,9 @@ type TaskUpdate struct {
 	JobID    string
 	Gtid     string
 	NatsAddr string
+	// SchemaHistory is the versions of the tables the target applied, up
+	// to the Gtid
+	SchemaHistory []*SchemaVersion
 }
 
 const (
@@ -416,6 +483,40 @@ func (r *WaitResult) ShouldRestart() bool {
 	return r.ExitCode == 1 && r.Err != nil
 }
 
+// PanicError is the error of a task that panicked. The task is stopped and
+// restarted according to its restart policy, rather than the panic taking
+// down the agent.
+type PanicError struct {
+	// Value is the value the task panicked with
+	Value string
+	// Stack is the stack trace of the goroutine that panicked
+	Stack string
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("panic: %s", e.Value)
+}
+
+// IsRecoverable lets the task be restarted if it panicked while starting
+func (e *PanicError) IsRecoverable() bool {
+	return true
+}
+
+// RecoverPanic recovers the panic of the goroutine it is deferred in, if
+// any, and passes it to onPanic as a PanicError. It must be deferred
+// directly for the panic to be recovered:
+//
+//	defer models.RecoverPanic(a.onPanic)
+//
+// The onPanic of the drivers stops the task with its restart state, the
+// worker then logging the stack of the panic and restarting the task
+// according to the restart policy of its job.
+func RecoverPanic(onPanic func(err *PanicError)) {
+	if p := recover(); p != nil {
+		onPanic(&PanicError{Value: fmt.Sprint(p), Stack: string(debug.Stack())})
+	}
+}
+
 func (r *WaitResult) String() string {
 	return fmt.Sprintf("Wait returned exit code %v, and error %v",
 		r.ExitCode, r.Err)
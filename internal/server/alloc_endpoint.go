@@ -46,12 +46,20 @@ func (a *Alloc) List(args *models.AllocListRequest, reply *models.AllocListRespo
 			}
 
 			var allocs []*models.AllocListStub
+			reply.NextToken = ""
 			for {
 				raw := iter.Next()
 				if raw == nil {
 					break
 				}
 				alloc := raw.(*models.Allocation)
+				if alloc.ID < args.QueryOptions.NextToken || !allocClientStatusIn(alloc, args.ClientStatuses) {
+					continue
+				}
+				if perPage := args.QueryOptions.PerPage; perPage > 0 && len(allocs) == perPage {
+					reply.NextToken = alloc.ID
+					break
+				}
 				allocs = append(allocs, alloc.Stub())
 			}
 			reply.Allocations = allocs
@@ -70,6 +78,20 @@ func (a *Alloc) List(args *models.AllocListRequest, reply *models.AllocListRespo
 	return a.srv.blockingRPC(&opts)
 }
 
+// allocClientStatusIn returns whether the client status of an allocation is
+// one of some statuses, true if there are none
+func allocClientStatusIn(alloc *models.Allocation, statuses []string) bool {
+	if len(statuses) == 0 {
+		return true
+	}
+	for _, status := range statuses {
+		if alloc.ClientStatus == status {
+			return true
+		}
+	}
+	return false
+}
+
 // GetAlloc is used to lookup a particular allocation
 func (a *Alloc) GetAlloc(args *models.AllocSpecificRequest,
 	reply *models.SingleAllocResponse) error {
@@ -0,0 +1,127 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package server
+
+import (
+	"net"
+	"time"
+
+	"github.com/hashicorp/raft"
+	"github.com/hashicorp/serf/serf"
+)
+
+// autopilotRaft is the Raft autopilot removes the dead servers from, as
+// *raft.Raft
+type autopilotRaft interface {
+	GetConfiguration() raft.ConfigurationFuture
+	RemovePeer(peer raft.ServerAddress) raft.Future
+}
+
+// autopilotSerf is the Serf autopilot sees the failed servers in, as
+// *serf.Serf
+type autopilotSerf interface {
+	Members() []serf.Member
+	RemoveFailedNode(node string) error
+}
+
+// autopilotLoop runs as long as we are the leader and periodically removes
+// dead servers from the Raft configuration.
+func (s *Server) autopilotLoop(stopCh chan struct{}) {
+	ticker := time.NewTicker(s.config.AutopilotInterval)
+	defer ticker.Stop()
+
+	// failedSince tracks when each server was first seen as failed
+	failedSince := make(map[raft.ServerAddress]time.Time)
+	for {
+		select {
+		case <-stopCh:
+			return
+		case <-s.shutdownCh:
+			return
+		case <-ticker.C:
+			if err := s.pruneDeadServers(s.raft, s.serf, failedSince, time.Now()); err != nil {
+				s.logger.Errorf("manager: autopilot: failed to prune dead servers: %v", err)
+			}
+		}
+	}
+}
+
+// pruneDeadServers removes the servers that have been failed for longer than
+// the DeadServerThreshold from the Raft configuration. Nothing is removed
+// when that would leave less than a majority of the servers, since the
+// failure is then more likely a network partition than dead servers.
+func (s *Server) pruneDeadServers(r autopilotRaft, members autopilotSerf, failedSince map[raft.ServerAddress]time.Time, now time.Time) error {
+	future := r.GetConfiguration()
+	if err := future.Error(); err != nil {
+		return err
+	}
+	servers := future.Configuration().Servers
+
+	// Index the Serf members of the servers in our region
+	serverMembers := make(map[raft.ServerAddress]serf.Member)
+	for _, member := range members.Members() {
+		valid, parts := isUdupServer(member)
+		if !valid || parts.Region != s.config.Region {
+			continue
+		}
+		addr := (&net.TCPAddr{IP: member.Addr, Port: parts.Port}).String()
+		serverMembers[raft.ServerAddress(addr)] = member
+	}
+
+	var dead []raft.Server
+	known := make(map[raft.ServerAddress]struct{}, len(servers))
+	for _, server := range servers {
+		known[server.Address] = struct{}{}
+		member, ok := serverMembers[server.Address]
+		if !ok || member.Status != serf.StatusFailed {
+			delete(failedSince, server.Address)
+			continue
+		}
+
+		since, ok := failedSince[server.Address]
+		if !ok {
+			failedSince[server.Address] = now
+			continue
+		}
+		if now.Sub(since) >= s.config.DeadServerThreshold {
+			dead = append(dead, server)
+		}
+	}
+
+	// Forget servers that are no longer part of the configuration
+	for addr := range failedSince {
+		if _, ok := known[addr]; !ok {
+			delete(failedSince, addr)
+		}
+	}
+
+	if len(dead) == 0 {
+		return nil
+	}
+	if len(dead)*2 >= len(servers) {
+		s.logger.Warnf("manager: autopilot: %d of %d servers are dead, not removing them since that would lose quorum",
+			len(dead), len(servers))
+		return nil
+	}
+
+	for _, server := range dead {
+		s.logger.Printf("manager: autopilot: removing dead server %q", server.Address)
+		if err := r.RemovePeer(server.Address).Error(); err != nil {
+			return err
+		}
+		delete(failedSince, server.Address)
+
+		// Have Serf forget the server as well, so it is not added back by
+		// the reconciliation.
+		if member, ok := serverMembers[server.Address]; ok {
+			if err := members.RemoveFailedNode(member.Name); err != nil {
+				s.logger.Warnf("manager: autopilot: failed to remove %q from serf: %v", member.Name, err)
+			}
+		}
+	}
+	return nil
+}
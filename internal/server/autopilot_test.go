@@ -0,0 +1,225 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package server
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/raft"
+	"github.com/hashicorp/serf/serf"
+
+	uconf "github.com/actiontech/dtle/internal/config"
+	ulog "github.com/actiontech/dtle/internal/logger"
+)
+
+// fakeAutopilotCluster is the Raft configuration and the Serf members of
+// servers, recording those autopilot removes
+type fakeAutopilotCluster struct {
+	servers []raft.Server
+	members []serf.Member
+
+	removedPeers []raft.ServerAddress
+	removedNodes []string
+}
+
+// newFakeAutopilotCluster returns a cluster of n servers, all alive
+func newFakeAutopilotCluster(n int) *fakeAutopilotCluster {
+	c := &fakeAutopilotCluster{}
+	for i := 0; i < n; i++ {
+		ip := net.IPv4(10, 0, 0, byte(i+1))
+		c.servers = append(c.servers, raft.Server{
+			ID:      raft.ServerID(fmt.Sprintf("server%d", i)),
+			Address: raft.ServerAddress(fmt.Sprintf("%s:4647", ip)),
+		})
+		c.members = append(c.members, serf.Member{
+			Name:   fmt.Sprintf("server%d.global", i),
+			Addr:   ip,
+			Tags:   map[string]string{"role": "server", "region": "global", "port": "4647"},
+			Status: serf.StatusAlive,
+		})
+	}
+	return c
+}
+
+// setStatus sets the Serf status of servers
+func (c *fakeAutopilotCluster) setStatus(status serf.MemberStatus, servers ...int) {
+	for _, i := range servers {
+		c.members[i].Status = status
+	}
+}
+
+func (c *fakeAutopilotCluster) GetConfiguration() raft.ConfigurationFuture {
+	return c
+}
+
+func (c *fakeAutopilotCluster) Error() error {
+	return nil
+}
+
+func (c *fakeAutopilotCluster) Index() uint64 {
+	return 0
+}
+
+func (c *fakeAutopilotCluster) Configuration() raft.Configuration {
+	return raft.Configuration{Servers: append([]raft.Server(nil), c.servers...)}
+}
+
+func (c *fakeAutopilotCluster) RemovePeer(peer raft.ServerAddress) raft.Future {
+	c.removedPeers = append(c.removedPeers, peer)
+	for i, server := range c.servers {
+		if server.Address == peer {
+			c.servers = append(c.servers[:i], c.servers[i+1:]...)
+			break
+		}
+	}
+	return c
+}
+
+func (c *fakeAutopilotCluster) Members() []serf.Member {
+	return c.members
+}
+
+func (c *fakeAutopilotCluster) RemoveFailedNode(node string) error {
+	c.removedNodes = append(c.removedNodes, node)
+	return nil
+}
+
+func TestServer_pruneDeadServers(t *testing.T) {
+	const threshold = 10 * time.Minute
+	s := &Server{
+		config: &uconf.ServerConfig{Region: "global", DeadServerThreshold: threshold},
+		logger: ulog.New(ioutil.Discard, ulog.ParseLevel("ERROR")),
+	}
+	start := time.Now()
+
+	t.Run("2 of 5 dead", func(t *testing.T) {
+		c := newFakeAutopilotCluster(5)
+		c.setStatus(serf.StatusFailed, 3, 4)
+		failedSince := make(map[raft.ServerAddress]time.Time)
+		for _, elapsed := range []time.Duration{0, threshold / 2, threshold - time.Second} {
+			if err := s.pruneDeadServers(c, c, failedSince, start.Add(elapsed)); err != nil {
+				t.Fatal(err)
+			}
+			if len(c.removedPeers) != 0 {
+				t.Fatalf("removed %v after %v, before the threshold", c.removedPeers, elapsed)
+			}
+		}
+		if len(failedSince) != 2 || !failedSince[c.servers[3].Address].Equal(start) {
+			t.Errorf("failedSince = %v, want the 2 failed servers since %v", failedSince, start)
+		}
+
+		if err := s.pruneDeadServers(c, c, failedSince, start.Add(threshold)); err != nil {
+			t.Fatal(err)
+		}
+		want := []raft.ServerAddress{"10.0.0.4:4647", "10.0.0.5:4647"}
+		if !reflect.DeepEqual(c.removedPeers, want) {
+			t.Errorf("removed peers %v, want %v", c.removedPeers, want)
+		}
+		if want := []string{"server3.global", "server4.global"}; !reflect.DeepEqual(c.removedNodes, want) {
+			t.Errorf("removed serf nodes %v, want %v", c.removedNodes, want)
+		}
+		if len(failedSince) != 0 {
+			t.Errorf("failedSince = %v after removing the dead servers, want none", failedSince)
+		}
+	})
+
+	// Removing a majority of the servers would lose quorum
+	for _, tt := range []struct {
+		servers int
+		failed  []int
+	}{
+		{3, []int{1, 2}},
+		{5, []int{2, 3, 4}},
+	} {
+		t.Run(fmt.Sprintf("%d of %d dead", len(tt.failed), tt.servers), func(t *testing.T) {
+			c := newFakeAutopilotCluster(tt.servers)
+			c.setStatus(serf.StatusFailed, tt.failed...)
+			failedSince := make(map[raft.ServerAddress]time.Time)
+			for _, elapsed := range []time.Duration{0, threshold, 2 * threshold} {
+				if err := s.pruneDeadServers(c, c, failedSince, start.Add(elapsed)); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if len(c.removedPeers) != 0 || len(c.removedNodes) != 0 {
+				t.Errorf("removed %v %v, losing quorum", c.removedPeers, c.removedNodes)
+			}
+			if len(failedSince) != len(tt.failed) {
+				t.Errorf("failedSince = %v, want the %d failed servers still tracked", failedSince, len(tt.failed))
+			}
+		})
+	}
+
+	t.Run("1 of 3 dead", func(t *testing.T) {
+		c := newFakeAutopilotCluster(3)
+		c.setStatus(serf.StatusFailed, 2)
+		failedSince := make(map[raft.ServerAddress]time.Time)
+		for _, elapsed := range []time.Duration{0, threshold} {
+			if err := s.pruneDeadServers(c, c, failedSince, start.Add(elapsed)); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if want := []raft.ServerAddress{"10.0.0.3:4647"}; !reflect.DeepEqual(c.removedPeers, want) {
+			t.Errorf("removed peers %v, want %v", c.removedPeers, want)
+		}
+	})
+
+	t.Run("alive again", func(t *testing.T) {
+		c := newFakeAutopilotCluster(5)
+		c.setStatus(serf.StatusFailed, 4)
+		failedSince := make(map[raft.ServerAddress]time.Time)
+		steps := []struct {
+			elapsed time.Duration
+			status  serf.MemberStatus
+		}{
+			{0, serf.StatusFailed},
+			{threshold / 2, serf.StatusAlive},
+			{threshold * 3 / 4, serf.StatusFailed},
+			{threshold, serf.StatusFailed},
+			{threshold + threshold/2, serf.StatusFailed},
+		}
+		for _, step := range steps {
+			c.setStatus(step.status, 4)
+			if err := s.pruneDeadServers(c, c, failedSince, start.Add(step.elapsed)); err != nil {
+				t.Fatal(err)
+			}
+			if step.status == serf.StatusAlive && len(failedSince) != 0 {
+				t.Errorf("failedSince = %v once alive again, want none", failedSince)
+			}
+			if len(c.removedPeers) != 0 {
+				t.Fatalf("removed %v after %v, failed again since %v", c.removedPeers, step.elapsed, threshold*3/4)
+			}
+		}
+		if err := s.pruneDeadServers(c, c, failedSince, start.Add(threshold*3/4+threshold)); err != nil {
+			t.Fatal(err)
+		}
+		if want := []raft.ServerAddress{"10.0.0.5:4647"}; !reflect.DeepEqual(c.removedPeers, want) {
+			t.Errorf("removed peers %v, want %v", c.removedPeers, want)
+		}
+	})
+
+	t.Run("unknown servers", func(t *testing.T) {
+		c := newFakeAutopilotCluster(3)
+		// A member of another region, or not a server, is not ours to remove
+		c.members[2].Tags["region"] = "other"
+		c.members[2].Status = serf.StatusFailed
+		failedSince := map[raft.ServerAddress]time.Time{"10.0.0.9:4647": start}
+		for _, elapsed := range []time.Duration{0, threshold} {
+			if err := s.pruneDeadServers(c, c, failedSince, start.Add(elapsed)); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if len(failedSince) != 0 || len(c.removedPeers) != 0 {
+			t.Errorf("failedSince = %v, removed %v, want neither the server out of the configuration nor that of another region",
+				failedSince, c.removedPeers)
+		}
+	})
+}
@@ -0,0 +1,115 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package server
+
+import (
+	"time"
+
+	"github.com/hashicorp/go-memdb"
+
+	"github.com/actiontech/dtle/internal/client/driver"
+	"github.com/actiontech/dtle/internal/models"
+)
+
+// binlogRetentionLoop runs as long as we are the leader and periodically
+// checks that the sources of the jobs still have the binlogs after their
+// checkpoints, so that a paused or lagging job is warned of before the
+// binlogs it needs are purged.
+func (s *Server) binlogRetentionLoop(stopCh chan struct{}) {
+	ticker := time.NewTicker(s.config.BinlogRetentionInterval)
+	defer ticker.Stop()
+
+	// warnings are the last warning raised for each job, raised again only
+	// once it changes
+	warnings := make(map[string]string)
+	for {
+		select {
+		case <-stopCh:
+			return
+		case <-s.shutdownCh:
+			return
+		case <-ticker.C:
+			if err := s.checkBinlogRetention(warnings); err != nil {
+				s.logger.Errorf("manager: binlog retention: %v", err)
+			}
+		}
+	}
+}
+
+// checkBinlogRetention checks the sources of the jobs with a checkpoint,
+// recording a warning event for each job whose binlogs are purged or about
+// to be.
+func (s *Server) checkBinlogRetention(warnings map[string]string) error {
+	iter, err := s.fsm.State().Jobs(memdb.NewWatchSet())
+	if err != nil {
+		return err
+	}
+
+	checked := make(map[string]bool)
+	var events []*models.JobEvent
+	for raw := iter.Next(); raw != nil; raw = iter.Next() {
+		job := raw.(*models.Job)
+		if job.Status == models.JobStatusDead || job.Status == models.JobStatusComplete {
+			continue
+		}
+		for _, task := range job.Tasks {
+			if task.Type != models.TaskTypeSrc {
+				continue
+			}
+			warning, ok := s.checkTaskBinlogRetention(job, task)
+			if !ok {
+				continue
+			}
+			checked[job.ID] = true
+			if warning != "" && warning != warnings[job.ID] {
+				s.logger.Warnf("manager: binlog retention: job %s: %s", job.ID, warning)
+				event := models.NewJobEvent(job.ID, models.JobEventWarning, warning, 0, time.Now().UnixNano())
+				event.Task = task.Type
+				events = append(events, event)
+			}
+			warnings[job.ID] = warning
+		}
+	}
+	for jobID := range warnings {
+		if !checked[jobID] {
+			delete(warnings, jobID)
+		}
+	}
+
+	if len(events) == 0 {
+		return nil
+	}
+	req := &models.JobEventsUpsertRequest{
+		Events:       events,
+		WriteRequest: models.WriteRequest{Region: s.config.Region},
+	}
+	_, _, err = s.raftApply(models.JobEventsUpsertRequestType|models.IgnoreUnknownTypeFlag, req)
+	return err
+}
+
+// checkTaskBinlogRetention returns the warning of the driver of a source
+// task, and false if the driver does not check its binlogs or failed to
+func (s *Server) checkTaskBinlogRetention(job *models.Job, task *models.Task) (string, bool) {
+	driverName := task.Driver
+	if driverName == "" {
+		driverName = models.TaskDriverMySQL
+	}
+	d, err := driver.NewDriver(driverName, driver.NewEmptyDriverContext())
+	if err != nil {
+		return "", false
+	}
+	checker, ok := d.(driver.BinlogRetentionChecker)
+	if !ok {
+		return "", false
+	}
+	warning, err := checker.CheckBinlogRetention(task)
+	if err != nil {
+		s.logger.Warnf("manager: binlog retention: failed to check the source of job %s: %v", job.ID, err)
+		return "", false
+	}
+	return warning, true
+}
@@ -41,6 +41,8 @@ const (
 	EvalSnapshot
 	AllocSnapshot
 	TimeTableSnapshot
+	JobEventSnapshot
+	NamespaceSnapshot
 )
 
 // udupFSM implements a finite store machine that is used
@@ -155,6 +157,12 @@ func (n *udupFSM) Apply(log *raft.Log) interface{} {
 		return n.applyAllocUpdate(buf[1:], log.Index)
 	case models.AllocClientUpdateRequestType:
 		return n.applyAllocClientUpdate(buf[1:], log.Index)
+	case models.NamespaceUpsertRequestType:
+		return n.applyUpsertNamespaces(buf[1:], log.Index)
+	case models.NamespaceDeleteRequestType:
+		return n.applyDeleteNamespaces(buf[1:], log.Index)
+	case models.JobEventsUpsertRequestType:
+		return n.applyUpsertJobEvents(buf[1:], log.Index)
 	default:
 		if ignoreUnknown {
 			n.logger.Warnf("server.fsm: ignoring unknown message type (%d), upgrade to newer version", msgType)
@@ -244,7 +252,7 @@ func (n *udupFSM) applyStatusUpdate(buf []byte, index uint64) interface{} {
 			job := raw.(*models.Job)
 			for _, task := range job.Tasks {
 				if task.NodeID == req.NodeID {
-					if job.Failover {
+					if job.RescheduleOnLostNode() {
 						// Scan the nodes
 						ws := memdb.NewWatchSet()
 						var out []*models.Node
@@ -274,7 +282,7 @@ func (n *udupFSM) applyStatusUpdate(buf []byte, index uint64) interface{} {
 								}
 							}
 
-							if err := n.state.UpsertJob(index, job); err != nil {
+							if err := n.state.UpsertJob(index, req.Time, job); err != nil {
 								n.logger.Errorf("server.fsm: UpsertJob failed: %v", err)
 								return err
 							}
@@ -298,14 +306,14 @@ func (n *udupFSM) applyStatusUpdate(buf []byte, index uint64) interface{} {
 								if len(out) > 0 {
 									alloc.NodeID = out[0].ID
 								}
-								if err := n.state.UpsertAlloc(index, alloc); err != nil {
+								if err := n.state.UpsertAlloc(index, req.Time, alloc); err != nil {
 									n.logger.Errorf("server.fsm: UpsertAlloc failed: %v", err)
 									return err
 								}
 							} else {
 								if alloc.Task == models.TaskTypeSrc {
 									alloc.TaskStates[alloc.Task].State = models.TaskStateDead
-									if err := n.state.UpsertAlloc(index, alloc); err != nil {
+									if err := n.state.UpsertAlloc(index, req.Time, alloc); err != nil {
 										n.logger.Errorf("server.fsm: UpsertAlloc failed: %v", err)
 										return err
 									}
@@ -328,7 +336,7 @@ func (n *udupFSM) applyStatusUpdate(buf []byte, index uint64) interface{} {
 							}
 							if node.Status == models.NodeStatusDown {
 								alloc.TaskStates[alloc.Task].State = models.TaskStateDead
-								if err := n.state.UpsertAlloc(index, alloc); err != nil {
+								if err := n.state.UpsertAlloc(index, req.Time, alloc); err != nil {
 									n.logger.Errorf("server.fsm: UpsertAlloc failed: %v", err)
 									return err
 								}
@@ -350,7 +358,7 @@ func (n *udupFSM) applyJobStatusUpdate(buf []byte, index uint64) interface{} {
 		panic(fmt.Errorf("failed to decode request: %v", err))
 	}
 
-	if err := n.state.UpdateJobStatus(index, req.JobID, req.Status); err != nil {
+	if err := n.state.UpdateJobStatus(index, req.Time, req.JobID, req.Status); err != nil {
 		n.logger.Errorf("server.fsm: UpdateJobStatus failed: %v", err)
 		return err
 	}
@@ -367,7 +375,7 @@ func (n *udupFSM) applyUpsertJob(buf []byte, index uint64) interface{} {
 
 	req.Job.Canonicalize()
 
-	if err := n.state.UpsertJob(index, req.Job); err != nil {
+	if err := n.state.UpsertJob(index, req.Time, req.Job); err != nil {
 		n.logger.Errorf("server.fsm: UpsertJob failed: %v", err)
 		return err
 	}
@@ -435,6 +443,51 @@ func (n *udupFSM) applyDeregisterOrder(buf []byte, index uint64) interface{} {
 	return nil
 }
 
+func (n *udupFSM) applyUpsertNamespaces(buf []byte, index uint64) interface{} {
+	defer metrics.MeasureSince([]string{"server", "fsm", "upsert_namespaces"}, time.Now())
+	var req models.NamespaceUpsertRequest
+	if err := models.Decode(buf, &req); err != nil {
+		panic(fmt.Errorf("failed to decode request: %v", err))
+	}
+
+	if err := n.state.UpsertNamespaces(index, req.Namespaces); err != nil {
+		n.logger.Errorf("server.fsm: UpsertNamespaces failed: %v", err)
+		return err
+	}
+
+	return nil
+}
+
+func (n *udupFSM) applyDeleteNamespaces(buf []byte, index uint64) interface{} {
+	defer metrics.MeasureSince([]string{"server", "fsm", "delete_namespaces"}, time.Now())
+	var req models.NamespaceDeleteRequest
+	if err := models.Decode(buf, &req); err != nil {
+		panic(fmt.Errorf("failed to decode request: %v", err))
+	}
+
+	if err := n.state.DeleteNamespaces(index, req.Namespaces); err != nil {
+		n.logger.Errorf("server.fsm: DeleteNamespaces failed: %v", err)
+		return err
+	}
+
+	return nil
+}
+
+func (n *udupFSM) applyUpsertJobEvents(buf []byte, index uint64) interface{} {
+	defer metrics.MeasureSince([]string{"server", "fsm", "upsert_job_events"}, time.Now())
+	var req models.JobEventsUpsertRequest
+	if err := models.Decode(buf, &req); err != nil {
+		panic(fmt.Errorf("failed to decode request: %v", err))
+	}
+
+	if err := n.state.UpsertJobEvents(index, req.Events); err != nil {
+		n.logger.Errorf("server.fsm: UpsertJobEvents failed: %v", err)
+		return err
+	}
+
+	return nil
+}
+
 func (n *udupFSM) applyUpdateEval(buf []byte, index uint64) interface{} {
 	defer metrics.MeasureSince([]string{"server", "fsm", "update_eval"}, time.Now())
 	var req models.EvalUpdateRequest
@@ -494,7 +547,7 @@ func (n *udupFSM) applyAllocUpdate(buf []byte, index uint64) interface{} {
 		}
 	}
 
-	if err := n.state.UpsertAllocs(index, req.Alloc); err != nil {
+	if err := n.state.UpsertAllocs(index, req.Time, req.Alloc); err != nil {
 		n.logger.Errorf("server.fsm: UpsertAllocs failed: %v", err)
 		return err
 	}
@@ -524,6 +577,9 @@ func (n *udupFSM) applyJobClientUpdate(buf []byte, index uint64) interface{} {
 				for _, t := range existing.Tasks {
 					t.Config["Gtid"] = ju.Gtid
 					//t.Config["NatsAddr"] = ju.NatsAddr
+					if len(ju.SchemaHistory) > 0 {
+						t.Config["SchemaHistory"] = ju.SchemaHistory
+					}
 				}
 				// Update all the client allocations
 				if err := n.state.UpdateJobFromClient(index, existing); err != nil {
@@ -690,6 +746,24 @@ func (n *udupFSM) Restore(old io.ReadCloser) error {
 				return err
 			}
 
+		case JobEventSnapshot:
+			event := new(models.JobEvent)
+			if err := dec.Decode(event); err != nil {
+				return err
+			}
+			if err := restore.JobEventRestore(event); err != nil {
+				return err
+			}
+
+		case NamespaceSnapshot:
+			ns := new(models.Namespace)
+			if err := dec.Decode(ns); err != nil {
+				return err
+			}
+			if err := restore.NamespaceRestore(ns); err != nil {
+				return err
+			}
+
 		case IndexSnapshot:
 			idx := new(store.IndexEntry)
 			if err := dec.Decode(idx); err != nil {
@@ -761,6 +835,14 @@ func (s *udupSnapshot) Persist(sink raft.SnapshotSink) error {
 		sink.Cancel()
 		return err
 	}
+	if err := s.persistJobEvents(sink, encoder); err != nil {
+		sink.Cancel()
+		return err
+	}
+	if err := s.persistNamespaces(sink, encoder); err != nil {
+		sink.Cancel()
+		return err
+	}
 
 	return nil
 }
@@ -904,6 +986,62 @@ func (s *udupSnapshot) persistAllocs(sink raft.SnapshotSink,
 	return nil
 }
 
+func (s *udupSnapshot) persistJobEvents(sink raft.SnapshotSink,
+	encoder *codec.Encoder) error {
+	// Get all the job events
+	ws := memdb.NewWatchSet()
+	events, err := s.snap.JobEvents(ws)
+	if err != nil {
+		return err
+	}
+
+	for {
+		// Get the next item
+		raw := events.Next()
+		if raw == nil {
+			break
+		}
+
+		// Prepare the request struct
+		event := raw.(*models.JobEvent)
+
+		// Write out the job event
+		sink.Write([]byte{byte(JobEventSnapshot)})
+		if err := encoder.Encode(event); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *udupSnapshot) persistNamespaces(sink raft.SnapshotSink,
+	encoder *codec.Encoder) error {
+	// Get all the namespaces
+	ws := memdb.NewWatchSet()
+	namespaces, err := s.snap.Namespaces(ws)
+	if err != nil {
+		return err
+	}
+
+	for {
+		// Get the next item
+		raw := namespaces.Next()
+		if raw == nil {
+			break
+		}
+
+		// Prepare the request struct
+		ns := raw.(*models.Namespace)
+
+		// Write out the namespace
+		sink.Write([]byte{byte(NamespaceSnapshot)})
+		if err := encoder.Encode(ns); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // Release is a no-op, as we just need to GC the pointer
 // to the store store snapshot. There is nothing to explicitly
 // cleanup.
@@ -0,0 +1,131 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package server
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/go-memdb"
+
+	"github.com/actiontech/dtle/internal/models"
+)
+
+// jobAlertInterval is how often the leader checks the alert policies of the
+// jobs
+const jobAlertInterval = 15 * time.Second
+
+// jobAlertLoop runs as long as we are the leader and periodically checks
+// the jobs against their alert policies, so that a job exceeding one is
+// degraded, and notified by the webhooks, while it keeps replicating.
+func (s *Server) jobAlertLoop(stopCh chan struct{}) {
+	ticker := time.NewTicker(jobAlertInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stopCh:
+			return
+		case <-s.shutdownCh:
+			return
+		case <-ticker.C:
+			if err := s.checkJobAlerts(time.Now()); err != nil {
+				s.logger.Errorf("manager: job alerts: %v", err)
+			}
+		}
+	}
+}
+
+// checkJobAlerts records a degraded event for each job newly exceeding its
+// alert policy, or exceeding it for other reasons, and a recovered event
+// for each degraded job no longer exceeding it. The events set the health
+// of the jobs.
+func (s *Server) checkJobAlerts(now time.Time) error {
+	state := s.fsm.State()
+	iter, err := state.Jobs(memdb.NewWatchSet())
+	if err != nil {
+		return err
+	}
+
+	var events []*models.JobEvent
+	for raw := iter.Next(); raw != nil; raw = iter.Next() {
+		job := raw.(*models.Job)
+		var reasons []string
+		if job.Alert != nil && job.Status == models.JobStatusRunning {
+			jobEvents, err := state.JobEventsByJob(memdb.NewWatchSet(), job.ID)
+			if err != nil {
+				return err
+			}
+			reasons = jobAlertReasons(job.Alert, jobEvents, now)
+		}
+
+		switch {
+		case len(reasons) > 0:
+			reason := strings.Join(reasons, "; ")
+			if job.Health == models.JobHealthDegraded && job.HealthDescription == reason {
+				continue
+			}
+			s.logger.Warnf("manager: job alerts: job %s is degraded: %s", job.ID, reason)
+			events = append(events, models.NewJobEvent(job.ID, models.JobEventDegraded, reason, 0, now.UnixNano()))
+		case job.Health == models.JobHealthDegraded:
+			s.logger.Printf("manager: job alerts: job %s recovered", job.ID)
+			events = append(events, models.NewJobEvent(job.ID, models.JobEventRecovered,
+				"No alert threshold is exceeded", 0, now.UnixNano()))
+		}
+	}
+
+	if len(events) == 0 {
+		return nil
+	}
+	req := &models.JobEventsUpsertRequest{
+		Events:       events,
+		WriteRequest: models.WriteRequest{Region: s.config.Region},
+	}
+	_, _, err = s.raftApply(models.JobEventsUpsertRequestType|models.IgnoreUnknownTypeFlag, req)
+	return err
+}
+
+// jobAlertReasons returns the thresholds of an alert policy a job exceeds,
+// given its events, oldest first: the error events of the last minute, and
+// the tasks lagging since their last restart.
+func jobAlertReasons(alert *models.AlertPolicy, events []*models.JobEvent, now time.Time) []string {
+	var reasons []string
+
+	errors := 0
+	lagging := make(map[string]string)
+	since := now.Add(-time.Minute).UnixNano()
+	for _, event := range events {
+		switch event.Type {
+		case models.JobEventError:
+			if event.Time >= since {
+				errors++
+			}
+			delete(lagging, event.Task)
+		case models.JobEventPlaced, models.JobEventStreaming, models.JobEventCaughtUp:
+			delete(lagging, event.Task)
+		case models.JobEventLagging:
+			lagging[event.Task] = event.Message
+		}
+	}
+
+	if alert.MaxErrorsPerMinute > 0 && errors > alert.MaxErrorsPerMinute {
+		reasons = append(reasons, fmt.Sprintf("more than %d errors in a minute", alert.MaxErrorsPerMinute))
+	}
+	if alert.MaxSecondsBehind > 0 {
+		var tasks []string
+		for task := range lagging {
+			tasks = append(tasks, task)
+		}
+		sort.Strings(tasks)
+		for _, task := range tasks {
+			reasons = append(reasons, fmt.Sprintf("task %s more than %ds behind its source", task, alert.MaxSecondsBehind))
+		}
+	}
+	return reasons
+}
@@ -8,6 +8,8 @@ package server
 
 import (
 	"fmt"
+	"net/url"
+	"strings"
 	"time"
 
 	"github.com/armon/go-metrics"
@@ -19,6 +21,7 @@ import (
 	"github.com/actiontech/dtle/internal/server/store"
 
 	"github.com/mitchellh/copystructure"
+	"github.com/mitchellh/mapstructure"
 )
 
 const (
@@ -26,6 +29,12 @@ const (
 	// enforcing the job modify index during registers.
 	RegisterEnforceIndexErrPrefix = "Enforcing job modify index"
 	MaskedPassword = "*"
+
+	// defaultCutoverTimeout bounds each wait of a cutover not given a timeout
+	defaultCutoverTimeout = 10 * time.Minute
+	// cutoverPollInterval is how often a cutover compares the source and
+	// the target
+	cutoverPollInterval = time.Second
 )
 
 // Job endpoint is used for job interactions
@@ -49,6 +58,12 @@ func (j *Job) Register(args *models.JobRegisterRequest, reply *models.JobRespons
 	// Initialize the job fields (sets defaults and any necessary init work).
 	args.Job.Canonicalize()
 
+	// Check the job against its namespace
+	if err := checkNamespace(j.srv.fsm.State(), args.Job); err != nil {
+		reply.Success = false
+		return err
+	}
+
 	// Validate the job.
 	/*if err := validateJob(args.Job); err != nil {
 		reply.Success = false
@@ -276,6 +291,329 @@ func (j *Job) Validate(args *models.JobValidateRequest,
 	return nil
 }
 
+// EstimateTables estimates the size of the tables a source task would
+// replicate
+func (j *Job) EstimateTables(args *models.TableEstimateRequest,
+	reply *models.TableEstimateResponse) error {
+	if done, err := j.srv.forward("Job.EstimateTables", args, args, reply); done {
+		return err
+	}
+	defer metrics.MeasureSince([]string{"udup", "job", "estimate_tables"}, time.Now())
+
+	task := args.Task
+	if task == nil {
+		return fmt.Errorf("missing task")
+	}
+	if task.Driver == "" {
+		task.Driver = models.TaskDriverMySQL
+	}
+	d, err := driver.NewDriver(task.Driver, driver.NewEmptyDriverContext())
+	if err != nil {
+		return err
+	}
+	estimator, ok := d.(driver.TableEstimator)
+	if !ok {
+		return fmt.Errorf("the tables of a %s source can not be estimated", task.Driver)
+	}
+	tables, err := estimator.EstimateTables(task)
+	if err != nil {
+		return err
+	}
+	reply.Tables = tables
+	for _, t := range tables {
+		reply.TotalRows += t.EstimatedRows
+		reply.TotalDataLength += t.DataLength
+	}
+	return nil
+}
+
+// Cutover waits for a job to catch up with its source, optionally sets the
+// source read-only, then waits for the job to apply the last transactions
+// of the source and returns their GTID set
+func (j *Job) Cutover(args *models.JobCutoverRequest,
+	reply *models.JobCutoverResponse) error {
+	if done, err := j.srv.forward("Job.Cutover", args, args, reply); done {
+		return err
+	}
+	defer metrics.MeasureSince([]string{"server", "job", "cutover"}, time.Now())
+
+	snap, err := j.srv.fsm.State().Snapshot()
+	if err != nil {
+		return err
+	}
+	job, err := snap.JobByID(memdb.NewWatchSet(), args.JobID)
+	if err != nil {
+		return err
+	}
+	if job == nil {
+		return fmt.Errorf("job not found: %s", args.JobID)
+	}
+	if job.Status != models.JobStatusRunning {
+		return fmt.Errorf("job %s is %s, not running", args.JobID, job.Status)
+	}
+	var src, dest *models.Task
+	for _, task := range job.Tasks {
+		switch task.Type {
+		case models.TaskTypeSrc:
+			src = task
+		case models.TaskTypeDest:
+			dest = task
+		}
+	}
+	if src == nil || dest == nil {
+		return fmt.Errorf("job %s has no source or target to cut over", args.JobID)
+	}
+	for _, task := range []*models.Task{src, dest} {
+		if task.Driver != "" && task.Driver != models.TaskDriverMySQL {
+			return fmt.Errorf("a job with a %s task can not be cut over", task.Driver)
+		}
+	}
+	d, err := driver.NewDriver(models.TaskDriverMySQL, driver.NewEmptyDriverContext())
+	if err != nil {
+		return err
+	}
+	cutoverer, ok := d.(driver.Cutoverer)
+	if !ok {
+		return fmt.Errorf("the %s driver can not cut a job over", models.TaskDriverMySQL)
+	}
+	timeout := time.Duration(args.Timeout) * time.Second
+	if timeout <= 0 {
+		timeout = defaultCutoverTimeout
+	}
+
+	// Wait for the job to catch up while the source is still written to
+	start := time.Now()
+	if err := j.waitTransactionsBehind(cutoverer, job.ID, src, dest, args.MaxLag, timeout); err != nil {
+		return err
+	}
+	reply.LagWait = time.Since(start).Seconds()
+
+	if args.ReadOnly {
+		if err := cutoverer.SetSourceReadOnly(src); err != nil {
+			return fmt.Errorf("failed to set the source read-only: %v", err)
+		}
+		reply.SourceReadOnly = true
+		j.srv.logger.Infof("server.job: cutover: set the source of job %s read-only", job.ID)
+	}
+
+	// Wait for the job to apply the transactions of the source up to now
+	start = time.Now()
+	gtidSet, err := cutoverer.GtidExecuted(src)
+	if err != nil {
+		return err
+	}
+	if err := j.waitGtidApplied(cutoverer, job.ID, src, dest, gtidSet, timeout); err != nil {
+		if reply.SourceReadOnly {
+			return fmt.Errorf("%v, the source is left read-only", err)
+		}
+		return err
+	}
+	reply.SyncWait = time.Since(start).Seconds()
+	reply.CutoverGtid = gtidSet
+
+	// The target is written to from its GTID set at the cutover, which a
+	// reverse job replicates from
+	reply.TargetGtid, err = cutoverer.GtidExecuted(dest)
+	if err != nil {
+		return err
+	}
+	event := models.NewJobEvent(job.ID, models.JobEventCutover,
+		fmt.Sprintf("the target has applied the source up to %s", gtidSet), 0, time.Now().UnixNano())
+	event.Details = map[string]string{
+		models.JobEventDetailCutoverGtid: reply.CutoverGtid,
+		models.JobEventDetailTargetGtid:  reply.TargetGtid,
+	}
+	req := &models.JobEventsUpsertRequest{
+		Events:       []*models.JobEvent{event},
+		WriteRequest: models.WriteRequest{Region: args.Region},
+	}
+	if _, _, err := j.srv.raftApply(models.JobEventsUpsertRequestType|models.IgnoreUnknownTypeFlag, req); err != nil {
+		j.srv.logger.Errorf("server.job: cutover: failed to record the cutover of job %s: %v", job.ID, err)
+	}
+	return nil
+}
+
+// SkipDDL records a DDL applied manually on the target of a job, which the
+// job skips once it replicates it
+func (j *Job) SkipDDL(args *models.JobSkipDDLRequest,
+	reply *models.JobSkipDDLResponse) error {
+	if done, err := j.srv.forward("Job.SkipDDL", args, args, reply); done {
+		return err
+	}
+	defer metrics.MeasureSince([]string{"server", "job", "skip_ddl"}, time.Now())
+
+	if strings.TrimSpace(args.Query) == "" {
+		return fmt.Errorf("missing the DDL to skip")
+	}
+	d, dest, err := j.mysqlTarget(args.JobID, "skip DDLs")
+	if err != nil {
+		return err
+	}
+	skipper, ok := d.(driver.DDLSkipper)
+	if !ok {
+		return fmt.Errorf("the %s driver can not skip DDLs", models.TaskDriverMySQL)
+	}
+	reply.Checksum, err = skipper.SkipDDL(args.JobID, dest, args.Query)
+	if err != nil {
+		return err
+	}
+	j.srv.logger.Infof("server.job: job %s skips the DDL %s once replicated", args.JobID, reply.Checksum)
+	return nil
+}
+
+// SkippedDDLs lists the DDLs recorded on the target of a job that the job
+// has yet to skip
+func (j *Job) SkippedDDLs(args *models.JobSpecificRequest,
+	reply *models.JobSkippedDDLsResponse) error {
+	if done, err := j.srv.forward("Job.SkippedDDLs", args, args, reply); done {
+		return err
+	}
+	defer metrics.MeasureSince([]string{"server", "job", "skipped_ddls"}, time.Now())
+
+	d, dest, err := j.mysqlTarget(args.JobID, "skip DDLs")
+	if err != nil {
+		return err
+	}
+	skipper, ok := d.(driver.DDLSkipper)
+	if !ok {
+		return fmt.Errorf("the %s driver can not skip DDLs", models.TaskDriverMySQL)
+	}
+	reply.DDLs, err = skipper.SkippedDDLs(args.JobID, dest)
+	return err
+}
+
+// DDLHistory lists the last DDLs a job replicated to its target, with
+// whether they were applied, skipped or failed
+func (j *Job) DDLHistory(args *models.JobSpecificRequest,
+	reply *models.JobDDLHistoryResponse) error {
+	if done, err := j.srv.forward("Job.DDLHistory", args, args, reply); done {
+		return err
+	}
+	defer metrics.MeasureSince([]string{"server", "job", "ddl_history"}, time.Now())
+
+	d, dest, err := j.mysqlTarget(args.JobID, "record DDLs")
+	if err != nil {
+		return err
+	}
+	recorder, ok := d.(driver.DDLRecorder)
+	if !ok {
+		return fmt.Errorf("the %s driver can not record DDLs", models.TaskDriverMySQL)
+	}
+	reply.DDLs, err = recorder.DDLHistory(args.JobID, dest)
+	return err
+}
+
+// SchemaHistory lists the versions of the tables a job replicated, kept with
+// its Gtid
+func (j *Job) SchemaHistory(args *models.JobSpecificRequest,
+	reply *models.JobSchemaHistoryResponse) error {
+	if done, err := j.srv.forward("Job.SchemaHistory", args, args, reply); done {
+		return err
+	}
+	defer metrics.MeasureSince([]string{"server", "job", "schema_history"}, time.Now())
+
+	snap, err := j.srv.fsm.State().Snapshot()
+	if err != nil {
+		return err
+	}
+	job, err := snap.JobByID(memdb.NewWatchSet(), args.JobID)
+	if err != nil {
+		return err
+	}
+	if job == nil {
+		return fmt.Errorf("job not found: %s", args.JobID)
+	}
+	for _, task := range job.Tasks {
+		history, ok := task.Config["SchemaHistory"]
+		if !ok || history == nil {
+			continue
+		}
+		if err := mapstructure.WeakDecode(history, &reply.Versions); err != nil {
+			return err
+		}
+		break
+	}
+	reply.Index = job.ModifyIndex
+	j.srv.setQueryMeta(&reply.QueryMeta)
+	return nil
+}
+
+// mysqlTarget returns the MySQL driver and the target task of a job, what
+// being the operation on the target the errors name
+func (j *Job) mysqlTarget(jobID string, what string) (driver.Driver, *models.Task, error) {
+	snap, err := j.srv.fsm.State().Snapshot()
+	if err != nil {
+		return nil, nil, err
+	}
+	job, err := snap.JobByID(memdb.NewWatchSet(), jobID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if job == nil {
+		return nil, nil, fmt.Errorf("job not found: %s", jobID)
+	}
+	var dest *models.Task
+	for _, task := range job.Tasks {
+		if task.Type == models.TaskTypeDest {
+			dest = task
+		}
+	}
+	if dest == nil {
+		return nil, nil, fmt.Errorf("job %s has no target", jobID)
+	}
+	if dest.Driver != "" && dest.Driver != models.TaskDriverMySQL {
+		return nil, nil, fmt.Errorf("a job with a %s target can not %s", dest.Driver, what)
+	}
+	d, err := driver.NewDriver(models.TaskDriverMySQL, driver.NewEmptyDriverContext())
+	if err != nil {
+		return nil, nil, err
+	}
+	return d, dest, nil
+}
+
+// waitTransactionsBehind waits until a job is at most maxLag transactions
+// behind its source
+func (j *Job) waitTransactionsBehind(cutoverer driver.Cutoverer, jobID string, src, dest *models.Task,
+	maxLag int64, timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	for {
+		gtidSet, err := cutoverer.GtidExecuted(src)
+		if err != nil {
+			return err
+		}
+		behind, err := cutoverer.TransactionsBehind(jobID, src, dest, gtidSet)
+		if err != nil {
+			return err
+		}
+		if behind <= maxLag {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("job %s is still %d transactions behind after %v", jobID, behind, timeout)
+		}
+		time.Sleep(cutoverPollInterval)
+	}
+}
+
+// waitGtidApplied waits until a job has applied a GTID set of its source
+func (j *Job) waitGtidApplied(cutoverer driver.Cutoverer, jobID string, src, dest *models.Task,
+	gtidSet string, timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	for {
+		behind, err := cutoverer.TransactionsBehind(jobID, src, dest, gtidSet)
+		if err != nil {
+			return err
+		}
+		if behind == 0 {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("job %s has yet to apply %d transactions of %s after %v", jobID, behind, gtidSet, timeout)
+		}
+		time.Sleep(cutoverPollInterval)
+	}
+}
+
 // Evaluate is used to force a job for re-evaluation
 func (j *Job) Evaluate(args *models.JobEvaluateRequest, reply *models.JobResponse) error {
 	if done, err := j.srv.forward("Job.Evaluate", args, args, reply); done {
@@ -451,12 +789,23 @@ func (j *Job) List(args *models.JobListRequest,
 			}
 
 			var jobs []*models.JobListStub
+			reply.NextToken = ""
 			for {
 				raw := iter.Next()
 				if raw == nil {
 					break
 				}
 				job := raw.(*models.Job)
+				if ns := args.QueryOptions.Namespace; ns != "" && job.Namespace != ns {
+					continue
+				}
+				if job.ID < args.QueryOptions.NextToken || !args.Matches(job) {
+					continue
+				}
+				if perPage := args.QueryOptions.PerPage; perPage > 0 && len(jobs) == perPage {
+					reply.NextToken = job.ID
+					break
+				}
 				jobCopy0, err := copystructure.Copy(job)
 				if err != nil {
 					return err
@@ -473,6 +822,37 @@ func (j *Job) List(args *models.JobListRequest,
 							connCfgMap["Password"] = MaskedPassword
 						}
 					}
+					if check, ok := t.Config["ConsistencyCheck"].(map[string]interface{}); ok {
+						if connCfgMap, ok := check["TargetConnectionConfig"].(map[string]interface{}); ok {
+							connCfgMap["Password"] = MaskedPassword
+						}
+					}
+					if webhook, ok := t.Config["Webhook"].(map[string]interface{}); ok {
+						if _, ok := webhook["Secret"]; ok {
+							webhook["Secret"] = MaskedPassword
+						}
+						// the headers may carry credentials
+						if headers, ok := webhook["Headers"].(map[string]interface{}); ok {
+							for k := range headers {
+								headers[k] = MaskedPassword
+							}
+						}
+					}
+					if jetStream, ok := t.Config["JetStream"].(map[string]interface{}); ok {
+						if _, ok := jetStream["Token"]; ok {
+							jetStream["Token"] = MaskedPassword
+						}
+						if servers, ok := jetStream["Servers"].([]interface{}); ok {
+							for i, server := range servers {
+								if u, err := url.Parse(fmt.Sprint(server)); err == nil && u.User != nil {
+									if _, ok := u.User.Password(); ok {
+										u.User = url.UserPassword(u.User.Username(), MaskedPassword)
+										servers[i] = u.String()
+									}
+								}
+							}
+						}
+					}
 				}
 				jobs = append(jobs, job.Stub(jobCopy))
 			}
@@ -569,6 +949,41 @@ func (j *Job) Evaluations(args *models.JobSpecificRequest,
 	return j.srv.blockingRPC(&opts)
 }
 
+// Events is used to list the timeline of a job
+func (j *Job) Events(args *models.JobSpecificRequest,
+	reply *models.JobEventsResponse) error {
+	if done, err := j.srv.forward("Job.Events", args, args, reply); done {
+		return err
+	}
+	defer metrics.MeasureSince([]string{"server", "job", "events"}, time.Now())
+
+	// Setup the blocking query
+	opts := blockingOptions{
+		queryOpts: &args.QueryOptions,
+		queryMeta: &reply.QueryMeta,
+		run: func(ws memdb.WatchSet, state *store.StateStore) error {
+			// Capture the events
+			var err error
+			reply.Events, err = state.JobEventsByJob(ws, args.JobID)
+			if err != nil {
+				return err
+			}
+
+			// Use the last index that affected the job_events table
+			index, err := state.Index("job_events")
+			if err != nil {
+				return err
+			}
+			reply.Index = index
+
+			// Set the query response
+			j.srv.setQueryMeta(&reply.QueryMeta)
+			return nil
+		}}
+
+	return j.srv.blockingRPC(&opts)
+}
+
 // Plan is used to cause a dry-run evaluation of the Job and return the results
 // with a potential diff containing annotations.
 func (j *Job) Plan(args *models.JobPlanRequest, reply *models.JobPlanResponse) error {
@@ -610,8 +1025,16 @@ func (j *Job) Plan(args *models.JobPlanRequest, reply *models.JobPlanResponse) e
 		updatedIndex = oldJob.JobModifyIndex + 1
 	}
 
+	// Diff the registered job against the planned one
+	if args.Diff {
+		reply.Diff, err = oldJob.Diff(args.Job)
+		if err != nil {
+			return err
+		}
+	}
+
 	// Insert the updated Job into the snapshot
-	snap.UpsertJob(updatedIndex, args.Job)
+	snap.UpsertJob(updatedIndex, time.Now().UnixNano(), args.Job)
 
 	// Create an eval and mark it as requiring annotations and insert that as well
 	eval := &models.Evaluation{
@@ -58,6 +58,11 @@ func (s *Server) leaderLoop(stopCh chan struct{}) {
 	var reconcileCh chan serf.Member
 	establishedLeader := false
 
+	// leaderStopCh stops the routines started when establishing leadership.
+	// It is replaced each time leadership is reasserted.
+	leaderStopCh := make(chan struct{})
+	defer func() { close(leaderStopCh) }()
+
 RECONCILE:
 	// Setup a reconciliation timer
 	reconcileCh = nil
@@ -74,7 +79,7 @@ RECONCILE:
 
 	// Check if we need to handle initial leadership actions
 	if !establishedLeader {
-		if err := s.establishLeadership(stopCh); err != nil {
+		if err := s.establishLeadership(leaderStopCh); err != nil {
 			s.logger.Errorf("manager: failed to establish leadership: %v",
 				err)
 			goto WAIT
@@ -104,6 +109,18 @@ WAIT:
 			goto RECONCILE
 		case member := <-reconcileCh:
 			s.reconcileMember(member)
+		case errCh := <-s.reassertLeaderCh:
+			if !establishedLeader {
+				errCh <- fmt.Errorf("leadership has not been established")
+				continue
+			}
+
+			// Rebuild the leader state, such as the eval broker and the
+			// heartbeat timers, from the current state store.
+			s.revokeLeadership()
+			close(leaderStopCh)
+			leaderStopCh = make(chan struct{})
+			errCh <- s.establishLeadership(leaderStopCh)
 		}
 	}
 }
@@ -140,6 +157,11 @@ func (s *Server) establishLeadership(stopCh chan struct{}) error {
 		return err
 	}
 
+	// Make sure the default namespace exists
+	if err := s.initializeDefaultNamespace(); err != nil {
+		return err
+	}
+
 	// Reap any failed evaluations
 	go s.reapFailedEvaluations(stopCh)
 
@@ -149,6 +171,25 @@ func (s *Server) establishLeadership(stopCh chan struct{}) error {
 	// Periodically unblock failed allocations
 	go s.periodicUnblockFailedEvals(stopCh)
 
+	// Periodically remove dead servers from the Raft configuration
+	if s.config.CleanupDeadServers {
+		go s.autopilotLoop(stopCh)
+	}
+
+	// Notify the webhooks of job events
+	if len(s.config.Webhooks) > 0 {
+		go s.webhookLoop(stopCh)
+	}
+
+	// Periodically check that the sources still have the binlogs the jobs
+	// need
+	if s.config.BinlogRetentionInterval > 0 {
+		go s.binlogRetentionLoop(stopCh)
+	}
+
+	// Periodically check the jobs against their alert policies
+	go s.jobAlertLoop(stopCh)
+
 	// Setup the heartbeat timers. This is done both when starting up or when
 	// a leader fail over happens. Since the timers are maintained by the leader
 	// node, effectively this means all the timers are renewed at the time of failover.
@@ -166,6 +207,31 @@ func (s *Server) establishLeadership(stopCh chan struct{}) error {
 	return nil
 }
 
+// initializeDefaultNamespace creates the default namespace the first time a
+// leader is elected, so jobs registered without a namespace have one.
+func (s *Server) initializeDefaultNamespace() error {
+	ns, err := s.fsm.State().NamespaceByName(nil, models.DefaultNamespace)
+	if err != nil {
+		return err
+	}
+	if ns != nil {
+		return nil
+	}
+
+	req := models.NamespaceUpsertRequest{
+		Namespaces: []*models.Namespace{{
+			Name:        models.DefaultNamespace,
+			Description: "Default shared namespace",
+		}},
+		WriteRequest: models.WriteRequest{Region: s.config.Region},
+	}
+	if _, _, err := s.raftApply(models.NamespaceUpsertRequestType, &req); err != nil {
+		s.logger.Errorf("manager: failed to create the default namespace: %v", err)
+		return err
+	}
+	return nil
+}
+
 // restoreEvals is used to restore pending evaluations into the eval broker and
 // blocked evaluations into the blocked eval tracker. The broker and blocked
 // eval tracker is maintained only by the leader, so it must be restored anytime
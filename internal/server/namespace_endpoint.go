@@ -0,0 +1,291 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package server
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/armon/go-metrics"
+	"github.com/hashicorp/go-memdb"
+
+	"github.com/actiontech/dtle/internal/models"
+	"github.com/actiontech/dtle/internal/server/store"
+)
+
+// Namespace endpoint is used for namespace interactions
+type Namespace struct {
+	srv *Server
+}
+
+// Upsert is used to create or update a set of namespaces
+func (n *Namespace) Upsert(args *models.NamespaceUpsertRequest, reply *models.GenericResponse) error {
+	if done, err := n.srv.forward("Namespace.Upsert", args, args, reply); done {
+		return err
+	}
+	defer metrics.MeasureSince([]string{"server", "namespace", "upsert"}, time.Now())
+
+	// Validate the arguments
+	if len(args.Namespaces) == 0 {
+		return fmt.Errorf("missing namespaces to upsert")
+	}
+	for _, ns := range args.Namespaces {
+		if err := ns.Validate(); err != nil {
+			return err
+		}
+	}
+
+	// Commit this update via Raft
+	_, index, err := n.srv.raftApply(models.NamespaceUpsertRequestType, args)
+	if err != nil {
+		n.srv.logger.Errorf("server.namespace: Upsert failed: %v", err)
+		return err
+	}
+
+	reply.Index = index
+	return nil
+}
+
+// Delete is used to delete a set of namespaces
+func (n *Namespace) Delete(args *models.NamespaceDeleteRequest, reply *models.GenericResponse) error {
+	if done, err := n.srv.forward("Namespace.Delete", args, args, reply); done {
+		return err
+	}
+	defer metrics.MeasureSince([]string{"server", "namespace", "delete"}, time.Now())
+
+	// Validate the arguments
+	if len(args.Namespaces) == 0 {
+		return fmt.Errorf("missing namespaces to delete")
+	}
+	state := n.srv.fsm.State()
+	ws := memdb.NewWatchSet()
+	for _, name := range args.Namespaces {
+		if name == models.DefaultNamespace {
+			return fmt.Errorf("default namespace can not be deleted")
+		}
+		ns, err := state.NamespaceByName(ws, name)
+		if err != nil {
+			return err
+		}
+		if ns == nil {
+			return fmt.Errorf("namespace %q not found", name)
+		}
+		iter, err := state.JobsByNamespace(ws, name)
+		if err != nil {
+			return err
+		}
+		if iter.Next() != nil {
+			return fmt.Errorf("namespace %q has jobs", name)
+		}
+	}
+
+	// Commit this update via Raft
+	_, index, err := n.srv.raftApply(models.NamespaceDeleteRequestType, args)
+	if err != nil {
+		n.srv.logger.Errorf("server.namespace: Delete failed: %v", err)
+		return err
+	}
+
+	reply.Index = index
+	return nil
+}
+
+// List is used to list the namespaces
+func (n *Namespace) List(args *models.NamespaceListRequest,
+	reply *models.NamespaceListResponse) error {
+	if done, err := n.srv.forward("Namespace.List", args, args, reply); done {
+		return err
+	}
+	defer metrics.MeasureSince([]string{"server", "namespace", "list"}, time.Now())
+
+	// Setup the blocking query
+	opts := blockingOptions{
+		queryOpts: &args.QueryOptions,
+		queryMeta: &reply.QueryMeta,
+		run: func(ws memdb.WatchSet, state *store.StateStore) error {
+			iter, err := state.Namespaces(ws)
+			if err != nil {
+				return err
+			}
+
+			var namespaces []*models.Namespace
+			for {
+				raw := iter.Next()
+				if raw == nil {
+					break
+				}
+				namespaces = append(namespaces, raw.(*models.Namespace))
+			}
+			reply.Namespaces = namespaces
+
+			// Use the last index that affected the namespaces table
+			index, err := state.Index("namespaces")
+			if err != nil {
+				return err
+			}
+			reply.Index = index
+
+			// Set the query response
+			n.srv.setQueryMeta(&reply.QueryMeta)
+			return nil
+		}}
+	return n.srv.blockingRPC(&opts)
+}
+
+// GetNamespace is used to request information about a specific namespace
+func (n *Namespace) GetNamespace(args *models.NamespaceSpecificRequest,
+	reply *models.SingleNamespaceResponse) error {
+	if done, err := n.srv.forward("Namespace.GetNamespace", args, args, reply); done {
+		return err
+	}
+	defer metrics.MeasureSince([]string{"server", "namespace", "get_namespace"}, time.Now())
+
+	// Setup the blocking query
+	opts := blockingOptions{
+		queryOpts: &args.QueryOptions,
+		queryMeta: &reply.QueryMeta,
+		run: func(ws memdb.WatchSet, state *store.StateStore) error {
+			out, err := state.NamespaceByName(ws, args.Name)
+			if err != nil {
+				return err
+			}
+
+			// Setup the output
+			reply.Namespace = out
+			if out != nil {
+				reply.Index = out.ModifyIndex
+			} else {
+				// Use the last index that affected the namespaces table
+				index, err := state.Index("namespaces")
+				if err != nil {
+					return err
+				}
+				reply.Index = index
+			}
+
+			// Set the query response
+			n.srv.setQueryMeta(&reply.QueryMeta)
+			return nil
+		}}
+	return n.srv.blockingRPC(&opts)
+}
+
+// Allowance is used by the clients to query the share of the namespace quota
+// each of the tasks of the namespace may use
+func (n *Namespace) Allowance(args *models.NamespaceAllowanceRequest,
+	reply *models.NamespaceAllowanceResponse) error {
+	if done, err := n.srv.forward("Namespace.Allowance", args, args, reply); done {
+		return err
+	}
+	defer metrics.MeasureSince([]string{"server", "namespace", "allowance"}, time.Now())
+
+	// Setup the blocking query
+	opts := blockingOptions{
+		queryOpts: &args.QueryOptions,
+		queryMeta: &reply.QueryMeta,
+		run: func(ws memdb.WatchSet, state *store.StateStore) error {
+			ns, err := state.NamespaceByName(ws, args.Namespace)
+			if err != nil {
+				return err
+			}
+
+			// Count the source tasks sharing the quota
+			iter, err := state.JobsByNamespace(ws, args.Namespace)
+			if err != nil {
+				return err
+			}
+			tasks := 0
+			for {
+				raw := iter.Next()
+				if raw == nil {
+					break
+				}
+				allocs, err := state.AllocsByJob(ws, raw.(*models.Job).ID, false)
+				if err != nil {
+					return err
+				}
+				for _, alloc := range allocs {
+					if alloc.Task == models.TaskTypeSrc && !alloc.TerminalStatus() {
+						tasks++
+					}
+				}
+			}
+
+			reply.Tasks = tasks
+			reply.TaskBandwidth = 0
+			if ns != nil && ns.Quota != nil && ns.Quota.MaxBandwidth > 0 {
+				share := tasks
+				if share == 0 {
+					share = 1
+				}
+				reply.TaskBandwidth = ns.Quota.MaxBandwidth / int64(share)
+				if reply.TaskBandwidth == 0 {
+					reply.TaskBandwidth = 1
+				}
+			}
+
+			// Use the last index that affected the allocs or namespaces table
+			reply.Index = 0
+			for _, table := range []string{"allocs", "namespaces"} {
+				index, err := state.Index(table)
+				if err != nil {
+					return err
+				}
+				if index > reply.Index {
+					reply.Index = index
+				}
+			}
+
+			// Set the query response
+			n.srv.setQueryMeta(&reply.QueryMeta)
+			return nil
+		}}
+	return n.srv.blockingRPC(&opts)
+}
+
+// checkNamespace verifies a job can be registered in its namespace: the
+// namespace must exist, the job name must not be used by another job of the
+// namespace and the quota must allow another active job.
+func checkNamespace(state *store.StateStore, job *models.Job) error {
+	ws := memdb.NewWatchSet()
+	ns, err := state.NamespaceByName(ws, job.Namespace)
+	if err != nil {
+		return err
+	}
+	if ns == nil && job.Namespace != models.DefaultNamespace {
+		return fmt.Errorf("namespace %q does not exist", job.Namespace)
+	}
+
+	iter, err := state.JobsByNamespace(ws, job.Namespace)
+	if err != nil {
+		return err
+	}
+	active := 0
+	for {
+		raw := iter.Next()
+		if raw == nil {
+			break
+		}
+		other := raw.(*models.Job)
+		if other.ID == job.ID {
+			continue
+		}
+		if other.Name == job.Name {
+			return fmt.Errorf("job name %q is already used by job %q in namespace %q",
+				job.Name, other.ID, job.Namespace)
+		}
+		if other.Status != models.JobStatusDead && other.Status != models.JobStatusComplete {
+			active++
+		}
+	}
+
+	if ns != nil && ns.Quota != nil && ns.Quota.MaxJobs > 0 && active >= ns.Quota.MaxJobs {
+		return fmt.Errorf("namespace %q quota exceeded: %d of %d jobs active",
+			job.Namespace, active, ns.Quota.MaxJobs)
+	}
+	return nil
+}
@@ -592,12 +592,83 @@ func (n *Node) batchUpdate(future *batchFuture, updates []*models.Allocation) {
 	if err != nil {
 		n.srv.logger.Errorf("server.agent: alloc update failed: %v", err)
 		mErr.Errors = append(mErr.Errors, err)
+	} else if err := n.createRescheduleEvals(updates); err != nil {
+		n.srv.logger.Errorf("server.agent: reschedule eval create failed: %v", err)
+		mErr.Errors = append(mErr.Errors, err)
 	}
 
 	// Respond to the future
 	future.Respond(index, mErr.ErrorOrNil())
 }
 
+// createRescheduleEvals creates an evaluation for every job whose allocation
+// failed on the client and whose reschedule policy allows moving it to
+// another node. The evaluation waits for the policy's backoff before it is
+// processed.
+func (n *Node) createRescheduleEvals(updates []*models.Allocation) error {
+	snap, err := n.srv.fsm.State().Snapshot()
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	ws := memdb.NewWatchSet()
+	var evals []*models.Evaluation
+	jobIDs := make(map[string]struct{})
+	for _, update := range updates {
+		if update.ClientStatus != models.AllocClientStatusFailed {
+			continue
+		}
+		alloc, err := snap.AllocByID(ws, update.ID)
+		if err != nil {
+			return err
+		}
+		if alloc == nil || alloc.DesiredStatus != models.AllocDesiredStatusRun {
+			continue
+		}
+		if _, ok := jobIDs[alloc.JobID]; ok {
+			continue
+		}
+
+		job, err := snap.JobByID(ws, alloc.JobID)
+		if err != nil {
+			return err
+		}
+		if job == nil || job.Reschedule == nil || !job.Reschedule.OnFailure {
+			continue
+		}
+		if job.Status == models.JobStatusPause {
+			continue
+		}
+		if !alloc.RescheduleEligible(job.Reschedule, now) {
+			n.srv.logger.Warnf("server.agent: alloc %q of job %q failed and has exhausted its reschedule policy",
+				alloc.ID, alloc.JobID)
+			continue
+		}
+		jobIDs[alloc.JobID] = struct{}{}
+
+		evals = append(evals, &models.Evaluation{
+			ID:             models.GenerateUUID(),
+			Type:           job.Type,
+			TriggeredBy:    models.EvalTriggerRetryFailed,
+			JobID:          job.ID,
+			JobModifyIndex: job.ModifyIndex,
+			Status:         models.EvalStatusPending,
+			Wait:           alloc.NextRescheduleDelay(job.Reschedule, now),
+		})
+	}
+	if len(evals) == 0 {
+		return nil
+	}
+
+	update := &models.EvalUpdateRequest{
+		Evals:        evals,
+		WriteRequest: models.WriteRequest{Region: n.srv.config.Region},
+	}
+	_, _, err = n.srv.raftApply(models.EvalUpdateRequestType, update)
+	return err
+}
+
 // List is used to list the available nodes
 func (n *Node) List(args *models.NodeListRequest,
 	reply *models.NodeListResponse) error {
@@ -7,9 +7,13 @@
 package server
 
 import (
+	"bytes"
 	"fmt"
+	"io/ioutil"
 	"net"
+	"time"
 
+	"github.com/armon/go-metrics"
 	"github.com/hashicorp/raft"
 	"github.com/hashicorp/serf/serf"
 
@@ -160,3 +164,102 @@ REMOVE:
 	op.srv.logger.Printf("[WARN] udup.operator: Removed Raft peer with id %q", args.ID)
 	return nil
 }
+
+// SnapshotSave is used to take a snapshot of the server state, including all
+// jobs and their checkpoints. The snapshot can be restored onto another
+// server cluster with SnapshotRestore.
+func (op *Operator) SnapshotSave(args *models.GenericRequest, reply *models.SnapshotSaveResponse) error {
+	if done, err := op.srv.forward("Operator.SnapshotSave", args, args, reply); done {
+		return err
+	}
+	defer metrics.MeasureSince([]string{"server", "operator", "snapshot_save"}, time.Now())
+
+	// Make sure everything committed so far is applied to the FSM.
+	if !args.AllowStale {
+		if err := op.srv.raft.Barrier(0).Error(); err != nil {
+			return err
+		}
+	}
+	index := op.srv.raft.AppliedIndex()
+
+	snap, err := op.srv.fsm.Snapshot()
+	if err != nil {
+		return err
+	}
+	defer snap.Release()
+
+	var sink snapshotBuffer
+	if err := snap.Persist(&sink); err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	if err := writeSnapshotArchive(&buf, index, sink.Bytes()); err != nil {
+		return err
+	}
+
+	reply.Index = index
+	reply.Data = buf.Bytes()
+	return nil
+}
+
+// reassertLeaderTimeout bounds the wait for the leader to rebuild its state
+// from a restored snapshot
+const reassertLeaderTimeout = 1 * time.Minute
+
+// SnapshotRestore is used to replace the server state with a snapshot taken
+// by SnapshotSave. It is meant for disaster recovery into a fresh server
+// cluster; the current state of the cluster is lost. The reply argument is
+// not used, but is required to fulfill the RPC interface.
+func (op *Operator) SnapshotRestore(args *models.SnapshotRestoreRequest, reply *struct{}) error {
+	if done, err := op.srv.forward("Operator.SnapshotRestore", args, args, reply); done {
+		return err
+	}
+	defer metrics.MeasureSince([]string{"server", "operator", "snapshot_restore"}, time.Now())
+
+	meta, state, err := readSnapshotArchive(bytes.NewReader(args.Data))
+	if err != nil {
+		return err
+	}
+
+	// Raft panics if the FSM fails to restore, so check the snapshot can be
+	// loaded into a scratch FSM first.
+	scratch, err := NewFSM(nil, nil, op.srv.config.LogOutput, op.srv.logger)
+	if err != nil {
+		return err
+	}
+	if err := scratch.Restore(ioutil.NopCloser(bytes.NewReader(state))); err != nil {
+		return fmt.Errorf("invalid snapshot: %v", err)
+	}
+
+	if err := op.srv.raft.Restore(meta, bytes.NewReader(state), 0); err != nil {
+		op.srv.logger.Warnf("udup.operator: Failed to restore snapshot: %v", err)
+		return err
+	}
+
+	// The leader state, such as the eval broker, was built from the old
+	// state, so have the leader rebuild it from the restored one. The leader
+	// loop is gone if the leadership was lost meanwhile, hence the timeout.
+	timeout := time.After(reassertLeaderTimeout)
+	errCh := make(chan error, 1)
+	select {
+	case op.srv.reassertLeaderCh <- errCh:
+	case <-op.srv.shutdownCh:
+		return fmt.Errorf("server shutting down")
+	case <-timeout:
+		return fmt.Errorf("timed out reasserting leadership after restoring snapshot")
+	}
+	select {
+	case err := <-errCh:
+		if err != nil {
+			return err
+		}
+	case <-op.srv.shutdownCh:
+		return fmt.Errorf("server shutting down")
+	case <-timeout:
+		return fmt.Errorf("timed out reasserting leadership after restoring snapshot")
+	}
+
+	op.srv.logger.Warnf("udup.operator: Restored snapshot at index %d", meta.Index)
+	return nil
+}
@@ -129,6 +129,7 @@ func (s *Server) applyPlan(job *models.Job, result *models.PlanResult, snap *sto
 	}
 
 	// Dispatch the Raft transaction
+	req.Time = now
 	future, err := s.raftApplyFuture(models.AllocUpdateRequestType, &req)
 	if err != nil {
 		return nil, err
@@ -137,7 +138,7 @@ func (s *Server) applyPlan(job *models.Job, result *models.PlanResult, snap *sto
 	// Optimistically apply to our store view
 	if snap != nil {
 		nextIdx := s.raft.AppliedIndex() + 1
-		if err := snap.UpsertAllocs(nextIdx, req.Alloc); err != nil {
+		if err := snap.UpsertAllocs(nextIdx, now, req.Alloc); err != nil {
 			return future, err
 		}
 	}
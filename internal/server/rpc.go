@@ -274,6 +274,10 @@ func (s *Server) forwardRegion(region, method string, args interface{}, reply in
 
 // raftApplyFuture is used to encode a message, run it through raft, and return the Raft future.
 func (s *Server) raftApplyFuture(t models.MessageType, msg interface{}) (raft.ApplyFuture, error) {
+	// The FSM records the time of the write rather than its own
+	if w, ok := msg.(interface{ StampTime(int64) }); ok {
+		w.StampTime(time.Now().UnixNano())
+	}
 	buf, err := models.Encode(t, msg)
 	if err != nil {
 		return nil, fmt.Errorf("Failed to encode request: %v", err)
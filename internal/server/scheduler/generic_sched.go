@@ -9,6 +9,7 @@ package scheduler
 import (
 	"fmt"
 	"math/rand"
+	"time"
 
 	//"math/rand"
 
@@ -69,6 +70,8 @@ type GenericScheduler struct {
 
 	nextEval *models.Evaluation
 
+	taintedNodes map[string]*models.Node
+
 	blocked        *models.Evaluation
 	failedTGAllocs map[string]*models.AllocMetric
 	queuedAllocs   map[string]int
@@ -94,7 +97,7 @@ func (s *GenericScheduler) Process(eval *models.Evaluation) error {
 	case models.EvalTriggerJobRegister, models.EvalTriggerNodeUpdate,
 		models.EvalTriggerJobDeregister, models.EvalTriggerRollingUpdate,
 		models.EvalTriggerJobPause, models.EvalTriggerJobResume,
-		models.EvalTriggerMaxPlans:
+		models.EvalTriggerMaxPlans, models.EvalTriggerRetryFailed:
 	default:
 		desc := fmt.Sprintf("scheduler cannot handle '%s' evaluation reason",
 			eval.TriggeredBy)
@@ -305,6 +308,7 @@ func (s *GenericScheduler) computeJobAllocs() error {
 		return fmt.Errorf("failed to get tainted nodes for job '%s': %v",
 			s.eval.JobID, err)
 	}
+	s.taintedNodes = tainted
 
 	// Update the allocations which are in pending/running store on tainted
 	// nodes to lost
@@ -389,7 +393,11 @@ func (s *GenericScheduler) computePlacements(place []allocTuple) error {
 		if preferredNode != nil {
 			// do nothing
 		} else {
-			nodeId := nodes[rand.Intn(len(nodes))].ID
+			candidates := nodes
+			if missing.Alloc != nil && missing.Alloc.ClientStatus == models.AllocClientStatusFailed {
+				candidates = excludeNode(nodes, missing.Alloc.NodeID)
+			}
+			nodeId := candidates[rand.Intn(len(candidates))].ID
 			s.logger.Debugf("sched: no preferred node. Auto selected node %v for task %v", nodeId, missing.Name)
 
 			ws := memdb.NewWatchSet() // TODO what is ws used for?
@@ -420,6 +428,7 @@ func (s *GenericScheduler) computePlacements(place []allocTuple) error {
 			// set the record the older allocation id so that they are chained
 			if missing.Alloc != nil {
 				alloc.PreviousAllocation = missing.Alloc.ID
+				alloc.RescheduleTracker = s.rescheduleTracker(missing.Alloc)
 			}
 
 			if missing.Task.Type == models.TaskTypeDest {
@@ -442,9 +451,56 @@ func (s *GenericScheduler) computePlacements(place []allocTuple) error {
 	return nil
 }
 
+// rescheduleTracker returns the reschedule history for a replacement of prev.
+// Only allocations that failed or were lost count as a reschedule; stopped
+// allocations being placed again after a resume start a fresh history.
+func (s *GenericScheduler) rescheduleTracker(prev *models.Allocation) *models.RescheduleTracker {
+	var reason string
+	switch prev.ClientStatus {
+	case models.AllocClientStatusFailed:
+		reason = models.AllocClientStatusFailed
+	case models.AllocClientStatusLost:
+		reason = models.AllocClientStatusLost
+	default:
+		if _, tainted := s.taintedNodes[prev.NodeID]; !tainted {
+			return prev.RescheduleTracker.Copy()
+		}
+		reason = models.AllocClientStatusLost
+	}
+
+	var delay time.Duration
+	if s.job != nil && s.job.Reschedule != nil {
+		delay = prev.NextRescheduleDelay(s.job.Reschedule, time.Now())
+	}
+
+	tracker := prev.RescheduleTracker.Copy()
+	if tracker == nil {
+		tracker = &models.RescheduleTracker{}
+	}
+	tracker.Events = append(tracker.Events, models.NewRescheduleEvent(
+		time.Now().UnixNano(), prev.ID, prev.NodeID, reason, delay))
+	return tracker
+}
+
+// excludeNode returns the nodes other than nodeID. If no other node is
+// available the input is returned unchanged.
+func excludeNode(nodes []*models.Node, nodeID string) []*models.Node {
+	var out []*models.Node
+	for _, n := range nodes {
+		if n.ID != nodeID {
+			out = append(out, n)
+		}
+	}
+	if len(out) == 0 {
+		return nodes
+	}
+	return out
+}
+
 // findPreferredNode finds the preferred node for an allocation
 func (s *GenericScheduler) findPreferredNode(allocTuple *allocTuple) (node *models.Node, err error) {
-	if allocTuple.Alloc != nil {
+	// A failed allocation is being rescheduled, so don't stick to its node.
+	if allocTuple.Alloc != nil && allocTuple.Alloc.ClientStatus != models.AllocClientStatusFailed {
 		task := allocTuple.Alloc.Job.LookupTask(allocTuple.Alloc.Task)
 		if task == nil {
 			err = fmt.Errorf("can't find task of existing allocation %q", allocTuple.Alloc.ID)
@@ -11,6 +11,7 @@ import (
 	"os"
 	"sync"
 	"testing"
+	"time"
 
 	memdb "github.com/hashicorp/go-memdb"
 
@@ -78,7 +79,7 @@ func (h *Harness) SubmitPlan(plan *models.Plan) (*models.PlanResult, State, erro
 	}
 
 	// Apply the full plan
-	err := h.State.UpsertAllocs(index, allocs)
+	err := h.State.UpsertAllocs(index, time.Now().UnixNano(), allocs)
 	return result, nil, err
 }
 
@@ -30,6 +30,7 @@ import (
 	"github.com/actiontech/dtle/internal"
 	uconf "github.com/actiontech/dtle/internal/config"
 	ulog "github.com/actiontech/dtle/internal/logger"
+	"github.com/actiontech/dtle/internal/models"
 	"github.com/actiontech/dtle/internal/server/store"
 )
 
@@ -100,6 +101,11 @@ type Server struct {
 	// join/leave from the region.
 	reconcileCh chan serf.Member
 
+	// reassertLeaderCh is used to ask the leader manager to revoke and
+	// re-establish leadership, such as after a snapshot restore replaced
+	// the state store.
+	reassertLeaderCh chan chan error
+
 	// eventCh is used to receive events from the serf cluster
 	eventCh chan serf.Event
 
@@ -138,6 +144,8 @@ type endpoints struct {
 	Eval   *Eval
 	Plan   *Plan
 	Alloc  *Alloc
+
+	Namespace *Namespace
 }
 
 // NewServer is used to construct a new Udup server from the
@@ -160,18 +168,19 @@ func NewServer(config *uconf.ServerConfig, logger *ulog.Logger) (*Server, error)
 
 	// Create the server
 	s := &Server{
-		config:       config,
-		connPool:     NewPool(config.LogOutput, serverRPCCache, serverMaxStreams),
-		logger:       logger,
-		rpcServer:    rpc.NewServer(),
-		peers:        make(map[string][]*serverParts),
-		localPeers:   make(map[raft.ServerAddress]*serverParts),
-		reconcileCh:  make(chan serf.Member, 32),
-		eventCh:      make(chan serf.Event, 256),
-		evalBroker:   evalBroker,
-		blockedEvals: blockedEvals,
-		planQueue:    planQueue,
-		shutdownCh:   make(chan struct{}),
+		config:           config,
+		connPool:         NewPool(config.LogOutput, serverRPCCache, serverMaxStreams),
+		logger:           logger,
+		rpcServer:        rpc.NewServer(),
+		peers:            make(map[string][]*serverParts),
+		localPeers:       make(map[raft.ServerAddress]*serverParts),
+		reconcileCh:      make(chan serf.Member, 32),
+		reassertLeaderCh: make(chan chan error),
+		eventCh:          make(chan serf.Event, 256),
+		evalBroker:       evalBroker,
+		blockedEvals:     blockedEvals,
+		planQueue:        planQueue,
+		shutdownCh:       make(chan struct{}),
 	}
 
 	// Initialize the RPC layer
@@ -392,6 +401,7 @@ func (s *Server) setupRPC() error {
 	s.endpoints.Node = &Node{srv: s}
 	s.endpoints.Plan = &Plan{s}
 	s.endpoints.Status = &Status{s}
+	s.endpoints.Namespace = &Namespace{s}
 
 	// Register the handlers
 	s.rpcServer.Register(s.endpoints.Alloc)
@@ -400,6 +410,7 @@ func (s *Server) setupRPC() error {
 	s.rpcServer.Register(s.endpoints.Order)
 	s.rpcServer.Register(s.endpoints.Node)
 	s.rpcServer.Register(s.endpoints.Plan)
+	s.rpcServer.Register(s.endpoints.Namespace)
 	s.rpcServer.Register(s.endpoints.Status)
 
 	list, err := net.ListenTCP("tcp", s.config.RPCAddr)
@@ -652,6 +663,37 @@ func (s *Server) IsLeader() bool {
 	}
 }
 
+// CheckConsensus returns the leader and the peers of the server, or an
+// error if the cluster has no leader
+func (s *Server) CheckConsensus() (string, error) {
+	if s.raft == nil {
+		leader := s.store.GetLeader()
+		if leader == nil {
+			return "", models.ErrNoLeader
+		}
+		return fmt.Sprintf("leader %s", leader), nil
+	}
+	leader := s.raft.Leader()
+	if leader == "" {
+		return "", models.ErrNoLeader
+	}
+	peers, err := s.numPeers()
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("leader %s, %d peers", leader, peers), nil
+}
+
+// CheckConsul returns an error if the Consul store of the server is not
+// reachable
+func (s *Server) CheckConsul() error {
+	if s.store == nil {
+		return fmt.Errorf("no consul store")
+	}
+	_, err := s.store.Client.Exists(s.store.LeaderKey())
+	return err
+}
+
 // Join is used to have Udup join the gossip ring
 // The target address should be another node listening on the
 // Serf address
@@ -0,0 +1,118 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package server
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+
+	"github.com/hashicorp/raft"
+	"github.com/ugorji/go/codec"
+
+	"github.com/actiontech/dtle/internal/models"
+)
+
+const (
+	// snapshotArchiveVersion is the version of the operator snapshot
+	// archive format
+	snapshotArchiveVersion = 1
+)
+
+// snapshotArchiveHeader is the first entry in an operator snapshot archive.
+// It is followed by the FSM snapshot itself.
+type snapshotArchiveHeader struct {
+	Version int
+
+	// Index is the raft index the snapshot was taken at
+	Index uint64
+
+	// Size and SHA256 describe the FSM snapshot following the header
+	Size   int64
+	SHA256 string
+}
+
+// snapshotBuffer is an in-memory raft.SnapshotSink used to persist the FSM
+// for an operator snapshot
+type snapshotBuffer struct {
+	bytes.Buffer
+}
+
+func (b *snapshotBuffer) ID() string {
+	return "operator"
+}
+
+func (b *snapshotBuffer) Cancel() error {
+	return nil
+}
+
+func (b *snapshotBuffer) Close() error {
+	return nil
+}
+
+// writeSnapshotArchive writes the FSM snapshot taken at index as a gzipped
+// archive to w.
+func writeSnapshotArchive(w io.Writer, index uint64, state []byte) error {
+	sum := sha256.Sum256(state)
+	header := snapshotArchiveHeader{
+		Version: snapshotArchiveVersion,
+		Index:   index,
+		Size:    int64(len(state)),
+		SHA256:  hex.EncodeToString(sum[:]),
+	}
+
+	zw := gzip.NewWriter(w)
+	if err := codec.NewEncoder(zw, models.MsgpackHandle).Encode(&header); err != nil {
+		return fmt.Errorf("failed to write snapshot header: %v", err)
+	}
+	if _, err := zw.Write(state); err != nil {
+		return fmt.Errorf("failed to write snapshot: %v", err)
+	}
+	return zw.Close()
+}
+
+// readSnapshotArchive reads an archive written by writeSnapshotArchive and
+// returns the raft metadata along with the FSM snapshot, after verifying its
+// checksum.
+func readSnapshotArchive(r io.Reader) (*raft.SnapshotMeta, []byte, error) {
+	zr, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to open snapshot: %v", err)
+	}
+	defer zr.Close()
+
+	var header snapshotArchiveHeader
+	if err := codec.NewDecoder(zr, models.MsgpackHandle).Decode(&header); err != nil {
+		return nil, nil, fmt.Errorf("failed to read snapshot header: %v", err)
+	}
+	if header.Version != snapshotArchiveVersion {
+		return nil, nil, fmt.Errorf("unsupported snapshot version %d", header.Version)
+	}
+
+	state, err := ioutil.ReadAll(zr)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read snapshot: %v", err)
+	}
+	if int64(len(state)) != header.Size {
+		return nil, nil, fmt.Errorf("snapshot size mismatch (%d != %d)", len(state), header.Size)
+	}
+	sum := sha256.Sum256(state)
+	if hex.EncodeToString(sum[:]) != header.SHA256 {
+		return nil, nil, fmt.Errorf("snapshot checksum mismatch")
+	}
+
+	meta := &raft.SnapshotMeta{
+		Version: raft.SnapshotVersionMax,
+		Index:   header.Index,
+		Size:    header.Size,
+	}
+	return meta, state, nil
+}
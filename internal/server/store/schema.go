@@ -29,6 +29,8 @@ func stateStoreSchema() *memdb.DBSchema {
 		orderTableSchema,
 		evalTableSchema,
 		allocTableSchema,
+		jobEventTableSchema,
+		namespaceTableSchema,
 	}
 
 	// Add each of the tables
@@ -108,6 +110,14 @@ func jobTableSchema() *memdb.TableSchema {
 					Lowercase: false,
 				},
 			},
+			"namespace": {
+				Name:         "namespace",
+				AllowMissing: false,
+				Unique:       false,
+				Indexer: &memdb.StringFieldIndex{
+					Field: "Namespace",
+				},
+			},
 		},
 	}
 }
@@ -240,3 +250,52 @@ func allocTableSchema() *memdb.TableSchema {
 		},
 	}
 }
+
+// jobEventTableSchema returns the MemDB schema for the job events table.
+// This table is used to store the timeline of each job.
+func jobEventTableSchema() *memdb.TableSchema {
+	return &memdb.TableSchema{
+		Name: "job_events",
+		Indexes: map[string]*memdb.IndexSchema{
+			// Primary index is used for direct lookup. The ID embeds the
+			// job ID and the raft index so it sorts in creation order.
+			"id": {
+				Name:         "id",
+				AllowMissing: false,
+				Unique:       true,
+				Indexer: &memdb.StringFieldIndex{
+					Field: "ID",
+				},
+			},
+
+			// Job index is used to lookup events by job
+			"job": {
+				Name:         "job",
+				AllowMissing: false,
+				Unique:       false,
+				Indexer: &memdb.StringFieldIndex{
+					Field:     "JobID",
+					Lowercase: true,
+				},
+			},
+		},
+	}
+}
+
+// namespaceTableSchema returns the MemDB schema for the namespaces table.
+// This table is used to store the namespaces jobs are registered in.
+func namespaceTableSchema() *memdb.TableSchema {
+	return &memdb.TableSchema{
+		Name: "namespaces",
+		Indexes: map[string]*memdb.IndexSchema{
+			"id": {
+				Name:         "id",
+				AllowMissing: false,
+				Unique:       true,
+				Indexer: &memdb.StringFieldIndex{
+					Field: "Name",
+				},
+			},
+		},
+	}
+}
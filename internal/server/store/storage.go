@@ -11,6 +11,7 @@ import (
 	"io"
 	"log"
 	"strconv"
+	"time"
 
 	"github.com/hashicorp/go-memdb"
 
@@ -154,7 +155,9 @@ func (s *StateStore) DeleteNode(index uint64, nodeID string) error {
 	return nil
 }
 
-func (s *StateStore) UpdateJobStatus(index uint64, jobID, status string) error {
+// UpdateJobStatus sets the status of a job. at is the unix nano time of the
+// write, which the job events are recorded at.
+func (s *StateStore) UpdateJobStatus(index uint64, at int64, jobID, status string) error {
 	txn := s.db.Txn(true)
 	defer txn.Abort()
 
@@ -186,6 +189,16 @@ func (s *StateStore) UpdateJobStatus(index uint64, jobID, status string) error {
 		return fmt.Errorf("index update failed: %v", err)
 	}
 
+	var events []*models.JobEvent
+	if status == models.JobStatusPause && existingJob.Status != models.JobStatusPause {
+		events = append(events, models.NewJobEvent(jobID, models.JobEventPaused, "Job paused", index, at))
+	} else if status != models.JobStatusPause && existingJob.Status == models.JobStatusPause {
+		events = append(events, models.NewJobEvent(jobID, models.JobEventResumed, "Job resumed", index, at))
+	}
+	if err := s.nestedInsertJobEvents(txn, index, events); err != nil {
+		return err
+	}
+
 	txn.Commit()
 	return nil
 }
@@ -353,11 +366,16 @@ func (s *StateStore) Nodes(ws memdb.WatchSet) (memdb.ResultIterator, error) {
 	return iter, nil
 }
 
-// UpsertJob is used to register a job or update a job definition
-func (s *StateStore) UpsertJob(index uint64, job *models.Job) error {
+// UpsertJob is used to register a job or update a job definition. at is the
+// unix nano time of the write, which the job events are recorded at.
+func (s *StateStore) UpsertJob(index uint64, at int64, job *models.Job) error {
 	txn := s.db.Txn(true)
 	defer txn.Abort()
 
+	if job.Namespace == "" {
+		job.Namespace = models.DefaultNamespace
+	}
+
 	// Check if the job already exists
 	existing, err := txn.First("jobs", "id", job.ID)
 	if err != nil {
@@ -365,11 +383,15 @@ func (s *StateStore) UpsertJob(index uint64, job *models.Job) error {
 	}
 
 	// Setup the indexes correctly
+	var event *models.JobEvent
 	if existing != nil {
 		if existing.(*models.Job).Status == models.JobStatusRunning {
 			return nil
 		}
+		event = models.NewJobEvent(job.ID, models.JobEventSubmitted, "Job updated", index, at)
 		job.CreateIndex = existing.(*models.Job).CreateIndex
+		job.Health = existing.(*models.Job).Health
+		job.HealthDescription = existing.(*models.Job).HealthDescription
 		job.ModifyIndex = index
 		job.JobModifyIndex = index
 		for _, t1 := range existing.(*models.Job).Tasks {
@@ -391,6 +413,7 @@ func (s *StateStore) UpsertJob(index uint64, job *models.Job) error {
 		job.CreateIndex = index
 		job.ModifyIndex = index
 		job.JobModifyIndex = index
+		event = models.NewJobEvent(job.ID, models.JobEventSubmitted, "Job registered", index, at)
 
 		if err := s.setJobStatus(index, txn, job, false, ""); err != nil {
 			return fmt.Errorf("setting job status for %q failed: %v", job.ID, err)
@@ -431,6 +454,9 @@ func (s *StateStore) UpsertJob(index uint64, job *models.Job) error {
 	if err := txn.Insert("index", &IndexEntry{"jobs", index}); err != nil {
 		return fmt.Errorf("index update failed: %v", err)
 	}
+	if err := s.nestedInsertJobEvents(txn, index, []*models.JobEvent{event}); err != nil {
+		return err
+	}
 
 	txn.Commit()
 	return nil
@@ -592,6 +618,14 @@ func (s *StateStore) DeleteJob(index uint64, jobID string) error {
 		return fmt.Errorf("index update failed: %v", err)
 	}
 
+	// Delete the timeline of the job
+	if _, err := txn.DeleteAll("job_events", "job", jobID); err != nil {
+		return fmt.Errorf("job events delete failed: %v", err)
+	}
+	if err := txn.Insert("index", &IndexEntry{"job_events", index}); err != nil {
+		return fmt.Errorf("index update failed: %v", err)
+	}
+
 	txn.Commit()
 	return nil
 }
@@ -657,6 +691,118 @@ func (s *StateStore) JobsByScheduler(ws memdb.WatchSet, schedulerType string) (m
 	return iter, nil
 }
 
+// JobsByNamespace returns an iterator over all the jobs of a namespace
+func (s *StateStore) JobsByNamespace(ws memdb.WatchSet, namespace string) (memdb.ResultIterator, error) {
+	txn := s.db.Txn(false)
+
+	iter, err := txn.Get("jobs", "namespace", namespace)
+	if err != nil {
+		return nil, err
+	}
+
+	ws.Add(iter.WatchCh())
+
+	return iter, nil
+}
+
+// UpsertNamespaces is used to create or update a set of namespaces
+func (s *StateStore) UpsertNamespaces(index uint64, namespaces []*models.Namespace) error {
+	txn := s.db.Txn(true)
+	defer txn.Abort()
+
+	for _, ns := range namespaces {
+		existing, err := txn.First("namespaces", "id", ns.Name)
+		if err != nil {
+			return fmt.Errorf("namespace lookup failed: %v", err)
+		}
+		if existing != nil {
+			ns.CreateIndex = existing.(*models.Namespace).CreateIndex
+		} else {
+			ns.CreateIndex = index
+		}
+		ns.ModifyIndex = index
+
+		if err := txn.Insert("namespaces", ns); err != nil {
+			return fmt.Errorf("namespace insert failed: %v", err)
+		}
+	}
+	if err := txn.Insert("index", &IndexEntry{"namespaces", index}); err != nil {
+		return fmt.Errorf("index update failed: %v", err)
+	}
+
+	txn.Commit()
+	return nil
+}
+
+// DeleteNamespaces is used to delete a set of namespaces. A namespace can
+// only be deleted once it has no jobs left.
+func (s *StateStore) DeleteNamespaces(index uint64, names []string) error {
+	txn := s.db.Txn(true)
+	defer txn.Abort()
+
+	for _, name := range names {
+		if name == models.DefaultNamespace {
+			return fmt.Errorf("default namespace can not be deleted")
+		}
+
+		existing, err := txn.First("namespaces", "id", name)
+		if err != nil {
+			return fmt.Errorf("namespace lookup failed: %v", err)
+		}
+		if existing == nil {
+			return fmt.Errorf("namespace %q not found", name)
+		}
+
+		job, err := txn.First("jobs", "namespace", name)
+		if err != nil {
+			return fmt.Errorf("job lookup failed: %v", err)
+		}
+		if job != nil {
+			return fmt.Errorf("namespace %q has jobs", name)
+		}
+
+		if err := txn.Delete("namespaces", existing); err != nil {
+			return fmt.Errorf("namespace delete failed: %v", err)
+		}
+	}
+	if err := txn.Insert("index", &IndexEntry{"namespaces", index}); err != nil {
+		return fmt.Errorf("index update failed: %v", err)
+	}
+
+	txn.Commit()
+	return nil
+}
+
+// NamespaceByName is used to lookup a namespace by its name
+func (s *StateStore) NamespaceByName(ws memdb.WatchSet, name string) (*models.Namespace, error) {
+	txn := s.db.Txn(false)
+
+	watchCh, existing, err := txn.FirstWatch("namespaces", "id", name)
+	if err != nil {
+		return nil, fmt.Errorf("namespace lookup failed: %v", err)
+	}
+	ws.Add(watchCh)
+
+	if existing != nil {
+		return existing.(*models.Namespace), nil
+	}
+	return nil, nil
+}
+
+// Namespaces returns an iterator over all the namespaces
+func (s *StateStore) Namespaces(ws memdb.WatchSet) (memdb.ResultIterator, error) {
+	txn := s.db.Txn(false)
+
+	iter, err := txn.Get("namespaces", "id")
+	if err != nil {
+		return nil, err
+	}
+
+	ws.Add(iter.WatchCh())
+
+	return iter, nil
+}
+
 //order start
 func (s *StateStore) UpsertOrder(index uint64, order *models.Order) error {
 	txn := s.db.Txn(true)
@@ -1015,6 +1161,11 @@ func (s *StateStore) nestedUpdateAllocFromClient(txn *memdb.Txn, index uint64, a
 	// Copy everything from the existing allocation
 	copyAlloc := exist.Copy()
 
+	// Record the task events the client reported since the last update
+	if err := s.nestedInsertJobEvents(txn, index, taskJobEvents(index, exist, alloc)); err != nil {
+		return err
+	}
+
 	// Pull in anything the client is the authority on
 	//if exist.DesiredStatus != models.AllocDesiredStatusPause {
 	copyAlloc.ClientStatus = alloc.ClientStatus
@@ -1050,13 +1201,15 @@ func (s *StateStore) nestedUpdateAllocFromClient(txn *memdb.Txn, index uint64, a
 }
 
 // UpsertAllocs is used to evict a set of allocations
-// and allocate new ones at the same time.
-func (s *StateStore) UpsertAllocs(index uint64, allocs []*models.Allocation) error {
+// and allocate new ones at the same time. at is the unix nano time of the
+// write, which the job events are recorded at.
+func (s *StateStore) UpsertAllocs(index uint64, at int64, allocs []*models.Allocation) error {
 	txn := s.db.Txn(true)
 	defer txn.Abort()
 
 	// Handle the allocations
 	jobs := make(map[string]string, 1)
+	var events []*models.JobEvent
 	for _, alloc := range allocs {
 		existing, err := txn.First("allocs", "id", alloc.ID)
 		if err != nil {
@@ -1068,6 +1221,7 @@ func (s *StateStore) UpsertAllocs(index uint64, allocs []*models.Allocation) err
 			alloc.CreateIndex = index
 			alloc.ModifyIndex = index
 			alloc.AllocModifyIndex = index
+			events = append(events, placementJobEvent(index, at, alloc))
 		} else {
 			alloc.CreateIndex = exist.CreateIndex
 			alloc.ModifyIndex = index
@@ -1107,12 +1261,17 @@ func (s *StateStore) UpsertAllocs(index uint64, allocs []*models.Allocation) err
 	if err := s.setJobStatuses(index, txn, jobs, false); err != nil {
 		return fmt.Errorf("setting job status failed: %v", err)
 	}
+	if err := s.nestedInsertJobEvents(txn, index, events); err != nil {
+		return err
+	}
 
 	txn.Commit()
 	return nil
 }
 
-func (s *StateStore) UpsertAlloc(index uint64, alloc *models.Allocation) error {
+// UpsertAlloc upserts an allocation. at is the unix nano time of the write,
+// which the job events are recorded at.
+func (s *StateStore) UpsertAlloc(index uint64, at int64, alloc *models.Allocation) error {
 	txn := s.db.Txn(true)
 	defer txn.Abort()
 
@@ -1124,15 +1283,26 @@ func (s *StateStore) UpsertAlloc(index uint64, alloc *models.Allocation) error {
 	}
 	exist, _ := existing.(*models.Allocation)
 
+	var events []*models.JobEvent
 	if exist == nil {
 		alloc.CreateIndex = index
 		alloc.ModifyIndex = index
 		alloc.AllocModifyIndex = index
+		events = append(events, placementJobEvent(index, at, alloc))
 	} else {
 		alloc.CreateIndex = exist.CreateIndex
 		alloc.ModifyIndex = index
 		alloc.AllocModifyIndex = index
 
+		if exist.NodeID != alloc.NodeID {
+			event := models.NewJobEvent(alloc.JobID, models.JobEventRescheduled,
+				fmt.Sprintf("Task %s moved from node %s to node %s", alloc.Task, exist.NodeID, alloc.NodeID), index, at)
+			event.Task = alloc.Task
+			event.AllocID = alloc.ID
+			event.NodeID = alloc.NodeID
+			events = append(events, event)
+		}
+
 		// If the scheduler is marking this allocation as lost we do not
 		// want to reuse the status of the existing allocation.
 		if alloc.ClientStatus != models.AllocClientStatusLost {
@@ -1166,11 +1336,220 @@ func (s *StateStore) UpsertAlloc(index uint64, alloc *models.Allocation) error {
 	if err := s.setJobStatuses(index, txn, jobs, false); err != nil {
 		return fmt.Errorf("setting job status failed: %v", err)
 	}
+	if err := s.nestedInsertJobEvents(txn, index, events); err != nil {
+		return err
+	}
 
 	txn.Commit()
 	return nil
 }
 
+// placementJobEvent returns the job event recording the placement of a new
+// allocation. Allocations replacing a failed one are recorded as rescheduled.
+func placementJobEvent(index uint64, at int64, alloc *models.Allocation) *models.JobEvent {
+	event := models.NewJobEvent(alloc.JobID, models.JobEventPlaced,
+		fmt.Sprintf("Task %s placed on node %s", alloc.Task, alloc.NodeID), index, at)
+	if alloc.RescheduleTracker != nil && len(alloc.RescheduleTracker.Events) > 0 {
+		last := alloc.RescheduleTracker.Events[len(alloc.RescheduleTracker.Events)-1]
+		event.Type = models.JobEventRescheduled
+		event.Message = fmt.Sprintf("Task %s rescheduled on node %s, replacing allocation %s on node %s",
+			alloc.Task, alloc.NodeID, last.PrevAllocID, last.PrevNodeID)
+		if last.Reason != "" {
+			event.Message = fmt.Sprintf("%s: %s", event.Message, last.Reason)
+		}
+	}
+	event.Task = alloc.Task
+	event.AllocID = alloc.ID
+	event.NodeID = alloc.NodeID
+	return event
+}
+
+// taskJobEvents returns the job events for the task events a client reported
+// in update that were not part of the existing allocation yet.
+func taskJobEvents(index uint64, exist, update *models.Allocation) []*models.JobEvent {
+	var events []*models.JobEvent
+	for task, state := range update.TaskStates {
+		if state == nil {
+			continue
+		}
+
+		// Task events are only appended by the client, so everything newer
+		// than the last known event has not been recorded yet.
+		var last time.Time
+		if prev, ok := exist.TaskStates[task]; ok && prev != nil && len(prev.Events) > 0 {
+			last = prev.Events[len(prev.Events)-1].Time
+		}
+		for _, te := range state.Events {
+			if te == nil || !te.Time.After(last) {
+				continue
+			}
+			eventType, message := models.TaskEventToJobEvent(te)
+			if eventType == "" {
+				continue
+			}
+			if message == "" {
+				message = te.Type
+			}
+			event := models.NewJobEvent(exist.JobID, eventType, message, index, te.Time.UnixNano())
+			event.Task = task
+			event.AllocID = exist.ID
+			event.NodeID = exist.NodeID
+			if te.ErrorClass != "" {
+				event.Details = map[string]string{models.JobEventDetailErrorClass: te.ErrorClass}
+			}
+			if te.CrashReport != "" {
+				if event.Details == nil {
+					event.Details = make(map[string]string)
+				}
+				event.Details[models.JobEventDetailCrashReport] = te.CrashReport
+			}
+			events = append(events, event)
+		}
+	}
+	return events
+}
+
+// UpsertJobEvents is used to record events in the timeline of their jobs,
+// created at the given index
+func (s *StateStore) UpsertJobEvents(index uint64, events []*models.JobEvent) error {
+	txn := s.db.Txn(true)
+	defer txn.Abort()
+
+	for _, event := range events {
+		event.CreateIndex = index
+	}
+	if err := s.nestedInsertJobEvents(txn, index, events); err != nil {
+		return err
+	}
+	txn.Commit()
+	return nil
+}
+
+// nestedInsertJobEvents is used to record events in the timeline of their
+// jobs. Once a job has more than MaxJobEvents events the oldest are dropped.
+func (s *StateStore) nestedInsertJobEvents(txn *memdb.Txn, index uint64, events []*models.JobEvent) error {
+	if len(events) == 0 {
+		return nil
+	}
+
+	jobs := make(map[string]struct{})
+	for _, event := range events {
+		if event == nil {
+			continue
+		}
+
+		// Find a free ID for the event at this index
+		for seq := 0; ; seq++ {
+			id := models.JobEventID(event.JobID, index, seq)
+			existing, err := txn.First("job_events", "id", id)
+			if err != nil {
+				return fmt.Errorf("job event lookup failed: %v", err)
+			}
+			if existing == nil {
+				event.ID = id
+				break
+			}
+		}
+		if err := txn.Insert("job_events", event); err != nil {
+			return fmt.Errorf("job event insert failed: %v", err)
+		}
+		jobs[event.JobID] = struct{}{}
+
+		if event.Type == models.JobEventDegraded || event.Type == models.JobEventRecovered {
+			if err := s.nestedUpdateJobHealth(txn, index, event); err != nil {
+				return err
+			}
+		}
+	}
+
+	for jobID := range jobs {
+		iter, err := txn.Get("job_events", "job", jobID)
+		if err != nil {
+			return fmt.Errorf("job events lookup failed: %v", err)
+		}
+		var existing []interface{}
+		for raw := iter.Next(); raw != nil; raw = iter.Next() {
+			existing = append(existing, raw)
+		}
+		for i := 0; i < len(existing)-models.MaxJobEvents; i++ {
+			if err := txn.Delete("job_events", existing[i]); err != nil {
+				return fmt.Errorf("job event delete failed: %v", err)
+			}
+		}
+	}
+
+	if err := txn.Insert("index", &IndexEntry{"job_events", index}); err != nil {
+		return fmt.Errorf("index update failed: %v", err)
+	}
+	return nil
+}
+
+// nestedUpdateJobHealth sets the health of the job of a degraded or
+// recovered event
+func (s *StateStore) nestedUpdateJobHealth(txn *memdb.Txn, index uint64, event *models.JobEvent) error {
+	existing, err := txn.First("jobs", "id", event.JobID)
+	if err != nil {
+		return fmt.Errorf("job lookup failed: %v", err)
+	}
+	if existing == nil {
+		return nil
+	}
+
+	job := existing.(*models.Job).Copy()
+	if event.Type == models.JobEventDegraded {
+		job.Health = models.JobHealthDegraded
+		job.HealthDescription = event.Message
+	} else {
+		job.Health = models.JobHealthHealthy
+		job.HealthDescription = ""
+	}
+	job.ModifyIndex = index
+	if err := txn.Insert("jobs", job); err != nil {
+		return fmt.Errorf("job insert failed: %v", err)
+	}
+	if err := txn.Insert("index", &IndexEntry{"jobs", index}); err != nil {
+		return fmt.Errorf("index update failed: %v", err)
+	}
+	return nil
+}
+
+// JobEventsByJob returns the timeline of a job, oldest event first
+func (s *StateStore) JobEventsByJob(ws memdb.WatchSet, jobID string) ([]*models.JobEvent, error) {
+	txn := s.db.Txn(false)
+
+	iter, err := txn.Get("job_events", "job", jobID)
+	if err != nil {
+		return nil, err
+	}
+
+	ws.Add(iter.WatchCh())
+
+	var out []*models.JobEvent
+	for {
+		raw := iter.Next()
+		if raw == nil {
+			break
+		}
+		out = append(out, raw.(*models.JobEvent))
+	}
+	return out, nil
+}
+
+// JobEvents returns an iterator over all the job events
+func (s *StateStore) JobEvents(ws memdb.WatchSet) (memdb.ResultIterator, error) {
+	txn := s.db.Txn(false)
+
+	// Walk the entire table
+	iter, err := txn.Get("job_events", "id")
+	if err != nil {
+		return nil, err
+	}
+
+	ws.Add(iter.WatchCh())
+
+	return iter, nil
+}
+
 // AllocByID is used to lookup an allocation by its ID
 func (s *StateStore) AllocByID(ws memdb.WatchSet, id string) (*models.Allocation, error) {
 	txn := s.db.Txn(false)
@@ -1546,6 +1925,10 @@ func (r *StateRestore) NodeRestore(node *models.Node) error {
 
 // JobRestore is used to restore a job
 func (r *StateRestore) JobRestore(job *models.Job) error {
+	// Jobs from before namespaces were added belong to the default one
+	if job.Namespace == "" {
+		job.Namespace = models.DefaultNamespace
+	}
 	if err := r.txn.Insert("jobs", job); err != nil {
 		return fmt.Errorf("job insert failed: %v", err)
 	}
@@ -1568,6 +1951,22 @@ func (r *StateRestore) AllocRestore(alloc *models.Allocation) error {
 	return nil
 }
 
+// JobEventRestore is used to restore a job event
+func (r *StateRestore) JobEventRestore(event *models.JobEvent) error {
+	if err := r.txn.Insert("job_events", event); err != nil {
+		return fmt.Errorf("job event insert failed: %v", err)
+	}
+	return nil
+}
+
+// NamespaceRestore is used to restore a namespace
+func (r *StateRestore) NamespaceRestore(ns *models.Namespace) error {
+	if err := r.txn.Insert("namespaces", ns); err != nil {
+		return fmt.Errorf("namespace insert failed: %v", err)
+	}
+	return nil
+}
+
 // IndexRestore is used to restore an index
 func (r *StateRestore) IndexRestore(idx *IndexEntry) error {
 	if err := r.txn.Insert("index", idx); err != nil {
@@ -0,0 +1,32 @@
+package store
+
+import (
+	"io/ioutil"
+	"testing"
+
+	"github.com/actiontech/dtle/internal/models"
+)
+
+func TestStateStore_JobEventsAtWriteTime(t *testing.T) {
+	const at = int64(1500000000123456789)
+	for i := 0; i < 2; i++ {
+		// every server applies the write at the time the leader stamped
+		s, err := NewStateStore(ioutil.Discard)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := s.UpsertJob(10, at, &models.Job{ID: "job", Name: "job", Type: models.JobTypeSync, Status: models.JobStatusPending}); err != nil {
+			t.Fatal(err)
+		}
+		if err := s.UpdateJobStatus(11, at+1, "job", models.JobStatusPause); err != nil {
+			t.Fatal(err)
+		}
+		events, err := s.JobEventsByJob(nil, "job")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(events) != 2 || events[0].Time != at || events[1].Time != at+1 {
+			t.Fatalf("events = %+v, want them at %v and %v", events, at, at+1)
+		}
+	}
+}
@@ -0,0 +1,346 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * Based on: github.com/hashicorp/nomad, github.com/github/gh-ost .
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package server
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/hashicorp/go-cleanhttp"
+	"github.com/hashicorp/go-memdb"
+
+	"github.com/actiontech/dtle/internal/config"
+	log "github.com/actiontech/dtle/internal/logger"
+	"github.com/actiontech/dtle/internal/models"
+)
+
+const (
+	// webhookQueueSize is the number of notifications a webhook buffers
+	// while deliveries are slow. Further notifications are dropped.
+	webhookQueueSize = 256
+
+	// webhookRetryBase is the wait before the first retry of a delivery,
+	// doubled for each further retry
+	webhookRetryBase = time.Second
+
+	defaultWebhookRetries = 3
+	defaultWebhookTimeout = 10 * time.Second
+)
+
+// defaultWebhookEvents are the job events notified by the webhooks that do
+// not list their events
+var defaultWebhookEvents = []string{
+	models.JobEventError,
+	models.JobEventSnapshotFinished,
+	models.JobEventPaused,
+	models.JobEventWarning,
+	models.JobEventBlocked,
+	models.JobEventDegraded,
+	models.JobEventRecovered,
+}
+
+// WebhookNotification is the body of the json webhooks
+type WebhookNotification struct {
+	Region    string
+	Namespace string
+	JobID     string
+	JobName   string
+	Type      string
+	Task      string
+	AllocID   string
+	NodeID    string
+	Message   string
+	Time      int64
+}
+
+// webhookLoop runs as long as we are the leader and notifies the configured
+// webhooks of the job events recorded from then on.
+func (s *Server) webhookLoop(stopCh chan struct{}) {
+	var hooks []*webhook
+	for _, conf := range s.config.Webhooks {
+		hook := newWebhook(conf, s.logger)
+		go hook.run(stopCh)
+		hooks = append(hooks, hook)
+	}
+
+	index, err := s.fsm.State().Index("job_events")
+	if err != nil {
+		s.logger.Errorf("manager: webhook: failed to read the job events index: %v", err)
+		return
+	}
+	for {
+		state := s.fsm.State()
+		ws := memdb.NewWatchSet()
+		ws.Add(stopCh)
+		ws.Add(state.AbandonCh())
+
+		notifications, last, err := s.newJobEvents(ws, index)
+		if err != nil {
+			s.logger.Errorf("manager: webhook: failed to read job events: %v", err)
+			return
+		}
+		for _, n := range notifications {
+			for _, hook := range hooks {
+				hook.notify(n)
+			}
+		}
+		index = last
+
+		ws.Watch(nil)
+		select {
+		case <-stopCh:
+			return
+		default:
+		}
+	}
+}
+
+// newJobEvents returns the job events recorded after the given index, oldest
+// first, along with the index of the newest event.
+func (s *Server) newJobEvents(ws memdb.WatchSet, index uint64) ([]*WebhookNotification, uint64, error) {
+	state := s.fsm.State()
+	iter, err := state.JobEvents(ws)
+	if err != nil {
+		return nil, index, err
+	}
+
+	var events []*models.JobEvent
+	last := index
+	for {
+		raw := iter.Next()
+		if raw == nil {
+			break
+		}
+		event := raw.(*models.JobEvent)
+		if event.CreateIndex <= index {
+			continue
+		}
+		events = append(events, event)
+		if event.CreateIndex > last {
+			last = event.CreateIndex
+		}
+	}
+	sort.Slice(events, func(i, j int) bool {
+		if events[i].CreateIndex != events[j].CreateIndex {
+			return events[i].CreateIndex < events[j].CreateIndex
+		}
+		return events[i].ID < events[j].ID
+	})
+
+	var notifications []*WebhookNotification
+	for _, event := range events {
+		n := &WebhookNotification{
+			Region:  s.config.Region,
+			JobID:   event.JobID,
+			Type:    event.Type,
+			Task:    event.Task,
+			AllocID: event.AllocID,
+			NodeID:  event.NodeID,
+			Message: event.Message,
+			Time:    event.Time,
+		}
+		job, err := state.JobByID(nil, event.JobID)
+		if err != nil {
+			return nil, index, err
+		}
+		if job != nil {
+			n.Namespace = job.Namespace
+			n.JobName = job.Name
+		}
+		notifications = append(notifications, n)
+	}
+	return notifications, last, nil
+}
+
+// webhook delivers notifications to a single endpoint, in order
+type webhook struct {
+	config  *config.WebhookConfig
+	events  map[string]struct{}
+	client  *http.Client
+	queueCh chan *WebhookNotification
+	logger  *log.Logger
+}
+
+func newWebhook(conf *config.WebhookConfig, logger *log.Logger) *webhook {
+	h := &webhook{
+		config:  conf,
+		events:  make(map[string]struct{}),
+		client:  cleanhttp.DefaultClient(),
+		queueCh: make(chan *WebhookNotification, webhookQueueSize),
+		logger:  logger,
+	}
+	events := conf.Events
+	if len(events) == 0 {
+		events = defaultWebhookEvents
+	}
+	for _, e := range events {
+		h.events[e] = struct{}{}
+	}
+	h.client.Timeout = conf.Timeout
+	if h.client.Timeout == 0 {
+		h.client.Timeout = defaultWebhookTimeout
+	}
+	return h
+}
+
+// notify queues the notification if the webhook is interested in it
+func (h *webhook) notify(n *WebhookNotification) {
+	if _, ok := h.events[n.Type]; !ok {
+		return
+	}
+	select {
+	case h.queueCh <- n:
+	default:
+		h.logger.Warnf("manager: webhook: queue of %s is full, dropping %s event of job %q",
+			h.config.URL, n.Type, n.JobID)
+	}
+}
+
+func (h *webhook) run(stopCh chan struct{}) {
+	for {
+		select {
+		case <-stopCh:
+			return
+		case n := <-h.queueCh:
+			h.deliver(n, stopCh)
+		}
+	}
+}
+
+// deliver posts the notification, retrying with an exponential backoff
+func (h *webhook) deliver(n *WebhookNotification, stopCh chan struct{}) {
+	retries := h.config.MaxRetries
+	if retries == 0 {
+		retries = defaultWebhookRetries
+	}
+
+	wait := webhookRetryBase
+	for attempt := 0; ; attempt++ {
+		err := h.post(n)
+		if err == nil {
+			return
+		}
+		if attempt >= retries {
+			h.logger.Errorf("manager: webhook: failed to deliver %s event of job %q to %s: %v",
+				n.Type, n.JobID, h.config.URL, err)
+			return
+		}
+		h.logger.Warnf("manager: webhook: failed to deliver %s event of job %q to %s, retrying in %v: %v",
+			n.Type, n.JobID, h.config.URL, wait, err)
+
+		select {
+		case <-stopCh:
+			return
+		case <-time.After(wait):
+		}
+		wait *= 2
+	}
+}
+
+func (h *webhook) post(n *WebhookNotification) error {
+	body, err := h.body(n)
+	if err != nil {
+		return err
+	}
+
+	u := h.config.URL
+	if h.config.Format == config.WebhookFormatDingTalk && h.config.Secret != "" {
+		u, err = dingTalkSignedURL(u, h.config.Secret, time.Now())
+		if err != nil {
+			return err
+		}
+	}
+
+	req, err := http.NewRequest("POST", u, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if h.config.Format != config.WebhookFormatDingTalk && h.config.Secret != "" {
+		req.Header.Set("X-Dtle-Signature", "sha256="+webhookSignature(h.config.Secret, body))
+	}
+
+	resp, err := h.client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("unexpected response code %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// body encodes the notification in the format of the webhook
+func (h *webhook) body(n *WebhookNotification) ([]byte, error) {
+	switch h.config.Format {
+	case config.WebhookFormatSlack:
+		return json.Marshal(map[string]string{
+			"text": webhookText(n),
+		})
+	case config.WebhookFormatDingTalk:
+		return json.Marshal(map[string]interface{}{
+			"msgtype": "text",
+			"text": map[string]string{
+				"content": webhookText(n),
+			},
+		})
+	default:
+		return json.Marshal(n)
+	}
+}
+
+// webhookText is the human readable message of the chat formats
+func webhookText(n *WebhookNotification) string {
+	name := n.JobName
+	if name == "" {
+		name = n.JobID
+	}
+	text := fmt.Sprintf("[dtle] job %q (%s/%s): %s", name, n.Region, n.Namespace, n.Type)
+	if n.Task != "" {
+		text += fmt.Sprintf(" on task %s", n.Task)
+	}
+	if n.Message != "" {
+		text += ": " + n.Message
+	}
+	return text
+}
+
+// webhookSignature returns the hex encoded HMAC-SHA256 of the body
+func webhookSignature(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// dingTalkSignedURL adds the timestamp and sign params DingTalk robots with
+// a secret require
+func dingTalkSignedURL(rawURL, secret string, now time.Time) (string, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", err
+	}
+	timestamp := strconv.FormatInt(now.UnixNano()/int64(time.Millisecond), 10)
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp + "\n" + secret))
+
+	q := u.Query()
+	q.Set("timestamp", timestamp)
+	q.Set("sign", base64.StdEncoding.EncodeToString(mac.Sum(nil)))
+	u.RawQuery = q.Encode()
+	return u.String(), nil
+}
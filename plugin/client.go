@@ -0,0 +1,130 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package plugin
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"golang.org/x/net/context"
+	"google.golang.org/grpc/status"
+
+	"github.com/actiontech/dtle/api/pb"
+)
+
+// launch starts a plugin with go-plugin, set by the build with the
+// "plugin" tag
+var launch func(path string) (*Client, error)
+
+// Client calls the driver of a started plugin
+type Client struct {
+	driver pb.DriverClient
+	// kill stops the plugin
+	kill func()
+}
+
+// Open starts the plugin of an executable
+func Open(path string) (*Client, error) {
+	if launch == nil {
+		return nil, fmt.Errorf("this build has no plugin support: build dtle with the %q tag", "plugin")
+	}
+	return launch(path)
+}
+
+// rpcError returns the error of a plugin without its gRPC code
+func rpcError(err error) error {
+	if err == nil {
+		return nil
+	}
+	if s, ok := status.FromError(err); ok {
+		return errors.New(s.Message())
+	}
+	return err
+}
+
+func encodeConfig(config map[string]interface{}) (string, error) {
+	data, err := json.Marshal(config)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// Validate checks the config of a task, and returns the error connecting
+// with it if any
+func (c *Client) Validate(taskType string, config map[string]interface{}) (string, error) {
+	configJSON, err := encodeConfig(config)
+	if err != nil {
+		return "", err
+	}
+	resp, err := c.driver.Validate(context.Background(), &pb.ValidateRequest{
+		Type:       taskType,
+		ConfigJson: configJSON,
+	})
+	if err != nil {
+		return "", rpcError(err)
+	}
+	return resp.ConnectionError, nil
+}
+
+func (c *Client) Start(task *Task) error {
+	configJSON, err := encodeConfig(task.Config)
+	if err != nil {
+		return err
+	}
+	_, err = c.driver.Start(context.Background(), &pb.StartRequest{
+		Id:         task.ID,
+		Type:       task.Type,
+		ConfigJson: configJSON,
+		Subject:    task.Subject,
+		Tp:         task.Tp,
+		MaxPayload: int64(task.MaxPayload),
+	})
+	return rpcError(err)
+}
+
+// Wait returns once a task has ended, with the error it failed with
+func (c *Client) Wait(id string) error {
+	resp, err := c.driver.Wait(context.Background(), &pb.TaskRequest{Id: id})
+	if err != nil {
+		return fmt.Errorf("plugin failed: %v", rpcError(err))
+	}
+	if resp.Error != "" {
+		return errors.New(resp.Error)
+	}
+	return nil
+}
+
+func (c *Client) Stats(id string) (*Stats, error) {
+	resp, err := c.driver.Stats(context.Background(), &pb.TaskRequest{Id: id})
+	if err != nil {
+		return nil, rpcError(err)
+	}
+	return &Stats{
+		Gtid:               resp.Gtid,
+		Stage:              resp.Stage,
+		ProgressPct:        resp.ProgressPct,
+		ETA:                resp.Eta,
+		Backlog:            resp.Backlog,
+		ExecMasterRowCount: resp.ExecMasterRowCount,
+		ExecMasterTxCount:  resp.ExecMasterTxCount,
+		ReadMasterRowCount: resp.ReadMasterRowCount,
+		ReadMasterTxCount:  resp.ReadMasterTxCount,
+	}, nil
+}
+
+func (c *Client) Shutdown(id string) error {
+	_, err := c.driver.Shutdown(context.Background(), &pb.TaskRequest{Id: id})
+	return rpcError(err)
+}
+
+// Close stops the plugin
+func (c *Client) Close() {
+	if c.kill != nil {
+		c.kill()
+	}
+}
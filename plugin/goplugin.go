@@ -0,0 +1,73 @@
+//go:build plugin
+// +build plugin
+
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package plugin
+
+import (
+	"context"
+	"os/exec"
+
+	goplugin "github.com/hashicorp/go-plugin"
+	"google.golang.org/grpc"
+
+	"github.com/actiontech/dtle/api/pb"
+)
+
+func init() {
+	launch = launchPlugin
+	serve = servePlugin
+}
+
+var handshake = goplugin.HandshakeConfig{
+	ProtocolVersion:  ProtocolVersion,
+	MagicCookieKey:   MagicCookieKey,
+	MagicCookieValue: MagicCookieValue,
+}
+
+// grpcPlugin is the go-plugin plugin of the Driver service
+type grpcPlugin struct {
+	goplugin.NetRPCUnsupportedPlugin
+	driver Driver
+}
+
+func (p *grpcPlugin) GRPCServer(broker *goplugin.GRPCBroker, s *grpc.Server) error {
+	pb.RegisterDriverServer(s, newServer(p.driver))
+	return nil
+}
+
+func (p *grpcPlugin) GRPCClient(ctx context.Context, broker *goplugin.GRPCBroker, c *grpc.ClientConn) (interface{}, error) {
+	return pb.NewDriverClient(c), nil
+}
+
+func launchPlugin(path string) (*Client, error) {
+	client := goplugin.NewClient(&goplugin.ClientConfig{
+		HandshakeConfig:  handshake,
+		Plugins:          map[string]goplugin.Plugin{pluginName: &grpcPlugin{}},
+		Cmd:              exec.Command(path),
+		AllowedProtocols: []goplugin.Protocol{goplugin.ProtocolGRPC},
+	})
+	rpcClient, err := client.Client()
+	if err != nil {
+		client.Kill()
+		return nil, err
+	}
+	raw, err := rpcClient.Dispense(pluginName)
+	if err != nil {
+		client.Kill()
+		return nil, err
+	}
+	return &Client{driver: raw.(pb.DriverClient), kill: client.Kill}, nil
+}
+
+func servePlugin(d Driver) {
+	goplugin.Serve(&goplugin.ServeConfig{
+		HandshakeConfig: handshake,
+		Plugins:         map[string]goplugin.Plugin{pluginName: &grpcPlugin{driver: d}},
+		GRPCServer:      goplugin.DefaultGRPCServer,
+	})
+}
@@ -0,0 +1,128 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+// Package plugin serves source and sink drivers out of the dtle tree. A
+// plugin is an executable named dtle-driver-<name> in the plugin directory
+// of the agents, which serves the driver <name> by calling Serve in its
+// main function. dtle starts the plugin for each task of its driver and
+// calls it with hashicorp/go-plugin over the gRPC protocol of
+// api/pb/driver.proto.
+//
+// Both dtle and the plugins must be built with the "plugin" tag, which
+// includes go-plugin.
+package plugin
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	// FilePrefix is the prefix of the file names of the plugins
+	FilePrefix = "dtle-driver-"
+
+	// ProtocolVersion is the version of the protocol of the plugins, which
+	// must match between dtle and a plugin
+	ProtocolVersion = 1
+
+	// MagicCookieKey and MagicCookieValue are set in the environment of
+	// the plugins started by dtle, so that they can tell they are
+	MagicCookieKey   = "DTLE_PLUGIN_MAGIC_COOKIE"
+	MagicCookieValue = "3b1e3a2c0f5d4e6a8c7b9d1f2a4c6e8b"
+
+	// pluginName is the name of the driver in the go-plugin plugin set
+	pluginName = "driver"
+)
+
+// Driver is a source or sink driver served by a plugin
+type Driver interface {
+	// Validate checks the config of a task
+	Validate(taskType string, config map[string]interface{}) error
+
+	// CheckConnection tries to connect with the config of a task
+	CheckConnection(taskType string, config map[string]interface{}) error
+
+	// Start starts a task, which runs until it fails or is shut down
+	Start(task *Task) (Handle, error)
+}
+
+// Task is a task of a job to start. Its config is decoded from JSON, so
+// numbers are float64: mapstructure.WeakDecode reads it into a struct.
+type Task struct {
+	ID string
+	// Type is either Src or Dest
+	Type   string
+	Config map[string]interface{}
+
+	// Subject is the NATS subject the source and the sink of the job
+	// exchange their messages on, at the NatsAddr of the config, and
+	// MaxPayload the maximum size of a message
+	Subject    string
+	Tp         string
+	MaxPayload int
+}
+
+// Handle is a started task
+type Handle interface {
+	// Wait returns once the task has ended, with the error it failed with
+	Wait() error
+
+	Stats() (*Stats, error)
+
+	// Shutdown stops the task
+	Shutdown() error
+}
+
+// Stats are the statistics of a task, shown in the status of its job
+type Stats struct {
+	// Gtid is the GTID set a sink has applied, saved as the checkpoint of
+	// the job and set as the Gtid of the config when the task restarts
+	Gtid               string
+	Stage              string
+	ProgressPct        string
+	ETA                string
+	Backlog            string
+	ExecMasterRowCount int64
+	ExecMasterTxCount  int64
+	ReadMasterRowCount int64
+	ReadMasterTxCount  int64
+}
+
+// Discover returns the plugins in a directory by driver name
+func Discover(dir string) (map[string]string, error) {
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	plugins := make(map[string]string)
+	for _, f := range files {
+		name := strings.TrimPrefix(f.Name(), FilePrefix)
+		if name == f.Name() || name == "" || f.IsDir() || f.Mode()&0111 == 0 {
+			continue
+		}
+		plugins[name] = filepath.Join(dir, f.Name())
+	}
+	return plugins, nil
+}
+
+// serve serves a driver with go-plugin, set by the build with the "plugin"
+// tag
+var serve func(d Driver)
+
+// Serve serves a driver to dtle. It is called by the main function of a
+// plugin and returns when dtle stops the plugin.
+func Serve(d Driver) {
+	if serve == nil {
+		fmt.Fprintf(os.Stderr, "this build has no plugin support: build the plugin with the %q tag\n", "plugin")
+		os.Exit(1)
+	}
+	serve(d)
+}
@@ -0,0 +1,166 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package plugin
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"google.golang.org/grpc"
+
+	"github.com/actiontech/dtle/api/pb"
+)
+
+type testDriver struct{}
+
+func (testDriver) Validate(taskType string, config map[string]interface{}) error {
+	if config["Topic"] == nil {
+		return fmt.Errorf("Topic is required")
+	}
+	return nil
+}
+
+func (testDriver) CheckConnection(taskType string, config map[string]interface{}) error {
+	return fmt.Errorf("connection refused")
+}
+
+func (testDriver) Start(task *Task) (Handle, error) {
+	if task.Type != "Dest" {
+		return nil, fmt.Errorf("can only be used on 'Dest'")
+	}
+	return &testHandle{task: task, done: make(chan struct{})}, nil
+}
+
+type testHandle struct {
+	task *Task
+	done chan struct{}
+}
+
+func (h *testHandle) Wait() error {
+	<-h.done
+	return fmt.Errorf("stopped")
+}
+
+func (h *testHandle) Stats() (*Stats, error) {
+	return &Stats{
+		Gtid:              h.task.Config["Gtid"].(string),
+		Stage:             h.task.Subject,
+		ExecMasterTxCount: int64(h.task.Config["Batch"].(float64)) + int64(h.task.MaxPayload),
+	}, nil
+}
+
+func (h *testHandle) Shutdown() error {
+	close(h.done)
+	return nil
+}
+
+func TestClient(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := grpc.NewServer()
+	pb.RegisterDriverServer(s, newServer(testDriver{}))
+	go s.Serve(l)
+	defer s.Stop()
+
+	conn, err := grpc.Dial(l.Addr().String(), grpc.WithInsecure())
+	if err != nil {
+		t.Fatal(err)
+	}
+	closed := false
+	c := &Client{driver: pb.NewDriverClient(conn), kill: func() { closed = true; conn.Close() }}
+	defer c.Close()
+
+	if _, err := c.Validate("Dest", nil); err == nil || err.Error() != "Topic is required" {
+		t.Errorf("Validate() without Topic, error = %v", err)
+	}
+	if connErr, err := c.Validate("Dest", map[string]interface{}{"Topic": "t"}); err != nil || connErr != "connection refused" {
+		t.Errorf("Validate() = %q, %v", connErr, err)
+	}
+
+	if err := c.Start(&Task{ID: "a", Type: "Src"}); err == nil {
+		t.Errorf("Start() of a source, got no error")
+	}
+	task := &Task{ID: "a", Type: "Dest", Subject: "job1", MaxPayload: 2,
+		Config: map[string]interface{}{"Gtid": "uuid:1-5", "Batch": 40}}
+	if err := c.Start(task); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Start(task); err == nil {
+		t.Errorf("Start() of a started task, got no error")
+	}
+	stats, err := c.Stats("a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (&Stats{Gtid: "uuid:1-5", Stage: "job1", ExecMasterTxCount: 42}); !reflect.DeepEqual(stats, want) {
+		t.Errorf("Stats() = %+v, want %+v", stats, want)
+	}
+	if _, err := c.Stats("b"); err == nil {
+		t.Errorf("Stats() of an unknown task, got no error")
+	}
+
+	waitErr := make(chan error)
+	go func() {
+		waitErr <- c.Wait("a")
+	}()
+	if err := c.Shutdown("a"); err != nil {
+		t.Fatal(err)
+	}
+	if err := <-waitErr; err == nil || err.Error() != "stopped" {
+		t.Errorf("Wait() error = %v, want stopped", err)
+	}
+
+	c.Close()
+	if !closed {
+		t.Errorf("Close() did not stop the plugin")
+	}
+}
+
+func TestDiscover(t *testing.T) {
+	dir, err := ioutil.TempDir("", "plugins")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	for name, mode := range map[string]os.FileMode{
+		"dtle-driver-Redis":    0755,
+		"dtle-driver-Postgres": 0755,
+		"dtle-driver-notes":    0644,
+		"dtle-driver-":         0755,
+		"other":                0755,
+	} {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), nil, mode); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Mkdir(filepath.Join(dir, "dtle-driver-dir"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	plugins, err := Discover(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{
+		"Redis":    filepath.Join(dir, "dtle-driver-Redis"),
+		"Postgres": filepath.Join(dir, "dtle-driver-Postgres"),
+	}
+	if !reflect.DeepEqual(plugins, want) {
+		t.Errorf("Discover() = %v, want %v", plugins, want)
+	}
+
+	if plugins, err := Discover(filepath.Join(dir, "none")); err != nil || len(plugins) != 0 {
+		t.Errorf("Discover() of a missing directory = %v, %v", plugins, err)
+	}
+}
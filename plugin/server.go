@@ -0,0 +1,137 @@
+/*
+ * Copyright (C) 2016-2018. ActionTech.
+ * License: MPL version 2: https://www.mozilla.org/en-US/MPL/2.0 .
+ */
+
+package plugin
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+
+	"golang.org/x/net/context"
+
+	"github.com/actiontech/dtle/api/pb"
+)
+
+// server serves a driver as the Driver service
+type server struct {
+	driver Driver
+
+	lock    sync.Mutex
+	handles map[string]Handle
+}
+
+func newServer(d Driver) *server {
+	return &server{
+		driver:  d,
+		handles: make(map[string]Handle),
+	}
+}
+
+func decodeConfig(configJSON string) (map[string]interface{}, error) {
+	config := make(map[string]interface{})
+	if configJSON == "" {
+		return config, nil
+	}
+	if err := json.Unmarshal([]byte(configJSON), &config); err != nil {
+		return nil, fmt.Errorf("invalid config: %v", err)
+	}
+	return config, nil
+}
+
+func (s *server) handle(id string) (Handle, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	h, ok := s.handles[id]
+	if !ok {
+		return nil, fmt.Errorf("unknown task %q", id)
+	}
+	return h, nil
+}
+
+func (s *server) Validate(ctx context.Context, req *pb.ValidateRequest) (*pb.ValidateResponse, error) {
+	config, err := decodeConfig(req.ConfigJson)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.driver.Validate(req.Type, config); err != nil {
+		return nil, err
+	}
+	resp := &pb.ValidateResponse{}
+	if err := s.driver.CheckConnection(req.Type, config); err != nil {
+		resp.ConnectionError = err.Error()
+	}
+	return resp, nil
+}
+
+func (s *server) Start(ctx context.Context, req *pb.StartRequest) (*pb.TaskResponse, error) {
+	config, err := decodeConfig(req.ConfigJson)
+	if err != nil {
+		return nil, err
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if _, ok := s.handles[req.Id]; ok {
+		return nil, fmt.Errorf("task %q is already started", req.Id)
+	}
+	h, err := s.driver.Start(&Task{
+		ID:         req.Id,
+		Type:       req.Type,
+		Config:     config,
+		Subject:    req.Subject,
+		Tp:         req.Tp,
+		MaxPayload: int(req.MaxPayload),
+	})
+	if err != nil {
+		return nil, err
+	}
+	s.handles[req.Id] = h
+	return &pb.TaskResponse{}, nil
+}
+
+func (s *server) Wait(ctx context.Context, req *pb.TaskRequest) (*pb.WaitResponse, error) {
+	h, err := s.handle(req.Id)
+	if err != nil {
+		return nil, err
+	}
+	resp := &pb.WaitResponse{}
+	if err := h.Wait(); err != nil {
+		resp.Error = err.Error()
+	}
+	return resp, nil
+}
+
+func (s *server) Stats(ctx context.Context, req *pb.TaskRequest) (*pb.StatsResponse, error) {
+	h, err := s.handle(req.Id)
+	if err != nil {
+		return nil, err
+	}
+	stats, err := h.Stats()
+	if err != nil {
+		return nil, err
+	}
+	return &pb.StatsResponse{
+		Gtid:               stats.Gtid,
+		Stage:              stats.Stage,
+		ProgressPct:        stats.ProgressPct,
+		Eta:                stats.ETA,
+		Backlog:            stats.Backlog,
+		ExecMasterRowCount: stats.ExecMasterRowCount,
+		ExecMasterTxCount:  stats.ExecMasterTxCount,
+		ReadMasterRowCount: stats.ReadMasterRowCount,
+		ReadMasterTxCount:  stats.ReadMasterTxCount,
+	}, nil
+}
+
+func (s *server) Shutdown(ctx context.Context, req *pb.TaskRequest) (*pb.TaskResponse, error) {
+	h, err := s.handle(req.Id)
+	if err != nil {
+		return nil, err
+	}
+	if err := h.Shutdown(); err != nil {
+		return nil, err
+	}
+	return &pb.TaskResponse{}, nil
+}